@@ -0,0 +1,76 @@
+package admin
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"aspen/config"
+)
+
+// RunCLI 执行 `aspen admin [选项] <命令> [参数]`，返回进程退出码。
+// 优先通过 socket 交给运行中的进程执行；socket 不可用（进程未运行）或指定 -offline 时直接操作数据库
+func RunCLI(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("admin", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	dbPath := fs.String("db", "config.db", "数据库路径（离线模式）")
+	socketPath := fs.String("socket", DefaultSocketPath(), "运行中进程的管理 socket 路径")
+	offline := fs.Bool("offline", false, "不连接运行中的进程，直接操作数据库")
+	fs.Usage = func() {
+		fmt.Fprintf(stderr, "用法: aspen admin [选项] <命令> [参数]\n\n%s\n\n选项:\n", commandUsage)
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return 2
+	}
+	req := Request{Command: fs.Arg(0), Args: fs.Args()[1:]}
+
+	var resp *Response
+	if !*offline {
+		var err error
+		resp, err = Call(*socketPath, req)
+		if err != nil && !errors.Is(err, errSocketUnavailable) {
+			fmt.Fprintf(stderr, "❌ %v\n", err)
+			return 1
+		}
+		if err != nil {
+			fmt.Fprintf(stderr, "进程未运行（%v），直接操作数据库 %s\n", err, *dbPath)
+		}
+	}
+	if resp == nil {
+		var err error
+		resp, err = executeOffline(*dbPath, req)
+		if err != nil {
+			fmt.Fprintf(stderr, "❌ %v\n", err)
+			return 1
+		}
+	}
+
+	if !resp.OK {
+		fmt.Fprintf(stderr, "❌ %s\n", resp.Error)
+		return 1
+	}
+	fmt.Fprint(stdout, resp.Output)
+	return 0
+}
+
+// executeOffline 直接打开数据库执行命令（与启动时相同的建表/迁移流程）
+func executeOffline(dbPath string, req Request) (*Response, error) {
+	if _, err := os.Stat(dbPath); err != nil {
+		return nil, fmt.Errorf("数据库 %s 不可用: %w", dbPath, err)
+	}
+	database, err := config.NewDatabase(dbPath)
+	if err != nil {
+		return nil, err
+	}
+	defer database.Close()
+
+	resp := NewOfflineService(database).Execute(req)
+	return &resp, nil
+}
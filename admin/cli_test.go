@@ -0,0 +1,119 @@
+package admin
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"aspen/config"
+	"aspen/manager"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// runOffline 在进程未运行（socket 不存在）时执行 CLI 命令
+func runOffline(t *testing.T, dir string, args ...string) (code int, stdout, stderr string) {
+	t.Helper()
+	var out, errOut bytes.Buffer
+	flags := []string{"-db", filepath.Join(dir, "config.db"), "-socket", filepath.Join(dir, "missing.sock")}
+	code = RunCLI(append(flags, args...), &out, &errOut)
+	return code, out.String(), errOut.String()
+}
+
+// TestRunCLI_OfflineKillSwitchStopsTraders 测试离线模式直接操作数据库：开启全局交易开关把运行状态的交易员标记为停止，并记录审计日志
+func TestRunCLI_OfflineKillSwitchStopsTraders(t *testing.T) {
+	dir := t.TempDir()
+	db := newTestDatabase(t, dir)
+	require.NoError(t, db.CreateTrader(&config.TraderRecord{ID: "u1_running", UserID: "u1", Name: "running", IsRunning: true}))
+	require.NoError(t, db.CreateTrader(&config.TraderRecord{ID: "u1_idle", UserID: "u1", Name: "idle"}))
+	db.Close()
+
+	code, stdout, stderr := runOffline(t, dir, "kill-switch", "on")
+	require.Equal(t, 0, code, stderr)
+	assert.Contains(t, stderr, "直接操作数据库")
+	assert.Contains(t, stdout, "全局交易开关: on")
+	assert.Contains(t, stdout, "已停止 1 个交易员: u1_running")
+
+	code, stdout, _ = runOffline(t, dir, "kill-switch")
+	require.Equal(t, 0, code)
+	assert.Contains(t, stdout, "全局交易开关: on")
+
+	db, err := config.NewDatabase(filepath.Join(dir, "config.db"))
+	require.NoError(t, err)
+	defer db.Close()
+	assert.True(t, manager.KillSwitchEnabled(db))
+	traders, err := db.GetTraders("u1")
+	require.NoError(t, err)
+	for _, trader := range traders {
+		assert.False(t, trader.IsRunning, trader.ID)
+	}
+	entries, err := db.ListAdminAuditLog(10)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, LocalAdminActor, entries[0].Actor)
+	assert.Equal(t, "kill_switch", entries[0].Action)
+	assert.Contains(t, entries[0].Details, "u1_running")
+}
+
+// TestRunCLI_OfflineStopTraderAndUsers 测试离线模式停止交易员（已停止时报错）和列出用户
+func TestRunCLI_OfflineStopTraderAndUsers(t *testing.T) {
+	dir := t.TempDir()
+	db := newTestDatabase(t, dir)
+	require.NoError(t, db.CreateTrader(&config.TraderRecord{ID: "u1_t", UserID: "u1", Name: "t", IsRunning: true}))
+	db.Close()
+
+	code, stdout, stderr := runOffline(t, dir, "stop-trader", "u1_t")
+	require.Equal(t, 0, code, stderr)
+	assert.Contains(t, stdout, "交易员 u1_t 已停止")
+
+	code, _, stderr = runOffline(t, dir, "stop-trader", "u1_t")
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, manager.ErrTraderNotRunning.Error())
+
+	code, _, stderr = runOffline(t, dir, "stop-trader", "nope")
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, "不存在")
+
+	code, stdout, _ = runOffline(t, dir, "users")
+	require.Equal(t, 0, code)
+	assert.Contains(t, stdout, "alice@example.com")
+}
+
+// TestRunCLI_OfflineBackupAndLoadReport 测试离线模式备份数据库，加载报告只在进程运行时可用
+func TestRunCLI_OfflineBackupAndLoadReport(t *testing.T) {
+	dir := t.TempDir()
+	newTestDatabase(t, dir).Close()
+	dest := filepath.Join(dir, "backups", "snapshot.db")
+
+	code, stdout, stderr := runOffline(t, dir, "backup", dest)
+	require.Equal(t, 0, code, stderr)
+	assert.Contains(t, stdout, dest)
+
+	backup, err := config.NewDatabase(dest)
+	require.NoError(t, err)
+	user, err := backup.GetUserByEmail("alice@example.com")
+	backup.Close()
+	require.NoError(t, err)
+	assert.Equal(t, "u1", user.ID)
+
+	code, _, stderr = runOffline(t, dir, "backup", dest)
+	assert.Equal(t, 1, code, "目标文件已存在时不覆盖")
+	assert.Contains(t, stderr, "已存在")
+
+	code, _, stderr = runOffline(t, dir, "load-report")
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, ErrOffline.Error())
+}
+
+// TestRunCLI_MissingDatabase 测试离线模式不会凭空创建数据库
+func TestRunCLI_MissingDatabase(t *testing.T) {
+	dir := t.TempDir()
+	code, _, stderr := runOffline(t, dir, "users")
+	assert.Equal(t, 1, code)
+	assert.True(t, strings.Contains(stderr, "不可用"), stderr)
+	_, err := os.Stat(filepath.Join(dir, "config.db"))
+	assert.True(t, os.IsNotExist(err))
+}
@@ -0,0 +1,131 @@
+package admin
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// Request 管理命令请求（socket 协议：每个连接一行 JSON 请求、一行 JSON 响应）
+type Request struct {
+	Command string   `json:"command"`
+	Args    []string `json:"args,omitempty"`
+}
+
+// Response 管理命令响应
+type Response struct {
+	OK     bool   `json:"ok"`
+	Output string `json:"output,omitempty"` // 供终端打印的结果
+	Error  string `json:"error,omitempty"`
+}
+
+// commandUsage 命令列表（用于帮助信息）
+const commandUsage = `命令:
+  users                      列出用户
+  reset-otp <用户ID|邮箱>     重置用户OTP，打印新的 otpauth:// 链接
+  stop-trader <交易员ID>      强制停止交易员
+  kill-switch [on|off]       查看或切换全局交易开关（开启时停止所有交易员并禁止启动）
+  backup [目标路径]           备份数据库（默认 backups/config-<时间>.db）
+  load-report                打印交易员加载报告（仅进程运行时可用）`
+
+// Execute 执行管理命令（socket 服务端和离线模式共用），操作者记为 local-admin
+func (s *Service) Execute(req Request) Response {
+	output, err := s.execute(req)
+	if err != nil {
+		return Response{Error: err.Error()}
+	}
+	return Response{OK: true, Output: output}
+}
+
+func (s *Service) execute(req Request) (string, error) {
+	switch req.Command {
+	case "users":
+		users, err := s.ListUsers()
+		if err != nil {
+			return "", err
+		}
+		var buf bytes.Buffer
+		w := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tEMAIL\tOTP_VERIFIED\tCREATED_AT")
+		for _, u := range users {
+			fmt.Fprintf(w, "%s\t%s\t%t\t%s\n", u.ID, u.Email, u.OTPVerified, u.CreatedAt.UTC().Format(time.RFC3339))
+		}
+		w.Flush()
+		return buf.String(), nil
+
+	case "reset-otp":
+		if len(req.Args) != 1 {
+			return "", fmt.Errorf("用法: reset-otp <用户ID|邮箱>")
+		}
+		reset, err := s.ResetOTP(LocalAdminActor, req.Args[0])
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("已重置用户 %s (%s) 的OTP，旧验证器已失效\n%s\n", reset.UserID, reset.Email, reset.ProvisioningURI), nil
+
+	case "stop-trader":
+		if len(req.Args) != 1 {
+			return "", fmt.Errorf("用法: stop-trader <交易员ID>")
+		}
+		if err := s.StopTrader(LocalAdminActor, req.Args[0]); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("交易员 %s 已停止\n", req.Args[0]), nil
+
+	case "kill-switch":
+		if len(req.Args) == 0 {
+			return fmt.Sprintf("全局交易开关: %s\n", onOff(s.KillSwitchEnabled())), nil
+		}
+		if len(req.Args) != 1 || (req.Args[0] != "on" && req.Args[0] != "off") {
+			return "", fmt.Errorf("用法: kill-switch [on|off]")
+		}
+		enabled := req.Args[0] == "on"
+		stopped, err := s.SetKillSwitch(LocalAdminActor, enabled)
+		if err != nil {
+			return "", err
+		}
+		out := fmt.Sprintf("全局交易开关: %s\n", onOff(enabled))
+		if len(stopped) > 0 {
+			out += fmt.Sprintf("已停止 %d 个交易员: %s\n", len(stopped), strings.Join(stopped, ", "))
+		}
+		return out, nil
+
+	case "backup":
+		if len(req.Args) > 1 {
+			return "", fmt.Errorf("用法: backup [目标路径]")
+		}
+		dest := ""
+		if len(req.Args) == 1 {
+			dest = req.Args[0]
+		}
+		path, err := s.Backup(LocalAdminActor, dest)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("数据库已备份到 %s\n", path), nil
+
+	case "load-report":
+		report, err := s.LoadReport()
+		if err != nil {
+			return "", err
+		}
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return "", err
+		}
+		return report.Summary() + "\n" + string(data) + "\n", nil
+
+	default:
+		return "", fmt.Errorf("未知命令 %q\n%s", req.Command, commandUsage)
+	}
+}
+
+func onOff(enabled bool) string {
+	if enabled {
+		return "on"
+	}
+	return "off"
+}
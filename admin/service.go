@@ -0,0 +1,193 @@
+// Package admin 本地管理操作：API 服务不可用或管理员无法登录（丢失OTP、token过期）时，
+// 通过同一二进制的 `aspen admin <命令>` 经 unix socket 操作运行中的进程，进程未运行时直接操作数据库
+package admin
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"aspen/auth"
+	"aspen/config"
+	"aspen/manager"
+)
+
+// LocalAdminActor 本地管理CLI在审计日志中的操作者
+const LocalAdminActor = "local-admin"
+
+// ErrOffline 进程未运行时无法执行的命令（如加载报告只存在于运行中的进程）
+var ErrOffline = errors.New("进程未运行，离线模式下不可用")
+
+// Service 管理操作（本地管理CLI和管理员HTTP接口共用，保证行为一致）
+type Service struct {
+	db      *config.Database
+	tm      *manager.TraderManager
+	offline bool
+}
+
+// NewService 创建运行中进程的管理服务
+func NewService(db *config.Database, tm *manager.TraderManager) *Service {
+	return &Service{db: db, tm: tm}
+}
+
+// NewOfflineService 创建离线管理服务（进程未运行，直接操作数据库，没有已加载的交易员）
+func NewOfflineService(db *config.Database) *Service {
+	return &Service{db: db, tm: manager.NewTraderManager(), offline: true}
+}
+
+// UserSummary 用户概要
+type UserSummary struct {
+	ID          string    `json:"id"`
+	Email       string    `json:"email"`
+	OTPVerified bool      `json:"otp_verified"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// ListUsers 列出所有用户
+func (s *Service) ListUsers() ([]UserSummary, error) {
+	userIDs, err := s.db.GetAllUsers()
+	if err != nil {
+		return nil, fmt.Errorf("获取用户列表失败: %w", err)
+	}
+	users := make([]UserSummary, 0, len(userIDs))
+	for _, id := range userIDs {
+		user, err := s.db.GetUserByID(id)
+		if err != nil {
+			return nil, fmt.Errorf("获取用户 %s 失败: %w", id, err)
+		}
+		users = append(users, UserSummary{ID: user.ID, Email: user.Email, OTPVerified: user.OTPVerified, CreatedAt: user.CreatedAt})
+	}
+	return users, nil
+}
+
+// OTPReset OTP重置结果（新密钥的 otpauth:// 链接交给用户导入验证器）
+type OTPReset struct {
+	UserID          string `json:"user_id"`
+	Email           string `json:"email"`
+	OTPSecret       string `json:"otp_secret"`
+	ProvisioningURI string `json:"provisioning_uri"`
+}
+
+// ResetOTP 为用户（ID或邮箱）生成新的OTP密钥，旧验证器立即失效
+func (s *Service) ResetOTP(actor, userRef string) (*OTPReset, error) {
+	user, err := s.findUser(userRef)
+	if err != nil {
+		return nil, err
+	}
+	secret, err := auth.GenerateOTPSecret()
+	if err != nil {
+		return nil, fmt.Errorf("OTP密钥生成失败: %w", err)
+	}
+	if err := s.db.ResetUserOTP(user.ID, secret); err != nil {
+		return nil, fmt.Errorf("重置OTP失败: %w", err)
+	}
+	s.audit(actor, "reset_otp", user.ID, user.Email)
+	return &OTPReset{
+		UserID:          user.ID,
+		Email:           user.Email,
+		OTPSecret:       secret,
+		ProvisioningURI: auth.GetOTPQRCodeURL(secret, user.Email),
+	}, nil
+}
+
+// findUser 按用户ID或邮箱查找用户
+func (s *Service) findUser(userRef string) (*config.User, error) {
+	user, err := s.db.GetUserByID(userRef)
+	if errors.Is(err, sql.ErrNoRows) && strings.Contains(userRef, "@") {
+		user, err = s.db.GetUserByEmail(userRef)
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("用户 %s 不存在", userRef)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("获取用户失败: %w", err)
+	}
+	return user, nil
+}
+
+// StopTrader 强制停止交易员（与停止交易员接口使用同一逻辑）
+func (s *Service) StopTrader(actor, traderID string) error {
+	userID, err := s.db.GetTraderOwner(traderID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("交易员 %s 不存在", traderID)
+	}
+	if err != nil {
+		return fmt.Errorf("获取交易员失败: %w", err)
+	}
+	if err := s.tm.StopTrader(s.db, userID, traderID); err != nil {
+		return err
+	}
+	s.audit(actor, "stop_trader", traderID, "user_id="+userID)
+	return nil
+}
+
+// KillSwitchEnabled 全局交易开关是否已开启
+func (s *Service) KillSwitchEnabled() bool {
+	return manager.KillSwitchEnabled(s.db)
+}
+
+// SetKillSwitch 开启或关闭全局交易开关，返回被停止的交易员ID
+func (s *Service) SetKillSwitch(actor string, enabled bool) ([]string, error) {
+	stopped, err := s.tm.SetKillSwitch(s.db, enabled)
+	if err != nil {
+		return stopped, err
+	}
+	s.audit(actor, "kill_switch", "", fmt.Sprintf("enabled=%t stopped=%s", enabled, strings.Join(stopped, ",")))
+	return stopped, nil
+}
+
+// FlattenAll 一键清仓：开启全局交易开关并平掉所有交易员的持仓
+func (s *Service) FlattenAll(actor string) ([]string, []manager.FlattenResult, error) {
+	if s.offline {
+		return nil, nil, ErrOffline
+	}
+	stopped, results, err := s.tm.FlattenAll(s.db)
+	if err != nil {
+		return stopped, results, err
+	}
+	closed, failed := 0, 0
+	for _, r := range results {
+		closed += len(r.Closed)
+		if r.Error != "" {
+			failed++
+		}
+	}
+	s.audit(actor, "flatten_all", "", fmt.Sprintf("stopped=%s closed=%d failed_traders=%d", strings.Join(stopped, ","), closed, failed))
+	return stopped, results, nil
+}
+
+// DefaultBackupPath 默认备份路径：backups/config-<UTC时间>.db
+func DefaultBackupPath(now time.Time) string {
+	return filepath.Join("backups", "config-"+now.UTC().Format("20060102-150405")+".db")
+}
+
+// Backup 备份数据库到 destPath（为空时使用默认路径），返回备份文件路径
+func (s *Service) Backup(actor, destPath string) (string, error) {
+	if destPath == "" {
+		destPath = DefaultBackupPath(time.Now())
+	}
+	if err := s.db.Backup(destPath); err != nil {
+		return "", err
+	}
+	s.audit(actor, "backup", destPath, "")
+	return destPath, nil
+}
+
+// LoadReport 交易员加载报告（只有运行中的进程才有）
+func (s *Service) LoadReport() (*manager.LoadReport, error) {
+	if s.offline {
+		return nil, ErrOffline
+	}
+	return s.tm.GetLoadReport(), nil
+}
+
+// audit 写入审计日志（失败不影响操作本身）
+func (s *Service) audit(actor, action, target, details string) {
+	if err := s.db.RecordAdminAudit(actor, action, target, details); err != nil {
+		log.Printf("⚠️  记录审计日志失败 (%s %s): %v", action, target, err)
+	}
+}
@@ -0,0 +1,126 @@
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SocketEnvName 管理 socket 路径的环境变量（未设置时为工作目录下的 admin.sock）
+const SocketEnvName = "ATRADE_ADMIN_SOCKET"
+
+// connTimeout 单个管理连接的读写超时（备份大数据库需要一定时间）
+const connTimeout = 5 * time.Minute
+
+// DefaultSocketPath 管理 socket 路径
+func DefaultSocketPath() string {
+	if path := strings.TrimSpace(os.Getenv(SocketEnvName)); path != "" {
+		return path
+	}
+	return "admin.sock"
+}
+
+// SocketServer 管理命令的 unix socket 服务。
+// 鉴权依赖文件权限：socket 文件权限为 0600，只有运行进程的系统用户（及 root）可以连接
+type SocketServer struct {
+	path     string
+	service  *Service
+	listener net.Listener
+	wg       sync.WaitGroup
+}
+
+// ListenSocket 在 path 上监听管理命令。遗留的 socket 文件（进程异常退出）会被清理；已有进程在监听时返回错误
+func ListenSocket(path string, service *Service) (*SocketServer, error) {
+	if _, err := os.Stat(path); err == nil {
+		if conn, err := net.DialTimeout("unix", path, time.Second); err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("管理 socket %s 已被其他进程使用", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, fmt.Errorf("清理遗留的管理 socket 失败: %w", err)
+		}
+	}
+
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("监听管理 socket 失败: %w", err)
+	}
+	if err := os.Chmod(path, 0600); err != nil {
+		listener.Close()
+		return nil, fmt.Errorf("设置管理 socket 权限失败: %w", err)
+	}
+
+	s := &SocketServer{path: path, service: service, listener: listener}
+	s.wg.Add(1)
+	go s.serve()
+	return s, nil
+}
+
+// Close 停止监听并删除 socket 文件（等待处理中的命令完成）
+func (s *SocketServer) Close() error {
+	err := s.listener.Close()
+	s.wg.Wait()
+	return err
+}
+
+func (s *SocketServer) serve() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				log.Printf("⚠️  管理 socket 接受连接失败: %v", err)
+			}
+			return
+		}
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.handle(conn)
+		}()
+	}
+}
+
+// handle 处理一个连接：读取一个请求，执行后写回响应
+func (s *SocketServer) handle(conn net.Conn) {
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(connTimeout))
+
+	var req Request
+	if err := json.NewDecoder(conn).Decode(&req); err != nil {
+		json.NewEncoder(conn).Encode(Response{Error: fmt.Sprintf("无效的请求: %v", err)})
+		return
+	}
+	log.Printf("🔧 本地管理命令: %s %s", req.Command, strings.Join(req.Args, " "))
+	if err := json.NewEncoder(conn).Encode(s.service.Execute(req)); err != nil {
+		log.Printf("⚠️  管理 socket 写回响应失败: %v", err)
+	}
+}
+
+// errSocketUnavailable 无法连接管理 socket（进程未运行）
+var errSocketUnavailable = errors.New("无法连接管理 socket")
+
+// Call 通过 socket 向运行中的进程发送命令。无法连接时返回的错误包装 errSocketUnavailable
+func Call(path string, req Request) (*Response, error) {
+	conn, err := net.DialTimeout("unix", path, 2*time.Second)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errSocketUnavailable, err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(connTimeout))
+
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		return nil, fmt.Errorf("发送管理命令失败: %w", err)
+	}
+	var resp Response
+	if err := json.NewDecoder(conn).Decode(&resp); err != nil {
+		return nil, fmt.Errorf("读取管理命令响应失败: %w", err)
+	}
+	return &resp, nil
+}
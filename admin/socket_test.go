@@ -0,0 +1,128 @@
+package admin
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"aspen/config"
+	"aspen/manager"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestDatabase 创建临时数据库并写入一个已验证OTP的用户
+func newTestDatabase(t *testing.T, dir string) *config.Database {
+	t.Helper()
+	db, err := config.NewDatabase(filepath.Join(dir, "config.db"))
+	require.NoError(t, err)
+	require.NoError(t, db.CreateUser(&config.User{ID: "u1", Email: "alice@example.com", OTPSecret: "OLDSECRET", OTPVerified: true}))
+	return db
+}
+
+// startTestSocket 为运行中的进程（已创建的交易员管理器）启动管理 socket
+func startTestSocket(t *testing.T) (string, *config.Database) {
+	t.Helper()
+	dir := t.TempDir()
+	db := newTestDatabase(t, dir)
+	path := filepath.Join(dir, "admin.sock")
+	server, err := ListenSocket(path, NewService(db, manager.NewTraderManager()))
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		server.Close()
+		db.Close()
+	})
+	return path, db
+}
+
+// TestSocket_ResetOTP 测试通过 socket 重置OTP：返回新的 otpauth 链接、更新数据库并以 local-admin 记录审计日志
+func TestSocket_ResetOTP(t *testing.T) {
+	path, db := startTestSocket(t)
+
+	resp, err := Call(path, Request{Command: "reset-otp", Args: []string{"alice@example.com"}})
+	require.NoError(t, err)
+	require.True(t, resp.OK, resp.Error)
+	assert.Contains(t, resp.Output, "otpauth://totp/")
+
+	user, err := db.GetUserByID("u1")
+	require.NoError(t, err)
+	assert.NotEqual(t, "OLDSECRET", user.OTPSecret)
+	assert.True(t, user.OTPVerified)
+	assert.Contains(t, resp.Output, "secret="+user.OTPSecret)
+
+	entries, err := db.ListAdminAuditLog(10)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, LocalAdminActor, entries[0].Actor)
+	assert.Equal(t, "reset_otp", entries[0].Action)
+	assert.Equal(t, "u1", entries[0].Target)
+}
+
+// TestSocket_ErrorsAndLoadReport 测试命令失败时返回错误响应，运行中的进程可以查看加载报告
+func TestSocket_ErrorsAndLoadReport(t *testing.T) {
+	path, _ := startTestSocket(t)
+
+	resp, err := Call(path, Request{Command: "reset-otp", Args: []string{"nobody@example.com"}})
+	require.NoError(t, err)
+	assert.False(t, resp.OK)
+	assert.Contains(t, resp.Error, "不存在")
+
+	resp, err = Call(path, Request{Command: "frobnicate"})
+	require.NoError(t, err)
+	assert.False(t, resp.OK)
+	assert.Contains(t, resp.Error, "未知命令")
+
+	resp, err = Call(path, Request{Command: "load-report"})
+	require.NoError(t, err)
+	require.True(t, resp.OK, resp.Error)
+	assert.Contains(t, resp.Output, "0/0 个交易员已加载")
+}
+
+// TestSocket_MalformedRequest 测试非 JSON 请求返回错误响应而不是断开连接
+func TestSocket_MalformedRequest(t *testing.T) {
+	path, _ := startTestSocket(t)
+
+	conn, err := net.Dial("unix", path)
+	require.NoError(t, err)
+	defer conn.Close()
+	_, err = conn.Write([]byte("users please\n"))
+	require.NoError(t, err)
+
+	line, err := bufio.NewReader(conn).ReadBytes('\n')
+	require.NoError(t, err)
+	var resp Response
+	require.NoError(t, json.Unmarshal(line, &resp))
+	assert.False(t, resp.OK)
+	assert.Contains(t, resp.Error, "无效的请求")
+}
+
+// TestListenSocket_PermissionsAndStaleFile 测试 socket 文件权限为 0600、遗留文件被清理、已被占用时拒绝监听，关闭后删除文件
+func TestListenSocket_PermissionsAndStaleFile(t *testing.T) {
+	dir := t.TempDir()
+	db := newTestDatabase(t, dir)
+	defer db.Close()
+	path := filepath.Join(dir, "admin.sock")
+	require.NoError(t, os.WriteFile(path, nil, 0644)) // 进程异常退出遗留的文件
+
+	server, err := ListenSocket(path, NewService(db, manager.NewTraderManager()))
+	require.NoError(t, err)
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	_, err = ListenSocket(path, NewService(db, manager.NewTraderManager()))
+	require.Error(t, err)
+	assert.True(t, strings.Contains(err.Error(), "已被其他进程使用"), err.Error())
+
+	require.NoError(t, server.Close())
+	_, err = os.Stat(path)
+	assert.True(t, os.IsNotExist(err), "关闭后应删除 socket 文件")
+
+	_, err = Call(path, Request{Command: "users"})
+	assert.ErrorIs(t, err, errSocketUnavailable)
+}
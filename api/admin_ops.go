@@ -0,0 +1,90 @@
+package api
+
+import (
+	"net/http"
+
+	"aspen/admin"
+
+	"github.com/gin-gonic/gin"
+)
+
+// adminService 管理操作服务（与本地管理CLI共用）
+func (s *Server) adminService() *admin.Service {
+	return admin.NewService(s.database, s.traderManager)
+}
+
+// handleGetKillSwitch 全局交易开关状态（仅管理员）
+func (s *Server) handleGetKillSwitch(c *gin.Context) {
+	if c.GetString("user_id") != adminUserID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "仅管理员可查看全局交易开关"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"enabled": s.adminService().KillSwitchEnabled()})
+}
+
+// handleSetKillSwitch 开启或关闭全局交易开关（仅管理员）：开启时停止所有交易员并禁止启动
+func (s *Server) handleSetKillSwitch(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if userID != adminUserID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "仅管理员可切换全局交易开关"})
+		return
+	}
+	var req struct {
+		Enabled *bool `json:"enabled" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	stopped, err := s.adminService().SetKillSwitch(userID, *req.Enabled)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "stopped": stopped})
+		return
+	}
+	if stopped == nil {
+		stopped = []string{}
+	}
+	c.JSON(http.StatusOK, gin.H{"enabled": *req.Enabled, "stopped": stopped})
+}
+
+// handleFlattenAll 一键清仓（仅管理员）：暂停系统（开启全局交易开关）并平掉所有交易员的全部持仓
+func (s *Server) handleFlattenAll(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if userID != adminUserID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "仅管理员可一键清仓"})
+		return
+	}
+
+	stopped, results, err := s.adminService().FlattenAll(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "stopped": stopped})
+		return
+	}
+	if stopped == nil {
+		stopped = []string{}
+	}
+	status := http.StatusOK
+	for _, r := range results {
+		if r.Error != "" {
+			status = http.StatusMultiStatus
+			break
+		}
+	}
+	c.JSON(status, gin.H{"paused": true, "stopped": stopped, "traders": results})
+}
+
+// handleBackup 备份数据库到服务器本地默认路径（仅管理员）
+func (s *Server) handleBackup(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if userID != adminUserID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "仅管理员可备份数据库"})
+		return
+	}
+	path, err := s.adminService().Backup(userID, "")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"path": path})
+}
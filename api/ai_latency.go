@@ -0,0 +1,25 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	"aspen/metrics"
+
+	"github.com/gin-gonic/gin"
+)
+
+// handleAILatency 各 provider/model 的AI请求延迟分位数（仅管理员，自进程启动以来的统计）
+func (s *Server) handleAILatency(c *gin.Context) {
+	if c.GetString("user_id") != adminUserID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "仅管理员可查看AI延迟统计"})
+		return
+	}
+
+	summaries, err := metrics.AILatency()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("计算AI延迟失败: %v", err)})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"latency": summaries})
+}
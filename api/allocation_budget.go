@@ -0,0 +1,34 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"aspen/decision"
+)
+
+// normalizeAllocationBudget 校验交易员的单币种资金分配上限，返回按币种覆盖配置的存储格式（JSON）
+// maxPct 为 0 表示使用系统默认；overrides 为空表示不覆盖
+func normalizeAllocationBudget(maxPct float64, overrides map[string]float64) (string, error) {
+	if maxPct < 0 || maxPct > 100 {
+		return "", fmt.Errorf("单币种分配上限必须在 0-100%% 之间，实际: %.2f", maxPct)
+	}
+	if len(overrides) == 0 {
+		return "", nil
+	}
+
+	raw, err := json.Marshal(overrides)
+	if err != nil {
+		return "", fmt.Errorf("单币种分配上限格式错误: %w", err)
+	}
+	normalized, err := decision.ParseAllocationOverrides(string(raw))
+	if err != nil {
+		return "", err
+	}
+
+	canonical, err := json.Marshal(normalized)
+	if err != nil {
+		return "", fmt.Errorf("单币种分配上限格式错误: %w", err)
+	}
+	return string(canonical), nil
+}
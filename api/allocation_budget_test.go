@@ -0,0 +1,29 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalizeAllocationBudget(t *testing.T) {
+	t.Run("按币种覆盖_标准化币种名", func(t *testing.T) {
+		overrides, err := normalizeAllocationBudget(40, map[string]float64{"btc": 60, "ETHUSDT": 30})
+		require.NoError(t, err)
+		assert.JSONEq(t, `{"BTCUSDT":60,"ETHUSDT":30}`, overrides)
+	})
+
+	t.Run("未覆盖_返回空", func(t *testing.T) {
+		overrides, err := normalizeAllocationBudget(0, nil)
+		require.NoError(t, err)
+		assert.Empty(t, overrides)
+	})
+
+	t.Run("超出范围_报错", func(t *testing.T) {
+		_, err := normalizeAllocationBudget(150, nil)
+		assert.Error(t, err)
+		_, err = normalizeAllocationBudget(40, map[string]float64{"BTCUSDT": -5})
+		assert.Error(t, err)
+	})
+}
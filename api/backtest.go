@@ -0,0 +1,138 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"aspen/backtest"
+	"aspen/logger"
+	"aspen/market"
+	"aspen/mcp"
+	"aspen/trader"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// backtestRateLimit 每个用户在 backtestRateWindow 内最多发起的回测次数（每次回测的每个周期都调用AI）
+	backtestRateLimit  = 5
+	backtestRateWindow = time.Hour
+)
+
+// backtestLimiter 按用户ID限流
+var backtestLimiter = &ipRateLimiter{limit: backtestRateLimit, window: backtestRateWindow, windows: make(map[string]*rateWindow)}
+
+// BacktestRequest 回测请求
+type BacktestRequest struct {
+	TraderID       string    `json:"trader_id" binding:"required"`
+	Symbols        []string  `json:"symbols"` // 为空时使用交易员的交易币种
+	Start          time.Time `json:"start" binding:"required"`
+	End            time.Time `json:"end" binding:"required"`
+	Interval       string    `json:"interval"`        // 决策间隔（如 15m），为空时使用交易员的扫描间隔
+	InitialBalance float64   `json:"initial_balance"` // 为空时使用交易员的初始余额
+}
+
+// handleBacktest 用交易员的AI模型、提示词、杠杆和指标配置回放历史K线，返回净值曲线、已实现盈亏、最大回撤和胜率
+// （不影响交易员的账户和运行状态，AI用量记入用户名下）
+func (s *Server) handleBacktest(c *gin.Context) {
+	userID := c.GetString("user_id")
+
+	var req BacktestRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	traderCfg, aiModel, _, err := s.database.GetTraderConfig(userID, req.TraderID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "交易员不存在或无访问权限"})
+		return
+	}
+
+	cfg := backtest.Config{
+		Symbols:            req.Symbols,
+		Start:              req.Start,
+		End:                req.End,
+		Interval:           time.Duration(traderCfg.ScanIntervalMinutes) * time.Minute,
+		InitialBalance:     req.InitialBalance,
+		BTCETHLeverage:     traderCfg.BTCETHLeverage,
+		AltcoinLeverage:    traderCfg.AltcoinLeverage,
+		CustomPrompt:       traderCfg.CustomPrompt,
+		OverrideBasePrompt: traderCfg.OverrideBasePrompt,
+		TemplateName:       traderCfg.SystemPromptTemplate,
+	}
+	if len(cfg.Symbols) == 0 {
+		cfg.Symbols = market.ParseSymbolList(traderCfg.TradingSymbols)
+	}
+	if cfg.InitialBalance == 0 {
+		cfg.InitialBalance = traderCfg.InitialBalance
+	}
+	if raw := strings.TrimSpace(req.Interval); raw != "" {
+		if cfg.Interval, err = time.ParseDuration(raw); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("无效的决策间隔: %s", raw)})
+			return
+		}
+	}
+	if cfg.DataSource, err = market.ParseDataSource(traderCfg.DataSource); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if cfg.Indicators, err = market.ParseIndicatorParams(traderCfg.IndicatorParams); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	// 回测使用交易员的模拟仓手续费和滑点（成交价固定为回放K线收盘价）
+	paperOverrides, err := trader.ParsePaperRealismOverrides(traderCfg.PaperRealismOverrides)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	realism, err := trader.ResolvePaperRealism(traderCfg.PaperRealism, paperOverrides, trader.PaperRealism{})
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	cfg.Costs = realism.Costs
+	if err := cfg.Normalize(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !backtestLimiter.Allow(userID, time.Now()) {
+		c.Header("Retry-After", fmt.Sprintf("%d", int(backtestRateWindow.Seconds())))
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": fmt.Sprintf("回测请求过于频繁（每小时最多%d次），请稍后再试", backtestRateLimit)})
+		return
+	}
+	if err := s.checkUserAIBudget(userID); err != nil {
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+		return
+	}
+
+	client, err := mcp.NewForProvider(aiModel.Provider, aiModel.APIKey, aiModel.CustomAPIURL, aiModel.CustomModelName)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	client.RedactValues = []string{userID}
+	// 回测的AI用量记入用户名下（不计入交易员）
+	client.OnUsage = func(usage mcp.TokenUsage) {
+		if err := s.database.RecordAIUsage(backtest.UsageTraderID, userID, usage.Provider, usage.Model, usage.PromptTokens, usage.CompletionTokens, usage.CostUSD); err != nil {
+			logger.Warnf("⚠️ 保存回测AI用量失败: %v", err)
+		}
+	}
+
+	logger.Infof("🔁 开始回测: user=%s, trader=%s, %v, %s — %s, 间隔 %s", userID, req.TraderID, cfg.Symbols,
+		cfg.Start.UTC().Format(time.RFC3339), cfg.End.UTC().Format(time.RFC3339), cfg.Interval)
+	result, err := backtest.Run(c.Request.Context(), client, cfg)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": fmt.Sprintf("回测失败: %v", err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"trader_id": req.TraderID,
+		"backtest":  result,
+	})
+}
@@ -0,0 +1,90 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"aspen/backtest"
+	"aspen/config"
+	"aspen/market"
+
+	"github.com/agiledragon/gomonkey/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestBacktest_UsesTraderConfig 测试回测使用交易员的AI模型和交易币种回放历史K线，AI用量记入用户名下；他人的交易员和无效参数被拒绝
+func TestBacktest_UsesTraderConfig(t *testing.T) {
+	var fetched []string
+	patches := gomonkey.ApplyMethod(reflect.TypeOf(&market.APIClient{}), "GetKlinesRange",
+		func(_ *market.APIClient, symbol, interval string, start, end time.Time) ([]market.Kline, error) {
+			fetched = append(fetched, symbol+"@"+interval)
+			step := time.Duration(map[string]int{"3m": 3, "30m": 30, "4h": 240}[interval]) * time.Minute
+			var klines []market.Kline
+			for open := start.Truncate(step).Add(step); open.Before(end); open = open.Add(step) {
+				klines = append(klines, market.Kline{OpenTime: open.UnixMilli(), Open: 100, High: 101, Low: 99, Close: 100, Volume: 10})
+			}
+			return klines, nil
+		})
+	defer patches.Reset()
+	backtestLimiter.windows = make(map[string]*rateWindow)
+
+	aiServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"choices":[{"message":{"content":"<decision>[{\"symbol\":\"SOLUSDT\",\"action\":\"wait\",\"reasoning\":\"flat\"}]</decision>"}}],`+
+			`"usage":{"prompt_tokens":1000,"completion_tokens":100}}`)
+	}))
+	defer aiServer.Close()
+
+	db := createTestDB(t)
+	defer db.Close()
+	require.NoError(t, db.CreateUser(&config.User{ID: "default", Email: "default@localhost", OTPVerified: true}))
+	require.NoError(t, db.CreateUser(&config.User{ID: "u2", Email: "u2@test.com", OTPVerified: true}))
+	require.NoError(t, db.CreateAIModel("default", "custom", "Custom", "custom", true, "test-key", aiServer.URL))
+	require.NoError(t, db.UpdateExchange("default", "paper", true, "", "", false, "", "", "", "", 1000))
+	require.NoError(t, db.CreateTrader(&config.TraderRecord{ID: "t1", UserID: "default", Name: "A", AIModelID: "custom", ExchangeID: "paper",
+		InitialBalance: 2000, ScanIntervalMinutes: 30, BTCETHLeverage: 5, AltcoinLeverage: 3, TradingSymbols: "SOLUSDT"}))
+	require.NoError(t, db.CreateTrader(&config.TraderRecord{ID: "t2", UserID: "u2", Name: "B", AIModelID: "custom", ExchangeID: "paper", InitialBalance: 1000}))
+
+	s := &Server{database: db}
+	router := setupTestRouter()
+	router.POST("/api/backtest", func(c *gin.Context) {
+		c.Set("user_id", "default")
+		s.handleBacktest(c)
+	})
+	start := time.Now().Add(-48 * time.Hour).Truncate(time.Hour).UTC()
+	post := func(body map[string]interface{}) *httptest.ResponseRecorder {
+		raw, _ := json.Marshal(body)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/backtest", bytes.NewReader(raw)))
+		return w
+	}
+
+	w := post(map[string]interface{}{"trader_id": "t1", "start": start, "end": start.Add(2 * time.Hour)})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var resp struct {
+		Backtest backtest.Result `json:"backtest"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, []string{"SOLUSDT"}, resp.Backtest.Symbols, "使用交易员的交易币种")
+	assert.Equal(t, "30m0s", resp.Backtest.Interval, "使用交易员的扫描间隔")
+	assert.Equal(t, 5, resp.Backtest.Cycles)
+	assert.Equal(t, 2000.0, resp.Backtest.InitialBalance)
+	assert.Equal(t, 2000.0, resp.Backtest.FinalEquity)
+	assert.Len(t, resp.Backtest.EquityCurve, 5)
+	assert.ElementsMatch(t, []string{"SOLUSDT@3m", "SOLUSDT@30m", "SOLUSDT@4h"}, fetched)
+
+	usage, err := db.GetAIUsageSummary(backtest.UsageTraderID, time.Now().Add(-time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, 5, usage.Calls)
+
+	assert.Equal(t, http.StatusNotFound, post(map[string]interface{}{"trader_id": "t2", "start": start, "end": start.Add(time.Hour)}).Code)
+	assert.Equal(t, http.StatusBadRequest, post(map[string]interface{}{"trader_id": "t1", "start": start, "end": start.Add(time.Hour), "interval": "7m"}).Code)
+	assert.Equal(t, http.StatusBadRequest, post(map[string]interface{}{"trader_id": "t1", "start": start, "end": start.Add(-time.Hour)}).Code)
+}
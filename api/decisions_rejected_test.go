@@ -0,0 +1,70 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"aspen/config"
+	"aspen/logger"
+	"aspen/manager"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestDecisions_RejectedFilter 测试决策日志接口返回拒绝原因，rejected=true 时只返回有决策被拒绝的周期
+func TestDecisions_RejectedFilter(t *testing.T) {
+	t.Chdir(t.TempDir()) // 交易员加载时会创建决策日志目录
+	db := createTestDB(t)
+	defer db.Close()
+	require.NoError(t, db.CreateUser(&config.User{ID: "default", Email: "default@localhost", OTPVerified: true}))
+	require.NoError(t, db.UpdateAIModel("default", "deepseek", true, "sk-test", "", ""))
+	require.NoError(t, db.UpdateExchange("default", "paper", true, "", "", false, "", "", "", "", 1000))
+	require.NoError(t, db.CreateTrader(&config.TraderRecord{
+		ID: "t-1", UserID: "default", Name: "T1", AIModelID: "deepseek", ExchangeID: "paper", InitialBalance: 1000,
+	}))
+
+	tm := manager.NewTraderManager()
+	require.NoError(t, tm.LoadTradersFromDatabase(db))
+	at, err := tm.GetTrader("t-1")
+	require.NoError(t, err)
+	decisionLogger := at.GetDecisionLogger()
+	require.NoError(t, decisionLogger.LogDecision(&logger.DecisionRecord{
+		Success:   true,
+		Decisions: []logger.DecisionAction{{Action: "close_long", Symbol: "ETHUSDT", Success: true}},
+	}))
+	require.NoError(t, decisionLogger.LogDecision(&logger.DecisionRecord{
+		Success: true,
+		Decisions: []logger.DecisionAction{{
+			Action: "open_long", Symbol: "BTCUSDT", Error: "❌ 动态风控已禁止开仓（当日亏损 4.50% / 上限 5.00%）",
+			RejectReason: "max_daily_loss",
+			Rejection:    "AI 想要 open_long BTCUSDT，但被 max_daily_loss 拦截：动态风控已禁止开仓（当日亏损 4.50% / 上限 5.00%）",
+		}},
+	}))
+
+	s := &Server{database: db, traderManager: tm}
+	router := setupTestRouter()
+	router.GET("/api/decisions", func(c *gin.Context) {
+		c.Set("user_id", "default")
+		s.handleDecisions(c)
+	})
+	get := func(url string) []logger.DecisionRecord {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, url, nil))
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var records []logger.DecisionRecord
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &records))
+		return records
+	}
+
+	assert.Len(t, get("/api/decisions?trader_id=t-1"), 2)
+
+	records := get("/api/decisions?trader_id=t-1&rejected=true")
+	require.Len(t, records, 1)
+	require.Len(t, records[0].Decisions, 1)
+	assert.Equal(t, "max_daily_loss", records[0].Decisions[0].RejectReason)
+	assert.Contains(t, records[0].Decisions[0].Rejection, "被 max_daily_loss 拦截")
+}
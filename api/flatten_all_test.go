@@ -0,0 +1,100 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"aspen/config"
+	"aspen/manager"
+	"aspen/market"
+
+	"github.com/agiledragon/gomonkey/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestFlattenAll_ClosesAllPositionsAndPausesSystem 测试一键清仓平掉所有交易员的持仓并开启全局交易开关，非管理员被拒绝
+func TestFlattenAll_ClosesAllPositionsAndPausesSystem(t *testing.T) {
+	t.Chdir(t.TempDir()) // 交易员加载时会创建决策日志目录
+	patches := gomonkey.ApplyFunc(market.GetCachedPrice, func(symbol string) (float64, bool) {
+		return 100, true
+	})
+	defer patches.Reset()
+
+	db := createTestDB(t)
+	defer db.Close()
+	require.NoError(t, db.CreateUser(&config.User{ID: "default", Email: "default@localhost", OTPVerified: true}))
+	require.NoError(t, db.UpdateAIModel("default", "deepseek", true, "sk-test", "", ""))
+	require.NoError(t, db.UpdateExchange("default", "paper", true, "", "", false, "", "", "", "", 1000))
+
+	states := map[string]string{
+		"t-a": `{"BTCUSDT_LONG":{"symbol":"BTCUSDT","side":"LONG","quantity":1,"entry_price":100,"leverage":1,"margin":100},` +
+			`"SOLUSDT_SHORT":{"symbol":"SOLUSDT","side":"SHORT","quantity":2,"entry_price":100,"leverage":1,"margin":200}}`,
+		"t-b": `{"ETHUSDT_SHORT":{"symbol":"ETHUSDT","side":"SHORT","quantity":1,"entry_price":100,"leverage":1,"margin":100}}`,
+		"t-c": `{}`,
+	}
+	for _, id := range []string{"t-a", "t-b", "t-c"} {
+		require.NoError(t, db.CreateTrader(&config.TraderRecord{
+			ID: id, UserID: "default", Name: id, AIModelID: "deepseek", ExchangeID: "paper", InitialBalance: 1000,
+		}))
+		require.NoError(t, db.SavePaperTraderState(id, 1000, 700, 0, states[id]))
+	}
+
+	tm := manager.NewTraderManager()
+	require.NoError(t, tm.LoadTradersFromDatabase(db))
+	positionCount := func(id string) int {
+		at, err := tm.GetTrader(id)
+		require.NoError(t, err)
+		positions, err := at.GetPositions()
+		require.NoError(t, err)
+		return len(positions)
+	}
+	require.Equal(t, 2, positionCount("t-a"))
+	require.Equal(t, 1, positionCount("t-b"))
+
+	s := &Server{database: db, traderManager: tm}
+	router := setupTestRouter()
+	withUser := func(userID string, h gin.HandlerFunc) gin.HandlerFunc {
+		return func(c *gin.Context) {
+			c.Set("user_id", userID)
+			h(c)
+		}
+	}
+	router.POST("/api/admin/flatten-all", withUser(adminUserID, s.handleFlattenAll))
+	router.POST("/api/user/flatten-all", withUser("default", s.handleFlattenAll))
+
+	// 非管理员不能一键清仓，持仓和开关都不变
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/user/flatten-all", nil))
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Equal(t, 2, positionCount("t-a"))
+	assert.False(t, manager.KillSwitchEnabled(db))
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/admin/flatten-all", nil))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var resp struct {
+		Paused  bool                    `json:"paused"`
+		Traders []manager.FlattenResult `json:"traders"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.True(t, resp.Paused)
+	require.Len(t, resp.Traders, 3)
+	assert.Equal(t, "t-a", resp.Traders[0].TraderID)
+	assert.ElementsMatch(t, []string{"BTCUSDT long", "SOLUSDT short"}, resp.Traders[0].Closed)
+	assert.Equal(t, []string{"ETHUSDT short"}, resp.Traders[1].Closed)
+	assert.Empty(t, resp.Traders[2].Closed)
+	for _, r := range resp.Traders {
+		assert.Empty(t, r.Error)
+	}
+
+	// 所有持仓已平，系统已暂停（交易员无法启动）
+	for _, id := range []string{"t-a", "t-b", "t-c"} {
+		assert.Zero(t, positionCount(id), id)
+	}
+	assert.True(t, manager.KillSwitchEnabled(db))
+}
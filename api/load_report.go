@@ -0,0 +1,66 @@
+package api
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// adminUserID 管理员用户ID（与 config.EnsureAdminUser 一致）
+const adminUserID = "admin"
+
+// handleLoadReport 交易员加载报告（仅管理员）：数据库记录与内存实例的一致性及加载失败原因
+func (s *Server) handleLoadReport(c *gin.Context) {
+	if c.GetString("user_id") != adminUserID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "仅管理员可查看加载报告"})
+		return
+	}
+
+	report, err := s.adminService().LoadReport()
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+// handleReloadTrader 重新加载单个交易员（用户修复配置后无需重启进程）
+func (s *Server) handleReloadTrader(c *gin.Context) {
+	userID := c.GetString("user_id")
+	traderID := c.Param("id")
+
+	// 校验交易员是否属于当前用户
+	// 不使用 GetTraderConfig：它关联查询AI模型和交易所，而这两者缺失正是加载失败的常见原因
+	traders, err := s.database.GetTraders(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("获取交易员列表失败: %v", err)})
+		return
+	}
+	owned := false
+	for _, t := range traders {
+		if t.ID == traderID {
+			owned = true
+			break
+		}
+	}
+	if !owned {
+		c.JSON(http.StatusNotFound, gin.H{"error": "交易员不存在或无访问权限"})
+		return
+	}
+
+	if _, err := s.traderManager.GetTrader(traderID); err == nil {
+		c.JSON(http.StatusOK, gin.H{"message": "交易员已加载", "status": "loaded"})
+		return
+	}
+
+	if err := s.traderManager.RetryLoadTrader(s.database, userID, traderID); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error(), "status": "load_error"})
+		return
+	}
+
+	s.traderManager.SyncSubscriptions()
+	log.Printf("✓ 交易员 %s 重新加载成功", traderID)
+	c.JSON(http.StatusOK, gin.H{"message": "交易员重新加载成功", "status": "loaded"})
+}
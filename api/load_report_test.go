@@ -0,0 +1,120 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"aspen/config"
+	"aspen/manager"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestLoadReport_CorruptRecordsReportedAndRetryable 测试加载失败的交易员出现在加载报告和用户列表中，修复后可重新加载
+func TestLoadReport_CorruptRecordsReportedAndRetryable(t *testing.T) {
+	t.Chdir(t.TempDir()) // 交易员加载时会创建决策日志目录
+	db := createTestDB(t)
+	defer db.Close()
+
+	// 默认用户下已有 deepseek 模型和 binance/paper 交易所（均未启用）
+	require.NoError(t, db.CreateUser(&config.User{ID: "default", Email: "default@localhost", OTPVerified: true}))
+	require.NoError(t, db.UpdateAIModel("default", "deepseek", true, "sk-test", "", ""))
+	require.NoError(t, db.UpdateExchange("default", "paper", true, "", "", false, "", "", "", "", 1000))
+
+	for _, rec := range []*config.TraderRecord{
+		{ID: "t-ok", UserID: "default", Name: "OK", AIModelID: "deepseek", ExchangeID: "paper", InitialBalance: 1000},
+		{ID: "t-missing-model", UserID: "default", Name: "NoModel", AIModelID: "gone", ExchangeID: "paper", InitialBalance: 1000},
+		{ID: "t-disabled-exchange", UserID: "default", Name: "NoExchange", AIModelID: "deepseek", ExchangeID: "binance", InitialBalance: 1000},
+	} {
+		require.NoError(t, db.CreateTrader(rec))
+	}
+
+	tm := manager.NewTraderManager()
+	require.NoError(t, tm.LoadTradersFromDatabase(db))
+
+	report := tm.GetLoadReport()
+	assert.Equal(t, 3, report.Total)
+	assert.Equal(t, 1, report.Loaded)
+	require.Len(t, report.Failed, 2)
+	assert.Equal(t, "t-disabled-exchange", report.Failed[0].TraderID)
+	assert.Equal(t, "交易所 binance 未启用", report.Failed[0].Reason)
+	assert.Equal(t, "t-missing-model", report.Failed[1].TraderID)
+	assert.Equal(t, "AI模型 gone 不存在", report.Failed[1].Reason)
+	assert.Contains(t, report.Summary(), "1/3 个交易员已加载，2 个失败")
+
+	s := &Server{database: db, traderManager: tm}
+	router := setupTestRouter()
+	withUser := func(userID string, h gin.HandlerFunc) gin.HandlerFunc {
+		return func(c *gin.Context) {
+			c.Set("user_id", userID)
+			h(c)
+		}
+	}
+	router.GET("/api/my-traders", withUser("default", s.handleTraderList))
+	router.POST("/api/traders/:id/reload", withUser("default", s.handleReloadTrader))
+	router.GET("/api/admin/load-report", withUser("default", s.handleLoadReport))
+
+	listStatuses := func() map[string]map[string]interface{} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/my-traders", nil))
+		require.Equal(t, http.StatusOK, w.Code)
+		var items []map[string]interface{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &items))
+		byID := make(map[string]map[string]interface{}, len(items))
+		for _, item := range items {
+			byID[item["trader_id"].(string)] = item
+		}
+		return byID
+	}
+
+	// 加载失败的交易员仍出现在列表中，带失败状态和原因
+	items := listStatuses()
+	require.Len(t, items, 3)
+	assert.Equal(t, "stopped", items["t-ok"]["status"])
+	assert.NotContains(t, items["t-ok"], "load_error")
+	assert.Equal(t, "load_error", items["t-missing-model"]["status"])
+	assert.Equal(t, "AI模型 gone 不存在", items["t-missing-model"]["load_error"])
+	assert.Equal(t, "load_error", items["t-disabled-exchange"]["status"])
+
+	// 非管理员不能查看加载报告
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/admin/load-report", nil))
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	// 未修复时重试仍失败
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/traders/t-missing-model/reload", nil))
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+
+	// 修复配置后重试成功，失败记录被清除
+	require.NoError(t, db.CreateAIModel("default", "gone", "DeepSeek", "deepseek", true, "sk-test", ""))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/traders/t-missing-model/reload", nil))
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	items = listStatuses()
+	assert.Equal(t, "stopped", items["t-missing-model"]["status"])
+	assert.Equal(t, "load_error", items["t-disabled-exchange"]["status"])
+
+	report = tm.GetLoadReport()
+	assert.Equal(t, 3, report.Total)
+	assert.Equal(t, 2, report.Loaded)
+	require.Len(t, report.Failed, 1)
+	assert.Equal(t, "t-disabled-exchange", report.Failed[0].TraderID)
+
+	// 管理员可查看加载报告
+	adminRouter := setupTestRouter()
+	adminRouter.GET("/api/admin/load-report", withUser(adminUserID, s.handleLoadReport))
+	w = httptest.NewRecorder()
+	adminRouter.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/admin/load-report", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	var got manager.LoadReport
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &got))
+	assert.Equal(t, 2, got.Loaded)
+	require.Len(t, got.Failed, 1)
+	assert.Equal(t, "交易所 binance 未启用", got.Failed[0].Reason)
+}
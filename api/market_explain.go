@@ -0,0 +1,32 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"aspen/market"
+
+	"github.com/gin-gonic/gin"
+)
+
+// handleMarketExplain 返回币种各指标当前的可读解读（看多/看空/中性）和汇总说明，不调用AI
+func (s *Server) handleMarketExplain(c *gin.Context) {
+	symbol := market.Normalize(strings.TrimSpace(c.Param("symbol")))
+	if symbol == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "币种不能为空"})
+		return
+	}
+
+	data, err := market.Get(symbol)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": fmt.Sprintf("获取 %s 市场数据失败: %v", symbol, err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"explanation": market.Explain(data),
+		"timestamp":   time.Now().UnixMilli(),
+	})
+}
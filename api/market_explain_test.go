@@ -0,0 +1,72 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"aspen/market"
+
+	"github.com/agiledragon/gomonkey/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestMarketExplain_DescribesIndicators 测试指标解读包含 RSI 和趋势方向，并给出各指标倾向
+func TestMarketExplain_DescribesIndicators(t *testing.T) {
+	patches := gomonkey.ApplyFunc(market.Get, func(symbol string) (*market.Data, error) {
+		if symbol != "ETHUSDT" {
+			return nil, errors.New("unknown symbol")
+		}
+		return &market.Data{
+			Symbol:            symbol,
+			CurrentPrice:      3000,
+			CurrentEMA20:      2950,
+			CurrentMACD:       4.2,
+			CurrentRSI7:       75,
+			CurrentWilliamsR:  -50,
+			CurrentMFI:        55,
+			SARValue:          2900,
+			SARTrend:          1,
+			LongerTermContext: &market.LongerTermData{EMA20: 2980, EMA50: 2900},
+		}, nil
+	})
+	defer patches.Reset()
+
+	s := &Server{}
+	router := setupTestRouter()
+	router.GET("/api/market/:symbol/explain", s.handleMarketExplain)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/market/eth/explain", nil)
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var resp struct {
+		Explanation market.Explanation `json:"explanation"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	exp := resp.Explanation
+	assert.Equal(t, "ETHUSDT", exp.Symbol)
+	assert.Equal(t, market.TrendUp, exp.Trend)
+	assert.Equal(t, market.BiasBullish, exp.Bias)
+	assert.Contains(t, exp.Summary, "趋势方向向上")
+	assert.Contains(t, exp.Summary, "RSI 75.0 超买")
+
+	biases := make(map[string]string)
+	for _, r := range exp.Indicators {
+		biases[r.Name] = r.Bias
+	}
+	assert.Equal(t, market.BiasBearish, biases["RSI7"])
+	assert.Equal(t, market.BiasBullish, biases["SAR"])
+	assert.Equal(t, market.BiasBullish, biases["EMA20"])
+	assert.Equal(t, market.BiasNeutral, biases["MFI"])
+
+	// 行情获取失败
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/api/market/DOGE/explain", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadGateway, w.Code)
+}
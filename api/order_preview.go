@@ -0,0 +1,53 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// orderPreviewRequest 假设订单（size_usd 与 quantity 二选一）
+type orderPreviewRequest struct {
+	Symbol   string  `json:"symbol" binding:"required"`
+	Side     string  `json:"side" binding:"required"` // long / short
+	SizeUSD  float64 `json:"size_usd"`
+	Quantity float64 `json:"quantity"`
+	Leverage int     `json:"leverage" binding:"required"`
+}
+
+// handleOrderPreview 预览假设订单需要的保证金、手续费、成交后的强平价格以及可用余额是否足够，不下单
+func (s *Server) handleOrderPreview(c *gin.Context) {
+	userID := c.GetString("user_id")
+	traderID := c.Param("id")
+
+	// 校验交易员归属
+	if _, _, _, err := s.database.GetTraderConfig(userID, traderID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "交易员不存在或无访问权限"})
+		return
+	}
+
+	var req orderPreviewRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	at, err := s.traderManager.GetTrader(traderID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	preview, err := at.PreviewOrder(req.Symbol, req.Side, req.SizeUSD, req.Quantity, req.Leverage)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"trader_id": traderID,
+		"preview":   preview,
+		"timestamp": time.Now().UnixMilli(),
+	})
+}
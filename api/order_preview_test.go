@@ -0,0 +1,98 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"aspen/config"
+	"aspen/manager"
+	"aspen/market"
+	"aspen/trader"
+
+	"github.com/agiledragon/gomonkey/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestOrderPreview_MarginAndLiquidation 测试假设订单的保证金、手续费和逐仓强平价格，余额不足时标记为不可下单，真实账户不变
+func TestOrderPreview_MarginAndLiquidation(t *testing.T) {
+	t.Chdir(t.TempDir()) // 交易员加载时会创建决策日志目录
+	patches := gomonkey.ApplyFunc(market.GetCachedPrice, func(symbol string) (float64, bool) {
+		return 50000, true
+	})
+	defer patches.Reset()
+
+	db := createTestDB(t)
+	defer db.Close()
+	require.NoError(t, db.CreateUser(&config.User{ID: "default", Email: "default@localhost", OTPVerified: true}))
+	require.NoError(t, db.UpdateAIModel("default", "deepseek", true, "sk-test", "", ""))
+	require.NoError(t, db.UpdateExchange("default", "paper", true, "", "", false, "", "", "", "", 1000))
+	require.NoError(t, db.CreateTrader(&config.TraderRecord{
+		ID: "t-paper", UserID: "default", Name: "Paper", AIModelID: "deepseek", ExchangeID: "paper",
+		InitialBalance: 1000, BTCETHLeverage: 10, AltcoinLeverage: 5,
+	}))
+
+	tm := manager.NewTraderManager()
+	require.NoError(t, tm.LoadTradersFromDatabase(db))
+	live, err := tm.GetTrader("t-paper")
+	require.NoError(t, err)
+
+	s := &Server{database: db, traderManager: tm}
+	router := setupTestRouter()
+	router.POST("/api/traders/:id/order-preview", func(c *gin.Context) {
+		c.Set("user_id", "default")
+		s.handleOrderPreview(c)
+	})
+	preview := func(traderID, body string) (*httptest.ResponseRecorder, trader.OrderPreview) {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/api/traders/"+traderID+"/order-preview", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+		var resp struct {
+			Preview trader.OrderPreview `json:"preview"`
+		}
+		if w.Code == http.StatusOK {
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		}
+		return w, resp.Preview
+	}
+
+	// 5000 USD @10x：数量 0.1，保证金 500，手续费 2
+	w, p := preview("t-paper", `{"symbol":"btc","side":"long","size_usd":5000,"leverage":10}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, "BTCUSDT", p.Symbol)
+	assert.Equal(t, "isolated", p.MarginMode)
+	assert.InDelta(t, 0.1, p.Quantity, 1e-9)
+	assert.InDelta(t, 5000, p.NotionalUSD, 1e-9)
+	assert.InDelta(t, 500, p.RequiredMargin, 1e-9)
+	assert.InDelta(t, 2, p.Fee, 1e-9)
+	assert.InDelta(t, 502, p.TotalRequired, 1e-9)
+	assert.InDelta(t, 1000, p.AvailableBalance, 1e-9)
+	assert.True(t, p.Fits)
+	// 逐仓多仓：500 + (P - 50000)·0.1 = 0.1·P·0.4% → P = 4500 / 0.0996
+	assert.InDelta(t, 4500/0.0996, p.LiquidationPrice, 1e-6)
+
+	// 20000 USD @10x 超出可用余额：保证金 2000 + 手续费 8
+	w, p = preview("t-paper", `{"symbol":"BTCUSDT","side":"short","size_usd":20000,"leverage":10}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.False(t, p.Fits)
+	assert.InDelta(t, 2008, p.TotalRequired, 1e-9)
+	// 逐仓空仓：2000 + (50000 - P)·0.4 = 0.4·P·0.4% → P = 22000 / 0.4016
+	assert.InDelta(t, 22000/0.4016, p.LiquidationPrice, 1e-6)
+
+	// 真实交易员没有持仓
+	positions, err := live.GetPositions()
+	require.NoError(t, err)
+	assert.Empty(t, positions)
+
+	w, _ = preview("t-paper", `{"symbol":"BTCUSDT","side":"up","size_usd":100,"leverage":10}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w, _ = preview("t-paper", `{"symbol":"BTCUSDT","side":"long","leverage":10}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w, _ = preview("someone-else", `{"symbol":"BTCUSDT","side":"long","size_usd":100,"leverage":10}`)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
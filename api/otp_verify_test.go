@@ -0,0 +1,126 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"aspen/auth"
+	"aspen/config"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pquerna/otp/totp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestVerifyOTPIssuesToken 测试OTP验证码正确时签发可用的JWT，错误时返回401
+func TestVerifyOTPIssuesToken(t *testing.T) {
+	db := createTestDB(t)
+	defer db.Close()
+
+	secret := "JBSWY3DPEHPK3PXP"
+	require.NoError(t, db.CreateUser(&config.User{ID: "u1", Email: "u1@example.com", OTPSecret: secret, OTPVerified: true}))
+
+	s := &Server{router: gin.New(), database: db}
+	s.setupRoutes()
+
+	verify := func(code string) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(map[string]string{"user_id": "u1", "otp_code": code})
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/api/otp/verify", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		s.router.ServeHTTP(w, req)
+		return w
+	}
+
+	code, err := totp.GenerateCode(secret, time.Now())
+	require.NoError(t, err)
+	w := verify(code)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var resp struct {
+		Token  string `json:"token"`
+		UserID string `json:"user_id"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, "u1", resp.UserID)
+	claims, err := auth.ValidateJWT(resp.Token)
+	require.NoError(t, err)
+	assert.Equal(t, "u1", claims.UserID)
+	assert.Equal(t, auth.ScopeTrade, claims.EffectiveScope())
+
+	// 错误的验证码（与当前有效验证码不同）
+	wrong := "000000"
+	if code == wrong {
+		wrong = "111111"
+	}
+	w = verify(wrong)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.NotContains(t, w.Body.String(), "token")
+}
+
+// TestOTPEnrollConfirm 测试注册后确认OTP绑定前登录被拒绝，确认后可以正常登录
+func TestOTPEnrollConfirm(t *testing.T) {
+	db := createTestDB(t)
+	defer db.Close()
+
+	secret := "JBSWY3DPEHPK3PXP"
+	hash, err := auth.HashPassword("secret123")
+	require.NoError(t, err)
+	require.NoError(t, db.CreateUser(&config.User{ID: "u1", Email: "u1@example.com", PasswordHash: hash, OTPSecret: secret}))
+
+	s := &Server{router: gin.New(), database: db}
+	s.setupRoutes()
+
+	post := func(path string, body map[string]string) *httptest.ResponseRecorder {
+		raw, _ := json.Marshal(body)
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, path, bytes.NewReader(raw))
+		req.Header.Set("Content-Type", "application/json")
+		s.router.ServeHTTP(w, req)
+		return w
+	}
+	login := func() *httptest.ResponseRecorder {
+		return post("/api/login", map[string]string{"email": "u1@example.com", "password": "secret123"})
+	}
+	code := func() string {
+		c, err := totp.GenerateCode(secret, time.Now())
+		require.NoError(t, err)
+		return c
+	}
+
+	// 确认前：登录和OTP登录验证都被拒绝
+	w := login()
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Contains(t, w.Body.String(), "requires_otp_setup")
+	w = post("/api/otp/verify", map[string]string{"user_id": "u1", "otp_code": code()})
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	// 错误的验证码不会完成确认
+	w = post("/api/otp/enroll/confirm", map[string]string{"user_id": "u1", "otp_code": "not-a-code"})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	user, err := db.GetUserByID("u1")
+	require.NoError(t, err)
+	assert.False(t, user.OTPVerified)
+
+	// 有效验证码确认绑定
+	w = post("/api/otp/enroll/confirm", map[string]string{"user_id": "u1", "otp_code": code()})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), "token")
+	user, err = db.GetUserByID("u1")
+	require.NoError(t, err)
+	assert.True(t, user.OTPVerified)
+
+	// 确认后：登录进入OTP验证，不能重复确认
+	w = login()
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), "requires_otp")
+	w = post("/api/otp/verify", map[string]string{"user_id": "u1", "otp_code": code()})
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	w = post("/api/otp/enroll/confirm", map[string]string{"user_id": "u1", "otp_code": code()})
+	assert.Equal(t, http.StatusConflict, w.Code)
+}
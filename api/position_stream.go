@@ -0,0 +1,93 @@
+package api
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"aspen/trader"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+// positionPushInterval 持仓详情推送间隔（与持仓详情缓存时间一致，推送和轮询看到的数据相同）
+const positionPushInterval = 3 * time.Second
+
+// positionPushWriteTimeout 单条推送的写超时，客户端长时间不读取时断开连接
+const positionPushWriteTimeout = 10 * time.Second
+
+// positionsPayload 持仓详情消息（GET /api/traders/:id/positions 的响应和 WebSocket 推送使用相同结构）
+func positionsPayload(traderID string, positions []trader.PositionView) gin.H {
+	return gin.H{
+		"trader_id": traderID,
+		"positions": positions,
+		"timestamp": time.Now().UnixMilli(),
+	}
+}
+
+// handleTraderPositionsStream 通过 WebSocket 定时推送指定交易员的持仓详情（仅限所有者），
+// 每条消息与轮询接口的响应相同；获取持仓失败时推送 {"trader_id","error"} 并继续推送
+func (s *Server) handleTraderPositionsStream(c *gin.Context) {
+	userID := c.GetString("user_id")
+	traderID := c.Param("id")
+
+	// 校验交易员归属
+	if _, _, _, err := s.database.GetTraderConfig(userID, traderID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "交易员不存在或无访问权限"})
+		return
+	}
+
+	at, err := s.traderManager.GetTrader(traderID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	upgrader := websocket.Upgrader{
+		CheckOrigin: func(r *http.Request) bool {
+			origin := r.Header.Get("Origin")
+			return origin == "" || corsAllowedOrigin(s.corsConfig, origin) != ""
+		},
+	}
+	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		log.Printf("⚠️  持仓推送连接升级失败 (trader=%s): %v", traderID, err)
+		return
+	}
+	defer conn.Close()
+
+	// 读取客户端消息以处理 ping/关闭帧，连接断开时结束推送
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				return
+			}
+		}
+	}()
+
+	ticker := time.NewTicker(positionPushInterval)
+	defer ticker.Stop()
+	for {
+		var payload gin.H
+		if positions, err := at.GetPositionViews(); err != nil {
+			payload = gin.H{"trader_id": traderID, "error": fmt.Sprintf("获取持仓详情失败: %v", err)}
+		} else {
+			payload = positionsPayload(traderID, positions)
+		}
+
+		conn.SetWriteDeadline(time.Now().Add(positionPushWriteTimeout))
+		if err := conn.WriteJSON(payload); err != nil {
+			return
+		}
+
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+	}
+}
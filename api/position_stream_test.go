@@ -0,0 +1,63 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"aspen/auth"
+	"aspen/config"
+	"aspen/manager"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestTraderPositionsStream 测试 WebSocket 推送的持仓详情与轮询接口结构相同，浏览器可通过 ?token= 认证，
+// 不能订阅其他用户的交易员
+func TestTraderPositionsStream(t *testing.T) {
+	t.Chdir(t.TempDir()) // 交易员加载时会创建决策日志目录
+	db := createTestDB(t)
+	defer db.Close()
+
+	require.NoError(t, db.CreateUser(&config.User{ID: "default", Email: "default@localhost", OTPVerified: true}))
+	require.NoError(t, db.UpdateAIModel("default", "deepseek", true, "sk-test", "", ""))
+	require.NoError(t, db.UpdateExchange("default", "paper", true, "", "", false, "", "", "", "", 1000))
+	require.NoError(t, db.CreateTrader(&config.TraderRecord{ID: "t1", UserID: "default", Name: "Paper", AIModelID: "deepseek", ExchangeID: "paper", InitialBalance: 1000}))
+
+	tm := manager.NewTraderManager()
+	require.NoError(t, tm.LoadTradersFromDatabase(db))
+
+	s := &Server{router: gin.New(), database: db, traderManager: tm}
+	s.setupRoutes()
+	server := httptest.NewServer(s.router)
+	defer server.Close()
+	wsURL := func(traderID string) string {
+		return "ws" + strings.TrimPrefix(server.URL, "http") + "/api/traders/" + traderID + "/positions/ws"
+	}
+
+	token, err := auth.GenerateScopedJWT("default", "default@localhost", auth.ScopeRead, time.Hour)
+	require.NoError(t, err)
+
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL("t1")+"?token="+token, nil)
+	require.NoError(t, err)
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var msg map[string]interface{}
+	require.NoError(t, conn.ReadJSON(&msg))
+	assert.Equal(t, "t1", msg["trader_id"])
+	assert.Contains(t, msg, "positions")
+	assert.Contains(t, msg, "timestamp")
+
+	_, resp, err := websocket.DefaultDialer.Dial(wsURL("t1"), nil)
+	require.Error(t, err)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode, "缺少token时拒绝连接")
+
+	_, resp, err = websocket.DefaultDialer.Dial(wsURL("other")+"?token="+token, nil)
+	require.Error(t, err)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode, "不能订阅不属于自己的交易员")
+}
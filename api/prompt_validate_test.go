@@ -0,0 +1,45 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"aspen/decision"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestValidatePrompt 测试自定义提示词校验：缺少市场数据占位符返回警告，包含全部必需占位符时通过
+func TestValidatePrompt(t *testing.T) {
+	s := &Server{}
+	router := setupTestRouter()
+	router.POST("/api/prompt/validate", s.handleValidatePrompt)
+
+	validate := func(prompt string) (int, map[string]interface{}) {
+		body, _ := json.Marshal(map[string]string{"prompt": prompt})
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/api/prompt/validate", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+
+		var resp map[string]interface{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		return w.Code, resp
+	}
+
+	code, resp := validate("只做BTC趋势，严格止损。\n" + decision.PromptPlaceholderOutputFormat)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, false, resp["valid"])
+	warnings, _ := resp["warnings"].([]interface{})
+	require.Len(t, warnings, 1)
+	assert.Contains(t, warnings[0], decision.PromptPlaceholderMarketData)
+
+	code, resp = validate("只做BTC趋势，严格止损。\n" + decision.PromptPlaceholderMarketData + "\n" + decision.PromptPlaceholderOutputFormat)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, true, resp["valid"])
+	assert.Empty(t, resp["warnings"])
+}
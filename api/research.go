@@ -0,0 +1,238 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"aspen/config"
+	"aspen/logger"
+	"aspen/market"
+	"aspen/mcp"
+	"aspen/research"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// researchRateLimit 每个用户在 researchRateWindow 内最多发起的研究次数
+	researchRateLimit  = 10
+	researchRateWindow = time.Hour
+	// researchListDefault / researchListMax 研究记录列表默认和最多返回的条数
+	researchListDefault = 20
+	researchListMax     = 100
+)
+
+// researchLimiter 按用户ID限流（每次研究都会产生AI调用成本）
+var researchLimiter = &ipRateLimiter{limit: researchRateLimit, window: researchRateWindow, windows: make(map[string]*rateWindow)}
+
+// handleResearch 按需研究单个币种：获取市场快照，调用用户选择的AI模型分析，保存并返回分析结果和使用的市场数据
+func (s *Server) handleResearch(c *gin.Context) {
+	userID := c.GetString("user_id")
+
+	var req struct {
+		Symbol    string `json:"symbol" binding:"required"`
+		Question  string `json:"question"`
+		AIModelID string `json:"ai_model_id"` // 为空时使用第一个已启用的模型
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	symbol := market.Normalize(strings.TrimSpace(req.Symbol))
+	question, err := research.NormalizeQuestion(req.Question)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !researchLimiter.Allow(userID, time.Now()) {
+		c.Header("Retry-After", fmt.Sprintf("%d", int(researchRateWindow.Seconds())))
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": fmt.Sprintf("研究请求过于频繁（每小时最多%d次），请稍后再试", researchRateLimit)})
+		return
+	}
+	if err := s.checkUserAIBudget(userID); err != nil {
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+		return
+	}
+
+	model, err := s.resolveResearchModel(userID, req.AIModelID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	client, err := mcp.NewForProvider(model.Provider, model.APIKey, model.CustomAPIURL, model.CustomModelName)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	client.RedactValues = []string{userID}
+	// 研究的AI用量记入用户名下（不计入任何交易员）
+	client.OnUsage = func(usage mcp.TokenUsage) {
+		if err := s.database.RecordAIUsage(research.UsageTraderID, userID, usage.Provider, usage.Model, usage.PromptTokens, usage.CompletionTokens, usage.CostUSD); err != nil {
+			logger.Warnf("⚠️ 保存研究AI用量失败: %v", err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), client.Budget.Total)
+	defer cancel()
+	result, err := research.Run(ctx, client, symbol, question)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": fmt.Sprintf("研究 %s 失败: %v", symbol, err)})
+		return
+	}
+
+	report := &config.ResearchReport{
+		UserID:     userID,
+		Symbol:     result.Symbol,
+		Question:   result.Question,
+		AIModelID:  model.ID,
+		Model:      client.Model,
+		Analysis:   result.Analysis,
+		DurationMs: result.DurationMs,
+	}
+	if result.Summary != nil {
+		raw, _ := json.Marshal(result.Summary)
+		report.Summary = string(raw)
+	}
+	raw, _ := json.Marshal(result.MarketData)
+	report.MarketData = string(raw)
+	if err := s.database.SaveResearchReport(report); err != nil {
+		logger.Warnf("⚠️ 保存研究记录失败: %v", err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"id":          report.ID,
+		"symbol":      result.Symbol,
+		"question":    result.Question,
+		"model":       report.Model,
+		"analysis":    result.Analysis,
+		"summary":     result.Summary,
+		"market_data": result.MarketData,
+		"market_text": result.MarketText,
+		"duration_ms": result.DurationMs,
+		"created_at":  report.CreatedAt,
+	})
+}
+
+// handleListResearch 获取用户的研究记录（?symbol= 按币种筛选，?limit= 条数）
+func (s *Server) handleListResearch(c *gin.Context) {
+	userID := c.GetString("user_id")
+
+	limit := researchListDefault
+	if raw := c.Query("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "无效的 limit 参数"})
+			return
+		}
+		limit = min(n, researchListMax)
+	}
+	symbol := ""
+	if raw := strings.TrimSpace(c.Query("symbol")); raw != "" {
+		symbol = market.Normalize(raw)
+	}
+
+	reports, err := s.database.ListResearchReports(userID, symbol, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("获取研究记录失败: %v", err)})
+		return
+	}
+	items := make([]gin.H, 0, len(reports))
+	for _, report := range reports {
+		items = append(items, researchReportResponse(report))
+	}
+	c.JSON(http.StatusOK, gin.H{"reports": items})
+}
+
+// handleGetResearch 获取一条研究记录
+func (s *Server) handleGetResearch(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的研究记录ID"})
+		return
+	}
+	report, err := s.database.GetResearchReport(c.GetString("user_id"), id)
+	if errors.Is(err, sql.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "研究记录不存在"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("获取研究记录失败: %v", err)})
+		return
+	}
+	c.JSON(http.StatusOK, researchReportResponse(report))
+}
+
+// researchReportResponse 研究记录的响应格式（摘要和市场数据按JSON对象返回）
+func researchReportResponse(report *config.ResearchReport) gin.H {
+	resp := gin.H{
+		"id":          report.ID,
+		"symbol":      report.Symbol,
+		"question":    report.Question,
+		"ai_model_id": report.AIModelID,
+		"model":       report.Model,
+		"analysis":    report.Analysis,
+		"summary":     nil,
+		"market_data": nil,
+		"duration_ms": report.DurationMs,
+		"created_at":  report.CreatedAt,
+	}
+	if report.Summary != "" {
+		resp["summary"] = json.RawMessage(report.Summary)
+	}
+	if report.MarketData != "" {
+		resp["market_data"] = json.RawMessage(report.MarketData)
+	}
+	return resp
+}
+
+// resolveResearchModel 研究使用的AI模型：必须是用户已启用的模型，未指定时使用第一个已启用的模型
+func (s *Server) resolveResearchModel(userID, modelID string) (*config.AIModelConfig, error) {
+	models, err := s.database.GetAIModels(userID)
+	if err != nil {
+		return nil, fmt.Errorf("获取AI模型配置失败: %w", err)
+	}
+	for _, model := range models {
+		if modelID != "" && model.ID != modelID {
+			continue
+		}
+		if !model.Enabled || model.APIKey == "" {
+			if modelID != "" {
+				return nil, fmt.Errorf("AI模型 %s 未启用或未配置API密钥", modelID)
+			}
+			continue
+		}
+		return model, nil
+	}
+	if modelID != "" {
+		return nil, fmt.Errorf("AI模型 %s 不存在", modelID)
+	}
+	return nil, fmt.Errorf("没有已启用的AI模型，请先在AI模型配置中设置API Key")
+}
+
+// checkUserAIBudget 检查用户当天（UTC）的AI成本是否已达到系统配置的每日预算（user_daily_ai_budget_usd，未配置或0表示不限制）
+func (s *Server) checkUserAIBudget(userID string) error {
+	raw, _ := s.database.GetSystemConfig("user_daily_ai_budget_usd")
+	budget, err := strconv.ParseFloat(strings.TrimSpace(raw), 64)
+	if err != nil || budget <= 0 {
+		return nil
+	}
+
+	now := time.Now().UTC()
+	dayStart := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	usage, err := s.database.GetUserAIUsageSummary(userID, dayStart)
+	if err != nil {
+		return fmt.Errorf("获取AI用量失败: %w", err)
+	}
+	if usage.EstimatedCostUSD >= budget {
+		return fmt.Errorf("今日AI成本 %.4f USD 已达到每日预算 %.2f USD", usage.EstimatedCostUSD, budget)
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"aspen/market"
+	"aspen/research"
+
+	"github.com/agiledragon/gomonkey/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestResearch_RunsAndStoresReport 测试币种研究：使用用户配置的模型分析，记录AI用量，保存记录并可在列表中查看
+func TestResearch_RunsAndStoresReport(t *testing.T) {
+	patches := gomonkey.ApplyFunc(market.GetSnapshot, func(symbol string) (*market.Data, error) {
+		return &market.Data{Symbol: symbol, CurrentPrice: 35.5}, nil
+	})
+	defer patches.Reset()
+	researchLimiter.windows = make(map[string]*rateWindow)
+
+	aiServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"choices":[{"message":{"content":"偏多\n<summary>{\"bias\":\"bullish\",\"confidence\":70,\"summary\":\"趋势偏多\"}</summary>"}}],`+
+			`"usage":{"prompt_tokens":1000,"completion_tokens":200}}`)
+	}))
+	defer aiServer.Close()
+
+	db := createTestDB(t)
+	defer db.Close()
+	require.NoError(t, db.CreateAIModel("default", "custom", "Custom", "custom", true, "test-key", aiServer.URL))
+
+	s := &Server{database: db}
+	router := setupTestRouter()
+	withUser := func(h gin.HandlerFunc) gin.HandlerFunc {
+		return func(c *gin.Context) {
+			c.Set("user_id", "default")
+			h(c)
+		}
+	}
+	router.POST("/api/research", withUser(s.handleResearch))
+	router.GET("/api/research", withUser(s.handleListResearch))
+	router.GET("/api/research/:id", withUser(s.handleGetResearch))
+
+	body, _ := json.Marshal(map[string]string{"symbol": "avax", "question": "现在适合做多吗？", "ai_model_id": "custom"})
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/api/research", bytes.NewReader(body))
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var resp map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, "AVAXUSDT", resp["symbol"])
+	assert.Equal(t, "偏多", resp["analysis"])
+	assert.Equal(t, "bullish", resp["summary"].(map[string]interface{})["bias"])
+	assert.Equal(t, 35.5, resp["market_data"].(map[string]interface{})["price"])
+	assert.Contains(t, resp["market_text"], "AVAXUSDT")
+
+	// AI用量记入用户名下（不计入交易员）
+	usage, err := db.GetUserAIUsageSummary("default", time.Now().Add(-time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, 1, usage.Calls)
+	assert.Equal(t, int64(1200), usage.TotalTokens)
+	usage, err = db.GetAIUsageSummary(research.UsageTraderID, time.Now().Add(-time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, 1, usage.Calls)
+
+	// 研究记录可在列表和详情中查看
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/api/research?symbol=AVAX", nil)
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var list struct {
+		Reports []map[string]interface{} `json:"reports"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+	require.Len(t, list.Reports, 1)
+	assert.Equal(t, "现在适合做多吗？", list.Reports[0]["question"])
+	assert.Equal(t, "趋势偏多", list.Reports[0]["summary"].(map[string]interface{})["summary"])
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", fmt.Sprintf("/api/research/%v", resp["id"]), nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	// 其他币种没有记录
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/api/research?symbol=BTC", nil)
+	router.ServeHTTP(w, req)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+	assert.Empty(t, list.Reports)
+}
+
+// TestResearch_BudgetAndModelChecks 测试每日AI预算用尽和模型未启用时拒绝研究（不调用AI）
+func TestResearch_BudgetAndModelChecks(t *testing.T) {
+	researchLimiter.windows = make(map[string]*rateWindow)
+	db := createTestDB(t)
+	defer db.Close()
+
+	s := &Server{database: db}
+	router := setupTestRouter()
+	router.POST("/api/research", func(c *gin.Context) {
+		c.Set("user_id", "default")
+		s.handleResearch(c)
+	})
+	post := func(body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/api/research", bytes.NewBufferString(body))
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// 默认用户的模型均未配置API密钥
+	w := post(`{"symbol":"AVAX"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code, w.Body.String())
+
+	require.NoError(t, db.SetSystemConfig("user_daily_ai_budget_usd", "1"))
+	require.NoError(t, db.RecordAIUsage("trader-1", "default", "deepseek", "deepseek-chat", 1000, 100, 1.5))
+	w = post(`{"symbol":"AVAX"}`)
+	assert.Equal(t, http.StatusTooManyRequests, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), "每日预算")
+
+	w = post(`{}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
@@ -0,0 +1,53 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"aspen/decision"
+)
+
+// normalizeReviewConfig 校验两级模型配置，返回存储用的触发条件JSON和降级方式
+// reviewModelID 为空表示不启用复核；启用时复核模型必须是用户已启用的其他AI模型，且至少配置一个触发条件
+func (s *Server) normalizeReviewConfig(userID, scanModelID, reviewModelID string, triggers *decision.ReviewTriggers, fallback string) (string, string, error) {
+	normalizedFallback, err := decision.NormalizeReviewFallback(fallback)
+	if err != nil {
+		return "", "", err
+	}
+	if reviewModelID == "" {
+		return "", normalizedFallback, nil
+	}
+	if reviewModelID == scanModelID {
+		return "", "", fmt.Errorf("复核模型不能与扫描模型相同")
+	}
+
+	models, err := s.database.GetAIModels(userID)
+	if err != nil {
+		return "", "", fmt.Errorf("获取AI模型配置失败: %w", err)
+	}
+	found := false
+	for _, model := range models {
+		if model.ID == reviewModelID {
+			if !model.Enabled {
+				return "", "", fmt.Errorf("复核模型 %s 未启用", reviewModelID)
+			}
+			found = true
+			break
+		}
+	}
+	if !found {
+		return "", "", fmt.Errorf("复核模型 %s 不存在", reviewModelID)
+	}
+
+	if triggers == nil || !triggers.Enabled() {
+		return "", "", fmt.Errorf("启用复核模型时至少需要一个触发条件（open / close_at_loss_over_pct / size_over_usd）")
+	}
+	if triggers.CloseAtLossOverPct < 0 || triggers.SizeOverUSD < 0 {
+		return "", "", fmt.Errorf("复核触发条件不能为负数")
+	}
+	raw, err := json.Marshal(triggers)
+	if err != nil {
+		return "", "", fmt.Errorf("复核触发条件格式错误: %w", err)
+	}
+	return string(raw), normalizedFallback, nil
+}
@@ -6,11 +6,15 @@ import (
 	"aspen/crypto"
 	"aspen/decision"
 	"aspen/hook"
+	"aspen/logger"
 	"aspen/manager"
+	"aspen/market"
 	"aspen/metrics"
+	"aspen/pool"
 	"aspen/trader"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net"
@@ -21,6 +25,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
 )
 
 // Server HTTP API服务器
@@ -73,36 +78,7 @@ func NewServer(traderManager *manager.TraderManager, database *config.Database,
 // 否则只允许配置的源列表
 func corsMiddleware(corsConfig *config.CORSConfig) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		origin := c.Request.Header.Get("Origin")
-		allowedOrigin := "*"
-
-		// 检查是否有配置的源列表
-		if corsConfig != nil && len(corsConfig.AllowedOrigins) > 0 {
-			// 检查是否包含通配符
-			hasWildcard := false
-			for _, o := range corsConfig.AllowedOrigins {
-				if o == "*" {
-					hasWildcard = true
-					break
-				}
-			}
-
-			if !hasWildcard {
-				// 检查请求的Origin是否在允许列表中
-				allowed := false
-				for _, o := range corsConfig.AllowedOrigins {
-					if o == origin {
-						allowed = true
-						allowedOrigin = origin
-						break
-					}
-				}
-				if !allowed {
-					// 不在允许列表中，设置为空（浏览器会阻止跨域请求）
-					allowedOrigin = ""
-				}
-			}
-		}
+		allowedOrigin := corsAllowedOrigin(corsConfig, c.Request.Header.Get("Origin"))
 
 		if allowedOrigin != "" {
 			c.Writer.Header().Set("Access-Control-Allow-Origin", allowedOrigin)
@@ -120,11 +96,37 @@ func corsMiddleware(corsConfig *config.CORSConfig) gin.HandlerFunc {
 	}
 }
 
+// corsAllowedOrigin 返回响应允许的源："*" 表示允许所有源，空字符串表示该源不在允许列表中
+func corsAllowedOrigin(corsConfig *config.CORSConfig, origin string) string {
+	// 检查是否有配置的源列表
+	if corsConfig == nil || len(corsConfig.AllowedOrigins) == 0 {
+		return "*"
+	}
+
+	// 检查是否包含通配符
+	for _, o := range corsConfig.AllowedOrigins {
+		if o == "*" {
+			return "*"
+		}
+	}
+
+	// 检查请求的Origin是否在允许列表中，不在列表中时返回空（浏览器会阻止跨域请求）
+	for _, o := range corsConfig.AllowedOrigins {
+		if o == origin {
+			return origin
+		}
+	}
+	return ""
+}
+
 // setupRoutes 设置路由
 func (s *Server) setupRoutes() {
-	// Prometheus metrics端点（根路径，不需要认证）
+	// Prometheus metrics端点（根路径，使用独立的抓取凭证和来源限制，不使用用户JWT）
 	s.router.GET("/metrics", metrics.Handler())
 
+	// 禁止搜索引擎抓取公开分享页
+	s.router.GET("/robots.txt", s.handleRobots)
+
 	// API路由组
 	api := s.router.Group("/api")
 	{
@@ -147,6 +149,7 @@ func (s *Server) setupRoutes() {
 		// 系统提示词模板管理（无需认证）
 		api.GET("/prompt-templates", s.handleGetPromptTemplates)
 		api.GET("/prompt-templates/:name", s.handleGetPromptTemplate)
+		api.POST("/prompt/validate", s.handleValidatePrompt)
 
 		// 公开的竞赛数据（无需认证）
 		api.GET("/traders", s.handlePublicTraderList)
@@ -157,43 +160,44 @@ func (s *Server) setupRoutes() {
 		api.POST("/equity-history-batch", s.handleEquityHistoryBatch)
 		api.GET("/traders/:id/public-config", s.handleGetPublicTraderConfig)
 
+		// 交易员公开分享页（无需认证，按IP限流）
+		api.GET("/public/share/:slug", s.handlePublicShare)
+
 		// 认证相关路由（无需认证）
 		api.POST("/register", s.handleRegister)
 		api.POST("/login", s.handleLogin)
-		api.POST("/verify-otp", s.handleVerifyOTP)
-		api.POST("/complete-registration", s.handleCompleteRegistration)
+		api.POST("/otp/verify", s.handleVerifyOTP)
+		api.POST("/verify-otp", s.handleVerifyOTP) // 兼容旧路径
+		api.POST("/otp/enroll/confirm", s.handleCompleteRegistration)
+		api.POST("/complete-registration", s.handleCompleteRegistration) // 兼容旧路径
 
-		// 需要认证的路由
+		// 需要认证的路由（只读token可访问的查看类接口）
 		protected := api.Group("/", s.authMiddleware())
 		{
 			// 注销（加入黑名单）
 			protected.POST("/logout", s.handleLogout)
 
-			// 服务器IP查询（需要认证，用于白名单配置）
-			protected.GET("/server-ip", s.handleGetServerIP)
-
-			// AI交易员管理
+			// AI交易员查看
 			protected.GET("/my-traders", s.handleTraderList)
+			protected.GET("/traders/:id", s.handleGetTrader)
 			protected.GET("/traders/:id/config", s.handleGetTraderConfig)
-			protected.POST("/traders", s.handleCreateTrader)
-			protected.PUT("/traders/:id", s.handleUpdateTrader)
-			protected.DELETE("/traders/:id", s.handleDeleteTrader)
-			protected.POST("/traders/:id/start", s.handleStartTrader)
-			protected.POST("/traders/:id/stop", s.handleStopTrader)
-			protected.PUT("/traders/:id/prompt", s.handleUpdateTraderPrompt)
-			protected.POST("/traders/:id/sync-balance", s.handleSyncBalance)
-
-			// AI模型配置
-			protected.GET("/models", s.handleGetModelConfigs)
-			protected.PUT("/models", s.handleUpdateModelConfigs)
-
-			// 交易所配置
-			protected.GET("/exchanges", s.handleGetExchangeConfigs)
-			protected.PUT("/exchanges", s.handleUpdateExchangeConfigs)
-
-			// 用户信号源配置
-			protected.GET("/user/signal-sources", s.handleGetUserSignalSource)
-			protected.POST("/user/signal-sources", s.handleSaveUserSignalSource)
+			protected.GET("/traders/:id/positions", s.handleTraderPositions)
+			protected.GET("/traders/:id/positions/ws", s.handleTraderPositionsStream) // WebSocket 推送持仓详情（消息与轮询响应相同）
+			protected.GET("/traders/:id/symbols/:symbol/history", s.handleSymbolHistory)
+			protected.GET("/traders/:id/anomalies", s.handleTraderAnomalies)
+			protected.GET("/traders/:id/errors", s.handleTraderErrors)
+			protected.GET("/traders/:id/ai-cost", s.handleTraderAICost)
+			protected.GET("/traders/:id/metrics", s.handleTraderMetrics)
+			protected.GET("/traders/:id/shares", s.handleListShareLinks)
+			protected.POST("/traders/:id/simulate", s.handleSimulateDecision)  // 只在账户副本上模拟，不下单
+			protected.POST("/traders/:id/order-preview", s.handleOrderPreview) // 预览假设订单的保证金和强平价格，不下单
+
+			// 币种研究记录
+			protected.GET("/research", s.handleListResearch)
+			protected.GET("/research/:id", s.handleGetResearch)
+
+			// 币种指标解读（只根据行情数据推导，不调用AI）
+			protected.GET("/market/:symbol/explain", s.handleMarketExplain)
 
 			// 指定trader的数据（使用query参数 ?trader_id=xxx）
 			protected.GET("/status", s.handleStatus)
@@ -204,6 +208,66 @@ func (s *Server) setupRoutes() {
 			protected.GET("/statistics", s.handleStatistics)
 			protected.GET("/performance", s.handlePerformance)
 		}
+
+		// 需要完整权限（trade）的路由：交易员管理、凭证配置等，只读token返回403
+		trade := protected.Group("/", s.requireTradeScope())
+		{
+			// 签发只读token（用于只读看板）
+			trade.POST("/tokens/read-only", s.handleCreateReadOnlyToken)
+
+			// 服务器IP查询（需要认证，用于白名单配置）
+			trade.GET("/server-ip", s.handleGetServerIP)
+
+			// AI交易员管理
+			trade.POST("/traders", s.handleCreateTrader)
+			trade.POST("/traders/leverage", s.handleBulkUpdateLeverage) // 批量更新杠杆
+			trade.PUT("/traders/:id", s.handleUpdateTrader)
+			trade.DELETE("/traders/:id", s.handleDeleteTrader)
+			trade.POST("/traders/:id/start", s.handleStartTrader)
+			trade.POST("/traders/:id/reload", s.handleReloadTrader)
+			trade.POST("/traders/:id/stop", s.handleStopTrader)
+			trade.POST("/traders/:id/reset-disabled", s.handleResetTraderDisabled)
+			trade.POST("/traders/:id/run-now", s.handleRunTraderNow)
+			trade.PUT("/traders/:id/prompt", s.handleUpdateTraderPrompt)
+			trade.POST("/traders/:id/sync-balance", s.handleSyncBalance)
+			trade.POST("/traders/:id/anomalies/:anomalyId/ack", s.handleAcknowledgeAnomaly)
+			trade.DELETE("/traders/:id/errors", s.handleClearTraderErrors)
+			trade.POST("/traders/:id/share", s.handleCreateShareLink)
+			trade.DELETE("/traders/:id/shares/:slug", s.handleRevokeShareLink)
+
+			// 按需研究单个币种（调用AI，计入用户AI用量）
+			trade.POST("/research", s.handleResearch)
+
+			// 回放历史K线回测交易员的提示词和AI模型（调用AI，计入用户AI用量）
+			trade.POST("/backtest", s.handleBacktest)
+
+			// AI模型配置
+			trade.GET("/models", s.handleGetModelConfigs)
+			trade.PUT("/models", s.handleUpdateModelConfigs)
+
+			// 交易所配置
+			trade.GET("/exchanges", s.handleGetExchangeConfigs)
+			trade.PUT("/exchanges", s.handleUpdateExchangeConfigs)
+
+			// 用户信号源配置
+			trade.GET("/user/signal-sources", s.handleGetUserSignalSource)
+			trade.POST("/user/signal-sources", s.handleSaveUserSignalSource)
+
+			// 用户级风控（所有交易员合计）
+			trade.GET("/user/risk-limits", s.handleGetUserRiskLimits)
+			trade.PUT("/user/risk-limits", s.handleUpdateUserRiskLimits)
+			trade.GET("/user/webhook", s.handleGetUserWebhook)
+			trade.PUT("/user/webhook", s.handleUpdateUserWebhook)
+
+			// 管理员：交易员加载报告
+			trade.GET("/admin/load-report", s.handleLoadReport)
+			trade.GET("/admin/subscriptions", s.handleSubscriptions)
+			trade.GET("/admin/ai/latency", s.handleAILatency)
+			trade.GET("/admin/kill-switch", s.handleGetKillSwitch)
+			trade.POST("/admin/kill-switch", s.handleSetKillSwitch)
+			trade.POST("/admin/flatten-all", s.handleFlattenAll)
+			trade.POST("/admin/backup", s.handleBackup)
+		}
 	}
 }
 
@@ -438,6 +502,37 @@ type CreateTraderRequest struct {
 	IsCrossMargin        *bool   `json:"is_cross_margin"`        // 指针类型，nil表示使用默认值true
 	UseCoinPool          bool    `json:"use_coin_pool"`
 	UseOITop             bool    `json:"use_oi_top"`
+	// 单币种资金分配上限（占净值百分比，0 表示使用系统默认），可按币种覆盖
+	MaxSymbolAllocationPct    float64            `json:"max_symbol_allocation_pct"`
+	SymbolAllocationOverrides map[string]float64 `json:"symbol_allocation_overrides"`
+	MarginAsset               string             `json:"margin_asset"` // 保证金资产（USDT/USDC，默认USDT）
+	// 两级模型：ai_model_id 为扫描模型，review_model_id 为复核模型（为空表示不复核）
+	ReviewModelID  string                   `json:"review_model_id"`
+	ReviewTriggers *decision.ReviewTriggers `json:"review_triggers"`
+	ReviewFallback string                   `json:"review_fallback"` // 复核模型不可用时的处理方式（hold/execute，默认hold）
+	// 仓位大小模式：absolute（默认，AI给出金额）/ percent（AI给出占净值百分比，范围默认1-30%）
+	PositionSizingMode string  `json:"position_sizing_mode"`
+	PositionSizeMinPct float64 `json:"position_size_min_pct"`
+	PositionSizeMaxPct float64 `json:"position_size_max_pct"`
+	// 行情数据源（binance/bybit/binance_us/finnhub/hyperliquid），空表示使用全局数据源
+	DataSource string `json:"data_source"`
+	// 对冲策略：no_hedge（默认，有反向持仓时拒绝开仓）/ allow_flip（先平反向持仓再开仓）
+	HedgePolicy string `json:"hedge_policy"`
+	// 只管理持仓模式：不开新仓和加仓，平仓和止盈止损调整照常
+	ManageOnly bool `json:"manage_only"`
+	// 硬回撤上限（%，0-100）：净值自最高点回撤达到该值时清仓并永久停用，0 表示不启用
+	HardDrawdownPct float64 `json:"hard_drawdown_pct"`
+	// 账户净值下限（绝对值）：净值低于该值时禁止开仓，0 表示不限制
+	MinEquity float64 `json:"min_equity"`
+	// 指标周期（RSI/EMA/ATR/TSI），未配置的周期使用默认值
+	IndicatorParams *market.IndicatorParams `json:"indicator_params"`
+	// 模拟仓真实度档位（optimistic/realistic/pessimistic），空表示使用系统配置；单项覆盖优先于档位
+	PaperRealism          string                        `json:"paper_realism"`
+	PaperRealismOverrides *trader.PaperRealismOverrides `json:"paper_realism_overrides"`
+	// 使用币种池时最多保留的币种数量，0 表示不限制
+	CoinPoolMaxSymbols int `json:"coin_pool_max_symbols"`
+	// 币种池超出上限时的排序依据（volume/oi），空为 volume
+	CoinPoolRankBy string `json:"coin_pool_rank_by"`
 }
 
 type ModelConfig struct {
@@ -513,6 +608,16 @@ func (s *Server) handleCreateTrader(c *gin.Context) {
 		return
 	}
 
+	// 检查用户交易员数量上限
+	if err := s.checkTraderLimit(userID); err != nil {
+		if errors.Is(err, errTraderLimitReached) {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
 	// 校验杠杆值
 	if req.BTCETHLeverage < 0 || req.BTCETHLeverage > 50 {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "BTC/ETH杠杆必须在1-50倍之间"})
@@ -523,16 +628,99 @@ func (s *Server) handleCreateTrader(c *gin.Context) {
 		return
 	}
 
-	// 校验交易币种格式
-	if req.TradingSymbols != "" {
-		symbols := strings.Split(req.TradingSymbols, ",")
-		for _, symbol := range symbols {
-			symbol = strings.TrimSpace(symbol)
-			if symbol != "" && !strings.HasSuffix(strings.ToUpper(symbol), "USDT") {
-				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("无效的币种格式: %s，必须以USDT结尾", symbol)})
-				return
-			}
-		}
+	// 校验行情数据源
+	dataSource, err := market.ParseDataSource(req.DataSource)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 标准化并按交易员的行情数据源校验交易币种
+	tradingSymbols, err := s.resolveTradingSymbols(req.TradingSymbols, dataSource)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("交易币种无效: %v", err)})
+		return
+	}
+
+	// 校验单币种资金分配上限
+	allocationOverrides, err := normalizeAllocationBudget(req.MaxSymbolAllocationPct, req.SymbolAllocationOverrides)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 校验保证金资产
+	marginAsset, err := trader.NormalizeMarginAsset(req.MarginAsset)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 校验两级模型配置
+	reviewTriggers, reviewFallback, err := s.normalizeReviewConfig(userID, req.AIModelID, req.ReviewModelID, req.ReviewTriggers, req.ReviewFallback)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 校验仓位大小模式
+	sizing, err := decision.NormalizePositionSizing(req.PositionSizingMode, req.PositionSizeMinPct, req.PositionSizeMaxPct)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 校验对冲策略
+	hedgePolicy, err := trader.NormalizeHedgePolicy(req.HedgePolicy)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 校验指标周期
+	var indicatorParams market.IndicatorParams
+	if req.IndicatorParams != nil {
+		indicatorParams = *req.IndicatorParams
+	}
+	if err := indicatorParams.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 校验模拟仓真实度档位和单项覆盖
+	paperRealism, err := trader.NormalizePaperRealism(req.PaperRealism)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	var paperOverrides trader.PaperRealismOverrides
+	if req.PaperRealismOverrides != nil {
+		paperOverrides = *req.PaperRealismOverrides
+	}
+	if err := paperOverrides.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 校验币种池数量上限和排序依据
+	if req.CoinPoolMaxSymbols < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "币种池数量上限不能为负数"})
+		return
+	}
+	coinPoolRankBy, err := pool.NormalizeRankBy(req.CoinPoolRankBy)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 校验硬回撤上限和净值下限
+	if err := validateHardDrawdownPct(req.HardDrawdownPct); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.MinEquity < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "净值下限不能为负数"})
+		return
 	}
 
 	// 生成交易员ID
@@ -657,7 +845,7 @@ func (s *Server) handleCreateTrader(c *gin.Context) {
 		InitialBalance:       actualBalance, // 使用实际查询的余额
 		BTCETHLeverage:       btcEthLeverage,
 		AltcoinLeverage:      altcoinLeverage,
-		TradingSymbols:       req.TradingSymbols,
+		TradingSymbols:       tradingSymbols.Canonical(),
 		UseCoinPool:          req.UseCoinPool,
 		UseOITop:             req.UseOITop,
 		CustomPrompt:         req.CustomPrompt,
@@ -666,6 +854,26 @@ func (s *Server) handleCreateTrader(c *gin.Context) {
 		IsCrossMargin:        isCrossMargin,
 		ScanIntervalMinutes:  scanIntervalMinutes,
 		IsRunning:            false,
+
+		MaxSymbolAllocationPct:    req.MaxSymbolAllocationPct,
+		SymbolAllocationOverrides: allocationOverrides,
+		MarginAsset:               marginAsset,
+		ReviewModelID:             req.ReviewModelID,
+		ReviewTriggers:            reviewTriggers,
+		ReviewFallback:            reviewFallback,
+		PositionSizingMode:        sizing.Mode,
+		PositionSizeMinPct:        sizing.MinPct,
+		PositionSizeMaxPct:        sizing.MaxPct,
+		DataSource:                string(dataSource),
+		HedgePolicy:               hedgePolicy,
+		IndicatorParams:           indicatorParams.Encode(),
+		ManageOnly:                req.ManageOnly,
+		HardDrawdownPct:           req.HardDrawdownPct,
+		MinEquity:                 req.MinEquity,
+		PaperRealism:              paperRealism,
+		PaperRealismOverrides:     paperOverrides.Encode(),
+		CoinPoolMaxSymbols:        req.CoinPoolMaxSymbols,
+		CoinPoolRankBy:            coinPoolRankBy,
 	}
 
 	// 保存到数据库
@@ -681,14 +889,17 @@ func (s *Server) handleCreateTrader(c *gin.Context) {
 		log.Printf("⚠️ 加载交易员到内存失败: %v", err)
 		// 这里不返回错误，因为交易员已经成功创建到数据库
 	}
+	s.traderManager.SyncSubscriptions()
 
 	log.Printf("✓ 创建交易员成功: %s (模型: %s, 交易所: %s)", req.Name, req.AIModelID, req.ExchangeID)
 
 	c.JSON(http.StatusCreated, gin.H{
-		"trader_id":   traderID,
-		"trader_name": req.Name,
-		"ai_model":    req.AIModelID,
-		"is_running":  false,
+		"trader_id":       traderID,
+		"trader_name":     req.Name,
+		"ai_model":        req.AIModelID,
+		"is_running":      false,
+		"trading_symbols": tradingSymbols.Symbols,
+		"symbol_warnings": tradingSymbols.Warnings,
 	})
 }
 
@@ -706,6 +917,38 @@ type UpdateTraderRequest struct {
 	OverrideBasePrompt   bool    `json:"override_base_prompt"`
 	SystemPromptTemplate string  `json:"system_prompt_template"`
 	IsCrossMargin        *bool   `json:"is_cross_margin"`
+	// 单币种资金分配上限，未提供时保持原值
+	MaxSymbolAllocationPct    *float64           `json:"max_symbol_allocation_pct"`
+	SymbolAllocationOverrides map[string]float64 `json:"symbol_allocation_overrides"`
+	MarginAsset               string             `json:"margin_asset"` // 保证金资产，为空时保持原值
+	// 两级模型，未提供时保持原值（review_model_id 为空字符串表示关闭复核）
+	ReviewModelID  *string                  `json:"review_model_id"`
+	ReviewTriggers *decision.ReviewTriggers `json:"review_triggers"`
+	ReviewFallback string                   `json:"review_fallback"`
+	// 仓位大小模式，未提供时保持原值
+	PositionSizingMode string   `json:"position_sizing_mode"`
+	PositionSizeMinPct *float64 `json:"position_size_min_pct"`
+	PositionSizeMaxPct *float64 `json:"position_size_max_pct"`
+	// 行情数据源，未提供时保持原值，空字符串表示改回全局数据源
+	DataSource *string `json:"data_source"`
+	// 对冲策略，未提供时保持原值
+	HedgePolicy string `json:"hedge_policy"`
+	// 只管理持仓模式，未提供时保持原值（运行中的交易员立即生效）
+	ManageOnly *bool `json:"manage_only"`
+	// 硬回撤上限，未提供时保持原值
+	HardDrawdownPct *float64 `json:"hard_drawdown_pct"`
+	// 账户净值下限，未提供时保持原值
+	MinEquity *float64 `json:"min_equity"`
+	// 指标周期，未提供时保持原值，{} 表示恢复默认周期
+	IndicatorParams *market.IndicatorParams `json:"indicator_params"`
+	// 模拟仓真实度档位，未提供时保持原值，空字符串表示改回系统配置
+	PaperRealism *string `json:"paper_realism"`
+	// 模拟仓单项覆盖，未提供时保持原值，{} 表示清除覆盖
+	PaperRealismOverrides *trader.PaperRealismOverrides `json:"paper_realism_overrides"`
+	// 币种池数量上限，未提供时保持原值，0 表示不限制
+	CoinPoolMaxSymbols *int `json:"coin_pool_max_symbols"`
+	// 币种池排序依据，未提供时保持原值
+	CoinPoolRankBy *string `json:"coin_pool_rank_by"`
 }
 
 // handleUpdateTrader 更新交易员配置
@@ -763,6 +1006,170 @@ func (s *Server) handleUpdateTrader(c *gin.Context) {
 		scanIntervalMinutes = 3
 	}
 
+	// 行情数据源，未提供时保持原值
+	dataSource := market.DataSource(existingTrader.DataSource)
+	if req.DataSource != nil {
+		dataSource, err = market.ParseDataSource(*req.DataSource)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	// 标准化并按交易员的行情数据源校验交易币种（旧格式的存储值在此次更新时一并规范化）
+	tradingSymbols, err := s.resolveTradingSymbols(req.TradingSymbols, dataSource)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("交易币种无效: %v", err)})
+		return
+	}
+
+	// 单币种资金分配上限，未提供时保持原值
+	maxSymbolAllocationPct := existingTrader.MaxSymbolAllocationPct
+	if req.MaxSymbolAllocationPct != nil {
+		maxSymbolAllocationPct = *req.MaxSymbolAllocationPct
+	}
+	allocationOverrides := existingTrader.SymbolAllocationOverrides
+	if req.SymbolAllocationOverrides != nil {
+		allocationOverrides, err = normalizeAllocationBudget(maxSymbolAllocationPct, req.SymbolAllocationOverrides)
+	} else {
+		_, err = normalizeAllocationBudget(maxSymbolAllocationPct, nil)
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 保证金资产，未提供时保持原值
+	marginAsset := existingTrader.MarginAsset
+	if req.MarginAsset != "" {
+		if marginAsset, err = trader.NormalizeMarginAsset(req.MarginAsset); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	// 两级模型，未提供时保持原值
+	reviewModelID := existingTrader.ReviewModelID
+	if req.ReviewModelID != nil {
+		reviewModelID = *req.ReviewModelID
+	}
+	reviewTriggerCfg := req.ReviewTriggers
+	if reviewTriggerCfg == nil {
+		if existing, err := decision.ParseReviewTriggers(existingTrader.ReviewTriggers); err == nil {
+			reviewTriggerCfg = &existing
+		}
+	}
+	reviewFallback := req.ReviewFallback
+	if reviewFallback == "" {
+		reviewFallback = existingTrader.ReviewFallback
+	}
+	reviewTriggers, reviewFallback, err := s.normalizeReviewConfig(userID, req.AIModelID, reviewModelID, reviewTriggerCfg, reviewFallback)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 仓位大小模式，未提供时保持原值
+	sizingMode := req.PositionSizingMode
+	if sizingMode == "" {
+		sizingMode = existingTrader.PositionSizingMode
+	}
+	sizeMinPct := existingTrader.PositionSizeMinPct
+	if req.PositionSizeMinPct != nil {
+		sizeMinPct = *req.PositionSizeMinPct
+	}
+	sizeMaxPct := existingTrader.PositionSizeMaxPct
+	if req.PositionSizeMaxPct != nil {
+		sizeMaxPct = *req.PositionSizeMaxPct
+	}
+	sizing, err := decision.NormalizePositionSizing(sizingMode, sizeMinPct, sizeMaxPct)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 对冲策略，未提供时保持原值
+	hedgePolicy := existingTrader.HedgePolicy
+	if req.HedgePolicy != "" {
+		hedgePolicy, err = trader.NormalizeHedgePolicy(req.HedgePolicy)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	// 指标周期，未提供时保持原值
+	indicatorParams, _ := market.ParseIndicatorParams(existingTrader.IndicatorParams)
+	if req.IndicatorParams != nil {
+		indicatorParams = *req.IndicatorParams
+		if err := indicatorParams.Validate(); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	// 模拟仓真实度档位和单项覆盖，未提供时保持原值（已加载的交易员重新加载后生效）
+	paperRealism := existingTrader.PaperRealism
+	if req.PaperRealism != nil {
+		paperRealism, err = trader.NormalizePaperRealism(*req.PaperRealism)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	paperOverrides, _ := trader.ParsePaperRealismOverrides(existingTrader.PaperRealismOverrides)
+	if req.PaperRealismOverrides != nil {
+		paperOverrides = *req.PaperRealismOverrides
+		if err := paperOverrides.Validate(); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	// 币种池数量上限和排序依据，未提供时保持原值
+	coinPoolMaxSymbols := existingTrader.CoinPoolMaxSymbols
+	if req.CoinPoolMaxSymbols != nil {
+		if *req.CoinPoolMaxSymbols < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "币种池数量上限不能为负数"})
+			return
+		}
+		coinPoolMaxSymbols = *req.CoinPoolMaxSymbols
+	}
+	coinPoolRankBy := existingTrader.CoinPoolRankBy
+	if req.CoinPoolRankBy != nil {
+		coinPoolRankBy, err = pool.NormalizeRankBy(*req.CoinPoolRankBy)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	// 只管理持仓模式，未提供时保持原值
+	manageOnly := existingTrader.ManageOnly
+	if req.ManageOnly != nil {
+		manageOnly = *req.ManageOnly
+	}
+
+	// 硬回撤上限，未提供时保持原值（已加载的交易员重新加载后生效）
+	hardDrawdownPct := existingTrader.HardDrawdownPct
+	if req.HardDrawdownPct != nil {
+		hardDrawdownPct = *req.HardDrawdownPct
+		if err := validateHardDrawdownPct(hardDrawdownPct); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	// 账户净值下限，未提供时保持原值
+	minEquity := existingTrader.MinEquity
+	if req.MinEquity != nil {
+		minEquity = *req.MinEquity
+		if minEquity < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "净值下限不能为负数"})
+			return
+		}
+	}
+
 	// 设置提示词模板，允许更新
 	systemPromptTemplate := req.SystemPromptTemplate
 	if systemPromptTemplate == "" {
@@ -779,13 +1186,33 @@ func (s *Server) handleUpdateTrader(c *gin.Context) {
 		InitialBalance:       req.InitialBalance,
 		BTCETHLeverage:       btcEthLeverage,
 		AltcoinLeverage:      altcoinLeverage,
-		TradingSymbols:       req.TradingSymbols,
+		TradingSymbols:       tradingSymbols.Canonical(),
 		CustomPrompt:         req.CustomPrompt,
 		OverrideBasePrompt:   req.OverrideBasePrompt,
 		SystemPromptTemplate: systemPromptTemplate,
 		IsCrossMargin:        isCrossMargin,
 		ScanIntervalMinutes:  scanIntervalMinutes,
 		IsRunning:            existingTrader.IsRunning, // 保持原值
+
+		MaxSymbolAllocationPct:    maxSymbolAllocationPct,
+		SymbolAllocationOverrides: allocationOverrides,
+		MarginAsset:               marginAsset,
+		ReviewModelID:             reviewModelID,
+		ReviewTriggers:            reviewTriggers,
+		ReviewFallback:            reviewFallback,
+		PositionSizingMode:        sizing.Mode,
+		PositionSizeMinPct:        sizing.MinPct,
+		PositionSizeMaxPct:        sizing.MaxPct,
+		DataSource:                string(dataSource),
+		HedgePolicy:               hedgePolicy,
+		IndicatorParams:           indicatorParams.Encode(),
+		ManageOnly:                manageOnly,
+		HardDrawdownPct:           hardDrawdownPct,
+		MinEquity:                 minEquity,
+		PaperRealism:              paperRealism,
+		PaperRealismOverrides:     paperOverrides.Encode(),
+		CoinPoolMaxSymbols:        coinPoolMaxSymbols,
+		CoinPoolRankBy:            coinPoolRankBy,
 	}
 
 	// 更新数据库
@@ -800,14 +1227,22 @@ func (s *Server) handleUpdateTrader(c *gin.Context) {
 	if err != nil {
 		log.Printf("⚠️ 重新加载交易员到内存失败: %v", err)
 	}
+	s.traderManager.SyncSubscriptions()
+
+	// 已加载的交易员不会重新创建，只管理持仓模式直接切换
+	if at, err := s.traderManager.GetTrader(traderID); err == nil {
+		at.SetManageOnly(manageOnly)
+	}
 
 	log.Printf("✓ 更新交易员成功: %s (模型: %s, 交易所: %s)", req.Name, req.AIModelID, req.ExchangeID)
 
 	c.JSON(http.StatusOK, gin.H{
-		"trader_id":   traderID,
-		"trader_name": req.Name,
-		"ai_model":    req.AIModelID,
-		"message":     "交易员更新成功",
+		"trader_id":       traderID,
+		"trader_name":     req.Name,
+		"ai_model":        req.AIModelID,
+		"message":         "交易员更新成功",
+		"trading_symbols": tradingSymbols.Symbols,
+		"symbol_warnings": tradingSymbols.Warnings,
 	})
 }
 
@@ -823,7 +1258,8 @@ func (s *Server) handleDeleteTrader(c *gin.Context) {
 		return
 	}
 
-	// 如果交易员正在运行，先停止它
+	// 如果交易员正在运行，先停止它（并取消等待中的自动重启）
+	s.traderManager.CancelRestart(traderID)
 	if trader, err := s.traderManager.GetTrader(traderID); err == nil {
 		status := trader.GetStatus()
 		if isRunning, ok := status["is_running"].(bool); ok && isRunning {
@@ -831,6 +1267,9 @@ func (s *Server) handleDeleteTrader(c *gin.Context) {
 			log.Printf("⏹  已停止运行中的交易员: %s", traderID)
 		}
 	}
+	s.traderManager.ClearLoadError(traderID)
+	s.traderManager.RemoveTrader(traderID)
+	s.traderManager.SyncSubscriptions()
 
 	log.Printf("✓ 交易员已删除: %s", traderID)
 	c.JSON(http.StatusOK, gin.H{"message": "交易员已删除"})
@@ -853,36 +1292,53 @@ func (s *Server) handleStartTrader(c *gin.Context) {
 
 	trader, err := s.traderManager.GetTrader(traderID)
 	if err != nil {
+		if loadErr := s.traderManager.GetLoadError(traderID); loadErr != nil {
+			c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("交易员加载失败: %s，请修复后重新加载", loadErr.Reason), "status": "load_error"})
+			return
+		}
 		c.JSON(http.StatusNotFound, gin.H{"error": "交易员不存在"})
 		return
 	}
 
-	// 检查交易员是否已经在运行
-	status := trader.GetStatus()
-	if isRunning, ok := status["is_running"].(bool); ok && isRunning {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "交易员已在运行中"})
+	// 已在运行时不重复启动，返回当前状态（重复请求幂等）
+	if s.traderManager.IsTraderRunning(trader) {
+		c.JSON(http.StatusOK, gin.H{"message": "交易员已在运行中", "status": "already_running", "trader": trader.GetStatus()})
+		return
+	}
+
+	// 全局交易开关开启时禁止启动
+	if manager.KillSwitchEnabled(s.database) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "全局交易开关已开启，禁止启动交易员"})
 		return
 	}
 
 	// 重新加载系统提示词模板（确保使用最新的硬盘文件）
 	s.reloadPromptTemplatesWithLog(templateName)
 
-	// 启动交易员
-	go func() {
-		log.Printf("▶️  启动交易员 %s (%s)", traderID, trader.GetName())
-		if err := trader.Run(); err != nil {
-			log.Printf("❌ 交易员 %s 运行错误: %v", trader.GetName(), err)
+	// 启动交易员（并发的重复启动只有一个生效）
+	if err := s.traderManager.StartTrader(s.database, userID, trader); err != nil {
+		if errors.Is(err, manager.ErrTraderAlreadyRunning) {
+			c.JSON(http.StatusOK, gin.H{"message": "交易员已在运行中", "status": "already_running", "trader": trader.GetStatus()})
+			return
 		}
-	}()
-
-	// 更新数据库中的运行状态
-	err = s.database.UpdateTraderStatus(userID, traderID, true)
-	if err != nil {
-		log.Printf("⚠️  更新交易员状态失败: %v", err)
+		if errors.Is(err, manager.ErrTraderDisabled) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error() + "，请先重置停用状态", "status": "disabled"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
 	}
 
 	log.Printf("✓ 交易员 %s 已启动", trader.GetName())
-	c.JSON(http.StatusOK, gin.H{"message": "交易员已启动"})
+	c.JSON(http.StatusOK, gin.H{"message": "交易员已启动", "status": "started", "trader": trader.GetStatus()})
+}
+
+// validateHardDrawdownPct 校验硬回撤上限（0 表示不启用）
+func validateHardDrawdownPct(pct float64) error {
+	if pct < 0 || pct >= 100 {
+		return fmt.Errorf("硬回撤上限必须在 0-100 之间（0 表示不启用），当前为 %.2f", pct)
+	}
+	return nil
 }
 
 // handleStopTrader 停止交易员
@@ -903,24 +1359,42 @@ func (s *Server) handleStopTrader(c *gin.Context) {
 		return
 	}
 
-	// 检查交易员是否正在运行
-	status := trader.GetStatus()
-	if isRunning, ok := status["is_running"].(bool); ok && !isRunning {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "交易员已停止"})
+	// 停止交易员（同时取消后续的自动重启，异常退出后正在等待重启的交易员也可以停止）
+	if err := s.traderManager.StopTrader(s.database, userID, traderID); err != nil {
+		if errors.Is(err, manager.ErrTraderNotRunning) {
+			// 已停止时重复停止不报错（幂等）
+			c.JSON(http.StatusOK, gin.H{"message": "交易员已停止", "status": "already_stopped", "trader": trader.GetStatus()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	// 停止交易员
-	trader.Stop()
+	log.Printf("⏹  交易员 %s 已停止", trader.GetName())
+	c.JSON(http.StatusOK, gin.H{"message": "交易员已停止", "status": "stopped", "trader": trader.GetStatus()})
+}
 
-	// 更新数据库中的运行状态
-	err = s.database.UpdateTraderStatus(userID, traderID, false)
+// handleResetTraderDisabled 手动重置因硬回撤停用的交易员（重置后需手动启动）
+func (s *Server) handleResetTraderDisabled(c *gin.Context) {
+	userID := c.GetString("user_id")
+	traderID := c.Param("id")
+
+	// 校验交易员是否属于当前用户
+	traderRecord, _, _, err := s.database.GetTraderConfig(userID, traderID)
 	if err != nil {
-		log.Printf("⚠️  更新交易员状态失败: %v", err)
+		c.JSON(http.StatusNotFound, gin.H{"error": "交易员不存在或无访问权限"})
+		return
+	}
+	if traderRecord.DisabledReason == "" {
+		c.JSON(http.StatusOK, gin.H{"message": "交易员未停用", "status": "not_disabled"})
+		return
 	}
 
-	log.Printf("⏹  交易员 %s 已停止", trader.GetName())
-	c.JSON(http.StatusOK, gin.H{"message": "交易员已停止"})
+	if err := s.traderManager.ResetTraderDisabled(s.database, userID, traderID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("重置停用状态失败: %v", err)})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "已重置停用状态，可以重新启动交易员", "status": "reset", "previous_reason": traderRecord.DisabledReason})
 }
 
 // handleUpdateTraderPrompt 更新交易员自定义Prompt
@@ -1301,10 +1775,13 @@ func (s *Server) handleSaveUserSignalSource(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "用户信号源配置已保存"})
 }
 
-// handleTraderList trader列表
+// handleTraderList trader列表（支持 ?exchange= 和 ?ai_model= 过滤）
 func (s *Server) handleTraderList(c *gin.Context) {
 	userID := c.GetString("user_id")
-	traders, err := s.database.GetTraders(userID)
+	traders, err := s.database.GetTradersFiltered(userID, config.TraderFilter{
+		ExchangeID: strings.TrimSpace(c.Query("exchange")),
+		AIModelID:  strings.TrimSpace(c.Query("ai_model")),
+	})
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("获取交易员列表失败: %v", err)})
 		return
@@ -1314,23 +1791,40 @@ func (s *Server) handleTraderList(c *gin.Context) {
 	for _, trader := range traders {
 		// 获取实时运行状态
 		isRunning := trader.IsRunning
+		var loadErr *manager.TraderLoadError
 		if at, err := s.traderManager.GetTrader(trader.ID); err == nil {
 			status := at.GetStatus()
 			if running, ok := status["is_running"].(bool); ok {
 				isRunning = running
 			}
+		} else {
+			// 加载失败的交易员不在内存中，返回失败原因而不是让其从列表中消失
+			loadErr = s.traderManager.GetLoadError(trader.ID)
+		}
+
+		status := "stopped"
+		if loadErr != nil {
+			status = "load_error"
+			isRunning = false
+		} else if isRunning {
+			status = "running"
 		}
 
 		// 返回完整的 AIModelID（如 "admin_deepseek"），不要截断
 		// 前端需要完整 ID 来验证模型是否存在（与 handleGetTraderConfig 保持一致）
-		result = append(result, map[string]interface{}{
+		item := map[string]interface{}{
 			"trader_id":       trader.ID,
 			"trader_name":     trader.Name,
 			"ai_model":        trader.AIModelID, // 使用完整 ID
 			"exchange_id":     trader.ExchangeID,
 			"is_running":      isRunning,
+			"status":          status,
 			"initial_balance": trader.InitialBalance,
-		})
+		}
+		if loadErr != nil {
+			item["load_error"] = loadErr.Reason
+		}
+		result = append(result, item)
 	}
 
 	c.JSON(http.StatusOK, result)
@@ -1363,6 +1857,10 @@ func (s *Server) handleGetTraderConfig(c *gin.Context) {
 
 	// 返回完整的模型ID，不做转换，保持与前端模型列表一致
 	aiModelID := traderConfig.AIModelID
+	allocationOverrides, _ := decision.ParseAllocationOverrides(traderConfig.SymbolAllocationOverrides)
+	reviewTriggers, _ := decision.ParseReviewTriggers(traderConfig.ReviewTriggers)
+	indicatorParams, _ := market.ParseIndicatorParams(traderConfig.IndicatorParams)
+	paperOverrides, _ := trader.ParsePaperRealismOverrides(traderConfig.PaperRealismOverrides)
 
 	result := map[string]interface{}{
 		"trader_id":              traderConfig.ID,
@@ -1381,6 +1879,27 @@ func (s *Server) handleGetTraderConfig(c *gin.Context) {
 		"use_coin_pool":          traderConfig.UseCoinPool,
 		"use_oi_top":             traderConfig.UseOITop,
 		"is_running":             isRunning,
+
+		"max_symbol_allocation_pct":   traderConfig.MaxSymbolAllocationPct,
+		"symbol_allocation_overrides": allocationOverrides,
+		"margin_asset":                traderConfig.MarginAsset,
+		"review_model_id":             traderConfig.ReviewModelID,
+		"review_triggers":             reviewTriggers,
+		"review_fallback":             traderConfig.ReviewFallback,
+		"position_sizing_mode":        traderConfig.PositionSizingMode,
+		"position_size_min_pct":       traderConfig.PositionSizeMinPct,
+		"position_size_max_pct":       traderConfig.PositionSizeMaxPct,
+		"data_source":                 traderConfig.DataSource,
+		"hedge_policy":                traderConfig.HedgePolicy,
+		"manage_only":                 traderConfig.ManageOnly,
+		"hard_drawdown_pct":           traderConfig.HardDrawdownPct,
+		"disabled_reason":             traderConfig.DisabledReason,
+		"min_equity":                  traderConfig.MinEquity,
+		"indicator_params":            indicatorParams.WithDefaults(),
+		"paper_realism":               traderConfig.PaperRealism,
+		"paper_realism_overrides":     paperOverrides,
+		"coin_pool_max_symbols":       traderConfig.CoinPoolMaxSymbols,
+		"coin_pool_rank_by":           traderConfig.CoinPoolRankBy,
 	}
 
 	c.JSON(http.StatusOK, result)
@@ -1462,6 +1981,238 @@ func (s *Server) handlePositions(c *gin.Context) {
 	c.JSON(http.StatusOK, positions)
 }
 
+// handleGetTrader 指定交易员的运行状态（含下一周期倒计时）
+func (s *Server) handleGetTrader(c *gin.Context) {
+	userID := c.GetString("user_id")
+	traderID := c.Param("id")
+
+	// 校验交易员归属
+	if _, _, _, err := s.database.GetTraderConfig(userID, traderID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "交易员不存在或无访问权限"})
+		return
+	}
+
+	trader, err := s.traderManager.GetTrader(traderID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, trader.GetStatus())
+}
+
+// handleRunTraderNow 立即触发一次决策周期
+// 与定时周期执行相同的逻辑；?reschedule=true 时从现在起重新计算定时周期
+func (s *Server) handleRunTraderNow(c *gin.Context) {
+	userID := c.GetString("user_id")
+	traderID := c.Param("id")
+
+	// 校验交易员归属
+	if _, _, _, err := s.database.GetTraderConfig(userID, traderID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "交易员不存在或无访问权限"})
+		return
+	}
+
+	at, err := s.traderManager.GetTrader(traderID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	reschedule, _ := strconv.ParseBool(c.Query("reschedule"))
+	cycle, err := at.RunNow(userID, reschedule)
+	if err != nil {
+		var inProgress *trader.CycleInProgressError
+		var cooldown *trader.ManualTriggerCooldownError
+		switch {
+		case errors.As(err, &inProgress):
+			c.JSON(http.StatusConflict, gin.H{
+				"error":         err.Error(),
+				"running_cycle": inProgress.CycleNumber,
+			})
+		case errors.As(err, &cooldown):
+			c.JSON(http.StatusTooManyRequests, gin.H{
+				"error":               err.Error(),
+				"retry_after_seconds": int(cooldown.Remaining.Seconds()) + 1,
+			})
+		case errors.Is(err, trader.ErrTraderNotRunning):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	status := at.GetStatus()
+	c.JSON(http.StatusAccepted, gin.H{
+		"message":           "决策周期已触发",
+		"cycle":             cycle,
+		"trigger_type":      trader.TriggerManual,
+		"rescheduled":       reschedule,
+		"next_cycle_at":     status["next_cycle_at"],
+		"seconds_remaining": status["seconds_remaining"],
+	})
+}
+
+// handleTraderPositions 指定交易员的持仓详情（仅限所有者，可高频轮询）
+func (s *Server) handleTraderPositions(c *gin.Context) {
+	userID := c.GetString("user_id")
+	traderID := c.Param("id")
+
+	// 校验交易员归属
+	if _, _, _, err := s.database.GetTraderConfig(userID, traderID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "交易员不存在或无访问权限"})
+		return
+	}
+
+	trader, err := s.traderManager.GetTrader(traderID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	positions, err := trader.GetPositionViews()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": fmt.Sprintf("获取持仓详情失败: %v", err),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, positionsPayload(traderID, positions))
+}
+
+// handleSymbolHistory 单个币种的时间线（决策与完整交易合并，从旧到新）
+// lookback 为读取的决策周期数，默认 500
+func (s *Server) handleSymbolHistory(c *gin.Context) {
+	userID := c.GetString("user_id")
+	traderID := c.Param("id")
+	symbol := market.Normalize(c.Param("symbol"))
+
+	// 校验交易员归属
+	if _, _, _, err := s.database.GetTraderConfig(userID, traderID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "交易员不存在或无访问权限"})
+		return
+	}
+
+	lookback := 500
+	if str := c.Query("lookback"); str != "" {
+		val, err := strconv.Atoi(str)
+		if err != nil || val <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "lookback 必须为正整数"})
+			return
+		}
+		lookback = val
+	}
+
+	trader, err := s.traderManager.GetTrader(traderID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	history, err := trader.GetSymbolHistory(symbol, lookback)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": fmt.Sprintf("获取币种历史失败: %v", err),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"trader_id": traderID,
+		"symbol":    symbol,
+		"history":   history,
+	})
+}
+
+// handleTraderAnomalies 交易员行为异常记录（从新到旧，含基线与观测值）
+func (s *Server) handleTraderAnomalies(c *gin.Context) {
+	userID := c.GetString("user_id")
+	traderID := c.Param("id")
+
+	// 校验交易员归属
+	if _, _, _, err := s.database.GetTraderConfig(userID, traderID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "交易员不存在或无访问权限"})
+		return
+	}
+
+	trader, err := s.traderManager.GetTrader(traderID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	anomalies := trader.GetAnomalies()
+	active := 0
+	for _, a := range anomalies {
+		if a.Active() {
+			active++
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"trader_id": traderID,
+		"active":    active,
+		"anomalies": anomalies,
+	})
+}
+
+// handleAcknowledgeAnomaly 确认（忽略）交易员行为异常
+func (s *Server) handleAcknowledgeAnomaly(c *gin.Context) {
+	userID := c.GetString("user_id")
+	traderID := c.Param("id")
+
+	// 校验交易员归属
+	if _, _, _, err := s.database.GetTraderConfig(userID, traderID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "交易员不存在或无访问权限"})
+		return
+	}
+
+	trader, err := s.traderManager.GetTrader(traderID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	anomaly, err := trader.AcknowledgeAnomaly(c.Param("anomalyId"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"anomaly": anomaly})
+}
+
+// handleTraderAICost 指定trader在时间窗口内的AI调用成本汇总
+// since 支持 RFC3339 时间、Unix秒级时间戳或相对时长（如 24h、7d），默认最近24小时
+func (s *Server) handleTraderAICost(c *gin.Context) {
+	userID := c.GetString("user_id")
+	traderID := c.Param("id")
+
+	// 校验交易员归属
+	if _, _, _, err := s.database.GetTraderConfig(userID, traderID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "交易员不存在或无访问权限"})
+		return
+	}
+
+	since, err := parseSinceParam(c.Query("since"), time.Now())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	summary, err := s.database.GetAIUsageSummary(traderID, since)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": fmt.Sprintf("获取AI用量失败: %v", err),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, summary)
+}
+
 // handleDecisions 决策日志列表
 func (s *Server) handleDecisions(c *gin.Context) {
 	_, traderID, err := s.getTraderFromQuery(c)
@@ -1485,6 +2236,17 @@ func (s *Server) handleDecisions(c *gin.Context) {
 		return
 	}
 
+	// rejected=true 时只返回有决策被拒绝的周期（每个动作的 reject_reason 和 rejection 说明拦截原因）
+	if c.Query("rejected") == "true" {
+		filtered := make([]*logger.DecisionRecord, 0, len(records))
+		for _, record := range records {
+			if len(record.Rejected()) > 0 {
+				filtered = append(filtered, record)
+			}
+		}
+		records = filtered
+	}
+
 	c.JSON(http.StatusOK, records)
 }
 
@@ -1527,13 +2289,13 @@ func (s *Server) handleStatistics(c *gin.Context) {
 		return
 	}
 
-	trader, err := s.traderManager.GetTrader(traderID)
+	at, err := s.traderManager.GetTrader(traderID)
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
 		return
 	}
 
-	stats, err := trader.GetDecisionLogger().GetStatistics()
+	stats, err := at.GetDecisionLogger().GetStatistics()
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": fmt.Sprintf("获取统计信息失败: %v", err),
@@ -1541,7 +2303,11 @@ func (s *Server) handleStatistics(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, stats)
+	// 附带成交滑点汇总（平均值和 P95，按动作类型分组）
+	c.JSON(http.StatusOK, struct {
+		*logger.Statistics
+		Slippage trader.SlippageSummary `json:"slippage"`
+	}{stats, at.GetSlippageSummary()})
 }
 
 // handleCompetition 竞赛总览（对比所有trader）
@@ -1599,6 +2365,8 @@ func (s *Server) handleEquityHistory(c *gin.Context) {
 		PositionCount    int     `json:"position_count"`    // 持仓数量
 		MarginUsedPct    float64 `json:"margin_used_pct"`   // 保证金使用率
 		CycleNumber      int     `json:"cycle_number"`
+		MarginAsset      string  `json:"margin_asset,omitempty"`  // 保证金资产（以上金额均按USD折算）
+		NativeEquity     float64 `json:"native_equity,omitempty"` // 净值折合保证金资产的原生数量
 	}
 
 	// 从AutoTrader获取初始余额（用于计算盈亏百分比）
@@ -1645,6 +2413,8 @@ func (s *Server) handleEquityHistory(c *gin.Context) {
 			PositionCount:    record.AccountState.PositionCount,
 			MarginUsedPct:    record.AccountState.MarginUsedPct,
 			CycleNumber:      record.CycleNumber,
+			MarginAsset:      record.AccountState.MarginAsset,
+			NativeEquity:     record.AccountState.NativeEquity,
 		})
 	}
 
@@ -1682,6 +2452,10 @@ func (s *Server) handlePerformance(c *gin.Context) {
 func (s *Server) authMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
+		// 浏览器的 WebSocket 无法设置请求头，升级请求可通过 ?token= 传递token
+		if authHeader == "" && websocket.IsWebSocketUpgrade(c.Request) && c.Query("token") != "" {
+			authHeader = "Bearer " + c.Query("token")
+		}
 		if authHeader == "" {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "缺少Authorization头"})
 			c.Abort()
@@ -1716,6 +2490,7 @@ func (s *Server) authMiddleware() gin.HandlerFunc {
 		// 将用户信息存储到上下文中
 		c.Set("user_id", claims.UserID)
 		c.Set("email", claims.Email)
+		c.Set("scope", claims.EffectiveScope())
 
 		// 异步更新用户最后活跃时间（不阻塞请求）
 		go func(userID string) {
@@ -1726,6 +2501,57 @@ func (s *Server) authMiddleware() gin.HandlerFunc {
 	}
 }
 
+// requireTradeScope 仅允许完整权限（trade）的token访问，只读token返回403
+func (s *Server) requireTradeScope() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetString("scope") != auth.ScopeTrade {
+			c.JSON(http.StatusForbidden, gin.H{"error": "只读token无权执行此操作"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// 只读token有效期（小时）
+const (
+	defaultReadOnlyTokenTTLHours = 24
+	maxReadOnlyTokenTTLHours     = 30 * 24
+)
+
+// handleCreateReadOnlyToken 为当前用户签发只读token（可查看交易员、持仓和统计，不能启停/创建交易员或修改配置）
+func (s *Server) handleCreateReadOnlyToken(c *gin.Context) {
+	var req struct {
+		TTLHours int `json:"ttl_hours"` // 有效期（小时），默认24，最多720
+	}
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if req.TTLHours == 0 {
+		req.TTLHours = defaultReadOnlyTokenTTLHours
+	}
+	if req.TTLHours < 0 || req.TTLHours > maxReadOnlyTokenTTLHours {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("ttl_hours 必须在 1-%d 之间", maxReadOnlyTokenTTLHours)})
+		return
+	}
+
+	ttl := time.Duration(req.TTLHours) * time.Hour
+	token, err := auth.GenerateScopedJWT(c.GetString("user_id"), c.GetString("email"), auth.ScopeRead, ttl)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "生成token失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"token":      token,
+		"scope":      auth.ScopeRead,
+		"expires_at": time.Now().Add(ttl).UnixMilli(),
+	})
+}
+
 // handleLogout 将当前token加入黑名单
 func (s *Server) handleLogout(c *gin.Context) {
 	authHeader := c.GetHeader("Authorization")
@@ -1867,7 +2693,8 @@ func (s *Server) handleRegister(c *gin.Context) {
 	})
 }
 
-// handleCompleteRegistration 完成注册（验证OTP）
+// handleCompleteRegistration 完成注册（确认OTP绑定）：用户提交验证器生成的验证码，与注册时下发的密钥匹配后才标记OTP已验证，
+// 此前登录和OTP登录验证都会被拒绝
 func (s *Server) handleCompleteRegistration(c *gin.Context) {
 	var req struct {
 		UserID  string `json:"user_id" binding:"required"`
@@ -1886,11 +2713,19 @@ func (s *Server) handleCompleteRegistration(c *gin.Context) {
 		return
 	}
 
+	// 已确认过的账户不能重复确认（避免跳过密码直接换取token）
+	if user.OTPVerified {
+		c.JSON(http.StatusConflict, gin.H{"error": "OTP已完成设置，请直接登录"})
+		return
+	}
+
 	// 验证OTP
 	if !auth.VerifyOTP(user.OTPSecret, req.OTPCode) {
+		metrics.RecordUserOTPVerification(false)
 		c.JSON(http.StatusBadRequest, gin.H{"error": "OTP验证码错误"})
 		return
 	}
+	metrics.RecordUserOTPVerification(true)
 
 	// 更新用户OTP验证状态
 	err = s.database.UpdateUserOTPVerified(req.UserID, true)
@@ -1967,7 +2802,7 @@ func (s *Server) handleLogin(c *gin.Context) {
 	})
 }
 
-// handleVerifyOTP 验证OTP并完成登录
+// handleVerifyOTP 验证OTP并完成登录：验证码正确时更新用户活跃时间并签发完整权限的JWT，错误时返回401
 func (s *Server) handleVerifyOTP(c *gin.Context) {
 	var req struct {
 		UserID  string `json:"user_id" binding:"required"`
@@ -1986,10 +2821,21 @@ func (s *Server) handleVerifyOTP(c *gin.Context) {
 		return
 	}
 
+	// 未确认OTP绑定的账户需先完成注册确认
+	if !user.OTPVerified {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error":              "账户未完成OTP设置",
+			"user_id":            user.ID,
+			"requires_otp_setup": true,
+		})
+		return
+	}
+
 	// 验证OTP
 	if !auth.VerifyOTP(user.OTPSecret, req.OTPCode) {
 		metrics.RecordUserOTPVerification(false)
-		c.JSON(http.StatusBadRequest, gin.H{"error": "验证码错误"})
+		metrics.RecordUserLogin("failed")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "验证码错误"})
 		return
 	}
 
@@ -1998,7 +2844,9 @@ func (s *Server) handleVerifyOTP(c *gin.Context) {
 	metrics.RecordUserLogin("success")
 
 	// 更新用户最后活跃时间
-	s.database.UpdateUserLastActive(user.ID)
+	if err := s.database.UpdateUserLastActive(user.ID); err != nil {
+		log.Printf("⚠️  更新用户 %s 最后活跃时间失败: %v", user.ID, err)
+	}
 
 	// 生成JWT token
 	token, err := auth.GenerateJWT(user.ID, user.Email)
@@ -2121,20 +2969,52 @@ func (s *Server) Start() error {
 	log.Printf("  • GET  /api/equity-history-batch?trader_ids=a,b,c - 批量获取历史数据（无需认证，表现对比优化）")
 	log.Printf("  • GET  /api/traders/:id/public-config - 公开的交易员配置（无需认证，不含敏感信息）")
 	log.Printf("  • POST /api/traders          - 创建新的AI交易员")
+	log.Printf("  • POST /api/traders/leverage - 批量更新交易员杠杆（全部或按ID/交易所/AI模型过滤）")
 	log.Printf("  • DELETE /api/traders/:id    - 删除AI交易员")
-	log.Printf("  • POST /api/traders/:id/start - 启动AI交易员")
-	log.Printf("  • POST /api/traders/:id/stop  - 停止AI交易员")
+	log.Printf("  • POST /api/traders/:id/start - 启动AI交易员（已在运行时返回当前状态）")
+	log.Printf("  • POST /api/traders/:id/stop  - 停止AI交易员（已停止时返回当前状态）")
+	log.Printf("  • POST /api/traders/:id/reload - 重新加载加载失败的交易员（修复配置后无需重启）")
+	log.Printf("  • GET  /api/traders/:id      - 交易员运行状态（含下一周期倒计时 next_cycle_at/seconds_remaining）")
+	log.Printf("  • POST /api/traders/:id/run-now?reschedule= - 立即触发一次决策周期（有冷却时间，周期执行中返回409）")
+	log.Printf("  • GET  /api/traders/:id/positions - 指定trader的持仓详情（止损止盈/持仓时长/开仓周期）")
+	log.Printf("  • GET  /api/traders/:id/positions/ws - WebSocket 推送持仓详情（?token= 传递token）")
+	log.Printf("  • POST /api/traders/:id/simulate - 在账户副本上模拟执行决策JSON（返回持仓/保证金/手续费，不影响真实账户）")
+	log.Printf("  • POST /api/backtest - 用交易员的提示词和AI模型回放历史K线（返回净值曲线/已实现盈亏/最大回撤/胜率）")
+	log.Printf("  • POST /api/traders/:id/order-preview - 预览假设订单的保证金、手续费、强平价格及余额是否足够（不下单）")
+	log.Printf("  • GET  /api/traders/:id/symbols/:symbol/history?lookback= - 单个币种的决策和交易时间线")
+	log.Printf("  • GET  /api/traders/:id/anomalies - 交易员行为异常记录")
+	log.Printf("  • POST /api/traders/:id/anomalies/:anomalyId/ack - 确认（忽略）行为异常")
+	log.Printf("  • GET  /api/traders/:id/errors - 交易员最近的错误（AI调用、行情获取、决策执行失败）")
+	log.Printf("  • DELETE /api/traders/:id/errors - 清空交易员的错误记录")
+	log.Printf("  • GET  /api/traders/:id/ai-cost?since= - 指定trader在时间窗口内的AI Token用量和估算成本")
+	log.Printf("  • GET  /api/traders/:id/metrics - 指定trader的交易指标（净值/盈亏/持仓/周期，JSON格式）")
+	log.Printf("  • POST /api/traders/:id/share - 创建只读公开分享链接（可选有效期和可见项）")
+	log.Printf("  • GET  /api/traders/:id/shares - 列出分享链接")
+	log.Printf("  • DELETE /api/traders/:id/shares/:slug - 撤销分享链接（立即生效）")
+	log.Printf("  • GET  /api/public/share/:slug - 公开分享页数据（无需认证，按IP限流）")
+	log.Printf("  • GET  /api/market/:symbol/explain - 币种各指标当前看多/看空/中性的可读解读")
 	log.Printf("  • GET  /api/models           - 获取AI模型配置")
 	log.Printf("  • PUT  /api/models           - 更新AI模型配置")
 	log.Printf("  • GET  /api/exchanges        - 获取交易所配置")
 	log.Printf("  • PUT  /api/exchanges        - 更新交易所配置")
+	log.Printf("  • GET  /api/user/risk-limits - 用户级风控（所有交易员合计的最大同时持仓数）")
+	log.Printf("  • PUT  /api/user/risk-limits - 设置用户级风控（0 表示不限制，开仓时立即生效）")
+	log.Printf("  • GET  /api/user/webhook    - 交易事件 Webhook 配置")
+	log.Printf("  • PUT  /api/user/webhook    - 设置交易事件 Webhook（开仓/平仓/强平/风控事件，HMAC 签名）")
 	log.Printf("  • GET  /api/status?trader_id=xxx     - 指定trader的系统状态")
 	log.Printf("  • GET  /api/account?trader_id=xxx    - 指定trader的账户信息")
 	log.Printf("  • GET  /api/positions?trader_id=xxx  - 指定trader的持仓列表")
-	log.Printf("  • GET  /api/decisions?trader_id=xxx  - 指定trader的决策日志")
+	log.Printf("  • GET  /api/decisions?trader_id=xxx  - 指定trader的决策日志（rejected=true 只看被拒绝的决策及原因）")
 	log.Printf("  • GET  /api/decisions/latest?trader_id=xxx - 指定trader的最新决策")
 	log.Printf("  • GET  /api/statistics?trader_id=xxx - 指定trader的统计信息")
 	log.Printf("  • GET  /api/performance?trader_id=xxx - 指定trader的AI学习表现分析")
+	log.Printf("  • GET  /api/admin/load-report - 交易员加载报告（仅管理员，含加载失败原因）")
+	log.Printf("  • GET  /api/admin/subscriptions - 行情订阅状态（仅管理员，含需要各币种的交易员）")
+	log.Printf("  • GET  /api/admin/ai/latency - AI请求延迟分位数（仅管理员，按 provider/model）")
+	log.Printf("  • GET  /api/admin/kill-switch - 全局交易开关状态（仅管理员）")
+	log.Printf("  • POST /api/admin/kill-switch - 切换全局交易开关（仅管理员，开启时停止所有交易员）")
+	log.Printf("  • POST /api/admin/flatten-all - 一键清仓（仅管理员，暂停系统并平掉所有交易员的持仓）")
+	log.Printf("  • POST /api/admin/backup - 备份数据库（仅管理员）")
 	log.Println()
 
 	// 启动用户统计指标收集器（每分钟更新一次）
@@ -2228,6 +3108,24 @@ func (s *Server) handleGetPromptTemplate(c *gin.Context) {
 	})
 }
 
+// handleValidatePrompt 检查自定义提示词是否包含必需的占位符（如市场数据块），缺少时返回警告
+func (s *Server) handleValidatePrompt(c *gin.Context) {
+	var req struct {
+		Prompt string `json:"prompt"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	warnings := decision.ValidateCustomPrompt(req.Prompt)
+	c.JSON(http.StatusOK, gin.H{
+		"valid":        len(warnings) == 0,
+		"warnings":     warnings,
+		"placeholders": decision.PromptPlaceholders,
+	})
+}
+
 // handlePublicTraderList 获取公开的交易员列表（无需认证）
 func (s *Server) handlePublicTraderList(c *gin.Context) {
 	// 从所有用户获取交易员信息
@@ -8,6 +8,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
@@ -445,3 +446,62 @@ func TestCreateTraderRequest_JSONParsing(t *testing.T) {
 	assert.True(t, req.UseCoinPool)
 	assert.False(t, req.UseOITop)
 }
+
+// ============================================================
+// AI cost endpoint
+// ============================================================
+
+func TestTraderAICost_SumsSeededUsage(t *testing.T) {
+	db := createTestDB(t)
+	defer db.Close()
+
+	// 默认用户下已有 deepseek 模型和 binance 交易所
+	require.NoError(t, db.CreateTrader(&config.TraderRecord{
+		ID: "trader-cost", UserID: "default", Name: "Cost Trader",
+		AIModelID: "deepseek", ExchangeID: "binance", InitialBalance: 1000,
+	}))
+	require.NoError(t, db.RecordAIUsage("trader-cost", "default", "deepseek", "deepseek-chat", 1000, 200, 0.0012))
+	require.NoError(t, db.RecordAIUsage("trader-cost", "default", "deepseek", "deepseek-chat", 3000, 500, 0.0034))
+	require.NoError(t, db.RecordAIUsage("other-trader", "default", "deepseek", "deepseek-chat", 9999, 9999, 9.99))
+
+	s := &Server{database: db}
+	router := setupTestRouter()
+	router.GET("/api/traders/:id/ai-cost", func(c *gin.Context) {
+		c.Set("user_id", "default")
+		s.handleTraderAICost(c)
+	})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/traders/trader-cost/ai-cost?since=1h", nil)
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var summary config.AIUsageSummary
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &summary))
+	assert.Equal(t, 2, summary.Calls)
+	assert.Equal(t, int64(4000), summary.PromptTokens)
+	assert.Equal(t, int64(700), summary.CompletionTokens)
+	assert.Equal(t, int64(4700), summary.TotalTokens)
+	assert.InDelta(t, 0.0046, summary.EstimatedCostUSD, 1e-9)
+
+	// 窗口之后没有用量
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/api/traders/trader-cost/ai-cost?since="+time.Now().Add(time.Hour).UTC().Format(time.RFC3339), nil)
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &summary))
+	assert.Equal(t, 0, summary.Calls)
+	assert.Equal(t, 0.0, summary.EstimatedCostUSD)
+
+	// 非本人交易员
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/api/traders/other-trader/ai-cost", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	// 无效 since
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/api/traders/trader-cost/ai-cost?since=yesterday", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
@@ -0,0 +1,530 @@
+package api
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"aspen/config"
+	"aspen/logger"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// shareSlugBytes 分享链接slug的随机字节数（base64url编码后22个字符，不可猜测）
+	shareSlugBytes = 16
+	// shareMaxExpiresHours 分享链接最长有效期（小时）
+	shareMaxExpiresHours = 24 * 365
+	// sharePayloadTTL 公开分享数据的服务端缓存时长
+	sharePayloadTTL = 60 * time.Second
+	// shareRateLimit 每个IP在 shareRateWindow 内最多访问公开分享接口的次数
+	shareRateLimit  = 30
+	shareRateWindow = time.Minute
+	// shareMaxEquityPoints 净值曲线最多返回的点数（超出时等间隔抽样）
+	shareMaxEquityPoints = 500
+	// shareMaxDecisions 决策摘要最多返回的周期数
+	shareMaxDecisions = 20
+)
+
+// shareRobotsTag 公开分享页禁止搜索引擎收录/存档
+const shareRobotsTag = "noindex, nofollow, noarchive"
+
+// CreateShareLinkRequest 创建分享链接请求（各项可见性可单独开关，未指定时使用默认值）
+type CreateShareLinkRequest struct {
+	ShowEquity     *bool `json:"show_equity"`      // 默认 true
+	ShowStats      *bool `json:"show_stats"`       // 默认 true
+	ShowTrades     *bool `json:"show_trades"`      // 默认 false
+	ShowDecisions  *bool `json:"show_decisions"`   // 默认 false
+	ShowAbsolute   *bool `json:"show_absolute"`    // 默认 false，只显示收益率
+	ExpiresInHours int   `json:"expires_in_hours"` // 0 表示永不过期
+}
+
+// boolOr 返回指针指向的值，为 nil 时返回默认值
+func boolOr(v *bool, def bool) bool {
+	if v == nil {
+		return def
+	}
+	return *v
+}
+
+// generateShareSlug 生成随机不透明的分享slug
+func generateShareSlug() (string, error) {
+	buf := make([]byte, shareSlugBytes)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("生成分享链接失败: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// PublicSharePayload 公开分享页数据
+// 只允许出现在此结构中的字段对外公开；不包含用户ID、邮箱、交易员ID、API配置、提示词和仓位大小
+type PublicSharePayload struct {
+	TraderName  string              `json:"trader_name"`
+	AIModel     string              `json:"ai_model"`
+	Exchange    string              `json:"exchange"`
+	ReturnPct   float64             `json:"return_pct"`             // 总收益率
+	Equity      *float64            `json:"equity,omitempty"`       // 当前净值（仅允许显示绝对金额时）
+	EquityCurve []PublicEquityPoint `json:"equity_curve,omitempty"` // 净值曲线（收益率）
+	Stats       *PublicShareStats   `json:"stats,omitempty"`
+	Trades      []PublicTrade       `json:"trades,omitempty"`
+	Decisions   []PublicDecision    `json:"decisions,omitempty"`
+	GeneratedAt time.Time           `json:"generated_at"`
+	ExpiresAt   *time.Time          `json:"expires_at,omitempty"`
+}
+
+// PublicEquityPoint 净值曲线数据点
+type PublicEquityPoint struct {
+	Timestamp time.Time `json:"timestamp"`
+	ReturnPct float64   `json:"return_pct"`
+	Equity    *float64  `json:"equity,omitempty"` // 仅允许显示绝对金额时
+}
+
+// PublicShareStats 交易统计
+type PublicShareStats struct {
+	TotalTrades   int      `json:"total_trades"`
+	WinningTrades int      `json:"winning_trades"`
+	LosingTrades  int      `json:"losing_trades"`
+	WinRate       float64  `json:"win_rate"`
+	ProfitFactor  float64  `json:"profit_factor"`
+	SharpeRatio   float64  `json:"sharpe_ratio"`
+	AvgWin        *float64 `json:"avg_win,omitempty"`  // 仅允许显示绝对金额时
+	AvgLoss       *float64 `json:"avg_loss,omitempty"` // 仅允许显示绝对金额时
+}
+
+// PublicTrade 已平仓交易（不含数量、仓位价值、保证金和杠杆）
+type PublicTrade struct {
+	Symbol      string    `json:"symbol"`
+	Side        string    `json:"side"`
+	OpenPrice   float64   `json:"open_price"`
+	ClosePrice  float64   `json:"close_price"`
+	PnLPct      float64   `json:"pnl_pct"`
+	PnL         *float64  `json:"pnl,omitempty"` // 仅允许显示绝对金额时
+	Duration    string    `json:"duration"`
+	OpenTime    time.Time `json:"open_time"`
+	CloseTime   time.Time `json:"close_time"`
+	WasStopLoss bool      `json:"was_stop_loss"`
+}
+
+// PublicDecision 决策摘要（不含提示词、思维链和执行细节）
+type PublicDecision struct {
+	Timestamp   time.Time              `json:"timestamp"`
+	CycleNumber int                    `json:"cycle_number"`
+	Actions     []PublicDecisionAction `json:"actions"`
+}
+
+// PublicDecisionAction 决策动作摘要
+type PublicDecisionAction struct {
+	Action  string `json:"action"`
+	Symbol  string `json:"symbol"`
+	Success bool   `json:"success"`
+	Note    string `json:"note,omitempty"`
+}
+
+// shareSource 生成公开分享数据所需的原始数据
+type shareSource struct {
+	TraderName     string
+	AIModel        string
+	Exchange       string
+	InitialBalance float64
+	Records        []*logger.DecisionRecord // 从旧到新
+	Performance    *logger.PerformanceAnalysis
+}
+
+// floatPtr 返回浮点数指针
+func floatPtr(v float64) *float64 {
+	return &v
+}
+
+// buildPublicSharePayload 按分享链接的可见性设置序列化公开数据
+// 这是公开分享页唯一的序列化入口，未开启的项不会出现在结果中
+func buildPublicSharePayload(link *config.ShareLink, src *shareSource, now time.Time) *PublicSharePayload {
+	payload := &PublicSharePayload{
+		TraderName:  src.TraderName,
+		AIModel:     src.AIModel,
+		Exchange:    src.Exchange,
+		GeneratedAt: now.UTC(),
+		ExpiresAt:   link.ExpiresAt,
+	}
+
+	initialBalance := src.InitialBalance
+	if initialBalance <= 0 && len(src.Records) > 0 {
+		initialBalance = src.Records[0].AccountState.TotalBalance
+	}
+	returnPct := func(record *logger.DecisionRecord) float64 {
+		if initialBalance <= 0 {
+			return 0
+		}
+		// TotalUnrealizedProfit字段实际存储的是TotalPnL（相对初始余额）
+		return record.AccountState.TotalUnrealizedProfit / initialBalance * 100
+	}
+
+	if len(src.Records) > 0 {
+		latest := src.Records[len(src.Records)-1]
+		payload.ReturnPct = returnPct(latest)
+		if link.ShowAbsolute {
+			payload.Equity = floatPtr(latest.AccountState.TotalBalance)
+		}
+	}
+
+	if link.ShowEquity {
+		step := 1
+		if len(src.Records) > shareMaxEquityPoints {
+			step = (len(src.Records) + shareMaxEquityPoints - 1) / shareMaxEquityPoints
+		}
+		for i := 0; i < len(src.Records); i += step {
+			record := src.Records[i]
+			point := PublicEquityPoint{Timestamp: record.Timestamp.UTC(), ReturnPct: returnPct(record)}
+			if link.ShowAbsolute {
+				point.Equity = floatPtr(record.AccountState.TotalBalance)
+			}
+			payload.EquityCurve = append(payload.EquityCurve, point)
+		}
+	}
+
+	if link.ShowStats && src.Performance != nil {
+		perf := src.Performance
+		stats := &PublicShareStats{
+			TotalTrades:   perf.TotalTrades,
+			WinningTrades: perf.WinningTrades,
+			LosingTrades:  perf.LosingTrades,
+			WinRate:       perf.WinRate,
+			ProfitFactor:  perf.ProfitFactor,
+			SharpeRatio:   perf.SharpeRatio,
+		}
+		if link.ShowAbsolute {
+			stats.AvgWin = floatPtr(perf.AvgWin)
+			stats.AvgLoss = floatPtr(perf.AvgLoss)
+		}
+		payload.Stats = stats
+	}
+
+	if link.ShowTrades && src.Performance != nil {
+		for _, trade := range src.Performance.RecentTrades {
+			pub := PublicTrade{
+				Symbol:      trade.Symbol,
+				Side:        trade.Side,
+				OpenPrice:   trade.OpenPrice,
+				ClosePrice:  trade.ClosePrice,
+				PnLPct:      trade.PnLPct,
+				Duration:    trade.Duration,
+				OpenTime:    trade.OpenTime.UTC(),
+				CloseTime:   trade.CloseTime.UTC(),
+				WasStopLoss: trade.WasStopLoss,
+			}
+			if link.ShowAbsolute {
+				pub.PnL = floatPtr(trade.PnL)
+			}
+			payload.Trades = append(payload.Trades, pub)
+		}
+	}
+
+	if link.ShowDecisions {
+		start := len(src.Records) - shareMaxDecisions
+		if start < 0 {
+			start = 0
+		}
+		// 从新到旧
+		for i := len(src.Records) - 1; i >= start; i-- {
+			record := src.Records[i]
+			decision := PublicDecision{
+				Timestamp:   record.Timestamp.UTC(),
+				CycleNumber: record.CycleNumber,
+				Actions:     []PublicDecisionAction{},
+			}
+			for _, action := range record.Decisions {
+				decision.Actions = append(decision.Actions, PublicDecisionAction{
+					Action:  action.Action,
+					Symbol:  action.Symbol,
+					Success: action.Success,
+					Note:    action.Note,
+				})
+			}
+			payload.Decisions = append(payload.Decisions, decision)
+		}
+	}
+
+	return payload
+}
+
+// shareCacheEntry 公开分享数据缓存项
+type shareCacheEntry struct {
+	body      []byte
+	etag      string
+	expiresAt time.Time
+}
+
+// shareCache 公开分享数据的服务端缓存（按slug）
+type shareCache struct {
+	mu      sync.Mutex
+	entries map[string]shareCacheEntry
+}
+
+func (c *shareCache) get(slug string, now time.Time) (shareCacheEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[slug]
+	if !ok || now.After(entry.expiresAt) {
+		delete(c.entries, slug)
+		return shareCacheEntry{}, false
+	}
+	return entry, true
+}
+
+func (c *shareCache) set(slug string, entry shareCacheEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[slug] = entry
+}
+
+func (c *shareCache) invalidate(slug string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, slug)
+}
+
+// ipRateLimiter 固定窗口的按IP限流器（防止批量抓取公开分享页）
+type ipRateLimiter struct {
+	mu      sync.Mutex
+	limit   int
+	window  time.Duration
+	windows map[string]*rateWindow
+}
+
+type rateWindow struct {
+	start time.Time
+	count int
+}
+
+// Allow 判断该IP本次请求是否放行
+func (l *ipRateLimiter) Allow(ip string, now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	w, ok := l.windows[ip]
+	if !ok || now.Sub(w.start) >= l.window {
+		// 顺便清理过期窗口，避免内存无限增长
+		for key, old := range l.windows {
+			if now.Sub(old.start) >= l.window {
+				delete(l.windows, key)
+			}
+		}
+		l.windows[ip] = &rateWindow{start: now, count: 1}
+		return true
+	}
+	if w.count >= l.limit {
+		return false
+	}
+	w.count++
+	return true
+}
+
+var (
+	publicShareCache   = &shareCache{entries: make(map[string]shareCacheEntry)}
+	publicShareLimiter = &ipRateLimiter{limit: shareRateLimit, window: shareRateWindow, windows: make(map[string]*rateWindow)}
+)
+
+// handleCreateShareLink 创建交易员公开分享链接
+func (s *Server) handleCreateShareLink(c *gin.Context) {
+	userID := c.GetString("user_id")
+	traderID := c.Param("id")
+
+	if _, _, _, err := s.database.GetTraderConfig(userID, traderID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "交易员不存在或无访问权限"})
+		return
+	}
+
+	var req CreateShareLinkRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if req.ExpiresInHours < 0 || req.ExpiresInHours > shareMaxExpiresHours {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("有效期必须在 0-%d 小时之间（0 表示永不过期）", shareMaxExpiresHours)})
+		return
+	}
+
+	slug, err := generateShareSlug()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	now := time.Now().UTC()
+	link := &config.ShareLink{
+		Slug:          slug,
+		TraderID:      traderID,
+		UserID:        userID,
+		ShowEquity:    boolOr(req.ShowEquity, true),
+		ShowStats:     boolOr(req.ShowStats, true),
+		ShowTrades:    boolOr(req.ShowTrades, false),
+		ShowDecisions: boolOr(req.ShowDecisions, false),
+		ShowAbsolute:  boolOr(req.ShowAbsolute, false),
+		CreatedAt:     now,
+	}
+	if req.ExpiresInHours > 0 {
+		expiresAt := now.Add(time.Duration(req.ExpiresInHours) * time.Hour)
+		link.ExpiresAt = &expiresAt
+	}
+
+	if err := s.database.CreateShareLink(link); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("创建分享链接失败: %v", err)})
+		return
+	}
+
+	log.Printf("🔗 用户 %s 为交易员 %s 创建了公开分享链接", userID, traderID)
+	c.JSON(http.StatusCreated, gin.H{
+		"link": link,
+		"path": "/api/public/share/" + slug,
+	})
+}
+
+// handleListShareLinks 列出交易员的分享链接（包含已撤销/过期的链接）
+func (s *Server) handleListShareLinks(c *gin.Context) {
+	userID := c.GetString("user_id")
+	traderID := c.Param("id")
+
+	if _, _, _, err := s.database.GetTraderConfig(userID, traderID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "交易员不存在或无访问权限"})
+		return
+	}
+
+	links, err := s.database.ListShareLinks(userID, traderID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("获取分享链接失败: %v", err)})
+		return
+	}
+
+	now := time.Now()
+	result := make([]gin.H, 0, len(links))
+	for _, link := range links {
+		result = append(result, gin.H{
+			"link":   link,
+			"path":   "/api/public/share/" + link.Slug,
+			"active": link.Active(now),
+		})
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// handleRevokeShareLink 撤销分享链接（立即生效）
+func (s *Server) handleRevokeShareLink(c *gin.Context) {
+	userID := c.GetString("user_id")
+	traderID := c.Param("id")
+	slug := c.Param("slug")
+
+	if err := s.database.RevokeShareLink(userID, traderID, slug); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "分享链接不存在或已撤销"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("撤销分享链接失败: %v", err)})
+		return
+	}
+	publicShareCache.invalidate(slug)
+
+	log.Printf("🔗 用户 %s 撤销了交易员 %s 的分享链接", userID, traderID)
+	c.JSON(http.StatusOK, gin.H{"message": "分享链接已撤销"})
+}
+
+// loadShareSource 从运行中的交易员加载公开分享所需数据
+func (s *Server) loadShareSource(traderID string) (*shareSource, error) {
+	trader, err := s.traderManager.GetTrader(traderID)
+	if err != nil {
+		return nil, err
+	}
+
+	records, err := trader.GetDecisionLogger().GetLatestRecords(10000)
+	if err != nil {
+		return nil, fmt.Errorf("获取历史数据失败: %w", err)
+	}
+	performance, err := trader.GetDecisionLogger().AnalyzePerformance(100)
+	if err != nil {
+		return nil, fmt.Errorf("分析历史表现失败: %w", err)
+	}
+
+	initialBalance := 0.0
+	if status := trader.GetStatus(); status != nil {
+		if ib, ok := status["initial_balance"].(float64); ok && ib > 0 {
+			initialBalance = ib
+		}
+	}
+
+	return &shareSource{
+		TraderName:     trader.GetName(),
+		AIModel:        trader.GetAIModel(),
+		Exchange:       trader.GetExchange(),
+		InitialBalance: initialBalance,
+		Records:        records,
+		Performance:    performance,
+	}, nil
+}
+
+// handlePublicShare 公开分享页数据（无需认证）
+// 每次请求都会检查链接状态（撤销立即生效），数据在服务端缓存 sharePayloadTTL 并通过ETag支持条件请求
+func (s *Server) handlePublicShare(c *gin.Context) {
+	c.Header("X-Robots-Tag", shareRobotsTag)
+
+	now := time.Now()
+	if !publicShareLimiter.Allow(c.ClientIP(), now) {
+		c.Header("Retry-After", fmt.Sprintf("%d", int(shareRateWindow.Seconds())))
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "请求过于频繁，请稍后再试"})
+		return
+	}
+
+	slug := c.Param("slug")
+	link, err := s.database.GetShareLink(slug)
+	if err != nil || !link.Active(now) {
+		// 不区分不存在/已撤销/已过期，避免泄露链接状态
+		publicShareCache.invalidate(slug)
+		c.JSON(http.StatusNotFound, gin.H{"error": "分享链接不存在或已失效"})
+		return
+	}
+
+	entry, ok := publicShareCache.get(slug, now)
+	if !ok {
+		src, err := s.loadShareSource(link.TraderID)
+		if err != nil {
+			log.Printf("⚠️  加载分享数据失败 (trader: %s): %v", link.TraderID, err)
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "交易员数据暂不可用"})
+			return
+		}
+		body, err := json.Marshal(buildPublicSharePayload(link, src, now))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "序列化分享数据失败"})
+			return
+		}
+		sum := sha256.Sum256(body)
+		entry = shareCacheEntry{
+			body:      body,
+			etag:      `"` + hex.EncodeToString(sum[:8]) + `"`,
+			expiresAt: now.Add(sharePayloadTTL),
+		}
+		publicShareCache.set(slug, entry)
+	}
+
+	// 允许浏览器/CDN缓存，但必须重新验证，保证撤销后立即失效
+	c.Header("Cache-Control", "public, no-cache")
+	c.Header("ETag", entry.etag)
+	if c.GetHeader("If-None-Match") == entry.etag {
+		c.Status(http.StatusNotModified)
+		return
+	}
+	c.Data(http.StatusOK, "application/json; charset=utf-8", entry.body)
+}
+
+// handleRobots 禁止搜索引擎抓取公开分享页
+func (s *Server) handleRobots(c *gin.Context) {
+	c.String(http.StatusOK, "User-agent: *\nDisallow: /api/public/\n")
+}
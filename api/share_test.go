@@ -0,0 +1,173 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"aspen/config"
+	"aspen/logger"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newShareTestSource 构造包含敏感字段的分享原始数据
+func newShareTestSource() *shareSource {
+	base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	return &shareSource{
+		TraderName:     "Alpha",
+		AIModel:        "deepseek",
+		Exchange:       "binance",
+		InitialBalance: 1000,
+		Records: []*logger.DecisionRecord{
+			{
+				Timestamp:    base,
+				CycleNumber:  1,
+				SystemPrompt: "SECRET_SYSTEM_PROMPT",
+				InputPrompt:  "SECRET_INPUT_PROMPT",
+				CoTTrace:     "SECRET_COT",
+				AccountState: logger.AccountSnapshot{TotalBalance: 1000, TotalUnrealizedProfit: 0},
+			},
+			{
+				Timestamp:   base.Add(3 * time.Minute),
+				CycleNumber: 2,
+				CoTTrace:    "SECRET_COT",
+				AccountState: logger.AccountSnapshot{
+					TotalBalance:          1234.5,
+					TotalUnrealizedProfit: 234.5,
+				},
+				Decisions: []logger.DecisionAction{
+					{Action: "open_long", Symbol: "BTCUSDT", Quantity: 0.777, Leverage: 9, Price: 50000, OrderID: 987654, Success: true, Note: "突破"},
+				},
+			},
+		},
+		Performance: &logger.PerformanceAnalysis{
+			TotalTrades:   1,
+			WinningTrades: 1,
+			WinRate:       100,
+			AvgWin:        88.8,
+			ProfitFactor:  2,
+			SharpeRatio:   1.5,
+			RecentTrades: []logger.TradeOutcome{
+				{
+					Symbol: "ETHUSDT", Side: "long", Quantity: 3.333, Leverage: 7,
+					OpenPrice: 3000, ClosePrice: 3100, PositionValue: 9999, MarginUsed: 1428,
+					PnL: 333.3, PnLPct: 23.3, Duration: "1h",
+				},
+			},
+		},
+	}
+}
+
+// TestBuildPublicSharePayload_MaskedFieldsNeverLeak 测试所有可见性组合下未开启的字段不会出现在序列化结果中
+func TestBuildPublicSharePayload_MaskedFieldsNeverLeak(t *testing.T) {
+	src := newShareTestSource()
+	now := time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC)
+
+	// 任何组合下都不允许出现的内容
+	alwaysHidden := []string{
+		"SECRET_SYSTEM_PROMPT", "SECRET_INPUT_PROMPT", "SECRET_COT",
+		"0.777", "3.333", "9999", "1428", "987654",
+		"user_id", "trader_id", "email", "quantity", "margin", "leverage", "position_value",
+	}
+
+	for mask := 0; mask < 32; mask++ {
+		link := &config.ShareLink{
+			Slug:          "slug",
+			TraderID:      "trader-secret-id",
+			UserID:        "user-secret-id",
+			ShowEquity:    mask&1 != 0,
+			ShowStats:     mask&2 != 0,
+			ShowTrades:    mask&4 != 0,
+			ShowDecisions: mask&8 != 0,
+			ShowAbsolute:  mask&16 != 0,
+		}
+		body, err := json.Marshal(buildPublicSharePayload(link, src, now))
+		require.NoError(t, err)
+		out := string(body)
+
+		for _, secret := range append(alwaysHidden, "trader-secret-id", "user-secret-id") {
+			assert.NotContains(t, out, secret, "mask=%05b", mask)
+		}
+
+		assert.Equal(t, link.ShowEquity, strings.Contains(out, `"equity_curve"`), "mask=%05b", mask)
+		assert.Equal(t, link.ShowStats, strings.Contains(out, `"stats"`), "mask=%05b", mask)
+		assert.Equal(t, link.ShowTrades, strings.Contains(out, `"trades"`), "mask=%05b", mask)
+		assert.Equal(t, link.ShowDecisions, strings.Contains(out, `"decisions"`), "mask=%05b", mask)
+
+		// 绝对金额只在显式开启时出现
+		for _, absolute := range []string{"1234.5", "234.5", "88.8", "333.3", `"pnl"`, `"avg_win"`, `"equity"`} {
+			if !link.ShowAbsolute {
+				assert.NotContains(t, out, absolute, "mask=%05b", mask)
+			}
+		}
+		if link.ShowAbsolute {
+			assert.Contains(t, out, "1234.5", "mask=%05b", mask)
+		}
+
+		// 收益率始终可见
+		assert.Contains(t, out, `"return_pct":23.45`, "mask=%05b", mask)
+	}
+}
+
+// TestBuildPublicSharePayload_Content 测试公开字段内容
+func TestBuildPublicSharePayload_Content(t *testing.T) {
+	link := &config.ShareLink{ShowEquity: true, ShowStats: true, ShowTrades: true, ShowDecisions: true}
+	payload := buildPublicSharePayload(link, newShareTestSource(), time.Now())
+
+	require.Len(t, payload.EquityCurve, 2)
+	assert.InDelta(t, 23.45, payload.EquityCurve[1].ReturnPct, 1e-9)
+	require.Len(t, payload.Trades, 1)
+	assert.Equal(t, "ETHUSDT", payload.Trades[0].Symbol)
+	assert.InDelta(t, 23.3, payload.Trades[0].PnLPct, 1e-9)
+	require.Len(t, payload.Decisions, 2)
+	assert.Equal(t, 2, payload.Decisions[0].CycleNumber, "决策摘要应从新到旧")
+	assert.Equal(t, []PublicDecisionAction{{Action: "open_long", Symbol: "BTCUSDT", Success: true, Note: "突破"}}, payload.Decisions[0].Actions)
+}
+
+// TestPublicShare_RevokedAndExpiredReturn404 测试撤销和过期的链接立即不可访问
+func TestPublicShare_RevokedAndExpiredReturn404(t *testing.T) {
+	db := createTestDB(t)
+	defer db.Close()
+	s := &Server{database: db}
+
+	expired := time.Now().Add(-time.Hour)
+	require.NoError(t, db.CreateShareLink(&config.ShareLink{Slug: "expired", TraderID: "t1", UserID: "u1", ExpiresAt: &expired}))
+	require.NoError(t, db.CreateShareLink(&config.ShareLink{Slug: "revoked", TraderID: "t1", UserID: "u1"}))
+	require.NoError(t, db.RevokeShareLink("u1", "t1", "revoked"))
+	assert.Error(t, db.RevokeShareLink("u1", "t1", "revoked"), "重复撤销应返回错误")
+
+	router := setupTestRouter()
+	router.GET("/api/public/share/:slug", s.handlePublicShare)
+
+	for _, slug := range []string{"expired", "revoked", "missing"} {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/api/public/share/"+slug, nil)
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusNotFound, w.Code, slug)
+		assert.Equal(t, shareRobotsTag, w.Header().Get("X-Robots-Tag"), slug)
+	}
+
+	links, err := db.ListShareLinks("u1", "t1")
+	require.NoError(t, err)
+	require.Len(t, links, 2)
+	for _, link := range links {
+		assert.False(t, link.Active(time.Now()), link.Slug)
+	}
+}
+
+// TestIPRateLimiter 测试按IP固定窗口限流
+func TestIPRateLimiter(t *testing.T) {
+	limiter := &ipRateLimiter{limit: 2, window: time.Minute, windows: make(map[string]*rateWindow)}
+	now := time.Now()
+
+	assert.True(t, limiter.Allow("1.1.1.1", now))
+	assert.True(t, limiter.Allow("1.1.1.1", now))
+	assert.False(t, limiter.Allow("1.1.1.1", now))
+	assert.True(t, limiter.Allow("2.2.2.2", now), "不同IP独立计数")
+	assert.True(t, limiter.Allow("1.1.1.1", now.Add(time.Minute)), "新窗口重新计数")
+}
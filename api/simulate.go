@@ -0,0 +1,93 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"aspen/decision"
+
+	"github.com/gin-gonic/gin"
+)
+
+// handleSimulateDecision 在交易员当前账户状态的副本上模拟执行决策，返回模拟后的持仓、保证金和手续费，不影响真实账户
+// 请求体为决策数组，或 {"decisions": [...]}，或单个决策对象
+func (s *Server) handleSimulateDecision(c *gin.Context) {
+	userID := c.GetString("user_id")
+	traderID := c.Param("id")
+
+	// 校验交易员归属
+	if _, _, _, err := s.database.GetTraderConfig(userID, traderID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "交易员不存在或无访问权限"})
+		return
+	}
+
+	at, err := s.traderManager.GetTrader(traderID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	decisions, err := parseSimulationDecisions(body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	result, err := at.SimulateDecisions(decisions)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("模拟决策失败: %v", err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"trader_id":  traderID,
+		"simulation": result,
+		"timestamp":  time.Now().UnixMilli(),
+	})
+}
+
+// parseSimulationDecisions 解析模拟请求中的决策列表
+func parseSimulationDecisions(body []byte) ([]decision.Decision, error) {
+	body = bytes.TrimSpace(body)
+	if len(body) == 0 {
+		return nil, fmt.Errorf("请求体不能为空")
+	}
+
+	var decisions []decision.Decision
+	if body[0] == '[' {
+		if err := json.Unmarshal(body, &decisions); err != nil {
+			return nil, fmt.Errorf("决策JSON格式错误: %w", err)
+		}
+	} else {
+		var req struct {
+			Decisions []decision.Decision `json:"decisions"`
+			decision.Decision
+		}
+		if err := json.Unmarshal(body, &req); err != nil {
+			return nil, fmt.Errorf("决策JSON格式错误: %w", err)
+		}
+		decisions = req.Decisions
+		if len(decisions) == 0 && req.Action != "" {
+			decisions = []decision.Decision{req.Decision}
+		}
+	}
+
+	if len(decisions) == 0 {
+		return nil, fmt.Errorf("至少需要一个决策")
+	}
+	for i, d := range decisions {
+		if d.Symbol == "" || d.Action == "" {
+			return nil, fmt.Errorf("决策 #%d 缺少 symbol 或 action", i+1)
+		}
+	}
+	return decisions, nil
+}
@@ -0,0 +1,107 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"aspen/config"
+	"aspen/manager"
+	"aspen/market"
+	"aspen/trader"
+
+	"github.com/agiledragon/gomonkey/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestSimulateDecision_OpenDoesNotTouchLiveTrader 测试模拟开仓返回预期的持仓、保证金和手续费，真实模拟仓交易员的余额和持仓不变
+func TestSimulateDecision_OpenDoesNotTouchLiveTrader(t *testing.T) {
+	t.Chdir(t.TempDir()) // 交易员加载时会创建决策日志目录
+	patches := gomonkey.ApplyFunc(market.GetCachedPrice, func(symbol string) (float64, bool) {
+		return 50000, true
+	})
+	defer patches.Reset()
+
+	db := createTestDB(t)
+	defer db.Close()
+	require.NoError(t, db.CreateUser(&config.User{ID: "default", Email: "default@localhost", OTPVerified: true}))
+	require.NoError(t, db.UpdateAIModel("default", "deepseek", true, "sk-test", "", ""))
+	require.NoError(t, db.UpdateExchange("default", "paper", true, "", "", false, "", "", "", "", 1000))
+	require.NoError(t, db.CreateTrader(&config.TraderRecord{
+		ID: "t-paper", UserID: "default", Name: "Paper", AIModelID: "deepseek", ExchangeID: "paper",
+		InitialBalance: 1000, BTCETHLeverage: 5, AltcoinLeverage: 5,
+	}))
+
+	tm := manager.NewTraderManager()
+	require.NoError(t, tm.LoadTradersFromDatabase(db))
+	live, err := tm.GetTrader("t-paper")
+	require.NoError(t, err)
+	before, err := live.GetAccountInfo()
+	require.NoError(t, err)
+
+	s := &Server{database: db, traderManager: tm}
+	router := setupTestRouter()
+	router.POST("/api/traders/:id/simulate", func(c *gin.Context) {
+		c.Set("user_id", "default")
+		s.handleSimulateDecision(c)
+	})
+	simulate := func(traderID, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/api/traders/"+traderID+"/simulate", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := simulate("t-paper", `{"decisions":[{"symbol":"BTCUSDT","action":"open_long","leverage":5,"position_size_usd":500,"stop_loss":49000,"take_profit":60000,"reasoning":"test"}]}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var resp struct {
+		Simulation trader.DecisionSimulation `json:"simulation"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	sim := resp.Simulation
+	assert.Equal(t, trader.SimulationSourcePaper, sim.Source)
+
+	require.Len(t, sim.Actions, 1)
+	action := sim.Actions[0]
+	require.Equal(t, "executed", action.Status, action.Error)
+	assert.Equal(t, "long", action.Side)
+	assert.InDelta(t, 50000, action.Price, 1e-9)
+	assert.InDelta(t, 0.01, action.Quantity, 1e-9)
+	assert.Equal(t, 5, action.Leverage)
+	assert.InDelta(t, 100, action.Margin, 1e-9) // 500 / 5
+	assert.InDelta(t, 0.2, action.Fee, 1e-9)    // 500 * 0.04%
+	assert.InDelta(t, 0.2, sim.TotalFees, 1e-9)
+
+	require.Len(t, sim.Positions, 1)
+	pos := sim.Positions[0]
+	assert.Equal(t, "BTCUSDT", pos["symbol"])
+	assert.Equal(t, "long", pos["side"])
+	assert.InDelta(t, 0.01, pos["positionAmt"], 1e-9)
+	assert.InDelta(t, 50000, pos["entryPrice"], 1e-9)
+	assert.InDelta(t, 899.8, sim.Balance["availableBalance"], 1e-6) // 1000 - 100 保证金 - 0.2 手续费
+
+	// 真实交易员的持仓和余额不变
+	positions, err := live.GetPositions()
+	require.NoError(t, err)
+	assert.Empty(t, positions)
+	after, err := live.GetAccountInfo()
+	require.NoError(t, err)
+	assert.Equal(t, before["total_equity"], after["total_equity"])
+	assert.Equal(t, before["available_balance"], after["available_balance"])
+
+	// 不符合风控规则的决策被拒绝而不是执行
+	w = simulate("t-paper", `[{"symbol":"BTCUSDT","action":"open_long","leverage":5,"position_size_usd":500,"stop_loss":60000,"take_profit":49000}]`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	require.Len(t, resp.Simulation.Actions, 1)
+	assert.Equal(t, "rejected", resp.Simulation.Actions[0].Status)
+	assert.Empty(t, resp.Simulation.Positions)
+
+	assert.Equal(t, http.StatusBadRequest, simulate("t-paper", `{"decisions":[]}`).Code)
+	assert.Equal(t, http.StatusNotFound, simulate("someone-else", `{"symbol":"BTCUSDT","action":"hold"}`).Code)
+}
@@ -0,0 +1,22 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// handleSubscriptions 行情订阅状态：已订阅的币种及需要它们的交易员（仅管理员，包含所有用户的交易员）
+func (s *Server) handleSubscriptions(c *gin.Context) {
+	if c.GetString("user_id") != adminUserID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "仅管理员可查看行情订阅状态"})
+		return
+	}
+
+	status, ok := s.traderManager.SubscriptionStatus()
+	if !ok {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "行情订阅对账未启动"})
+		return
+	}
+	c.JSON(http.StatusOK, status)
+}
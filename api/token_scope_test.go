@@ -0,0 +1,86 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"aspen/auth"
+	"aspen/config"
+	"aspen/manager"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestReadScopeToken 测试只读token可以查看持仓，但不能启停交易员、修改配置或再签发token
+func TestReadScopeToken(t *testing.T) {
+	t.Chdir(t.TempDir()) // 交易员加载时会创建决策日志目录
+	db := createTestDB(t)
+	defer db.Close()
+
+	require.NoError(t, db.CreateUser(&config.User{ID: "default", Email: "default@localhost", OTPVerified: true}))
+	require.NoError(t, db.UpdateAIModel("default", "deepseek", true, "sk-test", "", ""))
+	require.NoError(t, db.UpdateExchange("default", "paper", true, "", "", false, "", "", "", "", 1000))
+	require.NoError(t, db.CreateTrader(&config.TraderRecord{ID: "t1", UserID: "default", Name: "Paper", AIModelID: "deepseek", ExchangeID: "paper", InitialBalance: 1000}))
+
+	tm := manager.NewTraderManager()
+	require.NoError(t, tm.LoadTradersFromDatabase(db))
+
+	s := &Server{router: gin.New(), database: db, traderManager: tm}
+	s.setupRoutes()
+
+	do := func(method, path, token string, body []byte) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, bytes.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		if body != nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		s.router.ServeHTTP(w, req)
+		return w
+	}
+
+	readToken, err := auth.GenerateScopedJWT("default", "default@localhost", auth.ScopeRead, time.Hour)
+	require.NoError(t, err)
+
+	w := do(http.MethodGet, "/api/traders/t1/positions", readToken, nil)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	w = do(http.MethodPost, "/api/traders/t1/start", readToken, nil)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	w = do(http.MethodPost, "/api/traders", readToken, []byte(`{"name":"x"}`))
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	w = do(http.MethodGet, "/api/exchanges", readToken, nil)
+	assert.Equal(t, http.StatusForbidden, w.Code, "只读token不能查看交易所凭证配置")
+
+	w = do(http.MethodPost, "/api/tokens/read-only", readToken, nil)
+	assert.Equal(t, http.StatusForbidden, w.Code, "只读token不能签发新token")
+
+	// 完整权限token可以签发只读token，且通过范围检查
+	tradeToken := generateValidToken(t, "default", "default@localhost")
+	w = do(http.MethodPost, "/api/traders/t1/stop", tradeToken, nil)
+	assert.NotEqual(t, http.StatusForbidden, w.Code)
+
+	w = do(http.MethodPost, "/api/tokens/read-only", tradeToken, []byte(`{"ttl_hours":48}`))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var resp struct {
+		Token string `json:"token"`
+		Scope string `json:"scope"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, auth.ScopeRead, resp.Scope)
+	claims, err := auth.ValidateJWT(resp.Token)
+	require.NoError(t, err)
+	assert.Equal(t, auth.ScopeRead, claims.EffectiveScope())
+	assert.InDelta(t, (48 * time.Hour).Seconds(), time.Until(claims.ExpiresAt.Time).Seconds(), 10)
+
+	w = do(http.MethodPost, "/api/tokens/read-only", tradeToken, []byte(`{"ttl_hours":10000}`))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
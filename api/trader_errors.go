@@ -0,0 +1,55 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// handleTraderErrors 获取交易员最近的错误（AI调用、行情获取、决策执行失败，从新到旧）
+func (s *Server) handleTraderErrors(c *gin.Context) {
+	userID := c.GetString("user_id")
+	traderID := c.Param("id")
+
+	// 校验交易员归属
+	if _, _, _, err := s.database.GetTraderConfig(userID, traderID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "交易员不存在或无访问权限"})
+		return
+	}
+
+	at, err := s.traderManager.GetTrader(traderID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	errs := at.GetRecentErrors()
+	c.JSON(http.StatusOK, gin.H{
+		"trader_id": traderID,
+		"count":     len(errs),
+		"errors":    errs,
+	})
+}
+
+// handleClearTraderErrors 清空交易员的错误记录
+func (s *Server) handleClearTraderErrors(c *gin.Context) {
+	userID := c.GetString("user_id")
+	traderID := c.Param("id")
+
+	// 校验交易员归属
+	if _, _, _, err := s.database.GetTraderConfig(userID, traderID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "交易员不存在或无访问权限"})
+		return
+	}
+
+	at, err := s.traderManager.GetTrader(traderID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"trader_id": traderID,
+		"cleared":   at.ClearErrors(),
+	})
+}
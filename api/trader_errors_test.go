@@ -0,0 +1,83 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"aspen/config"
+	"aspen/manager"
+	"aspen/trader"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestTraderErrors_ListAndClear 测试获取交易员最近的错误（从新到旧）和清空错误记录，只能访问自己的交易员
+func TestTraderErrors_ListAndClear(t *testing.T) {
+	t.Chdir(t.TempDir()) // 交易员加载时会创建决策日志目录
+
+	db := createTestDB(t)
+	defer db.Close()
+	require.NoError(t, db.CreateUser(&config.User{ID: "default", Email: "default@localhost", OTPVerified: true}))
+	require.NoError(t, db.UpdateAIModel("default", "deepseek", true, "sk-test", "", ""))
+	require.NoError(t, db.UpdateExchange("default", "paper", true, "", "", false, "", "", "", "", 1000))
+	require.NoError(t, db.CreateTrader(&config.TraderRecord{
+		ID: "t-paper", UserID: "default", Name: "Paper", AIModelID: "deepseek", ExchangeID: "paper",
+		InitialBalance: 1000, BTCETHLeverage: 10, AltcoinLeverage: 5,
+	}))
+
+	tm := manager.NewTraderManager()
+	require.NoError(t, tm.LoadTradersFromDatabase(db))
+	at, err := tm.GetTrader("t-paper")
+	require.NoError(t, err)
+	at.RecordError(trader.ErrorKindMarketData, "SOLUSDT", "", errors.New("HTTP请求失败: timeout"))
+	at.RecordError(trader.ErrorKindAI, "", "", errors.New("AI API返回错误 (status 502)"))
+	at.RecordError(trader.ErrorKindAI, "", "", nil) // nil 错误不记录
+
+	s := &Server{database: db, traderManager: tm}
+	router := setupTestRouter()
+	withUser := func(h gin.HandlerFunc) gin.HandlerFunc {
+		return func(c *gin.Context) {
+			c.Set("user_id", "default")
+			h(c)
+		}
+	}
+	router.GET("/api/traders/:id/errors", withUser(s.handleTraderErrors))
+	router.DELETE("/api/traders/:id/errors", withUser(s.handleClearTraderErrors))
+	request := func(method, traderID string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(method, "/api/traders/"+traderID+"/errors", nil))
+		return w
+	}
+	list := func() []trader.TraderError {
+		w := request(http.MethodGet, "t-paper")
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var resp struct {
+			Count  int                  `json:"count"`
+			Errors []trader.TraderError `json:"errors"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		assert.Equal(t, len(resp.Errors), resp.Count)
+		return resp.Errors
+	}
+
+	errs := list()
+	require.Len(t, errs, 2)
+	assert.Equal(t, trader.ErrorKindAI, errs[0].Kind)
+	assert.Equal(t, "AI API返回错误 (status 502)", errs[0].Message)
+	assert.Equal(t, trader.ErrorKindMarketData, errs[1].Kind)
+	assert.Equal(t, "SOLUSDT", errs[1].Symbol)
+	assert.False(t, errs[1].Time.IsZero())
+
+	w := request(http.MethodDelete, "t-paper")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.JSONEq(t, `{"trader_id":"t-paper","cleared":2}`, w.Body.String())
+	assert.Empty(t, list())
+
+	assert.Equal(t, http.StatusNotFound, request(http.MethodGet, "someone-else").Code)
+	assert.Equal(t, http.StatusNotFound, request(http.MethodDelete, "someone-else").Code)
+}
@@ -0,0 +1,101 @@
+package api
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"aspen/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+// BulkLeverageRequest 批量更新交易员杠杆请求
+type BulkLeverageRequest struct {
+	BTCETHLeverage  int `json:"btc_eth_leverage"` // 0 表示保持原值
+	AltcoinLeverage int `json:"altcoin_leverage"` // 0 表示保持原值
+	// 只更新这些交易员，为空时更新当前用户的全部交易员
+	TraderIDs []string `json:"trader_ids"`
+	// 只更新使用该交易所/AI模型的交易员（与 trader_ids 同时给出时取交集）
+	ExchangeID string `json:"exchange_id"`
+	AIModelID  string `json:"ai_model_id"`
+}
+
+// validateLeverage 校验杠杆倍数（0 表示保持原值，至少需要给出一个）
+func validateLeverage(btcEthLeverage, altcoinLeverage int) error {
+	if btcEthLeverage < 0 || btcEthLeverage > 50 {
+		return fmt.Errorf("BTC/ETH杠杆必须在1-50倍之间")
+	}
+	if altcoinLeverage < 0 || altcoinLeverage > 20 {
+		return fmt.Errorf("山寨币杠杆必须在1-20倍之间")
+	}
+	if btcEthLeverage == 0 && altcoinLeverage == 0 {
+		return fmt.Errorf("请至少提供 btc_eth_leverage 或 altcoin_leverage")
+	}
+	return nil
+}
+
+// handleBulkUpdateLeverage 批量更新当前用户交易员的杠杆倍数（全部或按ID/交易所/AI模型过滤），
+// 已加载的交易员从下个周期起使用新杠杆
+func (s *Server) handleBulkUpdateLeverage(c *gin.Context) {
+	userID := c.GetString("user_id")
+
+	var req BulkLeverageRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := validateLeverage(req.BTCETHLeverage, req.AltcoinLeverage); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	traders, err := s.database.GetTradersFiltered(userID, config.TraderFilter{
+		ExchangeID: strings.TrimSpace(req.ExchangeID),
+		AIModelID:  strings.TrimSpace(req.AIModelID),
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("获取交易员列表失败: %v", err)})
+		return
+	}
+
+	ids := make([]string, 0, len(traders))
+	if len(req.TraderIDs) == 0 {
+		for _, t := range traders {
+			ids = append(ids, t.ID)
+		}
+	} else {
+		matched := make(map[string]bool, len(traders))
+		for _, t := range traders {
+			matched[t.ID] = true
+		}
+		for _, id := range req.TraderIDs {
+			id = strings.TrimSpace(id)
+			if !matched[id] {
+				c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("交易员 %s 不存在或不符合过滤条件", id)})
+				return
+			}
+			ids = append(ids, id)
+		}
+	}
+
+	if len(ids) > 0 {
+		if err := s.database.UpdateTradersLeverage(userID, ids, req.BTCETHLeverage, req.AltcoinLeverage); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("更新杠杆失败: %v", err)})
+			return
+		}
+	}
+	for _, id := range ids {
+		if at, err := s.traderManager.GetTrader(id); err == nil {
+			at.SetLeverageLimits(req.BTCETHLeverage, req.AltcoinLeverage)
+		}
+	}
+
+	log.Printf("✓ 批量更新杠杆: user=%s, 交易员 %d 个, BTC/ETH=%d, 山寨币=%d", userID, len(ids), req.BTCETHLeverage, req.AltcoinLeverage)
+	c.JSON(http.StatusOK, gin.H{
+		"updated":          ids,
+		"btc_eth_leverage": req.BTCETHLeverage,
+		"altcoin_leverage": req.AltcoinLeverage,
+	})
+}
@@ -0,0 +1,73 @@
+package api
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"aspen/config"
+	"aspen/manager"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestBulkUpdateLeverage 测试批量更新杠杆：指定的交易员都使用新杠杆，其他交易员不变，越界和他人的交易员被拒绝
+func TestBulkUpdateLeverage(t *testing.T) {
+	db := createTestDB(t)
+	defer db.Close()
+
+	require.NoError(t, db.CreateUser(&config.User{ID: "u1", Email: "u1@test.com", OTPVerified: true}))
+	require.NoError(t, db.CreateUser(&config.User{ID: "u2", Email: "u2@test.com", OTPVerified: true}))
+	for _, rec := range []*config.TraderRecord{
+		{ID: "t1", UserID: "u1", Name: "A", AIModelID: "deepseek", ExchangeID: "binance", InitialBalance: 1000},
+		{ID: "t2", UserID: "u1", Name: "B", AIModelID: "qwen", ExchangeID: "binance", InitialBalance: 1000},
+		{ID: "t3", UserID: "u1", Name: "C", AIModelID: "deepseek", ExchangeID: "paper", InitialBalance: 1000},
+		{ID: "t4", UserID: "u2", Name: "D", AIModelID: "deepseek", ExchangeID: "binance", InitialBalance: 1000},
+	} {
+		rec.BTCETHLeverage, rec.AltcoinLeverage = 5, 5
+		require.NoError(t, db.CreateTrader(rec))
+	}
+
+	s := &Server{database: db, traderManager: manager.NewTraderManager()}
+	router := setupTestRouter()
+	router.POST("/api/traders/leverage", func(c *gin.Context) {
+		c.Set("user_id", "u1")
+		s.handleBulkUpdateLeverage(c)
+	})
+	post := func(body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/traders/leverage", bytes.NewBufferString(body)))
+		return w
+	}
+	leverage := func(userID string) map[string][2]int {
+		traders, err := db.GetTraders(userID)
+		require.NoError(t, err)
+		out := make(map[string][2]int)
+		for _, tr := range traders {
+			out[tr.ID] = [2]int{tr.BTCETHLeverage, tr.AltcoinLeverage}
+		}
+		return out
+	}
+
+	w := post(`{"trader_ids": ["t1", "t2"], "btc_eth_leverage": 20, "altcoin_leverage": 8}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, map[string][2]int{"t1": {20, 8}, "t2": {20, 8}, "t3": {5, 5}}, leverage("u1"))
+
+	// 只给出一个杠杆时另一个保持原值；按交易所过滤
+	w = post(`{"exchange_id": "paper", "altcoin_leverage": 3}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, map[string][2]int{"t1": {20, 8}, "t2": {20, 8}, "t3": {5, 3}}, leverage("u1"))
+
+	// 越界或未给出杠杆时拒绝
+	assert.Equal(t, http.StatusBadRequest, post(`{"altcoin_leverage": 25}`).Code)
+	assert.Equal(t, http.StatusBadRequest, post(`{"btc_eth_leverage": -1}`).Code)
+	assert.Equal(t, http.StatusBadRequest, post(`{"trader_ids": ["t1"]}`).Code)
+
+	// 他人的交易员不能更新，且整批不生效
+	assert.Equal(t, http.StatusNotFound, post(`{"trader_ids": ["t1", "t4"], "btc_eth_leverage": 10}`).Code)
+	assert.Equal(t, [2]int{20, 8}, leverage("u1")["t1"])
+	assert.Equal(t, [2]int{5, 5}, leverage("u2")["t4"])
+}
@@ -0,0 +1,46 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// defaultMaxTradersPerUser 每个用户最多可创建的交易员数量（可通过系统配置 max_traders_per_user 覆盖，0 表示不限制）
+const defaultMaxTradersPerUser = 20
+
+// errTraderLimitReached 用户交易员数量已达上限
+var errTraderLimitReached = errors.New("交易员数量已达上限")
+
+// maxTradersPerUser 当前的用户交易员数量上限（0 表示不限制）
+func (s *Server) maxTradersPerUser() int {
+	raw, _ := s.database.GetSystemConfig("max_traders_per_user")
+	if raw = strings.TrimSpace(raw); raw == "" {
+		return defaultMaxTradersPerUser
+	}
+	limit, err := strconv.Atoi(raw)
+	if err != nil || limit < 0 {
+		return defaultMaxTradersPerUser
+	}
+	return limit
+}
+
+// checkTraderLimit 检查用户是否还能创建交易员（管理员不限制），达到上限时返回 errTraderLimitReached
+func (s *Server) checkTraderLimit(userID string) error {
+	if userID == adminUserID {
+		return nil
+	}
+	limit := s.maxTradersPerUser()
+	if limit == 0 {
+		return nil
+	}
+	traders, err := s.database.GetTraders(userID)
+	if err != nil {
+		return fmt.Errorf("获取交易员列表失败: %w", err)
+	}
+	if len(traders) >= limit {
+		return fmt.Errorf("%w：每个用户最多创建 %d 个交易员，当前已有 %d 个", errTraderLimitReached, limit, len(traders))
+	}
+	return nil
+}
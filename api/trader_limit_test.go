@@ -0,0 +1,63 @@
+package api
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"aspen/config"
+	"aspen/manager"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestCreateTraderLimit 测试用户交易员数量达到上限后创建返回403，管理员不受限制
+func TestCreateTraderLimit(t *testing.T) {
+	t.Chdir(t.TempDir()) // 交易员加载时会创建决策日志目录
+	db := createTestDB(t)
+	defer db.Close()
+
+	require.NoError(t, db.SetSystemConfig("max_traders_per_user", "2"))
+	require.NoError(t, db.CreateUser(&config.User{ID: "default", Email: "default@localhost", OTPVerified: true}))
+	require.NoError(t, db.UpdateAIModel("default", "deepseek", true, "sk-test", "", ""))
+	require.NoError(t, db.UpdateExchange("default", "paper", true, "", "", false, "", "", "", "", 1000))
+
+	s := &Server{database: db, traderManager: manager.NewTraderManager()}
+	create := func(userID string, n int) *httptest.ResponseRecorder {
+		router := gin.New()
+		router.POST("/traders", func(c *gin.Context) {
+			c.Set("user_id", userID)
+			s.handleCreateTrader(c)
+		})
+		body := fmt.Sprintf(`{"name":"trader-%d","ai_model_id":"deepseek","exchange_id":"paper","initial_balance":1000}`, n)
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/traders", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// 交易员ID按秒生成，第一个直接写入数据库，避免同一秒内ID冲突
+	require.NoError(t, db.CreateTrader(&config.TraderRecord{ID: "t1", UserID: "default", Name: "trader-1", AIModelID: "deepseek", ExchangeID: "paper", InitialBalance: 1000}))
+	w := create("default", 2)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	w = create("default", 3)
+	assert.Equal(t, http.StatusForbidden, w.Code, w.Body.String())
+	traders, err := db.GetTraders("default")
+	require.NoError(t, err)
+	assert.Len(t, traders, 2)
+
+	// 管理员不受数量限制
+	for i := 1; i <= 3; i++ {
+		require.NoError(t, db.CreateTrader(&config.TraderRecord{ID: fmt.Sprintf("admin-%d", i), UserID: adminUserID, Name: "Admin", AIModelID: "deepseek", ExchangeID: "paper", InitialBalance: 1000}))
+	}
+	assert.NoError(t, s.checkTraderLimit(adminUserID))
+
+	// 0 表示不限制
+	require.NoError(t, db.SetSystemConfig("max_traders_per_user", "0"))
+	assert.NoError(t, s.checkTraderLimit("default"))
+}
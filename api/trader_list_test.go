@@ -0,0 +1,60 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"testing"
+
+	"aspen/config"
+	"aspen/manager"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestHandleTraderList_Filters 测试交易员列表按 ?exchange= 和 ?ai_model= 过滤
+func TestHandleTraderList_Filters(t *testing.T) {
+	db := createTestDB(t)
+	defer db.Close()
+
+	require.NoError(t, db.CreateUser(&config.User{ID: "u1", Email: "u1@test.com", OTPVerified: true}))
+	require.NoError(t, db.CreateUser(&config.User{ID: "u2", Email: "u2@test.com", OTPVerified: true}))
+	for _, rec := range []*config.TraderRecord{
+		{ID: "t1", UserID: "u1", Name: "A", AIModelID: "deepseek", ExchangeID: "binance", InitialBalance: 1000},
+		{ID: "t2", UserID: "u1", Name: "B", AIModelID: "qwen", ExchangeID: "binance", InitialBalance: 1000},
+		{ID: "t3", UserID: "u1", Name: "C", AIModelID: "deepseek", ExchangeID: "paper", InitialBalance: 1000},
+		{ID: "t4", UserID: "u2", Name: "D", AIModelID: "deepseek", ExchangeID: "binance", InitialBalance: 1000},
+	} {
+		require.NoError(t, db.CreateTrader(rec))
+	}
+
+	s := &Server{database: db, traderManager: manager.NewTraderManager()}
+	router := setupTestRouter()
+	router.GET("/api/my-traders", func(c *gin.Context) {
+		c.Set("user_id", "u1")
+		s.handleTraderList(c)
+	})
+
+	list := func(query string) []string {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/my-traders"+query, nil))
+		require.Equal(t, http.StatusOK, w.Code)
+		var items []map[string]interface{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &items))
+		ids := make([]string, 0, len(items))
+		for _, item := range items {
+			ids = append(ids, item["trader_id"].(string))
+		}
+		sort.Strings(ids)
+		return ids
+	}
+
+	assert.Equal(t, []string{"t1", "t2", "t3"}, list(""))
+	assert.Equal(t, []string{"t1", "t2"}, list("?exchange=binance"))
+	assert.Equal(t, []string{"t1", "t3"}, list("?ai_model=deepseek"))
+	assert.Equal(t, []string{"t1"}, list("?exchange=binance&ai_model=deepseek"))
+	assert.Equal(t, []string{}, list("?exchange=hyperliquid"))
+}
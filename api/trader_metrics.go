@@ -0,0 +1,40 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"aspen/metrics"
+
+	"github.com/gin-gonic/gin"
+)
+
+// handleTraderMetrics 指定交易员的交易指标（净值、盈亏、持仓、周期等，Prometheus 指标的 JSON 形式）
+func (s *Server) handleTraderMetrics(c *gin.Context) {
+	userID := c.GetString("user_id")
+	traderID := c.Param("id")
+
+	// 校验交易员归属
+	if _, _, _, err := s.database.GetTraderConfig(userID, traderID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "交易员不存在或无访问权限"})
+		return
+	}
+
+	families, err := metrics.TraderMetrics(traderID)
+	if err != nil {
+		if errors.Is(err, metrics.ErrTraderMetricsBucketed) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("获取交易指标失败: %v", err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"trader_id": traderID,
+		"metrics":   families,
+		"timestamp": time.Now().UnixMilli(),
+	})
+}
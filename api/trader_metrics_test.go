@@ -0,0 +1,94 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"aspen/config"
+	"aspen/metrics"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestTraderMetrics_OnlyOwnTrader 测试只返回指定交易员的交易指标，不混入其他交易员的序列，且只有归属用户可以查看
+func TestTraderMetrics_OnlyOwnTrader(t *testing.T) {
+	db := createTestDB(t)
+	defer db.Close()
+	// 默认用户下已有 deepseek 模型和 paper 交易所
+	require.NoError(t, db.CreateUser(&config.User{ID: "default", Email: "default@localhost", OTPVerified: true}))
+	require.NoError(t, db.CreateTrader(&config.TraderRecord{ID: "metrics-mine", UserID: "default", Name: "Mine", AIModelID: "deepseek", ExchangeID: "paper", InitialBalance: 1000}))
+
+	mine := metrics.NewTradingMetricsRecorder("metrics-mine", "paper")
+	mine.RecordEquity(1234.5)
+	mine.RecordPnL(10, -2.5, 7.5)
+	mine.RecordPositions(3)
+	mine.RecordCycle(true)
+	mine.RecordCycle(true)
+	mine.RecordCycle(false)
+	other := metrics.NewTradingMetricsRecorder("metrics-other", "paper")
+	other.RecordEquity(999)
+	other.RecordPositions(7)
+	other.RecordCycle(true)
+
+	s := &Server{database: db}
+	request := func(userID, traderID string) *httptest.ResponseRecorder {
+		router := setupTestRouter()
+		router.GET("/api/traders/:id/metrics", func(c *gin.Context) {
+			c.Set("user_id", userID)
+			s.handleTraderMetrics(c)
+		})
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/traders/"+traderID+"/metrics", nil))
+		return w
+	}
+
+	w := request("default", "metrics-mine")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var resp struct {
+		TraderID string                 `json:"trader_id"`
+		Metrics  []metrics.MetricFamily `json:"metrics"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, "metrics-mine", resp.TraderID)
+
+	byName := make(map[string]metrics.MetricFamily)
+	for _, family := range resp.Metrics {
+		byName[family.Name] = family
+		for _, sample := range family.Samples {
+			assert.NotContains(t, sample.Labels, "trader_id")
+		}
+	}
+	value := func(name string, labels map[string]string) float64 {
+		t.Helper()
+		family, ok := byName[name]
+		require.True(t, ok, name)
+		for _, sample := range family.Samples {
+			if len(labels) == len(sample.Labels) && (len(labels) == 0 || assert.ObjectsAreEqual(labels, sample.Labels)) {
+				require.NotNil(t, sample.Value)
+				return *sample.Value
+			}
+		}
+		t.Fatalf("%s 没有标签为 %v 的序列", name, labels)
+		return 0
+	}
+
+	assert.Equal(t, 1234.5, value("aspen_trading_equity_usdt", nil))
+	assert.Equal(t, 3.0, value("aspen_trading_positions", nil))
+	assert.Equal(t, 7.5, value("aspen_trading_pnl_usdt", map[string]string{"type": "total"}))
+	assert.Equal(t, -2.5, value("aspen_trading_pnl_usdt", map[string]string{"type": "unrealized"}))
+	assert.Equal(t, 2.0, value("aspen_trading_cycles_total", map[string]string{"status": "success"}))
+	assert.Equal(t, 1.0, value("aspen_trading_cycles_total", map[string]string{"status": "failed"}))
+	assert.Len(t, byName["aspen_trading_equity_usdt"].Samples, 1, "不应包含其他交易员的序列")
+	assert.Len(t, byName["aspen_trading_positions"].Samples, 1)
+	assert.Equal(t, "gauge", byName["aspen_trading_equity_usdt"].Type)
+	assert.Equal(t, "counter", byName["aspen_trading_cycles_total"].Type)
+	assert.NotContains(t, byName, "aspen_http_requests_total", "不返回非交易员指标")
+
+	// 其他用户的交易员和不存在的交易员都返回404
+	assert.Equal(t, http.StatusNotFound, request("default", "metrics-other").Code)
+	assert.Equal(t, http.StatusNotFound, request("someone-else", "metrics-mine").Code)
+}
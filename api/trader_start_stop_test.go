@@ -0,0 +1,54 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"aspen/config"
+	"aspen/manager"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestStopTrader_Idempotent 测试停止已停止的交易员返回 200 和当前状态，而不是报错
+func TestStopTrader_Idempotent(t *testing.T) {
+	t.Chdir(t.TempDir()) // 交易员加载时会创建决策日志目录
+
+	db := createTestDB(t)
+	defer db.Close()
+	require.NoError(t, db.CreateUser(&config.User{ID: "default", Email: "default@localhost", OTPVerified: true}))
+	require.NoError(t, db.UpdateAIModel("default", "deepseek", true, "sk-test", "", ""))
+	require.NoError(t, db.UpdateExchange("default", "paper", true, "", "", false, "", "", "", "", 1000))
+	require.NoError(t, db.CreateTrader(&config.TraderRecord{
+		ID: "t-paper", UserID: "default", Name: "Paper", AIModelID: "deepseek", ExchangeID: "paper",
+		InitialBalance: 1000, BTCETHLeverage: 10, AltcoinLeverage: 5,
+	}))
+
+	tm := manager.NewTraderManager()
+	require.NoError(t, tm.LoadTradersFromDatabase(db))
+
+	s := &Server{database: db, traderManager: tm}
+	router := setupTestRouter()
+	router.POST("/api/traders/:id/stop", func(c *gin.Context) {
+		c.Set("user_id", "default")
+		s.handleStopTrader(c)
+	})
+
+	for i := 0; i < 2; i++ {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/traders/t-paper/stop", nil))
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var resp struct {
+			Status string                 `json:"status"`
+			Trader map[string]interface{} `json:"trader"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		assert.Equal(t, "already_stopped", resp.Status)
+		assert.Equal(t, false, resp.Trader["is_running"])
+		assert.Equal(t, "t-paper", resp.Trader["trader_id"])
+	}
+}
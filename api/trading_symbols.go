@@ -0,0 +1,92 @@
+package api
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"aspen/market"
+)
+
+// defaultMaxTradingSymbols 每个交易员最多可配置的交易币种数量（可通过系统配置 max_trading_symbols 覆盖）
+const defaultMaxTradingSymbols = 20
+
+// TradingSymbolsResult 交易币种标准化结果
+type TradingSymbolsResult struct {
+	Symbols  []string // 标准化后的币种列表
+	Warnings []string // 给用户的提示信息
+}
+
+// Canonical 返回用于存储的标准格式（逗号分隔）
+func (r *TradingSymbolsResult) Canonical() string {
+	return strings.Join(r.Symbols, ",")
+}
+
+// normalizeTradingSymbols 标准化并校验交易币种
+// available 为交易员数据源 source 可交易的币种集合，nil 表示无法校验；
+// strict 为 true 时不可用的币种直接报错，否则移除并给出提示
+func normalizeTradingSymbols(raw string, source market.DataSource, available map[string]bool, strict bool, maxCount int) (*TradingSymbolsResult, error) {
+	symbols, warnings := market.NormalizeSymbols(market.ParseSymbolList(raw))
+
+	if available != nil {
+		if source == "" {
+			source = market.GetCurrentDataSource()
+		}
+		kept := make([]string, 0, len(symbols))
+		for _, symbol := range symbols {
+			if available[symbol] {
+				kept = append(kept, symbol)
+				continue
+			}
+			if strict {
+				return nil, fmt.Errorf("%s 在 %s 数据源不可用", symbol, source)
+			}
+			warnings = append(warnings, fmt.Sprintf("%s 在 %s 数据源不可用，已移除", symbol, source))
+		}
+		symbols = kept
+		if len(symbols) == 0 {
+			return nil, fmt.Errorf("没有在 %s 数据源可用的交易币种", source)
+		}
+	}
+
+	if maxCount > 0 && len(symbols) > maxCount {
+		return nil, fmt.Errorf("交易币种数量超过上限: %d > %d", len(symbols), maxCount)
+	}
+
+	return &TradingSymbolsResult{Symbols: symbols, Warnings: warnings}, nil
+}
+
+// resolveTradingSymbols 按系统配置标准化交易员的交易币种，并按交易员的行情数据源校验可用性（创建/更新交易员时调用）
+func (s *Server) resolveTradingSymbols(raw string, source market.DataSource) (*TradingSymbolsResult, error) {
+	if strings.TrimSpace(raw) == "" {
+		return &TradingSymbolsResult{}, nil
+	}
+
+	strict := false
+	maxCount := defaultMaxTradingSymbols
+	if s.database != nil {
+		if strictStr, _ := s.database.GetSystemConfig("trading_symbols_strict"); strictStr == "true" {
+			strict = true
+		}
+		if maxStr, _ := s.database.GetSystemConfig("max_trading_symbols"); maxStr != "" {
+			if val, err := strconv.Atoi(maxStr); err == nil && val > 0 {
+				maxCount = val
+			}
+		}
+	}
+
+	var warnings []string
+	available, err := market.GetTradableSymbols(source)
+	if err != nil {
+		log.Printf("⚠️  获取可交易币种失败，跳过可用性校验: %v", err)
+		warnings = append(warnings, "暂时无法校验币种可用性")
+	}
+
+	result, err := normalizeTradingSymbols(raw, source, available, strict, maxCount)
+	if err != nil {
+		return nil, err
+	}
+	result.Warnings = append(result.Warnings, warnings...)
+	return result, nil
+}
@@ -0,0 +1,58 @@
+package api
+
+import (
+	"testing"
+
+	"aspen/market"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalizeTradingSymbols(t *testing.T) {
+	available := map[string]bool{"BTCUSDT": true, "ETHUSDT": true, "DOGEUSDT": true}
+
+	t.Run("标准化_去重_保持顺序", func(t *testing.T) {
+		result, err := normalizeTradingSymbols("eth, BTC,DOGE,ETHUSDT", "", available, false, 20)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"ETHUSDT", "BTCUSDT", "DOGEUSDT"}, result.Symbols)
+		assert.Equal(t, "ETHUSDT,BTCUSDT,DOGEUSDT", result.Canonical())
+		assert.Contains(t, result.Warnings, "DOGE 已识别为 DOGEUSDT")
+	})
+
+	t.Run("兼容JSON数组", func(t *testing.T) {
+		result, err := normalizeTradingSymbols(`["BTCUSDT","ETHUSDT"]`, "", available, false, 20)
+		require.NoError(t, err)
+		assert.Equal(t, "BTCUSDT,ETHUSDT", result.Canonical())
+	})
+
+	t.Run("不可用币种_非严格模式移除", func(t *testing.T) {
+		result, err := normalizeTradingSymbols("BTC,HYPE", market.DataSourceBybit, available, false, 20)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"BTCUSDT"}, result.Symbols)
+		require.NotEmpty(t, result.Warnings)
+		assert.Contains(t, result.Warnings[len(result.Warnings)-1], "HYPEUSDT")
+		assert.Contains(t, result.Warnings[len(result.Warnings)-1], "bybit", "提示交易员自己的数据源")
+	})
+
+	t.Run("不可用币种_严格模式报错", func(t *testing.T) {
+		_, err := normalizeTradingSymbols("BTC,HYPE", "", available, true, 20)
+		assert.Error(t, err)
+	})
+
+	t.Run("全部不可用_报错", func(t *testing.T) {
+		_, err := normalizeTradingSymbols("HYPE", "", available, false, 20)
+		assert.Error(t, err)
+	})
+
+	t.Run("无法校验时保留", func(t *testing.T) {
+		result, err := normalizeTradingSymbols("HYPE", "", nil, true, 20)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"HYPEUSDT"}, result.Symbols)
+	})
+
+	t.Run("超过数量上限", func(t *testing.T) {
+		_, err := normalizeTradingSymbols("BTC,ETH,DOGE", "", available, false, 2)
+		assert.Error(t, err)
+	})
+}
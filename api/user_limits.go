@@ -0,0 +1,99 @@
+package api
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"aspen/trader"
+
+	"github.com/gin-gonic/gin"
+)
+
+// handleGetUserRiskLimits 获取用户级风控配置
+func (s *Server) handleGetUserRiskLimits(c *gin.Context) {
+	userID := c.GetString("user_id")
+	limit, err := s.database.GetUserMaxConcurrentPositions(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("获取用户风控配置失败: %v", err)})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"max_concurrent_positions": limit})
+}
+
+// handleUpdateUserRiskLimits 设置用户所有交易员合计的最大同时持仓数（0 表示不限制）
+func (s *Server) handleUpdateUserRiskLimits(c *gin.Context) {
+	userID := c.GetString("user_id")
+	var req struct {
+		MaxConcurrentPositions *int `json:"max_concurrent_positions" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if *req.MaxConcurrentPositions < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "最大同时持仓数不能为负数（0 表示不限制）"})
+		return
+	}
+
+	if err := s.database.UpdateUserMaxConcurrentPositions(userID, *req.MaxConcurrentPositions); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("保存用户风控配置失败: %v", err)})
+		return
+	}
+
+	log.Printf("✓ 用户风控配置已保存: user=%s, max_concurrent_positions=%d", userID, *req.MaxConcurrentPositions)
+	c.JSON(http.StatusOK, gin.H{"max_concurrent_positions": *req.MaxConcurrentPositions})
+}
+
+// handleGetUserWebhook 获取用户的交易事件 Webhook 配置（不返回签名密钥）
+func (s *Server) handleGetUserWebhook(c *gin.Context) {
+	userID := c.GetString("user_id")
+	webhookURL, secret, err := s.database.GetUserWebhook(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("获取Webhook配置失败: %v", err)})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"url": webhookURL, "has_secret": secret != ""})
+}
+
+// handleUpdateUserWebhook 设置用户的交易事件 Webhook（url 为空表示关闭推送；secret 不传时保留原密钥），保存后立即对已加载的交易员生效
+func (s *Server) handleUpdateUserWebhook(c *gin.Context) {
+	userID := c.GetString("user_id")
+	var req struct {
+		URL    string  `json:"url"`
+		Secret *string `json:"secret"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	req.URL = strings.TrimSpace(req.URL)
+	if req.URL != "" {
+		parsed, err := url.Parse(req.URL)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Webhook地址必须是有效的 http/https URL"})
+			return
+		}
+	}
+
+	_, secret, err := s.database.GetUserWebhook(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("获取Webhook配置失败: %v", err)})
+		return
+	}
+	if req.Secret != nil {
+		secret = *req.Secret
+	}
+
+	if err := s.database.UpdateUserWebhook(userID, req.URL, secret); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("保存Webhook配置失败: %v", err)})
+		return
+	}
+	s.traderManager.SetUserWebhook(userID, trader.WebhookConfig{URL: req.URL, Secret: secret})
+
+	log.Printf("✓ 用户Webhook配置已保存: user=%s, enabled=%v", userID, req.URL != "")
+	c.JSON(http.StatusOK, gin.H{"url": req.URL, "has_secret": secret != ""})
+}
@@ -1,6 +1,11 @@
 package api
 
-import "strings"
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
 
 // MaskSensitiveString 脱敏敏感字符串，只显示前4位和后4位
 // 用于脱敏 API Key、Secret Key、Private Key 等敏感信息
@@ -99,3 +104,27 @@ func MaskEmail(email string) string {
 	}
 	return username[:2] + "****@" + domain
 }
+
+// parseSinceParam 解析查询参数中的起始时间
+// 支持 RFC3339 时间、Unix秒级时间戳和相对时长（如 "24h"、"7d"），为空时默认最近24小时
+func parseSinceParam(since string, now time.Time) (time.Time, error) {
+	since = strings.TrimSpace(since)
+	if since == "" {
+		return now.Add(-24 * time.Hour), nil
+	}
+	if t, err := time.Parse(time.RFC3339, since); err == nil {
+		return t, nil
+	}
+	if ts, err := strconv.ParseInt(since, 10, 64); err == nil {
+		return time.Unix(ts, 0), nil
+	}
+	if strings.HasSuffix(since, "d") {
+		if days, err := strconv.Atoi(strings.TrimSuffix(since, "d")); err == nil && days > 0 {
+			return now.Add(-time.Duration(days) * 24 * time.Hour), nil
+		}
+	}
+	if d, err := time.ParseDuration(since); err == nil && d > 0 {
+		return now.Add(-d), nil
+	}
+	return time.Time{}, fmt.Errorf("无效的since参数: %s（支持RFC3339时间、Unix时间戳或24h/7d等时长）", since)
+}
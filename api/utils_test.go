@@ -2,6 +2,7 @@ package api
 
 import (
 	"testing"
+	"time"
 )
 
 func TestMaskSensitiveString(t *testing.T) {
@@ -192,3 +193,33 @@ func TestMaskEmail(t *testing.T) {
 		})
 	}
 }
+
+func TestParseSinceParam(t *testing.T) {
+	now := time.Date(2025, 1, 10, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		input   string
+		want    time.Time
+		wantErr bool
+	}{
+		{"", now.Add(-24 * time.Hour), false},
+		{"2h", now.Add(-2 * time.Hour), false},
+		{"7d", now.Add(-7 * 24 * time.Hour), false},
+		{"2025-01-01T00:00:00Z", time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), false},
+		{"1736467200", time.Unix(1736467200, 0), false},
+		{"yesterday", time.Time{}, true},
+		{"-3h", time.Time{}, true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseSinceParam(tt.input, now)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("parseSinceParam(%q) 应返回错误", tt.input)
+			}
+			continue
+		}
+		if err != nil || !got.Equal(tt.want) {
+			t.Errorf("parseSinceParam(%q) = %v, %v; want %v", tt.input, got, err, tt.want)
+		}
+	}
+}
@@ -171,13 +171,28 @@ func IsTokenBlacklisted(token string) bool {
 	return false
 }
 
+// token 权限范围
+const (
+	ScopeRead  = "read"  // 只读：查看交易员、持仓和统计数据（只读看板）
+	ScopeTrade = "trade" // 完整权限：创建/启停交易员、修改配置等
+)
+
 // Claims JWT声明
 type Claims struct {
 	UserID string `json:"user_id"`
 	Email  string `json:"email"`
+	Scope  string `json:"scope,omitempty"` // 权限范围，为空视为 trade（兼容未携带scope的旧token）
 	jwt.RegisteredClaims
 }
 
+// EffectiveScope token 实际的权限范围
+func (c *Claims) EffectiveScope() string {
+	if c.Scope == "" {
+		return ScopeTrade
+	}
+	return c.Scope
+}
+
 // HashPassword 哈希密码
 func HashPassword(password string) (string, error) {
 	bytes, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
@@ -214,13 +229,22 @@ func VerifyOTP(secret, code string) bool {
 	return totp.Validate(code, secret)
 }
 
-// GenerateJWT 生成JWT token
+// GenerateJWT 生成完整权限的JWT token（24小时过期）
 func GenerateJWT(userID, email string) (string, error) {
+	return GenerateScopedJWT(userID, email, ScopeTrade, 24*time.Hour)
+}
+
+// GenerateScopedJWT 生成指定权限范围和有效期的JWT token
+func GenerateScopedJWT(userID, email, scope string, ttl time.Duration) (string, error) {
+	if scope != ScopeRead && scope != ScopeTrade {
+		return "", fmt.Errorf("未知的权限范围: %s", scope)
+	}
 	claims := Claims{
 		UserID: userID,
 		Email:  email,
+		Scope:  scope,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)), // 24小时过期
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(ttl)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 			NotBefore: jwt.NewNumericDate(time.Now()),
 			Issuer:    "Aspen",
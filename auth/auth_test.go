@@ -107,6 +107,29 @@ func TestGenerateJWT_ContainsExpiry(t *testing.T) {
 	assert.InDelta(t, 24*time.Hour.Seconds(), diff.Seconds(), 10, "token should expire in ~24h")
 }
 
+func TestGenerateScopedJWT_Scope(t *testing.T) {
+	resetBlacklist()
+
+	readToken, err := GenerateScopedJWT("u1", "u1@test.com", ScopeRead, 7*24*time.Hour)
+	require.NoError(t, err)
+	claims, err := ValidateJWT(readToken)
+	require.NoError(t, err)
+	assert.Equal(t, ScopeRead, claims.EffectiveScope())
+	assert.InDelta(t, (7 * 24 * time.Hour).Seconds(), time.Until(claims.ExpiresAt.Time).Seconds(), 10)
+
+	tradeToken, err := GenerateJWT("u1", "u1@test.com")
+	require.NoError(t, err)
+	claims, err = ValidateJWT(tradeToken)
+	require.NoError(t, err)
+	assert.Equal(t, ScopeTrade, claims.EffectiveScope())
+
+	// 未携带scope的旧token视为完整权限
+	assert.Equal(t, ScopeTrade, (&Claims{}).EffectiveScope())
+
+	_, err = GenerateScopedJWT("u1", "u1@test.com", "admin", time.Hour)
+	assert.Error(t, err, "unknown scope should be rejected")
+}
+
 func TestValidateToken_RejectsExpired(t *testing.T) {
 	resetBlacklist()
 
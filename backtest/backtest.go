@@ -0,0 +1,461 @@
+// Package backtest 回测：按时间回放历史K线，每个决策周期使用与实盘相同的流程（构建提示词、调用AI、解析和验证决策），
+// 在回放模式的模拟仓上按回放K线的收盘价执行，输出净值曲线、已实现盈亏、最大回撤和胜率。
+// 历史的持仓量和资金费率无法获取，回放的市场数据中按不可用处理；模拟仓不支持止损止盈单，回测中同样不触发
+package backtest
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"aspen/decision"
+	"aspen/logger"
+	"aspen/market"
+	"aspen/mcp"
+	"aspen/trader"
+)
+
+const (
+	// UsageTraderID 回测调用在AI用量表中记录的交易员ID（用量按用户统计，不计入任何交易员）
+	UsageTraderID = "backtest"
+	// MaxCycles 单次回测最多的决策周期数（每个周期调用一次AI）
+	MaxCycles = 500
+	// DefaultInterval 默认决策间隔
+	DefaultInterval = 15 * time.Minute
+	// DefaultInitialBalance 默认初始资金
+	DefaultInitialBalance = 1000.0
+	// historyBars 每个周期计算指标使用的K线根数（与实时行情的K线缓存一致）
+	historyBars = 100
+	// maxCandleStaleness 最新一根3分钟K线收盘超过该时长视为K线缺失，该周期跳过此币种（不让AI看到过期行情）
+	maxCandleStaleness = 15 * time.Minute
+)
+
+// replayIntervals 回放使用的K线周期（与实时行情一致：3分钟为主，4小时和30分钟为长周期参考）
+var replayIntervals = []string{"3m", "30m", "4h"}
+
+// fetchKlines 历史K线来源（测试可替换）
+var fetchKlines = func(source market.DataSource, symbol, interval string, start, end time.Time) ([]market.Kline, error) {
+	return market.NewAPIClientFor(source).GetKlinesRange(symbol, interval, start, end)
+}
+
+// Config 回测参数
+type Config struct {
+	Symbols            []string
+	Start              time.Time
+	End                time.Time
+	Interval           time.Duration // 决策间隔（回放步长），需为3分钟的整数倍
+	InitialBalance     float64
+	BTCETHLeverage     int
+	AltcoinLeverage    int
+	CustomPrompt       string
+	OverrideBasePrompt bool
+	TemplateName       string
+	DataSource         market.DataSource      // 获取历史K线的数据源（空值使用全局数据源）
+	Indicators         market.IndicatorParams // 指标周期（零值使用默认周期）
+	Costs              trader.PaperCostConfig // 手续费率和固定滑点（零值使用默认费率、无滑点；按回放K线收盘价成交，不模拟成交延迟）
+}
+
+// Normalize 校验参数并填充默认值（Run 会再次调用，重复调用结果不变）
+func (c *Config) Normalize() error {
+	seen := make(map[string]bool)
+	symbols := make([]string, 0, len(c.Symbols))
+	for _, symbol := range c.Symbols {
+		symbol = market.Normalize(strings.TrimSpace(symbol))
+		if symbol != "" && !seen[symbol] {
+			seen[symbol] = true
+			symbols = append(symbols, symbol)
+		}
+	}
+	if len(symbols) == 0 {
+		return fmt.Errorf("至少需要一个回测币种")
+	}
+	c.Symbols = symbols
+
+	if c.Interval == 0 {
+		c.Interval = DefaultInterval
+	}
+	if c.Interval < 3*time.Minute || c.Interval%(3*time.Minute) != 0 {
+		return fmt.Errorf("无效的决策间隔 %s（需为3分钟的整数倍）", c.Interval)
+	}
+	if c.Start.IsZero() || c.End.IsZero() || !c.End.After(c.Start) {
+		return fmt.Errorf("无效的回测区间: 结束时间必须晚于开始时间")
+	}
+	if c.End.After(time.Now()) {
+		return fmt.Errorf("回测结束时间不能晚于当前时间")
+	}
+	if cycles := int(c.End.Sub(c.Start)/c.Interval) + 1; cycles > MaxCycles {
+		return fmt.Errorf("回测区间包含 %d 个决策周期，超过上限 %d（缩短区间或增大决策间隔）", cycles, MaxCycles)
+	}
+	if c.InitialBalance == 0 {
+		c.InitialBalance = DefaultInitialBalance
+	}
+	if c.InitialBalance < 0 {
+		return fmt.Errorf("初始资金必须大于0")
+	}
+	if c.BTCETHLeverage <= 0 {
+		c.BTCETHLeverage = 5
+	}
+	if c.AltcoinLeverage <= 0 {
+		c.AltcoinLeverage = 5
+	}
+	return nil
+}
+
+// EquityPoint 净值曲线上的一个点（每个决策周期结束时）
+type EquityPoint struct {
+	Time   time.Time `json:"time"`
+	Equity float64   `json:"equity"`
+}
+
+// Result 回测结果
+type Result struct {
+	Symbols        []string      `json:"symbols"`
+	Start          time.Time     `json:"start"`
+	End            time.Time     `json:"end"`
+	Interval       string        `json:"interval"`
+	Cycles         int           `json:"cycles"`        // 回放的决策周期数
+	FailedCycles   int           `json:"failed_cycles"` // AI调用或决策解析失败的周期数
+	InitialBalance float64       `json:"initial_balance"`
+	FinalEquity    float64       `json:"final_equity"` // 回测结束时的净值（含未平仓持仓的浮动盈亏）
+	ReturnPct      float64       `json:"return_pct"`
+	RealizedPnL    float64       `json:"realized_pnl"` // 已实现盈亏（含强平，不含手续费）
+	TotalFees      float64       `json:"total_fees"`
+	MaxDrawdownPct float64       `json:"max_drawdown_pct"` // 净值曲线的最大回撤（百分比）
+	TotalTrades    int           `json:"total_trades"`     // 平仓次数（含部分平仓和强平）
+	WinningTrades  int           `json:"winning_trades"`
+	LosingTrades   int           `json:"losing_trades"`
+	WinRate        float64       `json:"win_rate"`       // 胜率（百分比）
+	OpenPositions  int           `json:"open_positions"` // 回测结束时仍未平仓的持仓数
+	EquityCurve    []EquityPoint `json:"equity_curve"`
+	Warnings       []string      `json:"warnings,omitempty"` // 数据问题（币种在部分区间没有K线、K线缺失跳过的周期）
+}
+
+// history 一个币种回放使用的历史K线（按周期）
+type history map[string][]market.Kline
+
+// Run 按 cfg 回放历史K线，逐周期调用AI决策并在回放模式的模拟仓上执行
+func Run(ctx context.Context, client *mcp.Client, cfg Config) (*Result, error) {
+	if err := cfg.Normalize(); err != nil {
+		return nil, err
+	}
+
+	histories, warnings, err := loadHistories(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	account, err := trader.NewPaperTrader(cfg.InitialBalance)
+	if err != nil {
+		return nil, err
+	}
+	var now time.Time
+	prices := make(map[string]float64) // 各币种最近一次回放的收盘价（K线缺失时沿用）
+	account.SetReplayMode(func(symbol string) (float64, error) {
+		price, ok := prices[symbol]
+		if !ok {
+			return 0, fmt.Errorf("%s 在 %s 之前没有K线数据", symbol, now.UTC().Format(time.RFC3339))
+		}
+		return price, nil
+	}, func() time.Time { return now })
+	account.SetCosts(cfg.Costs)
+
+	result := &Result{
+		Symbols:        cfg.Symbols,
+		Start:          cfg.Start,
+		End:            cfg.End,
+		Interval:       cfg.Interval.String(),
+		InitialBalance: cfg.InitialBalance,
+		EquityCurve:    []EquityPoint{},
+	}
+	var forcedCloses []float64 // 强平和止损/止盈单触发的平仓盈亏（计入交易统计）
+	account.SetLiquidationHandler(func(pos trader.Position, price, pnl float64) {
+		forcedCloses = append(forcedCloses, pnl)
+	})
+	account.SetStopOrderHandler(func(order trader.PaperStopOrder, pos trader.Position, price, pnl float64) {
+		forcedCloses = append(forcedCloses, pnl)
+	})
+
+	traderCfg := trader.AutoTraderConfig{BTCETHLeverage: cfg.BTCETHLeverage, AltcoinLeverage: cfg.AltcoinLeverage, IsCrossMargin: true}
+	skipped := make(map[string]int) // 各币种因K线缺失跳过的周期数
+	for now = cfg.Start; !now.After(cfg.End); now = now.Add(cfg.Interval) {
+		if err := ctx.Err(); err != nil {
+			return nil, fmt.Errorf("回测在 %s 中断: %w", now.UTC().Format(time.RFC3339), err)
+		}
+		result.Cycles++
+
+		replay := make(map[string]*market.Data)
+		for _, symbol := range cfg.Symbols {
+			data, stale := replayData(cfg, symbol, histories[symbol], now)
+			if stale {
+				skipped[symbol]++
+			}
+			if data != nil {
+				replay[symbol] = data
+				prices[symbol] = data.CurrentPrice
+			}
+		}
+
+		if len(replay) > 0 {
+			if err := runCycle(ctx, client, cfg, traderCfg, account, replay, now, result); err != nil {
+				result.FailedCycles++
+				logger.Warnf("⚠️  [Backtest] %s 决策失败: %v", now.UTC().Format(time.RFC3339), err)
+			}
+		}
+
+		equity, err := accountEquity(account)
+		if err != nil {
+			return nil, err
+		}
+		result.EquityCurve = append(result.EquityCurve, EquityPoint{Time: now, Equity: equity})
+	}
+
+	for _, pnl := range forcedCloses {
+		result.recordClose(pnl)
+	}
+	for _, symbol := range cfg.Symbols {
+		if n := skipped[symbol]; n > 0 {
+			warnings = append(warnings, fmt.Sprintf("%s 有 %d 个周期因K线缺失被跳过", symbol, n))
+		}
+	}
+	result.Warnings = warnings
+	if err := result.finish(account); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// runCycle 执行一个决策周期：按回放数据构建上下文并调用AI，在模拟仓上执行返回的决策
+func runCycle(ctx context.Context, client *mcp.Client, cfg Config, traderCfg trader.AutoTraderConfig, account *trader.PaperTrader, replay map[string]*market.Data, now time.Time, result *Result) error {
+	dctx, err := buildContext(cfg, account, replay, now, result.Cycles)
+	if err != nil {
+		return err
+	}
+	dctx.CallCtx = ctx
+	full, err := decision.GetFullDecisionWithCustomPrompt(dctx, client, cfg.CustomPrompt, cfg.OverrideBasePrompt, cfg.TemplateName)
+	if err != nil {
+		return err
+	}
+	for _, action := range trader.ReplayDecisions(account, full.Decisions, traderCfg) {
+		if action.Status != "executed" {
+			if action.Status == "rejected" {
+				logger.Infof("📝 [Backtest] %s %s %s 被拒绝: %s", now.UTC().Format(time.RFC3339), action.Symbol, action.Action, action.Error)
+			}
+			continue
+		}
+		result.TotalFees += action.Fee
+		switch action.Action {
+		case "close_long", "close_short", "partial_close":
+			result.recordClose(action.RealizedPnL)
+		}
+	}
+	return nil
+}
+
+// buildContext 按回放时刻的模拟仓状态和市场数据构建决策上下文
+func buildContext(cfg Config, account *trader.PaperTrader, replay map[string]*market.Data, now time.Time, cycle int) (*decision.Context, error) {
+	balance, err := account.GetBalance()
+	if err != nil {
+		return nil, fmt.Errorf("获取模拟仓余额失败: %w", err)
+	}
+	positions, err := account.GetPositions()
+	if err != nil {
+		return nil, fmt.Errorf("获取模拟仓持仓失败: %w", err)
+	}
+
+	equity := floatValue(balance, "totalWalletBalance") + floatValue(balance, "totalUnrealizedProfit")
+	marginUsed := floatValue(balance, "totalInitialMargin")
+	infos := make([]decision.PositionInfo, 0, len(positions))
+	for _, pos := range positions {
+		info := decision.PositionInfo{
+			Symbol:           fmt.Sprint(pos["symbol"]),
+			Side:             fmt.Sprint(pos["side"]),
+			EntryPrice:       floatValue(pos, "entryPrice"),
+			MarkPrice:        floatValue(pos, "markPrice"),
+			Quantity:         floatValue(pos, "positionAmt"),
+			UnrealizedPnL:    floatValue(pos, "unRealizedProfit"),
+			LiquidationPrice: floatValue(pos, "liquidationPrice"),
+			MarginUsed:       floatValue(pos, "initialMargin"),
+		}
+		info.Leverage, _ = pos["leverage"].(int)
+		if info.MarginUsed > 0 {
+			info.UnrealizedPnLPct = info.UnrealizedPnL / info.MarginUsed * 100
+		}
+		infos = append(infos, info)
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Symbol < infos[j].Symbol })
+
+	candidates := make([]decision.CandidateCoin, 0, len(cfg.Symbols))
+	for _, symbol := range cfg.Symbols {
+		candidates = append(candidates, decision.CandidateCoin{Symbol: symbol, Sources: []string{"backtest"}})
+	}
+
+	accountInfo := decision.AccountInfo{
+		TotalEquity:      equity,
+		AvailableBalance: floatValue(balance, "availableBalance"),
+		TotalPnL:         equity - cfg.InitialBalance,
+		TotalPnLPct:      (equity - cfg.InitialBalance) / cfg.InitialBalance * 100,
+		MarginUsed:       marginUsed,
+		PositionCount:    len(infos),
+		MarginAsset:      trader.MarginAssetUSDT,
+		NativeEquity:     equity,
+	}
+	if equity > 0 {
+		accountInfo.MarginUsedPct = marginUsed / equity * 100
+	}
+
+	return &decision.Context{
+		CurrentTime:      now.UTC().Format("2006-01-02 15:04:05"),
+		RuntimeMinutes:   int(now.Sub(cfg.Start).Minutes()),
+		CallCount:        cycle,
+		Account:          accountInfo,
+		Positions:        infos,
+		CandidateCoins:   candidates,
+		BTCETHLeverage:   cfg.BTCETHLeverage,
+		AltcoinLeverage:  cfg.AltcoinLeverage,
+		DataSource:       cfg.DataSource,
+		Indicators:       cfg.Indicators,
+		ReplayMarketData: replay,
+	}, nil
+}
+
+// loadHistories 分页获取各币种回测区间（含计算指标所需的预热K线）的历史K线。
+// 个别币种没有K线时记录警告并在回放中跳过，全部币种都没有K线时返回错误
+func loadHistories(cfg Config) (map[string]history, []string, error) {
+	histories := make(map[string]history)
+	var warnings []string
+	for _, symbol := range cfg.Symbols {
+		h := make(history)
+		for _, interval := range replayIntervals {
+			warmup := time.Duration(historyBars) * intervalDuration(interval)
+			klines, err := fetchKlines(cfg.DataSource, symbol, interval, cfg.Start.Add(-warmup), cfg.End)
+			if err != nil {
+				return nil, nil, err
+			}
+			h[interval] = klines
+		}
+
+		klines := h["3m"]
+		switch {
+		case len(klines) == 0:
+			warnings = append(warnings, fmt.Sprintf("%s 在回测区间内没有K线数据（可能尚未上线或已下架），已跳过", symbol))
+			continue
+		case klineTime(klines[0]).After(cfg.Start):
+			warnings = append(warnings, fmt.Sprintf("%s 从 %s 开始才有K线数据，之前的周期不包含该币种", symbol, klineTime(klines[0]).UTC().Format(time.RFC3339)))
+		}
+		if last := klineTime(klines[len(klines)-1]).Add(3 * time.Minute); cfg.End.Sub(last) > maxCandleStaleness {
+			warnings = append(warnings, fmt.Sprintf("%s 的K线在 %s 结束（可能已下架），之后的周期不包含该币种", symbol, last.UTC().Format(time.RFC3339)))
+		}
+		histories[symbol] = h
+	}
+	if len(histories) == 0 {
+		return nil, nil, fmt.Errorf("所有回测币种在区间内都没有K线数据")
+	}
+	return histories, warnings, nil
+}
+
+// replayData 组装币种在回放时刻 now 的市场数据（只使用 now 之前已收盘的K线）。
+// 尚无K线（未上线）或长周期K线不足时返回 nil；最新K线过期（数据缺口）时返回 nil 且 stale 为 true
+func replayData(cfg Config, symbol string, h history, now time.Time) (data *market.Data, stale bool) {
+	if h == nil {
+		return nil, false
+	}
+	klines3m := market.ClosedKlines(h["3m"], "3m", now, historyBars)
+	klines4h := market.ClosedKlines(h["4h"], "4h", now, historyBars)
+	if len(klines3m) == 0 || len(klines4h) == 0 {
+		return nil, false
+	}
+	lastOpen := klineTime(klines3m[len(klines3m)-1])
+	if now.Sub(lastOpen.Add(3*time.Minute)) > maxCandleStaleness {
+		// 之后还有K线时为数据缺口；之后再无K线（已下架）时不算缺口
+		all := h["3m"]
+		return nil, klineTime(all[len(all)-1]).After(lastOpen)
+	}
+	klines30m := market.ClosedKlines(h["30m"], "30m", now, historyBars)
+
+	data, err := market.BuildReplayData(cfg.DataSource, symbol, klines3m, klines4h, klines30m, cfg.Indicators)
+	if err != nil {
+		logger.Warnf("⚠️  [Backtest] 组装 %s 在 %s 的市场数据失败: %v", symbol, now.UTC().Format(time.RFC3339), err)
+		return nil, false
+	}
+	return data, false
+}
+
+// recordClose 记录一次平仓的盈亏
+func (r *Result) recordClose(pnl float64) {
+	r.TotalTrades++
+	if pnl > 0 {
+		r.WinningTrades++
+	} else {
+		r.LosingTrades++
+	}
+}
+
+// finish 根据模拟仓最终状态和净值曲线计算汇总指标
+func (r *Result) finish(account *trader.PaperTrader) error {
+	equity, err := accountEquity(account)
+	if err != nil {
+		return err
+	}
+	positions, err := account.GetPositions()
+	if err != nil {
+		return fmt.Errorf("获取模拟仓持仓失败: %w", err)
+	}
+	r.FinalEquity = equity
+	r.ReturnPct = (equity - r.InitialBalance) / r.InitialBalance * 100
+	r.RealizedPnL = account.RealizedPnL()
+	r.OpenPositions = len(positions)
+	r.MaxDrawdownPct = maxDrawdownPct(r.InitialBalance, r.EquityCurve)
+	if r.TotalTrades > 0 {
+		r.WinRate = float64(r.WinningTrades) / float64(r.TotalTrades) * 100
+	}
+	return nil
+}
+
+// maxDrawdownPct 净值曲线从峰值（不低于初始资金）回落的最大百分比
+func maxDrawdownPct(initial float64, curve []EquityPoint) float64 {
+	peak, maxDD := initial, 0.0
+	for _, p := range curve {
+		peak = math.Max(peak, p.Equity)
+		if peak > 0 {
+			maxDD = math.Max(maxDD, (peak-p.Equity)/peak*100)
+		}
+	}
+	return maxDD
+}
+
+// accountEquity 模拟仓净值（钱包余额 + 未实现盈亏）
+func accountEquity(account *trader.PaperTrader) (float64, error) {
+	balance, err := account.GetBalance()
+	if err != nil {
+		return 0, fmt.Errorf("获取模拟仓余额失败: %w", err)
+	}
+	return floatValue(balance, "totalWalletBalance") + floatValue(balance, "totalUnrealizedProfit"), nil
+}
+
+func floatValue(m map[string]interface{}, key string) float64 {
+	v, _ := m[key].(float64)
+	return v
+}
+
+// klineTime K线的开盘时间（兼容秒和毫秒时间戳）
+func klineTime(k market.Kline) time.Time {
+	if k.OpenTime >= 1e12 {
+		return time.UnixMilli(k.OpenTime)
+	}
+	return time.Unix(k.OpenTime, 0)
+}
+
+// intervalDuration K线周期的时长
+func intervalDuration(interval string) time.Duration {
+	switch interval {
+	case "4h":
+		return 4 * time.Hour
+	case "30m":
+		return 30 * time.Minute
+	default:
+		return 3 * time.Minute
+	}
+}
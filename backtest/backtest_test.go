@@ -0,0 +1,148 @@
+package backtest
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"aspen/market"
+	"aspen/mcp"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// replayPrice 测试K线的价格：每根3分钟K线上涨 0.1
+func replayPrice(base, open time.Time) float64 {
+	return 100 + float64(open.Sub(base)/(3*time.Minute))*0.1
+}
+
+// useTestKlines 用按时间生成的K线替换历史K线来源：gap 内没有3分钟K线，missing 中的币种没有任何K线
+func useTestKlines(t *testing.T, base time.Time, gap [2]time.Time, missing string) {
+	prev := fetchKlines
+	t.Cleanup(func() { fetchKlines = prev })
+	fetchKlines = func(source market.DataSource, symbol, interval string, start, end time.Time) ([]market.Kline, error) {
+		if symbol == missing {
+			return nil, nil
+		}
+		step := intervalDuration(interval)
+		var klines []market.Kline
+		for open := start.Truncate(step); open.Before(end); open = open.Add(step) {
+			if open.Before(start) || (interval == "3m" && !open.Before(gap[0]) && open.Before(gap[1])) {
+				continue
+			}
+			p := replayPrice(base, open)
+			klines = append(klines, market.Kline{OpenTime: open.UnixMilli(), Open: p, High: p, Low: p, Close: p, Volume: 10, CloseTime: open.Add(step).UnixMilli() - 1})
+		}
+		return klines, nil
+	}
+}
+
+// newBacktestAIServer 模拟 OpenAI 兼容接口：按调用顺序返回 responses，之后都返回 wait，记录收到的提示词
+func newBacktestAIServer(t *testing.T, responses []string, prompts *[]string) *httptest.Server {
+	var mu sync.Mutex
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		*prompts = append(*prompts, string(body))
+		content := `[{"symbol": "BTCUSDT", "action": "wait", "reasoning": "no setup"}]`
+		if n := len(*prompts); n <= len(responses) {
+			content = responses[n-1]
+		}
+		mu.Unlock()
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"choices": []map[string]interface{}{{"message": map[string]string{"content": "<decision>\n```json\n" + content + "\n```\n</decision>"}}},
+		})
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// TestRun 测试回放历史K线：按回放K线收盘价成交，统计平仓盈亏、胜率和净值曲线，K线缺口和无数据的币种记录警告
+func TestRun(t *testing.T) {
+	base := time.Now().Add(-72 * time.Hour).Truncate(time.Hour)
+	start := base.Add(24 * time.Hour)
+	end := start.Add(2 * time.Hour)
+	gap := [2]time.Time{start.Add(60 * time.Minute), start.Add(90 * time.Minute)}
+	useTestKlines(t, base, gap, "NEWUSDT")
+
+	var prompts []string
+	srv := newBacktestAIServer(t, []string{
+		`[{"symbol": "BTCUSDT", "action": "open_long", "leverage": 5, "position_size_usd": 500, "stop_loss": 1, "take_profit": 100000, "reasoning": "trend"}]`,
+		`[{"symbol": "BTCUSDT", "action": "close_long", "reasoning": "exit"}]`,
+	}, &prompts)
+	client, err := mcp.NewForProvider("custom", "test-key", srv.URL, "test-model")
+	require.NoError(t, err)
+
+	result, err := Run(context.Background(), client, Config{
+		Symbols:        []string{"btc", "NEWUSDT", "btcusdt"},
+		Start:          start,
+		End:            end,
+		Interval:       15 * time.Minute,
+		InitialBalance: 1000,
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"BTCUSDT", "NEWUSDT"}, result.Symbols)
+	assert.Equal(t, 9, result.Cycles)
+	require.Len(t, result.EquityCurve, 9)
+	assert.Len(t, prompts, 8, "K线缺失的周期没有可用行情，不调用AI")
+	assert.Zero(t, result.FailedCycles)
+
+	// 开仓和平仓都按当时最近一根已收盘K线的收盘价成交
+	openPrice := replayPrice(base, start.Add(-3*time.Minute))
+	closePrice := replayPrice(base, start.Add(12*time.Minute))
+	assert.InDelta(t, 500*(closePrice/openPrice-1), result.RealizedPnL, 1e-6)
+	assert.Equal(t, 1, result.TotalTrades)
+	assert.Equal(t, 1, result.WinningTrades)
+	assert.Equal(t, 100.0, result.WinRate)
+	assert.Greater(t, result.TotalFees, 0.0)
+	assert.InDelta(t, 1000+result.RealizedPnL-result.TotalFees, result.FinalEquity, 1e-6)
+	assert.Equal(t, result.FinalEquity, result.EquityCurve[len(result.EquityCurve)-1].Equity)
+	assert.Zero(t, result.OpenPositions)
+	assert.GreaterOrEqual(t, result.MaxDrawdownPct, 0.0)
+
+	require.Len(t, result.Warnings, 2)
+	assert.Contains(t, result.Warnings[0], "NEWUSDT")
+	assert.Contains(t, result.Warnings[1], "BTCUSDT 有 1 个周期因K线缺失被跳过")
+
+	// 历史回放不包含实时资金费率和持仓量
+	for _, prompt := range prompts {
+		assert.NotContains(t, prompt, "Funding Rate:")
+	}
+	assert.True(t, strings.Contains(prompts[0], start.UTC().Format("2006-01-02 15:04")), "提示词使用回放时刻")
+}
+
+// TestConfigNormalize 测试回测参数校验
+func TestConfigNormalize(t *testing.T) {
+	start := time.Now().Add(-48 * time.Hour).Truncate(time.Hour)
+	cfg := Config{Symbols: []string{"eth"}, Start: start, End: start.Add(time.Hour)}
+	require.NoError(t, cfg.Normalize())
+	assert.Equal(t, []string{"ETHUSDT"}, cfg.Symbols)
+	assert.Equal(t, DefaultInterval, cfg.Interval)
+	assert.Equal(t, DefaultInitialBalance, cfg.InitialBalance)
+
+	for name, c := range map[string]Config{
+		"no symbols":       {Start: start, End: start.Add(time.Hour)},
+		"reversed range":   {Symbols: []string{"ETH"}, Start: start, End: start.Add(-time.Hour)},
+		"future end":       {Symbols: []string{"ETH"}, Start: start, End: time.Now().Add(time.Hour)},
+		"odd interval":     {Symbols: []string{"ETH"}, Start: start, End: start.Add(time.Hour), Interval: 5 * time.Minute},
+		"too many cycles":  {Symbols: []string{"ETH"}, Start: start.Add(-30 * 24 * time.Hour), End: start, Interval: 3 * time.Minute},
+		"negative balance": {Symbols: []string{"ETH"}, Start: start, End: start.Add(time.Hour), InitialBalance: -1},
+	} {
+		assert.Error(t, c.Normalize(), name)
+	}
+}
+
+// TestMaxDrawdownPct 测试净值曲线最大回撤（峰值不低于初始资金）
+func TestMaxDrawdownPct(t *testing.T) {
+	curve := []EquityPoint{{Equity: 900}, {Equity: 1200}, {Equity: 900}, {Equity: 1300}}
+	assert.InDelta(t, 25.0, maxDrawdownPct(1000, curve), 1e-9)
+	assert.Zero(t, maxDrawdownPct(1000, nil))
+}
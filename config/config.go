@@ -41,6 +41,7 @@ type Config struct {
 	DefaultCoins       []string       `json:"default_coins"`
 	CoinPoolAPIURL     string         `json:"coin_pool_api_url"`
 	OITopAPIURL        string         `json:"oi_top_api_url"`
+	CoinPoolMinVolume  float64        `json:"coin_pool_min_volume"` // 币种池最小24h成交额（USDT），0 表示不过滤
 	MaxDailyLoss       float64        `json:"max_daily_loss"`
 	MaxDrawdown        float64        `json:"max_drawdown"`
 	StopTradingMinutes int            `json:"stop_trading_minutes"`
@@ -49,6 +50,22 @@ type Config struct {
 	DataKLineTime      string         `json:"data_k_line_time"`
 	MarketDataSource   string         `json:"market_data_source"` // 市场数据源: "binance" (默认), "bybit", "binance_us", "finnhub"
 	FinnhubAPIKey      string         `json:"finnhub_api_key"`    // Finnhub API Key
+	MarketDataFailoverSource    string `json:"market_data_failover_source"`    // 备用市场数据源（如 "bybit"），主数据源连续故障后自动切换、恢复后切回，空表示不切换
+	MarketDataFailoverThreshold int    `json:"market_data_failover_threshold"` // 主数据源连续故障多少次后切换到备用数据源（默认3，单个币种的错误不计入）
+	IntradaySeriesLen  int            `json:"intraday_series_length"` // 提示词中日内序列保留的数据点数（默认10，最多100）
+	DerivativesFallback string        `json:"derivatives_fallback"` // 数据源不提供OI/资金费率时: "omit"(默认，省略并注明不可用) 或 "zero"(按0输出)
+	FundingRateMaxAgeMinutes int       `json:"funding_rate_max_age_minutes"` // 资金费率最大有效期（分钟，默认120），刷新失败沿用的旧值超过时在提示词中标注为过期
+	HideDerivativesOnSpot *bool        `json:"hide_derivatives_on_spot"` // 现货数据源（binance_us、finnhub）是否在提示词中省略永续合约OI/资金费率部分（默认 true，false 时按 derivatives_fallback 处理）
+	PricePrecision     map[string]int `json:"price_precision"`        // 按币种覆盖提示词中的价格小数位数（默认使用交易所精度或动态精度）
+	MinPositionSize    map[string]float64 `json:"min_position_size"` // 按币种覆盖最小开仓金额（USDT，默认按交易所最小名义价值加安全边际）
+	MaxLeverage        map[string]int `json:"max_leverage"`         // 按币种覆盖最大杠杆（默认使用交易所元数据：Hyperliquid maxLeverage、Binance 杠杆分层），AI 给出更高杠杆时收紧
+	KlineGapBackfill   *bool          `json:"kline_gap_backfill"`     // 实时K线流缺失K线时是否通过REST补齐（默认 true，false 时只记录缺口）
+	KlineGapMaxBackfill int           `json:"kline_gap_max_backfill"` // 单个缺口最多补齐的K线根数（默认100，超过时只记录缺口）
+	AILogPrompts       bool           `json:"ai_log_prompts"`      // 以 debug 级别记录完整的AI请求和响应（API Key 和用户标识脱敏，默认关闭；环境变量 AI_LOG_PROMPTS 优先）
+	HTTPMaxIdleConns        int       `json:"http_max_idle_conns"`          // 行情和AI客户端共享连接池最多保留的空闲连接数（默认200）
+	HTTPMaxIdleConnsPerHost int       `json:"http_max_idle_conns_per_host"` // 每个主机最多保留的空闲连接数（默认32）
+	HTTPIdleConnTimeoutSeconds int    `json:"http_idle_conn_timeout_seconds"` // 空闲连接保留时长（秒，默认90）
+	RequestIDHeader    string         `json:"request_id_header"`   // 交易周期请求ID附带在AI和交易所请求中使用的请求头（默认 X-Request-ID）
 	Log                *LogConfig     `json:"log"`                 // 日志配置
 }
 
@@ -10,6 +10,7 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"path/filepath"
 	"slices"
 	"strings"
 	"time"
@@ -37,6 +38,7 @@ type DatabaseInterface interface {
 	UpdateTrader(trader *TraderRecord) error
 	UpdateTraderInitialBalance(userID, id string, newBalance float64) error
 	UpdateTraderCustomPrompt(userID, id string, customPrompt string, overrideBase bool) error
+	UpdateTraderTradingSymbols(userID, id, tradingSymbols string) error
 	DeleteTrader(userID, id string) error
 	GetTraderConfig(userID, traderID string) (*TraderRecord, *AIModelConfig, *ExchangeConfig, error)
 	GetSystemConfig(key string) (string, error)
@@ -46,7 +48,15 @@ type DatabaseInterface interface {
 	UpdateUserSignalSource(userID, coinPoolURL, oiTopURL string) error
 	SavePaperTraderState(traderID string, initialBalance, balance, realizedPnL float64, positions string) error
 	LoadPaperTraderState(traderID string) (initialBalance, balance, realizedPnL float64, positions string, exists bool, err error)
+	SavePaperTraderStateWithLimitOrders(traderID string, initialBalance, balance, realizedPnL float64, positions, limitOrders string) error
+	LoadPaperLimitOrders(traderID string) (string, error)
 	DeletePaperTraderState(traderID string) error
+	RecordAIUsage(traderID, userID, provider, model string, promptTokens, completionTokens int, costUSD float64) error
+	GetAIUsageSummary(traderID string, since time.Time) (*AIUsageSummary, error)
+	CreateShareLink(link *ShareLink) error
+	GetShareLink(slug string) (*ShareLink, error)
+	ListShareLinks(userID, traderID string) ([]*ShareLink, error)
+	RevokeShareLink(userID, traderID, slug string) error
 	GetCustomCoins() []string
 	LoadBetaCodesFromFile(filePath string) error
 	ValidateBetaCode(code string) (bool, error)
@@ -210,6 +220,64 @@ func (d *Database) createTables() error {
 		)`,
 		`CREATE INDEX IF NOT EXISTS idx_token_blacklist_expires ON token_blacklist(expires_at)`,
 
+		// AI调用用量表（按交易员统计Token和成本）
+		`CREATE TABLE IF NOT EXISTS ai_usage (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			trader_id TEXT NOT NULL,
+			user_id TEXT NOT NULL DEFAULT '',
+			provider TEXT NOT NULL DEFAULT '',
+			model TEXT NOT NULL DEFAULT '',
+			prompt_tokens INTEGER NOT NULL DEFAULT 0,
+			completion_tokens INTEGER NOT NULL DEFAULT 0,
+			cost_usd REAL NOT NULL DEFAULT 0,
+			created_at TEXT NOT NULL
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_ai_usage_trader_time ON ai_usage(trader_id, created_at)`,
+		`CREATE INDEX IF NOT EXISTS idx_ai_usage_user_time ON ai_usage(user_id, created_at)`,
+
+		// 币种研究记录表（按需AI分析，不关联交易员）
+		`CREATE TABLE IF NOT EXISTS research_reports (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id TEXT NOT NULL,
+			symbol TEXT NOT NULL,
+			question TEXT NOT NULL DEFAULT '',
+			ai_model_id TEXT NOT NULL DEFAULT '',
+			model TEXT NOT NULL DEFAULT '',
+			analysis TEXT NOT NULL DEFAULT '',
+			summary TEXT NOT NULL DEFAULT '',
+			market_data TEXT NOT NULL DEFAULT '',
+			duration_ms INTEGER NOT NULL DEFAULT 0,
+			created_at TEXT NOT NULL
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_research_reports_user_time ON research_reports(user_id, created_at)`,
+
+		// 交易员公开分享链接表（只读，无需登录访问）
+		`CREATE TABLE IF NOT EXISTS share_links (
+			slug TEXT PRIMARY KEY,
+			trader_id TEXT NOT NULL,
+			user_id TEXT NOT NULL,
+			show_equity BOOLEAN NOT NULL DEFAULT 1,
+			show_stats BOOLEAN NOT NULL DEFAULT 1,
+			show_trades BOOLEAN NOT NULL DEFAULT 0,
+			show_decisions BOOLEAN NOT NULL DEFAULT 0,
+			show_absolute BOOLEAN NOT NULL DEFAULT 0,
+			expires_at TEXT NOT NULL DEFAULT '',
+			revoked_at TEXT NOT NULL DEFAULT '',
+			created_at TEXT NOT NULL
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_share_links_user_trader ON share_links(user_id, trader_id)`,
+
+		// 管理操作审计日志表（本地管理CLI、管理员接口的写操作）
+		`CREATE TABLE IF NOT EXISTS admin_audit_log (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			actor TEXT NOT NULL,
+			action TEXT NOT NULL,
+			target TEXT NOT NULL DEFAULT '',
+			details TEXT NOT NULL DEFAULT '',
+			created_at TEXT NOT NULL
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_admin_audit_log_time ON admin_audit_log(created_at)`,
+
 		// 内测码表
 		`CREATE TABLE IF NOT EXISTS beta_codes (
 			code TEXT PRIMARY KEY,
@@ -281,6 +349,35 @@ func (d *Database) createTables() error {
 		`ALTER TABLE traders ADD COLUMN use_coin_pool BOOLEAN DEFAULT 0`,               // 是否使用COIN POOL信号源
 		`ALTER TABLE traders ADD COLUMN use_oi_top BOOLEAN DEFAULT 0`,                  // 是否使用OI TOP信号源
 		`ALTER TABLE traders ADD COLUMN system_prompt_template TEXT DEFAULT 'hybrid'`, // 系统提示词模板名称
+		`ALTER TABLE traders ADD COLUMN max_symbol_allocation_pct REAL DEFAULT 0`,      // 单币种资金分配上限（0 表示使用系统默认）
+		`ALTER TABLE traders ADD COLUMN symbol_allocation_overrides TEXT DEFAULT ''`,   // 按币种覆盖的分配上限（JSON格式）
+		`ALTER TABLE traders ADD COLUMN margin_asset TEXT DEFAULT 'USDT'`,              // 保证金资产（已有记录默认USDT，保持历史数值口径）
+		`ALTER TABLE traders ADD COLUMN review_model_id TEXT DEFAULT ''`,               // 复核模型（空表示不启用两级模型）
+		`ALTER TABLE traders ADD COLUMN review_triggers TEXT DEFAULT ''`,               // 复核触发条件（JSON格式）
+		`ALTER TABLE traders ADD COLUMN review_fallback TEXT DEFAULT 'hold'`,          // 复核模型不可用时的处理方式（hold/execute）
+		`ALTER TABLE traders ADD COLUMN position_sizing_mode TEXT DEFAULT 'absolute'`, // 仓位大小模式（absolute=按金额，percent=按净值百分比）
+		`ALTER TABLE traders ADD COLUMN position_size_min_pct REAL DEFAULT 0`,         // 百分比模式单笔最小仓位（0 表示默认1%）
+		`ALTER TABLE traders ADD COLUMN position_size_max_pct REAL DEFAULT 0`,         // 百分比模式单笔最大仓位（0 表示默认30%）
+		`ALTER TABLE traders ADD COLUMN data_source TEXT DEFAULT ''`,                  // 行情数据源（空表示使用全局 market_data_source）
+		`ALTER TABLE traders ADD COLUMN hedge_policy TEXT DEFAULT 'no_hedge'`,         // 对冲策略（no_hedge=有反向持仓时拒绝开仓，allow_flip=先平反向持仓再开仓）
+		`ALTER TABLE traders ADD COLUMN indicator_params TEXT DEFAULT ''`,             // 指标周期（JSON格式，空表示全部使用默认周期）
+		`ALTER TABLE traders ADD COLUMN manage_only BOOLEAN DEFAULT 0`,                // 只管理持仓模式（不再开新仓，平仓和止盈止损照常）
+		`ALTER TABLE traders ADD COLUMN hard_drawdown_pct REAL DEFAULT 0`,             // 硬回撤上限（%），触发后清仓并永久停用，0 表示不启用
+		`ALTER TABLE traders ADD COLUMN disabled_reason TEXT DEFAULT ''`,              // 停用原因（非空表示已停用，需手动重置后才能启动）
+		`ALTER TABLE traders ADD COLUMN hard_drawdown_peak REAL DEFAULT 0`,            // 硬回撤计算的最高净值（0 表示尚未记录）
+		`ALTER TABLE traders ADD COLUMN hard_drawdown_rebase BOOLEAN DEFAULT 0`,       // 手动重置后以下一次的净值作为新的最高净值（不以初始资金为下限）
+		`ALTER TABLE traders ADD COLUMN min_equity REAL DEFAULT 0`,                    // 账户净值下限（绝对值），低于时禁止开仓，0 表示不限制
+		`ALTER TABLE traders ADD COLUMN paper_realism TEXT DEFAULT ''`,                // 模拟仓真实度档位（optimistic/realistic/pessimistic，空表示使用系统配置）
+		`ALTER TABLE traders ADD COLUMN paper_realism_overrides TEXT DEFAULT ''`,      // 模拟仓手续费/滑点/成交模式单项覆盖（JSON格式）
+		`ALTER TABLE traders ADD COLUMN coin_pool_max_symbols INTEGER DEFAULT 0`,      // 使用币种池时最多保留的币种数量，0 表示不限制
+		`ALTER TABLE traders ADD COLUMN coin_pool_rank_by TEXT DEFAULT ''`,            // 币种池超出上限时的排序依据（volume/oi，空为 volume）
+		`ALTER TABLE traders ADD COLUMN risk_peak_equity REAL DEFAULT 0`,              // 动态风控回撤计算的净值峰值（0 表示尚未记录）
+		`ALTER TABLE traders ADD COLUMN day_start_equity REAL DEFAULT 0`,              // 当日（UTC）起始净值（0 表示尚未记录）
+		`ALTER TABLE traders ADD COLUMN day_start_date TEXT DEFAULT ''`,               // 当日起始净值对应的 UTC 日期（YYYY-MM-DD）
+		`ALTER TABLE paper_trader_state ADD COLUMN limit_orders TEXT DEFAULT '[]'`,    // 模拟仓未成交的限价挂单（JSON格式）
+		`ALTER TABLE users ADD COLUMN max_concurrent_positions INTEGER DEFAULT 0`,     // 用户所有交易员合计最大同时持仓数（0 表示不限制）
+		`ALTER TABLE users ADD COLUMN webhook_url TEXT DEFAULT ''`,                    // 交易事件 Webhook 地址（为空不推送）
+		`ALTER TABLE users ADD COLUMN webhook_secret TEXT DEFAULT ''`,                 // Webhook HMAC 签名密钥
 		`ALTER TABLE ai_models ADD COLUMN custom_api_url TEXT DEFAULT ''`,              // 自定义API地址
 		`ALTER TABLE ai_models ADD COLUMN custom_model_name TEXT DEFAULT ''`,           // 自定义模型名称
 	}
@@ -332,7 +429,7 @@ func (d *Database) initDefaultData() error {
 
 	for _, exchange := range exchanges {
 		if exchange.id == "paper" {
-			// 模拟仓需要设置初始USDC金额
+			// 模拟仓需要设置初始金额（以交易员的保证金资产计，列名沿用历史的 USDC）
 			initialUSDC := 10000.0
 			_, err := d.db.Exec(`
 				INSERT OR IGNORE INTO exchanges (id, user_id, name, type, enabled, paper_trading_initial_usdc) 
@@ -361,6 +458,65 @@ func (d *Database) initDefaultData() error {
 		"max_daily_loss":       "10.0",                                                                                // 最大日损失百分比
 		"max_drawdown":         "20.0",                                                                                // 最大回撤百分比
 		"stop_trading_minutes": "60",                                                                                  // 停止交易时间（分钟）
+		"coin_pool_min_volume": "0",                                                                                   // 币种池最小24h成交额（USDT），0 表示不过滤
+		"max_trading_symbols":    "20",    // 每个交易员最多可配置的交易币种数量
+		"max_traders_per_user":   "20",    // 每个用户最多可创建的交易员数量（管理员不限制），0 表示不限制
+		"trading_symbols_strict": "false", // 交易币种在数据源不可用时：true=拒绝保存，false=移除并提示
+		"max_symbol_allocation_pct": "0", // 单币种默认最多占用净值的百分比（按保证金计算），0 表示不限制（默认关闭，需要时设置）
+		"paper_price_impact_enabled":     "false", // 模拟仓大单价格冲击模型（按订单占近期成交额比例恶化成交价）
+		"paper_price_impact_coefficient": "0.1",   // 价格冲击系数
+		"paper_price_impact_max_pct":     "2",     // 单笔最大价格冲击百分比
+		"paper_fill_mode":     "instant", // 模拟仓成交价：instant=实时价格，next_candle_open=回测按下一根K线开盘价、实盘按成交延迟后的实时价格
+		"paper_fill_interval": "3m",      // next_candle_open 模式回测使用的K线周期
+		"paper_fill_latency_ms": "1000",  // next_candle_open 模式实盘模拟仓的成交延迟（毫秒，上限5000）
+		"paper_position_mode": "hedge",   // 模拟仓持仓模式：hedge=双向持仓，one_way=单向持仓（反向开仓先抵消现有持仓）
+		"stablecoin_peg_check_enabled":     "false",    // 稳定币脱锚保护：报价稳定币偏离锚定超过阈值时禁止新开仓
+		"stablecoin_peg_symbol":            "USDCUSDT", // 用于检测锚定的稳定币交易对
+		"stablecoin_peg_max_deviation_pct": "0.5",      // 允许偏离 1 的最大百分比
+		"data_outage_max_age_seconds":      "0",        // 行情中断保护：行情超过该秒数未更新时视为中断，0 表示不检查
+		"data_outage_action":               "block_opens", // 行情中断时的处理：block_opens（只禁止开仓）、flatten（禁止开仓并清仓）
+		"max_spread_bps":                   "0",        // 开仓前允许的最大买卖价差（bps），0 表示不检查
+		"cycle_align_timeframe":            "",         // 周期对齐：定时周期在该K线周期（如 3m）收盘后执行，空值表示按扫描间隔固定计时
+		"cycle_align_delay_seconds":        "2",        // 周期对齐时在K线收盘后延迟的秒数（等待收盘K线写入行情缓存）
+		"ai_call_budget_seconds":           "120",      // 单周期AI调用时间预算（秒，含获取市场数据和重试），应小于扫描间隔
+		"prompt_token_budget":              "0",        // 提示词估算 token 预算（系统+用户提示词），超出时发送前裁剪次要市场数据分段，0 表示不限制
+		"churn_guard_enabled":              "false",    // 防反复开平仓：短期内对同一币种同方向再次开仓需更高信心度
+		"churn_guard_cycles":               "3",        // 防反复开平仓检查的周期窗口
+		"churn_guard_min_confidence":       "85",       // 窗口内允许再次开仓所需的最低信心度
+		"entry_confirmation_candles":       "0",        // 开仓确认K线：开仓方向的指标信号需持续的已收盘3分钟K线根数，不足时推迟开仓，0 表示不检查
+		"entry_confirmation_signal":        "sar",      // 开仓确认使用的指标信号：sar、zero_lag、range、ema（价格相对快速EMA）
+		"min_bars_between_trades":          "0",        // 最少间隔K线：同一币种上次开平仓后需过去的3分钟K线根数才能再次开平仓，0 表示不检查
+		"quote_quantity_orders":            "false",    // 按金额下单：开仓按仓位金额下单，价格波动只影响成交数量（目前仅模拟仓支持，其他交易所仍按数量下单）
+		"loss_cooldown_loss_pct":           "0",        // 亏损后降仓冷却：单笔平仓亏损收益率达到该值（%）后缩小之后的开仓仓位，0 表示不启用
+		"loss_cooldown_size_factor":        "0.5",      // 冷却期间的仓位系数（0-1）
+		"loss_cooldown_minutes":            "60",       // 冷却时长（分钟），0 表示不按时间结束
+		"loss_cooldown_trades":             "0",        // 冷却持续的开仓笔数，0 表示不按笔数结束（都配置时先到者结束）
+		"confidence_sizing_min_notional":   "0",        // 信心度仓位：信心度 0 对应的开仓仓位金额，0 表示不启用（启用后开仓仓位按AI信心度在最小和最大金额之间取值）
+		"confidence_sizing_max_notional":   "0",        // 信心度 100 对应的开仓仓位金额（需不小于最小金额）
+		"confidence_sizing_exponent":       "1",        // 信心度曲线指数：1 为线性（信心度 50 对应中点），大于 1 时低信心度仓位更小
+		"prompt_cache_tolerance_pct":       "0",        // 提示词缓存：各币种价格相对上次AI调用的变化都不超过该值（%）且持仓不变时复用上次决策，0 表示不启用
+		"prompt_cache_max_age_minutes":     "15",       // 同一个决策最长复用时长（分钟），0 表示不限制
+		"intent_dedup_window_minutes":      "15",       // 重复开仓意图检查窗口（分钟）：窗口内已成交且未平仓的相同开仓（币种、方向、仓位大小和入场价格相近）被拒绝，0 表示不检查
+		"halted_zero_volume_candles":       "5",        // 停牌检测：最近连续这么多根3分钟K线成交量为 0 的币种视为停牌，本周期跳过（不给AI行情、不执行其决策），0 表示不检查
+		"reconcile_positions_on_start":     "true",     // 实盘交易员启动时按交易所实际持仓覆盖本地持仓状态并记录差异（模拟仓不对账）
+		"trading_blackout_windows":         "[]",       // 交易暂停窗口（JSON数组，UTC，如 [{"start":"2026-01-15T13:00:00Z","end":"2026-01-15T14:00:00Z","label":"CPI"}]），窗口内禁止新开仓
+		"trading_blackout_flatten":         "false",    // 进入交易暂停窗口时是否平掉所有持仓
+		"dust_position_threshold_usd":      "0",        // 粉尘仓位阈值（USD）：持仓名义价值低于该值时下一周期自动平仓，0 表示不处理
+		"stale_order_max_age_minutes":      "60",       // 挂单最长保留时间（分钟）：超过该时长未成交的限价挂单在下一周期自动撤单并释放冻结资金，0 表示不处理
+		"anomaly_detection_enabled":        "false",    // 交易员行为异常检测（失败率/开仓占比/仓位大小/手续费占比与自身历史基线比较）
+		"anomaly_baseline_cycles":          "200",      // 异常检测基线窗口周期数
+		"anomaly_recent_cycles":            "20",       // 异常检测近期窗口周期数
+		"anomaly_sensitivity":              "1",        // 异常检测灵敏度（越大阈值越低，越容易触发）
+		"slippage_warning_bps":             "20",       // 平均成交滑点（bps）超过该值时在交易员详情中提示，0 表示不提示
+		"metrics_bearer_token":             "",         // /metrics 抓取 Bearer Token（环境变量 METRICS_BEARER_TOKEN 优先），为空不校验
+		"metrics_basic_auth":               "",         // /metrics 抓取 Basic Auth，格式 user:password（环境变量 METRICS_BASIC_AUTH 优先）
+		"metrics_allowed_cidrs":            "",         // 允许抓取 /metrics 的来源网段，逗号分隔，为空不限制
+		"metrics_label_mode":               "full",     // 指标 trader_id 标签粒度：full（私有部署）/ hashed / bucketed（共享部署）
+		"metrics_label_salt":               "",         // hashed/bucketed 模式的哈希盐
+		"risk_scale_reduce_until":        "0.5", // 动态风控：亏损达到上限该比例时缩放到最低系数
+		"risk_scale_min_size_factor":     "0.5", // 动态风控：最低仓位系数
+		"risk_scale_min_leverage_factor": "0.5", // 动态风控：最低杠杆系数
+		"risk_scale_block_entry_at":      "0.8", // 动态风控：亏损达到上限该比例后禁止开仓
 		"btc_eth_leverage":     "5",                                                                                   // BTC/ETH杠杆倍数
 		"altcoin_leverage":     "5",                                                                                   // 山寨币杠杆倍数
 		"jwt_secret":           "",                                                                                    // JWT密钥，默认为空，由config.json或系统生成
@@ -505,32 +661,57 @@ type ExchangeConfig struct {
 	AsterSigner     string    `json:"asterSigner"`
 	AsterPrivateKey string    `json:"asterPrivateKey"`
 	// Paper Trading 特定字段
-	PaperTradingInitialUSDC float64 `json:"paperTradingInitialUSDC"` // 模拟仓初始USDC金额
+	PaperTradingInitialUSDC float64 `json:"paperTradingInitialUSDC"` // 模拟仓初始金额（以交易员的保证金资产 USDT/USDC 计）
 	CreatedAt               time.Time `json:"created_at"`
 	UpdatedAt               time.Time `json:"updated_at"`
 }
 
 // TraderRecord 交易员配置（数据库实体）
 type TraderRecord struct {
-	ID                   string    `json:"id"`
-	UserID               string    `json:"user_id"`
-	Name                 string    `json:"name"`
-	AIModelID            string    `json:"ai_model_id"`
-	ExchangeID           string    `json:"exchange_id"`
-	InitialBalance       float64   `json:"initial_balance"`
-	ScanIntervalMinutes  int       `json:"scan_interval_minutes"`
-	IsRunning            bool      `json:"is_running"`
-	BTCETHLeverage       int       `json:"btc_eth_leverage"`       // BTC/ETH杠杆倍数
-	AltcoinLeverage      int       `json:"altcoin_leverage"`       // 山寨币杠杆倍数
-	TradingSymbols       string    `json:"trading_symbols"`        // 交易币种，逗号分隔
-	UseCoinPool          bool      `json:"use_coin_pool"`          // 是否使用COIN POOL信号源
-	UseOITop             bool      `json:"use_oi_top"`             // 是否使用OI TOP信号源
-	CustomPrompt         string    `json:"custom_prompt"`          // 自定义交易策略prompt
-	OverrideBasePrompt   bool      `json:"override_base_prompt"`   // 是否覆盖基础prompt
-	SystemPromptTemplate string    `json:"system_prompt_template"` // 系统提示词模板名称
-	IsCrossMargin        bool      `json:"is_cross_margin"`        // 是否为全仓模式（true=全仓，false=逐仓）
-	CreatedAt            time.Time `json:"created_at"`
-	UpdatedAt            time.Time `json:"updated_at"`
+	ID                        string    `json:"id"`
+	UserID                    string    `json:"user_id"`
+	Name                      string    `json:"name"`
+	AIModelID                 string    `json:"ai_model_id"`
+	ExchangeID                string    `json:"exchange_id"`
+	InitialBalance            float64   `json:"initial_balance"`
+	ScanIntervalMinutes       int       `json:"scan_interval_minutes"`
+	IsRunning                 bool      `json:"is_running"`
+	BTCETHLeverage            int       `json:"btc_eth_leverage"`            // BTC/ETH杠杆倍数
+	AltcoinLeverage           int       `json:"altcoin_leverage"`            // 山寨币杠杆倍数
+	TradingSymbols            string    `json:"trading_symbols"`             // 交易币种，逗号分隔
+	UseCoinPool               bool      `json:"use_coin_pool"`               // 是否使用COIN POOL信号源
+	UseOITop                  bool      `json:"use_oi_top"`                  // 是否使用OI TOP信号源
+	CustomPrompt              string    `json:"custom_prompt"`               // 自定义交易策略prompt
+	OverrideBasePrompt        bool      `json:"override_base_prompt"`        // 是否覆盖基础prompt
+	SystemPromptTemplate      string    `json:"system_prompt_template"`      // 系统提示词模板名称
+	IsCrossMargin             bool      `json:"is_cross_margin"`             // 是否为全仓模式（true=全仓，false=逐仓）
+	MaxSymbolAllocationPct    float64   `json:"max_symbol_allocation_pct"`   // 单币种资金分配上限百分比（0 表示使用系统默认）
+	SymbolAllocationOverrides string    `json:"symbol_allocation_overrides"` // 按币种覆盖的分配上限（JSON格式，如 {"BTCUSDT":60}）
+	MarginAsset               string    `json:"margin_asset"`                // 保证金资产（USDT/USDC）
+	ReviewModelID             string    `json:"review_model_id"`             // 复核模型ID（ai_model_id 为扫描模型，空表示不复核）
+	ReviewTriggers            string    `json:"review_triggers"`             // 复核触发条件（JSON格式，如 {"open":true,"size_over_usd":500}）
+	ReviewFallback            string    `json:"review_fallback"`             // 复核模型不可用时的处理方式（hold/execute）
+	PositionSizingMode        string    `json:"position_sizing_mode"`        // 仓位大小模式（absolute/percent）
+	PositionSizeMinPct        float64   `json:"position_size_min_pct"`       // 百分比模式单笔最小仓位（占净值%，0 表示默认）
+	PositionSizeMaxPct        float64   `json:"position_size_max_pct"`       // 百分比模式单笔最大仓位（占净值%，0 表示默认）
+	DataSource                string    `json:"data_source"`                 // 行情数据源（binance/bybit/...，空表示使用全局数据源）
+	HedgePolicy               string    `json:"hedge_policy"`                // 对冲策略（no_hedge/allow_flip）
+	IndicatorParams           string    `json:"indicator_params"`            // 指标周期（JSON格式，如 {"rsi_short":14}，空表示默认周期）
+	ManageOnly                bool      `json:"manage_only"`                 // 只管理持仓模式（拒绝开仓和加仓，平仓和止盈止损照常）
+	HardDrawdownPct           float64   `json:"hard_drawdown_pct"`           // 硬回撤上限（净值自最高点回撤%），触发后清仓并永久停用，0 表示不启用
+	DisabledReason            string    `json:"disabled_reason"`             // 停用原因（非空表示已因硬回撤停用，需手动重置）
+	HardDrawdownPeak          float64   `json:"-"`                           // 硬回撤计算的最高净值（0 表示尚未记录），进程重启后沿用
+	HardDrawdownRebase        bool      `json:"-"`                           // 手动重置后尚未记录新的最高净值（下一次的净值作为最高净值）
+	MinEquity                 float64   `json:"min_equity"`                  // 账户净值下限（保证金资产计价的绝对值），低于时禁止开仓，0 表示不限制
+	PaperRealism              string    `json:"paper_realism"`               // 模拟仓真实度档位（optimistic/realistic/pessimistic，空表示使用系统配置）
+	PaperRealismOverrides     string    `json:"paper_realism_overrides"`     // 模拟仓单项覆盖（JSON格式，如 {"fee_pct":0.02}，优先于档位）
+	CoinPoolMaxSymbols        int       `json:"coin_pool_max_symbols"`       // 使用币种池（AI500+OI Top）时最多保留的币种数量，0 表示不限制
+	CoinPoolRankBy            string    `json:"coin_pool_rank_by"`           // 币种池超出上限时的排序依据（volume=24h成交额，oi=持仓价值，空为 volume）
+	RiskPeakEquity            float64   `json:"-"`                           // 动态风控回撤计算的净值峰值（0 表示尚未记录），进程重启后沿用
+	DayStartEquity            float64   `json:"-"`                           // 当日（UTC）起始净值（0 表示尚未记录），同一天内重启后沿用
+	DayStartDate              string    `json:"-"`                           // 当日起始净值对应的 UTC 日期（YYYY-MM-DD）
+	CreatedAt                 time.Time `json:"created_at"`
+	UpdatedAt                 time.Time `json:"updated_at"`
 }
 
 // UserSignalSource 用户信号源配置
@@ -645,12 +826,69 @@ func (d *Database) UpdateUserOTPVerified(userID string, verified bool) error {
 	return err
 }
 
+// ResetUserOTP 为用户更换OTP密钥（丢失验证器时由管理员重置，用户用新密钥即可登录）
+func (d *Database) ResetUserOTP(userID, otpSecret string) error {
+	result, err := d.db.Exec(`UPDATE users SET otp_secret = ?, otp_verified = 1 WHERE id = ?`, otpSecret, userID)
+	if err != nil {
+		return err
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// GetUserMaxConcurrentPositions 获取用户所有交易员合计的最大同时持仓数（0 表示不限制）
+func (d *Database) GetUserMaxConcurrentPositions(userID string) (int, error) {
+	var limit int
+	err := d.db.QueryRow(`SELECT COALESCE(max_concurrent_positions, 0) FROM users WHERE id = ?`, userID).Scan(&limit)
+	return limit, err
+}
+
+// UpdateUserMaxConcurrentPositions 设置用户所有交易员合计的最大同时持仓数（0 表示不限制）
+func (d *Database) UpdateUserMaxConcurrentPositions(userID string, limit int) error {
+	result, err := d.db.Exec(`
+		UPDATE users
+		SET max_concurrent_positions = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ?
+	`, limit, userID)
+	if err != nil {
+		return err
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
 // UpdateUserLastActive 更新用户最后活跃时间
 func (d *Database) UpdateUserLastActive(userID string) error {
 	_, err := d.db.Exec(`UPDATE users SET last_active_at = CURRENT_TIMESTAMP WHERE id = ?`, userID)
 	return err
 }
 
+// GetUserWebhook 获取用户的交易事件 Webhook 地址和签名密钥
+func (d *Database) GetUserWebhook(userID string) (url, secret string, err error) {
+	err = d.db.QueryRow(`SELECT COALESCE(webhook_url, ''), COALESCE(webhook_secret, '') FROM users WHERE id = ?`, userID).Scan(&url, &secret)
+	return url, secret, err
+}
+
+// UpdateUserWebhook 设置用户的交易事件 Webhook（地址为空表示关闭推送）
+func (d *Database) UpdateUserWebhook(userID, url, secret string) error {
+	result, err := d.db.Exec(`
+		UPDATE users
+		SET webhook_url = ?, webhook_secret = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ?
+	`, url, secret, userID)
+	if err != nil {
+		return err
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
 // UserStats 用户统计信息
 type UserStats struct {
 	TotalUsers          int `json:"total_users"`
@@ -1019,15 +1257,53 @@ func (d *Database) CreateExchange(userID, id, name, typ string, enabled bool, ap
 
 // CreateTrader 创建交易员
 func (d *Database) CreateTrader(trader *TraderRecord) error {
+	marginAsset := trader.MarginAsset
+	if marginAsset == "" {
+		marginAsset = "USDT"
+	}
+	reviewFallback := trader.ReviewFallback
+	if reviewFallback == "" {
+		reviewFallback = "hold"
+	}
+	sizingMode := trader.PositionSizingMode
+	if sizingMode == "" {
+		sizingMode = "absolute"
+	}
+	hedgePolicy := trader.HedgePolicy
+	if hedgePolicy == "" {
+		hedgePolicy = "no_hedge"
+	}
 	_, err := d.db.Exec(`
-		INSERT INTO traders (id, user_id, name, ai_model_id, exchange_id, initial_balance, scan_interval_minutes, is_running, btc_eth_leverage, altcoin_leverage, trading_symbols, use_coin_pool, use_oi_top, custom_prompt, override_base_prompt, system_prompt_template, is_cross_margin)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
-	`, trader.ID, trader.UserID, trader.Name, trader.AIModelID, trader.ExchangeID, trader.InitialBalance, trader.ScanIntervalMinutes, trader.IsRunning, trader.BTCETHLeverage, trader.AltcoinLeverage, trader.TradingSymbols, trader.UseCoinPool, trader.UseOITop, trader.CustomPrompt, trader.OverrideBasePrompt, trader.SystemPromptTemplate, trader.IsCrossMargin)
+		INSERT INTO traders (id, user_id, name, ai_model_id, exchange_id, initial_balance, scan_interval_minutes, is_running, btc_eth_leverage, altcoin_leverage, trading_symbols, use_coin_pool, use_oi_top, custom_prompt, override_base_prompt, system_prompt_template, is_cross_margin, max_symbol_allocation_pct, symbol_allocation_overrides, margin_asset, review_model_id, review_triggers, review_fallback, position_sizing_mode, position_size_min_pct, position_size_max_pct, data_source, hedge_policy, indicator_params, manage_only, hard_drawdown_pct, min_equity, paper_realism, paper_realism_overrides, coin_pool_max_symbols, coin_pool_rank_by)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, trader.ID, trader.UserID, trader.Name, trader.AIModelID, trader.ExchangeID, trader.InitialBalance, trader.ScanIntervalMinutes, trader.IsRunning, trader.BTCETHLeverage, trader.AltcoinLeverage, trader.TradingSymbols, trader.UseCoinPool, trader.UseOITop, trader.CustomPrompt, trader.OverrideBasePrompt, trader.SystemPromptTemplate, trader.IsCrossMargin, trader.MaxSymbolAllocationPct, trader.SymbolAllocationOverrides, marginAsset, trader.ReviewModelID, trader.ReviewTriggers, reviewFallback, sizingMode, trader.PositionSizeMinPct, trader.PositionSizeMaxPct, trader.DataSource, hedgePolicy, trader.IndicatorParams, trader.ManageOnly, trader.HardDrawdownPct, trader.MinEquity, trader.PaperRealism, trader.PaperRealismOverrides, trader.CoinPoolMaxSymbols, trader.CoinPoolRankBy)
 	return err
 }
 
+// TraderFilter 交易员列表过滤条件（空字段不过滤）
+type TraderFilter struct {
+	ExchangeID string
+	AIModelID  string
+}
+
 // GetTraders 获取用户的交易员
 func (d *Database) GetTraders(userID string) ([]*TraderRecord, error) {
+	return d.GetTradersFiltered(userID, TraderFilter{})
+}
+
+// GetTradersFiltered 按交易所和AI模型过滤用户的交易员
+func (d *Database) GetTradersFiltered(userID string, filter TraderFilter) ([]*TraderRecord, error) {
+	where := "user_id = ?"
+	args := []interface{}{userID}
+	if filter.ExchangeID != "" {
+		where += " AND exchange_id = ?"
+		args = append(args, filter.ExchangeID)
+	}
+	if filter.AIModelID != "" {
+		where += " AND ai_model_id = ?"
+		args = append(args, filter.AIModelID)
+	}
+
 	rows, err := d.db.Query(`
 		SELECT id, user_id, name, ai_model_id, exchange_id, initial_balance, scan_interval_minutes, is_running,
 		       COALESCE(btc_eth_leverage, 5) as btc_eth_leverage, COALESCE(altcoin_leverage, 5) as altcoin_leverage,
@@ -1035,9 +1311,35 @@ func (d *Database) GetTraders(userID string) ([]*TraderRecord, error) {
 		       COALESCE(use_coin_pool, 0) as use_coin_pool, COALESCE(use_oi_top, 0) as use_oi_top,
 		       COALESCE(custom_prompt, '') as custom_prompt, COALESCE(override_base_prompt, 0) as override_base_prompt,
 		       COALESCE(system_prompt_template, 'hybrid') as system_prompt_template,
-		       COALESCE(is_cross_margin, 1) as is_cross_margin, created_at, updated_at
-		FROM traders WHERE user_id = ? ORDER BY created_at DESC
-	`, userID)
+		       COALESCE(is_cross_margin, 1) as is_cross_margin,
+		       COALESCE(max_symbol_allocation_pct, 0) as max_symbol_allocation_pct,
+		       COALESCE(symbol_allocation_overrides, '') as symbol_allocation_overrides,
+		       COALESCE(NULLIF(margin_asset, ''), 'USDT') as margin_asset,
+		       COALESCE(review_model_id, '') as review_model_id,
+		       COALESCE(review_triggers, '') as review_triggers,
+		       COALESCE(NULLIF(review_fallback, ''), 'hold') as review_fallback,
+		       COALESCE(NULLIF(position_sizing_mode, ''), 'absolute') as position_sizing_mode,
+		       COALESCE(position_size_min_pct, 0) as position_size_min_pct,
+		       COALESCE(position_size_max_pct, 0) as position_size_max_pct,
+		       COALESCE(data_source, '') as data_source,
+		       COALESCE(NULLIF(hedge_policy, ''), 'no_hedge') as hedge_policy,
+		       COALESCE(indicator_params, '') as indicator_params,
+		       COALESCE(manage_only, 0) as manage_only,
+		       COALESCE(hard_drawdown_pct, 0) as hard_drawdown_pct,
+		       COALESCE(disabled_reason, '') as disabled_reason,
+		       COALESCE(hard_drawdown_peak, 0) as hard_drawdown_peak,
+		       COALESCE(hard_drawdown_rebase, 0) as hard_drawdown_rebase,
+		       COALESCE(min_equity, 0) as min_equity,
+		       COALESCE(paper_realism, '') as paper_realism,
+		       COALESCE(paper_realism_overrides, '') as paper_realism_overrides,
+		       COALESCE(coin_pool_max_symbols, 0) as coin_pool_max_symbols,
+		       COALESCE(coin_pool_rank_by, '') as coin_pool_rank_by,
+		       COALESCE(risk_peak_equity, 0) as risk_peak_equity,
+		       COALESCE(day_start_equity, 0) as day_start_equity,
+		       COALESCE(day_start_date, '') as day_start_date,
+		       created_at, updated_at
+		FROM traders WHERE `+where+` ORDER BY created_at DESC
+	`, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -1053,6 +1355,14 @@ func (d *Database) GetTraders(userID string) ([]*TraderRecord, error) {
 			&trader.UseCoinPool, &trader.UseOITop,
 			&trader.CustomPrompt, &trader.OverrideBasePrompt, &trader.SystemPromptTemplate,
 			&trader.IsCrossMargin,
+			&trader.MaxSymbolAllocationPct, &trader.SymbolAllocationOverrides, &trader.MarginAsset,
+			&trader.ReviewModelID, &trader.ReviewTriggers, &trader.ReviewFallback,
+			&trader.PositionSizingMode, &trader.PositionSizeMinPct, &trader.PositionSizeMaxPct,
+			&trader.DataSource, &trader.HedgePolicy, &trader.IndicatorParams, &trader.ManageOnly,
+			&trader.HardDrawdownPct, &trader.DisabledReason, &trader.HardDrawdownPeak, &trader.HardDrawdownRebase, &trader.MinEquity,
+			&trader.PaperRealism, &trader.PaperRealismOverrides,
+			&trader.CoinPoolMaxSymbols, &trader.CoinPoolRankBy,
+			&trader.RiskPeakEquity, &trader.DayStartEquity, &trader.DayStartDate,
 			&trader.CreatedAt, &trader.UpdatedAt,
 		)
 		if err != nil {
@@ -1064,12 +1374,51 @@ func (d *Database) GetTraders(userID string) ([]*TraderRecord, error) {
 	return traders, nil
 }
 
+// GetTraderOwner 获取交易员所属用户ID
+func (d *Database) GetTraderOwner(traderID string) (string, error) {
+	var userID string
+	err := d.db.QueryRow(`SELECT user_id FROM traders WHERE id = ?`, traderID).Scan(&userID)
+	return userID, err
+}
+
 // UpdateTraderStatus 更新交易员状态
 func (d *Database) UpdateTraderStatus(userID, id string, isRunning bool) error {
 	_, err := d.db.Exec(`UPDATE traders SET is_running = ? WHERE id = ? AND user_id = ?`, isRunning, id, userID)
 	return err
 }
 
+// DisableTrader 停用交易员（如触发硬回撤上限）：标记为未运行并记录原因，重置前不能启动
+func (d *Database) DisableTrader(userID, id, reason string) error {
+	_, err := d.db.Exec(`UPDATE traders SET is_running = 0, disabled_reason = ? WHERE id = ? AND user_id = ?`, reason, id, userID)
+	return err
+}
+
+// SaveHardDrawdownPeak 保存硬回撤计算的最高净值（同时结束重置后的重新计算状态）
+func (d *Database) SaveHardDrawdownPeak(userID, id string, peak float64) error {
+	_, err := d.db.Exec(`UPDATE traders SET hard_drawdown_peak = ?, hard_drawdown_rebase = 0 WHERE id = ? AND user_id = ?`, peak, id, userID)
+	return err
+}
+
+// SaveRiskEquity 保存动态风控的净值峰值和当日（UTC）起始净值，进程重启后按同一基准计算回撤和当日盈亏
+func (d *Database) SaveRiskEquity(userID, id string, peak, dayStart float64, dayStartDate string) error {
+	_, err := d.db.Exec(`UPDATE traders SET risk_peak_equity = ?, day_start_equity = ?, day_start_date = ? WHERE id = ? AND user_id = ?`,
+		peak, dayStart, dayStartDate, id, userID)
+	return err
+}
+
+// ResetTraderDisabled 清除交易员的停用状态（允许再次启动），并清除硬回撤的最高净值：
+// 之后以下一次的净值作为新的最高净值，进程重启后也不会按旧的最高净值或初始资金立即再次触发
+func (d *Database) ResetTraderDisabled(userID, id string) error {
+	result, err := d.db.Exec(`UPDATE traders SET disabled_reason = '', hard_drawdown_peak = 0, hard_drawdown_rebase = 1 WHERE id = ? AND user_id = ?`, id, userID)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("交易员 %s 不存在", id)
+	}
+	return nil
+}
+
 // UpdateTrader 更新交易员配置
 func (d *Database) UpdateTrader(trader *TraderRecord) error {
 	_, err := d.db.Exec(`
@@ -1077,21 +1426,71 @@ func (d *Database) UpdateTrader(trader *TraderRecord) error {
 			name = ?, ai_model_id = ?, exchange_id = ?, initial_balance = ?,
 			scan_interval_minutes = ?, btc_eth_leverage = ?, altcoin_leverage = ?,
 			trading_symbols = ?, custom_prompt = ?, override_base_prompt = ?,
-			system_prompt_template = ?, is_cross_margin = ?, updated_at = CURRENT_TIMESTAMP
+			system_prompt_template = ?, is_cross_margin = ?,
+			max_symbol_allocation_pct = ?, symbol_allocation_overrides = ?,
+			margin_asset = COALESCE(NULLIF(?, ''), margin_asset),
+			review_model_id = ?, review_triggers = ?, review_fallback = COALESCE(NULLIF(?, ''), review_fallback),
+			position_sizing_mode = COALESCE(NULLIF(?, ''), position_sizing_mode),
+			position_size_min_pct = ?, position_size_max_pct = ?, data_source = ?,
+			hedge_policy = COALESCE(NULLIF(?, ''), hedge_policy),
+			indicator_params = ?, manage_only = ?, hard_drawdown_pct = ?, min_equity = ?,
+			paper_realism = ?, paper_realism_overrides = ?,
+			coin_pool_max_symbols = ?, coin_pool_rank_by = ?,
+			updated_at = CURRENT_TIMESTAMP
 		WHERE id = ? AND user_id = ?
 	`, trader.Name, trader.AIModelID, trader.ExchangeID, trader.InitialBalance,
 		trader.ScanIntervalMinutes, trader.BTCETHLeverage, trader.AltcoinLeverage,
 		trader.TradingSymbols, trader.CustomPrompt, trader.OverrideBasePrompt,
-		trader.SystemPromptTemplate, trader.IsCrossMargin, trader.ID, trader.UserID)
+		trader.SystemPromptTemplate, trader.IsCrossMargin,
+		trader.MaxSymbolAllocationPct, trader.SymbolAllocationOverrides, trader.MarginAsset,
+		trader.ReviewModelID, trader.ReviewTriggers, trader.ReviewFallback,
+		trader.PositionSizingMode, trader.PositionSizeMinPct, trader.PositionSizeMaxPct, trader.DataSource, trader.HedgePolicy, trader.IndicatorParams, trader.ManageOnly, trader.HardDrawdownPct, trader.MinEquity, trader.PaperRealism, trader.PaperRealismOverrides, trader.CoinPoolMaxSymbols, trader.CoinPoolRankBy, trader.ID, trader.UserID)
 	return err
 }
 
+// UpdateTradersLeverage 在一个事务中更新用户多个交易员的杠杆倍数（值为 0 时保持原值），任一交易员不存在时全部回滚
+func (d *Database) UpdateTradersLeverage(userID string, ids []string, btcEthLeverage, altcoinLeverage int) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return fmt.Errorf("开始事务失败: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`
+		UPDATE traders SET
+			btc_eth_leverage = COALESCE(NULLIF(?, 0), btc_eth_leverage),
+			altcoin_leverage = COALESCE(NULLIF(?, 0), altcoin_leverage),
+			updated_at = CURRENT_TIMESTAMP
+		WHERE id = ? AND user_id = ?`)
+	if err != nil {
+		return fmt.Errorf("准备语句失败: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, id := range ids {
+		result, err := stmt.Exec(btcEthLeverage, altcoinLeverage, id, userID)
+		if err != nil {
+			return fmt.Errorf("更新交易员 %s 杠杆失败: %w", id, err)
+		}
+		if n, _ := result.RowsAffected(); n == 0 {
+			return fmt.Errorf("交易员 %s 不存在", id)
+		}
+	}
+	return tx.Commit()
+}
+
 // UpdateTraderCustomPrompt 更新交易员自定义Prompt
 func (d *Database) UpdateTraderCustomPrompt(userID, id string, customPrompt string, overrideBase bool) error {
 	_, err := d.db.Exec(`UPDATE traders SET custom_prompt = ?, override_base_prompt = ? WHERE id = ? AND user_id = ?`, customPrompt, overrideBase, id, userID)
 	return err
 }
 
+// UpdateTraderTradingSymbols 更新交易员交易币种（用于批量规范化旧数据）
+func (d *Database) UpdateTraderTradingSymbols(userID, id, tradingSymbols string) error {
+	_, err := d.db.Exec(`UPDATE traders SET trading_symbols = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ? AND user_id = ?`, tradingSymbols, id, userID)
+	return err
+}
+
 // UpdateTraderInitialBalance 更新交易员初始余额（用于自动同步交易所实际余额）
 func (d *Database) UpdateTraderInitialBalance(userID, id string, newBalance float64) error {
 	_, err := d.db.Exec(`UPDATE traders SET initial_balance = ? WHERE id = ? AND user_id = ?`, newBalance, id, userID)
@@ -1122,6 +1521,31 @@ func (d *Database) GetTraderConfig(userID, traderID string) (*TraderRecord, *AIM
 			COALESCE(t.override_base_prompt, 0) as override_base_prompt,
 			COALESCE(t.system_prompt_template, 'hybrid') as system_prompt_template,
 			COALESCE(t.is_cross_margin, 1) as is_cross_margin,
+			COALESCE(t.max_symbol_allocation_pct, 0) as max_symbol_allocation_pct,
+			COALESCE(t.symbol_allocation_overrides, '') as symbol_allocation_overrides,
+			COALESCE(NULLIF(t.margin_asset, ''), 'USDT') as margin_asset,
+			COALESCE(t.review_model_id, '') as review_model_id,
+			COALESCE(t.review_triggers, '') as review_triggers,
+			COALESCE(NULLIF(t.review_fallback, ''), 'hold') as review_fallback,
+			COALESCE(NULLIF(t.position_sizing_mode, ''), 'absolute') as position_sizing_mode,
+			COALESCE(t.position_size_min_pct, 0) as position_size_min_pct,
+			COALESCE(t.position_size_max_pct, 0) as position_size_max_pct,
+			COALESCE(t.data_source, '') as data_source,
+			COALESCE(NULLIF(t.hedge_policy, ''), 'no_hedge') as hedge_policy,
+			COALESCE(t.indicator_params, '') as indicator_params,
+			COALESCE(t.manage_only, 0) as manage_only,
+			COALESCE(t.hard_drawdown_pct, 0) as hard_drawdown_pct,
+			COALESCE(t.disabled_reason, '') as disabled_reason,
+			COALESCE(t.hard_drawdown_peak, 0) as hard_drawdown_peak,
+			COALESCE(t.hard_drawdown_rebase, 0) as hard_drawdown_rebase,
+			COALESCE(t.min_equity, 0) as min_equity,
+			COALESCE(t.paper_realism, '') as paper_realism,
+			COALESCE(t.paper_realism_overrides, '') as paper_realism_overrides,
+			COALESCE(t.coin_pool_max_symbols, 0) as coin_pool_max_symbols,
+			COALESCE(t.coin_pool_rank_by, '') as coin_pool_rank_by,
+			COALESCE(t.risk_peak_equity, 0) as risk_peak_equity,
+			COALESCE(t.day_start_equity, 0) as day_start_equity,
+			COALESCE(t.day_start_date, '') as day_start_date,
 			t.created_at, t.updated_at,
 			a.id, a.user_id, a.name, a.provider, a.enabled, a.api_key,
 			COALESCE(a.custom_api_url, '') as custom_api_url,
@@ -1145,6 +1569,14 @@ func (d *Database) GetTraderConfig(userID, traderID string) (*TraderRecord, *AIM
 		&trader.UseCoinPool, &trader.UseOITop,
 		&trader.CustomPrompt, &trader.OverrideBasePrompt, &trader.SystemPromptTemplate,
 		&trader.IsCrossMargin,
+		&trader.MaxSymbolAllocationPct, &trader.SymbolAllocationOverrides, &trader.MarginAsset,
+		&trader.ReviewModelID, &trader.ReviewTriggers, &trader.ReviewFallback,
+		&trader.PositionSizingMode, &trader.PositionSizeMinPct, &trader.PositionSizeMaxPct,
+		&trader.DataSource, &trader.HedgePolicy, &trader.IndicatorParams, &trader.ManageOnly,
+		&trader.HardDrawdownPct, &trader.DisabledReason, &trader.HardDrawdownPeak, &trader.HardDrawdownRebase, &trader.MinEquity,
+		&trader.PaperRealism, &trader.PaperRealismOverrides,
+		&trader.CoinPoolMaxSymbols, &trader.CoinPoolRankBy,
+		&trader.RiskPeakEquity, &trader.DayStartEquity, &trader.DayStartDate,
 		&trader.CreatedAt, &trader.UpdatedAt,
 		&aiModel.ID, &aiModel.UserID, &aiModel.Name, &aiModel.Provider, &aiModel.Enabled, &aiModel.APIKey,
 		&aiModel.CustomAPIURL, &aiModel.CustomModelName,
@@ -1247,15 +1679,32 @@ func (d *Database) GetCustomCoins() []string {
 	return symbols
 }
 
-// SavePaperTraderState 保存模拟仓交易器状态到数据库
+// SavePaperTraderState 保存模拟仓交易器状态到数据库（没有限价挂单）
 func (d *Database) SavePaperTraderState(traderID string, initialBalance, balance, realizedPnL float64, positions string) error {
+	return d.SavePaperTraderStateWithLimitOrders(traderID, initialBalance, balance, realizedPnL, positions, "[]")
+}
+
+// SavePaperTraderStateWithLimitOrders 保存模拟仓交易器状态和未成交的限价挂单（同一条记录，挂单冻结的资金与余额一起保存）
+func (d *Database) SavePaperTraderStateWithLimitOrders(traderID string, initialBalance, balance, realizedPnL float64, positions, limitOrders string) error {
 	_, err := d.db.Exec(`
-		INSERT OR REPLACE INTO paper_trader_state (trader_id, initial_balance, balance, realized_pnl, positions, updated_at)
-		VALUES (?, ?, ?, ?, ?, datetime('now'))
-	`, traderID, initialBalance, balance, realizedPnL, positions)
+		INSERT OR REPLACE INTO paper_trader_state (trader_id, initial_balance, balance, realized_pnl, positions, limit_orders, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, datetime('now'))
+	`, traderID, initialBalance, balance, realizedPnL, positions, limitOrders)
 	return err
 }
 
+// LoadPaperLimitOrders 从数据库加载模拟仓未成交的限价挂单（JSON格式，没有保存状态时返回空字符串）
+func (d *Database) LoadPaperLimitOrders(traderID string) (string, error) {
+	var orders string
+	err := d.db.QueryRow(`
+		SELECT COALESCE(limit_orders, '[]') FROM paper_trader_state WHERE trader_id = ?
+	`, traderID).Scan(&orders)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return orders, err
+}
+
 // LoadPaperTraderState 从数据库加载模拟仓交易器状态
 func (d *Database) LoadPaperTraderState(traderID string) (initialBalance, balance, realizedPnL float64, positions string, exists bool, err error) {
 	err = d.db.QueryRow(`
@@ -1277,6 +1726,234 @@ func (d *Database) DeletePaperTraderState(traderID string) error {
 	return err
 }
 
+// AIUsageSummary 交易员AI调用用量汇总
+type AIUsageSummary struct {
+	TraderID         string    `json:"trader_id"`
+	Since            time.Time `json:"since"`
+	Calls            int       `json:"calls"`
+	PromptTokens     int64     `json:"prompt_tokens"`
+	CompletionTokens int64     `json:"completion_tokens"`
+	TotalTokens      int64     `json:"total_tokens"`
+	EstimatedCostUSD float64   `json:"estimated_cost_usd"`
+}
+
+// RecordAIUsage 记录一次AI调用的Token用量和估算成本
+func (d *Database) RecordAIUsage(traderID, userID, provider, model string, promptTokens, completionTokens int, costUSD float64) error {
+	_, err := d.db.Exec(`
+		INSERT INTO ai_usage (trader_id, user_id, provider, model, prompt_tokens, completion_tokens, cost_usd, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`, traderID, userID, provider, model, promptTokens, completionTokens, costUSD, time.Now().UTC().Format(time.RFC3339))
+	return err
+}
+
+// GetAIUsageSummary 汇总交易员自 since 以来的AI调用用量
+func (d *Database) GetAIUsageSummary(traderID string, since time.Time) (*AIUsageSummary, error) {
+	summary := &AIUsageSummary{TraderID: traderID, Since: since.UTC()}
+	err := d.db.QueryRow(`
+		SELECT COUNT(*), COALESCE(SUM(prompt_tokens), 0), COALESCE(SUM(completion_tokens), 0), COALESCE(SUM(cost_usd), 0)
+		FROM ai_usage WHERE trader_id = ? AND created_at >= ?
+	`, traderID, since.UTC().Format(time.RFC3339)).Scan(&summary.Calls, &summary.PromptTokens, &summary.CompletionTokens, &summary.EstimatedCostUSD)
+	if err != nil {
+		return nil, err
+	}
+	summary.TotalTokens = summary.PromptTokens + summary.CompletionTokens
+	return summary, nil
+}
+
+// GetUserAIUsageSummary 汇总用户自 since 以来的全部AI调用用量（所有交易员和币种研究）
+func (d *Database) GetUserAIUsageSummary(userID string, since time.Time) (*AIUsageSummary, error) {
+	summary := &AIUsageSummary{Since: since.UTC()}
+	err := d.db.QueryRow(`
+		SELECT COUNT(*), COALESCE(SUM(prompt_tokens), 0), COALESCE(SUM(completion_tokens), 0), COALESCE(SUM(cost_usd), 0)
+		FROM ai_usage WHERE user_id = ? AND created_at >= ?
+	`, userID, since.UTC().Format(time.RFC3339)).Scan(&summary.Calls, &summary.PromptTokens, &summary.CompletionTokens, &summary.EstimatedCostUSD)
+	if err != nil {
+		return nil, err
+	}
+	summary.TotalTokens = summary.PromptTokens + summary.CompletionTokens
+	return summary, nil
+}
+
+// ResearchReport 币种研究记录
+type ResearchReport struct {
+	ID         int64     `json:"id"`
+	UserID     string    `json:"-"`
+	Symbol     string    `json:"symbol"`
+	Question   string    `json:"question"`
+	AIModelID  string    `json:"ai_model_id"`
+	Model      string    `json:"model"`
+	Analysis   string    `json:"analysis"`
+	Summary    string    `json:"summary"`     // 结构化摘要JSON（AI未按格式输出时为空）
+	MarketData string    `json:"market_data"` // 使用的市场数据JSON
+	DurationMs int64     `json:"duration_ms"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// SaveResearchReport 保存币种研究记录
+func (d *Database) SaveResearchReport(report *ResearchReport) error {
+	if report.CreatedAt.IsZero() {
+		report.CreatedAt = time.Now().UTC()
+	}
+	result, err := d.db.Exec(`
+		INSERT INTO research_reports (user_id, symbol, question, ai_model_id, model, analysis, summary, market_data, duration_ms, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, report.UserID, report.Symbol, report.Question, report.AIModelID, report.Model, report.Analysis, report.Summary,
+		report.MarketData, report.DurationMs, report.CreatedAt.UTC().Format(time.RFC3339Nano))
+	if err != nil {
+		return err
+	}
+	report.ID, err = result.LastInsertId()
+	return err
+}
+
+// researchReportColumns 研究记录查询字段
+const researchReportColumns = `id, user_id, symbol, question, ai_model_id, model, analysis, summary, market_data, duration_ms, created_at`
+
+// scanResearchReport 扫描一行研究记录
+func scanResearchReport(scanner interface{ Scan(dest ...any) error }) (*ResearchReport, error) {
+	var report ResearchReport
+	var createdAt string
+	if err := scanner.Scan(&report.ID, &report.UserID, &report.Symbol, &report.Question, &report.AIModelID, &report.Model,
+		&report.Analysis, &report.Summary, &report.MarketData, &report.DurationMs, &createdAt); err != nil {
+		return nil, err
+	}
+	report.CreatedAt, _ = time.Parse(time.RFC3339Nano, createdAt)
+	return &report, nil
+}
+
+// ListResearchReports 获取用户的研究记录（按时间倒序，symbol 非空时只返回该币种）
+func (d *Database) ListResearchReports(userID, symbol string, limit int) ([]*ResearchReport, error) {
+	query := `SELECT ` + researchReportColumns + ` FROM research_reports WHERE user_id = ?`
+	args := []any{userID}
+	if symbol != "" {
+		query += ` AND symbol = ?`
+		args = append(args, symbol)
+	}
+	query += ` ORDER BY created_at DESC, id DESC LIMIT ?`
+	args = append(args, limit)
+
+	rows, err := d.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	reports := make([]*ResearchReport, 0)
+	for rows.Next() {
+		report, err := scanResearchReport(rows)
+		if err != nil {
+			return nil, err
+		}
+		reports = append(reports, report)
+	}
+	return reports, rows.Err()
+}
+
+// GetResearchReport 获取用户的一条研究记录
+func (d *Database) GetResearchReport(userID string, id int64) (*ResearchReport, error) {
+	return scanResearchReport(d.db.QueryRow(`SELECT `+researchReportColumns+` FROM research_reports WHERE id = ? AND user_id = ?`, id, userID))
+}
+
+// ShareLink 交易员公开分享链接（可见性按项单独控制）
+type ShareLink struct {
+	Slug          string     `json:"slug"`
+	TraderID      string     `json:"trader_id"`
+	UserID        string     `json:"-"`
+	ShowEquity    bool       `json:"show_equity"`    // 净值曲线
+	ShowStats     bool       `json:"show_stats"`     // 统计数据
+	ShowTrades    bool       `json:"show_trades"`    // 交易列表（不含仓位大小）
+	ShowDecisions bool       `json:"show_decisions"` // 决策摘要
+	ShowAbsolute  bool       `json:"show_absolute"`  // 是否显示绝对金额（默认只显示收益率）
+	ExpiresAt     *time.Time `json:"expires_at,omitempty"`
+	RevokedAt     *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+// Active 分享链接当前是否有效（未撤销且未过期）
+func (l *ShareLink) Active(now time.Time) bool {
+	if l.RevokedAt != nil {
+		return false
+	}
+	return l.ExpiresAt == nil || now.Before(*l.ExpiresAt)
+}
+
+// CreateShareLink 创建分享链接
+func (d *Database) CreateShareLink(link *ShareLink) error {
+	expiresAt := ""
+	if link.ExpiresAt != nil {
+		expiresAt = link.ExpiresAt.UTC().Format(time.RFC3339)
+	}
+	if link.CreatedAt.IsZero() {
+		link.CreatedAt = time.Now().UTC()
+	}
+	_, err := d.db.Exec(`
+		INSERT INTO share_links (slug, trader_id, user_id, show_equity, show_stats, show_trades, show_decisions, show_absolute, expires_at, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, link.Slug, link.TraderID, link.UserID, link.ShowEquity, link.ShowStats, link.ShowTrades, link.ShowDecisions, link.ShowAbsolute,
+		expiresAt, link.CreatedAt.UTC().Format(time.RFC3339))
+	return err
+}
+
+// shareLinkColumns 分享链接查询字段
+const shareLinkColumns = `slug, trader_id, user_id, show_equity, show_stats, show_trades, show_decisions, show_absolute, expires_at, revoked_at, created_at`
+
+// scanShareLink 扫描一行分享链接记录
+func scanShareLink(scanner interface{ Scan(dest ...any) error }) (*ShareLink, error) {
+	var link ShareLink
+	var expiresAt, revokedAt, createdAt string
+	if err := scanner.Scan(&link.Slug, &link.TraderID, &link.UserID, &link.ShowEquity, &link.ShowStats, &link.ShowTrades,
+		&link.ShowDecisions, &link.ShowAbsolute, &expiresAt, &revokedAt, &createdAt); err != nil {
+		return nil, err
+	}
+	if t, err := time.Parse(time.RFC3339, expiresAt); err == nil {
+		link.ExpiresAt = &t
+	}
+	if t, err := time.Parse(time.RFC3339, revokedAt); err == nil {
+		link.RevokedAt = &t
+	}
+	link.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
+	return &link, nil
+}
+
+// GetShareLink 根据slug获取分享链接（包含已撤销/过期的链接，由调用方判断是否有效）
+func (d *Database) GetShareLink(slug string) (*ShareLink, error) {
+	return scanShareLink(d.db.QueryRow(`SELECT `+shareLinkColumns+` FROM share_links WHERE slug = ?`, slug))
+}
+
+// ListShareLinks 获取用户某个交易员的全部分享链接（按创建时间倒序）
+func (d *Database) ListShareLinks(userID, traderID string) ([]*ShareLink, error) {
+	rows, err := d.db.Query(`SELECT `+shareLinkColumns+` FROM share_links WHERE user_id = ? AND trader_id = ? ORDER BY created_at DESC`, userID, traderID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var links []*ShareLink
+	for rows.Next() {
+		link, err := scanShareLink(rows)
+		if err != nil {
+			return nil, err
+		}
+		links = append(links, link)
+	}
+	return links, rows.Err()
+}
+
+// RevokeShareLink 撤销分享链接（立即生效）
+func (d *Database) RevokeShareLink(userID, traderID, slug string) error {
+	result, err := d.db.Exec(`
+		UPDATE share_links SET revoked_at = ?
+		WHERE slug = ? AND user_id = ? AND trader_id = ? AND revoked_at = ''
+	`, time.Now().UTC().Format(time.RFC3339), slug, userID, traderID)
+	if err != nil {
+		return err
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
 // BlacklistToken 将token哈希加入黑名单
 func (d *Database) BlacklistToken(tokenHash string, expiresAt time.Time) error {
 	_, err := d.db.Exec(`
@@ -1336,6 +2013,65 @@ func (d *Database) GetAllBlacklistedTokens() (map[string]time.Time, error) {
 	return tokens, nil
 }
 
+// AdminAuditEntry 管理操作审计记录
+type AdminAuditEntry struct {
+	ID        int64     `json:"id"`
+	Actor     string    `json:"actor"`  // 操作者（用户ID，本地管理CLI为 local-admin）
+	Action    string    `json:"action"` // 操作（如 reset_otp、stop_trader）
+	Target    string    `json:"target"` // 操作对象（用户ID、交易员ID等）
+	Details   string    `json:"details"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// RecordAdminAudit 记录管理操作
+func (d *Database) RecordAdminAudit(actor, action, target, details string) error {
+	_, err := d.db.Exec(`
+		INSERT INTO admin_audit_log (actor, action, target, details, created_at)
+		VALUES (?, ?, ?, ?, ?)
+	`, actor, action, target, details, time.Now().UTC().Format(time.RFC3339))
+	return err
+}
+
+// ListAdminAuditLog 获取最近的管理操作记录（按时间倒序）
+func (d *Database) ListAdminAuditLog(limit int) ([]*AdminAuditEntry, error) {
+	rows, err := d.db.Query(`
+		SELECT id, actor, action, target, details, created_at FROM admin_audit_log
+		ORDER BY id DESC LIMIT ?
+	`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []*AdminAuditEntry
+	for rows.Next() {
+		var entry AdminAuditEntry
+		var createdAt string
+		if err := rows.Scan(&entry.ID, &entry.Actor, &entry.Action, &entry.Target, &entry.Details, &createdAt); err != nil {
+			return nil, err
+		}
+		entry.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
+		entries = append(entries, &entry)
+	}
+	return entries, rows.Err()
+}
+
+// Backup 将数据库一致性地备份到 destPath（VACUUM INTO，运行中也可执行；目标文件已存在时失败）
+func (d *Database) Backup(destPath string) error {
+	if _, err := os.Stat(destPath); err == nil {
+		return fmt.Errorf("备份文件已存在: %s", destPath)
+	}
+	if dir := filepath.Dir(destPath); dir != "" {
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return fmt.Errorf("创建备份目录失败: %w", err)
+		}
+	}
+	if _, err := d.db.Exec(`VACUUM INTO ?`, destPath); err != nil {
+		return fmt.Errorf("备份数据库失败: %w", err)
+	}
+	return nil
+}
+
 // Close 关闭数据库连接
 func (d *Database) Close() error {
 	return d.db.Close()
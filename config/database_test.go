@@ -3,6 +3,7 @@ package config
 import (
 	"aspen/crypto"
 	"os"
+	"reflect"
 	"testing"
 	"time"
 )
@@ -797,3 +798,51 @@ func TestConcurrentWritesWithWAL(t *testing.T) {
 		t.Errorf("并发写入失败次数过多: %d", errorCount)
 	}
 }
+
+// TestGetTradersFiltered 测试按交易所和AI模型过滤交易员列表
+func TestGetTradersFiltered(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	for _, rec := range []*TraderRecord{
+		{ID: "t1", UserID: "test-user-001", Name: "A", AIModelID: "deepseek", ExchangeID: "binance", InitialBalance: 1000},
+		{ID: "t2", UserID: "test-user-001", Name: "B", AIModelID: "qwen", ExchangeID: "binance", InitialBalance: 1000},
+		{ID: "t3", UserID: "test-user-001", Name: "C", AIModelID: "deepseek", ExchangeID: "paper", InitialBalance: 1000},
+		{ID: "t4", UserID: "test-user-002", Name: "D", AIModelID: "deepseek", ExchangeID: "binance", InitialBalance: 1000},
+	} {
+		if err := db.CreateTrader(rec); err != nil {
+			t.Fatalf("创建交易员失败: %v", err)
+		}
+	}
+
+	ids := func(filter TraderFilter) map[string]bool {
+		traders, err := db.GetTradersFiltered("test-user-001", filter)
+		if err != nil {
+			t.Fatalf("查询交易员失败: %v", err)
+		}
+		result := make(map[string]bool, len(traders))
+		for _, trader := range traders {
+			result[trader.ID] = true
+		}
+		return result
+	}
+
+	tests := []struct {
+		name   string
+		filter TraderFilter
+		want   map[string]bool
+	}{
+		{"不过滤", TraderFilter{}, map[string]bool{"t1": true, "t2": true, "t3": true}},
+		{"按交易所", TraderFilter{ExchangeID: "binance"}, map[string]bool{"t1": true, "t2": true}},
+		{"按AI模型", TraderFilter{AIModelID: "deepseek"}, map[string]bool{"t1": true, "t3": true}},
+		{"组合过滤", TraderFilter{ExchangeID: "binance", AIModelID: "deepseek"}, map[string]bool{"t1": true}},
+		{"无匹配", TraderFilter{ExchangeID: "hyperliquid"}, map[string]bool{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ids(tt.filter); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("过滤结果 = %v, 期望 %v", got, tt.want)
+			}
+		})
+	}
+}
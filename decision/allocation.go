@@ -0,0 +1,125 @@
+package decision
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"aspen/market"
+)
+
+// AllocationBudget 单币种资金分配上限（按占用保证金占账户净值的百分比计算）
+type AllocationBudget struct {
+	DefaultPct float64            // 任意单币种默认最多占用净值的百分比（0 表示不限制）
+	SymbolPct  map[string]float64 // 按币种覆盖的上限（如 BTCUSDT: 60）
+}
+
+// BudgetPctFor 返回该币种的分配上限百分比（0 表示不限制）
+func (b AllocationBudget) BudgetPctFor(symbol string) float64 {
+	if pct, ok := b.SymbolPct[symbol]; ok && pct > 0 {
+		return pct
+	}
+	return b.DefaultPct
+}
+
+// Enabled 是否配置了任何分配上限
+func (b AllocationBudget) Enabled() bool {
+	if b.DefaultPct > 0 {
+		return true
+	}
+	for _, pct := range b.SymbolPct {
+		if pct > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseAllocationOverrides 解析按币种覆盖的分配上限（JSON对象，如 {"BTC":60,"ETHUSDT":50}）
+// 币种名会被标准化，百分比必须在 (0, 100] 范围内
+func ParseAllocationOverrides(raw string) (map[string]float64, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return nil, nil
+	}
+
+	var parsed map[string]float64
+	if err := json.Unmarshal([]byte(raw), &parsed); err != nil {
+		return nil, fmt.Errorf("单币种分配上限格式错误: %w", err)
+	}
+
+	overrides := make(map[string]float64, len(parsed))
+	for symbol, pct := range parsed {
+		if pct <= 0 || pct > 100 {
+			return nil, fmt.Errorf("%s 的分配上限必须在 0-100%% 之间，实际: %.2f", symbol, pct)
+		}
+		overrides[market.Normalize(strings.ToUpper(strings.TrimSpace(symbol)))] = pct
+	}
+	return overrides, nil
+}
+
+// SymbolAllocation 单币种当前资金分配情况
+type SymbolAllocation struct {
+	Symbol        string  `json:"symbol"`
+	MarginUsed    float64 `json:"margin_used"`    // 该币种所有持仓占用的保证金
+	AllocationPct float64 `json:"allocation_pct"` // 占账户净值百分比
+	BudgetPct     float64 `json:"budget_pct"`     // 分配上限百分比（0 表示不限制）
+}
+
+// Exceeded 当前分配是否已达到/超过上限（超过后只禁止加仓，不会强制平仓）
+func (a SymbolAllocation) Exceeded() bool {
+	return a.BudgetPct > 0 && a.AllocationPct >= a.BudgetPct
+}
+
+// ComputeSymbolAllocations 按币种汇总持仓保证金并计算占净值比例（按币种排序）
+func ComputeSymbolAllocations(positions []PositionInfo, equity float64, budget AllocationBudget) []SymbolAllocation {
+	margins := make(map[string]float64)
+	for _, pos := range positions {
+		margins[pos.Symbol] += pos.MarginUsed
+	}
+
+	allocations := make([]SymbolAllocation, 0, len(margins))
+	for symbol, margin := range margins {
+		pct := 0.0
+		if equity > 0 {
+			pct = margin / equity * 100
+		}
+		allocations = append(allocations, SymbolAllocation{
+			Symbol:        symbol,
+			MarginUsed:    margin,
+			AllocationPct: pct,
+			BudgetPct:     budget.BudgetPctFor(symbol),
+		})
+	}
+	sort.Slice(allocations, func(i, j int) bool {
+		return allocations[i].Symbol < allocations[j].Symbol
+	})
+	return allocations
+}
+
+// formatAllocations 生成提示词中的单币种资金分配说明
+func formatAllocations(allocations []SymbolAllocation, budget AllocationBudget) string {
+	if !budget.Enabled() {
+		return ""
+	}
+
+	var sb strings.Builder
+	if budget.DefaultPct > 0 {
+		sb.WriteString(fmt.Sprintf("单币种分配: 默认上限%.0f%%净值（按占用保证金计算，含新开仓）", budget.DefaultPct))
+	} else {
+		sb.WriteString("单币种分配: 按占用保证金计算，含新开仓")
+	}
+	for _, a := range allocations {
+		if a.BudgetPct <= 0 {
+			sb.WriteString(fmt.Sprintf(" | %s %.1f%%", a.Symbol, a.AllocationPct))
+			continue
+		}
+		sb.WriteString(fmt.Sprintf(" | %s %.1f%%/%.0f%%", a.Symbol, a.AllocationPct, a.BudgetPct))
+		if a.Exceeded() {
+			sb.WriteString("（已满，禁止加仓）")
+		}
+	}
+	sb.WriteString("\n\n")
+	return sb.String()
+}
@@ -0,0 +1,58 @@
+package decision
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+// TestComputeSymbolAllocations 测试按币种汇总多空保证金并匹配分配上限
+func TestComputeSymbolAllocations(t *testing.T) {
+	budget := AllocationBudget{DefaultPct: 40, SymbolPct: map[string]float64{"BTCUSDT": 60}}
+	positions := []PositionInfo{
+		{Symbol: "ETHUSDT", Side: "long", MarginUsed: 300},
+		{Symbol: "BTCUSDT", Side: "long", MarginUsed: 400},
+		{Symbol: "ETHUSDT", Side: "short", MarginUsed: 200},
+	}
+
+	allocations := ComputeSymbolAllocations(positions, 1000, budget)
+	if len(allocations) != 2 {
+		t.Fatalf("len = %d, want 2", len(allocations))
+	}
+
+	btc, eth := allocations[0], allocations[1]
+	if btc.Symbol != "BTCUSDT" || math.Abs(btc.AllocationPct-40) > 1e-9 || btc.BudgetPct != 60 || btc.Exceeded() {
+		t.Errorf("BTC allocation = %+v", btc)
+	}
+	if eth.Symbol != "ETHUSDT" || math.Abs(eth.AllocationPct-50) > 1e-9 || eth.BudgetPct != 40 || !eth.Exceeded() {
+		t.Errorf("ETH allocation = %+v", eth)
+	}
+
+	prompt := formatAllocations(allocations, budget)
+	if !strings.Contains(prompt, "ETHUSDT 50.0%/40%（已满，禁止加仓）") || !strings.Contains(prompt, "BTCUSDT 40.0%/60%") {
+		t.Errorf("prompt = %q", prompt)
+	}
+	if formatAllocations(allocations, AllocationBudget{}) != "" {
+		t.Error("未配置上限时不应输出分配说明")
+	}
+}
+
+// TestParseAllocationOverrides 测试按币种覆盖配置的解析和校验
+func TestParseAllocationOverrides(t *testing.T) {
+	overrides, err := ParseAllocationOverrides(`{"btc":60," ETHUSDT ":50}`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if overrides["BTCUSDT"] != 60 || overrides["ETHUSDT"] != 50 {
+		t.Errorf("overrides = %v", overrides)
+	}
+
+	if overrides, err := ParseAllocationOverrides(""); err != nil || overrides != nil {
+		t.Errorf("空配置应返回 nil, got %v, %v", overrides, err)
+	}
+	for _, raw := range []string{`{"BTCUSDT":0}`, `{"BTCUSDT":120}`, `[1,2]`} {
+		if _, err := ParseAllocationOverrides(raw); err == nil {
+			t.Errorf("%s 应返回错误", raw)
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package decision
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+)
+
+// ParseWarning 解析AI响应时发现的问题（不影响执行，记录在周期日志中便于排查）
+type ParseWarning struct {
+	Code     string   `json:"code"`
+	Message  string   `json:"message"`
+	Payloads []string `json:"payloads,omitempty"` // 相关的原始内容（如互相冲突的决策块）
+}
+
+// ParseWarningMultipleBlocks 响应中有多个内容不同的决策块（如模型"反思"后又输出修订版），采用最后一个
+const ParseWarningMultipleBlocks = "multiple_decision_blocks"
+
+var (
+	reReasoningSection = regexp.MustCompile(`(?s)<reasoning>.*?(</reasoning>|$)`)
+	reDecisionOpen     = regexp.MustCompile(`<decision>`)
+)
+
+// decisionBlock 响应中的一个候选决策数组
+type decisionBlock struct {
+	raw       string // 原始JSON数组文本
+	decisions []Decision
+	err       error // 格式校验或解析失败的原因
+}
+
+// decisionExtraction 决策提取结果
+type decisionExtraction struct {
+	decisions []Decision
+	warnings  []ParseWarning
+}
+
+// extractDecisionBlocks 提取决策：在决策区域中找出所有决策数组，采用最后一个完整有效的数组。
+//
+// 决策区域：所有 <decision> 标签的内容（未闭合的标签取到响应结尾）；没有 <decision> 标签时为去掉 <reasoning> 部分的全文；
+// 以上都找不到数组时再搜索全文（兼容把JSON写在思维链里的旧格式）。
+// 数组按括号匹配截取（跳过字符串内的括号），数组之后的说明文字不影响解析。
+// 多个有效数组内容不一致时记录 multiple_decision_blocks 警告并保留全部原始内容
+func extractDecisionBlocks(response string) (*decisionExtraction, error) {
+	// 预清洗：去零宽/BOM；在查找括号之前先修复全角字符，否则 ［ 无法识别为数组开头
+	s := fixMissingQuotes(strings.TrimSpace(removeInvisibleRunes(response)))
+
+	region := decisionRegion(s)
+	blocks := findDecisionBlocks(region)
+	if len(blocks) == 0 && region != s {
+		blocks = findDecisionBlocks(s)
+	}
+
+	if len(blocks) == 0 {
+		// 🔧 安全回退 (Safe Fallback)：当AI只输出思维链没有JSON时，生成保底决策（避免系统崩溃）
+		log.Printf("⚠️  [SafeFallback] AI未输出JSON决策，进入安全等待模式 (AI response without JSON, entering safe wait mode)")
+
+		// 提取思维链摘要（最多 240 字符）
+		cotSummary := region
+		if len(cotSummary) > 240 {
+			cotSummary = cotSummary[:240] + "..."
+		}
+		return &decisionExtraction{decisions: []Decision{{
+			Symbol:    "ALL",
+			Action:    "wait",
+			Reasoning: fmt.Sprintf("模型未输出结构化JSON决策，进入安全等待；摘要：%s", cotSummary),
+		}}}, nil
+	}
+
+	var valid []decisionBlock
+	for _, b := range blocks {
+		if b.err == nil {
+			valid = append(valid, b)
+		}
+	}
+	if len(valid) == 0 {
+		last := blocks[len(blocks)-1]
+		return nil, fmt.Errorf("%w\nJSON内容: %s\n完整响应:\n%s", last.err, last.raw, response)
+	}
+
+	chosen := valid[len(valid)-1]
+	result := &decisionExtraction{decisions: chosen.decisions}
+	if len(valid) > 1 && !sameDecisions(valid) {
+		payloads := make([]string, len(valid))
+		for i, b := range valid {
+			payloads[i] = b.raw
+		}
+		log.Printf("⚠️  响应中有 %d 个内容不同的决策块，采用最后一个", len(valid))
+		result.warnings = append(result.warnings, ParseWarning{
+			Code:     ParseWarningMultipleBlocks,
+			Message:  fmt.Sprintf("响应中有 %d 个内容不同的决策块，采用最后一个", len(valid)),
+			Payloads: payloads,
+		})
+	}
+	return result, nil
+}
+
+// decisionRegion 决策区域：<decision> 标签的内容，没有标签时为去掉 <reasoning> 部分的全文
+func decisionRegion(s string) string {
+	outside := reReasoningSection.ReplaceAllString(s, "")
+	opens := reDecisionOpen.FindAllStringIndex(outside, -1)
+	if len(opens) == 0 {
+		return strings.TrimSpace(outside)
+	}
+
+	var parts []string
+	for _, loc := range opens {
+		content := outside[loc[1]:]
+		if end := strings.Index(content, "</decision>"); end >= 0 {
+			content = content[:end]
+		}
+		parts = append(parts, strings.TrimSpace(content))
+	}
+	return strings.Join(parts, "\n")
+}
+
+// findDecisionBlocks 按出现顺序找出所有以 [{ 开头的JSON数组（数组内的嵌套数组不单独计入）
+func findDecisionBlocks(s string) []decisionBlock {
+	var blocks []decisionBlock
+	for i := 0; i < len(s); i++ {
+		if s[i] != '[' || !reArrayHead.MatchString(s[i:]) {
+			continue
+		}
+		end := findMatchingBracket(s, i)
+		if end < 0 {
+			// 数组未闭合（输出被截断），保留为无效块以便报告原因
+			blocks = append(blocks, decisionBlock{raw: s[i:], err: fmt.Errorf("JSON数组未闭合")})
+			break
+		}
+		blocks = append(blocks, parseDecisionBlock(s[i:end+1]))
+		i = end
+	}
+	return blocks
+}
+
+// parseDecisionBlock 校验并解析单个决策数组
+func parseDecisionBlock(raw string) decisionBlock {
+	b := decisionBlock{raw: raw}
+	jsonContent := compactArrayOpen(raw) // 把 "[ {" 规整为 "[{"
+	if err := validateJSONFormat(jsonContent); err != nil {
+		b.err = fmt.Errorf("JSON格式验证失败: %w", err)
+		return b
+	}
+	jsonContent = normalizeDecisionNumbers(jsonContent) // 规整科学计数法、带单位和加引号的数字
+	if err := json.Unmarshal([]byte(jsonContent), &b.decisions); err != nil {
+		b.err = fmt.Errorf("JSON解析失败: %w", err)
+	}
+	return b
+}
+
+// sameDecisions 多个决策块解析后的内容是否一致
+func sameDecisions(blocks []decisionBlock) bool {
+	first, _ := json.Marshal(blocks[0].decisions)
+	for _, b := range blocks[1:] {
+		data, _ := json.Marshal(b.decisions)
+		if string(data) != string(first) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,99 @@
+package decision
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// loadDecisionFixture 读取 testdata/decision_blocks 下的AI响应样本
+func loadDecisionFixture(t *testing.T, name string) string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", "decision_blocks", name))
+	require.NoError(t, err)
+	return string(data)
+}
+
+// TestExtractDecisionBlocks_Fixtures 测试真实问题响应：多个决策块、数组后的说明文字、思维链中的决策块
+func TestExtractDecisionBlocks_Fixtures(t *testing.T) {
+	tests := []struct {
+		fixture     string
+		wantActions map[string]string // symbol -> action
+		wantWarning bool
+	}{
+		{"double_block.txt", map[string]string{"BTCUSDT": "wait", "SOLUSDT": "wait"}, true},
+		{"trailing_prose.txt", map[string]string{"ETHUSDT": "close_long", "BTCUSDT": "hold"}, false},
+		{"reasoning_block.txt", map[string]string{"DOGEUSDT": "wait"}, false},
+		{"duplicate_block.txt", map[string]string{"BTCUSDT": "wait"}, false},
+		{"unclosed_tag_trailing.txt", map[string]string{"BNBUSDT": "hold"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			extraction, err := extractDecisionBlocks(loadDecisionFixture(t, tt.fixture))
+			require.NoError(t, err)
+
+			got := make(map[string]string)
+			for _, d := range extraction.decisions {
+				got[d.Symbol] = d.Action
+			}
+			assert.Equal(t, tt.wantActions, got)
+
+			if !tt.wantWarning {
+				assert.Empty(t, extraction.warnings)
+				return
+			}
+			require.Len(t, extraction.warnings, 1)
+			w := extraction.warnings[0]
+			assert.Equal(t, ParseWarningMultipleBlocks, w.Code)
+			require.Len(t, w.Payloads, 2, "保留全部冲突的决策块")
+			assert.Contains(t, w.Payloads[0], "open_long")
+			assert.Contains(t, w.Payloads[1], "疑似空头回补")
+		})
+	}
+}
+
+// TestParseFullDecisionResponse_MultipleBlocksWarning 测试多个决策块的警告随完整决策返回
+func TestParseFullDecisionResponse_MultipleBlocksWarning(t *testing.T) {
+	fd, err := parseFullDecisionResponse(loadDecisionFixture(t, "double_block.txt"), 1000, 10, 5, nil, PositionSizing{})
+	require.NoError(t, err)
+	require.Len(t, fd.Decisions, 2)
+	assert.Equal(t, "wait", fd.Decisions[0].Action)
+	require.Len(t, fd.ParseWarnings, 1)
+	assert.Equal(t, ParseWarningMultipleBlocks, fd.ParseWarnings[0].Code)
+	assert.Contains(t, fd.CoTTrace, "MACD 金叉")
+}
+
+// TestExtractDecisionBlocks_PrefersLastValid 测试最后一个块无效时采用之前的有效块，全部无效时返回最后一个块的错误
+func TestExtractDecisionBlocks_PrefersLastValid(t *testing.T) {
+	response := `<decision>
+[{"symbol": "BTCUSDT", "action": "hold", "reasoning": "ok"}]
+</decision>
+修订：
+<decision>
+[{"symbol": "BTCUSDT", "action": "open_long", "position_size_usd": 98,000}]
+</decision>`
+	decisions, err := extractDecisions(response)
+	require.NoError(t, err)
+	require.Len(t, decisions, 1)
+	assert.Equal(t, "hold", decisions[0].Action)
+
+	_, err = extractDecisions(`<decision>[{"symbol": "BTCUSDT", "stop_loss": "90000~95000"}]</decision>`)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "~")
+
+	_, err = extractDecisions(`<decision>[{"symbol": "BTCUSDT", "action": "hold", "reasoning": "输出被截断`)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "未闭合")
+}
+
+// TestFindMatchingBracket_SkipsStrings 测试括号匹配跳过字符串中的括号和转义引号
+func TestFindMatchingBracket_SkipsStrings(t *testing.T) {
+	s := `[{"reasoning": "突破 [97000] 后回踩 \"确认]\""}] trailing ]`
+	end := findMatchingBracket(s, 0)
+	require.Greater(t, end, 0)
+	assert.True(t, strings.HasSuffix(s[:end+1], `"}]`))
+}
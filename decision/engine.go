@@ -4,9 +4,11 @@ import (
 	"aspen/market"
 	"aspen/mcp"
 	"aspen/pool"
+	"context"
 	"encoding/json"
 	"fmt"
 	"log"
+	"math"
 	"regexp"
 	"strings"
 	"time"
@@ -40,7 +42,8 @@ type PositionInfo struct {
 	PeakPnLPct       float64 `json:"peak_pnl_pct"` // 历史最高收益率（百分比）
 	LiquidationPrice float64 `json:"liquidation_price"`
 	MarginUsed       float64 `json:"margin_used"`
-	UpdateTime       int64   `json:"update_time"` // 持仓更新时间戳（毫秒）
+	UpdateTime       int64   `json:"update_time"`           // 持仓更新时间戳（毫秒）
+	PositionID       string  `json:"position_id,omitempty"` // 持仓ID（平仓/调整决策可用其精确指定持仓）
 }
 
 // AccountInfo 账户信息
@@ -52,6 +55,11 @@ type AccountInfo struct {
 	MarginUsed       float64 `json:"margin_used"`       // 已用保证金
 	MarginUsedPct    float64 `json:"margin_used_pct"`   // 保证金使用率
 	PositionCount    int     `json:"position_count"`    // 持仓数量
+	DailyPnL         float64 `json:"daily_pnl"`         // 当日盈亏
+	DailyPnLPct      float64 `json:"daily_pnl_pct"`     // 当日盈亏百分比
+	DrawdownPct      float64 `json:"drawdown_pct"`      // 从净值峰值的回撤百分比
+	MarginAsset      string  `json:"margin_asset"`      // 保证金资产（USDT/USDC），上面的金额均按USD折算
+	NativeEquity     float64 `json:"native_equity"`     // 净值折合保证金资产的原生数量
 }
 
 // CandidateCoin 候选币种（来自币种池）
@@ -72,39 +80,80 @@ type OITopData struct {
 
 // Context 交易上下文（传递给AI的完整信息）
 type Context struct {
-	CurrentTime     string                  `json:"current_time"`
-	RuntimeMinutes  int                     `json:"runtime_minutes"`
-	CallCount       int                     `json:"call_count"`
-	Account         AccountInfo             `json:"account"`
-	Positions       []PositionInfo          `json:"positions"`
-	CandidateCoins  []CandidateCoin         `json:"candidate_coins"`
-	MarketDataMap   map[string]*market.Data `json:"-"` // 不序列化，但内部使用
-	OITopDataMap    map[string]*OITopData   `json:"-"` // OI Top数据映射
-	Performance     interface{}             `json:"-"` // 历史表现分析（logger.PerformanceAnalysis）
-	BTCETHLeverage  int                     `json:"-"` // BTC/ETH杠杆倍数（从配置读取）
-	AltcoinLeverage int                     `json:"-"` // 山寨币杠杆倍数（从配置读取）
+	CurrentTime      string                  `json:"current_time"`
+	RuntimeMinutes   int                     `json:"runtime_minutes"`
+	CallCount        int                     `json:"call_count"`
+	Account          AccountInfo             `json:"account"`
+	Positions        []PositionInfo          `json:"positions"`
+	CandidateCoins   []CandidateCoin         `json:"candidate_coins"`
+	MarketDataMap    map[string]*market.Data `json:"-"` // 不序列化，但内部使用
+	MarketDataErrors map[string]error        `json:"-"` // 本周期获取市场数据失败的币种及错误
+	OITopDataMap     map[string]*OITopData   `json:"-"` // OI Top数据映射
+	Performance      interface{}             `json:"-"` // 历史表现分析（logger.PerformanceAnalysis）
+	BTCETHLeverage   int                     `json:"-"` // BTC/ETH杠杆倍数（从配置读取）
+	AltcoinLeverage  int                     `json:"-"` // 山寨币杠杆倍数（从配置读取）
+	RiskLimits       *RiskLimits             `json:"-"` // 本周期动态风控上限（nil 表示不缩放）
+	AllocationBudget AllocationBudget        `json:"-"` // 单币种资金分配上限
+	Allocations      []SymbolAllocation      `json:"-"` // 当前各币种资金分配
+	RecentActions    []RecentSymbolAction    `json:"-"` // 各币种近期交易动作
+	CallCtx          context.Context         `json:"-"` // 本周期调用上下文（携带周期截止时间，AI调用预算会扣除已用时间；nil 表示仅使用客户端预算）
+	PromptBudget     int                     `json:"-"` // 提示词 token 预算（系统+用户提示词的估算值），超出时裁剪次要市场数据分段，0 表示不限制
+	Sizing           PositionSizing          `json:"-"` // 仓位大小模式（按金额或按净值百分比）
+	DataSource       market.DataSource       `json:"-"` // 交易员的行情数据源（空值使用全局数据源）
+	Indicators       market.IndicatorParams  `json:"-"` // 交易员的指标周期（零值使用默认周期）
+	PromptCache      *PromptCache            `json:"-"` // 提示词缓存（行情没有明显变化时复用上次决策，nil 表示不启用）
+	ManageOnly       bool                    `json:"-"` // 只管理持仓模式（不允许开仓和加仓）
+	HaltedCandles    int                     `json:"-"` // 停牌检测：最近连续这么多根K线成交量为 0 时本周期跳过该币种，0 表示不检查
+	HaltedSymbols    map[string]int          `json:"-"` // 本周期疑似停牌而跳过的币种及其连续零成交量K线根数
+	ReplayMarketData map[string]*market.Data `json:"-"` // 回测时按回放时刻预先组装的市场数据（非 nil 时只使用这些数据，不获取实时行情和OI Top数据）
+}
+
+// marketDataSource 本周期实际使用的行情数据源
+func (ctx *Context) marketDataSource() market.DataSource {
+	if ctx.DataSource != "" {
+		return ctx.DataSource
+	}
+	return market.GetCurrentDataSource()
+}
+
+// getMarketData 获取币种的市场数据（回测时从回放数据中读取）
+func (ctx *Context) getMarketData(symbol string) (*market.Data, error) {
+	if ctx.ReplayMarketData != nil {
+		if data, ok := ctx.ReplayMarketData[symbol]; ok {
+			return data, nil
+		}
+		return nil, fmt.Errorf("回放数据中没有 %s 在当前时刻的K线", symbol)
+	}
+	return market.GetWithIndicators(ctx.DataSource, symbol, ctx.Indicators)
 }
 
 // Decision AI的交易决策
 type Decision struct {
 	Symbol string `json:"symbol"`
-	Action string `json:"action"` // "open_long", "open_short", "close_long", "close_short", "update_stop_loss", "update_take_profit", "partial_close", "hold", "wait"
+	Action string `json:"action"` // "open_long", "open_short", "add_to_position", "close_long", "close_short", "update_stop_loss", "update_take_profit", "partial_close", "hold", "wait"
 
 	// 开仓参数
 	Leverage        int     `json:"leverage,omitempty"`
-	PositionSizeUSD float64 `json:"position_size_usd,omitempty"`
+	PositionSizeUSD float64 `json:"position_size_usd,omitempty"` // 开仓仓位；add_to_position 时为加仓金额
+	PositionSizePct float64 `json:"position_size_pct,omitempty"` // 按净值百分比给出的仓位（给出时优先于 position_size_usd，执行时按净值换算）
 	StopLoss        float64 `json:"stop_loss,omitempty"`
 	TakeProfit      float64 `json:"take_profit,omitempty"`
+	LimitPrice      float64 `json:"limit_price,omitempty"` // 可选，开仓限价（挂限价单等价格到达后成交，不填按市价开仓）
 
 	// 调整参数（新增）
 	NewStopLoss     float64 `json:"new_stop_loss,omitempty"`    // 用于 update_stop_loss
 	NewTakeProfit   float64 `json:"new_take_profit,omitempty"`  // 用于 update_take_profit
 	ClosePercentage float64 `json:"close_percentage,omitempty"` // 用于 partial_close (0-100)
+	PositionID      string  `json:"position_id,omitempty"`      // 可选，平仓/部分平仓/调整止损止盈时指定持仓（不填则按 symbol+方向处理）
 
 	// 通用参数
 	Confidence int     `json:"confidence,omitempty"` // 信心度 (0-100)
 	RiskUSD    float64 `json:"risk_usd,omitempty"`   // 最大美元风险
 	Reasoning  string  `json:"reasoning"`
+	Note       string  `json:"note,omitempty"` // 给用户看的简短说明（如"RSI底背离，开多"），区别于完整思维链
+
+	// SizingWarning 解析仓位字段时的警告（如同时给出百分比和金额）
+	SizingWarning string `json:"sizing_warning,omitempty"`
 }
 
 // FullDecision AI的完整决策（包含思维链）
@@ -116,6 +165,12 @@ type FullDecision struct {
 	Timestamp    time.Time  `json:"timestamp"`
 	// AIRequestDurationMs 记录 AI API 调用耗时（毫秒）方便排查延迟问题
 	AIRequestDurationMs int64 `json:"ai_request_duration_ms,omitempty"`
+	// Review 两级模型的复核过程（未启用或没有需要复核的决策时为空，此时 Decisions 为复核后的决策）
+	Review *DecisionReview `json:"review,omitempty"`
+	// ParseWarnings 解析AI响应时发现的问题（如多个互相冲突的决策块）
+	ParseWarnings []ParseWarning `json:"parse_warnings,omitempty"`
+	// CacheHit 行情与上次AI调用相同或在容差内，复用了上次的决策（本周期没有调用AI）
+	CacheHit bool `json:"cache_hit,omitempty"`
 }
 
 // GetFullDecision 获取AI的完整交易决策（批量分析所有币种和持仓）
@@ -131,19 +186,51 @@ func GetFullDecisionWithCustomPrompt(ctx *Context, mcpClient *mcp.Client, custom
 	}
 
 	// 2. 构建 System Prompt（固定规则）和 User Prompt（动态数据）
-	systemPrompt := buildSystemPromptWithCustom(ctx.Account.TotalEquity, ctx.BTCETHLeverage, ctx.AltcoinLeverage, customPrompt, overrideBase, templateName)
-	userPrompt := buildUserPrompt(ctx)
+	// 动态风控生效时，提示词中的杠杆上限使用缩放后的值
+	btcEthLeverage, altcoinLeverage := ctx.BTCETHLeverage, ctx.AltcoinLeverage
+	if ctx.RiskLimits != nil && !ctx.RiskLimits.EntriesBlocked {
+		btcEthLeverage, altcoinLeverage = ctx.RiskLimits.MaxBTCETHLeverage, ctx.RiskLimits.MaxAltcoinLeverage
+	}
+	systemPrompt := buildSystemPromptWithCustom(ctx.Account.TotalEquity, btcEthLeverage, altcoinLeverage, customPrompt, overrideBase, templateName)
+	if ctx.Sizing.IsPercent() {
+		systemPrompt += "\n\n" + formatSizingRules(ctx.Sizing, ctx.Account.TotalEquity)
+	}
+	userPrompt := buildUserPromptWithinBudget(ctx, systemPrompt)
+	// 自定义提示词包含 {{market_data}} 时，市场数据内联到占位符位置
+	systemPrompt, userPrompt = inlineMarketData(systemPrompt, userPrompt)
+
+	// 行情没有明显变化时复用上次决策，跳过AI调用
+	var snapshot promptSnapshot
+	if ctx.PromptCache != nil {
+		snapshot = newPromptSnapshot(ctx, systemPrompt)
+		cached, change, hit := ctx.PromptCache.lookup(snapshot, time.Now())
+		recordPromptCacheResult(string(mcpClient.Provider), mcpClient.Model, hit)
+		if hit {
+			log.Printf("♻️  行情与上次AI调用相近（最大价格变化 %.3f%%），复用上次决策，跳过AI调用", change)
+			cached.Timestamp = time.Now()
+			cached.SystemPrompt = systemPrompt
+			cached.UserPrompt = userPrompt
+			cached.AIRequestDurationMs = 0
+			cached.CacheHit = true
+			dropHaltedDecisions(ctx, cached)
+			return cached, nil
+		}
+	}
 
 	// 3. 调用AI API（使用 system + user prompt）
+	callCtx := ctx.CallCtx
+	if callCtx == nil {
+		callCtx = context.Background()
+	}
 	aiCallStart := time.Now()
-	aiResponse, err := mcpClient.CallWithMessages(systemPrompt, userPrompt)
+	aiResponse, err := mcpClient.CallWithMessagesContext(callCtx, systemPrompt, userPrompt)
 	aiCallDuration := time.Since(aiCallStart)
 	if err != nil {
 		return nil, fmt.Errorf("调用AI API失败: %w", err)
 	}
 
 	// 4. 解析AI响应
-	decision, err := parseFullDecisionResponse(aiResponse, ctx.Account.TotalEquity, ctx.BTCETHLeverage, ctx.AltcoinLeverage)
+	decision, err := parseFullDecisionResponse(aiResponse, ctx.Account.TotalEquity, ctx.BTCETHLeverage, ctx.AltcoinLeverage, ctx.RiskLimits, ctx.Sizing)
 
 	// 无论是否有错误，都要保存 SystemPrompt 和 UserPrompt（用于调试和决策未执行后的问题定位）
 	if decision != nil {
@@ -160,19 +247,27 @@ func GetFullDecisionWithCustomPrompt(ctx *Context, mcpClient *mcp.Client, custom
 	decision.Timestamp = time.Now()
 	decision.SystemPrompt = systemPrompt // 保存系统prompt
 	decision.UserPrompt = userPrompt     // 保存输入prompt
+	if ctx.PromptCache != nil {
+		ctx.PromptCache.store(snapshot, decision, decision.Timestamp)
+	}
+	dropHaltedDecisions(ctx, decision)
 	return decision, nil
 }
 
 // fetchMarketDataForContext 为上下文中的所有币种获取市场数据和OI数据
 func fetchMarketDataForContext(ctx *Context) error {
 	ctx.MarketDataMap = make(map[string]*market.Data)
+	ctx.MarketDataErrors = make(map[string]error)
 	ctx.OITopDataMap = make(map[string]*OITopData)
+	ctx.HaltedSymbols = make(map[string]int)
 
 	// 收集所有需要获取数据的币种
 	symbolSet := make(map[string]bool)
 
-	// 0. 始终获取 BTC 市场数据（作为重要的市场指标，即使不在候选列表中也要获取）
-	symbolSet["BTCUSDT"] = true
+	// 0. 始终获取 BTC 市场数据（作为重要的市场指标，即使不在候选列表中也要获取；回测时只在回放数据包含 BTC 时获取）
+	if ctx.ReplayMarketData == nil || ctx.ReplayMarketData["BTCUSDT"] != nil {
+		symbolSet["BTCUSDT"] = true
+	}
 
 	// 1. 优先获取持仓币种的数据（这是必须的）
 	for _, pos := range ctx.Positions {
@@ -199,13 +294,23 @@ func fetchMarketDataForContext(ctx *Context) error {
 	successCount := 0
 	failedCount := 0
 	filteredCount := 0
+	haltedCount := 0
 
 	for symbol := range symbolSet {
-		data, err := market.Get(symbol)
+		data, err := ctx.getMarketData(symbol)
 		if err != nil {
 			// 单个币种失败不影响整体，记录错误
 			failedCount++
 			log.Printf("⚠️  获取 %s 市场数据失败: %v", symbol, err)
+			ctx.MarketDataErrors[symbol] = err
+			continue
+		}
+
+		// 停牌检测：最近多根K线成交量都为 0 时本周期跳过该币种（持仓币种也跳过：停牌期间无法成交）
+		if data.AppearsHalted(ctx.HaltedCandles) {
+			haltedCount++
+			log.Printf("⚠️  %s symbol appears halted（最近 %d 根K线成交量为 0），本周期跳过此币种", symbol, data.ZeroVolumeCandles)
+			ctx.HaltedSymbols[symbol] = data.ZeroVolumeCandles
 			continue
 		}
 
@@ -230,7 +335,7 @@ func fetchMarketDataForContext(ctx *Context) error {
 					symbol, oiValueInMillions, minOIThresholdMillions, data.OpenInterest.Latest, data.CurrentPrice)
 				continue
 			}
-		} else if !isExistingPosition && data.OpenInterest == nil {
+		} else if !isExistingPosition && data.OpenInterest == nil && !data.NoOpenInterest {
 			// 如果没有 OI 数据，记录警告但不过滤（可能是新币种或数据源问题）
 			log.Printf("⚠️  %s 没有持仓量(OI)数据，但保留在候选列表中", symbol)
 		}
@@ -240,11 +345,14 @@ func fetchMarketDataForContext(ctx *Context) error {
 	}
 
 	// 输出统计信息
-	if failedCount > 0 || filteredCount > 0 {
-		log.Printf("📊 市场数据获取统计: 成功 %d 个, 失败 %d 个, 流动性过滤 %d 个", successCount, failedCount, filteredCount)
+	if failedCount > 0 || filteredCount > 0 || haltedCount > 0 {
+		log.Printf("📊 市场数据获取统计: 成功 %d 个, 失败 %d 个, 流动性过滤 %d 个, 疑似停牌 %d 个", successCount, failedCount, filteredCount, haltedCount)
 	}
 
-	// 加载OI Top数据（不影响主流程）
+	// 加载OI Top数据（不影响主流程；回测时没有历史OI Top数据，不使用实时数据）
+	if ctx.ReplayMarketData != nil {
+		return nil
+	}
 	oiPositions, err := pool.GetOITopPositions()
 	if err == nil {
 		for _, pos := range oiPositions {
@@ -295,6 +403,11 @@ func calculateMaxCandidates(ctx *Context) int {
 
 // buildSystemPromptWithCustom 构建包含自定义内容的 System Prompt
 func buildSystemPromptWithCustom(accountEquity float64, btcEthLeverage, altcoinLeverage int, customPrompt string, overrideBase bool, templateName string) string {
+	// 替换输出格式占位符
+	if strings.Contains(customPrompt, PromptPlaceholderOutputFormat) {
+		customPrompt = strings.ReplaceAll(customPrompt, PromptPlaceholderOutputFormat, buildOutputFormatSection(accountEquity, btcEthLeverage))
+	}
+
 	// 如果覆盖基础prompt且有自定义prompt，只使用自定义prompt
 	if overrideBase && customPrompt != "" {
 		return customPrompt
@@ -366,6 +479,14 @@ func buildSystemPrompt(accountEquity float64, btcEthLeverage, altcoinLeverage in
 	sb.WriteString("6. 开仓金额: 建议 **≥12 USDT** (交易所最小名义价值 10 USDT + 安全边际)\n\n")
 
 	// 3. 输出格式 - 动态生成
+	sb.WriteString(buildOutputFormatSection(accountEquity, btcEthLeverage))
+
+	return sb.String()
+}
+
+// buildOutputFormatSection 构建输出格式说明（决策解析依赖其中的 XML 标签和字段）
+func buildOutputFormatSection(accountEquity float64, btcEthLeverage int) string {
+	var sb strings.Builder
 	sb.WriteString("# 输出格式 (严格遵守)\n\n")
 	sb.WriteString("**必须使用XML标签 <reasoning> 和 <decision> 标签分隔思维链和决策JSON，避免解析错误**\n\n")
 	sb.WriteString("## 格式要求\n\n")
@@ -375,20 +496,30 @@ func buildSystemPrompt(accountEquity float64, btcEthLeverage, altcoinLeverage in
 	sb.WriteString("</reasoning>\n\n")
 	sb.WriteString("<decision>\n")
 	sb.WriteString("```json\n[\n")
-	sb.WriteString(fmt.Sprintf("  {\"symbol\": \"BTCUSDT\", \"action\": \"open_short\", \"leverage\": %d, \"position_size_usd\": %.0f, \"stop_loss\": 97000, \"take_profit\": 91000, \"confidence\": 85, \"risk_usd\": 300, \"reasoning\": \"下跌趋势+MACD死叉\"},\n", btcEthLeverage, accountEquity*5))
+	sb.WriteString(fmt.Sprintf("  {\"symbol\": \"BTCUSDT\", \"action\": \"open_short\", \"leverage\": %d, \"position_size_usd\": %.0f, \"stop_loss\": 97000, \"take_profit\": 91000, \"confidence\": 85, \"risk_usd\": 300, \"reasoning\": \"下跌趋势+MACD死叉\", \"note\": \"跌破支撑，开空\"},\n", btcEthLeverage, accountEquity*5))
 	sb.WriteString("  {\"symbol\": \"ETHUSDT\", \"action\": \"close_long\", \"reasoning\": \"止盈离场\"}\n")
 	sb.WriteString("]\n```\n")
 	sb.WriteString("</decision>\n\n")
 	sb.WriteString("## 字段说明\n\n")
-	sb.WriteString("- `action`: open_long | open_short | close_long | close_short | hold | wait\n")
+	sb.WriteString("- `action`: open_long | open_short | add_to_position | close_long | close_short | hold | wait\n")
 	sb.WriteString("- `confidence`: 0-100（开仓建议≥75）\n")
-	sb.WriteString("- 开仓时必填: leverage, position_size_usd, stop_loss, take_profit, confidence, risk_usd, reasoning\n\n")
+	sb.WriteString(fmt.Sprintf("- `note`: 可选，给用户看的一句话说明（≤%d字），如\"RSI底背离，开多\"\n", maxDecisionNoteRunes))
+	sb.WriteString("- `position_id`: 可选，平仓/部分平仓/调整止损止盈时填写当前持仓列表中的持仓ID，精确指定要操作的持仓\n")
+	sb.WriteString("- 开仓时必填: leverage, position_size_usd, stop_loss, take_profit, confidence, risk_usd, reasoning\n")
+	sb.WriteString("- `limit_price`: 可选，开仓限价：挂限价单，等价格回到该价位再成交（多单低于当前价、空单高于当前价），长时间未成交自动撤单；不填按市价开仓。仅模拟仓支持，实盘交易员请勿填写\n")
+	sb.WriteString("- `add_to_position`: 对已有持仓加仓，必填 position_size_usd（加仓金额），可选 stop_loss/take_profit（按加仓后总仓位重设）、position_id\n")
+	sb.WriteString("- 重复开仓保护: 近期已成交且未平仓的开仓（同币种、同方向、仓位大小和入场价格相近）会被系统以 duplicate_intent 拒绝，即使持仓列表暂未显示该持仓；有意加仓请使用 add_to_position，不要重复 open_long/open_short\n\n")
 
 	return sb.String()
 }
 
 // buildUserPrompt 构建 User Prompt（动态数据）
 func buildUserPrompt(ctx *Context) string {
+	return buildUserPromptWithSections(ctx, nil)
+}
+
+// buildUserPromptWithSections 构建 User Prompt，币种市场数据只输出 flags 中启用的分段
+func buildUserPromptWithSections(ctx *Context, flags market.SectionFlags) string {
 	var sb strings.Builder
 
 	// 系统状态
@@ -415,13 +546,22 @@ func buildUserPrompt(ctx *Context) string {
 		))
 	} else {
 		// 如果 BTC 数据获取失败，记录警告但继续
-		dataSourceName := string(market.GetCurrentDataSource())
+		dataSourceName := string(ctx.marketDataSource())
 		sb.WriteString(fmt.Sprintf("BTC: 数据获取失败（请检查网络连接或 %s API 状态）\n\n", strings.ToUpper(dataSourceName)))
 		log.Printf("⚠️  警告: BTC 市场数据获取失败，这可能会影响 AI 决策质量")
 	}
 
-	// 账户
-	sb.WriteString(fmt.Sprintf("账户: 净值%.2f | 余额%.2f (%.1f%%) | 盈亏%+.2f%% | 保证金%.1f%% | 持仓%d个\n\n",
+	// 账户（明确保证金资产，金额统一按USD折算）
+	accountLabel := "账户"
+	if asset := ctx.Account.MarginAsset; asset != "" {
+		accountLabel = fmt.Sprintf("账户（保证金资产%s，金额按USD折算", asset)
+		if native := ctx.Account.NativeEquity; native > 0 && math.Abs(native-ctx.Account.TotalEquity) >= 0.01 {
+			accountLabel += fmt.Sprintf("，净值折合%.2f %s", native, asset)
+		}
+		accountLabel += "）"
+	}
+	sb.WriteString(fmt.Sprintf("%s: 净值%.2f | 余额%.2f (%.1f%%) | 盈亏%+.2f%% | 保证金%.1f%% | 持仓%d个\n\n",
+		accountLabel,
 		ctx.Account.TotalEquity,
 		ctx.Account.AvailableBalance,
 		(ctx.Account.AvailableBalance/ctx.Account.TotalEquity)*100,
@@ -429,6 +569,22 @@ func buildUserPrompt(ctx *Context) string {
 		ctx.Account.MarginUsedPct,
 		ctx.Account.PositionCount))
 
+	// 动态风控上限（仓位和杠杆须在此范围内）
+	if ctx.RiskLimits != nil {
+		sb.WriteString(formatRiskLimits(ctx.RiskLimits))
+	}
+
+	// 只管理持仓模式：告知AI不要开新仓（执行器也会拒绝）
+	if ctx.ManageOnly {
+		sb.WriteString("⛔ 只管理持仓模式：禁止新开仓和加仓，只允许平仓/部分平仓/调整止损止盈/观望\n\n")
+	}
+
+	// 单币种资金分配（当前占用 vs 上限）
+	sb.WriteString(formatAllocations(ctx.Allocations, ctx.AllocationBudget))
+
+	// 近期币种操作（避免刚平仓即反手/重复开仓）
+	sb.WriteString(formatRecentSymbolActions(ctx.RecentActions, ctx))
+
 	// 持仓（完整市场数据）
 	if len(ctx.Positions) > 0 {
 		sb.WriteString("## 当前持仓\n")
@@ -449,15 +605,19 @@ func buildUserPrompt(ctx *Context) string {
 
 			// 计算仓位价值（名义价值）= 数量 × 当前价格
 			positionValue := pos.Quantity * pos.MarkPrice
-			sb.WriteString(fmt.Sprintf("%d. %s %s | 入场价%.4f 当前价%.4f | 数量%.4f | 仓位价值%.2f USDT | 盈亏%+.2f%% | 盈亏金额%+.2f USDT | 最高收益率%.2f%% | 杠杆%dx | 保证金%.0f | 强平价%.4f%s\n\n",
-				i+1, pos.Symbol, strings.ToUpper(pos.Side),
+			positionID := ""
+			if pos.PositionID != "" {
+				positionID = fmt.Sprintf(" [持仓ID: %s]", pos.PositionID)
+			}
+			sb.WriteString(fmt.Sprintf("%d. %s %s%s | 入场价%.4f 当前价%.4f | 数量%.4f | 仓位价值%.2f USDT | 盈亏%+.2f%% | 盈亏金额%+.2f USDT | 最高收益率%.2f%% | 杠杆%dx | 保证金%.0f | 强平价%.4f%s\n\n",
+				i+1, pos.Symbol, strings.ToUpper(pos.Side), positionID,
 				pos.EntryPrice, pos.MarkPrice, pos.Quantity, positionValue,
 				pos.UnrealizedPnLPct, pos.UnrealizedPnL, pos.PeakPnLPct,
 				pos.Leverage, pos.MarginUsed, pos.LiquidationPrice, holdingDuration))
 
 			// 使用FormatMarketData输出完整市场数据
 			if marketData, ok := ctx.MarketDataMap[pos.Symbol]; ok {
-				sb.WriteString(market.Format(marketData))
+				sb.WriteString(market.FormatSections(marketData, flags))
 				sb.WriteString("\n")
 			}
 		}
@@ -484,7 +644,7 @@ func buildUserPrompt(ctx *Context) string {
 
 	// 如果有候选币种但数据获取失败，显示警告
 	if len(ctx.CandidateCoins) > 0 && displayedCount == 0 {
-		dataSourceName := string(market.GetCurrentDataSource())
+		dataSourceName := string(ctx.marketDataSource())
 		sb.WriteString("⚠️ **警告：所有候选币种的市场数据获取失败！**\n\n")
 		sb.WriteString(fmt.Sprintf("失败的币种: %v\n", missingDataCoins))
 		sb.WriteString("可能原因：\n")
@@ -513,7 +673,7 @@ func buildUserPrompt(ctx *Context) string {
 
 		// 使用FormatMarketData输出完整市场数据
 		sb.WriteString(fmt.Sprintf("### %d. %s%s\n\n", displayedCount, coin.Symbol, sourceTags))
-		sb.WriteString(market.Format(marketData))
+		sb.WriteString(market.FormatSections(marketData, flags))
 		sb.WriteString("\n")
 	}
 	sb.WriteString("\n")
@@ -539,30 +699,42 @@ func buildUserPrompt(ctx *Context) string {
 }
 
 // parseFullDecisionResponse 解析AI的完整决策响应
-func parseFullDecisionResponse(aiResponse string, accountEquity float64, btcEthLeverage, altcoinLeverage int) (*FullDecision, error) {
+func parseFullDecisionResponse(aiResponse string, accountEquity float64, btcEthLeverage, altcoinLeverage int, limits *RiskLimits, sizing PositionSizing) (*FullDecision, error) {
 	// 1. 提取思维链
 	cotTrace := extractCoTTrace(aiResponse)
 
 	// 2. 提取JSON决策列表
-	decisions, err := extractDecisions(aiResponse)
+	extraction, err := extractDecisionBlocks(aiResponse)
 	if err != nil {
 		return &FullDecision{
 			CoTTrace:  cotTrace,
 			Decisions: []Decision{},
 		}, fmt.Errorf("提取决策失败: %w", err)
 	}
+	decisions, warnings := extraction.decisions, extraction.warnings
 
-	// 3. 验证决策
-	if err := validateDecisions(decisions, accountEquity, btcEthLeverage, altcoinLeverage); err != nil {
+	normalizeDecisionNotes(decisions)
+
+	// 3. 按净值百分比给出的仓位先换算为金额，再验证决策
+	if err := resolvePositionSizing(decisions, accountEquity, sizing); err != nil {
 		return &FullDecision{
-			CoTTrace:  cotTrace,
-			Decisions: decisions,
+			CoTTrace:      cotTrace,
+			Decisions:     decisions,
+			ParseWarnings: warnings,
+		}, fmt.Errorf("决策验证失败: %w", err)
+	}
+	if err := validateDecisions(decisions, accountEquity, btcEthLeverage, altcoinLeverage, limits); err != nil {
+		return &FullDecision{
+			CoTTrace:      cotTrace,
+			Decisions:     decisions,
+			ParseWarnings: warnings,
 		}, fmt.Errorf("决策验证失败: %w", err)
 	}
 
 	return &FullDecision{
-		CoTTrace:  cotTrace,
-		Decisions: decisions,
+		CoTTrace:      cotTrace,
+		Decisions:     decisions,
+		ParseWarnings: warnings,
 	}, nil
 }
 
@@ -591,84 +763,13 @@ func extractCoTTrace(response string) string {
 	return strings.TrimSpace(response)
 }
 
-// extractDecisions 提取JSON决策列表
+// extractDecisions 提取JSON决策列表（规则见 extractDecisionBlocks）
 func extractDecisions(response string) ([]Decision, error) {
-	// 预清洗：去零宽/BOM
-	s := removeInvisibleRunes(response)
-	s = strings.TrimSpace(s)
-
-	// 🔧 关键修复 (Critical Fix)：在正则匹配之前就先修复全角字符！
-	// 否则正则表达式 \[ 无法匹配全角的 ［
-	s = fixMissingQuotes(s)
-
-	// 方法1: 优先尝试从 <decision> 标签中提取
-	var jsonPart string
-	if match := reDecisionTag.FindStringSubmatch(s); match != nil && len(match) > 1 {
-		jsonPart = strings.TrimSpace(match[1])
-		log.Printf("✓ 使用 <decision> 标签提取JSON")
-	} else {
-		// 后备方案：使用整个响应
-		jsonPart = s
-		log.Printf("⚠️  未找到 <decision> 标签，使用全文搜索JSON")
-	}
-
-	// 修复 jsonPart 中的全角字符
-	jsonPart = fixMissingQuotes(jsonPart)
-
-	// 1) 优先从 ```json 代码块中提取
-	if m := reJSONFence.FindStringSubmatch(jsonPart); m != nil && len(m) > 1 {
-		jsonContent := strings.TrimSpace(m[1])
-		jsonContent = compactArrayOpen(jsonContent) // 把 "[ {" 规整为 "[{"
-		jsonContent = fixMissingQuotes(jsonContent) // 二次修复（防止 regex 提取后还有残留全角）
-		if err := validateJSONFormat(jsonContent); err != nil {
-			return nil, fmt.Errorf("JSON格式验证失败: %w\nJSON内容: %s\n完整响应:\n%s", err, jsonContent, response)
-		}
-		var decisions []Decision
-		if err := json.Unmarshal([]byte(jsonContent), &decisions); err != nil {
-			return nil, fmt.Errorf("JSON解析失败: %w\nJSON内容: %s", err, jsonContent)
-		}
-		return decisions, nil
-	}
-
-	// 2) 退而求其次 (Fallback)：全文寻找首个对象数组
-	// 注意：此时 jsonPart 已经过 fixMissingQuotes()，全角字符已转换为半角
-	jsonContent := strings.TrimSpace(reJSONArray.FindString(jsonPart))
-	if jsonContent == "" {
-		// 🔧 安全回退 (Safe Fallback)：当AI只输出思维链没有JSON时，生成保底决策（避免系统崩溃）
-		log.Printf("⚠️  [SafeFallback] AI未输出JSON决策，进入安全等待模式 (AI response without JSON, entering safe wait mode)")
-
-		// 提取思维链摘要（最多 240 字符）
-		cotSummary := jsonPart
-		if len(cotSummary) > 240 {
-			cotSummary = cotSummary[:240] + "..."
-		}
-
-		// 生成保底决策：所有币种进入 wait 状态
-		fallbackDecision := Decision{
-			Symbol:    "ALL",
-			Action:    "wait",
-			Reasoning: fmt.Sprintf("模型未输出结构化JSON决策，进入安全等待；摘要：%s", cotSummary),
-		}
-
-		return []Decision{fallbackDecision}, nil
-	}
-
-	// 🔧 规整格式（此时全角字符已在前面修复过）
-	jsonContent = compactArrayOpen(jsonContent)
-	jsonContent = fixMissingQuotes(jsonContent) // 二次修复（防止 regex 提取后还有残留全角）
-
-	// 🔧 验证 JSON 格式（检测常见错误）
-	if err := validateJSONFormat(jsonContent); err != nil {
-		return nil, fmt.Errorf("JSON格式验证失败: %w\nJSON内容: %s\n完整响应:\n%s", err, jsonContent, response)
-	}
-
-	// 解析JSON
-	var decisions []Decision
-	if err := json.Unmarshal([]byte(jsonContent), &decisions); err != nil {
-		return nil, fmt.Errorf("JSON解析失败: %w\nJSON内容: %s", err, jsonContent)
+	extraction, err := extractDecisionBlocks(response)
+	if err != nil {
+		return nil, err
 	}
-
-	return decisions, nil
+	return extraction.decisions, nil
 }
 
 // fixMissingQuotes 替换中文引号和全角字符为英文引号和半角字符（避免AI输出全角JSON字符导致解析失败）
@@ -752,24 +853,53 @@ func compactArrayOpen(s string) string {
 }
 
 // validateDecisions 验证所有决策（需要账户信息和杠杆配置）
-func validateDecisions(decisions []Decision, accountEquity float64, btcEthLeverage, altcoinLeverage int) error {
+func validateDecisions(decisions []Decision, accountEquity float64, btcEthLeverage, altcoinLeverage int, limits *RiskLimits) error {
 	for i, decision := range decisions {
-		if err := validateDecision(&decision, accountEquity, btcEthLeverage, altcoinLeverage); err != nil {
-			return fmt.Errorf("决策 #%d 验证失败: %w", i+1, err)
+		if err := validateDecisionWithLimits(&decision, accountEquity, btcEthLeverage, altcoinLeverage, limits); err != nil {
+			return &ValidationError{Index: i, Decision: decision, Err: err}
 		}
 	}
 	return nil
 }
 
-// findMatchingBracket 查找匹配的右括号
+// maxDecisionNoteRunes 决策说明最大长度（字符数，提示词中告知AI的上限和截断长度共用）
+const maxDecisionNoteRunes = 60
+
+// normalizeDecisionNotes 清理决策说明：去除首尾空白和换行，超长截断（截断后含省略号不超过最大长度）
+func normalizeDecisionNotes(decisions []Decision) {
+	for i := range decisions {
+		note := strings.Join(strings.Fields(decisions[i].Note), " ")
+		if runes := []rune(note); len(runes) > maxDecisionNoteRunes {
+			note = string(runes[:maxDecisionNoteRunes-3]) + "..."
+		}
+		decisions[i].Note = note
+	}
+}
+
+// findMatchingBracket 查找匹配的右括号（跳过JSON字符串内的括号）
 func findMatchingBracket(s string, start int) int {
 	if start >= len(s) || s[start] != '[' {
 		return -1
 	}
 
 	depth := 0
+	inString, escaped := false, false
 	for i := start; i < len(s); i++ {
-		switch s[i] {
+		c := s[i]
+		if inString {
+			switch {
+			case escaped:
+				escaped = false
+			case c == '\\':
+				escaped = true
+			case c == '"':
+				inString = false
+			}
+			continue
+		}
+		switch c {
+		case '"':
+			inString = true
 		case '[':
 			depth++
 		case ']':
@@ -785,10 +915,21 @@ func findMatchingBracket(s string, start int) int {
 
 // validateDecision 验证单个决策的有效性
 func validateDecision(d *Decision, accountEquity float64, btcEthLeverage, altcoinLeverage int) error {
+	return validateDecisionWithLimits(d, accountEquity, btcEthLeverage, altcoinLeverage, nil)
+}
+
+// ValidateDecision 按AI决策的同一规则验证外部提交的单个决策（如模拟接口）
+func ValidateDecision(d *Decision, accountEquity float64, btcEthLeverage, altcoinLeverage int) error {
+	return validateDecision(d, accountEquity, btcEthLeverage, altcoinLeverage)
+}
+
+// validateDecisionWithLimits 验证单个决策的有效性（叠加本周期动态风控上限）
+func validateDecisionWithLimits(d *Decision, accountEquity float64, btcEthLeverage, altcoinLeverage int, limits *RiskLimits) error {
 	// 验证action
 	validActions := map[string]bool{
 		"open_long":          true,
 		"open_short":         true,
+		"add_to_position":    true,
 		"close_long":         true,
 		"close_short":        true,
 		"update_stop_loss":   true,
@@ -802,6 +943,11 @@ func validateDecision(d *Decision, accountEquity float64, btcEthLeverage, altcoi
 		return fmt.Errorf("无效的action: %s", d.Action)
 	}
 
+	// 持仓ID只用于指定已有持仓，开仓时由系统分配
+	if d.PositionID != "" && (d.Action == "open_long" || d.Action == "open_short" || d.Action == "hold" || d.Action == "wait") {
+		return fmt.Errorf("%s 不能指定 position_id（持仓ID仅用于平仓和调整已有持仓）", d.Action)
+	}
+
 	// 开仓操作必须提供完整参数
 	if d.Action == "open_long" || d.Action == "open_short" {
 		// 根据币种使用配置的杠杆上限
@@ -812,9 +958,27 @@ func validateDecision(d *Decision, accountEquity float64, btcEthLeverage, altcoi
 			maxPositionValue = accountEquity * 10 // BTC/ETH最多10倍账户净值
 		}
 
+		// 动态风控：亏损接近上限时禁止开仓，否则按系数收紧杠杆和仓位上限
+		if limits != nil {
+			if limits.EntriesBlocked {
+				return Reject(limits.BlockCode(), fmt.Errorf("动态风控已禁止开仓（%s）", limits.Reason))
+			}
+			if lev := limits.MaxLeverageFor(d.Symbol); lev < maxLeverage {
+				maxLeverage = lev
+			}
+			if maxPos := limits.MaxPositionFor(d.Symbol); maxPos < maxPositionValue {
+				maxPositionValue = maxPos
+			}
+		}
+
+		// 交易所币种杠杆上限（数据源元数据或手动配置）
+		if lev, ok := market.SymbolMaxLeverage(d.Symbol); ok && lev < maxLeverage {
+			maxLeverage = lev
+		}
+
 		// ✅ Fallback 机制：杠杆超限时自动修正为上限值（而不是直接拒绝决策）
 		if d.Leverage <= 0 {
-			return fmt.Errorf("杠杆必须大于0: %d", d.Leverage)
+			return Reject(RejectLeverage, fmt.Errorf("杠杆必须大于0: %d", d.Leverage))
 		}
 		if d.Leverage > maxLeverage {
 			log.Printf("⚠️  [Leverage Fallback] %s 杠杆超限 (%dx > %dx)，自动调整为上限值 %dx",
@@ -822,31 +986,24 @@ func validateDecision(d *Decision, accountEquity float64, btcEthLeverage, altcoi
 			d.Leverage = maxLeverage // 自动修正为上限值
 		}
 		if d.PositionSizeUSD <= 0 {
-			return fmt.Errorf("仓位大小必须大于0: %.2f", d.PositionSizeUSD)
+			return Reject(RejectMinPositionSize, fmt.Errorf("仓位大小必须大于0: %.2f", d.PositionSizeUSD))
 		}
 
-		// ✅ 验证最小开仓金额（防止数量格式化为 0 的错误）
-		// Binance 最小名义价值 10 USDT + 安全边际
-		const minPositionSizeGeneral = 12.0 // 10 + 20% 安全边际
-		const minPositionSizeBTCETH = 60.0  // BTC/ETH 因价格高和精度限制需要更大金额（更灵活）
-
-		if d.Symbol == "BTCUSDT" || d.Symbol == "ETHUSDT" {
-			if d.PositionSizeUSD < minPositionSizeBTCETH {
-				return fmt.Errorf("%s 开仓金额过小(%.2f USDT)，必须≥%.2f USDT（因价格高且精度限制，避免数量四舍五入为0）", d.Symbol, d.PositionSizeUSD, minPositionSizeBTCETH)
-			}
-		} else {
-			if d.PositionSizeUSD < minPositionSizeGeneral {
-				return fmt.Errorf("开仓金额过小(%.2f USDT)，必须≥%.2f USDT（Binance 最小名义价值要求）", d.PositionSizeUSD, minPositionSizeGeneral)
-			}
+		// ✅ 验证最小开仓金额（按币种：交易所最小名义价值要求，BTC/ETH 还要避免数量四舍五入为0）
+		if minSize := MinPositionSizeUSD(d.Symbol); d.PositionSizeUSD < minSize {
+			return Reject(RejectMinPositionSize, fmt.Errorf("%s 开仓金额过小(%.2f USDT)，必须≥%.2f USDT（交易所最小名义价值及精度要求）", d.Symbol, d.PositionSizeUSD, minSize))
 		}
 
 		// 验证仓位价值上限（加1%容差以避免浮点数精度问题）
 		tolerance := maxPositionValue * 0.01 // 1%容差
 		if d.PositionSizeUSD > maxPositionValue+tolerance {
+			if limits != nil && limits.SizeFactor < 1 {
+				return Reject(RejectMaxPositionValue, fmt.Errorf("动态风控下单币种仓位价值不能超过%.0f USDT（仓位系数 %.2f），实际: %.0f", maxPositionValue, limits.SizeFactor, d.PositionSizeUSD))
+			}
 			if d.Symbol == "BTCUSDT" || d.Symbol == "ETHUSDT" {
-				return fmt.Errorf("BTC/ETH单币种仓位价值不能超过%.0f USDT（10倍账户净值），实际: %.0f", maxPositionValue, d.PositionSizeUSD)
+				return Reject(RejectMaxPositionValue, fmt.Errorf("BTC/ETH单币种仓位价值不能超过%.0f USDT（10倍账户净值），实际: %.0f", maxPositionValue, d.PositionSizeUSD))
 			} else {
-				return fmt.Errorf("山寨币单币种仓位价值不能超过%.0f USDT（1.5倍账户净值），实际: %.0f", maxPositionValue, d.PositionSizeUSD)
+				return Reject(RejectMaxPositionValue, fmt.Errorf("山寨币单币种仓位价值不能超过%.0f USDT（1.5倍账户净值），实际: %.0f", maxPositionValue, d.PositionSizeUSD))
 			}
 		}
 		if d.StopLoss <= 0 || d.TakeProfit <= 0 {
@@ -864,6 +1021,14 @@ func validateDecision(d *Decision, accountEquity float64, btcEthLeverage, altcoi
 			}
 		}
 
+		// 限价开仓：限价必须在止损价和止盈价之间
+		if d.LimitPrice < 0 {
+			return fmt.Errorf("限价不能为负数: %.4f", d.LimitPrice)
+		}
+		if d.LimitPrice > 0 && (d.LimitPrice <= math.Min(d.StopLoss, d.TakeProfit) || d.LimitPrice >= math.Max(d.StopLoss, d.TakeProfit)) {
+			return fmt.Errorf("限价 %.4f 必须在止损价 %.4f 和止盈价 %.4f 之间", d.LimitPrice, d.StopLoss, d.TakeProfit)
+		}
+
 		// 验证风险回报比（必须≥1:3）
 		// 计算入场价（假设当前市价）
 		var entryPrice float64
@@ -892,8 +1057,18 @@ func validateDecision(d *Decision, accountEquity float64, btcEthLeverage, altcoi
 
 		// 硬约束：风险回报比必须≥3.0
 		if riskRewardRatio < 3.0 {
-			return fmt.Errorf("风险回报比过低(%.2f:1)，必须≥3.0:1 [风险:%.2f%% 收益:%.2f%%] [止损:%.2f 止盈:%.2f]",
-				riskRewardRatio, riskPercent, rewardPercent, d.StopLoss, d.TakeProfit)
+			return Reject(RejectRiskReward, fmt.Errorf("风险回报比过低(%.2f:1)，必须≥3.0:1 [风险:%.2f%% 收益:%.2f%%] [止损:%.2f 止盈:%.2f]",
+				riskRewardRatio, riskPercent, rewardPercent, d.StopLoss, d.TakeProfit))
+		}
+	}
+
+	// 加仓验证（杠杆沿用持仓，止损止盈可选）
+	if d.Action == "add_to_position" {
+		if limits != nil && limits.EntriesBlocked {
+			return Reject(limits.BlockCode(), fmt.Errorf("动态风控已禁止加仓（%s）", limits.Reason))
+		}
+		if d.PositionSizeUSD <= 0 {
+			return fmt.Errorf("加仓金额必须大于0: %.2f", d.PositionSizeUSD)
 		}
 	}
 
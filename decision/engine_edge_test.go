@@ -1,6 +1,8 @@
 package decision
 
 import (
+	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -317,6 +319,24 @@ func TestValidateDecision_OpenLong_MinPositionSize(t *testing.T) {
 	assert.Contains(t, err.Error(), "开仓金额过小")
 }
 
+func TestValidateDecision_OpenLong_LimitPriceBetweenStops(t *testing.T) {
+	d := &Decision{
+		Symbol:          "SOLUSDT",
+		Action:          "open_long",
+		Leverage:        3,
+		PositionSizeUSD: 500,
+		StopLoss:        90,
+		TakeProfit:      150,
+		LimitPrice:      95,
+	}
+	assert.NoError(t, validateDecision(d, 1000, 10, 5))
+
+	d.LimitPrice = 85 // below stop loss
+	err := validateDecision(d, 1000, 10, 5)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "限价")
+}
+
 // ============================================================
 // parseFullDecisionResponse integration
 // ============================================================
@@ -334,7 +354,7 @@ BTC is looking bullish on multiple timeframes.
 ` + "```" + `
 </decision>`
 
-	fd, err := parseFullDecisionResponse(response, 1000, 10, 5)
+	fd, err := parseFullDecisionResponse(response, 1000, 10, 5, nil, PositionSizing{})
 	require.NoError(t, err)
 	require.NotNil(t, fd)
 	assert.Contains(t, fd.CoTTrace, "BTC is looking bullish")
@@ -342,8 +362,47 @@ BTC is looking bullish on multiple timeframes.
 	assert.Equal(t, "hold", fd.Decisions[0].Action)
 }
 
+func TestParseFullDecisionResponse_Note(t *testing.T) {
+	response := `<decision>
+` + "```json" + `
+[
+  {"symbol": "BTCUSDT", "action": "hold", "reasoning": "long analysis", "note": "  bullish RSI divergence\n"},
+  {"symbol": "ETHUSDT", "action": "wait", "reasoning": "no setup"}
+]
+` + "```" + `
+</decision>`
+
+	fd, err := parseFullDecisionResponse(response, 1000, 10, 5, nil, PositionSizing{})
+	require.NoError(t, err)
+	require.Len(t, fd.Decisions, 2)
+	assert.Equal(t, "bullish RSI divergence", fd.Decisions[0].Note)
+	assert.Equal(t, "long analysis", fd.Decisions[0].Reasoning)
+	assert.Empty(t, fd.Decisions[1].Note)
+}
+
+func TestNormalizeDecisionNotes_Truncates(t *testing.T) {
+	decisions := []Decision{{Note: strings.Repeat("多", maxDecisionNoteRunes+10)}}
+	normalizeDecisionNotes(decisions)
+	assert.Equal(t, maxDecisionNoteRunes, len([]rune(decisions[0].Note)))
+	assert.True(t, strings.HasSuffix(decisions[0].Note, "..."))
+}
+
+func TestNormalizeDecisionNotes_Boundary(t *testing.T) {
+	exact := strings.Repeat("多", maxDecisionNoteRunes)
+	decisions := []Decision{{Note: exact}, {Note: exact + "空"}}
+	normalizeDecisionNotes(decisions)
+	assert.Equal(t, exact, decisions[0].Note, "恰好等于上限时不截断")
+	assert.Equal(t, strings.Repeat("多", maxDecisionNoteRunes-3)+"...", decisions[1].Note)
+	assert.Equal(t, 60, maxDecisionNoteRunes, "与提示词中的 ≤60字 一致")
+}
+
+func TestBuildSystemPrompt_NoteLimitMatchesTruncation(t *testing.T) {
+	prompt := buildOutputFormatSection(1000, 5)
+	assert.Contains(t, prompt, fmt.Sprintf("（≤%d字）", maxDecisionNoteRunes))
+}
+
 func TestParseFullDecisionResponse_EmptyResponse(t *testing.T) {
-	fd, err := parseFullDecisionResponse("", 1000, 10, 5)
+	fd, err := parseFullDecisionResponse("", 1000, 10, 5, nil, PositionSizing{})
 	// Should produce a safe fallback, no crash
 	require.NoError(t, err)
 	require.NotNil(t, fd)
@@ -0,0 +1,22 @@
+package decision
+
+import "log"
+
+// DefaultHaltedZeroVolumeCandles 默认停牌检测阈值：最近连续这么多根3分钟K线成交量为 0 时本周期跳过该币种
+const DefaultHaltedZeroVolumeCandles = 5
+
+// dropHaltedDecisions 移除针对本周期疑似停牌币种的决策（停牌期间无法成交，AI仍可能根据持仓信息给出决策）
+func dropHaltedDecisions(ctx *Context, fd *FullDecision) {
+	if len(ctx.HaltedSymbols) == 0 || fd == nil {
+		return
+	}
+	kept := fd.Decisions[:0]
+	for _, d := range fd.Decisions {
+		if candles, halted := ctx.HaltedSymbols[d.Symbol]; halted {
+			log.Printf("⚠️  %s symbol appears halted（最近 %d 根K线成交量为 0），本周期跳过决策 %s", d.Symbol, candles, d.Action)
+			continue
+		}
+		kept = append(kept, d)
+	}
+	fd.Decisions = kept
+}
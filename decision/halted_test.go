@@ -0,0 +1,65 @@
+package decision
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"aspen/market"
+	"aspen/pool"
+
+	"github.com/agiledragon/gomonkey/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestHaltedSymbol_SkippedForCycle 测试最近多根K线成交量为 0 的币种本周期不进入提示词，AI针对它的决策被丢弃
+func TestHaltedSymbol_SkippedForCycle(t *testing.T) {
+	patches := gomonkey.ApplyFunc(market.GetWithIndicators, func(source market.DataSource, symbol string, params market.IndicatorParams) (*market.Data, error) {
+		data := &market.Data{Symbol: symbol, CurrentPrice: 100, CurrentRSI7: math.NaN(), NoOpenInterest: true}
+		if symbol == "ETHUSDT" {
+			data.ZeroVolumeCandles = 8
+		}
+		return data, nil
+	})
+	patches.ApplyFunc(pool.GetOITopPositions, func() ([]pool.OIPosition, error) {
+		return nil, errors.New("oi top disabled in test")
+	})
+	defer patches.Reset()
+
+	content := "<decision>\n```json\n[{\"symbol\": \"ETHUSDT\", \"action\": \"close_long\", \"reasoning\": \"exit\"}," +
+		" {\"symbol\": \"BTCUSDT\", \"action\": \"wait\", \"reasoning\": \"no setup\"}]\n```\n</decision>"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		resp, _ := json.Marshal(map[string]interface{}{
+			"choices": []map[string]interface{}{{"message": map[string]string{"content": content}}},
+		})
+		w.Write(resp)
+	}))
+	defer srv.Close()
+
+	ctx := &Context{
+		Account:         AccountInfo{TotalEquity: 1000, AvailableBalance: 1000},
+		Positions:       []PositionInfo{{Symbol: "ETHUSDT", Side: "long", EntryPrice: 100, MarkPrice: 100, Quantity: 1, Leverage: 5}},
+		CandidateCoins:  []CandidateCoin{{Symbol: "SOLUSDT"}},
+		BTCETHLeverage:  10,
+		AltcoinLeverage: 5,
+		HaltedCandles:   DefaultHaltedZeroVolumeCandles,
+	}
+	fd, err := GetFullDecision(ctx, newReviewClient(srv.URL))
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]int{"ETHUSDT": 8}, ctx.HaltedSymbols)
+	assert.NotContains(t, ctx.MarketDataMap, "ETHUSDT")
+	assert.Contains(t, ctx.MarketDataMap, "SOLUSDT")
+	require.Len(t, fd.Decisions, 1)
+	assert.Equal(t, "BTCUSDT", fd.Decisions[0].Symbol)
+
+	// 关闭检测时照常获取行情
+	ctx.HaltedCandles = 0
+	require.NoError(t, fetchMarketDataForContext(ctx))
+	assert.Empty(t, ctx.HaltedSymbols)
+	assert.Contains(t, ctx.MarketDataMap, "ETHUSDT")
+}
@@ -0,0 +1,158 @@
+package decision
+
+import (
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// 数字字段允许的单位写法
+const (
+	numberUnitNone     = iota
+	numberUnitUSD      // 金额/价格：$500、500USDT、500 USD
+	numberUnitPercent  // 百分比：5%
+	numberUnitLeverage // 杠杆：10x、10倍、10%
+)
+
+// numericField 决策中的数字字段
+type numericField struct {
+	integer bool // 整数字段（科学计数法和 10.0 这类整数值转为整数）
+	unit    int
+}
+
+// decisionNumericFields 需要规整的决策数字字段（只处理单位不会产生歧义的写法）
+var decisionNumericFields = map[string]numericField{
+	"leverage":          {integer: true, unit: numberUnitLeverage},
+	"confidence":        {integer: true, unit: numberUnitPercent},
+	"position_size_usd": {unit: numberUnitUSD},
+	"position_size_pct": {unit: numberUnitPercent},
+	"stop_loss":         {unit: numberUnitUSD},
+	"take_profit":       {unit: numberUnitUSD},
+	"limit_price":       {unit: numberUnitUSD},
+	"new_stop_loss":     {unit: numberUnitUSD},
+	"new_take_profit":   {unit: numberUnitUSD},
+	"close_percentage":  {unit: numberUnitPercent},
+	"risk_usd":          {unit: numberUnitUSD},
+}
+
+// numberUnitSuffixes 各单位允许的后缀（按长度从长到短匹配，不区分大小写）
+var numberUnitSuffixes = map[int][]string{
+	numberUnitUSD:      {"usdt", "usdc", "usd", "u", "$"},
+	numberUnitPercent:  {"%"},
+	numberUnitLeverage: {"倍", "x", "%"},
+}
+
+var reDecimalNumber = regexp.MustCompile(`^[+-]?(\d+\.?\d*|\.\d+)([eE][+-]?\d+)?$`)
+
+// normalizeDecisionNumbers 规整AI输出中常见的数字写法：科学计数法、带单位的金额（500USDT）、带百分号的杠杆（10%）、
+// 加引号的数字。只处理已知的数字字段，无法确定含义的值保持原样（由 json.Unmarshal 报错）
+func normalizeDecisionNumbers(s string) string {
+	var b strings.Builder
+	b.Grow(len(s))
+	for i := 0; i < len(s); {
+		if s[i] != '"' {
+			b.WriteByte(s[i])
+			i++
+			continue
+		}
+		end := jsonStringEnd(s, i)
+		if end < 0 {
+			b.WriteString(s[i:])
+			break
+		}
+		key := s[i+1 : end]
+		b.WriteString(s[i : end+1])
+		i = end + 1
+
+		field, ok := decisionNumericFields[key]
+		if !ok {
+			continue
+		}
+		colon := skipJSONSpace(s, i)
+		if colon >= len(s) || s[colon] != ':' {
+			continue
+		}
+		start := skipJSONSpace(s, colon+1)
+		if start >= len(s) {
+			continue
+		}
+
+		var raw string
+		valueEnd := start
+		if s[start] == '"' {
+			if valueEnd = jsonStringEnd(s, start); valueEnd < 0 {
+				continue
+			}
+			raw = s[start+1 : valueEnd]
+			valueEnd++
+		} else {
+			for valueEnd < len(s) && !strings.ContainsRune(",}]\n", rune(s[valueEnd])) {
+				valueEnd++
+			}
+			raw = s[start:valueEnd]
+		}
+		if num, ok := field.coerce(raw); ok {
+			b.WriteString(s[i:start])
+			b.WriteString(num)
+			if s[start] != '"' {
+				// 保留裸值后的空白（如换行前的缩进）
+				b.WriteString(raw[len(strings.TrimRight(raw, " \t\r")):])
+			}
+			i = valueEnd
+		}
+	}
+	return b.String()
+}
+
+// coerce 把字段值转为JSON数字，无法确定含义时返回 false
+func (f numericField) coerce(raw string) (string, bool) {
+	v := strings.ToLower(strings.TrimSpace(raw))
+	if f.unit == numberUnitUSD {
+		v = strings.TrimSpace(strings.TrimPrefix(v, "$"))
+	}
+	for _, suffix := range numberUnitSuffixes[f.unit] {
+		if strings.HasSuffix(v, suffix) {
+			v = strings.TrimSpace(strings.TrimSuffix(v, suffix))
+			break
+		}
+	}
+	if !reDecimalNumber.MatchString(v) {
+		return "", false
+	}
+	n, err := strconv.ParseFloat(v, 64)
+	if err != nil || math.IsInf(n, 0) || math.IsNaN(n) {
+		return "", false
+	}
+	if f.integer {
+		if n != math.Trunc(n) || math.Abs(n) > math.MaxInt32 {
+			return "", false
+		}
+		return strconv.FormatInt(int64(n), 10), true
+	}
+	return strconv.FormatFloat(n, 'f', -1, 64), true
+}
+
+// jsonStringEnd 从 start 处的引号开始，返回JSON字符串结束引号的位置（未闭合返回 -1）
+func jsonStringEnd(s string, start int) int {
+	escaped := false
+	for i := start + 1; i < len(s); i++ {
+		switch {
+		case escaped:
+			escaped = false
+		case s[i] == '\\':
+			escaped = true
+		case s[i] == '"':
+			return i
+		}
+	}
+	return -1
+}
+
+// skipJSONSpace 跳过空白字符
+func skipJSONSpace(s string, i int) int {
+	for i < len(s) && strings.ContainsRune(" \t\r\n", rune(s[i])) {
+		i++
+	}
+	return i
+}
@@ -0,0 +1,90 @@
+package decision
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalizeDecisionNumbers(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		check func(t *testing.T, d Decision)
+	}{
+		{
+			name:  "科学计数法",
+			input: `[{"symbol":"BTCUSDT","action":"open_long","leverage":5,"position_size_usd":5e3,"confidence":8e1}]`,
+			check: func(t *testing.T, d Decision) {
+				assert.Equal(t, 5000.0, d.PositionSizeUSD)
+				assert.Equal(t, 80, d.Confidence)
+			},
+		},
+		{
+			name:  "加引号的科学计数法",
+			input: `[{"symbol":"BTCUSDT","action":"open_long","leverage":"5","position_size_usd":"5e3"}]`,
+			check: func(t *testing.T, d Decision) {
+				assert.Equal(t, 5, d.Leverage)
+				assert.Equal(t, 5000.0, d.PositionSizeUSD)
+			},
+		},
+		{
+			name:  "金额带单位",
+			input: `[{"symbol":"BTCUSDT","action":"open_long","position_size_usd":500USDT,"stop_loss":"$95000.5","risk_usd":"20 USD"}]`,
+			check: func(t *testing.T, d Decision) {
+				assert.Equal(t, 500.0, d.PositionSizeUSD)
+				assert.Equal(t, 95000.5, d.StopLoss)
+				assert.Equal(t, 20.0, d.RiskUSD)
+			},
+		},
+		{
+			name: "杠杆带百分号",
+			input: `[{
+				"symbol": "ETHUSDT",
+				"action": "open_short",
+				"leverage": "10%",
+				"position_size_pct": 5%
+			}]`,
+			check: func(t *testing.T, d Decision) {
+				assert.Equal(t, 10, d.Leverage)
+				assert.Equal(t, 5.0, d.PositionSizePct)
+			},
+		},
+		{
+			name:  "杠杆带倍数",
+			input: `[{"symbol":"ETHUSDT","action":"open_short","leverage":3x}]`,
+			check: func(t *testing.T, d Decision) {
+				assert.Equal(t, 3, d.Leverage)
+			},
+		},
+		{
+			name:  "字符串内容不受影响",
+			input: `[{"symbol":"BTCUSDT","action":"wait","reasoning":"\"leverage\": 10% 太高, position_size_usd 500USDT"}]`,
+			check: func(t *testing.T, d Decision) {
+				assert.Equal(t, `"leverage": 10% 太高, position_size_usd 500USDT`, d.Reasoning)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decisions, err := extractDecisions(tt.input)
+			require.NoError(t, err)
+			require.Len(t, decisions, 1)
+			tt.check(t, decisions[0])
+		})
+	}
+}
+
+func TestNormalizeDecisionNumbers_Ambiguous(t *testing.T) {
+	// 金额字段的百分号和非整数杠杆含义不明确，保持原样由解析报错
+	for _, input := range []string{
+		`[{"symbol":"BTCUSDT","action":"open_long","position_size_usd":"5%"}]`,
+		`[{"symbol":"BTCUSDT","action":"open_long","leverage":2.5}]`,
+		`[{"symbol":"BTCUSDT","action":"open_long","leverage":"ten"}]`,
+	} {
+		_, err := extractDecisions(input)
+		assert.Error(t, err, input)
+	}
+}
@@ -0,0 +1,71 @@
+package decision
+
+import (
+	"log"
+	"strings"
+	"unicode/utf8"
+
+	"aspen/market"
+)
+
+// EstimateTokens 粗略估算文本的 token 数（不依赖具体模型的分词器）
+// ASCII 文本约 4 个字符 1 个 token；中文等非 ASCII 字符按每个字符 1 个 token 计，偏保守
+func EstimateTokens(text string) int {
+	ascii, other := 0, 0
+	for _, r := range text {
+		if r < utf8.RuneSelf {
+			ascii++
+		} else {
+			other++
+		}
+	}
+	return (ascii+3)/4 + other
+}
+
+// promptTrimOrder 超出预算时依次裁剪的市场数据分段（从最不重要的开始）
+// 当前价格和主要指标（SectionHeader）始终保留
+var promptTrimOrder = []market.Section{
+	market.SectionPatterns,
+	market.SectionIndicators,
+	market.SectionLongerTerm,
+	market.SectionIntraday,
+	market.SectionDerivatives,
+}
+
+// buildUserPromptWithinBudget 构建 User Prompt，系统和用户提示词的估算 token 数超过 ctx.PromptBudget 时
+// 按 promptTrimOrder 逐个去掉所有币种的市场数据分段，直到不超过预算；全部可裁剪分段去掉后仍超出时照常发送并记录警告
+func buildUserPromptWithinBudget(ctx *Context, systemPrompt string) string {
+	userPrompt := buildUserPrompt(ctx)
+	budget := ctx.PromptBudget
+	if budget <= 0 {
+		return userPrompt
+	}
+
+	systemTokens := EstimateTokens(systemPrompt)
+	original := systemTokens + EstimateTokens(userPrompt)
+	if original <= budget {
+		return userPrompt
+	}
+
+	flags := market.SectionFlags{}
+	var trimmed []string
+	total := original
+	for _, section := range promptTrimOrder {
+		flags[section] = false
+		trimmed = append(trimmed, string(section))
+		userPrompt = buildUserPromptWithSections(ctx, flags)
+		total = systemTokens + EstimateTokens(userPrompt)
+		if total <= budget {
+			break
+		}
+	}
+
+	if total > budget {
+		log.Printf("⚠️  提示词估算 %d tokens 超过预算 %d，已裁剪全部可裁剪分段 [%s] 后仍有 %d tokens，照常发送",
+			original, budget, strings.Join(trimmed, ", "), total)
+	} else {
+		log.Printf("✂️  提示词估算 %d tokens 超过预算 %d，已裁剪市场数据分段 [%s]，裁剪后 %d tokens",
+			original, budget, strings.Join(trimmed, ", "), total)
+	}
+	return userPrompt
+}
@@ -0,0 +1,101 @@
+package decision
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"testing"
+
+	"aspen/market"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// budgetMarketData 覆盖所有分段的市场数据
+func budgetMarketData(symbol string) *market.Data {
+	series := make([]float64, 40)
+	for i := range series {
+		series[i] = 100 + float64(i)*0.37
+	}
+	return &market.Data{
+		Symbol:       symbol,
+		CurrentPrice: 112.5,
+		OpenInterest: &market.OIData{Latest: 1e6, Average: 9.9e5},
+		FundingRate:  0.0001,
+		IntradaySeries: &market.IntradayData{
+			MidPrices: series, EMA20Values: series, MACDValues: series,
+			RSI7Values: series, RSI14Values: series, Volume: series,
+		},
+		LongerTermContext: &market.LongerTermData{MACDValues: series, RSI14Values: series},
+		RSIBuySignal:      true,
+	}
+}
+
+// budgetContext 多个候选币种的交易上下文
+func budgetContext(budget int) *Context {
+	ctx := &Context{
+		CurrentTime:   "2026-01-01 00:00:00",
+		Account:       AccountInfo{TotalEquity: 10000, AvailableBalance: 10000},
+		MarketDataMap: map[string]*market.Data{},
+		PromptBudget:  budget,
+	}
+	for i := 0; i < 8; i++ {
+		symbol := fmt.Sprintf("COIN%dUSDT", i)
+		ctx.CandidateCoins = append(ctx.CandidateCoins, CandidateCoin{Symbol: symbol})
+		ctx.MarketDataMap[symbol] = budgetMarketData(symbol)
+	}
+	return ctx
+}
+
+func TestEstimateTokens(t *testing.T) {
+	assert.Zero(t, EstimateTokens(""))
+	assert.Equal(t, 1, EstimateTokens("abcd"))
+	assert.Equal(t, 2, EstimateTokens("abcde"))
+	assert.Equal(t, 2, EstimateTokens("持仓"))
+	assert.Equal(t, 3, EstimateTokens("BTC 持仓"))
+}
+
+func TestBuildUserPromptWithinBudget_SmallPromptUntouched(t *testing.T) {
+	const systemPrompt = "system rules"
+	full := buildUserPrompt(budgetContext(0))
+
+	assert.Equal(t, full, buildUserPromptWithinBudget(budgetContext(0), systemPrompt), "未配置预算")
+	budget := EstimateTokens(systemPrompt) + EstimateTokens(full)
+	assert.Equal(t, full, buildUserPromptWithinBudget(budgetContext(budget), systemPrompt), "恰好等于预算")
+}
+
+func TestBuildUserPromptWithinBudget_TrimsLeastImportantSections(t *testing.T) {
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stderr)
+
+	const systemPrompt = "system rules"
+	full := buildUserPrompt(budgetContext(0))
+	fullTokens := EstimateTokens(systemPrompt) + EstimateTokens(full)
+
+	// 预算只够去掉附加指标和长期背景之后的提示词
+	withoutLonger := buildUserPromptWithSections(budgetContext(0), market.SectionFlags{
+		market.SectionPatterns: false, market.SectionIndicators: false, market.SectionLongerTerm: false,
+	})
+	budget := EstimateTokens(systemPrompt) + EstimateTokens(withoutLonger)
+	require.Less(t, budget, fullTokens)
+
+	trimmed := buildUserPromptWithinBudget(budgetContext(budget), systemPrompt)
+	assert.LessOrEqual(t, EstimateTokens(systemPrompt)+EstimateTokens(trimmed), budget)
+	assert.Equal(t, withoutLonger, trimmed)
+	assert.NotContains(t, trimmed, "Longer-term context")
+	assert.NotContains(t, trimmed, "Additional indicators")
+	assert.Contains(t, trimmed, "Intraday series", "预算足够时保留更重要的分段")
+	assert.Equal(t, 8, strings.Count(trimmed, "current_price ="), "所有币种保留当前价格")
+	assert.Contains(t, logs.String(), "[patterns, indicators, longer_term]")
+
+	// 即使去掉全部可裁剪分段仍超预算时照常发送（仅保留主要指标）并记录警告
+	logs.Reset()
+	minimal := buildUserPromptWithinBudget(budgetContext(10), systemPrompt)
+	assert.NotContains(t, minimal, "Funding Rate")
+	assert.Equal(t, 8, strings.Count(minimal, "current_price ="))
+	assert.Contains(t, logs.String(), "仍有")
+}
@@ -0,0 +1,147 @@
+package decision
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"aspen/market"
+	"aspen/metrics"
+)
+
+// PromptCacheConfig 行情没有明显变化时复用上一次AI决策（默认关闭）
+type PromptCacheConfig struct {
+	TolerancePct float64       // 各币种价格相对上次AI调用的变化都不超过该百分比（且系统提示词、币种和持仓不变）时复用，0 表示不启用
+	MaxAge       time.Duration // 同一个决策最长复用多久，超过后重新调用AI，0 表示不限制
+}
+
+// DefaultPromptCacheConfig 默认提示词缓存参数（未启用）
+func DefaultPromptCacheConfig() PromptCacheConfig {
+	return PromptCacheConfig{MaxAge: 15 * time.Minute}
+}
+
+// Enabled 是否启用
+func (c PromptCacheConfig) Enabled() bool {
+	return c.TolerancePct > 0
+}
+
+// PromptCache 单个交易员的提示词缓存，只保留最近一次AI调用的决策
+type PromptCache struct {
+	cfg PromptCacheConfig
+
+	mu    sync.Mutex
+	entry *promptCacheEntry
+}
+
+// promptCacheEntry 最近一次AI调用的行情快照和决策
+type promptCacheEntry struct {
+	snapshot promptSnapshot
+	decision FullDecision
+	storedAt time.Time
+}
+
+// promptSnapshot 一次AI调用的行情快照
+type promptSnapshot struct {
+	marketKey string             // 格式化后的市场数据的哈希（完全相同的行情直接命中）
+	stateKey  string             // 不随价格波动的部分的哈希：系统提示词、币种列表和持仓
+	prices    map[string]float64 // 各币种当前价格（按容差比较）
+}
+
+// NewPromptCache 创建提示词缓存（未启用时返回 nil）
+func NewPromptCache(cfg PromptCacheConfig) *PromptCache {
+	if !cfg.Enabled() {
+		return nil
+	}
+	return &PromptCache{cfg: cfg}
+}
+
+// newPromptSnapshot 按本周期的上下文和系统提示词生成行情快照
+func newPromptSnapshot(ctx *Context, systemPrompt string) promptSnapshot {
+	symbols := make([]string, 0, len(ctx.MarketDataMap))
+	for symbol := range ctx.MarketDataMap {
+		symbols = append(symbols, symbol)
+	}
+	sort.Strings(symbols)
+
+	marketHash := sha256.New()
+	stateHash := sha256.New()
+	stateHash.Write([]byte(systemPrompt))
+	prices := make(map[string]float64, len(symbols))
+	for _, symbol := range symbols {
+		data := ctx.MarketDataMap[symbol]
+		fmt.Fprintf(marketHash, "%s\n%s\n", symbol, market.Format(data))
+		fmt.Fprintf(stateHash, "|%s", symbol)
+		prices[symbol] = data.CurrentPrice
+	}
+	for _, pos := range ctx.Positions {
+		fmt.Fprintf(stateHash, "|%s:%s:%g:%d:%s", pos.Symbol, pos.Side, pos.Quantity, pos.Leverage, pos.PositionID)
+	}
+	return promptSnapshot{
+		marketKey: hex.EncodeToString(marketHash.Sum(nil)),
+		stateKey:  hex.EncodeToString(stateHash.Sum(nil)),
+		prices:    prices,
+	}
+}
+
+// maxPriceChangePct 两个快照之间各币种价格的最大变化百分比（币种不同或价格无效时返回 +Inf）
+func (s promptSnapshot) maxPriceChangePct(prev promptSnapshot) float64 {
+	if len(s.prices) != len(prev.prices) {
+		return math.Inf(1)
+	}
+	maxChange := 0.0
+	for symbol, price := range s.prices {
+		prevPrice, ok := prev.prices[symbol]
+		if !ok || prevPrice <= 0 {
+			return math.Inf(1)
+		}
+		maxChange = math.Max(maxChange, math.Abs(price-prevPrice)/prevPrice*100)
+	}
+	return maxChange
+}
+
+// lookup 行情与上次AI调用相同或在容差内时返回上次的决策（副本），以及最大价格变化百分比
+func (c *PromptCache) lookup(snapshot promptSnapshot, now time.Time) (*FullDecision, float64, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry := c.entry
+	if entry == nil || entry.snapshot.stateKey != snapshot.stateKey {
+		return nil, 0, false
+	}
+	if c.cfg.MaxAge > 0 && now.Sub(entry.storedAt) > c.cfg.MaxAge {
+		return nil, 0, false
+	}
+	change := 0.0
+	if entry.snapshot.marketKey != snapshot.marketKey {
+		change = snapshot.maxPriceChangePct(entry.snapshot)
+		if change > c.cfg.TolerancePct {
+			return nil, change, false
+		}
+	}
+	cached := entry.decision
+	cached.Decisions = append([]Decision(nil), entry.decision.Decisions...)
+	return &cached, change, true
+}
+
+// store 记录本次AI调用的行情快照和决策（保存副本，之后对决策的修改不影响缓存）
+func (c *PromptCache) store(snapshot promptSnapshot, decision *FullDecision, now time.Time) {
+	stored := *decision
+	stored.Decisions = append([]Decision(nil), decision.Decisions...)
+	stored.Review = nil
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entry = &promptCacheEntry{snapshot: snapshot, decision: stored, storedAt: now}
+}
+
+// recordPromptCacheResult 记录提示词缓存命中/未命中指标
+func recordPromptCacheResult(provider, model string, hit bool) {
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+	metrics.AIPromptCacheTotal.WithLabelValues(provider, model, result).Inc()
+}
@@ -0,0 +1,109 @@
+package decision
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"aspen/market"
+	"aspen/mcp"
+	"aspen/metrics"
+	"aspen/pool"
+
+	"github.com/agiledragon/gomonkey/v2"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// promptCacheResponse 假AI服务返回的决策
+const promptCacheResponse = "<decision>\n```json\n[{\"symbol\": \"BTCUSDT\", \"action\": \"wait\", \"reasoning\": \"no setup\"}]\n```\n</decision>"
+
+// patchMarketPrice 固定行情：BTCUSDT 当前价格取 *price，不加载 OI Top 数据
+func patchMarketPrice(t *testing.T, price *float64) {
+	t.Helper()
+	patches := gomonkey.ApplyFunc(market.GetWithIndicators, func(source market.DataSource, symbol string, params market.IndicatorParams) (*market.Data, error) {
+		return &market.Data{Symbol: symbol, CurrentPrice: *price, CurrentRSI7: math.NaN(), NoOpenInterest: true}, nil
+	})
+	patches.ApplyFunc(pool.GetOITopPositions, func() ([]pool.OIPosition, error) {
+		return nil, errors.New("oi top disabled in test")
+	})
+	t.Cleanup(patches.Reset)
+}
+
+// newCountingAIServer 构造返回固定决策的假AI服务，记录调用次数
+func newCountingAIServer(t *testing.T) (*mcp.Client, *atomic.Int32) {
+	t.Helper()
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		resp, _ := json.Marshal(map[string]interface{}{
+			"choices": []map[string]interface{}{{"message": map[string]string{"content": promptCacheResponse}}},
+		})
+		w.Write(resp)
+	}))
+	t.Cleanup(srv.Close)
+	return newReviewClient(srv.URL), &calls
+}
+
+// newPromptCacheContext 构造只有 BTCUSDT 的决策上下文
+func newPromptCacheContext(cache *PromptCache) *Context {
+	return &Context{
+		Account:         AccountInfo{TotalEquity: 1000, AvailableBalance: 1000},
+		BTCETHLeverage:  10,
+		AltcoinLeverage: 5,
+		PromptCache:     cache,
+	}
+}
+
+// TestPromptCache_IdenticalSnapshotsWithinToleranceCallAIOnce 测试行情在容差内时第二个周期复用决策，只调用一次AI
+func TestPromptCache_IdenticalSnapshotsWithinToleranceCallAIOnce(t *testing.T) {
+	price := 60000.0
+	patchMarketPrice(t, &price)
+	client, calls := newCountingAIServer(t)
+	hits := testutil.ToFloat64(metrics.AIPromptCacheTotal.WithLabelValues(string(client.Provider), client.Model, "hit"))
+
+	cache := NewPromptCache(PromptCacheConfig{TolerancePct: 0.5, MaxAge: time.Hour})
+	first, err := GetFullDecision(newPromptCacheContext(cache), client)
+	require.NoError(t, err)
+	assert.False(t, first.CacheHit)
+
+	// 价格变化 0.1%，在 0.5% 容差内
+	price = 60060
+	second, err := GetFullDecision(newPromptCacheContext(cache), client)
+	require.NoError(t, err)
+
+	assert.Equal(t, int32(1), calls.Load())
+	assert.True(t, second.CacheHit)
+	assert.Equal(t, first.Decisions, second.Decisions)
+	assert.Contains(t, second.UserPrompt, "60060")
+	assert.Equal(t, hits+1, testutil.ToFloat64(metrics.AIPromptCacheTotal.WithLabelValues(string(client.Provider), client.Model, "hit")))
+}
+
+// TestPromptCache_ChangeBeyondToleranceCallsAI 测试价格变化超过容差时重新调用AI
+func TestPromptCache_ChangeBeyondToleranceCallsAI(t *testing.T) {
+	price := 60000.0
+	patchMarketPrice(t, &price)
+	client, calls := newCountingAIServer(t)
+
+	cache := NewPromptCache(PromptCacheConfig{TolerancePct: 0.5, MaxAge: time.Hour})
+	_, err := GetFullDecision(newPromptCacheContext(cache), client)
+	require.NoError(t, err)
+
+	price = 61000
+	second, err := GetFullDecision(newPromptCacheContext(cache), client)
+	require.NoError(t, err)
+
+	assert.Equal(t, int32(2), calls.Load())
+	assert.False(t, second.CacheHit)
+}
+
+// TestPromptCache_DisabledByDefault 测试默认配置不启用缓存
+func TestPromptCache_DisabledByDefault(t *testing.T) {
+	assert.Nil(t, NewPromptCache(DefaultPromptCacheConfig()))
+}
@@ -0,0 +1,68 @@
+package decision
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// 自定义提示词中的替换占位符
+const (
+	PromptPlaceholderMarketData   = "{{market_data}}"   // 替换为市场数据块（账户、持仓、候选币种及其行情）
+	PromptPlaceholderOutputFormat = "{{output_format}}" // 替换为输出格式说明（决策解析依赖其中的XML标签和字段）
+)
+
+// PromptPlaceholder 提示词占位符说明
+type PromptPlaceholder struct {
+	Token       string `json:"token"`
+	Description string `json:"description"`
+	Required    bool   `json:"required"`
+}
+
+// PromptPlaceholders 自定义提示词支持的全部占位符
+var PromptPlaceholders = []PromptPlaceholder{
+	{Token: PromptPlaceholderMarketData, Description: "市场数据块：账户、持仓、候选币种及其行情指标", Required: true},
+	{Token: PromptPlaceholderOutputFormat, Description: "输出格式说明：<reasoning>/<decision> 标签和决策字段", Required: true},
+}
+
+// marketDataInlinedUserPrompt 市场数据已内联到系统提示词时发送的用户消息
+const marketDataInlinedUserPrompt = "市场数据已包含在系统提示中，请按输出格式给出交易决策。"
+
+var placeholderPattern = regexp.MustCompile(`\{\{\s*[A-Za-z0-9_]+\s*\}\}`)
+
+// ValidateCustomPrompt 检查自定义提示词是否包含必需的占位符，返回警告列表（为空表示通过）。
+// 缺少市场数据占位符的提示词替换基础提示词后，AI 只能在看不到行情的情况下做决策
+func ValidateCustomPrompt(prompt string) []string {
+	warnings := []string{}
+	if strings.TrimSpace(prompt) == "" {
+		return append(warnings, "提示词为空")
+	}
+
+	for _, p := range PromptPlaceholders {
+		if p.Required && !strings.Contains(prompt, p.Token) {
+			warnings = append(warnings, fmt.Sprintf("缺少必需占位符 %s（%s）", p.Token, p.Description))
+		}
+	}
+
+	known := make(map[string]bool, len(PromptPlaceholders))
+	for _, p := range PromptPlaceholders {
+		known[p.Token] = true
+	}
+	seen := make(map[string]bool)
+	for _, token := range placeholderPattern.FindAllString(prompt, -1) {
+		if known[token] || seen[token] {
+			continue
+		}
+		seen[token] = true
+		warnings = append(warnings, fmt.Sprintf("未知占位符 %s，不会被替换", token))
+	}
+	return warnings
+}
+
+// inlineMarketData 系统提示词包含市场数据占位符时，把用户提示词（市场数据块）替换到占位符位置
+func inlineMarketData(systemPrompt, userPrompt string) (string, string) {
+	if !strings.Contains(systemPrompt, PromptPlaceholderMarketData) {
+		return systemPrompt, userPrompt
+	}
+	return strings.ReplaceAll(systemPrompt, PromptPlaceholderMarketData, userPrompt), marketDataInlinedUserPrompt
+}
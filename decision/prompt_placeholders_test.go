@@ -0,0 +1,55 @@
+package decision
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestValidateCustomPrompt 测试缺少必需占位符和未知占位符的警告
+func TestValidateCustomPrompt(t *testing.T) {
+	tests := []struct {
+		name         string
+		prompt       string
+		wantWarnings []string // 每条警告应包含的内容
+	}{
+		{"全部占位符", "策略\n{{market_data}}\n{{output_format}}", nil},
+		{"缺少市场数据", "策略\n{{output_format}}", []string{PromptPlaceholderMarketData}},
+		{"未知占位符", "{{market_data}}{{output_format}}{{market_dta}}{{market_dta}}", []string{"{{market_dta}}"}},
+		{"空提示词", "  ", []string{"为空"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			warnings := ValidateCustomPrompt(tt.prompt)
+			if len(warnings) != len(tt.wantWarnings) {
+				t.Fatalf("warnings = %v, want %d", warnings, len(tt.wantWarnings))
+			}
+			for i, want := range tt.wantWarnings {
+				if !strings.Contains(warnings[i], want) {
+					t.Errorf("warnings[%d] = %q, want contains %q", i, warnings[i], want)
+				}
+			}
+		})
+	}
+}
+
+// TestPromptPlaceholderSubstitution 测试占位符替换：输出格式替换到自定义提示词中，市场数据内联到占位符位置
+func TestPromptPlaceholderSubstitution(t *testing.T) {
+	system := buildSystemPromptWithCustom(1000, 10, 5, "策略\n{{market_data}}\n{{output_format}}", true, "")
+	if strings.Contains(system, PromptPlaceholderOutputFormat) || !strings.Contains(system, "<decision>") {
+		t.Fatalf("输出格式占位符未替换: %s", system)
+	}
+
+	system, user := inlineMarketData(system, "BTC: 100000")
+	if strings.Contains(system, PromptPlaceholderMarketData) || !strings.Contains(system, "策略\nBTC: 100000\n") {
+		t.Errorf("市场数据未内联: %s", system)
+	}
+	if user != marketDataInlinedUserPrompt {
+		t.Errorf("user prompt = %q", user)
+	}
+
+	// 没有占位符时保持原样（市场数据作为用户消息发送）
+	system, user = inlineMarketData("策略", "BTC: 100000")
+	if system != "策略" || user != "BTC: 100000" {
+		t.Errorf("无占位符时不应修改: %q / %q", system, user)
+	}
+}
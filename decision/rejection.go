@@ -0,0 +1,63 @@
+package decision
+
+import (
+	"errors"
+	"fmt"
+)
+
+// 决策被拒绝的原因代码（记录在决策日志中，向用户说明AI的决策为什么没有执行）
+const (
+	RejectInvalidDecision  = "invalid_decision"   // 决策参数无效（动作、止损止盈、平仓比例等）
+	RejectLeverage         = "leverage"           // 杠杆无效
+	RejectMinPositionSize  = "min_position_size"  // 开仓金额低于交易所最小名义价值
+	RejectMaxPositionValue = "max_position_value" // 仓位价值超过单币种上限
+	RejectRiskReward       = "risk_reward"        // 风险回报比过低
+	RejectMaxDailyLoss     = "max_daily_loss"     // 当日亏损接近上限，禁止开仓
+	RejectMaxDrawdown      = "max_drawdown"       // 回撤接近上限，禁止开仓
+	RejectMinEquity        = "min_equity"         // 账户净值低于下限，禁止开仓
+)
+
+// RejectionError 带原因代码的决策拒绝（错误信息与原错误相同）
+type RejectionError struct {
+	Code string
+	Err  error
+}
+
+func (e *RejectionError) Error() string { return e.Err.Error() }
+
+func (e *RejectionError) Unwrap() error { return e.Err }
+
+// Reject 为拒绝决策的错误附加原因代码
+func Reject(code string, err error) error {
+	return &RejectionError{Code: code, Err: err}
+}
+
+// RejectionCode 错误链中的拒绝原因代码（不是决策拒绝时返回空字符串）
+func RejectionCode(err error) string {
+	var rejection *RejectionError
+	if errors.As(err, &rejection) {
+		return rejection.Code
+	}
+	return ""
+}
+
+// ValidationError AI输出的某个决策未通过验证（整批决策都不会执行）
+type ValidationError struct {
+	Index    int // 决策在AI输出中的序号（从0开始）
+	Decision Decision
+	Err      error
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("决策 #%d 验证失败: %v", e.Index+1, e.Err)
+}
+
+func (e *ValidationError) Unwrap() error { return e.Err }
+
+// Code 拒绝原因代码（未细分的验证失败为 invalid_decision）
+func (e *ValidationError) Code() string {
+	if code := RejectionCode(e.Err); code != "" {
+		return code
+	}
+	return RejectInvalidDecision
+}
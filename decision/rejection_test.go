@@ -0,0 +1,38 @@
+package decision
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestValidateDecisions_RejectionCodes 测试验证失败时返回被拒绝的决策序号和具体原因代码
+func TestValidateDecisions_RejectionCodes(t *testing.T) {
+	blocked := ComputeRiskLimits(DefaultRiskScalingConfig(5, 20), 1000, -4.5, 0, 5, 5)
+	tests := []struct {
+		name     string
+		decision Decision
+		limits   *RiskLimits
+		wantCode string
+	}{
+		{"开仓金额过小", Decision{Symbol: "SOLUSDT", Action: "open_long", Leverage: 5, PositionSizeUSD: 1, StopLoss: 90, TakeProfit: 150}, nil, RejectMinPositionSize},
+		{"当日亏损接近上限", Decision{Symbol: "SOLUSDT", Action: "open_long", Leverage: 5, PositionSizeUSD: 200, StopLoss: 90, TakeProfit: 150}, blocked, RejectMaxDailyLoss},
+		{"杠杆无效", Decision{Symbol: "SOLUSDT", Action: "open_long", Leverage: 0, PositionSizeUSD: 200, StopLoss: 90, TakeProfit: 150}, nil, RejectLeverage},
+		{"未细分的无效决策", Decision{Symbol: "SOLUSDT", Action: "partial_close", ClosePercentage: 120}, nil, RejectInvalidDecision},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decisions := []Decision{{Symbol: "BTCUSDT", Action: "hold"}, tt.decision}
+			err := validateDecisions(decisions, 1000, 10, 5, tt.limits)
+
+			var invalid *ValidationError
+			require.True(t, errors.As(err, &invalid), "err: %v", err)
+			assert.Equal(t, 1, invalid.Index)
+			assert.Equal(t, tt.decision.Action, invalid.Decision.Action)
+			assert.Equal(t, tt.wantCode, invalid.Code())
+			assert.Contains(t, err.Error(), "决策 #2 验证失败")
+		})
+	}
+}
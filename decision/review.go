@@ -0,0 +1,328 @@
+package decision
+
+import (
+	"aspen/mcp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// ReviewTriggers 两级模型的复核触发条件（满足任一条件的决策需复核模型确认后执行）
+type ReviewTriggers struct {
+	Open               bool    `json:"open"`                   // 所有开仓决策
+	CloseAtLossOverPct float64 `json:"close_at_loss_over_pct"` // 平仓/部分平仓时持仓亏损超过该百分比（0 表示不检查）
+	SizeOverUSD        float64 `json:"size_over_usd"`          // 开仓仓位价值超过该金额（0 表示不检查）
+}
+
+// Enabled 是否配置了任一触发条件
+func (t ReviewTriggers) Enabled() bool {
+	return t.Open || t.CloseAtLossOverPct > 0 || t.SizeOverUSD > 0
+}
+
+// ParseReviewTriggers 解析存储的复核触发条件（JSON格式，空字符串表示未配置）
+func ParseReviewTriggers(raw string) (ReviewTriggers, error) {
+	var triggers ReviewTriggers
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return triggers, nil
+	}
+	if err := json.Unmarshal([]byte(raw), &triggers); err != nil {
+		return ReviewTriggers{}, fmt.Errorf("复核触发条件格式错误: %w", err)
+	}
+	if triggers.CloseAtLossOverPct < 0 || triggers.SizeOverUSD < 0 {
+		return ReviewTriggers{}, fmt.Errorf("复核触发条件不能为负数")
+	}
+	return triggers, nil
+}
+
+// 复核模型不可用（调用失败、超时、输出无法解析）时的处理方式
+const (
+	ReviewFallbackHold    = "hold"    // 放弃需要复核的决策（默认）
+	ReviewFallbackExecute = "execute" // 不经复核按扫描模型的决策执行
+)
+
+// NormalizeReviewFallback 校验复核不可用时的处理方式（空值使用 hold）
+func NormalizeReviewFallback(fallback string) (string, error) {
+	switch strings.ToLower(strings.TrimSpace(fallback)) {
+	case "", ReviewFallbackHold:
+		return ReviewFallbackHold, nil
+	case ReviewFallbackExecute:
+		return ReviewFallbackExecute, nil
+	}
+	return "", fmt.Errorf("无效的复核降级方式: %s（可选 hold / execute）", fallback)
+}
+
+// ReviewConfig 复核配置
+type ReviewConfig struct {
+	Triggers ReviewTriggers
+	Fallback string // ReviewFallbackHold / ReviewFallbackExecute
+}
+
+// 复核结论
+const (
+	VerdictApprove = "approve" // 同意执行
+	VerdictVeto    = "veto"    // 否决
+	VerdictModify  = "modify"  // 调整参数后执行
+)
+
+// ReviewVerdict 复核模型对单个决策的结论（约束的JSON输出格式）
+type ReviewVerdict struct {
+	Index   int    `json:"index"`   // 待复核决策的序号
+	Verdict string `json:"verdict"` // approve / veto / modify
+	Reason  string `json:"reason"`
+	// modify 时调整的参数（未填写的保持原值；仓位和杠杆只能调低）
+	Leverage        int     `json:"leverage,omitempty"`
+	PositionSizeUSD float64 `json:"position_size_usd,omitempty"`
+	StopLoss        float64 `json:"stop_loss,omitempty"`
+	TakeProfit      float64 `json:"take_profit,omitempty"`
+	ClosePercentage float64 `json:"close_percentage,omitempty"`
+}
+
+// ReviewedDecision 一个需要复核的决策及复核结果
+type ReviewedDecision struct {
+	Proposed Decision  `json:"proposed"` // 扫描模型的原始决策
+	Verdict  string    `json:"verdict"`  // approve / veto / modify，复核不可用时为 fallback_hold / fallback_execute
+	Reason   string    `json:"reason,omitempty"`
+	Final    *Decision `json:"final,omitempty"` // 最终执行的决策（否决或放弃时为空）
+}
+
+// DecisionReview 本周期的复核过程
+type DecisionReview struct {
+	Model       string             `json:"model"`                  // 复核模型
+	Items       []ReviewedDecision `json:"items"`                  // 需要复核的决策及结论
+	RawResponse string             `json:"raw_response,omitempty"` // 复核模型原始输出
+	DurationMs  int64              `json:"duration_ms"`
+	Error       string             `json:"error,omitempty"`    // 复核模型不可用的原因
+	Fallback    string             `json:"fallback,omitempty"` // 复核不可用时采用的处理方式
+}
+
+// needsReview 决策是否满足复核触发条件，返回触发原因
+func needsReview(d Decision, ctx *Context, triggers ReviewTriggers) (string, bool) {
+	switch d.Action {
+	case "open_long", "open_short", "add_to_position":
+		if triggers.Open {
+			return "开仓", true
+		}
+		if triggers.SizeOverUSD > 0 && d.PositionSizeUSD > triggers.SizeOverUSD {
+			return fmt.Sprintf("仓位 %.0f 超过 %.0f", d.PositionSizeUSD, triggers.SizeOverUSD), true
+		}
+	case "close_long", "close_short", "partial_close":
+		if triggers.CloseAtLossOverPct <= 0 {
+			return "", false
+		}
+		for _, pos := range ctx.Positions {
+			if pos.Symbol != d.Symbol {
+				continue
+			}
+			if d.PositionID != "" && pos.PositionID != d.PositionID {
+				continue
+			}
+			if (d.Action == "close_long" && pos.Side != "long") || (d.Action == "close_short" && pos.Side != "short") {
+				continue
+			}
+			if pos.UnrealizedPnLPct <= -triggers.CloseAtLossOverPct {
+				return fmt.Sprintf("亏损 %.2f%% 平仓", pos.UnrealizedPnLPct), true
+			}
+		}
+	}
+	return "", false
+}
+
+// buildReviewSystemPrompt 复核模型的系统提示词（约束输出格式）
+func buildReviewSystemPrompt() string {
+	var sb strings.Builder
+	sb.WriteString("你是加密货币交易的风控复核员。另一个模型根据下面的市场快照给出了交易决策，请逐个复核标记的决策：\n")
+	sb.WriteString("- approve: 决策合理，按原参数执行\n")
+	sb.WriteString("- veto: 决策不合理（逆势、证据不足、风险过高等），不执行\n")
+	sb.WriteString("- modify: 方向合理但参数需要调整，只能调低 leverage / position_size_usd / close_percentage，或调整 stop_loss / take_profit\n\n")
+	sb.WriteString("# 输出格式 (严格遵守)\n\n")
+	sb.WriteString("只输出一个JSON数组，每个待复核决策一项，不要输出其他内容：\n")
+	sb.WriteString("```json\n[\n")
+	sb.WriteString("  {\"index\": 0, \"verdict\": \"approve\", \"reason\": \"趋势和量能一致\"},\n")
+	sb.WriteString("  {\"index\": 1, \"verdict\": \"modify\", \"reason\": \"波动偏大，降低仓位\", \"position_size_usd\": 300, \"leverage\": 3},\n")
+	sb.WriteString("  {\"index\": 2, \"verdict\": \"veto\", \"reason\": \"4小时趋势向下，不宜做多\"}\n")
+	sb.WriteString("]\n```\n")
+	return sb.String()
+}
+
+// buildReviewUserPrompt 复核模型的输入：扫描模型看到的同一份快照 + 待复核决策
+func buildReviewUserPrompt(snapshot string, pending []Decision) string {
+	var sb strings.Builder
+	sb.WriteString(snapshot)
+	sb.WriteString("\n\n# 待复核决策\n\n")
+	for i, d := range pending {
+		data, _ := json.Marshal(d)
+		sb.WriteString(fmt.Sprintf("[%d] %s\n", i, data))
+	}
+	return sb.String()
+}
+
+// parseReviewVerdicts 解析复核模型输出，每个待复核决策必须有且仅有一个合法结论
+func parseReviewVerdicts(response string, count int) ([]ReviewVerdict, error) {
+	s := fixMissingQuotes(strings.TrimSpace(removeInvisibleRunes(response)))
+	if match := reDecisionTag.FindStringSubmatch(s); match != nil {
+		s = strings.TrimSpace(match[1])
+	}
+
+	var jsonContent string
+	if m := reJSONFence.FindStringSubmatch(s); m != nil {
+		jsonContent = m[1]
+	} else {
+		jsonContent = reJSONArray.FindString(s)
+	}
+	if jsonContent == "" {
+		return nil, fmt.Errorf("复核输出中没有JSON数组")
+	}
+
+	var verdicts []ReviewVerdict
+	if err := json.Unmarshal([]byte(compactArrayOpen(strings.TrimSpace(jsonContent))), &verdicts); err != nil {
+		return nil, fmt.Errorf("复核输出JSON解析失败: %w", err)
+	}
+
+	byIndex := make([]*ReviewVerdict, count)
+	for i := range verdicts {
+		v := &verdicts[i]
+		if v.Index < 0 || v.Index >= count {
+			return nil, fmt.Errorf("复核结论序号 %d 超出范围（共 %d 个待复核决策）", v.Index, count)
+		}
+		if byIndex[v.Index] != nil {
+			return nil, fmt.Errorf("复核结论序号 %d 重复", v.Index)
+		}
+		v.Verdict = strings.ToLower(strings.TrimSpace(v.Verdict))
+		switch v.Verdict {
+		case VerdictApprove, VerdictVeto, VerdictModify:
+		default:
+			return nil, fmt.Errorf("无效的复核结论: %q", v.Verdict)
+		}
+		byIndex[v.Index] = v
+	}
+
+	out := make([]ReviewVerdict, count)
+	for i, v := range byIndex {
+		if v == nil {
+			return nil, fmt.Errorf("缺少第 %d 个决策的复核结论", i)
+		}
+		out[i] = *v
+	}
+	return out, nil
+}
+
+// applyModification 应用复核模型的参数调整（仓位、杠杆和平仓比例只能调低），调整后的决策需通过校验
+func applyModification(d Decision, v ReviewVerdict, ctx *Context) (Decision, error) {
+	if v.Leverage > 0 && v.Leverage < d.Leverage {
+		d.Leverage = v.Leverage
+	}
+	if v.PositionSizeUSD > 0 && v.PositionSizeUSD < d.PositionSizeUSD {
+		d.PositionSizeUSD = v.PositionSizeUSD
+		d.PositionSizePct = 0 // 复核模型按金额调低仓位，执行时不再按百分比重新换算
+	}
+	if v.ClosePercentage > 0 && v.ClosePercentage < d.ClosePercentage {
+		d.ClosePercentage = v.ClosePercentage
+	}
+	if v.StopLoss > 0 {
+		d.StopLoss = v.StopLoss
+	}
+	if v.TakeProfit > 0 {
+		d.TakeProfit = v.TakeProfit
+	}
+	if err := validateDecisionWithLimits(&d, ctx.Account.TotalEquity, ctx.BTCETHLeverage, ctx.AltcoinLeverage, ctx.RiskLimits); err != nil {
+		return d, err
+	}
+	return d, nil
+}
+
+// ReviewDecisions 两级模型复核：扫描模型的决策中满足触发条件的，交给复核模型确认、否决或调整
+// 复核模型使用与扫描模型相同的快照（full.UserPrompt）和本周期调用上下文（共享周期时间预算），
+// 复核不可用时按 cfg.Fallback 执行或放弃，并记录在返回的复核过程中；没有需要复核的决策时返回 nil
+func ReviewDecisions(ctx *Context, full *FullDecision, reviewer *mcp.Client, cfg ReviewConfig) *DecisionReview {
+	var pending []Decision
+	pendingIdx := make(map[int]int) // full.Decisions 序号 -> pending 序号
+	for i, d := range full.Decisions {
+		if reason, ok := needsReview(d, ctx, cfg.Triggers); ok {
+			log.Printf("🔍 %s %s 需要复核（%s）", d.Symbol, d.Action, reason)
+			pendingIdx[i] = len(pending)
+			pending = append(pending, d)
+		}
+	}
+	if len(pending) == 0 {
+		return nil
+	}
+
+	review := &DecisionReview{Model: reviewer.Model, Items: make([]ReviewedDecision, len(pending))}
+	for i, d := range pending {
+		review.Items[i].Proposed = d
+	}
+
+	callCtx := ctx.CallCtx
+	if callCtx == nil {
+		callCtx = context.Background()
+	}
+	start := time.Now()
+	response, err := reviewer.CallWithMessagesContext(callCtx, buildReviewSystemPrompt(), buildReviewUserPrompt(full.UserPrompt, pending))
+	review.DurationMs = time.Since(start).Milliseconds()
+	review.RawResponse = response
+
+	var verdicts []ReviewVerdict
+	if err == nil {
+		verdicts, err = parseReviewVerdicts(response, len(pending))
+	}
+	if err != nil {
+		review.Error = err.Error()
+		review.Fallback = cfg.Fallback
+		if review.Fallback != ReviewFallbackExecute {
+			review.Fallback = ReviewFallbackHold
+		}
+		log.Printf("⚠️  复核模型 %s 不可用（%v），%d 个待复核决策按 %s 处理", reviewer.Model, err, len(pending), review.Fallback)
+	}
+
+	var final []Decision
+	for i, d := range full.Decisions {
+		p, ok := pendingIdx[i]
+		if !ok {
+			final = append(final, d)
+			continue
+		}
+		item := &review.Items[p]
+
+		if verdicts == nil {
+			item.Verdict = "fallback_" + review.Fallback
+			item.Reason = review.Error
+			if review.Fallback == ReviewFallbackExecute {
+				executed := d
+				item.Final = &executed
+				final = append(final, d)
+			}
+			continue
+		}
+
+		v := verdicts[p]
+		item.Verdict, item.Reason = v.Verdict, v.Reason
+		switch v.Verdict {
+		case VerdictApprove:
+			executed := d
+			item.Final = &executed
+			final = append(final, d)
+		case VerdictModify:
+			modified, err := applyModification(d, v, ctx)
+			if err != nil {
+				// 调整后的参数不合法时按否决处理
+				item.Verdict = VerdictVeto
+				item.Reason = fmt.Sprintf("%s（调整后参数无效: %v）", v.Reason, err)
+				log.Printf("🛑 复核否决 %s %s: %s", d.Symbol, d.Action, item.Reason)
+				continue
+			}
+			item.Final = &modified
+			final = append(final, modified)
+			log.Printf("✏️  复核调整 %s %s: %s", d.Symbol, d.Action, v.Reason)
+		case VerdictVeto:
+			log.Printf("🛑 复核否决 %s %s: %s", d.Symbol, d.Action, v.Reason)
+		}
+	}
+
+	full.Decisions = final
+	full.Review = review
+	return review
+}
@@ -0,0 +1,243 @@
+package decision
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"aspen/mcp"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newReviewServer 构造返回固定复核输出的假AI服务（delay 为响应延迟），记录收到的用户提示词
+func newReviewServer(t *testing.T, content string, delay time.Duration) (*httptest.Server, *atomic.Value) {
+	t.Helper()
+	var lastPrompt atomic.Value
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Messages []struct {
+				Role    string `json:"role"`
+				Content string `json:"content"`
+			} `json:"messages"`
+		}
+		body, _ := io.ReadAll(r.Body)
+		json.Unmarshal(body, &req)
+		for _, m := range req.Messages {
+			if m.Role == "user" {
+				lastPrompt.Store(m.Content)
+			}
+		}
+		select {
+		case <-time.After(delay):
+		case <-r.Context().Done():
+			return
+		}
+		resp, _ := json.Marshal(map[string]interface{}{
+			"choices": []map[string]interface{}{{"message": map[string]string{"content": content}}},
+		})
+		w.Write(resp)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &lastPrompt
+}
+
+// newReviewClient 构造指向假AI服务的复核模型客户端
+func newReviewClient(url string) *mcp.Client {
+	client := mcp.New()
+	client.SetCustomAPI(url, "sk-test", "review-model")
+	client.Budget = mcp.CallBudget{Total: 300 * time.Millisecond, FirstAttemptShare: 1, MinAttempt: 50 * time.Millisecond, MaxAttempts: 1}
+	return client
+}
+
+// reviewFixture 扫描模型的决策：一个开仓、一个亏损持仓平仓、一个不需要复核的 hold
+func reviewFixture() (*Context, *FullDecision) {
+	ctx := &Context{
+		Account:         AccountInfo{TotalEquity: 10000},
+		BTCETHLeverage:  10,
+		AltcoinLeverage: 5,
+		Positions: []PositionInfo{
+			{Symbol: "SOLUSDT", Side: "short", UnrealizedPnLPct: -12},
+		},
+	}
+	full := &FullDecision{
+		UserPrompt: "market snapshot",
+		Decisions: []Decision{
+			{Symbol: "SOLUSDT", Action: "close_short", Reasoning: "止损"},
+			{Symbol: "BTCUSDT", Action: "open_long", Leverage: 5, PositionSizeUSD: 1000, StopLoss: 95000, TakeProfit: 110000, Reasoning: "突破"},
+			{Symbol: "ETHUSDT", Action: "hold", Reasoning: "观望"},
+		},
+	}
+	return ctx, full
+}
+
+var reviewTriggers = ReviewTriggers{Open: true, CloseAtLossOverPct: 10}
+
+func TestNeedsReview(t *testing.T) {
+	ctx, full := reviewFixture()
+	_, ok := needsReview(full.Decisions[0], ctx, reviewTriggers)
+	assert.True(t, ok, "亏损 12% 超过 10% 的平仓需要复核")
+	_, ok = needsReview(full.Decisions[0], ctx, ReviewTriggers{CloseAtLossOverPct: 15})
+	assert.False(t, ok)
+	_, ok = needsReview(full.Decisions[1], ctx, ReviewTriggers{SizeOverUSD: 500})
+	assert.True(t, ok)
+	_, ok = needsReview(full.Decisions[1], ctx, ReviewTriggers{SizeOverUSD: 5000})
+	assert.False(t, ok)
+	_, ok = needsReview(full.Decisions[2], ctx, reviewTriggers)
+	assert.False(t, ok)
+}
+
+func TestReviewDecisions_Approve(t *testing.T) {
+	srv, prompt := newReviewServer(t, `[{"index":0,"verdict":"approve","reason":"止损合理"},{"index":1,"verdict":"approve","reason":"趋势确认"}]`, 0)
+	ctx, full := reviewFixture()
+	original := append([]Decision(nil), full.Decisions...)
+
+	review := ReviewDecisions(ctx, full, newReviewClient(srv.URL), ReviewConfig{Triggers: reviewTriggers})
+	require.NotNil(t, review)
+	assert.Equal(t, "review-model", review.Model)
+	assert.Empty(t, review.Error)
+	assert.Equal(t, original, full.Decisions)
+	assert.Same(t, review, full.Review)
+	require.Len(t, review.Items, 2)
+	assert.Equal(t, VerdictApprove, review.Items[1].Verdict)
+	assert.Equal(t, original[1], *review.Items[1].Final)
+
+	// 复核模型收到扫描模型的快照和待复核决策（不含 hold）
+	sent := prompt.Load().(string)
+	assert.True(t, strings.HasPrefix(sent, "market snapshot"))
+	assert.Contains(t, sent, `[1] {"symbol":"BTCUSDT","action":"open_long"`)
+	assert.NotContains(t, sent, "ETHUSDT")
+}
+
+func TestReviewDecisions_Veto(t *testing.T) {
+	srv, _ := newReviewServer(t, "<decision>\n```json\n[{\"index\":0,\"verdict\":\"approve\",\"reason\":\"ok\"},{\"index\":1,\"verdict\":\"VETO\",\"reason\":\"4h 下跌趋势\"}]\n```\n</decision>", 0)
+	ctx, full := reviewFixture()
+
+	review := ReviewDecisions(ctx, full, newReviewClient(srv.URL), ReviewConfig{Triggers: reviewTriggers})
+	require.NotNil(t, review)
+	require.Len(t, full.Decisions, 2)
+	assert.Equal(t, "close_short", full.Decisions[0].Action)
+	assert.Equal(t, "hold", full.Decisions[1].Action)
+	assert.Equal(t, VerdictVeto, review.Items[1].Verdict)
+	assert.Equal(t, "4h 下跌趋势", review.Items[1].Reason)
+	assert.Nil(t, review.Items[1].Final)
+	assert.Equal(t, "open_long", review.Items[1].Proposed.Action, "保留扫描模型的原始决策")
+}
+
+func TestReviewDecisions_Modify(t *testing.T) {
+	srv, _ := newReviewServer(t, `[{"index":0,"verdict":"approve","reason":"ok"},{"index":1,"verdict":"modify","reason":"降低仓位","position_size_usd":400,"leverage":8,"stop_loss":96000}]`, 0)
+	ctx, full := reviewFixture()
+
+	review := ReviewDecisions(ctx, full, newReviewClient(srv.URL), ReviewConfig{Triggers: reviewTriggers})
+	require.NotNil(t, review)
+	require.Len(t, full.Decisions, 3)
+	modified := full.Decisions[1]
+	assert.Equal(t, 400.0, modified.PositionSizeUSD)
+	assert.Equal(t, 5, modified.Leverage, "杠杆只能调低")
+	assert.Equal(t, 96000.0, modified.StopLoss)
+	assert.Equal(t, VerdictModify, review.Items[1].Verdict)
+	assert.Equal(t, modified, *review.Items[1].Final)
+	assert.Equal(t, 1000.0, review.Items[1].Proposed.PositionSizeUSD)
+
+	// 调整后参数无效（止损高于止盈）时按否决处理
+	srv, _ = newReviewServer(t, `[{"index":0,"verdict":"approve","reason":"ok"},{"index":1,"verdict":"modify","reason":"收紧止损","stop_loss":120000}]`, 0)
+	ctx, full = reviewFixture()
+	review = ReviewDecisions(ctx, full, newReviewClient(srv.URL), ReviewConfig{Triggers: reviewTriggers})
+	require.NotNil(t, review)
+	assert.Equal(t, VerdictVeto, review.Items[1].Verdict)
+	assert.Contains(t, review.Items[1].Reason, "调整后参数无效")
+	assert.Len(t, full.Decisions, 2)
+}
+
+func TestReviewDecisions_MalformedOutput(t *testing.T) {
+	outputs := map[string]string{
+		"非JSON": "我认为这些决策都不错",
+		"缺少结论":  `[{"index":0,"verdict":"approve","reason":"ok"}]`,
+		"无效结论":  `[{"index":0,"verdict":"approve"},{"index":1,"verdict":"maybe"}]`,
+		"序号越界":  `[{"index":0,"verdict":"approve"},{"index":5,"verdict":"approve"}]`,
+		"序号重复":  `[{"index":0,"verdict":"approve"},{"index":0,"verdict":"veto"}]`,
+	}
+	for name, output := range outputs {
+		t.Run(name, func(t *testing.T) {
+			srv, _ := newReviewServer(t, output, 0)
+
+			// 默认降级：放弃需要复核的决策
+			ctx, full := reviewFixture()
+			review := ReviewDecisions(ctx, full, newReviewClient(srv.URL), ReviewConfig{Triggers: reviewTriggers})
+			require.NotNil(t, review)
+			assert.NotEmpty(t, review.Error)
+			assert.Equal(t, ReviewFallbackHold, review.Fallback)
+			assert.Equal(t, output, review.RawResponse)
+			require.Len(t, full.Decisions, 1)
+			assert.Equal(t, "hold", full.Decisions[0].Action)
+			assert.Equal(t, "fallback_hold", review.Items[0].Verdict)
+
+			// 配置为不经复核执行
+			ctx, full = reviewFixture()
+			review = ReviewDecisions(ctx, full, newReviewClient(srv.URL), ReviewConfig{Triggers: reviewTriggers, Fallback: ReviewFallbackExecute})
+			require.NotNil(t, review)
+			assert.Equal(t, ReviewFallbackExecute, review.Fallback)
+			assert.Len(t, full.Decisions, 3)
+			assert.Equal(t, "fallback_execute", review.Items[1].Verdict)
+		})
+	}
+}
+
+func TestReviewDecisions_Timeout(t *testing.T) {
+	srv, _ := newReviewServer(t, `[{"index":0,"verdict":"approve"},{"index":1,"verdict":"approve"}]`, 2*time.Second)
+	ctx, full := reviewFixture()
+	// 周期剩余时间预算由调用上下文传入，复核模型与扫描模型共享
+	callCtx, cancel := context.WithTimeout(context.Background(), 150*time.Millisecond)
+	defer cancel()
+	ctx.CallCtx = callCtx
+
+	start := time.Now()
+	review := ReviewDecisions(ctx, full, newReviewClient(srv.URL), ReviewConfig{Triggers: reviewTriggers})
+	assert.Less(t, time.Since(start), time.Second)
+	require.NotNil(t, review)
+	assert.NotEmpty(t, review.Error)
+	assert.Equal(t, ReviewFallbackHold, review.Fallback)
+	require.Len(t, full.Decisions, 1)
+	assert.Equal(t, "hold", full.Decisions[0].Action)
+}
+
+func TestReviewDecisions_NothingToReview(t *testing.T) {
+	var hits int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+	}))
+	defer srv.Close()
+
+	ctx, full := reviewFixture()
+	full.Decisions = full.Decisions[2:]
+	assert.Nil(t, ReviewDecisions(ctx, full, newReviewClient(srv.URL), ReviewConfig{Triggers: reviewTriggers}))
+	assert.Nil(t, full.Review)
+	assert.Zero(t, atomic.LoadInt32(&hits), "没有需要复核的决策时不调用复核模型")
+}
+
+func TestParseReviewTriggersAndFallback(t *testing.T) {
+	triggers, err := ParseReviewTriggers(`{"open":true,"size_over_usd":500}`)
+	require.NoError(t, err)
+	assert.Equal(t, ReviewTriggers{Open: true, SizeOverUSD: 500}, triggers)
+	triggers, err = ParseReviewTriggers("")
+	require.NoError(t, err)
+	assert.False(t, triggers.Enabled())
+	_, err = ParseReviewTriggers(`{"close_at_loss_over_pct":-1}`)
+	assert.Error(t, err)
+
+	fallback, err := NormalizeReviewFallback("")
+	require.NoError(t, err)
+	assert.Equal(t, ReviewFallbackHold, fallback)
+	fallback, err = NormalizeReviewFallback("Execute")
+	require.NoError(t, err)
+	assert.Equal(t, ReviewFallbackExecute, fallback)
+	_, err = NormalizeReviewFallback("skip")
+	assert.Error(t, err)
+}
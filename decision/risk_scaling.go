@@ -0,0 +1,182 @@
+package decision
+
+import (
+	"fmt"
+	"math"
+)
+
+// RiskScalingConfig 动态风险缩放规则
+// 根据当日盈亏和账户回撤，逐步收紧最大仓位和最大杠杆
+type RiskScalingConfig struct {
+	MaxDailyLossPct float64 `json:"max_daily_loss_pct"` // 日亏损上限（百分比，<=0 表示不按日亏损缩放）
+	MaxDrawdownPct  float64 `json:"max_drawdown_pct"`   // 最大回撤上限（百分比，<=0 表示不按回撤缩放）
+	ReduceUntil     float64 `json:"reduce_until"`       // 线性缩放终点（占上限的比例），默认 0.5
+	MinSizeFactor   float64 `json:"min_size_factor"`    // 缩放终点时的仓位系数，默认 0.5
+	MinLevFactor    float64 `json:"min_lev_factor"`     // 缩放终点时的杠杆系数，默认 0.5
+	BlockEntryAt    float64 `json:"block_entry_at"`     // 达到上限该比例后禁止开仓，默认 0.8
+	MinEquity       float64 `json:"min_equity"`         // 账户净值下限（绝对值，与百分比回撤无关），低于时禁止开仓，<=0 表示不限制
+}
+
+// DefaultRiskScalingConfig 默认风险缩放规则：
+// 亏损达到上限的 50% 时仓位/杠杆线性降至 50%，达到上限的 80% 后禁止新开仓
+func DefaultRiskScalingConfig(maxDailyLossPct, maxDrawdownPct float64) RiskScalingConfig {
+	return RiskScalingConfig{
+		MaxDailyLossPct: maxDailyLossPct,
+		MaxDrawdownPct:  maxDrawdownPct,
+		ReduceUntil:     0.5,
+		MinSizeFactor:   0.5,
+		MinLevFactor:    0.5,
+		BlockEntryAt:    0.8,
+	}
+}
+
+// RiskLimits 本周期生效的风控上限（由 ComputeRiskLimits 计算）
+type RiskLimits struct {
+	DailyPnLPct        float64 `json:"daily_pnl_pct"`        // 当日盈亏百分比
+	DrawdownPct        float64 `json:"drawdown_pct"`         // 当前回撤百分比
+	LossUsage          float64 `json:"loss_usage"`           // 已使用的亏损额度（0-1，取日亏损与回撤中较大者）
+	SizeFactor         float64 `json:"size_factor"`          // 仓位系数
+	LeverageFactor     float64 `json:"leverage_factor"`      // 杠杆系数
+	EntriesBlocked     bool    `json:"entries_blocked"`      // 是否禁止开仓
+	MaxBTCETHLeverage  int     `json:"max_btc_eth_leverage"` // BTC/ETH 当前允许的最大杠杆
+	MaxAltcoinLeverage int     `json:"max_altcoin_leverage"` // 山寨币当前允许的最大杠杆
+	MaxBTCETHPosition  float64 `json:"max_btc_eth_position"` // BTC/ETH 当前允许的最大仓位价值
+	MaxAltcoinPosition float64 `json:"max_altcoin_position"` // 山寨币当前允许的最大仓位价值
+	Reason             string  `json:"reason,omitempty"`     // 缩放原因
+	Guard              string  `json:"guard,omitempty"`      // 起作用的风控上限（max_daily_loss/max_drawdown/min_equity）
+}
+
+// BlockCode 禁止开仓时的拒绝原因代码
+func (l *RiskLimits) BlockCode() string {
+	if l.Guard == "" {
+		return RejectMaxDailyLoss
+	}
+	return l.Guard
+}
+
+// ComputeRiskLimits 根据当日盈亏和回撤计算本周期生效的风控上限
+// dailyPnLPct 为负表示亏损；drawdownPct 为正表示从峰值回撤的百分比
+func ComputeRiskLimits(cfg RiskScalingConfig, accountEquity, dailyPnLPct, drawdownPct float64, btcEthLeverage, altcoinLeverage int) *RiskLimits {
+	limits := &RiskLimits{
+		DailyPnLPct:    dailyPnLPct,
+		DrawdownPct:    drawdownPct,
+		SizeFactor:     1.0,
+		LeverageFactor: 1.0,
+	}
+
+	// 计算亏损额度使用率（取两者中较大者）
+	dailyUsage := 0.0
+	if cfg.MaxDailyLossPct > 0 && dailyPnLPct < 0 {
+		dailyUsage = -dailyPnLPct / cfg.MaxDailyLossPct
+	}
+	drawdownUsage := 0.0
+	if cfg.MaxDrawdownPct > 0 && drawdownPct > 0 {
+		drawdownUsage = drawdownPct / cfg.MaxDrawdownPct
+	}
+	usage := math.Max(dailyUsage, drawdownUsage)
+	limits.LossUsage = usage
+
+	if usage > 0 {
+		if dailyUsage >= drawdownUsage {
+			limits.Reason = fmt.Sprintf("当日亏损 %.2f%% / 上限 %.2f%%", -dailyPnLPct, cfg.MaxDailyLossPct)
+			limits.Guard = RejectMaxDailyLoss
+		} else {
+			limits.Reason = fmt.Sprintf("回撤 %.2f%% / 上限 %.2f%%", drawdownPct, cfg.MaxDrawdownPct)
+			limits.Guard = RejectMaxDrawdown
+		}
+	}
+
+	blockAt := cfg.BlockEntryAt
+	if blockAt <= 0 || blockAt > 1 {
+		blockAt = 1
+	}
+	if usage >= blockAt {
+		limits.EntriesBlocked = true
+		limits.SizeFactor = 0
+		limits.LeverageFactor = 0
+	} else {
+		limits.SizeFactor = scaleFactor(usage, cfg.ReduceUntil, cfg.MinSizeFactor)
+		limits.LeverageFactor = scaleFactor(usage, cfg.ReduceUntil, cfg.MinLevFactor)
+	}
+
+	// 净值低于下限时禁止开仓（优先于亏损额度的说明）
+	if cfg.MinEquity > 0 && accountEquity < cfg.MinEquity {
+		limits.EntriesBlocked = true
+		limits.SizeFactor = 0
+		limits.LeverageFactor = 0
+		limits.Reason = fmt.Sprintf("净值 %.2f 低于下限 %.2f", accountEquity, cfg.MinEquity)
+		limits.Guard = RejectMinEquity
+	}
+
+	limits.MaxBTCETHLeverage = scaleLeverage(btcEthLeverage, limits.LeverageFactor)
+	limits.MaxAltcoinLeverage = scaleLeverage(altcoinLeverage, limits.LeverageFactor)
+	limits.MaxBTCETHPosition = accountEquity * 10 * limits.SizeFactor
+	limits.MaxAltcoinPosition = accountEquity * 1.5 * limits.SizeFactor
+
+	return limits
+}
+
+// scaleFactor 线性缩放：usage=0 时为1，usage=reduceUntil 时为 minFactor，之后保持 minFactor
+func scaleFactor(usage, reduceUntil, minFactor float64) float64 {
+	if usage <= 0 {
+		return 1.0
+	}
+	if minFactor <= 0 || minFactor > 1 {
+		minFactor = 1.0
+	}
+	if reduceUntil <= 0 || usage >= reduceUntil {
+		return minFactor
+	}
+	return 1.0 - (1.0-minFactor)*(usage/reduceUntil)
+}
+
+// scaleLeverage 按系数缩放杠杆，未被禁止时至少保留1倍
+func scaleLeverage(leverage int, factor float64) int {
+	if factor <= 0 {
+		return 0
+	}
+	scaled := int(math.Floor(float64(leverage) * factor))
+	if scaled < 1 {
+		scaled = 1
+	}
+	return scaled
+}
+
+// MaxLeverageFor 返回该币种当前允许的最大杠杆
+func (l *RiskLimits) MaxLeverageFor(symbol string) int {
+	if isBTCETH(symbol) {
+		return l.MaxBTCETHLeverage
+	}
+	return l.MaxAltcoinLeverage
+}
+
+// MaxPositionFor 返回该币种当前允许的最大仓位价值
+func (l *RiskLimits) MaxPositionFor(symbol string) float64 {
+	if isBTCETH(symbol) {
+		return l.MaxBTCETHPosition
+	}
+	return l.MaxAltcoinPosition
+}
+
+// isBTCETH 是否为 BTC/ETH
+func isBTCETH(symbol string) bool {
+	return symbol == "BTCUSDT" || symbol == "ETHUSDT"
+}
+
+// formatRiskLimits 生成提示词中的动态风控说明
+func formatRiskLimits(l *RiskLimits) string {
+	if l.EntriesBlocked {
+		blocked := fmt.Sprintf("已接近亏损上限（%s）", l.Reason)
+		if l.Guard == RejectMinEquity {
+			blocked = "账户" + l.Reason
+		}
+		return fmt.Sprintf("风控: 当日盈亏%+.2f%% | 回撤%.2f%% | ⛔ %s，本周期禁止新开仓，只允许平仓/调整止损止盈/观望\n\n",
+			l.DailyPnLPct, l.DrawdownPct, blocked)
+	}
+	if l.SizeFactor >= 1 && l.LeverageFactor >= 1 {
+		return fmt.Sprintf("风控: 当日盈亏%+.2f%% | 回撤%.2f%% | 未触发缩放\n\n", l.DailyPnLPct, l.DrawdownPct)
+	}
+	return fmt.Sprintf("风控: 当日盈亏%+.2f%% | 回撤%.2f%% | 仓位系数%.2f（%s）| 当前允许上限: 山寨 %.0f U / %dx, BTC/ETH %.0f U / %dx\n\n",
+		l.DailyPnLPct, l.DrawdownPct, l.SizeFactor, l.Reason,
+		l.MaxAltcoinPosition, l.MaxAltcoinLeverage, l.MaxBTCETHPosition, l.MaxBTCETHLeverage)
+}
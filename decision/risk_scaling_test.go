@@ -0,0 +1,146 @@
+package decision
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+// TestComputeRiskLimits 测试动态风险缩放在各边界的计算结果
+func TestComputeRiskLimits(t *testing.T) {
+	cfg := DefaultRiskScalingConfig(10, 20) // 日亏损上限10%，回撤上限20%
+
+	tests := []struct {
+		name        string
+		dailyPnLPct float64
+		drawdownPct float64
+		wantFactor  float64
+		wantBlocked bool
+		wantAltLev  int
+		wantBTCLev  int
+	}{
+		{name: "盈利日_不缩放", dailyPnLPct: 3, drawdownPct: 0, wantFactor: 1, wantAltLev: 5, wantBTCLev: 10},
+		{name: "盈亏为0_不缩放", dailyPnLPct: 0, drawdownPct: 0, wantFactor: 1, wantAltLev: 5, wantBTCLev: 10},
+		{name: "亏损达上限25%_线性缩放到0.75", dailyPnLPct: -2.5, wantFactor: 0.75, wantAltLev: 3, wantBTCLev: 7},
+		{name: "亏损达上限一半_缩放到0.5", dailyPnLPct: -5, wantFactor: 0.5, wantAltLev: 2, wantBTCLev: 5},
+		{name: "亏损达上限70%_保持0.5", dailyPnLPct: -7, wantFactor: 0.5, wantAltLev: 2, wantBTCLev: 5},
+		{name: "亏损达上限80%_禁止开仓", dailyPnLPct: -8, wantFactor: 0, wantBlocked: true},
+		{name: "亏损恰好等于上限_禁止开仓", dailyPnLPct: -10, wantFactor: 0, wantBlocked: true},
+		{name: "亏损超过上限_禁止开仓", dailyPnLPct: -15, wantFactor: 0, wantBlocked: true},
+		{name: "回撤主导_回撤达上限一半", dailyPnLPct: -1, drawdownPct: 10, wantFactor: 0.5, wantAltLev: 2, wantBTCLev: 5},
+		{name: "日内回升转正_仍受回撤约束", dailyPnLPct: 1, drawdownPct: 5, wantFactor: 0.75, wantAltLev: 3, wantBTCLev: 7},
+		{name: "日内回升转正_无回撤_恢复满额", dailyPnLPct: 0.5, drawdownPct: 0, wantFactor: 1, wantAltLev: 5, wantBTCLev: 10},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limits := ComputeRiskLimits(cfg, 1000, tt.dailyPnLPct, tt.drawdownPct, 10, 5)
+
+			if limits.EntriesBlocked != tt.wantBlocked {
+				t.Fatalf("EntriesBlocked = %v, want %v", limits.EntriesBlocked, tt.wantBlocked)
+			}
+			if math.Abs(limits.SizeFactor-tt.wantFactor) > 1e-9 {
+				t.Errorf("SizeFactor = %.4f, want %.4f", limits.SizeFactor, tt.wantFactor)
+			}
+			if tt.wantBlocked {
+				return
+			}
+			if limits.MaxAltcoinLeverage != tt.wantAltLev {
+				t.Errorf("MaxAltcoinLeverage = %d, want %d", limits.MaxAltcoinLeverage, tt.wantAltLev)
+			}
+			if limits.MaxBTCETHLeverage != tt.wantBTCLev {
+				t.Errorf("MaxBTCETHLeverage = %d, want %d", limits.MaxBTCETHLeverage, tt.wantBTCLev)
+			}
+			if want := 1000 * 1.5 * tt.wantFactor; math.Abs(limits.MaxAltcoinPosition-want) > 1e-9 {
+				t.Errorf("MaxAltcoinPosition = %.2f, want %.2f", limits.MaxAltcoinPosition, want)
+			}
+		})
+	}
+}
+
+// TestComputeRiskLimits_MinLeverage 测试缩放后杠杆至少保留1倍
+func TestComputeRiskLimits_MinLeverage(t *testing.T) {
+	limits := ComputeRiskLimits(DefaultRiskScalingConfig(10, 20), 1000, -5, 0, 1, 1)
+	if limits.MaxAltcoinLeverage != 1 || limits.MaxBTCETHLeverage != 1 {
+		t.Errorf("杠杆应至少为1x, got alt=%d btc=%d", limits.MaxAltcoinLeverage, limits.MaxBTCETHLeverage)
+	}
+}
+
+// TestComputeRiskLimits_Disabled 测试未配置上限时不缩放
+func TestComputeRiskLimits_Disabled(t *testing.T) {
+	limits := ComputeRiskLimits(DefaultRiskScalingConfig(0, 0), 1000, -50, 60, 10, 5)
+	if limits.EntriesBlocked || limits.SizeFactor != 1 {
+		t.Errorf("未配置上限时不应缩放, got blocked=%v factor=%.2f", limits.EntriesBlocked, limits.SizeFactor)
+	}
+}
+
+// TestComputeRiskLimits_MinEquity 测试净值低于绝对值下限时禁止开仓（不受百分比回撤影响），高于下限时不限制
+func TestComputeRiskLimits_MinEquity(t *testing.T) {
+	cfg := DefaultRiskScalingConfig(10, 20)
+	cfg.MinEquity = 500
+
+	open := &Decision{Symbol: "SOLUSDT", Action: "open_long", Leverage: 5, PositionSizeUSD: 300, StopLoss: 90, TakeProfit: 150}
+
+	// 无亏损和回撤，但净值低于下限
+	below := ComputeRiskLimits(cfg, 499, 0, 0, 10, 5)
+	if !below.EntriesBlocked || below.BlockCode() != RejectMinEquity {
+		t.Fatalf("净值低于下限时应禁止开仓, got blocked=%v code=%s", below.EntriesBlocked, below.BlockCode())
+	}
+	if err := validateDecisionWithLimits(open, 499, 10, 5, below); RejectionCode(err) != RejectMinEquity {
+		t.Errorf("开仓应被 min_equity 拒绝, got %v", err)
+	}
+	if !strings.Contains(formatRiskLimits(below), "账户净值 499.00 低于下限 500.00") {
+		t.Errorf("提示词应说明净值低于下限: %s", formatRiskLimits(below))
+	}
+
+	// 净值等于或高于下限时不限制
+	for _, equity := range []float64{500, 800} {
+		above := ComputeRiskLimits(cfg, equity, 0, 0, 10, 5)
+		if above.EntriesBlocked || above.SizeFactor != 1 {
+			t.Errorf("净值 %.0f 不低于下限时不应限制, got blocked=%v factor=%.2f", equity, above.EntriesBlocked, above.SizeFactor)
+		}
+		if err := validateDecisionWithLimits(open, equity, 10, 5, above); err != nil {
+			t.Errorf("净值 %.0f 时应允许开仓: %v", equity, err)
+		}
+	}
+}
+
+// TestValidateDecisionWithLimits 测试验证阶段应用动态风控上限
+func TestValidateDecisionWithLimits(t *testing.T) {
+	newDecision := func() *Decision {
+		return &Decision{
+			Symbol:          "SOLUSDT",
+			Action:          "open_long",
+			Leverage:        5,
+			PositionSizeUSD: 1000,
+			StopLoss:        90,
+			TakeProfit:      150,
+		}
+	}
+
+	// 亏损达上限一半：山寨仓位上限 1000*1.5*0.5=750，应拒绝 1000 的仓位
+	limits := ComputeRiskLimits(DefaultRiskScalingConfig(10, 20), 1000, -5, 0, 10, 5)
+	if err := validateDecisionWithLimits(newDecision(), 1000, 10, 5, limits); err == nil {
+		t.Error("超过缩放后仓位上限时应返回错误")
+	}
+
+	// 仓位在范围内时杠杆被收紧到缩放后的上限
+	d := newDecision()
+	d.PositionSizeUSD = 700
+	if err := validateDecisionWithLimits(d, 1000, 10, 5, limits); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if d.Leverage != 2 {
+		t.Errorf("Leverage = %d, want 2", d.Leverage)
+	}
+
+	// 禁止开仓时拒绝开仓，但允许平仓
+	blocked := ComputeRiskLimits(DefaultRiskScalingConfig(10, 20), 1000, -9, 0, 10, 5)
+	if err := validateDecisionWithLimits(newDecision(), 1000, 10, 5, blocked); err == nil {
+		t.Error("禁止开仓时应返回错误")
+	}
+	closeDecision := &Decision{Symbol: "SOLUSDT", Action: "close_long"}
+	if err := validateDecisionWithLimits(closeDecision, 1000, 10, 5, blocked); err != nil {
+		t.Errorf("禁止开仓时仍应允许平仓: %v", err)
+	}
+}
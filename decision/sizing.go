@@ -0,0 +1,154 @@
+package decision
+
+import (
+	"fmt"
+	"strings"
+
+	"aspen/market"
+)
+
+// 仓位大小模式
+const (
+	SizingModeAbsolute = "absolute" // AI给出 position_size_usd（默认，兼容旧配置）
+	SizingModePercent  = "percent"  // AI给出 position_size_pct（占净值百分比），由系统按执行时净值换算金额
+)
+
+// 百分比模式的默认仓位范围（占净值百分比）
+const (
+	DefaultMinSizePct = 1.0
+	DefaultMaxSizePct = 30.0
+)
+
+// 默认最小开仓金额：Binance 最小名义价值 10 USDT + 安全边际，BTC/ETH 因价格高和精度限制需要更大金额
+const (
+	minPositionSizeGeneral = 12.0
+	minPositionSizeBTCETH  = 60.0
+)
+
+// minNotionalSafetyMargin 交易所最小名义价值的安全边际倍数（与默认值 10 → 12 USDT 一致）
+const minNotionalSafetyMargin = 1.2
+
+// MinPositionSizeUSD 该币种的最小开仓金额（USDT）：config.json 中按币种配置的值优先，
+// 否则取默认值与 exchangeInfo 最小名义价值（加安全边际）中的较大者
+func MinPositionSizeUSD(symbol string) float64 {
+	if size, ok := market.ConfiguredMinPositionSize(symbol); ok {
+		return size
+	}
+	floor := minPositionSizeGeneral
+	if symbol == "BTCUSDT" || symbol == "ETHUSDT" {
+		floor = minPositionSizeBTCETH
+	}
+	if notional, ok := market.ExchangeMinNotional(symbol); ok && notional*minNotionalSafetyMargin > floor {
+		floor = notional * minNotionalSafetyMargin
+	}
+	return floor
+}
+
+// PositionSizing 交易员的仓位大小模式
+type PositionSizing struct {
+	Mode   string  // absolute / percent
+	MinPct float64 // 百分比模式下单笔最小仓位（占净值%）
+	MaxPct float64 // 百分比模式下单笔最大仓位（占净值%）
+}
+
+// NormalizePositionSizing 校验仓位大小模式（空模式使用 absolute，未设置的百分比范围使用默认值 1-30%）
+func NormalizePositionSizing(mode string, minPct, maxPct float64) (PositionSizing, error) {
+	mode = strings.ToLower(strings.TrimSpace(mode))
+	switch mode {
+	case "":
+		mode = SizingModeAbsolute
+	case SizingModeAbsolute, SizingModePercent:
+	default:
+		return PositionSizing{}, fmt.Errorf("未知的仓位大小模式: %s（可选 absolute / percent）", mode)
+	}
+	if minPct == 0 {
+		minPct = DefaultMinSizePct
+	}
+	if maxPct == 0 {
+		maxPct = DefaultMaxSizePct
+	}
+	if minPct < 0 || maxPct > 100 || minPct > maxPct {
+		return PositionSizing{}, fmt.Errorf("仓位百分比范围无效: %.2f%%-%.2f%%（需满足 0 < 最小值 ≤ 最大值 ≤ 100）", minPct, maxPct)
+	}
+	return PositionSizing{Mode: mode, MinPct: minPct, MaxPct: maxPct}, nil
+}
+
+// IsPercent 是否按净值百分比开仓
+func (s PositionSizing) IsPercent() bool {
+	return s.Mode == SizingModePercent
+}
+
+// bounds 百分比范围（未配置时使用默认值）
+func (s PositionSizing) bounds() (float64, float64) {
+	minPct, maxPct := s.MinPct, s.MaxPct
+	if minPct <= 0 {
+		minPct = DefaultMinSizePct
+	}
+	if maxPct <= 0 {
+		maxPct = DefaultMaxSizePct
+	}
+	return minPct, maxPct
+}
+
+// SizeUSD 按净值把仓位百分比换算为金额。换算结果低于交易所最小开仓金额时，
+// 若最小金额仍在百分比上限内则提高到最小金额（返回调整说明），否则拒绝
+func (s PositionSizing) SizeUSD(symbol string, pct, equity float64) (float64, string, error) {
+	minPct, maxPct := s.bounds()
+	if pct < minPct || pct > maxPct {
+		return 0, "", fmt.Errorf("仓位百分比 %.2f%% 超出允许范围 %.2f%%-%.2f%%", pct, minPct, maxPct)
+	}
+	if equity <= 0 {
+		return 0, "", fmt.Errorf("账户净值异常 (%.2f)，无法按百分比换算仓位", equity)
+	}
+
+	size := equity * pct / 100
+	floor := MinPositionSizeUSD(symbol)
+	if size >= floor {
+		return size, "", nil
+	}
+	if floor > equity*maxPct/100 {
+		return 0, "", fmt.Errorf("%s 按净值 %.2f 换算的仓位 %.2f USDT（%.2f%%）低于最小开仓金额 %.2f USDT，且最小金额超过仓位上限 %.2f%%",
+			symbol, equity, size, pct, floor, maxPct)
+	}
+	return floor, fmt.Sprintf("仓位 %.2f%% × 净值 %.2f = %.2f USDT 低于最小开仓金额，提高到 %.2f USDT（%.2f%%）",
+		pct, equity, size, floor, floor/equity*100), nil
+}
+
+// isEntryAction 是否为增加风险敞口的开仓/加仓动作
+func isEntryAction(action string) bool {
+	return action == "open_long" || action == "open_short" || action == "add_to_position"
+}
+
+// resolvePositionSizing 处理开仓决策的仓位字段：给出 position_size_pct 时优先使用百分比
+// （同时给出 position_size_usd 时记录警告），并按提示词中的净值快照换算金额用于验证；
+// 执行时系统会按执行时的净值重新换算
+func resolvePositionSizing(decisions []Decision, accountEquity float64, sizing PositionSizing) error {
+	for i := range decisions {
+		d := &decisions[i]
+		if !isEntryAction(d.Action) || d.PositionSizePct == 0 {
+			continue
+		}
+		if d.PositionSizeUSD > 0 {
+			d.SizingWarning = fmt.Sprintf("同时给出 position_size_pct=%.2f 和 position_size_usd=%.2f，按百分比执行", d.PositionSizePct, d.PositionSizeUSD)
+		}
+		size, _, err := sizing.SizeUSD(d.Symbol, d.PositionSizePct, accountEquity)
+		if err != nil {
+			return fmt.Errorf("决策 #%d %s: %w", i+1, d.Symbol, err)
+		}
+		d.PositionSizeUSD = size
+	}
+	return nil
+}
+
+// formatSizingRules 百分比模式下的仓位说明（追加到系统提示词）
+func formatSizingRules(sizing PositionSizing, accountEquity float64) string {
+	minPct, maxPct := sizing.bounds()
+	var sb strings.Builder
+	sb.WriteString("# 仓位大小（按净值百分比）\n\n")
+	sb.WriteString("- 开仓和加仓时使用 `position_size_pct`（占当前账户净值的百分比），**不要**给出 position_size_usd，金额由系统按执行时的净值换算\n")
+	sb.WriteString(fmt.Sprintf("- 允许范围: %.0f%%-%.0f%%（当前净值 %.2f，约 %.2f-%.2f USDT）\n",
+		minPct, maxPct, accountEquity, accountEquity*minPct/100, accountEquity*maxPct/100))
+	sb.WriteString("- 以上文中以 USDT 表示的仓位上限仍然适用；换算金额低于交易所最小开仓金额时，系统会在百分比上限内提高到最小金额，否则拒绝\n")
+	sb.WriteString("- 示例: {\"symbol\": \"SOLUSDT\", \"action\": \"open_long\", \"leverage\": 5, \"position_size_pct\": 10, \"stop_loss\": 140, \"take_profit\": 170, \"confidence\": 80, \"risk_usd\": 20, \"reasoning\": \"...\"}\n\n")
+	return sb.String()
+}
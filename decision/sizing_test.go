@@ -0,0 +1,150 @@
+package decision
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"aspen/market"
+)
+
+// TestPositionSizingSizeUSD 测试不同净值下百分比仓位的换算，以及与最小开仓金额的交互
+func TestPositionSizingSizeUSD(t *testing.T) {
+	sizing, err := NormalizePositionSizing("percent", 0, 0)
+	if err != nil {
+		t.Fatalf("NormalizePositionSizing: %v", err)
+	}
+	if !sizing.IsPercent() || sizing.MinPct != DefaultMinSizePct || sizing.MaxPct != DefaultMaxSizePct {
+		t.Fatalf("sizing = %+v", sizing)
+	}
+
+	tests := []struct {
+		name     string
+		symbol   string
+		pct      float64
+		equity   float64
+		want     float64
+		adjusted bool
+		wantErr  bool
+	}{
+		{"大账户", "SOLUSDT", 10, 50000, 5000, false, false},
+		{"中等账户", "SOLUSDT", 10, 1200, 120, false, false},
+		{"小账户刚好达到最小金额", "SOLUSDT", 10, 120, 12, false, false},
+		{"低于最小金额但在上限内时提高到最小金额", "SOLUSDT", 5, 100, 12, true, false},
+		{"最小金额超过仓位上限时拒绝", "SOLUSDT", 10, 30, 0, false, true},
+		{"BTC 最小金额更高", "BTCUSDT", 10, 300, 60, true, false},
+		{"BTC 小账户无法满足最小金额", "BTCUSDT", 30, 150, 0, false, true},
+		{"超过百分比上限", "SOLUSDT", 50, 1000, 0, false, true},
+		{"低于百分比下限", "SOLUSDT", 0.5, 1000, 0, false, true},
+		{"净值异常", "SOLUSDT", 10, 0, 0, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, adjustment, err := sizing.SizeUSD(tt.symbol, tt.pct, tt.equity)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("want error, got %.2f", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("size = %.4f, want %.4f", got, tt.want)
+			}
+			if (adjustment != "") != tt.adjusted {
+				t.Errorf("adjustment = %q, want adjusted=%v", adjustment, tt.adjusted)
+			}
+		})
+	}
+}
+
+// TestNormalizePositionSizing 测试仓位大小模式校验
+func TestNormalizePositionSizing(t *testing.T) {
+	if s, err := NormalizePositionSizing("", 0, 0); err != nil || s.IsPercent() {
+		t.Errorf("空模式应为 absolute: %+v, %v", s, err)
+	}
+	if s, err := NormalizePositionSizing("PERCENT", 2, 20); err != nil || !s.IsPercent() || s.MinPct != 2 || s.MaxPct != 20 {
+		t.Errorf("percent 2-20: %+v, %v", s, err)
+	}
+	for _, bad := range []struct {
+		mode           string
+		minPct, maxPct float64
+	}{
+		{"kelly", 0, 0},
+		{"percent", 40, 20},
+		{"percent", -1, 20},
+		{"percent", 1, 150},
+	} {
+		if _, err := NormalizePositionSizing(bad.mode, bad.minPct, bad.maxPct); err == nil {
+			t.Errorf("%+v 应被拒绝", bad)
+		}
+	}
+}
+
+// TestParsePercentSizedDecisions 测试解析器接受 position_size_pct，同时给出两个字段时按百分比并记录警告
+func TestParsePercentSizedDecisions(t *testing.T) {
+	sizing, _ := NormalizePositionSizing("percent", 1, 30)
+	response := `<reasoning>ok</reasoning>
+<decision>
+[
+  {"symbol": "SOLUSDT", "action": "open_long", "leverage": 5, "position_size_pct": 10, "stop_loss": 100, "take_profit": 160, "confidence": 80, "reasoning": "pct"},
+  {"symbol": "XRPUSDT", "action": "open_short", "leverage": 5, "position_size_pct": 5, "position_size_usd": 5000, "stop_loss": 0.7, "take_profit": 0.4, "confidence": 80, "reasoning": "mixed"},
+  {"symbol": "ADAUSDT", "action": "open_long", "leverage": 5, "position_size_usd": 150, "stop_loss": 0.3, "take_profit": 0.6, "confidence": 80, "reasoning": "usd"}
+]
+</decision>`
+
+	fd, err := parseFullDecisionResponse(response, 1200, 10, 5, nil, sizing)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	pct, mixed, usd := fd.Decisions[0], fd.Decisions[1], fd.Decisions[2]
+	if pct.PositionSizePct != 10 || math.Abs(pct.PositionSizeUSD-120) > 1e-9 || pct.SizingWarning != "" {
+		t.Errorf("pct decision = %+v", pct)
+	}
+	if math.Abs(mixed.PositionSizeUSD-60) > 1e-9 || !strings.Contains(mixed.SizingWarning, "按百分比执行") {
+		t.Errorf("mixed decision = %+v", mixed)
+	}
+	if usd.PositionSizePct != 0 || usd.PositionSizeUSD != 150 {
+		t.Errorf("usd decision = %+v", usd)
+	}
+
+	// 超出百分比上限的决策验证失败（即使模型算出的金额看起来合理）
+	over := strings.Replace(response, `"position_size_pct": 10`, `"position_size_pct": 45`, 1)
+	if _, err := parseFullDecisionResponse(over, 1200, 10, 5, nil, sizing); err == nil {
+		t.Error("超过百分比上限应验证失败")
+	}
+}
+
+// TestValidateDecision_SymbolMinPositionSize 测试按币种配置的最小开仓金额：配置了更高最小值的币种拒绝 15 USDT 的开仓，默认币种接受
+func TestValidateDecision_SymbolMinPositionSize(t *testing.T) {
+	market.SetMinPositionSizeOverrides(map[string]float64{"pepeusdt": 25})
+	defer market.SetMinPositionSizeOverrides(nil)
+
+	newOpen := func(symbol string) *Decision {
+		return &Decision{Symbol: symbol, Action: "open_long", Leverage: 3, PositionSizeUSD: 15, StopLoss: 1, TakeProfit: 200}
+	}
+
+	err := validateDecision(newOpen("PEPEUSDT"), 1000, 10, 5)
+	if err == nil || !strings.Contains(err.Error(), "必须≥25.00 USDT") {
+		t.Fatalf("PEPEUSDT 15 USDT 应因最小开仓金额 25 被拒绝, err=%v", err)
+	}
+	if err := validateDecision(newOpen("SOLUSDT"), 1000, 10, 5); err != nil {
+		t.Fatalf("SOLUSDT 使用默认最小金额 12 USDT，15 USDT 应通过: %v", err)
+	}
+
+	if got := MinPositionSizeUSD("PEPEUSDT"); got != 25 {
+		t.Errorf("MinPositionSizeUSD(PEPEUSDT) = %.2f, want 25", got)
+	}
+	if got := MinPositionSizeUSD("BTCUSDT"); got != minPositionSizeBTCETH {
+		t.Errorf("未配置的 BTCUSDT 应使用默认值 %.2f, got %.2f", minPositionSizeBTCETH, got)
+	}
+
+	// 百分比仓位换算同样使用币种最小金额
+	sizing, _ := NormalizePositionSizing("percent", 0, 0)
+	size, adjustment, err := sizing.SizeUSD("PEPEUSDT", 2, 1000)
+	if err != nil || size != 25 || adjustment == "" {
+		t.Errorf("SizeUSD(PEPEUSDT, 2%%, 1000) = %.2f, %q, %v; want 提高到 25", size, adjustment, err)
+	}
+}
@@ -0,0 +1,87 @@
+package decision
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// RecentSymbolAction 币种近期交易动作（写入提示词，避免刚平仓又立即反手或重复开仓）
+type RecentSymbolAction struct {
+	Symbol    string
+	Action    string  // open_long, open_short, add_to_position, close_long, close_short, partial_close
+	CyclesAgo int     // 距今周期数（1 表示上一周期）
+	PnLPct    float64 // 平仓时的收益率（仅平仓动作有效）
+}
+
+// recentActionLabels 动作的中文描述
+var recentActionLabels = map[string]string{
+	"open_long":       "开多",
+	"open_short":      "开空",
+	"add_to_position": "加仓",
+	"close_long":      "平多",
+	"close_short":     "平空",
+	"partial_close":   "部分平仓",
+}
+
+// isCloseAction 是否为平仓动作
+func isCloseAction(action string) bool {
+	return action == "close_long" || action == "close_short" || action == "partial_close"
+}
+
+// formatRecentSymbolActions 格式化候选币种和持仓币种的近期操作（每个币种一行，从新到旧）
+func formatRecentSymbolActions(actions []RecentSymbolAction, ctx *Context) string {
+	if len(actions) == 0 {
+		return ""
+	}
+
+	relevant := make(map[string]bool)
+	for _, coin := range ctx.CandidateCoins {
+		relevant[coin.Symbol] = true
+	}
+	for _, pos := range ctx.Positions {
+		relevant[pos.Symbol] = true
+	}
+
+	bySymbol := make(map[string][]RecentSymbolAction)
+	var symbols []string
+	for _, a := range actions {
+		if !relevant[a.Symbol] {
+			continue
+		}
+		if _, seen := bySymbol[a.Symbol]; !seen {
+			symbols = append(symbols, a.Symbol)
+		}
+		bySymbol[a.Symbol] = append(bySymbol[a.Symbol], a)
+	}
+	if len(symbols) == 0 {
+		return ""
+	}
+	sort.Strings(symbols)
+
+	var sb strings.Builder
+	sb.WriteString("## 近期币种操作（刚平仓的币种请避免立即反手或重复开仓）\n")
+	for _, symbol := range symbols {
+		items := bySymbol[symbol]
+		sort.SliceStable(items, func(i, j int) bool { return items[i].CyclesAgo < items[j].CyclesAgo })
+		parts := make([]string, 0, len(items))
+		for _, a := range items {
+			label := recentActionLabels[a.Action]
+			if label == "" {
+				label = a.Action
+			}
+			when := "上一周期"
+			if a.CyclesAgo > 1 {
+				when = fmt.Sprintf("%d个周期前", a.CyclesAgo)
+			}
+			if isCloseAction(a.Action) {
+				parts = append(parts, fmt.Sprintf("%s%s（%+.2f%%）", when, label, a.PnLPct))
+			} else {
+				parts = append(parts, when+label)
+			}
+		}
+		sb.WriteString(fmt.Sprintf("%s: %s\n", symbol, strings.Join(parts, " | ")))
+	}
+	sb.WriteString("\n")
+	return sb.String()
+}
@@ -0,0 +1,34 @@
+package decision
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestFormatRecentSymbolActions 测试只输出候选币种和持仓币种的近期操作（从新到旧）
+func TestFormatRecentSymbolActions(t *testing.T) {
+	ctx := &Context{
+		CandidateCoins: []CandidateCoin{{Symbol: "BTCUSDT"}},
+		Positions:      []PositionInfo{{Symbol: "ETHUSDT", Side: "short"}},
+	}
+	actions := []RecentSymbolAction{
+		{Symbol: "BTCUSDT", Action: "open_long", CyclesAgo: 4},
+		{Symbol: "BTCUSDT", Action: "close_long", CyclesAgo: 1, PnLPct: -1.5},
+		{Symbol: "ETHUSDT", Action: "open_short", CyclesAgo: 2},
+		{Symbol: "DOGEUSDT", Action: "close_short", CyclesAgo: 1, PnLPct: 2},
+	}
+
+	prompt := formatRecentSymbolActions(actions, ctx)
+	if !strings.Contains(prompt, "BTCUSDT: 上一周期平多（-1.50%） | 4个周期前开多") {
+		t.Errorf("prompt = %q", prompt)
+	}
+	if !strings.Contains(prompt, "ETHUSDT: 2个周期前开空") {
+		t.Errorf("prompt = %q", prompt)
+	}
+	if strings.Contains(prompt, "DOGEUSDT") {
+		t.Error("非候选且无持仓的币种不应输出")
+	}
+	if formatRecentSymbolActions(nil, ctx) != "" {
+		t.Error("没有近期操作时不应输出")
+	}
+}
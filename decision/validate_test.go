@@ -2,6 +2,8 @@ package decision
 
 import (
 	"testing"
+
+	"aspen/market"
 )
 
 // TestLeverageFallback 测试杠杆超限时的自动修正功能
@@ -98,3 +100,59 @@ func TestLeverageFallback(t *testing.T) {
 		})
 	}
 }
+
+// TestValidatePositionID 测试持仓ID只能用于平仓和调整已有持仓
+func TestValidatePositionID(t *testing.T) {
+	open := Decision{Symbol: "SOLUSDT", Action: "open_long", Leverage: 5, PositionSizeUSD: 100, StopLoss: 50, TakeProfit: 200, PositionID: "SOL-L-260101000000"}
+	if err := validateDecision(&open, 1000, 10, 5); err == nil {
+		t.Error("开仓决策指定 position_id 应被拒绝")
+	}
+
+	for _, d := range []Decision{
+		{Action: "close_long", PositionID: "SOL-L-260101000000"},
+		{Symbol: "SOLUSDT", Action: "partial_close", ClosePercentage: 50, PositionID: "SOL-L-260101000000"},
+		{Symbol: "SOLUSDT", Action: "update_stop_loss", NewStopLoss: 90, PositionID: "SOL-L-260101000000"},
+	} {
+		if err := validateDecision(&d, 1000, 10, 5); err != nil {
+			t.Errorf("%s 指定 position_id 应通过验证: %v", d.Action, err)
+		}
+	}
+}
+
+// TestValidateAddToPosition 测试加仓决策的验证
+func TestValidateAddToPosition(t *testing.T) {
+	add := Decision{Symbol: "SOLUSDT", Action: "add_to_position", PositionSizeUSD: 100, PositionID: "SOL-L-260101000000"}
+	if err := validateDecision(&add, 1000, 10, 5); err != nil {
+		t.Errorf("加仓决策应通过验证: %v", err)
+	}
+
+	noSize := Decision{Symbol: "SOLUSDT", Action: "add_to_position"}
+	if err := validateDecision(&noSize, 1000, 10, 5); err == nil {
+		t.Error("加仓金额为0应被拒绝")
+	}
+
+	blocked := Decision{Symbol: "SOLUSDT", Action: "add_to_position", PositionSizeUSD: 100}
+	if err := validateDecisionWithLimits(&blocked, 1000, 10, 5, &RiskLimits{EntriesBlocked: true, Reason: "test"}); err == nil {
+		t.Error("动态风控禁止开仓时加仓应被拒绝")
+	}
+}
+
+// TestLeverageFallback_SymbolMaxLeverage 测试AI给出的杠杆超过交易所币种杠杆上限时收紧到该上限
+func TestLeverageFallback_SymbolMaxLeverage(t *testing.T) {
+	market.RegisterExchangeMaxLeverages(map[string]int{"CAPTESTUSDT": 20})
+
+	d := Decision{
+		Symbol:          "CAPTESTUSDT",
+		Action:          "open_long",
+		Leverage:        50,
+		PositionSizeUSD: 100,
+		StopLoss:        0.9,
+		TakeProfit:      1.2,
+	}
+	if err := validateDecision(&d, 1000, 100, 100); err != nil {
+		t.Fatalf("validateDecision() error = %v", err)
+	}
+	if d.Leverage != 20 {
+		t.Errorf("Leverage = %d, want 20", d.Leverage)
+	}
+}
@@ -0,0 +1,84 @@
+package httpclient
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// DefaultRequestIDHeader 默认的请求ID请求头
+const DefaultRequestIDHeader = "X-Request-ID"
+
+var (
+	requestIDMu     sync.RWMutex
+	requestIDHeader = DefaultRequestIDHeader
+)
+
+// SetRequestIDHeader 设置外发请求附带请求ID使用的请求头（空值使用默认值）
+func SetRequestIDHeader(name string) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		name = DefaultRequestIDHeader
+	}
+	requestIDMu.Lock()
+	defer requestIDMu.Unlock()
+	requestIDHeader = http.CanonicalHeaderKey(name)
+}
+
+// RequestIDHeader 外发请求附带请求ID使用的请求头
+func RequestIDHeader() string {
+	requestIDMu.RLock()
+	defer requestIDMu.RUnlock()
+	return requestIDHeader
+}
+
+// NewRequestID 生成请求ID（16位十六进制随机串），用于关联一个交易周期在各模块的日志和外发请求
+func NewRequestID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+type requestIDKey struct{}
+
+// WithRequestID 在 context 中携带请求ID
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestIDFromContext context 中携带的请求ID（没有时返回空字符串）
+func RequestIDFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// RequestIDTransport 为外发请求附带请求ID请求头：优先使用请求 context 中的ID，否则使用 Current 返回的当前ID
+// （交易所SDK的请求不携带周期 context，由交易员在周期开始时设置当前ID）
+type RequestIDTransport struct {
+	Base    http.RoundTripper // 为 nil 时使用 http.DefaultTransport
+	Current func() string
+}
+
+// RoundTrip 实现 http.RoundTripper
+func (t *RequestIDTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	id := RequestIDFromContext(req.Context())
+	if id == "" && t.Current != nil {
+		id = t.Current()
+	}
+	if id == "" {
+		return base.RoundTrip(req)
+	}
+	req = req.Clone(req.Context())
+	req.Header.Set(RequestIDHeader(), id)
+	return base.RoundTrip(req)
+}
@@ -0,0 +1,84 @@
+// Package httpclient 行情、AI和交易所客户端共享的HTTP设施：可配置的连接池传输层，以及关联交易周期的请求ID
+package httpclient
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+// PoolConfig HTTP连接池参数（0 使用默认值）
+type PoolConfig struct {
+	MaxIdleConns        int           // 所有主机合计最多保留的空闲连接数
+	MaxIdleConnsPerHost int           // 每个主机最多保留的空闲连接数（Go 默认只有 2，交易员多时频繁重建连接）
+	IdleConnTimeout     time.Duration // 空闲连接保留时长
+}
+
+// DefaultPoolConfig 默认连接池参数
+func DefaultPoolConfig() PoolConfig {
+	return PoolConfig{
+		MaxIdleConns:        200,
+		MaxIdleConnsPerHost: 32,
+		IdleConnTimeout:     90 * time.Second,
+	}
+}
+
+// withDefaults 未设置的字段使用默认值
+func (c PoolConfig) withDefaults() PoolConfig {
+	defaults := DefaultPoolConfig()
+	if c.MaxIdleConns <= 0 {
+		c.MaxIdleConns = defaults.MaxIdleConns
+	}
+	if c.MaxIdleConnsPerHost <= 0 {
+		c.MaxIdleConnsPerHost = defaults.MaxIdleConnsPerHost
+	}
+	if c.IdleConnTimeout <= 0 {
+		c.IdleConnTimeout = defaults.IdleConnTimeout
+	}
+	return c
+}
+
+var (
+	mu         sync.Mutex
+	poolConfig = DefaultPoolConfig()
+	shared     *http.Transport
+)
+
+// Configure 设置连接池参数（启动时调用），之后创建的客户端使用新的共享传输层
+func Configure(cfg PoolConfig) {
+	mu.Lock()
+	defer mu.Unlock()
+	poolConfig = cfg.withDefaults()
+	if shared != nil {
+		shared.CloseIdleConnections()
+		shared = nil
+	}
+}
+
+// CurrentPoolConfig 当前的连接池参数
+func CurrentPoolConfig() PoolConfig {
+	mu.Lock()
+	defer mu.Unlock()
+	return poolConfig
+}
+
+// SharedTransport 按当前连接池参数创建的共享传输层（多个客户端复用同一个连接池）
+func SharedTransport() *http.Transport {
+	mu.Lock()
+	defer mu.Unlock()
+	if shared == nil {
+		shared = NewTransport(poolConfig)
+	}
+	return shared
+}
+
+// NewTransport 按连接池参数创建独立的传输层（拨号、TLS 和环境变量代理设置沿用 http.DefaultTransport），
+// 需要单独设置代理时使用
+func NewTransport(cfg PoolConfig) *http.Transport {
+	cfg = cfg.withDefaults()
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConns = cfg.MaxIdleConns
+	transport.MaxIdleConnsPerHost = cfg.MaxIdleConnsPerHost
+	transport.IdleConnTimeout = cfg.IdleConnTimeout
+	return transport
+}
@@ -0,0 +1,43 @@
+package httpclient
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestNewTransport_AppliesPoolConfig 测试传输层使用配置的连接池参数，未设置的字段使用默认值
+func TestNewTransport_AppliesPoolConfig(t *testing.T) {
+	transport := NewTransport(PoolConfig{MaxIdleConns: 500, MaxIdleConnsPerHost: 64, IdleConnTimeout: 30 * time.Second})
+	assert.Equal(t, 500, transport.MaxIdleConns)
+	assert.Equal(t, 64, transport.MaxIdleConnsPerHost)
+	assert.Equal(t, 30*time.Second, transport.IdleConnTimeout)
+	assert.NotSame(t, http.DefaultTransport, transport)
+
+	defaults := DefaultPoolConfig()
+	transport = NewTransport(PoolConfig{MaxIdleConnsPerHost: 8})
+	assert.Equal(t, defaults.MaxIdleConns, transport.MaxIdleConns)
+	assert.Equal(t, 8, transport.MaxIdleConnsPerHost)
+	assert.Equal(t, defaults.IdleConnTimeout, transport.IdleConnTimeout)
+}
+
+// TestConfigure_RebuildsSharedTransport 测试重新配置后共享传输层按新参数创建，配置前后多次获取复用同一个连接池
+func TestConfigure_RebuildsSharedTransport(t *testing.T) {
+	t.Cleanup(func() { Configure(DefaultPoolConfig()) })
+
+	Configure(PoolConfig{MaxIdleConns: 300, MaxIdleConnsPerHost: 50, IdleConnTimeout: time.Minute})
+	first := SharedTransport()
+	assert.Same(t, first, SharedTransport())
+	assert.Equal(t, 300, first.MaxIdleConns)
+	assert.Equal(t, 50, first.MaxIdleConnsPerHost)
+	assert.Equal(t, time.Minute, first.IdleConnTimeout)
+
+	Configure(PoolConfig{MaxIdleConnsPerHost: 10})
+	second := SharedTransport()
+	assert.NotSame(t, first, second)
+	assert.Equal(t, 10, second.MaxIdleConnsPerHost)
+	assert.Equal(t, DefaultPoolConfig().MaxIdleConns, second.MaxIdleConns)
+	assert.Equal(t, PoolConfig{MaxIdleConns: 200, MaxIdleConnsPerHost: 10, IdleConnTimeout: 90 * time.Second}, CurrentPoolConfig())
+}
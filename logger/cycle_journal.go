@@ -0,0 +1,191 @@
+package logger
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// 周期日志阶段
+const (
+	JournalStageStarted    = "started"     // 周期开始（AI调用前的账户和持仓快照）
+	JournalStageAIResponse = "ai_response" // AI已响应（原始响应和按执行顺序的计划动作）
+	JournalStageOrder      = "order"       // 单个动作的执行结果
+)
+
+// journalDirName 周期日志目录（位于决策日志目录下，周期正常结束后删除对应文件）
+const journalDirName = "journal"
+
+// CycleJournalEntry 周期日志条目（每行一条JSON，按发生顺序追加）
+type CycleJournalEntry struct {
+	Stage     string           `json:"stage"`
+	Timestamp time.Time        `json:"timestamp"`
+	Record    *DecisionRecord  `json:"record,omitempty"`  // started / ai_response：当时的决策记录
+	Planned   []DecisionAction `json:"planned,omitempty"` // ai_response：按执行顺序的计划动作（含客户端订单ID）
+	Index     int              `json:"index,omitempty"`   // order：动作在计划中的序号
+	Action    *DecisionAction  `json:"action,omitempty"`  // order：动作执行结果
+}
+
+// CycleJournal 进行中周期的执行日志（nil 时所有方法为空操作，写入失败不影响交易）
+type CycleJournal struct {
+	ID   string
+	path string
+}
+
+// BeginCycle 写入周期开始记录（AI调用前的快照）
+func (l *DecisionLogger) BeginCycle(cycleID string, snapshot *DecisionRecord) (*CycleJournal, error) {
+	dir := filepath.Join(l.logDir, journalDirName)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("创建周期日志目录失败: %w", err)
+	}
+	j := &CycleJournal{ID: cycleID, path: filepath.Join(dir, fmt.Sprintf("cycle_%s.jsonl", cycleID))}
+	if err := j.append(CycleJournalEntry{Stage: JournalStageStarted, Record: snapshot}); err != nil {
+		return nil, err
+	}
+	return j, nil
+}
+
+// RecordAIResponse 追加AI响应和计划动作
+func (j *CycleJournal) RecordAIResponse(record *DecisionRecord, planned []DecisionAction) error {
+	if j == nil {
+		return nil
+	}
+	return j.append(CycleJournalEntry{Stage: JournalStageAIResponse, Record: record, Planned: planned})
+}
+
+// RecordOrder 追加单个动作的执行结果
+func (j *CycleJournal) RecordOrder(index int, action DecisionAction) error {
+	if j == nil {
+		return nil
+	}
+	return j.append(CycleJournalEntry{Stage: JournalStageOrder, Index: index, Action: &action})
+}
+
+// Complete 周期决策记录已保存，删除周期日志
+func (j *CycleJournal) Complete() error {
+	if j == nil {
+		return nil
+	}
+	if err := os.Remove(j.path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("删除周期日志失败: %w", err)
+	}
+	return nil
+}
+
+// append 追加一条日志并落盘（进程随时可能被终止）
+func (j *CycleJournal) append(entry CycleJournalEntry) error {
+	if entry.Timestamp.IsZero() {
+		entry.Timestamp = time.Now()
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("序列化周期日志失败: %w", err)
+	}
+
+	f, err := os.OpenFile(j.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("打开周期日志失败: %w", err)
+	}
+	defer f.Close()
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("写入周期日志失败: %w", err)
+	}
+	return f.Sync()
+}
+
+// PendingCycle 未正常结束的周期（进程在周期执行中被终止）
+type PendingCycle struct {
+	ID        string
+	Stage     string                 // 最后写入的阶段
+	StartedAt time.Time              // 周期开始时间
+	Record    *DecisionRecord        // 最新的决策记录快照
+	Planned   []DecisionAction       // 计划动作（AI已响应时）
+	Executed  map[int]DecisionAction // 已记录执行结果的动作（按计划序号）
+	path      string
+}
+
+// PendingCycles 获取未正常结束的周期（按开始时间从旧到新）
+func (l *DecisionLogger) PendingCycles() ([]*PendingCycle, error) {
+	files, err := filepath.Glob(filepath.Join(l.logDir, journalDirName, "cycle_*.jsonl"))
+	if err != nil {
+		return nil, fmt.Errorf("查找周期日志失败: %w", err)
+	}
+
+	var pending []*PendingCycle
+	for _, path := range files {
+		p, err := readPendingCycle(path)
+		if err != nil {
+			fmt.Printf("⚠ 读取周期日志失败 (%s): %v\n", filepath.Base(path), err)
+			continue
+		}
+		if p != nil {
+			pending = append(pending, p)
+		}
+	}
+	sort.Slice(pending, func(i, j int) bool { return pending[i].StartedAt.Before(pending[j].StartedAt) })
+	return pending, nil
+}
+
+// readPendingCycle 重放周期日志；被终止时写了一半的最后一行会被忽略
+func readPendingCycle(path string) (*PendingCycle, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	p := &PendingCycle{
+		ID:       strings.TrimSuffix(strings.TrimPrefix(filepath.Base(path), "cycle_"), ".jsonl"),
+		Executed: make(map[int]DecisionAction),
+		path:     path,
+	}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024) // 记录中包含完整的提示词
+	for scanner.Scan() {
+		var entry CycleJournalEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			break
+		}
+		p.Stage = entry.Stage
+		switch entry.Stage {
+		case JournalStageStarted:
+			p.StartedAt = entry.Timestamp
+			p.Record = entry.Record
+		case JournalStageAIResponse:
+			p.Record = entry.Record
+			p.Planned = entry.Planned
+		case JournalStageOrder:
+			if entry.Action != nil {
+				p.Executed[entry.Index] = *entry.Action
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if p.Record == nil {
+		// 连周期开始记录都没有写完，无可对账的内容
+		os.Remove(path)
+		return nil, nil
+	}
+	return p, nil
+}
+
+// CompleteInterruptedCycle 保存中断周期对账后的决策记录（保留周期开始时间）并删除周期日志
+func (l *DecisionLogger) CompleteInterruptedCycle(p *PendingCycle, record *DecisionRecord) error {
+	if record.Timestamp.IsZero() {
+		record.Timestamp = p.StartedAt
+	}
+	if err := l.writeRecord(record); err != nil {
+		return err
+	}
+	if err := os.Remove(p.path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("删除周期日志失败: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package logger
+
+import (
+	"os"
+	"testing"
+)
+
+// TestPendingCycles_IgnoresTornWrite 测试进程在写入周期日志途中被终止时，写了一半的最后一行被忽略
+func TestPendingCycles_IgnoresTornWrite(t *testing.T) {
+	l := NewDecisionLogger(t.TempDir())
+
+	j, err := l.BeginCycle("100", &DecisionRecord{CandidateCoins: []string{"BTCUSDT"}})
+	if err != nil {
+		t.Fatalf("BeginCycle failed: %v", err)
+	}
+	planned := []DecisionAction{{Action: "close_long", Symbol: "BTCUSDT", ClientOrderID: "abc"}}
+	if err := j.RecordAIResponse(&DecisionRecord{CoTTrace: "cot"}, planned); err != nil {
+		t.Fatalf("RecordAIResponse failed: %v", err)
+	}
+	if err := j.RecordOrder(0, DecisionAction{Action: "close_long", Symbol: "BTCUSDT", Success: true}); err != nil {
+		t.Fatalf("RecordOrder failed: %v", err)
+	}
+
+	// 模拟第二个动作的结果只写了一半
+	f, err := os.OpenFile(j.path, os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		t.Fatalf("open journal failed: %v", err)
+	}
+	f.WriteString(`{"stage":"order","index":1,"action":{"act`)
+	f.Close()
+
+	pending, err := l.PendingCycles()
+	if err != nil {
+		t.Fatalf("PendingCycles failed: %v", err)
+	}
+	if len(pending) != 1 {
+		t.Fatalf("len(pending) = %d, want 1", len(pending))
+	}
+	p := pending[0]
+	if p.ID != "100" || p.Stage != JournalStageOrder || p.StartedAt.IsZero() {
+		t.Errorf("pending = %+v", p)
+	}
+	if p.Record.CoTTrace != "cot" || len(p.Planned) != 1 || len(p.Executed) != 1 || !p.Executed[0].Success {
+		t.Errorf("重放结果不正确: record=%+v planned=%+v executed=%+v", p.Record, p.Planned, p.Executed)
+	}
+
+	// 对账后保存记录（使用周期开始时间）并删除周期日志
+	if err := l.CompleteInterruptedCycle(p, &DecisionRecord{Interrupted: true}); err != nil {
+		t.Fatalf("CompleteInterruptedCycle failed: %v", err)
+	}
+	records, err := l.GetLatestRecords(10)
+	if err != nil || len(records) != 1 || !records[0].Interrupted || !records[0].Timestamp.Equal(p.StartedAt) {
+		t.Fatalf("中断周期记录不正确: %+v, %v", records, err)
+	}
+	if pending, _ := l.PendingCycles(); len(pending) != 0 {
+		t.Errorf("周期日志应已删除: %+v", pending)
+	}
+}
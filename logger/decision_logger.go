@@ -27,6 +27,62 @@ type DecisionRecord struct {
 	ErrorMessage   string             `json:"error_message"`   // 错误信息（如果有）
 	// AIRequestDurationMs 记录 AI API 调用耗时（毫秒），方便评估调用性能
 	AIRequestDurationMs int64 `json:"ai_request_duration_ms,omitempty"`
+	// RiskLimits 本周期生效的动态风控上限
+	RiskLimits *RiskLimitsSnapshot `json:"risk_limits,omitempty"`
+	// TriggerType 周期触发方式（scheduled 定时 / manual 手动），TriggeredBy 手动触发的用户ID
+	TriggerType string `json:"trigger_type,omitempty"`
+	TriggeredBy string `json:"triggered_by,omitempty"`
+	// RequestID 周期请求ID（同时出现在该周期的日志行和AI/交易所请求头中）
+	RequestID string `json:"request_id,omitempty"`
+	// Interrupted 周期执行中进程重启，重启后按周期日志和交易所订单对账补全的记录
+	Interrupted bool `json:"interrupted,omitempty"`
+	// Review 两级模型的复核过程（CoTTrace/DecisionJSON 为扫描模型的输出）
+	Review *ReviewSnapshot `json:"review,omitempty"`
+	// ParseWarnings 解析AI响应时发现的问题（如 multiple_decision_blocks），保留相关原始内容便于排查
+	ParseWarnings []ParseWarning `json:"parse_warnings,omitempty"`
+}
+
+// Rejected 本周期被检查拒绝的决策（带拒绝原因代码的动作）
+func (r *DecisionRecord) Rejected() []DecisionAction {
+	var rejected []DecisionAction
+	for _, action := range r.Decisions {
+		if action.RejectReason != "" {
+			rejected = append(rejected, action)
+		}
+	}
+	return rejected
+}
+
+// ParseWarning 解析AI响应时发现的问题
+type ParseWarning struct {
+	Code     string   `json:"code"`
+	Message  string   `json:"message"`
+	Payloads []string `json:"payloads,omitempty"`
+}
+
+// ReviewSnapshot 复核模型的复核过程快照
+type ReviewSnapshot struct {
+	ScanModel   string `json:"scan_model"`
+	ReviewModel string `json:"review_model"`
+	VerdictJSON string `json:"verdict_json"`           // 每个待复核决策的原始决策、结论和最终执行的决策
+	RawResponse string `json:"raw_response,omitempty"` // 复核模型原始输出
+	DurationMs  int64  `json:"duration_ms"`
+	Error       string `json:"error,omitempty"`    // 复核模型不可用的原因
+	Fallback    string `json:"fallback,omitempty"` // 复核不可用时采用的处理方式（hold/execute）
+}
+
+// RiskLimitsSnapshot 动态风控上限快照
+type RiskLimitsSnapshot struct {
+	DailyPnLPct        float64 `json:"daily_pnl_pct"`
+	DrawdownPct        float64 `json:"drawdown_pct"`
+	SizeFactor         float64 `json:"size_factor"`
+	LeverageFactor     float64 `json:"leverage_factor"`
+	EntriesBlocked     bool    `json:"entries_blocked"`
+	MaxBTCETHLeverage  int     `json:"max_btc_eth_leverage"`
+	MaxAltcoinLeverage int     `json:"max_altcoin_leverage"`
+	MaxBTCETHPosition  float64 `json:"max_btc_eth_position"`
+	MaxAltcoinPosition float64 `json:"max_altcoin_position"`
+	Reason             string  `json:"reason,omitempty"`
 }
 
 // AccountSnapshot 账户状态快照
@@ -36,6 +92,17 @@ type AccountSnapshot struct {
 	TotalUnrealizedProfit float64 `json:"total_unrealized_profit"`
 	PositionCount         int     `json:"position_count"`
 	MarginUsedPct         float64 `json:"margin_used_pct"`
+	// 以上金额均按USD折算；MarginAsset 保证金资产，NativeEquity 净值折合保证金资产的原生数量
+	MarginAsset   string                 `json:"margin_asset,omitempty"`
+	NativeEquity  float64                `json:"native_equity,omitempty"`
+	AssetBalances []AssetBalanceSnapshot `json:"asset_balances,omitempty"` // 各保证金资产明细
+}
+
+// AssetBalanceSnapshot 单个保证金资产快照
+type AssetBalanceSnapshot struct {
+	Asset    string  `json:"asset"`
+	Amount   float64 `json:"amount"`    // 原生数量（钱包余额 + 未实现盈亏）
+	USDValue float64 `json:"usd_value"` // USD折算
 }
 
 // PositionSnapshot 持仓快照
@@ -52,15 +119,38 @@ type PositionSnapshot struct {
 
 // DecisionAction 决策动作
 type DecisionAction struct {
-	Action    string    `json:"action"`    // open_long, open_short, close_long, close_short, update_stop_loss, update_take_profit, partial_close
-	Symbol    string    `json:"symbol"`    // 币种
-	Quantity  float64   `json:"quantity"`  // 数量（部分平仓时使用）
-	Leverage  int       `json:"leverage"`  // 杠杆（开仓时）
-	Price     float64   `json:"price"`     // 执行价格
-	OrderID   int64     `json:"order_id"`  // 订单ID
-	Timestamp time.Time `json:"timestamp"` // 执行时间
-	Success   bool      `json:"success"`   // 是否成功
-	Error     string    `json:"error"`     // 错误信息
+	Action    string    `json:"action"`         // open_long, open_short, add_to_position, close_long, close_short, update_stop_loss, update_take_profit, partial_close
+	Symbol    string    `json:"symbol"`         // 币种
+	Quantity  float64   `json:"quantity"`       // 数量（部分平仓时使用）
+	Leverage  int       `json:"leverage"`       // 杠杆（开仓时）
+	Price     float64   `json:"price"`          // 执行价格
+	OrderID   int64     `json:"order_id"`       // 订单ID
+	Timestamp time.Time `json:"timestamp"`      // 执行时间
+	Success   bool      `json:"success"`        // 是否成功
+	Error     string    `json:"error"`          // 错误信息
+	Note      string    `json:"note,omitempty"` // AI给出的简短说明（面向用户）
+	// Adjustments 执行前风控对决策的调整（如按单币种分配上限缩小仓位）
+	Adjustments []string `json:"adjustments,omitempty"`
+	// PnLPct 平仓时该仓位的收益率（仅平仓动作）
+	PnLPct float64 `json:"pnl_pct,omitempty"`
+	// GuardVerdict 防反复开平仓检查的结论（拒绝原因或高信心放行说明）
+	GuardVerdict string `json:"guard_verdict,omitempty"`
+	// PositionID 本次动作开立或影响的持仓ID（用于还原单笔持仓的完整生命周期）
+	PositionID string `json:"position_id,omitempty"`
+	// ClientOrderID 按周期和决策序号确定的客户端订单ID（重启后据此向交易所查询订单）
+	ClientOrderID string `json:"client_order_id,omitempty"`
+	// IntentKey 开仓意图键（币种、方向、仓位大小和入场价格分桶），用于拒绝重复开仓
+	IntentKey string `json:"intent_key,omitempty"`
+	// RejectReason 执行前被拒绝的原因代码（如 duplicate_intent、max_daily_loss、min_position_size）
+	RejectReason string `json:"reject_reason,omitempty"`
+	// Rejection 面向用户的拒绝说明（AI想执行什么、被哪项检查拦截、原因）
+	Rejection string `json:"rejection,omitempty"`
+	// RequestedSizePct AI按净值百分比给出的仓位（百分比模式开仓/加仓时）
+	RequestedSizePct float64 `json:"requested_size_pct,omitempty"`
+	// SizingEquity 换算仓位时使用的执行时账户净值
+	SizingEquity float64 `json:"sizing_equity,omitempty"`
+	// RealizedSizeUSD 实际下单的仓位价值（数量 × 价格，开仓/加仓成功时）
+	RealizedSizeUSD float64 `json:"realized_size_usd,omitempty"`
 }
 
 // DecisionLogger 决策日志记录器
@@ -91,11 +181,21 @@ func NewDecisionLogger(logDir string) *DecisionLogger {
 	}
 }
 
+// Dir 决策日志目录
+func (l *DecisionLogger) Dir() string {
+	return l.logDir
+}
+
 // LogDecision 记录决策
 func (l *DecisionLogger) LogDecision(record *DecisionRecord) error {
+	record.Timestamp = time.Now()
+	return l.writeRecord(record)
+}
+
+// writeRecord 分配周期编号并写入决策记录文件（使用记录自身的时间戳）
+func (l *DecisionLogger) writeRecord(record *DecisionRecord) error {
 	l.cycleNumber++
 	record.CycleNumber = l.cycleNumber
-	record.Timestamp = time.Now()
 
 	// 生成文件名：decision_YYYYMMDD_HHMMSS_cycleN.json
 	filename := fmt.Sprintf("decision_%s_cycle%d.json",
@@ -226,6 +326,7 @@ func (l *DecisionLogger) GetStatistics() (*Statistics, error) {
 	}
 
 	stats := &Statistics{}
+	var requestedPctSum, realizedPctSum, realizedUSDSum float64
 
 	for _, file := range files {
 		if file.IsDir() {
@@ -246,6 +347,12 @@ func (l *DecisionLogger) GetStatistics() (*Statistics, error) {
 		stats.TotalCycles++
 
 		for _, action := range record.Decisions {
+			if action.Success && action.RequestedSizePct > 0 && action.SizingEquity > 0 {
+				stats.PercentSizedEntries++
+				requestedPctSum += action.RequestedSizePct
+				realizedPctSum += action.RealizedSizeUSD / action.SizingEquity * 100
+				realizedUSDSum += action.RealizedSizeUSD
+			}
 			if action.Success {
 				switch action.Action {
 				case "open_long", "open_short":
@@ -266,6 +373,13 @@ func (l *DecisionLogger) GetStatistics() (*Statistics, error) {
 		}
 	}
 
+	if stats.PercentSizedEntries > 0 {
+		n := float64(stats.PercentSizedEntries)
+		stats.AvgRequestedSizePct = requestedPctSum / n
+		stats.AvgRealizedSizePct = realizedPctSum / n
+		stats.AvgRealizedSizeUSD = realizedUSDSum / n
+	}
+
 	return stats, nil
 }
 
@@ -276,24 +390,32 @@ type Statistics struct {
 	FailedCycles        int `json:"failed_cycles"`
 	TotalOpenPositions  int `json:"total_open_positions"`
 	TotalClosePositions int `json:"total_close_positions"`
+	// 按净值百分比开仓的统计（核对AI请求的百分比与实际下单金额）
+	PercentSizedEntries int     `json:"percent_sized_entries"`
+	AvgRequestedSizePct float64 `json:"avg_requested_size_pct"`
+	AvgRealizedSizePct  float64 `json:"avg_realized_size_pct"` // 实际下单金额占执行时净值的百分比
+	AvgRealizedSizeUSD  float64 `json:"avg_realized_size_usd"`
 }
 
 // TradeOutcome 单笔交易结果
 type TradeOutcome struct {
-	Symbol        string    `json:"symbol"`         // 币种
-	Side          string    `json:"side"`           // long/short
-	Quantity      float64   `json:"quantity"`       // 仓位数量
-	Leverage      int       `json:"leverage"`       // 杠杆倍数
-	OpenPrice     float64   `json:"open_price"`     // 开仓价
-	ClosePrice    float64   `json:"close_price"`    // 平仓价
-	PositionValue float64   `json:"position_value"` // 仓位价值（quantity × openPrice）
-	MarginUsed    float64   `json:"margin_used"`    // 保证金使用（positionValue / leverage）
-	PnL           float64   `json:"pn_l"`           // 盈亏（USDT）
-	PnLPct        float64   `json:"pn_l_pct"`       // 盈亏百分比（相对保证金）
-	Duration      string    `json:"duration"`       // 持仓时长
-	OpenTime      time.Time `json:"open_time"`      // 开仓时间
-	CloseTime     time.Time `json:"close_time"`     // 平仓时间
-	WasStopLoss   bool      `json:"was_stop_loss"`  // 是否止损
+	Symbol        string    `json:"symbol"`                // 币种
+	Side          string    `json:"side"`                  // long/short
+	Quantity      float64   `json:"quantity"`              // 仓位数量
+	Leverage      int       `json:"leverage"`              // 杠杆倍数
+	OpenPrice     float64   `json:"open_price"`            // 开仓价
+	ClosePrice    float64   `json:"close_price"`           // 平仓价
+	PositionValue float64   `json:"position_value"`        // 仓位价值（quantity × openPrice）
+	MarginUsed    float64   `json:"margin_used"`           // 保证金使用（positionValue / leverage）
+	PnL           float64   `json:"pn_l"`                  // 盈亏（USDT）
+	PnLPct        float64   `json:"pn_l_pct"`              // 盈亏百分比（相对保证金）
+	Duration      string    `json:"duration"`              // 持仓时长
+	OpenTime      time.Time `json:"open_time"`             // 开仓时间
+	CloseTime     time.Time `json:"close_time"`            // 平仓时间
+	WasStopLoss   bool      `json:"was_stop_loss"`         // 是否止损
+	OpenNote      string    `json:"open_note,omitempty"`   // 开仓说明
+	CloseNote     string    `json:"close_note,omitempty"`  // 平仓说明
+	PositionID    string    `json:"position_id,omitempty"` // 持仓ID
 }
 
 // PerformanceAnalysis 交易表现分析
@@ -325,6 +447,26 @@ type SymbolPerformance struct {
 
 // AnalyzePerformance 分析最近N个周期的交易表现
 func (l *DecisionLogger) AnalyzePerformance(lookbackCycles int) (*PerformanceAnalysis, error) {
+	return l.analyzePerformance(lookbackCycles, 10)
+}
+
+// GetSymbolTrades 获取最近N个周期内某个币种的全部完整交易（开仓到平仓，最新的在前）
+func (l *DecisionLogger) GetSymbolTrades(lookbackCycles int, symbol string) ([]TradeOutcome, error) {
+	analysis, err := l.analyzePerformance(lookbackCycles, 0)
+	if err != nil {
+		return nil, err
+	}
+	trades := []TradeOutcome{}
+	for _, trade := range analysis.RecentTrades {
+		if trade.Symbol == symbol {
+			trades = append(trades, trade)
+		}
+	}
+	return trades, nil
+}
+
+// analyzePerformance 分析交易表现，RecentTrades 最多保留 maxRecentTrades 笔（0 表示不限制）
+func (l *DecisionLogger) analyzePerformance(lookbackCycles int, maxRecentTrades int) (*PerformanceAnalysis, error) {
 	records, err := l.GetLatestRecords(lookbackCycles)
 	if err != nil {
 		return nil, fmt.Errorf("读取历史记录失败: %w", err)
@@ -380,11 +522,13 @@ func (l *DecisionLogger) AnalyzePerformance(lookbackCycles int) (*PerformanceAna
 				case "open_long", "open_short":
 					// 记录开仓
 					openPositions[posKey] = map[string]interface{}{
-						"side":      side,
-						"openPrice": action.Price,
-						"openTime":  action.Timestamp,
-						"quantity":  action.Quantity,
-						"leverage":  action.Leverage,
+						"side":       side,
+						"openPrice":  action.Price,
+						"openTime":   action.Timestamp,
+						"quantity":   action.Quantity,
+						"leverage":   action.Leverage,
+						"note":       action.Note,
+						"positionID": action.PositionID,
 					}
 				case "close_long", "close_short", "auto_close_long", "auto_close_short":
 					// 移除已平仓记录
@@ -432,6 +576,8 @@ func (l *DecisionLogger) AnalyzePerformance(lookbackCycles int) (*PerformanceAna
 					"openTime":           action.Timestamp,
 					"quantity":           action.Quantity,
 					"leverage":           action.Leverage,
+					"note":               action.Note,
+					"positionID":         action.PositionID,
 					"remainingQuantity":  action.Quantity, // 🔧 BUG FIX：追蹤剩餘數量
 					"accumulatedPnL":     0.0,             // 🔧 BUG FIX：累積部分平倉盈虧
 					"partialCloseCount":  0,               // 🔧 BUG FIX：部分平倉次數
@@ -446,6 +592,11 @@ func (l *DecisionLogger) AnalyzePerformance(lookbackCycles int) (*PerformanceAna
 					side := openPos["side"].(string)
 					quantity := openPos["quantity"].(float64)
 					leverage := openPos["leverage"].(int)
+					openNote, _ := openPos["note"].(string)
+					positionID, _ := openPos["positionID"].(string)
+					if positionID == "" {
+						positionID = action.PositionID
+					}
 
 					// 🔧 BUG FIX：取得追蹤字段（若不存在則初始化）
 					remainingQty, _ := openPos["remainingQuantity"].(float64)
@@ -508,6 +659,9 @@ func (l *DecisionLogger) AnalyzePerformance(lookbackCycles int) (*PerformanceAna
 								Duration:      action.Timestamp.Sub(openTime).String(),
 								OpenTime:      openTime,
 								CloseTime:     action.Timestamp,
+								OpenNote:      openNote,
+								CloseNote:     action.Note,
+								PositionID:    positionID,
 							}
 
 							analysis.RecentTrades = append(analysis.RecentTrades, outcome)
@@ -568,6 +722,9 @@ func (l *DecisionLogger) AnalyzePerformance(lookbackCycles int) (*PerformanceAna
 							Duration:      action.Timestamp.Sub(openTime).String(),
 							OpenTime:      openTime,
 							CloseTime:     action.Timestamp,
+							OpenNote:      openNote,
+							CloseNote:     action.Note,
+							PositionID:    positionID,
 						}
 
 						analysis.RecentTrades = append(analysis.RecentTrades, outcome)
@@ -650,12 +807,12 @@ func (l *DecisionLogger) AnalyzePerformance(lookbackCycles int) (*PerformanceAna
 	}
 
 	// 只保留最近的交易（倒序：最新的在前）
-	if len(analysis.RecentTrades) > 10 {
+	if maxRecentTrades > 0 && len(analysis.RecentTrades) > maxRecentTrades {
 		// 反转数组，让最新的在前
 		for i, j := 0, len(analysis.RecentTrades)-1; i < j; i, j = i+1, j-1 {
 			analysis.RecentTrades[i], analysis.RecentTrades[j] = analysis.RecentTrades[j], analysis.RecentTrades[i]
 		}
-		analysis.RecentTrades = analysis.RecentTrades[:10]
+		analysis.RecentTrades = analysis.RecentTrades[:maxRecentTrades]
 	} else if len(analysis.RecentTrades) > 0 {
 		// 反转数组
 		for i, j := 0, len(analysis.RecentTrades)-1; i < j; i, j = i+1, j-1 {
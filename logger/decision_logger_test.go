@@ -0,0 +1,58 @@
+package logger
+
+import (
+	"testing"
+	"time"
+)
+
+// TestDecisionNoteStored 测试决策说明和持仓ID随决策记录保存，并出现在交易结果中
+func TestDecisionNoteStored(t *testing.T) {
+	l := NewDecisionLogger(t.TempDir())
+	openTime := time.Now().Add(-time.Hour)
+
+	records := []*DecisionRecord{
+		{
+			Success: true,
+			Decisions: []DecisionAction{
+				{Action: "open_long", Symbol: "BTCUSDT", Quantity: 0.1, Leverage: 10, Price: 50000, Timestamp: openTime, Success: true, Note: "bullish RSI divergence", PositionID: "BTC-L-260101000000"},
+			},
+		},
+		{
+			Success: true,
+			Decisions: []DecisionAction{
+				{Action: "close_long", Symbol: "BTCUSDT", Quantity: 0.1, Price: 51000, Timestamp: time.Now(), Success: true, Note: "take profit at resistance"},
+			},
+		},
+	}
+	for _, record := range records {
+		if err := l.LogDecision(record); err != nil {
+			t.Fatalf("LogDecision failed: %v", err)
+		}
+	}
+
+	saved, err := l.GetLatestRecords(10)
+	if err != nil {
+		t.Fatalf("GetLatestRecords failed: %v", err)
+	}
+	if len(saved) != 2 || saved[0].Decisions[0].Note != "bullish RSI divergence" {
+		t.Fatalf("开仓说明未保存: %+v", saved)
+	}
+
+	analysis, err := l.AnalyzePerformance(10)
+	if err != nil {
+		t.Fatalf("AnalyzePerformance failed: %v", err)
+	}
+	if len(analysis.RecentTrades) != 1 {
+		t.Fatalf("RecentTrades = %d, want 1", len(analysis.RecentTrades))
+	}
+	trade := analysis.RecentTrades[0]
+	if trade.OpenNote != "bullish RSI divergence" {
+		t.Errorf("OpenNote = %q", trade.OpenNote)
+	}
+	if trade.CloseNote != "take profit at resistance" {
+		t.Errorf("CloseNote = %q", trade.CloseNote)
+	}
+	if trade.PositionID != "BTC-L-260101000000" {
+		t.Errorf("PositionID = %q, 交易结果应关联开仓的持仓ID", trade.PositionID)
+	}
+}
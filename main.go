@@ -1,24 +1,31 @@
 package main
 
 import (
+	"aspen/admin"
 	"aspen/api"
 	"aspen/auth"
 	"aspen/config"
 	"aspen/crypto"
+	"aspen/httpclient"
+	"aspen/logger"
 	"aspen/manager"
 	"aspen/market"
+	"aspen/mcp"
+	"aspen/metrics"
 	"aspen/pool"
 	"encoding/json"
 	"fmt"
 	"log"
 	"os"
 	"os/signal"
+	"sort"
 	"strconv"
 	"strings"
 	"syscall"
 	"time"
 
 	"github.com/joho/godotenv"
+	"github.com/sirupsen/logrus"
 )
 
 // syncConfigToDatabase 将配置同步到数据库
@@ -57,6 +64,11 @@ func syncConfigToDatabase(database *config.Database, configFile *config.Config)
 		configs["altcoin_leverage"] = strconv.Itoa(configFile.Leverage.AltcoinLeverage)
 	}
 
+	// 同步币种池流动性过滤阈值
+	if configFile.CoinPoolMinVolume > 0 {
+		configs["coin_pool_min_volume"] = strconv.FormatFloat(configFile.CoinPoolMinVolume, 'f', -1, 64)
+	}
+
 	// 如果JWT密钥不为空，也同步
 	if configFile.JWTSecret != "" {
 		configs["jwt_secret"] = configFile.JWTSecret
@@ -111,6 +123,12 @@ func loadBetaCodesToDatabase(database *config.Database) error {
 }
 
 func main() {
+	// 本地管理命令：aspen admin <命令>（不启动服务，优先通过 socket 操作运行中的进程）
+	if len(os.Args) > 1 && os.Args[1] == "admin" {
+		_ = godotenv.Load()
+		os.Exit(admin.RunCLI(os.Args[2:], os.Stdout, os.Stderr))
+	}
+
 	fmt.Println("╔════════════════════════════════════════════════════════════╗")
 	fmt.Println("║    🤖 AI多模型交易系统 - 支持 DeepSeek & Qwen            ║")
 	fmt.Println("╚════════════════════════════════════════════════════════════╝")
@@ -133,8 +151,47 @@ func main() {
 		cfg = &config.Config{}
 	}
 
+	// 行情和AI客户端共享的HTTP连接池（需在创建客户端之前设置）
+	httpclient.Configure(httpclient.PoolConfig{
+		MaxIdleConns:        cfg.HTTPMaxIdleConns,
+		MaxIdleConnsPerHost: cfg.HTTPMaxIdleConnsPerHost,
+		IdleConnTimeout:     time.Duration(cfg.HTTPIdleConnTimeoutSeconds) * time.Second,
+	})
+	httpclient.SetRequestIDHeader(cfg.RequestIDHeader)
+
 	// 初始化市场数据源
 	market.InitDataSource(cfg.MarketDataSource, cfg.FinnhubAPIKey)
+	market.SetDataSourceFailover(cfg.MarketDataFailoverSource, cfg.MarketDataFailoverThreshold)
+	market.SetIntradaySeriesLength(cfg.IntradaySeriesLen)
+	market.SetDerivativesFallback(cfg.DerivativesFallback)
+	market.SetFundingRateMaxAge(cfg.FundingRateMaxAgeMinutes)
+	market.SetHideDerivativesOnSpot(cfg.HideDerivativesOnSpot == nil || *cfg.HideDerivativesOnSpot)
+	market.SetPricePrecisionOverrides(cfg.PricePrecision)
+	market.SetMinPositionSizeOverrides(cfg.MinPositionSize)
+	market.SetMaxLeverageOverrides(cfg.MaxLeverage)
+	market.SetKlineGapBackfill(cfg.KlineGapBackfill == nil || *cfg.KlineGapBackfill, cfg.KlineGapMaxBackfill)
+
+	// AI 请求/响应调试日志（环境变量 AI_LOG_PROMPTS 优先于 config.json，默认关闭）
+	aiLogPrompts := cfg.AILogPrompts
+	if envValue := strings.TrimSpace(os.Getenv(mcp.PromptLogEnvName)); envValue != "" {
+		if parsed, err := strconv.ParseBool(envValue); err == nil {
+			aiLogPrompts = parsed
+		} else {
+			log.Printf("⚠️  环境变量 %s 无效 (%s)，使用配置值: %v", mcp.PromptLogEnvName, envValue, aiLogPrompts)
+		}
+	}
+	mcp.SetPromptLogging(aiLogPrompts)
+	if aiLogPrompts {
+		if !logger.Log.IsLevelEnabled(logrus.DebugLevel) {
+			logger.Log.SetLevel(logrus.DebugLevel)
+		}
+		log.Printf("📝 已开启 AI 请求/响应调试日志（debug 级别，API Key 和用户标识已脱敏），日志量较大，仅用于排查问题")
+	}
+	go func() {
+		if err := market.LoadExchangePricePrecisions(); err != nil {
+			log.Printf("⚠️  加载交易所价格精度和最小名义价值失败，使用动态精度和默认最小开仓金额: %v", err)
+		}
+	}()
 
 	log.Printf("📋 初始化配置数据库: %s", dbPath)
 	database, err := config.NewDatabase(dbPath)
@@ -183,6 +240,23 @@ func main() {
 	}
 	auth.SetJWTSecret(jwtSecret)
 
+	// Prometheus /metrics 保护和 trader_id 标签粒度（环境变量 METRICS_* 优先于系统配置）
+	metricsCfg, err := metrics.LoadScrapeConfig(func(key string) string {
+		if value := strings.TrimSpace(os.Getenv(strings.ToUpper(key))); value != "" {
+			return value
+		}
+		value, _ := database.GetSystemConfig(key)
+		return value
+	})
+	if err != nil {
+		log.Fatalf("❌ /metrics 配置无效: %v", err)
+	}
+	metrics.Configure(metricsCfg)
+	if !metricsCfg.AuthEnabled() && len(metricsCfg.AllowedCIDRs) == 0 {
+		log.Printf("⚠️  /metrics 未启用抓取认证和来源限制，任何能访问端口的人都可以读取指标（设置 METRICS_BEARER_TOKEN 或 metrics_allowed_cidrs）")
+	}
+	log.Printf("📈 指标 trader_id 标签粒度: %s", metricsCfg.LabelMode)
+
 	// 设置auth的数据库依赖，启用token黑名单持久化
 	auth.SetDatabase(database)
 	auth.LoadBlacklistFromDB()
@@ -231,8 +305,19 @@ func main() {
 		log.Printf("✓ 已配置OI Top API")
 	}
 
+	// 设置币种池流动性过滤（24h成交额下限）
+	if minVolumeStr, _ := database.GetSystemConfig("coin_pool_min_volume"); minVolumeStr != "" {
+		if minVolume, err := strconv.ParseFloat(minVolumeStr, 64); err == nil {
+			pool.SetMinQuoteVolume(minVolume)
+		} else {
+			log.Printf("⚠️  解析coin_pool_min_volume配置失败: %v", err)
+		}
+	}
+
 	// 创建TraderManager
 	traderManager := manager.NewTraderManager()
+	// 交易员异常退出后自动重启（按系统配置的退避策略）
+	traderManager.EnableAutoRestart(database)
 
 	// 从数据库加载所有交易员到内存
 	err = traderManager.LoadTradersFromDatabase(database)
@@ -308,8 +393,25 @@ func main() {
 		}
 	}()
 
-	// 启动流行情数据 - 默认使用所有交易员设置的币种 如果没有设置币种 则优先使用系统默认
-	go market.NewWSMonitor(150).Start(database.GetCustomCoins())
+	// 启动本地管理 socket（aspen admin 命令通过它操作运行中的进程）
+	adminSocket, err := admin.ListenSocket(admin.DefaultSocketPath(), admin.NewService(database, traderManager))
+	if err != nil {
+		log.Printf("⚠️  启动本地管理 socket 失败: %v", err)
+	} else {
+		log.Printf("🔧 本地管理 socket: %s", admin.DefaultSocketPath())
+	}
+
+	// 启动流行情数据 - 订阅所有已加载交易员需要的币种（含系统默认币种），之后由订阅对账器随交易员变更自动维护
+	wsMonitor := market.NewWSMonitor(150)
+	go func() {
+		var coins []string
+		for symbol := range traderManager.RequiredSymbols(database.GetCustomCoins()) {
+			coins = append(coins, symbol)
+		}
+		sort.Strings(coins)
+		wsMonitor.Start(coins)
+		traderManager.StartSubscriptionReconciler(wsMonitor, database)
+	}()
 	//go market.NewWSMonitor(150).Start([]string{}) //这里是一个使用方式 传入空的话 则使用market市场的所有币种
 	// 设置优雅退出
 	sigChan := make(chan os.Signal, 1)
@@ -317,6 +419,10 @@ func main() {
 
 	// 自动启动数据库中配置为运行状态的交易员
 	go func() {
+		if manager.KillSwitchEnabled(database) {
+			log.Printf("🛑 全局交易开关已开启，跳过自动启动交易员")
+			return
+		}
 		userIDs, err := database.GetAllUsers()
 		if err != nil {
 			log.Printf("⚠️  获取用户列表失败，跳过自动启动: %v", err)
@@ -343,7 +449,7 @@ func main() {
 				traderName := traderCfg.Name
 				go func() {
 					log.Printf("▶️  自动启动交易员 %s (%s)", traderName, traderID)
-					if err := t.Run(); err != nil {
+					if err := traderManager.RunTrader(t); err != nil {
 						log.Printf("❌ 交易员 %s 运行错误: %v", traderName, err)
 					}
 				}()
@@ -374,6 +480,10 @@ func main() {
 		log.Println("✅ API 服务器已安全关闭")
 	}
 
+	if adminSocket != nil {
+		adminSocket.Close()
+	}
+
 	// 步骤 3: 关闭数据库连接 (确保所有写入完成)
 	log.Println("💾 关闭数据库连接...")
 	if err := database.Close(); err != nil {
@@ -0,0 +1,48 @@
+package manager
+
+import (
+	"fmt"
+	"log"
+	"sort"
+
+	"aspen/config"
+)
+
+// FlattenResult 单个交易员的一键清仓结果
+type FlattenResult struct {
+	TraderID string   `json:"trader_id"`
+	UserID   string   `json:"user_id"`
+	Closed   []string `json:"closed"`          // 已平仓的持仓（"SYMBOL side"）
+	Error    string   `json:"error,omitempty"` // 获取持仓或平仓失败的原因
+}
+
+// FlattenAll 一键清仓：先开启全局交易开关（停止所有交易员并禁止启动，避免清仓过程中再开仓），
+// 再平掉所有已加载交易员的全部持仓。返回被停止的交易员ID和每个交易员的清仓结果（按交易员ID排序）；
+// 部分持仓平仓失败时其余交易员照常清仓，失败原因记录在对应结果中
+func (tm *TraderManager) FlattenAll(database *config.Database) ([]string, []FlattenResult, error) {
+	stopped, err := tm.SetKillSwitch(database, true)
+	if err != nil {
+		return stopped, nil, fmt.Errorf("暂停交易失败: %w", err)
+	}
+
+	traders := tm.GetAllTraders()
+	results := make([]FlattenResult, 0, len(traders))
+	failed := 0
+	for id, at := range traders {
+		result := FlattenResult{TraderID: id, UserID: at.GetUserID()}
+		closed, err := at.FlattenPositions()
+		result.Closed = closed
+		if result.Closed == nil {
+			result.Closed = []string{}
+		}
+		if err != nil {
+			result.Error = err.Error()
+			failed++
+		}
+		results = append(results, result)
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].TraderID < results[j].TraderID })
+
+	log.Printf("🚨 一键清仓完成：%d 个交易员，%d 个清仓失败", len(results), failed)
+	return stopped, results, nil
+}
@@ -0,0 +1,48 @@
+package manager
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestStartTrader_DisabledUntilReset 测试因硬回撤停用的交易员重新加载后仍不能启动，手动重置后才允许启动
+func TestStartTrader_DisabledUntilReset(t *testing.T) {
+	_, db := newPositionLimitTest(t)
+	require.NoError(t, db.UpdateTraderStatus("default", "t-a", true))
+	require.NoError(t, db.DisableTrader("default", "t-a", "硬回撤熔断：回撤 25.00%（上限 20.00%）"))
+
+	record, _, _, err := db.GetTraderConfig("default", "t-a")
+	require.NoError(t, err)
+	assert.False(t, record.IsRunning, "停用时标记为未运行，进程重启后不自动启动")
+	assert.Contains(t, record.DisabledReason, "硬回撤熔断")
+
+	// 模拟进程重启：从数据库重新加载
+	tm := NewTraderManager()
+	require.NoError(t, tm.LoadTradersFromDatabase(db))
+	at, err := tm.GetTrader("t-a")
+	require.NoError(t, err)
+
+	err = tm.StartTrader(db, "default", at)
+	assert.ErrorIs(t, err, ErrTraderDisabled)
+	assert.Contains(t, err.Error(), "硬回撤熔断")
+	assert.False(t, tm.IsTraderRunning(at))
+
+	require.NoError(t, db.SaveHardDrawdownPeak("default", "t-a", 1500))
+	require.NoError(t, tm.ResetTraderDisabled(db, "default", "t-a"))
+	assert.Empty(t, at.DisabledReason())
+	record, _, _, err = db.GetTraderConfig("default", "t-a")
+	require.NoError(t, err)
+	assert.Empty(t, record.DisabledReason)
+	assert.Zero(t, record.HardDrawdownPeak, "重置时清除最高净值")
+	assert.True(t, record.HardDrawdownRebase, "重启后仍以下一次的净值重新计算回撤")
+
+	require.NoError(t, db.SaveHardDrawdownPeak("default", "t-a", 800))
+	record, _, _, err = db.GetTraderConfig("default", "t-a")
+	require.NoError(t, err)
+	assert.Equal(t, 800.0, record.HardDrawdownPeak)
+	assert.False(t, record.HardDrawdownRebase)
+
+	assert.Error(t, tm.ResetTraderDisabled(db, "default", "missing"))
+}
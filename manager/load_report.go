@@ -0,0 +1,113 @@
+package manager
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"aspen/config"
+)
+
+// TraderLoadError 交易员加载失败记录
+type TraderLoadError struct {
+	TraderID   string    `json:"trader_id"`
+	TraderName string    `json:"trader_name"`
+	UserID     string    `json:"user_id"`
+	Reason     string    `json:"reason"`
+	FailedAt   time.Time `json:"failed_at"`
+}
+
+// LoadReport 启动加载一致性报告：数据库中的交易员记录与内存中的交易员实例对比
+type LoadReport struct {
+	Total       int                `json:"total"`  // 数据库中的交易员记录数
+	Loaded      int                `json:"loaded"` // 已加载到内存的交易员数
+	Failed      []*TraderLoadError `json:"failed"` // 加载失败的交易员及原因
+	GeneratedAt time.Time          `json:"generated_at"`
+}
+
+// Summary 报告摘要（用于启动日志）
+func (r *LoadReport) Summary() string {
+	summary := fmt.Sprintf("%d/%d 个交易员已加载", r.Loaded, r.Total)
+	if len(r.Failed) == 0 {
+		return summary
+	}
+	reasons := make([]string, 0, len(r.Failed))
+	for _, e := range r.Failed {
+		reasons = append(reasons, fmt.Sprintf("%s(%s): %s", e.TraderName, e.TraderID, e.Reason))
+	}
+	return fmt.Sprintf("%s，%d 个失败: %s", summary, len(r.Failed), strings.Join(reasons, "; "))
+}
+
+// recordLoadError 记录交易员加载失败原因（不加锁，调用方已加锁）
+func (tm *TraderManager) recordLoadError(traderCfg *config.TraderRecord, reason string) {
+	log.Printf("❌ 交易员 %s (%s) 加载失败: %s", traderCfg.Name, traderCfg.ID, reason)
+	tm.loadErrors[traderCfg.ID] = &TraderLoadError{
+		TraderID:   traderCfg.ID,
+		TraderName: traderCfg.Name,
+		UserID:     traderCfg.UserID,
+		Reason:     reason,
+		FailedAt:   time.Now().UTC(),
+	}
+}
+
+// verifyLoadedTraders 校验数据库中的每条交易员记录在内存中都有对应实例，生成加载报告（不加锁，调用方已加锁）
+// 未记录失败原因却不在内存中的交易员也计入失败，避免静默丢失
+func (tm *TraderManager) verifyLoadedTraders(records []*config.TraderRecord) *LoadReport {
+	report := &LoadReport{Total: len(records), Failed: []*TraderLoadError{}, GeneratedAt: time.Now().UTC()}
+	for _, record := range records {
+		if _, ok := tm.traders[record.ID]; ok {
+			delete(tm.loadErrors, record.ID)
+			report.Loaded++
+			continue
+		}
+		if _, ok := tm.loadErrors[record.ID]; !ok {
+			tm.recordLoadError(record, "未加载到内存（原因未知）")
+		}
+		report.Failed = append(report.Failed, tm.loadErrors[record.ID])
+	}
+	sort.Slice(report.Failed, func(i, j int) bool { return report.Failed[i].TraderID < report.Failed[j].TraderID })
+	return report
+}
+
+// GetLoadReport 获取当前加载报告（启动后重试成功的交易员会从失败列表中移除）
+func (tm *TraderManager) GetLoadReport() *LoadReport {
+	tm.mu.RLock()
+	defer tm.mu.RUnlock()
+
+	report := &LoadReport{Loaded: len(tm.traders), Failed: []*TraderLoadError{}, GeneratedAt: tm.loadedAt}
+	for _, e := range tm.loadErrors {
+		copied := *e
+		report.Failed = append(report.Failed, &copied)
+	}
+	sort.Slice(report.Failed, func(i, j int) bool { return report.Failed[i].TraderID < report.Failed[j].TraderID })
+	report.Total = report.Loaded + len(report.Failed)
+	return report
+}
+
+// GetLoadError 获取交易员的加载失败记录，未失败时返回nil
+func (tm *TraderManager) GetLoadError(traderID string) *TraderLoadError {
+	tm.mu.RLock()
+	defer tm.mu.RUnlock()
+
+	if e, ok := tm.loadErrors[traderID]; ok {
+		copied := *e
+		return &copied
+	}
+	return nil
+}
+
+// RetryLoadTrader 重新加载单个交易员（用户修复配置后无需重启进程）
+// 加载成功会清除失败记录，失败则更新失败原因
+func (tm *TraderManager) RetryLoadTrader(database *config.Database, userID, traderID string) error {
+	log.Printf("🔄 重试加载交易员 %s", traderID)
+	return tm.LoadTraderByID(database, userID, traderID)
+}
+
+// ClearLoadError 清除交易员的加载失败记录（交易员被删除时调用）
+func (tm *TraderManager) ClearLoadError(traderID string) {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	delete(tm.loadErrors, traderID)
+}
@@ -0,0 +1,112 @@
+package manager
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+
+	"aspen/config"
+	"aspen/decision"
+	"aspen/trader"
+)
+
+// userOpenLocks 按用户串行化开仓名额检查，名额在下单结束前一直占用
+type userOpenLocks struct {
+	mu    sync.Mutex
+	users map[string]*sync.Mutex
+}
+
+// lock 获取用户的开仓锁
+func (l *userOpenLocks) lock(userID string) *sync.Mutex {
+	l.mu.Lock()
+	if l.users == nil {
+		l.users = make(map[string]*sync.Mutex)
+	}
+	m, ok := l.users[userID]
+	if !ok {
+		m = &sync.Mutex{}
+		l.users[userID] = m
+	}
+	l.mu.Unlock()
+
+	m.Lock()
+	return m
+}
+
+// userPositionGate 用户级最大同时持仓数：同一用户所有交易员的持仓合计达到上限时拒绝新开仓。
+// 上限在每次开仓时从数据库读取，修改后立即生效
+type userPositionGate struct {
+	tm       *TraderManager
+	database *config.Database
+}
+
+// positionGate 交易员使用的开仓协调器（没有数据库时不限制）
+func (tm *TraderManager) positionGate(database *config.Database) trader.OpenGate {
+	if database == nil {
+		return nil
+	}
+	return &userPositionGate{tm: tm, database: database}
+}
+
+// AcquireOpen 检查用户合计持仓数，未超限时占用名额直到 release 被调用
+func (g *userPositionGate) AcquireOpen(at *trader.AutoTrader, symbol, side string) (func(), error) {
+	userID := at.GetUserID()
+	limit, err := g.database.GetUserMaxConcurrentPositions(userID)
+	if err != nil {
+		log.Printf("⚠️  读取用户 %s 最大同时持仓数失败: %v，本次不限制", userID, err)
+		return func() {}, nil
+	}
+	if limit <= 0 {
+		return func() {}, nil
+	}
+
+	m := g.tm.openLocks.lock(userID)
+	open, err := g.tm.userPositionKeys(userID)
+	if err != nil {
+		m.Unlock()
+		return nil, fmt.Errorf("❌ 无法统计用户持仓（最大同时持仓数 %d），拒绝开仓: %w", limit, err)
+	}
+	// 同一交易所账户上已有该持仓（其他交易员开的）时不占用新名额
+	if !open[positionKey(at, symbol, side)] && len(open) >= limit {
+		m.Unlock()
+		return nil, decision.Reject(trader.RejectMaxConcurrentPositions,
+			fmt.Errorf("❌ 所有交易员合计已有 %d 个持仓，达到用户最大同时持仓数 %d，拒绝开仓 %s", len(open), limit, symbol))
+	}
+	return m.Unlock, nil
+}
+
+// userPositionKeys 用户所有已加载交易员的持仓（同一交易所账户上的持仓只计一次）
+func (tm *TraderManager) userPositionKeys(userID string) (map[string]bool, error) {
+	tm.mu.RLock()
+	var traders []*trader.AutoTrader
+	for _, at := range tm.traders {
+		if at.GetUserID() == userID {
+			traders = append(traders, at)
+		}
+	}
+	tm.mu.RUnlock()
+
+	keys := make(map[string]bool)
+	for _, at := range traders {
+		positions, err := at.GetPositions()
+		if err != nil {
+			return nil, fmt.Errorf("交易员 %s: %w", at.GetName(), err)
+		}
+		for _, pos := range positions {
+			symbol, _ := pos["symbol"].(string)
+			side, _ := pos["side"].(string)
+			keys[positionKey(at, symbol, side)] = true
+		}
+	}
+	return keys, nil
+}
+
+// positionKey 持仓所在账户内的唯一标识：模拟仓每个交易员是独立账户，实盘交易员共用用户在该交易所的账户
+func positionKey(at *trader.AutoTrader, symbol, side string) string {
+	account := at.GetExchange()
+	if account == "paper" {
+		account = "paper:" + at.GetID()
+	}
+	return account + "|" + strings.ToUpper(symbol) + "|" + strings.ToLower(side)
+}
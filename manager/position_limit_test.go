@@ -0,0 +1,116 @@
+package manager
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"aspen/config"
+	"aspen/market"
+
+	"github.com/agiledragon/gomonkey/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newPositionLimitTest 加载同一用户的两个模拟仓交易员：A 持有 BTCUSDT 多仓，B 持有 ETHUSDT 空仓
+func newPositionLimitTest(t *testing.T) (*TraderManager, *config.Database) {
+	t.Helper()
+	t.Chdir(t.TempDir()) // 交易员加载时会创建决策日志目录
+	patches := gomonkey.ApplyFunc(market.GetCachedPrice, func(symbol string) (float64, bool) {
+		return 100, true
+	})
+	t.Cleanup(patches.Reset)
+
+	db, err := config.NewDatabase(filepath.Join(t.TempDir(), "config.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	require.NoError(t, db.CreateUser(&config.User{ID: "default", Email: "default@localhost", OTPVerified: true}))
+	require.NoError(t, db.UpdateAIModel("default", "deepseek", true, "sk-test", "", ""))
+	require.NoError(t, db.UpdateExchange("default", "paper", true, "", "", false, "", "", "", "", 1000))
+	for _, id := range []string{"t-a", "t-b"} {
+		require.NoError(t, db.CreateTrader(&config.TraderRecord{
+			ID: id, UserID: "default", Name: id, AIModelID: "deepseek", ExchangeID: "paper", InitialBalance: 1000,
+		}))
+	}
+	require.NoError(t, db.SavePaperTraderState("t-a", 1000, 900, 0,
+		`{"BTCUSDT_LONG":{"symbol":"BTCUSDT","side":"LONG","quantity":1,"entry_price":100,"leverage":1,"margin":100}}`))
+	require.NoError(t, db.SavePaperTraderState("t-b", 1000, 900, 0,
+		`{"ETHUSDT_SHORT":{"symbol":"ETHUSDT","side":"SHORT","quantity":1,"entry_price":100,"leverage":1,"margin":100}}`))
+
+	tm := NewTraderManager()
+	require.NoError(t, tm.LoadTradersFromDatabase(db))
+	return tm, db
+}
+
+// TestUserPositionGate_RejectsOverUserCap 测试两个交易员的持仓合计达到用户上限时，任一交易员的新开仓都被拒绝
+func TestUserPositionGate_RejectsOverUserCap(t *testing.T) {
+	tm, db := newPositionLimitTest(t)
+	a, err := tm.GetTrader("t-a")
+	require.NoError(t, err)
+	b, err := tm.GetTrader("t-b")
+	require.NoError(t, err)
+	gate := tm.positionGate(db)
+
+	// 未设置上限时不限制
+	release, err := gate.AcquireOpen(b, "SOLUSDT", "long")
+	require.NoError(t, err)
+	release()
+
+	// 上限3：合计2个持仓，B 可以开第3个
+	require.NoError(t, db.UpdateUserMaxConcurrentPositions("default", 3))
+	release, err = gate.AcquireOpen(b, "SOLUSDT", "long")
+	require.NoError(t, err)
+	release()
+
+	// 上限2：A 和 B 各有1个持仓，合计已达上限，两个交易员都不能再开仓
+	require.NoError(t, db.UpdateUserMaxConcurrentPositions("default", 2))
+	_, err = gate.AcquireOpen(b, "SOLUSDT", "long")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "合计已有 2 个持仓，达到用户最大同时持仓数 2")
+	_, err = gate.AcquireOpen(a, "ETHUSDT", "long")
+	require.Error(t, err)
+
+	// 上限按用户保存，不存在的用户返回错误
+	limit, err := db.GetUserMaxConcurrentPositions("default")
+	require.NoError(t, err)
+	assert.Equal(t, 2, limit)
+	assert.Error(t, db.UpdateUserMaxConcurrentPositions("nobody", 1))
+}
+
+// TestUserPositionGate_SerializesOpens 测试同一用户的开仓名额在下单结束前一直占用，另一个交易员的开仓等待前一个结束后再检查
+func TestUserPositionGate_SerializesOpens(t *testing.T) {
+	tm, db := newPositionLimitTest(t)
+	a, err := tm.GetTrader("t-a")
+	require.NoError(t, err)
+	b, err := tm.GetTrader("t-b")
+	require.NoError(t, err)
+	gate := tm.positionGate(db)
+	require.NoError(t, db.UpdateUserMaxConcurrentPositions("default", 3))
+
+	releaseA, err := gate.AcquireOpen(a, "SOLUSDT", "long")
+	require.NoError(t, err)
+
+	acquired := make(chan error, 1)
+	go func() {
+		release, err := gate.AcquireOpen(b, "BNBUSDT", "short")
+		if err == nil {
+			release()
+		}
+		acquired <- err
+	}()
+
+	select {
+	case <-acquired:
+		t.Fatal("A 下单结束前 B 不应获得开仓名额")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	releaseA()
+	select {
+	case err := <-acquired:
+		assert.NoError(t, err)
+	case <-time.After(2 * time.Second):
+		t.Fatal("A 释放名额后 B 应继续检查")
+	}
+}
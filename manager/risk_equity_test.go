@@ -0,0 +1,32 @@
+package manager
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestSaveRiskEquity_ReloadedWithTrader 测试保存的动态风控净值峰值和当日起始净值随交易员配置加载，进程重启后沿用
+func TestSaveRiskEquity_ReloadedWithTrader(t *testing.T) {
+	_, db := newPositionLimitTest(t)
+	require.NoError(t, db.SaveRiskEquity("default", "t-a", 1500, 1200, "2026-03-01"))
+
+	record, _, _, err := db.GetTraderConfig("default", "t-a")
+	require.NoError(t, err)
+	assert.Equal(t, 1500.0, record.RiskPeakEquity)
+	assert.Equal(t, 1200.0, record.DayStartEquity)
+	assert.Equal(t, "2026-03-01", record.DayStartDate)
+
+	traders, err := db.GetTraders("default")
+	require.NoError(t, err)
+	found := false
+	for _, tr := range traders {
+		if tr.ID == "t-a" {
+			found = true
+			assert.Equal(t, 1500.0, tr.RiskPeakEquity)
+			assert.Equal(t, "2026-03-01", tr.DayStartDate)
+		}
+	}
+	assert.True(t, found)
+}
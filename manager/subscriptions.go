@@ -0,0 +1,355 @@
+package manager
+
+import (
+	"encoding/json"
+	"log"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"aspen/config"
+	"aspen/market"
+	"aspen/metrics"
+	"aspen/pool"
+	"aspen/trader"
+)
+
+// DefaultCoinsOwner 系统默认币种在订阅状态中的需求方名称（没有交易员需要时也保持订阅）
+const DefaultCoinsOwner = "default"
+
+// SubscriptionMonitor 实时行情订阅（WebSocket 监控器）
+type SubscriptionMonitor interface {
+	SubscribedSymbols() []string
+	Subscribe(symbols []string) error
+	Unsubscribe(symbols []string) error
+}
+
+// SubscriptionConfig 行情订阅对账配置
+type SubscriptionConfig struct {
+	Interval time.Duration // 定期对账间隔
+	Grace    time.Duration // 币种不再被需要后保留订阅的时长，避免交易员重载或币种池波动时反复订阅
+}
+
+// DefaultSubscriptionConfig 默认对账配置：每分钟对账，不再需要的币种保留10分钟
+func DefaultSubscriptionConfig() SubscriptionConfig {
+	return SubscriptionConfig{
+		Interval: time.Minute,
+		Grace:    10 * time.Minute,
+	}
+}
+
+// SymbolSubscription 单个币种的订阅状态
+type SymbolSubscription struct {
+	Symbol        string     `json:"symbol"`
+	Subscribed    bool       `json:"subscribed"`
+	RequiredBy    []string   `json:"required_by"`              // 需要该币种的交易员ID（系统默认币种为 "default"）
+	UnneededSince *time.Time `json:"unneeded_since,omitempty"` // 不再被需要的时间，超过宽限期后取消订阅
+}
+
+// SubscriptionStatus 行情订阅状态
+type SubscriptionStatus struct {
+	Symbols        []SymbolSubscription `json:"symbols"`
+	LastReconciled time.Time            `json:"last_reconciled"`
+	LastError      string               `json:"last_error,omitempty"`
+	GraceSeconds   float64              `json:"grace_seconds"`
+}
+
+// SubscriptionReconciler 行情订阅对账器：对比交易员需要的币种与已订阅的币种，
+// 订阅缺少的币种（监控器负责 REST 回填），不再需要的币种超过宽限期后取消订阅
+type SubscriptionReconciler struct {
+	monitor  SubscriptionMonitor
+	required func() map[string][]string // 币种 -> 需要该币种的交易员ID
+	cfg      SubscriptionConfig
+	trigger  chan struct{}
+
+	mu             sync.Mutex
+	unneededSince  map[string]time.Time
+	lastRequired   map[string][]string
+	lastReconciled time.Time
+	lastError      string
+}
+
+// NewSubscriptionReconciler 创建行情订阅对账器
+func NewSubscriptionReconciler(monitor SubscriptionMonitor, required func() map[string][]string, cfg SubscriptionConfig) *SubscriptionReconciler {
+	return &SubscriptionReconciler{
+		monitor:       monitor,
+		required:      required,
+		cfg:           cfg,
+		trigger:       make(chan struct{}, 1),
+		unneededSince: make(map[string]time.Time),
+	}
+}
+
+// Reconcile 执行一次对账，返回新订阅和取消订阅的币种
+func (r *SubscriptionReconciler) Reconcile(now time.Time) (added, removed []string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	required := r.required()
+	subscribed := make(map[string]bool)
+	for _, symbol := range r.monitor.SubscribedSymbols() {
+		subscribed[symbol] = true
+	}
+
+	var missing, expired []string
+	for symbol := range required {
+		delete(r.unneededSince, symbol)
+		if !subscribed[symbol] {
+			missing = append(missing, symbol)
+		}
+	}
+	for symbol := range subscribed {
+		if _, ok := required[symbol]; ok {
+			continue
+		}
+		since, ok := r.unneededSince[symbol]
+		if !ok {
+			r.unneededSince[symbol] = now
+			continue
+		}
+		if now.Sub(since) >= r.cfg.Grace {
+			expired = append(expired, symbol)
+		}
+	}
+	// 已不在订阅中的币种无需继续计时
+	for symbol := range r.unneededSince {
+		if !subscribed[symbol] {
+			delete(r.unneededSince, symbol)
+		}
+	}
+	sort.Strings(missing)
+	sort.Strings(expired)
+
+	r.lastError = ""
+	if len(missing) > 0 {
+		err := r.monitor.Subscribe(missing)
+		if err != nil {
+			log.Printf("⚠️  [订阅对账] 订阅部分币种失败（下次对账重试）: %v", err)
+			r.lastError = err.Error()
+		}
+		// 只统计实际订阅成功的币种
+		current := make(map[string]bool)
+		for _, symbol := range r.monitor.SubscribedSymbols() {
+			current[symbol] = true
+		}
+		for _, symbol := range missing {
+			if current[symbol] {
+				added = append(added, symbol)
+			} else {
+				metrics.SubscriptionReconcileTotal.WithLabelValues("subscribe_failed").Inc()
+			}
+		}
+		if len(added) > 0 {
+			log.Printf("📡 [订阅对账] 新订阅 %d 个币种: %v", len(added), added)
+			metrics.SubscriptionReconcileTotal.WithLabelValues("subscribed").Add(float64(len(added)))
+		}
+	}
+	if len(expired) > 0 {
+		if err := r.monitor.Unsubscribe(expired); err != nil {
+			log.Printf("⚠️  [订阅对账] 取消订阅失败: %v", err)
+			r.lastError = err.Error()
+		}
+		for _, symbol := range expired {
+			delete(r.unneededSince, symbol)
+		}
+		removed = expired
+		log.Printf("📴 [订阅对账] 已超过宽限期 %v，取消订阅 %d 个币种: %v", r.cfg.Grace, len(removed), removed)
+		metrics.SubscriptionReconcileTotal.WithLabelValues("unsubscribed").Add(float64(len(removed)))
+	}
+
+	r.lastRequired = required
+	r.lastReconciled = now
+	return added, removed
+}
+
+// Trigger 请求尽快对账（交易员创建、更新、删除时调用，不阻塞）
+func (r *SubscriptionReconciler) Trigger() {
+	select {
+	case r.trigger <- struct{}{}:
+	default:
+	}
+}
+
+// Run 定期对账并响应 Trigger（阻塞，stop 关闭后返回）
+func (r *SubscriptionReconciler) Run(stop <-chan struct{}) {
+	ticker := time.NewTicker(r.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		case <-r.trigger:
+		}
+		r.Reconcile(time.Now())
+	}
+}
+
+// Status 当前订阅状态：已订阅和需要订阅的币种及需要它们的交易员
+func (r *SubscriptionReconciler) Status() SubscriptionStatus {
+	subscribed := r.monitor.SubscribedSymbols()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	bySymbol := make(map[string]*SymbolSubscription)
+	for _, symbol := range subscribed {
+		bySymbol[symbol] = &SymbolSubscription{Symbol: symbol, Subscribed: true, RequiredBy: []string{}}
+	}
+	for symbol, owners := range r.lastRequired {
+		sub, ok := bySymbol[symbol]
+		if !ok {
+			sub = &SymbolSubscription{Symbol: symbol}
+			bySymbol[symbol] = sub
+		}
+		sub.RequiredBy = owners
+	}
+	for symbol, since := range r.unneededSince {
+		if sub, ok := bySymbol[symbol]; ok {
+			since := since
+			sub.UnneededSince = &since
+		}
+	}
+
+	status := SubscriptionStatus{
+		Symbols:        make([]SymbolSubscription, 0, len(bySymbol)),
+		LastReconciled: r.lastReconciled,
+		LastError:      r.lastError,
+		GraceSeconds:   r.cfg.Grace.Seconds(),
+	}
+	for _, sub := range bySymbol {
+		status.Symbols = append(status.Symbols, *sub)
+	}
+	sort.Slice(status.Symbols, func(i, j int) bool { return status.Symbols[i].Symbol < status.Symbols[j].Symbol })
+	return status
+}
+
+// RequiredSymbols 所有已加载交易员需要实时行情的币种（币种 -> 交易员ID），
+// 使用币种池的交易员需要币种池中（按交易员的币种数量上限截取后）的全部币种，baseline 中的币种归属于 DefaultCoinsOwner
+func (tm *TraderManager) RequiredSymbols(baseline []string) map[string][]string {
+	tm.mu.RLock()
+	traders := make([]*trader.AutoTrader, 0, len(tm.traders))
+	for _, t := range tm.traders {
+		traders = append(traders, t)
+	}
+	tm.mu.RUnlock()
+
+	required := make(map[string][]string)
+	add := func(symbol, owner string) {
+		for _, existing := range required[symbol] {
+			if existing == owner {
+				return
+			}
+		}
+		required[symbol] = append(required[symbol], owner)
+	}
+	for _, symbol := range baseline {
+		add(market.Normalize(symbol), DefaultCoinsOwner)
+	}
+
+	var poolUsers []*trader.AutoTrader
+	for _, t := range traders {
+		symbols, usesPool := t.RequiredSymbols()
+		for _, symbol := range symbols {
+			add(symbol, t.GetID())
+		}
+		if usesPool {
+			poolUsers = append(poolUsers, t)
+		}
+	}
+	if len(poolUsers) > 0 {
+		mergedPool, err := getMergedCoinPool()
+		if err != nil {
+			log.Printf("⚠️  [订阅对账] 获取币种池失败: %v", err)
+		} else {
+			for _, t := range poolUsers {
+				for _, symbol := range t.CapCoinPool(mergedPool).AllSymbols {
+					add(market.Normalize(symbol), t.GetID())
+				}
+			}
+		}
+	}
+
+	for _, owners := range required {
+		sort.Strings(owners)
+	}
+	return required
+}
+
+// getMergedCoinPool 交易员无币种配置时使用的候选币种池（与交易员决策时一致：AI500前20 + OI Top）
+var getMergedCoinPool = func() (*pool.MergedCoinPool, error) {
+	return pool.GetMergedCoinPool(20)
+}
+
+// StartSubscriptionReconciler 按已加载交易员自动维护行情订阅：启动时立即对账，之后定期对账，
+// 交易员创建、更新、删除时触发对账
+func (tm *TraderManager) StartSubscriptionReconciler(monitor SubscriptionMonitor, database *config.Database) *SubscriptionReconciler {
+	cfg := loadSubscriptionConfig(database)
+	reconciler := NewSubscriptionReconciler(monitor, func() map[string][]string {
+		return tm.RequiredSymbols(loadDefaultCoins(database))
+	}, cfg)
+
+	tm.mu.Lock()
+	tm.subscriptions = reconciler
+	tm.mu.Unlock()
+
+	reconciler.Trigger()
+	go reconciler.Run(nil)
+	log.Printf("📡 行情订阅对账: 每 %v 对账一次，不再需要的币种保留 %v", cfg.Interval, cfg.Grace)
+	return reconciler
+}
+
+// SyncSubscriptions 交易员变更后触发行情订阅对账（对账器未启动时忽略）
+func (tm *TraderManager) SyncSubscriptions() {
+	tm.mu.RLock()
+	reconciler := tm.subscriptions
+	tm.mu.RUnlock()
+	if reconciler != nil {
+		reconciler.Trigger()
+	}
+}
+
+// SubscriptionStatus 行情订阅状态，对账器未启动时返回 false
+func (tm *TraderManager) SubscriptionStatus() (SubscriptionStatus, bool) {
+	tm.mu.RLock()
+	reconciler := tm.subscriptions
+	tm.mu.RUnlock()
+	if reconciler == nil {
+		return SubscriptionStatus{}, false
+	}
+	return reconciler.Status(), true
+}
+
+// loadDefaultCoins 从系统配置读取默认币种（没有交易员需要时也保持订阅）
+func loadDefaultCoins(database *config.Database) []string {
+	if database == nil {
+		return nil
+	}
+	var coins []string
+	if str, _ := database.GetSystemConfig("default_coins"); str != "" {
+		if err := json.Unmarshal([]byte(str), &coins); err != nil {
+			log.Printf("⚠️  解析default_coins配置失败: %v", err)
+		}
+	}
+	return coins
+}
+
+// loadSubscriptionConfig 从系统配置读取行情订阅对账配置（未配置时使用默认值）
+func loadSubscriptionConfig(database *config.Database) SubscriptionConfig {
+	cfg := DefaultSubscriptionConfig()
+	if database == nil {
+		return cfg
+	}
+	if str, _ := database.GetSystemConfig("subscription_reconcile_interval_seconds"); str != "" {
+		if val, err := strconv.ParseFloat(str, 64); err == nil && val > 0 {
+			cfg.Interval = time.Duration(val * float64(time.Second))
+		}
+	}
+	if str, _ := database.GetSystemConfig("subscription_grace_seconds"); str != "" {
+		if val, err := strconv.ParseFloat(str, 64); err == nil && val >= 0 {
+			cfg.Grace = time.Duration(val * float64(time.Second))
+		}
+	}
+	return cfg
+}
@@ -0,0 +1,227 @@
+package manager
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"aspen/metrics"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// fakeMonitor 模拟 WebSocket 监控器，failing 中的币种订阅失败
+type fakeMonitor struct {
+	mu           sync.Mutex
+	subscribed   map[string]bool
+	failing      map[string]bool
+	unsubscribes int
+}
+
+func newFakeMonitor(symbols ...string) *fakeMonitor {
+	m := &fakeMonitor{subscribed: make(map[string]bool), failing: make(map[string]bool)}
+	for _, s := range symbols {
+		m.subscribed[s] = true
+	}
+	return m
+}
+
+func (m *fakeMonitor) SubscribedSymbols() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var symbols []string
+	for s := range m.subscribed {
+		symbols = append(symbols, s)
+	}
+	sort.Strings(symbols)
+	return symbols
+}
+
+func (m *fakeMonitor) Subscribe(symbols []string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var err error
+	for _, s := range symbols {
+		if m.failing[s] {
+			err = errors.New("backfill failed: " + s)
+			continue
+		}
+		m.subscribed[s] = true
+	}
+	return err
+}
+
+func (m *fakeMonitor) Unsubscribe(symbols []string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, s := range symbols {
+		delete(m.subscribed, s)
+	}
+	m.unsubscribes++
+	return nil
+}
+
+// fakeTraders 模拟已加载交易员的币种需求（交易员ID -> 币种）
+type fakeTraders struct {
+	mu      sync.Mutex
+	symbols map[string][]string
+}
+
+func (f *fakeTraders) set(id string, symbols ...string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if symbols == nil {
+		delete(f.symbols, id)
+		return
+	}
+	f.symbols[id] = symbols
+}
+
+func (f *fakeTraders) required() map[string][]string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	required := make(map[string][]string)
+	for id, symbols := range f.symbols {
+		for _, s := range symbols {
+			required[s] = append(required[s], id)
+		}
+	}
+	for _, owners := range required {
+		sort.Strings(owners)
+	}
+	return required
+}
+
+// TestSubscriptionReconciler_ConvergesUnderTraderChurn 测试交易员创建、更新、删除后订阅收敛到需要的币种，
+// 不再需要的币种在宽限期后才取消订阅
+func TestSubscriptionReconciler_ConvergesUnderTraderChurn(t *testing.T) {
+	monitor := newFakeMonitor("BTCUSDT", "DOGEUSDT") // 启动时的旧订阅
+	traders := &fakeTraders{symbols: map[string][]string{"t1": {"BTCUSDT", "ETHUSDT"}}}
+	r := NewSubscriptionReconciler(monitor, traders.required, SubscriptionConfig{Interval: time.Minute, Grace: 5 * time.Minute})
+	subscribed := testutil.ToFloat64(metrics.SubscriptionReconcileTotal.WithLabelValues("subscribed"))
+	unsubscribed := testutil.ToFloat64(metrics.SubscriptionReconcileTotal.WithLabelValues("unsubscribed"))
+
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	added, removed := r.Reconcile(start)
+	if len(added) != 1 || added[0] != "ETHUSDT" || len(removed) != 0 {
+		t.Fatalf("首次对账 added=%v removed=%v, want [ETHUSDT] []", added, removed)
+	}
+
+	// 新建交易员需要 SOL；更新 t1 不再需要 ETH
+	traders.set("t2", "BTCUSDT", "SOLUSDT")
+	traders.set("t1", "BTCUSDT")
+	added, removed = r.Reconcile(start.Add(time.Minute))
+	if len(added) != 1 || added[0] != "SOLUSDT" || len(removed) != 0 {
+		t.Fatalf("交易员变更后 added=%v removed=%v, want [SOLUSDT] []（ETH 仍在宽限期内）", added, removed)
+	}
+
+	// 宽限期内 ETH 又被需要，重新计时
+	traders.set("t3", "ETHUSDT")
+	r.Reconcile(start.Add(3 * time.Minute))
+	traders.set("t3")
+
+	// 删除 t2：DOGE 超过宽限期取消订阅，ETH 和 SOL 刚开始计时
+	traders.set("t2")
+	_, removed = r.Reconcile(start.Add(6 * time.Minute))
+	if len(removed) != 1 || removed[0] != "DOGEUSDT" {
+		t.Fatalf("removed=%v, want [DOGEUSDT]", removed)
+	}
+	_, removed = r.Reconcile(start.Add(12 * time.Minute))
+	if len(removed) != 2 || removed[0] != "ETHUSDT" || removed[1] != "SOLUSDT" {
+		t.Fatalf("removed=%v, want [ETHUSDT SOLUSDT]", removed)
+	}
+
+	got := monitor.SubscribedSymbols()
+	if len(got) != 1 || got[0] != "BTCUSDT" {
+		t.Errorf("最终订阅 %v, want [BTCUSDT]", got)
+	}
+	if added, removed := r.Reconcile(start.Add(13 * time.Minute)); len(added)+len(removed) != 0 {
+		t.Errorf("收敛后再次对账不应有动作: added=%v removed=%v", added, removed)
+	}
+	if d := testutil.ToFloat64(metrics.SubscriptionReconcileTotal.WithLabelValues("subscribed")) - subscribed; d != 2 {
+		t.Errorf("subscribed 指标增加 %v, want 2", d)
+	}
+	if d := testutil.ToFloat64(metrics.SubscriptionReconcileTotal.WithLabelValues("unsubscribed")) - unsubscribed; d != 3 {
+		t.Errorf("unsubscribed 指标增加 %v, want 3", d)
+	}
+}
+
+// TestSubscriptionReconciler_RetriesFailedSubscribe 测试订阅失败的币种下次对账重试，并在状态中记录错误
+func TestSubscriptionReconciler_RetriesFailedSubscribe(t *testing.T) {
+	monitor := newFakeMonitor()
+	monitor.failing["AVAXUSDT"] = true
+	traders := &fakeTraders{symbols: map[string][]string{"t1": {"AVAXUSDT", "BTCUSDT"}}}
+	r := NewSubscriptionReconciler(monitor, traders.required, DefaultSubscriptionConfig())
+
+	now := time.Now()
+	added, _ := r.Reconcile(now)
+	if len(added) != 1 || added[0] != "BTCUSDT" {
+		t.Fatalf("added=%v, want [BTCUSDT]", added)
+	}
+	status := r.Status()
+	if status.LastError == "" {
+		t.Error("订阅失败应记录在状态中")
+	}
+	if len(status.Symbols) != 2 || status.Symbols[0].Symbol != "AVAXUSDT" || status.Symbols[0].Subscribed {
+		t.Fatalf("status=%+v, want AVAXUSDT 未订阅", status.Symbols)
+	}
+
+	monitor.mu.Lock()
+	delete(monitor.failing, "AVAXUSDT")
+	monitor.mu.Unlock()
+	added, _ = r.Reconcile(now.Add(time.Minute))
+	if len(added) != 1 || added[0] != "AVAXUSDT" {
+		t.Fatalf("重试 added=%v, want [AVAXUSDT]", added)
+	}
+	if status := r.Status(); status.LastError != "" {
+		t.Errorf("重试成功后 LastError=%q", status.LastError)
+	}
+}
+
+// TestSubscriptionReconciler_StatusListsRequiringTraders 测试订阅状态列出每个币种及需要它的交易员
+func TestSubscriptionReconciler_StatusListsRequiringTraders(t *testing.T) {
+	monitor := newFakeMonitor("XRPUSDT")
+	traders := &fakeTraders{symbols: map[string][]string{"t1": {"BTCUSDT"}, "t2": {"BTCUSDT", "ETHUSDT"}}}
+	r := NewSubscriptionReconciler(monitor, traders.required, DefaultSubscriptionConfig())
+	now := time.Now()
+	r.Reconcile(now)
+
+	status := r.Status()
+	want := map[string][]string{"BTCUSDT": {"t1", "t2"}, "ETHUSDT": {"t2"}, "XRPUSDT": {}}
+	if len(status.Symbols) != len(want) {
+		t.Fatalf("status=%+v", status.Symbols)
+	}
+	for _, sub := range status.Symbols {
+		if !sub.Subscribed {
+			t.Errorf("%s 应已订阅", sub.Symbol)
+		}
+		if len(sub.RequiredBy) != len(want[sub.Symbol]) {
+			t.Errorf("%s required_by=%v, want %v", sub.Symbol, sub.RequiredBy, want[sub.Symbol])
+		}
+		if (sub.Symbol == "XRPUSDT") != (sub.UnneededSince != nil) {
+			t.Errorf("%s unneeded_since=%v", sub.Symbol, sub.UnneededSince)
+		}
+	}
+}
+
+// TestSubscriptionReconciler_Trigger 测试交易员变更触发对账不等待定期对账
+func TestSubscriptionReconciler_Trigger(t *testing.T) {
+	monitor := newFakeMonitor()
+	traders := &fakeTraders{symbols: map[string][]string{}}
+	r := NewSubscriptionReconciler(monitor, traders.required, SubscriptionConfig{Interval: time.Hour, Grace: time.Hour})
+	stop := make(chan struct{})
+	defer close(stop)
+	go r.Run(stop)
+
+	traders.set("t1", "BTCUSDT")
+	r.Trigger()
+	deadline := time.Now().Add(2 * time.Second)
+	for len(monitor.SubscribedSymbols()) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("触发后未对账")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,209 @@
+package manager
+
+import (
+	"fmt"
+	"log"
+	"runtime/debug"
+	"strconv"
+	"sync"
+	"time"
+
+	"aspen/config"
+	"aspen/metrics"
+)
+
+// RestartPolicy 交易员异常退出（Run 返回错误或 panic）后的自动重启策略
+type RestartPolicy struct {
+	MaxRestarts    int           // 连续失败的最大重启次数，超过后放弃并标记为已停止（0 表示不自动重启）
+	InitialBackoff time.Duration // 首次重启前的等待时间，之后每次翻倍
+	MaxBackoff     time.Duration // 等待时间上限
+	StableAfter    time.Duration // 单次运行超过该时长视为已恢复，重置连续失败计数
+}
+
+// DefaultRestartPolicy 默认重启策略：最多连续重启5次，等待 10s 起翻倍、最长 5 分钟
+func DefaultRestartPolicy() RestartPolicy {
+	return RestartPolicy{
+		MaxRestarts:    5,
+		InitialBackoff: 10 * time.Second,
+		MaxBackoff:     5 * time.Minute,
+		StableAfter:    30 * time.Minute,
+	}
+}
+
+// backoff 第 attempt 次（从1开始）重启前的等待时间
+func (p RestartPolicy) backoff(attempt int) time.Duration {
+	d := p.InitialBackoff
+	for i := 1; i < attempt && d < p.MaxBackoff; i++ {
+		d *= 2
+	}
+	if p.MaxBackoff > 0 && d > p.MaxBackoff {
+		d = p.MaxBackoff
+	}
+	return d
+}
+
+// SupervisedTrader 受监督运行的交易员
+type SupervisedTrader interface {
+	Run() error
+	GetID() string
+	GetName() string
+	GetUserID() string
+}
+
+// Supervisor 监督交易员运行：异常退出后按指数退避自动重启，连续失败过多时放弃。
+// Run 正常返回（用户停止）不重启；重启等待期间可通过 Cancel 取消
+type Supervisor struct {
+	policy   RestartPolicy
+	onGiveUp func(t SupervisedTrader, err error) // 放弃重启时回调（如把交易员标记为已停止）
+
+	mu      sync.Mutex
+	cancels map[string]chan struct{} // key: trader ID
+}
+
+// NewSupervisor 创建交易员监督器
+func NewSupervisor(policy RestartPolicy, onGiveUp func(t SupervisedTrader, err error)) *Supervisor {
+	return &Supervisor{
+		policy:   policy,
+		onGiveUp: onGiveUp,
+		cancels:  make(map[string]chan struct{}),
+	}
+}
+
+// Run 运行交易员直到正常停止或放弃重启（阻塞），返回最后一次运行的错误；
+// 交易员已在监督中（运行或等待重启）时不重复运行，返回 ErrTraderAlreadyRunning
+func (s *Supervisor) Run(t SupervisedTrader) error {
+	cancel, ok := s.claim(t.GetID())
+	if !ok {
+		return ErrTraderAlreadyRunning
+	}
+	return s.supervise(t, cancel)
+}
+
+// Start 在后台监督运行交易员（不阻塞），onExit 在监督结束后回调（可为 nil）；
+// 交易员已在监督中时不重复启动，返回 false
+func (s *Supervisor) Start(t SupervisedTrader, onExit func(err error)) bool {
+	cancel, ok := s.claim(t.GetID())
+	if !ok {
+		return false
+	}
+	go func() {
+		err := s.supervise(t, cancel)
+		if onExit != nil {
+			onExit(err)
+		}
+	}()
+	return true
+}
+
+// Running 交易员是否处于监督中（运行或等待重启）
+func (s *Supervisor) Running(traderID string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.cancels[traderID]
+	return ok
+}
+
+// claim 登记交易员为监督中，已登记时返回 false（检查和登记在同一把锁内，并发启动只有一个成功）
+func (s *Supervisor) claim(traderID string) (chan struct{}, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.cancels[traderID]; ok {
+		return nil, false
+	}
+	cancel := make(chan struct{})
+	s.cancels[traderID] = cancel
+	return cancel, true
+}
+
+// supervise 运行交易员并在异常退出后按策略重启，结束时注销监督登记
+func (s *Supervisor) supervise(t SupervisedTrader, cancel chan struct{}) error {
+	defer func() {
+		s.mu.Lock()
+		if s.cancels[t.GetID()] == cancel {
+			delete(s.cancels, t.GetID())
+		}
+		s.mu.Unlock()
+	}()
+
+	failures := 0
+	for {
+		start := time.Now()
+		err := runRecovered(t)
+		if err == nil {
+			return nil
+		}
+		if s.policy.StableAfter > 0 && time.Since(start) >= s.policy.StableAfter {
+			failures = 0
+		}
+		failures++
+
+		if failures > s.policy.MaxRestarts {
+			log.Printf("❌ 交易员 %s 连续异常退出 %d 次，放弃自动重启: %v", t.GetName(), failures, err)
+			metrics.TraderRestartsTotal.WithLabelValues(t.GetID(), "gave_up").Inc()
+			if s.onGiveUp != nil {
+				s.onGiveUp(t, err)
+			}
+			return err
+		}
+
+		wait := s.policy.backoff(failures)
+		log.Printf("⚠️  交易员 %s 异常退出: %v，%v 后第 %d/%d 次自动重启", t.GetName(), err, wait, failures, s.policy.MaxRestarts)
+		select {
+		case <-time.After(wait):
+		case <-cancel:
+			log.Printf("⏹  交易员 %s 已停止，取消自动重启", t.GetName())
+			return err
+		}
+		metrics.TraderRestartsTotal.WithLabelValues(t.GetID(), "restarted").Inc()
+		log.Printf("🔄 自动重启交易员 %s", t.GetName())
+	}
+}
+
+// Cancel 取消交易员后续的自动重启（用户停止或删除交易员时调用），返回该交易员是否处于监督中（运行或等待重启）
+func (s *Supervisor) Cancel(traderID string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cancel, ok := s.cancels[traderID]
+	if ok {
+		close(cancel)
+		delete(s.cancels, traderID)
+	}
+	return ok
+}
+
+// runRecovered 运行交易员，panic 转换为错误
+func runRecovered(t SupervisedTrader) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v\n%s", r, debug.Stack())
+		}
+	}()
+	return t.Run()
+}
+
+// loadRestartPolicy 从系统配置读取交易员自动重启策略（未配置时使用默认值）
+func loadRestartPolicy(database *config.Database) RestartPolicy {
+	policy := DefaultRestartPolicy()
+	if database == nil {
+		return policy
+	}
+	if str, _ := database.GetSystemConfig("trader_restart_max_attempts"); str != "" {
+		if val, err := strconv.Atoi(str); err == nil && val >= 0 {
+			policy.MaxRestarts = val
+		}
+	}
+	if str, _ := database.GetSystemConfig("trader_restart_backoff_seconds"); str != "" {
+		if val, err := strconv.ParseFloat(str, 64); err == nil && val > 0 {
+			policy.InitialBackoff = time.Duration(val * float64(time.Second))
+		}
+	}
+	if str, _ := database.GetSystemConfig("trader_restart_max_backoff_seconds"); str != "" {
+		if val, err := strconv.ParseFloat(str, 64); err == nil && val > 0 {
+			policy.MaxBackoff = time.Duration(val * float64(time.Second))
+		}
+	}
+	if policy.MaxBackoff < policy.InitialBackoff {
+		policy.MaxBackoff = policy.InitialBackoff
+	}
+	return policy
+}
@@ -0,0 +1,189 @@
+package manager
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"aspen/metrics"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// flakyTrader 前 failures 次运行返回错误（panicAt 次运行时 panic），之后正常退出
+type flakyTrader struct {
+	id       string
+	failures int
+	panicAt  int
+
+	mu   sync.Mutex
+	runs []time.Time
+}
+
+func (f *flakyTrader) Run() error {
+	f.mu.Lock()
+	f.runs = append(f.runs, time.Now())
+	n := len(f.runs)
+	f.mu.Unlock()
+	if n == f.panicAt {
+		panic("boom")
+	}
+	if n <= f.failures {
+		return errors.New("exchange unavailable")
+	}
+	return nil
+}
+
+func (f *flakyTrader) GetID() string     { return f.id }
+func (f *flakyTrader) GetName() string   { return f.id }
+func (f *flakyTrader) GetUserID() string { return "user-1" }
+
+func (f *flakyTrader) runCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.runs)
+}
+
+func testPolicy(maxRestarts int) RestartPolicy {
+	return RestartPolicy{MaxRestarts: maxRestarts, InitialBackoff: 10 * time.Millisecond, MaxBackoff: 25 * time.Millisecond}
+}
+
+// TestSupervisor_RestartsWithBackoff 测试异常退出后按指数退避（有上限）重启，恢复后正常退出不再重启
+func TestSupervisor_RestartsWithBackoff(t *testing.T) {
+	ft := &flakyTrader{id: "sup-backoff", failures: 3, panicAt: 2}
+	var gaveUp bool
+	s := NewSupervisor(testPolicy(5), func(SupervisedTrader, error) { gaveUp = true })
+
+	if err := s.Run(ft); err != nil {
+		t.Fatalf("Run = %v, want nil", err)
+	}
+	if ft.runCount() != 4 || gaveUp {
+		t.Fatalf("runs = %d, gaveUp = %v, want 4 runs without giving up", ft.runCount(), gaveUp)
+	}
+
+	// 等待时间：10ms、20ms、25ms（上限）
+	want := []time.Duration{10 * time.Millisecond, 20 * time.Millisecond, 25 * time.Millisecond}
+	for i, wantGap := range want {
+		if gap := ft.runs[i+1].Sub(ft.runs[i]); gap < wantGap {
+			t.Errorf("第 %d 次重启等待 %v, want >= %v", i+1, gap, wantGap)
+		}
+	}
+	if got := testutil.ToFloat64(metrics.TraderRestartsTotal.WithLabelValues("sup-backoff", "restarted")); got != 3 {
+		t.Errorf("restarted metric = %v, want 3", got)
+	}
+}
+
+// TestSupervisor_GivesUpAfterMaxRestarts 测试连续失败超过上限后放弃重启并回调（标记为已停止）
+func TestSupervisor_GivesUpAfterMaxRestarts(t *testing.T) {
+	ft := &flakyTrader{id: "sup-giveup", failures: 100}
+	var gaveUp SupervisedTrader
+	s := NewSupervisor(testPolicy(2), func(t SupervisedTrader, err error) { gaveUp = t })
+
+	if err := s.Run(ft); err == nil {
+		t.Fatal("放弃重启时应返回最后一次错误")
+	}
+	if ft.runCount() != 3 {
+		t.Errorf("runs = %d, want 3 (首次 + 2 次重启)", ft.runCount())
+	}
+	if gaveUp == nil || gaveUp.GetID() != "sup-giveup" {
+		t.Errorf("应回调放弃重启: %v", gaveUp)
+	}
+	if got := testutil.ToFloat64(metrics.TraderRestartsTotal.WithLabelValues("sup-giveup", "gave_up")); got != 1 {
+		t.Errorf("gave_up metric = %v, want 1", got)
+	}
+}
+
+// TestSupervisor_CancelDuringBackoff 测试等待重启期间停止交易员会取消重启
+func TestSupervisor_CancelDuringBackoff(t *testing.T) {
+	ft := &flakyTrader{id: "sup-cancel", failures: 1}
+	s := NewSupervisor(RestartPolicy{MaxRestarts: 5, InitialBackoff: time.Hour, MaxBackoff: time.Hour}, nil)
+
+	done := make(chan error, 1)
+	go func() { done <- s.Run(ft) }()
+
+	deadline := time.Now().Add(time.Second)
+	for ft.runCount() == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(5 * time.Millisecond)
+	if !s.Cancel("sup-cancel") {
+		t.Fatal("等待重启的交易员应处于监督中")
+	}
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("取消后应立即返回")
+	}
+	if ft.runCount() != 1 {
+		t.Errorf("runs = %d, want 1", ft.runCount())
+	}
+	if s.Cancel("sup-cancel") {
+		t.Error("已结束的交易员不应处于监督中")
+	}
+}
+
+// TestRestartPolicy_Backoff 测试退避时间翻倍且不超过上限
+func TestRestartPolicy_Backoff(t *testing.T) {
+	p := RestartPolicy{InitialBackoff: 10 * time.Second, MaxBackoff: time.Minute}
+	for attempt, want := range map[int]time.Duration{1: 10 * time.Second, 2: 20 * time.Second, 3: 40 * time.Second, 4: time.Minute, 10: time.Minute} {
+		if got := p.backoff(attempt); got != want {
+			t.Errorf("backoff(%d) = %v, want %v", attempt, got, want)
+		}
+	}
+}
+
+// blockingTrader 运行到 release 关闭后正常退出
+type blockingTrader struct {
+	id      string
+	release chan struct{}
+	runs    int32
+}
+
+func (b *blockingTrader) Run() error {
+	atomic.AddInt32(&b.runs, 1)
+	<-b.release
+	return nil
+}
+
+func (b *blockingTrader) GetID() string     { return b.id }
+func (b *blockingTrader) GetName() string   { return b.id }
+func (b *blockingTrader) GetUserID() string { return "user-1" }
+
+// TestSupervisor_StartIsIdempotent 测试已在监督中的交易员不会被重复启动（不会运行第二个主循环）
+func TestSupervisor_StartIsIdempotent(t *testing.T) {
+	bt := &blockingTrader{id: "sup-idempotent", release: make(chan struct{})}
+	s := NewSupervisor(testPolicy(1), nil)
+
+	exited := make(chan error, 1)
+	if !s.Start(bt, func(err error) { exited <- err }) {
+		t.Fatal("首次启动应成功")
+	}
+	if s.Start(bt, nil) {
+		t.Error("运行中的交易员不应重复启动")
+	}
+	if err := s.Run(bt); !errors.Is(err, ErrTraderAlreadyRunning) {
+		t.Errorf("Run = %v, want ErrTraderAlreadyRunning", err)
+	}
+	if !s.Running("sup-idempotent") {
+		t.Error("交易员应处于监督中")
+	}
+
+	close(bt.release)
+	select {
+	case err := <-exited:
+		if err != nil {
+			t.Errorf("onExit err = %v, want nil", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("交易员正常退出后应回调 onExit")
+	}
+	if got := atomic.LoadInt32(&bt.runs); got != 1 {
+		t.Errorf("runs = %d, want 1", got)
+	}
+	if s.Running("sup-idempotent") {
+		t.Error("已退出的交易员不应处于监督中")
+	}
+}
@@ -0,0 +1,157 @@
+package manager
+
+import (
+	"errors"
+	"fmt"
+	"log"
+
+	"aspen/config"
+	"aspen/trader"
+)
+
+// ErrTraderNotRunning 交易员本就未运行（也不在等待自动重启）
+var ErrTraderNotRunning = errors.New("交易员已停止")
+
+// ErrTraderAlreadyRunning 交易员已在运行中（或等待自动重启），不重复启动
+var ErrTraderAlreadyRunning = errors.New("交易员已在运行中")
+
+// ErrTraderDisabled 交易员已因硬回撤停用，需手动重置后才能启动
+var ErrTraderDisabled = errors.New("交易员已停用")
+
+// KillSwitchConfigKey 全局交易开关（"true" 表示已开启：停止所有交易员并禁止启动）
+const KillSwitchConfigKey = "trading_kill_switch"
+
+// IsTraderRunning 交易员是否在运行中（处于监督中或主循环正在运行）
+func (tm *TraderManager) IsTraderRunning(at *trader.AutoTrader) bool {
+	tm.mu.RLock()
+	supervisor := tm.supervisor
+	tm.mu.RUnlock()
+	if supervisor.Running(at.GetID()) {
+		return true
+	}
+	isRunning, _ := at.GetStatus()["is_running"].(bool)
+	return isRunning
+}
+
+// StartTrader 在监督下后台启动交易员，并在数据库中标记为运行中（进程重启后自动启动）。
+// 交易员已在运行时不重复启动，返回 ErrTraderAlreadyRunning；已停用时返回 ErrTraderDisabled
+func (tm *TraderManager) StartTrader(database *config.Database, userID string, at *trader.AutoTrader) error {
+	tm.mu.RLock()
+	supervisor := tm.supervisor
+	tm.mu.RUnlock()
+
+	if isRunning, _ := at.GetStatus()["is_running"].(bool); isRunning {
+		return ErrTraderAlreadyRunning
+	}
+	if reason := at.DisabledReason(); reason != "" {
+		return fmt.Errorf("%w: %s", ErrTraderDisabled, reason)
+	}
+	started := supervisor.Start(at, func(err error) {
+		if err != nil {
+			log.Printf("❌ 交易员 %s 运行错误: %v", at.GetName(), err)
+		}
+	})
+	if !started {
+		return ErrTraderAlreadyRunning
+	}
+	log.Printf("▶️  启动交易员 %s (%s)", at.GetID(), at.GetName())
+
+	if err := database.UpdateTraderStatus(userID, at.GetID(), true); err != nil {
+		log.Printf("⚠️  更新交易员状态失败: %v", err)
+	}
+	return nil
+}
+
+// StopTrader 停止交易员：取消后续自动重启、停止运行，并在数据库中标记为已停止（进程重启后不再自动启动）。
+// 交易员未加载到内存时（如进程未运行时的离线管理）按数据库状态判断并只更新数据库
+func (tm *TraderManager) StopTrader(database *config.Database, userID, traderID string) error {
+	supervised := tm.CancelRestart(traderID)
+
+	running := supervised
+	at, err := tm.GetTrader(traderID)
+	if err == nil {
+		if isRunning, ok := at.GetStatus()["is_running"].(bool); !ok || isRunning {
+			running = true
+		}
+	} else {
+		traders, err := database.GetTraders(userID)
+		if err != nil {
+			return fmt.Errorf("获取交易员列表失败: %w", err)
+		}
+		found := false
+		for _, record := range traders {
+			if record.ID == traderID {
+				found, running = true, running || record.IsRunning
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("交易员 %s 不存在", traderID)
+		}
+	}
+	if !running {
+		return ErrTraderNotRunning
+	}
+
+	if at != nil {
+		at.Stop()
+	}
+	if err := database.UpdateTraderStatus(userID, traderID, false); err != nil {
+		log.Printf("⚠️  更新交易员状态失败: %v", err)
+	}
+	return nil
+}
+
+// ResetTraderDisabled 手动重置交易员的停用状态（数据库和已加载的交易员），之后可以再次启动
+func (tm *TraderManager) ResetTraderDisabled(database *config.Database, userID, traderID string) error {
+	if err := database.ResetTraderDisabled(userID, traderID); err != nil {
+		return err
+	}
+	if at, err := tm.GetTrader(traderID); err == nil {
+		at.ResetHardDrawdown()
+	}
+	log.Printf("🔓 已重置交易员 %s 的停用状态", traderID)
+	return nil
+}
+
+// KillSwitchEnabled 全局交易开关是否已开启
+func KillSwitchEnabled(database *config.Database) bool {
+	value, err := database.GetSystemConfig(KillSwitchConfigKey)
+	return err == nil && value == "true"
+}
+
+// SetKillSwitch 开启或关闭全局交易开关。开启时停止所有运行中的交易员（返回被停止的交易员ID）；
+// 关闭后交易员不会自动恢复，需要逐个手动启动
+func (tm *TraderManager) SetKillSwitch(database *config.Database, enabled bool) ([]string, error) {
+	if err := database.SetSystemConfig(KillSwitchConfigKey, fmt.Sprintf("%t", enabled)); err != nil {
+		return nil, fmt.Errorf("保存全局交易开关失败: %w", err)
+	}
+	if !enabled {
+		log.Printf("🟢 全局交易开关已关闭，允许启动交易员")
+		return nil, nil
+	}
+
+	userIDs, err := database.GetAllUsers()
+	if err != nil {
+		return nil, fmt.Errorf("获取用户列表失败: %w", err)
+	}
+	var stopped []string
+	for _, userID := range userIDs {
+		traders, err := database.GetTraders(userID)
+		if err != nil {
+			return stopped, fmt.Errorf("获取用户 %s 的交易员失败: %w", userID, err)
+		}
+		for _, record := range traders {
+			err := tm.StopTrader(database, userID, record.ID)
+			if errors.Is(err, ErrTraderNotRunning) {
+				continue
+			}
+			if err != nil {
+				return stopped, err
+			}
+			stopped = append(stopped, record.ID)
+		}
+	}
+	log.Printf("🛑 全局交易开关已开启，停止了 %d 个交易员", len(stopped))
+	return stopped, nil
+}
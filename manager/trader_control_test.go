@@ -0,0 +1,39 @@
+package manager
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestStartTrader_AlreadyRunning 测试启动运行中的交易员返回 ErrTraderAlreadyRunning，不会启动第二个主循环
+func TestStartTrader_AlreadyRunning(t *testing.T) {
+	tm, db := newPositionLimitTest(t)
+	at, err := tm.GetTrader("t-a")
+	require.NoError(t, err)
+
+	// 交易员已在监督下运行
+	running := &blockingTrader{id: "t-a", release: make(chan struct{})}
+	require.True(t, tm.supervisor.Start(running, nil))
+	defer close(running.release)
+	require.Eventually(t, func() bool { return atomic.LoadInt32(&running.runs) == 1 }, time.Second, time.Millisecond)
+
+	assert.True(t, tm.IsTraderRunning(at))
+	for i := 0; i < 3; i++ {
+		assert.ErrorIs(t, tm.StartTrader(db, "default", at), ErrTraderAlreadyRunning)
+	}
+	assert.Equal(t, int32(1), atomic.LoadInt32(&running.runs))
+	assert.False(t, at.GetStatus()["is_running"].(bool), "不应运行第二个主循环")
+
+	record, _, _, err := db.GetTraderConfig("default", "t-a")
+	require.NoError(t, err)
+	assert.False(t, record.IsRunning, "重复启动不修改数据库状态")
+
+	// 停止后再次停止返回 ErrTraderNotRunning（由接口按幂等处理）
+	require.NoError(t, tm.StopTrader(db, "default", "t-a"))
+	assert.ErrorIs(t, tm.StopTrader(db, "default", "t-a"), ErrTraderNotRunning)
+	assert.False(t, tm.IsTraderRunning(at))
+}
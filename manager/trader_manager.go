@@ -6,6 +6,8 @@ import (
 	"fmt"
 	"log"
 	"aspen/config"
+	"aspen/decision"
+	"aspen/market"
 	"aspen/trader"
 	"sort"
 	"strconv"
@@ -26,22 +28,70 @@ type TraderManager struct {
 	traders          map[string]*trader.AutoTrader // key: trader ID
 	competitionCache *CompetitionCache
 	communityCache   *CompetitionCache
+	loadErrors       map[string]*TraderLoadError // key: trader ID，加载失败的交易员及原因
+	loadedAt         time.Time                   // 最近一次全量加载时间
+	supervisor       *Supervisor                 // 异常退出后自动重启交易员
+	subscriptions    *SubscriptionReconciler     // 按已加载交易员维护行情订阅（未启动时为 nil）
+	openLocks        userOpenLocks               // 用户级最大同时持仓数检查的开仓锁
 	mu               sync.RWMutex
 }
 
 // NewTraderManager 创建trader管理器
 func NewTraderManager() *TraderManager {
 	return &TraderManager{
-		traders: make(map[string]*trader.AutoTrader),
+		traders:    make(map[string]*trader.AutoTrader),
+		loadErrors: make(map[string]*TraderLoadError),
 		competitionCache: &CompetitionCache{
 			data: make(map[string]interface{}),
 		},
 		communityCache: &CompetitionCache{
 			data: make(map[string]interface{}),
 		},
+		supervisor: NewSupervisor(DefaultRestartPolicy(), nil),
 	}
 }
 
+// EnableAutoRestart 按系统配置启用交易员自动重启，放弃重启时在数据库中把交易员标记为已停止
+func (tm *TraderManager) EnableAutoRestart(database *config.Database) {
+	policy := loadRestartPolicy(database)
+	supervisor := NewSupervisor(policy, func(t SupervisedTrader, err error) {
+		if database == nil {
+			return
+		}
+		if dbErr := database.UpdateTraderStatus(t.GetUserID(), t.GetID(), false); dbErr != nil {
+			log.Printf("⚠️  标记交易员 %s 为已停止失败: %v", t.GetName(), dbErr)
+		}
+	})
+
+	tm.mu.Lock()
+	tm.supervisor = supervisor
+	tm.mu.Unlock()
+	log.Printf("🔄 交易员自动重启: 最多连续 %d 次，等待 %v 起翻倍、最长 %v", policy.MaxRestarts, policy.InitialBackoff, policy.MaxBackoff)
+}
+
+// RunTrader 在监督下运行交易员（阻塞）：异常退出后按重启策略自动重启
+func (tm *TraderManager) RunTrader(at *trader.AutoTrader) error {
+	tm.mu.RLock()
+	supervisor := tm.supervisor
+	tm.mu.RUnlock()
+	return supervisor.Run(at)
+}
+
+// CancelRestart 取消交易员后续的自动重启（停止或删除交易员时调用），返回该交易员是否处于监督中（运行或等待重启）
+func (tm *TraderManager) CancelRestart(traderID string) bool {
+	tm.mu.RLock()
+	supervisor := tm.supervisor
+	tm.mu.RUnlock()
+	return supervisor.Cancel(traderID)
+}
+
+// RemoveTrader 从内存中移除交易员（删除交易员时调用，调用方负责先停止交易员）
+func (tm *TraderManager) RemoveTrader(traderID string) {
+	tm.mu.Lock()
+	delete(tm.traders, traderID)
+	tm.mu.Unlock()
+}
+
 // LoadTradersFromDatabase 从数据库加载所有交易员到内存
 func (tm *TraderManager) LoadTradersFromDatabase(database *config.Database) error {
 	tm.mu.Lock()
@@ -105,7 +155,7 @@ func (tm *TraderManager) LoadTradersFromDatabase(database *config.Database) erro
 		// 获取AI模型配置（使用交易员所属的用户ID）
 		aiModels, err := database.GetAIModels(traderCfg.UserID)
 		if err != nil {
-			log.Printf("⚠️  获取AI模型配置失败: %v", err)
+			tm.recordLoadError(traderCfg, fmt.Sprintf("获取AI模型配置失败: %v", err))
 			continue
 		}
 
@@ -129,19 +179,19 @@ func (tm *TraderManager) LoadTradersFromDatabase(database *config.Database) erro
 		}
 
 		if aiModelCfg == nil {
-			log.Printf("⚠️  交易员 %s 的AI模型 %s 不存在，跳过", traderCfg.Name, traderCfg.AIModelID)
+			tm.recordLoadError(traderCfg, fmt.Sprintf("AI模型 %s 不存在", traderCfg.AIModelID))
 			continue
 		}
 
 		if !aiModelCfg.Enabled {
-			log.Printf("⚠️  交易员 %s 的AI模型 %s 未启用，跳过", traderCfg.Name, traderCfg.AIModelID)
+			tm.recordLoadError(traderCfg, fmt.Sprintf("AI模型 %s 未启用", traderCfg.AIModelID))
 			continue
 		}
 
 		// 获取交易所配置（使用交易员所属的用户ID）
 		exchanges, err := database.GetExchanges(traderCfg.UserID)
 		if err != nil {
-			log.Printf("⚠️  获取交易所配置失败: %v", err)
+			tm.recordLoadError(traderCfg, fmt.Sprintf("获取交易所配置失败: %v", err))
 			continue
 		}
 
@@ -154,12 +204,12 @@ func (tm *TraderManager) LoadTradersFromDatabase(database *config.Database) erro
 		}
 
 		if exchangeCfg == nil {
-			log.Printf("⚠️  交易员 %s 的交易所 %s 不存在，跳过", traderCfg.Name, traderCfg.ExchangeID)
+			tm.recordLoadError(traderCfg, fmt.Sprintf("交易所 %s 不存在", traderCfg.ExchangeID))
 			continue
 		}
 
 		if !exchangeCfg.Enabled {
-			log.Printf("⚠️  交易员 %s 的交易所 %s 未启用，跳过", traderCfg.Name, traderCfg.ExchangeID)
+			tm.recordLoadError(traderCfg, fmt.Sprintf("交易所 %s 未启用", traderCfg.ExchangeID))
 			continue
 		}
 
@@ -176,12 +226,19 @@ func (tm *TraderManager) LoadTradersFromDatabase(database *config.Database) erro
 		// 添加到TraderManager
 		err = tm.addTraderFromDB(traderCfg, aiModelCfg, exchangeCfg, coinPoolURL, oiTopURL, maxDailyLoss, maxDrawdown, stopTradingMinutes, defaultCoins, database, traderCfg.UserID)
 		if err != nil {
-			log.Printf("❌ 添加交易员 %s 失败: %v", traderCfg.Name, err)
+			tm.recordLoadError(traderCfg, err.Error())
 			continue
 		}
 	}
 
-	log.Printf("✓ 成功加载 %d 个交易员到内存", len(tm.traders))
+	// 一致性校验：数据库中的每个交易员都应在内存中有对应实例
+	report := tm.verifyLoadedTraders(allTraders)
+	tm.loadedAt = report.GeneratedAt
+	if len(report.Failed) > 0 {
+		log.Printf("⚠️ %s", report.Summary())
+	} else {
+		log.Printf("✓ %s", report.Summary())
+	}
 	return nil
 }
 
@@ -192,17 +249,8 @@ func (tm *TraderManager) addTraderFromDB(traderCfg *config.TraderRecord, aiModel
 	}
 
 	// 处理交易币种列表
-	var tradingCoins []string
-	if traderCfg.TradingSymbols != "" {
-		// 解析逗号分隔的交易币种列表
-		symbols := strings.Split(traderCfg.TradingSymbols, ",")
-		for _, symbol := range symbols {
-			symbol = strings.TrimSpace(symbol)
-			if symbol != "" {
-				tradingCoins = append(tradingCoins, symbol)
-			}
-		}
-	}
+	// 兼容逗号分隔和JSON数组两种存储格式
+	tradingCoins := market.ParseSymbolList(traderCfg.TradingSymbols)
 
 	// 如果没有指定交易币种，使用默认币种
 	if len(tradingCoins) == 0 {
@@ -216,6 +264,8 @@ func (tm *TraderManager) addTraderFromDB(traderCfg *config.TraderRecord, aiModel
 		log.Printf("✓ 交易员 %s 启用 COIN POOL 信号源: %s", traderCfg.Name, coinPoolURL)
 	}
 
+	paperRealism := loadPaperRealism(database, traderCfg)
+
 	// 构建AutoTraderConfig
 	traderConfig := trader.AutoTraderConfig{
 		ID:                    traderCfg.ID,
@@ -227,18 +277,63 @@ func (tm *TraderManager) addTraderFromDB(traderCfg *config.TraderRecord, aiModel
 		HyperliquidPrivateKey: "",
 		HyperliquidTestnet:    exchangeCfg.Testnet,
 		CoinPoolAPIURL:        effectiveCoinPoolURL,
+		CoinPoolMaxSymbols:    traderCfg.CoinPoolMaxSymbols,
+		CoinPoolRankBy:        traderCfg.CoinPoolRankBy,
 		UseQwen:               aiModelCfg.Provider == "qwen",
 		DeepSeekKey:           "",
 		QwenKey:               "",
 		CustomAPIURL:          aiModelCfg.CustomAPIURL,    // 自定义API URL
 		CustomModelName:       aiModelCfg.CustomModelName, // 自定义模型名称
 		ScanInterval:          time.Duration(traderCfg.ScanIntervalMinutes) * time.Minute,
+		AICallBudget:          loadAICallBudget(database),
 		InitialBalance:        traderCfg.InitialBalance,
 		BTCETHLeverage:        traderCfg.BTCETHLeverage,
 		AltcoinLeverage:       traderCfg.AltcoinLeverage,
 		MaxDailyLoss:          maxDailyLoss,
 		MaxDrawdown:           maxDrawdown,
 		StopTradingTime:       time.Duration(stopTradingMinutes) * time.Minute,
+		RiskScaling:           loadRiskScalingConfig(database, traderCfg, maxDailyLoss, maxDrawdown),
+		AllocationBudget:      loadAllocationBudget(database, traderCfg),
+		PositionSizing:        loadPositionSizing(traderCfg),
+		DataSource:            loadDataSource(traderCfg),
+		Indicators:            loadIndicatorParams(traderCfg),
+		HedgePolicy:           loadHedgePolicy(traderCfg),
+		ManageOnly:            traderCfg.ManageOnly,
+		HardDrawdownPct:       traderCfg.HardDrawdownPct,
+		DisabledReason:        traderCfg.DisabledReason,
+		HardDrawdownPeak:      traderCfg.HardDrawdownPeak,
+		HardDrawdownRebase:    traderCfg.HardDrawdownRebase,
+		RiskPeakEquity:        traderCfg.RiskPeakEquity,
+		DayStartEquity:        traderCfg.DayStartEquity,
+		DayStartDate:          traderCfg.DayStartDate,
+		PaperPriceImpact:      paperRealism.PriceImpact,
+		PaperFill:             paperRealism.Fill,
+		PaperCosts:            paperRealism.Costs,
+		PaperPositionMode:     loadPaperPositionMode(database),
+		StablecoinPeg:         loadStablecoinPegConfig(database),
+		DataOutage:            loadDataOutageConfig(database),
+		MarginAsset:           traderCfg.MarginAsset,
+		MaxSpreadBps:          loadMaxSpreadBps(database),
+		Webhook:               loadWebhookConfig(database, userID),
+		ChurnGuard:            loadChurnGuardConfig(database),
+		CandleAlign:           loadCandleAlignConfig(database),
+		EntryConfirmation:     loadEntryConfirmationConfig(database),
+		MinBarsBetweenTrades:  loadMinBarsBetweenTrades(database),
+		QuoteQuantityOrders:   loadQuoteQuantityOrders(database),
+		LossCooldown:          loadLossCooldownConfig(database),
+		ConfidenceSizing:      loadConfidenceSizingConfig(database),
+		PromptCache:           loadPromptCacheConfig(database),
+		IntentDedupWindow:     loadIntentDedupWindow(database),
+		HaltedZeroVolumeCandles: loadHaltedZeroVolumeCandles(database),
+		ReconcilePositionsOnStart: loadReconcilePositionsOnStart(database),
+		Blackout:              loadBlackoutConfig(database),
+		DustThresholdUSD:      loadDustThresholdUSD(database),
+		StaleOrderMaxAge:      loadStaleOrderMaxAge(database),
+		Anomaly:               loadAnomalyConfig(database),
+		SlippageWarningBps:    loadSlippageWarningBps(database),
+		Review:                loadReviewConfig(database, traderCfg),
+		PromptTokenBudget:     loadPromptTokenBudget(database),
+		OpenGate:              tm.positionGate(database),
 		IsCrossMargin:         traderCfg.IsCrossMargin,
 		DefaultCoins:          defaultCoins,
 		TradingCoins:          tradingCoins,
@@ -311,6 +406,9 @@ func (tm *TraderManager) addTraderFromDB(traderCfg *config.TraderRecord, aiModel
 		}
 	}
 
+	// 对账上次进程在周期执行中重启时未结束的周期
+	at.RecoverInterruptedCycles()
+
 	tm.traders[traderCfg.ID] = at
 	log.Printf("✓ Trader '%s' (%s + %s) 已加载到内存", traderCfg.Name, aiModelCfg.Provider, exchangeCfg.ID)
 	return nil
@@ -328,17 +426,8 @@ func (tm *TraderManager) AddTraderFromDB(traderCfg *config.TraderRecord, aiModel
 	}
 
 	// 处理交易币种列表
-	var tradingCoins []string
-	if traderCfg.TradingSymbols != "" {
-		// 解析逗号分隔的交易币种列表
-		symbols := strings.Split(traderCfg.TradingSymbols, ",")
-		for _, symbol := range symbols {
-			symbol = strings.TrimSpace(symbol)
-			if symbol != "" {
-				tradingCoins = append(tradingCoins, symbol)
-			}
-		}
-	}
+	// 兼容逗号分隔和JSON数组两种存储格式
+	tradingCoins := market.ParseSymbolList(traderCfg.TradingSymbols)
 
 	// 如果没有指定交易币种，使用默认币种
 	if len(tradingCoins) == 0 {
@@ -352,6 +441,8 @@ func (tm *TraderManager) AddTraderFromDB(traderCfg *config.TraderRecord, aiModel
 		log.Printf("✓ 交易员 %s 启用 COIN POOL 信号源: %s", traderCfg.Name, coinPoolURL)
 	}
 
+	paperRealism := loadPaperRealism(database, traderCfg)
+
 	// 构建AutoTraderConfig
 	traderConfig := trader.AutoTraderConfig{
 		ID:                    traderCfg.ID,
@@ -363,18 +454,63 @@ func (tm *TraderManager) AddTraderFromDB(traderCfg *config.TraderRecord, aiModel
 		HyperliquidPrivateKey: "",
 		HyperliquidTestnet:    exchangeCfg.Testnet,
 		CoinPoolAPIURL:        effectiveCoinPoolURL,
+		CoinPoolMaxSymbols:    traderCfg.CoinPoolMaxSymbols,
+		CoinPoolRankBy:        traderCfg.CoinPoolRankBy,
 		UseQwen:               aiModelCfg.Provider == "qwen",
 		DeepSeekKey:           "",
 		QwenKey:               "",
 		CustomAPIURL:          aiModelCfg.CustomAPIURL,    // 自定义API URL
 		CustomModelName:       aiModelCfg.CustomModelName, // 自定义模型名称
 		ScanInterval:          time.Duration(traderCfg.ScanIntervalMinutes) * time.Minute,
+		AICallBudget:          loadAICallBudget(database),
 		InitialBalance:        traderCfg.InitialBalance,
 		BTCETHLeverage:        traderCfg.BTCETHLeverage,
 		AltcoinLeverage:       traderCfg.AltcoinLeverage,
 		MaxDailyLoss:          maxDailyLoss,
 		MaxDrawdown:           maxDrawdown,
 		StopTradingTime:       time.Duration(stopTradingMinutes) * time.Minute,
+		RiskScaling:           loadRiskScalingConfig(database, traderCfg, maxDailyLoss, maxDrawdown),
+		AllocationBudget:      loadAllocationBudget(database, traderCfg),
+		PositionSizing:        loadPositionSizing(traderCfg),
+		DataSource:            loadDataSource(traderCfg),
+		Indicators:            loadIndicatorParams(traderCfg),
+		HedgePolicy:           loadHedgePolicy(traderCfg),
+		ManageOnly:            traderCfg.ManageOnly,
+		HardDrawdownPct:       traderCfg.HardDrawdownPct,
+		DisabledReason:        traderCfg.DisabledReason,
+		HardDrawdownPeak:      traderCfg.HardDrawdownPeak,
+		HardDrawdownRebase:    traderCfg.HardDrawdownRebase,
+		RiskPeakEquity:        traderCfg.RiskPeakEquity,
+		DayStartEquity:        traderCfg.DayStartEquity,
+		DayStartDate:          traderCfg.DayStartDate,
+		PaperPriceImpact:      paperRealism.PriceImpact,
+		PaperFill:             paperRealism.Fill,
+		PaperCosts:            paperRealism.Costs,
+		PaperPositionMode:     loadPaperPositionMode(database),
+		StablecoinPeg:         loadStablecoinPegConfig(database),
+		DataOutage:            loadDataOutageConfig(database),
+		MarginAsset:           traderCfg.MarginAsset,
+		MaxSpreadBps:          loadMaxSpreadBps(database),
+		Webhook:               loadWebhookConfig(database, userID),
+		ChurnGuard:            loadChurnGuardConfig(database),
+		CandleAlign:           loadCandleAlignConfig(database),
+		EntryConfirmation:     loadEntryConfirmationConfig(database),
+		MinBarsBetweenTrades:  loadMinBarsBetweenTrades(database),
+		QuoteQuantityOrders:   loadQuoteQuantityOrders(database),
+		LossCooldown:          loadLossCooldownConfig(database),
+		ConfidenceSizing:      loadConfidenceSizingConfig(database),
+		PromptCache:           loadPromptCacheConfig(database),
+		IntentDedupWindow:     loadIntentDedupWindow(database),
+		HaltedZeroVolumeCandles: loadHaltedZeroVolumeCandles(database),
+		ReconcilePositionsOnStart: loadReconcilePositionsOnStart(database),
+		Blackout:              loadBlackoutConfig(database),
+		DustThresholdUSD:      loadDustThresholdUSD(database),
+		StaleOrderMaxAge:      loadStaleOrderMaxAge(database),
+		Anomaly:               loadAnomalyConfig(database),
+		SlippageWarningBps:    loadSlippageWarningBps(database),
+		Review:                loadReviewConfig(database, traderCfg),
+		PromptTokenBudget:     loadPromptTokenBudget(database),
+		OpenGate:              tm.positionGate(database),
 		IsCrossMargin:         traderCfg.IsCrossMargin,
 		DefaultCoins:          defaultCoins,
 		TradingCoins:          tradingCoins,
@@ -446,6 +582,9 @@ func (tm *TraderManager) AddTraderFromDB(traderCfg *config.TraderRecord, aiModel
 		}
 	}
 
+	// 对账上次进程在周期执行中重启时未结束的周期
+	at.RecoverInterruptedCycles()
+
 	tm.traders[traderCfg.ID] = at
 	log.Printf("✓ Trader '%s' (%s + %s) 已添加", traderCfg.Name, aiModelCfg.Provider, exchangeCfg.ID)
 	return nil
@@ -496,7 +635,7 @@ func (tm *TraderManager) StartAll() {
 	for id, t := range tm.traders {
 		go func(traderID string, at *trader.AutoTrader) {
 			log.Printf("▶️  启动 %s...", at.GetName())
-			if err := at.Run(); err != nil {
+			if err := tm.supervisor.Run(at); err != nil {
 				log.Printf("❌ %s 运行错误: %v", at.GetName(), err)
 			}
 		}(id, t)
@@ -1039,12 +1178,12 @@ func (tm *TraderManager) LoadUserTraders(database *config.Database, userID strin
 		}
 
 		if aiModelCfg == nil {
-			log.Printf("⚠️ 交易员 %s 的AI模型 %s 不存在，跳过", traderCfg.Name, traderCfg.AIModelID)
+			tm.recordLoadError(traderCfg, fmt.Sprintf("AI模型 %s 不存在", traderCfg.AIModelID))
 			continue
 		}
 
 		if !aiModelCfg.Enabled {
-			log.Printf("⚠️ 交易员 %s 的AI模型 %s 未启用，跳过", traderCfg.Name, traderCfg.AIModelID)
+			tm.recordLoadError(traderCfg, fmt.Sprintf("AI模型 %s 未启用", traderCfg.AIModelID))
 			continue
 		}
 
@@ -1058,20 +1197,22 @@ func (tm *TraderManager) LoadUserTraders(database *config.Database, userID strin
 		}
 
 		if exchangeCfg == nil {
-			log.Printf("⚠️ 交易员 %s 的交易所 %s 不存在，跳过", traderCfg.Name, traderCfg.ExchangeID)
+			tm.recordLoadError(traderCfg, fmt.Sprintf("交易所 %s 不存在", traderCfg.ExchangeID))
 			continue
 		}
 
 		if !exchangeCfg.Enabled {
-			log.Printf("⚠️ 交易员 %s 的交易所 %s 未启用，跳过", traderCfg.Name, traderCfg.ExchangeID)
+			tm.recordLoadError(traderCfg, fmt.Sprintf("交易所 %s 未启用", traderCfg.ExchangeID))
 			continue
 		}
 
 		// 使用现有的方法加载交易员
 		err = tm.loadSingleTrader(traderCfg, aiModelCfg, exchangeCfg, coinPoolURL, oiTopURL, maxDailyLoss, maxDrawdown, stopTradingMinutes, defaultCoins, database, userID)
 		if err != nil {
-			log.Printf("⚠️ 加载交易员 %s 失败: %v", traderCfg.Name, err)
+			tm.recordLoadError(traderCfg, err.Error())
+			continue
 		}
+		delete(tm.loadErrors, traderCfg.ID)
 	}
 
 	return nil
@@ -1086,10 +1227,20 @@ func (tm *TraderManager) LoadUserTraders(database *config.Database, userID strin
 //
 // 返回:
 //   - error: 如果交易员不存在、配置无效或加载失败则返回错误
-func (tm *TraderManager) LoadTraderByID(database *config.Database, userID, traderID string) error {
+func (tm *TraderManager) LoadTraderByID(database *config.Database, userID, traderID string) (err error) {
 	tm.mu.Lock()
 	defer tm.mu.Unlock()
 
+	var traderCfg *config.TraderRecord
+	defer func() {
+		// 记录或清除加载失败原因，供加载报告和交易员列表展示
+		if err == nil {
+			delete(tm.loadErrors, traderID)
+		} else if traderCfg != nil {
+			tm.recordLoadError(traderCfg, err.Error())
+		}
+	}()
+
 	// 1. 检查是否已加载
 	if _, exists := tm.traders[traderID]; exists {
 		log.Printf("⚠️ 交易员 %s 已经加载，跳过", traderID)
@@ -1102,7 +1253,6 @@ func (tm *TraderManager) LoadTraderByID(database *config.Database, userID, trade
 		return fmt.Errorf("获取交易员列表失败: %w", err)
 	}
 
-	var traderCfg *config.TraderRecord
 	for _, t := range traders {
 		if t.ID == traderID {
 			traderCfg = t
@@ -1230,17 +1380,8 @@ func (tm *TraderManager) LoadTraderByID(database *config.Database, userID, trade
 // loadSingleTrader 加载单个交易员（从现有代码提取的公共逻辑）
 func (tm *TraderManager) loadSingleTrader(traderCfg *config.TraderRecord, aiModelCfg *config.AIModelConfig, exchangeCfg *config.ExchangeConfig, coinPoolURL, oiTopURL string, maxDailyLoss, maxDrawdown float64, stopTradingMinutes int, defaultCoins []string, database *config.Database, userID string) error {
 	// 处理交易币种列表
-	var tradingCoins []string
-	if traderCfg.TradingSymbols != "" {
-		// 解析逗号分隔的交易币种列表
-		symbols := strings.Split(traderCfg.TradingSymbols, ",")
-		for _, symbol := range symbols {
-			symbol = strings.TrimSpace(symbol)
-			if symbol != "" {
-				tradingCoins = append(tradingCoins, symbol)
-			}
-		}
-	}
+	// 兼容逗号分隔和JSON数组两种存储格式
+	tradingCoins := market.ParseSymbolList(traderCfg.TradingSymbols)
 
 	// 如果没有指定交易币种，使用默认币种
 	if len(tradingCoins) == 0 {
@@ -1254,6 +1395,8 @@ func (tm *TraderManager) loadSingleTrader(traderCfg *config.TraderRecord, aiMode
 		log.Printf("✓ 交易员 %s 启用 COIN POOL 信号源: %s", traderCfg.Name, coinPoolURL)
 	}
 
+	paperRealism := loadPaperRealism(database, traderCfg)
+
 	// 构建AutoTraderConfig
 	traderConfig := trader.AutoTraderConfig{
 		ID:                   traderCfg.ID,
@@ -1264,7 +1407,10 @@ func (tm *TraderManager) loadSingleTrader(traderCfg *config.TraderRecord, aiMode
 		BTCETHLeverage:       traderCfg.BTCETHLeverage,
 		AltcoinLeverage:      traderCfg.AltcoinLeverage,
 		ScanInterval:         time.Duration(traderCfg.ScanIntervalMinutes) * time.Minute,
+		AICallBudget:         loadAICallBudget(database),
 		CoinPoolAPIURL:       effectiveCoinPoolURL,
+		CoinPoolMaxSymbols:   traderCfg.CoinPoolMaxSymbols,
+		CoinPoolRankBy:       traderCfg.CoinPoolRankBy,
 		CustomAPIURL:         aiModelCfg.CustomAPIURL,    // 自定义API URL
 		CustomModelName:      aiModelCfg.CustomModelName, // 自定义模型名称（OpenRouter 也使用此字段存储模型名称）
 		UseQwen:              aiModelCfg.Provider == "qwen",
@@ -1272,6 +1418,48 @@ func (tm *TraderManager) loadSingleTrader(traderCfg *config.TraderRecord, aiMode
 		MaxDailyLoss:         maxDailyLoss,
 		MaxDrawdown:          maxDrawdown,
 		StopTradingTime:      time.Duration(stopTradingMinutes) * time.Minute,
+		RiskScaling:          loadRiskScalingConfig(database, traderCfg, maxDailyLoss, maxDrawdown),
+		AllocationBudget:     loadAllocationBudget(database, traderCfg),
+		PositionSizing:       loadPositionSizing(traderCfg),
+		DataSource:           loadDataSource(traderCfg),
+		Indicators:           loadIndicatorParams(traderCfg),
+		HedgePolicy:          loadHedgePolicy(traderCfg),
+		ManageOnly:           traderCfg.ManageOnly,
+		HardDrawdownPct:      traderCfg.HardDrawdownPct,
+		DisabledReason:       traderCfg.DisabledReason,
+		HardDrawdownPeak:     traderCfg.HardDrawdownPeak,
+		HardDrawdownRebase:   traderCfg.HardDrawdownRebase,
+		RiskPeakEquity:       traderCfg.RiskPeakEquity,
+		DayStartEquity:       traderCfg.DayStartEquity,
+		DayStartDate:         traderCfg.DayStartDate,
+		PaperPriceImpact:     paperRealism.PriceImpact,
+		PaperFill:            paperRealism.Fill,
+		PaperCosts:           paperRealism.Costs,
+		PaperPositionMode:    loadPaperPositionMode(database),
+		StablecoinPeg:        loadStablecoinPegConfig(database),
+		DataOutage:           loadDataOutageConfig(database),
+		MarginAsset:          traderCfg.MarginAsset,
+		MaxSpreadBps:         loadMaxSpreadBps(database),
+		Webhook:              loadWebhookConfig(database, userID),
+		ChurnGuard:           loadChurnGuardConfig(database),
+		CandleAlign:          loadCandleAlignConfig(database),
+		EntryConfirmation:    loadEntryConfirmationConfig(database),
+		MinBarsBetweenTrades: loadMinBarsBetweenTrades(database),
+		QuoteQuantityOrders:  loadQuoteQuantityOrders(database),
+		LossCooldown:         loadLossCooldownConfig(database),
+		ConfidenceSizing:     loadConfidenceSizingConfig(database),
+		PromptCache:          loadPromptCacheConfig(database),
+		IntentDedupWindow:    loadIntentDedupWindow(database),
+		HaltedZeroVolumeCandles: loadHaltedZeroVolumeCandles(database),
+		ReconcilePositionsOnStart: loadReconcilePositionsOnStart(database),
+		Blackout:             loadBlackoutConfig(database),
+		DustThresholdUSD:     loadDustThresholdUSD(database),
+		StaleOrderMaxAge:     loadStaleOrderMaxAge(database),
+		Anomaly:              loadAnomalyConfig(database),
+		SlippageWarningBps:   loadSlippageWarningBps(database),
+		Review:               loadReviewConfig(database, traderCfg),
+		PromptTokenBudget:    loadPromptTokenBudget(database),
+		OpenGate:             tm.positionGate(database),
 		IsCrossMargin:        traderCfg.IsCrossMargin,
 		DefaultCoins:         defaultCoins,
 		TradingCoins:         tradingCoins,
@@ -1345,7 +1533,628 @@ func (tm *TraderManager) loadSingleTrader(traderCfg *config.TraderRecord, aiMode
 		}
 	}
 
+	// 对账上次进程在周期执行中重启时未结束的周期
+	at.RecoverInterruptedCycles()
+
 	tm.traders[traderCfg.ID] = at
 	log.Printf("✓ Trader '%s' (%s + %s) 已为用户加载到内存", traderCfg.Name, aiModelCfg.Provider, exchangeCfg.ID)
 	return nil
 }
+
+// loadRiskScalingConfig 从系统配置读取动态风险缩放规则（缺失或无效时使用默认值），净值下限取交易员配置
+func loadRiskScalingConfig(database *config.Database, traderCfg *config.TraderRecord, maxDailyLoss, maxDrawdown float64) decision.RiskScalingConfig {
+	cfg := decision.DefaultRiskScalingConfig(maxDailyLoss, maxDrawdown)
+	if traderCfg != nil && traderCfg.MinEquity > 0 {
+		cfg.MinEquity = traderCfg.MinEquity
+	}
+	if database == nil {
+		return cfg
+	}
+
+	parseRatio := func(key string, target *float64) {
+		str, _ := database.GetSystemConfig(key)
+		if val, err := strconv.ParseFloat(str, 64); err == nil && val > 0 && val <= 1 {
+			*target = val
+		}
+	}
+	parseRatio("risk_scale_reduce_until", &cfg.ReduceUntil)
+	parseRatio("risk_scale_min_size_factor", &cfg.MinSizeFactor)
+	parseRatio("risk_scale_min_leverage_factor", &cfg.MinLevFactor)
+	parseRatio("risk_scale_block_entry_at", &cfg.BlockEntryAt)
+
+	return cfg
+}
+
+// loadAllocationBudget 读取交易员的单币种资金分配上限（交易员未设置时使用系统配置 max_symbol_allocation_pct）
+func loadAllocationBudget(database *config.Database, traderCfg *config.TraderRecord) decision.AllocationBudget {
+	budget := decision.AllocationBudget{DefaultPct: traderCfg.MaxSymbolAllocationPct}
+	if budget.DefaultPct <= 0 && database != nil {
+		str, _ := database.GetSystemConfig("max_symbol_allocation_pct")
+		if val, err := strconv.ParseFloat(str, 64); err == nil && val > 0 && val <= 100 {
+			budget.DefaultPct = val
+		}
+	}
+
+	overrides, err := decision.ParseAllocationOverrides(traderCfg.SymbolAllocationOverrides)
+	if err != nil {
+		log.Printf("⚠️  交易员 %s 的单币种分配上限配置无效，已忽略: %v", traderCfg.Name, err)
+	}
+	budget.SymbolPct = overrides
+
+	return budget
+}
+
+// loadPaperPriceImpactConfig 从系统配置读取模拟仓大单价格冲击模型（默认关闭）
+func loadPaperPriceImpactConfig(database *config.Database) trader.PriceImpactConfig {
+	cfg := trader.DefaultPriceImpactConfig()
+	if database == nil {
+		return cfg
+	}
+
+	if enabled, _ := database.GetSystemConfig("paper_price_impact_enabled"); enabled == "true" {
+		cfg.Enabled = true
+	}
+	if str, _ := database.GetSystemConfig("paper_price_impact_coefficient"); str != "" {
+		if val, err := strconv.ParseFloat(str, 64); err == nil && val > 0 {
+			cfg.Coefficient = val
+		}
+	}
+	if str, _ := database.GetSystemConfig("paper_price_impact_max_pct"); str != "" {
+		if val, err := strconv.ParseFloat(str, 64); err == nil && val > 0 {
+			cfg.MaxImpactPct = val
+		}
+	}
+
+	return cfg
+}
+
+// loadPaperRealism 读取交易员的模拟仓真实度：设置了档位时使用档位参数，否则使用系统配置的价格冲击和成交价模型，
+// 再叠加交易员的单项覆盖（配置无效时忽略并记录原因）
+func loadPaperRealism(database *config.Database, traderCfg *config.TraderRecord) trader.PaperRealism {
+	base := trader.PaperRealism{
+		PriceImpact: loadPaperPriceImpactConfig(database),
+		Fill:        loadPaperFillConfig(database),
+	}
+
+	overrides, err := trader.ParsePaperRealismOverrides(traderCfg.PaperRealismOverrides)
+	if err != nil {
+		log.Printf("⚠️  交易员 %s: %v，已忽略单项覆盖", traderCfg.Name, err)
+	}
+	realism, err := trader.ResolvePaperRealism(traderCfg.PaperRealism, overrides, base)
+	if err != nil {
+		log.Printf("⚠️  交易员 %s: %v，使用系统配置", traderCfg.Name, err)
+		realism, _ = trader.ResolvePaperRealism("", overrides, base)
+	}
+	return realism
+}
+
+// loadPaperFillConfig 从系统配置读取模拟仓成交价模型（默认按实时价格成交）
+func loadPaperFillConfig(database *config.Database) trader.PaperFillConfig {
+	cfg := trader.DefaultPaperFillConfig()
+	if database == nil {
+		return cfg
+	}
+
+	if mode, _ := database.GetSystemConfig("paper_fill_mode"); mode != "" {
+		if normalized, err := trader.NormalizePaperFillMode(mode); err == nil {
+			cfg.Mode = normalized
+		} else {
+			log.Printf("⚠️  %v，使用 %s", err, cfg.Mode)
+		}
+	}
+	if interval, _ := database.GetSystemConfig("paper_fill_interval"); interval != "" {
+		cfg.Interval = interval
+	}
+	if raw, _ := database.GetSystemConfig("paper_fill_latency_ms"); raw != "" {
+		if ms, err := strconv.Atoi(raw); err == nil && ms > 0 {
+			cfg.Latency = time.Duration(ms) * time.Millisecond
+		} else {
+			log.Printf("⚠️  paper_fill_latency_ms 无效: %q，使用默认成交延迟", raw)
+		}
+	}
+
+	return cfg
+}
+
+// loadWebhookConfig 读取用户的交易事件 Webhook 配置（读取失败时不推送）
+func loadWebhookConfig(database *config.Database, userID string) trader.WebhookConfig {
+	if database == nil {
+		return trader.WebhookConfig{}
+	}
+	url, secret, err := database.GetUserWebhook(userID)
+	if err != nil {
+		log.Printf("⚠️  读取用户 %s 的 Webhook 配置失败: %v", userID, err)
+		return trader.WebhookConfig{}
+	}
+	return trader.WebhookConfig{URL: url, Secret: secret}
+}
+
+// SetUserWebhook 更新用户所有已加载交易员的 Webhook 配置（保存后立即生效）
+func (tm *TraderManager) SetUserWebhook(userID string, cfg trader.WebhookConfig) {
+	tm.mu.RLock()
+	defer tm.mu.RUnlock()
+	for _, at := range tm.traders {
+		if at.GetUserID() == userID {
+			at.SetWebhook(cfg)
+		}
+	}
+}
+
+// loadPaperPositionMode 从系统配置读取模拟仓持仓模式（默认双向持仓）
+func loadPaperPositionMode(database *config.Database) string {
+	if database == nil {
+		return trader.PaperPositionHedge
+	}
+	mode, _ := database.GetSystemConfig("paper_position_mode")
+	normalized, err := trader.NormalizePaperPositionMode(mode)
+	if err != nil {
+		log.Printf("⚠️  %v，使用 %s", err, trader.PaperPositionHedge)
+		return trader.PaperPositionHedge
+	}
+	return normalized
+}
+
+// loadStablecoinPegConfig 从系统配置读取稳定币脱锚保护（默认关闭）
+func loadStablecoinPegConfig(database *config.Database) trader.StablecoinPegConfig {
+	cfg := trader.DefaultStablecoinPegConfig()
+	if database == nil {
+		return cfg
+	}
+
+	if enabled, _ := database.GetSystemConfig("stablecoin_peg_check_enabled"); enabled == "true" {
+		cfg.Enabled = true
+	}
+	if symbol, _ := database.GetSystemConfig("stablecoin_peg_symbol"); strings.TrimSpace(symbol) != "" {
+		cfg.Symbol = strings.ToUpper(strings.TrimSpace(symbol))
+	}
+	if str, _ := database.GetSystemConfig("stablecoin_peg_max_deviation_pct"); str != "" {
+		if val, err := strconv.ParseFloat(str, 64); err == nil && val > 0 {
+			cfg.MaxDeviationPct = val
+		}
+	}
+
+	return cfg
+}
+
+// loadDataOutageConfig 从系统配置读取行情中断保护（默认关闭，处理方式无效时使用 block_opens）
+func loadDataOutageConfig(database *config.Database) trader.DataOutageConfig {
+	cfg := trader.DefaultDataOutageConfig()
+	if database == nil {
+		return cfg
+	}
+
+	if str, _ := database.GetSystemConfig("data_outage_max_age_seconds"); str != "" {
+		if val, err := strconv.ParseFloat(strings.TrimSpace(str), 64); err == nil && val > 0 {
+			cfg.MaxAge = time.Duration(val * float64(time.Second))
+		}
+	}
+	action, _ := database.GetSystemConfig("data_outage_action")
+	normalized, err := trader.NormalizeDataOutageAction(action)
+	if err != nil {
+		log.Printf("⚠️  %v，使用 %s", err, cfg.Action)
+		return cfg
+	}
+	cfg.Action = normalized
+	return cfg
+}
+
+// loadMaxSpreadBps 从系统配置读取开仓前允许的最大买卖价差（bps，0 表示不检查）
+func loadMaxSpreadBps(database *config.Database) float64 {
+	if database == nil {
+		return 0
+	}
+	str, _ := database.GetSystemConfig("max_spread_bps")
+	val, err := strconv.ParseFloat(str, 64)
+	if err != nil || val < 0 {
+		return 0
+	}
+	return val
+}
+
+// loadAICallBudget 从系统配置读取AI调用时间预算（秒，0 或无效时使用默认值）
+func loadAICallBudget(database *config.Database) time.Duration {
+	if database == nil {
+		return 0
+	}
+	str, _ := database.GetSystemConfig("ai_call_budget_seconds")
+	val, err := strconv.ParseFloat(str, 64)
+	if err != nil || val <= 0 {
+		return 0
+	}
+	return time.Duration(val * float64(time.Second))
+}
+
+// loadCandleAlignConfig 从系统配置读取决策周期对齐K线收盘的设置（默认不对齐，K线周期无效时不对齐）
+func loadCandleAlignConfig(database *config.Database) trader.CandleAlignConfig {
+	cfg := trader.CandleAlignConfig{Delay: trader.DefaultCandleAlignDelay}
+	if database == nil {
+		return cfg
+	}
+
+	raw, _ := database.GetSystemConfig("cycle_align_timeframe")
+	timeframe, err := trader.ParseCandleAlignTimeframe(raw)
+	if err != nil {
+		log.Printf("⚠️  %v，按扫描间隔计时", err)
+		return cfg
+	}
+	cfg.Timeframe = timeframe
+	if str, _ := database.GetSystemConfig("cycle_align_delay_seconds"); str != "" {
+		if val, err := strconv.ParseFloat(strings.TrimSpace(str), 64); err == nil && val >= 0 {
+			cfg.Delay = time.Duration(val * float64(time.Second))
+		}
+	}
+	return cfg
+}
+
+// loadChurnGuardConfig 从系统配置读取防反复开平仓检查（默认关闭）
+func loadChurnGuardConfig(database *config.Database) trader.ChurnGuardConfig {
+	cfg := trader.DefaultChurnGuardConfig()
+	if database == nil {
+		return cfg
+	}
+
+	if enabled, _ := database.GetSystemConfig("churn_guard_enabled"); enabled == "true" {
+		cfg.Enabled = true
+	}
+	if str, _ := database.GetSystemConfig("churn_guard_cycles"); str != "" {
+		if val, err := strconv.Atoi(str); err == nil && val > 0 {
+			cfg.Cycles = val
+		}
+	}
+	if str, _ := database.GetSystemConfig("churn_guard_min_confidence"); str != "" {
+		if val, err := strconv.Atoi(str); err == nil && val >= 0 && val <= 100 {
+			cfg.MinConfidence = val
+		}
+	}
+
+	return cfg
+}
+
+// loadEntryConfirmationConfig 从系统配置读取开仓确认K线要求（默认关闭，信号名称无效时使用 sar）
+func loadEntryConfirmationConfig(database *config.Database) trader.EntryConfirmationConfig {
+	cfg := trader.DefaultEntryConfirmationConfig()
+	if database == nil {
+		return cfg
+	}
+
+	if str, _ := database.GetSystemConfig("entry_confirmation_candles"); str != "" {
+		if val, err := strconv.Atoi(strings.TrimSpace(str)); err == nil && val >= 0 {
+			cfg.Candles = val
+		}
+	}
+	signal, _ := database.GetSystemConfig("entry_confirmation_signal")
+	normalized, err := trader.NormalizeConfirmSignal(signal)
+	if err != nil {
+		log.Printf("⚠️  %v，使用 %s", err, cfg.Signal)
+		return cfg
+	}
+	cfg.Signal = normalized
+	return cfg
+}
+
+// loadMinBarsBetweenTrades 从系统配置读取同一币种两次开平仓之间最少间隔的K线根数（默认 0 不检查）
+func loadMinBarsBetweenTrades(database *config.Database) int {
+	if database == nil {
+		return 0
+	}
+	str, _ := database.GetSystemConfig("min_bars_between_trades")
+	val, err := strconv.Atoi(strings.TrimSpace(str))
+	if err != nil || val < 0 {
+		return 0
+	}
+	return val
+}
+
+// loadQuoteQuantityOrders 从系统配置读取是否按金额下单（默认关闭）
+func loadQuoteQuantityOrders(database *config.Database) bool {
+	if database == nil {
+		return false
+	}
+	enabled, _ := database.GetSystemConfig("quote_quantity_orders")
+	return strings.TrimSpace(enabled) == "true"
+}
+
+// loadLossCooldownConfig 从系统配置读取亏损后降仓冷却参数（默认关闭，仓位系数需在 0-1 之间）
+func loadLossCooldownConfig(database *config.Database) trader.LossCooldownConfig {
+	cfg := trader.DefaultLossCooldownConfig()
+	if database == nil {
+		return cfg
+	}
+
+	if str, _ := database.GetSystemConfig("loss_cooldown_loss_pct"); str != "" {
+		if val, err := strconv.ParseFloat(strings.TrimSpace(str), 64); err == nil && val >= 0 {
+			cfg.LossPct = val
+		}
+	}
+	if str, _ := database.GetSystemConfig("loss_cooldown_size_factor"); str != "" {
+		if val, err := strconv.ParseFloat(strings.TrimSpace(str), 64); err == nil && val > 0 && val < 1 {
+			cfg.SizeFactor = val
+		}
+	}
+	if str, _ := database.GetSystemConfig("loss_cooldown_minutes"); str != "" {
+		if val, err := strconv.Atoi(strings.TrimSpace(str)); err == nil && val >= 0 {
+			cfg.Minutes = val
+		}
+	}
+	if str, _ := database.GetSystemConfig("loss_cooldown_trades"); str != "" {
+		if val, err := strconv.Atoi(strings.TrimSpace(str)); err == nil && val >= 0 {
+			cfg.Trades = val
+		}
+	}
+	return cfg
+}
+
+// loadConfidenceSizingConfig 从系统配置读取信心度仓位参数（默认关闭，最大仓位需不小于最小仓位）
+func loadConfidenceSizingConfig(database *config.Database) trader.ConfidenceSizingConfig {
+	cfg := trader.DefaultConfidenceSizingConfig()
+	if database == nil {
+		return cfg
+	}
+
+	if str, _ := database.GetSystemConfig("confidence_sizing_min_notional"); str != "" {
+		if val, err := strconv.ParseFloat(strings.TrimSpace(str), 64); err == nil && val >= 0 {
+			cfg.MinNotional = val
+		}
+	}
+	if str, _ := database.GetSystemConfig("confidence_sizing_max_notional"); str != "" {
+		if val, err := strconv.ParseFloat(strings.TrimSpace(str), 64); err == nil && val >= 0 {
+			cfg.MaxNotional = val
+		}
+	}
+	if str, _ := database.GetSystemConfig("confidence_sizing_exponent"); str != "" {
+		if val, err := strconv.ParseFloat(strings.TrimSpace(str), 64); err == nil && val > 0 {
+			cfg.Exponent = val
+		}
+	}
+	if cfg.MinNotional > 0 && cfg.MaxNotional < cfg.MinNotional {
+		log.Printf("⚠️  信心度仓位最大仓位 %.2f 小于最小仓位 %.2f，不启用", cfg.MaxNotional, cfg.MinNotional)
+	}
+	return cfg
+}
+
+// loadPromptCacheConfig 从系统配置读取提示词缓存参数（默认关闭）
+func loadPromptCacheConfig(database *config.Database) decision.PromptCacheConfig {
+	cfg := decision.DefaultPromptCacheConfig()
+	if database == nil {
+		return cfg
+	}
+
+	if str, _ := database.GetSystemConfig("prompt_cache_tolerance_pct"); str != "" {
+		if val, err := strconv.ParseFloat(strings.TrimSpace(str), 64); err == nil && val >= 0 {
+			cfg.TolerancePct = val
+		}
+	}
+	if str, _ := database.GetSystemConfig("prompt_cache_max_age_minutes"); str != "" {
+		if val, err := strconv.Atoi(strings.TrimSpace(str)); err == nil && val >= 0 {
+			cfg.MaxAge = time.Duration(val) * time.Minute
+		}
+	}
+	return cfg
+}
+
+// loadIntentDedupWindow 从系统配置读取重复开仓意图检查窗口（分钟，未配置或无效时使用默认值，0 表示不检查）
+func loadIntentDedupWindow(database *config.Database) time.Duration {
+	if database == nil {
+		return trader.DefaultIntentDedupWindow
+	}
+	str, _ := database.GetSystemConfig("intent_dedup_window_minutes")
+	val, err := strconv.ParseFloat(strings.TrimSpace(str), 64)
+	if err != nil || val < 0 {
+		return trader.DefaultIntentDedupWindow
+	}
+	return time.Duration(val * float64(time.Minute))
+}
+
+// loadHaltedZeroVolumeCandles 从系统配置读取停牌检测的连续零成交量K线根数（未配置或无效时使用默认值，0 表示不检查）
+func loadHaltedZeroVolumeCandles(database *config.Database) int {
+	if database == nil {
+		return decision.DefaultHaltedZeroVolumeCandles
+	}
+	str, _ := database.GetSystemConfig("halted_zero_volume_candles")
+	val, err := strconv.Atoi(strings.TrimSpace(str))
+	if err != nil || val < 0 {
+		return decision.DefaultHaltedZeroVolumeCandles
+	}
+	return val
+}
+
+// loadReconcilePositionsOnStart 从系统配置读取实盘交易员启动时是否按交易所持仓对账（默认开启）
+func loadReconcilePositionsOnStart(database *config.Database) bool {
+	if database == nil {
+		return true
+	}
+	str, _ := database.GetSystemConfig("reconcile_positions_on_start")
+	return strings.TrimSpace(str) != "false"
+}
+
+// loadBlackoutConfig 从系统配置读取交易暂停窗口（格式无效时忽略并记录警告）
+func loadBlackoutConfig(database *config.Database) trader.BlackoutConfig {
+	var cfg trader.BlackoutConfig
+	if database == nil {
+		return cfg
+	}
+
+	raw, _ := database.GetSystemConfig("trading_blackout_windows")
+	windows, err := trader.ParseBlackoutWindows(raw)
+	if err != nil {
+		log.Printf("⚠️  交易暂停窗口配置无效，已忽略: %v", err)
+	}
+	cfg.Windows = windows
+	if flatten, _ := database.GetSystemConfig("trading_blackout_flatten"); flatten == "true" {
+		cfg.FlattenPositions = true
+	}
+
+	return cfg
+}
+
+// loadDustThresholdUSD 从系统配置读取粉尘仓位阈值（USD，0 表示不处理）
+func loadDustThresholdUSD(database *config.Database) float64 {
+	if database == nil {
+		return 0
+	}
+	str, _ := database.GetSystemConfig("dust_position_threshold_usd")
+	val, err := strconv.ParseFloat(str, 64)
+	if err != nil || val < 0 {
+		return 0
+	}
+	return val
+}
+
+// loadStaleOrderMaxAge 从系统配置读取挂单最长保留时间（0 表示不处理）
+func loadStaleOrderMaxAge(database *config.Database) time.Duration {
+	if database == nil {
+		return 0
+	}
+	str, _ := database.GetSystemConfig("stale_order_max_age_minutes")
+	minutes, err := strconv.Atoi(str)
+	if err != nil || minutes < 0 {
+		return 0
+	}
+	return time.Duration(minutes) * time.Minute
+}
+
+// loadAnomalyConfig 从系统配置读取交易员行为异常检测参数
+func loadAnomalyConfig(database *config.Database) trader.AnomalyConfig {
+	cfg := trader.DefaultAnomalyConfig()
+	if database == nil {
+		return cfg
+	}
+
+	if enabled, _ := database.GetSystemConfig("anomaly_detection_enabled"); enabled == "true" {
+		cfg.Enabled = true
+	}
+	if str, _ := database.GetSystemConfig("anomaly_baseline_cycles"); str != "" {
+		if val, err := strconv.Atoi(str); err == nil && val > 0 {
+			cfg.BaselineCycles = val
+		}
+	}
+	if str, _ := database.GetSystemConfig("anomaly_recent_cycles"); str != "" {
+		if val, err := strconv.Atoi(str); err == nil && val > 0 {
+			cfg.RecentCycles = val
+		}
+	}
+	if str, _ := database.GetSystemConfig("anomaly_sensitivity"); str != "" {
+		if val, err := strconv.ParseFloat(str, 64); err == nil && val > 0 {
+			cfg.Sensitivity = val
+		}
+	}
+
+	return cfg
+}
+
+// loadSlippageWarningBps 从系统配置读取平均滑点提示阈值（bps，0 表示不提示）
+func loadSlippageWarningBps(database *config.Database) float64 {
+	if database == nil {
+		return 0
+	}
+	str, _ := database.GetSystemConfig("slippage_warning_bps")
+	val, err := strconv.ParseFloat(str, 64)
+	if err != nil || val < 0 {
+		return 0
+	}
+	return val
+}
+
+// loadPositionSizing 读取交易员的仓位大小模式（配置无效时使用按金额模式，并记录原因）
+func loadPositionSizing(traderCfg *config.TraderRecord) decision.PositionSizing {
+	sizing, err := decision.NormalizePositionSizing(traderCfg.PositionSizingMode, traderCfg.PositionSizeMinPct, traderCfg.PositionSizeMaxPct)
+	if err != nil {
+		log.Printf("⚠️  交易员 %s: %v，使用按金额模式", traderCfg.Name, err)
+		sizing, _ = decision.NormalizePositionSizing(decision.SizingModeAbsolute, 0, 0)
+	}
+	return sizing
+}
+
+// loadDataSource 读取交易员的行情数据源（未配置或无效时使用全局数据源，并记录原因）
+func loadDataSource(traderCfg *config.TraderRecord) market.DataSource {
+	source, err := market.ParseDataSource(traderCfg.DataSource)
+	if err != nil {
+		log.Printf("⚠️  交易员 %s: %v，使用全局数据源", traderCfg.Name, err)
+		return ""
+	}
+	if source != "" {
+		log.Printf("📊 交易员 %s 使用行情数据源: %s", traderCfg.Name, source)
+	}
+	return source
+}
+
+// loadIndicatorParams 读取交易员的指标周期（配置无效时使用默认周期，并记录原因）
+func loadIndicatorParams(traderCfg *config.TraderRecord) market.IndicatorParams {
+	params, err := market.ParseIndicatorParams(traderCfg.IndicatorParams)
+	if err != nil {
+		log.Printf("⚠️  交易员 %s: %v，使用默认指标周期", traderCfg.Name, err)
+		return market.IndicatorParams{}
+	}
+	return params
+}
+
+// loadHedgePolicy 读取交易员的对冲策略（配置无效时使用 no_hedge，并记录原因）
+func loadHedgePolicy(traderCfg *config.TraderRecord) string {
+	policy, err := trader.NormalizeHedgePolicy(traderCfg.HedgePolicy)
+	if err != nil {
+		log.Printf("⚠️  交易员 %s: %v，使用 %s", traderCfg.Name, err, trader.HedgePolicyNoHedge)
+		return trader.HedgePolicyNoHedge
+	}
+	return policy
+}
+
+// loadReviewConfig 读取交易员的两级模型配置（复核模型不存在、未启用或配置无效时不启用复核，并记录原因）
+func loadReviewConfig(database *config.Database, traderCfg *config.TraderRecord) trader.ReviewConfig {
+	var cfg trader.ReviewConfig
+	if database == nil || traderCfg.ReviewModelID == "" {
+		return cfg
+	}
+
+	triggers, err := decision.ParseReviewTriggers(traderCfg.ReviewTriggers)
+	if err != nil {
+		log.Printf("⚠️  交易员 %s 的%v，不启用复核", traderCfg.Name, err)
+		return cfg
+	}
+	if !triggers.Enabled() {
+		log.Printf("⚠️  交易员 %s 配置了复核模型但没有触发条件，不启用复核", traderCfg.Name)
+		return cfg
+	}
+	fallback, err := decision.NormalizeReviewFallback(traderCfg.ReviewFallback)
+	if err != nil {
+		log.Printf("⚠️  交易员 %s: %v，使用 hold", traderCfg.Name, err)
+		fallback = decision.ReviewFallbackHold
+	}
+
+	models, err := database.GetAIModels(traderCfg.UserID)
+	if err != nil {
+		log.Printf("⚠️  交易员 %s 获取复核模型失败，不启用复核: %v", traderCfg.Name, err)
+		return cfg
+	}
+	for _, model := range models {
+		if model.ID != traderCfg.ReviewModelID {
+			continue
+		}
+		if !model.Enabled {
+			log.Printf("⚠️  交易员 %s 的复核模型 %s 未启用，不启用复核", traderCfg.Name, model.ID)
+			return cfg
+		}
+		return trader.ReviewConfig{
+			Provider:        model.Provider,
+			APIKey:          model.APIKey,
+			CustomAPIURL:    model.CustomAPIURL,
+			CustomModelName: model.CustomModelName,
+			Triggers:        triggers,
+			Fallback:        fallback,
+		}
+	}
+	log.Printf("⚠️  交易员 %s 的复核模型 %s 不存在，不启用复核", traderCfg.Name, traderCfg.ReviewModelID)
+	return cfg
+}
+
+// loadPromptTokenBudget 从系统配置读取提示词 token 预算（0 表示不限制）
+func loadPromptTokenBudget(database *config.Database) int {
+	if database == nil {
+		return 0
+	}
+	str, _ := database.GetSystemConfig("prompt_token_budget")
+	val, err := strconv.Atoi(strings.TrimSpace(str))
+	if err != nil || val < 0 {
+		return 0
+	}
+	return val
+}
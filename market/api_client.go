@@ -2,6 +2,7 @@ package market
 
 import (
 	"aspen/hook"
+	"aspen/httpclient"
 	"bytes"
 	"encoding/json"
 	"fmt"
@@ -16,19 +17,27 @@ import (
 
 type APIClient struct {
 	client *http.Client
+	source DataSource // 为空时跟随全局数据源
+}
+
+// NewAPIClientFor 创建使用指定数据源的客户端（交易员单独配置了数据源时使用）
+func NewAPIClientFor(source DataSource) *APIClient {
+	c := NewAPIClient()
+	c.source = source
+	return c
 }
 
 func NewAPIClient() *APIClient {
 	client := &http.Client{
-		Timeout: 30 * time.Second,
+		Timeout:   30 * time.Second,
+		Transport: httpclient.SharedTransport(),
 	}
 
-	// 检查环境变量中的代理配置
+	// 检查环境变量中的代理配置（使用代理时单独创建传输层，连接池参数相同）
 	proxyURL := getProxyFromEnv()
 	if proxyURL != nil {
-		transport := &http.Transport{
-			Proxy: http.ProxyURL(proxyURL),
-		}
+		transport := httpclient.NewTransport(httpclient.CurrentPoolConfig())
+		transport.Proxy = http.ProxyURL(proxyURL)
 		client.Transport = transport
 		log.Printf("🌐 [Market] 使用代理服务器: %s", proxyURL.Host)
 	}
@@ -73,11 +82,17 @@ func getProxyFromEnv() *url.URL {
 	return proxyURL
 }
 
+// dataSource 客户端实际使用的数据源
+func (c *APIClient) dataSource() DataSource {
+	return resolveDataSource(c.source)
+}
+
 func (c *APIClient) GetExchangeInfo() (*ExchangeInfo, error) {
 	// 根据数据源选择不同的 endpoint
-	cfg := GetDataSourceConfig()
+	source := c.dataSource()
+	cfg := dataSourceConfigFor(source)
 	var endpoint string
-	switch currentDataSource {
+	switch source {
 	case DataSourceFinnhub:
 		// Finnhub 不支持 exchangeInfo，返回空结构
 		return &ExchangeInfo{Symbols: []SymbolInfo{}}, nil
@@ -94,7 +109,7 @@ func (c *APIClient) GetExchangeInfo() (*ExchangeInfo, error) {
 	var resp *http.Response
 	var err error
 
-	if currentDataSource == DataSourceHyperliquid {
+	if source == DataSourceHyperliquid {
 		// Hyperliquid uses POST
 		reqBody := HyperliquidRequest{Type: "meta"}
 		jsonBody, _ := json.Marshal(reqBody)
@@ -113,7 +128,7 @@ func (c *APIClient) GetExchangeInfo() (*ExchangeInfo, error) {
 		return nil, err
 	}
 
-	if currentDataSource == DataSourceHyperliquid {
+	if source == DataSourceHyperliquid {
 		var meta HyperliquidMeta
 		if err := json.Unmarshal(body, &meta); err != nil {
 			return nil, err
@@ -140,6 +155,7 @@ func (c *APIClient) GetExchangeInfo() (*ExchangeInfo, error) {
 				ContractType: "PERPETUAL",
 				BaseAsset:    asset.Name,
 				QuoteAsset:   "USDT",
+				MaxLeverage:  asset.MaxLeverage,
 			})
 		}
 		return &exchangeInfo, nil
@@ -155,12 +171,18 @@ func (c *APIClient) GetExchangeInfo() (*ExchangeInfo, error) {
 }
 
 func (c *APIClient) GetKlines(symbol, interval string, limit int) ([]Kline, error) {
-	cfg := GetDataSourceConfig()
+	return c.getKlines(symbol, interval, limit, 0, 0)
+}
+
+// getKlines 获取K线：startMs/endMs 为 0 时获取最近 limit 根，否则获取开盘时间在 [startMs, endMs] 内的最多 limit 根（毫秒时间戳）
+func (c *APIClient) getKlines(symbol, interval string, limit int, startMs, endMs int64) ([]Kline, error) {
+	source := c.dataSource()
+	cfg := dataSourceConfigFor(source)
 	var url string
 	var req *http.Request
 	var err error
 
-	switch currentDataSource {
+	switch source {
 	case DataSourceFinnhub:
 		// Finnhub API 格式: /api/v1/crypto/candle?symbol=BINANCE:BTCUSDT&resolution=3&from=timestamp&to=timestamp&token=API_KEY
 		if cfg.APIKey == "" {
@@ -182,6 +204,9 @@ func (c *APIClient) GetKlines(symbol, interval string, limit int) ([]Kline, erro
 		to := now.Unix()
 		// 根据间隔计算 from 时间
 		from := calculateFromTime(interval, limit, to)
+		if endMs > 0 {
+			from, to = startMs/1000, endMs/1000
+		}
 		q.Add("from", strconv.FormatInt(from, 10))
 		q.Add("to", strconv.FormatInt(to, 10))
 		q.Add("token", cfg.APIKey)
@@ -200,6 +225,10 @@ func (c *APIClient) GetKlines(symbol, interval string, limit int) ([]Kline, erro
 		bybitInterval := convertIntervalToBybit(interval)
 		q.Add("interval", bybitInterval)
 		q.Add("limit", strconv.Itoa(limit))
+		if endMs > 0 {
+			q.Add("start", strconv.FormatInt(startMs, 10))
+			q.Add("end", strconv.FormatInt(endMs, 10))
+		}
 		req.URL.RawQuery = q.Encode()
 	case DataSourceBinanceUS:
 		// Binance.US 使用现货 API
@@ -212,6 +241,10 @@ func (c *APIClient) GetKlines(symbol, interval string, limit int) ([]Kline, erro
 		q.Add("symbol", symbol)
 		q.Add("interval", interval)
 		q.Add("limit", strconv.Itoa(limit))
+		if endMs > 0 {
+			q.Add("startTime", strconv.FormatInt(startMs, 10))
+			q.Add("endTime", strconv.FormatInt(endMs, 10))
+		}
 		req.URL.RawQuery = q.Encode()
 	case DataSourceHyperliquid:
 		url = fmt.Sprintf("%s%s", cfg.BaseURL, cfg.KlinesEndpoint)
@@ -223,6 +256,9 @@ func (c *APIClient) GetKlines(symbol, interval string, limit int) ([]Kline, erro
 
 		startTime := CalculateHyperliquidStartTime(interval, limit)
 		endTime := time.Now().UnixMilli()
+		if endMs > 0 {
+			startTime, endTime = startMs, endMs
+		}
 
 		reqBody := HyperliquidRequest{
 			Type: "candleSnapshot",
@@ -249,12 +285,16 @@ func (c *APIClient) GetKlines(symbol, interval string, limit int) ([]Kline, erro
 		q.Add("symbol", symbol)
 		q.Add("interval", interval)
 		q.Add("limit", strconv.Itoa(limit))
+		if endMs > 0 {
+			q.Add("startTime", strconv.FormatInt(startMs, 10))
+			q.Add("endTime", strconv.FormatInt(endMs, 10))
+		}
 		req.URL.RawQuery = q.Encode()
 	}
 
 	resp, err := c.client.Do(req)
 	if err != nil {
-		sourceName := string(currentDataSource)
+		sourceName := string(source)
 		return nil, fmt.Errorf("HTTP请求失败 (可能是网络问题或%s API不可访问): %w", sourceName, err)
 	}
 	defer resp.Body.Close()
@@ -266,25 +306,25 @@ func (c *APIClient) GetKlines(symbol, interval string, limit int) ([]Kline, erro
 
 	// 检查HTTP状态码
 	if resp.StatusCode != http.StatusOK {
-		sourceName := string(currentDataSource)
-		return nil, fmt.Errorf("%s API返回错误状态码 %d: %s", sourceName, resp.StatusCode, string(body))
+		sourceName := string(source)
+		return nil, newStatusError(resp.StatusCode, "%s API返回错误状态码 %d: %s", sourceName, resp.StatusCode, string(body))
 	}
 
 	// 根据数据源解析不同的响应格式
 	var klines []Kline
-	if currentDataSource == DataSourceFinnhub {
+	if source == DataSourceFinnhub {
 		klines, err = parseFinnhubKlinesResponse(body, symbol, interval)
 		if err != nil {
 			log.Printf("❌ [Market] 解析Finnhub K线数据失败, symbol=%s, interval=%s, 响应内容: %s", symbol, interval, string(body))
 			return nil, fmt.Errorf("解析Finnhub JSON响应失败: %w", err)
 		}
-	} else if currentDataSource == DataSourceBybit {
+	} else if source == DataSourceBybit {
 		klines, err = parseBybitKlinesResponse(body, symbol, interval)
 		if err != nil {
 			log.Printf("❌ [Market] 解析Bybit K线数据失败, symbol=%s, interval=%s, 响应内容: %s", symbol, interval, string(body))
 			return nil, fmt.Errorf("解析Bybit JSON响应失败: %w", err)
 		}
-	} else if currentDataSource == DataSourceHyperliquid {
+	} else if source == DataSourceHyperliquid {
 		var hlKlines []HyperliquidCandle
 		err = json.Unmarshal(body, &hlKlines)
 		if err != nil {
@@ -537,12 +577,13 @@ func parseKline(kr KlineResponse) (Kline, error) {
 }
 
 func (c *APIClient) GetCurrentPrice(symbol string) (float64, error) {
-	cfg := GetDataSourceConfig()
+	source := c.dataSource()
+	cfg := dataSourceConfigFor(source)
 	var url string
 	var req *http.Request
 	var err error
 
-	switch currentDataSource {
+	switch source {
 	case DataSourceFinnhub:
 		// Finnhub: /api/v1/quote?symbol=BINANCE:BTCUSDT&token=API_KEY
 		if cfg.APIKey == "" {
@@ -596,7 +637,7 @@ func (c *APIClient) GetCurrentPrice(symbol string) (float64, error) {
 	}
 
 	var price float64
-	if currentDataSource == DataSourceFinnhub {
+	if source == DataSourceFinnhub {
 		var response struct {
 			C  float64 `json:"c"`  // Current price
 			H  float64 `json:"h"`  // High
@@ -613,7 +654,7 @@ func (c *APIClient) GetCurrentPrice(symbol string) (float64, error) {
 			return 0, fmt.Errorf("Finnhub API返回的价格为0")
 		}
 		price = response.C
-	} else if currentDataSource == DataSourceBybit {
+	} else if source == DataSourceBybit {
 		var response struct {
 			RetCode int    `json:"retCode"`
 			RetMsg  string `json:"retMsg"`
@@ -634,7 +675,7 @@ func (c *APIClient) GetCurrentPrice(symbol string) (float64, error) {
 		if err != nil {
 			return 0, err
 		}
-	} else if currentDataSource == DataSourceHyperliquid {
+	} else if source == DataSourceHyperliquid {
 		var allMids HyperliquidAllMids
 		err = json.Unmarshal(body, &allMids)
 		if err != nil {
@@ -670,3 +711,70 @@ func (c *APIClient) GetCurrentPrice(symbol string) (float64, error) {
 
 	return price, nil
 }
+
+// GetBookTicker 获取最优买卖价（用于开仓前检查价差）
+func (c *APIClient) GetBookTicker(symbol string) (*BookTicker, error) {
+	source := c.dataSource()
+	url, err := bookTickerURL(source, symbol)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	return parseBookTicker(source, symbol, body)
+}
+
+// parseBookTicker 解析盘口数据（Binance/Binance.US 的 bookTicker 或 Bybit 的 tickers）
+func parseBookTicker(source DataSource, symbol string, body []byte) (*BookTicker, error) {
+	var bidStr, askStr string
+	if source == DataSourceBybit {
+		var response struct {
+			RetCode int    `json:"retCode"`
+			RetMsg  string `json:"retMsg"`
+			Result  struct {
+				List []struct {
+					Bid1Price string `json:"bid1Price"`
+					Ask1Price string `json:"ask1Price"`
+				} `json:"list"`
+			} `json:"result"`
+		}
+		if err := json.Unmarshal(body, &response); err != nil {
+			return nil, err
+		}
+		if response.RetCode != 0 || len(response.Result.List) == 0 {
+			return nil, fmt.Errorf("Bybit API错误: %s", response.RetMsg)
+		}
+		bidStr, askStr = response.Result.List[0].Bid1Price, response.Result.List[0].Ask1Price
+	} else {
+		var response struct {
+			BidPrice string `json:"bidPrice"`
+			AskPrice string `json:"askPrice"`
+		}
+		if err := json.Unmarshal(body, &response); err != nil {
+			return nil, err
+		}
+		bidStr, askStr = response.BidPrice, response.AskPrice
+	}
+
+	bid, err := strconv.ParseFloat(bidStr, 64)
+	if err != nil {
+		return nil, fmt.Errorf("解析 %s 买一价失败: %w", symbol, err)
+	}
+	ask, err := strconv.ParseFloat(askStr, 64)
+	if err != nil {
+		return nil, fmt.Errorf("解析 %s 卖一价失败: %w", symbol, err)
+	}
+	if bid <= 0 || ask <= 0 || ask < bid {
+		return nil, fmt.Errorf("%s 盘口数据异常: 买一 %.8f / 卖一 %.8f", symbol, bid, ask)
+	}
+	return &BookTicker{Symbol: symbol, BidPrice: bid, AskPrice: ask}, nil
+}
@@ -0,0 +1,36 @@
+package market
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"aspen/httpclient"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestNewAPIClient_UsesConfiguredPool 测试行情客户端使用配置的连接池参数，配置代理时单独的传输层参数相同
+func TestNewAPIClient_UsesConfiguredPool(t *testing.T) {
+	for _, key := range []string{"HTTPS_PROXY", "https_proxy", "HTTP_PROXY", "http_proxy"} {
+		t.Setenv(key, "")
+	}
+	httpclient.Configure(httpclient.PoolConfig{MaxIdleConns: 400, MaxIdleConnsPerHost: 40, IdleConnTimeout: 45 * time.Second})
+	t.Cleanup(func() { httpclient.Configure(httpclient.DefaultPoolConfig()) })
+
+	client := NewAPIClient()
+	transport, ok := client.client.Transport.(*http.Transport)
+	require.True(t, ok)
+	assert.Same(t, httpclient.SharedTransport(), transport)
+	assert.Equal(t, 400, transport.MaxIdleConns)
+	assert.Equal(t, 40, transport.MaxIdleConnsPerHost)
+	assert.Equal(t, 45*time.Second, transport.IdleConnTimeout)
+
+	t.Setenv("HTTPS_PROXY", "http://127.0.0.1:8888")
+	proxied, ok := NewAPIClient().client.Transport.(*http.Transport)
+	require.True(t, ok)
+	assert.NotSame(t, httpclient.SharedTransport(), proxied)
+	assert.NotNil(t, proxied.Proxy)
+	assert.Equal(t, 40, proxied.MaxIdleConnsPerHost)
+}
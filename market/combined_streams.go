@@ -5,8 +5,10 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/websocket"
@@ -15,18 +17,23 @@ import (
 type CombinedStreamsClient struct {
 	conn        *websocket.Conn
 	mu          sync.RWMutex
-	subscribers map[string]chan []byte
+	subscribers map[string][]chan []byte // 同一个流可以有多个订阅者，消息分发给每个订阅者
 	reconnect   bool
 	done        chan struct{}
-	batchSize   int // 每批订阅的流数量
+	batchSize   int                            // 每批订阅的流数量
+	klines      map[string]map[string]struct{} // 已订阅的K线：周期 -> 币种（重连后重新订阅）
+	retryDelay  time.Duration                  // 断线后等待多久重连
+	lastMessage atomic.Int64                   // 最近一次收到消息的时间（Unix 纳秒，0 表示尚未收到）
 }
 
 func NewCombinedStreamsClient(batchSize int) *CombinedStreamsClient {
 	return &CombinedStreamsClient{
-		subscribers: make(map[string]chan []byte),
+		subscribers: make(map[string][]chan []byte),
 		reconnect:   true,
 		done:        make(chan struct{}),
 		batchSize:   batchSize,
+		klines:      make(map[string]map[string]struct{}),
+		retryDelay:  3 * time.Second,
 	}
 }
 
@@ -63,18 +70,85 @@ func (c *CombinedStreamsClient) Connect() error {
 	return nil
 }
 
-// BatchSubscribeKlines 批量订阅K线
+// BatchSubscribeKlines 批量订阅K线（订阅成功后记录下来，断线重连后自动重新订阅）
 func (c *CombinedStreamsClient) BatchSubscribeKlines(symbols []string, interval string) error {
+	if err := c.batchKlines(symbols, interval, true); err != nil {
+		return err
+	}
+	c.rememberKlines(symbols, interval, true)
+	return nil
+}
+
+// BatchUnsubscribeKlines 批量取消订阅K线（取消订阅请求发送失败时重连后也不再重新订阅）
+func (c *CombinedStreamsClient) BatchUnsubscribeKlines(symbols []string, interval string) error {
+	c.rememberKlines(symbols, interval, false)
+	return c.batchKlines(symbols, interval, false)
+}
+
+// rememberKlines 记录（subscribe=false 时删除）已订阅的K线
+func (c *CombinedStreamsClient) rememberKlines(symbols []string, interval string, subscribe bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	set := c.klines[interval]
+	if set == nil {
+		if !subscribe {
+			return
+		}
+		set = make(map[string]struct{})
+		c.klines[interval] = set
+	}
+	for _, symbol := range symbols {
+		if subscribe {
+			set[symbol] = struct{}{}
+		} else {
+			delete(set, symbol)
+		}
+	}
+	if len(set) == 0 {
+		delete(c.klines, interval)
+	}
+}
+
+// subscribedKlines 已订阅K线的快照：周期 -> 按字母排序的币种
+func (c *CombinedStreamsClient) subscribedKlines() map[string][]string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	result := make(map[string][]string, len(c.klines))
+	for interval, set := range c.klines {
+		symbols := make([]string, 0, len(set))
+		for symbol := range set {
+			symbols = append(symbols, symbol)
+		}
+		sort.Strings(symbols)
+		result[interval] = symbols
+	}
+	return result
+}
+
+// resubscribeKlines 重新发送所有已记录的K线订阅（新连接上没有任何订阅，不重新订阅的话数据会静默中断）
+func (c *CombinedStreamsClient) resubscribeKlines() {
+	for interval, symbols := range c.subscribedKlines() {
+		log.Printf("🔁 [WebSocket] 重新订阅 %s K线, 数量: %d", interval, len(symbols))
+		if err := c.batchKlines(symbols, interval, true); err != nil {
+			log.Printf("⚠️  重新订阅 %s K线失败: %v", interval, err)
+		}
+	}
+}
+
+// batchKlines 分批发送K线订阅/取消订阅请求
+func (c *CombinedStreamsClient) batchKlines(symbols []string, interval string, subscribe bool) error {
 	// 将symbols分批处理
 	batches := c.splitIntoBatches(symbols, c.batchSize)
 
 	for i, batch := range batches {
-		log.Printf("订阅第 %d 批, 数量: %d", i+1, len(batch))
+		log.Printf("%s第 %d 批, 数量: %d", subscribeAction(subscribe), i+1, len(batch))
 
 		if GetCurrentDataSource() == DataSourceBybit {
 			// Bybit 使用不同的订阅格式
-			if err := c.subscribeBybitKlines(batch, interval); err != nil {
-				return fmt.Errorf("第 %d 批订阅失败: %v", i+1, err)
+			if err := c.subscribeBybitKlines(batch, interval, subscribe); err != nil {
+				return fmt.Errorf("第 %d 批%s失败: %v", i+1, subscribeAction(subscribe), err)
 			}
 		} else if GetCurrentDataSource() == DataSourceHyperliquid {
 			// Hyperliquid specific subscription
@@ -85,8 +159,12 @@ func (c *CombinedStreamsClient) BatchSubscribeKlines(symbols []string, interval
 				if len(symbol) > 4 && symbol[len(symbol)-4:] == "USDT" {
 					hlSymbol = symbol[:len(symbol)-4]
 				}
+				method := "subscribe"
+				if !subscribe {
+					method = "unsubscribe"
+				}
 				msg := map[string]interface{}{
-					"method": "subscribe",
+					"method": method,
 					"subscription": map[string]string{
 						"type":     "candle",
 						"coin":     hlSymbol,
@@ -104,8 +182,14 @@ func (c *CombinedStreamsClient) BatchSubscribeKlines(symbols []string, interval
 				streams[j] = fmt.Sprintf("%s@kline_%s", strings.ToLower(symbol), interval)
 			}
 
-			if err := c.subscribeStreams(streams); err != nil {
-				return fmt.Errorf("第 %d 批订阅失败: %v", i+1, err)
+			var sendErr error
+			if subscribe {
+				sendErr = c.subscribeStreams(streams)
+			} else {
+				sendErr = c.unsubscribeStreams(streams)
+			}
+			if sendErr != nil {
+				return fmt.Errorf("第 %d 批%s失败: %v", i+1, subscribeAction(subscribe), sendErr)
 			}
 		}
 
@@ -118,8 +202,16 @@ func (c *CombinedStreamsClient) BatchSubscribeKlines(symbols []string, interval
 	return nil
 }
 
-// subscribeBybitKlines 订阅 Bybit K线数据
-func (c *CombinedStreamsClient) subscribeBybitKlines(symbols []string, interval string) error {
+// subscribeAction 订阅/取消订阅（用于日志）
+func subscribeAction(subscribe bool) string {
+	if subscribe {
+		return "订阅"
+	}
+	return "取消订阅"
+}
+
+// subscribeBybitKlines 订阅（subscribe=false 时取消订阅）Bybit K线数据
+func (c *CombinedStreamsClient) subscribeBybitKlines(symbols []string, interval string, subscribe bool) error {
 	// Bybit 间隔格式转换: 3m -> 3, 4h -> 240
 	bybitInterval := convertIntervalToBybit(interval)
 
@@ -129,8 +221,12 @@ func (c *CombinedStreamsClient) subscribeBybitKlines(symbols []string, interval
 		args[i] = fmt.Sprintf("kline.%s.%s", bybitInterval, symbol)
 	}
 
+	op := "subscribe"
+	if !subscribe {
+		op = "unsubscribe"
+	}
 	subscribeMsg := map[string]interface{}{
-		"op":   "subscribe",
+		"op":   op,
 		"args": args,
 	}
 
@@ -141,7 +237,7 @@ func (c *CombinedStreamsClient) subscribeBybitKlines(symbols []string, interval
 		return fmt.Errorf("WebSocket未连接")
 	}
 
-	log.Printf("📡 [Bybit] 订阅流: %v", args)
+	log.Printf("📡 [Bybit] %s流: %v", subscribeAction(subscribe), args)
 	return c.conn.WriteJSON(subscribeMsg)
 }
 
@@ -179,6 +275,25 @@ func (c *CombinedStreamsClient) subscribeStreams(streams []string) error {
 	return c.conn.WriteJSON(subscribeMsg)
 }
 
+// unsubscribeStreams 取消订阅多个流（Binance 格式）
+func (c *CombinedStreamsClient) unsubscribeStreams(streams []string) error {
+	unsubscribeMsg := map[string]interface{}{
+		"method": "UNSUBSCRIBE",
+		"params": streams,
+		"id":     time.Now().UnixNano(),
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.conn == nil {
+		return fmt.Errorf("WebSocket未连接")
+	}
+
+	log.Printf("📡 [Binance] 取消订阅流: %v", streams)
+	return c.conn.WriteJSON(unsubscribeMsg)
+}
+
 func (c *CombinedStreamsClient) sendJSON(msg interface{}) error {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
@@ -217,16 +332,28 @@ func (c *CombinedStreamsClient) readMessages() {
 
 			// 记录消息指标
 			wsMetrics.RecordMessage()
+			c.lastMessage.Store(time.Now().UnixNano())
 
 			c.handleCombinedMessage(message)
 		}
 	}
 }
 
+// LastMessageAt 最近一次收到消息的时间（尚未收到时为零值）
+func (c *CombinedStreamsClient) LastMessageAt() time.Time {
+	ns := c.lastMessage.Load()
+	if ns == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, ns)
+}
+
 func (c *CombinedStreamsClient) handleCombinedMessage(message []byte) {
-	if GetCurrentDataSource() == DataSourceBybit {
+	// 切换到备用数据源后 WebSocket 仍连接主数据源，按主数据源的格式解析
+	source := dataFailover.wsSource()
+	if source == DataSourceBybit {
 		c.handleBybitMessage(message)
-	} else if GetCurrentDataSource() == DataSourceHyperliquid {
+	} else if source == DataSourceHyperliquid {
 		c.handleHyperliquidMessage(message)
 	} else {
 		c.handleBinanceMessage(message)
@@ -260,11 +387,7 @@ func (c *CombinedStreamsClient) handleHyperliquidMessage(message []byte) {
 		symbol := coin + "USDT"
 		streamKey := fmt.Sprintf("%s@kline_%s", strings.ToLower(symbol), interval)
 
-		c.mu.RLock()
-		ch, exists := c.subscribers[streamKey]
-		c.mu.RUnlock()
-
-		if exists {
+		if c.hasSubscribers(streamKey) {
 			// Convert to Binance KlineWSData
 			t, _ := dataMap["t"].(float64)
 			o, _ := dataMap["o"].(string)
@@ -311,11 +434,7 @@ func (c *CombinedStreamsClient) handleHyperliquidMessage(message []byte) {
 			}
 
 			jsonBytes, _ := json.Marshal(binanceMsg)
-
-			select {
-			case ch <- jsonBytes:
-			default:
-			}
+			c.publish(streamKey, jsonBytes)
 		}
 	}
 }
@@ -332,17 +451,7 @@ func (c *CombinedStreamsClient) handleBinanceMessage(message []byte) {
 		return
 	}
 
-	c.mu.RLock()
-	ch, exists := c.subscribers[combinedMsg.Stream]
-	c.mu.RUnlock()
-
-	if exists {
-		select {
-		case ch <- combinedMsg.Data:
-		default:
-			log.Printf("订阅者通道已满: %s", combinedMsg.Stream)
-		}
-	}
+	c.publish(combinedMsg.Stream, combinedMsg.Data)
 }
 
 // handleBybitMessage 处理 Bybit 格式的消息
@@ -378,22 +487,14 @@ func (c *CombinedStreamsClient) handleBybitMessage(message []byte) {
 			binanceInterval := convertBybitIntervalToBinance(interval)
 			stream := fmt.Sprintf("%s@kline_%s", symbol, binanceInterval)
 
-			c.mu.RLock()
-			ch, exists := c.subscribers[stream]
-			c.mu.RUnlock()
-
-			if exists {
+			if c.hasSubscribers(stream) {
 				// Bybit 的 data 是数组，需要提取第一个元素
 				var dataArray []json.RawMessage
 				if err := json.Unmarshal(bybitMsg.Data, &dataArray); err == nil && len(dataArray) > 0 {
 					// 转换为 Binance 格式的 Kline 数据（传递间隔信息）
 					binanceData := c.convertBybitKlineToBinance(dataArray[0], symbol, binanceInterval)
 					if binanceData != nil {
-						select {
-						case ch <- binanceData:
-						default:
-							log.Printf("订阅者通道已满: %s", stream)
-						}
+						c.publish(stream, binanceData)
 					}
 				}
 			}
@@ -487,12 +588,76 @@ func getIntervalMs(interval string) int64 {
 	return 180000 // 默认3分钟
 }
 
-func (c *CombinedStreamsClient) AddSubscriber(stream string, bufferSize int) <-chan []byte {
+// Subscriber 流的一个订阅者（AddSubscriber 返回的句柄，传给 RemoveSubscriber 时只移除该订阅者）
+type Subscriber struct {
+	Stream string
+	C      <-chan []byte // 接收消息的通道，移除订阅或关闭客户端后关闭
+	ch     chan []byte
+}
+
+// newSubscriber 创建订阅者
+func newSubscriber(stream string, bufferSize int) *Subscriber {
 	ch := make(chan []byte, bufferSize)
+	return &Subscriber{Stream: stream, C: ch, ch: ch}
+}
+
+// removeSubscriber 从订阅者列表中删除 sub 并关闭其通道（不在列表中时说明已移除，不重复关闭）
+func removeSubscriber(subscribers map[string][]chan []byte, sub *Subscriber) {
+	chans := subscribers[sub.Stream]
+	for i, ch := range chans {
+		if ch != sub.ch {
+			continue
+		}
+		close(ch)
+		chans = append(chans[:i:i], chans[i+1:]...)
+		if len(chans) == 0 {
+			delete(subscribers, sub.Stream)
+		} else {
+			subscribers[sub.Stream] = chans
+		}
+		return
+	}
+}
+
+// AddSubscriber 为流注册一个新的订阅者（同一个流已有订阅者时追加，不影响已有订阅者）
+func (c *CombinedStreamsClient) AddSubscriber(stream string, bufferSize int) *Subscriber {
+	sub := newSubscriber(stream, bufferSize)
 	c.mu.Lock()
-	c.subscribers[stream] = ch
+	c.subscribers[stream] = append(c.subscribers[stream], sub.ch)
 	c.mu.Unlock()
-	return ch
+	return sub
+}
+
+// RemoveSubscriber 移除该订阅者并关闭其通道，同一个流的其他订阅者不受影响
+func (c *CombinedStreamsClient) RemoveSubscriber(sub *Subscriber) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	removeSubscriber(c.subscribers, sub)
+}
+
+// hasSubscribers 流是否有订阅者
+func (c *CombinedStreamsClient) hasSubscribers(stream string) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return len(c.subscribers[stream]) > 0
+}
+
+// publish 将消息分发给流的每个订阅者（持有读锁发送，避免与 RemoveSubscriber 关闭通道竞争）
+func (c *CombinedStreamsClient) publish(stream string, data []byte) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	fanOut(stream, c.subscribers[stream], data)
+}
+
+// fanOut 非阻塞地向每个订阅者通道发送消息，通道已满的订阅者丢弃本条消息，不影响其他订阅者
+func fanOut(stream string, subscribers []chan []byte, data []byte) {
+	for _, ch := range subscribers {
+		select {
+		case ch <- data:
+		default:
+			log.Printf("订阅者通道已满: %s", stream)
+		}
+	}
 }
 
 func (c *CombinedStreamsClient) handleReconnect() {
@@ -504,12 +669,14 @@ func (c *CombinedStreamsClient) handleReconnect() {
 	wsMetrics.RecordReconnect()
 
 	log.Println("组合流尝试重新连接...")
-	time.Sleep(3 * time.Second)
+	time.Sleep(c.retryDelay)
 
 	if err := c.Connect(); err != nil {
 		log.Printf("组合流重新连接失败: %v", err)
 		go c.handleReconnect()
+		return
 	}
+	c.resubscribeKlines()
 }
 
 func (c *CombinedStreamsClient) Close() {
@@ -524,8 +691,10 @@ func (c *CombinedStreamsClient) Close() {
 		c.conn = nil
 	}
 
-	for stream, ch := range c.subscribers {
-		close(ch)
+	for stream, chans := range c.subscribers {
+		for _, ch := range chans {
+			close(ch)
+		}
 		delete(c.subscribers, stream)
 	}
 }
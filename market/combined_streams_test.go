@@ -0,0 +1,255 @@
+package market
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// TestCombinedStreamsClient_FanOut 测试同一个流的多个订阅者都能收到每条消息
+func TestCombinedStreamsClient_FanOut(t *testing.T) {
+	c := NewCombinedStreamsClient(10)
+	stream := klineStream("BTCUSDT", "3m")
+	first := c.AddSubscriber(stream, 10)
+	second := c.AddSubscriber(stream, 10)
+	other := c.AddSubscriber(klineStream("ETHUSDT", "3m"), 10)
+
+	c.handleBinanceMessage([]byte(`{"stream":"btcusdt@kline_3m","data":{"e":"kline","s":"BTCUSDT"}}`))
+	c.handleBinanceMessage([]byte(`{"stream":"btcusdt@kline_3m","data":{"e":"kline","s":"BTCUSDT","E":2}}`))
+
+	for name, sub := range map[string]*Subscriber{"first": first, "second": second} {
+		if got := len(sub.C); got != 2 {
+			t.Fatalf("%s 订阅者收到 %d 条消息，期望 2 条", name, got)
+		}
+		if msg := string(<-sub.C); msg != `{"e":"kline","s":"BTCUSDT"}` {
+			t.Errorf("%s 订阅者收到的第一条消息 = %s", name, msg)
+		}
+	}
+	if len(other.C) != 0 {
+		t.Error("其他流的订阅者不应收到消息")
+	}
+}
+
+// TestCombinedStreamsClient_RemoveSubscriberKeepsOthers 测试同一个流的两个订阅者中一个取消订阅时只关闭它自己的通道，
+// 另一个订阅者继续收到消息
+func TestCombinedStreamsClient_RemoveSubscriberKeepsOthers(t *testing.T) {
+	c := NewCombinedStreamsClient(10)
+	stream := klineStream("BTCUSDT", "3m")
+	first := c.AddSubscriber(stream, 10)
+	second := c.AddSubscriber(stream, 10)
+
+	c.RemoveSubscriber(first)
+	if _, ok := <-first.C; ok {
+		t.Error("移除的订阅者通道应关闭")
+	}
+	c.RemoveSubscriber(first) // 重复移除不应重复关闭通道
+
+	c.handleBinanceMessage([]byte(`{"stream":"btcusdt@kline_3m","data":{"e":"kline","s":"BTCUSDT"}}`))
+	if got := len(second.C); got != 1 {
+		t.Fatalf("另一个订阅者收到 %d 条消息，期望 1 条", got)
+	}
+	if !c.hasSubscribers(stream) {
+		t.Error("另一个订阅者仍应保留")
+	}
+
+	c.RemoveSubscriber(second)
+	<-second.C // 剩余的一条消息
+	if _, ok := <-second.C; ok {
+		t.Error("移除后通道应关闭")
+	}
+	if c.hasSubscribers(stream) {
+		t.Error("所有订阅者移除后不应还有订阅者")
+	}
+
+	// 关闭客户端后再移除订阅者不会重复关闭通道
+	third := c.AddSubscriber(stream, 10)
+	c.Close()
+	c.RemoveSubscriber(third)
+}
+
+// TestCombinedStreamsClient_FullSubscriberDoesNotBlockOthers 测试某个订阅者通道已满时其他订阅者仍能收到消息
+func TestCombinedStreamsClient_FullSubscriberDoesNotBlockOthers(t *testing.T) {
+	c := NewCombinedStreamsClient(10)
+	stream := klineStream("BTCUSDT", "3m")
+	slow := c.AddSubscriber(stream, 1)
+	fast := c.AddSubscriber(stream, 10)
+
+	for i := 0; i < 3; i++ {
+		c.publish(stream, []byte("kline"))
+	}
+	if len(slow.C) != 1 {
+		t.Errorf("已满的订阅者应只保留 1 条消息，实际 %d 条", len(slow.C))
+	}
+	if len(fast.C) != 3 {
+		t.Errorf("其他订阅者应收到全部 3 条消息，实际 %d 条", len(fast.C))
+	}
+}
+
+// TestWSClient_FanOut 测试单流客户端同一个流的多个订阅者都能收到每条消息
+func TestWSClient_FanOut(t *testing.T) {
+	w := NewWSClient()
+	first := w.AddSubscriber("btcusdt@ticker", 10)
+	second := w.AddSubscriber("btcusdt@ticker", 10)
+
+	w.handleMessage([]byte(`{"stream":"btcusdt@ticker","data":{"c":"100"}}`))
+
+	for name, sub := range map[string]*Subscriber{"first": first, "second": second} {
+		if got := len(sub.C); got != 1 {
+			t.Errorf("%s 订阅者收到 %d 条消息，期望 1 条", name, got)
+		}
+	}
+
+	w.RemoveSubscriber(first)
+	w.handleMessage([]byte(`{"stream":"btcusdt@ticker","data":{"c":"101"}}`))
+	if got := len(second.C); got != 2 {
+		t.Errorf("另一个订阅者收到 %d 条消息，期望 2 条", got)
+	}
+}
+
+// wsTestServer 测试用 WebSocket 服务器：把每个连接收到的消息按连接序号发送到 received
+type wsTestServer struct {
+	received chan wsTestMessage
+	conns    int32
+}
+
+type wsTestMessage struct {
+	conn int32
+	body map[string]interface{}
+}
+
+// newWSTestServer 启动测试 WebSocket 服务器并把当前数据源的 WebSocket 地址指向它（测试结束后恢复）
+func newWSTestServer(t *testing.T) *wsTestServer {
+	t.Helper()
+	s := &wsTestServer{received: make(chan wsTestMessage, 100)}
+	upgrader := websocket.Upgrader{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		id := atomic.AddInt32(&s.conns, 1)
+		for {
+			var body map[string]interface{}
+			if err := conn.ReadJSON(&body); err != nil {
+				return
+			}
+			s.received <- wsTestMessage{conn: id, body: body}
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	prevSource := currentDataSource
+	currentDataSource = DataSourceBinance
+	cfg := dataSourceConfigs[DataSourceBinance]
+	prevURL := cfg.WSStreamURL
+	cfg.WSStreamURL = "ws" + strings.TrimPrefix(srv.URL, "http")
+	t.Cleanup(func() {
+		currentDataSource = prevSource
+		cfg.WSStreamURL = prevURL
+	})
+	return s
+}
+
+// next 等待下一条消息
+func (s *wsTestServer) next(t *testing.T) wsTestMessage {
+	t.Helper()
+	select {
+	case msg := <-s.received:
+		return msg
+	case <-time.After(5 * time.Second):
+		t.Fatal("等待 WebSocket 消息超时")
+		return wsTestMessage{}
+	}
+}
+
+// subscribedParams 订阅消息中的流
+func subscribedParams(t *testing.T, msg wsTestMessage) []string {
+	t.Helper()
+	if msg.body["method"] != "SUBSCRIBE" {
+		t.Fatalf("期望 SUBSCRIBE 消息，实际 %v", msg.body)
+	}
+	var params []string
+	for _, p := range msg.body["params"].([]interface{}) {
+		params = append(params, p.(string))
+	}
+	return params
+}
+
+// TestCombinedStreamsClient_ResubscribesAfterReconnect 测试底层连接断开重连后自动重新发送K线订阅，已取消订阅的币种不再订阅
+func TestCombinedStreamsClient_ResubscribesAfterReconnect(t *testing.T) {
+	server := newWSTestServer(t)
+	c := NewCombinedStreamsClient(10)
+	c.retryDelay = 10 * time.Millisecond
+	if err := c.Connect(); err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	defer c.Close()
+
+	if err := c.BatchSubscribeKlines([]string{"BTCUSDT", "ETHUSDT", "SOLUSDT"}, "3m"); err != nil {
+		t.Fatalf("BatchSubscribeKlines: %v", err)
+	}
+	server.next(t)
+	if err := c.BatchUnsubscribeKlines([]string{"SOLUSDT"}, "3m"); err != nil {
+		t.Fatalf("BatchUnsubscribeKlines: %v", err)
+	}
+	if msg := server.next(t); msg.body["method"] != "UNSUBSCRIBE" {
+		t.Fatalf("取消订阅应只发送 UNSUBSCRIBE 消息，实际 %v", msg.body)
+	}
+
+	c.mu.RLock()
+	c.conn.Close()
+	c.mu.RUnlock()
+
+	msg := server.next(t)
+	if msg.conn != 2 {
+		t.Fatalf("重新订阅应发送到新连接，实际连接 %d", msg.conn)
+	}
+	want := []string{"btcusdt@kline_3m", "ethusdt@kline_3m"}
+	if got := subscribedParams(t, msg); !reflect.DeepEqual(got, want) {
+		t.Errorf("重连后重新订阅 %v，期望 %v", got, want)
+	}
+}
+
+// TestWSClient_ResubscribesAfterReconnect 测试单流客户端底层连接断开重连后自动重新发送订阅
+func TestWSClient_ResubscribesAfterReconnect(t *testing.T) {
+	server := newWSTestServer(t)
+	w := NewWSClient()
+	w.retryDelay = 10 * time.Millisecond
+	if err := w.Connect(); err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	defer w.Close()
+
+	if err := w.SubscribeKline("btcusdt", "3m"); err != nil {
+		t.Fatalf("SubscribeKline: %v", err)
+	}
+	if err := w.SubscribeTicker("btcusdt"); err != nil {
+		t.Fatalf("SubscribeTicker: %v", err)
+	}
+	server.next(t)
+	server.next(t)
+
+	w.mu.RLock()
+	w.conn.Close()
+	w.mu.RUnlock()
+
+	var got []string
+	for i := 0; i < 2; i++ {
+		msg := server.next(t)
+		if msg.conn != 2 {
+			t.Fatalf("重新订阅应发送到新连接，实际连接 %d", msg.conn)
+		}
+		got = append(got, subscribedParams(t, msg)...)
+	}
+	want := []string{"btcusdt@kline_3m", "btcusdt@ticker"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("重连后重新订阅 %v，期望 %v", got, want)
+	}
+}
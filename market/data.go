@@ -25,22 +25,59 @@ var (
 	frCacheTTL     = 1 * time.Hour
 )
 
+// defaultFundingRateMaxAge 资金费率的默认最大有效期（缓存有效期的 2 倍，即至少一次刷新失败）
+const defaultFundingRateMaxAge = 2 * time.Hour
+
+var fundingRateMaxAge = defaultFundingRateMaxAge
+
+// SetFundingRateMaxAge 设置资金费率的最大有效期（分钟，非正数使用默认值 120），超过时提示词中标注为过期
+func SetFundingRateMaxAge(minutes int) {
+	if minutes <= 0 {
+		fundingRateMaxAge = defaultFundingRateMaxAge
+		return
+	}
+	fundingRateMaxAge = time.Duration(minutes) * time.Minute
+}
+
+// 日内序列保留的数据点数（影响提示词长度）
+const (
+	defaultIntradaySeriesLength = 10
+	maxIntradaySeriesLength     = 100 // 与K线缓存数量一致，更多的点没有数据
+)
+
+var intradaySeriesLength = defaultIntradaySeriesLength
+
+// SetIntradaySeriesLength 设置日内序列保留的数据点数（非正数使用默认值 10，最多 100）
+func SetIntradaySeriesLength(n int) {
+	switch {
+	case n <= 0:
+		n = defaultIntradaySeriesLength
+	case n > maxIntradaySeriesLength:
+		log.Printf("⚠️  [Market] 日内序列长度 %d 超过K线缓存数量，使用 %d", n, maxIntradaySeriesLength)
+		n = maxIntradaySeriesLength
+	}
+	intradaySeriesLength = n
+}
+
 // Get 获取指定代币的市场数据
 func Get(symbol string) (*Data, error) {
+	return getCached(Normalize(symbol), IndicatorParams{})
+}
+
+// getCached 从 WebSocket 缓存的K线组装市场数据
+func getCached(symbol string, params IndicatorParams) (*Data, error) {
 	var klines3m, klines4h, klines30m []Kline
 	var err error
-	// 标准化symbol
-	symbol = Normalize(symbol)
 	// 获取3分钟K线数据 (最近10个)
 	klines3m, err = WSMonitorCli.GetCurrentKlines(symbol, "3m") // 多获取一些用于计算
 	if err != nil {
-		return nil, fmt.Errorf("获取3分钟K线失败: %v", err)
+		return nil, fmt.Errorf("获取3分钟K线失败: %w", err)
 	}
 
 	// 获取4小时K线数据 (最近10个)
 	klines4h, err = WSMonitorCli.GetCurrentKlines(symbol, "4h") // 多获取用于计算指标
 	if err != nil {
-		return nil, fmt.Errorf("获取4小时K线失败: %v", err)
+		return nil, fmt.Errorf("获取4小时K线失败: %w", err)
 	}
 
 	// 获取30分钟K线数据（择时用）
@@ -50,6 +87,101 @@ func Get(symbol string) (*Data, error) {
 		klines30m = []Kline{}
 	}
 
+	return buildData(GetCurrentDataSource(), symbol, klines3m, klines4h, klines30m, params)
+}
+
+// GetFrom 从指定数据源获取市场数据（交易员单独配置了数据源时使用）。
+// 数据源为空或与全局数据源相同时使用 WebSocket 缓存，否则通过该数据源的 REST 接口获取K线
+func GetFrom(source DataSource, symbol string) (*Data, error) {
+	return getFrom(source, Normalize(symbol), IndicatorParams{})
+}
+
+// GetWithIndicators 从指定数据源获取市场数据，并按交易员配置的指标周期计算基础指标
+func GetWithIndicators(source DataSource, symbol string, params IndicatorParams) (*Data, error) {
+	if params.IsDefault() {
+		return GetFrom(source, symbol)
+	}
+	return getFrom(source, Normalize(symbol), params)
+}
+
+// getFrom 全局数据源使用 WebSocket 缓存，并统计主数据源的连续数据源级故障（达到阈值后切换到备用数据源）；
+// 已切换到备用数据源时，原本使用主数据源的请求改为通过备用数据源的 REST 接口获取（WebSocket 缓存仍来自主数据源）
+func getFrom(source DataSource, symbol string, params IndicatorParams) (*Data, error) {
+	if failover := dataFailover.redirect(source); failover != "" {
+		return getFromREST(failover, symbol, params)
+	}
+	if source != "" && source != GetCurrentDataSource() {
+		return getFromREST(source, symbol, params)
+	}
+
+	var data *Data
+	var err error
+	if params.IsDefault() {
+		data, err = Get(symbol)
+	} else {
+		data, err = getCached(symbol, params)
+	}
+	outcome := err
+	if err == nil && wsStale(time.Now()) {
+		outcome = errWSStale // 缓存仍可读取但推送已中断：数据照常返回，同时计入数据源故障
+	}
+	dataFailover.record(outcome)
+	return data, err
+}
+
+// GetSnapshot 获取单个币种的市场数据快照（用于临时查询，如币种研究）：
+// WebSocket 缓存已有该币种时直接使用缓存（已切换到备用数据源时除外），否则只通过 REST 接口获取，不动态订阅 WebSocket 流
+func GetSnapshot(symbol string) (*Data, error) {
+	symbol = Normalize(symbol)
+	if WSMonitorCli != nil && WSMonitorCli.HasKlines(symbol) && dataFailover.redirect("") == "" {
+		return Get(symbol)
+	}
+	return getFromREST(GetCurrentDataSource(), symbol, IndicatorParams{})
+}
+
+// getFromREST 通过数据源的 REST 接口获取K线并组装市场数据
+func getFromREST(source DataSource, symbol string, params IndicatorParams) (*Data, error) {
+	client := NewAPIClientFor(source)
+	klines3m, err := client.GetKlines(symbol, "3m", 100)
+	if err != nil {
+		return nil, fmt.Errorf("获取3分钟K线失败 (%s): %v", source, err)
+	}
+	klines4h, err := client.GetKlines(symbol, "4h", 100)
+	if err != nil {
+		return nil, fmt.Errorf("获取4小时K线失败 (%s): %v", source, err)
+	}
+	klines30m, err := client.GetKlines(symbol, "30m", 100)
+	if err != nil {
+		log.Printf("获取30分钟K线失败 (%s): %v", source, err)
+		klines30m = []Kline{}
+	}
+
+	return buildData(source, symbol, klines3m, klines4h, klines30m, params)
+}
+
+// buildData 根据K线计算指标并组装市场数据（基础指标按 params 的周期计算，未配置的周期使用默认值）
+func buildData(source DataSource, symbol string, klines3m, klines4h, klines30m []Kline, params IndicatorParams) (*Data, error) {
+	data, err := buildIndicatorData(source, symbol, klines3m, klines4h, klines30m, params)
+	if err != nil {
+		return nil, err
+	}
+
+	// 获取OI和Funding Rate（数据源不支持时直接跳过，失败不影响整体，使用默认值）
+	oiData, fundingRate, fundingUpdatedAt := fetchDerivativesData(source, symbol)
+	oiUnavailable, fundingUnavailable := derivativesUnavailable(source)
+	if oiUnavailable {
+		oiData = nil
+	}
+	data.OpenInterest = oiData
+	data.FundingRate = fundingRate
+	data.FundingUpdatedAt = fundingUpdatedAt
+	data.NoOpenInterest = oiUnavailable
+	data.NoFundingRate = fundingUnavailable
+	return data, nil
+}
+
+// buildIndicatorData 只根据K线计算指标组装市场数据，不获取持仓量和资金费率
+func buildIndicatorData(source DataSource, symbol string, klines3m, klines4h, klines30m []Kline, params IndicatorParams) (*Data, error) {
 	// 检查数据是否为空
 	if len(klines3m) == 0 {
 		return nil, fmt.Errorf("3分钟K线数据为空")
@@ -59,10 +191,15 @@ func Get(symbol string) (*Data, error) {
 	}
 
 	// 计算当前指标 (基于3分钟最新数据)
+	p := params.WithDefaults()
 	currentPrice := klines3m[len(klines3m)-1].Close
-	currentEMA20 := calculateEMA(klines3m, 20)
+	currentEMA20 := calculateEMA(klines3m, p.EMAFast)
 	currentMACD := calculateMACD(klines3m)
-	currentRSI7 := calculateRSI(klines3m, 7)
+	currentRSI7 := calculateRSI(klines3m, p.RSIShort)
+	currentWilliamsR := calculateWilliamsR(klines3m, 14)
+	currentMFI := calculateMFI(klines3m, 14)
+	bollingerMiddle, bollingerUpper, bollingerLower := calculateBollingerBands(klines3m, 20, 2.0)
+	sarValue, sarTrend, sarFlipped := calculateParabolicSAR(klines3m, 0.02, 0.2)
 
 	// 计算价格变化百分比
 	// 1小时价格变化 = 20个3分钟K线前的价格
@@ -83,28 +220,18 @@ func Get(symbol string) (*Data, error) {
 		}
 	}
 
-	// 获取OI数据
-	oiData, err := getOpenInterestData(symbol)
-	if err != nil {
-		// OI失败不影响整体,使用默认值
-		oiData = &OIData{Latest: 0, Average: 0}
-	}
-
-	// 获取Funding Rate
-	fundingRate, _ := getFundingRate(symbol)
-
 	// 计算日内系列数据
-	intradayData := calculateIntradaySeries(klines3m)
+	intradayData := calculateIntradaySeriesN(klines3m, intradaySeriesLength, p)
 
 	// 计算长期数据
-	longerTermData := calculateLongerTermData(klines4h)
+	longerTermData := calculateLongerTermDataWith(klines4h, p)
 
 	// ——— 来自 Pine 脚本的新增指标计算（1—10） ———
-	currentTSI, currentTSISignal := calculateTSI(klines3m, 35, 35, 13)
-	tsi4h, tsi4hSignal := calculateTSI(klines4h, 35, 35, 13)
+	currentTSI, currentTSISignal := calculateTSI(klines3m, p.TSILong, p.TSIShort, p.TSISignal)
+	tsi4h, tsi4hSignal := calculateTSI(klines4h, p.TSILong, p.TSIShort, p.TSISignal)
 	var tsi30m, tsi30mSignal float64
 	if len(klines30m) > 0 {
-		tsi30m, tsi30mSignal = calculateTSI(klines30m, 35, 35, 13)
+		tsi30m, tsi30mSignal = calculateTSI(klines30m, p.TSILong, p.TSIShort, p.TSISignal)
 	}
 	kemadTrend, kemaVal, kemadATR := calculateKEMAD(klines3m)
 	vgbTrend, vgbAvg, vgbUpper, vgbLower, vgbScore := calculateVolatilityGaussianBands(klines3m, 20, 2.0)
@@ -130,10 +257,20 @@ func Get(symbol string) (*Data, error) {
 		CurrentEMA20:      currentEMA20,
 		CurrentMACD:       currentMACD,
 		CurrentRSI7:       currentRSI7,
-		OpenInterest:      oiData,
-		FundingRate:       fundingRate,
+		CurrentWilliamsR:  currentWilliamsR,
+		CurrentMFI:        currentMFI,
+		BollingerUpper:    bollingerUpper,
+		BollingerMiddle:   bollingerMiddle,
+		BollingerLower:    bollingerLower,
+		SARValue:          sarValue,
+		SARTrend:          sarTrend,
+		SARFlipped:        sarFlipped,
+		ZeroVolumeCandles: zeroVolumeStreak(klines3m),
+		LatestCandleAt:    klineOpenTime(klines3m[len(klines3m)-1].OpenTime),
+		SpotOnly:          dataSourceConfigFor(source).SpotOnly(),
 		IntradaySeries:    intradayData,
 		LongerTermContext: longerTermData,
+		Indicators:        p,
 		// 新增 1—10 指标汇总
 		CurrentTSI:            currentTSI,
 		CurrentTSISignal:      currentTSISignal,
@@ -301,19 +438,143 @@ func calculateATR(klines []Kline, period int) float64 {
 	return atr
 }
 
-// calculateIntradaySeries 计算日内系列数据
+// calculateWilliamsR 计算Williams %R：(最高价 - 收盘价) / (最高价 - 最低价) × -100，范围 [-100, 0]
+// 接近 0 表示收盘价位于区间高位（超买），接近 -100 表示位于区间低位（超卖）
+// 数据不足或区间无波动（最高价 == 最低价）时返回中性值 -50
+func calculateWilliamsR(klines []Kline, period int) float64 {
+	if period <= 0 || len(klines) < period {
+		return -50
+	}
+
+	window := klines[len(klines)-period:]
+	highest := window[0].High
+	lowest := window[0].Low
+	for _, k := range window[1:] {
+		highest = math.Max(highest, k.High)
+		lowest = math.Min(lowest, k.Low)
+	}
+
+	if highest == lowest {
+		return -50
+	}
+
+	close := window[len(window)-1].Close
+	wr := (highest - close) / (highest - lowest) * -100
+	return math.Max(-100, math.Min(0, wr))
+}
+
+// calculateMFI 计算资金流量指标MFI（成交量加权的RSI），范围 [0, 100]
+// 典型价格 = (最高价 + 最低价 + 收盘价) / 3，资金流 = 典型价格 × 成交量；
+// 典型价格上涨的K线计入正向资金流，下跌的计入负向资金流
+// 数据不足（少于 period+1 根K线）时返回 0，负向资金流为 0 时返回 100
+func calculateMFI(klines []Kline, period int) float64 {
+	if period <= 0 || len(klines) <= period {
+		return 0
+	}
+
+	typicalPrice := func(k Kline) float64 {
+		return (k.High + k.Low + k.Close) / 3
+	}
+
+	positiveFlow := 0.0
+	negativeFlow := 0.0
+	start := len(klines) - period
+	for i := start; i < len(klines); i++ {
+		tp := typicalPrice(klines[i])
+		prevTP := typicalPrice(klines[i-1])
+		flow := tp * klines[i].Volume
+		if tp > prevTP {
+			positiveFlow += flow
+		} else if tp < prevTP {
+			negativeFlow += flow
+		}
+	}
+
+	if negativeFlow == 0 {
+		return 100
+	}
+
+	moneyRatio := positiveFlow / negativeFlow
+	return 100 - 100/(1+moneyRatio)
+}
+
+// calculateParabolicSAR 计算抛物线转向指标（Wilder Parabolic SAR）
+// 递推：SAR(i) = SAR(i-1) + AF × (EP - SAR(i-1))，EP 为当前趋势的极值点（上升趋势最高价、下降趋势最低价），
+// 每创新极值 AF 增加 accStep，上限 accMax；上升趋势中 SAR 不高于前两根K线最低价，下降趋势中不低于前两根K线最高价。
+// 价格穿越 SAR 时反转：新 SAR 取原趋势的 EP，AF 重置。
+// 返回最新K线的 SAR、趋势（1=上升，-1=下降）和是否在最新K线发生反转；少于2根K线时返回 0, 0, false
+// accStep/accMax 非正时使用常用参数 0.02/0.2
+func calculateParabolicSAR(klines []Kline, accStep, accMax float64) (sar float64, trend int, flipped bool) {
+	if len(klines) < 2 {
+		return 0, 0, false
+	}
+	if accStep <= 0 {
+		accStep = 0.02
+	}
+	if accMax <= 0 {
+		accMax = 0.2
+	}
+
+	// 以前两根K线的收盘价确定初始趋势
+	var ep float64
+	if klines[1].Close >= klines[0].Close {
+		trend, sar, ep = 1, klines[0].Low, klines[0].High
+	} else {
+		trend, sar, ep = -1, klines[0].High, klines[0].Low
+	}
+	af := accStep
+
+	for i := 1; i < len(klines); i++ {
+		k := klines[i]
+		flipped = false
+		sar += af * (ep - sar)
+
+		if trend == 1 {
+			sar = math.Min(sar, klines[i-1].Low)
+			if i >= 2 {
+				sar = math.Min(sar, klines[i-2].Low)
+			}
+			if k.Low < sar {
+				trend, sar, ep, af, flipped = -1, ep, k.Low, accStep, true
+			} else if k.High > ep {
+				ep = k.High
+				af = math.Min(af+accStep, accMax)
+			}
+		} else {
+			sar = math.Max(sar, klines[i-1].High)
+			if i >= 2 {
+				sar = math.Max(sar, klines[i-2].High)
+			}
+			if k.High > sar {
+				trend, sar, ep, af, flipped = 1, ep, k.High, accStep, true
+			} else if k.Low < ep {
+				ep = k.Low
+				af = math.Min(af+accStep, accMax)
+			}
+		}
+	}
+	return sar, trend, flipped
+}
+
+// calculateIntradaySeries 计算日内系列数据（保留的数据点数由 SetIntradaySeriesLength 配置）
 func calculateIntradaySeries(klines []Kline) *IntradayData {
+	return calculateIntradaySeriesN(klines, intradaySeriesLength, DefaultIndicatorParams())
+}
+
+// calculateIntradaySeriesN 按指标周期 p 计算最近 length 个数据点的日内系列数据
+// 各指标只在K线数量满足其最小回看周期的数据点上计算，因此数据不足时指标序列可能短于 length
+func calculateIntradaySeriesN(klines []Kline, length int, p IndicatorParams) *IntradayData {
 	data := &IntradayData{
-		MidPrices:   make([]float64, 0, 10),
-		EMA20Values: make([]float64, 0, 10),
-		MACDValues:  make([]float64, 0, 10),
-		RSI7Values:  make([]float64, 0, 10),
-		RSI14Values: make([]float64, 0, 10),
-		Volume:      make([]float64, 0, 10),
+		MidPrices:   make([]float64, 0, length),
+		EMA20Values: make([]float64, 0, length),
+		MACDValues:  make([]float64, 0, length),
+		RSI7Values:  make([]float64, 0, length),
+		RSI14Values: make([]float64, 0, length),
+		Volume:      make([]float64, 0, length),
 	}
 
-	// 获取最近10个数据点
-	start := len(klines) - 10
+	// 获取最近 length 个数据点
+	start := len(klines) - length
 	if start < 0 {
 		start = 0
 	}
@@ -323,8 +584,8 @@ func calculateIntradaySeries(klines []Kline) *IntradayData {
 		data.Volume = append(data.Volume, klines[i].Volume)
 
 		// 计算每个点的EMA20
-		if i >= 19 {
-			ema20 := calculateEMA(klines[:i+1], 20)
+		if i >= p.EMAFast-1 {
+			ema20 := calculateEMA(klines[:i+1], p.EMAFast)
 			data.EMA20Values = append(data.EMA20Values, ema20)
 		}
 
@@ -335,36 +596,41 @@ func calculateIntradaySeries(klines []Kline) *IntradayData {
 		}
 
 		// 计算每个点的RSI
-		if i >= 7 {
-			rsi7 := calculateRSI(klines[:i+1], 7)
+		if i >= p.RSIShort {
+			rsi7 := calculateRSI(klines[:i+1], p.RSIShort)
 			data.RSI7Values = append(data.RSI7Values, rsi7)
 		}
-		if i >= 14 {
-			rsi14 := calculateRSI(klines[:i+1], 14)
+		if i >= p.RSILong {
+			rsi14 := calculateRSI(klines[:i+1], p.RSILong)
 			data.RSI14Values = append(data.RSI14Values, rsi14)
 		}
 	}
 
 	// 计算3m ATR14
-	data.ATR14 = calculateATR(klines, 14)
+	data.ATR14 = calculateATR(klines, p.ATRLong)
 
 	return data
 }
 
 // calculateLongerTermData 计算长期数据
 func calculateLongerTermData(klines []Kline) *LongerTermData {
+	return calculateLongerTermDataWith(klines, DefaultIndicatorParams())
+}
+
+// calculateLongerTermDataWith 按指标周期 p 计算长期数据
+func calculateLongerTermDataWith(klines []Kline, p IndicatorParams) *LongerTermData {
 	data := &LongerTermData{
 		MACDValues:  make([]float64, 0, 10),
 		RSI14Values: make([]float64, 0, 10),
 	}
 
 	// 计算EMA
-	data.EMA20 = calculateEMA(klines, 20)
-	data.EMA50 = calculateEMA(klines, 50)
+	data.EMA20 = calculateEMA(klines, p.EMAFast)
+	data.EMA50 = calculateEMA(klines, p.EMASlow)
 
 	// 计算ATR
-	data.ATR3 = calculateATR(klines, 3)
-	data.ATR14 = calculateATR(klines, 14)
+	data.ATR3 = calculateATR(klines, p.ATRShort)
+	data.ATR14 = calculateATR(klines, p.ATRLong)
 
 	// 计算成交量
 	if len(klines) > 0 {
@@ -388,8 +654,8 @@ func calculateLongerTermData(klines []Kline) *LongerTermData {
 			macd := calculateMACD(klines[:i+1])
 			data.MACDValues = append(data.MACDValues, macd)
 		}
-		if i >= 14 {
-			rsi14 := calculateRSI(klines[:i+1], 14)
+		if i >= p.RSILong {
+			rsi14 := calculateRSI(klines[:i+1], p.RSILong)
 			data.RSI14Values = append(data.RSI14Values, rsi14)
 		}
 	}
@@ -397,35 +663,112 @@ func calculateLongerTermData(klines []Kline) *LongerTermData {
 	return data
 }
 
+// 数据源不提供 OI/资金费率（如 Binance.US、Finnhub 现货数据）时的处理方式
+const (
+	DerivativesFallbackOmit = "omit" // 提示词中省略对应行并注明数据不可用（默认）
+	DerivativesFallbackZero = "zero" // 按 0 输出（旧行为）
+)
+
+var derivativesFallback = DerivativesFallbackOmit
+
+// SetDerivativesFallback 设置数据源不提供 OI/资金费率时的处理方式（空值使用 omit，无效值忽略）
+func SetDerivativesFallback(mode string) {
+	mode = strings.ToLower(strings.TrimSpace(mode))
+	switch mode {
+	case "":
+		derivativesFallback = DerivativesFallbackOmit
+	case DerivativesFallbackOmit, DerivativesFallbackZero:
+		derivativesFallback = mode
+	default:
+		log.Printf("⚠️  [Market] 未知的 derivatives_fallback: %s（可选 omit、zero），使用 %s", mode, derivativesFallback)
+	}
+}
+
+// hideDerivativesOnSpot 现货数据源（无永续合约）是否在提示词中完全省略持仓量/资金费率部分（默认 true）
+var hideDerivativesOnSpot = true
+
+// SetHideDerivativesOnSpot 设置现货数据源是否省略持仓量/资金费率部分
+// （false 时按 derivatives_fallback 处理：注明不可用或按 0 输出）
+func SetHideDerivativesOnSpot(hide bool) {
+	hideDerivativesOnSpot = hide
+}
+
+// derivativesUnavailable 数据源是否缺少 OI/资金费率（配置为 zero 时视为可用，按 0 输出）
+func derivativesUnavailable(source DataSource) (oi, funding bool) {
+	if derivativesFallback == DerivativesFallbackZero {
+		return false, false
+	}
+	cfg := dataSourceConfigFor(source)
+	return !cfg.SupportsOpenInterest(), !cfg.SupportsFunding()
+}
+
+// fetchDerivativesData 获取OI、资金费率及其获取时间
+// 数据源没有对应接口时不发起请求也不记录日志；请求失败按数据源和错误类别抑制重复日志，并计入失败率指标。
+// 资金费率刷新失败时沿用过期的缓存值（返回其获取时间，由提示词标注是否过期）
+func fetchDerivativesData(source DataSource, symbol string) (*OIData, float64, time.Time) {
+	cfg := dataSourceConfigFor(source)
+	source = cfg.Source
+
+	oiData := &OIData{Latest: 0, Average: 0}
+	if cfg.SupportsOpenInterest() {
+		if data, err := getOpenInterestData(source, symbol); err != nil {
+			sourceErrors.Failure(source, sourceKindOpenInterest, symbol, err)
+		} else {
+			sourceErrors.Success(source, sourceKindOpenInterest)
+			if data.Latest == 0 {
+				sourceErrors.Warning(source, sourceKindOpenInterest, errClassZero,
+					fmt.Sprintf("%s 的 OpenInterest 为 0（可能是数据问题或币种未交易）", symbol))
+			}
+			oiData = data
+		}
+	}
+
+	var fundingRate float64
+	var fundingUpdatedAt time.Time
+	if cfg.SupportsFunding() {
+		if cache, err := getFundingRate(source, symbol); err != nil {
+			sourceErrors.Failure(source, sourceKindFundingRate, symbol, err)
+			if stale, ok := cachedFundingRate(source, symbol); ok {
+				fundingRate, fundingUpdatedAt = stale.Rate, stale.UpdatedAt
+			}
+		} else {
+			sourceErrors.Success(source, sourceKindFundingRate)
+			fundingRate, fundingUpdatedAt = cache.Rate, cache.UpdatedAt
+		}
+	}
+
+	return oiData, fundingRate, fundingUpdatedAt
+}
+
 // getOpenInterestData 获取OI数据
-func getOpenInterestData(symbol string) (*OIData, error) {
-	url, err := GetOIURL(symbol)
+func getOpenInterestData(source DataSource, symbol string) (*OIData, error) {
+	url, err := oiURL(source, symbol)
 	if err != nil {
 		return nil, err
 	}
 
-	apiClient := NewAPIClient()
+	apiClient := NewAPIClientFor(source)
 	resp, err := apiClient.client.Get(url)
 	if err != nil {
-		sourceName := string(GetCurrentDataSource())
-		return nil, fmt.Errorf("HTTP请求失败 (%s): %w", sourceName, err)
+		sourceName := string(source)
+		return nil, newSourceError(errClassHTTP, "HTTP请求失败 (%s): %w", sourceName, err)
 	}
 	defer resp.Body.Close()
 
 	body, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		return nil, fmt.Errorf("读取响应失败: %w", err)
+		return nil, newSourceError(errClassHTTP, "读取响应失败: %w", err)
 	}
 
 	// 检查HTTP状态码
 	if resp.StatusCode != http.StatusOK {
-		sourceName := string(GetCurrentDataSource())
-		return nil, fmt.Errorf("%s API返回错误状态码 %d: %s", sourceName, resp.StatusCode, string(body))
+		sourceName := string(source)
+		return nil, newStatusError(resp.StatusCode, "%s API返回错误状态码 %d: %s", sourceName, resp.StatusCode, string(body))
 	}
 
 	var oi float64
 
-	if GetCurrentDataSource() == DataSourceBybit {
+	if source == DataSourceBybit {
 		// Bybit 响应格式
 		var response struct {
 			RetCode int    `json:"retCode"`
@@ -438,16 +781,14 @@ func getOpenInterestData(symbol string) (*OIData, error) {
 			} `json:"result"`
 		}
 		if err := json.Unmarshal(body, &response); err != nil {
-			log.Printf("❌ [Market] 解析Bybit OpenInterest数据失败, symbol=%s, 响应内容: %s", symbol, string(body))
-			return nil, fmt.Errorf("解析Bybit JSON响应失败: %w", err)
+			return nil, newSourceError(errClassParse, "解析Bybit JSON响应失败: %w, 响应内容: %s", err, string(body))
 		}
 		if response.RetCode != 0 {
-			return nil, fmt.Errorf("Bybit API错误: %s (code: %d)", response.RetMsg, response.RetCode)
+			return nil, newSourceError(errClassAPI, "Bybit API错误: %s (code: %d)", response.RetMsg, response.RetCode)
 		}
 		oi, err = strconv.ParseFloat(response.Result.OpenInterest, 64)
 		if err != nil {
-			log.Printf("❌ [Market] 解析Bybit OpenInterest数值失败, symbol=%s, value=%s", symbol, response.Result.OpenInterest)
-			return nil, fmt.Errorf("解析OpenInterest数值失败: %w", err)
+			return nil, newSourceError(errClassParse, "解析OpenInterest数值失败 (value=%s): %w", response.Result.OpenInterest, err)
 		}
 	} else {
 		// Binance 响应格式
@@ -457,58 +798,66 @@ func getOpenInterestData(symbol string) (*OIData, error) {
 			Time         int64  `json:"time"`
 		}
 		if err := json.Unmarshal(body, &result); err != nil {
-			log.Printf("❌ [Market] 解析OpenInterest数据失败, symbol=%s, 响应内容: %s", symbol, string(body))
-			return nil, fmt.Errorf("解析JSON响应失败: %w", err)
+			return nil, newSourceError(errClassParse, "解析JSON响应失败: %w, 响应内容: %s", err, string(body))
 		}
 		oi, err = strconv.ParseFloat(result.OpenInterest, 64)
 		if err != nil {
-			log.Printf("❌ [Market] 解析OpenInterest数值失败, symbol=%s, value=%s", symbol, result.OpenInterest)
-			return nil, fmt.Errorf("解析OpenInterest数值失败: %w", err)
+			return nil, newSourceError(errClassParse, "解析OpenInterest数值失败 (value=%s): %w", result.OpenInterest, err)
 		}
 	}
 
-	if oi == 0 {
-		log.Printf("⚠️  [Market] %s 的 OpenInterest 为 0（可能是数据问题或币种未交易）", symbol)
-	}
-
 	return &OIData{
 		Latest:  oi,
 		Average: oi * 0.999, // 近似平均值
 	}, nil
 }
 
-// getFundingRate 获取资金费率（优化：使用 1 小时缓存）
-func getFundingRate(symbol string) (float64, error) {
-	// 检查缓存（有效期 1 小时）
+// fundingRateCacheKey 资金费率缓存键（按数据源区分）
+func fundingRateCacheKey(source DataSource, symbol string) string {
+	return string(source) + ":" + symbol
+}
+
+// cachedFundingRate 读取资金费率缓存（不检查有效期）
+func cachedFundingRate(source DataSource, symbol string) (*FundingRateCache, bool) {
+	cached, ok := fundingRateMap.Load(fundingRateCacheKey(source, symbol))
+	if !ok {
+		return nil, false
+	}
+	return cached.(*FundingRateCache), true
+}
+
+// getFundingRate 获取资金费率及其获取时间（优化：使用 1 小时缓存）
+func getFundingRate(source DataSource, symbol string) (*FundingRateCache, error) {
+	// 检查缓存（有效期 1 小时，按数据源区分）
 	// Funding Rate 每 8 小时才更新，1 小时缓存非常合理
-	if cached, ok := fundingRateMap.Load(symbol); ok {
-		cache := cached.(*FundingRateCache)
-		if time.Since(cache.UpdatedAt) < frCacheTTL {
-			// 缓存命中，直接返回
-			return cache.Rate, nil
-		}
+	if cache, ok := cachedFundingRate(source, symbol); ok && time.Since(cache.UpdatedAt) < frCacheTTL {
+		// 缓存命中，直接返回
+		return cache, nil
 	}
 
 	// 缓存过期或不存在，调用 API
-	url, err := GetFundingURL(symbol)
+	url, err := fundingURL(source, symbol)
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
 
-	apiClient := NewAPIClient()
+	apiClient := NewAPIClientFor(source)
 	resp, err := apiClient.client.Get(url)
 	if err != nil {
-		return 0, err
+		return nil, newSourceError(errClassHTTP, "HTTP请求失败: %w", err)
 	}
 	defer resp.Body.Close()
 
 	body, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		return 0, err
+		return nil, newSourceError(errClassHTTP, "读取响应失败: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, newStatusError(resp.StatusCode, "API返回错误状态码 %d: %s", resp.StatusCode, string(body))
 	}
 
 	var fundingRate float64
-	if GetCurrentDataSource() == DataSourceBybit {
+	if source == DataSourceBybit {
 		// Bybit 响应格式
 		var response struct {
 			RetCode int    `json:"retCode"`
@@ -523,14 +872,14 @@ func getFundingRate(symbol string) (float64, error) {
 			} `json:"result"`
 		}
 		if err := json.Unmarshal(body, &response); err != nil {
-			return 0, err
+			return nil, newSourceError(errClassParse, "解析Bybit JSON响应失败: %w", err)
 		}
 		if response.RetCode != 0 || len(response.Result.List) == 0 {
-			return 0, fmt.Errorf("Bybit API错误: %s", response.RetMsg)
+			return nil, newSourceError(errClassAPI, "Bybit API错误: %s", response.RetMsg)
 		}
 		fundingRate, err = strconv.ParseFloat(response.Result.List[0].FundingRate, 64)
 		if err != nil {
-			return 0, err
+			return nil, newSourceError(errClassParse, "解析Funding Rate数值失败: %w", err)
 		}
 	} else {
 		// Binance 响应格式
@@ -544,21 +893,22 @@ func getFundingRate(symbol string) (float64, error) {
 			Time            int64  `json:"time"`
 		}
 		if err := json.Unmarshal(body, &result); err != nil {
-			return 0, err
+			return nil, newSourceError(errClassParse, "解析JSON响应失败: %w", err)
 		}
 		fundingRate, err = strconv.ParseFloat(result.LastFundingRate, 64)
 		if err != nil {
-			return 0, err
+			return nil, newSourceError(errClassParse, "解析Funding Rate数值失败: %w", err)
 		}
 	}
 
 	// 更新缓存
-	fundingRateMap.Store(symbol, &FundingRateCache{
+	cache := &FundingRateCache{
 		Rate:      fundingRate,
 		UpdatedAt: time.Now(),
-	})
+	}
+	fundingRateMap.Store(fundingRateCacheKey(source, symbol), cache)
 
-	return fundingRate, nil
+	return cache, nil
 }
 
 // TSI 指标计算 来自脚本:1—TSI副图指标，指标-40区域金叉买，正40死叉卖
@@ -753,6 +1103,23 @@ func calculateVolatilityGaussianBands(klines []Kline, length int, mult float64)
 	return trend, avg, upper, lower, score
 }
 
+// calculateBollingerBands 计算布林带：中轨为收盘价的 period 周期SMA，上下轨为中轨 ± mult 倍标准差
+// 数据不足（少于 period 根K线）时返回 0
+func calculateBollingerBands(klines []Kline, period int, mult float64) (middle, upper, lower float64) {
+	if period <= 0 || len(klines) < period {
+		return 0, 0, 0
+	}
+	closes := make([]float64, len(klines))
+	for i := range closes {
+		closes[i] = klines[i].Close
+	}
+	middle = sma(closes, period)
+	sd := stdev(closes, period)
+	upper = middle + mult*sd
+	lower = middle - mult*sd
+	return middle, upper, lower
+}
+
 // calculateSSLHybridExit 来自脚本: 4—ssl代码中有EXIT 上下箭头指示买卖
 // 构造SSL通道(高低价SMA)与基线，价格上下穿通道触发EXIT信号
 func calculateSSLHybridExit(klines []Kline, chLen int, baselineLen int) (exitSignal int, baseline, upperK, lowerK float64) {
@@ -971,117 +1338,6 @@ func calculateRSIWithPatterns(klines []Kline, period int) (rsiVal float64, buy,
 	return rsiVal, buy, sell
 }
 
-// Format 格式化输出市场数据
-func Format(data *Data) string {
-	var sb strings.Builder
-
-	// 使用动态精度格式化价格
-	priceStr := formatPriceWithDynamicPrecision(data.CurrentPrice)
-	sb.WriteString(fmt.Sprintf("current_price = %s, current_ema20 = %.3f, current_macd = %.3f, current_rsi (7 period) = %.3f, current_tsi = %.3f, tsi_signal = %.3f\n\n",
-		priceStr, data.CurrentEMA20, data.CurrentMACD, data.CurrentRSI7, data.CurrentTSI, data.CurrentTSISignal))
-
-	sb.WriteString(fmt.Sprintf("In addition, here is the latest %s open interest and funding rate for perps:\n\n",
-		data.Symbol))
-
-	if data.OpenInterest != nil {
-		// 使用动态精度格式化 OI 数据
-		oiLatestStr := formatPriceWithDynamicPrecision(data.OpenInterest.Latest)
-		oiAverageStr := formatPriceWithDynamicPrecision(data.OpenInterest.Average)
-		sb.WriteString(fmt.Sprintf("Open Interest: Latest: %s Average: %s\n\n",
-			oiLatestStr, oiAverageStr))
-	}
-
-	sb.WriteString(fmt.Sprintf("Funding Rate: %.2e\n\n", data.FundingRate))
-
-	if data.IntradaySeries != nil {
-		sb.WriteString("Intraday series (3‑minute intervals, oldest → latest):\n\n")
-
-		if len(data.IntradaySeries.MidPrices) > 0 {
-			sb.WriteString(fmt.Sprintf("Mid prices: %s\n\n", formatFloatSlice(data.IntradaySeries.MidPrices)))
-		}
-
-		if len(data.IntradaySeries.EMA20Values) > 0 {
-			sb.WriteString(fmt.Sprintf("EMA indicators (20‑period): %s\n\n", formatFloatSlice(data.IntradaySeries.EMA20Values)))
-		}
-
-		if len(data.IntradaySeries.MACDValues) > 0 {
-			sb.WriteString(fmt.Sprintf("MACD indicators: %s\n\n", formatFloatSlice(data.IntradaySeries.MACDValues)))
-		}
-
-		if len(data.IntradaySeries.RSI7Values) > 0 {
-			sb.WriteString(fmt.Sprintf("RSI indicators (7‑Period): %s\n\n", formatFloatSlice(data.IntradaySeries.RSI7Values)))
-		}
-
-		if len(data.IntradaySeries.RSI14Values) > 0 {
-			sb.WriteString(fmt.Sprintf("RSI indicators (14‑Period): %s\n\n", formatFloatSlice(data.IntradaySeries.RSI14Values)))
-		}
-
-		if len(data.IntradaySeries.Volume) > 0 {
-			sb.WriteString(fmt.Sprintf("Volume: %s\n\n", formatFloatSlice(data.IntradaySeries.Volume)))
-		}
-
-		sb.WriteString(fmt.Sprintf("3m ATR (14‑period): %.3f\n\n", data.IntradaySeries.ATR14))
-	}
-
-	if data.LongerTermContext != nil {
-		sb.WriteString("Longer‑term context (4‑hour timeframe):\n\n")
-
-		sb.WriteString(fmt.Sprintf("20‑Period EMA: %.3f vs. 50‑Period EMA: %.3f\n\n",
-			data.LongerTermContext.EMA20, data.LongerTermContext.EMA50))
-
-		sb.WriteString(fmt.Sprintf("3‑Period ATR: %.3f vs. 14‑Period ATR: %.3f\n\n",
-			data.LongerTermContext.ATR3, data.LongerTermContext.ATR14))
-
-		sb.WriteString(fmt.Sprintf("Current Volume: %.3f vs. Average Volume: %.3f\n\n",
-			data.LongerTermContext.CurrentVolume, data.LongerTermContext.AverageVolume))
-
-		if len(data.LongerTermContext.MACDValues) > 0 {
-			sb.WriteString(fmt.Sprintf("MACD indicators: %s\n\n", formatFloatSlice(data.LongerTermContext.MACDValues)))
-		}
-
-		if len(data.LongerTermContext.RSI14Values) > 0 {
-			sb.WriteString(fmt.Sprintf("RSI indicators (14‑Period): %s\n\n", formatFloatSlice(data.LongerTermContext.RSI14Values)))
-		}
-	}
-
-	// 脚本 1—10 指标摘要
-	sb.WriteString("Additional indicators (scripts #1–#10):\n\n")
-	aboveSignal := data.CurrentTSI > data.CurrentTSISignal
-	zone := "neutral"
-	if data.CurrentTSI >= 40 {
-		zone = "overbought(>=+40)"
-	} else if data.CurrentTSI <= -40 {
-		zone = "oversold(<=-40)"
-	}
-	sb.WriteString(fmt.Sprintf("TSI: value=%.2f, signal=%.2f, above_signal=%v, zone=%s\n",
-		data.CurrentTSI, data.CurrentTSISignal, aboveSignal, zone))
-	sb.WriteString(fmt.Sprintf("KEMAD: trend=%d, kema=%.3f, atr=%.3f\n",
-		data.KEMADTrend, data.KEMADEMA, data.KEMADATR))
-	sb.WriteString(fmt.Sprintf("Volatility Gaussian Bands: trend=%d, avg=%.3f, upper=%.3f, lower=%.3f, score=%.3f\n",
-		data.VGBTrend, data.VGBAvg, data.VGBUpper, data.VGBLower, data.VGBScore))
-	sb.WriteString(fmt.Sprintf("SSL Hybrid Exit: signal=%d, baseline=%.3f, upperK=%.3f, lowerK=%.3f\n",
-		data.SSLExitSignal, data.SSLBaseline, data.SSLUpperK, data.SSLLowerK))
-	sb.WriteString("Timeframe indicators (4h, 30m):\n")
-	sb.WriteString(fmt.Sprintf("tsi_4h_value=%.2f, tsi_4h_signal=%.2f\n", data.TSI4hValue, data.TSI4hSignal))
-	sb.WriteString(fmt.Sprintf("tsi_30m_value=%.2f, tsi_30m_signal=%.2f\n", data.TSI30mValue, data.TSI30mSignal))
-	sb.WriteString(fmt.Sprintf("ssl_4h_exit=%d, ssl_4h_baseline=%.3f, ssl_4h_upperK=%.3f, ssl_4h_lowerK=%.3f\n", data.SSL4hExitSignal, data.SSL4hBaseline, data.SSL4hUpperK, data.SSL4hLowerK))
-	sb.WriteString(fmt.Sprintf("ssl_30m_exit=%d, ssl_30m_baseline=%.3f, ssl_30m_upperK=%.3f, ssl_30m_lowerK=%.3f\n\n", data.SSL30mExitSignal, data.SSL30mBaseline, data.SSL30mUpperK, data.SSL30mLowerK))
-	sb.WriteString(fmt.Sprintf("Zero‑Lag Trend: trend=%d, zlema=%.3f, volatility=%.3f\n",
-		data.ZeroLagTrend, data.ZeroLagZLEMA, data.ZeroLagVolatility))
-	sb.WriteString(fmt.Sprintf("QQE MOD Hybrid: trend=%d, fastTL=%.3f, upper=%.3f, lower=%.3f\n",
-		data.QQETrend, data.QQEFastTL, data.QQEUpper, data.QQELower))
-	sb.WriteString(fmt.Sprintf("Range Filtered: kalman=%.3f, trend=%d, kTrend=%d, combined=%d\n",
-		data.RangeKalman, data.RangeTrend, data.RangeKTrend, data.RangeCombinedTrend))
-	sb.WriteString(fmt.Sprintf("DPSD: trend=%d, pt=%.3f, dema=%.3f, perUp=%.3f, perDown=%.3f\n",
-		data.DPSDTrend, data.DPSDPT, data.DPSDEMA, data.DPSDPerUp, data.DPSDPerDown))
-	sb.WriteString(fmt.Sprintf("Ultimate RSI: value=%.2f, signal=%.2f, overbought=%v, oversold=%v\n",
-		data.UltimateRSI, data.UltimateRSISignal, data.UltimateRSIOverbought, data.UltimateRSIOversold))
-	sb.WriteString(fmt.Sprintf("RSI(10): buy=%v, sell=%v, rsi=%.2f\n\n",
-		data.RSIBuySignal, data.RSISellSignal, data.RSIValue))
-
-	return sb.String()
-}
-
 // formatPriceWithDynamicPrecision 根据价格区间动态选择精度
 // 这样可以完美支持从超低价 meme coin (< 0.0001) 到 BTC/ETH 的所有币种
 func formatPriceWithDynamicPrecision(price float64) string {
@@ -103,6 +103,162 @@ func TestCalculateRSI_RangeCheck(t *testing.T) {
 	assert.LessOrEqual(t, rsi, 100.0, "RSI must be <= 100")
 }
 
+// ============================================================
+// Williams %R
+// ============================================================
+
+func TestCalculateWilliamsR_Uptrend(t *testing.T) {
+	// Steady uptrend closing at the top of each bar: close sits at the range high
+	klines := make([]Kline, 20)
+	for i := range klines {
+		base := 100.0 + float64(i)
+		klines[i] = Kline{Open: base, High: base + 1, Low: base - 1, Close: base + 1}
+	}
+	wr := calculateWilliamsR(klines, 14)
+	assert.InDelta(t, 0.0, wr, 1.0, "uptrend should be near 0 (overbought)")
+}
+
+func TestCalculateWilliamsR_Downtrend(t *testing.T) {
+	klines := make([]Kline, 20)
+	for i := range klines {
+		base := 100.0 - float64(i)
+		klines[i] = Kline{Open: base, High: base + 1, Low: base - 1, Close: base - 1}
+	}
+	wr := calculateWilliamsR(klines, 14)
+	assert.InDelta(t, -100.0, wr, 1.0, "downtrend should be near -100 (oversold)")
+}
+
+func TestCalculateWilliamsR_EdgeCases(t *testing.T) {
+	assert.Equal(t, -50.0, calculateWilliamsR(nil, 14), "no data should be neutral")
+	assert.Equal(t, -50.0, calculateWilliamsR(generateEdgeTestKlines(5), 14), "insufficient data should be neutral")
+
+	flat := make([]Kline, 20)
+	for i := range flat {
+		flat[i] = Kline{Open: 100, High: 100, Low: 100, Close: 100}
+	}
+	assert.Equal(t, -50.0, calculateWilliamsR(flat, 14), "flat range should be neutral, not NaN")
+
+	wr := calculateWilliamsR(generateEdgeTestKlines(50), 14)
+	assert.GreaterOrEqual(t, wr, -100.0)
+	assert.LessOrEqual(t, wr, 0.0)
+}
+
+// ============================================================
+// MFI (money flow index)
+// ============================================================
+
+func TestCalculateMFI_InsufficientData(t *testing.T) {
+	assert.Equal(t, 0.0, calculateMFI(nil, 14))
+	assert.Equal(t, 0.0, calculateMFI(generateEdgeTestKlines(14), 14), "MFI needs period+1 klines")
+}
+
+func TestCalculateMFI_AllPositiveFlowSaturates(t *testing.T) {
+	// generateEdgeTestKlines is a steady uptrend: every bar has a higher typical price
+	mfi := calculateMFI(generateEdgeTestKlines(30), 14)
+	assert.Equal(t, 100.0, mfi, "MFI with no negative flow should be 100")
+}
+
+func TestCalculateMFI_RangeCheck(t *testing.T) {
+	klines := make([]Kline, 60)
+	for i := range klines {
+		base := 100 + 5*math.Sin(float64(i)/3)
+		klines[i] = Kline{High: base + 1, Low: base - 1, Close: base + 0.2, Volume: 1000 + float64(i%7)*150}
+	}
+	mfi := calculateMFI(klines, 14)
+	assert.Greater(t, mfi, 0.0)
+	assert.Less(t, mfi, 100.0)
+
+	down := make([]Kline, 20)
+	for i := range down {
+		base := 100.0 - float64(i)
+		down[i] = Kline{High: base + 1, Low: base - 1, Close: base, Volume: 1000}
+	}
+	assert.InDelta(t, 0.0, calculateMFI(down, 14), 1e-9, "MFI with only negative flow should be 0")
+}
+
+// ============================================================
+// Parabolic SAR
+// ============================================================
+
+// sarTrendKlines 稳定上涨 n 根K线（每根最高价 base+1，最低价 base-1）
+func sarTrendKlines(n int) []Kline {
+	klines := make([]Kline, n)
+	for i := range klines {
+		base := 100.0 + float64(i)
+		klines[i] = Kline{Open: base, High: base + 1, Low: base - 1, Close: base + 0.5}
+	}
+	return klines
+}
+
+func TestCalculateParabolicSAR_InsufficientData(t *testing.T) {
+	sar, trend, flipped := calculateParabolicSAR(nil, 0.02, 0.2)
+	assert.Equal(t, 0.0, sar)
+	assert.Equal(t, 0, trend)
+	assert.False(t, flipped)
+
+	_, trend, _ = calculateParabolicSAR(sarTrendKlines(1), 0.02, 0.2)
+	assert.Equal(t, 0, trend)
+}
+
+func TestCalculateParabolicSAR_Uptrend(t *testing.T) {
+	klines := sarTrendKlines(20)
+	sar, trend, flipped := calculateParabolicSAR(klines, 0.02, 0.2)
+	assert.Equal(t, 1, trend)
+	assert.False(t, flipped)
+	assert.Less(t, sar, klines[len(klines)-1].Low, "uptrend SAR should trail below price")
+	assert.Greater(t, sar, klines[0].Low, "SAR should accelerate toward price")
+
+	// 默认参数
+	sarDefault, _, _ := calculateParabolicSAR(klines, 0, 0)
+	assert.Equal(t, sar, sarDefault)
+}
+
+func TestCalculateParabolicSAR_Recurrence(t *testing.T) {
+	klines := []Kline{
+		{High: 110, Low: 100, Close: 105},
+		{High: 104, Low: 96, Close: 98},
+		{High: 99, Low: 90, Close: 92},
+		{High: 95, Low: 88, Close: 89},
+	}
+	// 初始下降趋势：SAR=110，EP=100，AF=0.02
+	// 第1根：SAR=109.8，不低于前两根最高价 → 110；新低 96 → EP=96，AF=0.04
+	// 第2根：SAR=109.44 → 110；新低 90 → EP=90，AF=0.06
+	sar, trend, _ := calculateParabolicSAR(klines[:3], 0.02, 0.2)
+	assert.Equal(t, -1, trend)
+	assert.Equal(t, 110.0, sar)
+
+	// 第3根：SAR=110+0.06×(90-110)=108.8，高于前两根最高价，不再受限
+	sar, trend, flipped := calculateParabolicSAR(klines, 0.02, 0.2)
+	assert.Equal(t, -1, trend)
+	assert.False(t, flipped)
+	assert.InDelta(t, 108.8, sar, 1e-9)
+}
+
+func TestCalculateParabolicSAR_FlipOnReversal(t *testing.T) {
+	klines := sarTrendKlines(20)
+	highest := klines[len(klines)-1].High
+
+	// 最新K线急跌穿越 SAR：反转为下降趋势，新 SAR 取上升趋势的最高价
+	crash := Kline{Open: 118, High: 118.5, Low: 90, Close: 91}
+	reversed := append(append([]Kline{}, klines...), crash)
+	sar, trend, flipped := calculateParabolicSAR(reversed, 0.02, 0.2)
+	assert.True(t, flipped, "SAR should flip on the crash bar")
+	assert.Equal(t, -1, trend)
+	assert.Equal(t, highest, sar)
+
+	// 下一根K线延续下跌：保持下降趋势，不再标记反转
+	next := append(reversed, Kline{Open: 91, High: 92, Low: 88, Close: 89})
+	sar, trend, flipped = calculateParabolicSAR(next, 0.02, 0.2)
+	assert.False(t, flipped)
+	assert.Equal(t, -1, trend)
+	assert.Greater(t, sar, 92.0, "downtrend SAR should stay above price")
+}
+
+func TestFormat_ParabolicSAR(t *testing.T) {
+	output := Format(&Data{Symbol: "BTCUSDT", CurrentPrice: 100, SARValue: 118, SARTrend: -1, SARFlipped: true})
+	assert.Contains(t, output, "current_parabolic_sar (0.02/0.2) = 118.00, above price (downtrend), flipped_this_bar = true")
+}
+
 // ============================================================
 // ATR edge cases
 // ============================================================
@@ -201,6 +357,38 @@ func TestCalculateVGB_NormalData(t *testing.T) {
 	assert.Contains(t, []int{-1, 0, 1}, trend)
 }
 
+// ============================================================
+// Bollinger Bands
+// ============================================================
+
+func TestCalculateBollingerBands_InsufficientData(t *testing.T) {
+	klines := generateEdgeTestKlines(5)
+	middle, upper, lower := calculateBollingerBands(klines, 20, 2.0)
+	assert.Equal(t, 0.0, middle)
+	assert.Equal(t, 0.0, upper)
+	assert.Equal(t, 0.0, lower)
+}
+
+func TestCalculateBollingerBands_NormalData(t *testing.T) {
+	klines := generateEdgeTestKlines(50)
+	middle, upper, lower := calculateBollingerBands(klines, 20, 2.0)
+	assert.Greater(t, middle, 0.0)
+	assert.Greater(t, upper, middle, "upper band should be above middle band")
+	assert.Less(t, lower, middle, "lower band should be below middle band")
+	assert.InDelta(t, upper-middle, middle-lower, 1e-9, "bands should be symmetric around the middle")
+}
+
+func TestCalculateBollingerBands_FlatPrices(t *testing.T) {
+	klines := make([]Kline, 20)
+	for i := range klines {
+		klines[i] = Kline{Open: 100, High: 100, Low: 100, Close: 100}
+	}
+	middle, upper, lower := calculateBollingerBands(klines, 20, 2.0)
+	assert.Equal(t, 100.0, middle)
+	assert.Equal(t, 100.0, upper, "zero deviation collapses the bands onto the middle")
+	assert.Equal(t, 100.0, lower)
+}
+
 // ============================================================
 // SSL Hybrid Exit
 // ============================================================
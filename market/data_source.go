@@ -3,6 +3,8 @@ package market
 import (
 	"fmt"
 	"log"
+	"strings"
+	"sync"
 )
 
 // DataSource 数据源类型
@@ -29,6 +31,9 @@ type DataSourceConfig struct {
 	APIKey          string // 某些数据源需要 API key (如 Finnhub)
 }
 
+// dataSourceMu 保护 currentDataSource（主数据源故障时会在运行中切换到备用数据源）
+var dataSourceMu sync.RWMutex
+
 var (
 	currentDataSource DataSource = DataSourceBinance
 	dataSourceConfigs            = map[DataSource]*DataSourceConfig{
@@ -90,6 +95,8 @@ func InitDataSource(source string, apiKey string) {
 	if source == "" {
 		source = "binance" // 默认使用 Binance
 	}
+	dataSourceMu.Lock()
+	defer dataSourceMu.Unlock()
 
 	switch DataSource(source) {
 	case DataSourceFinnhub:
@@ -122,12 +129,39 @@ func InitDataSource(source string, apiKey string) {
 
 // GetCurrentDataSource 获取当前数据源
 func GetCurrentDataSource() DataSource {
+	dataSourceMu.RLock()
+	defer dataSourceMu.RUnlock()
 	return currentDataSource
 }
 
+// ParseDataSource 校验数据源名称（空字符串表示跟随全局数据源）
+func ParseDataSource(source string) (DataSource, error) {
+	ds := DataSource(strings.ToLower(strings.TrimSpace(source)))
+	if ds == "" {
+		return "", nil
+	}
+	if _, ok := dataSourceConfigs[ds]; !ok {
+		return "", fmt.Errorf("未知的数据源: %s", source)
+	}
+	return ds, nil
+}
+
+// resolveDataSource 未指定数据源时使用全局数据源
+func resolveDataSource(source DataSource) DataSource {
+	if source == "" {
+		return GetCurrentDataSource()
+	}
+	return source
+}
+
 // GetDataSourceConfig 获取数据源配置
 func GetDataSourceConfig() *DataSourceConfig {
-	cfg, ok := dataSourceConfigs[currentDataSource]
+	return dataSourceConfigFor(GetCurrentDataSource())
+}
+
+// dataSourceConfigFor 获取指定数据源的配置
+func dataSourceConfigFor(source DataSource) *DataSourceConfig {
+	cfg, ok := dataSourceConfigs[resolveDataSource(source)]
 	if !ok {
 		log.Printf("⚠️  [Market] 数据源配置不存在，使用 Binance 默认配置")
 		return dataSourceConfigs[DataSourceBinance]
@@ -135,6 +169,21 @@ func GetDataSourceConfig() *DataSourceConfig {
 	return cfg
 }
 
+// SupportsOpenInterest 数据源是否提供 Open Interest 数据
+func (c *DataSourceConfig) SupportsOpenInterest() bool {
+	return c.OIEndpoint != ""
+}
+
+// SupportsFunding 数据源是否提供 Funding Rate 数据
+func (c *DataSourceConfig) SupportsFunding() bool {
+	return c.FundingEndpoint != ""
+}
+
+// SpotOnly 数据源是否只有现货数据（OI 和 Funding Rate 都不提供）
+func (c *DataSourceConfig) SpotOnly() bool {
+	return !c.SupportsOpenInterest() && !c.SupportsFunding()
+}
+
 // GetBaseURL 获取基础URL
 func GetBaseURL() string {
 	return GetDataSourceConfig().BaseURL
@@ -154,12 +203,16 @@ func GetPriceURL() string {
 
 // GetOIURL 获取Open Interest URL
 func GetOIURL(symbol string) (string, error) {
-	cfg := GetDataSourceConfig()
+	return oiURL(GetCurrentDataSource(), symbol)
+}
+
+func oiURL(source DataSource, symbol string) (string, error) {
+	cfg := dataSourceConfigFor(source)
 	if cfg.OIEndpoint == "" {
 		return "", fmt.Errorf("当前数据源 %s 不支持 Open Interest 数据", cfg.Source)
 	}
 
-	switch currentDataSource {
+	switch cfg.Source {
 	case DataSourceBinance:
 		return fmt.Sprintf("%s%s?symbol=%s", cfg.BaseURL, cfg.OIEndpoint, symbol), nil
 	case DataSourceBybit:
@@ -176,14 +229,57 @@ func GetOIURL(symbol string) (string, error) {
 	}
 }
 
+// GetBookTickerURL 获取最优买卖价（盘口）URL
+func GetBookTickerURL(symbol string) (string, error) {
+	return bookTickerURL(GetCurrentDataSource(), symbol)
+}
+
+func bookTickerURL(source DataSource, symbol string) (string, error) {
+	cfg := dataSourceConfigFor(source)
+
+	switch cfg.Source {
+	case DataSourceBinance:
+		return fmt.Sprintf("%s/fapi/v1/ticker/bookTicker?symbol=%s", cfg.BaseURL, symbol), nil
+	case DataSourceBinanceUS:
+		return fmt.Sprintf("%s/api/v3/ticker/bookTicker?symbol=%s", cfg.BaseURL, symbol), nil
+	case DataSourceBybit:
+		// Bybit 的买一/卖一价在 tickers 接口中
+		return fmt.Sprintf("%s%s?category=linear&symbol=%s", cfg.BaseURL, cfg.PriceEndpoint, symbol), nil
+	default:
+		return "", fmt.Errorf("当前数据源 %s 不支持盘口数据", cfg.Source)
+	}
+}
+
+// GetTicker24hrURL 获取全部币种的24小时行情URL（币种池流动性过滤使用，响应为 Binance 格式的行情列表）
+func GetTicker24hrURL() (string, error) {
+	return ticker24hrURL(GetCurrentDataSource())
+}
+
+func ticker24hrURL(source DataSource) (string, error) {
+	cfg := dataSourceConfigFor(source)
+
+	switch cfg.Source {
+	case DataSourceBinance:
+		return fmt.Sprintf("%s/fapi/v1/ticker/24hr", cfg.BaseURL), nil
+	case DataSourceBinanceUS:
+		return fmt.Sprintf("%s/api/v3/ticker/24hr", cfg.BaseURL), nil
+	default:
+		return "", fmt.Errorf("当前数据源 %s 不支持24小时行情列表", cfg.Source)
+	}
+}
+
 // GetFundingURL 获取Funding Rate URL
 func GetFundingURL(symbol string) (string, error) {
-	cfg := GetDataSourceConfig()
+	return fundingURL(GetCurrentDataSource(), symbol)
+}
+
+func fundingURL(source DataSource, symbol string) (string, error) {
+	cfg := dataSourceConfigFor(source)
 	if cfg.FundingEndpoint == "" {
 		return "", fmt.Errorf("当前数据源 %s 不支持 Funding Rate 数据", cfg.Source)
 	}
 
-	switch currentDataSource {
+	switch cfg.Source {
 	case DataSourceBinance:
 		return fmt.Sprintf("%s%s?symbol=%s", cfg.BaseURL, cfg.FundingEndpoint, symbol), nil
 	case DataSourceBybit:
@@ -0,0 +1,173 @@
+package market
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// newBinanceTestServer 模拟 Binance 期货行情接口（价格固定为 price）
+func newBinanceTestServer(t *testing.T, price float64, hits *int32) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(hits, 1)
+		p := fmt.Sprintf("%.2f", price)
+		switch r.URL.Path {
+		case "/fapi/v1/ticker/price":
+			fmt.Fprintf(w, `{"symbol":%q,"price":%q}`, r.URL.Query().Get("symbol"), p)
+		case "/fapi/v1/klines":
+			klines := make([][]interface{}, 100)
+			for i := range klines {
+				open := int64(i) * 180000
+				klines[i] = []interface{}{open, p, p, p, p, "10", open + 179999, "1000", 5, "5", "500", "0"}
+			}
+			json.NewEncoder(w).Encode(klines)
+		case "/fapi/v1/openInterest":
+			fmt.Fprint(w, `{"openInterest":"1000","symbol":"SOLUSDT","time":0}`)
+		case "/fapi/v1/premiumIndex":
+			fmt.Fprint(w, `{"symbol":"SOLUSDT","lastFundingRate":"0.0001"}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// newBybitTestServer 模拟 Bybit 线性合约行情接口（价格固定为 price）
+func newBybitTestServer(t *testing.T, price float64, hits *int32) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(hits, 1)
+		if r.URL.Query().Get("category") != "linear" {
+			http.Error(w, "missing category", http.StatusBadRequest)
+			return
+		}
+		p := fmt.Sprintf("%.2f", price)
+		switch r.URL.Path {
+		case "/v5/market/tickers":
+			fmt.Fprintf(w, `{"retCode":0,"result":{"list":[{"lastPrice":%q,"fundingRate":"0.0002","bid1Price":%q,"ask1Price":%q}]}}`, p, p, p)
+		case "/v5/market/kline":
+			list := make([]string, 100)
+			for i := range list {
+				list[i] = fmt.Sprintf(`{"startTime":"%d","open":%q,"high":%q,"low":%q,"close":%q,"volume":"10","turnover":"1000"}`, int64(i)*180000, p, p, p, p)
+			}
+			fmt.Fprintf(w, `{"retCode":0,"result":{"category":"linear","list":[%s]}}`, strings.Join(list, ","))
+		case "/v5/market/open-interest":
+			fmt.Fprint(w, `{"retCode":0,"result":{"openInterest":"2000"}}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// useTestBaseURL 把数据源指向测试服务器（测试结束后恢复）
+func useTestBaseURL(t *testing.T, source DataSource, url string) {
+	cfg := dataSourceConfigs[source]
+	prev := cfg.BaseURL
+	cfg.BaseURL = url
+	t.Cleanup(func() { cfg.BaseURL = prev })
+}
+
+// TestGetFrom_PerTraderDataSource 测试配置了不同数据源的两个交易员各自从对应交易所获取价格，互不影响全局数据源
+func TestGetFrom_PerTraderDataSource(t *testing.T) {
+	var binanceHits, bybitHits int32
+	useTestBaseURL(t, DataSourceBinance, newBinanceTestServer(t, 100, &binanceHits).URL)
+	useTestBaseURL(t, DataSourceBybit, newBybitTestServer(t, 50, &bybitHits).URL)
+
+	// 全局数据源为第三个交易所，两个交易员都走各自数据源的 REST 接口
+	prevSource := currentDataSource
+	currentDataSource = DataSourceHyperliquid
+	defer func() { currentDataSource = prevSource }()
+
+	binanceData, err := GetFrom(DataSourceBinance, "SOLUSDT")
+	if err != nil {
+		t.Fatalf("GetFrom(binance): %v", err)
+	}
+	if binanceData.CurrentPrice != 100 {
+		t.Errorf("binance 交易员价格 = %.2f, want 100", binanceData.CurrentPrice)
+	}
+	if bybitHits != 0 {
+		t.Errorf("binance 交易员不应请求 Bybit（%d 次）", bybitHits)
+	}
+
+	bybitData, err := GetFrom(DataSourceBybit, "SOLUSDT")
+	if err != nil {
+		t.Fatalf("GetFrom(bybit): %v", err)
+	}
+	if bybitData.CurrentPrice != 50 {
+		t.Errorf("bybit 交易员价格 = %.2f, want 50", bybitData.CurrentPrice)
+	}
+	if bybitHits == 0 {
+		t.Error("bybit 交易员应请求 Bybit")
+	}
+
+	// 资金费率缓存按数据源区分
+	if binanceData.FundingRate != 0.0001 || bybitData.FundingRate != 0.0002 {
+		t.Errorf("资金费率 = %v / %v, want 0.0001 / 0.0002", binanceData.FundingRate, bybitData.FundingRate)
+	}
+
+	for source, want := range map[DataSource]float64{DataSourceBinance: 100, DataSourceBybit: 50} {
+		price, err := NewAPIClientFor(source).GetCurrentPrice("SOLUSDT")
+		if err != nil || price != want {
+			t.Errorf("%s GetCurrentPrice = %.2f, %v, want %.2f", source, price, err, want)
+		}
+	}
+	if GetCurrentDataSource() != DataSourceHyperliquid {
+		t.Errorf("全局数据源被修改为 %s", GetCurrentDataSource())
+	}
+}
+
+// TestParseDataSource 测试数据源名称校验
+func TestParseDataSource(t *testing.T) {
+	if s, err := ParseDataSource(""); err != nil || s != "" {
+		t.Errorf(`ParseDataSource("") = %q, %v`, s, err)
+	}
+	if s, err := ParseDataSource(" Bybit "); err != nil || s != DataSourceBybit {
+		t.Errorf(`ParseDataSource(" Bybit ") = %q, %v`, s, err)
+	}
+	if _, err := ParseDataSource("kraken"); err == nil {
+		t.Error("未知数据源应返回错误")
+	}
+}
+
+// TestTicker24hrURL 测试24小时行情列表URL按数据源选择，不支持的数据源返回错误
+func TestTicker24hrURL(t *testing.T) {
+	if url, err := ticker24hrURL(DataSourceBinance); err != nil || url != "https://fapi.binance.com/fapi/v1/ticker/24hr" {
+		t.Errorf("ticker24hrURL(binance) = %q, %v", url, err)
+	}
+	if url, err := ticker24hrURL(DataSourceBinanceUS); err != nil || url != "https://api.binance.us/api/v3/ticker/24hr" {
+		t.Errorf("ticker24hrURL(binance_us) = %q, %v", url, err)
+	}
+	if _, err := ticker24hrURL(DataSourceBybit); err == nil {
+		t.Error("Bybit 不提供 Binance 格式的行情列表，应返回错误")
+	}
+}
+
+// TestGetSnapshot_RESTOnlyForUnsubscribedSymbol 测试未订阅的币种只通过 REST 获取快照，不新增 WebSocket 订阅
+func TestGetSnapshot_RESTOnlyForUnsubscribedSymbol(t *testing.T) {
+	var hits int32
+	useTestBaseURL(t, DataSourceBinance, newBinanceTestServer(t, 35, &hits).URL)
+	prevSource, prevMonitor := currentDataSource, WSMonitorCli
+	currentDataSource = DataSourceBinance
+	WSMonitorCli = NewWSMonitor(1)
+	defer func() { currentDataSource, WSMonitorCli = prevSource, prevMonitor }()
+
+	data, err := GetSnapshot("avax")
+	if err != nil {
+		t.Fatalf("GetSnapshot: %v", err)
+	}
+	if data.Symbol != "AVAXUSDT" || data.CurrentPrice != 35 {
+		t.Errorf("snapshot = %s %.2f, want AVAXUSDT 35", data.Symbol, data.CurrentPrice)
+	}
+	if hits == 0 {
+		t.Error("未订阅的币种应通过 REST 获取")
+	}
+	if WSMonitorCli.HasKlines("AVAXUSDT") {
+		t.Error("快照不应写入 WebSocket 缓存（不动态订阅）")
+	}
+}
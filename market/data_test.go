@@ -98,6 +98,49 @@ func TestCalculateIntradaySeries_VolumeCollection(t *testing.T) {
 	}
 }
 
+// TestCalculateIntradaySeries_ConfiguredLength 测试日内序列长度可配置，指标仍遵守最小回看周期
+func TestCalculateIntradaySeries_ConfiguredLength(t *testing.T) {
+	defer SetIntradaySeriesLength(0)
+
+	tests := []struct {
+		name       string
+		length     int
+		klineCount int
+		wantLen    int // 价格/成交量序列长度
+		wantMACD   int // MACD 需要至少 26 根K线
+	}{
+		{"默认10个点", 0, 100, 10, 10},
+		{"配置30个点", 30, 100, 30, 30},
+		{"配置80个点，MACD受回看周期限制", 80, 100, 80, 75},
+		{"K线不足时返回全部", 30, 20, 20, 0},
+		{"超过上限截断为100", 500, 100, 100, 75},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			SetIntradaySeriesLength(tt.length)
+			klines := generateTestKlines(tt.klineCount)
+			data := calculateIntradaySeries(klines)
+
+			if len(data.MidPrices) != tt.wantLen || len(data.Volume) != tt.wantLen {
+				t.Errorf("MidPrices/Volume length = %d/%d, want %d", len(data.MidPrices), len(data.Volume), tt.wantLen)
+			}
+			if len(data.MACDValues) != tt.wantMACD {
+				t.Errorf("MACDValues length = %d, want %d", len(data.MACDValues), tt.wantMACD)
+			}
+			if data.MidPrices[len(data.MidPrices)-1] != klines[len(klines)-1].Close {
+				t.Errorf("last mid price should be the latest close")
+			}
+			// 每个指标值都基于满足回看周期的K线计算
+			for _, v := range data.EMA20Values {
+				if v == 0 {
+					t.Errorf("EMA20 series contains a value computed without enough lookback")
+				}
+			}
+		})
+	}
+}
+
 // TestCalculateIntradaySeries_VolumeValues 测试 Volume 值的正确性
 func TestCalculateIntradaySeries_VolumeValues(t *testing.T) {
 	klines := []Kline{
@@ -347,3 +390,24 @@ func TestCalculateIntradaySeries_VolumePrecision(t *testing.T) {
 		}
 	}
 }
+
+// TestParseBookTicker 测试盘口解析和价差计算
+func TestParseBookTicker(t *testing.T) {
+	book, err := parseBookTicker(DataSourceBinance, "BTCUSDT", []byte(`{"symbol":"BTCUSDT","bidPrice":"49995.00","bidQty":"1","askPrice":"50005.00","askQty":"1"}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if spread := book.SpreadBps(); math.Abs(spread-2) > 1e-9 {
+		t.Errorf("SpreadBps = %.4f, want 2", spread)
+	}
+
+	for _, body := range []string{
+		`{"bidPrice":"0","askPrice":"1"}`,
+		`{"bidPrice":"2","askPrice":"1"}`,
+		`{"bidPrice":"x","askPrice":"1"}`,
+	} {
+		if _, err := parseBookTicker(DataSourceBinance, "BTCUSDT", []byte(body)); err == nil {
+			t.Errorf("%s 应返回错误", body)
+		}
+	}
+}
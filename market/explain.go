@@ -0,0 +1,197 @@
+package market
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// 指标信号倾向
+const (
+	BiasBullish = "bullish"
+	BiasBearish = "bearish"
+	BiasNeutral = "neutral"
+)
+
+// 趋势方向
+const (
+	TrendUp       = "up"
+	TrendDown     = "down"
+	TrendSideways = "sideways"
+)
+
+// IndicatorReading 单个指标当前的解读
+type IndicatorReading struct {
+	Name  string  `json:"name"`
+	Value float64 `json:"value"`
+	Bias  string  `json:"bias"` // bullish / bearish / neutral
+	Note  string  `json:"note"` // 可读说明
+}
+
+// Explanation 币种指标解读：各指标当前看多/看空/中性以及汇总说明（只根据已有的市场数据推导，不调用AI）
+type Explanation struct {
+	Symbol     string             `json:"symbol"`
+	Price      float64            `json:"price"`
+	Trend      string             `json:"trend"` // 趋势类指标的多数方向：up / down / sideways
+	Bias       string             `json:"bias"`  // 全部指标的多数倾向
+	Bullish    int                `json:"bullish"`
+	Bearish    int                `json:"bearish"`
+	Neutral    int                `json:"neutral"`
+	Indicators []IndicatorReading `json:"indicators"`
+	Summary    string             `json:"summary"`
+}
+
+// biasName 倾向的中文名称
+func biasName(bias string) string {
+	switch bias {
+	case BiasBullish:
+		return "看多"
+	case BiasBearish:
+		return "看空"
+	}
+	return "中性"
+}
+
+// directionBias 趋势方向（1/-1/0）对应的倾向
+func directionBias(direction int) string {
+	switch {
+	case direction > 0:
+		return BiasBullish
+	case direction < 0:
+		return BiasBearish
+	}
+	return BiasNeutral
+}
+
+// validReading 指标值是否有效（NaN/Inf 表示数据不足，跳过该指标）
+func validReading(v float64) bool {
+	return !math.IsNaN(v) && !math.IsInf(v, 0)
+}
+
+// Explain 把市场数据中的主要指标解读为看多/看空/中性，并汇总趋势方向和整体倾向
+func Explain(data *Data) *Explanation {
+	p := data.Indicators.WithDefaults()
+	exp := &Explanation{Symbol: data.Symbol, Price: data.CurrentPrice}
+	trendVotes := 0
+	add := func(name string, value float64, bias, note string, trend bool) {
+		exp.Indicators = append(exp.Indicators, IndicatorReading{Name: name, Value: value, Bias: bias, Note: note})
+		if trend {
+			switch bias {
+			case BiasBullish:
+				trendVotes++
+			case BiasBearish:
+				trendVotes--
+			}
+		}
+	}
+
+	// 趋势类指标
+	if data.CurrentEMA20 > 0 && validReading(data.CurrentEMA20) {
+		name := fmt.Sprintf("EMA%d", p.EMAFast)
+		switch {
+		case data.CurrentPrice > data.CurrentEMA20:
+			add(name, data.CurrentEMA20, BiasBullish, fmt.Sprintf("价格位于 %s 上方，短期趋势向上", name), true)
+		case data.CurrentPrice < data.CurrentEMA20:
+			add(name, data.CurrentEMA20, BiasBearish, fmt.Sprintf("价格位于 %s 下方，短期趋势向下", name), true)
+		default:
+			add(name, data.CurrentEMA20, BiasNeutral, fmt.Sprintf("价格与 %s 持平", name), true)
+		}
+	}
+	if data.SARTrend != 0 {
+		note := "SAR 位于价格下方，处于上升趋势"
+		if data.SARTrend < 0 {
+			note = "SAR 位于价格上方，处于下降趋势"
+		}
+		if data.SARFlipped {
+			note += "（本根K线刚反转）"
+		}
+		add("SAR", data.SARValue, directionBias(data.SARTrend), note, true)
+	}
+	if lt := data.LongerTermContext; lt != nil && lt.EMA20 > 0 && lt.EMA50 > 0 {
+		switch {
+		case lt.EMA20 > lt.EMA50:
+			add("EMA20/EMA50 (4h)", lt.EMA20-lt.EMA50, BiasBullish, "4小时 EMA20 高于 EMA50，中期趋势向上", true)
+		case lt.EMA20 < lt.EMA50:
+			add("EMA20/EMA50 (4h)", lt.EMA20-lt.EMA50, BiasBearish, "4小时 EMA20 低于 EMA50，中期趋势向下", true)
+		}
+	}
+	if data.ZeroLagTrend != 0 {
+		note := "零滞后趋势向上"
+		if data.ZeroLagTrend < 0 {
+			note = "零滞后趋势向下"
+		}
+		add("ZeroLag", data.ZeroLagZLEMA, directionBias(data.ZeroLagTrend), note, true)
+	}
+
+	// 动量类指标
+	if validReading(data.CurrentMACD) && data.CurrentMACD != 0 {
+		if data.CurrentMACD > 0 {
+			add("MACD", data.CurrentMACD, BiasBullish, "MACD 为正，动量偏多", false)
+		} else {
+			add("MACD", data.CurrentMACD, BiasBearish, "MACD 为负，动量偏空", false)
+		}
+	}
+	if validReading(data.CurrentRSI7) && data.CurrentRSI7 > 0 {
+		name := fmt.Sprintf("RSI%d", p.RSIShort)
+		switch {
+		case data.CurrentRSI7 >= 70:
+			add(name, data.CurrentRSI7, BiasBearish, fmt.Sprintf("RSI %.1f 超买（≥70），存在回调风险", data.CurrentRSI7), false)
+		case data.CurrentRSI7 <= 30:
+			add(name, data.CurrentRSI7, BiasBullish, fmt.Sprintf("RSI %.1f 超卖（≤30），存在反弹可能", data.CurrentRSI7), false)
+		default:
+			add(name, data.CurrentRSI7, BiasNeutral, fmt.Sprintf("RSI %.1f 处于中性区间（30-70）", data.CurrentRSI7), false)
+		}
+	}
+	if validReading(data.CurrentWilliamsR) && data.CurrentWilliamsR < 0 {
+		switch {
+		case data.CurrentWilliamsR > -20:
+			add("Williams %R", data.CurrentWilliamsR, BiasBearish, "Williams %R 高于 -20，超买", false)
+		case data.CurrentWilliamsR < -80:
+			add("Williams %R", data.CurrentWilliamsR, BiasBullish, "Williams %R 低于 -80，超卖", false)
+		default:
+			add("Williams %R", data.CurrentWilliamsR, BiasNeutral, "Williams %R 处于中性区间", false)
+		}
+	}
+	if validReading(data.CurrentMFI) && data.CurrentMFI > 0 {
+		switch {
+		case data.CurrentMFI > 80:
+			add("MFI", data.CurrentMFI, BiasBearish, "MFI 高于 80，资金流超买", false)
+		case data.CurrentMFI < 20:
+			add("MFI", data.CurrentMFI, BiasBullish, "MFI 低于 20，资金流超卖", false)
+		default:
+			add("MFI", data.CurrentMFI, BiasNeutral, "MFI 处于中性区间", false)
+		}
+	}
+
+	for _, r := range exp.Indicators {
+		switch r.Bias {
+		case BiasBullish:
+			exp.Bullish++
+		case BiasBearish:
+			exp.Bearish++
+		default:
+			exp.Neutral++
+		}
+	}
+	exp.Bias = BiasNeutral
+	if exp.Bullish > exp.Bearish {
+		exp.Bias = BiasBullish
+	} else if exp.Bearish > exp.Bullish {
+		exp.Bias = BiasBearish
+	}
+	exp.Trend = TrendSideways
+	trendName := "震荡（趋势指标没有一致方向）"
+	if trendVotes > 0 {
+		exp.Trend, trendName = TrendUp, "向上"
+	} else if trendVotes < 0 {
+		exp.Trend, trendName = TrendDown, "向下"
+	}
+
+	lines := []string{fmt.Sprintf("%s 当前价格 %s，趋势方向%s；%d 项看多、%d 项看空、%d 项中性，整体%s。",
+		data.Symbol, formatSymbolPrice(data.Symbol, data.CurrentPrice), trendName, exp.Bullish, exp.Bearish, exp.Neutral, biasName(exp.Bias))}
+	for _, r := range exp.Indicators {
+		lines = append(lines, fmt.Sprintf("- %s（%s）: %s", r.Name, biasName(r.Bias), r.Note))
+	}
+	exp.Summary = strings.Join(lines, "\n")
+	return exp
+}
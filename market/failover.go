@@ -0,0 +1,200 @@
+package market
+
+import (
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// defaultFailoverThreshold 主数据源连续失败多少次后切换到备用数据源
+const defaultFailoverThreshold = 3
+
+// failoverProbeInterval 切换到备用数据源后探测主数据源是否恢复的间隔
+const failoverProbeInterval = 5 * time.Minute
+
+// wsStaleAfter WebSocket 超过该时长没有收到任何消息时视为主数据源推送中断
+const wsStaleAfter = 2 * time.Minute
+
+// errWSStale 主数据源的 WebSocket 推送中断（缓存的K线不再更新）
+var errWSStale = errors.New("WebSocket 推送中断，K线缓存已过期")
+
+// dataSourceFailover 主数据源故障切换：全局数据源连续发生数据源级故障（网络错误、5xx/429、WebSocket 推送中断）
+// 达到阈值后，把全局数据源热切换为备用数据源；单个币种的错误（如不存在的交易对、K线不足）不计入。
+// 切换后定期探测主数据源，恢复后自动切回
+type dataSourceFailover struct {
+	mu        sync.Mutex
+	secondary DataSource // 为空表示未启用
+	threshold int
+	primary   DataSource // 开始统计失败时的全局数据源
+	failures  int        // 主数据源连续失败次数
+	active    bool       // 是否已切换到备用数据源
+	lastProbe time.Time  // 最近一次探测主数据源的时间
+	probing   bool       // 是否正在探测主数据源
+
+	now   func() time.Time
+	probe func(source DataSource) error // 探测数据源是否可用（测试可替换）
+}
+
+// newDataSourceFailover 创建故障切换状态（未启用）
+func newDataSourceFailover() *dataSourceFailover {
+	return &dataSourceFailover{threshold: defaultFailoverThreshold, now: time.Now, probe: probeDataSource}
+}
+
+// dataFailover 全局数据源故障切换状态
+var dataFailover = newDataSourceFailover()
+
+// SetDataSourceFailover 设置备用数据源（空字符串关闭故障切换）和触发切换的连续失败次数（非正数使用默认值 3）。
+// 备用数据源与当前全局数据源相同或名称无效时不启用
+func SetDataSourceFailover(secondary string, threshold int) {
+	ds, err := ParseDataSource(secondary)
+	if err != nil {
+		log.Printf("⚠️  [Market] 备用数据源无效，不启用故障切换: %v", err)
+		ds = ""
+	}
+	primary := GetCurrentDataSource()
+	if ds == primary {
+		log.Printf("⚠️  [Market] 备用数据源与主数据源相同 (%s)，不启用故障切换", ds)
+		ds = ""
+	}
+	if threshold <= 0 {
+		threshold = defaultFailoverThreshold
+	}
+
+	dataFailover.mu.Lock()
+	defer dataFailover.mu.Unlock()
+	dataFailover.secondary = ds
+	dataFailover.threshold = threshold
+	dataFailover.primary = primary
+	dataFailover.failures = 0
+	dataFailover.active = false
+	if ds != "" {
+		log.Printf("📊 [Market] 备用数据源: %s（主数据源 %s 连续 %d 次数据源故障后切换，恢复后自动切回）", ds, primary, threshold)
+	}
+}
+
+// wsSource WebSocket 连接的数据源：已切换到备用数据源时为主数据源，否则为全局数据源
+func (f *dataSourceFailover) wsSource() DataSource {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.active {
+		return f.primary
+	}
+	return GetCurrentDataSource()
+}
+
+// isSourceLevelError 是否为数据源整体故障：网络/连接失败、5xx 或 429 状态码、WebSocket 推送中断。
+// 单个币种的错误（如交易对不存在返回 400、K线数据不足）不表示数据源不可用
+func isSourceLevelError(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, errWSStale) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	var se *sourceError
+	if errors.As(err, &se) {
+		switch se.class {
+		case errClassHTTP:
+			return true
+		case errClassStatus:
+			return se.status >= http.StatusInternalServerError || se.status == http.StatusTooManyRequests
+		}
+	}
+	return false
+}
+
+// wsStale 主数据源的 WebSocket 是否已长时间没有收到消息（尚未收到过消息时不视为中断）
+func wsStale(now time.Time) bool {
+	if WSMonitorCli == nil || WSMonitorCli.combinedClient == nil {
+		return false
+	}
+	last := WSMonitorCli.combinedClient.LastMessageAt()
+	return !last.IsZero() && now.Sub(last) > wsStaleAfter
+}
+
+// redirect 已切换到备用数据源时，原本使用主数据源（或跟随全局数据源）的请求返回备用数据源，否则返回空；
+// 距上次探测超过探测间隔时在后台探测主数据源是否恢复
+func (f *dataSourceFailover) redirect(source DataSource) DataSource {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !f.active || (source != "" && source != f.primary) {
+		return ""
+	}
+	if !f.probing && f.now().Sub(f.lastProbe) >= failoverProbeInterval {
+		f.probing = true
+		go f.probePrimary()
+	}
+	return f.secondary
+}
+
+// record 记录一次全局数据源的获取结果，连续发生数据源级故障达到阈值时切换到备用数据源。
+// 单个币种的错误不计入也不清零连续失败次数
+func (f *dataSourceFailover) record(err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.secondary == "" || f.active {
+		return
+	}
+	if err == nil {
+		f.failures = 0
+		return
+	}
+	if !isSourceLevelError(err) {
+		return
+	}
+	f.failures++
+	if f.failures < f.threshold {
+		return
+	}
+
+	f.active = true
+	f.lastProbe = f.now()
+	dataSourceMu.Lock()
+	currentDataSource = f.secondary
+	dataSourceMu.Unlock()
+	log.Printf("🔀 [Market] 主数据源 %s 连续 %d 次获取行情失败（最近一次: %v），已切换到备用数据源 %s", f.primary, f.failures, err, f.secondary)
+}
+
+// probePrimary 探测主数据源（REST 接口可用且 WebSocket 推送未中断），恢复后切回主数据源
+func (f *dataSourceFailover) probePrimary() {
+	f.mu.Lock()
+	primary := f.primary
+	f.mu.Unlock()
+
+	err := f.probe(primary)
+	if err == nil && wsStale(f.now()) {
+		err = errWSStale
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.probing = false
+	f.lastProbe = f.now()
+	if !f.active || primary != f.primary {
+		return
+	}
+	if err != nil && isSourceLevelError(err) {
+		log.Printf("⚠️  [Market] 主数据源 %s 仍不可用: %v，继续使用备用数据源 %s", primary, err, f.secondary)
+		return
+	}
+
+	f.active = false
+	f.failures = 0
+	dataSourceMu.Lock()
+	currentDataSource = primary
+	dataSourceMu.Unlock()
+	log.Printf("🔀 [Market] 主数据源 %s 已恢复，切回主数据源", primary)
+}
+
+// probeDataSource 通过 REST 接口获取一根K线探测数据源是否可用
+func probeDataSource(source DataSource) error {
+	_, err := NewAPIClientFor(source).GetKlines("BTCUSDT", "3m", 1)
+	return err
+}
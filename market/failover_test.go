@@ -0,0 +1,247 @@
+package market
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newDownTestServer 模拟不可用的数据源（所有请求返回 503）
+func newDownTestServer(t *testing.T, hits *int32) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(hits, 1)
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// useFailoverState 使用独立的全局数据源和故障切换状态（测试结束后恢复）
+func useFailoverState(t *testing.T, primary DataSource) {
+	prevSource, prevMonitor, prevFailover := currentDataSource, WSMonitorCli, dataFailover
+	currentDataSource = primary
+	WSMonitorCli = NewWSMonitor(1)
+	dataFailover = newDataSourceFailover()
+	t.Cleanup(func() { currentDataSource, WSMonitorCli, dataFailover = prevSource, prevMonitor, prevFailover })
+}
+
+// TestDataSourceFailover_SwitchesToSecondary 测试主数据源连续失败达到阈值后切换到备用数据源并恢复获取行情
+func TestDataSourceFailover_SwitchesToSecondary(t *testing.T) {
+	var binanceHits, bybitHits int32
+	useTestBaseURL(t, DataSourceBinance, newDownTestServer(t, &binanceHits).URL)
+	useTestBaseURL(t, DataSourceBybit, newBybitTestServer(t, 50, &bybitHits).URL)
+	useFailoverState(t, DataSourceBinance)
+	SetDataSourceFailover("bybit", 3)
+
+	for i := 1; i <= 3; i++ {
+		if _, err := GetFrom("", "SOLUSDT"); err == nil {
+			t.Fatalf("第 %d 次请求：主数据源不可用时应返回错误", i)
+		}
+		if i < 3 && GetCurrentDataSource() != DataSourceBinance {
+			t.Fatalf("第 %d 次失败后不应切换（阈值 3），当前数据源 %s", i, GetCurrentDataSource())
+		}
+	}
+	if GetCurrentDataSource() != DataSourceBybit {
+		t.Fatalf("连续失败 3 次后应切换到 bybit，当前数据源 %s", GetCurrentDataSource())
+	}
+	if bybitHits != 0 {
+		t.Errorf("切换前不应请求备用数据源（%d 次）", bybitHits)
+	}
+
+	// 切换后跟随全局数据源和显式配置主数据源的交易员都从备用数据源获取行情
+	for _, source := range []DataSource{"", DataSourceBinance} {
+		data, err := GetFrom(source, "SOLUSDT")
+		if err != nil {
+			t.Fatalf("切换后 GetFrom(%q): %v", source, err)
+		}
+		if data.CurrentPrice != 50 {
+			t.Errorf("切换后 GetFrom(%q) 价格 = %.2f, want 50（备用数据源）", source, data.CurrentPrice)
+		}
+	}
+
+	before := atomic.LoadInt32(&binanceHits)
+	if _, err := GetWithIndicators("", "SOLUSDT", IndicatorParams{RSIShort: 9}); err != nil {
+		t.Fatalf("切换后 GetWithIndicators: %v", err)
+	}
+	if atomic.LoadInt32(&binanceHits) != before {
+		t.Error("切换后不应再请求主数据源")
+	}
+}
+
+// TestDataSourceFailover_SuccessResetsFailures 测试主数据源偶发失败（未连续达到阈值）不会切换
+func TestDataSourceFailover_SuccessResetsFailures(t *testing.T) {
+	var downHits, upHits, bybitHits int32
+	down := newDownTestServer(t, &downHits).URL
+	up := newBinanceTestServer(t, 100, &upHits).URL
+	useTestBaseURL(t, DataSourceBinance, down)
+	useTestBaseURL(t, DataSourceBybit, newBybitTestServer(t, 50, &bybitHits).URL)
+	useFailoverState(t, DataSourceBinance)
+	SetDataSourceFailover("bybit", 2)
+
+	if _, err := GetFrom("", "SOLUSDT"); err == nil {
+		t.Fatal("主数据源不可用时应返回错误")
+	}
+	dataSourceConfigs[DataSourceBinance].BaseURL = up
+	if data, err := GetFrom("", "SOLUSDT"); err != nil || data.CurrentPrice != 100 {
+		t.Fatalf("主数据源恢复后应正常获取: %v", err)
+	}
+	dataSourceConfigs[DataSourceBinance].BaseURL = down
+	if _, err := GetFrom("", "ETHUSDT"); err == nil {
+		t.Fatal("主数据源不可用时应返回错误")
+	}
+
+	if GetCurrentDataSource() != DataSourceBinance {
+		t.Errorf("失败次数被成功请求重置，不应切换，当前数据源 %s", GetCurrentDataSource())
+	}
+	if bybitHits != 0 {
+		t.Errorf("未切换时不应请求备用数据源（%d 次）", bybitHits)
+	}
+}
+
+// TestSetDataSourceFailover_Disabled 测试备用数据源为空、无效或与主数据源相同时不启用故障切换
+func TestSetDataSourceFailover_Disabled(t *testing.T) {
+	useFailoverState(t, DataSourceBinance)
+	for _, secondary := range []string{"", "kraken", "binance"} {
+		SetDataSourceFailover(secondary, 1)
+		dataFailover.record(newStatusError(http.StatusServiceUnavailable, "binance API返回错误状态码 503"))
+		if GetCurrentDataSource() != DataSourceBinance {
+			t.Fatalf("备用数据源 %q 不应触发切换，当前数据源 %s", secondary, GetCurrentDataSource())
+		}
+	}
+}
+
+// TestDataSourceFailover_PerSymbolErrorsDoNotCount 测试单个币种的错误（交易对不存在返回 400）不计入故障切换，也不清零连续失败次数
+func TestDataSourceFailover_PerSymbolErrorsDoNotCount(t *testing.T) {
+	var bybitHits int32
+	badSymbol := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"code":-1121,"msg":"Invalid symbol."}`, http.StatusBadRequest)
+	}))
+	t.Cleanup(badSymbol.Close)
+	useTestBaseURL(t, DataSourceBinance, badSymbol.URL)
+	useTestBaseURL(t, DataSourceBybit, newBybitTestServer(t, 50, &bybitHits).URL)
+	useFailoverState(t, DataSourceBinance)
+	SetDataSourceFailover("bybit", 2)
+
+	for i := 0; i < 5; i++ {
+		if _, err := GetFrom("", "NOTACOINUSDT"); err == nil {
+			t.Fatal("不存在的交易对应返回错误")
+		}
+	}
+	if GetCurrentDataSource() != DataSourceBinance {
+		t.Fatalf("单个币种的错误不应触发切换，当前数据源 %s", GetCurrentDataSource())
+	}
+
+	dataFailover.record(newStatusError(http.StatusServiceUnavailable, "binance API返回错误状态码 503"))
+	dataFailover.record(fmt.Errorf("获取3分钟K线失败: %w", newStatusError(http.StatusBadRequest, "Invalid symbol")))
+	dataFailover.record(newStatusError(http.StatusBadGateway, "binance API返回错误状态码 502"))
+	if GetCurrentDataSource() != DataSourceBybit {
+		t.Errorf("连续 2 次数据源故障（中间的单币种错误不清零）应切换，当前数据源 %s", GetCurrentDataSource())
+	}
+	if bybitHits != 0 {
+		t.Errorf("切换前不应请求备用数据源（%d 次）", bybitHits)
+	}
+}
+
+// TestDataSourceFailover_WSStaleCounts 测试主数据源 WebSocket 推送中断时，即使缓存仍可读取也计入故障并切换
+func TestDataSourceFailover_WSStaleCounts(t *testing.T) {
+	var binanceHits, bybitHits int32
+	useTestBaseURL(t, DataSourceBinance, newBinanceTestServer(t, 100, &binanceHits).URL)
+	useTestBaseURL(t, DataSourceBybit, newBybitTestServer(t, 50, &bybitHits).URL)
+	useFailoverState(t, DataSourceBinance)
+	SetDataSourceFailover("bybit", 2)
+
+	WSMonitorCli.combinedClient.lastMessage.Store(time.Now().Add(-time.Minute).UnixNano())
+	if data, err := GetFrom("", "SOLUSDT"); err != nil || data.CurrentPrice != 100 {
+		t.Fatalf("推送正常时从主数据源获取: %v", err)
+	}
+	WSMonitorCli.combinedClient.lastMessage.Store(time.Now().Add(-10 * time.Minute).UnixNano())
+	for i := 0; i < 2; i++ {
+		if data, err := GetFrom("", "SOLUSDT"); err != nil || data.CurrentPrice != 100 {
+			t.Fatalf("推送中断时缓存数据照常返回: %v", err)
+		}
+	}
+	if GetCurrentDataSource() != DataSourceBybit {
+		t.Errorf("推送中断 2 次后应切换到 bybit，当前数据源 %s", GetCurrentDataSource())
+	}
+}
+
+// TestDataSourceFailover_ProbesBackToPrimary 测试切换后按探测间隔探测主数据源，主数据源恢复后切回
+func TestDataSourceFailover_ProbesBackToPrimary(t *testing.T) {
+	useFailoverState(t, DataSourceBinance)
+	SetDataSourceFailover("bybit", 1)
+	clock := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	dataFailover.now = func() time.Time { return clock }
+	probes := make(chan DataSource, 1)
+	probeErr := error(newStatusError(http.StatusServiceUnavailable, "binance API返回错误状态码 503"))
+	dataFailover.probe = func(source DataSource) error {
+		probes <- source
+		return probeErr
+	}
+
+	dataFailover.record(newStatusError(http.StatusServiceUnavailable, "binance API返回错误状态码 503"))
+	if GetCurrentDataSource() != DataSourceBybit {
+		t.Fatalf("应切换到 bybit，当前数据源 %s", GetCurrentDataSource())
+	}
+
+	// 未到探测间隔不探测
+	clock = clock.Add(failoverProbeInterval - time.Second)
+	if got := dataFailover.redirect(""); got != DataSourceBybit {
+		t.Fatalf("redirect = %s, want bybit", got)
+	}
+	select {
+	case <-probes:
+		t.Fatal("未到探测间隔不应探测主数据源")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// 到达探测间隔后在后台探测主数据源，仍不可用时继续使用备用数据源
+	clock = clock.Add(time.Second)
+	dataFailover.redirect("")
+	select {
+	case source := <-probes:
+		if source != DataSourceBinance {
+			t.Fatalf("探测的数据源 = %s, want binance", source)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("到达探测间隔应探测主数据源")
+	}
+	waitFor(t, func() bool {
+		dataFailover.mu.Lock()
+		defer dataFailover.mu.Unlock()
+		return !dataFailover.probing
+	})
+	if GetCurrentDataSource() != DataSourceBybit {
+		t.Fatalf("主数据源仍不可用时不应切回，当前数据源 %s", GetCurrentDataSource())
+	}
+
+	// 主数据源恢复（单个币种的错误也表示数据源可访问）后切回
+	probeErr = newStatusError(http.StatusBadRequest, "Invalid symbol")
+	clock = clock.Add(failoverProbeInterval)
+	dataFailover.redirect("")
+	<-probes
+	waitFor(t, func() bool { return GetCurrentDataSource() == DataSourceBinance })
+	if got := dataFailover.redirect(""); got != "" {
+		t.Errorf("切回后不应再重定向到备用数据源: %s", got)
+	}
+
+	// 切回后重新统计连续失败
+	dataFailover.record(newStatusError(http.StatusServiceUnavailable, "binance API返回错误状态码 503"))
+	if GetCurrentDataSource() != DataSourceBybit {
+		t.Errorf("切回后再次故障应重新切换，当前数据源 %s", GetCurrentDataSource())
+	}
+}
+
+// waitFor 等待条件成立（后台探测在 goroutine 中完成）
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("等待超时")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
@@ -0,0 +1,267 @@
+package market
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Section 市场数据提示词的分段
+type Section string
+
+// 分段按以下固定顺序输出（formatSections），新增内容应归入已有分段或在列表中明确插入，
+// 不要追加零散的输出，保证提示词变更在 testdata/format.golden 中是可审查的差异
+const (
+	SectionHeader      Section = "header"      // 当前价格和主要指标
+	SectionDerivatives Section = "derivatives" // 持仓量和资金费率
+	SectionIntraday    Section = "intraday"    // 3分钟日内序列
+	SectionLongerTerm  Section = "longer_term" // 4小时长期背景
+	SectionIndicators  Section = "indicators"  // 脚本 #1-#10 附加指标
+	SectionPatterns    Section = "patterns"    // K线形态信号
+)
+
+// SectionFlags 分段开关（未列出的分段默认输出，false 表示关闭）
+type SectionFlags map[Section]bool
+
+// Enabled 分段是否输出
+func (f SectionFlags) Enabled(s Section) bool {
+	enabled, ok := f[s]
+	return !ok || enabled
+}
+
+// formatSection 提示词分段及其渲染函数
+type formatSection struct {
+	name   Section
+	render func(sb *strings.Builder, data *Data)
+}
+
+// formatSections 分段注册表（按输出顺序）
+var formatSections = []formatSection{
+	{SectionHeader, writeHeaderSection},
+	{SectionDerivatives, writeDerivativesSection},
+	{SectionIntraday, writeIntradaySection},
+	{SectionLongerTerm, writeLongerTermSection},
+	{SectionIndicators, writeIndicatorsSection},
+	{SectionPatterns, writePatternsSection},
+}
+
+// Sections 全部分段（按输出顺序）
+func Sections() []Section {
+	out := make([]Section, len(formatSections))
+	for i, s := range formatSections {
+		out[i] = s.name
+	}
+	return out
+}
+
+// Format 格式化输出市场数据（全部分段）
+// 输出只包含 ASCII 字符，避免部分模型对排版符号（不间断连字符、箭头、破折号）分词浪费或解析错误
+func Format(data *Data) string {
+	return FormatSections(data, nil)
+}
+
+// FormatSections 按分段开关格式化输出市场数据
+func FormatSections(data *Data, flags SectionFlags) string {
+	var sb strings.Builder
+	for _, s := range formatSections {
+		if flags.Enabled(s.name) {
+			s.render(&sb, data)
+		}
+	}
+	return sb.String()
+}
+
+// writeHeaderSection 当前价格和主要指标
+func writeHeaderSection(sb *strings.Builder, data *Data) {
+	// 按币种精度格式化价格（未配置时使用动态精度）
+	priceStr := formatSymbolPrice(data.Symbol, data.CurrentPrice)
+	p := data.Indicators.WithDefaults()
+	sb.WriteString(fmt.Sprintf("current_price = %s, current_ema%d = %.3f, current_macd = %.3f, current_rsi (%d period) = %.3f, current_tsi = %.3f, tsi_signal = %.3f\n\n",
+		priceStr, p.EMAFast, data.CurrentEMA20, data.CurrentMACD, p.RSIShort, data.CurrentRSI7, data.CurrentTSI, data.CurrentTSISignal))
+
+	sb.WriteString(fmt.Sprintf("current_williams_r (14 period) = %.3f (above -20 = overbought, below -80 = oversold)\n\n",
+		data.CurrentWilliamsR))
+
+	sb.WriteString(fmt.Sprintf("current_mfi (14 period) = %.3f (above 80 = overbought, below 20 = oversold)\n\n",
+		data.CurrentMFI))
+
+	// 数据不足时（各轨为 0）整行省略
+	if data.BollingerMiddle > 0 {
+		bandPosition := "inside bands"
+		switch {
+		case data.CurrentPrice > data.BollingerUpper:
+			bandPosition = "above upper band"
+		case data.CurrentPrice < data.BollingerLower:
+			bandPosition = "below lower band"
+		}
+		percentB := "n/a"
+		if width := data.BollingerUpper - data.BollingerLower; width > 0 {
+			percentB = fmt.Sprintf("%.3f", (data.CurrentPrice-data.BollingerLower)/width)
+		}
+		sb.WriteString(fmt.Sprintf("bollinger_bands (20, 2.0) = upper %s, middle %s, lower %s, price %s, percent_b = %s (above 1 = above upper band, below 0 = below lower band)\n\n",
+			formatSymbolPrice(data.Symbol, data.BollingerUpper), formatSymbolPrice(data.Symbol, data.BollingerMiddle),
+			formatSymbolPrice(data.Symbol, data.BollingerLower), bandPosition, percentB))
+	}
+
+	sarDirection := "n/a"
+	switch {
+	case data.SARTrend > 0:
+		sarDirection = "below price (uptrend)"
+	case data.SARTrend < 0:
+		sarDirection = "above price (downtrend)"
+	}
+	sb.WriteString(fmt.Sprintf("current_parabolic_sar (0.02/0.2) = %s, %s, flipped_this_bar = %v\n\n",
+		formatSymbolPrice(data.Symbol, data.SARValue), sarDirection, data.SARFlipped))
+}
+
+// writeDerivativesSection 持仓量和资金费率
+// 数据源不提供的数据整行省略并注明不可用，避免模型把 0 当作真实数据；现货数据源（默认）整个部分省略
+func writeDerivativesSection(sb *strings.Builder, data *Data) {
+	if data.SpotOnly && hideDerivativesOnSpot {
+		// 现货数据源没有永续合约，不描述永续合约的持仓量和资金费率
+		return
+	}
+	if data.NoOpenInterest && data.NoFundingRate {
+		sb.WriteString(fmt.Sprintf("Open interest and funding rate for %s are not available from the current market data source (spot data); they are omitted, not zero.\n\n",
+			data.Symbol))
+		return
+	}
+
+	sb.WriteString(fmt.Sprintf("In addition, here is the latest %s open interest and funding rate for perps:\n\n",
+		data.Symbol))
+
+	if data.NoOpenInterest {
+		sb.WriteString("Open interest is not available from the current market data source; it is omitted, not zero.\n\n")
+	} else if data.OpenInterest != nil {
+		// 使用动态精度格式化 OI 数据
+		oiLatestStr := formatPriceWithDynamicPrecision(data.OpenInterest.Latest)
+		oiAverageStr := formatPriceWithDynamicPrecision(data.OpenInterest.Average)
+		sb.WriteString(fmt.Sprintf("Open Interest: Latest: %s Average: %s\n\n",
+			oiLatestStr, oiAverageStr))
+	}
+
+	if data.NoFundingRate {
+		sb.WriteString("Funding rate is not available from the current market data source; it is omitted, not zero.\n\n")
+	} else if age, stale := fundingRateAge(data, time.Now()); stale {
+		sb.WriteString(fmt.Sprintf("Funding Rate: %s (STALE: last updated %d minutes ago, may not reflect the current rate)\n\n",
+			formatFundingRate(data.FundingRate), int(age.Minutes())))
+	} else {
+		sb.WriteString(fmt.Sprintf("Funding Rate: %s\n\n", formatFundingRate(data.FundingRate)))
+	}
+}
+
+// fundingRateAge 资金费率距获取时的时长，以及是否超过最大有效期（获取时间未知时不视为过期）
+func fundingRateAge(data *Data, now time.Time) (time.Duration, bool) {
+	if data.FundingUpdatedAt.IsZero() {
+		return 0, false
+	}
+	age := now.Sub(data.FundingUpdatedAt)
+	return age, age > fundingRateMaxAge
+}
+
+// writeIntradaySection 3分钟日内序列
+func writeIntradaySection(sb *strings.Builder, data *Data) {
+	if data.IntradaySeries == nil {
+		return
+	}
+	sb.WriteString("Intraday series (3-minute intervals, oldest -> latest):\n\n")
+	p := data.Indicators.WithDefaults()
+
+	if len(data.IntradaySeries.MidPrices) > 0 {
+		sb.WriteString(fmt.Sprintf("Mid prices: %s\n\n", formatSymbolPriceSlice(data.Symbol, data.IntradaySeries.MidPrices)))
+	}
+
+	if len(data.IntradaySeries.EMA20Values) > 0 {
+		sb.WriteString(fmt.Sprintf("EMA indicators (%d-period): %s\n\n", p.EMAFast, formatSymbolPriceSlice(data.Symbol, data.IntradaySeries.EMA20Values)))
+	}
+
+	if len(data.IntradaySeries.MACDValues) > 0 {
+		sb.WriteString(fmt.Sprintf("MACD indicators: %s\n\n", formatFloatSlice(data.IntradaySeries.MACDValues)))
+	}
+
+	if len(data.IntradaySeries.RSI7Values) > 0 {
+		sb.WriteString(fmt.Sprintf("RSI indicators (%d-Period): %s\n\n", p.RSIShort, formatFloatSlice(data.IntradaySeries.RSI7Values)))
+	}
+
+	if len(data.IntradaySeries.RSI14Values) > 0 {
+		sb.WriteString(fmt.Sprintf("RSI indicators (%d-Period): %s\n\n", p.RSILong, formatFloatSlice(data.IntradaySeries.RSI14Values)))
+	}
+
+	if len(data.IntradaySeries.Volume) > 0 {
+		sb.WriteString(fmt.Sprintf("Volume: %s\n\n", formatFloatSlice(data.IntradaySeries.Volume)))
+	}
+
+	sb.WriteString(fmt.Sprintf("3m ATR (%d-period): %.3f\n\n", p.ATRLong, data.IntradaySeries.ATR14))
+}
+
+// writeLongerTermSection 4小时长期背景
+func writeLongerTermSection(sb *strings.Builder, data *Data) {
+	if data.LongerTermContext == nil {
+		return
+	}
+	sb.WriteString("Longer-term context (4-hour timeframe):\n\n")
+	p := data.Indicators.WithDefaults()
+
+	sb.WriteString(fmt.Sprintf("%d-Period EMA: %.3f vs. %d-Period EMA: %.3f\n\n",
+		p.EMAFast, data.LongerTermContext.EMA20, p.EMASlow, data.LongerTermContext.EMA50))
+
+	sb.WriteString(fmt.Sprintf("%d-Period ATR: %.3f vs. %d-Period ATR: %.3f\n\n",
+		p.ATRShort, data.LongerTermContext.ATR3, p.ATRLong, data.LongerTermContext.ATR14))
+
+	sb.WriteString(fmt.Sprintf("Current Volume: %.3f vs. Average Volume: %.3f\n\n",
+		data.LongerTermContext.CurrentVolume, data.LongerTermContext.AverageVolume))
+
+	if len(data.LongerTermContext.MACDValues) > 0 {
+		sb.WriteString(fmt.Sprintf("MACD indicators: %s\n\n", formatFloatSlice(data.LongerTermContext.MACDValues)))
+	}
+
+	if len(data.LongerTermContext.RSI14Values) > 0 {
+		sb.WriteString(fmt.Sprintf("RSI indicators (%d-Period): %s\n\n", p.RSILong, formatFloatSlice(data.LongerTermContext.RSI14Values)))
+	}
+}
+
+// writeIndicatorsSection 脚本 #1-#10 附加指标摘要
+func writeIndicatorsSection(sb *strings.Builder, data *Data) {
+	sb.WriteString("Additional indicators (scripts #1-#10):\n\n")
+	aboveSignal := data.CurrentTSI > data.CurrentTSISignal
+	zone := "neutral"
+	if data.CurrentTSI >= 40 {
+		zone = "overbought(>=+40)"
+	} else if data.CurrentTSI <= -40 {
+		zone = "oversold(<=-40)"
+	}
+	sb.WriteString(fmt.Sprintf("TSI: value=%.2f, signal=%.2f, above_signal=%v, zone=%s\n",
+		data.CurrentTSI, data.CurrentTSISignal, aboveSignal, zone))
+	sb.WriteString(fmt.Sprintf("KEMAD: trend=%d, kema=%.3f, atr=%.3f\n",
+		data.KEMADTrend, data.KEMADEMA, data.KEMADATR))
+	sb.WriteString(fmt.Sprintf("Volatility Gaussian Bands: trend=%d, avg=%.3f, upper=%.3f, lower=%.3f, score=%.3f\n",
+		data.VGBTrend, data.VGBAvg, data.VGBUpper, data.VGBLower, data.VGBScore))
+	sb.WriteString(fmt.Sprintf("SSL Hybrid Exit: signal=%d, baseline=%.3f, upperK=%.3f, lowerK=%.3f\n",
+		data.SSLExitSignal, data.SSLBaseline, data.SSLUpperK, data.SSLLowerK))
+	sb.WriteString("Timeframe indicators (4h, 30m):\n")
+	sb.WriteString(fmt.Sprintf("tsi_4h_value=%.2f, tsi_4h_signal=%.2f\n", data.TSI4hValue, data.TSI4hSignal))
+	sb.WriteString(fmt.Sprintf("tsi_30m_value=%.2f, tsi_30m_signal=%.2f\n", data.TSI30mValue, data.TSI30mSignal))
+	sb.WriteString(fmt.Sprintf("ssl_4h_exit=%d, ssl_4h_baseline=%.3f, ssl_4h_upperK=%.3f, ssl_4h_lowerK=%.3f\n", data.SSL4hExitSignal, data.SSL4hBaseline, data.SSL4hUpperK, data.SSL4hLowerK))
+	sb.WriteString(fmt.Sprintf("ssl_30m_exit=%d, ssl_30m_baseline=%.3f, ssl_30m_upperK=%.3f, ssl_30m_lowerK=%.3f\n\n", data.SSL30mExitSignal, data.SSL30mBaseline, data.SSL30mUpperK, data.SSL30mLowerK))
+	sb.WriteString(fmt.Sprintf("Zero-Lag Trend: trend=%d, zlema=%.3f, volatility=%.3f\n",
+		data.ZeroLagTrend, data.ZeroLagZLEMA, data.ZeroLagVolatility))
+	sb.WriteString(fmt.Sprintf("QQE MOD Hybrid: trend=%d, fastTL=%.3f, upper=%.3f, lower=%.3f\n",
+		data.QQETrend, data.QQEFastTL, data.QQEUpper, data.QQELower))
+	sb.WriteString(fmt.Sprintf("Range Filtered: kalman=%.3f, trend=%d, kTrend=%d, combined=%d\n",
+		data.RangeKalman, data.RangeTrend, data.RangeKTrend, data.RangeCombinedTrend))
+	sb.WriteString(fmt.Sprintf("DPSD: trend=%d, pt=%.3f, dema=%.3f, perUp=%.3f, perDown=%.3f\n",
+		data.DPSDTrend, data.DPSDPT, data.DPSDEMA, data.DPSDPerUp, data.DPSDPerDown))
+	sb.WriteString(fmt.Sprintf("Ultimate RSI: value=%.2f, signal=%.2f, overbought=%v, oversold=%v\n\n",
+		data.UltimateRSI, data.UltimateRSISignal, data.UltimateRSIOverbought, data.UltimateRSIOversold))
+}
+
+// writePatternsSection K线形态信号（RSI阈值与吞噬形态）
+func writePatternsSection(sb *strings.Builder, data *Data) {
+	sb.WriteString(fmt.Sprintf("RSI(10) patterns: buy=%v, sell=%v, rsi=%.2f\n\n",
+		data.RSIBuySignal, data.RSISellSignal, data.RSIValue))
+}
+
+// formatFundingRate 资金费率以百分比定点小数输出（不使用科学计数法）
+func formatFundingRate(rate float64) string {
+	return fmt.Sprintf("%.4f%% (%.6f)", rate*100, rate)
+}
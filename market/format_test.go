@@ -0,0 +1,293 @@
+package market
+
+import (
+	"flag"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// 修改提示词文本后使用 go test ./market -run TestFormat_Golden -update 重新生成 golden 文件
+var updateGolden = flag.Bool("update", false, "update golden files")
+
+// formatFixture 覆盖所有分段的固定市场数据
+func formatFixture() *Data {
+	return &Data{
+		Symbol:           "ETHUSDT",
+		CurrentPrice:     3500.25,
+		CurrentEMA20:     3450.0,
+		CurrentMACD:      12.5,
+		CurrentRSI7:      55.0,
+		CurrentWilliamsR: -35.5,
+		CurrentMFI:       62.25,
+		BollingerUpper:   3560.5,
+		BollingerMiddle:  3480.25,
+		BollingerLower:   3400,
+		SARValue:         3420.5,
+		SARTrend:         1,
+		OpenInterest:     &OIData{Latest: 50000, Average: 49000},
+		FundingRate:      0.0001,
+		IntradaySeries: &IntradayData{
+			MidPrices:   []float64{3400, 3450, 3500},
+			EMA20Values: []float64{3420, 3440},
+			MACDValues:  []float64{10.5, 12.5},
+			RSI7Values:  []float64{52, 55},
+			RSI14Values: []float64{50, 53},
+			Volume:      []float64{1000, 1200, 1100},
+			ATR14:       45.3,
+		},
+		LongerTermContext: &LongerTermData{
+			EMA20:         3400,
+			EMA50:         3350,
+			ATR3:          50,
+			ATR14:         80,
+			CurrentVolume: 5000,
+			AverageVolume: 4500,
+			MACDValues:    []float64{5, 8, 12},
+			RSI14Values:   []float64{48, 52, 55},
+		},
+		CurrentTSI:        42.5,
+		CurrentTSISignal:  38.25,
+		KEMADTrend:        1,
+		KEMADEMA:          3440.5,
+		KEMADATR:          22.75,
+		SSLExitSignal:     -1,
+		SSLBaseline:       3460,
+		UltimateRSI:       58.5,
+		UltimateRSISignal: 56.25,
+		RSIBuySignal:      true,
+		RSIValue:          54.5,
+	}
+}
+
+func TestFormat_Golden(t *testing.T) {
+	got := Format(formatFixture())
+	path := filepath.Join("testdata", "format.golden")
+	if *updateGolden {
+		require.NoError(t, os.MkdirAll("testdata", 0755))
+		require.NoError(t, os.WriteFile(path, []byte(got), 0644))
+	}
+	want, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, string(want), got, "prompt text changed; rerun with -update and review the golden diff")
+}
+
+func TestFormat_ASCIIOnly(t *testing.T) {
+	output := Format(formatFixture())
+	for i, r := range output {
+		require.Less(t, r, rune(128), "non-ASCII character %q at offset %d", r, i)
+	}
+}
+
+func TestFormatSections_OrderAndFlags(t *testing.T) {
+	assert.Equal(t, []Section{
+		SectionHeader, SectionDerivatives, SectionIntraday, SectionLongerTerm, SectionIndicators, SectionPatterns,
+	}, Sections())
+
+	full := Format(formatFixture())
+	markers := []string{"current_price", "Funding Rate", "Intraday series", "Longer-term context", "Additional indicators", "RSI(10) patterns"}
+	last := -1
+	for _, m := range markers {
+		idx := strings.Index(full, m)
+		require.Greater(t, idx, last, "%s out of order", m)
+		last = idx
+	}
+
+	partial := FormatSections(formatFixture(), SectionFlags{SectionIntraday: false, SectionIndicators: false, SectionHeader: true})
+	assert.Contains(t, partial, "current_price")
+	assert.Contains(t, partial, "Longer-term context")
+	assert.NotContains(t, partial, "Intraday series")
+	assert.NotContains(t, partial, "Additional indicators")
+}
+
+func TestFormatFundingRate(t *testing.T) {
+	assert.Equal(t, "0.0100% (0.000100)", formatFundingRate(0.0001))
+	assert.Equal(t, "-0.0375% (-0.000375)", formatFundingRate(-0.000375))
+	assert.Equal(t, "0.0000% (0.000000)", formatFundingRate(0))
+}
+
+func TestFormat_SymbolPricePrecision(t *testing.T) {
+	defer func() {
+		SetPricePrecisionOverrides(nil)
+		pricePrecisions.Lock()
+		pricePrecisions.exchange = make(map[string]int)
+		pricePrecisions.Unlock()
+	}()
+
+	registerExchangePrecisions(&ExchangeInfo{Symbols: []SymbolInfo{
+		{Symbol: "ETHUSDT", PricePrecision: 3},
+		{Symbol: "DOGEUSDT", PricePrecision: 6},
+		{Symbol: "HYPEUSDT"}, // 未提供精度
+	}})
+	SetPricePrecisionOverrides(map[string]int{"dogeusdt": 5, "BADUSDT": 20})
+
+	// 交易所精度覆盖动态精度（动态精度下 3500.25 输出 2 位小数）
+	output := Format(formatFixture())
+	assert.Contains(t, output, "current_price = 3500.250,")
+	assert.Contains(t, output, "Mid prices: [3400.000, 3450.000, 3500.000]")
+	assert.Contains(t, output, "EMA indicators (20-period): [3420.000, 3440.000]")
+	assert.Contains(t, output, "MACD indicators: [10.5000, 12.5000]", "非价格序列不受影响")
+
+	// 手动配置优先于交易所精度
+	assert.Equal(t, "0.12346", formatSymbolPrice("DOGEUSDT", 0.123456))
+	// 未提供、无效或未知的币种使用动态精度
+	assert.Equal(t, "0.1235", formatSymbolPrice("HYPEUSDT", 0.123456))
+	assert.Equal(t, "0.1235", formatSymbolPrice("BADUSDT", 0.123456))
+	assert.Equal(t, "45678.91", formatSymbolPrice("BTCUSDT", 45678.9123))
+	assert.Equal(t, formatFloatSlice([]float64{1.5, 2.25}), formatSymbolPriceSlice("BTCUSDT", []float64{1.5, 2.25}))
+}
+
+// TestFormat_BollingerBands 测试布林带行输出价格所在位置和 %B，数据不足时整行省略
+func TestFormat_BollingerBands(t *testing.T) {
+	data := formatFixture()
+	data.CurrentPrice = 3500
+	data.BollingerUpper, data.BollingerMiddle, data.BollingerLower = 3600, 3500, 3400
+	output := Format(data)
+	assert.Contains(t, output, "price inside bands, percent_b = 0.500")
+
+	data.CurrentPrice = 3650
+	assert.Contains(t, Format(data), "price above upper band, percent_b = 1.250")
+	data.CurrentPrice = 3350
+	assert.Contains(t, Format(data), "price below lower band, percent_b = -0.250")
+
+	// 无波动时上下轨重合，%B 无意义
+	data.BollingerUpper, data.BollingerLower = 3500, 3500
+	data.CurrentPrice = 3500
+	assert.Contains(t, Format(data), "percent_b = n/a")
+
+	data.BollingerUpper, data.BollingerMiddle, data.BollingerLower = 0, 0, 0
+	assert.NotContains(t, Format(data), "bollinger_bands")
+}
+
+// TestFormat_DerivativesUnavailable 测试数据源不提供 OI/资金费率时整行省略并注明不可用，而不是输出 0
+func TestFormat_DerivativesUnavailable(t *testing.T) {
+	data := formatFixture()
+	data.OpenInterest, data.FundingRate = nil, 0
+	data.NoOpenInterest, data.NoFundingRate = true, true
+	output := Format(data)
+	assert.NotContains(t, output, "Open Interest:")
+	assert.NotContains(t, output, "Funding Rate:")
+	assert.NotContains(t, output, "for perps")
+	assert.Contains(t, output, "Open interest and funding rate for ETHUSDT are not available from the current market data source")
+
+	// 只缺少其中一项时保留另一项
+	data = formatFixture()
+	data.OpenInterest, data.NoOpenInterest = nil, true
+	output = Format(data)
+	assert.NotContains(t, output, "Open Interest:")
+	assert.Contains(t, output, "Open interest is not available")
+	assert.Contains(t, output, "Funding Rate: 0.0100%")
+}
+
+// TestFormat_SpotOnlySource 测试现货数据源（无永续合约）省略整个持仓量/资金费率部分，即使配置为按 0 输出
+func TestFormat_SpotOnlySource(t *testing.T) {
+	defer SetHideDerivativesOnSpot(true)
+
+	data := formatFixture()
+	data.OpenInterest, data.FundingRate = &OIData{}, 0
+	data.SpotOnly = true
+	output := Format(data)
+	assert.NotContains(t, output, "perps")
+	assert.NotContains(t, output, "Open Interest")
+	assert.NotContains(t, output, "Funding Rate")
+	assert.NotContains(t, output, "not available from the current market data source")
+
+	// 关闭后按 derivatives_fallback 处理（这里为按 0 输出）
+	SetHideDerivativesOnSpot(false)
+	output = Format(data)
+	assert.Contains(t, output, "for perps")
+	assert.Contains(t, output, "Funding Rate: 0.0000%")
+
+	for source, spotOnly := range map[DataSource]bool{
+		DataSourceBinanceUS:   true,
+		DataSourceFinnhub:     true,
+		DataSourceBinance:     false,
+		DataSourceBybit:       false,
+		DataSourceHyperliquid: false,
+	} {
+		assert.Equal(t, spotOnly, dataSourceConfigFor(source).SpotOnly(), source)
+	}
+}
+
+// TestFormat_StaleFundingRate 测试资金费率超过最大有效期时在输出中标注为过期
+func TestFormat_StaleFundingRate(t *testing.T) {
+	defer SetFundingRateMaxAge(0)
+	SetFundingRateMaxAge(90)
+
+	data := formatFixture()
+	data.FundingUpdatedAt = time.Now().Add(-30 * time.Minute)
+	output := Format(data)
+	assert.Contains(t, output, "Funding Rate: 0.0100% (0.000100)\n")
+	assert.NotContains(t, output, "STALE")
+
+	data.FundingUpdatedAt = time.Now().Add(-3 * time.Hour)
+	output = Format(data)
+	assert.Contains(t, output, "Funding Rate: 0.0100% (0.000100) (STALE: last updated 180 minutes ago")
+
+	// 获取时间未知时不标注
+	data.FundingUpdatedAt = time.Time{}
+	assert.NotContains(t, Format(data), "STALE")
+}
+
+// TestFetchDerivativesData_FallsBackToStaleFundingCache 测试资金费率刷新失败时沿用过期缓存值及其获取时间，并在输出中标注为过期
+func TestFetchDerivativesData_FallsBackToStaleFundingCache(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	now := time.Now()
+	tracker, _ := newTestSourceErrorTracker(&now)
+	prevTracker, prevConfig := sourceErrors, dataSourceConfigs[DataSourceBinance]
+	cfg := *prevConfig
+	cfg.BaseURL, cfg.OIEndpoint = server.URL, ""
+	sourceErrors, dataSourceConfigs[DataSourceBinance] = tracker, &cfg
+	defer func() { sourceErrors, dataSourceConfigs[DataSourceBinance] = prevTracker, prevConfig }()
+
+	key := fundingRateCacheKey(DataSourceBinance, "STALEUSDT")
+	updatedAt := now.Add(-3 * time.Hour)
+	fundingRateMap.Store(key, &FundingRateCache{Rate: 0.0003, UpdatedAt: updatedAt})
+	defer fundingRateMap.Delete(key)
+
+	_, rate, fundingUpdatedAt := fetchDerivativesData(DataSourceBinance, "STALEUSDT")
+	assert.Equal(t, 0.0003, rate)
+	assert.Equal(t, updatedAt, fundingUpdatedAt)
+
+	data := formatFixture()
+	data.FundingRate, data.FundingUpdatedAt = rate, fundingUpdatedAt
+	assert.Contains(t, Format(data), "Funding Rate: 0.0300% (0.000300) (STALE: last updated 180 minutes ago")
+}
+
+func TestDerivativesUnavailable_BySource(t *testing.T) {
+	defer func() {
+		currentDataSource = DataSourceBinance
+		SetDerivativesFallback("")
+	}()
+
+	for _, source := range []DataSource{DataSourceBinanceUS, DataSourceFinnhub} {
+		currentDataSource = source
+		oi, funding := derivativesUnavailable("")
+		assert.True(t, oi, source)
+		assert.True(t, funding, source)
+	}
+	currentDataSource = DataSourceBybit
+	oi, funding := derivativesUnavailable("")
+	assert.False(t, oi)
+	assert.False(t, funding)
+
+	// 配置为 zero 时保持旧行为（按 0 输出）
+	currentDataSource = DataSourceBinanceUS
+	SetDerivativesFallback("ZERO")
+	oi, funding = derivativesUnavailable("")
+	assert.False(t, oi)
+	assert.False(t, funding)
+	SetDerivativesFallback("bogus")
+	assert.Equal(t, DerivativesFallbackZero, derivativesFallback, "无效值忽略")
+}
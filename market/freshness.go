@@ -0,0 +1,28 @@
+package market
+
+import "time"
+
+// klineOpenTime K线开盘时间（各数据源的时间戳单位不同：币安为毫秒，Hyperliquid/Bybit 已换算为秒），0 表示未知
+func klineOpenTime(openTime int64) time.Time {
+	switch {
+	case openTime <= 0:
+		return time.Time{}
+	case openTime >= 1e12:
+		return time.UnixMilli(openTime)
+	default:
+		return time.Unix(openTime, 0)
+	}
+}
+
+// Age 行情数据的时效：距最新一根3分钟K线收盘（尚未收盘时为当前时间）已过去的时长，
+// 最新K线时间未知时返回 false
+func (d *Data) Age(now time.Time) (time.Duration, bool) {
+	if d == nil || d.LatestCandleAt.IsZero() {
+		return 0, false
+	}
+	closeAt := d.LatestCandleAt.Add(3 * time.Minute)
+	if now.Before(closeAt) {
+		return 0, true
+	}
+	return now.Sub(closeAt), true
+}
@@ -0,0 +1,32 @@
+package market
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestData_Age 测试按最新K线时间计算行情时效（毫秒和秒两种时间戳单位）
+func TestData_Age(t *testing.T) {
+	openAt := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	assert.True(t, klineOpenTime(openAt.UnixMilli()).Equal(openAt))
+	assert.True(t, klineOpenTime(openAt.Unix()).Equal(openAt))
+	assert.True(t, klineOpenTime(0).IsZero())
+
+	klines := indicatorTestKlines(100)
+	klines[len(klines)-1].OpenTime = openAt.UnixMilli()
+	data, err := buildData(DataSourceBinanceUS, "BTCUSDT", klines, klines, nil, IndicatorParams{})
+	require.NoError(t, err)
+	assert.True(t, data.LatestCandleAt.Equal(openAt))
+
+	age, ok := data.Age(openAt.Add(time.Minute))
+	assert.True(t, ok)
+	assert.Zero(t, age, "最新K线尚未收盘")
+	age, _ = data.Age(openAt.Add(10 * time.Minute))
+	assert.Equal(t, 7*time.Minute, age)
+
+	_, ok = (&Data{}).Age(openAt)
+	assert.False(t, ok)
+}
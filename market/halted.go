@@ -0,0 +1,15 @@
+package market
+
+// zeroVolumeStreak 从最新一根K线往前数，连续成交量为 0 的K线根数
+func zeroVolumeStreak(klines []Kline) int {
+	streak := 0
+	for i := len(klines) - 1; i >= 0 && klines[i].Volume == 0; i-- {
+		streak++
+	}
+	return streak
+}
+
+// AppearsHalted 最近 minCandles 根K线成交量都为 0 时认为币种停牌或暂停交易（minCandles ≤ 0 表示不检查）
+func (d *Data) AppearsHalted(minCandles int) bool {
+	return minCandles > 0 && d.ZeroVolumeCandles >= minCandles
+}
@@ -0,0 +1,32 @@
+package market
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestBuildData_ZeroVolumeKlinesAppearHalted 测试最近的K线成交量为 0 时计入连续零成交量根数，达到阈值判定为停牌
+func TestBuildData_ZeroVolumeKlinesAppearHalted(t *testing.T) {
+	klines := indicatorTestKlines(100)
+	for i := len(klines) - 6; i < len(klines); i++ {
+		klines[i].Volume = 0
+		klines[i].Open, klines[i].High, klines[i].Low = klines[i].Close, klines[i].Close, klines[i].Close
+	}
+
+	// binance_us 不提供 OI/资金费率，构建数据时不发起网络请求
+	data, err := buildData(DataSourceBinanceUS, "BTCUSDT", klines, klines, nil, IndicatorParams{})
+	require.NoError(t, err)
+	assert.Equal(t, 6, data.ZeroVolumeCandles)
+	assert.True(t, data.AppearsHalted(5))
+	assert.False(t, data.AppearsHalted(7))
+	assert.False(t, data.AppearsHalted(0), "0 表示不检查")
+
+	// 中间出现一根有成交的K线时重新计数
+	klines[len(klines)-3].Volume = 10
+	data, err = buildData(DataSourceBinanceUS, "BTCUSDT", klines, klines, nil, IndicatorParams{})
+	require.NoError(t, err)
+	assert.Equal(t, 2, data.ZeroVolumeCandles)
+	assert.False(t, data.AppearsHalted(5))
+}
@@ -0,0 +1,67 @@
+package market
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// historyPageSize 分页获取历史K线时每页的K线根数（Binance 单次最多返回 1500 根）
+const historyPageSize = 1000
+
+// GetKlinesRange 分页获取开盘时间在 [start, end) 内的历史K线，按开盘时间排序并去重。
+// 每页只请求 historyPageSize 根K线对应的时间窗口，只返回窗口内最新K线的数据源（如 Bybit）也能完整取到；
+// 缺失的K线（行情中断、币种尚未上线）不补齐，由调用方按时间处理
+func (c *APIClient) GetKlinesRange(symbol, interval string, start, end time.Time) ([]Kline, error) {
+	if interval == "1M" {
+		return nil, fmt.Errorf("不支持按时间范围获取 %s K线", interval)
+	}
+	startMs, endMs := start.UnixMilli(), end.UnixMilli()
+	if endMs <= startMs {
+		return nil, fmt.Errorf("无效的时间范围: %s — %s", start.Format(time.RFC3339), end.Format(time.RFC3339))
+	}
+
+	window := getIntervalMs(interval) * historyPageSize
+	var klines []Kline
+	last := int64(-1)
+	for from := startMs; from < endMs; from += window {
+		to := min(from+window, endMs) - 1
+		page, err := c.getKlines(symbol, interval, historyPageSize, from, to)
+		if err != nil {
+			return nil, fmt.Errorf("获取 %s %s 历史K线失败 (%s): %w", symbol, interval, time.UnixMilli(from).UTC().Format(time.RFC3339), err)
+		}
+		sort.Slice(page, func(i, j int) bool {
+			return klineOpenTime(page[i].OpenTime).Before(klineOpenTime(page[j].OpenTime))
+		})
+		for _, k := range page {
+			openMs := klineOpenTime(k.OpenTime).UnixMilli()
+			if openMs < from || openMs > to || openMs <= last {
+				continue
+			}
+			klines = append(klines, k)
+			last = openMs
+		}
+	}
+	return klines, nil
+}
+
+// ClosedKlines 截至 at 已收盘的最近 limit 根K线（klines 按开盘时间排序，interval 为其周期），用于按时间回放历史K线
+func ClosedKlines(klines []Kline, interval string, at time.Time, limit int) []Kline {
+	step := time.Duration(getIntervalMs(interval)) * time.Millisecond
+	end := sort.Search(len(klines), func(i int) bool {
+		return klineOpenTime(klines[i].OpenTime).Add(step).After(at)
+	})
+	return klines[max(0, end-limit):end]
+}
+
+// BuildReplayData 用历史K线组装回放时刻的市场数据（回测使用）。
+// 只计算指标，不获取持仓量和资金费率，也不读取资金费率缓存，避免实时数据混入回放；两者在提示词中按不可用处理
+func BuildReplayData(source DataSource, symbol string, klines3m, klines4h, klines30m []Kline, params IndicatorParams) (*Data, error) {
+	data, err := buildIndicatorData(source, symbol, klines3m, klines4h, klines30m, params)
+	if err != nil {
+		return nil, err
+	}
+	data.NoOpenInterest = true
+	data.NoFundingRate = true
+	return data, nil
+}
@@ -0,0 +1,100 @@
+package market
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newHistoryTestServer 模拟 Binance 历史K线接口：K线从 listedAt 开始，跳过 gap 内的K线，按 startTime/endTime/limit 返回
+func newHistoryTestServer(t *testing.T, listedAt, end time.Time, gap [2]time.Time, requests *int) *httptest.Server {
+	step := int64(3 * time.Minute / time.Millisecond)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*requests++
+		q := r.URL.Query()
+		from, _ := strconv.ParseInt(q.Get("startTime"), 10, 64)
+		to, _ := strconv.ParseInt(q.Get("endTime"), 10, 64)
+		limit, _ := strconv.Atoi(q.Get("limit"))
+		klines := [][]interface{}{}
+		for open := max(from, listedAt.UnixMilli()); open <= to && open < end.UnixMilli() && len(klines) < limit; open += step {
+			if open >= gap[0].UnixMilli() && open < gap[1].UnixMilli() {
+				continue
+			}
+			p := fmt.Sprintf("%d", 100+(open-listedAt.UnixMilli())/step)
+			klines = append(klines, []interface{}{open, p, p, p, p, "10", open + step - 1, "1000", 5, "5", "500", "0"})
+		}
+		json.NewEncoder(w).Encode(klines)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// TestGetKlinesRange 测试分页获取历史K线：币种上线前和缺口内没有K线，分页边界不重复也不遗漏
+func TestGetKlinesRange(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	listedAt := start.Add(6 * time.Hour)
+	end := start.Add(5 * 24 * time.Hour)
+	gap := [2]time.Time{start.Add(48 * time.Hour), start.Add(50 * time.Hour)}
+	var requests int
+	useTestBaseURL(t, DataSourceBinance, newHistoryTestServer(t, listedAt, end, gap, &requests).URL)
+
+	klines, err := NewAPIClientFor(DataSourceBinance).GetKlinesRange("NEWUSDT", "3m", start, end)
+	require.NoError(t, err)
+
+	want := int(end.Sub(listedAt)/(3*time.Minute)) - int(gap[1].Sub(gap[0])/(3*time.Minute))
+	require.Len(t, klines, want)
+	assert.Equal(t, listedAt.UnixMilli(), klines[0].OpenTime, "从上线时间开始")
+	assert.Equal(t, end.Add(-3*time.Minute).UnixMilli(), klines[len(klines)-1].OpenTime)
+	for i := 1; i < len(klines); i++ {
+		require.Greater(t, klines[i].OpenTime, klines[i-1].OpenTime)
+	}
+	assert.Equal(t, int(end.Sub(start)/(1000*3*time.Minute))+1, requests, "每页覆盖 %d 根K线", historyPageSize)
+
+	_, err = NewAPIClientFor(DataSourceBinance).GetKlinesRange("NEWUSDT", "3m", end, start)
+	assert.Error(t, err)
+}
+
+// TestClosedKlines 测试按回放时刻截取已收盘的K线
+func TestClosedKlines(t *testing.T) {
+	base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	klines := make([]Kline, 10)
+	for i := range klines {
+		klines[i] = Kline{OpenTime: base.Add(time.Duration(i) * 3 * time.Minute).UnixMilli(), Close: float64(i)}
+	}
+
+	window := ClosedKlines(klines, "3m", base.Add(9*time.Minute), 100)
+	require.Len(t, window, 3, "第4根K线在 00:12 才收盘")
+	assert.Equal(t, 2.0, window[len(window)-1].Close)
+
+	window = ClosedKlines(klines, "3m", base.Add(time.Hour), 4)
+	require.Len(t, window, 4)
+	assert.Equal(t, 6.0, window[0].Close)
+
+	assert.Empty(t, ClosedKlines(klines, "3m", base.Add(2*time.Minute), 100), "第一根K线尚未收盘")
+}
+
+// TestBuildReplayData_IgnoresFundingCache 测试回放数据不使用实时资金费率缓存
+func TestBuildReplayData_IgnoresFundingCache(t *testing.T) {
+	fundingRateMap.Store(fundingRateCacheKey(DataSourceBinance, "REPLAYUSDT"), &FundingRateCache{Rate: 0.0005, UpdatedAt: time.Now()})
+	defer fundingRateMap.Delete(fundingRateCacheKey(DataSourceBinance, "REPLAYUSDT"))
+
+	klines := generateEdgeTestKlines(100)
+	data, err := BuildReplayData(DataSourceBinance, "REPLAYUSDT", klines, klines, nil, IndicatorParams{})
+	require.NoError(t, err)
+	assert.Equal(t, klines[len(klines)-1].Close, data.CurrentPrice)
+	assert.Zero(t, data.FundingRate)
+	assert.Nil(t, data.OpenInterest)
+	assert.True(t, data.NoFundingRate)
+	assert.True(t, data.NoOpenInterest)
+	assert.NotContains(t, Format(data), "Funding Rate:")
+
+	_, err = BuildReplayData(DataSourceBinance, "REPLAYUSDT", nil, klines, nil, IndicatorParams{})
+	assert.Error(t, err)
+}
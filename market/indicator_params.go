@@ -0,0 +1,106 @@
+package market
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// maxIndicatorPeriod 指标周期上限（与K线缓存数量一致，更长的周期没有足够数据）
+const maxIndicatorPeriod = klineCacheSize
+
+// IndicatorParams 交易员可配置的指标周期（0 表示使用默认值）。
+// 只影响提示词中的基础指标（RSI、EMA、ATR、TSI），脚本附加指标保持固定参数
+type IndicatorParams struct {
+	RSIShort  int `json:"rsi_short,omitempty"`  // 短周期RSI（默认7）：current_rsi 和日内RSI序列
+	RSILong   int `json:"rsi_long,omitempty"`   // 长周期RSI（默认14）：日内和4小时RSI序列
+	EMAFast   int `json:"ema_fast,omitempty"`   // 快速EMA（默认20）：current_ema、日内EMA序列和4小时EMA
+	EMASlow   int `json:"ema_slow,omitempty"`   // 慢速EMA（默认50）：4小时EMA
+	ATRShort  int `json:"atr_short,omitempty"`  // 短周期ATR（默认3）：4小时ATR
+	ATRLong   int `json:"atr_long,omitempty"`   // 长周期ATR（默认14）：3分钟和4小时ATR
+	TSILong   int `json:"tsi_long,omitempty"`   // TSI 长平滑周期（默认35）
+	TSIShort  int `json:"tsi_short,omitempty"`  // TSI 短平滑周期（默认35）
+	TSISignal int `json:"tsi_signal,omitempty"` // TSI 信号线周期（默认13）
+}
+
+// DefaultIndicatorParams 默认指标周期
+func DefaultIndicatorParams() IndicatorParams {
+	return IndicatorParams{
+		RSIShort: 7, RSILong: 14,
+		EMAFast: 20, EMASlow: 50,
+		ATRShort: 3, ATRLong: 14,
+		TSILong: 35, TSIShort: 35, TSISignal: 13,
+	}
+}
+
+// WithDefaults 未配置（0）的周期使用默认值
+func (p IndicatorParams) WithDefaults() IndicatorParams {
+	d := DefaultIndicatorParams()
+	fill := func(v *int, def int) {
+		if *v == 0 {
+			*v = def
+		}
+	}
+	fill(&p.RSIShort, d.RSIShort)
+	fill(&p.RSILong, d.RSILong)
+	fill(&p.EMAFast, d.EMAFast)
+	fill(&p.EMASlow, d.EMASlow)
+	fill(&p.ATRShort, d.ATRShort)
+	fill(&p.ATRLong, d.ATRLong)
+	fill(&p.TSILong, d.TSILong)
+	fill(&p.TSIShort, d.TSIShort)
+	fill(&p.TSISignal, d.TSISignal)
+	return p
+}
+
+// IsDefault 是否与默认指标周期相同
+func (p IndicatorParams) IsDefault() bool {
+	return p.WithDefaults() == DefaultIndicatorParams()
+}
+
+// Validate 校验指标周期：必须为正数（0 表示默认）且不超过K线缓存数量
+func (p IndicatorParams) Validate() error {
+	fields := []struct {
+		name  string
+		value int
+	}{
+		{"rsi_short", p.RSIShort}, {"rsi_long", p.RSILong},
+		{"ema_fast", p.EMAFast}, {"ema_slow", p.EMASlow},
+		{"atr_short", p.ATRShort}, {"atr_long", p.ATRLong},
+		{"tsi_long", p.TSILong}, {"tsi_short", p.TSIShort}, {"tsi_signal", p.TSISignal},
+	}
+	for _, f := range fields {
+		if f.value < 0 {
+			return fmt.Errorf("指标周期 %s 必须为正数: %d", f.name, f.value)
+		}
+		if f.value > maxIndicatorPeriod {
+			return fmt.Errorf("指标周期 %s 不能超过 %d: %d", f.name, maxIndicatorPeriod, f.value)
+		}
+	}
+	return nil
+}
+
+// ParseIndicatorParams 解析交易员的指标周期配置（JSON格式，空字符串表示全部使用默认值）
+func ParseIndicatorParams(raw string) (IndicatorParams, error) {
+	var params IndicatorParams
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return params, nil
+	}
+	if err := json.Unmarshal([]byte(raw), &params); err != nil {
+		return IndicatorParams{}, fmt.Errorf("指标周期配置格式错误: %w", err)
+	}
+	if err := params.Validate(); err != nil {
+		return IndicatorParams{}, err
+	}
+	return params, nil
+}
+
+// Encode 序列化为数据库存储格式（全部为默认值时返回空字符串）
+func (p IndicatorParams) Encode() string {
+	if p == (IndicatorParams{}) {
+		return ""
+	}
+	raw, _ := json.Marshal(p)
+	return string(raw)
+}
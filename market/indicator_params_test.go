@@ -0,0 +1,73 @@
+package market
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// indicatorTestKlines 生成价格来回震荡的K线（不同周期的RSI结果不同）
+func indicatorTestKlines(n int) []Kline {
+	klines := make([]Kline, n)
+	for i := range klines {
+		price := 100 + 5*math.Sin(float64(i)/3) + float64(i%4)
+		klines[i] = Kline{
+			OpenTime: int64(i) * threeMinutes,
+			Open:     price - 0.5,
+			High:     price + 1,
+			Low:      price - 1,
+			Close:    price,
+			Volume:   1000,
+		}
+	}
+	return klines
+}
+
+// TestBuildData_IndicatorParamsOverrideRSI 测试同一组K线上，RSI周期为14的交易员得到与默认7周期不同的RSI，提示词标签随之变化
+func TestBuildData_IndicatorParamsOverrideRSI(t *testing.T) {
+	klines := indicatorTestKlines(100)
+
+	// binance_us 不提供 OI/资金费率，构建数据时不发起网络请求
+	defaults, err := buildData(DataSourceBinanceUS, "BTCUSDT", klines, klines, nil, IndicatorParams{})
+	require.NoError(t, err)
+	custom, err := buildData(DataSourceBinanceUS, "BTCUSDT", klines, klines, nil, IndicatorParams{RSIShort: 14})
+	require.NoError(t, err)
+
+	assert.InDelta(t, calculateRSI(klines, 7), defaults.CurrentRSI7, 1e-9)
+	assert.InDelta(t, calculateRSI(klines, 14), custom.CurrentRSI7, 1e-9)
+	assert.NotEqual(t, defaults.CurrentRSI7, custom.CurrentRSI7)
+	assert.NotEqual(t, defaults.IntradaySeries.RSI7Values, custom.IntradaySeries.RSI7Values)
+	assert.Equal(t, defaults.IntradaySeries.RSI14Values, custom.IntradaySeries.RSI14Values)
+	assert.Equal(t, defaults.CurrentEMA20, custom.CurrentEMA20)
+
+	assert.Contains(t, Format(defaults), "current_rsi (7 period)")
+	assert.Contains(t, Format(custom), "current_rsi (14 period)")
+	assert.False(t, strings.Contains(Format(custom), "RSI indicators (7-Period)"))
+}
+
+// TestIndicatorParams_Validate 测试指标周期必须为正数且不超过K线缓存数量，0 使用默认值
+func TestIndicatorParams_Validate(t *testing.T) {
+	assert.NoError(t, IndicatorParams{}.Validate())
+	assert.True(t, IndicatorParams{}.IsDefault())
+	assert.True(t, IndicatorParams{RSIShort: 7}.IsDefault())
+	assert.Equal(t, 14, IndicatorParams{RSIShort: 14}.WithDefaults().RSIShort)
+	assert.Equal(t, 20, IndicatorParams{RSIShort: 14}.WithDefaults().EMAFast)
+
+	assert.ErrorContains(t, IndicatorParams{RSIShort: -1}.Validate(), "rsi_short 必须为正数")
+	assert.ErrorContains(t, IndicatorParams{TSISignal: -13}.Validate(), "tsi_signal")
+	assert.ErrorContains(t, IndicatorParams{EMASlow: 200}.Validate(), "ema_slow 不能超过 100")
+
+	params, err := ParseIndicatorParams(`{"rsi_short":14,"atr_long":21}`)
+	require.NoError(t, err)
+	assert.Equal(t, IndicatorParams{RSIShort: 14, ATRLong: 21}, params)
+	assert.Equal(t, `{"rsi_short":14,"atr_long":21}`, params.Encode())
+	assert.Equal(t, "", IndicatorParams{}.Encode())
+
+	_, err = ParseIndicatorParams(`{"rsi_short":0,"ema_fast":-5}`)
+	assert.Error(t, err)
+	_, err = ParseIndicatorParams(`not json`)
+	assert.Error(t, err)
+}
@@ -0,0 +1,91 @@
+package market
+
+import (
+	"log"
+	"sync"
+
+	"aspen/metrics"
+)
+
+// klineCacheSize 每个币种每个周期缓存的K线数量
+const klineCacheSize = 100
+
+// defaultKlineGapMaxBackfill 单个缺口默认最多补齐的K线根数（与K线缓存数量一致）
+const defaultKlineGapMaxBackfill = klineCacheSize
+
+// klineGapSettings K线缺口补齐配置：enabled 为 false 时只记录缺口不补齐，缺失超过 maxCandles 根时不补齐
+var klineGapSettings = struct {
+	sync.RWMutex
+	enabled    bool
+	maxCandles int
+}{enabled: true, maxCandles: defaultKlineGapMaxBackfill}
+
+// fetchKlinesForBackfill 补齐缺口使用的REST K线接口（测试可替换）
+var fetchKlinesForBackfill = func(symbol, interval string, limit int) ([]Kline, error) {
+	return NewAPIClient().GetKlines(symbol, interval, limit)
+}
+
+// SetKlineGapBackfill 设置实时K线缺口补齐：enabled 为 false 时只记录缺口，maxCandles 非正数使用默认值
+func SetKlineGapBackfill(enabled bool, maxCandles int) {
+	if maxCandles <= 0 {
+		maxCandles = defaultKlineGapMaxBackfill
+	}
+	klineGapSettings.Lock()
+	klineGapSettings.enabled = enabled
+	klineGapSettings.maxCandles = maxCandles
+	klineGapSettings.Unlock()
+}
+
+// klineGapBackfillConfig 当前的缺口补齐配置
+func klineGapBackfillConfig() (bool, int) {
+	klineGapSettings.RLock()
+	defer klineGapSettings.RUnlock()
+	return klineGapSettings.enabled, klineGapSettings.maxCandles
+}
+
+// missingKlines 两根K线之间缺失的K线根数（按开盘时间和周期计算，不定长周期如 1M 不检测）
+func missingKlines(last, next Kline, interval string) int {
+	if interval == "1M" || next.OpenTime <= last.OpenTime {
+		return 0
+	}
+	step := getIntervalMs(interval)
+	return int((next.OpenTime-last.OpenTime)/step) - 1
+}
+
+// fillKlineGap 实时K线流跳过了 last 与 next 之间的K线时，通过REST接口补齐缺失的K线。
+// 返回按开盘时间排序的补齐K线（可能不完整）；补齐失败或未启用时返回 nil，缺口照常记录日志和指标
+func fillKlineGap(symbol, interval string, last, next Kline) []Kline {
+	missing := missingKlines(last, next, interval)
+	if missing <= 0 {
+		return nil
+	}
+
+	enabled, maxCandles := klineGapBackfillConfig()
+	if !enabled || missing > maxCandles {
+		log.Printf("⚠️  [Market] %s %s K线缺失 %d 根（%d → %d），未补齐", symbol, interval, missing, last.OpenTime, next.OpenTime)
+		metrics.RecordKlineGap(interval, missing, "skipped")
+		return nil
+	}
+
+	// 最新的 missing+2 根K线覆盖缺口两端（最后一根可能是正在形成的 next）
+	klines, err := fetchKlinesForBackfill(symbol, interval, missing+2)
+	if err != nil {
+		log.Printf("⚠️  [Market] %s %s K线缺失 %d 根，补齐失败: %v", symbol, interval, missing, err)
+		metrics.RecordKlineGap(interval, missing, "failed")
+		return nil
+	}
+
+	var filled []Kline
+	for _, k := range klines {
+		if k.OpenTime > last.OpenTime && k.OpenTime < next.OpenTime {
+			filled = append(filled, k)
+		}
+	}
+	result := "backfilled"
+	if len(filled) < missing {
+		result = "partial"
+	}
+	log.Printf("🩹 [Market] %s %s K线缺失 %d 根，已补齐 %d 根", symbol, interval, missing, len(filled))
+	metrics.RecordKlineGap(interval, missing, result)
+	return filled
+}
@@ -0,0 +1,147 @@
+package market
+
+import (
+	"errors"
+	"testing"
+
+	"aspen/metrics"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+const threeMinutes = int64(180000)
+
+// klinesAt 按开盘时间序号生成 3m K线
+func klinesAt(indexes ...int64) []Kline {
+	klines := make([]Kline, 0, len(indexes))
+	for _, i := range indexes {
+		klines = append(klines, Kline{OpenTime: i * threeMinutes, CloseTime: (i+1)*threeMinutes - 1, Close: float64(100 + i)})
+	}
+	return klines
+}
+
+// streamKline 构造实时K线推送
+func streamKline(index int64) KlineWSData {
+	var data KlineWSData
+	data.Kline.StartTime = index * threeMinutes
+	data.Kline.CloseTime = (index+1)*threeMinutes - 1
+	data.Kline.ClosePrice = "105"
+	return data
+}
+
+// openTimes K线开盘时间序号
+func openTimes(klines []Kline) []int64 {
+	result := make([]int64, 0, len(klines))
+	for _, k := range klines {
+		result = append(result, k.OpenTime/threeMinutes)
+	}
+	return result
+}
+
+// withBackfill 替换补齐使用的REST接口和配置，测试结束后恢复
+func withBackfill(t *testing.T, enabled bool, fetch func(symbol, interval string, limit int) ([]Kline, error)) {
+	t.Helper()
+	prevFetch := fetchKlinesForBackfill
+	prevEnabled, prevMax := klineGapBackfillConfig()
+	fetchKlinesForBackfill = fetch
+	SetKlineGapBackfill(enabled, 0)
+	t.Cleanup(func() {
+		fetchKlinesForBackfill = prevFetch
+		SetKlineGapBackfill(prevEnabled, prevMax)
+	})
+}
+
+// TestProcessKlineUpdate_BackfillsGap 测试实时流跳过K线时检测到缺口、记录指标并通过REST补齐缺失的K线
+func TestProcessKlineUpdate_BackfillsGap(t *testing.T) {
+	var fetchedLimit int
+	withBackfill(t, true, func(symbol, interval string, limit int) ([]Kline, error) {
+		fetchedLimit = limit
+		return klinesAt(2, 3, 4, 5), nil // 最新K线，包含缺口两端
+	})
+	gapsBefore := testutil.ToFloat64(metrics.KlineGapsTotal.WithLabelValues("3m", "backfilled"))
+	candlesBefore := testutil.ToFloat64(metrics.KlineGapCandlesTotal.WithLabelValues("3m"))
+
+	m := &WSMonitor{}
+	m.klineDataMap3m.Store("BTCUSDT", klinesAt(0, 1, 2))
+	m.processKlineUpdate("BTCUSDT", streamKline(5), "3m") // 缺失 3、4
+
+	value, _ := m.klineDataMap3m.Load("BTCUSDT")
+	klines := value.([]Kline)
+	if got := openTimes(klines); len(got) != 6 || got[3] != 3 || got[4] != 4 || got[5] != 5 {
+		t.Fatalf("补齐后的K线开盘时间 = %v, want [0 1 2 3 4 5]", got)
+	}
+	if klines[3].Close != 103 || klines[5].Close != 105 {
+		t.Errorf("补齐的K线应来自REST，最新K线来自实时流: %+v", klines[3:])
+	}
+	if fetchedLimit != 4 {
+		t.Errorf("REST 请求数量 = %d, want 4（缺失2根加两端）", fetchedLimit)
+	}
+	if got := testutil.ToFloat64(metrics.KlineGapsTotal.WithLabelValues("3m", "backfilled")) - gapsBefore; got != 1 {
+		t.Errorf("缺口计数增加 %v, want 1", got)
+	}
+	if got := testutil.ToFloat64(metrics.KlineGapCandlesTotal.WithLabelValues("3m")) - candlesBefore; got != 2 {
+		t.Errorf("缺失K线计数增加 %v, want 2", got)
+	}
+
+	// 连续的K线和同一根K线的更新不触发补齐
+	fetchedLimit = 0
+	m.processKlineUpdate("BTCUSDT", streamKline(5), "3m")
+	m.processKlineUpdate("BTCUSDT", streamKline(6), "3m")
+	if fetchedLimit != 0 {
+		t.Errorf("没有缺口时不应请求REST")
+	}
+}
+
+// TestProcessKlineUpdate_GapWithoutBackfill 测试补齐失败或关闭补齐时仍记录缺口并照常追加最新K线
+func TestProcessKlineUpdate_GapWithoutBackfill(t *testing.T) {
+	withBackfill(t, true, func(symbol, interval string, limit int) ([]Kline, error) {
+		return nil, errors.New("rate limited")
+	})
+	failedBefore := testutil.ToFloat64(metrics.KlineGapsTotal.WithLabelValues("3m", "failed"))
+
+	m := &WSMonitor{}
+	m.klineDataMap3m.Store("ETHUSDT", klinesAt(0, 1))
+	m.processKlineUpdate("ETHUSDT", streamKline(4), "3m")
+	value, _ := m.klineDataMap3m.Load("ETHUSDT")
+	if got := openTimes(value.([]Kline)); len(got) != 3 || got[2] != 4 {
+		t.Errorf("补齐失败时K线 = %v, want [0 1 4]", got)
+	}
+	if got := testutil.ToFloat64(metrics.KlineGapsTotal.WithLabelValues("3m", "failed")) - failedBefore; got != 1 {
+		t.Errorf("补齐失败计数增加 %v, want 1", got)
+	}
+
+	fetched := false
+	withBackfill(t, false, func(symbol, interval string, limit int) ([]Kline, error) {
+		fetched = true
+		return nil, nil
+	})
+	skippedBefore := testutil.ToFloat64(metrics.KlineGapsTotal.WithLabelValues("3m", "skipped"))
+	m.processKlineUpdate("ETHUSDT", streamKline(6), "3m")
+	if fetched {
+		t.Error("关闭补齐时不应请求REST")
+	}
+	if got := testutil.ToFloat64(metrics.KlineGapsTotal.WithLabelValues("3m", "skipped")) - skippedBefore; got != 1 {
+		t.Errorf("未补齐计数增加 %v, want 1", got)
+	}
+}
+
+// TestMissingKlines 测试按周期计算缺失K线根数
+func TestMissingKlines(t *testing.T) {
+	tests := []struct {
+		last, next int64
+		interval   string
+		want       int
+	}{
+		{0, threeMinutes, "3m", 0},
+		{0, 4 * threeMinutes, "3m", 3},
+		{0, 2 * 14400000, "4h", 1},
+		{threeMinutes, threeMinutes, "3m", 0},
+		{2 * threeMinutes, threeMinutes, "3m", 0},
+		{0, 3 * 2592000000, "1M", 0},
+	}
+	for _, tt := range tests {
+		if got := missingKlines(Kline{OpenTime: tt.last}, Kline{OpenTime: tt.next}, tt.interval); got != tt.want {
+			t.Errorf("missingKlines(%d, %d, %s) = %d, want %d", tt.last, tt.next, tt.interval, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package market
+
+import (
+	"log"
+	"strings"
+	"sync"
+)
+
+// maxLeverages 按币种的最大杠杆
+// configured 来自 config.json 的 max_leverage（优先），exchange 来自数据源元数据（Hyperliquid meta 的 maxLeverage、Binance 杠杆分层）
+var maxLeverages = struct {
+	sync.RWMutex
+	configured map[string]int
+	exchange   map[string]int
+}{
+	configured: make(map[string]int),
+	exchange:   make(map[string]int),
+}
+
+// SetMaxLeverageOverrides 设置手动配置的币种最大杠杆（覆盖交易所元数据），非正数忽略
+func SetMaxLeverageOverrides(overrides map[string]int) {
+	configured := make(map[string]int, len(overrides))
+	for symbol, leverage := range overrides {
+		if leverage <= 0 {
+			log.Printf("⚠️  [Market] %s 最大杠杆 %d 无效，已忽略", symbol, leverage)
+			continue
+		}
+		configured[Normalize(symbol)] = leverage
+	}
+
+	maxLeverages.Lock()
+	maxLeverages.configured = configured
+	maxLeverages.Unlock()
+}
+
+// RegisterExchangeMaxLeverages 记录交易所给出的币种最大杠杆（如 Binance 杠杆分层的最高档），非正数忽略
+func RegisterExchangeMaxLeverages(leverages map[string]int) {
+	maxLeverages.Lock()
+	defer maxLeverages.Unlock()
+	for symbol, leverage := range leverages {
+		if leverage > 0 {
+			maxLeverages.exchange[strings.ToUpper(symbol)] = leverage
+		}
+	}
+}
+
+// registerExchangeMaxLeverages 记录 exchangeInfo 中的最大杠杆，未提供的币种不记录
+func registerExchangeMaxLeverages(info *ExchangeInfo) {
+	if info == nil {
+		return
+	}
+	leverages := make(map[string]int)
+	for _, s := range info.Symbols {
+		leverages[s.Symbol] = s.MaxLeverage
+	}
+	RegisterExchangeMaxLeverages(leverages)
+}
+
+// SymbolMaxLeverage 币种允许的最大杠杆（手动配置优先于交易所元数据），未知时返回 false
+func SymbolMaxLeverage(symbol string) (int, bool) {
+	symbol = strings.ToUpper(symbol)
+	maxLeverages.RLock()
+	defer maxLeverages.RUnlock()
+	if leverage, ok := maxLeverages.configured[symbol]; ok {
+		return leverage, true
+	}
+	leverage, ok := maxLeverages.exchange[symbol]
+	return leverage, ok
+}
@@ -0,0 +1,55 @@
+package market
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestSymbolMaxLeverage_FromHyperliquidMeta 测试从 Hyperliquid meta 的 maxLeverage 记录币种最大杠杆，手动配置优先
+func TestSymbolMaxLeverage_FromHyperliquidMeta(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"universe":[
+			{"name":"BTC","szDecimals":5,"maxLeverage":40},
+			{"name":"DOGE","szDecimals":0,"maxLeverage":20},
+			{"name":"OLD","szDecimals":0,"maxLeverage":3,"isDelisted":true}
+		]}`))
+	}))
+	defer server.Close()
+
+	prevConfig := dataSourceConfigs[DataSourceHyperliquid]
+	cfg := *prevConfig
+	cfg.BaseURL = server.URL
+	dataSourceConfigs[DataSourceHyperliquid] = &cfg
+	defer func() {
+		dataSourceConfigs[DataSourceHyperliquid] = prevConfig
+		SetMaxLeverageOverrides(nil)
+		maxLeverages.Lock()
+		maxLeverages.exchange = make(map[string]int)
+		maxLeverages.Unlock()
+	}()
+
+	info, err := NewAPIClientFor(DataSourceHyperliquid).GetExchangeInfo()
+	require.NoError(t, err)
+	registerExchangeMaxLeverages(info)
+
+	leverage, ok := SymbolMaxLeverage("dogeusdt")
+	assert.True(t, ok)
+	assert.Equal(t, 20, leverage)
+	leverage, ok = SymbolMaxLeverage("BTCUSDT")
+	assert.True(t, ok)
+	assert.Equal(t, 40, leverage)
+	for _, symbol := range []string{"OLDUSDT", "ETHUSDT"} {
+		_, ok := SymbolMaxLeverage(symbol)
+		assert.False(t, ok, symbol)
+	}
+
+	SetMaxLeverageOverrides(map[string]int{"BTCUSDT": 10, "ZEROUSDT": 0})
+	leverage, _ = SymbolMaxLeverage("BTCUSDT")
+	assert.Equal(t, 10, leverage, "手动配置优先于交易所元数据")
+	_, ok = SymbolMaxLeverage("ZEROUSDT")
+	assert.False(t, ok, "非正数配置忽略")
+}
@@ -0,0 +1,82 @@
+package market
+
+import (
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// minPositionSizes 按币种的最小开仓金额（USDT）
+// configured 来自 config.json 的 min_position_size（优先），exchange 来自数据源 exchangeInfo 的最小名义价值过滤器
+var minPositionSizes = struct {
+	sync.RWMutex
+	configured map[string]float64
+	exchange   map[string]float64
+}{
+	configured: make(map[string]float64),
+	exchange:   make(map[string]float64),
+}
+
+// SetMinPositionSizeOverrides 设置手动配置的币种最小开仓金额（覆盖 exchangeInfo 和默认值），非正数忽略
+func SetMinPositionSizeOverrides(overrides map[string]float64) {
+	configured := make(map[string]float64, len(overrides))
+	for symbol, size := range overrides {
+		if size <= 0 {
+			log.Printf("⚠️  [Market] %s 最小开仓金额 %.2f 无效，已忽略", symbol, size)
+			continue
+		}
+		configured[Normalize(symbol)] = size
+	}
+
+	minPositionSizes.Lock()
+	minPositionSizes.configured = configured
+	minPositionSizes.Unlock()
+}
+
+// registerExchangeMinNotionals 记录 exchangeInfo 中的最小名义价值，没有相关过滤器的币种不记录
+func registerExchangeMinNotionals(info *ExchangeInfo) {
+	if info == nil {
+		return
+	}
+	minPositionSizes.Lock()
+	defer minPositionSizes.Unlock()
+	for _, s := range info.Symbols {
+		if notional := symbolMinNotional(s); notional > 0 {
+			minPositionSizes.exchange[strings.ToUpper(s.Symbol)] = notional
+		}
+	}
+}
+
+// symbolMinNotional 从交易对过滤器中解析最小名义价值（解析失败返回 0）
+func symbolMinNotional(s SymbolInfo) float64 {
+	for _, f := range s.Filters {
+		if f.FilterType != "MIN_NOTIONAL" && f.FilterType != "NOTIONAL" {
+			continue
+		}
+		value := f.Notional
+		if value == "" {
+			value = f.MinNotional
+		}
+		if notional, err := strconv.ParseFloat(value, 64); err == nil && notional > 0 {
+			return notional
+		}
+	}
+	return 0
+}
+
+// ConfiguredMinPositionSize 手动配置的币种最小开仓金额，未配置时返回 false
+func ConfiguredMinPositionSize(symbol string) (float64, bool) {
+	minPositionSizes.RLock()
+	defer minPositionSizes.RUnlock()
+	size, ok := minPositionSizes.configured[strings.ToUpper(symbol)]
+	return size, ok
+}
+
+// ExchangeMinNotional exchangeInfo 中的币种最小名义价值，未知时返回 false
+func ExchangeMinNotional(symbol string) (float64, bool) {
+	minPositionSizes.RLock()
+	defer minPositionSizes.RUnlock()
+	notional, ok := minPositionSizes.exchange[strings.ToUpper(symbol)]
+	return notional, ok
+}
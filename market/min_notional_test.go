@@ -0,0 +1,42 @@
+package market
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestMinPositionSizes 测试从 exchangeInfo 过滤器解析最小名义价值，以及手动配置的最小开仓金额
+func TestMinPositionSizes(t *testing.T) {
+	defer func() {
+		SetMinPositionSizeOverrides(nil)
+		minPositionSizes.Lock()
+		minPositionSizes.exchange = make(map[string]float64)
+		minPositionSizes.Unlock()
+	}()
+
+	registerExchangeMinNotionals(&ExchangeInfo{Symbols: []SymbolInfo{
+		{Symbol: "SOLUSDT", Filters: []SymbolFilter{{FilterType: "PRICE_FILTER"}, {FilterType: "MIN_NOTIONAL", Notional: "5"}}},
+		{Symbol: "BTCUSD", Filters: []SymbolFilter{{FilterType: "NOTIONAL", MinNotional: "10.00000000"}}}, // 现货格式
+		{Symbol: "HYPEUSDT"}, // 未提供过滤器
+		{Symbol: "BADUSDT", Filters: []SymbolFilter{{FilterType: "MIN_NOTIONAL", Notional: "n/a"}}},
+	}})
+	SetMinPositionSizeOverrides(map[string]float64{"dogeusdt": 20, "ZEROUSDT": 0})
+
+	notional, ok := ExchangeMinNotional("solusdt")
+	assert.True(t, ok)
+	assert.Equal(t, 5.0, notional)
+	notional, ok = ExchangeMinNotional("BTCUSD")
+	assert.True(t, ok)
+	assert.Equal(t, 10.0, notional)
+	for _, symbol := range []string{"HYPEUSDT", "BADUSDT", "ETHUSDT"} {
+		_, ok := ExchangeMinNotional(symbol)
+		assert.False(t, ok, symbol)
+	}
+
+	size, ok := ConfiguredMinPositionSize("DOGEUSDT")
+	assert.True(t, ok)
+	assert.Equal(t, 20.0, size)
+	_, ok = ConfiguredMinPositionSize("ZEROUSDT")
+	assert.False(t, ok, "非正数配置忽略")
+}
@@ -3,8 +3,10 @@ package market
 import (
 	"aspen/metrics"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -18,11 +20,18 @@ type WSMonitor struct {
 	alertsChan     chan Alert
 	klineDataMap3m sync.Map // 存储每个交易对的K线历史数据
 	klineDataMap4h sync.Map // 存储每个交易对的K线历史数据
+	priceUpdatedAt sync.Map // 每个交易对3分钟K线缓存最近一次更新的时间（判断缓存价格是否过期）
 	tickerDataMap  sync.Map // 存储每个交易对的ticker数据
 	batchSize      int
 	filterSymbols  sync.Map // 使用sync.Map来存储需要监控的币种和其状态
 	symbolStats    sync.Map // 存储币种统计信息
 	FilterSymbol   []string //经过筛选的币种
+
+	subscribedMu sync.RWMutex
+	subscribed   map[string]map[string]bool // 已订阅实时K线的币种 -> K线周期
+
+	streamSubsMu sync.Mutex
+	streamSubs   map[string]*Subscriber // 监控器在组合流上注册的订阅者（流 -> 订阅者），取消订阅时只移除自己的订阅者
 }
 type SymbolStats struct {
 	LastActiveTime   time.Time
@@ -41,6 +50,7 @@ func NewWSMonitor(batchSize int) *WSMonitor {
 		combinedClient: NewCombinedStreamsClient(batchSize),
 		alertsChan:     make(chan Alert, 1000),
 		batchSize:      batchSize,
+		subscribed:     make(map[string]map[string]bool),
 	}
 	return WSMonitorCli
 }
@@ -55,6 +65,7 @@ func (m *WSMonitor) Initialize(coins []string) error {
 		if err != nil {
 			return err
 		}
+		registerExchangePrecisions(exchangeInfo)
 		// 筛选永续合约交易对 --仅测试时使用
 		//exchangeInfo.Symbols = exchangeInfo.Symbols[0:2]
 		for _, symbol := range exchangeInfo.Symbols {
@@ -101,7 +112,7 @@ func (m *WSMonitor) initializeHistoricalData() error {
 				return
 			}
 			if len(klines) > 0 {
-				m.klineDataMap3m.Store(s, klines)
+				m.storeKlines(s, "3m", klines)
 				log.Printf("已加载 %s 的历史K线数据-3m: %d 条", s, len(klines))
 			}
 			// 获取历史K线数据
@@ -153,19 +164,44 @@ func (m *WSMonitor) subscribeSymbol(symbol, st string) []string {
 		stream := fmt.Sprintf("kline.%s.%s", bybitInterval, symbol)
 		// 转换为 Binance 格式用于内部映射
 		binanceStream := fmt.Sprintf("%s@kline_%s", strings.ToLower(symbol), st)
-		ch := m.combinedClient.AddSubscriber(binanceStream, 100)
+		sub := m.addStreamSubscriber(binanceStream)
 		streams = append(streams, stream)
-		go m.handleKlineData(symbol, ch, st)
+		go m.handleKlineData(symbol, sub.C, st)
 	} else {
 		// Binance 格式
 		stream := fmt.Sprintf("%s@kline_%s", strings.ToLower(symbol), st)
-		ch := m.combinedClient.AddSubscriber(stream, 100)
+		sub := m.addStreamSubscriber(stream)
 		streams = append(streams, stream)
-		go m.handleKlineData(symbol, ch, st)
+		go m.handleKlineData(symbol, sub.C, st)
 	}
 
 	return streams
 }
+
+// addStreamSubscriber 在组合流上为流注册监控器的订阅者（已注册过的先移除，避免同一个流重复处理）
+func (m *WSMonitor) addStreamSubscriber(stream string) *Subscriber {
+	m.streamSubsMu.Lock()
+	defer m.streamSubsMu.Unlock()
+	if m.streamSubs == nil {
+		m.streamSubs = make(map[string]*Subscriber)
+	}
+	if old, ok := m.streamSubs[stream]; ok {
+		m.combinedClient.RemoveSubscriber(old)
+	}
+	sub := m.combinedClient.AddSubscriber(stream, 100)
+	m.streamSubs[stream] = sub
+	return sub
+}
+
+// removeStreamSubscriber 移除监控器在该流上的订阅者（同一个流的其他订阅者不受影响）
+func (m *WSMonitor) removeStreamSubscriber(stream string) {
+	m.streamSubsMu.Lock()
+	defer m.streamSubsMu.Unlock()
+	if sub, ok := m.streamSubs[stream]; ok {
+		m.combinedClient.RemoveSubscriber(sub)
+		delete(m.streamSubs, stream)
+	}
+}
 func (m *WSMonitor) subscribeAll() error {
 	// 执行批量订阅
 	log.Println("开始订阅所有交易对...")
@@ -180,6 +216,7 @@ func (m *WSMonitor) subscribeAll() error {
 			log.Printf("❌ 订阅 %s K线失败: %v", st, err)
 			return err
 		}
+		m.markSubscribed(st, m.symbols...)
 	}
 	log.Println("所有交易对订阅完成")
 	return nil
@@ -235,19 +272,31 @@ func (m *WSMonitor) processKlineUpdate(symbol string, wsData KlineWSData, _time
 			// 更新当前K线
 			klines[len(klines)-1] = kline
 		} else {
+			// 实时流跳过了K线时先通过REST补齐缺口，避免指标窗口错位
+			if len(klines) > 0 {
+				klines = append(klines, fillKlineGap(symbol, _time, klines[len(klines)-1], kline)...)
+			}
 			// 添加新K线
 			klines = append(klines, kline)
 
 			// 保持数据长度
-			if len(klines) > 100 {
-				klines = klines[1:]
+			if len(klines) > klineCacheSize {
+				klines = klines[len(klines)-klineCacheSize:]
 			}
 		}
 	} else {
 		klines = []Kline{kline}
 	}
 
-	klineDataMap.Store(symbol, klines)
+	m.storeKlines(symbol, _time, klines)
+}
+
+// storeKlines 更新K线缓存，3分钟K线同时记录更新时间（缓存价格取自最新的3分钟K线）
+func (m *WSMonitor) storeKlines(symbol, _time string, klines []Kline) {
+	m.getKlineDataMap(_time).Store(symbol, klines)
+	if _time == "3m" {
+		m.priceUpdatedAt.Store(symbol, time.Now())
+	}
 }
 
 func (m *WSMonitor) GetCurrentKlines(symbol string, _time string) ([]Kline, error) {
@@ -260,11 +309,11 @@ func (m *WSMonitor) GetCurrentKlines(symbol string, _time string) ([]Kline, erro
 		klines, err := apiClient.GetKlines(symbol, _time, 100)
 		if err != nil {
 			log.Printf("❌ [Market] 获取 %s 的 %s K线数据失败: %v", symbol, _time, err)
-			return nil, fmt.Errorf("获取%v分钟K线失败: %v", _time, err)
+			return nil, fmt.Errorf("获取%v分钟K线失败: %w", _time, err)
 		}
 
 		// 动态缓存进缓存
-		m.getKlineDataMap(_time).Store(strings.ToUpper(symbol), klines)
+		m.storeKlines(strings.ToUpper(symbol), _time, klines)
 
 		// 订阅 WebSocket 流
 		subStr := m.subscribeSymbol(symbol, _time)
@@ -272,6 +321,8 @@ func (m *WSMonitor) GetCurrentKlines(symbol string, _time string) ([]Kline, erro
 		log.Printf("动态订阅流: %v", subStr)
 		if subErr != nil {
 			log.Printf("警告: 动态订阅%v分钟K线失败: %v (使用API数据)", _time, subErr)
+		} else {
+			m.markSubscribed(_time, strings.ToUpper(symbol))
 		}
 
 		// ✅ FIX: 返回深拷贝而非引用
@@ -287,6 +338,141 @@ func (m *WSMonitor) GetCurrentKlines(symbol string, _time string) ([]Kline, erro
 	return result, nil
 }
 
+// markSubscribed 记录币种已订阅该周期的K线
+func (m *WSMonitor) markSubscribed(st string, symbols ...string) {
+	m.subscribedMu.Lock()
+	defer m.subscribedMu.Unlock()
+	for _, symbol := range symbols {
+		if m.subscribed[symbol] == nil {
+			m.subscribed[symbol] = make(map[string]bool)
+		}
+		m.subscribed[symbol][st] = true
+	}
+	metrics.SetSubscribedSymbols(len(m.subscribed))
+}
+
+// missingIntervals 币种尚未订阅的K线周期
+func (m *WSMonitor) missingIntervals(symbol string) []string {
+	m.subscribedMu.RLock()
+	defer m.subscribedMu.RUnlock()
+	var missing []string
+	for _, st := range subKlineTime {
+		if !m.subscribed[symbol][st] {
+			missing = append(missing, st)
+		}
+	}
+	return missing
+}
+
+// SubscribedSymbols 当前已订阅实时K线的币种（按字母排序）
+func (m *WSMonitor) SubscribedSymbols() []string {
+	m.subscribedMu.RLock()
+	defer m.subscribedMu.RUnlock()
+	symbols := make([]string, 0, len(m.subscribed))
+	for symbol := range m.subscribed {
+		symbols = append(symbols, symbol)
+	}
+	sort.Strings(symbols)
+	return symbols
+}
+
+// Subscribe 订阅币种的实时K线：先通过 REST 回填K线缓存，再订阅 WebSocket 流（已订阅的周期跳过）。
+// 单个币种失败不影响其他币种，返回汇总的错误
+func (m *WSMonitor) Subscribe(symbols []string) error {
+	apiClient := NewAPIClient()
+	pending := make(map[string][]string) // K线周期 -> 待订阅币种
+	var errs []error
+	for _, symbol := range symbols {
+		symbol = Normalize(symbol)
+		for _, st := range m.missingIntervals(symbol) {
+			klines, err := apiClient.GetKlines(symbol, st, 100)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("回填 %s %s K线失败: %w", symbol, st, err))
+				continue
+			}
+			m.storeKlines(symbol, st, klines)
+			m.subscribeSymbol(symbol, st)
+			pending[st] = append(pending[st], symbol)
+		}
+	}
+
+	for st, batch := range pending {
+		if err := m.combinedClient.BatchSubscribeKlines(batch, st); err != nil {
+			// 订阅失败时移除订阅者，下次对账重试
+			for _, symbol := range batch {
+				m.removeStreamSubscriber(klineStream(symbol, st))
+			}
+			errs = append(errs, fmt.Errorf("订阅 %s K线失败: %w", st, err))
+			continue
+		}
+		m.markSubscribed(st, batch...)
+	}
+	return errors.Join(errs...)
+}
+
+// Unsubscribe 取消订阅币种的实时K线并清理缓存（取消订阅请求发送失败时本地状态仍会清理）
+func (m *WSMonitor) Unsubscribe(symbols []string) error {
+	var errs []error
+	for _, st := range subKlineTime {
+		if err := m.combinedClient.BatchUnsubscribeKlines(symbols, st); err != nil {
+			errs = append(errs, fmt.Errorf("取消订阅 %s K线失败: %w", st, err))
+		}
+	}
+
+	m.subscribedMu.Lock()
+	for _, symbol := range symbols {
+		for _, st := range subKlineTime {
+			m.removeStreamSubscriber(klineStream(symbol, st))
+			m.getKlineDataMap(st).Delete(symbol)
+		}
+		delete(m.subscribed, symbol)
+	}
+	metrics.SetSubscribedSymbols(len(m.subscribed))
+	m.subscribedMu.Unlock()
+	return errors.Join(errs...)
+}
+
+// klineStream 内部映射使用的K线流名称（Binance 格式）
+func klineStream(symbol, st string) string {
+	return fmt.Sprintf("%s@kline_%s", strings.ToLower(symbol), st)
+}
+
+// HasKlines 该币种的3分钟和4小时K线是否已在WebSocket缓存中（已订阅）
+func (m *WSMonitor) HasKlines(symbol string) bool {
+	_, has3m := m.klineDataMap3m.Load(symbol)
+	_, has4h := m.klineDataMap4h.Load(symbol)
+	return has3m && has4h
+}
+
+// cachedPriceMaxAge 缓存价格的最大有效期：K线缓存超过该时长未更新（WebSocket 推送中断）时视为过期
+var cachedPriceMaxAge = 30 * time.Second
+
+// GetCachedPrice 从WebSocket K线缓存读取最新价格（不发起REST请求）；
+// 缓存超过 cachedPriceMaxAge 未更新时返回 false，由调用方通过 REST 获取
+func (m *WSMonitor) GetCachedPrice(symbol string) (float64, bool) {
+	value, exists := m.klineDataMap3m.Load(symbol)
+	if !exists {
+		return 0, false
+	}
+	updatedAt, ok := m.priceUpdatedAt.Load(symbol)
+	if !ok || time.Since(updatedAt.(time.Time)) > cachedPriceMaxAge {
+		return 0, false
+	}
+	klines := value.([]Kline)
+	if len(klines) == 0 || klines[len(klines)-1].Close <= 0 {
+		return 0, false
+	}
+	return klines[len(klines)-1].Close, true
+}
+
+// GetCachedPrice 从全局行情缓存读取最新价格，监控器未启动、无缓存或缓存已过期时返回 false
+func GetCachedPrice(symbol string) (float64, bool) {
+	if WSMonitorCli == nil {
+		return 0, false
+	}
+	return WSMonitorCli.GetCachedPrice(Normalize(symbol))
+}
+
 func (m *WSMonitor) Close() {
 	m.wsClient.Close()
 	close(m.alertsChan)
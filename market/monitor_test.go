@@ -0,0 +1,77 @@
+package market
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// TestWSMonitor_UnsubscribeClearsState 测试取消订阅后清理订阅记录、订阅者和K线缓存（WebSocket 未连接时本地状态仍会清理）
+func TestWSMonitor_UnsubscribeClearsState(t *testing.T) {
+	prevMonitor := WSMonitorCli
+	defer func() { WSMonitorCli = prevMonitor }()
+	m := NewWSMonitor(10)
+
+	for _, st := range subKlineTime {
+		m.subscribeSymbol("SOLUSDT", st)
+		m.getKlineDataMap(st).Store("SOLUSDT", []Kline{{Close: 150}})
+		m.markSubscribed(st, "SOLUSDT", "BTCUSDT")
+	}
+	if got := m.SubscribedSymbols(); !reflect.DeepEqual(got, []string{"BTCUSDT", "SOLUSDT"}) {
+		t.Fatalf("SubscribedSymbols = %v", got)
+	}
+	if missing := m.missingIntervals("SOLUSDT"); len(missing) != 0 {
+		t.Fatalf("已订阅币种不应缺少周期: %v", missing)
+	}
+
+	if err := m.Unsubscribe([]string{"SOLUSDT"}); err == nil {
+		t.Error("WebSocket 未连接时应返回取消订阅请求的错误")
+	}
+	if got := m.SubscribedSymbols(); !reflect.DeepEqual(got, []string{"BTCUSDT"}) {
+		t.Errorf("SubscribedSymbols = %v, want [BTCUSDT]", got)
+	}
+	if m.HasKlines("SOLUSDT") {
+		t.Error("取消订阅后应清理K线缓存")
+	}
+	m.combinedClient.mu.RLock()
+	_, exists := m.combinedClient.subscribers[klineStream("SOLUSDT", "3m")]
+	m.combinedClient.mu.RUnlock()
+	if exists {
+		t.Error("取消订阅后应移除订阅者")
+	}
+	if missing := m.missingIntervals("SOLUSDT"); !reflect.DeepEqual(missing, subKlineTime) {
+		t.Errorf("missingIntervals = %v, want %v", missing, subKlineTime)
+	}
+}
+
+// TestWSMonitor_GetCachedPriceExpires 测试K线缓存超过最大有效期未更新（推送中断）时缓存价格视为过期，由调用方通过 REST 获取
+func TestWSMonitor_GetCachedPriceExpires(t *testing.T) {
+	prevMonitor := WSMonitorCli
+	defer func() { WSMonitorCli = prevMonitor }()
+	m := NewWSMonitor(1)
+
+	if _, ok := m.GetCachedPrice("BTCUSDT"); ok {
+		t.Fatal("无缓存时应返回 false")
+	}
+	m.storeKlines("BTCUSDT", "3m", klinesAt(0, 1, 2))
+	if price, ok := m.GetCachedPrice("BTCUSDT"); !ok || price != 102 {
+		t.Fatalf("新鲜缓存 GetCachedPrice = %.2f, %v, want 102, true", price, ok)
+	}
+
+	m.priceUpdatedAt.Store("BTCUSDT", time.Now().Add(-cachedPriceMaxAge-time.Second))
+	if _, ok := GetCachedPrice("BTCUSDT"); ok {
+		t.Error("缓存超过最大有效期未更新时应视为过期")
+	}
+
+	// 实时推送恢复后重新可用
+	m.processKlineUpdate("BTCUSDT", streamKline(2), "3m")
+	if _, ok := GetCachedPrice("BTCUSDT"); !ok {
+		t.Error("推送更新后缓存价格应重新可用")
+	}
+
+	// 没有记录更新时间的缓存不作为价格来源
+	m.klineDataMap3m.Store("ETHUSDT", klinesAt(0))
+	if _, ok := m.GetCachedPrice("ETHUSDT"); ok {
+		t.Error("更新时间未知的缓存应视为过期")
+	}
+}
@@ -0,0 +1,98 @@
+package market
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+)
+
+// maxPricePrecision 价格小数位数上限
+const maxPricePrecision = 12
+
+// pricePrecisions 按币种的价格小数位数
+// configured 来自 config.json 的 price_precision（优先），exchange 来自数据源 exchangeInfo 的 pricePrecision
+var pricePrecisions = struct {
+	sync.RWMutex
+	configured map[string]int
+	exchange   map[string]int
+}{
+	configured: make(map[string]int),
+	exchange:   make(map[string]int),
+}
+
+// SetPricePrecisionOverrides 设置手动配置的币种价格小数位数（覆盖 exchangeInfo 和动态精度），超出 0-12 的值忽略
+func SetPricePrecisionOverrides(overrides map[string]int) {
+	configured := make(map[string]int, len(overrides))
+	for symbol, decimals := range overrides {
+		if decimals < 0 || decimals > maxPricePrecision {
+			log.Printf("⚠️  [Market] %s 价格精度 %d 无效（0-%d），已忽略", symbol, decimals, maxPricePrecision)
+			continue
+		}
+		configured[Normalize(symbol)] = decimals
+	}
+
+	pricePrecisions.Lock()
+	pricePrecisions.configured = configured
+	pricePrecisions.Unlock()
+}
+
+// registerExchangePrecisions 记录 exchangeInfo 中的价格小数位数
+// 部分数据源（如 Hyperliquid、Bybit）不返回 pricePrecision，值为 0 的币种视为未提供，继续使用动态精度
+func registerExchangePrecisions(info *ExchangeInfo) {
+	if info == nil {
+		return
+	}
+	pricePrecisions.Lock()
+	defer pricePrecisions.Unlock()
+	for _, s := range info.Symbols {
+		if s.PricePrecision > 0 && s.PricePrecision <= maxPricePrecision {
+			pricePrecisions.exchange[strings.ToUpper(s.Symbol)] = s.PricePrecision
+		}
+	}
+}
+
+// LoadExchangePricePrecisions 从当前数据源的 exchangeInfo 加载币种价格小数位数、最小名义价值和最大杠杆
+func LoadExchangePricePrecisions() error {
+	info, err := NewAPIClient().GetExchangeInfo()
+	if err != nil {
+		return fmt.Errorf("获取交易对信息失败: %w", err)
+	}
+	registerExchangePrecisions(info)
+	registerExchangeMinNotionals(info)
+	registerExchangeMaxLeverages(info)
+	return nil
+}
+
+// pricePrecisionFor 获取币种的价格小数位数（手动配置优先于 exchangeInfo），未知时返回 false
+func pricePrecisionFor(symbol string) (int, bool) {
+	symbol = strings.ToUpper(symbol)
+	pricePrecisions.RLock()
+	defer pricePrecisions.RUnlock()
+	if decimals, ok := pricePrecisions.configured[symbol]; ok {
+		return decimals, true
+	}
+	decimals, ok := pricePrecisions.exchange[symbol]
+	return decimals, ok
+}
+
+// formatSymbolPrice 格式化币种价格：有配置或交易所精度时按该精度，否则使用动态精度
+func formatSymbolPrice(symbol string, price float64) string {
+	if decimals, ok := pricePrecisionFor(symbol); ok {
+		return fmt.Sprintf("%.*f", decimals, price)
+	}
+	return formatPriceWithDynamicPrecision(price)
+}
+
+// formatSymbolPriceSlice 格式化币种价格序列
+func formatSymbolPriceSlice(symbol string, values []float64) string {
+	decimals, ok := pricePrecisionFor(symbol)
+	if !ok {
+		return formatFloatSlice(values)
+	}
+	strValues := make([]string, len(values))
+	for i, v := range values {
+		strValues[i] = fmt.Sprintf("%.*f", decimals, v)
+	}
+	return "[" + strings.Join(strValues, ", ") + "]"
+}
@@ -0,0 +1,198 @@
+package market
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"aspen/metrics"
+)
+
+// 数据源请求类型
+const (
+	sourceKindOpenInterest = "open_interest"
+	sourceKindFundingRate  = "funding_rate"
+)
+
+// sourceErrorClass 数据源错误类别（同一类别的重复错误会被合并日志）
+type sourceErrorClass string
+
+const (
+	errClassHTTP   sourceErrorClass = "http"   // 网络/连接失败
+	errClassStatus sourceErrorClass = "status" // 非 200 状态码
+	errClassParse  sourceErrorClass = "parse"  // 响应解析失败
+	errClassAPI    sourceErrorClass = "api"    // 接口返回业务错误
+	errClassZero   sourceErrorClass = "zero"   // 返回值为 0（数据可疑，不计入失败率）
+	errClassOther  sourceErrorClass = "other"
+)
+
+const (
+	// sourceErrorLogWindow 同一数据源同类错误的日志间隔（窗口内只输出一次，其余计数）
+	sourceErrorLogWindow = 10 * time.Minute
+	// sourceErrorBudgetWindow 失败率统计的滚动窗口
+	sourceErrorBudgetWindow = 15 * time.Minute
+)
+
+// sourceError 带类别的数据源错误
+type sourceError struct {
+	class  sourceErrorClass
+	status int // HTTP 状态码（仅 status 类别）
+	err    error
+}
+
+func (e *sourceError) Error() string { return e.err.Error() }
+func (e *sourceError) Unwrap() error { return e.err }
+
+// newSourceError 创建带类别的数据源错误
+func newSourceError(class sourceErrorClass, format string, args ...interface{}) error {
+	return &sourceError{class: class, err: fmt.Errorf(format, args...)}
+}
+
+// newStatusError 创建非 200 状态码错误（保留状态码，用于区分数据源故障和单个币种的请求错误）
+func newStatusError(status int, format string, args ...interface{}) error {
+	return &sourceError{class: errClassStatus, status: status, err: fmt.Errorf(format, args...)}
+}
+
+// classifySourceError 获取错误类别（未分类的错误归为 other）
+func classifySourceError(err error) sourceErrorClass {
+	var se *sourceError
+	if errors.As(err, &se) {
+		return se.class
+	}
+	return errClassOther
+}
+
+// suppressionState 单个数据源/请求类型/错误类别的日志抑制状态
+type suppressionState struct {
+	lastLogged time.Time
+	suppressed int
+}
+
+// sourceOutcome 一次请求结果（用于滚动失败率）
+type sourceOutcome struct {
+	at     time.Time
+	failed bool
+}
+
+// sourceErrorTracker 数据源错误日志抑制和滚动失败率统计
+// 同一数据源同一请求类型的同类错误在 logWindow 内只输出一次，窗口结束后输出时附带被抑制的条数；
+// 首次出现和错误类别变化时总是立即输出
+type sourceErrorTracker struct {
+	mu           sync.Mutex
+	now          func() time.Time
+	logf         func(format string, args ...interface{})
+	logWindow    time.Duration
+	budgetWindow time.Duration
+	states       map[string]*suppressionState // key: source/kind/class
+	lastClass    map[string]sourceErrorClass  // key: source/kind，最近一次错误的类别
+	outcomes     map[string][]sourceOutcome   // key: source/kind
+}
+
+// newSourceErrorTracker 创建数据源错误跟踪器
+func newSourceErrorTracker(logWindow, budgetWindow time.Duration) *sourceErrorTracker {
+	return &sourceErrorTracker{
+		now:          time.Now,
+		logf:         log.Printf,
+		logWindow:    logWindow,
+		budgetWindow: budgetWindow,
+		states:       make(map[string]*suppressionState),
+		lastClass:    make(map[string]sourceErrorClass),
+		outcomes:     make(map[string][]sourceOutcome),
+	}
+}
+
+// sourceErrors 全局数据源错误跟踪器
+var sourceErrors = newSourceErrorTracker(sourceErrorLogWindow, sourceErrorBudgetWindow)
+
+// Failure 记录一次请求失败（计入失败率，日志按类别抑制）
+func (t *sourceErrorTracker) Failure(source DataSource, kind, symbol string, err error) {
+	class := classifySourceError(err)
+	metrics.RecordMarketSourceError(string(source), kind, string(class))
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.logLocked(source, kind, class, fmt.Sprintf("❌ [Market] %s 获取 %s 失败 (%s, %s): %v", source, kind, symbol, class, err))
+	t.recordLocked(source, kind, true)
+}
+
+// Warning 记录可疑数据（不计入失败率，日志按类别抑制）
+func (t *sourceErrorTracker) Warning(source DataSource, kind string, class sourceErrorClass, msg string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.logLocked(source, kind, class, "⚠️  [Market] "+msg)
+}
+
+// Success 记录一次请求成功
+func (t *sourceErrorTracker) Success(source DataSource, kind string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.recordLocked(source, kind, false)
+}
+
+// ErrorRatio 获取滚动窗口内的失败率
+func (t *sourceErrorTracker) ErrorRatio(source DataSource, kind string) float64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.ratioLocked(string(source) + "/" + kind)
+}
+
+// logLocked 按抑制规则输出日志（调用方已加锁）
+func (t *sourceErrorTracker) logLocked(source DataSource, kind string, class sourceErrorClass, msg string) {
+	kindKey := string(source) + "/" + kind
+	key := kindKey + "/" + string(class)
+	now := t.now()
+
+	prevClass, seen := t.lastClass[kindKey]
+	t.lastClass[kindKey] = class
+
+	state, ok := t.states[key]
+	if !ok {
+		// 首次出现：立即输出
+		t.states[key] = &suppressionState{lastLogged: now}
+		t.logf("%s", msg)
+		return
+	}
+	// 窗口内的同类错误只计数；错误类别发生变化时立即输出
+	if now.Sub(state.lastLogged) < t.logWindow && (!seen || prevClass == class) {
+		state.suppressed++
+		return
+	}
+	if state.suppressed > 0 {
+		msg = fmt.Sprintf("%s（过去 %s 内已抑制 %d 条相似错误）", msg, now.Sub(state.lastLogged).Round(time.Second), state.suppressed)
+	}
+	state.lastLogged = now
+	state.suppressed = 0
+	t.logf("%s", msg)
+}
+
+// recordLocked 记录请求结果并更新失败率指标（调用方已加锁）
+func (t *sourceErrorTracker) recordLocked(source DataSource, kind string, failed bool) {
+	key := string(source) + "/" + kind
+	t.outcomes[key] = append(t.outcomes[key], sourceOutcome{at: t.now(), failed: failed})
+	metrics.SetMarketSourceErrorRatio(string(source), kind, t.ratioLocked(key))
+}
+
+// ratioLocked 清理窗口外的记录并计算失败率（调用方已加锁）
+func (t *sourceErrorTracker) ratioLocked(key string) float64 {
+	cutoff := t.now().Add(-t.budgetWindow)
+	list := t.outcomes[key]
+	start := 0
+	for start < len(list) && list[start].at.Before(cutoff) {
+		start++
+	}
+	list = list[start:]
+	t.outcomes[key] = list
+
+	if len(list) == 0 {
+		return 0
+	}
+	failed := 0
+	for _, o := range list {
+		if o.failed {
+			failed++
+		}
+	}
+	return float64(failed) / float64(len(list))
+}
@@ -0,0 +1,127 @@
+package market
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestSourceErrorTracker 创建使用可控时钟并捕获日志的跟踪器
+func newTestSourceErrorTracker(now *time.Time) (*sourceErrorTracker, *[]string) {
+	var logs []string
+	tracker := newSourceErrorTracker(10*time.Minute, 15*time.Minute)
+	tracker.now = func() time.Time { return *now }
+	tracker.logf = func(format string, args ...interface{}) {
+		logs = append(logs, fmt.Sprintf(format, args...))
+	}
+	return tracker, &logs
+}
+
+// TestFetchDerivativesData_UnsupportedSourceShortCircuit 测试数据源不支持OI/资金费率时不请求也不记录日志
+func TestFetchDerivativesData_UnsupportedSourceShortCircuit(t *testing.T) {
+	now := time.Now()
+	tracker, logs := newTestSourceErrorTracker(&now)
+
+	prevTracker, prevSource := sourceErrors, currentDataSource
+	sourceErrors, currentDataSource = tracker, DataSourceBinanceUS
+	defer func() { sourceErrors, currentDataSource = prevTracker, prevSource }()
+
+	for i := 0; i < 50; i++ {
+		oi, funding, fundingUpdatedAt := fetchDerivativesData("", "BTCUSDT")
+		if oi == nil || oi.Latest != 0 || oi.Average != 0 || funding != 0 || !fundingUpdatedAt.IsZero() {
+			t.Fatalf("fetchDerivativesData() = %+v, %v, %v, want zero values", oi, funding, fundingUpdatedAt)
+		}
+	}
+
+	if len(*logs) != 0 {
+		t.Errorf("不支持的数据源不应输出日志: %v", *logs)
+	}
+	if len(tracker.outcomes) != 0 {
+		t.Errorf("不支持的数据源不应计入失败率: %v", tracker.outcomes)
+	}
+}
+
+// TestSourceErrorTracker_Suppression 测试重复错误按窗口合并日志，首次出现和类别变化立即输出
+func TestSourceErrorTracker_Suppression(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	tracker, logs := newTestSourceErrorTracker(&now)
+
+	statusErr := newSourceError(errClassStatus, "API返回错误状态码 451")
+	parseErr := newSourceError(errClassParse, "解析JSON响应失败")
+
+	tracker.Failure(DataSourceBinance, sourceKindOpenInterest, "BTCUSDT", statusErr)
+	if len(*logs) != 1 {
+		t.Fatalf("首次出现应立即输出, logs = %v", *logs)
+	}
+
+	// 窗口内同类错误（不同币种）只计数
+	for i := 0; i < 412; i++ {
+		now = now.Add(time.Second)
+		tracker.Failure(DataSourceBinance, sourceKindOpenInterest, fmt.Sprintf("SYM%dUSDT", i), statusErr)
+	}
+	if len(*logs) != 1 {
+		t.Fatalf("窗口内的同类错误应被抑制, logs = %v", *logs)
+	}
+
+	// 其他请求类型的错误独立计数
+	tracker.Failure(DataSourceBinance, sourceKindFundingRate, "BTCUSDT", statusErr)
+	if len(*logs) != 2 {
+		t.Fatalf("不同请求类型的首次错误应立即输出, logs = %v", *logs)
+	}
+
+	// 窗口结束后输出并附带被抑制的条数
+	now = now.Add(10 * time.Minute)
+	tracker.Failure(DataSourceBinance, sourceKindOpenInterest, "BTCUSDT", statusErr)
+	if len(*logs) != 3 || !strings.Contains((*logs)[2], "已抑制 412 条相似错误") {
+		t.Fatalf("窗口结束后应输出抑制计数, logs = %v", *logs)
+	}
+
+	// 类别变化立即输出
+	now = now.Add(time.Second)
+	tracker.Failure(DataSourceBinance, sourceKindOpenInterest, "BTCUSDT", parseErr)
+	if len(*logs) != 4 || !strings.Contains((*logs)[3], "parse") {
+		t.Fatalf("错误类别变化应立即输出, logs = %v", *logs)
+	}
+
+	// 类别切回时同样立即输出（即使仍在该类别的窗口内）
+	now = now.Add(time.Second)
+	tracker.Failure(DataSourceBinance, sourceKindOpenInterest, "BTCUSDT", statusErr)
+	if len(*logs) != 5 {
+		t.Fatalf("错误类别切回应立即输出, logs = %v", *logs)
+	}
+
+	// 未分类的错误归为 other
+	if got := classifySourceError(fmt.Errorf("wrapped: %w", errors.New("x"))); got != errClassOther {
+		t.Errorf("classifySourceError() = %s, want other", got)
+	}
+	if got := classifySourceError(fmt.Errorf("wrapped: %w", parseErr)); got != errClassParse {
+		t.Errorf("classifySourceError() = %s, want parse", got)
+	}
+}
+
+// TestSourceErrorTracker_ErrorRatio 测试滚动窗口失败率
+func TestSourceErrorTracker_ErrorRatio(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	tracker, _ := newTestSourceErrorTracker(&now)
+
+	for i := 0; i < 3; i++ {
+		tracker.Failure(DataSourceBybit, sourceKindFundingRate, "BTCUSDT", newSourceError(errClassHTTP, "timeout"))
+	}
+	tracker.Success(DataSourceBybit, sourceKindFundingRate)
+
+	if got := tracker.ErrorRatio(DataSourceBybit, sourceKindFundingRate); got != 0.75 {
+		t.Errorf("ErrorRatio() = %v, want 0.75", got)
+	}
+	if got := tracker.ErrorRatio(DataSourceBybit, sourceKindOpenInterest); got != 0 {
+		t.Errorf("没有请求时 ErrorRatio() = %v, want 0", got)
+	}
+
+	// 失败记录滑出窗口后只剩新的成功记录
+	now = now.Add(16 * time.Minute)
+	tracker.Success(DataSourceBybit, sourceKindFundingRate)
+	if got := tracker.ErrorRatio(DataSourceBybit, sourceKindFundingRate); got != 0 {
+		t.Errorf("窗口滑出后 ErrorRatio() = %v, want 0", got)
+	}
+}
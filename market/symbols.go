@@ -0,0 +1,114 @@
+package market
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// tradableSymbolsCacheTTL 可交易币种列表缓存时间
+const tradableSymbolsCacheTTL = time.Hour
+
+// tradableSymbolsCache 按数据源缓存的可交易币种列表
+var tradableSymbolsCache = struct {
+	sync.Mutex
+	symbols   map[DataSource]map[string]bool
+	fetchedAt map[DataSource]time.Time
+}{
+	symbols:   make(map[DataSource]map[string]bool),
+	fetchedAt: make(map[DataSource]time.Time),
+}
+
+// ParseSymbolList 解析存储的交易币种列表
+// 兼容 JSON 数组（["BTCUSDT","ETHUSDT"]）和旧的逗号分隔格式（"BTCUSDT,ETHUSDT"），不做标准化
+func ParseSymbolList(raw string) []string {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return nil
+	}
+
+	var parts []string
+	if strings.HasPrefix(raw, "[") {
+		if err := json.Unmarshal([]byte(raw), &parts); err != nil {
+			parts = strings.Split(strings.Trim(raw, "[]"), ",")
+		}
+	} else {
+		parts = strings.Split(raw, ",")
+	}
+
+	symbols := make([]string, 0, len(parts))
+	for _, part := range parts {
+		part = strings.Trim(strings.TrimSpace(part), `"'`)
+		if part != "" {
+			symbols = append(symbols, part)
+		}
+	}
+	return symbols
+}
+
+// NormalizeSymbols 标准化交易币种列表：去空格、转大写、补全USDT后缀、去重（保持原顺序）
+// 返回标准化后的列表和给用户的提示信息
+func NormalizeSymbols(raw []string) ([]string, []string) {
+	var symbols []string
+	var warnings []string
+	seen := make(map[string]bool)
+
+	for _, item := range raw {
+		item = strings.ToUpper(strings.TrimSpace(item))
+		if item == "" {
+			continue
+		}
+
+		symbol := Normalize(item)
+		if symbol != item {
+			warnings = append(warnings, fmt.Sprintf("%s 已识别为 %s", item, symbol))
+		}
+		if seen[symbol] {
+			warnings = append(warnings, fmt.Sprintf("%s 重复，已去重", symbol))
+			continue
+		}
+		seen[symbol] = true
+		symbols = append(symbols, symbol)
+	}
+
+	return symbols, warnings
+}
+
+// GetTradableSymbols 获取指定数据源可交易的币种集合（带缓存，source 为空时使用全局数据源）
+// 数据源不提供交易对列表时（如 Finnhub）返回 nil，表示无法校验
+func GetTradableSymbols(source DataSource) (map[string]bool, error) {
+	source = resolveDataSource(source)
+
+	tradableSymbolsCache.Lock()
+	if symbols, ok := tradableSymbolsCache.symbols[source]; ok && time.Since(tradableSymbolsCache.fetchedAt[source]) < tradableSymbolsCacheTTL {
+		tradableSymbolsCache.Unlock()
+		return symbols, nil
+	}
+	tradableSymbolsCache.Unlock()
+
+	// 请求交易所时不持有缓存锁，避免慢请求阻塞其他数据源的查询
+	exchangeInfo, err := NewAPIClientFor(source).GetExchangeInfo()
+	if err != nil {
+		return nil, fmt.Errorf("获取交易对信息失败: %w", err)
+	}
+	if len(exchangeInfo.Symbols) == 0 {
+		return nil, nil
+	}
+	registerExchangePrecisions(exchangeInfo)
+
+	symbols := make(map[string]bool, len(exchangeInfo.Symbols))
+	for _, info := range exchangeInfo.Symbols {
+		if info.Status != "" && info.Status != "TRADING" {
+			continue
+		}
+		symbols[strings.ToUpper(info.Symbol)] = true
+	}
+
+	tradableSymbolsCache.Lock()
+	tradableSymbolsCache.symbols[source] = symbols
+	tradableSymbolsCache.fetchedAt[source] = time.Now()
+	tradableSymbolsCache.Unlock()
+	return symbols, nil
+}
@@ -0,0 +1,73 @@
+package market
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseSymbolList(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+		want []string
+	}{
+		{"空字符串", "", nil},
+		{"逗号分隔", "BTCUSDT, ETHUSDT,,", []string{"BTCUSDT", "ETHUSDT"}},
+		{"JSON数组", `["BTCUSDT","ETHUSDT"]`, []string{"BTCUSDT", "ETHUSDT"}},
+		{"不规范的数组", `[BTC, "ETH"]`, []string{"BTC", "ETH"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ParseSymbolList(tt.raw); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseSymbolList(%q) = %v, want %v", tt.raw, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeSymbols(t *testing.T) {
+	symbols, warnings := NormalizeSymbols([]string{" doge", "BTCUSDT", "btc", "ETHUSDT", "DOGEUSDT"})
+
+	want := []string{"DOGEUSDT", "BTCUSDT", "ETHUSDT"}
+	if !reflect.DeepEqual(symbols, want) {
+		t.Errorf("symbols = %v, want %v", symbols, want)
+	}
+	wantWarnings := []string{
+		"DOGE 已识别为 DOGEUSDT",
+		"BTC 已识别为 BTCUSDT",
+		"BTCUSDT 重复，已去重",
+		"DOGEUSDT 重复，已去重",
+	}
+	if !reflect.DeepEqual(warnings, wantWarnings) {
+		t.Errorf("warnings = %v, want %v", warnings, wantWarnings)
+	}
+}
+
+func TestGetTradableSymbols_PerSource(t *testing.T) {
+	prevSource := currentDataSource
+	currentDataSource = DataSourceBinance
+	tradableSymbolsCache.Lock()
+	tradableSymbolsCache.symbols[DataSourceBinance] = map[string]bool{"BTCUSDT": true}
+	tradableSymbolsCache.fetchedAt[DataSourceBinance] = time.Now()
+	tradableSymbolsCache.symbols[DataSourceBybit] = map[string]bool{"HYPEUSDT": true}
+	tradableSymbolsCache.fetchedAt[DataSourceBybit] = time.Now()
+	tradableSymbolsCache.Unlock()
+	defer func() {
+		currentDataSource = prevSource
+		tradableSymbolsCache.Lock()
+		delete(tradableSymbolsCache.symbols, DataSourceBinance)
+		delete(tradableSymbolsCache.symbols, DataSourceBybit)
+		tradableSymbolsCache.Unlock()
+	}()
+
+	symbols, err := GetTradableSymbols(DataSourceBybit)
+	if err != nil || !symbols["HYPEUSDT"] || symbols["BTCUSDT"] {
+		t.Errorf("bybit symbols = %v, err = %v, want the bybit list", symbols, err)
+	}
+	symbols, err = GetTradableSymbols("")
+	if err != nil || !symbols["BTCUSDT"] {
+		t.Errorf("global symbols = %v, err = %v, want the binance list", symbols, err)
+	}
+}
@@ -8,13 +8,28 @@ type Data struct {
 	CurrentPrice      float64
 	PriceChange1h     float64 // 1小时价格变化百分比
 	PriceChange4h     float64 // 4小时价格变化百分比
-	CurrentEMA20      float64
+	CurrentEMA20      float64 // 快速EMA（默认20周期，见 Indicators）
 	CurrentMACD       float64
-	CurrentRSI7       float64
+	CurrentRSI7       float64 // 短周期RSI（默认7周期，见 Indicators）
+	CurrentWilliamsR  float64 // Williams %R（14周期），范围 [-100, 0]
+	CurrentMFI        float64 // 资金流量指标MFI（14周期），范围 [0, 100]
+	BollingerUpper    float64 // 布林带上轨（20周期，2倍标准差）
+	BollingerMiddle   float64 // 布林带中轨（20周期SMA）
+	BollingerLower    float64 // 布林带下轨（20周期，2倍标准差）
+	SARValue          float64 // 抛物线转向指标SAR（0.02/0.2）
+	SARTrend          int     // SAR趋势：1=上升（SAR在价格下方），-1=下降（SAR在价格上方）
+	SARFlipped        bool    // SAR是否在最新K线发生反转
+	ZeroVolumeCandles int     // 最近连续成交量为 0 的3分钟K线根数（停牌或暂停交易的迹象）
 	OpenInterest      *OIData
 	FundingRate       float64
+	FundingUpdatedAt  time.Time // 资金费率的获取时间（刷新失败时为沿用的缓存值的获取时间），零值表示未知
+	LatestCandleAt    time.Time // 最新一根3分钟K线的开盘时间（行情源中断时不再前进），零值表示未知
+	NoOpenInterest    bool      // 数据源不提供持仓量（提示词中省略，不按 0 输出）
+	NoFundingRate     bool      // 数据源不提供资金费率（提示词中省略，不按 0 输出）
+	SpotOnly          bool      // 数据源只有现货数据（无永续合约的 OI 和资金费率，如 Binance.US、Finnhub）
 	IntradaySeries    *IntradayData
 	LongerTermContext *LongerTermData
+	Indicators        IndicatorParams // 计算基础指标使用的周期（零值表示默认周期）
 	// 1—10 指标字段（新增）
 	CurrentTSI            float64
 	CurrentTSISignal      float64
@@ -102,13 +117,23 @@ type ExchangeInfo struct {
 }
 
 type SymbolInfo struct {
-	Symbol            string `json:"symbol"`
-	Status            string `json:"status"`
-	BaseAsset         string `json:"baseAsset"`
-	QuoteAsset        string `json:"quoteAsset"`
-	ContractType      string `json:"contractType"`
-	PricePrecision    int    `json:"pricePrecision"`
-	QuantityPrecision int    `json:"quantityPrecision"`
+	Symbol            string         `json:"symbol"`
+	Status            string         `json:"status"`
+	BaseAsset         string         `json:"baseAsset"`
+	QuoteAsset        string         `json:"quoteAsset"`
+	ContractType      string         `json:"contractType"`
+	PricePrecision    int            `json:"pricePrecision"`
+	QuantityPrecision int            `json:"quantityPrecision"`
+	Filters           []SymbolFilter `json:"filters"`
+	MaxLeverage       int            `json:"maxLeverage,omitempty"` // 最大杠杆（Hyperliquid meta 提供，Binance exchangeInfo 不提供）
+}
+
+// SymbolFilter 交易对过滤器（只解析最小名义价值相关字段）
+// Binance 合约为 MIN_NOTIONAL/notional，现货（Binance US）为 NOTIONAL 或 MIN_NOTIONAL/minNotional
+type SymbolFilter struct {
+	FilterType  string `json:"filterType"`
+	Notional    string `json:"notional"`
+	MinNotional string `json:"minNotional"`
 }
 
 type Kline struct {
@@ -132,6 +157,22 @@ type PriceTicker struct {
 	Price  string `json:"price"`
 }
 
+// BookTicker 最优买卖价（盘口）
+type BookTicker struct {
+	Symbol   string  `json:"symbol"`
+	BidPrice float64 `json:"bid_price"`
+	AskPrice float64 `json:"ask_price"`
+}
+
+// SpreadBps 买卖价差（相对中间价，单位bps）
+func (b *BookTicker) SpreadBps() float64 {
+	mid := (b.BidPrice + b.AskPrice) / 2
+	if mid <= 0 {
+		return 0
+	}
+	return (b.AskPrice - b.BidPrice) / mid * 10000
+}
+
 type Ticker24hr struct {
 	Symbol             string `json:"symbol"`
 	PriceChange        string `json:"priceChange"`
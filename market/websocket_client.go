@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -14,9 +15,11 @@ import (
 type WSClient struct {
 	conn        *websocket.Conn
 	mu          sync.RWMutex
-	subscribers map[string]chan []byte
+	subscribers map[string][]chan []byte // 同一个流可以有多个订阅者，消息分发给每个订阅者
 	reconnect   bool
 	done        chan struct{}
+	streams     map[string]interface{} // 已订阅的流 -> 订阅消息（重连后重新发送）
+	retryDelay  time.Duration          // 断线后等待多久重连
 }
 
 type WSMessage struct {
@@ -71,9 +74,11 @@ type TickerWSData struct {
 
 func NewWSClient() *WSClient {
 	return &WSClient{
-		subscribers: make(map[string]chan []byte),
+		subscribers: make(map[string][]chan []byte),
 		reconnect:   true,
 		done:        make(chan struct{}),
+		streams:     make(map[string]interface{}),
+		retryDelay:  3 * time.Second,
 	}
 }
 
@@ -117,7 +122,7 @@ func (w *WSClient) SubscribeKline(symbol, interval string) error {
 				"interval": ConvertIntervalToHyperliquid(interval),
 			},
 		}
-		return w.sendJSON(msg)
+		return w.sendSubscription(fmt.Sprintf("%s@kline_%s", strings.ToLower(symbol), interval), msg)
 	}
 
 	// Binance/Bybit (Bybit handled in monitor.go via combined streams usually, but here for single stream)
@@ -141,7 +146,39 @@ func (w *WSClient) subscribe(stream string) error {
 		"params": []string{stream},
 		"id":     time.Now().Unix(),
 	}
-	return w.sendJSON(subscribeMsg)
+	return w.sendSubscription(stream, subscribeMsg)
+}
+
+// sendSubscription 发送订阅消息，发送成功后记录下来，断线重连后自动重新发送
+func (w *WSClient) sendSubscription(stream string, msg interface{}) error {
+	if err := w.sendJSON(msg); err != nil {
+		return err
+	}
+	w.mu.Lock()
+	w.streams[stream] = msg
+	w.mu.Unlock()
+	return nil
+}
+
+// resubscribe 重新发送所有已记录的订阅（新连接上没有任何订阅，不重新订阅的话数据会静默中断）
+func (w *WSClient) resubscribe() {
+	w.mu.RLock()
+	streams := make([]string, 0, len(w.streams))
+	for stream := range w.streams {
+		streams = append(streams, stream)
+	}
+	sort.Strings(streams)
+	msgs := make([]interface{}, len(streams))
+	for i, stream := range streams {
+		msgs[i] = w.streams[stream]
+	}
+	w.mu.RUnlock()
+
+	for i, msg := range msgs {
+		if err := w.sendJSON(msg); err != nil {
+			log.Printf("⚠️  重新订阅 %s 失败: %v", streams[i], err)
+		}
+	}
 }
 
 func (w *WSClient) sendJSON(msg interface{}) error {
@@ -200,17 +237,7 @@ func (w *WSClient) handleMessage(message []byte) {
 		return
 	}
 
-	w.mu.RLock()
-	ch, exists := w.subscribers[wsMsg.Stream]
-	w.mu.RUnlock()
-
-	if exists {
-		select {
-		case ch <- wsMsg.Data:
-		default:
-			log.Printf("订阅者通道已满: %s", wsMsg.Stream)
-		}
-	}
+	w.publish(wsMsg.Stream, wsMsg.Data)
 }
 
 func (w *WSClient) handleHyperliquidMessage(message []byte) {
@@ -259,11 +286,7 @@ func (w *WSClient) handleHyperliquidMessage(message []byte) {
 		symbol := coin + "USDT"
 		streamKey := fmt.Sprintf("%s@kline_%s", strings.ToLower(symbol), interval)
 
-		w.mu.RLock()
-		ch, exists := w.subscribers[streamKey]
-		w.mu.RUnlock()
-
-		if exists {
+		if w.hasSubscribers(streamKey) {
 			// We need to convert Hyperliquid candle to Binance KlineWSData format
 			// so monitor.go can consume it without changes.
 			// Or we change monitor.go to handle raw bytes differently.
@@ -316,11 +339,7 @@ func (w *WSClient) handleHyperliquidMessage(message []byte) {
 			}
 
 			jsonBytes, _ := json.Marshal(binanceMsg)
-
-			select {
-			case ch <- jsonBytes:
-			default:
-			}
+			w.publish(streamKey, jsonBytes)
 		}
 	}
 }
@@ -331,26 +350,44 @@ func (w *WSClient) handleReconnect() {
 	}
 
 	log.Println("尝试重新连接...")
-	time.Sleep(3 * time.Second)
+	time.Sleep(w.retryDelay)
 
 	if err := w.Connect(); err != nil {
 		log.Printf("重新连接失败: %v", err)
 		go w.handleReconnect()
+		return
 	}
+	w.resubscribe()
 }
 
-func (w *WSClient) AddSubscriber(stream string, bufferSize int) <-chan []byte {
-	ch := make(chan []byte, bufferSize)
+// AddSubscriber 为流注册一个新的订阅者（同一个流已有订阅者时追加，不影响已有订阅者）
+func (w *WSClient) AddSubscriber(stream string, bufferSize int) *Subscriber {
+	sub := newSubscriber(stream, bufferSize)
 	w.mu.Lock()
-	w.subscribers[stream] = ch
+	w.subscribers[stream] = append(w.subscribers[stream], sub.ch)
 	w.mu.Unlock()
-	return ch
+	return sub
 }
 
-func (w *WSClient) RemoveSubscriber(stream string) {
+// hasSubscribers 流是否有订阅者
+func (w *WSClient) hasSubscribers(stream string) bool {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return len(w.subscribers[stream]) > 0
+}
+
+// publish 将消息分发给流的每个订阅者
+func (w *WSClient) publish(stream string, data []byte) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	fanOut(stream, w.subscribers[stream], data)
+}
+
+// RemoveSubscriber 移除该订阅者并关闭其通道，同一个流的其他订阅者不受影响
+func (w *WSClient) RemoveSubscriber(sub *Subscriber) {
 	w.mu.Lock()
-	delete(w.subscribers, stream)
-	w.mu.Unlock()
+	defer w.mu.Unlock()
+	removeSubscriber(w.subscribers, sub)
 }
 
 func (w *WSClient) Close() {
@@ -366,8 +403,10 @@ func (w *WSClient) Close() {
 	}
 
 	// 关闭所有订阅者通道
-	for stream, ch := range w.subscribers {
-		close(ch)
+	for stream, chans := range w.subscribers {
+		for _, ch := range chans {
+			close(ch)
+		}
 		delete(w.subscribers, stream)
 	}
 }
@@ -1,10 +1,12 @@
 package mcp
 
 import (
+	"aspen/httpclient"
 	"aspen/metrics"
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -27,13 +29,81 @@ const (
 
 // Client AI API配置
 type Client struct {
-	Provider   Provider
-	APIKey     string
-	BaseURL    string
-	Model      string
-	Timeout    time.Duration
-	UseFullURL bool // 是否使用完整URL（不添加/chat/completions）
-	MaxTokens  int  // AI响应的最大token数
+	Provider         Provider
+	APIKey           string
+	BaseURL          string
+	Model            string
+	Timeout          time.Duration // 单次尝试的超时上限（实际超时由调用时间预算分配）
+	UseFullURL       bool          // 是否使用完整URL（不添加/chat/completions）
+	MaxTokens        int           // AI响应的最大token数
+	MaxResponseBytes int64         // AI响应体的最大字节数，超过时返回错误（0 使用 DefaultMaxResponseBytes）
+	Budget           CallBudget    // 单次调用（含重试）的时间预算
+
+	// OnUsage 每次AI调用成功后回调Token用量（可选，用于持久化成本统计）
+	OnUsage func(usage TokenUsage)
+
+	// RedactValues 记录AI请求/响应调试日志时额外脱敏的值（如用户ID、交易员ID），API Key 总是脱敏
+	RedactValues []string
+}
+
+// TokenUsage 单次AI调用的Token用量
+type TokenUsage struct {
+	Provider         string
+	Model            string
+	PromptTokens     int
+	CompletionTokens int
+	CostUSD          float64 // 估算成本（USD）
+}
+
+// DefaultMaxResponseBytes 默认AI响应体大小上限（8MB，远大于正常的决策响应）
+const DefaultMaxResponseBytes int64 = 8 << 20
+
+// ErrResponseTooLarge AI响应体超过大小上限（异常或恶意的API端点）
+var ErrResponseTooLarge = errors.New("AI响应超过大小上限")
+
+// ErrBudgetExhausted AI调用时间预算已耗尽（区别于AI服务商返回的错误）
+var ErrBudgetExhausted = errors.New("AI调用时间预算已耗尽")
+
+// CallBudget AI调用时间预算：一次调用的所有尝试、退避等待共享同一个截止时间，
+// 避免逐次超时叠加导致单个周期远超扫描间隔
+type CallBudget struct {
+	Total             time.Duration // 单次调用（含重试和退避）的总时间预算
+	FirstAttemptShare float64       // 首次尝试可使用的剩余预算比例，后续尝试使用扣除退避后的全部剩余预算
+	MinAttempt        time.Duration // 剩余预算（扣除退避后）低于该值时不再重试
+	Backoff           time.Duration // 退避基数：第 n 次重试前等待 n×Backoff
+	MaxAttempts       int           // 最多尝试次数
+}
+
+// DefaultCallBudget 默认AI调用时间预算（远小于默认3分钟扫描间隔）
+func DefaultCallBudget() CallBudget {
+	return CallBudget{
+		Total:             120 * time.Second,
+		FirstAttemptShare: 0.6,
+		MinAttempt:        10 * time.Second,
+		Backoff:           2 * time.Second,
+		MaxAttempts:       3,
+	}
+}
+
+// withDefaults 未设置的字段使用默认值
+func (b CallBudget) withDefaults() CallBudget {
+	defaults := DefaultCallBudget()
+	if b.Total <= 0 {
+		b.Total = defaults.Total
+	}
+	if b.FirstAttemptShare <= 0 || b.FirstAttemptShare > 1 {
+		b.FirstAttemptShare = defaults.FirstAttemptShare
+	}
+	if b.MinAttempt <= 0 {
+		b.MinAttempt = defaults.MinAttempt
+	}
+	if b.Backoff < 0 {
+		b.Backoff = defaults.Backoff
+	}
+	if b.MaxAttempts <= 0 {
+		b.MaxAttempts = defaults.MaxAttempts
+	}
+	return b
 }
 
 func New() *Client {
@@ -48,16 +118,53 @@ func New() *Client {
 		}
 	}
 
+	// 从环境变量读取响应体大小上限（字节），默认 DefaultMaxResponseBytes
+	maxResponseBytes := DefaultMaxResponseBytes
+	if envMaxBytes := os.Getenv("AI_MAX_RESPONSE_BYTES"); envMaxBytes != "" {
+		if parsed, err := strconv.ParseInt(envMaxBytes, 10, 64); err == nil && parsed > 0 {
+			maxResponseBytes = parsed
+			log.Printf("🔧 [MCP] 使用环境变量 AI_MAX_RESPONSE_BYTES: %d", maxResponseBytes)
+		} else {
+			log.Printf("⚠️  [MCP] 环境变量 AI_MAX_RESPONSE_BYTES 无效 (%s)，使用默认值: %d", envMaxBytes, maxResponseBytes)
+		}
+	}
+
 	// 默认配置
 	return &Client{
-		Provider:  ProviderDeepSeek,
-		BaseURL:   "https://api.deepseek.com/v1",
-		Model:     "deepseek-chat",
-		Timeout:   180 * time.Second, // 增加到180秒，因为AI需要分析大量数据
-		MaxTokens: maxTokens,
+		Provider:         ProviderDeepSeek,
+		BaseURL:          "https://api.deepseek.com/v1",
+		Model:            "deepseek-chat",
+		Timeout:          180 * time.Second, // 增加到180秒，因为AI需要分析大量数据
+		MaxTokens:        maxTokens,
+		MaxResponseBytes: maxResponseBytes,
+		Budget:           DefaultCallBudget(),
 	}
 }
 
+// NewForProvider 按用户配置的AI模型（提供商、密钥、自定义URL和模型名）创建客户端
+func NewForProvider(provider, apiKey, customURL, customModel string) (*Client, error) {
+	if apiKey == "" {
+		return nil, fmt.Errorf("AI模型 (%s) API密钥未设置", provider)
+	}
+	client := New()
+	switch provider {
+	case "custom":
+		client.SetCustomAPI(customURL, apiKey, customModel)
+	case "openrouter":
+		if customModel == "" {
+			customModel = "openai/gpt-4o"
+		}
+		client.SetOpenRouterAPIKey(apiKey, customModel)
+	case "qwen":
+		client.SetQwenAPIKey(apiKey, customURL, customModel)
+	case "deepseek":
+		client.SetDeepSeekAPIKey(apiKey, customURL, customModel)
+	default:
+		return nil, fmt.Errorf("不支持的AI模型提供商: %s", provider)
+	}
+	return client, nil
+}
+
 // SetDeepSeekAPIKey 设置DeepSeek API密钥
 // customURL 为空时使用默认URL，customModel 为空时使用默认模型
 func (client *Client) SetDeepSeekAPIKey(apiKey string, customURL string, customModel string) {
@@ -161,6 +268,14 @@ func (client *Client) SetClient(newClient Client) {
 
 // CallWithMessages 使用 system + user prompt 调用AI API（推荐）
 func (client *Client) CallWithMessages(systemPrompt, userPrompt string) (string, error) {
+	return client.CallWithMessagesContext(context.Background(), systemPrompt, userPrompt)
+}
+
+// CallWithMessagesContext 在时间预算内调用AI API
+// 截止时间取 Budget.Total 与 ctx 截止时间中较早者（ctx 由交易周期传入，已扣除本周期获取市场数据等耗时）；
+// 首次尝试使用 FirstAttemptShare 比例的剩余预算，之后的重试使用扣除退避后的全部剩余预算，
+// 剩余预算不足 MinAttempt 时不再重试，返回 ErrBudgetExhausted
+func (client *Client) CallWithMessagesContext(ctx context.Context, systemPrompt, userPrompt string) (string, error) {
 	if client.APIKey == "" {
 		return "", fmt.Errorf("AI API密钥未设置，请先调用 SetDeepSeekAPIKey()、SetQwenAPIKey()、SetOpenRouterAPIKey() 或 SetCustomAPI()")
 	}
@@ -168,17 +283,53 @@ func (client *Client) CallWithMessages(systemPrompt, userPrompt string) (string,
 	// 创建指标记录器
 	metricsRecorder := metrics.NewAIMetricsRecorder(string(client.Provider), client.Model)
 
-	// 重试配置
-	maxRetries := 3
+	budget := client.Budget.withDefaults()
+	deadline := time.Now().Add(budget.Total)
+	if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(deadline) {
+		deadline = ctxDeadline
+	}
+	ctx, cancel := context.WithDeadline(ctx, deadline)
+	defer cancel()
+
 	var lastErr error
+	attempts := 0
 
-	for attempt := 1; attempt <= maxRetries; attempt++ {
+	for attempt := 1; attempt <= budget.MaxAttempts; attempt++ {
+		remaining := time.Until(deadline)
 		if attempt > 1 {
-			fmt.Printf("⚠️  AI API调用失败，正在重试 (%d/%d)...\n", attempt, maxRetries)
+			waitTime := time.Duration(attempt-1) * budget.Backoff
+			if remaining-waitTime < budget.MinAttempt {
+				fmt.Printf("⏱️  AI调用剩余预算 %v 不足以重试，放弃重试\n", remaining.Round(time.Millisecond))
+				break
+			}
+			fmt.Printf("⚠️  AI API调用失败，%v 后重试 (%d/%d)，剩余预算 %v...\n", waitTime, attempt, budget.MaxAttempts, remaining.Round(time.Millisecond))
 			metricsRecorder.RecordRetry()
+
+			timer := time.NewTimer(waitTime)
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				timer.Stop()
+			}
+			remaining = time.Until(deadline)
+		}
+		if remaining <= 0 {
+			break
 		}
 
-		result, err := client.callOnce(systemPrompt, userPrompt)
+		// 分配本次尝试的超时
+		attemptTimeout := remaining
+		if attempt == 1 && budget.MaxAttempts > 1 {
+			attemptTimeout = time.Duration(float64(remaining) * budget.FirstAttemptShare)
+		}
+		if client.Timeout > 0 && attemptTimeout > client.Timeout {
+			attemptTimeout = client.Timeout
+		}
+
+		attemptCtx, cancelAttempt := context.WithTimeout(ctx, attemptTimeout)
+		result, err := client.callOnce(attemptCtx, systemPrompt, userPrompt)
+		cancelAttempt()
+		attempts = attempt
 		if err == nil {
 			if attempt > 1 {
 				fmt.Printf("✓ AI API重试成功\n")
@@ -191,40 +342,44 @@ func (client *Client) CallWithMessages(systemPrompt, userPrompt string) (string,
 		lastErr = err
 		// 如果不是网络错误，不重试
 		if !isRetryableError(err) {
-			metricsRecorder.RecordFailure("error")
+			metricsRecorder.RecordFailure(failureStatus(err))
 			return "", err
 		}
+	}
 
-		// 重试前等待
-		if attempt < maxRetries {
-			waitTime := time.Duration(attempt) * 2 * time.Second
-			fmt.Printf("⏳ 等待%v后重试...\n", waitTime)
-			time.Sleep(waitTime)
+	// 未用完尝试次数即退出：时间预算耗尽
+	if attempts < budget.MaxAttempts {
+		metricsRecorder.RecordFailure("budget_exhausted")
+		if lastErr == nil {
+			return "", fmt.Errorf("%w（调用前剩余 %v）", ErrBudgetExhausted, time.Until(deadline).Round(time.Millisecond))
 		}
+		return "", fmt.Errorf("%w（已尝试 %d 次）: %w", ErrBudgetExhausted, attempts, lastErr)
 	}
 
-	// 记录最终失败
-	if strings.Contains(strings.ToLower(lastErr.Error()), "timeout") {
-		metricsRecorder.RecordFailure("timeout")
-	} else {
-		metricsRecorder.RecordFailure("failed")
-	}
+	// 记录最终失败（按最后一次尝试的错误类型区分超时/状态码/解析等）
+	metricsRecorder.RecordFailure(failureStatus(lastErr))
 
-	return "", fmt.Errorf("重试%d次后仍然失败: %w", maxRetries, lastErr)
+	return "", fmt.Errorf("重试%d次后仍然失败: %w", budget.MaxAttempts, lastErr)
 }
 
-// callOnce 单次调用AI API（内部使用）
-func (client *Client) callOnce(systemPrompt, userPrompt string) (string, error) {
+// callOnce 单次调用AI API（内部使用，超时由 ctx 截止时间控制）
+func (client *Client) callOnce(ctx context.Context, systemPrompt, userPrompt string) (string, error) {
 	// 打印当前 AI 配置
 	log.Printf("📡 [MCP] AI 请求配置:")
 	log.Printf("   Provider: %s", client.Provider)
 	log.Printf("   BaseURL: %s", client.BaseURL)
 	log.Printf("   Model: %s", client.Model)
 	log.Printf("   UseFullURL: %v", client.UseFullURL)
+	requestID := httpclient.RequestIDFromContext(ctx)
+	if requestID != "" {
+		log.Printf("   RequestID: %s", requestID)
+	}
 	if len(client.APIKey) > 8 {
 		log.Printf("   API Key: %s...%s", client.APIKey[:4], client.APIKey[len(client.APIKey)-4:])
 	}
 
+	client.logPrompt(systemPrompt, userPrompt)
+
 	// 构建 messages 数组
 	messages := []map[string]string{}
 
@@ -292,28 +447,38 @@ func (client *Client) callOnce(systemPrompt, userPrompt string) (string, error)
 	default:
 		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", client.APIKey))
 	}
-
-	// 发送请求（使用带超时的HTTP客户端）
-	// 注意：http.Client.Timeout 包括连接、发送请求和读取响应的总时间
-	httpClient := &http.Client{
-		Timeout: client.Timeout,
+	// 交易周期的请求ID，便于在AI服务商侧关联同一周期的请求
+	if requestID != "" {
+		req.Header.Set(httpclient.RequestIDHeader(), requestID)
 	}
 
-	// 使用 context 包装请求，确保整个请求过程（包括读取响应）都有超时保护
-	ctx, cancel := context.WithTimeout(context.Background(), client.Timeout)
-	defer cancel()
+	// 发送请求
+	// 使用 context 控制整个请求过程（连接、发送请求和读取响应）的超时
+	timeout := client.Timeout
+	if deadline, ok := ctx.Deadline(); ok {
+		timeout = time.Until(deadline).Round(time.Millisecond)
+	}
 	req = req.WithContext(ctx)
 
-	resp, err := httpClient.Do(req)
+	resp, err := client.httpClient().Do(req)
 	if err != nil {
 		// 检查是否是超时错误
 		if ctx.Err() == context.DeadlineExceeded {
-			return "", fmt.Errorf("请求超时（%v）: %w", client.Timeout, err)
+			return "", newCallError(CallErrorTimeout, "请求超时（%v）: %w", timeout, err)
 		}
-		return "", fmt.Errorf("发送请求失败: %w", err)
+		return "", newCallError(CallErrorNetwork, "发送请求失败: %w", err)
 	}
 	defer resp.Body.Close()
 
+	// 响应体大小上限：声明的长度超限时直接拒绝，否则最多读取上限+1字节以判断是否超限
+	maxBytes := client.MaxResponseBytes
+	if maxBytes <= 0 {
+		maxBytes = DefaultMaxResponseBytes
+	}
+	if resp.ContentLength > maxBytes {
+		return "", newCallError(CallErrorTooLarge, "%w: Content-Length %d 字节 > %d 字节", ErrResponseTooLarge, resp.ContentLength, maxBytes)
+	}
+
 	// 读取响应（使用带超时的 context 控制）
 	// 由于 http.Client.Timeout 已经包含了读取时间，这里主要是为了更好的错误处理
 	type readResult struct {
@@ -323,7 +488,7 @@ func (client *Client) callOnce(systemPrompt, userPrompt string) (string, error)
 	resultChan := make(chan readResult, 1)
 
 	go func() {
-		data, err := io.ReadAll(resp.Body)
+		data, err := io.ReadAll(io.LimitReader(resp.Body, maxBytes+1))
 		resultChan <- readResult{data: data, err: err}
 	}()
 
@@ -333,16 +498,21 @@ func (client *Client) callOnce(systemPrompt, userPrompt string) (string, error)
 		body = result.data
 		err = result.err
 		if err != nil {
-			return "", fmt.Errorf("读取响应失败: %w", err)
+			return "", newCallError(CallErrorNetwork, "读取响应失败: %w", err)
 		}
 	case <-ctx.Done():
-		return "", fmt.Errorf("读取响应超时（%v）: %w", client.Timeout, ctx.Err())
+		return "", newCallError(CallErrorTimeout, "读取响应超时（%v）: %w", timeout, ctx.Err())
 	}
+	if int64(len(body)) > maxBytes {
+		return "", newCallError(CallErrorTooLarge, "%w: 已读取超过 %d 字节", ErrResponseTooLarge, maxBytes)
+	}
+
+	client.logResponse(body)
 
 	if resp.StatusCode != http.StatusOK {
-		// 记录失败指标
-		metrics.AIRequestsTotal.WithLabelValues(string(client.Provider), client.Model, "failed").Inc()
-		return "", fmt.Errorf("API返回错误 (status %d): %s", resp.StatusCode, string(body))
+		callErr := newCallError(CallErrorHTTPStatus, "API返回错误 (status %d): %s", resp.StatusCode, string(body))
+		callErr.StatusCode = resp.StatusCode
+		return "", callErr
 	}
 
 	// 解析响应（包含token使用量）
@@ -360,13 +530,11 @@ func (client *Client) callOnce(systemPrompt, userPrompt string) (string, error)
 	}
 
 	if err := json.Unmarshal(body, &result); err != nil {
-		metrics.AIRequestsTotal.WithLabelValues(string(client.Provider), client.Model, "parse_error").Inc()
-		return "", fmt.Errorf("解析响应失败: %w", err)
+		return "", newCallError(CallErrorParse, "解析响应失败: %w", err)
 	}
 
 	if len(result.Choices) == 0 {
-		metrics.AIRequestsTotal.WithLabelValues(string(client.Provider), client.Model, "empty_response").Inc()
-		return "", fmt.Errorf("API返回空响应")
+		return "", newCallError(CallErrorEmpty, "API返回空响应")
 	}
 
 	// 记录Token使用量指标
@@ -382,13 +550,32 @@ func (client *Client) callOnce(systemPrompt, userPrompt string) (string, error)
 		
 		log.Printf("📊 [MCP] Token使用: prompt=%d, completion=%d, total=%d, 估算成本=$%.6f",
 			result.Usage.PromptTokens, result.Usage.CompletionTokens, result.Usage.TotalTokens, cost)
+
+		if client.OnUsage != nil {
+			client.OnUsage(TokenUsage{
+				Provider:         string(client.Provider),
+				Model:            client.Model,
+				PromptTokens:     result.Usage.PromptTokens,
+				CompletionTokens: result.Usage.CompletionTokens,
+				CostUSD:          cost,
+			})
+		}
 	}
 
 	return result.Choices[0].Message.Content, nil
 }
 
+// httpClient 使用共享连接池的HTTP客户端（超时由调用 context 控制）
+func (client *Client) httpClient() *http.Client {
+	return &http.Client{Transport: httpclient.SharedTransport()}
+}
+
 // isRetryableError 判断错误是否可重试
 func isRetryableError(err error) bool {
+	var callErr *CallError
+	if errors.As(err, &callErr) && (callErr.Kind == CallErrorTimeout || callErr.Kind == CallErrorNetwork) {
+		return true
+	}
 	errStr := err.Error()
 	// 网络错误、超时、EOF等可以重试
 	retryableErrors := []string{
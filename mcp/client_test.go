@@ -0,0 +1,299 @@
+package mcp
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"aspen/httpclient"
+	"aspen/metrics"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// budgetEpsilon 调用耗时允许超出预算的误差
+const budgetEpsilon = 100 * time.Millisecond
+
+const okResponse = `{"choices":[{"message":{"content":"ok"}}]}`
+
+// newSlowServer 构造一个按请求序号返回不同延迟的假AI服务（delays 用完后沿用最后一个）
+func newSlowServer(t *testing.T, delays ...time.Duration) (*httptest.Server, *int32) {
+	t.Helper()
+	var hits int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.ReadAll(r.Body) // 读完请求体后服务端才能感知客户端断开
+		n := int(atomic.AddInt32(&hits, 1))
+		delay := delays[len(delays)-1]
+		if n <= len(delays) {
+			delay = delays[n-1]
+		}
+		select {
+		case <-time.After(delay):
+			w.Write([]byte(okResponse))
+		case <-r.Context().Done():
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &hits
+}
+
+// newBudgetTestClient 构造使用小时间预算的测试客户端
+func newBudgetTestClient(url string, total, minAttempt time.Duration) *Client {
+	client := New()
+	client.SetCustomAPI(url, "sk-test", "test-model")
+	client.Budget = CallBudget{
+		Total:             total,
+		FirstAttemptShare: 0.6,
+		MinAttempt:        minAttempt,
+		Backoff:           20 * time.Millisecond,
+		MaxAttempts:       3,
+	}
+	return client
+}
+
+// TestCallWithMessages_NeverExceedsBudget 测试慢服务下调用总耗时不超过预算
+func TestCallWithMessages_NeverExceedsBudget(t *testing.T) {
+	srv, hits := newSlowServer(t, 5*time.Second)
+	client := newBudgetTestClient(srv.URL, 500*time.Millisecond, 50*time.Millisecond)
+
+	start := time.Now()
+	_, err := client.CallWithMessages("system", "user")
+	elapsed := time.Since(start)
+
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, ErrBudgetExhausted), "应返回 ErrBudgetExhausted: %v", err)
+	assert.LessOrEqual(t, elapsed, 500*time.Millisecond+budgetEpsilon)
+	// 首次尝试使用 60% 预算，第二次使用剩余预算，第三次已无预算
+	assert.Equal(t, int32(2), atomic.LoadInt32(hits))
+}
+
+// TestCallWithMessagesContext_AttemptsAdaptToRemainingTime 测试周期已耗时后剩余预算不足以重试时只尝试一次
+func TestCallWithMessagesContext_AttemptsAdaptToRemainingTime(t *testing.T) {
+	srv, hits := newSlowServer(t, 5*time.Second)
+	client := newBudgetTestClient(srv.URL, 5*time.Second, 100*time.Millisecond)
+
+	// 模拟周期内获取市场数据已用掉大部分时间，只剩 200ms
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := client.CallWithMessagesContext(ctx, "system", "user")
+	elapsed := time.Since(start)
+
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, ErrBudgetExhausted), "应返回 ErrBudgetExhausted: %v", err)
+	assert.LessOrEqual(t, elapsed, 200*time.Millisecond+budgetEpsilon)
+	assert.Equal(t, int32(1), atomic.LoadInt32(hits), "剩余预算低于下限时不应重试")
+}
+
+// TestCallWithMessagesContext_ExpiredDeadlineSkipsCall 测试周期截止时间已过时不发起请求
+func TestCallWithMessagesContext_ExpiredDeadlineSkipsCall(t *testing.T) {
+	srv, hits := newSlowServer(t, 0)
+	client := newBudgetTestClient(srv.URL, time.Second, 50*time.Millisecond)
+
+	ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+
+	_, err := client.CallWithMessagesContext(ctx, "system", "user")
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, ErrBudgetExhausted))
+	assert.Equal(t, int32(0), atomic.LoadInt32(hits))
+}
+
+// TestCallWithMessages_RetrySucceedsWithinBudget 测试首次尝试超时后重试在剩余预算内成功
+func TestCallWithMessages_RetrySucceedsWithinBudget(t *testing.T) {
+	srv, hits := newSlowServer(t, 5*time.Second, 10*time.Millisecond)
+	client := newBudgetTestClient(srv.URL, 500*time.Millisecond, 50*time.Millisecond)
+
+	start := time.Now()
+	result, err := client.CallWithMessages("system", "user")
+	elapsed := time.Since(start)
+
+	require.NoError(t, err)
+	assert.Equal(t, "ok", result)
+	assert.Equal(t, int32(2), atomic.LoadInt32(hits))
+	assert.LessOrEqual(t, elapsed, 500*time.Millisecond+budgetEpsilon)
+}
+
+// TestCallWithMessages_ProviderErrorNotBudgetError 测试服务商错误不被记录为预算耗尽
+func TestCallWithMessages_ProviderErrorNotBudgetError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"error":"invalid api key"}`))
+	}))
+	defer srv.Close()
+	client := newBudgetTestClient(srv.URL, time.Second, 50*time.Millisecond)
+
+	_, err := client.CallWithMessages("system", "user")
+	require.Error(t, err)
+	assert.False(t, errors.Is(err, ErrBudgetExhausted))
+	assert.Contains(t, err.Error(), "status 401")
+}
+
+// aiRequestCount 读取 aspen_ai_requests_total 指定标签的当前计数
+func aiRequestCount(t *testing.T, model, status string) float64 {
+	t.Helper()
+	m := &dto.Metric{}
+	require.NoError(t, metrics.AIRequestsTotal.WithLabelValues(string(ProviderCustom), model, status).Write(m))
+	return m.GetCounter().GetValue()
+}
+
+// TestCallWithMessages_FailureMetricLabels 测试各类调用错误返回对应的错误类型并记录对应的指标标签
+func TestCallWithMessages_FailureMetricLabels(t *testing.T) {
+	tests := []struct {
+		name       string
+		handler    http.HandlerFunc
+		kind       CallErrorKind
+		statusCode int
+		attempts   int32
+	}{
+		{
+			name: "timeout",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				io.ReadAll(r.Body)
+				select {
+				case <-time.After(5 * time.Second):
+					w.Write([]byte(okResponse))
+				case <-r.Context().Done():
+				}
+			},
+			kind:     CallErrorTimeout,
+			attempts: 2,
+		},
+		{
+			name: "http_status",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(`{"error":"bad request"}`))
+			},
+			kind:       CallErrorHTTPStatus,
+			statusCode: http.StatusBadRequest,
+			attempts:   1,
+		},
+		{
+			name: "parse",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(`not json`))
+			},
+			kind:     CallErrorParse,
+			attempts: 1,
+		},
+		{
+			name: "empty",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(`{"choices":[]}`))
+			},
+			kind:     CallErrorEmpty,
+			attempts: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var hits int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				atomic.AddInt32(&hits, 1)
+				tt.handler(w, r)
+			}))
+			defer srv.Close()
+
+			// 每个子测试使用独立的模型名，避免与其他测试共享计数
+			model := "label-test-" + tt.name
+			client := newBudgetTestClient(srv.URL, 2*time.Second, 50*time.Millisecond)
+			client.Model = model
+			client.Timeout = 100 * time.Millisecond
+			client.Budget.MaxAttempts = 2
+
+			_, err := client.CallWithMessages("system", "user")
+			require.Error(t, err)
+
+			var callErr *CallError
+			require.True(t, errors.As(err, &callErr), "应返回 *CallError: %v", err)
+			assert.Equal(t, tt.kind, callErr.Kind)
+			assert.Equal(t, tt.statusCode, callErr.StatusCode)
+			assert.False(t, errors.Is(err, ErrBudgetExhausted))
+			assert.Equal(t, tt.attempts, atomic.LoadInt32(&hits))
+
+			assert.Equal(t, float64(1), aiRequestCount(t, model, string(tt.kind)))
+			assert.Equal(t, float64(0), aiRequestCount(t, model, "failed"))
+			assert.Equal(t, float64(0), aiRequestCount(t, model, "success"))
+		})
+	}
+}
+
+// TestCallWithMessages_ResponseSizeLimit 测试响应体超过大小上限时返回明确的错误且不重试（声明长度和分块传输两种情况）
+func TestCallWithMessages_ResponseSizeLimit(t *testing.T) {
+	oversized := `{"choices":[{"message":{"content":"` + strings.Repeat("x", 4096) + `"}}]}`
+
+	tests := []struct {
+		name    string
+		chunked bool
+	}{
+		{"content_length", false},
+		{"chunked", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var hits int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				atomic.AddInt32(&hits, 1)
+				if !tt.chunked {
+					w.Header().Set("Content-Length", strconv.Itoa(len(oversized)))
+					w.Write([]byte(oversized))
+					return
+				}
+				for i := 0; i < len(oversized); i += 512 {
+					w.Write([]byte(oversized[i:min(i+512, len(oversized))]))
+					w.(http.Flusher).Flush()
+				}
+			}))
+			defer srv.Close()
+
+			client := newBudgetTestClient(srv.URL, 2*time.Second, 50*time.Millisecond)
+			client.Model = "size-limit-test-" + tt.name
+			client.MaxResponseBytes = 1024
+
+			_, err := client.CallWithMessages("system", "user")
+			require.Error(t, err)
+			assert.True(t, errors.Is(err, ErrResponseTooLarge), "应返回响应超限错误: %v", err)
+			assert.Contains(t, err.Error(), "1024 字节")
+			var callErr *CallError
+			require.True(t, errors.As(err, &callErr))
+			assert.Equal(t, CallErrorTooLarge, callErr.Kind)
+			assert.Equal(t, int32(1), atomic.LoadInt32(&hits), "响应超限不应重试")
+		})
+	}
+
+	// 上限以内的响应正常解析
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(oversized))
+	}))
+	defer srv.Close()
+	client := newBudgetTestClient(srv.URL, 2*time.Second, 50*time.Millisecond)
+	client.MaxResponseBytes = int64(len(oversized))
+	content, err := client.CallWithMessages("system", "user")
+	require.NoError(t, err)
+	assert.Len(t, content, 4096)
+}
+
+// TestClient_UsesSharedPool 测试AI客户端使用配置的共享连接池
+func TestClient_UsesSharedPool(t *testing.T) {
+	httpclient.Configure(httpclient.PoolConfig{MaxIdleConns: 150, MaxIdleConnsPerHost: 25, IdleConnTimeout: time.Minute})
+	t.Cleanup(func() { httpclient.Configure(httpclient.DefaultPoolConfig()) })
+
+	transport, ok := New().httpClient().Transport.(*http.Transport)
+	require.True(t, ok)
+	assert.Same(t, httpclient.SharedTransport(), transport)
+	assert.Equal(t, 150, transport.MaxIdleConns)
+	assert.Equal(t, 25, transport.MaxIdleConnsPerHost)
+	assert.Equal(t, time.Minute, transport.IdleConnTimeout)
+}
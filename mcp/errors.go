@@ -0,0 +1,42 @@
+package mcp
+
+import (
+	"errors"
+	"fmt"
+)
+
+// CallErrorKind AI单次调用失败的类型（同时作为 aspen_ai_requests_total 的 status 标签）
+type CallErrorKind string
+
+const (
+	CallErrorTimeout    CallErrorKind = "timeout"     // 请求或读取响应超时
+	CallErrorNetwork    CallErrorKind = "network"     // 连接失败、读取响应中断等网络错误
+	CallErrorHTTPStatus CallErrorKind = "http_status" // 服务商返回非 200 状态码
+	CallErrorParse      CallErrorKind = "parse"       // 响应不是合法的JSON
+	CallErrorEmpty      CallErrorKind = "empty"       // 响应中没有 choices
+	CallErrorTooLarge   CallErrorKind = "too_large"   // 响应体超过大小上限
+)
+
+// CallError 带类型的AI调用错误
+type CallError struct {
+	Kind       CallErrorKind
+	StatusCode int // 仅 CallErrorHTTPStatus 有效
+	Err        error
+}
+
+func (e *CallError) Error() string { return e.Err.Error() }
+func (e *CallError) Unwrap() error { return e.Err }
+
+// newCallError 创建带类型的AI调用错误
+func newCallError(kind CallErrorKind, format string, args ...interface{}) *CallError {
+	return &CallError{Kind: kind, Err: fmt.Errorf(format, args...)}
+}
+
+// failureStatus 获取失败调用的指标 status 标签（未分类的错误记为 failed）
+func failureStatus(err error) string {
+	var callErr *CallError
+	if errors.As(err, &callErr) {
+		return string(callErr.Kind)
+	}
+	return "failed"
+}
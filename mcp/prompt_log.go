@@ -0,0 +1,70 @@
+package mcp
+
+import (
+	"aspen/logger"
+	"regexp"
+	"sort"
+	"strings"
+	"sync/atomic"
+)
+
+// PromptLogEnvName 开启AI请求/响应调试日志的环境变量（优先于 config.json 的 ai_log_prompts）
+const PromptLogEnvName = "AI_LOG_PROMPTS"
+
+// redactedPlaceholder 脱敏后的占位符
+const redactedPlaceholder = "[REDACTED]"
+
+// promptLogging 是否以 debug 级别记录完整的AI请求和响应（默认关闭）
+var promptLogging atomic.Bool
+
+// SetPromptLogging 设置是否以 debug 级别记录完整的AI请求和响应（记录前脱敏 API Key 和用户标识）
+func SetPromptLogging(enabled bool) {
+	promptLogging.Store(enabled)
+}
+
+// PromptLoggingEnabled 是否记录AI请求/响应调试日志
+func PromptLoggingEnabled() bool {
+	return promptLogging.Load()
+}
+
+// redactPatterns 无论是否已知都需要脱敏的内容：常见的 API Key 格式、Authorization 头和邮箱
+var redactPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`sk-[A-Za-z0-9_\-]{8,}`),
+	regexp.MustCompile(`(?i)bearer\s+[A-Za-z0-9._\-]+`),
+	regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`),
+}
+
+// redactForLog 脱敏日志内容：替换已知的敏感值（API Key、用户ID等）和 redactPatterns 匹配的内容
+func redactForLog(text string, secrets ...string) string {
+	// 先替换较长的值，避免较短的值是其子串时只替换一部分
+	sorted := append([]string(nil), secrets...)
+	sort.Slice(sorted, func(i, j int) bool { return len(sorted[i]) > len(sorted[j]) })
+	for _, secret := range sorted {
+		if secret = strings.TrimSpace(secret); secret != "" {
+			text = strings.ReplaceAll(text, secret, redactedPlaceholder)
+		}
+	}
+	for _, pattern := range redactPatterns {
+		text = pattern.ReplaceAllString(text, redactedPlaceholder)
+	}
+	return text
+}
+
+// logPrompt 记录发送给AI的提示词（未开启时不记录）
+func (client *Client) logPrompt(systemPrompt, userPrompt string) {
+	if !PromptLoggingEnabled() {
+		return
+	}
+	secrets := append([]string{client.APIKey}, client.RedactValues...)
+	logger.Debugf("📝 [MCP] AI 请求 (%s/%s)\n[system]\n%s\n[user]\n%s",
+		client.Provider, client.Model, redactForLog(systemPrompt, secrets...), redactForLog(userPrompt, secrets...))
+}
+
+// logResponse 记录AI返回的原始响应（未开启时不记录）
+func (client *Client) logResponse(body []byte) {
+	if !PromptLoggingEnabled() {
+		return
+	}
+	secrets := append([]string{client.APIKey}, client.RedactValues...)
+	logger.Debugf("📝 [MCP] AI 响应 (%s/%s)\n%s", client.Provider, client.Model, redactForLog(string(body), secrets...))
+}
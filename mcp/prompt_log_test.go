@@ -0,0 +1,80 @@
+package mcp
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"aspen/logger"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// capturePromptLog 开启AI请求/响应调试日志并捕获 debug 级别日志输出
+func capturePromptLog(t *testing.T, enabled bool) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	prevOut, prevLevel := logger.Log.Out, logger.Log.GetLevel()
+	logger.Log.SetOutput(&buf)
+	logger.Log.SetLevel(logrus.DebugLevel)
+	SetPromptLogging(enabled)
+	t.Cleanup(func() {
+		logger.Log.SetOutput(prevOut)
+		logger.Log.SetLevel(prevLevel)
+		SetPromptLogging(false)
+	})
+	return &buf
+}
+
+// TestPromptLogging_RedactsAPIKeyAndUserIdentifiers 测试开启后记录完整的请求和响应，并脱敏 API Key 和用户标识
+func TestPromptLogging_RedactsAPIKeyAndUserIdentifiers(t *testing.T) {
+	const apiKey = "sk-live-0123456789abcdef"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"choices":[{"message":{"content":"open BTCUSDT for user-42, key ` + apiKey + `"}}]}`))
+	}))
+	defer srv.Close()
+	buf := capturePromptLog(t, true)
+
+	client := newBudgetTestClient(srv.URL, time.Second, 50*time.Millisecond)
+	client.APIKey = apiKey
+	client.RedactValues = []string{"user-42", "trader-7"}
+
+	_, err := client.CallWithMessages("system prompt for trader-7",
+		"account of user-42 (alice@example.com), key "+apiKey+", Authorization: Bearer abc.def-123")
+	require.NoError(t, err)
+
+	output := buf.String()
+	assert.Contains(t, output, "system prompt for [REDACTED]")
+	assert.Contains(t, output, "account of [REDACTED] ([REDACTED]), key [REDACTED]")
+	assert.Contains(t, output, "open BTCUSDT for [REDACTED]")
+	assert.NotContains(t, output, apiKey)
+	assert.NotContains(t, output, "user-42")
+	assert.NotContains(t, output, "trader-7")
+	assert.NotContains(t, output, "alice@example.com")
+	assert.NotContains(t, output, "abc.def-123")
+}
+
+// TestPromptLogging_DisabledByDefault 测试未开启时不记录请求和响应
+func TestPromptLogging_DisabledByDefault(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(okResponse))
+	}))
+	defer srv.Close()
+	buf := capturePromptLog(t, false)
+
+	client := newBudgetTestClient(srv.URL, time.Second, 50*time.Millisecond)
+	_, err := client.CallWithMessages("secret system prompt", "secret user prompt")
+	require.NoError(t, err)
+	assert.False(t, PromptLoggingEnabled())
+	assert.NotContains(t, buf.String(), "secret")
+}
+
+// TestRedactForLog 测试较长的敏感值优先替换，空值忽略
+func TestRedactForLog(t *testing.T) {
+	assert.Equal(t, "[REDACTED] and [REDACTED]", redactForLog("trader-1-long and trader-1", "", "trader-1", "trader-1-long"))
+	assert.Equal(t, "no secrets here", redactForLog("no secrets here"))
+}
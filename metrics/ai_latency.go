@@ -0,0 +1,101 @@
+package metrics
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// aiRequestDurationName AI请求延迟直方图的指标名
+const aiRequestDurationName = "aspen_ai_request_duration_seconds"
+
+// AILatencySummary 单个 provider/model 的AI请求延迟统计（自进程启动以来，单位秒）
+type AILatencySummary struct {
+	Provider string  `json:"provider"`
+	Model    string  `json:"model"`
+	Count    uint64  `json:"count"`
+	Avg      float64 `json:"avg_seconds"`
+	P50      float64 `json:"p50_seconds"`
+	P95      float64 `json:"p95_seconds"`
+	P99      float64 `json:"p99_seconds"`
+}
+
+// AILatency 各 provider/model 的AI请求延迟分位数（按 provider、model 排序）
+func AILatency() ([]AILatencySummary, error) {
+	return aiLatencyPercentiles(gatherer)
+}
+
+// aiLatencyPercentiles 从AI请求延迟直方图计算延迟分位数。
+// 分位数在所在桶内线性插值（与 PromQL histogram_quantile 一致），落在最高桶之外时取最高桶的上界
+func aiLatencyPercentiles(g prometheus.Gatherer) ([]AILatencySummary, error) {
+	families, err := g.Gather()
+	if err != nil {
+		return nil, fmt.Errorf("读取指标失败: %w", err)
+	}
+
+	summaries := []AILatencySummary{}
+	for _, family := range families {
+		if family.GetName() != aiRequestDurationName {
+			continue
+		}
+		for _, m := range family.GetMetric() {
+			h := m.GetHistogram()
+			if h == nil || h.GetSampleCount() == 0 {
+				continue
+			}
+			summary := AILatencySummary{
+				Count: h.GetSampleCount(),
+				Avg:   h.GetSampleSum() / float64(h.GetSampleCount()),
+				P50:   histogramQuantile(0.50, h),
+				P95:   histogramQuantile(0.95, h),
+				P99:   histogramQuantile(0.99, h),
+			}
+			for _, label := range m.GetLabel() {
+				switch label.GetName() {
+				case "provider":
+					summary.Provider = label.GetValue()
+				case "model":
+					summary.Model = label.GetValue()
+				}
+			}
+			summaries = append(summaries, summary)
+		}
+	}
+
+	sort.Slice(summaries, func(i, j int) bool {
+		if summaries[i].Provider != summaries[j].Provider {
+			return summaries[i].Provider < summaries[j].Provider
+		}
+		return summaries[i].Model < summaries[j].Model
+	})
+	return summaries, nil
+}
+
+// histogramQuantile 按累计桶计数估算分位数 q（0~1）
+func histogramQuantile(q float64, h *dto.Histogram) float64 {
+	total := float64(h.GetSampleCount())
+	if total == 0 {
+		return math.NaN()
+	}
+	rank := q * total
+
+	lowerBound, lowerCount := 0.0, 0.0
+	for _, b := range h.GetBucket() {
+		upper, count := b.GetUpperBound(), float64(b.GetCumulativeCount())
+		if count >= rank {
+			if math.IsInf(upper, 1) {
+				return lowerBound
+			}
+			if count == lowerCount {
+				return upper
+			}
+			return lowerBound + (upper-lowerBound)*(rank-lowerCount)/(count-lowerCount)
+		}
+		lowerBound, lowerCount = upper, count
+	}
+	// 超出最高桶（+Inf 桶不在 Bucket 列表中）
+	return lowerBound
+}
@@ -0,0 +1,74 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestAILatencyPercentiles 测试按 provider/model 从延迟直方图计算分位数
+func TestAILatencyPercentiles(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	hist := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    aiRequestDurationName,
+		Buckets: []float64{1.0, 2.0, 5.0, 10.0, 20.0, 30.0, 60.0, 120.0, 180.0},
+	}, []string{"provider", "model"})
+	registry.MustRegister(hist)
+
+	// deepseek：100 次请求，90 次 3 秒（2~5 桶），10 次 15 秒（10~20 桶）
+	for i := 0; i < 90; i++ {
+		hist.WithLabelValues("deepseek", "deepseek-chat").Observe(3)
+	}
+	for i := 0; i < 10; i++ {
+		hist.WithLabelValues("deepseek", "deepseek-chat").Observe(15)
+	}
+	// qwen：全部 0.5 秒；另有一次超出最高桶的 300 秒
+	for i := 0; i < 99; i++ {
+		hist.WithLabelValues("qwen", "qwen-max").Observe(0.5)
+	}
+	hist.WithLabelValues("qwen", "qwen-max").Observe(300)
+
+	summaries, err := aiLatencyPercentiles(registry)
+	require.NoError(t, err)
+	require.Len(t, summaries, 2)
+
+	ds := summaries[0]
+	assert.Equal(t, "deepseek", ds.Provider)
+	assert.Equal(t, "deepseek-chat", ds.Model)
+	assert.Equal(t, uint64(100), ds.Count)
+	assert.InDelta(t, 4.2, ds.Avg, 1e-9)
+	// p50：第 50 个样本在 2~5 桶（90 个）内插值 = 2 + 3×50/90
+	assert.InDelta(t, 2+3*50.0/90, ds.P50, 1e-9)
+	// p95：第 95 个样本在 10~20 桶（10 个）内插值 = 10 + 10×5/10
+	assert.InDelta(t, 15, ds.P95, 1e-9)
+	assert.True(t, ds.P50 <= ds.P95 && ds.P95 <= ds.P99)
+
+	// qwen：p50 在 0~1 桶内插值，p99 恰好为第 99 个样本 = 桶上界 1
+	qwen := summaries[1]
+	assert.Equal(t, "qwen", qwen.Provider)
+	assert.InDelta(t, 50.0/99, qwen.P50, 1e-9)
+	assert.InDelta(t, 1.0, qwen.P99, 1e-9)
+
+	// 超出最高桶的样本（+Inf 桶）取最高桶上界
+	families, err := registry.Gather()
+	require.NoError(t, err)
+	checked := false
+	for _, m := range families[0].GetMetric() {
+		for _, label := range m.GetLabel() {
+			if label.GetName() == "provider" && label.GetValue() == "qwen" {
+				assert.Equal(t, 180.0, histogramQuantile(1.0, m.GetHistogram()))
+				checked = true
+			}
+		}
+	}
+	assert.True(t, checked)
+}
+
+// TestAILatencyPercentiles_NoSamples 测试没有AI请求时返回空列表
+func TestAILatencyPercentiles_NoSamples(t *testing.T) {
+	summaries, err := aiLatencyPercentiles(prometheus.NewRegistry())
+	require.NoError(t, err)
+	assert.Empty(t, summaries)
+}
@@ -5,8 +5,6 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/prometheus/client_golang/prometheus"
-	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 // Version 应用版本（可在编译时注入）
@@ -21,16 +19,7 @@ func Init() {
 	AppStartTime.Set(float64(time.Now().Unix()))
 }
 
-// Handler 返回Prometheus metrics处理器
+// Handler 返回Prometheus metrics处理器（按 Configure 的配置校验来源和抓取凭证）
 func Handler() gin.HandlerFunc {
-	h := promhttp.HandlerFor(
-		prometheus.DefaultGatherer,
-		promhttp.HandlerOpts{
-			EnableOpenMetrics: true,
-		},
-	)
-	
-	return func(c *gin.Context) {
-		h.ServeHTTP(c.Writer, c.Request)
-	}
+	return scrapeHandler(newPromHandler())
 }
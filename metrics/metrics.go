@@ -178,7 +178,7 @@ var (
 			Name: "aspen_ai_requests_total",
 			Help: "Total number of AI API requests",
 		},
-		[]string{"provider", "model", "status"}, // status: "success", "failed", "timeout"
+		[]string{"provider", "model", "status"}, // status: "success", "timeout", "network", "http_status", "parse", "empty", "budget_exhausted", "failed"
 	)
 
 	// AIRequestDuration AI请求延迟
@@ -218,6 +218,15 @@ var (
 		[]string{"provider", "model"},
 	)
 
+	// AIPromptCacheTotal 提示词缓存结果（命中时复用上次决策，不调用AI）
+	AIPromptCacheTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "aspen_ai_prompt_cache_total",
+			Help: "Total number of AI prompt cache lookups",
+		},
+		[]string{"provider", "model", "result"}, // result: "hit", "miss"
+	)
+
 	// AIDecisionParseTotal 决策解析结果
 	AIDecisionParseTotal = promauto.NewCounterVec(
 		prometheus.CounterOpts{
@@ -251,6 +260,26 @@ var (
 		[]string{"trader_id", "exchange", "action", "status"}, // action: "open_long", "close_short", etc.
 	)
 
+	// TradingSlippageBps 成交滑点（相对决策参考价格，正数表示成交价差于参考价格）
+	TradingSlippageBps = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "aspen_trading_slippage_bps",
+			Help:    "Signed slippage between decision reference price and fill price in basis points (positive = adverse)",
+			Buckets: []float64{-50, -20, -10, -5, -2, 0, 2, 5, 10, 20, 50, 100},
+		},
+		[]string{"trader_id", "exchange", "action"},
+	)
+
+	// TradingFillLatency 决策参考价格取得到成交的耗时
+	TradingFillLatency = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "aspen_trading_fill_latency_seconds",
+			Help:    "Elapsed time between decision price snapshot and order fill in seconds",
+			Buckets: []float64{0.5, 1, 2, 5, 10, 20, 30, 60, 120, 300},
+		},
+		[]string{"trader_id", "exchange", "action"},
+	)
+
 	// TradingPnL 盈亏
 	TradingPnL = promauto.NewGaugeVec(
 		prometheus.GaugeOpts{
@@ -305,6 +334,15 @@ var (
 		[]string{"trader_id", "reason"}, // reason: "max_daily_loss", "max_drawdown", "stop_loss"
 	)
 
+	// TraderRestartsTotal 交易员异常退出后的自动重启次数
+	TraderRestartsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "aspen_trader_restarts_total",
+			Help: "Total number of automatic trader restarts after a crash",
+		},
+		[]string{"trader_id", "result"}, // result: "restarted", "gave_up"
+	)
+
 	// ActiveTraders 活跃交易员数量
 	ActiveTraders = promauto.NewGauge(
 		prometheus.GaugeOpts{
@@ -380,6 +418,51 @@ var (
 			Help: "Number of subscribed trading symbols",
 		},
 	)
+
+	// KlineGapsTotal 实时K线流中检测到的缺口数（按补齐结果）
+	KlineGapsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "aspen_kline_gaps_total",
+			Help: "Total number of gaps detected in streamed klines",
+		},
+		[]string{"interval", "result"}, // result: "backfilled", "partial", "failed", "skipped"
+	)
+
+	// KlineGapCandlesTotal 缺口中缺失的K线根数
+	KlineGapCandlesTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "aspen_kline_gap_candles_total",
+			Help: "Total number of candles missing from streamed klines",
+		},
+		[]string{"interval"},
+	)
+
+	// SubscriptionReconcileTotal 行情订阅对账动作次数（按币种计）
+	SubscriptionReconcileTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "aspen_subscription_reconcile_total",
+			Help: "Total number of symbols subscribed or unsubscribed by the subscription reconciler",
+		},
+		[]string{"action"}, // action: "subscribed", "unsubscribed", "subscribe_failed"
+	)
+
+	// MarketSourceErrorsTotal 行情数据源请求失败次数（按错误类别）
+	MarketSourceErrorsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "aspen_market_source_errors_total",
+			Help: "Total number of market data source request failures",
+		},
+		[]string{"source", "kind", "class"}, // kind: "open_interest", "funding_rate"; class: "http", "status", "parse", "api"
+	)
+
+	// MarketSourceErrorRatio 行情数据源滚动窗口内的失败率（错误预算消耗）
+	MarketSourceErrorRatio = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "aspen_market_source_error_ratio",
+			Help: "Rolling failure ratio of market data source requests",
+		},
+		[]string{"source", "kind"},
+	)
 )
 
 // ============================================================================
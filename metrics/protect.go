@@ -0,0 +1,201 @@
+package metrics
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// trader_id 标签粒度
+const (
+	LabelModeFull     = "full"     // 完整 trader_id（私有部署）
+	LabelModeHashed   = "hashed"   // trader_id 的哈希（共享部署，仍可区分单个交易员但无法对应到用户）
+	LabelModeBucketed = "bucketed" // 按哈希分桶（共享部署，只能看到分桶聚合）
+)
+
+// traderLabelBuckets bucketed 模式的分桶数
+const traderLabelBuckets = 16
+
+// ScrapeConfig /metrics 端点保护和标签粒度配置
+// 抓取凭证与用户 JWT 相互独立，仅供 Prometheus 抓取器使用；同时配置 Bearer Token 和 Basic Auth 时满足其一即可
+type ScrapeConfig struct {
+	BearerToken   string
+	BasicUser     string
+	BasicPassword string
+	AllowedCIDRs  []*net.IPNet // 允许抓取的来源网段（按 TCP 连接地址判断，不信任 X-Forwarded-For），为空表示不限制
+	LabelMode     string       // full / hashed / bucketed
+	LabelSalt     string       // hashed/bucketed 模式的哈希盐，避免通过已知 trader_id 反查
+}
+
+// AuthEnabled 是否要求抓取凭证
+func (c ScrapeConfig) AuthEnabled() bool {
+	return c.BearerToken != "" || c.BasicUser != ""
+}
+
+var (
+	scrapeMu     sync.RWMutex
+	scrapeConfig = ScrapeConfig{LabelMode: LabelModeFull}
+
+	// gatherer 指标来源（测试可替换）
+	gatherer prometheus.Gatherer = prometheus.DefaultGatherer
+)
+
+// LoadScrapeConfig 读取 /metrics 保护配置，lookup 按配置键返回取值（调用方决定环境变量和系统配置的优先级）
+//
+//	metrics_bearer_token   Bearer Token
+//	metrics_basic_auth     Basic Auth 凭证，格式 user:password
+//	metrics_allowed_cidrs  允许的来源网段，逗号分隔（如 10.0.0.0/8,127.0.0.1/32）
+//	metrics_label_mode     trader_id 标签粒度：full（默认）/ hashed / bucketed
+//	metrics_label_salt     标签哈希盐
+func LoadScrapeConfig(lookup func(key string) string) (ScrapeConfig, error) {
+	cfg := ScrapeConfig{
+		BearerToken: strings.TrimSpace(lookup("metrics_bearer_token")),
+		LabelSalt:   lookup("metrics_label_salt"),
+	}
+
+	if basic := strings.TrimSpace(lookup("metrics_basic_auth")); basic != "" {
+		user, password, ok := strings.Cut(basic, ":")
+		if !ok || user == "" || password == "" {
+			return cfg, fmt.Errorf("metrics_basic_auth 格式应为 user:password")
+		}
+		cfg.BasicUser, cfg.BasicPassword = user, password
+	}
+
+	for _, item := range strings.Split(lookup("metrics_allowed_cidrs"), ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		if !strings.Contains(item, "/") {
+			// 单个IP按主机网段处理
+			if ip := net.ParseIP(item); ip != nil && ip.To4() != nil {
+				item += "/32"
+			} else {
+				item += "/128"
+			}
+		}
+		_, ipNet, err := net.ParseCIDR(item)
+		if err != nil {
+			return cfg, fmt.Errorf("metrics_allowed_cidrs 中的网段 %q 无效: %w", item, err)
+		}
+		cfg.AllowedCIDRs = append(cfg.AllowedCIDRs, ipNet)
+	}
+
+	switch mode := strings.ToLower(strings.TrimSpace(lookup("metrics_label_mode"))); mode {
+	case "":
+		cfg.LabelMode = LabelModeFull
+	case LabelModeFull, LabelModeHashed, LabelModeBucketed:
+		cfg.LabelMode = mode
+	default:
+		return cfg, fmt.Errorf("metrics_label_mode 无效: %s（可选 full、hashed、bucketed）", mode)
+	}
+
+	return cfg, nil
+}
+
+// Configure 设置 /metrics 保护和标签粒度（应在创建交易员之前调用，保证所有序列使用同一粒度）
+func Configure(cfg ScrapeConfig) {
+	if cfg.LabelMode == "" {
+		cfg.LabelMode = LabelModeFull
+	}
+	scrapeMu.Lock()
+	defer scrapeMu.Unlock()
+	scrapeConfig = cfg
+}
+
+// currentScrapeConfig 获取当前配置
+func currentScrapeConfig() ScrapeConfig {
+	scrapeMu.RLock()
+	defer scrapeMu.RUnlock()
+	return scrapeConfig
+}
+
+// TraderLabel 按配置的粒度生成 trader_id 标签值（所有记录器统一经过这里）
+func TraderLabel(traderID string) string {
+	cfg := currentScrapeConfig()
+	if cfg.LabelMode == LabelModeFull {
+		return traderID
+	}
+	sum := sha256.Sum256([]byte(cfg.LabelSalt + traderID))
+	if cfg.LabelMode == LabelModeBucketed {
+		return fmt.Sprintf("bucket_%02d", binary.BigEndian.Uint32(sum[:4])%traderLabelBuckets)
+	}
+	return "t_" + hex.EncodeToString(sum[:6])
+}
+
+// authorizeScrape 检查抓取请求的来源和凭证，返回拒绝时的状态码（0 表示允许）
+func authorizeScrape(c *gin.Context, cfg ScrapeConfig) int {
+	if len(cfg.AllowedCIDRs) > 0 {
+		ip := net.ParseIP(c.RemoteIP())
+		allowed := false
+		for _, ipNet := range cfg.AllowedCIDRs {
+			if ip != nil && ipNet.Contains(ip) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return http.StatusForbidden
+		}
+	}
+
+	if !cfg.AuthEnabled() {
+		return 0
+	}
+	header := c.GetHeader("Authorization")
+	if cfg.BearerToken != "" {
+		if token, ok := strings.CutPrefix(header, "Bearer "); ok &&
+			subtle.ConstantTimeCompare([]byte(token), []byte(cfg.BearerToken)) == 1 {
+			return 0
+		}
+	}
+	if cfg.BasicUser != "" {
+		if user, password, ok := c.Request.BasicAuth(); ok &&
+			subtle.ConstantTimeCompare([]byte(user), []byte(cfg.BasicUser)) == 1 &&
+			subtle.ConstantTimeCompare([]byte(password), []byte(cfg.BasicPassword)) == 1 {
+			return 0
+		}
+	}
+	return http.StatusUnauthorized
+}
+
+// scrapeHandler 带保护的指标处理器：来源或凭证不通过时直接返回，不读取指标注册表
+func scrapeHandler(h http.Handler) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		cfg := currentScrapeConfig()
+		switch authorizeScrape(c, cfg) {
+		case http.StatusForbidden:
+			c.AbortWithStatus(http.StatusForbidden)
+			return
+		case http.StatusUnauthorized:
+			if cfg.BasicUser != "" {
+				c.Header("WWW-Authenticate", `Basic realm="metrics"`)
+			} else {
+				c.Header("WWW-Authenticate", `Bearer realm="metrics"`)
+			}
+			c.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+		h.ServeHTTP(c.Writer, c.Request)
+	}
+}
+
+// newPromHandler 创建 Prometheus 处理器
+func newPromHandler() http.Handler {
+	return promhttp.HandlerFor(
+		gatherer,
+		promhttp.HandlerOpts{
+			EnableOpenMetrics: true,
+		},
+	)
+}
@@ -0,0 +1,253 @@
+package metrics
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// withScrapeConfig 使用指定配置和计数的指标来源，测试结束后恢复
+func withScrapeConfig(t *testing.T, cfg ScrapeConfig) *int32 {
+	t.Helper()
+	var gathers int32
+	origGatherer, origConfig := gatherer, currentScrapeConfig()
+	gatherer = prometheus.GathererFunc(func() ([]*dto.MetricFamily, error) {
+		atomic.AddInt32(&gathers, 1)
+		return prometheus.DefaultGatherer.Gather()
+	})
+	Configure(cfg)
+	t.Cleanup(func() {
+		gatherer = origGatherer
+		Configure(origConfig)
+	})
+	return &gathers
+}
+
+// newMetricsRouter 挂载 /metrics 和一个普通路由（与 API 服务器一致使用请求指标中间件）
+func newMetricsRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(GinMiddleware())
+	router.GET("/metrics", Handler())
+	return router
+}
+
+// scrape 发起一次抓取请求
+func scrape(router *gin.Engine, remoteAddr string, setup func(r *http.Request)) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	req.RemoteAddr = remoteAddr
+	if setup != nil {
+		setup(req)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestMetricsHandler_BearerToken(t *testing.T) {
+	gathers := withScrapeConfig(t, ScrapeConfig{BearerToken: "scrape-secret"})
+	router := newMetricsRouter()
+
+	// 未携带或错误的凭证返回 401，且不读取指标注册表
+	w := scrape(router, "192.0.2.1:1234", nil)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Equal(t, `Bearer realm="metrics"`, w.Header().Get("WWW-Authenticate"))
+	w = scrape(router, "192.0.2.1:1234", func(r *http.Request) { r.Header.Set("Authorization", "Bearer wrong") })
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Empty(t, w.Body.String())
+	assert.Zero(t, atomic.LoadInt32(gathers))
+
+	w = scrape(router, "192.0.2.1:1234", func(r *http.Request) { r.Header.Set("Authorization", "Bearer scrape-secret") })
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "go_goroutines")
+	assert.Equal(t, int32(1), atomic.LoadInt32(gathers))
+}
+
+func TestMetricsHandler_BasicAuth(t *testing.T) {
+	gathers := withScrapeConfig(t, ScrapeConfig{BasicUser: "prometheus", BasicPassword: "pa:ss"})
+	router := newMetricsRouter()
+
+	w := scrape(router, "192.0.2.1:1234", func(r *http.Request) { r.SetBasicAuth("prometheus", "wrong") })
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Equal(t, `Basic realm="metrics"`, w.Header().Get("WWW-Authenticate"))
+	assert.Zero(t, atomic.LoadInt32(gathers))
+
+	w = scrape(router, "192.0.2.1:1234", func(r *http.Request) { r.SetBasicAuth("prometheus", "pa:ss") })
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestMetricsHandler_CIDRAllowlist(t *testing.T) {
+	cfg, err := LoadScrapeConfig(func(key string) string {
+		return map[string]string{"metrics_allowed_cidrs": "10.0.0.0/8, 127.0.0.1"}[key]
+	})
+	require.NoError(t, err)
+	gathers := withScrapeConfig(t, cfg)
+	router := newMetricsRouter()
+
+	// 不在允许网段内返回 403，伪造 X-Forwarded-For 无效
+	w := scrape(router, "203.0.113.5:1234", func(r *http.Request) { r.Header.Set("X-Forwarded-For", "10.0.0.1") })
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Zero(t, atomic.LoadInt32(gathers))
+
+	assert.Equal(t, http.StatusOK, scrape(router, "10.1.2.3:1234", nil).Code)
+	assert.Equal(t, http.StatusOK, scrape(router, "127.0.0.1:1234", nil).Code)
+
+	// 同时配置凭证时两者都要满足
+	cfg.BearerToken = "scrape-secret"
+	Configure(cfg)
+	assert.Equal(t, http.StatusUnauthorized, scrape(router, "10.1.2.3:1234", nil).Code)
+	assert.Equal(t, http.StatusForbidden, scrape(router, "203.0.113.5:1234", func(r *http.Request) {
+		r.Header.Set("Authorization", "Bearer scrape-secret")
+	}).Code)
+}
+
+func TestMetricsHandler_ExcludedFromRequestMetrics(t *testing.T) {
+	withScrapeConfig(t, ScrapeConfig{BearerToken: "scrape-secret"})
+	router := newMetricsRouter()
+	router.GET("/ping", func(c *gin.Context) { c.Status(http.StatusOK) })
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/ping", nil))
+	scrape(router, "192.0.2.1:1234", nil)
+	scrape(router, "192.0.2.1:1234", func(r *http.Request) { r.Header.Set("Authorization", "Bearer scrape-secret") })
+
+	families, err := prometheus.DefaultGatherer.Gather()
+	require.NoError(t, err)
+	paths := map[string]bool{}
+	for _, family := range families {
+		if family.GetName() != "aspen_http_requests_total" {
+			continue
+		}
+		for _, m := range family.GetMetric() {
+			for _, label := range m.GetLabel() {
+				if label.GetName() == "path" {
+					paths[label.GetValue()] = true
+				}
+			}
+		}
+	}
+	assert.True(t, paths["/ping"])
+	assert.False(t, paths["/metrics"], "/metrics 不计入请求指标")
+}
+
+func TestTraderLabel_Modes(t *testing.T) {
+	withScrapeConfig(t, ScrapeConfig{})
+	assert.Equal(t, "user1_binance_deepseek", TraderLabel("user1_binance_deepseek"))
+
+	Configure(ScrapeConfig{LabelMode: LabelModeHashed, LabelSalt: "salt"})
+	hashed := TraderLabel("user1_binance_deepseek")
+	assert.Regexp(t, `^t_[0-9a-f]{12}$`, hashed)
+	assert.Equal(t, hashed, TraderLabel("user1_binance_deepseek"), "同一交易员标签稳定")
+	assert.NotEqual(t, hashed, TraderLabel("user2_binance_deepseek"))
+	Configure(ScrapeConfig{LabelMode: LabelModeHashed, LabelSalt: "other"})
+	assert.NotEqual(t, hashed, TraderLabel("user1_binance_deepseek"), "盐不同哈希不同")
+
+	Configure(ScrapeConfig{LabelMode: LabelModeBucketed})
+	assert.Regexp(t, `^bucket_(0[0-9]|1[0-5])$`, TraderLabel("user1_binance_deepseek"))
+}
+
+func TestTradingMetricsRecorder_UsesHashedLabels(t *testing.T) {
+	withScrapeConfig(t, ScrapeConfig{LabelMode: LabelModeHashed, LabelSalt: "salt"})
+	recorder := NewTradingMetricsRecorder("private_trader_42", "binance")
+	recorder.RecordEquity(12345)
+	recorder.RecordPnL(10, -5, 5)
+
+	var m dto.Metric
+	require.NoError(t, TradingEquity.WithLabelValues(TraderLabel("private_trader_42")).Write(&m))
+	assert.Equal(t, 12345.0, m.GetGauge().GetValue())
+
+	// 注册表中不出现原始 trader_id
+	router := newMetricsRouter()
+	w := scrape(router, "192.0.2.1:1234", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.False(t, strings.Contains(w.Body.String(), "private_trader_42"))
+	assert.Contains(t, w.Body.String(), TraderLabel("private_trader_42"))
+}
+
+// TestTradingMetricsRecorder_BucketedAggregates 测试分桶模式下同一分桶的两个交易员的 Gauge 不互相覆盖：金额和持仓数求和，百分比取最大值
+func TestTradingMetricsRecorder_BucketedAggregates(t *testing.T) {
+	withScrapeConfig(t, ScrapeConfig{LabelMode: LabelModeBucketed, LabelSalt: "bucket-test"})
+	first := "bucket_trader_0"
+	second := ""
+	for i := 1; second == ""; i++ {
+		if id := fmt.Sprintf("bucket_trader_%d", i); TraderLabel(id) == TraderLabel(first) {
+			second = id
+		}
+	}
+	bucket := TraderLabel(first)
+	gauge := func(vec *prometheus.GaugeVec, labels ...string) float64 {
+		var m dto.Metric
+		require.NoError(t, vec.WithLabelValues(append([]string{bucket}, labels...)...).Write(&m))
+		return m.GetGauge().GetValue()
+	}
+
+	a := NewTradingMetricsRecorder(first, "binance")
+	b := NewTradingMetricsRecorder(second, "paper")
+	a.RecordEquity(1000)
+	b.RecordEquity(250)
+	a.RecordPnL(10, -5, 5)
+	b.RecordPnL(20, 5, 25)
+	a.RecordPositions(2)
+	b.RecordPositions(3)
+	a.RecordDrawdown(12)
+	b.RecordDrawdown(4)
+	a.RecordMarginUsed(30)
+	b.RecordMarginUsed(55)
+
+	assert.Equal(t, 1250.0, gauge(TradingEquity))
+	assert.Equal(t, 30.0, gauge(TradingPnL, "realized"))
+	assert.Equal(t, 0.0, gauge(TradingPnL, "unrealized"))
+	assert.Equal(t, 30.0, gauge(TradingPnL, "total"))
+	assert.Equal(t, 5.0, gauge(TradingPositions))
+	assert.Equal(t, 12.0, gauge(TradingDrawdown), "回撤取分桶内最大值")
+	assert.Equal(t, 55.0, gauge(TradingMarginUsed))
+
+	// 交易员更新数值时替换自己的值，不重复累加
+	a.RecordEquity(900)
+	a.RecordDrawdown(2)
+	assert.Equal(t, 1150.0, gauge(TradingEquity))
+	assert.Equal(t, 4.0, gauge(TradingDrawdown))
+}
+
+func TestLoadScrapeConfig(t *testing.T) {
+	values := map[string]string{
+		"metrics_bearer_token":  " token ",
+		"metrics_basic_auth":    "prom:secret",
+		"metrics_allowed_cidrs": "10.0.0.0/8,::1",
+		"metrics_label_mode":    "Hashed",
+	}
+	cfg, err := LoadScrapeConfig(func(key string) string { return values[key] })
+	require.NoError(t, err)
+	assert.Equal(t, "token", cfg.BearerToken)
+	assert.Equal(t, "prom", cfg.BasicUser)
+	assert.Equal(t, "secret", cfg.BasicPassword)
+	require.Len(t, cfg.AllowedCIDRs, 2)
+	assert.Equal(t, "::1/128", cfg.AllowedCIDRs[1].String())
+	assert.Equal(t, LabelModeHashed, cfg.LabelMode)
+
+	cfg, err = LoadScrapeConfig(func(string) string { return "" })
+	require.NoError(t, err)
+	assert.False(t, cfg.AuthEnabled())
+	assert.Equal(t, LabelModeFull, cfg.LabelMode)
+
+	for key, value := range map[string]string{
+		"metrics_basic_auth":    "no-password",
+		"metrics_allowed_cidrs": "10.0.0.0/33",
+		"metrics_label_mode":    "anonymous",
+	} {
+		_, err := LoadScrapeConfig(func(k string) string {
+			if k == key {
+				return value
+			}
+			return ""
+		})
+		assert.Error(t, err, key)
+	}
+}
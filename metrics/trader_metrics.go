@@ -0,0 +1,102 @@
+package metrics
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// ErrTraderMetricsBucketed bucketed 模式下多个交易员共用同一标签值，无法单独取出某个交易员的指标
+var ErrTraderMetricsBucketed = errors.New("指标标签为分桶模式（metrics_label_mode=bucketed），无法按交易员查看")
+
+// traderMetricPrefixes 按交易员记录的指标（交易周期、订单、滑点、盈亏、净值、持仓、风控、重启）
+var traderMetricPrefixes = []string{"aspen_trading_", "aspen_trader_"}
+
+// MetricSample 单条指标序列（不含 trader_id 标签）；计数器和仪表盘给出 value，直方图给出 count 和 sum
+type MetricSample struct {
+	Labels map[string]string `json:"labels,omitempty"`
+	Value  *float64          `json:"value,omitempty"`
+	Count  uint64            `json:"count,omitempty"`
+	Sum    float64           `json:"sum,omitempty"`
+}
+
+// MetricFamily 一个指标及其序列
+type MetricFamily struct {
+	Name    string         `json:"name"`
+	Help    string         `json:"help"`
+	Type    string         `json:"type"` // counter / gauge / histogram
+	Samples []MetricSample `json:"samples"`
+}
+
+// TraderMetrics 单个交易员的交易指标（按指标名排序，没有记录过的指标不返回）
+func TraderMetrics(traderID string) ([]MetricFamily, error) {
+	if currentScrapeConfig().LabelMode == LabelModeBucketed {
+		return nil, ErrTraderMetricsBucketed
+	}
+	return traderMetrics(gatherer, TraderLabel(traderID))
+}
+
+// traderMetrics 从指标注册表中取出 trader_id 标签等于 label 的交易指标序列
+func traderMetrics(g prometheus.Gatherer, label string) ([]MetricFamily, error) {
+	families, err := g.Gather()
+	if err != nil {
+		return nil, fmt.Errorf("读取指标失败: %w", err)
+	}
+
+	result := []MetricFamily{}
+	for _, family := range families {
+		if !isTraderMetric(family.GetName()) {
+			continue
+		}
+		out := MetricFamily{
+			Name: family.GetName(),
+			Help: family.GetHelp(),
+			Type: strings.ToLower(family.GetType().String()),
+		}
+		for _, m := range family.GetMetric() {
+			sample := MetricSample{Labels: map[string]string{}}
+			matched := false
+			for _, l := range m.GetLabel() {
+				if l.GetName() == "trader_id" {
+					matched = l.GetValue() == label
+					continue
+				}
+				sample.Labels[l.GetName()] = l.GetValue()
+			}
+			if !matched {
+				continue
+			}
+			switch {
+			case m.GetCounter() != nil:
+				value := m.GetCounter().GetValue()
+				sample.Value = &value
+			case m.GetGauge() != nil:
+				value := m.GetGauge().GetValue()
+				sample.Value = &value
+			case m.GetHistogram() != nil:
+				sample.Count = m.GetHistogram().GetSampleCount()
+				sample.Sum = m.GetHistogram().GetSampleSum()
+			}
+			out.Samples = append(out.Samples, sample)
+		}
+		if len(out.Samples) > 0 {
+			result = append(result, out)
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result, nil
+}
+
+// isTraderMetric 是否为按交易员记录的指标
+func isTraderMetric(name string) bool {
+	for _, prefix := range traderMetricPrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
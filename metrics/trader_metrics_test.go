@@ -0,0 +1,26 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestTraderMetrics_LabelModes 测试 hashed 模式按哈希标签取出交易员指标，bucketed 模式无法区分交易员时返回错误
+func TestTraderMetrics_LabelModes(t *testing.T) {
+	withScrapeConfig(t, ScrapeConfig{LabelMode: LabelModeHashed, LabelSalt: "salt"})
+	NewTradingMetricsRecorder("hashed_trader_1", "binance").RecordEquity(321)
+	NewTradingMetricsRecorder("hashed_trader_2", "binance").RecordEquity(654)
+
+	families, err := TraderMetrics("hashed_trader_1")
+	require.NoError(t, err)
+	require.Len(t, families, 1)
+	assert.Equal(t, "aspen_trading_equity_usdt", families[0].Name)
+	require.Len(t, families[0].Samples, 1)
+	assert.Equal(t, 321.0, *families[0].Samples[0].Value)
+
+	Configure(ScrapeConfig{LabelMode: LabelModeBucketed})
+	_, err = TraderMetrics("hashed_trader_1")
+	assert.ErrorIs(t, err, ErrTraderMetricsBucketed)
+}
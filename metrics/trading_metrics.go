@@ -1,5 +1,12 @@
 package metrics
 
+import (
+	"strings"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
 // TradingMetricsRecorder 交易指标记录器
 type TradingMetricsRecorder struct {
 	TraderID string
@@ -14,13 +21,70 @@ func NewTradingMetricsRecorder(traderID, exchange string) *TradingMetricsRecorde
 	}
 }
 
+// label trader_id 标签值（粒度由 Configure 的 LabelMode 决定）
+func (r *TradingMetricsRecorder) label() string {
+	return TraderLabel(r.TraderID)
+}
+
+// bucketAggregation bucketed 模式下同一分桶内各交易员数值的聚合方式
+type bucketAggregation int
+
+const (
+	bucketSum bucketAggregation = iota // 求和（金额、持仓数）
+	bucketMax                          // 取最大值（百分比）
+)
+
+// bucketGaugeKey 分桶 Gauge 序列（指标 + 标签值）
+type bucketGaugeKey struct {
+	gauge  *prometheus.GaugeVec
+	labels string
+}
+
+// bucketGauges bucketed 模式下每个分桶序列中各交易员的最新值（trader_id -> 值）
+var bucketGauges = struct {
+	sync.Mutex
+	values map[bucketGaugeKey]map[string]float64
+}{values: make(map[bucketGaugeKey]map[string]float64)}
+
+// setGauge 设置交易员的 Gauge（labels 为 trader_id 之后的其余标签值）。
+// bucketed 模式下多个交易员共用同一标签值，直接 Set 会互相覆盖，改为按 agg 聚合分桶内所有交易员的最新值
+func (r *TradingMetricsRecorder) setGauge(gauge *prometheus.GaugeVec, agg bucketAggregation, value float64, labels ...string) {
+	labelValues := append([]string{r.label()}, labels...)
+	if currentScrapeConfig().LabelMode != LabelModeBucketed {
+		gauge.WithLabelValues(labelValues...).Set(value)
+		return
+	}
+
+	key := bucketGaugeKey{gauge: gauge, labels: strings.Join(labelValues, "\x00")}
+	bucketGauges.Lock()
+	defer bucketGauges.Unlock()
+	values := bucketGauges.values[key]
+	if values == nil {
+		values = make(map[string]float64)
+		bucketGauges.values[key] = values
+	}
+	values[r.TraderID] = value
+
+	aggregated, first := 0.0, true
+	for _, v := range values {
+		switch {
+		case agg == bucketSum:
+			aggregated += v
+		case first || v > aggregated:
+			aggregated = v
+		}
+		first = false
+	}
+	gauge.WithLabelValues(labelValues...).Set(aggregated)
+}
+
 // RecordCycle 记录交易周期
 func (r *TradingMetricsRecorder) RecordCycle(success bool) {
 	status := "success"
 	if !success {
 		status = "failed"
 	}
-	TradingCyclesTotal.WithLabelValues(r.TraderID, status).Inc()
+	TradingCyclesTotal.WithLabelValues(r.label(), status).Inc()
 }
 
 // RecordOrder 记录订单
@@ -29,39 +93,45 @@ func (r *TradingMetricsRecorder) RecordOrder(action string, success bool) {
 	if !success {
 		status = "failed"
 	}
-	TradingOrdersTotal.WithLabelValues(r.TraderID, r.Exchange, action, status).Inc()
+	TradingOrdersTotal.WithLabelValues(r.label(), r.Exchange, action, status).Inc()
+}
+
+// RecordSlippage 记录成交滑点（bps）和决策参考价格取得到成交的耗时
+func (r *TradingMetricsRecorder) RecordSlippage(action string, slippageBps, latencySeconds float64) {
+	TradingSlippageBps.WithLabelValues(r.label(), r.Exchange, action).Observe(slippageBps)
+	TradingFillLatency.WithLabelValues(r.label(), r.Exchange, action).Observe(latencySeconds)
 }
 
-// RecordPnL 记录盈亏
+// RecordPnL 记录盈亏（分桶模式下为分桶内合计）
 func (r *TradingMetricsRecorder) RecordPnL(realized, unrealized, total float64) {
-	TradingPnL.WithLabelValues(r.TraderID, "realized").Set(realized)
-	TradingPnL.WithLabelValues(r.TraderID, "unrealized").Set(unrealized)
-	TradingPnL.WithLabelValues(r.TraderID, "total").Set(total)
+	r.setGauge(TradingPnL, bucketSum, realized, "realized")
+	r.setGauge(TradingPnL, bucketSum, unrealized, "unrealized")
+	r.setGauge(TradingPnL, bucketSum, total, "total")
 }
 
-// RecordEquity 记录账户净值
+// RecordEquity 记录账户净值（分桶模式下为分桶内合计）
 func (r *TradingMetricsRecorder) RecordEquity(equity float64) {
-	TradingEquity.WithLabelValues(r.TraderID).Set(equity)
+	r.setGauge(TradingEquity, bucketSum, equity)
 }
 
-// RecordDrawdown 记录回撤
+// RecordDrawdown 记录回撤（分桶模式下为分桶内最大值）
 func (r *TradingMetricsRecorder) RecordDrawdown(drawdownPct float64) {
-	TradingDrawdown.WithLabelValues(r.TraderID).Set(drawdownPct)
+	r.setGauge(TradingDrawdown, bucketMax, drawdownPct)
 }
 
-// RecordPositions 记录持仓数
+// RecordPositions 记录持仓数（分桶模式下为分桶内合计）
 func (r *TradingMetricsRecorder) RecordPositions(count int) {
-	TradingPositions.WithLabelValues(r.TraderID).Set(float64(count))
+	r.setGauge(TradingPositions, bucketSum, float64(count))
 }
 
-// RecordMarginUsed 记录保证金使用率
+// RecordMarginUsed 记录保证金使用率（分桶模式下为分桶内最大值）
 func (r *TradingMetricsRecorder) RecordMarginUsed(pct float64) {
-	TradingMarginUsed.WithLabelValues(r.TraderID).Set(pct)
+	r.setGauge(TradingMarginUsed, bucketMax, pct)
 }
 
 // RecordRiskControl 记录风控触发
 func (r *TradingMetricsRecorder) RecordRiskControl(reason string) {
-	TradingRiskControlTriggered.WithLabelValues(r.TraderID, reason).Inc()
+	TradingRiskControlTriggered.WithLabelValues(r.label(), reason).Inc()
 }
 
 // SetActiveTraders 设置活跃交易员数量
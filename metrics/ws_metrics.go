@@ -52,7 +52,23 @@ func RecordMarketDataLag(symbol string, eventTime int64) {
 	}
 }
 
+// RecordKlineGap 记录实时K线流中的缺口及补齐结果
+func RecordKlineGap(interval string, missing int, result string) {
+	KlineGapsTotal.WithLabelValues(interval, result).Inc()
+	KlineGapCandlesTotal.WithLabelValues(interval).Add(float64(missing))
+}
+
 // SetSubscribedSymbols 设置订阅的币种数
 func SetSubscribedSymbols(count int) {
 	SubscribedSymbols.Set(float64(count))
 }
+
+// RecordMarketSourceError 记录行情数据源请求失败
+func RecordMarketSourceError(source, kind, class string) {
+	MarketSourceErrorsTotal.WithLabelValues(source, kind, class).Inc()
+}
+
+// SetMarketSourceErrorRatio 设置行情数据源滚动窗口内的失败率
+func SetMarketSourceErrorRatio(source, kind string, ratio float64) {
+	MarketSourceErrorRatio.WithLabelValues(source, kind).Set(ratio)
+}
@@ -256,6 +256,7 @@ func GetAvailableCoins() ([]string, error) {
 	if err != nil {
 		return nil, err
 	}
+	coins = filterCoinsByMinVolume(coins)
 
 	var symbols []string
 	for _, coin := range coins {
@@ -279,6 +280,7 @@ func GetTopRatedCoins(limit int) ([]string, error) {
 	if err != nil {
 		return nil, err
 	}
+	coins = filterCoinsByMinVolume(coins)
 
 	// 过滤可用的币种
 	var availableCoins []CoinInfo
@@ -576,7 +578,7 @@ func GetOITopSymbols() ([]string, error) {
 		symbols = append(symbols, symbol)
 	}
 
-	return symbols, nil
+	return filterByMinVolume(symbols), nil
 }
 
 // MergedCoinPool 合并的币种池（AI500 + OI Top）
@@ -603,13 +605,17 @@ func GetMergedCoinPool(ai500Limit int) (*MergedCoinPool, error) {
 		oiTopSymbols = []string{} // 失败时用空列表
 	}
 
-	// 3. 合并并去重
+	// 3. 合并并去重（AI500按评分在前，OI Top按排名在后）
 	symbolSet := make(map[string]bool)
 	symbolSources := make(map[string][]string)
+	var allSymbols []string
 
 	// 添加AI500币种
 	for _, symbol := range ai500TopSymbols {
-		symbolSet[symbol] = true
+		if !symbolSet[symbol] {
+			symbolSet[symbol] = true
+			allSymbols = append(allSymbols, symbol)
+		}
 		symbolSources[symbol] = append(symbolSources[symbol], "ai500")
 	}
 
@@ -617,16 +623,11 @@ func GetMergedCoinPool(ai500Limit int) (*MergedCoinPool, error) {
 	for _, symbol := range oiTopSymbols {
 		if !symbolSet[symbol] {
 			symbolSet[symbol] = true
+			allSymbols = append(allSymbols, symbol)
 		}
 		symbolSources[symbol] = append(symbolSources[symbol], "oi_top")
 	}
 
-	// 转换为数组
-	var allSymbols []string
-	for symbol := range symbolSet {
-		allSymbols = append(allSymbols, symbol)
-	}
-
 	// 获取完整数据
 	ai500Coins, _ := GetCoinPool()
 	oiTopPositions, _ := GetOITopPositions()
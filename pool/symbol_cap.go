@@ -0,0 +1,95 @@
+package pool
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+)
+
+// 币种池排序依据
+const (
+	RankByVolume = "volume" // 按24小时成交额（USDT）
+	RankByOI     = "oi"     // 按持仓价值（OI Top 的当前持仓量 × 最新价）
+)
+
+// NormalizeRankBy 校验币种池排序依据（空值为 volume）
+func NormalizeRankBy(rankBy string) (string, error) {
+	switch rankBy = strings.ToLower(strings.TrimSpace(rankBy)); rankBy {
+	case "":
+		return RankByVolume, nil
+	case RankByVolume, RankByOI:
+		return rankBy, nil
+	default:
+		return "", fmt.Errorf("无效的币种池排序依据: %s（可选 %s、%s）", rankBy, RankByVolume, RankByOI)
+	}
+}
+
+// CapMergedCoinPool 币种池币种数量超过 maxSymbols 时按排序依据只保留排名最高的币种（用于单个交易员的币种数量上限，
+// 避免币种池突然扩大导致AI调用成本和行情订阅暴增）。maxSymbols<=0 表示不限制，原样返回；否则返回新的币种池，不修改 merged
+func CapMergedCoinPool(merged *MergedCoinPool, maxSymbols int, rankBy string) *MergedCoinPool {
+	if merged == nil || maxSymbols <= 0 || len(merged.AllSymbols) <= maxSymbols {
+		return merged
+	}
+	normalized, err := NormalizeRankBy(rankBy)
+	if err != nil {
+		log.Printf("⚠️  %v，使用 %s", err, RankByVolume)
+		normalized = RankByVolume
+	}
+
+	capped := capSymbols(merged.AllSymbols, merged.OITopCoins, maxSymbols, normalized)
+	sources := make(map[string][]string, len(capped))
+	for _, symbol := range capped {
+		if src, ok := merged.SymbolSources[symbol]; ok {
+			sources[symbol] = src
+		}
+	}
+	result := *merged
+	result.AllSymbols = capped
+	result.SymbolSources = sources
+	return &result
+}
+
+// capSymbols 按排序依据保留排名最高的 limit 个币种（排名相同或缺少数据时保持原顺序，
+// 没有排序数据的币种排在最后；获取排序数据失败时按原顺序截取）
+func capSymbols(symbols []string, positions []OIPosition, limit int, rankBy string) []string {
+	scores, err := symbolRankScores(rankBy, positions)
+	if err != nil {
+		log.Printf("⚠️  获取币种池排序数据失败，按原顺序截取: %v", err)
+		scores = nil
+	}
+
+	ranked := append([]string(nil), symbols...)
+	sort.SliceStable(ranked, func(i, j int) bool {
+		si, okI := scores[ranked[i]]
+		sj, okJ := scores[ranked[j]]
+		if okI != okJ {
+			return okI
+		}
+		return si > sj
+	})
+
+	log.Printf("✂️  币种池共 %d 个币种，超过上限 %d，按 %s 保留排名最高的 %d 个",
+		len(symbols), limit, rankBy, limit)
+	return ranked[:limit]
+}
+
+// symbolRankScores 各币种的排序分值
+func symbolRankScores(rankBy string, positions []OIPosition) (map[string]float64, error) {
+	volumes, prices, err := getTickers()
+	if err != nil {
+		return nil, err
+	}
+	if rankBy != RankByOI {
+		return volumes, nil
+	}
+
+	scores := make(map[string]float64, len(positions))
+	for _, pos := range positions {
+		symbol := normalizeSymbol(pos.Symbol)
+		if price, ok := prices[symbol]; ok && pos.CurrentOI > 0 {
+			scores[symbol] = pos.CurrentOI * price
+		}
+	}
+	return scores, nil
+}
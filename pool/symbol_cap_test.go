@@ -0,0 +1,90 @@
+package pool
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupLargePool 模拟 500 个币种的 OI Top 和24小时行情：第 i 个币种成交额为 i×1000，持仓价值为 (500-i)×10
+func setupLargePool(t *testing.T, size int) {
+	t.Helper()
+
+	positions := make([]OIPosition, size)
+	tickers := make([]map[string]string, size)
+	for i := range positions {
+		symbol := fmt.Sprintf("C%03dUSDT", i)
+		positions[i] = OIPosition{Symbol: symbol, Rank: i + 1, CurrentOI: float64(size - i)}
+		tickers[i] = map[string]string{"symbol": symbol, "quoteVolume": fmt.Sprintf("%d", i*1000), "lastPrice": "10"}
+	}
+	oiServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		resp := OITopAPIResponse{Success: true}
+		resp.Data.Positions = positions
+		json.NewEncoder(w).Encode(resp)
+	}))
+	t.Cleanup(oiServer.Close)
+	raw, _ := json.Marshal(tickers)
+	setupTickerServer(t, string(raw))
+
+	oldOITop, oldCoinPool := oiTopConfig, coinPoolConfig
+	oiTopConfig.APIURL = oiServer.URL
+	oiTopConfig.CacheDir = t.TempDir()
+	coinPoolConfig.APIURL = ""
+	coinPoolConfig.UseDefaultCoins = false
+	t.Cleanup(func() {
+		oiTopConfig, coinPoolConfig = oldOITop, oldCoinPool
+	})
+}
+
+// TestCapMergedCoinPool_ByVolume 测试 500 个币种的币种池按24小时成交额截取为排名最高的 N 个
+func TestCapMergedCoinPool_ByVolume(t *testing.T) {
+	setupLargePool(t, 500)
+
+	merged, err := GetMergedCoinPool(20)
+	require.NoError(t, err)
+	assert.Len(t, merged.AllSymbols, 508, "币种池本身不截取：500 个 OI Top 币种 + 8 个默认主流币种")
+
+	capped := CapMergedCoinPool(merged, 30, RankByVolume)
+	require.Len(t, capped.AllSymbols, 30)
+	for i, symbol := range capped.AllSymbols {
+		assert.Equal(t, fmt.Sprintf("C%03dUSDT", 499-i), symbol, "按成交额从高到低")
+	}
+	// 默认主流币种（AI500）不在行情中，没有成交额数据，排在最后被截掉
+	assert.NotContains(t, capped.AllSymbols, "BTCUSDT")
+	assert.Len(t, capped.SymbolSources, 30, "被截掉的币种不保留来源")
+	assert.NotContains(t, capped.SymbolSources, "C000USDT")
+	assert.Len(t, merged.AllSymbols, 508, "不修改原币种池")
+}
+
+// TestCapMergedCoinPool_ByOI 测试按持仓价值截取，以及不限制上限（0）时保留全部币种
+func TestCapMergedCoinPool_ByOI(t *testing.T) {
+	setupLargePool(t, 500)
+
+	merged, err := GetMergedCoinPool(20)
+	require.NoError(t, err)
+	capped := CapMergedCoinPool(merged, 10, RankByOI)
+	require.Len(t, capped.AllSymbols, 10)
+	for i, symbol := range capped.AllSymbols {
+		assert.Equal(t, fmt.Sprintf("C%03dUSDT", i), symbol, "按持仓价值从高到低")
+	}
+
+	assert.Same(t, merged, CapMergedCoinPool(merged, 0, RankByVolume), "未配置上限时不截取")
+	assert.Same(t, merged, CapMergedCoinPool(merged, 1000, RankByVolume), "未超过上限时不截取")
+}
+
+// TestNormalizeRankBy 测试排序依据校验
+func TestNormalizeRankBy(t *testing.T) {
+	rankBy, err := NormalizeRankBy("")
+	require.NoError(t, err)
+	assert.Equal(t, RankByVolume, rankBy)
+	rankBy, err = NormalizeRankBy(" OI ")
+	require.NoError(t, err)
+	assert.Equal(t, RankByOI, rankBy)
+	_, err = NormalizeRankBy("score")
+	assert.Error(t, err)
+}
@@ -0,0 +1,183 @@
+package pool
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"aspen/market"
+)
+
+// VolumeFilterConfig 流动性过滤配置
+type VolumeFilterConfig struct {
+	MinQuoteVolume float64       // 24小时最小成交额（USDT），<=0 表示不过滤
+	TickerURL      string        // 24小时行情API，为空时按全局 market_data_source 选择
+	Timeout        time.Duration // 请求超时
+	CacheTTL       time.Duration // 成交额缓存时间
+}
+
+var volumeFilterConfig = VolumeFilterConfig{
+	MinQuoteVolume: 0,
+	Timeout:        10 * time.Second,
+	CacheTTL:       5 * time.Minute,
+}
+
+// quoteVolumeCache 24小时成交额和最新价缓存
+var quoteVolumeCache = struct {
+	sync.Mutex
+	volumes   map[string]float64
+	prices    map[string]float64
+	fetchedAt time.Time
+}{}
+
+// ticker24hrResponse 24小时行情（只解析需要的字段）
+type ticker24hrResponse struct {
+	Symbol      string `json:"symbol"`
+	QuoteVolume string `json:"quoteVolume"`
+	LastPrice   string `json:"lastPrice"`
+}
+
+// SetMinQuoteVolume 设置币种池最小24小时成交额（USDT），<=0 表示关闭过滤
+func SetMinQuoteVolume(minVolume float64) {
+	volumeFilterConfig.MinQuoteVolume = minVolume
+	if minVolume > 0 {
+		log.Printf("✓ 已启用币种池流动性过滤（24h成交额 ≥ %.0f USDT）", minVolume)
+	}
+}
+
+// getQuoteVolumes 获取所有币种的24小时成交额（带缓存）
+func getQuoteVolumes() (map[string]float64, error) {
+	volumes, _, err := getTickers()
+	return volumes, err
+}
+
+// getTickers 获取所有币种的24小时成交额和最新价（带缓存）
+func getTickers() (volumes, prices map[string]float64, err error) {
+	quoteVolumeCache.Lock()
+	defer quoteVolumeCache.Unlock()
+
+	if quoteVolumeCache.volumes != nil && time.Since(quoteVolumeCache.fetchedAt) < volumeFilterConfig.CacheTTL {
+		return quoteVolumeCache.volumes, quoteVolumeCache.prices, nil
+	}
+
+	volumes, prices, err = fetchTickers()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	quoteVolumeCache.volumes = volumes
+	quoteVolumeCache.prices = prices
+	quoteVolumeCache.fetchedAt = time.Now()
+	return volumes, prices, nil
+}
+
+// fetchTickers 从交易所24小时行情接口获取成交额和最新价
+func fetchTickers() (map[string]float64, map[string]float64, error) {
+	client := &http.Client{
+		Timeout: volumeFilterConfig.Timeout,
+	}
+
+	tickerURL := volumeFilterConfig.TickerURL
+	if tickerURL == "" {
+		url, err := market.GetTicker24hrURL()
+		if err != nil {
+			return nil, nil, err
+		}
+		tickerURL = url
+	}
+
+	resp, err := client.Get(tickerURL)
+	if err != nil {
+		return nil, nil, fmt.Errorf("请求24小时行情失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, fmt.Errorf("读取响应失败: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil, fmt.Errorf("API返回错误 (status %d): %s", resp.StatusCode, string(body))
+	}
+
+	var tickers []ticker24hrResponse
+	if err := json.Unmarshal(body, &tickers); err != nil {
+		return nil, nil, fmt.Errorf("JSON解析失败: %w", err)
+	}
+
+	volumes := make(map[string]float64, len(tickers))
+	prices := make(map[string]float64, len(tickers))
+	for _, t := range tickers {
+		volume, err := strconv.ParseFloat(t.QuoteVolume, 64)
+		if err != nil {
+			continue
+		}
+		volumes[t.Symbol] = volume
+		if price, err := strconv.ParseFloat(t.LastPrice, 64); err == nil {
+			prices[t.Symbol] = price
+		}
+	}
+	return volumes, prices, nil
+}
+
+// filterByMinVolume 过滤掉24小时成交额低于阈值的币种
+// 行情获取失败时不过滤（避免因行情接口故障清空币种池）；行情中不存在的币种保留
+func filterByMinVolume(symbols []string) []string {
+	minVolume := volumeFilterConfig.MinQuoteVolume
+	if minVolume <= 0 || len(symbols) == 0 {
+		return symbols
+	}
+
+	volumes, err := getQuoteVolumes()
+	if err != nil {
+		log.Printf("⚠️  获取24小时成交额失败，跳过流动性过滤: %v", err)
+		return symbols
+	}
+
+	filtered := make([]string, 0, len(symbols))
+	var dropped []string
+	for _, symbol := range symbols {
+		if volume, ok := volumes[symbol]; ok && volume < minVolume {
+			dropped = append(dropped, symbol)
+			continue
+		}
+		filtered = append(filtered, symbol)
+	}
+
+	if len(dropped) > 0 {
+		log.Printf("🚫 流动性过滤: 移除%d个24h成交额低于%.0f USDT的币种: %v", len(dropped), minVolume, dropped)
+	}
+	return filtered
+}
+
+// filterCoinsByMinVolume 将成交额不足的币种标记为不可用
+func filterCoinsByMinVolume(coins []CoinInfo) []CoinInfo {
+	if volumeFilterConfig.MinQuoteVolume <= 0 {
+		return coins
+	}
+
+	symbols := make([]string, 0, len(coins))
+	for _, coin := range coins {
+		if coin.IsAvailable {
+			symbols = append(symbols, normalizeSymbol(coin.Pair))
+		}
+	}
+
+	kept := make(map[string]bool)
+	for _, symbol := range filterByMinVolume(symbols) {
+		kept[symbol] = true
+	}
+
+	for i := range coins {
+		if coins[i].IsAvailable && !kept[normalizeSymbol(coins[i].Pair)] {
+			coins[i].IsAvailable = false
+		}
+	}
+	return coins
+}
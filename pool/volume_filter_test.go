@@ -0,0 +1,102 @@
+package pool
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"aspen/market"
+)
+
+// setupTickerServer 启动返回固定24小时成交额的测试服务器，并重置过滤配置
+func setupTickerServer(t *testing.T, body string) {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(body))
+	}))
+
+	oldConfig := volumeFilterConfig
+	volumeFilterConfig.TickerURL = server.URL
+	volumeFilterConfig.CacheTTL = time.Minute
+	quoteVolumeCache.volumes = nil
+
+	t.Cleanup(func() {
+		server.Close()
+		volumeFilterConfig = oldConfig
+		quoteVolumeCache.volumes = nil
+	})
+}
+
+const cannedTickers = `[
+	{"symbol":"BTCUSDT","quoteVolume":"15000000000.00"},
+	{"symbol":"ETHUSDT","quoteVolume":"8000000000.00"},
+	{"symbol":"LOWUSDT","quoteVolume":"120000.50"},
+	{"symbol":"THINUSDT","quoteVolume":"4999999.99"}
+]`
+
+func TestFilterByMinVolume(t *testing.T) {
+	setupTickerServer(t, cannedTickers)
+	SetMinQuoteVolume(5_000_000)
+
+	got := filterByMinVolume([]string{"BTCUSDT", "LOWUSDT", "ETHUSDT", "THINUSDT", "NEWUSDT"})
+	want := []string{"BTCUSDT", "ETHUSDT", "NEWUSDT"} // 行情中不存在的币种保留
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("filterByMinVolume() = %v, want %v", got, want)
+	}
+}
+
+func TestFilterByMinVolume_Disabled(t *testing.T) {
+	setupTickerServer(t, cannedTickers)
+	SetMinQuoteVolume(0)
+
+	symbols := []string{"BTCUSDT", "LOWUSDT"}
+	if got := filterByMinVolume(symbols); !reflect.DeepEqual(got, symbols) {
+		t.Errorf("关闭过滤时不应移除币种, got %v", got)
+	}
+}
+
+func TestFilterByMinVolume_TickerError(t *testing.T) {
+	setupTickerServer(t, `not json`)
+	SetMinQuoteVolume(5_000_000)
+
+	symbols := []string{"BTCUSDT", "LOWUSDT"}
+	if got := filterByMinVolume(symbols); !reflect.DeepEqual(got, symbols) {
+		t.Errorf("行情获取失败时不应过滤, got %v", got)
+	}
+}
+
+func TestFilterByMinVolume_UnsupportedDataSource(t *testing.T) {
+	setupTickerServer(t, cannedTickers)
+	volumeFilterConfig.TickerURL = ""
+	SetMinQuoteVolume(5_000_000)
+	market.InitDataSource("bybit", "")
+	t.Cleanup(func() { market.InitDataSource("binance", "") })
+
+	symbols := []string{"BTCUSDT", "LOWUSDT"}
+	if got := filterByMinVolume(symbols); !reflect.DeepEqual(got, symbols) {
+		t.Errorf("数据源不提供24小时行情时应跳过过滤, got %v", got)
+	}
+}
+
+func TestFilterCoinsByMinVolume(t *testing.T) {
+	setupTickerServer(t, cannedTickers)
+	SetMinQuoteVolume(1_000_000)
+
+	coins := filterCoinsByMinVolume([]CoinInfo{
+		{Pair: "BTCUSDT", IsAvailable: true},
+		{Pair: "low", IsAvailable: true},
+		{Pair: "THINUSDT", IsAvailable: true},
+	})
+
+	wantAvailable := map[string]bool{"BTCUSDT": true, "low": false, "THINUSDT": true}
+	for _, coin := range coins {
+		if coin.IsAvailable != wantAvailable[coin.Pair] {
+			t.Errorf("%s IsAvailable = %v, want %v", coin.Pair, coin.IsAvailable, wantAvailable[coin.Pair])
+		}
+	}
+}
@@ -0,0 +1,197 @@
+// Package research 按需对单个币种做AI研究分析（不需要配置交易员）。
+// 与交易决策引擎（decision 包）分开：研究提示词输出自由格式分析和简短的结构化摘要，不要求交易决策JSON
+package research
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"aspen/market"
+	"aspen/mcp"
+)
+
+const (
+	// UsageTraderID 研究调用在AI用量表中记录的交易员ID（用量按用户统计，不计入任何交易员）
+	UsageTraderID = "research"
+	// MaxQuestionLength 问题的最大字符数
+	MaxQuestionLength = 500
+	// defaultQuestion 未提供问题时的默认研究方向
+	defaultQuestion = "结合当前指标，分析该币种的趋势、关键价位以及做多/做空的机会与风险。"
+)
+
+// Bias 研究结论的方向
+const (
+	BiasBullish = "bullish"
+	BiasBearish = "bearish"
+	BiasNeutral = "neutral"
+)
+
+// Summary 研究结论的结构化摘要
+type Summary struct {
+	Bias       string   `json:"bias"`       // bullish / bearish / neutral
+	Confidence int      `json:"confidence"` // 0-100
+	Horizon    string   `json:"horizon"`    // 分析适用的时间范围，如 "1-3天"
+	KeyPoints  []string `json:"key_points"` // 主要依据
+	Risks      []string `json:"risks"`      // 主要风险
+	Summary    string   `json:"summary"`    // 一句话结论
+}
+
+// Result 一次研究的结果
+type Result struct {
+	Symbol      string    `json:"symbol"`
+	Question    string    `json:"question"`
+	Analysis    string    `json:"analysis"`          // 自由格式分析
+	Summary     *Summary  `json:"summary,omitempty"` // 结构化摘要（AI未按格式输出时为空）
+	MarketData  *Snapshot `json:"market_data"`       // 本次使用的市场数据快照
+	MarketText  string    `json:"market_text"`       // 发送给AI的完整市场数据文本
+	RawResponse string    `json:"-"`
+	DurationMs  int64     `json:"duration_ms"`
+}
+
+// Snapshot 研究使用的关键市场数据（完整指标见 Result.MarketText）
+type Snapshot struct {
+	Price         float64  `json:"price"`
+	PriceChange1h float64  `json:"price_change_1h"`
+	PriceChange4h float64  `json:"price_change_4h"`
+	EMA20         float64  `json:"ema20"`
+	MACD          float64  `json:"macd"`
+	RSI7          float64  `json:"rsi7"`
+	FundingRate   *float64 `json:"funding_rate,omitempty"`  // 数据源不提供时为空
+	OpenInterest  *float64 `json:"open_interest,omitempty"` // 数据源不提供时为空
+}
+
+// newSnapshot 提取关键市场数据（指标无法计算时的 NaN/Inf 按 0 处理，保证可以序列化为JSON）
+func newSnapshot(data *market.Data) *Snapshot {
+	s := &Snapshot{
+		Price:         finite(data.CurrentPrice),
+		PriceChange1h: finite(data.PriceChange1h),
+		PriceChange4h: finite(data.PriceChange4h),
+		EMA20:         finite(data.CurrentEMA20),
+		MACD:          finite(data.CurrentMACD),
+		RSI7:          finite(data.CurrentRSI7),
+	}
+	if !data.NoFundingRate {
+		rate := finite(data.FundingRate)
+		s.FundingRate = &rate
+	}
+	if !data.NoOpenInterest && data.OpenInterest != nil {
+		oi := finite(data.OpenInterest.Latest)
+		s.OpenInterest = &oi
+	}
+	return s
+}
+
+func finite(v float64) float64 {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return 0
+	}
+	return v
+}
+
+// NormalizeQuestion 校验问题长度，空问题使用默认研究方向
+func NormalizeQuestion(question string) (string, error) {
+	question = strings.TrimSpace(question)
+	if question == "" {
+		return defaultQuestion, nil
+	}
+	if utf8.RuneCountInString(question) > MaxQuestionLength {
+		return "", fmt.Errorf("问题过长（最多%d字）", MaxQuestionLength)
+	}
+	return question, nil
+}
+
+// Run 获取币种的市场快照（未订阅的币种只通过REST获取，不新增订阅），调用AI完成研究分析
+func Run(ctx context.Context, client *mcp.Client, symbol, question string) (*Result, error) {
+	symbol = market.Normalize(symbol)
+	question, err := NormalizeQuestion(question)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := market.GetSnapshot(symbol)
+	if err != nil {
+		return nil, fmt.Errorf("获取 %s 市场数据失败: %w", symbol, err)
+	}
+	marketText := market.Format(data)
+
+	start := time.Now()
+	response, err := client.CallWithMessagesContext(ctx, buildSystemPrompt(), buildUserPrompt(symbol, marketText, question, start))
+	if err != nil {
+		return nil, fmt.Errorf("调用AI API失败: %w", err)
+	}
+
+	analysis, summary := parseResponse(response)
+	return &Result{
+		Symbol:      symbol,
+		Question:    question,
+		Analysis:    analysis,
+		Summary:     summary,
+		MarketData:  newSnapshot(data),
+		MarketText:  marketText,
+		RawResponse: response,
+		DurationMs:  time.Since(start).Milliseconds(),
+	}, nil
+}
+
+// buildSystemPrompt 研究提示词（与交易决策提示词分开维护）
+func buildSystemPrompt() string {
+	var sb strings.Builder
+	sb.WriteString("你是专业的加密货币市场研究员。用户想了解某个币种当前的状况，你只做分析，不直接下单。\n\n")
+	sb.WriteString("# 要求\n\n")
+	sb.WriteString("1. 只依据提供的市场数据（价格、K线指标、持仓量、资金费率等）分析，不要编造数据中没有的信息\n")
+	sb.WriteString("2. 重点回答用户的问题，说明判断依据、关键价位和主要风险\n")
+	sb.WriteString("3. 数据不足以得出结论时直接说明，方向给 neutral\n\n")
+	sb.WriteString("# 输出格式\n\n")
+	sb.WriteString("先输出自由格式的分析（Markdown），最后用 <summary> 标签输出结构化摘要JSON：\n\n")
+	sb.WriteString("<summary>\n")
+	sb.WriteString("{\"bias\": \"bullish|bearish|neutral\", \"confidence\": 0-100, \"horizon\": \"1-3天\", ")
+	sb.WriteString("\"key_points\": [\"主要依据\"], \"risks\": [\"主要风险\"], \"summary\": \"一句话结论\"}\n")
+	sb.WriteString("</summary>\n")
+	return sb.String()
+}
+
+// buildUserPrompt 研究的用户提示词：币种市场数据和问题
+func buildUserPrompt(symbol, marketText, question string, now time.Time) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("时间: %s | 币种: %s\n\n", now.UTC().Format("2006-01-02 15:04:05 UTC"), symbol))
+	sb.WriteString("## 市场数据\n\n")
+	sb.WriteString(marketText)
+	sb.WriteString("\n## 问题\n\n")
+	sb.WriteString(question)
+	sb.WriteString("\n")
+	return sb.String()
+}
+
+var summaryPattern = regexp.MustCompile(`(?s)<summary>(.*?)</summary>`)
+
+// parseResponse 拆分自由格式分析和 <summary> 中的结构化摘要；摘要缺失或无法解析时返回完整响应作为分析
+func parseResponse(response string) (string, *Summary) {
+	match := summaryPattern.FindStringSubmatchIndex(response)
+	if match == nil {
+		return strings.TrimSpace(response), nil
+	}
+
+	raw := strings.TrimSpace(response[match[2]:match[3]])
+	raw = strings.TrimPrefix(raw, "```json")
+	raw = strings.TrimPrefix(raw, "```")
+	raw = strings.TrimSuffix(raw, "```")
+
+	var summary Summary
+	if err := json.Unmarshal([]byte(strings.TrimSpace(raw)), &summary); err != nil {
+		return strings.TrimSpace(response), nil
+	}
+	summary.Bias = strings.ToLower(strings.TrimSpace(summary.Bias))
+	if summary.Bias != BiasBullish && summary.Bias != BiasBearish {
+		summary.Bias = BiasNeutral
+	}
+	summary.Confidence = max(0, min(100, summary.Confidence))
+
+	analysis := strings.TrimSpace(response[:match[0]] + response[match[1]:])
+	return analysis, &summary
+}
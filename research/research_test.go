@@ -0,0 +1,97 @@
+package research
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"aspen/market"
+	"aspen/mcp"
+
+	"github.com/agiledragon/gomonkey/v2"
+)
+
+const testResponse = "AVAX 4小时级别处于上升趋势，RSI 偏高。\n\n<summary>\n```json\n" +
+	`{"bias": "Bullish", "confidence": 130, "horizon": "1-3天", "key_points": ["EMA20上方"], "risks": ["RSI超买"], "summary": "趋势偏多"}` +
+	"\n```\n</summary>"
+
+// newTestAIServer 模拟 OpenAI 兼容接口，记录收到的提示词
+func newTestAIServer(t *testing.T, content string, prompts *[]string) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		*prompts = append(*prompts, string(body))
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"choices": []map[string]interface{}{{"message": map[string]string{"content": content}}},
+		})
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// TestRun 测试研究流程：使用币种市场快照构建提示词，拆分自由格式分析和结构化摘要
+func TestRun(t *testing.T) {
+	var snapshotSymbols []string
+	patches := gomonkey.ApplyFunc(market.GetSnapshot, func(symbol string) (*market.Data, error) {
+		snapshotSymbols = append(snapshotSymbols, symbol)
+		return &market.Data{Symbol: symbol, CurrentPrice: 35.5, CurrentRSI7: math.NaN(), NoOpenInterest: true, FundingRate: 0.0001}, nil
+	})
+	defer patches.Reset()
+
+	var prompts []string
+	client, err := mcp.NewForProvider("custom", "test-key", newTestAIServer(t, testResponse, &prompts).URL, "test-model")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := Run(context.Background(), client, "avax", "")
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	if len(snapshotSymbols) != 1 || snapshotSymbols[0] != "AVAXUSDT" {
+		t.Errorf("snapshot symbols = %v", snapshotSymbols)
+	}
+	if len(prompts) != 1 || !strings.Contains(prompts[0], "AVAXUSDT") || !strings.Contains(prompts[0], "结构化摘要") {
+		t.Errorf("提示词应包含币种和摘要格式: %v", prompts)
+	}
+	if result.Question != defaultQuestion {
+		t.Errorf("question = %q", result.Question)
+	}
+	if result.Analysis != "AVAX 4小时级别处于上升趋势，RSI 偏高。" {
+		t.Errorf("analysis = %q", result.Analysis)
+	}
+	if result.Summary == nil || result.Summary.Bias != BiasBullish || result.Summary.Confidence != 100 || result.Summary.Summary != "趋势偏多" {
+		t.Errorf("summary = %+v", result.Summary)
+	}
+	if result.MarketData.Price != 35.5 || result.MarketData.RSI7 != 0 || result.MarketData.OpenInterest != nil || result.MarketData.FundingRate == nil {
+		t.Errorf("market data = %+v", result.MarketData)
+	}
+	if _, err := json.Marshal(result); err != nil {
+		t.Errorf("结果应可序列化: %v", err)
+	}
+}
+
+// TestParseResponse_WithoutSummary 测试AI未按格式输出摘要时保留完整分析
+func TestParseResponse_WithoutSummary(t *testing.T) {
+	for _, response := range []string{"只有分析", "分析\n<summary>不是JSON</summary>"} {
+		analysis, summary := parseResponse(response)
+		if summary != nil || analysis != response {
+			t.Errorf("parseResponse(%q) = %q, %+v", response, analysis, summary)
+		}
+	}
+}
+
+// TestNormalizeQuestion 测试问题长度校验
+func TestNormalizeQuestion(t *testing.T) {
+	if _, err := NormalizeQuestion(strings.Repeat("问", MaxQuestionLength)); err != nil {
+		t.Errorf("最大长度的问题应通过: %v", err)
+	}
+	if _, err := NormalizeQuestion(strings.Repeat("问", MaxQuestionLength+1)); err == nil {
+		t.Error("超长问题应返回错误")
+	}
+}
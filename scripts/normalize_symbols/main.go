@@ -0,0 +1,61 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"strings"
+
+	"aspen/config"
+	"aspen/market"
+)
+
+// 批量规范化所有交易员的 trading_symbols（一次性迁移工具）
+// 用法: go run ./scripts/normalize_symbols -db config.db [-dry-run]
+func main() {
+	dbPath := flag.String("db", "config.db", "数据库文件路径")
+	dryRun := flag.Bool("dry-run", false, "只打印变更，不写入数据库")
+	flag.Parse()
+
+	database, err := config.NewDatabase(*dbPath)
+	if err != nil {
+		log.Fatalf("❌ 打开数据库失败: %v", err)
+	}
+	defer database.Close()
+
+	userIDs, err := database.GetAllUsers()
+	if err != nil {
+		log.Fatalf("❌ 获取用户列表失败: %v", err)
+	}
+
+	changed := 0
+	for _, userID := range userIDs {
+		traders, err := database.GetTraders(userID)
+		if err != nil {
+			log.Printf("⚠️  获取用户 %s 的交易员失败: %v", userID, err)
+			continue
+		}
+
+		for _, t := range traders {
+			symbols, warnings := market.NormalizeSymbols(market.ParseSymbolList(t.TradingSymbols))
+			canonical := strings.Join(symbols, ",")
+			if canonical == t.TradingSymbols {
+				continue
+			}
+
+			log.Printf("🔄 %s (%s): %q → %q %v", t.Name, t.ID, t.TradingSymbols, canonical, warnings)
+			changed++
+			if *dryRun {
+				continue
+			}
+			if err := database.UpdateTraderTradingSymbols(userID, t.ID, canonical); err != nil {
+				log.Printf("❌ 更新交易员 %s 失败: %v", t.ID, err)
+			}
+		}
+	}
+
+	if *dryRun {
+		log.Printf("✅ 预览完成，共 %d 个交易员需要规范化（未写入）", changed)
+	} else {
+		log.Printf("✅ 规范化完成，共更新 %d 个交易员", changed)
+	}
+}
@@ -0,0 +1,32 @@
+package trader
+
+import (
+	"context"
+
+	"aspen/logger"
+	"aspen/mcp"
+)
+
+// resolveAICallBudget 根据配置计算AI调用时间预算
+// 预算必须小于扫描间隔，否则慢周期会与下一周期重叠；超出时收紧为扫描间隔的 2/3
+func resolveAICallBudget(cfg AutoTraderConfig) mcp.CallBudget {
+	budget := mcp.DefaultCallBudget()
+	if cfg.AICallBudget > 0 {
+		budget.Total = cfg.AICallBudget
+	}
+	if cfg.ScanInterval > 0 && budget.Total >= cfg.ScanInterval {
+		limited := cfg.ScanInterval * 2 / 3
+		logger.Warnf("⚠️  [%s] AI调用时间预算 %v 不小于扫描间隔 %v，收紧为 %v", cfg.Name, budget.Total, cfg.ScanInterval, limited)
+		budget.Total = limited
+	}
+	return budget
+}
+
+// newCycleContext 创建本周期的调用上下文：从周期开始计时，获取市场数据与AI调用（含重试）共享同一时间预算
+func (at *AutoTrader) newCycleContext() (context.Context, context.CancelFunc) {
+	total := mcp.DefaultCallBudget().Total
+	if at.mcpClient != nil && at.mcpClient.Budget.Total > 0 {
+		total = at.mcpClient.Budget.Total
+	}
+	return context.WithTimeout(context.Background(), total)
+}
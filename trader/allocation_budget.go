@@ -0,0 +1,89 @@
+package trader
+
+import (
+	"fmt"
+
+	"aspen/decision"
+	"aspen/logger"
+)
+
+// minAllocationOpenUSD 按分配上限收紧后，仓位价值低于此值则直接拒绝开仓
+const minAllocationOpenUSD = 10.0
+
+// accountEquity 从余额信息计算账户净值（钱包余额 + 未实现盈亏）
+func accountEquity(balance map[string]interface{}) float64 {
+	wallet, _ := balance["totalWalletBalance"].(float64)
+	unrealized, _ := balance["totalUnrealizedProfit"].(float64)
+	return wallet + unrealized
+}
+
+// positionMarginUsed 持仓占用的保证金：使用交易器返回的实际初始保证金，未提供时按标记价格/杠杆估算
+func positionMarginUsed(pos map[string]interface{}) float64 {
+	if margin, _ := pos["initialMargin"].(float64); margin > 0 {
+		return margin
+	}
+	quantity, _ := pos["positionAmt"].(float64)
+	if quantity < 0 {
+		quantity = -quantity
+	}
+	markPrice, _ := pos["markPrice"].(float64)
+	return quantity * markPrice / float64(positionLeverage(pos, 10))
+}
+
+// symbolMarginUsed 汇总持仓中各币种占用的保证金（多空合计）
+func symbolMarginUsed(positions []map[string]interface{}) map[string]float64 {
+	margins := make(map[string]float64)
+	for _, pos := range positions {
+		symbol, _ := pos["symbol"].(string)
+		if symbol == "" {
+			continue
+		}
+		margins[symbol] += positionMarginUsed(pos)
+	}
+	return margins
+}
+
+// enforceAllocationBudget 按单币种资金分配上限检查/收紧开仓决策
+// 以执行时的持仓和净值计算：该币种已占用保证金 + 新开仓保证金 不得超过 净值 × 上限%；
+// 已超出上限（如开仓后净值下降）时只禁止加仓，不会强制平仓
+func (at *AutoTrader) enforceAllocationBudget(d *decision.Decision, actionRecord *logger.DecisionAction) error {
+	budgetPct := at.config.AllocationBudget.BudgetPctFor(d.Symbol)
+	if budgetPct <= 0 || d.Leverage <= 0 || d.PositionSizeUSD <= 0 {
+		return nil
+	}
+
+	balance, err := at.trader.GetBalance()
+	if err != nil {
+		return fmt.Errorf("获取账户余额失败: %w", err)
+	}
+	equity := accountEquity(balance)
+	if equity <= 0 {
+		return fmt.Errorf("❌ 账户净值异常 (%.2f)，无法计算 %s 的资金分配", equity, d.Symbol)
+	}
+
+	positions, err := at.trader.GetPositions()
+	if err != nil {
+		return fmt.Errorf("获取持仓失败: %w", err)
+	}
+	existing := symbolMarginUsed(positions)[d.Symbol]
+
+	remaining := equity*budgetPct/100 - existing
+	newMargin := d.PositionSizeUSD / float64(d.Leverage)
+	if newMargin <= remaining {
+		return nil
+	}
+
+	stablecoinUnit := at.getStablecoinUnit()
+	maxSize := remaining * float64(d.Leverage)
+	if remaining <= 0 || maxSize < minAllocationOpenUSD {
+		return reject(RejectSymbolAllocation, fmt.Errorf("❌ %s 资金分配已达上限: 已占用 %.1f%% / 上限 %.0f%%，禁止加仓",
+			d.Symbol, existing/equity*100, budgetPct))
+	}
+
+	adjustment := fmt.Sprintf("单币种分配上限 %.0f%%: 仓位 %.2f → %.2f %s（已占用 %.1f%%）",
+		budgetPct, d.PositionSizeUSD, maxSize, stablecoinUnit, existing/equity*100)
+	logger.Warnf("  ⚠️  %s %s", d.Symbol, adjustment)
+	actionRecord.Adjustments = append(actionRecord.Adjustments, adjustment)
+	d.PositionSizeUSD = maxSize
+	return nil
+}
@@ -0,0 +1,477 @@
+package trader
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"aspen/logger"
+)
+
+// AnomalyConfig 交易员行为异常检测配置（默认关闭）
+// 以交易员自身较早的历史为基线，每个周期结束后用最近的周期窗口与之比较
+type AnomalyConfig struct {
+	Enabled        bool
+	BaselineCycles int     // 基线窗口周期数（近期窗口之前的周期）
+	RecentCycles   int     // 近期窗口周期数
+	Sensitivity    float64 // 灵敏度：阈值按 1/Sensitivity 缩放，越大越敏感
+}
+
+// DefaultAnomalyConfig 默认异常检测参数（未启用）
+func DefaultAnomalyConfig() AnomalyConfig {
+	return AnomalyConfig{
+		BaselineCycles: 200,
+		RecentCycles:   20,
+		Sensitivity:    1,
+	}
+}
+
+// withDefaults 未设置的字段使用默认值
+func (c AnomalyConfig) withDefaults() AnomalyConfig {
+	defaults := DefaultAnomalyConfig()
+	if c.BaselineCycles <= 0 {
+		c.BaselineCycles = defaults.BaselineCycles
+	}
+	if c.RecentCycles <= 0 {
+		c.RecentCycles = defaults.RecentCycles
+	}
+	if c.Sensitivity <= 0 {
+		c.Sensitivity = defaults.Sensitivity
+	}
+	return c
+}
+
+// 异常指标
+const (
+	AnomalyFailureRate  = "failure_rate"  // 周期失败率
+	AnomalyOpenShare    = "open_share"    // 开仓动作占比
+	AnomalyPositionSize = "position_size" // 开仓名义价值中位数
+	AnomalyFeeRatio     = "fee_ratio"     // 手续费占毛盈亏的比例
+)
+
+// anomalyFeeRate 估算手续费使用的费率（与开仓保证金检查一致，按单边 0.04% 计）
+const anomalyFeeRate = 0.0004
+
+// anomalyMinSamples 指标在每个窗口内至少需要的样本数（开仓数/交易数），样本不足时不评估
+const anomalyMinSamples = 3
+
+// BehaviorStats 一个周期窗口内的交易员行为统计
+type BehaviorStats struct {
+	Cycles            int     `json:"cycles"`
+	FailureRate       float64 `json:"failure_rate"`        // 失败周期占比
+	Actions           int     `json:"actions"`             // 成功执行的交易动作数（不含 hold/wait）
+	ActionsPerCycle   float64 `json:"actions_per_cycle"`   // 平均每周期交易动作数
+	OpenShare         float64 `json:"open_share"`          // 开仓动作占交易动作的比例
+	Opens             int     `json:"opens"`               // 开仓次数
+	MedianPositionUSD float64 `json:"median_position_usd"` // 开仓名义价值中位数
+	Trades            int     `json:"trades"`              // 完整交易（平仓）数
+	FeeRatio          float64 `json:"fee_ratio"`           // 估算手续费 / 毛盈亏绝对值之和
+}
+
+// openLeg 统计手续费时追踪的未平仓开仓
+type openLeg struct {
+	price    float64
+	quantity float64
+}
+
+// computeBehaviorStats 统计 records[from:] 的行为指标
+// 开仓价格从 records[:from] 中追踪，保证窗口起点之前开仓、窗口内平仓的交易也能计算盈亏和手续费
+func computeBehaviorStats(records []*logger.DecisionRecord, from int) BehaviorStats {
+	var stats BehaviorStats
+	var failed, opens int
+	var sizes []float64
+	var fees, grossPnL float64
+	legs := make(map[string]openLeg)
+
+	for i, record := range records {
+		inWindow := i >= from
+		if inWindow {
+			stats.Cycles++
+			if !record.Success {
+				failed++
+			}
+		}
+
+		for _, action := range record.Decisions {
+			if !action.Success {
+				continue
+			}
+			side := SymbolAction{Action: action.Action}.side()
+			key := action.Symbol + "_" + side
+
+			switch action.Action {
+			case "open_long", "open_short":
+				legs[key] = openLeg{price: action.Price, quantity: action.Quantity}
+				if inWindow {
+					opens++
+					sizes = append(sizes, action.Price*action.Quantity)
+				}
+			case "close_long", "close_short":
+				leg, ok := legs[key]
+				delete(legs, key)
+				if !inWindow || !ok || leg.price <= 0 || leg.quantity <= 0 || action.Price <= 0 {
+					break
+				}
+				pnl := (action.Price - leg.price) * leg.quantity
+				if side == "short" {
+					pnl = -pnl
+				}
+				stats.Trades++
+				grossPnL += math.Abs(pnl)
+				fees += (leg.price + action.Price) * leg.quantity * anomalyFeeRate
+			}
+			if inWindow && isTrackedAction(action.Action) {
+				stats.Actions++
+			}
+		}
+	}
+
+	if stats.Cycles > 0 {
+		stats.FailureRate = float64(failed) / float64(stats.Cycles)
+		stats.ActionsPerCycle = float64(stats.Actions) / float64(stats.Cycles)
+	}
+	if stats.Actions > 0 {
+		stats.OpenShare = float64(opens) / float64(stats.Actions)
+	}
+	stats.Opens = opens
+	stats.MedianPositionUSD = median(sizes)
+	if grossPnL > 0 {
+		stats.FeeRatio = fees / grossPnL
+	}
+	return stats
+}
+
+// median 中位数（空切片返回0）
+func median(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[mid-1] + sorted[mid]) / 2
+	}
+	return sorted[mid]
+}
+
+// anomalyRule 异常规则：偏离值达到 trigger/灵敏度 时触发，回落到一半以下时解除（滞后，避免反复触发）
+type anomalyRule struct {
+	metric    string
+	desc      string
+	trigger   float64                                   // 灵敏度为 1 时的触发阈值
+	value     func(s BehaviorStats) float64             // 指标值
+	deviation func(baseline, observed float64) float64  // 偏离值
+	ready     func(baseline, recent BehaviorStats) bool // 样本是否足够
+}
+
+// 偏离值计算方式
+var (
+	absoluteDelta = func(baseline, observed float64) float64 { return observed - baseline }
+	relativeDelta = func(baseline, observed float64) float64 {
+		if baseline <= 0 {
+			return 0
+		}
+		return observed/baseline - 1
+	}
+)
+
+// anomalyRules 异常规则列表（按固定顺序评估，结果可复现）
+var anomalyRules = []anomalyRule{
+	{
+		metric:    AnomalyFailureRate,
+		desc:      "周期失败率",
+		trigger:   0.3, // 失败率上升 30 个百分点
+		value:     func(s BehaviorStats) float64 { return s.FailureRate },
+		deviation: absoluteDelta,
+		ready:     func(b, r BehaviorStats) bool { return b.Cycles > 0 && r.Cycles > 0 },
+	},
+	{
+		metric:    AnomalyOpenShare,
+		desc:      "开仓动作占比",
+		trigger:   0.4, // 开仓占比上升 40 个百分点
+		value:     func(s BehaviorStats) float64 { return s.OpenShare },
+		deviation: absoluteDelta,
+		ready: func(b, r BehaviorStats) bool {
+			return b.Actions >= anomalyMinSamples && r.Actions >= anomalyMinSamples
+		},
+	},
+	{
+		metric:    AnomalyPositionSize,
+		desc:      "开仓名义价值中位数",
+		trigger:   2, // 达到基线的 3 倍
+		value:     func(s BehaviorStats) float64 { return s.MedianPositionUSD },
+		deviation: relativeDelta,
+		ready: func(b, r BehaviorStats) bool {
+			return b.Opens >= anomalyMinSamples && r.Opens >= anomalyMinSamples
+		},
+	},
+	{
+		metric:    AnomalyFeeRatio,
+		desc:      "手续费占毛盈亏比例",
+		trigger:   0.25, // 占比上升 25 个百分点
+		value:     func(s BehaviorStats) float64 { return s.FeeRatio },
+		deviation: absoluteDelta,
+		ready: func(b, r BehaviorStats) bool {
+			return b.Trades >= anomalyMinSamples && r.Trades >= anomalyMinSamples
+		},
+	},
+}
+
+// anomalyEvaluation 单个指标的评估结果
+type anomalyEvaluation struct {
+	Metric    string
+	Desc      string
+	Baseline  float64
+	Observed  float64
+	Deviation float64
+	Threshold float64 // 触发阈值（已按灵敏度缩放）
+}
+
+// evaluateAnomalies 比较基线和近期窗口：返回新触发的异常和应解除的指标
+// active 为当前处于异常状态的指标，已处于异常状态的指标不重复触发，偏离回落到阈值一半以下才解除
+func evaluateAnomalies(baseline, recent BehaviorStats, active map[string]bool, sensitivity float64) (triggered []anomalyEvaluation, resolved []string) {
+	if sensitivity <= 0 {
+		sensitivity = 1
+	}
+	for _, rule := range anomalyRules {
+		if !rule.ready(baseline, recent) {
+			continue
+		}
+		eval := anomalyEvaluation{
+			Metric:    rule.metric,
+			Desc:      rule.desc,
+			Baseline:  rule.value(baseline),
+			Observed:  rule.value(recent),
+			Threshold: rule.trigger / sensitivity,
+		}
+		eval.Deviation = rule.deviation(eval.Baseline, eval.Observed)
+
+		switch {
+		case !active[rule.metric] && eval.Deviation >= eval.Threshold:
+			triggered = append(triggered, eval)
+		case active[rule.metric] && eval.Deviation < eval.Threshold/2:
+			resolved = append(resolved, rule.metric)
+		}
+	}
+	return triggered, resolved
+}
+
+// Anomaly 检测到的行为异常（附带基线与观测值作为证据）
+type Anomaly struct {
+	ID             string        `json:"id"`
+	Metric         string        `json:"metric"`
+	Description    string        `json:"description"`
+	DetectedAt     time.Time     `json:"detected_at"`
+	ResolvedAt     *time.Time    `json:"resolved_at,omitempty"` // 为空表示仍处于异常状态
+	Baseline       float64       `json:"baseline"`
+	Observed       float64       `json:"observed"`
+	Threshold      float64       `json:"threshold"`
+	BaselineStats  BehaviorStats `json:"baseline_stats"`
+	RecentStats    BehaviorStats `json:"recent_stats"`
+	Acknowledged   bool          `json:"acknowledged"`
+	AcknowledgedAt *time.Time    `json:"acknowledged_at,omitempty"`
+}
+
+// Active 是否仍处于异常状态
+func (a Anomaly) Active() bool {
+	return a.ResolvedAt == nil
+}
+
+// anomalyHistoryLimit 保留的异常记录数
+const anomalyHistoryLimit = 200
+
+// anomalyStore 异常记录（持久化到决策日志目录下的 anomalies/anomalies.json）
+type anomalyStore struct {
+	mu        sync.Mutex
+	path      string
+	loaded    bool
+	anomalies []Anomaly // 从旧到新
+}
+
+// loadLocked 首次使用时从文件加载（调用方已加锁）
+func (s *anomalyStore) loadLocked() {
+	if s.loaded {
+		return
+	}
+	s.loaded = true
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		return
+	}
+	if err := json.Unmarshal(data, &s.anomalies); err != nil {
+		logger.Warnf("⚠️  解析异常记录失败: %v", err)
+	}
+}
+
+// saveLocked 写入文件（调用方已加锁）
+func (s *anomalyStore) saveLocked() error {
+	if len(s.anomalies) > anomalyHistoryLimit {
+		s.anomalies = s.anomalies[len(s.anomalies)-anomalyHistoryLimit:]
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0700); err != nil {
+		return fmt.Errorf("创建异常记录目录失败: %w", err)
+	}
+	data, err := json.MarshalIndent(s.anomalies, "", "  ")
+	if err != nil {
+		return fmt.Errorf("序列化异常记录失败: %w", err)
+	}
+	if err := os.WriteFile(s.path, data, 0600); err != nil {
+		return fmt.Errorf("写入异常记录失败: %w", err)
+	}
+	return nil
+}
+
+// list 获取全部异常记录（从新到旧）
+func (s *anomalyStore) list() []Anomaly {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.loadLocked()
+	out := make([]Anomaly, 0, len(s.anomalies))
+	for i := len(s.anomalies) - 1; i >= 0; i-- {
+		out = append(out, s.anomalies[i])
+	}
+	return out
+}
+
+// activeMetrics 当前处于异常状态的指标
+func (s *anomalyStore) activeMetrics() map[string]bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.loadLocked()
+	active := make(map[string]bool)
+	for _, a := range s.anomalies {
+		if a.Active() {
+			active[a.Metric] = true
+		}
+	}
+	return active
+}
+
+// apply 记录新触发的异常并解除已恢复的指标
+func (s *anomalyStore) apply(added []Anomaly, resolved []string, now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.loadLocked()
+	for _, metric := range resolved {
+		for i := range s.anomalies {
+			if s.anomalies[i].Metric == metric && s.anomalies[i].Active() {
+				resolvedAt := now
+				s.anomalies[i].ResolvedAt = &resolvedAt
+			}
+		}
+	}
+	s.anomalies = append(s.anomalies, added...)
+	return s.saveLocked()
+}
+
+// acknowledge 确认（忽略）异常
+func (s *anomalyStore) acknowledge(id string, now time.Time) (*Anomaly, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.loadLocked()
+	for i := range s.anomalies {
+		if s.anomalies[i].ID != id {
+			continue
+		}
+		if !s.anomalies[i].Acknowledged {
+			ackAt := now
+			s.anomalies[i].Acknowledged = true
+			s.anomalies[i].AcknowledgedAt = &ackAt
+			if err := s.saveLocked(); err != nil {
+				return nil, err
+			}
+		}
+		a := s.anomalies[i]
+		return &a, nil
+	}
+	return nil, fmt.Errorf("异常记录 %s 不存在", id)
+}
+
+// anomalyStoreFor 获取交易员的异常记录（首次使用时创建）
+func (at *AutoTrader) anomalyStoreFor() *anomalyStore {
+	at.anomalyOnce.Do(func() {
+		at.anomalies = &anomalyStore{path: filepath.Join(at.decisionLogger.Dir(), "anomalies", "anomalies.json")}
+	})
+	return at.anomalies
+}
+
+// checkAnomalies 周期结束后评估行为异常，新触发的异常发出通知，返回新触发的异常
+func (at *AutoTrader) checkAnomalies(now time.Time) []Anomaly {
+	if !at.config.Anomaly.Enabled {
+		return nil
+	}
+	cfg := at.config.Anomaly.withDefaults()
+
+	records, err := at.decisionLogger.GetLatestRecords(cfg.BaselineCycles + cfg.RecentCycles)
+	if err != nil {
+		logger.Warnf("⚠️  异常检测：读取决策日志失败: %v", err)
+		return nil
+	}
+	// 基线至少需要与近期窗口相同的周期数
+	if len(records) < 2*cfg.RecentCycles {
+		return nil
+	}
+	from := len(records) - cfg.RecentCycles
+	baseline := computeBehaviorStats(records[:from], 0)
+	recent := computeBehaviorStats(records, from)
+
+	store := at.anomalyStoreFor()
+	triggered, resolved := evaluateAnomalies(baseline, recent, store.activeMetrics(), cfg.Sensitivity)
+	if len(triggered) == 0 && len(resolved) == 0 {
+		return nil
+	}
+
+	var added []Anomaly
+	for _, eval := range triggered {
+		added = append(added, Anomaly{
+			ID:            fmt.Sprintf("%s-%d", eval.Metric, now.UnixMilli()),
+			Metric:        eval.Metric,
+			Description:   fmt.Sprintf("%s异常：近 %d 个周期为 %.4g，基线为 %.4g", eval.Desc, recent.Cycles, eval.Observed, eval.Baseline),
+			DetectedAt:    now,
+			Baseline:      eval.Baseline,
+			Observed:      eval.Observed,
+			Threshold:     eval.Threshold,
+			BaselineStats: baseline,
+			RecentStats:   recent,
+		})
+	}
+	if err := store.apply(added, resolved, now); err != nil {
+		logger.Warnf("⚠️  保存异常记录失败: %v", err)
+	}
+
+	for _, a := range added {
+		logger.Warnf("🚨 [%s] 交易员行为异常：%s", at.name, a.Description)
+	}
+	for _, metric := range resolved {
+		logger.Infof("✅ [%s] 交易员行为异常已恢复：%s", at.name, metric)
+	}
+	return added
+}
+
+// GetAnomalies 获取行为异常记录（从新到旧）
+func (at *AutoTrader) GetAnomalies() []Anomaly {
+	return at.anomalyStoreFor().list()
+}
+
+// AcknowledgeAnomaly 确认（忽略）行为异常
+func (at *AutoTrader) AcknowledgeAnomaly(id string) (*Anomaly, error) {
+	return at.anomalyStoreFor().acknowledge(id, time.Now())
+}
+
+// activeAnomalyMetrics 当前处于异常状态的指标（按固定顺序）
+func (at *AutoTrader) activeAnomalyMetrics() []string {
+	active := at.anomalyStoreFor().activeMetrics()
+	metrics := []string{}
+	for _, rule := range anomalyRules {
+		if active[rule.metric] {
+			metrics = append(metrics, rule.metric)
+		}
+	}
+	return metrics
+}
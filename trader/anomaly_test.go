@@ -0,0 +1,201 @@
+package trader
+
+import (
+	"testing"
+	"time"
+
+	"aspen/logger"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// syntheticCycle 构造合成周期记录
+func syntheticCycle(success bool, actions ...logger.DecisionAction) *logger.DecisionRecord {
+	for i := range actions {
+		actions[i].Success = true
+	}
+	return &logger.DecisionRecord{Success: success, Decisions: actions}
+}
+
+// roundTrip 一次完整交易：按 entry 开多、按 exit 平多
+func roundTrip(symbol string, entry, exit, qty float64) []*logger.DecisionRecord {
+	return []*logger.DecisionRecord{
+		syntheticCycle(true, logger.DecisionAction{Symbol: symbol, Action: "open_long", Price: entry, Quantity: qty}),
+		syntheticCycle(true, logger.DecisionAction{Symbol: symbol, Action: "close_long", Price: exit, Quantity: qty}),
+	}
+}
+
+// repeatRoundTrips 重复 n 次完整交易
+func repeatRoundTrips(n int, entry, exit, qty float64) []*logger.DecisionRecord {
+	var records []*logger.DecisionRecord
+	for i := 0; i < n; i++ {
+		records = append(records, roundTrip("BTCUSDT", entry, exit, qty)...)
+	}
+	return records
+}
+
+func TestComputeBehaviorStats(t *testing.T) {
+	records := []*logger.DecisionRecord{
+		syntheticCycle(true, logger.DecisionAction{Symbol: "BTCUSDT", Action: "open_long", Price: 100, Quantity: 1}),
+		syntheticCycle(false),
+		syntheticCycle(true,
+			logger.DecisionAction{Symbol: "BTCUSDT", Action: "close_long", Price: 110, Quantity: 1},
+			logger.DecisionAction{Symbol: "ETHUSDT", Action: "open_short", Price: 50, Quantity: 4},
+			logger.DecisionAction{Symbol: "SOLUSDT", Action: "hold"},
+		),
+		syntheticCycle(true, logger.DecisionAction{Symbol: "ETHUSDT", Action: "close_short", Price: 40, Quantity: 4}),
+	}
+
+	stats := computeBehaviorStats(records, 0)
+	assert.Equal(t, 4, stats.Cycles)
+	assert.InDelta(t, 0.25, stats.FailureRate, 1e-9)
+	assert.Equal(t, 4, stats.Actions, "hold 不计入交易动作")
+	assert.InDelta(t, 1.0, stats.ActionsPerCycle, 1e-9)
+	assert.InDelta(t, 0.5, stats.OpenShare, 1e-9)
+	assert.Equal(t, 2, stats.Opens)
+	assert.InDelta(t, 150, stats.MedianPositionUSD, 1e-9)
+	assert.Equal(t, 2, stats.Trades)
+	// 手续费 (100+110)*1*0.0004 + (50+40)*4*0.0004 = 0.228，毛盈亏 10 + 40 = 50
+	assert.InDelta(t, 0.228/50, stats.FeeRatio, 1e-9)
+
+	// 窗口外开仓、窗口内平仓的交易仍计算手续费
+	window := computeBehaviorStats(records, 2)
+	assert.Equal(t, 2, window.Cycles)
+	assert.Zero(t, window.FailureRate)
+	assert.Equal(t, 1, window.Opens)
+	assert.Equal(t, 2, window.Trades)
+}
+
+func TestEvaluateAnomalies(t *testing.T) {
+	tests := []struct {
+		name     string
+		baseline []*logger.DecisionRecord
+		recent   []*logger.DecisionRecord
+		metric   string
+	}{
+		{
+			name:     "失败率上升",
+			baseline: repeatRoundTrips(10, 100, 110, 1),
+			recent: []*logger.DecisionRecord{
+				syntheticCycle(false), syntheticCycle(false), syntheticCycle(false), syntheticCycle(true),
+			},
+			metric: AnomalyFailureRate,
+		},
+		{
+			name:     "全部为开仓动作",
+			baseline: repeatRoundTrips(10, 100, 110, 1),
+			recent: func() []*logger.DecisionRecord {
+				var records []*logger.DecisionRecord
+				for _, symbol := range []string{"BTCUSDT", "ETHUSDT", "SOLUSDT", "BNBUSDT"} {
+					records = append(records, syntheticCycle(true, logger.DecisionAction{Symbol: symbol, Action: "open_long", Price: 100, Quantity: 1}))
+				}
+				return records
+			}(),
+			metric: AnomalyOpenShare,
+		},
+		{
+			name:     "仓位中位数翻三倍",
+			baseline: repeatRoundTrips(10, 100, 110, 1),
+			recent:   repeatRoundTrips(3, 100, 110, 3),
+			metric:   AnomalyPositionSize,
+		},
+		{
+			name:     "手续费吞噬利润",
+			baseline: repeatRoundTrips(10, 100, 110, 1),
+			recent:   repeatRoundTrips(3, 100, 100.1, 1),
+			metric:   AnomalyFeeRatio,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			records := append(append([]*logger.DecisionRecord{}, tt.baseline...), tt.recent...)
+			from := len(tt.baseline)
+			baseline := computeBehaviorStats(records[:from], 0)
+			recent := computeBehaviorStats(records, from)
+
+			triggered, resolved := evaluateAnomalies(baseline, recent, nil, 1)
+			assert.Empty(t, resolved)
+			require.Len(t, triggered, 1)
+			assert.Equal(t, tt.metric, triggered[0].Metric)
+			assert.GreaterOrEqual(t, triggered[0].Deviation, triggered[0].Threshold)
+
+			// 同样的输入结果相同
+			again, _ := evaluateAnomalies(baseline, recent, nil, 1)
+			assert.Equal(t, triggered, again)
+		})
+	}
+
+	// 行为与基线一致时不触发
+	baseline := computeBehaviorStats(repeatRoundTrips(10, 100, 110, 1), 0)
+	triggered, _ := evaluateAnomalies(baseline, baseline, nil, 1)
+	assert.Empty(t, triggered)
+}
+
+func TestEvaluateAnomalies_SensitivityAndHysteresis(t *testing.T) {
+	baseline := BehaviorStats{Cycles: 100, FailureRate: 0.1}
+	at := func(rate float64) BehaviorStats { return BehaviorStats{Cycles: 20, FailureRate: rate} }
+
+	// 偏离 0.2：灵敏度 1 时（阈值 0.3）不触发，灵敏度 2 时（阈值 0.15）触发
+	triggered, _ := evaluateAnomalies(baseline, at(0.3), nil, 1)
+	assert.Empty(t, triggered)
+	triggered, _ = evaluateAnomalies(baseline, at(0.3), nil, 2)
+	require.Len(t, triggered, 1)
+	assert.InDelta(t, 0.15, triggered[0].Threshold, 1e-9)
+
+	// 已处于异常状态：不重复触发，偏离在阈值一半以上时保持，回落到一半以下才解除
+	active := map[string]bool{AnomalyFailureRate: true}
+	triggered, resolved := evaluateAnomalies(baseline, at(0.5), active, 1)
+	assert.Empty(t, triggered)
+	assert.Empty(t, resolved)
+	triggered, resolved = evaluateAnomalies(baseline, at(0.3), active, 1)
+	assert.Empty(t, triggered)
+	assert.Empty(t, resolved, "偏离 0.2 高于解除阈值 0.15，不应解除")
+	_, resolved = evaluateAnomalies(baseline, at(0.2), active, 1)
+	assert.Equal(t, []string{AnomalyFailureRate}, resolved)
+}
+
+func TestCheckAnomalies_PersistAndAcknowledge(t *testing.T) {
+	logDir := t.TempDir()
+	at := newJournalTestTrader(logDir, &MockTrader{})
+	at.config.Anomaly = AnomalyConfig{Enabled: true, BaselineCycles: 6, RecentCycles: 3, Sensitivity: 1}
+
+	// 基线 6 个成功周期，近期 3 个周期全部失败
+	for i := 0; i < 6; i++ {
+		require.NoError(t, at.decisionLogger.LogDecision(&logger.DecisionRecord{Success: true}))
+	}
+	for i := 0; i < 3; i++ {
+		require.NoError(t, at.decisionLogger.LogDecision(&logger.DecisionRecord{Success: false}))
+	}
+
+	now := time.Date(2026, 1, 15, 12, 0, 0, 0, time.UTC)
+	added := at.checkAnomalies(now)
+	require.Len(t, added, 1)
+	assert.Equal(t, AnomalyFailureRate, added[0].Metric)
+	assert.Zero(t, added[0].Baseline)
+	assert.Equal(t, 1.0, added[0].Observed)
+	assert.Equal(t, []string{AnomalyFailureRate}, at.activeAnomalyMetrics())
+	assert.Equal(t, true, at.GetStatus()["anomaly_active"])
+
+	// 异常持续时不重复告警
+	assert.Empty(t, at.checkAnomalies(now.Add(time.Minute)))
+
+	// 重启后从文件恢复，确认后保留记录
+	restarted := newJournalTestTrader(logDir, &MockTrader{})
+	restarted.config.Anomaly = at.config.Anomaly
+	anomalies := restarted.GetAnomalies()
+	require.Len(t, anomalies, 1)
+	assert.True(t, anomalies[0].Active())
+
+	acked, err := restarted.AcknowledgeAnomaly(anomalies[0].ID)
+	require.NoError(t, err)
+	assert.True(t, acked.Acknowledged)
+	assert.True(t, restarted.GetAnomalies()[0].Acknowledged)
+	_, err = restarted.AcknowledgeAnomaly("missing")
+	assert.Error(t, err)
+
+	// 未启用时不评估
+	restarted.config.Anomaly.Enabled = false
+	assert.Nil(t, restarted.checkAnomalies(now))
+}
@@ -37,6 +37,9 @@ type AsterTrader struct {
 	// 缓存交易对精度信息
 	symbolPrecision map[string]SymbolPrecision
 	mu              sync.RWMutex
+
+	// 交易周期的请求ID（附带在交易所请求头中）
+	requestIDHolder
 }
 
 // SymbolPrecision 交易对精度信息
@@ -70,15 +73,16 @@ func NewAsterTrader(user, signer, privateKeyHex string) (*AsterTrader, error) {
 		client = res.GetResult()
 	}
 
-	return &AsterTrader{
+	trader := &AsterTrader{
 		ctx:             context.Background(),
 		user:            user,
 		signer:          signer,
 		privateKey:      privKey,
 		symbolPrecision: make(map[string]SymbolPrecision),
-		client:          client,
 		baseURL:         "https://fapi.asterdex.com",
-	}, nil
+	}
+	trader.client = trader.withRequestIDHeader(client)
+	return trader, nil
 }
 
 // genNonce 生成微秒时间戳
@@ -516,6 +520,14 @@ func (t *AsterTrader) GetBalance() (map[string]interface{}, error) {
 		"totalWalletBalance":    totalWalletBalance, // 钱包余额（不含未实现盈亏）
 		"availableBalance":      availableBalance,   // 可用余额
 		"totalUnrealizedProfit": realUnrealizedPnl,  // 未实现盈亏（从持仓累加）
+		"assetBalances": []AssetBalance{{ // Aster 仅使用 USDT 保证金
+			Asset:            MarginAssetUSDT,
+			WalletBalance:    totalWalletBalance,
+			AvailableBalance: availableBalance,
+			UnrealizedProfit: realUnrealizedPnl,
+			USDPrice:         1,
+			USDValue:         totalEquity,
+		}},
 	}, nil
 }
 
@@ -549,6 +561,9 @@ func (t *AsterTrader) GetPositions() ([]map[string]interface{}, error) {
 		unRealizedProfit, _ := strconv.ParseFloat(pos["unRealizedProfit"].(string), 64)
 		leverageVal, _ := strconv.ParseFloat(pos["leverage"].(string), 64)
 		liquidationPrice, _ := strconv.ParseFloat(pos["liquidationPrice"].(string), 64)
+		marginType, _ := pos["marginType"].(string)
+		isolatedWallet, _ := pos["isolatedWallet"].(string)
+		notional, _ := pos["notional"].(string)
 
 		// 判断方向（与Binance一致）
 		side := "long"
@@ -567,6 +582,7 @@ func (t *AsterTrader) GetPositions() ([]map[string]interface{}, error) {
 			"unRealizedProfit": unRealizedProfit,
 			"leverage":         leverageVal,
 			"liquidationPrice": liquidationPrice,
+			"initialMargin":    positionInitialMargin(marginType, isolatedWallet, notional, leverageVal),
 		})
 	}
 
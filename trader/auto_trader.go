@@ -3,16 +3,19 @@ package trader
 import (
 	configpkg "aspen/config"
 	"aspen/decision"
+	"aspen/httpclient"
 	"aspen/logger"
 	"aspen/market"
 	"aspen/mcp"
 	"aspen/metrics"
 	"aspen/pool"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"math"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -41,10 +44,21 @@ type AutoTraderConfig struct {
 	AsterPrivateKey string // Aster API钱包私钥
 
 	// Paper Trading配置
-	PaperTradingInitialUSDC float64 // 模拟仓初始USDC金额
+	PaperTradingInitialUSDC float64           // 模拟仓初始金额（以 MarginAsset 计，字段名沿用历史的 USDC）
+	PaperPriceImpact        PriceImpactConfig // 模拟仓大单价格冲击模型（默认关闭）
+	PaperFill               PaperFillConfig   // 模拟仓成交价模型（默认按实时价格成交）
+	PaperCosts              PaperCostConfig   // 模拟仓手续费率和固定滑点（零值使用默认费率、无滑点）
+	PaperPositionMode       string            // 模拟仓持仓模式（hedge/one_way，空值为 hedge）
+
+	// 保证金资产（USDT/USDC，用于仓位计算和显示的单位，空值默认 USDT；Hyperliquid 固定 USDC）
+	MarginAsset string
 
 	CoinPoolAPIURL string
 
+	// 使用币种池（AI500+OI Top）时最多保留的币种数量（0 表示不限制）和超出时的排序依据（volume/oi）
+	CoinPoolMaxSymbols int
+	CoinPoolRankBy     string
+
 	// AI配置
 	UseQwen     bool
 	DeepSeekKey string
@@ -59,6 +73,12 @@ type AutoTraderConfig struct {
 	// 扫描配置
 	ScanInterval time.Duration // 扫描间隔（建议3分钟）
 
+	// AI调用时间预算（从周期开始计时，获取市场数据和AI调用含重试共享；0 表示使用默认值，超过扫描间隔时自动收紧）
+	AICallBudget time.Duration
+
+	// 手动触发（run-now）两次之间的冷却时间，0 表示使用默认值（60秒）
+	ManualTriggerCooldown time.Duration
+
 	// 账户配置
 	InitialBalance float64 // 初始金额（用于计算盈亏，需手动设置）
 
@@ -71,6 +91,111 @@ type AutoTraderConfig struct {
 	MaxDrawdown     float64       // 最大回撤百分比（提示）
 	StopTradingTime time.Duration // 触发风控后暂停时长
 
+	// 动态风险缩放（根据当日盈亏和回撤收紧仓位/杠杆上限，零值时按 MaxDailyLoss/MaxDrawdown 使用默认规则）
+	RiskScaling decision.RiskScalingConfig
+
+	// 单币种资金分配上限（单个币种占用保证金不超过净值的一定比例，零值表示不限制）
+	AllocationBudget decision.AllocationBudget
+
+	// 稳定币脱锚保护（报价稳定币偏离锚定超过阈值时禁止新开仓，默认关闭）
+	StablecoinPeg StablecoinPegConfig
+
+	// 行情中断保护：行情超过阈值未更新时禁止新开仓或清仓（默认关闭）
+	DataOutage DataOutageConfig
+
+	// 开仓前允许的最大买卖价差（bps，0 表示不检查）
+	MaxSpreadBps float64
+
+	// 交易事件 Webhook（开仓/平仓/强平/风控事件，URL 为空表示不推送）
+	Webhook WebhookConfig
+
+	// 防反复开平仓：短期内对同一币种同方向重复开平仓需更高信心度（默认关闭）
+	ChurnGuard ChurnGuardConfig
+
+	// 开仓确认K线：开仓方向的指标信号需持续指定根数的已收盘K线（默认关闭）
+	EntryConfirmation EntryConfirmationConfig
+
+	// 最少间隔K线：同一币种上次开平仓后需过去这么多根3分钟K线才能再次开平仓，0 表示不检查
+	MinBarsBetweenTrades int
+
+	// 按金额下单：开仓/加仓按仓位金额（花费的保证金资产）下单，价格波动只影响成交数量（交易器不支持时按数量下单）
+	QuoteQuantityOrders bool
+
+	// 亏损后降仓冷却：单笔平仓亏损达到阈值后按系数缩小之后的开仓仓位（默认关闭）
+	LossCooldown LossCooldownConfig
+
+	// 周期对齐：定时周期在主K线周期收盘后执行（默认关闭，按扫描间隔固定计时）
+	CandleAlign CandleAlignConfig
+
+	// 信心度仓位：开仓/加仓仓位按AI信心度在最小和最大仓位金额之间取值（默认关闭）
+	ConfidenceSizing ConfidenceSizingConfig
+
+	// 提示词缓存：行情与上次AI调用相同或在容差内时复用上次的决策，跳过AI调用（默认关闭）
+	PromptCache decision.PromptCacheConfig
+
+	// 停牌检测：最近连续这么多根3分钟K线成交量为 0 的币种本周期跳过（不给AI行情、不执行其决策），0 表示不检查
+	HaltedZeroVolumeCandles int
+
+	// 重复开仓意图检查窗口：窗口内已成交过相同意图（币种、方向、仓位大小和入场价格相近）且未平仓的开仓被拒绝，0 表示不检查
+	IntentDedupWindow time.Duration
+
+	// 实盘交易员启动时按交易所实际持仓覆盖本地持仓状态并记录差异（模拟仓不需要对账）
+	ReconcilePositionsOnStart bool
+
+	// 仓位大小模式（按金额或按净值百分比，默认按金额）
+	PositionSizing decision.PositionSizing
+
+	// 对冲策略：同币种已有反向持仓时拒绝开仓（no_hedge，默认）或先平掉反向持仓（allow_flip），与交易所账户持仓模式无关
+	HedgePolicy string
+
+	// 只管理持仓模式：拒绝开仓和加仓，平仓、部分平仓和止盈止损调整照常执行（运行中可通过 SetManageOnly 切换）
+	ManageOnly bool
+
+	// 硬回撤上限：净值自最高点回撤达到该百分比时清仓并永久停用交易员（手动重置前不能启动），0 表示不启用
+	HardDrawdownPct float64
+
+	// 停用原因（从数据库加载，非空表示已因硬回撤停用）
+	DisabledReason string
+
+	// 硬回撤计算的最高净值和重置后重新计算状态（从数据库加载，进程重启后沿用）
+	HardDrawdownPeak   float64
+	HardDrawdownRebase bool
+
+	// 动态风控的净值峰值和当日（UTC）起始净值（从数据库加载，进程重启后沿用）
+	RiskPeakEquity float64
+	DayStartEquity float64
+	DayStartDate   string
+
+	// 行情数据源（空值使用全局 market_data_source；与全局不同时通过该数据源的 REST 接口获取行情）
+	DataSource market.DataSource
+
+	// 指标周期（RSI/EMA/ATR/TSI，未配置的周期使用默认值）
+	Indicators market.IndicatorParams
+
+	// 交易暂停窗口（UTC时间段内禁止新开仓，可选清仓）
+	Blackout BlackoutConfig
+
+	// 粉尘仓位阈值（USD）：持仓名义价值低于该值时在下一周期自动平仓，0 表示不处理
+	DustThresholdUSD float64
+
+	// 挂单最长保留时间：超过该时长未成交的限价挂单在下一周期自动撤单并释放冻结资金，0 表示不处理
+	StaleOrderMaxAge time.Duration
+
+	// 交易员行为异常检测（与自身历史基线比较，默认关闭）
+	Anomaly AnomalyConfig
+
+	// 平均成交滑点（bps）超过该值时在交易员详情中提示，0 表示不提示
+	SlippageWarningBps float64
+
+	// 两级模型：满足触发条件的决策由复核模型确认后执行（默认不启用）
+	Review ReviewConfig
+
+	// 提示词估算 token 预算，超出时发送前裁剪次要市场数据分段，0 表示不限制
+	PromptTokenBudget int
+
+	// 跨交易员的开仓协调（如用户级最大同时持仓数，由交易员管理器注入），nil 表示不限制
+	OpenGate OpenGate
+
 	// 仓位模式
 	IsCrossMargin bool // true=全仓模式, false=逐仓模式
 
@@ -91,10 +216,18 @@ type AutoTrader struct {
 	config                AutoTraderConfig
 	trader                Trader // 使用Trader接口（支持多平台）
 	mcpClient             *mcp.Client
+	reviewClient          *mcp.Client                     // 复核模型（未启用两级模型时为 nil）
 	decisionLogger        *logger.DecisionLogger // 决策日志记录器
 	metricsRecorder       *metrics.TradingMetricsRecorder // 交易指标记录器
 	initialBalance        float64
 	dailyPnL              float64
+	dayStartEquity        float64              // 当日（UTC）起始净值（用于计算当日盈亏）
+	dayStartDate          string               // 当日起始净值对应的 UTC 日期
+	peakEquity            float64              // 观察到的净值峰值（用于计算回撤，持久化）
+	riskLimits            *decision.RiskLimits // 本周期生效的动态风控上限
+	marginAsset           string               // 保证金资产（USDT/USDC）
+	assetBalances         []AssetBalance       // 最近一次获取的各保证金资产余额
+	bookTickerFn          bookTickerFunc       // 盘口数据来源（测试可替换）
 	customPrompt          string   // 自定义交易策略prompt
 	overrideBasePrompt    bool     // 是否覆盖基础prompt
 	systemPromptTemplate  string   // 系统提示词模板名称
@@ -107,10 +240,41 @@ type AutoTrader struct {
 	callCount             int                // AI调用次数
 	positionFirstSeenTime map[string]int64   // 持仓首次出现时间 (symbol_side -> timestamp毫秒)
 	stopMonitorCh         chan struct{}      // 用于停止监控goroutine
+	stopOnce              *sync.Once         // 保证 stopMonitorCh 只关闭一次（Stop 和硬回撤熔断可能并发触发）
 	monitorWg             sync.WaitGroup     // 用于等待监控goroutine结束
 	peakPnLCache          map[string]float64 // 最高收益缓存 (symbol -> 峰值盈亏百分比)
 	peakPnLCacheMutex     sync.RWMutex       // 缓存读写锁
 	lastBalanceSyncTime   time.Time          // 上次余额同步时间
+	positionMeta          map[string]*PositionMeta // 持仓元数据 (symbol_side -> 止损止盈/开仓周期等)
+	positionMetaMutex     sync.RWMutex             // 持仓元数据读写锁
+	positionViewCache     []PositionView           // 持仓详情缓存
+	positionViewCacheAt   time.Time                // 持仓详情缓存时间
+	cycleMutex            sync.Mutex               // 保护决策周期运行状态/调度时间
+	cycleRunning          bool                     // 是否有决策周期正在执行
+	runningCycle          int                      // 正在执行（或最近一次）的周期编号
+	nextCycleAt           time.Time                // 下一次定时周期时间
+	lastManualTrigger     time.Time                // 上次手动触发时间
+	rescheduleCh          chan struct{}            // 手动触发要求重新计算定时周期
+	symbolHistory         symbolHistory            // 各币种最近的交易动作
+	intents               intentBook               // 已成交的开仓意图（拒绝重复开仓）
+	signalStreaks         signalStreakBook         // 各币种指标信号已持续的K线数（开仓确认）
+	lastActions           lastActionBook           // 各币种最近一次开平仓所在的K线（最少间隔K线）
+	dataFeed              dataFeedState            // 行情时效监测（行情中断保护）
+	lossCooldown          lossCooldownState        // 亏损后降仓冷却状态
+	promptCache           *decision.PromptCache    // 提示词缓存（未启用时为 nil）
+	manageOnly            atomic.Bool              // 只管理持仓模式（拒绝开仓和加仓）
+	hardDrawdown          hardDrawdownState        // 硬回撤熔断状态
+	errorLog              errorLog                 // 最近的错误（AI调用、行情获取、决策执行失败）
+	positionIDs           positionIDBook           // 持仓ID登记（由 positionMetaMutex 保护）
+	faultHook             faultHookFunc            // 周期执行的故障注入（仅测试使用）
+	anomalies             *anomalyStore            // 行为异常记录（首次使用时加载）
+	anomalyOnce           sync.Once                // 初始化 anomalies
+	slippage              *slippageTracker         // 成交滑点记录（首次使用时加载）
+	slippageOnce          sync.Once                // 初始化 slippage
+	decisionPrices        map[string]priceRef      // 本周期AI决策使用的价格快照（滑点参考价格）
+	decisionPricesMutex   sync.Mutex               // 保护 decisionPrices
+	webhook               *webhookSender           // 交易事件 Webhook（未配置时为 nil）
+	webhookMu             sync.RWMutex             // 保护 webhook
 	database              interface{}        // 数据库引用（用于自动更新余额）
 	userID                string             // 用户ID
 }
@@ -179,6 +343,8 @@ func NewAutoTrader(config AutoTraderConfig, database interface{}, userID string)
 		}
 	}
 
+	mcpClient.Budget = resolveAICallBudget(config)
+
 	// 初始化币种池API
 	if config.CoinPoolAPIURL != "" {
 		pool.SetCoinPoolAPI(config.CoinPoolAPIURL)
@@ -189,9 +355,19 @@ func NewAutoTrader(config AutoTraderConfig, database interface{}, userID string)
 		config.Exchange = "binance"
 	}
 
+	// 保证金资产（账户币种）：Hyperliquid 只支持 USDC 保证金；模拟仓的余额、日志和API均以该币种计
+	marginAsset, err := NormalizeMarginAsset(config.MarginAsset)
+	if err != nil {
+		return nil, err
+	}
+	if config.Exchange == "hyperliquid" && marginAsset != MarginAssetUSDC {
+		logger.Infof("💱 [%s] Hyperliquid 仅支持 USDC 保证金，忽略配置的 %s", config.Name, marginAsset)
+		marginAsset = MarginAssetUSDC
+	}
+	logger.Infof("💱 [%s] 保证金资产: %s", config.Name, marginAsset)
+
 	// 根据配置创建对应的交易器
 	var trader Trader
-	var err error
 
 	// 记录仓位模式（通用）
 	marginModeStr := "全仓"
@@ -217,19 +393,21 @@ func NewAutoTrader(config AutoTraderConfig, database interface{}, userID string)
 			return nil, fmt.Errorf("初始化Aster交易器失败: %w", err)
 		}
 	case "paper":
-		logger.Infof("📝 [%s] 使用模拟仓交易 (初始余额: %.2f USDC)", config.Name, config.PaperTradingInitialUSDC)
 		if config.PaperTradingInitialUSDC <= 0 {
 			config.PaperTradingInitialUSDC = 10000.0 // 默认值
 		}
-		// 尝试使用带数据库持久化的构造函数
-		if db, ok := database.(*configpkg.Database); ok && db != nil {
-			trader, err = NewPaperTraderWithDB(config.PaperTradingInitialUSDC, db, config.ID)
-		} else {
-			trader, err = NewPaperTrader(config.PaperTradingInitialUSDC)
-		}
+		logger.Infof("📝 [%s] 使用模拟仓交易 (初始余额: %.2f %s)", config.Name, config.PaperTradingInitialUSDC, marginAsset)
+		// 尝试使用带数据库持久化的构造函数（没有数据库时不持久化）
+		db, _ := database.(*configpkg.Database)
+		trader, err = NewPaperTraderWithDB(config.PaperTradingInitialUSDC, marginAsset, db, config.ID)
 		if err != nil {
 			return nil, fmt.Errorf("初始化模拟仓交易器失败: %w", err)
 		}
+		trader.(*PaperTrader).SetPriceImpact(config.PaperPriceImpact)
+		trader.(*PaperTrader).SetFillModel(config.PaperFill)
+		trader.(*PaperTrader).SetCosts(config.PaperCosts)
+		trader.(*PaperTrader).SetPositionMode(config.PaperPositionMode)
+		trader.(*PaperTrader).SetDataSource(config.DataSource)
 		// ⚠️ 重要：对于 paper trader，强制使用 PaperTradingInitialUSDC 作为 InitialBalance
 		// 这样总盈亏计算才会正确（因为 PaperTrader 的初始余额就是 PaperTradingInitialUSDC）
 		config.InitialBalance = config.PaperTradingInitialUSDC
@@ -237,6 +415,16 @@ func NewAutoTrader(config AutoTraderConfig, database interface{}, userID string)
 		return nil, fmt.Errorf("不支持的交易平台: %s", config.Exchange)
 	}
 
+	// 对冲策略（交易所账户的持仓模式不影响该规则）
+	hedgePolicy, err := NormalizeHedgePolicy(config.HedgePolicy)
+	if err != nil {
+		return nil, err
+	}
+	config.HedgePolicy = hedgePolicy
+	if pm, ok := trader.(PositionModeTrader); ok {
+		logger.Infof("🔀 [%s] 账户持仓模式: %s，对冲策略: %s", config.Name, pm.PositionMode(), hedgePolicy)
+	}
+
 	// 验证初始金额配置（模拟仓不需要此验证，因为它使用 PaperTradingInitialUSDC）
 	if config.Exchange != "paper" && config.InitialBalance <= 0 {
 		return nil, fmt.Errorf("初始金额必须大于0，请在配置中设置InitialBalance")
@@ -246,6 +434,11 @@ func NewAutoTrader(config AutoTraderConfig, database interface{}, userID string)
 	logDir := fmt.Sprintf("decision_logs/%s", config.ID)
 	decisionLogger := logger.NewDecisionLogger(logDir)
 
+	// 动态风险缩放：未配置时使用默认规则
+	if config.RiskScaling == (decision.RiskScalingConfig{}) {
+		config.RiskScaling = decision.DefaultRiskScalingConfig(config.MaxDailyLoss, config.MaxDrawdown)
+	}
+
 	// 设置默认系统提示词模板
 	systemPromptTemplate := config.SystemPromptTemplate
 	if systemPromptTemplate == "" {
@@ -253,7 +446,36 @@ func NewAutoTrader(config AutoTraderConfig, database interface{}, userID string)
 		systemPromptTemplate = "adaptive"
 	}
 
-	return &AutoTrader{
+	// 持久化每次AI调用的Token用量（用于按交易员统计AI成本）
+	type AIUsageRecorder interface {
+		RecordAIUsage(traderID, userID, provider, model string, promptTokens, completionTokens int, costUSD float64) error
+	}
+	if db, ok := database.(AIUsageRecorder); ok {
+		traderID, traderName := config.ID, config.Name
+		mcpClient.OnUsage = func(usage mcp.TokenUsage) {
+			if err := db.RecordAIUsage(traderID, userID, usage.Provider, usage.Model, usage.PromptTokens, usage.CompletionTokens, usage.CostUSD); err != nil {
+				logger.Warnf("⚠️ [%s] 保存AI用量失败: %v", traderName, err)
+			}
+		}
+	}
+
+	// AI 请求/响应调试日志中脱敏用户和交易员标识
+	mcpClient.RedactValues = []string{userID, config.ID}
+
+	// 两级模型：复核模型与扫描模型共享周期时间预算和Token用量统计
+	var reviewClient *mcp.Client
+	if config.Review.Enabled() {
+		reviewClient, err = newReviewClient(config.Review)
+		if err != nil {
+			return nil, fmt.Errorf("初始化复核模型失败: %w", err)
+		}
+		reviewClient.Budget = mcpClient.Budget
+		reviewClient.OnUsage = mcpClient.OnUsage
+		reviewClient.RedactValues = mcpClient.RedactValues
+		logger.Infof("🔍 [%s] 启用两级模型: 扫描 %s，复核 %s（不可用时 %s）", config.Name, mcpClient.Model, reviewClient.Model, config.Review.Fallback)
+	}
+
+	at := &AutoTrader{
 		id:                    config.ID,
 		name:                  config.Name,
 		aiModel:               config.AIModel,
@@ -261,9 +483,11 @@ func NewAutoTrader(config AutoTraderConfig, database interface{}, userID string)
 		config:                config,
 		trader:                trader,
 		mcpClient:             mcpClient,
+		reviewClient:          reviewClient,
 		decisionLogger:        decisionLogger,
 		metricsRecorder:       metrics.NewTradingMetricsRecorder(config.ID, config.Exchange),
 		initialBalance:        config.InitialBalance,
+		marginAsset:           marginAsset,
 		systemPromptTemplate:  systemPromptTemplate,
 		defaultCoins:          config.DefaultCoins,
 		tradingCoins:          config.TradingCoins,
@@ -273,25 +497,70 @@ func NewAutoTrader(config AutoTraderConfig, database interface{}, userID string)
 		isRunning:             false,
 		positionFirstSeenTime: make(map[string]int64),
 		stopMonitorCh:         make(chan struct{}),
+		stopOnce:              &sync.Once{},
 		monitorWg:             sync.WaitGroup{},
 		peakPnLCache:          make(map[string]float64),
+		positionMeta:          make(map[string]*PositionMeta),
 		peakPnLCacheMutex:     sync.RWMutex{},
 		lastBalanceSyncTime:   time.Now(), // 初始化为当前时间
 		database:              database,
 		userID:                userID,
-	}, nil
+		webhook:               newWebhookSender(config.Webhook),
+		promptCache:           decision.NewPromptCache(config.PromptCache),
+	}
+
+	at.manageOnly.Store(config.ManageOnly)
+	at.hardDrawdown.disabledReason = config.DisabledReason
+	at.hardDrawdown.peak = config.HardDrawdownPeak
+	at.hardDrawdown.rebase = config.HardDrawdownRebase
+	at.peakEquity = config.RiskPeakEquity
+	at.dayStartEquity = config.DayStartEquity
+	at.dayStartDate = config.DayStartDate
+
+	if config.QuoteQuantityOrders {
+		if _, ok := trader.(QuoteQuantityTrader); !ok {
+			logger.Warnf("⚠️  [%s] 交易所不支持按金额下单，开仓仍按数量下单", config.Name)
+		}
+	}
+
+	// 模拟仓强平和止损/止盈单触发时推送 Webhook，限价单成交时按开仓记录
+	if pt, ok := trader.(*PaperTrader); ok {
+		pt.SetLiquidationHandler(at.onPaperLiquidation)
+		pt.SetStopOrderHandler(at.onPaperStopOrder)
+		pt.SetLimitOrderHandler(at.onPaperLimitFill)
+	}
+
+	// 从决策日志恢复各币种最近的交易动作（重启后仍能在提示词和防反复开平仓检查中使用）、持仓ID和已成交的开仓意图
+	if records, err := decisionLogger.GetLatestRecords(symbolHistoryLookback); err == nil {
+		at.symbolHistory.restoreFromRecords(records)
+		at.positionIDs.restoreFromRecords(records)
+		at.intents.restoreFromRecords(at.id, records)
+		at.lastActions.restoreFromRecords(records)
+	}
+
+	return at, nil
 }
 
 // Run 运行自动交易主循环
 func (at *AutoTrader) Run() error {
+	// 已因硬回撤停用的交易员在手动重置前不运行
+	if reason := at.DisabledReason(); reason != "" {
+		logger.Warnf("[%s] ⛔ 交易员已停用（%s），需手动重置后才能启动", at.name, reason)
+		return nil
+	}
+
 	at.isRunning = true
 	at.stopMonitorCh = make(chan struct{})
+	at.stopOnce = &sync.Once{}
 	at.startTime = time.Now()
 
 	logger.Info("🚀 AI驱动自动交易系统启动")
 	stablecoinUnit := at.getStablecoinUnit()
 	logger.Infof("💰 初始余额: %.2f %s", at.initialBalance, stablecoinUnit)
 	logger.Infof("⚙️  扫描间隔: %v", at.config.ScanInterval)
+	if align := at.config.CandleAlign; align.Enabled() {
+		logger.Infof("⚙️  周期对齐: %v K线收盘后 %v 执行", align.Timeframe, align.Delay)
+	}
 	logger.Info("🤖 AI将全权决定杠杆、仓位大小、止损止盈等参数")
 	at.monitorWg.Add(1)
 	defer func() {
@@ -300,28 +569,55 @@ func (at *AutoTrader) Run() error {
 		logger.Infof("[%s] ⏹ 自动交易主循环已退出 (isRunning=%v)", at.name, at.isRunning)
 	}()
 
+	// 重启后本地持仓状态可能与交易所不一致，先按交易所持仓对账
+	if at.config.ReconcilePositionsOnStart {
+		if _, err := at.ReconcilePositions(); err != nil {
+			logger.Warnf("⚠️ [%s] 启动时持仓对账失败: %v", at.name, err)
+		}
+	}
+
 	// 启动回撤监控
 	at.startDrawdownMonitor()
 
-	ticker := time.NewTicker(at.config.ScanInterval)
-	defer ticker.Stop()
+	rescheduleCh := make(chan struct{}, 1)
+	at.cycleMutex.Lock()
+	at.rescheduleCh = rescheduleCh
+	at.cycleMutex.Unlock()
 
-	// 首次立即执行
-	if err := at.runCycle(); err != nil {
-		logger.Errorf("❌ 执行失败: %v", err)
+	// 定时周期按扫描间隔计时，启用周期对齐时在K线收盘后执行
+	next := at.nextScheduledCycle(time.Now())
+	timer := time.NewTimer(time.Until(next))
+	defer timer.Stop()
+	at.setNextCycleAt(next)
+	defer at.setNextCycleAt(time.Time{})
+
+	// 首次立即执行（对齐K线收盘时等待第一个收盘点，不在K线中途决策）
+	if !at.config.CandleAlign.Enabled() {
+		at.runScheduledCycle()
 	}
 
 	for at.isRunning {
 		select {
-		case <-ticker.C:
+		case <-timer.C:
+			next = at.nextScheduledCycle(time.Now())
+			timer.Reset(time.Until(next))
+			at.setNextCycleAt(next)
 			if !at.isRunning {
 				logger.Warnf("[%s] ⚠️  检测到 isRunning=false，退出循环", at.name)
 				return nil
 			}
-			if err := at.runCycle(); err != nil {
-				logger.Errorf("❌ 执行失败: %v", err)
-				// 注意：runCycle 的错误不会导致停止，只是记录日志
+			at.runScheduledCycle()
+		case <-rescheduleCh:
+			// 手动触发并要求重新计时：从现在起重新计算下一次定时周期
+			next = at.nextScheduledCycle(time.Now())
+			if !timer.Stop() {
+				select {
+				case <-timer.C:
+				default:
+				}
 			}
+			timer.Reset(time.Until(next))
+			at.setNextCycleAt(next)
 		case <-at.stopMonitorCh:
 			logger.Infof("[%s] ⏹ 收到停止信号 (stopMonitorCh)，退出自动交易主循环", at.name)
 			return nil
@@ -338,11 +634,16 @@ func (at *AutoTrader) Stop() {
 		return
 	}
 	at.isRunning = false
-	close(at.stopMonitorCh) // 通知监控goroutine停止
-	at.monitorWg.Wait()     // 等待监控goroutine结束
+	at.signalStop()     // 通知监控goroutine停止
+	at.monitorWg.Wait() // 等待监控goroutine结束
 	logger.Info("⏹ 自动交易系统停止")
 }
 
+// signalStop 关闭 stopMonitorCh 通知主循环和监控goroutine停止（Stop 和硬回撤熔断可能并发调用，只关闭一次）
+func (at *AutoTrader) signalStop() {
+	at.stopOnce.Do(func() { close(at.stopMonitorCh) })
+}
+
 // autoSyncBalanceIfNeeded 自动同步余额（每10分钟检查一次，变化>5%才更新）
 func (at *AutoTrader) autoSyncBalanceIfNeeded() {
 	// ⚠️ 重要：Paper Trading 的初始余额是固定的，不应该被自动同步修改
@@ -446,35 +747,75 @@ func (at *AutoTrader) autoSyncBalanceIfNeeded() {
 	at.lastBalanceSyncTime = time.Now()
 }
 
+// runScheduledCycle 执行一次定时周期（已有手动周期在执行时跳过）
+func (at *AutoTrader) runScheduledCycle() {
+	err := at.executeCycle(CycleTrigger{Type: TriggerScheduled})
+	var inProgress *CycleInProgressError
+	if errors.As(err, &inProgress) {
+		logger.Warnf("[%s] ⏭  周期 #%d 仍在执行，跳过本次定时周期", at.name, inProgress.CycleNumber)
+		return
+	}
+	if err != nil {
+		// 注意：runCycle 的错误不会导致停止，只是记录日志
+		logger.Errorf("❌ 执行失败: %v", err)
+	}
+}
+
 // runCycle 运行一个交易周期（使用AI全权决策）
-func (at *AutoTrader) runCycle() error {
-	at.callCount++
+// 定时与手动触发共用此逻辑，调用方需先通过 beginCycleLocked 分配周期编号
+func (at *AutoTrader) runCycle(trigger CycleTrigger) error {
+	// 本周期的请求ID：标记本周期的日志行、写入决策记录，并附带在AI和交易所请求头中
+	requestID := httpclient.NewRequestID()
+	cycleLog := logger.WithField("request_id", requestID)
+	if setter, ok := at.trader.(requestIDSetter); ok {
+		setter.SetRequestID(requestID)
+	}
 
-	logger.Debug("\n" + strings.Repeat("=", 70) + "\n")
-	logger.Infof("⏰ %s - AI决策周期 #%d", time.Now().Format("2006-01-02 15:04:05"), at.callCount)
-	logger.Debug(strings.Repeat("=", 70))
+	cycleLog.Debug("\n" + strings.Repeat("=", 70) + "\n")
+	cycleLog.Infof("⏰ %s - AI决策周期 #%d (%s)", time.Now().Format("2006-01-02 15:04:05"), at.callCount, trigger.Type)
+	cycleLog.Debug(strings.Repeat("=", 70))
 
 	// 创建决策记录
 	record := &logger.DecisionRecord{
+		RequestID:    requestID,
 		ExecutionLog: []string{},
 		Success:      true,
+		TriggerType:  trigger.Type,
+		TriggeredBy:  trigger.RequestedBy,
 	}
 
+	// 周期截止时间：构建上下文、获取市场数据和AI调用共享同一时间预算
+	cycleCtx, cancelCycle := at.newCycleContext()
+	defer cancelCycle()
+	cycleCtx = httpclient.WithRequestID(cycleCtx, requestID)
+
 	// 1. 检查是否需要停止交易
 	if time.Now().Before(at.stopUntil) {
 		remaining := at.stopUntil.Sub(time.Now())
-		logger.Infof("⏸ 风险控制：暂停交易中，剩余 %.0f 分钟", remaining.Minutes())
+		cycleLog.Infof("⏸ 风险控制：暂停交易中，剩余 %.0f 分钟", remaining.Minutes())
 		record.Success = false
 		record.ErrorMessage = fmt.Sprintf("风险控制暂停中，剩余 %.0f 分钟", remaining.Minutes())
 		at.decisionLogger.LogDecision(record)
 		return nil
 	}
 
-	// 2. 重置日盈亏（每天重置）
-	if time.Since(at.lastResetTime) > 24*time.Hour {
+	// 处于交易暂停窗口且配置了清仓时，先平掉所有持仓（窗口内开仓由开仓检查拒绝）
+	record.ExecutionLog = append(record.ExecutionLog, at.flattenForBlackout(time.Now())...)
+
+	// 行情超过阈值未更新时进入行情中断保护（禁止开仓，配置了清仓时平掉所有持仓），恢复后自动解除
+	record.ExecutionLog = append(record.ExecutionLog, at.handleDataOutage(time.Now())...)
+
+	// 清理部分平仓或数量取整后残留的粉尘仓位
+	record.ExecutionLog = append(record.ExecutionLog, at.closeDustPositions()...)
+
+	// 撤销超时未成交的限价挂单，释放冻结的资金
+	record.ExecutionLog = append(record.ExecutionLog, at.cancelStaleOrders()...)
+
+	// 2. 重置日盈亏（UTC 日期切换时重置，当日起始净值在构建上下文时按日期切换）
+	if now := time.Now(); now.UTC().Format(dayStartLayout) != at.lastResetTime.UTC().Format(dayStartLayout) {
 		at.dailyPnL = 0
-		at.lastResetTime = time.Now()
-		logger.Info("📅 日盈亏已重置")
+		at.lastResetTime = now
+		cycleLog.Info("📅 日盈亏已重置")
 	}
 
 	// 3. 自动同步余额（每10分钟检查一次，充值/提现后自动更新）
@@ -485,6 +826,7 @@ func (at *AutoTrader) runCycle() error {
 	if err != nil {
 		record.Success = false
 		record.ErrorMessage = fmt.Sprintf("构建交易上下文失败: %v", err)
+		at.RecordError(ErrorKindContext, "", "", err)
 		at.decisionLogger.LogDecision(record)
 		return fmt.Errorf("构建交易上下文失败: %w", err)
 	}
@@ -496,6 +838,38 @@ func (at *AutoTrader) runCycle() error {
 		TotalUnrealizedProfit: ctx.Account.TotalPnL,
 		PositionCount:         ctx.Account.PositionCount,
 		MarginUsedPct:         ctx.Account.MarginUsedPct,
+		MarginAsset:           ctx.Account.MarginAsset,
+		NativeEquity:          ctx.Account.NativeEquity,
+	}
+	for _, a := range at.assetBalances {
+		record.AccountState.AssetBalances = append(record.AccountState.AssetBalances, logger.AssetBalanceSnapshot{
+			Asset:    a.Asset,
+			Amount:   a.WalletBalance + a.UnrealizedProfit,
+			USDValue: a.USDValue,
+		})
+	}
+	if l := ctx.RiskLimits; l != nil {
+		record.RiskLimits = &logger.RiskLimitsSnapshot{
+			DailyPnLPct:        l.DailyPnLPct,
+			DrawdownPct:        l.DrawdownPct,
+			SizeFactor:         l.SizeFactor,
+			LeverageFactor:     l.LeverageFactor,
+			EntriesBlocked:     l.EntriesBlocked,
+			MaxBTCETHLeverage:  l.MaxBTCETHLeverage,
+			MaxAltcoinLeverage: l.MaxAltcoinLeverage,
+			MaxBTCETHPosition:  l.MaxBTCETHPosition,
+			MaxAltcoinPosition: l.MaxAltcoinPosition,
+			Reason:             l.Reason,
+		}
+	}
+
+	// 净值自最高点回撤达到硬上限：清仓并永久停用（手动重置前不能再启动）
+	if reason := at.checkHardDrawdown(ctx.Account.TotalEquity); reason != "" {
+		record.Success = false
+		record.ErrorMessage = reason
+		record.ExecutionLog = append(record.ExecutionLog, at.disableForHardDrawdown(reason)...)
+		at.decisionLogger.LogDecision(record)
+		return nil
 	}
 
 	// 保存持仓快照
@@ -512,40 +886,53 @@ func (at *AutoTrader) runCycle() error {
 		})
 	}
 
-	logger.Debug(strings.Repeat("=", 70))
+	cycleLog.Debug(strings.Repeat("=", 70))
 	for _, coin := range ctx.CandidateCoins {
 		record.CandidateCoins = append(record.CandidateCoins, coin.Symbol)
 	}
 
 	stablecoinUnit := at.getStablecoinUnit()
-	logger.Infof("📊 账户净值: %.2f %s | 可用: %.2f %s | 持仓: %d",
+	cycleLog.Infof("📊 账户净值: %.2f %s | 可用: %.2f %s | 持仓: %d",
 		ctx.Account.TotalEquity, stablecoinUnit, ctx.Account.AvailableBalance, stablecoinUnit, ctx.Account.PositionCount)
 	
 	// 诊断信息：显示候选币种配置情况
 	if len(ctx.CandidateCoins) == 0 {
-		logger.Warnf("⚠️  警告: 候选币种列表为空！")
-		logger.Warnf("   - 自定义币种 (tradingCoins): %v (数量: %d)", at.tradingCoins, len(at.tradingCoins))
-		logger.Warnf("   - 默认币种 (defaultCoins): %v (数量: %d)", at.defaultCoins, len(at.defaultCoins))
-		logger.Warnf("   - 如果两者都为空，系统应该使用 AI500+OI Top 作为 fallback")
+		cycleLog.Warnf("⚠️  警告: 候选币种列表为空！")
+		cycleLog.Warnf("   - 自定义币种 (tradingCoins): %v (数量: %d)", at.tradingCoins, len(at.tradingCoins))
+		cycleLog.Warnf("   - 默认币种 (defaultCoins): %v (数量: %d)", at.defaultCoins, len(at.defaultCoins))
+		cycleLog.Warnf("   - 如果两者都为空，系统应该使用 AI500+OI Top 作为 fallback")
 	} else {
-		logger.Infof("📋 候选币种列表: %d 个", len(ctx.CandidateCoins))
+		cycleLog.Infof("📋 候选币种列表: %d 个", len(ctx.CandidateCoins))
 		for i, coin := range ctx.CandidateCoins {
 			if i < 5 { // 只显示前5个
-				logger.Infof("   %d. %s (来源: %v)", i+1, coin.Symbol, coin.Sources)
+				cycleLog.Infof("   %d. %s (来源: %v)", i+1, coin.Symbol, coin.Sources)
 			}
 		}
 		if len(ctx.CandidateCoins) > 5 {
-			logger.Infof("   ... 还有 %d 个币种", len(ctx.CandidateCoins)-5)
+			cycleLog.Infof("   ... 还有 %d 个币种", len(ctx.CandidateCoins)-5)
 		}
 	}
 
+	// 周期日志：AI调用前写入周期开始快照，进程在周期中途重启后据此对账
+	cycleID := newCycleID(time.Now())
+	journal, err := at.decisionLogger.BeginCycle(cycleID, record)
+	if err != nil {
+		cycleLog.Warnf("⚠ 写入周期日志失败: %v", err)
+	}
+	if err := at.injectFault(faultAfterCycleStarted, -1); err != nil {
+		return err
+	}
+
 	// 5. 调用AI获取完整决策
-	logger.Infof("🤖 正在请求AI分析并决策... [模板: %s]", at.systemPromptTemplate)
+	ctx.CallCtx = cycleCtx
+	cycleLog.Infof("🤖 正在请求AI分析并决策... [模板: %s]", at.systemPromptTemplate)
+	snapshotAt := time.Now() // 行情快照在AI调用前获取，作为滑点的参考时间
 	decision, err := decision.GetFullDecisionWithCustomPrompt(ctx, at.mcpClient, at.customPrompt, at.overrideBasePrompt, at.systemPromptTemplate)
+	at.recordMarketDataErrors(ctx.MarketDataErrors)
 
 	if decision != nil && decision.AIRequestDurationMs > 0 {
 		record.AIRequestDurationMs = decision.AIRequestDurationMs
-		logger.Infof("⏱️ AI调用耗时: %.2f 秒", float64(record.AIRequestDurationMs)/1000)
+		cycleLog.Infof("⏱️ AI调用耗时: %.2f 秒", float64(record.AIRequestDurationMs)/1000)
 		record.ExecutionLog = append(record.ExecutionLog,
 			fmt.Sprintf("AI调用耗时: %d ms", record.AIRequestDurationMs))
 	}
@@ -559,30 +946,43 @@ func (at *AutoTrader) runCycle() error {
 			decisionJSON, _ := json.MarshalIndent(decision.Decisions, "", "  ")
 			record.DecisionJSON = string(decisionJSON)
 		}
+		for _, w := range decision.ParseWarnings {
+			record.ParseWarnings = append(record.ParseWarnings, logger.ParseWarning{Code: w.Code, Message: w.Message, Payloads: w.Payloads})
+			record.ExecutionLog = append(record.ExecutionLog, fmt.Sprintf("⚠️ %s: %s", w.Code, w.Message))
+			cycleLog.Warnf("⚠️  [%s] %s", at.name, w.Message)
+		}
 	}
 
 	if err != nil {
 		record.Success = false
 		record.ErrorMessage = fmt.Sprintf("获取AI决策失败: %v", err)
+		at.RecordError(ErrorKindAI, "", "", err)
+		at.recordValidationRejection(record, err)
+		if errors.Is(err, mcp.ErrBudgetExhausted) {
+			// 时间预算耗尽与AI服务商错误分开记录，便于区分是周期过慢还是服务异常
+			record.ErrorMessage = fmt.Sprintf("AI调用超出周期时间预算: %v", err)
+			cycleLog.Warnf("⏱️  本周期AI调用超出时间预算（%v），跳过本周期决策", at.mcpClient.Budget.Total)
+		}
 
 		// 打印系统提示词和AI思维链（即使有错误，也要输出以便调试）
 		if decision != nil {
-			logger.Debug("\n" + strings.Repeat("=", 70) + "\n")
-			logger.Infof("📋 系统提示词 [模板: %s] (错误情况)", at.systemPromptTemplate)
-			logger.Debug(strings.Repeat("=", 70))
-			logger.Info(decision.SystemPrompt)
-			logger.Debug(strings.Repeat("=", 70))
+			cycleLog.Debug("\n" + strings.Repeat("=", 70) + "\n")
+			cycleLog.Infof("📋 系统提示词 [模板: %s] (错误情况)", at.systemPromptTemplate)
+			cycleLog.Debug(strings.Repeat("=", 70))
+			cycleLog.Info(decision.SystemPrompt)
+			cycleLog.Debug(strings.Repeat("=", 70))
 
 			if decision.CoTTrace != "" {
-				logger.Debug("\n" + strings.Repeat("-", 70) + "\n")
-				logger.Info("💭 AI思维链分析（错误情况）:")
-				logger.Debug(strings.Repeat("-", 70))
-				logger.Info(decision.CoTTrace)
-				logger.Debug(strings.Repeat("-", 70))
+				cycleLog.Debug("\n" + strings.Repeat("-", 70) + "\n")
+				cycleLog.Info("💭 AI思维链分析（错误情况）:")
+				cycleLog.Debug(strings.Repeat("-", 70))
+				cycleLog.Info(decision.CoTTrace)
+				cycleLog.Debug(strings.Repeat("-", 70))
 			}
 		}
 
 		at.decisionLogger.LogDecision(record)
+		journal.Complete()
 		return fmt.Errorf("获取AI决策失败: %w", err)
 	}
 
@@ -609,51 +1009,43 @@ func (at *AutoTrader) runCycle() error {
 	//           d.Leverage, d.PositionSizeUSD, d.StopLoss, d.TakeProfit)
 	//     }
 	// }
-	logger.Info("")
-	logger.Debug(strings.Repeat("-", 70))
+	cycleLog.Info("")
+	cycleLog.Debug(strings.Repeat("-", 70))
 	// 8. 对决策排序：确保先平仓后开仓（防止仓位叠加超限）
-	logger.Debug(strings.Repeat("-", 70))
+	cycleLog.Debug(strings.Repeat("-", 70))
+
+	// 两级模型复核（开仓、大仓位、大额亏损平仓等需复核模型确认）
+	at.reviewDecisions(ctx, decision, record)
 
 	// 8. 对决策排序：确保先平仓后开仓（防止仓位叠加超限）
 	sortedDecisions := sortDecisionsByPriority(decision.Decisions)
 
-	logger.Info("🔄 执行顺序（已优化）: 先平仓→后开仓")
+	cycleLog.Info("🔄 执行顺序（已优化）: 先平仓→后开仓")
 	for i, d := range sortedDecisions {
-		logger.Infof("  [%d] %s %s", i+1, d.Symbol, d.Action)
-	}
-	logger.Info("")
-
-	// 执行决策并记录结果
-	for _, d := range sortedDecisions {
-		actionRecord := logger.DecisionAction{
-			Action:    d.Action,
-			Symbol:    d.Symbol,
-			Quantity:  0,
-			Leverage:  d.Leverage,
-			Price:     0,
-			Timestamp: time.Now(),
-			Success:   false,
-		}
-
-		if err := at.executeDecisionWithRecord(&d, &actionRecord); err != nil {
-			logger.Errorf("❌ 执行决策失败 (%s %s): %v", d.Symbol, d.Action, err)
-			actionRecord.Error = err.Error()
-			record.ExecutionLog = append(record.ExecutionLog, fmt.Sprintf("❌ %s %s 失败: %v", d.Symbol, d.Action, err))
-		} else {
-			actionRecord.Success = true
-			record.ExecutionLog = append(record.ExecutionLog, fmt.Sprintf("✓ %s %s 成功", d.Symbol, d.Action))
-			// 成功执行后短暂延迟
-			time.Sleep(1 * time.Second)
-		}
+		cycleLog.Infof("  [%d] %s %s", i+1, d.Symbol, d.Action)
+	}
+	cycleLog.Info("")
 
-		record.Decisions = append(record.Decisions, actionRecord)
+	// 执行决策并记录结果（计划动作和每个动作的结果同步写入周期日志）
+	// 以AI看到的行情快照价格作为成交滑点的参考价格
+	at.observeSignalStreaks(ctx, snapshotAt)
+	at.setDecisionPrices(ctx, snapshotAt)
+	err = at.executeCycleDecisions(journal, cycleID, record, sortedDecisions, ctx.Positions)
+	at.setDecisionPrices(nil, time.Time{})
+	if err != nil {
+		return err
 	}
 
-	// 9. 保存决策记录
+	// 9. 保存决策记录（保存成功后周期结束，删除周期日志；保存失败时保留，重启后对账补全）
 	if err := at.decisionLogger.LogDecision(record); err != nil {
-		logger.Warnf("⚠ 保存决策记录失败: %v", err)
+		cycleLog.Warnf("⚠ 保存决策记录失败: %v", err)
+	} else if err := journal.Complete(); err != nil {
+		cycleLog.Warnf("⚠ %v", err)
 	}
 
+	// 行为异常检测（与自身历史基线比较）
+	at.checkAnomalies(time.Now())
+
 	// 10. 记录交易指标
 	at.metricsRecorder.RecordCycle(record.Success)
 	at.metricsRecorder.RecordEquity(record.AccountState.TotalBalance)
@@ -673,6 +1065,19 @@ func (at *AutoTrader) runCycle() error {
 	return nil
 }
 
+// updateDrawdown 按观察到的净值更新峰值，返回当前净值自峰值的回撤（%）。
+// 峰值从第一次取得的净值开始跟踪（不以初始余额为起点，由 updateRiskEquity 保存，进程重启后沿用），
+// 已经低于初始余额的交易员不会因此永久处于回撤中而被禁止开仓，净值回升后回撤随之减小
+func (at *AutoTrader) updateDrawdown(totalEquity float64) float64 {
+	if totalEquity > at.peakEquity {
+		at.peakEquity = totalEquity
+	}
+	if at.peakEquity <= 0 || totalEquity <= 0 {
+		return 0
+	}
+	return (at.peakEquity - totalEquity) / at.peakEquity * 100
+}
+
 // buildTradingContext 构建交易上下文
 func (at *AutoTrader) buildTradingContext() (*decision.Context, error) {
 	// 1. 获取账户信息
@@ -699,6 +1104,10 @@ func (at *AutoTrader) buildTradingContext() (*decision.Context, error) {
 	// Total Equity = 钱包余额 + 未实现盈亏
 	totalEquity := totalWalletBalance + totalUnrealizedProfit
 
+	// 各保证金资产明细，以及净值折合保证金资产的原生数量
+	at.assetBalances, _ = balance["assetBalances"].([]AssetBalance)
+	nativeEquity, _ := marginAssetEquity(balance, at.getStablecoinUnit(), totalEquity)
+
 	// 2. 获取持仓信息
 	positions, err := at.trader.GetPositions()
 	if err != nil {
@@ -738,12 +1147,12 @@ func (at *AutoTrader) buildTradingContext() (*decision.Context, error) {
 		unrealizedPnl := pos["unRealizedProfit"].(float64)
 		liquidationPrice := pos["liquidationPrice"].(float64)
 
-		// 计算占用保证金（估算）
+		// 占用保证金（交易器未返回实际初始保证金时按标记价格估算，与单币种分配上限检查口径一致）
 		leverage := 10 // 默认值，实际应该从持仓信息获取
 		if lev, ok := pos["leverage"].(float64); ok {
 			leverage = int(lev)
 		}
-		marginUsed := (quantity * markPrice) / float64(leverage)
+		marginUsed := positionMarginUsed(pos)
 		totalMarginUsed += marginUsed
 
 		// 计算盈亏百分比（基于保证金，考虑杠杆）
@@ -785,6 +1194,14 @@ func (at *AutoTrader) buildTradingContext() (*decision.Context, error) {
 			delete(at.positionFirstSeenTime, key)
 		}
 	}
+	currentFirstSeen := make(map[string]int64, len(currentPositionKeys))
+	for key := range currentPositionKeys {
+		currentFirstSeen[key] = at.positionFirstSeenTime[key]
+	}
+	at.syncPositionMeta(currentFirstSeen)
+	for i := range positionInfos {
+		positionInfos[i].PositionID = at.positionIDFor(positionInfos[i].Symbol, positionInfos[i].Side)
+	}
 
 	// 3. 获取交易员的候选币种池
 	candidateCoins, err := at.getCandidateCoins()
@@ -804,6 +1221,21 @@ func (at *AutoTrader) buildTradingContext() (*decision.Context, error) {
 		marginUsedPct = (totalMarginUsed / totalEquity) * 100
 	}
 
+	// 计算当日盈亏和回撤（用于动态风控）
+	dailyPnLPct, drawdownPct := at.updateRiskEquity(totalEquity, time.Now())
+	wasBlocked := at.riskLimits != nil && at.riskLimits.EntriesBlocked
+	at.riskLimits = decision.ComputeRiskLimits(at.config.RiskScaling, totalEquity, dailyPnLPct, drawdownPct,
+		at.config.BTCETHLeverage, at.config.AltcoinLeverage)
+	if at.riskLimits.EntriesBlocked {
+		logger.Warnf("⛔ 动态风控：%s，本周期禁止新开仓", at.riskLimits.Reason)
+		if !wasBlocked {
+			at.emitWebhook(WebhookEvent{Event: WebhookEventRisk, Reason: fmt.Sprintf("动态风控禁止开仓：%s", at.riskLimits.Reason)})
+		}
+	} else if at.riskLimits.SizeFactor < 1 {
+		logger.Warnf("⚠️  动态风控：%s，仓位系数 %.2f，杠杆上限 山寨%dx / BTC/ETH %dx",
+			at.riskLimits.Reason, at.riskLimits.SizeFactor, at.riskLimits.MaxAltcoinLeverage, at.riskLimits.MaxBTCETHLeverage)
+	}
+
 	// 5. 分析历史表现（最近100个周期，避免长期持仓的交易记录丢失）
 	// 假设每3分钟一个周期，100个周期 = 5小时，足够覆盖大部分交易
 	performance, err := at.decisionLogger.AnalyzePerformance(100)
@@ -828,10 +1260,26 @@ func (at *AutoTrader) buildTradingContext() (*decision.Context, error) {
 			MarginUsed:       totalMarginUsed,
 			MarginUsedPct:    marginUsedPct,
 			PositionCount:    len(positionInfos),
+			DailyPnL:         at.dailyPnL,
+			DailyPnLPct:      dailyPnLPct,
+			DrawdownPct:      drawdownPct,
+			MarginAsset:      at.getStablecoinUnit(),
+			NativeEquity:     nativeEquity,
 		},
-		Positions:      positionInfos,
-		CandidateCoins: candidateCoins,
-		Performance:    performance, // 添加历史表现分析
+		Positions:        positionInfos,
+		CandidateCoins:   candidateCoins,
+		Performance:      performance, // 添加历史表现分析
+		RiskLimits:       at.riskLimits,
+		AllocationBudget: at.config.AllocationBudget,
+		Allocations:      decision.ComputeSymbolAllocations(positionInfos, totalEquity, at.config.AllocationBudget),
+		RecentActions:    at.recentSymbolActions(),
+		PromptBudget:     at.config.PromptTokenBudget,
+		Sizing:           at.config.PositionSizing,
+		DataSource:       at.config.DataSource,
+		Indicators:       at.config.Indicators,
+		PromptCache:      at.promptCache,
+		ManageOnly:       at.ManageOnly(),
+		HaltedCandles:    at.config.HaltedZeroVolumeCandles,
 	}
 
 	return ctx, nil
@@ -842,11 +1290,18 @@ func (at *AutoTrader) executeDecisionWithRecord(decision *decision.Decision, act
 	var err error
 	action := decision.Action
 
+	// 指定了持仓ID时先校验持仓归属，并补全币种
+	if err := at.resolvePositionRef(decision, actionRecord); err != nil {
+		return err
+	}
+
 	switch action {
 	case "open_long":
 		err = at.executeOpenLongWithRecord(decision, actionRecord)
 	case "open_short":
 		err = at.executeOpenShortWithRecord(decision, actionRecord)
+	case "add_to_position":
+		err = at.executeAddToPositionWithRecord(decision, actionRecord)
 	case "close_long":
 		err = at.executeCloseLongWithRecord(decision, actionRecord)
 	case "close_short":
@@ -864,28 +1319,136 @@ func (at *AutoTrader) executeDecisionWithRecord(decision *decision.Decision, act
 		return fmt.Errorf("未知的action: %s", action)
 	}
 
+	// 检查未通过被拒绝时记录原因代码和说明
+	if err != nil {
+		recordRejection(decision, actionRecord, err)
+	}
+
 	// 记录订单指标
 	at.metricsRecorder.RecordOrder(action, err == nil)
 
 	return err
 }
 
+// enforceRiskLimits 按本周期动态风控上限检查/收紧开仓决策
+func (at *AutoTrader) enforceRiskLimits(d *decision.Decision) error {
+	limits := at.riskLimits
+	if limits == nil {
+		return nil
+	}
+	if limits.EntriesBlocked {
+		return reject(limits.BlockCode(), fmt.Errorf("❌ 动态风控已禁止开仓（%s）", limits.Reason))
+	}
+	if maxLev := limits.MaxLeverageFor(d.Symbol); maxLev > 0 && d.Leverage > maxLev {
+		logger.Warnf("  ⚠️  动态风控：%s 杠杆 %dx → %dx", d.Symbol, d.Leverage, maxLev)
+		d.Leverage = maxLev
+	}
+	if maxPos := limits.MaxPositionFor(d.Symbol); maxPos > 0 && d.PositionSizeUSD > maxPos {
+		logger.Warnf("  ⚠️  动态风控：%s 仓位 %.2f → %.2f %s", d.Symbol, d.PositionSizeUSD, maxPos, at.getStablecoinUnit())
+		d.PositionSizeUSD = maxPos
+	}
+	return nil
+}
+
 // executeOpenLongWithRecord 执行开多仓并记录详细信息
 func (at *AutoTrader) executeOpenLongWithRecord(decision *decision.Decision, actionRecord *logger.DecisionAction) error {
 	logger.Infof("  📈 开多仓: %s", decision.Symbol)
 
+	// 只管理持仓模式禁止新开仓
+	if err := at.checkManageOnly(); err != nil {
+		return err
+	}
+
+	// 交易暂停窗口（重大事件前后）禁止新开仓
+	if err := at.checkBlackout(time.Now()); err != nil {
+		return err
+	}
+
+	// 稳定币脱锚保护：脱锚期间禁止新开仓
+	if err := at.checkStablecoinPeg(); err != nil {
+		return err
+	}
+
+	// 行情中断期间禁止新开仓
+	if err := at.checkDataOutage(); err != nil {
+		return err
+	}
+
+	// 买卖价差过大时拒绝开仓
+	if err := at.checkSpread(decision.Symbol); err != nil {
+		return err
+	}
+
+	// 短期内对同一币种同方向刚开过或平过仓时，需要更高信心度
+	if err := at.checkChurnGuard(decision, actionRecord); err != nil {
+		return err
+	}
+
+	// 距同一币种上次开平仓需过去足够的K线
+	if err := at.checkMinBarsSinceTrade(decision, actionRecord, time.Now()); err != nil {
+		return err
+	}
+
+	// 开仓方向的信号需已持续足够的K线，减少假信号
+	if err := at.checkEntryConfirmation(decision, actionRecord); err != nil {
+		return err
+	}
+
+	// 周期中途失败后重试或AI重复给出已成交的同一开仓时拒绝，防止持仓查询滞后导致仓位翻倍
+	if err := at.checkDuplicateIntent(decision, actionRecord, time.Now()); err != nil {
+		return err
+	}
+
+	// 按净值百分比给出的仓位以执行时净值换算为金额（在风控收紧之前）
+	if err := at.resolvePositionSize(decision, actionRecord); err != nil {
+		return err
+	}
+
+	// 按信心度确定仓位（启用时）
+	if err := at.applyConfidenceSizing(decision, actionRecord); err != nil {
+		return err
+	}
+
+	// 亏损后降仓冷却期间按系数缩小仓位
+	at.applyLossCooldown(decision, actionRecord, time.Now())
+
+	// 交易所币种杠杆上限
+	at.applySymbolMaxLeverage(decision, actionRecord)
+
+	// 动态风控：禁止开仓或收紧仓位/杠杆
+	if err := at.enforceRiskLimits(decision); err != nil {
+		return err
+	}
+
+	// 单币种资金分配上限：在动态风控之后执行，两者取更严格者
+	if err := at.enforceAllocationBudget(decision, actionRecord); err != nil {
+		return err
+	}
+
+	// 对冲策略：同币种已有反向持仓时拒绝开仓或先反手平仓
+	if err := at.enforceHedgePolicy(decision, actionRecord); err != nil {
+		return err
+	}
+
 	// ⚠️ 关键：检查是否已有同币种同方向持仓，如果有则拒绝开仓（防止仓位叠加超限）
 	positions, err := at.trader.GetPositions()
 	if err == nil {
 		for _, pos := range positions {
 			if pos["symbol"] == decision.Symbol && pos["side"] == "long" {
-				return fmt.Errorf("❌ %s 已有多仓，拒绝开仓以防止仓位叠加超限。如需换仓，请先给出 close_long 决策", decision.Symbol)
+				return reject(RejectPositionExists, fmt.Errorf("❌ %s 已有多仓，拒绝开仓以防止仓位叠加超限。如需换仓，请先给出 close_long 决策", decision.Symbol))
 			}
 		}
 	}
 
+	// 用户级最大同时持仓数：占用名额直到下单结束，避免同一用户的多个交易员同时开仓超限
+	release, err := at.acquireOpenSlot(decision.Symbol, "long")
+	if err != nil {
+		return err
+	}
+	defer release()
+
 	// 获取当前价格
-	marketData, err := market.Get(decision.Symbol)
+	marketData, err := at.getMarketData(decision.Symbol)
 	if err != nil {
 		return err
 	}
@@ -907,6 +1470,13 @@ func (at *AutoTrader) executeOpenLongWithRecord(decision *decision.Decision, act
 		availableBalance = avail
 	}
 
+	// 可用余额为负（全仓浮亏已超过空闲余额）时不能开新仓，也不能按可用余额反推缩小仓位
+	if availableBalance <= 0 {
+		stablecoinUnit := at.getStablecoinUnit()
+		return reject(RejectInsufficientMargin, fmt.Errorf("❌ 保证金不足: 可用余额为 %.2f %s（缺口 %.2f %s），暂停开仓",
+			availableBalance, stablecoinUnit, math.Max(0, -availableBalance), stablecoinUnit))
+	}
+
 	// 手续费估算（Taker费率 0.04%）
 	estimatedFee := decision.PositionSizeUSD * 0.0004
 	totalRequired := requiredMargin + estimatedFee
@@ -941,8 +1511,8 @@ func (at *AutoTrader) executeOpenLongWithRecord(decision *decision.Decision, act
 				originalSize, decision.PositionSizeUSD, stablecoinUnit, excessPercent)
 		} else {
 			stablecoinUnit := at.getStablecoinUnit()
-			return fmt.Errorf("❌ 保证金不足: 需要 %.2f %s（保证金 %.2f + 手续费 %.2f），可用 %.2f %s",
-				totalRequired, stablecoinUnit, requiredMargin, estimatedFee, availableBalance, stablecoinUnit)
+			return reject(RejectInsufficientMargin, fmt.Errorf("❌ 保证金不足: 需要 %.2f %s（保证金 %.2f + 手续费 %.2f），可用 %.2f %s",
+				totalRequired, stablecoinUnit, requiredMargin, estimatedFee, availableBalance, stablecoinUnit))
 		}
 	}
 
@@ -952,8 +1522,13 @@ func (at *AutoTrader) executeOpenLongWithRecord(decision *decision.Decision, act
 		// 继续执行，不影响交易
 	}
 
+	// 给出限价时挂限价单（仅模拟仓支持），价格到达限价后成交
+	if placed, err := at.placeLimitOpen(decision, "LONG", marketData.CurrentPrice, actionRecord); placed || err != nil {
+		return err
+	}
+
 	// 开仓
-	order, err := at.trader.OpenLong(decision.Symbol, quantity, decision.Leverage)
+	order, err := at.openLong(decision.Symbol, quantity, decision.PositionSizeUSD, decision.Leverage, actionRecord.ClientOrderID, at.decisionRef(decision.Symbol, marketData.CurrentPrice))
 	if err != nil {
 		return err
 	}
@@ -963,6 +1538,8 @@ func (at *AutoTrader) executeOpenLongWithRecord(decision *decision.Decision, act
 		actionRecord.OrderID = orderID
 	}
 
+	actionRecord.RealizedSizeUSD = actionRecord.Quantity * actionRecord.Price
+	quantity = applyQuoteFill(order, quantity, actionRecord)
 	logger.Infof("  ✓ 开仓成功，订单ID: %v, 数量: %.4f", order["orderId"], quantity)
 
 	// 记录开仓时间
@@ -976,6 +1553,8 @@ func (at *AutoTrader) executeOpenLongWithRecord(decision *decision.Decision, act
 	if err := at.trader.SetTakeProfit(decision.Symbol, "LONG", quantity, decision.TakeProfit); err != nil {
 		logger.Warnf("  ⚠ 设置止盈失败: %v", err)
 	}
+	actionRecord.PositionID = at.setPositionMeta(decision.Symbol, "long", decision.StopLoss, decision.TakeProfit, decision.Note)
+	at.intents.record(actionRecord.IntentKey, time.Now())
 
 	return nil
 }
@@ -984,18 +1563,101 @@ func (at *AutoTrader) executeOpenLongWithRecord(decision *decision.Decision, act
 func (at *AutoTrader) executeOpenShortWithRecord(decision *decision.Decision, actionRecord *logger.DecisionAction) error {
 	logger.Infof("  📉 开空仓: %s", decision.Symbol)
 
+	// 只管理持仓模式禁止新开仓
+	if err := at.checkManageOnly(); err != nil {
+		return err
+	}
+
+	// 交易暂停窗口（重大事件前后）禁止新开仓
+	if err := at.checkBlackout(time.Now()); err != nil {
+		return err
+	}
+
+	// 稳定币脱锚保护：脱锚期间禁止新开仓
+	if err := at.checkStablecoinPeg(); err != nil {
+		return err
+	}
+
+	// 行情中断期间禁止新开仓
+	if err := at.checkDataOutage(); err != nil {
+		return err
+	}
+
+	// 买卖价差过大时拒绝开仓
+	if err := at.checkSpread(decision.Symbol); err != nil {
+		return err
+	}
+
+	// 短期内对同一币种同方向刚开过或平过仓时，需要更高信心度
+	if err := at.checkChurnGuard(decision, actionRecord); err != nil {
+		return err
+	}
+
+	// 距同一币种上次开平仓需过去足够的K线
+	if err := at.checkMinBarsSinceTrade(decision, actionRecord, time.Now()); err != nil {
+		return err
+	}
+
+	// 开仓方向的信号需已持续足够的K线，减少假信号
+	if err := at.checkEntryConfirmation(decision, actionRecord); err != nil {
+		return err
+	}
+
+	// 周期中途失败后重试或AI重复给出已成交的同一开仓时拒绝，防止持仓查询滞后导致仓位翻倍
+	if err := at.checkDuplicateIntent(decision, actionRecord, time.Now()); err != nil {
+		return err
+	}
+
+	// 按净值百分比给出的仓位以执行时净值换算为金额（在风控收紧之前）
+	if err := at.resolvePositionSize(decision, actionRecord); err != nil {
+		return err
+	}
+
+	// 按信心度确定仓位（启用时）
+	if err := at.applyConfidenceSizing(decision, actionRecord); err != nil {
+		return err
+	}
+
+	// 亏损后降仓冷却期间按系数缩小仓位
+	at.applyLossCooldown(decision, actionRecord, time.Now())
+
+	// 交易所币种杠杆上限
+	at.applySymbolMaxLeverage(decision, actionRecord)
+
+	// 动态风控：禁止开仓或收紧仓位/杠杆
+	if err := at.enforceRiskLimits(decision); err != nil {
+		return err
+	}
+
+	// 单币种资金分配上限：在动态风控之后执行，两者取更严格者
+	if err := at.enforceAllocationBudget(decision, actionRecord); err != nil {
+		return err
+	}
+
+	// 对冲策略：同币种已有反向持仓时拒绝开仓或先反手平仓
+	if err := at.enforceHedgePolicy(decision, actionRecord); err != nil {
+		return err
+	}
+
 	// ⚠️ 关键：检查是否已有同币种同方向持仓，如果有则拒绝开仓（防止仓位叠加超限）
 	positions, err := at.trader.GetPositions()
 	if err == nil {
 		for _, pos := range positions {
 			if pos["symbol"] == decision.Symbol && pos["side"] == "short" {
-				return fmt.Errorf("❌ %s 已有空仓，拒绝开仓以防止仓位叠加超限。如需换仓，请先给出 close_short 决策", decision.Symbol)
+				return reject(RejectPositionExists, fmt.Errorf("❌ %s 已有空仓，拒绝开仓以防止仓位叠加超限。如需换仓，请先给出 close_short 决策", decision.Symbol))
 			}
 		}
 	}
 
+	// 用户级最大同时持仓数：占用名额直到下单结束，避免同一用户的多个交易员同时开仓超限
+	release, err := at.acquireOpenSlot(decision.Symbol, "short")
+	if err != nil {
+		return err
+	}
+	defer release()
+
 	// 获取当前价格
-	marketData, err := market.Get(decision.Symbol)
+	marketData, err := at.getMarketData(decision.Symbol)
 	if err != nil {
 		return err
 	}
@@ -1017,6 +1679,13 @@ func (at *AutoTrader) executeOpenShortWithRecord(decision *decision.Decision, ac
 		availableBalance = avail
 	}
 
+	// 可用余额为负（全仓浮亏已超过空闲余额）时不能开新仓，也不能按可用余额反推缩小仓位
+	if availableBalance <= 0 {
+		stablecoinUnit := at.getStablecoinUnit()
+		return reject(RejectInsufficientMargin, fmt.Errorf("❌ 保证金不足: 可用余额为 %.2f %s（缺口 %.2f %s），暂停开仓",
+			availableBalance, stablecoinUnit, math.Max(0, -availableBalance), stablecoinUnit))
+	}
+
 	// 手续费估算（Taker费率 0.04%）
 	estimatedFee := decision.PositionSizeUSD * 0.0004
 	totalRequired := requiredMargin + estimatedFee
@@ -1051,8 +1720,8 @@ func (at *AutoTrader) executeOpenShortWithRecord(decision *decision.Decision, ac
 				originalSize, decision.PositionSizeUSD, stablecoinUnit, excessPercent)
 		} else {
 			stablecoinUnit := at.getStablecoinUnit()
-			return fmt.Errorf("❌ 保证金不足: 需要 %.2f %s（保证金 %.2f + 手续费 %.2f），可用 %.2f %s",
-				totalRequired, stablecoinUnit, requiredMargin, estimatedFee, availableBalance, stablecoinUnit)
+			return reject(RejectInsufficientMargin, fmt.Errorf("❌ 保证金不足: 需要 %.2f %s（保证金 %.2f + 手续费 %.2f），可用 %.2f %s",
+				totalRequired, stablecoinUnit, requiredMargin, estimatedFee, availableBalance, stablecoinUnit))
 		}
 	}
 
@@ -1062,8 +1731,13 @@ func (at *AutoTrader) executeOpenShortWithRecord(decision *decision.Decision, ac
 		// 继续执行，不影响交易
 	}
 
+	// 给出限价时挂限价单（仅模拟仓支持），价格到达限价后成交
+	if placed, err := at.placeLimitOpen(decision, "SHORT", marketData.CurrentPrice, actionRecord); placed || err != nil {
+		return err
+	}
+
 	// 开仓
-	order, err := at.trader.OpenShort(decision.Symbol, quantity, decision.Leverage)
+	order, err := at.openShort(decision.Symbol, quantity, decision.PositionSizeUSD, decision.Leverage, actionRecord.ClientOrderID, at.decisionRef(decision.Symbol, marketData.CurrentPrice))
 	if err != nil {
 		return err
 	}
@@ -1073,6 +1747,8 @@ func (at *AutoTrader) executeOpenShortWithRecord(decision *decision.Decision, ac
 		actionRecord.OrderID = orderID
 	}
 
+	actionRecord.RealizedSizeUSD = actionRecord.Quantity * actionRecord.Price
+	quantity = applyQuoteFill(order, quantity, actionRecord)
 	logger.Infof("  ✓ 开仓成功，订单ID: %v, 数量: %.4f", order["orderId"], quantity)
 
 	// 记录开仓时间
@@ -1086,6 +1762,131 @@ func (at *AutoTrader) executeOpenShortWithRecord(decision *decision.Decision, ac
 	if err := at.trader.SetTakeProfit(decision.Symbol, "SHORT", quantity, decision.TakeProfit); err != nil {
 		logger.Warnf("  ⚠ 设置止盈失败: %v", err)
 	}
+	actionRecord.PositionID = at.setPositionMeta(decision.Symbol, "short", decision.StopLoss, decision.TakeProfit, decision.Note)
+	at.intents.record(actionRecord.IntentKey, time.Now())
+
+	return nil
+}
+
+// executeAddToPositionWithRecord 对已有持仓加仓并记录详细信息（不受重复开仓意图检查限制）
+func (at *AutoTrader) executeAddToPositionWithRecord(decision *decision.Decision, actionRecord *logger.DecisionAction) error {
+	logger.Infof("  ➕ 加仓: %s", decision.Symbol)
+
+	// 加仓与开仓一样增加风险敞口，同样受只管理持仓模式、暂停窗口、脱锚、价差和风控限制
+	if err := at.checkManageOnly(); err != nil {
+		return err
+	}
+	if err := at.checkBlackout(time.Now()); err != nil {
+		return err
+	}
+	if err := at.checkStablecoinPeg(); err != nil {
+		return err
+	}
+	if err := at.checkDataOutage(); err != nil {
+		return err
+	}
+	if err := at.checkSpread(decision.Symbol); err != nil {
+		return err
+	}
+	if err := at.checkMinBarsSinceTrade(decision, actionRecord, time.Now()); err != nil {
+		return err
+	}
+
+	positions, err := at.trader.GetPositions()
+	if err != nil {
+		return fmt.Errorf("获取持仓失败: %w", err)
+	}
+	targetPosition := at.findTargetPosition(positions, decision)
+	if targetPosition == nil {
+		return fmt.Errorf("❌ %s 没有持仓，无法加仓。新开仓请使用 open_long 或 open_short", decision.Symbol)
+	}
+	side, _ := targetPosition["side"].(string)
+	side = strings.ToLower(side)
+	actionRecord.PositionID = at.positionIDFor(decision.Symbol, side)
+
+	// 沿用持仓杠杆（风控可能进一步收紧）
+	if decision.Leverage <= 0 {
+		if lev, ok := targetPosition["leverage"].(float64); ok && lev > 0 {
+			decision.Leverage = int(lev)
+		} else {
+			decision.Leverage = 1
+		}
+	}
+	actionRecord.Leverage = decision.Leverage
+
+	if err := at.resolvePositionSize(decision, actionRecord); err != nil {
+		return err
+	}
+	if err := at.applyConfidenceSizing(decision, actionRecord); err != nil {
+		return err
+	}
+	at.applyLossCooldown(decision, actionRecord, time.Now())
+	at.applySymbolMaxLeverage(decision, actionRecord)
+	if err := at.enforceRiskLimits(decision); err != nil {
+		return err
+	}
+	if err := at.enforceAllocationBudget(decision, actionRecord); err != nil {
+		return err
+	}
+
+	marketData, err := at.getMarketData(decision.Symbol)
+	if err != nil {
+		return err
+	}
+	quantity := decision.PositionSizeUSD / marketData.CurrentPrice
+	actionRecord.Quantity = quantity
+	actionRecord.Price = marketData.CurrentPrice
+
+	balance, err := at.trader.GetBalance()
+	if err != nil {
+		return fmt.Errorf("获取账户余额失败: %w", err)
+	}
+	availableBalance, _ := balance["availableBalance"].(float64)
+	requiredMargin := decision.PositionSizeUSD / float64(decision.Leverage)
+	estimatedFee := decision.PositionSizeUSD * 0.0004
+	if requiredMargin+estimatedFee > availableBalance {
+		stablecoinUnit := at.getStablecoinUnit()
+		return reject(RejectInsufficientMargin, fmt.Errorf("❌ 保证金不足: 加仓需要 %.2f %s（保证金 %.2f + 手续费 %.2f），可用 %.2f %s",
+			requiredMargin+estimatedFee, stablecoinUnit, requiredMargin, estimatedFee, availableBalance, stablecoinUnit))
+	}
+
+	var order map[string]interface{}
+	ref := at.decisionRef(decision.Symbol, marketData.CurrentPrice)
+	if side == "long" {
+		order, err = at.openLong(decision.Symbol, quantity, decision.PositionSizeUSD, decision.Leverage, actionRecord.ClientOrderID, ref)
+	} else {
+		order, err = at.openShort(decision.Symbol, quantity, decision.PositionSizeUSD, decision.Leverage, actionRecord.ClientOrderID, ref)
+	}
+	if err != nil {
+		return err
+	}
+	if orderID, ok := order["orderId"].(int64); ok {
+		actionRecord.OrderID = orderID
+	}
+	actionRecord.RealizedSizeUSD = actionRecord.Quantity * actionRecord.Price
+	quantity = applyQuoteFill(order, quantity, actionRecord)
+	logger.Infof("  ✓ 加仓成功，订单ID: %v, 数量: %.4f", order["orderId"], quantity)
+
+	// 给出新止损止盈时按加仓后的总数量重新设置
+	positionAmt, _ := targetPosition["positionAmt"].(float64)
+	totalQuantity := math.Abs(positionAmt) + quantity
+	positionSide := strings.ToUpper(side)
+	if decision.StopLoss > 0 {
+		if err := at.trader.CancelStopLossOrders(decision.Symbol); err != nil {
+			logger.Warnf("  ⚠ 取消旧止损单失败: %v", err)
+		}
+		if err := at.trader.SetStopLoss(decision.Symbol, positionSide, totalQuantity, decision.StopLoss); err != nil {
+			logger.Warnf("  ⚠ 设置止损失败: %v", err)
+		}
+	}
+	if decision.TakeProfit > 0 {
+		if err := at.trader.CancelTakeProfitOrders(decision.Symbol); err != nil {
+			logger.Warnf("  ⚠ 取消旧止盈单失败: %v", err)
+		}
+		if err := at.trader.SetTakeProfit(decision.Symbol, positionSide, totalQuantity, decision.TakeProfit); err != nil {
+			logger.Warnf("  ⚠ 设置止盈失败: %v", err)
+		}
+	}
 
 	return nil
 }
@@ -1094,19 +1895,29 @@ func (at *AutoTrader) executeOpenShortWithRecord(decision *decision.Decision, ac
 func (at *AutoTrader) executeCloseLongWithRecord(decision *decision.Decision, actionRecord *logger.DecisionAction) error {
 	logger.Infof("  🔄 平多仓: %s", decision.Symbol)
 
+	// 距同一币种上次开平仓需过去足够的K线（避免刚开仓就平仓）
+	if err := at.checkMinBarsSinceTrade(decision, actionRecord, time.Now()); err != nil {
+		return err
+	}
+
 	// 获取当前价格
-	marketData, err := market.Get(decision.Symbol)
+	marketData, err := at.getMarketData(decision.Symbol)
 	if err != nil {
 		return err
 	}
 	actionRecord.Price = marketData.CurrentPrice
 
 	// 平仓
-	order, err := at.trader.CloseLong(decision.Symbol, 0) // 0 = 全部平仓
+	order, err := at.closeLong(decision.Symbol, 0, actionRecord.ClientOrderID, at.decisionRef(decision.Symbol, marketData.CurrentPrice)) // 0 = 全部平仓
 	if err != nil {
 		return err
 	}
 
+	if id := at.closePositionMeta(decision.Symbol, "long"); id != "" {
+		actionRecord.PositionID = id
+	}
+	at.intents.clearPosition(at.id, decision.Symbol, "long")
+
 	// 记录订单ID
 	if orderID, ok := order["orderId"].(int64); ok {
 		actionRecord.OrderID = orderID
@@ -1120,19 +1931,29 @@ func (at *AutoTrader) executeCloseLongWithRecord(decision *decision.Decision, ac
 func (at *AutoTrader) executeCloseShortWithRecord(decision *decision.Decision, actionRecord *logger.DecisionAction) error {
 	logger.Infof("  🔄 平空仓: %s", decision.Symbol)
 
+	// 距同一币种上次开平仓需过去足够的K线（避免刚开仓就平仓）
+	if err := at.checkMinBarsSinceTrade(decision, actionRecord, time.Now()); err != nil {
+		return err
+	}
+
 	// 获取当前价格
-	marketData, err := market.Get(decision.Symbol)
+	marketData, err := at.getMarketData(decision.Symbol)
 	if err != nil {
 		return err
 	}
 	actionRecord.Price = marketData.CurrentPrice
 
 	// 平仓
-	order, err := at.trader.CloseShort(decision.Symbol, 0) // 0 = 全部平仓
+	order, err := at.closeShort(decision.Symbol, 0, actionRecord.ClientOrderID, at.decisionRef(decision.Symbol, marketData.CurrentPrice)) // 0 = 全部平仓
 	if err != nil {
 		return err
 	}
 
+	if id := at.closePositionMeta(decision.Symbol, "short"); id != "" {
+		actionRecord.PositionID = id
+	}
+	at.intents.clearPosition(at.id, decision.Symbol, "short")
+
 	// 记录订单ID
 	if orderID, ok := order["orderId"].(int64); ok {
 		actionRecord.OrderID = orderID
@@ -1147,7 +1968,7 @@ func (at *AutoTrader) executeUpdateStopLossWithRecord(decision *decision.Decisio
 	logger.Infof("  🎯 调整止损: %s → %.2f", decision.Symbol, decision.NewStopLoss)
 
 	// 获取当前价格
-	marketData, err := market.Get(decision.Symbol)
+	marketData, err := at.getMarketData(decision.Symbol)
 	if err != nil {
 		return err
 	}
@@ -1160,15 +1981,7 @@ func (at *AutoTrader) executeUpdateStopLossWithRecord(decision *decision.Decisio
 	}
 
 	// 查找目标持仓
-	var targetPosition map[string]interface{}
-	for _, pos := range positions {
-		symbol, _ := pos["symbol"].(string)
-		posAmt, _ := pos["positionAmt"].(float64)
-		if symbol == decision.Symbol && posAmt != 0 {
-			targetPosition = pos
-			break
-		}
-	}
+	targetPosition := at.findTargetPosition(positions, decision)
 
 	if targetPosition == nil {
 		return fmt.Errorf("持仓不存在: %s", decision.Symbol)
@@ -1176,6 +1989,7 @@ func (at *AutoTrader) executeUpdateStopLossWithRecord(decision *decision.Decisio
 
 	// 获取持仓方向和数量
 	side, _ := targetPosition["side"].(string)
+	actionRecord.PositionID = at.positionIDFor(decision.Symbol, side)
 	positionSide := strings.ToUpper(side)
 	positionAmt, _ := targetPosition["positionAmt"].(float64)
 
@@ -1222,6 +2036,8 @@ func (at *AutoTrader) executeUpdateStopLossWithRecord(decision *decision.Decisio
 		return fmt.Errorf("修改止损失败: %w", err)
 	}
 
+	at.updatePositionMetaStops(decision.Symbol, side, decision.NewStopLoss, 0)
+
 	logger.Infof("  ✓ 止损已调整: %.2f (当前价格: %.2f)", decision.NewStopLoss, marketData.CurrentPrice)
 	return nil
 }
@@ -1231,7 +2047,7 @@ func (at *AutoTrader) executeUpdateTakeProfitWithRecord(decision *decision.Decis
 	logger.Infof("  🎯 调整止盈: %s → %.2f", decision.Symbol, decision.NewTakeProfit)
 
 	// 获取当前价格
-	marketData, err := market.Get(decision.Symbol)
+	marketData, err := at.getMarketData(decision.Symbol)
 	if err != nil {
 		return err
 	}
@@ -1244,15 +2060,7 @@ func (at *AutoTrader) executeUpdateTakeProfitWithRecord(decision *decision.Decis
 	}
 
 	// 查找目标持仓
-	var targetPosition map[string]interface{}
-	for _, pos := range positions {
-		symbol, _ := pos["symbol"].(string)
-		posAmt, _ := pos["positionAmt"].(float64)
-		if symbol == decision.Symbol && posAmt != 0 {
-			targetPosition = pos
-			break
-		}
-	}
+	targetPosition := at.findTargetPosition(positions, decision)
 
 	if targetPosition == nil {
 		return fmt.Errorf("持仓不存在: %s", decision.Symbol)
@@ -1260,6 +2068,7 @@ func (at *AutoTrader) executeUpdateTakeProfitWithRecord(decision *decision.Decis
 
 	// 获取持仓方向和数量
 	side, _ := targetPosition["side"].(string)
+	actionRecord.PositionID = at.positionIDFor(decision.Symbol, side)
 	positionSide := strings.ToUpper(side)
 	positionAmt, _ := targetPosition["positionAmt"].(float64)
 
@@ -1306,6 +2115,8 @@ func (at *AutoTrader) executeUpdateTakeProfitWithRecord(decision *decision.Decis
 		return fmt.Errorf("修改止盈失败: %w", err)
 	}
 
+	at.updatePositionMetaStops(decision.Symbol, side, 0, decision.NewTakeProfit)
+
 	logger.Infof("  ✓ 止盈已调整: %.2f (当前价格: %.2f)", decision.NewTakeProfit, marketData.CurrentPrice)
 	return nil
 }
@@ -1318,9 +2129,12 @@ func (at *AutoTrader) executePartialCloseWithRecord(decision *decision.Decision,
 	if decision.ClosePercentage <= 0 || decision.ClosePercentage > 100 {
 		return fmt.Errorf("平仓百分比必须在 0-100 之间，当前: %.1f", decision.ClosePercentage)
 	}
+	if err := at.checkMinBarsSinceTrade(decision, actionRecord, time.Now()); err != nil {
+		return err
+	}
 
 	// 获取当前价格
-	marketData, err := market.Get(decision.Symbol)
+	marketData, err := at.getMarketData(decision.Symbol)
 	if err != nil {
 		return err
 	}
@@ -1333,15 +2147,7 @@ func (at *AutoTrader) executePartialCloseWithRecord(decision *decision.Decision,
 	}
 
 	// 查找目标持仓
-	var targetPosition map[string]interface{}
-	for _, pos := range positions {
-		symbol, _ := pos["symbol"].(string)
-		posAmt, _ := pos["positionAmt"].(float64)
-		if symbol == decision.Symbol && posAmt != 0 {
-			targetPosition = pos
-			break
-		}
-	}
+	targetPosition := at.findTargetPosition(positions, decision)
 
 	if targetPosition == nil {
 		return fmt.Errorf("持仓不存在: %s", decision.Symbol)
@@ -1349,6 +2155,7 @@ func (at *AutoTrader) executePartialCloseWithRecord(decision *decision.Decision,
 
 	// 获取持仓方向和数量
 	side, _ := targetPosition["side"].(string)
+	actionRecord.PositionID = at.positionIDFor(decision.Symbol, side)
 	positionSide := strings.ToUpper(side)
 	positionAmt, _ := targetPosition["positionAmt"].(float64)
 
@@ -1359,10 +2166,11 @@ func (at *AutoTrader) executePartialCloseWithRecord(decision *decision.Decision,
 
 	// 执行平仓
 	var order map[string]interface{}
+	ref := at.decisionRef(decision.Symbol, marketData.CurrentPrice)
 	if positionSide == "LONG" {
-		order, err = at.trader.CloseLong(decision.Symbol, closeQuantity)
+		order, err = at.closeLong(decision.Symbol, closeQuantity, actionRecord.ClientOrderID, ref)
 	} else {
-		order, err = at.trader.CloseShort(decision.Symbol, closeQuantity)
+		order, err = at.closeShort(decision.Symbol, closeQuantity, actionRecord.ClientOrderID, ref)
 	}
 
 	if err != nil {
@@ -1386,6 +2194,11 @@ func (at *AutoTrader) GetID() string {
 	return at.id
 }
 
+// GetUserID 获取trader所属用户ID
+func (at *AutoTrader) GetUserID() string {
+	return at.userID
+}
+
 // GetName 获取trader名称
 func (at *AutoTrader) GetName() string {
 	return at.name
@@ -1406,6 +2219,16 @@ func (at *AutoTrader) SetCustomPrompt(prompt string) {
 	at.customPrompt = prompt
 }
 
+// SetLeverageLimits 设置BTC/ETH和山寨币的杠杆倍数（下个周期生效，值为 0 时保持原值）
+func (at *AutoTrader) SetLeverageLimits(btcEthLeverage, altcoinLeverage int) {
+	if btcEthLeverage > 0 {
+		at.config.BTCETHLeverage = btcEthLeverage
+	}
+	if altcoinLeverage > 0 {
+		at.config.AltcoinLeverage = altcoinLeverage
+	}
+}
+
 // SetOverrideBasePrompt 设置是否覆盖基础prompt
 func (at *AutoTrader) SetOverrideBasePrompt(override bool) {
 	at.overrideBasePrompt = override
@@ -1437,21 +2260,56 @@ func (at *AutoTrader) GetStatus() map[string]interface{} {
 		aiProvider = "Custom"
 	}
 
+	// 下一次定时周期倒计时（服务端计算，未运行时为空）
+	var nextCycleAtStr string
+	secondsRemaining := 0
+	if nextCycleAt := at.GetNextCycleAt(); !nextCycleAt.IsZero() {
+		nextCycleAtStr = nextCycleAt.Format(time.RFC3339)
+		if remaining := time.Until(nextCycleAt); remaining > 0 {
+			secondsRemaining = int(remaining.Seconds())
+		}
+	}
+
+	// 行为异常状态（未启用异常检测时为空）
+	anomalyMetrics := []string{}
+	if at.config.Anomaly.Enabled {
+		anomalyMetrics = at.activeAnomalyMetrics()
+	}
+
 	return map[string]interface{}{
-		"trader_id":       at.id,
-		"trader_name":     at.name,
-		"ai_model":        at.aiModel,
-		"exchange":        at.exchange,
-		"is_running":      at.isRunning,
-		"start_time":      at.startTime.Format(time.RFC3339),
-		"runtime_minutes": int(time.Since(at.startTime).Minutes()),
-		"call_count":      at.callCount,
-		"initial_balance": at.initialBalance,
-		"scan_interval":   at.config.ScanInterval.String(),
-		"stop_until":      at.stopUntil.Format(time.RFC3339),
-		"last_reset_time": at.lastResetTime.Format(time.RFC3339),
-		"ai_provider":     aiProvider,
+		"trader_id":         at.id,
+		"trader_name":       at.name,
+		"ai_model":          at.aiModel,
+		"exchange":          at.exchange,
+		"is_running":        at.isRunning,
+		"start_time":        at.startTime.Format(time.RFC3339),
+		"runtime_minutes":   int(time.Since(at.startTime).Minutes()),
+		"call_count":        at.callCount,
+		"initial_balance":   at.initialBalance,
+		"scan_interval":     at.config.ScanInterval.String(),
+		"stop_until":        at.stopUntil.Format(time.RFC3339),
+		"last_reset_time":   at.lastResetTime.Format(time.RFC3339),
+		"ai_provider":       aiProvider,
+		"next_cycle_at":     nextCycleAtStr,
+		"seconds_remaining": secondsRemaining,
+		"anomaly_active":    len(anomalyMetrics) > 0,
+		"anomaly_metrics":   anomalyMetrics,
+		"slippage_warning":  at.GetSlippageSummary().Warning,
+		"position_mode":     at.PositionMode(),
+		"hedge_policy":      at.config.HedgePolicy,
+		"manage_only":       at.ManageOnly(),
+		"hard_drawdown_pct": at.config.HardDrawdownPct,
+		"disabled_reason":   at.DisabledReason(),
+		"margin_asset":      at.getStablecoinUnit(), // 账户币种（余额和盈亏的单位）
+	}
+}
+
+// PositionMode 交易所账户的持仓模式（hedge/one_way，交易器不区分持仓模式时为空）
+func (at *AutoTrader) PositionMode() string {
+	if t, ok := at.trader.(PositionModeTrader); ok {
+		return t.PositionMode()
 	}
+	return ""
 }
 
 // GetAccountInfo 获取账户信息（用于API）
@@ -1479,6 +2337,10 @@ func (at *AutoTrader) GetAccountInfo() (map[string]interface{}, error) {
 	// Total Equity = 钱包余额 + 未实现盈亏
 	totalEquity := totalWalletBalance + totalUnrealizedProfit
 
+	marginAsset := at.getStablecoinUnit()
+	nativeEquity, _ := marginAssetEquity(balance, marginAsset, totalEquity)
+	assetBalances, _ := balance["assetBalances"].([]AssetBalance)
+
 	// 获取持仓计算总保证金
 	positions, err := at.trader.GetPositions()
 	if err != nil {
@@ -1533,6 +2395,11 @@ func (at *AutoTrader) GetAccountInfo() (map[string]interface{}, error) {
 		"position_count":  len(positions),  // 持仓数量
 		"margin_used":     totalMarginUsed, // 保证金占用
 		"margin_used_pct": marginUsedPct,   // 保证金使用率
+
+		// 保证金资产（以上金额均按USD折算）
+		"margin_asset":   marginAsset,
+		"native_equity":  nativeEquity,  // 净值折合保证金资产的原生数量
+		"asset_balances": assetBalances, // 各保证金资产明细
 	}, nil
 }
 
@@ -1616,8 +2483,8 @@ func sortDecisionsByPriority(decisions []decision.Decision) []decision.Decision
 			return 1 // 最高优先级：先平仓（包括部分平仓）
 		case "update_stop_loss", "update_take_profit":
 			return 2 // 调整持仓止盈止损
-		case "open_long", "open_short":
-			return 3 // 次优先级：后开仓
+		case "open_long", "open_short", "add_to_position":
+			return 3 // 次优先级：后开仓（包括加仓）
 		case "hold", "wait":
 			return 4 // 最低优先级：观望
 		default:
@@ -1672,6 +2539,7 @@ func (at *AutoTrader) getCandidateCoins() ([]decision.CandidateCoin, error) {
 				logger.Errorf("❌ [%s] 获取AI500+OI Top币种池失败: %v", at.name, err)
 				return nil, fmt.Errorf("获取合并币种池失败: %w", err)
 			}
+			mergedPool = at.CapCoinPool(mergedPool)
 
 			// 构建候选币种列表（包含来源信息）
 			for _, symbol := range mergedPool.AllSymbols {
@@ -1713,10 +2581,16 @@ func (at *AutoTrader) getCandidateCoins() ([]decision.CandidateCoin, error) {
 	}
 }
 
-// getStablecoinUnit 根据交易所类型返回稳定币单位
+// getStablecoinUnit 返回保证金资产单位（未配置时根据交易所类型推断）
 func (at *AutoTrader) getStablecoinUnit() string {
+	if at.marginAsset != "" {
+		return at.marginAsset
+	}
+	if pt, ok := at.trader.(*PaperTrader); ok {
+		return pt.MarginAsset()
+	}
 	switch at.exchange {
-	case "hyperliquid", "paper":
+	case "hyperliquid":
 		return "USDC"
 	case "binance", "aster":
 		return "USDT"
@@ -1726,14 +2600,14 @@ func (at *AutoTrader) getStablecoinUnit() string {
 }
 
 // normalizeSymbol 标准化币种符号（确保以USDT结尾）
-// 注意：虽然 Hyperliquid 和 Paper Trading 使用 USDC，但交易对格式统一使用 USDT 后缀
+// 注意：虽然 Hyperliquid（以及配置为 USDC 的账户）使用 USDC，但交易对格式统一使用 USDT 后缀
 // 例如：BTCUSDT 在 Hyperliquid 内部会转换为 BTC，但符号格式保持一致
 func normalizeSymbol(symbol string) string {
 	// 转为大写
 	symbol = strings.ToUpper(strings.TrimSpace(symbol))
 
 	// 确保以USDT结尾
-	// 注意：即使交易所使用 USDC（如 Hyperliquid、Paper Trading），
+	// 注意：即使账户使用 USDC 保证金（如 Hyperliquid），
 	// 交易对格式仍然使用 USDT 后缀以保持一致性
 	if !strings.HasSuffix(symbol, "USDT") {
 		symbol = symbol + "USDT"
@@ -1835,10 +2709,12 @@ func (at *AutoTrader) checkPositionDrawdown() {
 				symbol, side, currentPnLPct, peakPnLPct, drawdownPct)
 
 			// 执行平仓
-			if err := at.emergencyClosePosition(symbol, side); err != nil {
+			if err := at.emergencyClosePosition(symbol, side, markPrice); err != nil {
 				logger.Errorf("❌ 回撤平仓失败 (%s %s): %v", symbol, side, err)
 			} else {
 				logger.Infof("✅ 回撤平仓成功: %s %s", symbol, side)
+				at.emitWebhook(WebhookEvent{Event: WebhookEventRisk, Symbol: symbol, Side: side, Price: markPrice,
+					Reason: fmt.Sprintf("回撤平仓：收益 %.2f%% 自最高 %.2f%% 回撤 %.2f%%", currentPnLPct, peakPnLPct, drawdownPct)})
 				// 平仓后清理该持仓的缓存
 				at.ClearPeakPnLCache(symbol, side)
 			}
@@ -1850,17 +2726,18 @@ func (at *AutoTrader) checkPositionDrawdown() {
 	}
 }
 
-// 紧急平仓函数
-func (at *AutoTrader) emergencyClosePosition(symbol, side string) error {
+// 紧急平仓函数（止损管理触发，markPrice 为触发时的标记价格，作为滑点参考价格）
+func (at *AutoTrader) emergencyClosePosition(symbol, side string, markPrice float64) error {
+	ref := priceRef{Price: markPrice, At: time.Now(), Source: fillSourceStop}
 	switch side {
 	case "long":
-		order, err := at.trader.CloseLong(symbol, 0) // 0 = 全部平仓
+		order, err := at.closeLong(symbol, 0, "", ref) // 0 = 全部平仓
 		if err != nil {
 			return err
 		}
 		logger.Infof("✅ 紧急平多仓成功，订单ID: %v", order["orderId"])
 	case "short":
-		order, err := at.trader.CloseShort(symbol, 0) // 0 = 全部平仓
+		order, err := at.closeShort(symbol, 0, "", ref) // 0 = 全部平仓
 		if err != nil {
 			return err
 		}
@@ -1868,10 +2745,38 @@ func (at *AutoTrader) emergencyClosePosition(symbol, side string) error {
 	default:
 		return fmt.Errorf("未知的持仓方向: %s", side)
 	}
+	at.intents.clearPosition(at.id, symbol, side)
 
 	return nil
 }
 
+// closeAllPositions 平掉所有持仓（label 为触发原因，如 "交易暂停窗口（...）"），返回执行日志
+func (at *AutoTrader) closeAllPositions(label string) []string {
+	positions, err := at.trader.GetPositions()
+	if err != nil {
+		logger.Warnf("⚠️  %s清仓：获取持仓失败: %v", label, err)
+		return []string{fmt.Sprintf("❌ %s清仓失败：获取持仓失败: %v", label, err)}
+	}
+
+	var logs []string
+	for _, pos := range positions {
+		symbol, _ := pos["symbol"].(string)
+		side, _ := pos["side"].(string)
+		markPrice, _ := pos["markPrice"].(float64)
+		if symbol == "" {
+			continue
+		}
+		logger.Infof("🚫 %s：平仓 %s %s", label, symbol, side)
+		if err := at.emergencyClosePosition(symbol, side, markPrice); err != nil {
+			logger.Errorf("❌ %s平仓失败 (%s %s): %v", label, symbol, side, err)
+			logs = append(logs, fmt.Sprintf("❌ %s平仓 %s %s 失败: %v", label, symbol, side, err))
+			continue
+		}
+		logs = append(logs, fmt.Sprintf("✓ %s平仓 %s %s", label, symbol, side))
+	}
+	return logs
+}
+
 // GetPeakPnLCache 获取最高收益缓存
 func (at *AutoTrader) GetPeakPnLCache() map[string]float64 {
 	at.peakPnLCacheMutex.RLock()
@@ -13,6 +13,7 @@ import (
 	"aspen/pool"
 
 	"github.com/agiledragon/gomonkey/v2"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/suite"
 )
 
@@ -325,6 +326,658 @@ func (s *AutoTraderTestSuite) TestGetPositions() {
 	})
 }
 
+func (s *AutoTraderTestSuite) TestGetPositionViews() {
+	s.mockTrader.positions = []map[string]interface{}{
+		{
+			"symbol":           "BTCUSDT",
+			"side":             "long",
+			"entryPrice":       50000.0,
+			"markPrice":        51000.0,
+			"positionAmt":      0.1,
+			"unRealizedProfit": 100.0,
+			"liquidationPrice": 45000.0,
+			"leverage":         10.0,
+		},
+	}
+	s.autoTrader.callCount = 7
+	s.autoTrader.setPositionMeta("BTCUSDT", "long", 49000, 55000, "RSI底背离，开多")
+	s.autoTrader.updatePositionMetaStops("BTCUSDT", "long", 49500, 0)
+
+	views, err := s.autoTrader.GetPositionViews()
+
+	s.NoError(err)
+	s.Require().Equal(1, len(views))
+	view := views[0]
+	s.Equal("BTCUSDT", view.Symbol)
+	s.Equal(49500.0, view.StopLoss)
+	s.Equal(55000.0, view.TakeProfit)
+	s.Equal(7, view.OpenCycle)
+	s.Equal("RSI底背离，开多", view.Note)
+	s.Equal(10, view.Leverage)
+	s.Greater(view.OpenedAt, int64(0))
+	s.Nil(view.FundingPaid)
+}
+
+// fundingMockTrader 能查询资金费的 MockTrader
+type fundingMockTrader struct {
+	*MockTrader
+	since map[string]time.Time
+}
+
+func (t *fundingMockTrader) FundingPaid(symbol string, since time.Time) (float64, error) {
+	t.since[symbol] = since
+	return 1.25, nil
+}
+
+// TestGetPositionViews_MarginAndFunding 测试持仓详情使用交易器返回的实际保证金，并从开仓时间起查询已支付的资金费
+func (s *AutoTraderTestSuite) TestGetPositionViews_MarginAndFunding() {
+	s.mockTrader.positions = []map[string]interface{}{
+		{"symbol": "BTCUSDT", "side": "long", "entryPrice": 50000.0, "markPrice": 51000.0, "positionAmt": 0.1, "leverage": 10.0, "initialMargin": 480.0},
+		{"symbol": "ETHUSDT", "side": "long", "entryPrice": 3000.0, "markPrice": 3000.0, "positionAmt": 1.0, "leverage": 10.0},
+		{"symbol": "ETHUSDT", "side": "short", "entryPrice": 3100.0, "markPrice": 3000.0, "positionAmt": 1.0, "leverage": 10.0},
+	}
+	exchange := &fundingMockTrader{MockTrader: s.mockTrader, since: make(map[string]time.Time)}
+	s.autoTrader.trader = exchange
+	s.autoTrader.setPositionMeta("BTCUSDT", "long", 0, 0, "")
+	s.autoTrader.setPositionMeta("ETHUSDT", "long", 0, 0, "")
+	s.autoTrader.setPositionMeta("ETHUSDT", "short", 0, 0, "")
+
+	views, err := s.autoTrader.GetPositionViews()
+	s.NoError(err)
+	s.Require().Len(views, 3)
+	btc := views[0]
+	s.Equal(480.0, btc.MarginUsed, "使用交易器返回的初始保证金")
+	s.Require().NotNil(btc.FundingPaid)
+	s.Equal(1.25, *btc.FundingPaid)
+	s.Equal(s.autoTrader.positionMeta[positionMetaKey("BTCUSDT", "long")].OpenedAt, exchange.since["BTCUSDT"])
+
+	s.InDelta(300.0, views[1].MarginUsed, 1e-9, "未提供保证金时按标记价格估算")
+	s.Nil(views[1].FundingPaid, "同一币种同时持有多空仓时资金费无法拆分")
+	s.NotContains(exchange.since, "ETHUSDT")
+}
+
+// TestGetPositionViews_TraderDataSource 测试交易员单独配置数据源时，标记价格从该数据源获取而不是全局行情缓存
+func (s *AutoTraderTestSuite) TestGetPositionViews_TraderDataSource() {
+	s.mockTrader.positions = []map[string]interface{}{
+		{
+			"symbol":           "BTCUSDT",
+			"side":             "long",
+			"entryPrice":       50000.0,
+			"markPrice":        51000.0,
+			"positionAmt":      0.1,
+			"unRealizedProfit": 100.0,
+			"leverage":         10.0,
+		},
+	}
+	s.patches.ApplyFunc(market.GetCachedPrice, func(symbol string) (float64, bool) {
+		return 60000.0, true
+	})
+	s.patches.ApplyMethod((*market.APIClient)(nil), "GetCurrentPrice", func(_ *market.APIClient, symbol string) (float64, error) {
+		return 52000.0, nil
+	})
+
+	s.autoTrader.config.DataSource = market.DataSourceHyperliquid
+	views, err := s.autoTrader.GetPositionViews()
+	s.NoError(err)
+	s.Require().Len(views, 1)
+	s.Equal(52000.0, views[0].MarkPrice)
+	s.Equal("source", views[0].PriceSource)
+	s.InDelta(200.0, views[0].UnrealizedPnL, 1e-9)
+
+	s.autoTrader.config.DataSource = ""
+	s.autoTrader.positionViewCache = nil
+	views, err = s.autoTrader.GetPositionViews()
+	s.NoError(err)
+	s.Require().Len(views, 1)
+	s.Equal(60000.0, views[0].MarkPrice)
+	s.Equal("cache", views[0].PriceSource)
+}
+
+// waitCycleDone 等待后台手动周期执行结束
+func (s *AutoTraderTestSuite) waitCycleDone() {
+	s.Eventually(func() bool {
+		s.autoTrader.cycleMutex.Lock()
+		defer s.autoTrader.cycleMutex.Unlock()
+		return !s.autoTrader.cycleRunning
+	}, 2*time.Second, 10*time.Millisecond)
+}
+
+func (s *AutoTraderTestSuite) TestRunNow() {
+	s.autoTrader.isRunning = true
+	s.autoTrader.decisionLogger = logger.NewDecisionLogger(s.T().TempDir())
+	// 风控暂停中：周期会记录暂停并立即返回，避免调用AI
+	s.autoTrader.stopUntil = time.Now().Add(time.Hour)
+	s.autoTrader.rescheduleCh = make(chan struct{}, 1)
+	nextCycleAt := time.Now().Add(2 * time.Minute)
+	s.autoTrader.nextCycleAt = nextCycleAt
+
+	s.Run("未运行时拒绝", func() {
+		s.autoTrader.isRunning = false
+		_, err := s.autoTrader.RunNow("test_user", false)
+		s.ErrorIs(err, ErrTraderNotRunning)
+		s.autoTrader.isRunning = true
+	})
+
+	s.Run("周期执行中返回正在执行的周期编号", func() {
+		s.autoTrader.cycleRunning = true
+		s.autoTrader.runningCycle = 12
+		_, err := s.autoTrader.RunNow("test_user", false)
+		var inProgress *CycleInProgressError
+		s.Require().ErrorAs(err, &inProgress)
+		s.Equal(12, inProgress.CycleNumber)
+		s.autoTrader.cycleRunning = false
+	})
+
+	s.Run("手动触发且不改变定时计划", func() {
+		cycle, err := s.autoTrader.RunNow("test_user", false)
+		s.Require().NoError(err)
+		s.Equal(1, cycle)
+		s.waitCycleDone()
+
+		s.Equal(nextCycleAt, s.autoTrader.GetNextCycleAt())
+		s.Len(s.autoTrader.rescheduleCh, 0)
+
+		records, err := s.autoTrader.decisionLogger.GetLatestRecords(1)
+		s.Require().NoError(err)
+		s.Require().Len(records, 1)
+		s.Equal(TriggerManual, records[0].TriggerType)
+		s.Equal("test_user", records[0].TriggeredBy)
+	})
+
+	s.Run("冷却时间内拒绝", func() {
+		_, err := s.autoTrader.RunNow("test_user", false)
+		var cooldown *ManualTriggerCooldownError
+		s.Require().ErrorAs(err, &cooldown)
+		s.Greater(cooldown.Remaining, time.Duration(0))
+		s.LessOrEqual(cooldown.Remaining, defaultManualTriggerCooldown)
+	})
+
+	s.Run("reschedule 时通知主循环重新计时", func() {
+		s.autoTrader.lastManualTrigger = time.Now().Add(-defaultManualTriggerCooldown)
+		cycle, err := s.autoTrader.RunNow("test_user", true)
+		s.Require().NoError(err)
+		s.Equal(2, cycle)
+		s.waitCycleDone()
+		s.Len(s.autoTrader.rescheduleCh, 1)
+	})
+}
+
+func (s *AutoTraderTestSuite) TestScheduledCycleSkippedWhileInFlight() {
+	s.autoTrader.cycleRunning = true
+	s.autoTrader.runningCycle = 3
+
+	err := s.autoTrader.executeCycle(CycleTrigger{Type: TriggerScheduled})
+
+	var inProgress *CycleInProgressError
+	s.Require().ErrorAs(err, &inProgress)
+	s.Equal(3, inProgress.CycleNumber)
+	s.Equal(0, s.autoTrader.callCount)
+}
+
+func (s *AutoTraderTestSuite) TestEnforceAllocationBudget() {
+	s.autoTrader.config.AllocationBudget = decision.AllocationBudget{DefaultPct: 40}
+	// 已有 BTC 多仓: 0.5 × 50000 / 10x = 2500 保证金
+	s.mockTrader.positions = []map[string]interface{}{
+		{
+			"symbol":           "BTCUSDT",
+			"side":             "long",
+			"entryPrice":       50000.0,
+			"markPrice":        50000.0,
+			"positionAmt":      0.5,
+			"unRealizedProfit": 0.0,
+			"liquidationPrice": 45000.0,
+			"leverage":         10.0,
+		},
+	}
+
+	s.Run("加仓超出上限时收紧仓位并记录调整", func() {
+		// 净值 10100 × 40% = 4040，剩余 1540 保证金 → 最多 15400 仓位
+		d := &decision.Decision{Action: "open_long", Symbol: "BTCUSDT", Leverage: 10, PositionSizeUSD: 30000}
+		record := &logger.DecisionAction{}
+
+		s.Require().NoError(s.autoTrader.enforceAllocationBudget(d, record))
+		s.InDelta(15400.0, d.PositionSizeUSD, 0.01)
+		s.Require().Len(record.Adjustments, 1)
+		s.Contains(record.Adjustments[0], "单币种分配上限 40%")
+	})
+
+	s.Run("未超出上限时不调整", func() {
+		d := &decision.Decision{Action: "open_long", Symbol: "ETHUSDT", Leverage: 5, PositionSizeUSD: 10000}
+		record := &logger.DecisionAction{}
+
+		s.Require().NoError(s.autoTrader.enforceAllocationBudget(d, record))
+		s.Equal(10000.0, d.PositionSizeUSD)
+		s.Empty(record.Adjustments)
+	})
+
+	s.Run("开仓后净值下降超出上限只禁止加仓", func() {
+		s.mockTrader.balance = map[string]interface{}{
+			"totalWalletBalance":    5000.0,
+			"availableBalance":      2500.0,
+			"totalUnrealizedProfit": 0.0,
+		}
+		defer func() {
+			s.mockTrader.balance = map[string]interface{}{
+				"totalWalletBalance":    10000.0,
+				"availableBalance":      8000.0,
+				"totalUnrealizedProfit": 100.0,
+			}
+		}()
+		d := &decision.Decision{Action: "open_short", Symbol: "BTCUSDT", Leverage: 10, PositionSizeUSD: 1000}
+
+		err := s.autoTrader.enforceAllocationBudget(d, &logger.DecisionAction{})
+		s.Require().Error(err)
+		s.Contains(err.Error(), "禁止加仓")
+		// 不会强制平仓，已有持仓保持不变
+		s.Len(s.mockTrader.positions, 1)
+		s.Equal(0.5, s.mockTrader.positions[0]["positionAmt"])
+	})
+
+	s.Run("与动态风控上限取更严格者", func() {
+		tests := []struct {
+			name        string
+			maxPosition float64
+			want        float64
+		}{
+			{name: "动态风控更严格", maxPosition: 5000, want: 5000},
+			{name: "分配上限更严格", maxPosition: 20000, want: 15400},
+		}
+		for _, tt := range tests {
+			s.autoTrader.riskLimits = &decision.RiskLimits{
+				SizeFactor: 1, LeverageFactor: 1,
+				MaxBTCETHPosition: tt.maxPosition, MaxBTCETHLeverage: 10,
+			}
+			d := &decision.Decision{Action: "open_long", Symbol: "BTCUSDT", Leverage: 10, PositionSizeUSD: 30000}
+
+			s.Require().NoError(s.autoTrader.enforceRiskLimits(d), tt.name)
+			s.Require().NoError(s.autoTrader.enforceAllocationBudget(d, &logger.DecisionAction{}), tt.name)
+			s.InDelta(tt.want, d.PositionSizeUSD, 0.01, tt.name)
+		}
+		s.autoTrader.riskLimits = nil
+	})
+
+	s.Run("使用交易器返回的实际保证金", func() {
+		// 开仓后价格上涨，按标记价格估算为 2500，实际初始保证金为 2000 → 剩余 2040 保证金 → 最多 20400 仓位
+		s.mockTrader.positions[0]["initialMargin"] = 2000.0
+		defer delete(s.mockTrader.positions[0], "initialMargin")
+		d := &decision.Decision{Action: "open_long", Symbol: "BTCUSDT", Leverage: 10, PositionSizeUSD: 30000}
+
+		s.Require().NoError(s.autoTrader.enforceAllocationBudget(d, &logger.DecisionAction{}))
+		s.InDelta(20400.0, d.PositionSizeUSD, 0.01)
+	})
+
+	s.Run("未设置分配上限时不限制", func() {
+		s.autoTrader.config.AllocationBudget = decision.AllocationBudget{}
+		defer func() { s.autoTrader.config.AllocationBudget = decision.AllocationBudget{DefaultPct: 40} }()
+		d := &decision.Decision{Action: "open_long", Symbol: "BTCUSDT", Leverage: 10, PositionSizeUSD: 30000}
+
+		s.Require().NoError(s.autoTrader.enforceAllocationBudget(d, &logger.DecisionAction{}))
+		s.Equal(30000.0, d.PositionSizeUSD)
+	})
+}
+
+// TestCheckStablecoinPeg 测试稳定币脱锚保护
+func (s *AutoTraderTestSuite) TestCheckStablecoinPeg() {
+	s.autoTrader.config.StablecoinPeg = StablecoinPegConfig{Enabled: true, Symbol: "USDCUSDT", MaxDeviationPct: 0.5}
+	defer func() {
+		s.autoTrader.config.StablecoinPeg = StablecoinPegConfig{}
+		s.mockTrader.marketPrices = nil
+	}()
+
+	tests := []struct {
+		name      string
+		price     float64
+		wantBlock bool
+	}{
+		{"锚定正常", 1.0002, false},
+		{"区间内小幅偏离", 0.996, false},
+		{"向下脱锚", 0.97, true},
+		{"向上脱锚", 1.012, true},
+	}
+	for _, tt := range tests {
+		s.Run(tt.name, func() {
+			s.mockTrader.marketPrices = map[string]float64{"USDCUSDT": tt.price}
+			err := s.autoTrader.checkStablecoinPeg()
+			if tt.wantBlock {
+				s.Require().Error(err)
+				s.Contains(err.Error(), "禁止新开仓")
+			} else {
+				s.NoError(err)
+			}
+		})
+	}
+
+	s.Run("脱锚时开仓被拒绝", func() {
+		s.mockTrader.marketPrices = map[string]float64{"USDCUSDT": 0.95}
+		d := &decision.Decision{Action: "open_long", Symbol: "BTCUSDT", Leverage: 5, PositionSizeUSD: 1000}
+
+		err := s.autoTrader.executeOpenLongWithRecord(d, &logger.DecisionAction{})
+		s.Require().Error(err)
+		s.Contains(err.Error(), "稳定币脱锚保护")
+	})
+
+	s.Run("未启用时不检查", func() {
+		s.autoTrader.config.StablecoinPeg.Enabled = false
+		s.mockTrader.marketPrices = map[string]float64{"USDCUSDT": 0.5}
+		s.NoError(s.autoTrader.checkStablecoinPeg())
+	})
+}
+
+// TestCheckSpread 测试开仓前买卖价差检查
+func (s *AutoTraderTestSuite) TestCheckSpread() {
+	s.autoTrader.config.MaxSpreadBps = 10
+	defer func() {
+		s.autoTrader.config.MaxSpreadBps = 0
+		s.autoTrader.bookTickerFn = nil
+	}()
+
+	setBook := func(bid, ask float64) {
+		s.autoTrader.bookTickerFn = func(symbol string) (*market.BookTicker, error) {
+			return &market.BookTicker{Symbol: symbol, BidPrice: bid, AskPrice: ask}, nil
+		}
+	}
+
+	s.Run("价差在上限内允许开仓", func() {
+		setBook(49990, 50010) // 4 bps
+		s.NoError(s.autoTrader.checkSpread("BTCUSDT"))
+	})
+
+	s.Run("价差过大拒绝开仓", func() {
+		setBook(49500, 50500) // 200 bps
+		err := s.autoTrader.checkSpread("BTCUSDT")
+		s.Require().Error(err)
+		s.Contains(err.Error(), "超过上限")
+	})
+
+	s.Run("价差过大时开空被拒绝", func() {
+		setBook(1.00, 1.05)
+		d := &decision.Decision{Action: "open_short", Symbol: "DOGEUSDT", Leverage: 5, PositionSizeUSD: 1000}
+		err := s.autoTrader.executeOpenShortWithRecord(d, &logger.DecisionAction{})
+		s.Require().Error(err)
+		s.Contains(err.Error(), "买卖价差")
+	})
+
+	s.Run("无法获取盘口时放行", func() {
+		s.autoTrader.bookTickerFn = func(symbol string) (*market.BookTicker, error) {
+			return nil, errors.New("network error")
+		}
+		s.NoError(s.autoTrader.checkSpread("BTCUSDT"))
+	})
+
+	s.Run("未配置上限时不检查", func() {
+		s.autoTrader.config.MaxSpreadBps = 0
+		setBook(1, 2)
+		s.NoError(s.autoTrader.checkSpread("BTCUSDT"))
+	})
+}
+
+func (s *AutoTraderTestSuite) TestCheckChurnGuard() {
+	s.autoTrader.config.ChurnGuard = ChurnGuardConfig{Enabled: true, Cycles: 3, MinConfidence: 85}
+	s.autoTrader.callCount = 10
+	defer func() {
+		s.autoTrader.config.ChurnGuard = ChurnGuardConfig{}
+		s.autoTrader.symbolHistory = symbolHistory{}
+		s.autoTrader.callCount = 0
+	}()
+
+	s.autoTrader.symbolHistory.record(SymbolAction{Symbol: "BTCUSDT", Action: "close_long", Cycle: 9, PnLPct: -2.5})
+	s.autoTrader.symbolHistory.record(SymbolAction{Symbol: "ETHUSDT", Action: "open_short", Cycle: 5})
+
+	s.Run("窗口内重复同方向开仓被拒绝", func() {
+		record := &logger.DecisionAction{}
+		err := s.autoTrader.checkChurnGuard(&decision.Decision{Action: "open_long", Symbol: "BTCUSDT", Confidence: 70}, record)
+		s.Require().Error(err)
+		s.Contains(err.Error(), "防反复开平仓")
+		s.Contains(record.GuardVerdict, "拒绝")
+		s.Contains(record.GuardVerdict, "平多（-2.50%）")
+	})
+
+	s.Run("高信心度放行并记录结论", func() {
+		record := &logger.DecisionAction{}
+		err := s.autoTrader.checkChurnGuard(&decision.Decision{Action: "open_long", Symbol: "BTCUSDT", Confidence: 90}, record)
+		s.NoError(err)
+		s.Contains(record.GuardVerdict, "放行")
+	})
+
+	s.Run("反方向开仓不受影响", func() {
+		record := &logger.DecisionAction{}
+		s.NoError(s.autoTrader.checkChurnGuard(&decision.Decision{Action: "open_short", Symbol: "BTCUSDT", Confidence: 60}, record))
+		s.Empty(record.GuardVerdict)
+	})
+
+	s.Run("超出周期窗口不检查", func() {
+		record := &logger.DecisionAction{}
+		s.NoError(s.autoTrader.checkChurnGuard(&decision.Decision{Action: "open_short", Symbol: "ETHUSDT", Confidence: 60}, record))
+		s.Empty(record.GuardVerdict)
+	})
+
+	s.Run("开仓流程中被拒绝", func() {
+		d := &decision.Decision{Action: "open_long", Symbol: "BTCUSDT", Leverage: 5, PositionSizeUSD: 1000, Confidence: 70}
+		err := s.autoTrader.executeOpenLongWithRecord(d, &logger.DecisionAction{})
+		s.Require().Error(err)
+		s.Contains(err.Error(), "防反复开平仓")
+	})
+
+	s.Run("未启用时不检查", func() {
+		s.autoTrader.config.ChurnGuard.Enabled = false
+		s.NoError(s.autoTrader.checkChurnGuard(&decision.Decision{Action: "open_long", Symbol: "BTCUSDT"}, &logger.DecisionAction{}))
+	})
+}
+
+func (s *AutoTraderTestSuite) TestSymbolHistoryRestore() {
+	records := []*logger.DecisionRecord{
+		{Decisions: []logger.DecisionAction{{Action: "open_long", Symbol: "SOLUSDT", Success: true}}},
+		{Decisions: []logger.DecisionAction{{Action: "open_short", Symbol: "SOLUSDT", Success: false}, {Action: "hold", Symbol: "BTCUSDT", Success: true}}},
+		{Decisions: []logger.DecisionAction{{Action: "close_long", Symbol: "SOLUSDT", Success: true, PnLPct: 3.2}}},
+	}
+	var h symbolHistory
+	h.restoreFromRecords(records)
+
+	got := h.recent("SOLUSDT")
+	s.Require().Len(got, 2, "失败和非交易动作不应恢复")
+	s.Equal("open_long", got[0].Action)
+	s.Equal(-2, got[0].Cycle)
+	s.Equal("close_long", got[1].Action)
+	s.Equal(0, got[1].Cycle)
+	s.InDelta(3.2, got[1].PnLPct, 1e-9)
+	s.Empty(h.recent("BTCUSDT"))
+
+	for i := 0; i < symbolHistoryLimit+3; i++ {
+		h.record(SymbolAction{Symbol: "SOLUSDT", Action: "open_long", Cycle: i + 1})
+	}
+	s.Len(h.recent("SOLUSDT"), symbolHistoryLimit, "每个币种只保留最近的动作")
+}
+
+func (s *AutoTraderTestSuite) TestCheckBlackout() {
+	now := time.Now().UTC()
+	s.autoTrader.config.Blackout = BlackoutConfig{Windows: []BlackoutWindow{
+		{Start: now.Add(-10 * time.Minute), End: now.Add(20 * time.Minute), Label: "CPI"},
+	}}
+	defer func() {
+		s.autoTrader.config.Blackout = BlackoutConfig{}
+		s.mockTrader.positions = nil
+	}()
+
+	s.Run("窗口内开仓被拒绝", func() {
+		err := s.autoTrader.checkBlackout(now)
+		s.Require().Error(err)
+		s.Contains(err.Error(), "CPI")
+
+		d := &decision.Decision{Action: "open_long", Symbol: "BTCUSDT", Leverage: 5, PositionSizeUSD: 1000}
+		err = s.autoTrader.executeOpenLongWithRecord(d, &logger.DecisionAction{})
+		s.Require().Error(err)
+		s.Contains(err.Error(), "交易暂停窗口")
+
+		d = &decision.Decision{Action: "open_short", Symbol: "ETHUSDT", Leverage: 5, PositionSizeUSD: 1000}
+		err = s.autoTrader.executeOpenShortWithRecord(d, &logger.DecisionAction{})
+		s.Require().Error(err)
+		s.Contains(err.Error(), "交易暂停窗口")
+	})
+
+	s.Run("窗口外允许开仓", func() {
+		s.NoError(s.autoTrader.checkBlackout(now.Add(-11 * time.Minute)))
+		s.NoError(s.autoTrader.checkBlackout(now.Add(20*time.Minute)), "结束时间不在窗口内")
+		s.NoError(s.autoTrader.checkBlackout(now.Add(time.Hour)))
+	})
+
+	s.Run("未配置清仓时不平仓", func() {
+		s.mockTrader.positions = []map[string]interface{}{{"symbol": "BTCUSDT", "side": "long"}}
+		s.Empty(s.autoTrader.flattenForBlackout(now))
+	})
+
+	s.Run("配置清仓时窗口内平掉所有持仓", func() {
+		s.autoTrader.config.Blackout.FlattenPositions = true
+		s.mockTrader.positions = []map[string]interface{}{
+			{"symbol": "BTCUSDT", "side": "long"},
+			{"symbol": "ETHUSDT", "side": "short"},
+		}
+		logs := s.autoTrader.flattenForBlackout(now)
+		s.Require().Len(logs, 2)
+		s.Contains(logs[0], "✓")
+		s.Contains(logs[1], "ETHUSDT short")
+
+		s.Empty(s.autoTrader.flattenForBlackout(now.Add(time.Hour)), "窗口外不平仓")
+	})
+}
+
+func (s *AutoTraderTestSuite) TestCloseDustPositions() {
+	s.mockTrader.positions = []map[string]interface{}{
+		{"symbol": "BTCUSDT", "side": "long", "positionAmt": 0.0001, "markPrice": 50000.0}, // 5 USD
+		{"symbol": "ETHUSDT", "side": "short", "positionAmt": -0.5, "markPrice": 3000.0},   // 1500 USD
+		{"symbol": "DOGEUSDT", "side": "short", "positionAmt": -20.0, "markPrice": 0.2},    // 4 USD
+	}
+	s.mockTrader.closed = nil
+	defer func() {
+		s.autoTrader.config.DustThresholdUSD = 0
+		s.mockTrader.positions = nil
+		s.mockTrader.closed = nil
+	}()
+
+	s.Run("未配置阈值时不处理", func() {
+		s.Empty(s.autoTrader.closeDustPositions())
+		s.Empty(s.mockTrader.closed)
+	})
+
+	s.Run("低于阈值的残余持仓被平掉，高于阈值的保留", func() {
+		s.autoTrader.config.DustThresholdUSD = 10
+		logs := s.autoTrader.closeDustPositions()
+		s.Len(logs, 2)
+		s.Equal([]string{"BTCUSDT_long", "DOGEUSDT_short"}, s.mockTrader.closed)
+	})
+
+	s.Run("平仓失败时记录错误", func() {
+		s.mockTrader.closed = nil
+		s.mockTrader.shouldFailCloseLong = true
+		defer func() { s.mockTrader.shouldFailCloseLong = false }()
+
+		logs := s.autoTrader.closeDustPositions()
+		s.Require().Len(logs, 2)
+		s.Contains(logs[0], "❌")
+		s.Equal([]string{"DOGEUSDT_short"}, s.mockTrader.closed)
+	})
+}
+
+func (s *AutoTraderTestSuite) TestPositionIDs() {
+	s.autoTrader.positionMeta = make(map[string]*PositionMeta)
+	defer func() {
+		s.autoTrader.positionMeta = make(map[string]*PositionMeta)
+		s.autoTrader.positionIDs = positionIDBook{}
+	}()
+
+	btcID := s.autoTrader.setPositionMeta("BTCUSDT", "long", 90000, 110000, "")
+	ethID := s.autoTrader.setPositionMeta("ETHUSDT", "short", 4000, 3000, "")
+	s.Require().NotEmpty(btcID)
+	s.Require().NotEmpty(ethID)
+	s.NotEqual(btcID, ethID)
+	s.Regexp(`^BTC-L-\d{12}$`, btcID)
+	s.Regexp(`^ETH-S-\d{12}$`, ethID)
+
+	s.Run("按ID和按币种混合的决策批次", func() {
+		byID := &decision.Decision{Action: "close_short", PositionID: ethID}
+		record := &logger.DecisionAction{}
+		s.Require().NoError(s.autoTrader.resolvePositionRef(byID, record))
+		s.Equal("ETHUSDT", byID.Symbol, "未指定币种时按持仓ID补全")
+		s.Equal("ETHUSDT", record.Symbol)
+		s.Equal(ethID, record.PositionID)
+
+		bySymbol := &decision.Decision{Action: "close_long", Symbol: "BTCUSDT"}
+		record = &logger.DecisionAction{}
+		s.NoError(s.autoTrader.resolvePositionRef(bySymbol, record), "未指定ID时保持按币种处理")
+		s.Empty(record.PositionID)
+	})
+
+	s.Run("ID与方向或币种不一致被拒绝", func() {
+		err := s.autoTrader.resolvePositionRef(&decision.Decision{Action: "close_long", PositionID: ethID}, &logger.DecisionAction{})
+		s.Require().Error(err)
+		s.Contains(err.Error(), "不能执行 close_long")
+
+		err = s.autoTrader.resolvePositionRef(&decision.Decision{Action: "update_stop_loss", Symbol: "BTCUSDT", PositionID: ethID}, &logger.DecisionAction{})
+		s.Require().Error(err)
+		s.Contains(err.Error(), "不一致")
+	})
+
+	s.Run("引用已平仓或不存在的ID被拒绝", func() {
+		s.Equal(ethID, s.autoTrader.closePositionMeta("ETHUSDT", "short"))
+
+		err := s.autoTrader.executeDecisionWithRecord(&decision.Decision{Action: "close_short", PositionID: ethID}, &logger.DecisionAction{})
+		s.Require().Error(err)
+		s.Contains(err.Error(), "已平仓")
+
+		err = s.autoTrader.executeDecisionWithRecord(&decision.Decision{Action: "partial_close", ClosePercentage: 50, PositionID: "DOGE-L-260101000000"}, &logger.DecisionAction{})
+		s.Require().Error(err)
+		s.Contains(err.Error(), "不存在或不属于该交易员")
+	})
+
+	s.Run("对冲持仓按ID的方向定位", func() {
+		shortID := s.autoTrader.setPositionMeta("BTCUSDT", "short", 110000, 90000, "")
+		positions := []map[string]interface{}{
+			{"symbol": "BTCUSDT", "side": "long", "positionAmt": 0.1},
+			{"symbol": "BTCUSDT", "side": "short", "positionAmt": -0.2},
+		}
+		target := s.autoTrader.findTargetPosition(positions, &decision.Decision{Symbol: "BTCUSDT", PositionID: shortID})
+		s.Require().NotNil(target)
+		s.Equal("short", target["side"])
+
+		target = s.autoTrader.findTargetPosition(positions, &decision.Decision{Symbol: "BTCUSDT"})
+		s.Require().NotNil(target)
+		s.Equal("long", target["side"], "未指定ID时沿用按币种查找的第一个持仓")
+	})
+
+	s.Run("同步持仓时已平仓的ID记入已平仓列表", func() {
+		s.autoTrader.syncPositionMeta(map[string]int64{"BTCUSDT_short": time.Now().UnixMilli()})
+		err := s.autoTrader.resolvePositionRef(&decision.Decision{Action: "close_long", PositionID: btcID}, &logger.DecisionAction{})
+		s.Require().Error(err)
+		s.Contains(err.Error(), "已平仓")
+	})
+}
+
+func (s *AutoTraderTestSuite) TestPositionIDRestore() {
+	s.autoTrader.positionMeta = make(map[string]*PositionMeta)
+	s.autoTrader.positionIDs = positionIDBook{}
+	defer func() {
+		s.autoTrader.positionMeta = make(map[string]*PositionMeta)
+		s.autoTrader.positionIDs = positionIDBook{}
+	}()
+
+	s.autoTrader.positionIDs.restoreFromRecords([]*logger.DecisionRecord{
+		{Decisions: []logger.DecisionAction{
+			{Action: "open_long", Symbol: "SOLUSDT", Success: true, PositionID: "SOL-L-260101000000"},
+			{Action: "open_short", Symbol: "XRPUSDT", Success: true, PositionID: "XRP-S-260101000000"},
+		}},
+		{Decisions: []logger.DecisionAction{
+			{Action: "close_short", Symbol: "XRPUSDT", Success: true},
+		}},
+	})
+
+	// 重启后首次同步持仓时沿用日志中的持仓ID
+	s.autoTrader.syncPositionMeta(map[string]int64{"SOLUSDT_long": time.Now().UnixMilli()})
+	s.Equal("SOL-L-260101000000", s.autoTrader.positionIDFor("SOLUSDT", "long"))
+
+	err := s.autoTrader.resolvePositionRef(&decision.Decision{Action: "close_short", PositionID: "XRP-S-260101000000"}, &logger.DecisionAction{})
+	s.Require().Error(err)
+	s.Contains(err.Error(), "已平仓")
+}
+
 // ============================================================
 // 层次 7: getCandidateCoins 测试
 // ============================================================
@@ -983,6 +1636,8 @@ type MockTrader struct {
 	shouldFailOpenLong   bool
 	shouldFailCloseLong  bool
 	shouldFailCloseShort bool
+	marketPrices         map[string]float64 // 按币种覆盖 GetMarketPrice 返回值
+	closed               []string           // 已平仓的持仓（symbol_side）
 }
 
 func (m *MockTrader) GetBalance() (map[string]interface{}, error) {
@@ -1030,6 +1685,7 @@ func (m *MockTrader) CloseLong(symbol string, quantity float64) (map[string]inte
 	if m.shouldFailCloseLong {
 		return nil, errors.New("failed to close long")
 	}
+	m.closed = append(m.closed, symbol+"_long")
 	return map[string]interface{}{
 		"orderId": int64(123458),
 		"symbol":  symbol,
@@ -1040,6 +1696,7 @@ func (m *MockTrader) CloseShort(symbol string, quantity float64) (map[string]int
 	if m.shouldFailCloseShort {
 		return nil, errors.New("failed to close short")
 	}
+	m.closed = append(m.closed, symbol+"_short")
 	return map[string]interface{}{
 		"orderId": int64(123459),
 		"symbol":  symbol,
@@ -1055,6 +1712,9 @@ func (m *MockTrader) SetMarginMode(symbol string, isCrossMargin bool) error {
 }
 
 func (m *MockTrader) GetMarketPrice(symbol string) (float64, error) {
+	if price, ok := m.marketPrices[symbol]; ok {
+		return price, nil
+	}
 	return 50000.0, nil
 }
 
@@ -1210,3 +1870,27 @@ func TestCalculatePnLPercentage_RealWorldScenarios(t *testing.T) {
 		}
 	})
 }
+
+// TestUpdateDrawdown_TracksRuntimePeak 测试回撤按运行期间观察到的净值峰值计算：已低于初始余额的交易员启动后不处于回撤中，
+// 回撤后净值回升时回撤减小，动态风控恢复开仓
+func TestUpdateDrawdown_TracksRuntimePeak(t *testing.T) {
+	at := &AutoTrader{initialBalance: 10000}
+	cfg := decision.DefaultRiskScalingConfig(5, 20)
+
+	dd := at.updateDrawdown(8000) // 启动时已低于初始余额 20%
+	assert.Zero(t, dd)
+	assert.False(t, decision.ComputeRiskLimits(cfg, 8000, 0, dd, 5, 5).EntriesBlocked, "不以初始余额为峰值")
+
+	dd = at.updateDrawdown(6600)
+	assert.InDelta(t, 17.5, dd, 1e-9)
+	assert.True(t, decision.ComputeRiskLimits(cfg, 6600, 0, dd, 5, 5).EntriesBlocked, "自运行峰值回撤 17.5% 禁止开仓")
+
+	dd = at.updateDrawdown(7800)
+	assert.InDelta(t, 2.5, dd, 1e-9)
+	assert.False(t, decision.ComputeRiskLimits(cfg, 7800, 0, dd, 5, 5).EntriesBlocked, "净值回升后恢复开仓")
+
+	dd = at.updateDrawdown(9000)
+	assert.Zero(t, dd)
+	assert.Equal(t, 9000.0, at.peakEquity, "创新高后峰值随之上移")
+	assert.InDelta(t, 10, at.updateDrawdown(8100), 1e-9)
+}
@@ -4,14 +4,17 @@ import (
 	"context"
 	"crypto/rand"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"log"
 	"aspen/hook"
+	"math"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/adshao/go-binance/v2/common"
 	"github.com/adshao/go-binance/v2/futures"
 )
 
@@ -43,10 +46,35 @@ func getBrOrderID() string {
 	return orderID
 }
 
+// brClientOrderID 指定了客户端订单ID时生成确定的订单ID（同样带br ID前缀，重启后可据此查询订单），否则生成随机订单ID
+func brClientOrderID(clientOrderID string) string {
+	if clientOrderID == "" {
+		return getBrOrderID()
+	}
+	orderID := "x-KzrpZaP9" + clientOrderID
+	if len(orderID) > 32 {
+		orderID = orderID[:32]
+	}
+	return orderID
+}
+
+// 账户持仓模式（启动时检测，从不自动切换：同一账户可能还在被其他程序使用）
+const (
+	PositionModeHedge  = "hedge"   // 双向持仓：订单需指定 positionSide LONG/SHORT，同一币种多空分开持仓
+	PositionModeOneWay = "one_way" // 单向持仓：positionSide 为 BOTH，平仓单使用 reduceOnly
+)
+
 // FuturesTrader 币安合约交易器
 type FuturesTrader struct {
 	client *futures.Client
 
+	// 交易周期的请求ID（附带在交易所请求头中）
+	requestIDHolder
+
+	// 账户持仓模式（为空表示尚未检测成功）
+	positionMode      string
+	positionModeMutex sync.RWMutex
+
 	// 余额缓存
 	cachedBalance     map[string]interface{}
 	balanceCacheTime  time.Time
@@ -59,6 +87,9 @@ type FuturesTrader struct {
 
 	// 缓存有效期（15秒）
 	cacheDuration time.Duration
+
+	// 多资产保证金的USD折算价格缓存
+	assetPrices assetPriceCache
 }
 
 // NewFuturesTrader 创建合约交易器
@@ -76,36 +107,61 @@ func NewFuturesTrader(apiKey, secretKey string, userId string) *FuturesTrader {
 		client:        client,
 		cacheDuration: 15 * time.Second, // 15秒缓存
 	}
+	client.HTTPClient = trader.withRequestIDHeader(client.HTTPClient)
+
+	// 检测账户持仓模式并按模式构造订单参数（不修改账户设置）
+	if mode, err := trader.detectPositionMode(); err != nil {
+		log.Printf("⚠️ %v，将在下单前重试", err)
+	} else {
+		log.Printf("  ✓ 账户持仓模式: %s", mode)
+	}
 
-	// 设置双向持仓模式（Hedge Mode）
-	// 这是必需的，因为代码中使用了 PositionSide (LONG/SHORT)
-	if err := trader.setDualSidePosition(); err != nil {
-		log.Printf("⚠️ 设置双向持仓模式失败: %v (如果已是双向模式则忽略此警告)", err)
+	// 按杠杆分层记录各币种最大杠杆，开仓时据此收紧AI给出的杠杆
+	if err := trader.loadLeverageBrackets(); err != nil {
+		log.Printf("⚠️ %v，不按交易所杠杆上限收紧杠杆", err)
 	}
 
 	return trader
 }
 
-// setDualSidePosition 设置双向持仓模式（初始化时调用）
-func (t *FuturesTrader) setDualSidePosition() error {
-	// 尝试设置双向持仓模式
-	err := t.client.NewChangePositionModeService().
-		DualSide(true). // true = 双向持仓（Hedge Mode）
-		Do(context.Background())
-
+// detectPositionMode 查询账户持仓模式（GET /fapi/v1/positionSide/dual），只读取不切换
+func (t *FuturesTrader) detectPositionMode() (string, error) {
+	res, err := t.client.NewGetPositionModeService().Do(context.Background())
 	if err != nil {
-		// 如果错误信息包含"No need to change"，说明已经是双向持仓模式
-		if strings.Contains(err.Error(), "No need to change position side") {
-			log.Printf("  ✓ 账户已是双向持仓模式（Hedge Mode）")
-			return nil
-		}
-		// 其他错误则返回（但在调用方不会中断初始化）
-		return err
+		return "", fmt.Errorf("查询持仓模式失败: %w", err)
 	}
 
-	log.Printf("  ✓ 账户已切换为双向持仓模式（Hedge Mode）")
-	log.Printf("  ℹ️  双向持仓模式允许同时持有多单和空单")
-	return nil
+	mode := PositionModeOneWay
+	if res.DualSidePosition {
+		mode = PositionModeHedge
+	}
+	t.positionModeMutex.Lock()
+	t.positionMode = mode
+	t.positionModeMutex.Unlock()
+	return mode, nil
+}
+
+// PositionMode 账户持仓模式（hedge/one_way，尚未检测成功时为空）
+func (t *FuturesTrader) PositionMode() string {
+	t.positionModeMutex.RLock()
+	defer t.positionModeMutex.RUnlock()
+	return t.positionMode
+}
+
+// orderPositionSide 按账户持仓模式确定订单的 positionSide：双向持仓使用 LONG/SHORT，单向持仓使用 BOTH。
+// 单向持仓模式下平仓单返回 reduceOnly=true，防止平仓数量超过持仓时反向开仓（双向持仓模式不允许发送 reduceOnly）
+func (t *FuturesTrader) orderPositionSide(side futures.PositionSideType, closing bool) (futures.PositionSideType, bool, error) {
+	mode := t.PositionMode()
+	if mode == "" {
+		var err error
+		if mode, err = t.detectPositionMode(); err != nil {
+			return "", false, err
+		}
+	}
+	if mode == PositionModeHedge {
+		return side, false, nil
+	}
+	return futures.PositionSideTypeBoth, closing, nil
 }
 
 // syncBinanceServerTime 同步币安服务器时间，确保请求时间戳合法
@@ -152,6 +208,38 @@ func (t *FuturesTrader) GetBalance() (map[string]interface{}, error) {
 		account.AvailableBalance,
 		account.TotalUnrealizedProfit)
 
+	// 联合保证金模式下按资产拆分余额（USDT/USDC/BNB 等可同时作为保证金），总额按USD折算汇总，避免不同单位直接相加；
+	// 单资产模式下只有 USDT 计入保证金，其他资产的余额不能充当保证金，不计入净值
+	var assets []AssetBalance
+	for _, asset := range account.Assets {
+		if !account.MultiAssetsMargin && asset.Asset != MarginAssetUSDT {
+			continue
+		}
+		walletBalance, _ := strconv.ParseFloat(asset.WalletBalance, 64)
+		availableBalance, _ := strconv.ParseFloat(asset.AvailableBalance, 64)
+		unrealizedProfit, _ := strconv.ParseFloat(asset.UnrealizedProfit, 64)
+		assets = append(assets, AssetBalance{
+			Asset:            asset.Asset,
+			WalletBalance:    walletBalance,
+			AvailableBalance: availableBalance,
+			UnrealizedProfit: unrealizedProfit,
+		})
+	}
+	if len(assets) > 0 {
+		consolidated, walletUSD, unrealizedUSD := consolidateAssetBalances(assets, func(asset string) (float64, error) {
+			return t.assetPrices.USDPrice(asset, t.GetMarketPrice)
+		})
+		result["assetBalances"] = consolidated
+		if len(consolidated) > 0 {
+			result["totalWalletBalance"] = walletUSD
+			result["totalUnrealizedProfit"] = unrealizedUSD
+		}
+		for _, a := range consolidated {
+			log.Printf("  • %s: 钱包余额=%.4f, 未实现盈亏=%.4f, 折算价格=%.4f, 折合 %.2f USD",
+				a.Asset, a.WalletBalance, a.UnrealizedProfit, a.USDPrice, a.USDValue)
+		}
+	}
+
 	// 更新缓存
 	t.balanceCacheMutex.Lock()
 	t.cachedBalance = result
@@ -180,6 +268,20 @@ func (t *FuturesTrader) GetPositions() ([]map[string]interface{}, error) {
 		return nil, fmt.Errorf("获取持仓失败: %w", err)
 	}
 
+	result := convertPositionRisks(positions)
+
+	// 更新缓存
+	t.positionsCacheMutex.Lock()
+	t.cachedPositions = result
+	t.positionsCacheTime = time.Now()
+	t.positionsCacheMutex.Unlock()
+
+	return result, nil
+}
+
+// convertPositionRisks 转换持仓风险数据。双向持仓模式下同一币种的多仓和空仓分两行返回
+// （positionSide LONG/SHORT），单向持仓模式只有一行（BOTH，按数量正负判断方向）；空仓数量统一为负数
+func convertPositionRisks(positions []*futures.PositionRisk) []map[string]interface{} {
 	var result []map[string]interface{}
 	for _, pos := range positions {
 		posAmt, _ := strconv.ParseFloat(pos.PositionAmt, 64)
@@ -189,30 +291,68 @@ func (t *FuturesTrader) GetPositions() ([]map[string]interface{}, error) {
 
 		posMap := make(map[string]interface{})
 		posMap["symbol"] = pos.Symbol
-		posMap["positionAmt"], _ = strconv.ParseFloat(pos.PositionAmt, 64)
 		posMap["entryPrice"], _ = strconv.ParseFloat(pos.EntryPrice, 64)
 		posMap["markPrice"], _ = strconv.ParseFloat(pos.MarkPrice, 64)
 		posMap["unRealizedProfit"], _ = strconv.ParseFloat(pos.UnRealizedProfit, 64)
 		posMap["leverage"], _ = strconv.ParseFloat(pos.Leverage, 64)
 		posMap["liquidationPrice"], _ = strconv.ParseFloat(pos.LiquidationPrice, 64)
+		posMap["initialMargin"] = positionInitialMargin(pos.MarginType, pos.IsolatedWallet, pos.Notional, posMap["leverage"].(float64))
 
 		// 判断方向
-		if posAmt > 0 {
+		switch {
+		case pos.PositionSide == string(futures.PositionSideTypeLong):
 			posMap["side"] = "long"
-		} else {
+		case pos.PositionSide == string(futures.PositionSideTypeShort):
 			posMap["side"] = "short"
+		case posAmt > 0:
+			posMap["side"] = "long"
+		default:
+			posMap["side"] = "short"
+		}
+		if posMap["side"] == "short" {
+			posAmt = -math.Abs(posAmt)
+		} else {
+			posAmt = math.Abs(posAmt)
 		}
+		posMap["positionAmt"] = posAmt
 
 		result = append(result, posMap)
 	}
+	return result
+}
 
-	// 更新缓存
-	t.positionsCacheMutex.Lock()
-	t.cachedPositions = result
-	t.positionsCacheTime = time.Now()
-	t.positionsCacheMutex.Unlock()
+// positionInitialMargin 持仓占用的初始保证金：逐仓为逐仓钱包余额，全仓按名义价值/杠杆计算（与币安账户页一致）
+func positionInitialMargin(marginType, isolatedWallet, notional string, leverage float64) float64 {
+	if strings.EqualFold(marginType, "isolated") {
+		if wallet, err := strconv.ParseFloat(isolatedWallet, 64); err == nil && wallet > 0 {
+			return wallet
+		}
+	}
+	value, _ := strconv.ParseFloat(notional, 64)
+	if leverage <= 0 {
+		return 0
+	}
+	return math.Abs(value) / leverage
+}
 
-	return result, nil
+// FundingPaid 查询币种自 since 起已支付的资金费（资金费流水取反，收到资金费时为负数）
+func (t *FuturesTrader) FundingPaid(symbol string, since time.Time) (float64, error) {
+	incomes, err := t.client.NewGetIncomeHistoryService().
+		Symbol(symbol).
+		IncomeType("FUNDING_FEE").
+		StartTime(since.UnixMilli()).
+		Limit(1000).
+		Do(context.Background())
+	if err != nil {
+		return 0, fmt.Errorf("获取资金费流水失败: %w", err)
+	}
+
+	paid := 0.0
+	for _, income := range incomes {
+		amount, _ := strconv.ParseFloat(income.Income, 64)
+		paid -= amount
+	}
+	return paid, nil
 }
 
 // SetMarginMode 设置仓位模式
@@ -314,6 +454,11 @@ func (t *FuturesTrader) SetLeverage(symbol string, leverage int) error {
 
 // OpenLong 开多仓
 func (t *FuturesTrader) OpenLong(symbol string, quantity float64, leverage int) (map[string]interface{}, error) {
+	return t.OpenLongWithClientID(symbol, quantity, leverage, "")
+}
+
+// OpenLongWithClientID 开多仓（clientOrderID 非空时使用确定的客户端订单ID）
+func (t *FuturesTrader) OpenLongWithClientID(symbol string, quantity float64, leverage int, clientOrderID string) (map[string]interface{}, error) {
 	// 先取消该币种的所有委托单（清理旧的止损止盈单）
 	if err := t.CancelAllOrders(symbol); err != nil {
 		log.Printf("  ⚠ 取消旧委托单失败（可能没有委托单）: %v", err)
@@ -344,14 +489,22 @@ func (t *FuturesTrader) OpenLong(symbol string, quantity float64, leverage int)
 	}
 
 	// 创建市价买入订单（使用br ID）
-	order, err := t.client.NewCreateOrderService().
+	positionSide, reduceOnly, err := t.orderPositionSide(futures.PositionSideTypeLong, false)
+	if err != nil {
+		return nil, err
+	}
+	orderService := t.client.NewCreateOrderService().
 		Symbol(symbol).
 		Side(futures.SideTypeBuy).
-		PositionSide(futures.PositionSideTypeLong).
+		PositionSide(positionSide).
 		Type(futures.OrderTypeMarket).
 		Quantity(quantityStr).
-		NewClientOrderID(getBrOrderID()).
-		Do(context.Background())
+		NewClientOrderID(brClientOrderID(clientOrderID)).
+		NewOrderResponseType(futures.NewOrderRespTypeRESULT) // 返回成交均价，用于统计滑点
+	if reduceOnly {
+		orderService.ReduceOnly(true)
+	}
+	order, err := orderService.Do(context.Background())
 
 	if err != nil {
 		return nil, fmt.Errorf("开多仓失败: %w", err)
@@ -364,11 +517,19 @@ func (t *FuturesTrader) OpenLong(symbol string, quantity float64, leverage int)
 	result["orderId"] = order.OrderID
 	result["symbol"] = order.Symbol
 	result["status"] = order.Status
+	if avgPrice, err := strconv.ParseFloat(order.AvgPrice, 64); err == nil && avgPrice > 0 {
+		result["avgPrice"] = avgPrice
+	}
 	return result, nil
 }
 
 // OpenShort 开空仓
 func (t *FuturesTrader) OpenShort(symbol string, quantity float64, leverage int) (map[string]interface{}, error) {
+	return t.OpenShortWithClientID(symbol, quantity, leverage, "")
+}
+
+// OpenShortWithClientID 开空仓（clientOrderID 非空时使用确定的客户端订单ID）
+func (t *FuturesTrader) OpenShortWithClientID(symbol string, quantity float64, leverage int, clientOrderID string) (map[string]interface{}, error) {
 	// 先取消该币种的所有委托单（清理旧的止损止盈单）
 	if err := t.CancelAllOrders(symbol); err != nil {
 		log.Printf("  ⚠ 取消旧委托单失败（可能没有委托单）: %v", err)
@@ -399,14 +560,22 @@ func (t *FuturesTrader) OpenShort(symbol string, quantity float64, leverage int)
 	}
 
 	// 创建市价卖出订单（使用br ID）
-	order, err := t.client.NewCreateOrderService().
+	positionSide, reduceOnly, err := t.orderPositionSide(futures.PositionSideTypeShort, false)
+	if err != nil {
+		return nil, err
+	}
+	orderService := t.client.NewCreateOrderService().
 		Symbol(symbol).
 		Side(futures.SideTypeSell).
-		PositionSide(futures.PositionSideTypeShort).
+		PositionSide(positionSide).
 		Type(futures.OrderTypeMarket).
 		Quantity(quantityStr).
-		NewClientOrderID(getBrOrderID()).
-		Do(context.Background())
+		NewClientOrderID(brClientOrderID(clientOrderID)).
+		NewOrderResponseType(futures.NewOrderRespTypeRESULT) // 返回成交均价，用于统计滑点
+	if reduceOnly {
+		orderService.ReduceOnly(true)
+	}
+	order, err := orderService.Do(context.Background())
 
 	if err != nil {
 		return nil, fmt.Errorf("开空仓失败: %w", err)
@@ -419,11 +588,19 @@ func (t *FuturesTrader) OpenShort(symbol string, quantity float64, leverage int)
 	result["orderId"] = order.OrderID
 	result["symbol"] = order.Symbol
 	result["status"] = order.Status
+	if avgPrice, err := strconv.ParseFloat(order.AvgPrice, 64); err == nil && avgPrice > 0 {
+		result["avgPrice"] = avgPrice
+	}
 	return result, nil
 }
 
 // CloseLong 平多仓
 func (t *FuturesTrader) CloseLong(symbol string, quantity float64) (map[string]interface{}, error) {
+	return t.CloseLongWithClientID(symbol, quantity, "")
+}
+
+// CloseLongWithClientID 平多仓（clientOrderID 非空时使用确定的客户端订单ID）
+func (t *FuturesTrader) CloseLongWithClientID(symbol string, quantity float64, clientOrderID string) (map[string]interface{}, error) {
 	// 如果数量为0，获取当前持仓数量
 	if quantity == 0 {
 		positions, err := t.GetPositions()
@@ -450,14 +627,22 @@ func (t *FuturesTrader) CloseLong(symbol string, quantity float64) (map[string]i
 	}
 
 	// 创建市价卖出订单（平多，使用br ID）
-	order, err := t.client.NewCreateOrderService().
+	positionSide, reduceOnly, err := t.orderPositionSide(futures.PositionSideTypeLong, true)
+	if err != nil {
+		return nil, err
+	}
+	orderService := t.client.NewCreateOrderService().
 		Symbol(symbol).
 		Side(futures.SideTypeSell).
-		PositionSide(futures.PositionSideTypeLong).
+		PositionSide(positionSide).
 		Type(futures.OrderTypeMarket).
 		Quantity(quantityStr).
-		NewClientOrderID(getBrOrderID()).
-		Do(context.Background())
+		NewClientOrderID(brClientOrderID(clientOrderID)).
+		NewOrderResponseType(futures.NewOrderRespTypeRESULT) // 返回成交均价，用于统计滑点
+	if reduceOnly {
+		orderService.ReduceOnly(true)
+	}
+	order, err := orderService.Do(context.Background())
 
 	if err != nil {
 		return nil, fmt.Errorf("平多仓失败: %w", err)
@@ -474,11 +659,19 @@ func (t *FuturesTrader) CloseLong(symbol string, quantity float64) (map[string]i
 	result["orderId"] = order.OrderID
 	result["symbol"] = order.Symbol
 	result["status"] = order.Status
+	if avgPrice, err := strconv.ParseFloat(order.AvgPrice, 64); err == nil && avgPrice > 0 {
+		result["avgPrice"] = avgPrice
+	}
 	return result, nil
 }
 
 // CloseShort 平空仓
 func (t *FuturesTrader) CloseShort(symbol string, quantity float64) (map[string]interface{}, error) {
+	return t.CloseShortWithClientID(symbol, quantity, "")
+}
+
+// CloseShortWithClientID 平空仓（clientOrderID 非空时使用确定的客户端订单ID）
+func (t *FuturesTrader) CloseShortWithClientID(symbol string, quantity float64, clientOrderID string) (map[string]interface{}, error) {
 	// 如果数量为0，获取当前持仓数量
 	if quantity == 0 {
 		positions, err := t.GetPositions()
@@ -505,14 +698,22 @@ func (t *FuturesTrader) CloseShort(symbol string, quantity float64) (map[string]
 	}
 
 	// 创建市价买入订单（平空，使用br ID）
-	order, err := t.client.NewCreateOrderService().
+	positionSide, reduceOnly, err := t.orderPositionSide(futures.PositionSideTypeShort, true)
+	if err != nil {
+		return nil, err
+	}
+	orderService := t.client.NewCreateOrderService().
 		Symbol(symbol).
 		Side(futures.SideTypeBuy).
-		PositionSide(futures.PositionSideTypeShort).
+		PositionSide(positionSide).
 		Type(futures.OrderTypeMarket).
 		Quantity(quantityStr).
-		NewClientOrderID(getBrOrderID()).
-		Do(context.Background())
+		NewClientOrderID(brClientOrderID(clientOrderID)).
+		NewOrderResponseType(futures.NewOrderRespTypeRESULT) // 返回成交均价，用于统计滑点
+	if reduceOnly {
+		orderService.ReduceOnly(true)
+	}
+	order, err := orderService.Do(context.Background())
 
 	if err != nil {
 		return nil, fmt.Errorf("平空仓失败: %w", err)
@@ -529,9 +730,38 @@ func (t *FuturesTrader) CloseShort(symbol string, quantity float64) (map[string]
 	result["orderId"] = order.OrderID
 	result["symbol"] = order.Symbol
 	result["status"] = order.Status
+	if avgPrice, err := strconv.ParseFloat(order.AvgPrice, 64); err == nil && avgPrice > 0 {
+		result["avgPrice"] = avgPrice
+	}
 	return result, nil
 }
 
+// QueryOrderByClientID 按客户端订单ID查询订单（found=false 表示交易所没有该订单）
+func (t *FuturesTrader) QueryOrderByClientID(symbol, clientOrderID string) (map[string]interface{}, bool, error) {
+	order, err := t.client.NewGetOrderService().
+		Symbol(symbol).
+		OrigClientOrderID(brClientOrderID(clientOrderID)).
+		Do(context.Background())
+	if err != nil {
+		// -2013: Order does not exist
+		var apiErr *common.APIError
+		if errors.As(err, &apiErr) && apiErr.Code == -2013 {
+			return nil, false, nil
+		}
+		return nil, false, fmt.Errorf("查询订单失败: %w", err)
+	}
+
+	avgPrice, _ := strconv.ParseFloat(order.AvgPrice, 64)
+	executedQty, _ := strconv.ParseFloat(order.ExecutedQuantity, 64)
+	result := make(map[string]interface{})
+	result["orderId"] = order.OrderID
+	result["symbol"] = order.Symbol
+	result["status"] = string(order.Status)
+	result["avgPrice"] = avgPrice
+	result["executedQty"] = executedQty
+	return result, true, nil
+}
+
 // CancelStopLossOrders 仅取消止损单（不影响止盈单）
 func (t *FuturesTrader) CancelStopLossOrders(symbol string) error {
 	// 获取该币种的所有未完成订单
@@ -739,6 +969,12 @@ func (t *FuturesTrader) SetStopLoss(symbol string, positionSide string, quantity
 		return err
 	}
 
+	// 使用 closePosition 平掉整个仓位，单向持仓模式下不需要（也不允许）reduceOnly
+	posSide, _, err = t.orderPositionSide(posSide, false)
+	if err != nil {
+		return err
+	}
+
 	_, err = t.client.NewCreateOrderService().
 		Symbol(symbol).
 		Side(side).
@@ -777,6 +1013,12 @@ func (t *FuturesTrader) SetTakeProfit(symbol string, positionSide string, quanti
 		return err
 	}
 
+	// 使用 closePosition 平掉整个仓位，单向持仓模式下不需要（也不允许）reduceOnly
+	posSide, _, err = t.orderPositionSide(posSide, false)
+	if err != nil {
+		return err
+	}
+
 	_, err = t.client.NewCreateOrderService().
 		Symbol(symbol).
 		Side(side).
@@ -299,6 +299,7 @@ func (s *BinanceFuturesTestSuite) Cleanup() {
 // TestFuturesTrader_InterfaceCompliance 测试接口兼容性
 func TestFuturesTrader_InterfaceCompliance(t *testing.T) {
 	var _ Trader = (*FuturesTrader)(nil)
+	var _ ClientOrderIDTrader = (*FuturesTrader)(nil)
 }
 
 // TestFuturesTrader_CommonInterface 使用测试套件运行所有通用接口测试
@@ -418,3 +419,316 @@ func TestGetBrOrderID(t *testing.T) {
 		ids[id] = true
 	}
 }
+
+// TestFuturesTrader_GetBalanceMultiAsset 测试 USDT+USDC 多资产保证金按USD折算汇总
+func TestFuturesTrader_GetBalanceMultiAsset(t *testing.T) {
+	priceRequests := 0
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var respBody interface{}
+
+		switch r.URL.Path {
+		case "/fapi/v2/account":
+			respBody = map[string]interface{}{
+				"multiAssetsMargin":     true,
+				"totalWalletBalance":    "6000.00",
+				"availableBalance":      "5500.00",
+				"totalUnrealizedProfit": "30.00",
+				"assets": []map[string]interface{}{
+					{"asset": "USDT", "walletBalance": "4000.00", "unrealizedProfit": "20.00", "availableBalance": "3500.00"},
+					{"asset": "USDC", "walletBalance": "2000.00", "unrealizedProfit": "10.00", "availableBalance": "2000.00"},
+					{"asset": "BNB", "walletBalance": "0.00", "unrealizedProfit": "0.00", "availableBalance": "0.00"},
+				},
+			}
+		case "/fapi/v1/ticker/price", "/fapi/v2/ticker/price":
+			priceRequests++
+			respBody = []map[string]interface{}{
+				{"Symbol": r.URL.Query().Get("symbol"), "Price": "0.9900", "Time": 1234567890},
+			}
+		default:
+			respBody = map[string]interface{}{}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(respBody)
+	}))
+	defer mockServer.Close()
+
+	client := futures.NewClient("test_api_key", "test_secret_key")
+	client.BaseURL = mockServer.URL
+	client.HTTPClient = mockServer.Client()
+	trader := &FuturesTrader{client: client}
+
+	balance, err := trader.GetBalance()
+	assert.NoError(t, err)
+
+	// USDT 按 1 折算，USDC 按 USDCUSDT=0.99 折算
+	assert.InDelta(t, 4000+2000*0.99, balance["totalWalletBalance"].(float64), 1e-6)
+	assert.InDelta(t, 20+10*0.99, balance["totalUnrealizedProfit"].(float64), 1e-6)
+
+	assets := balance["assetBalances"].([]AssetBalance)
+	if assert.Len(t, assets, 2, "零余额资产不应出现在明细中") {
+		assert.Equal(t, "USDT", assets[0].Asset)
+		assert.Equal(t, 1.0, assets[0].USDPrice)
+		assert.InDelta(t, 4020, assets[0].USDValue, 1e-6)
+		assert.Equal(t, "USDC", assets[1].Asset)
+		assert.InDelta(t, 2000, assets[1].WalletBalance, 1e-6, "明细保留原生数量")
+		assert.InDelta(t, 2010*0.99, assets[1].USDValue, 1e-6)
+	}
+
+	// 净值折合 USDC 原生数量
+	equity := balance["totalWalletBalance"].(float64) + balance["totalUnrealizedProfit"].(float64)
+	native, price := marginAssetEquity(balance, MarginAssetUSDC, equity)
+	assert.Equal(t, 0.99, price)
+	assert.InDelta(t, equity/0.99, native, 1e-6)
+
+	// 折算价格有缓存
+	_, err = trader.GetBalance()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, priceRequests)
+}
+
+// TestFuturesTrader_GetBalanceSingleAsset 测试单资产保证金模式下只有 USDT 计入净值，其他资产余额不折算汇总
+func TestFuturesTrader_GetBalanceSingleAsset(t *testing.T) {
+	priceRequests := 0
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var respBody interface{}
+
+		switch r.URL.Path {
+		case "/fapi/v2/account":
+			respBody = map[string]interface{}{
+				"multiAssetsMargin":     false,
+				"totalWalletBalance":    "4000.00",
+				"availableBalance":      "3500.00",
+				"totalUnrealizedProfit": "20.00",
+				"assets": []map[string]interface{}{
+					{"asset": "USDT", "walletBalance": "4000.00", "unrealizedProfit": "20.00", "availableBalance": "3500.00"},
+					{"asset": "USDC", "walletBalance": "2000.00", "unrealizedProfit": "0.00", "availableBalance": "2000.00"},
+				},
+			}
+		case "/fapi/v1/ticker/price", "/fapi/v2/ticker/price":
+			priceRequests++
+			respBody = []map[string]interface{}{
+				{"Symbol": r.URL.Query().Get("symbol"), "Price": "0.9900", "Time": 1234567890},
+			}
+		default:
+			respBody = map[string]interface{}{}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(respBody)
+	}))
+	defer mockServer.Close()
+
+	client := futures.NewClient("test_api_key", "test_secret_key")
+	client.BaseURL = mockServer.URL
+	client.HTTPClient = mockServer.Client()
+	trader := &FuturesTrader{client: client}
+
+	balance, err := trader.GetBalance()
+	assert.NoError(t, err)
+
+	assert.InDelta(t, 4000, balance["totalWalletBalance"].(float64), 1e-6, "USDC 余额不计入单资产模式的净值")
+	assert.InDelta(t, 20, balance["totalUnrealizedProfit"].(float64), 1e-6)
+	assets := balance["assetBalances"].([]AssetBalance)
+	if assert.Len(t, assets, 1) {
+		assert.Equal(t, "USDT", assets[0].Asset)
+	}
+	assert.Zero(t, priceRequests, "不需要获取其他资产的折算价格")
+}
+
+// TestFuturesTrader_QueryOrderByClientID 测试按确定的客户端订单ID查询订单（不存在时返回 found=false）
+func TestFuturesTrader_QueryOrderByClientID(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Query().Get("origClientOrderId") != brClientOrderID("a1b2c3d4e5f6a7b8c9d0") {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]interface{}{"code": -2013, "msg": "Order does not exist."})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"symbol":        "BTCUSDT",
+			"orderId":       42,
+			"clientOrderId": r.URL.Query().Get("origClientOrderId"),
+			"status":        "FILLED",
+			"avgPrice":      "50000.5",
+			"executedQty":   "0.010",
+		})
+	}))
+	defer mockServer.Close()
+
+	client := futures.NewClient("test_api_key", "test_secret_key")
+	client.BaseURL = mockServer.URL
+	client.HTTPClient = mockServer.Client()
+	trader := &FuturesTrader{client: client}
+
+	// 同一客户端订单ID总是生成同一个带br前缀的订单ID
+	id := brClientOrderID("a1b2c3d4e5f6a7b8c9d0")
+	assert.Equal(t, id, brClientOrderID("a1b2c3d4e5f6a7b8c9d0"))
+	assert.True(t, strings.HasPrefix(id, "x-KzrpZaP9"))
+	assert.LessOrEqual(t, len(id), 32)
+
+	order, found, err := trader.QueryOrderByClientID("BTCUSDT", "a1b2c3d4e5f6a7b8c9d0")
+	assert.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, int64(42), order["orderId"])
+	assert.Equal(t, "FILLED", order["status"])
+	assert.InDelta(t, 50000.5, order["avgPrice"].(float64), 1e-9)
+
+	_, found, err = trader.QueryOrderByClientID("BTCUSDT", "ffffffffffffffffffff")
+	assert.NoError(t, err)
+	assert.False(t, found)
+}
+
+// newPositionModeTestTrader 创建连接到 mock 服务器的币安交易器：账户持仓模式为 dualSide，记录所有下单参数和持仓模式切换请求
+func newPositionModeTestTrader(t *testing.T, dualSide bool, positions []map[string]interface{}) (*FuturesTrader, *[]map[string]string, *int) {
+	var orders []map[string]string
+	modeChanges := 0
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.URL.Path == "/fapi/v1/positionSide/dual" && r.Method == http.MethodGet:
+			json.NewEncoder(w).Encode(map[string]interface{}{"dualSidePosition": dualSide})
+		case r.URL.Path == "/fapi/v1/positionSide/dual":
+			modeChanges++
+			json.NewEncoder(w).Encode(map[string]interface{}{"code": 200, "msg": "success"})
+		case r.URL.Path == "/fapi/v1/order" && r.Method == http.MethodPost:
+			r.ParseForm()
+			orders = append(orders, map[string]string{
+				"side":          r.FormValue("side"),
+				"positionSide":  r.FormValue("positionSide"),
+				"reduceOnly":    r.FormValue("reduceOnly"),
+				"closePosition": r.FormValue("closePosition"),
+			})
+			json.NewEncoder(w).Encode(map[string]interface{}{"orderId": len(orders), "symbol": r.FormValue("symbol"), "status": "FILLED"})
+		case r.URL.Path == "/fapi/v2/positionRisk":
+			json.NewEncoder(w).Encode(positions)
+		case r.URL.Path == "/fapi/v1/exchangeInfo":
+			json.NewEncoder(w).Encode(map[string]interface{}{"symbols": []map[string]interface{}{{
+				"symbol": "BTCUSDT", "status": "TRADING", "quantityPrecision": 3,
+				"filters": []map[string]interface{}{{"filterType": "LOT_SIZE", "minQty": "0.001", "maxQty": "1000", "stepSize": "0.001"}},
+			}}})
+		case r.URL.Path == "/fapi/v1/ticker/price" || r.URL.Path == "/fapi/v2/ticker/price":
+			json.NewEncoder(w).Encode([]map[string]interface{}{{"symbol": "BTCUSDT", "price": "50000"}})
+		case r.URL.Path == "/fapi/v1/leverage":
+			json.NewEncoder(w).Encode(map[string]interface{}{"leverage": 5, "symbol": "BTCUSDT"})
+		case r.URL.Path == "/fapi/v1/allOpenOrders":
+			json.NewEncoder(w).Encode(map[string]interface{}{"code": 200, "msg": "ok"})
+		default:
+			json.NewEncoder(w).Encode(map[string]interface{}{})
+		}
+	}))
+	t.Cleanup(mockServer.Close)
+
+	client := futures.NewClient("test_api_key", "test_secret_key")
+	client.BaseURL = mockServer.URL
+	client.HTTPClient = mockServer.Client()
+	return &FuturesTrader{client: client}, &orders, &modeChanges
+}
+
+// TestFuturesTrader_OrderParamsByPositionMode 测试按账户持仓模式构造订单：双向持仓指定 LONG/SHORT，
+// 单向持仓使用 BOTH 且平仓单带 reduceOnly；从不切换账户持仓模式
+func TestFuturesTrader_OrderParamsByPositionMode(t *testing.T) {
+	tests := []struct {
+		name     string
+		dualSide bool
+		wantMode string
+		want     []map[string]string // 开多、开空、平多、平空、止损（多）
+	}{
+		{"双向持仓", true, PositionModeHedge, []map[string]string{
+			{"side": "BUY", "positionSide": "LONG", "reduceOnly": "", "closePosition": ""},
+			{"side": "SELL", "positionSide": "SHORT", "reduceOnly": "", "closePosition": ""},
+			{"side": "SELL", "positionSide": "LONG", "reduceOnly": "", "closePosition": ""},
+			{"side": "BUY", "positionSide": "SHORT", "reduceOnly": "", "closePosition": ""},
+			{"side": "SELL", "positionSide": "LONG", "reduceOnly": "", "closePosition": "true"},
+		}},
+		{"单向持仓", false, PositionModeOneWay, []map[string]string{
+			{"side": "BUY", "positionSide": "BOTH", "reduceOnly": "", "closePosition": ""},
+			{"side": "SELL", "positionSide": "BOTH", "reduceOnly": "", "closePosition": ""},
+			{"side": "SELL", "positionSide": "BOTH", "reduceOnly": "true", "closePosition": ""},
+			{"side": "BUY", "positionSide": "BOTH", "reduceOnly": "true", "closePosition": ""},
+			{"side": "SELL", "positionSide": "BOTH", "reduceOnly": "", "closePosition": "true"},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			trader, orders, modeChanges := newPositionModeTestTrader(t, tt.dualSide, nil)
+
+			mode, err := trader.detectPositionMode()
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantMode, mode)
+			assert.Equal(t, tt.wantMode, trader.PositionMode())
+
+			_, err = trader.OpenLong("BTCUSDT", 0.01, 5)
+			assert.NoError(t, err)
+			_, err = trader.OpenShort("BTCUSDT", 0.01, 5)
+			assert.NoError(t, err)
+			_, err = trader.CloseLong("BTCUSDT", 0.01)
+			assert.NoError(t, err)
+			_, err = trader.CloseShort("BTCUSDT", 0.01)
+			assert.NoError(t, err)
+			assert.NoError(t, trader.SetStopLoss("BTCUSDT", "LONG", 0.01, 45000))
+
+			assert.Equal(t, tt.want, *orders)
+			assert.Zero(t, *modeChanges, "不应切换账户持仓模式")
+		})
+	}
+}
+
+// TestFuturesTrader_DetectPositionModeBeforeOrder 测试启动时未检测到持仓模式时在下单前检测
+func TestFuturesTrader_DetectPositionModeBeforeOrder(t *testing.T) {
+	trader, orders, _ := newPositionModeTestTrader(t, false, nil)
+	assert.Empty(t, trader.PositionMode())
+
+	_, err := trader.CloseLong("BTCUSDT", 0.01)
+	assert.NoError(t, err)
+	assert.Equal(t, PositionModeOneWay, trader.PositionMode())
+	assert.Equal(t, "BOTH", (*orders)[0]["positionSide"])
+}
+
+// TestFuturesTrader_GetPositionsByPositionMode 测试两种持仓模式下的持仓对账：
+// 双向持仓同一币种多空分两行返回，单向持仓按数量正负判断方向
+func TestFuturesTrader_GetPositionsByPositionMode(t *testing.T) {
+	row := func(symbol, side, amt string) map[string]interface{} {
+		return map[string]interface{}{"symbol": symbol, "positionSide": side, "positionAmt": amt,
+			"entryPrice": "100", "markPrice": "101", "unRealizedProfit": "1", "leverage": "5", "liquidationPrice": "50"}
+	}
+
+	t.Run("双向持仓", func(t *testing.T) {
+		trader, _, _ := newPositionModeTestTrader(t, true, []map[string]interface{}{
+			row("BTCUSDT", "LONG", "0.5"),
+			row("BTCUSDT", "SHORT", "-0.3"),
+			row("ETHUSDT", "LONG", "0"),
+			row("ETHUSDT", "SHORT", "0"),
+		})
+		positions, err := trader.GetPositions()
+		assert.NoError(t, err)
+		assert.Len(t, positions, 2)
+		assert.Equal(t, "long", positions[0]["side"])
+		assert.Equal(t, 0.5, positions[0]["positionAmt"])
+		assert.Equal(t, "short", positions[1]["side"])
+		assert.Equal(t, -0.3, positions[1]["positionAmt"])
+	})
+
+	t.Run("单向持仓", func(t *testing.T) {
+		trader, _, _ := newPositionModeTestTrader(t, false, []map[string]interface{}{
+			row("BTCUSDT", "BOTH", "-0.3"),
+			row("ETHUSDT", "BOTH", "2"),
+			row("SOLUSDT", "BOTH", "0"),
+		})
+		positions, err := trader.GetPositions()
+		assert.NoError(t, err)
+		assert.Len(t, positions, 2)
+		assert.Equal(t, "short", positions[0]["side"])
+		assert.Equal(t, -0.3, positions[0]["positionAmt"])
+		assert.Equal(t, "long", positions[1]["side"])
+		assert.Equal(t, 2.0, positions[1]["positionAmt"])
+	})
+}
+
+// TestPositionInitialMargin 测试持仓初始保证金：逐仓取逐仓钱包余额，全仓按名义价值/杠杆计算
+func TestPositionInitialMargin(t *testing.T) {
+	assert.InDelta(t, 120.5, positionInitialMargin("isolated", "120.5", "-1000", 10), 1e-9)
+	assert.InDelta(t, 100.0, positionInitialMargin("cross", "0", "-1000", 10), 1e-9)
+	assert.InDelta(t, 100.0, positionInitialMargin("isolated", "", "1000", 10), 1e-9, "逐仓钱包缺失时按名义价值估算")
+	assert.Zero(t, positionInitialMargin("cross", "0", "1000", 0))
+}
@@ -0,0 +1,93 @@
+package trader
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// BlackoutWindow 交易暂停窗口（UTC），用于规避已知的高影响事件（如 CPI、FOMC）
+type BlackoutWindow struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+	Label string    `json:"label,omitempty"`
+}
+
+// Contains 时间是否落在窗口内（含开始，不含结束）
+func (w BlackoutWindow) Contains(t time.Time) bool {
+	return !t.Before(w.Start) && t.Before(w.End)
+}
+
+// String 窗口描述
+func (w BlackoutWindow) String() string {
+	desc := fmt.Sprintf("%s ~ %s UTC", w.Start.UTC().Format("2006-01-02 15:04"), w.End.UTC().Format("2006-01-02 15:04"))
+	if w.Label != "" {
+		desc = w.Label + " " + desc
+	}
+	return desc
+}
+
+// BlackoutConfig 交易暂停窗口配置（默认无窗口）
+// 窗口内禁止新开仓；FlattenPositions 为 true 时进入窗口后平掉所有持仓
+type BlackoutConfig struct {
+	Windows          []BlackoutWindow
+	FlattenPositions bool
+}
+
+// ParseBlackoutWindows 解析交易暂停窗口（JSON数组，如 [{"start":"2026-01-15T13:00:00Z","end":"2026-01-15T14:00:00Z","label":"CPI"}]）
+// 时间统一转换为UTC，结束时间必须晚于开始时间
+func ParseBlackoutWindows(raw string) ([]BlackoutWindow, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return nil, nil
+	}
+
+	var windows []BlackoutWindow
+	if err := json.Unmarshal([]byte(raw), &windows); err != nil {
+		return nil, fmt.Errorf("交易暂停窗口格式错误: %w", err)
+	}
+	for i := range windows {
+		w := &windows[i]
+		if w.Start.IsZero() || w.End.IsZero() {
+			return nil, fmt.Errorf("第 %d 个交易暂停窗口缺少开始或结束时间", i+1)
+		}
+		if !w.End.After(w.Start) {
+			return nil, fmt.Errorf("第 %d 个交易暂停窗口的结束时间必须晚于开始时间", i+1)
+		}
+		w.Start, w.End = w.Start.UTC(), w.End.UTC()
+		w.Label = strings.TrimSpace(w.Label)
+	}
+	return windows, nil
+}
+
+// activeBlackout 获取当前所处的交易暂停窗口，不在窗口内时返回nil
+func (at *AutoTrader) activeBlackout(now time.Time) *BlackoutWindow {
+	for i := range at.config.Blackout.Windows {
+		if w := at.config.Blackout.Windows[i]; w.Contains(now) {
+			return &w
+		}
+	}
+	return nil
+}
+
+// checkBlackout 开仓前检查是否处于交易暂停窗口
+func (at *AutoTrader) checkBlackout(now time.Time) error {
+	if w := at.activeBlackout(now); w != nil {
+		return reject(RejectBlackout, fmt.Errorf("❌ 交易暂停窗口（%s）内禁止新开仓", w))
+	}
+	return nil
+}
+
+// flattenForBlackout 处于交易暂停窗口且配置了清仓时平掉所有持仓，返回执行日志
+func (at *AutoTrader) flattenForBlackout(now time.Time) []string {
+	if !at.config.Blackout.FlattenPositions {
+		return nil
+	}
+	w := at.activeBlackout(now)
+	if w == nil {
+		return nil
+	}
+
+	return at.closeAllPositions(fmt.Sprintf("交易暂停窗口（%s）", w))
+}
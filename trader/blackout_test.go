@@ -0,0 +1,32 @@
+package trader
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestParseBlackoutWindows 测试交易暂停窗口解析（转换为UTC，拒绝无效窗口）
+func TestParseBlackoutWindows(t *testing.T) {
+	windows, err := ParseBlackoutWindows(`[{"start":"2026-01-15T21:30:00+08:00","end":"2026-01-15T14:30:00Z","label":" CPI "}]`)
+	require.NoError(t, err)
+	require.Len(t, windows, 1)
+	assert.Equal(t, time.Date(2026, 1, 15, 13, 30, 0, 0, time.UTC), windows[0].Start)
+	assert.Equal(t, time.UTC, windows[0].Start.Location())
+	assert.Equal(t, "CPI", windows[0].Label)
+
+	windows, err = ParseBlackoutWindows("  ")
+	assert.NoError(t, err)
+	assert.Empty(t, windows)
+
+	for _, raw := range []string{
+		`not json`,
+		`[{"start":"2026-01-15T14:00:00Z"}]`,
+		`[{"start":"2026-01-15T14:00:00Z","end":"2026-01-15T13:00:00Z"}]`,
+	} {
+		_, err := ParseBlackoutWindows(raw)
+		assert.Error(t, err, raw)
+	}
+}
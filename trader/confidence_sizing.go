@@ -0,0 +1,64 @@
+package trader
+
+import (
+	"fmt"
+	"math"
+
+	"aspen/decision"
+	"aspen/logger"
+)
+
+// ConfidenceSizingConfig 按AI信心度确定开仓仓位（默认关闭）：
+// 仓位 = MinNotional + (MaxNotional - MinNotional) × (信心度/100)^Exponent，
+// Exponent 为 1 时线性（信心度 50 对应中点），大于 1 时低信心度的仓位增长更慢
+type ConfidenceSizingConfig struct {
+	MinNotional float64 // 信心度 0 对应的仓位金额，0 表示不启用
+	MaxNotional float64 // 信心度 100 对应的仓位金额（需不小于 MinNotional）
+	Exponent    float64 // 信心度曲线指数（>0）
+}
+
+// DefaultConfidenceSizingConfig 默认信心度仓位参数（未启用）
+func DefaultConfidenceSizingConfig() ConfidenceSizingConfig {
+	return ConfidenceSizingConfig{Exponent: 1}
+}
+
+// Enabled 是否启用（需配置有效的仓位区间和指数）
+func (c ConfidenceSizingConfig) Enabled() bool {
+	return c.MinNotional > 0 && c.MaxNotional >= c.MinNotional && c.Exponent > 0
+}
+
+// SizeFor 按信心度（0-100，超出范围时截断）计算仓位金额
+func (c ConfidenceSizingConfig) SizeFor(confidence int) float64 {
+	ratio := math.Min(math.Max(float64(confidence), 0), 100) / 100
+	return c.MinNotional + (c.MaxNotional-c.MinNotional)*math.Pow(ratio, c.Exponent)
+}
+
+// applyConfidenceSizing 按决策的信心度替换开仓/加仓仓位，并限制在可用余额 × 杠杆以内，调整写入 actionRecord.Adjustments
+// （决策未给出信心度时保留原仓位；之后的动态风控和分配上限照常收紧）
+func (at *AutoTrader) applyConfidenceSizing(d *decision.Decision, actionRecord *logger.DecisionAction) error {
+	cfg := at.config.ConfidenceSizing
+	if !cfg.Enabled() || d.Confidence <= 0 {
+		return nil
+	}
+
+	unit := at.getStablecoinUnit()
+	size := cfg.SizeFor(d.Confidence)
+	adjustment := fmt.Sprintf("信心度仓位（信心度 %d）: 仓位 %.2f → %.2f %s", d.Confidence, d.PositionSizeUSD, size, unit)
+
+	balance, err := at.trader.GetBalance()
+	if err != nil {
+		return fmt.Errorf("获取账户余额失败: %w", err)
+	}
+	available, _ := balance["availableBalance"].(float64)
+	if d.Leverage > 0 && available > 0 {
+		if limit := available * float64(d.Leverage); size > limit {
+			size = limit
+			adjustment += fmt.Sprintf("（受可用余额 %.2f × %dx 限制为 %.2f %s）", available, d.Leverage, size, unit)
+		}
+	}
+
+	logger.Infof("  📏 %s %s", d.Symbol, adjustment)
+	actionRecord.Adjustments = append(actionRecord.Adjustments, adjustment)
+	d.PositionSizeUSD = size
+	return nil
+}
@@ -0,0 +1,83 @@
+package trader
+
+import (
+	"testing"
+
+	"aspen/decision"
+	"aspen/logger"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// confidenceSizingTrader 启用信心度仓位（100-1000）的交易员，可用余额 availableBalance
+func confidenceSizingTrader(t *testing.T, availableBalance float64) *AutoTrader {
+	exchange := &MockTrader{balance: map[string]interface{}{
+		"totalWalletBalance":    10000.0,
+		"availableBalance":      availableBalance,
+		"totalUnrealizedProfit": 0.0,
+	}}
+	at := newJournalTestTrader(t.TempDir(), exchange)
+	at.config.ConfidenceSizing = ConfidenceSizingConfig{MinNotional: 100, MaxNotional: 1000, Exponent: 1}
+	return at
+}
+
+// TestConfidenceSizing_MapsConfidenceToSize 测试信心度 50 对应中点仓位、100 对应最大仓位，指数曲线缩小低信心度仓位
+func TestConfidenceSizing_MapsConfidenceToSize(t *testing.T) {
+	tests := []struct {
+		name       string
+		exponent   float64
+		confidence int
+		want       float64
+	}{
+		{"线性中点", 1, 50, 550},
+		{"最大仓位", 1, 100, 1000},
+		{"信心度超过100截断", 1, 120, 1000},
+		{"指数曲线", 2, 50, 325},
+		{"指数曲线最大仓位", 2, 100, 1000},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			at := confidenceSizingTrader(t, 8000)
+			at.config.ConfidenceSizing.Exponent = tt.exponent
+			d := &decision.Decision{Symbol: "BTCUSDT", Action: "open_long", Leverage: 5, PositionSizeUSD: 3000, Confidence: tt.confidence}
+			record := &logger.DecisionAction{}
+
+			require.NoError(t, at.applyConfidenceSizing(d, record))
+			assert.InDelta(t, tt.want, d.PositionSizeUSD, 1e-9)
+			require.Len(t, record.Adjustments, 1)
+			assert.Contains(t, record.Adjustments[0], "信心度仓位")
+		})
+	}
+}
+
+// TestConfidenceSizing_ClampedToAvailableBalance 测试信心度仓位不超过可用余额 × 杠杆
+func TestConfidenceSizing_ClampedToAvailableBalance(t *testing.T) {
+	at := confidenceSizingTrader(t, 50)
+	d := &decision.Decision{Symbol: "BTCUSDT", Action: "open_long", Leverage: 5, PositionSizeUSD: 3000, Confidence: 100}
+	record := &logger.DecisionAction{}
+
+	require.NoError(t, at.applyConfidenceSizing(d, record))
+	assert.Equal(t, 250.0, d.PositionSizeUSD)
+	require.Len(t, record.Adjustments, 1)
+	assert.Contains(t, record.Adjustments[0], "受可用余额 50.00 × 5x 限制")
+}
+
+// TestConfidenceSizing_KeepsSizeWhenDisabledOrNoConfidence 测试未启用或决策未给出信心度时保留原仓位
+func TestConfidenceSizing_KeepsSizeWhenDisabledOrNoConfidence(t *testing.T) {
+	assert.False(t, DefaultConfidenceSizingConfig().Enabled())
+	assert.False(t, ConfidenceSizingConfig{MinNotional: 500, MaxNotional: 100, Exponent: 1}.Enabled())
+
+	at := confidenceSizingTrader(t, 8000)
+	d := &decision.Decision{Symbol: "BTCUSDT", Action: "open_long", Leverage: 5, PositionSizeUSD: 3000}
+	record := &logger.DecisionAction{}
+	require.NoError(t, at.applyConfidenceSizing(d, record))
+	assert.Equal(t, 3000.0, d.PositionSizeUSD)
+	assert.Empty(t, record.Adjustments)
+
+	at.config.ConfidenceSizing = DefaultConfidenceSizingConfig()
+	d.Confidence = 90
+	require.NoError(t, at.applyConfidenceSizing(d, record))
+	assert.Equal(t, 3000.0, d.PositionSizeUSD)
+}
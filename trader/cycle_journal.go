@@ -0,0 +1,326 @@
+package trader
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"time"
+
+	"aspen/decision"
+	"aspen/logger"
+)
+
+// 周期执行的故障注入点（测试中用于模拟进程在各阶段之间被终止）
+const (
+	faultAfterCycleStarted   = "cycle_started"   // 已写入周期开始记录，AI尚未响应
+	faultAfterAIResponse     = "ai_response"     // 已写入AI响应，尚未执行任何动作
+	faultAfterOrderExecuted  = "order_executed"  // 动作已执行，执行结果尚未写入周期日志
+	faultAfterOrderJournaled = "order_journaled" // 动作执行结果已写入周期日志
+)
+
+// faultHookFunc 故障注入回调，返回错误时模拟进程在该点被终止（index 为动作序号，非动作阶段为 -1）
+type faultHookFunc func(point string, index int) error
+
+// injectFault 触发故障注入点（未设置 faultHook 时为空操作）
+func (at *AutoTrader) injectFault(point string, index int) error {
+	if at.faultHook == nil {
+		return nil
+	}
+	return at.faultHook(point, index)
+}
+
+// newCycleID 生成周期ID（周期开始时间的毫秒时间戳）
+func newCycleID(startedAt time.Time) string {
+	return strconv.FormatInt(startedAt.UnixMilli(), 10)
+}
+
+// cycleClientOrderID 按交易员、周期和决策序号生成确定的客户端订单ID（20位十六进制）
+// 重启后可由周期日志中的计划动作重新得到同一个ID，据此向交易所查询订单
+func cycleClientOrderID(traderID, cycleID string, index int) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s/%s/%d", traderID, cycleID, index)))
+	return hex.EncodeToString(sum[:])[:20]
+}
+
+// isOrderAction 是否为会向交易所下单的动作
+func isOrderAction(action string) bool {
+	switch action {
+	case "open_long", "open_short", "add_to_position", "close_long", "close_short", "partial_close":
+		return true
+	}
+	return false
+}
+
+// openLong 开多仓（启用按金额下单时花费 quoteQuantity；交易器支持时都使用确定的客户端订单ID），成交后按参考价格记录滑点
+func (at *AutoTrader) openLong(symbol string, quantity, quoteQuantity float64, leverage int, clientOrderID string, ref priceRef) (map[string]interface{}, error) {
+	var order map[string]interface{}
+	var err error
+	if t, ok := at.quoteClientOrderIDTrader(); ok && quoteQuantity > 0 && clientOrderID != "" {
+		order, err = t.OpenLongQuoteWithClientID(symbol, quoteQuantity, leverage, clientOrderID)
+	} else if t, ok := at.quoteQuantityTrader(); ok && quoteQuantity > 0 {
+		order, err = t.OpenLongQuote(symbol, quoteQuantity, leverage)
+	} else if t, ok := at.trader.(ClientOrderIDTrader); ok && clientOrderID != "" {
+		order, err = t.OpenLongWithClientID(symbol, quantity, leverage, clientOrderID)
+	} else {
+		order, err = at.trader.OpenLong(symbol, quantity, leverage)
+	}
+	if err == nil {
+		at.recordFill("open_long", symbol, ref, order)
+		at.emitOrderWebhook("open_long", symbol, leverage, order)
+	}
+	return order, err
+}
+
+// openShort 开空仓（启用按金额下单时花费 quoteQuantity；交易器支持时都使用确定的客户端订单ID），成交后按参考价格记录滑点
+func (at *AutoTrader) openShort(symbol string, quantity, quoteQuantity float64, leverage int, clientOrderID string, ref priceRef) (map[string]interface{}, error) {
+	var order map[string]interface{}
+	var err error
+	if t, ok := at.quoteClientOrderIDTrader(); ok && quoteQuantity > 0 && clientOrderID != "" {
+		order, err = t.OpenShortQuoteWithClientID(symbol, quoteQuantity, leverage, clientOrderID)
+	} else if t, ok := at.quoteQuantityTrader(); ok && quoteQuantity > 0 {
+		order, err = t.OpenShortQuote(symbol, quoteQuantity, leverage)
+	} else if t, ok := at.trader.(ClientOrderIDTrader); ok && clientOrderID != "" {
+		order, err = t.OpenShortWithClientID(symbol, quantity, leverage, clientOrderID)
+	} else {
+		order, err = at.trader.OpenShort(symbol, quantity, leverage)
+	}
+	if err == nil {
+		at.recordFill("open_short", symbol, ref, order)
+		at.emitOrderWebhook("open_short", symbol, leverage, order)
+	}
+	return order, err
+}
+
+// closeLong 平多仓（交易器支持时使用确定的客户端订单ID），成交后按参考价格记录滑点
+func (at *AutoTrader) closeLong(symbol string, quantity float64, clientOrderID string, ref priceRef) (map[string]interface{}, error) {
+	var order map[string]interface{}
+	var err error
+	if t, ok := at.trader.(ClientOrderIDTrader); ok && clientOrderID != "" {
+		order, err = t.CloseLongWithClientID(symbol, quantity, clientOrderID)
+	} else {
+		order, err = at.trader.CloseLong(symbol, quantity)
+	}
+	if err == nil {
+		at.recordFill("close_long", symbol, ref, order)
+		at.emitOrderWebhook("close_long", symbol, 0, order)
+	}
+	return order, err
+}
+
+// closeShort 平空仓（交易器支持时使用确定的客户端订单ID），成交后按参考价格记录滑点
+func (at *AutoTrader) closeShort(symbol string, quantity float64, clientOrderID string, ref priceRef) (map[string]interface{}, error) {
+	var order map[string]interface{}
+	var err error
+	if t, ok := at.trader.(ClientOrderIDTrader); ok && clientOrderID != "" {
+		order, err = t.CloseShortWithClientID(symbol, quantity, clientOrderID)
+	} else {
+		order, err = at.trader.CloseShort(symbol, quantity)
+	}
+	if err == nil {
+		at.recordFill("close_short", symbol, ref, order)
+		at.emitOrderWebhook("close_short", symbol, 0, order)
+	}
+	return order, err
+}
+
+// executeCycleDecisions 按顺序执行决策，并将计划动作和每个动作的执行结果写入周期日志
+// 只有故障注入点模拟进程终止时返回错误，单个决策执行失败记录在 actionRecord 中
+func (at *AutoTrader) executeCycleDecisions(journal *logger.CycleJournal, cycleID string, record *logger.DecisionRecord, decisions []decision.Decision, positions []decision.PositionInfo) error {
+	planned := make([]logger.DecisionAction, len(decisions))
+	for i, d := range decisions {
+		planned[i] = logger.DecisionAction{
+			Action:   d.Action,
+			Symbol:   d.Symbol,
+			Leverage: d.Leverage,
+			Note:     d.Note,
+		}
+		if isOrderAction(d.Action) {
+			planned[i].ClientOrderID = cycleClientOrderID(at.id, cycleID, i)
+		}
+		if d.Action == "open_long" || d.Action == "open_short" {
+			// 在风控调整仓位之前按AI给出的原始决策确定意图键，并随计划动作写入周期日志
+			planned[i].IntentKey = at.openIntentKey(&d)
+		}
+	}
+	if err := journal.RecordAIResponse(record, planned); err != nil {
+		logger.Warnf("⚠ 写入周期日志失败: %v", err)
+	}
+	if err := at.injectFault(faultAfterAIResponse, -1); err != nil {
+		return err
+	}
+
+	for i, d := range decisions {
+		actionRecord := planned[i]
+		actionRecord.Timestamp = time.Now()
+
+		if err := at.executeDecisionWithRecord(&d, &actionRecord); err != nil {
+			logger.Errorf("❌ 执行决策失败 (%s %s): %v", d.Symbol, d.Action, err)
+			actionRecord.Error = err.Error()
+			if decision.RejectionCode(err) == "" {
+				// 风控检查拒绝是预期行为，不计入错误记录
+				at.RecordError(ErrorKindExecution, d.Symbol, d.Action, err)
+			}
+			record.ExecutionLog = append(record.ExecutionLog, fmt.Sprintf("❌ %s %s 失败: %v", d.Symbol, d.Action, err))
+		} else {
+			actionRecord.Success = true
+			record.ExecutionLog = append(record.ExecutionLog, fmt.Sprintf("✓ %s %s 成功", d.Symbol, d.Action))
+			at.recordSymbolAction(&actionRecord, positions)
+			at.observeLossCooldown(&actionRecord, actionRecord.Timestamp)
+			at.observeLastAction(&actionRecord, actionRecord.Timestamp)
+			// 成功执行后短暂延迟
+			time.Sleep(1 * time.Second)
+		}
+
+		if actionRecord.GuardVerdict != "" {
+			record.ExecutionLog = append(record.ExecutionLog, fmt.Sprintf("🛡 %s 防反复开平仓检查（%s）", d.Symbol, actionRecord.GuardVerdict))
+		}
+
+		if err := at.injectFault(faultAfterOrderExecuted, i); err != nil {
+			return err
+		}
+		if err := journal.RecordOrder(i, actionRecord); err != nil {
+			logger.Warnf("⚠ 写入周期日志失败: %v", err)
+		}
+		if err := at.injectFault(faultAfterOrderJournaled, i); err != nil {
+			return err
+		}
+
+		record.Decisions = append(record.Decisions, actionRecord)
+	}
+	return nil
+}
+
+// RecoverInterruptedCycles 启动时对账上次进程在周期执行中被终止而未结束的周期：
+// 标记为中断，按客户端订单ID向交易所确认未记录结果的订单是否已提交，补全决策记录并发出通知。
+// 不会自动重新执行未执行的决策。返回处理的周期数
+func (at *AutoTrader) RecoverInterruptedCycles() int {
+	pending, err := at.decisionLogger.PendingCycles()
+	if err != nil {
+		logger.Warnf("⚠️ [%s] 读取未结束的周期失败: %v", at.name, err)
+		return 0
+	}
+
+	recovered := 0
+	for _, p := range pending {
+		record, summary := at.reconcileInterruptedCycle(p)
+		if err := at.decisionLogger.CompleteInterruptedCycle(p, record); err != nil {
+			logger.Warnf("⚠️ [%s] 保存中断周期 %s 的记录失败: %v", at.name, p.ID, err)
+			continue
+		}
+		// 已确认成交的动作同样计入币种动作历史、开仓意图和持仓ID
+		records := []*logger.DecisionRecord{record}
+		at.symbolHistory.restoreFromRecords(records)
+		at.intents.restoreFromRecords(at.id, records)
+		at.positionMetaMutex.Lock()
+		at.positionIDs.restoreFromRecords(records)
+		at.positionMetaMutex.Unlock()
+
+		logger.Warnf("⚠️ [%s] 周期 %s（%s 开始）执行中进程重启，已标记为中断并完成对账：%s",
+			at.name, p.ID, p.StartedAt.Format("2006-01-02 15:04:05"), summary)
+		recovered++
+	}
+	return recovered
+}
+
+// reconcileInterruptedCycle 根据周期日志和交易所订单补全中断周期的决策记录，返回记录和对账摘要
+func (at *AutoTrader) reconcileInterruptedCycle(p *logger.PendingCycle) (*logger.DecisionRecord, string) {
+	record := p.Record
+	record.Interrupted = true
+	record.Success = false
+	record.Decisions = nil
+
+	if p.Stage == logger.JournalStageStarted {
+		record.ErrorMessage = "周期执行中进程重启，AI响应前中断"
+		record.ExecutionLog = append(record.ExecutionLog, "⚠️ 周期中断：AI响应前进程重启，未执行任何决策")
+		return record, "AI响应前中断，未执行任何决策"
+	}
+	record.ErrorMessage = "周期执行中进程重启，周期已中断（未执行的决策不会自动重新执行）"
+
+	var journaled, confirmed, notSubmitted, unknown int
+	for i, planned := range p.Planned {
+		if action, ok := p.Executed[i]; ok {
+			journaled++
+			record.Decisions = append(record.Decisions, action)
+			continue
+		}
+
+		action := at.reconcilePlannedAction(planned)
+		action.Timestamp = p.StartedAt
+		switch {
+		case action.Success:
+			confirmed++
+			record.ExecutionLog = append(record.ExecutionLog, fmt.Sprintf("✓ %s %s 重启后对账：订单已成交", action.Symbol, action.Action))
+			if action.Action == "open_long" || action.Action == "open_short" {
+				record.ExecutionLog = append(record.ExecutionLog, fmt.Sprintf("⚠️ %s %s 开仓后中断，止损止盈可能未设置", action.Symbol, action.Action))
+			}
+		case action.Error == interruptedNotExecuted:
+			// hold/wait 无需执行
+		case action.Error == interruptedNotSubmitted:
+			notSubmitted++
+			record.ExecutionLog = append(record.ExecutionLog, fmt.Sprintf("❌ %s %s 重启后对账：订单未提交", action.Symbol, action.Action))
+		default:
+			unknown++
+			record.ExecutionLog = append(record.ExecutionLog, fmt.Sprintf("⚠️ %s %s 重启后对账：%s", action.Symbol, action.Action, action.Error))
+		}
+		record.Decisions = append(record.Decisions, action)
+	}
+
+	summary := fmt.Sprintf("计划 %d 个动作，已记录 %d 个，交易所确认成交 %d 个，未提交 %d 个，结果未知 %d 个",
+		len(p.Planned), journaled, confirmed, notSubmitted, unknown)
+	return record, summary
+}
+
+// 中断周期中未记录结果的动作的错误说明
+const (
+	interruptedNotSubmitted = "周期中断，订单未提交"
+	interruptedNotExecuted  = "周期中断，未执行"
+	interruptedUnconfirmed  = "周期中断，止盈止损调整无法确认是否已执行"
+	interruptedUnknown      = "周期中断，交易器不支持按客户端订单ID查询，执行结果未知"
+)
+
+// reconcilePlannedAction 按客户端订单ID向交易所确认计划动作是否已执行
+func (at *AutoTrader) reconcilePlannedAction(planned logger.DecisionAction) logger.DecisionAction {
+	action := planned
+	switch {
+	case action.Action == "hold" || action.Action == "wait":
+		action.Error = interruptedNotExecuted
+		return action
+	case !isOrderAction(action.Action):
+		action.Error = interruptedUnconfirmed
+		return action
+	}
+
+	lookup, ok := at.trader.(ClientOrderIDTrader)
+	if !ok || action.ClientOrderID == "" {
+		action.Error = interruptedUnknown
+		return action
+	}
+
+	order, found, err := lookup.QueryOrderByClientID(action.Symbol, action.ClientOrderID)
+	if err != nil {
+		action.Error = fmt.Sprintf("周期中断，查询订单失败，执行结果未知: %v", err)
+		return action
+	}
+	if !found {
+		action.Error = interruptedNotSubmitted
+		return action
+	}
+
+	status, _ := order["status"].(string)
+	switch status {
+	case "CANCELED", "EXPIRED", "REJECTED":
+		action.Error = fmt.Sprintf("周期中断，订单已提交但未成交（%s）", status)
+		return action
+	}
+	action.Success = true
+	if orderID, ok := order["orderId"].(int64); ok {
+		action.OrderID = orderID
+	}
+	if price, ok := order["avgPrice"].(float64); ok && price > 0 {
+		action.Price = price
+	}
+	if qty, ok := order["executedQty"].(float64); ok && qty > 0 {
+		action.Quantity = qty
+	}
+	return action
+}
@@ -0,0 +1,207 @@
+package trader
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"aspen/decision"
+	"aspen/logger"
+	"aspen/market"
+	"aspen/metrics"
+
+	"github.com/agiledragon/gomonkey/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// errSimulatedKill 模拟进程被终止
+var errSimulatedKill = errors.New("simulated kill")
+
+// clientIDMockTrader 支持客户端订单ID的模拟交易所（记录实际提交的订单，进程"重启"后仍然保留）
+type clientIDMockTrader struct {
+	*MockTrader
+	orders map[string]map[string]interface{} // clientOrderID -> 订单
+}
+
+func newClientIDMockTrader() *clientIDMockTrader {
+	return &clientIDMockTrader{
+		MockTrader: &MockTrader{positions: []map[string]interface{}{}},
+		orders:     make(map[string]map[string]interface{}),
+	}
+}
+
+func (m *clientIDMockTrader) place(symbol, clientOrderID string, order map[string]interface{}, err error) (map[string]interface{}, error) {
+	if err != nil {
+		return nil, err
+	}
+	m.orders[clientOrderID] = map[string]interface{}{
+		"orderId":     order["orderId"],
+		"symbol":      symbol,
+		"status":      "FILLED",
+		"avgPrice":    50000.0,
+		"executedQty": 0.1,
+	}
+	return order, nil
+}
+
+func (m *clientIDMockTrader) OpenLongWithClientID(symbol string, quantity float64, leverage int, clientOrderID string) (map[string]interface{}, error) {
+	order, err := m.OpenLong(symbol, quantity, leverage)
+	return m.place(symbol, clientOrderID, order, err)
+}
+
+func (m *clientIDMockTrader) OpenShortWithClientID(symbol string, quantity float64, leverage int, clientOrderID string) (map[string]interface{}, error) {
+	order, err := m.OpenShort(symbol, quantity, leverage)
+	return m.place(symbol, clientOrderID, order, err)
+}
+
+func (m *clientIDMockTrader) CloseLongWithClientID(symbol string, quantity float64, clientOrderID string) (map[string]interface{}, error) {
+	order, err := m.CloseLong(symbol, quantity)
+	return m.place(symbol, clientOrderID, order, err)
+}
+
+func (m *clientIDMockTrader) CloseShortWithClientID(symbol string, quantity float64, clientOrderID string) (map[string]interface{}, error) {
+	order, err := m.CloseShort(symbol, quantity)
+	return m.place(symbol, clientOrderID, order, err)
+}
+
+func (m *clientIDMockTrader) QueryOrderByClientID(symbol, clientOrderID string) (map[string]interface{}, bool, error) {
+	order, ok := m.orders[clientOrderID]
+	return order, ok, nil
+}
+
+// newJournalTestTrader 构造使用指定决策日志目录和交易所的 AutoTrader（同一目录再次构造即模拟重启）
+func newJournalTestTrader(logDir string, exchange Trader) *AutoTrader {
+	return &AutoTrader{
+		id:                    "journal_trader",
+		name:                  "Journal Trader",
+		trader:                exchange,
+		decisionLogger:        logger.NewDecisionLogger(logDir),
+		metricsRecorder:       metrics.NewTradingMetricsRecorder("journal_trader", "binance"),
+		positionFirstSeenTime: make(map[string]int64),
+		peakPnLCache:          make(map[string]float64),
+	}
+}
+
+// TestCycleJournal_RecoverAfterKill 测试进程在周期各阶段之间被终止后，重启对账得到的记录与交易所实际执行情况一致
+func TestCycleJournal_RecoverAfterKill(t *testing.T) {
+	patches := gomonkey.ApplyFunc(market.Get, func(symbol string) (*market.Data, error) {
+		return &market.Data{Symbol: symbol, CurrentPrice: 50000.0}, nil
+	})
+	defer patches.Reset()
+
+	decisions := []decision.Decision{
+		{Symbol: "BTCUSDT", Action: "close_long"},
+		{Symbol: "ETHUSDT", Action: "close_short"},
+	}
+
+	tests := []struct {
+		point    string
+		index    int
+		executed int // 被终止前交易所实际执行的订单数
+	}{
+		{faultAfterCycleStarted, -1, 0},
+		{faultAfterAIResponse, -1, 0},
+		{faultAfterOrderExecuted, 0, 1},
+		{faultAfterOrderJournaled, 0, 1},
+		{faultAfterOrderExecuted, 1, 2},
+		{faultAfterOrderJournaled, 1, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s#%d", tt.point, tt.index), func(t *testing.T) {
+			logDir := t.TempDir()
+			exchange := newClientIDMockTrader()
+
+			at := newJournalTestTrader(logDir, exchange)
+			at.faultHook = func(point string, index int) error {
+				if point == tt.point && index == tt.index {
+					return errSimulatedKill
+				}
+				return nil
+			}
+
+			cycleID := newCycleID(time.Now())
+			record := &logger.DecisionRecord{ExecutionLog: []string{}, Success: true, CandidateCoins: []string{"BTCUSDT", "ETHUSDT"}}
+			journal, err := at.decisionLogger.BeginCycle(cycleID, record)
+			require.NoError(t, err)
+			if err := at.injectFault(faultAfterCycleStarted, -1); err == nil {
+				record.InputPrompt = "prompt"
+				record.CoTTrace = "raw ai response"
+				err = at.executeCycleDecisions(journal, cycleID, record, decisions, nil)
+				require.ErrorIs(t, err, errSimulatedKill)
+			}
+			require.Len(t, exchange.orders, tt.executed)
+
+			// 重启：新的进程实例读取同一个决策日志目录，交易所状态保持不变
+			restarted := newJournalTestTrader(logDir, exchange)
+			assert.Equal(t, 1, restarted.RecoverInterruptedCycles())
+
+			pending, err := restarted.decisionLogger.PendingCycles()
+			require.NoError(t, err)
+			assert.Empty(t, pending, "对账完成后周期日志应被删除")
+
+			records, err := restarted.decisionLogger.GetLatestRecords(10)
+			require.NoError(t, err)
+			require.Len(t, records, 1)
+			got := records[0]
+			assert.True(t, got.Interrupted)
+			assert.False(t, got.Success)
+			assert.Equal(t, []string{"BTCUSDT", "ETHUSDT"}, got.CandidateCoins, "应保留周期开始时的快照")
+
+			if tt.point == faultAfterCycleStarted {
+				assert.Empty(t, got.Decisions)
+				assert.Contains(t, got.ErrorMessage, "AI响应前")
+				return
+			}
+			assert.Equal(t, "raw ai response", got.CoTTrace)
+			require.Len(t, got.Decisions, len(decisions))
+			for i, action := range got.Decisions {
+				clientOrderID := cycleClientOrderID("journal_trader", cycleID, i)
+				assert.Equal(t, clientOrderID, action.ClientOrderID)
+				_, executed := exchange.orders[clientOrderID]
+				assert.Equal(t, executed, action.Success, "动作 %d (%s) 的记录应与交易所一致", i, action.Action)
+				if !executed {
+					assert.Equal(t, interruptedNotSubmitted, action.Error)
+				}
+			}
+
+			// 已确认成交的平仓计入币种动作历史
+			assert.Len(t, restarted.symbolHistory.all(), tt.executed)
+
+			// 再次启动不会重复处理
+			assert.Equal(t, 0, newJournalTestTrader(logDir, exchange).RecoverInterruptedCycles())
+		})
+	}
+}
+
+// TestCycleJournal_CompletedAndUnsupported 测试正常结束的周期不留日志，交易器不支持客户端订单ID时记录为结果未知
+func TestCycleJournal_CompletedAndUnsupported(t *testing.T) {
+	logDir := t.TempDir()
+	at := newJournalTestTrader(logDir, &MockTrader{})
+
+	journal, err := at.decisionLogger.BeginCycle("1", &logger.DecisionRecord{})
+	require.NoError(t, err)
+	require.NoError(t, journal.Complete())
+	pending, err := at.decisionLogger.PendingCycles()
+	require.NoError(t, err)
+	assert.Empty(t, pending)
+
+	// 计划了开仓和持有，AI响应后被终止
+	journal, err = at.decisionLogger.BeginCycle("2", &logger.DecisionRecord{})
+	require.NoError(t, err)
+	require.NoError(t, journal.RecordAIResponse(&logger.DecisionRecord{}, []logger.DecisionAction{
+		{Symbol: "BTCUSDT", Action: "open_long", ClientOrderID: cycleClientOrderID(at.id, "2", 0)},
+		{Symbol: "ETHUSDT", Action: "hold"},
+	}))
+
+	assert.Equal(t, 1, at.RecoverInterruptedCycles())
+	records, err := at.decisionLogger.GetLatestRecords(10)
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	require.Len(t, records[0].Decisions, 2)
+	assert.False(t, records[0].Decisions[0].Success)
+	assert.Equal(t, interruptedUnknown, records[0].Decisions[0].Error)
+	assert.Equal(t, interruptedNotExecuted, records[0].Decisions[1].Error)
+}
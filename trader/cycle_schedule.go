@@ -0,0 +1,56 @@
+package trader
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// DefaultCandleAlignDelay 默认在K线收盘后延迟多久执行周期（等待交易所和行情缓存写入收盘K线）
+const DefaultCandleAlignDelay = 2 * time.Second
+
+// CandleAlignConfig 决策周期对齐K线收盘（默认关闭，按扫描间隔固定计时）
+// 启用后每个定时周期在主K线周期收盘后 Delay 执行（如每根3分钟K线收盘后 2 秒），避免在K线中途按未收盘的数据决策；
+// 扫描间隔大于K线周期时，按扫描间隔跳过中间的收盘点
+type CandleAlignConfig struct {
+	Timeframe time.Duration // 对齐的K线周期，0 表示不对齐
+	Delay     time.Duration // 收盘后的延迟
+}
+
+// Enabled 是否对齐K线收盘
+func (c CandleAlignConfig) Enabled() bool {
+	return c.Timeframe > 0
+}
+
+// ParseCandleAlignTimeframe 解析对齐的K线周期（如 3m、15m、1h），空值表示不对齐
+// 周期需为整分钟且能整除一天，与交易所K线的划分一致
+func ParseCandleAlignTimeframe(raw string) (time.Duration, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(raw)
+	if err != nil || d <= 0 || d%time.Minute != 0 || (24*time.Hour)%d != 0 {
+		return 0, fmt.Errorf("无效的对齐K线周期: %s（应为能整除一天的整分钟数，如 3m、15m、1h）", raw)
+	}
+	return d, nil
+}
+
+// nextCandleClose t 之后（不含 t）第一个K线收盘加延迟的时间点（K线按 UTC 整周期划分）
+func (c CandleAlignConfig) nextCandleClose(t time.Time) time.Time {
+	return t.Add(-c.Delay).Truncate(c.Timeframe).Add(c.Timeframe + c.Delay)
+}
+
+// nextScheduledCycle 下一次定时周期的时间：未对齐时为 now 加扫描间隔；
+// 对齐时为距 now 约一个扫描间隔的K线收盘点（扫描间隔不大于K线周期时即下一次收盘）
+func (at *AutoTrader) nextScheduledCycle(now time.Time) time.Time {
+	align := at.config.CandleAlign
+	if !align.Enabled() {
+		return now.Add(at.config.ScanInterval)
+	}
+	from := now.Add(at.config.ScanInterval - align.Timeframe)
+	if from.Before(now) {
+		from = now
+	}
+	return align.nextCandleClose(from)
+}
@@ -0,0 +1,59 @@
+package trader
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestNextScheduledCycle_AlignedToCandleClose 测试启用周期对齐后，按模拟时钟推进的每个定时周期都在3分钟K线收盘后 2 秒执行
+func TestNextScheduledCycle_AlignedToCandleClose(t *testing.T) {
+	at := newJournalTestTrader(t.TempDir(), &MockTrader{})
+	at.config.ScanInterval = 3 * time.Minute
+	at.config.CandleAlign = CandleAlignConfig{Timeframe: 3 * time.Minute, Delay: 2 * time.Second}
+
+	clock := time.Date(2026, 3, 1, 12, 1, 17, 0, time.UTC) // 启动于K线中途
+	var fires []time.Time
+	for i := 0; i < 5; i++ {
+		fire := at.nextScheduledCycle(clock)
+		require.True(t, fire.After(clock))
+		assert.Equal(t, 2*time.Second, fire.Sub(fire.Truncate(3*time.Minute)), "在K线收盘后 2 秒执行: %s", fire)
+		fires = append(fires, fire)
+		clock = fire.Add(5 * time.Millisecond) // 定时器触发稍有延迟
+	}
+	assert.Equal(t, time.Date(2026, 3, 1, 12, 3, 2, 0, time.UTC), fires[0], "第一个周期等到下一次收盘")
+	for i := 1; i < len(fires); i++ {
+		assert.Equal(t, 3*time.Minute, fires[i].Sub(fires[i-1]))
+	}
+
+	// 扫描间隔为两根K线时隔一个收盘点执行
+	at.config.ScanInterval = 6 * time.Minute
+	clock = time.Date(2026, 3, 1, 12, 3, 2, 5e6, time.UTC)
+	assert.Equal(t, time.Date(2026, 3, 1, 12, 9, 2, 0, time.UTC), at.nextScheduledCycle(clock))
+	clock = time.Date(2026, 3, 1, 12, 1, 17, 0, time.UTC)
+	assert.Equal(t, time.Date(2026, 3, 1, 12, 6, 2, 0, time.UTC), at.nextScheduledCycle(clock), "首个周期不超过一个扫描间隔")
+
+	// 刚好在收盘后 1 秒（尚未到执行点）时等到本次收盘点
+	clock = time.Date(2026, 3, 1, 12, 3, 1, 0, time.UTC)
+	at.config.ScanInterval = 3 * time.Minute
+	assert.Equal(t, time.Date(2026, 3, 1, 12, 3, 2, 0, time.UTC), at.nextScheduledCycle(clock))
+
+	// 未启用时按扫描间隔计时
+	at.config.CandleAlign = CandleAlignConfig{}
+	assert.Equal(t, clock.Add(3*time.Minute), at.nextScheduledCycle(clock))
+}
+
+// TestParseCandleAlignTimeframe 测试对齐K线周期解析
+func TestParseCandleAlignTimeframe(t *testing.T) {
+	for raw, want := range map[string]time.Duration{"": 0, "3m": 3 * time.Minute, " 15m ": 15 * time.Minute, "1h": time.Hour, "4h": 4 * time.Hour} {
+		got, err := ParseCandleAlignTimeframe(raw)
+		require.NoError(t, err, raw)
+		assert.Equal(t, want, got, raw)
+	}
+	for _, raw := range []string{"abc", "30s", "7m", "-3m"} {
+		_, err := ParseCandleAlignTimeframe(raw)
+		assert.Error(t, err, raw)
+	}
+}
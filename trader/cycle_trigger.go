@@ -0,0 +1,156 @@
+package trader
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"aspen/logger"
+)
+
+// 决策周期触发方式
+const (
+	TriggerScheduled = "scheduled" // 定时触发
+	TriggerManual    = "manual"    // 手动触发（API run-now）
+)
+
+// defaultManualTriggerCooldown 两次手动触发之间的默认冷却时间
+const defaultManualTriggerCooldown = 60 * time.Second
+
+// ErrTraderNotRunning 交易员未运行时不能手动触发周期
+var ErrTraderNotRunning = errors.New("交易员未运行")
+
+// CycleTrigger 决策周期触发信息
+type CycleTrigger struct {
+	Type        string // 触发方式: scheduled / manual
+	RequestedBy string // 手动触发的用户ID
+}
+
+// CycleInProgressError 已有决策周期正在执行
+type CycleInProgressError struct {
+	CycleNumber int
+}
+
+func (e *CycleInProgressError) Error() string {
+	return fmt.Sprintf("决策周期 #%d 正在执行中", e.CycleNumber)
+}
+
+// ManualTriggerCooldownError 手动触发仍在冷却中
+type ManualTriggerCooldownError struct {
+	Remaining time.Duration
+}
+
+func (e *ManualTriggerCooldownError) Error() string {
+	return fmt.Sprintf("手动触发冷却中，请 %.0f 秒后再试", e.Remaining.Seconds())
+}
+
+// manualTriggerCooldown 获取手动触发冷却时间（未配置时使用默认值）
+func (at *AutoTrader) manualTriggerCooldown() time.Duration {
+	if at.config.ManualTriggerCooldown > 0 {
+		return at.config.ManualTriggerCooldown
+	}
+	return defaultManualTriggerCooldown
+}
+
+// beginCycleLocked 标记新周期开始并分配周期编号（调用方需持有 cycleMutex）
+func (at *AutoTrader) beginCycleLocked() (int, error) {
+	if at.cycleRunning {
+		return 0, &CycleInProgressError{CycleNumber: at.runningCycle}
+	}
+	at.cycleRunning = true
+	at.callCount++
+	at.runningCycle = at.callCount
+	return at.runningCycle, nil
+}
+
+// endCycle 标记当前周期结束
+func (at *AutoTrader) endCycle() {
+	at.cycleMutex.Lock()
+	at.cycleRunning = false
+	at.cycleMutex.Unlock()
+}
+
+// executeCycle 执行一个决策周期（定时与手动触发共用），同一时间只允许一个周期在执行
+func (at *AutoTrader) executeCycle(trigger CycleTrigger) error {
+	at.cycleMutex.Lock()
+	_, err := at.beginCycleLocked()
+	at.cycleMutex.Unlock()
+	if err != nil {
+		return err
+	}
+	defer at.endCycle()
+
+	return at.runCycle(trigger)
+}
+
+// RunNow 立即触发一次决策周期（手动触发）
+// 受冷却时间限制，已有周期在执行时拒绝；reschedule 为 true 时从现在起重新计算定时周期
+// 返回本次周期编号，周期在后台异步执行
+func (at *AutoTrader) RunNow(requestedBy string, reschedule bool) (int, error) {
+	if !at.isRunning {
+		return 0, ErrTraderNotRunning
+	}
+
+	at.cycleMutex.Lock()
+	if at.cycleRunning {
+		running := at.runningCycle
+		at.cycleMutex.Unlock()
+		return 0, &CycleInProgressError{CycleNumber: running}
+	}
+	if !at.lastManualTrigger.IsZero() {
+		if remaining := at.manualTriggerCooldown() - time.Since(at.lastManualTrigger); remaining > 0 {
+			at.cycleMutex.Unlock()
+			return 0, &ManualTriggerCooldownError{Remaining: remaining}
+		}
+	}
+	cycle, err := at.beginCycleLocked()
+	if err != nil {
+		at.cycleMutex.Unlock()
+		return 0, err
+	}
+	at.lastManualTrigger = time.Now()
+	at.cycleMutex.Unlock()
+
+	if reschedule {
+		at.requestReschedule()
+	}
+
+	logger.Infof("[%s] ▶️  用户 %s 手动触发决策周期 #%d (reschedule=%v)", at.name, requestedBy, cycle, reschedule)
+
+	go func() {
+		defer at.endCycle()
+		if err := at.runCycle(CycleTrigger{Type: TriggerManual, RequestedBy: requestedBy}); err != nil {
+			logger.Errorf("❌ 手动触发周期执行失败: %v", err)
+		}
+	}()
+
+	return cycle, nil
+}
+
+// requestReschedule 通知主循环从现在起重新计算定时周期（非阻塞）
+func (at *AutoTrader) requestReschedule() {
+	at.cycleMutex.Lock()
+	ch := at.rescheduleCh
+	at.cycleMutex.Unlock()
+	if ch == nil {
+		return
+	}
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
+
+// setNextCycleAt 记录下一次定时周期时间
+func (at *AutoTrader) setNextCycleAt(t time.Time) {
+	at.cycleMutex.Lock()
+	at.nextCycleAt = t
+	at.cycleMutex.Unlock()
+}
+
+// GetNextCycleAt 获取下一次定时周期时间（未运行时为零值）
+func (at *AutoTrader) GetNextCycleAt() time.Time {
+	at.cycleMutex.Lock()
+	defer at.cycleMutex.Unlock()
+	return at.nextCycleAt
+}
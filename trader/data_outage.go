@@ -0,0 +1,178 @@
+package trader
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"aspen/logger"
+)
+
+// 行情中断时的处理方式
+const (
+	DataOutageBlockOpens = "block_opens" // 只禁止新开仓，保留持仓
+	DataOutageFlatten    = "flatten"     // 禁止新开仓并平掉所有持仓
+)
+
+// DataOutageConfig 行情中断保护（默认关闭）
+// 行情超过 MaxAge 未更新（K线不再前进或获取失败）时视为行情中断，中断期间禁止新开仓，
+// Action 为 flatten 时同时平掉所有持仓，避免在看不到行情的情况下持有杠杆仓位；行情恢复后自动解除
+type DataOutageConfig struct {
+	MaxAge time.Duration // 行情允许的最长未更新时长，0 表示不检查
+	Action string        // block_opens / flatten
+}
+
+// DefaultDataOutageConfig 默认行情中断保护参数（未启用）
+func DefaultDataOutageConfig() DataOutageConfig {
+	return DataOutageConfig{Action: DataOutageBlockOpens}
+}
+
+// NormalizeDataOutageAction 校验行情中断处理方式（空值为 block_opens）
+func NormalizeDataOutageAction(action string) (string, error) {
+	switch action = strings.ToLower(strings.TrimSpace(action)); action {
+	case "":
+		return DataOutageBlockOpens, nil
+	case DataOutageBlockOpens, DataOutageFlatten:
+		return action, nil
+	default:
+		return "", fmt.Errorf("无效的行情中断处理方式: %s（可选 %s、%s）", action, DataOutageBlockOpens, DataOutageFlatten)
+	}
+}
+
+// dataFeedState 行情时效监测状态（零值可用）
+type dataFeedState struct {
+	mu          sync.Mutex
+	since       time.Time // 开始监测的时间（尚未取得过行情时从此计算中断时长）
+	lastFreshAt time.Time // 已取得的最新行情对应的时间
+	outage      bool      // 是否处于行情中断状态
+	symbols     []string  // 上次检查使用的币种（清仓后仍据此检查行情是否恢复）
+}
+
+// observe 记录一次取得的行情时间（只前进不后退）
+func (s *dataFeedState) observe(freshAt time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if freshAt.After(s.lastFreshAt) {
+		s.lastFreshAt = freshAt
+	}
+}
+
+// staleFor 行情已未更新的时长
+func (s *dataFeedState) staleFor(now time.Time) time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.since.IsZero() {
+		s.since = now
+	}
+	last := s.lastFreshAt
+	if last.IsZero() {
+		last = s.since
+	}
+	if now.Before(last) {
+		return 0
+	}
+	return now.Sub(last)
+}
+
+// setOutage 更新中断状态，返回是否刚进入中断、是否刚恢复
+func (s *dataFeedState) setOutage(outage bool) (entered, recovered bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entered = outage && !s.outage
+	recovered = !outage && s.outage
+	s.outage = outage
+	return entered, recovered
+}
+
+// inOutage 是否处于行情中断状态
+func (s *dataFeedState) inOutage() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.outage
+}
+
+// dataFeedSymbols 检查行情时效使用的币种：持仓币种，无持仓时为首个交易币种，都没有时沿用上次检查的币种
+func (at *AutoTrader) dataFeedSymbols() []string {
+	var symbols []string
+	if positions, err := at.trader.GetPositions(); err == nil {
+		seen := make(map[string]bool)
+		for _, pos := range positions {
+			if symbol, _ := pos["symbol"].(string); symbol != "" && !seen[symbol] {
+				seen[symbol] = true
+				symbols = append(symbols, symbol)
+			}
+		}
+	}
+	if len(symbols) == 0 {
+		for _, coins := range [][]string{at.tradingCoins, at.defaultCoins} {
+			if len(coins) > 0 {
+				symbols = coins[:1]
+				break
+			}
+		}
+	}
+
+	at.dataFeed.mu.Lock()
+	defer at.dataFeed.mu.Unlock()
+	if len(symbols) == 0 {
+		return at.dataFeed.symbols
+	}
+	at.dataFeed.symbols = symbols
+	return symbols
+}
+
+// probeDataFeed 获取行情并记录其时间（任一币种的行情是新的即认为行情源正常），返回行情已未更新的时长
+func (at *AutoTrader) probeDataFeed(now time.Time) time.Duration {
+	for _, symbol := range at.dataFeedSymbols() {
+		data, err := at.getMarketData(symbol)
+		if err != nil {
+			logger.Warnf("⚠️  行情时效检查：获取 %s 行情失败: %v", symbol, err)
+			continue
+		}
+		age, known := data.Age(now)
+		if !known {
+			age = 0 // 数据源未提供K线时间时，以成功取得行情的时间为准
+		}
+		at.dataFeed.observe(now.Add(-age))
+	}
+	return at.dataFeed.staleFor(now)
+}
+
+// handleDataOutage 每个周期开始时检查行情时效：超过阈值时进入行情中断（禁止开仓，配置了 flatten 时清仓），
+// 行情恢复后解除，返回执行日志
+func (at *AutoTrader) handleDataOutage(now time.Time) []string {
+	cfg := at.config.DataOutage
+	if cfg.MaxAge <= 0 {
+		return nil
+	}
+
+	staleFor := at.probeDataFeed(now)
+	outage := staleFor > cfg.MaxAge
+	entered, recovered := at.dataFeed.setOutage(outage)
+	if recovered {
+		logger.Infof("✅ [%s] 行情数据已恢复，解除行情中断保护", at.name)
+		return []string{"✅ 行情数据已恢复，恢复开仓"}
+	}
+	if !outage {
+		return nil
+	}
+
+	desc := fmt.Sprintf("行情数据已 %s 未更新（阈值 %s）", staleFor.Round(time.Second), cfg.MaxAge)
+	if entered {
+		logger.Warnf("⚠️  [%s] %s，进入行情中断保护（%s）", at.name, desc, cfg.Action)
+	}
+	logs := []string{fmt.Sprintf("⚠️ %s，暂停开仓", desc)}
+	if cfg.Action == DataOutageFlatten {
+		logs = append(logs, at.closeAllPositions("行情中断")...)
+	}
+	return logs
+}
+
+// checkDataOutage 开仓前检查是否处于行情中断状态
+func (at *AutoTrader) checkDataOutage() error {
+	if at.config.DataOutage.MaxAge <= 0 || !at.dataFeed.inOutage() {
+		return nil
+	}
+	return reject(RejectDataOutage, fmt.Errorf("❌ 行情数据中断（超过 %s 未更新），禁止新开仓", at.config.DataOutage.MaxAge))
+}
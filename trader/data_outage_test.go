@@ -0,0 +1,104 @@
+package trader
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"aspen/decision"
+	"aspen/logger"
+	"aspen/market"
+
+	"github.com/agiledragon/gomonkey/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// patchMarketFeed 模拟行情源：返回最新K线时间为 *latest 的行情，*down 为 true 时获取失败
+func patchMarketFeed(latest *time.Time, down *bool) *gomonkey.Patches {
+	return gomonkey.ApplyFunc(market.GetWithIndicators, func(source market.DataSource, symbol string, params market.IndicatorParams) (*market.Data, error) {
+		if *down {
+			return nil, errors.New("feed down")
+		}
+		return &market.Data{Symbol: symbol, CurrentPrice: 100, LatestCandleAt: *latest}, nil
+	})
+}
+
+// TestDataOutage_FlattenPastThreshold 测试行情超过阈值未更新时清仓并禁止开仓，行情恢复后解除
+func TestDataOutage_FlattenPastThreshold(t *testing.T) {
+	t0 := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	latest, down := t0.Add(-time.Minute), false
+	patches := patchMarketFeed(&latest, &down)
+	defer patches.Reset()
+
+	pt := newPricedPaperTrader(t, 10000, map[string]float64{"BTCUSDT": 100})
+	_, err := pt.OpenLong("BTCUSDT", 1, 5)
+	require.NoError(t, err)
+	at := newJournalTestTrader(t.TempDir(), pt)
+	at.config.DataOutage = DataOutageConfig{MaxAge: 10 * time.Minute, Action: DataOutageFlatten}
+
+	assert.Empty(t, at.handleDataOutage(t0))
+
+	// 行情停在 t0 开盘的K线（t0+2m 收盘）：t0+5m 时只过去 3 分钟，尚未超过阈值
+	assert.Empty(t, at.handleDataOutage(t0.Add(5*time.Minute)))
+	assert.Len(t, pt.positions, 1)
+
+	logs := at.handleDataOutage(t0.Add(15 * time.Minute))
+	require.NotEmpty(t, logs)
+	assert.Contains(t, logs[0], "13m0s")
+	assert.Empty(t, pt.positions, "超过阈值后清仓")
+
+	d := &decision.Decision{Symbol: "BTCUSDT", Action: "open_long", Leverage: 5, PositionSizeUSD: 100}
+	err = at.executeOpenLongWithRecord(d, &logger.DecisionAction{})
+	require.Error(t, err)
+	assert.Equal(t, RejectDataOutage, decision.RejectionCode(err))
+
+	// 行情恢复
+	latest = t0.Add(15 * time.Minute)
+	logs = at.handleDataOutage(t0.Add(16 * time.Minute))
+	require.Len(t, logs, 1)
+	assert.Contains(t, logs[0], "恢复")
+	assert.NoError(t, at.checkDataOutage())
+}
+
+// TestDataOutage_BlockOpensKeepsPositions 测试只禁止开仓时保留持仓；行情获取持续失败同样视为中断
+func TestDataOutage_BlockOpensKeepsPositions(t *testing.T) {
+	t0 := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	latest, down := time.Time{}, true
+	patches := patchMarketFeed(&latest, &down)
+	defer patches.Reset()
+
+	pt := newPricedPaperTrader(t, 10000, map[string]float64{"ETHUSDT": 100})
+	_, err := pt.OpenShort("ETHUSDT", 1, 5)
+	require.NoError(t, err)
+	at := newJournalTestTrader(t.TempDir(), pt)
+	at.config.DataOutage = DataOutageConfig{MaxAge: 5 * time.Minute, Action: DataOutageBlockOpens}
+
+	assert.Empty(t, at.handleDataOutage(t0), "从开始监测时计算中断时长")
+	assert.NoError(t, at.checkDataOutage())
+
+	logs := at.handleDataOutage(t0.Add(6 * time.Minute))
+	require.Len(t, logs, 1)
+	assert.Contains(t, logs[0], "暂停开仓")
+	assert.Len(t, pt.positions, 1, "只禁止开仓时保留持仓")
+	err = at.checkDataOutage()
+	require.Error(t, err)
+	assert.Equal(t, RejectDataOutage, decision.RejectionCode(err))
+
+	// 未启用时不检查
+	at.config.DataOutage.MaxAge = 0
+	assert.Empty(t, at.handleDataOutage(t0.Add(time.Hour)))
+	assert.NoError(t, at.checkDataOutage())
+}
+
+// TestNormalizeDataOutageAction 测试行情中断处理方式校验
+func TestNormalizeDataOutageAction(t *testing.T) {
+	action, err := NormalizeDataOutageAction("")
+	require.NoError(t, err)
+	assert.Equal(t, DataOutageBlockOpens, action)
+	action, err = NormalizeDataOutageAction(" Flatten ")
+	require.NoError(t, err)
+	assert.Equal(t, DataOutageFlatten, action)
+	_, err = NormalizeDataOutageAction("panic")
+	assert.Error(t, err)
+}
@@ -0,0 +1,48 @@
+package trader
+
+import (
+	"fmt"
+	"math"
+
+	"aspen/logger"
+)
+
+// closeDustPositions 平掉名义价值低于粉尘阈值的残余持仓（部分平仓、数量取整后残留的极小仓位），返回执行日志
+func (at *AutoTrader) closeDustPositions() []string {
+	threshold := at.config.DustThresholdUSD
+	if threshold <= 0 {
+		return nil
+	}
+
+	positions, err := at.trader.GetPositions()
+	if err != nil {
+		logger.Warnf("⚠️  粉尘仓位清理：获取持仓失败: %v", err)
+		return nil
+	}
+
+	var logs []string
+	for _, pos := range positions {
+		symbol, _ := pos["symbol"].(string)
+		side, _ := pos["side"].(string)
+		quantity, _ := pos["positionAmt"].(float64)
+		markPrice, _ := pos["markPrice"].(float64)
+		quantity = math.Abs(quantity)
+		if symbol == "" || quantity == 0 || markPrice <= 0 {
+			continue
+		}
+		notional := quantity * markPrice
+		if notional >= threshold {
+			continue
+		}
+
+		logger.Infof("🧹 %s %s 持仓名义价值 %.4f USD 低于粉尘阈值 %.2f USD，自动平仓", symbol, side, notional, threshold)
+		if err := at.emergencyClosePosition(symbol, side, markPrice); err != nil {
+			logger.Warnf("⚠️  粉尘仓位平仓失败 (%s %s): %v", symbol, side, err)
+			logs = append(logs, fmt.Sprintf("❌ 粉尘仓位 %s %s（%.4f USD）平仓失败: %v", symbol, side, notional, err))
+			continue
+		}
+		at.closePositionMeta(symbol, side)
+		logs = append(logs, fmt.Sprintf("🧹 粉尘仓位 %s %s（%.4f USD < %.2f USD）已平仓", symbol, side, notional, threshold))
+	}
+	return logs
+}
@@ -0,0 +1,148 @@
+package trader
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"aspen/decision"
+	"aspen/logger"
+	"aspen/market"
+)
+
+// 确认开仓方向使用的指标信号（均按 3 分钟K线计算）
+const (
+	ConfirmSignalSAR     = "sar"      // 抛物线转向趋势
+	ConfirmSignalZeroLag = "zero_lag" // 零滞后趋势
+	ConfirmSignalRange   = "range"    // 区间过滤综合趋势
+	ConfirmSignalEMA     = "ema"      // 价格位于快速EMA上方/下方
+)
+
+// confirmationCandleInterval 信号持续时间按该周期的已收盘K线计数（与指标使用的K线周期一致）
+const confirmationCandleInterval = 3 * time.Minute
+
+// EntryConfirmationConfig 开仓确认K线要求（默认关闭）：
+// 开仓方向对应的指标信号需已持续 Candles 根已收盘K线，否则推迟开仓
+type EntryConfirmationConfig struct {
+	Candles int    // 需要持续的K线根数，0 表示不检查
+	Signal  string // 使用的指标信号（sar/zero_lag/range/ema）
+}
+
+// DefaultEntryConfirmationConfig 默认开仓确认参数（未启用）
+func DefaultEntryConfirmationConfig() EntryConfirmationConfig {
+	return EntryConfirmationConfig{Signal: ConfirmSignalSAR}
+}
+
+// NormalizeConfirmSignal 校验确认信号名称（空值使用 sar）
+func NormalizeConfirmSignal(signal string) (string, error) {
+	signal = strings.ToLower(strings.TrimSpace(signal))
+	switch signal {
+	case "":
+		return ConfirmSignalSAR, nil
+	case ConfirmSignalSAR, ConfirmSignalZeroLag, ConfirmSignalRange, ConfirmSignalEMA:
+		return signal, nil
+	}
+	return "", fmt.Errorf("未知的开仓确认信号: %s（可选 sar、zero_lag、range、ema）", signal)
+}
+
+// confirmSignalDirection 指标信号方向：1=看多，-1=看空，0=无信号
+func confirmSignalDirection(signal string, data *market.Data) int {
+	if data == nil {
+		return 0
+	}
+	switch signal {
+	case ConfirmSignalZeroLag:
+		return data.ZeroLagTrend
+	case ConfirmSignalRange:
+		return data.RangeCombinedTrend
+	case ConfirmSignalEMA:
+		switch {
+		case data.CurrentEMA20 <= 0 || data.CurrentPrice == data.CurrentEMA20:
+			return 0
+		case data.CurrentPrice > data.CurrentEMA20:
+			return 1
+		default:
+			return -1
+		}
+	default:
+		return data.SARTrend
+	}
+}
+
+// signalStreak 单个币种当前信号方向已持续的已收盘K线根数
+type signalStreak struct {
+	direction  int
+	candles    int
+	lastCandle time.Time // 最近一次计入的K线收盘时间
+}
+
+// signalStreakBook 各币种的信号持续记录（零值可用）
+type signalStreakBook struct {
+	mu      sync.Mutex
+	streaks map[string]signalStreak
+}
+
+// observe 记录一次信号观察：方向变化时重新计数，同一方向在新的K线收盘后加一（同一根K线内多次观察只计一次）
+func (b *signalStreakBook) observe(symbol string, direction int, candle time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.streaks == nil {
+		b.streaks = make(map[string]signalStreak)
+	}
+	s, ok := b.streaks[symbol]
+	switch {
+	case !ok || s.direction != direction:
+		s = signalStreak{direction: direction, lastCandle: candle}
+		if direction != 0 {
+			s.candles = 1
+		}
+	case direction != 0 && candle.After(s.lastCandle):
+		s.candles++
+		s.lastCandle = candle
+	}
+	b.streaks[symbol] = s
+}
+
+// get 币种当前的信号方向和持续K线数
+func (b *signalStreakBook) get(symbol string) (int, int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	s := b.streaks[symbol]
+	return s.direction, s.candles
+}
+
+// observeSignalStreaks 每个周期按AI决策使用的行情更新各币种的信号持续记录（未启用开仓确认时不记录）
+func (at *AutoTrader) observeSignalStreaks(ctx *decision.Context, now time.Time) {
+	cfg := at.config.EntryConfirmation
+	if cfg.Candles <= 0 || ctx == nil {
+		return
+	}
+	candle := now.Truncate(confirmationCandleInterval)
+	for symbol, data := range ctx.MarketDataMap {
+		at.signalStreaks.observe(symbol, confirmSignalDirection(cfg.Signal, data), candle)
+	}
+}
+
+// checkEntryConfirmation 开仓前检查对应方向的信号是否已持续足够的K线，不足时推迟开仓，结论写入 actionRecord.GuardVerdict
+func (at *AutoTrader) checkEntryConfirmation(d *decision.Decision, actionRecord *logger.DecisionAction) error {
+	cfg := at.config.EntryConfirmation
+	if cfg.Candles <= 0 {
+		return nil
+	}
+
+	want := 1
+	if d.Action == "open_short" {
+		want = -1
+	}
+	direction, candles := at.signalStreaks.get(d.Symbol)
+	if direction != want {
+		candles = 0
+	}
+	if candles >= cfg.Candles {
+		return nil
+	}
+	actionRecord.GuardVerdict = fmt.Sprintf("推迟：%s %s 信号已持续 %d/%d 根K线", d.Symbol, cfg.Signal, candles, cfg.Candles)
+	return reject(RejectEntryConfirmation, fmt.Errorf("❌ 开仓确认：%s %s 信号尚未持续 %d 根已收盘K线（当前 %d 根），推迟开仓",
+		d.Symbol, cfg.Signal, cfg.Candles, candles))
+}
@@ -0,0 +1,86 @@
+package trader
+
+import (
+	"testing"
+	"time"
+
+	"aspen/decision"
+	"aspen/logger"
+	"aspen/market"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestEntryConfirmation_DefersUntilSignalHolds 测试开仓被推迟，直到信号持续了配置的已收盘K线根数
+func TestEntryConfirmation_DefersUntilSignalHolds(t *testing.T) {
+	at := newJournalTestTrader(t.TempDir(), &MockTrader{})
+	at.config.EntryConfirmation = EntryConfirmationConfig{Candles: 3, Signal: ConfirmSignalSAR}
+
+	cycle := func(now time.Time, sarTrend int) {
+		at.observeSignalStreaks(&decision.Context{MarketDataMap: map[string]*market.Data{
+			"BTCUSDT": {Symbol: "BTCUSDT", SARTrend: sarTrend},
+		}}, now)
+	}
+	check := func(action string) error {
+		d := &decision.Decision{Symbol: "BTCUSDT", Action: action}
+		return at.checkEntryConfirmation(d, &logger.DecisionAction{})
+	}
+
+	t0 := time.Date(2026, 1, 15, 12, 0, 30, 0, time.UTC)
+	cycle(t0, 1)
+	err := check("open_long")
+	require.Error(t, err)
+	assert.Equal(t, RejectEntryConfirmation, decision.RejectionCode(err))
+
+	// 同一根K线内的多次观察只计一次
+	cycle(t0.Add(time.Minute), 1)
+	require.Error(t, check("open_long"))
+
+	cycle(t0.Add(3*time.Minute), 1)
+	require.Error(t, check("open_long"), "信号只持续了 2 根K线")
+
+	cycle(t0.Add(6*time.Minute), 1)
+	assert.NoError(t, check("open_long"), "信号已持续 3 根K线")
+	assert.Error(t, check("open_short"), "反方向没有信号")
+
+	// 信号反转后重新计数
+	cycle(t0.Add(9*time.Minute), -1)
+	assert.Error(t, check("open_long"))
+	assert.Error(t, check("open_short"))
+	cycle(t0.Add(12*time.Minute), -1)
+	cycle(t0.Add(15*time.Minute), -1)
+	assert.NoError(t, check("open_short"))
+
+	// 信号消失后重新计数
+	cycle(t0.Add(18*time.Minute), 0)
+	assert.Error(t, check("open_short"))
+}
+
+// TestEntryConfirmation_Disabled 测试未配置确认K线时不检查也不记录信号
+func TestEntryConfirmation_Disabled(t *testing.T) {
+	at := newJournalTestTrader(t.TempDir(), &MockTrader{})
+	at.observeSignalStreaks(&decision.Context{MarketDataMap: map[string]*market.Data{
+		"BTCUSDT": {Symbol: "BTCUSDT", SARTrend: -1},
+	}}, time.Now())
+
+	d := &decision.Decision{Symbol: "BTCUSDT", Action: "open_long"}
+	assert.NoError(t, at.checkEntryConfirmation(d, &logger.DecisionAction{}))
+	assert.Empty(t, at.signalStreaks.streaks)
+}
+
+// TestConfirmSignalDirection 测试各确认信号的方向
+func TestConfirmSignalDirection(t *testing.T) {
+	data := &market.Data{CurrentPrice: 101, CurrentEMA20: 100, SARTrend: -1, ZeroLagTrend: 1, RangeCombinedTrend: -1}
+	assert.Equal(t, -1, confirmSignalDirection(ConfirmSignalSAR, data))
+	assert.Equal(t, 1, confirmSignalDirection(ConfirmSignalZeroLag, data))
+	assert.Equal(t, -1, confirmSignalDirection(ConfirmSignalRange, data))
+	assert.Equal(t, 1, confirmSignalDirection(ConfirmSignalEMA, data))
+	assert.Equal(t, 0, confirmSignalDirection(ConfirmSignalSAR, nil))
+
+	signal, err := NormalizeConfirmSignal(" Zero_Lag ")
+	require.NoError(t, err)
+	assert.Equal(t, ConfirmSignalZeroLag, signal)
+	_, err = NormalizeConfirmSignal("macd")
+	assert.Error(t, err)
+}
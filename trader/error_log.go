@@ -0,0 +1,101 @@
+package trader
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// errorLogLimit 每个交易员保留的最近错误条数
+const errorLogLimit = 100
+
+// 交易员错误类别
+const (
+	ErrorKindContext    = "context"     // 构建交易上下文失败（余额、持仓查询等）
+	ErrorKindMarketData = "market_data" // 获取币种市场数据失败
+	ErrorKindAI         = "ai"          // 获取AI决策失败
+	ErrorKindExecution  = "execution"   // 执行决策失败
+)
+
+// TraderError 交易员运行中的一条错误记录
+type TraderError struct {
+	Time    time.Time `json:"time"`
+	Kind    string    `json:"kind"` // 见 ErrorKind* 常量
+	Cycle   int       `json:"cycle"`
+	Symbol  string    `json:"symbol,omitempty"`
+	Action  string    `json:"action,omitempty"`
+	Message string    `json:"message"`
+}
+
+// errorLog 交易员最近的错误（零值可用，超过 errorLogLimit 条时丢弃最早的）
+type errorLog struct {
+	mu      sync.Mutex
+	entries []TraderError // 从旧到新
+}
+
+// record 追加一条错误
+func (l *errorLog) record(e TraderError) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.entries = append(l.entries, e)
+	if len(l.entries) > errorLogLimit {
+		l.entries = append([]TraderError(nil), l.entries[len(l.entries)-errorLogLimit:]...)
+	}
+}
+
+// list 获取所有错误（从新到旧）
+func (l *errorLog) list() []TraderError {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	out := make([]TraderError, len(l.entries))
+	for i, e := range l.entries {
+		out[len(l.entries)-1-i] = e
+	}
+	return out
+}
+
+// clear 清空错误，返回清除的条数
+func (l *errorLog) clear() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	n := len(l.entries)
+	l.entries = nil
+	return n
+}
+
+// RecordError 记录一条交易员错误（供 API 查看，err 为 nil 时忽略）
+func (at *AutoTrader) RecordError(kind, symbol, action string, err error) {
+	if err == nil {
+		return
+	}
+	at.errorLog.record(TraderError{
+		Time:    time.Now(),
+		Kind:    kind,
+		Cycle:   at.callCount,
+		Symbol:  symbol,
+		Action:  action,
+		Message: err.Error(),
+	})
+}
+
+// recordMarketDataErrors 记录本周期获取失败的币种市场数据（按币种排序，便于阅读）
+func (at *AutoTrader) recordMarketDataErrors(errs map[string]error) {
+	symbols := make([]string, 0, len(errs))
+	for symbol := range errs {
+		symbols = append(symbols, symbol)
+	}
+	sort.Strings(symbols)
+	for _, symbol := range symbols {
+		at.RecordError(ErrorKindMarketData, symbol, "", errs[symbol])
+	}
+}
+
+// GetRecentErrors 获取交易员最近的错误（从新到旧，最多 errorLogLimit 条）
+func (at *AutoTrader) GetRecentErrors() []TraderError {
+	return at.errorLog.list()
+}
+
+// ClearErrors 清空交易员的错误记录，返回清除的条数
+func (at *AutoTrader) ClearErrors() int {
+	return at.errorLog.clear()
+}
@@ -0,0 +1,36 @@
+package trader
+
+import (
+	"errors"
+	"fmt"
+
+	"aspen/logger"
+)
+
+// FlattenPositions 立即平掉交易员的所有持仓（管理员一键清仓使用），返回已平仓的持仓（"SYMBOL side"）。
+// 某个持仓平仓失败时继续平其余持仓，返回的错误包含所有失败原因
+func (at *AutoTrader) FlattenPositions() ([]string, error) {
+	positions, err := at.trader.GetPositions()
+	if err != nil {
+		return nil, fmt.Errorf("获取持仓失败: %w", err)
+	}
+
+	closed := []string{}
+	var errs []error
+	for _, pos := range positions {
+		symbol, _ := pos["symbol"].(string)
+		side, _ := pos["side"].(string)
+		markPrice, _ := pos["markPrice"].(float64)
+		if symbol == "" {
+			continue
+		}
+		logger.Infof("🚨 [%s] 一键清仓：平仓 %s %s", at.name, symbol, side)
+		if err := at.emergencyClosePosition(symbol, side, markPrice); err != nil {
+			logger.Errorf("❌ [%s] 一键清仓平仓失败 (%s %s): %v", at.name, symbol, side, err)
+			errs = append(errs, fmt.Errorf("%s %s: %w", symbol, side, err))
+			continue
+		}
+		closed = append(closed, symbol+" "+side)
+	}
+	return closed, errors.Join(errs...)
+}
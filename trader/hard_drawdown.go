@@ -0,0 +1,109 @@
+package trader
+
+import (
+	"fmt"
+	"sync"
+
+	"aspen/logger"
+)
+
+// traderDisabler 持久化交易员停用状态（*config.Database 实现），进程重启后仍不自动启动
+type traderDisabler interface {
+	DisableTrader(userID, id, reason string) error
+}
+
+// hardDrawdownPeakSaver 持久化硬回撤计算的最高净值（*config.Database 实现），进程重启后按同一基准计算回撤
+type hardDrawdownPeakSaver interface {
+	SaveHardDrawdownPeak(userID, id string, peak float64) error
+}
+
+// hardDrawdownState 硬回撤熔断状态
+type hardDrawdownState struct {
+	mu             sync.Mutex
+	peak           float64 // 计算硬回撤的最高净值（0 表示尚未记录）
+	rebase         bool    // 重置后以下一次的净值作为新的最高净值（不再以初始资金为下限）
+	disabledReason string  // 停用原因，非空表示已停用
+}
+
+// DisabledReason 交易员的停用原因（空表示未停用）
+func (at *AutoTrader) DisabledReason() string {
+	at.hardDrawdown.mu.Lock()
+	defer at.hardDrawdown.mu.Unlock()
+	return at.hardDrawdown.disabledReason
+}
+
+// ResetHardDrawdown 手动重置停用状态，之后以重置后第一次的净值重新计算回撤
+// （数据库中的最高净值由 config.Database.ResetTraderDisabled 一并清除）
+func (at *AutoTrader) ResetHardDrawdown() {
+	at.hardDrawdown.mu.Lock()
+	defer at.hardDrawdown.mu.Unlock()
+	at.hardDrawdown.disabledReason = ""
+	at.hardDrawdown.peak = 0
+	at.hardDrawdown.rebase = true
+}
+
+// checkHardDrawdown 按本周期净值更新最高净值（初始资金为下限），回撤达到硬上限时返回停用原因。
+// 最高净值变化时保存到数据库，进程重启后沿用（手动重置后的重新计算状态也不会因重启丢失）
+func (at *AutoTrader) checkHardDrawdown(equity float64) string {
+	limit := at.config.HardDrawdownPct
+	if limit <= 0 || equity <= 0 {
+		return ""
+	}
+
+	s := &at.hardDrawdown
+	s.mu.Lock()
+	previousPeak := s.peak
+	if s.peak <= 0 {
+		s.peak = equity
+		if !s.rebase && at.initialBalance > s.peak {
+			s.peak = at.initialBalance
+		}
+		s.rebase = false
+	}
+	if equity > s.peak {
+		s.peak = equity
+	}
+	peak := s.peak
+	s.mu.Unlock()
+
+	if peak != previousPeak {
+		if db, ok := at.database.(hardDrawdownPeakSaver); ok {
+			if err := db.SaveHardDrawdownPeak(at.userID, at.id, peak); err != nil {
+				logger.Warnf("⚠️ [%s] 保存硬回撤最高净值失败: %v", at.name, err)
+			}
+		}
+	}
+
+	drawdownPct := (peak - equity) / peak * 100
+	if drawdownPct < limit {
+		return ""
+	}
+	unit := at.getStablecoinUnit()
+	return fmt.Sprintf("硬回撤熔断：净值 %.2f %s 自最高 %.2f %s 回撤 %.2f%%（上限 %.2f%%）", equity, unit, peak, unit, drawdownPct, limit)
+}
+
+// disableForHardDrawdown 触发硬回撤：平掉所有持仓、持久化停用状态并结束主循环，返回执行日志
+func (at *AutoTrader) disableForHardDrawdown(reason string) []string {
+	logger.Errorf("⛔ [%s] %s，清仓并停用交易员", at.name, reason)
+	logs := at.closeAllPositions("硬回撤熔断")
+
+	at.hardDrawdown.mu.Lock()
+	at.hardDrawdown.disabledReason = reason
+	at.hardDrawdown.mu.Unlock()
+
+	if db, ok := at.database.(traderDisabler); ok {
+		if err := db.DisableTrader(at.userID, at.id, reason); err != nil {
+			logger.Errorf("❌ [%s] 保存停用状态失败: %v", at.name, err)
+			logs = append(logs, fmt.Sprintf("❌ 保存停用状态失败: %v", err))
+		}
+	}
+	at.emitWebhook(WebhookEvent{Event: WebhookEventRisk, Reason: reason})
+
+	// 在决策周期内结束主循环（不能调用 Stop：Stop 会等待主循环退出）。
+	// 与 Stop 并发时 stopMonitorCh 只关闭一次
+	if at.isRunning {
+		at.isRunning = false
+		at.signalStop()
+	}
+	return append(logs, "⛔ "+reason+"，交易员已停用，需手动重置后才能启动")
+}
@@ -0,0 +1,137 @@
+package trader
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingDisabler 记录持久化的停用状态和硬回撤最高净值
+type recordingDisabler struct {
+	reasons map[string]string
+	peaks   map[string]float64
+}
+
+func newRecordingDisabler() *recordingDisabler {
+	return &recordingDisabler{reasons: make(map[string]string), peaks: make(map[string]float64)}
+}
+
+func (d *recordingDisabler) DisableTrader(userID, id, reason string) error {
+	d.reasons[userID+"/"+id] = reason
+	return nil
+}
+
+func (d *recordingDisabler) SaveHardDrawdownPeak(userID, id string, peak float64) error {
+	d.peaks[userID+"/"+id] = peak
+	return nil
+}
+
+// TestHardDrawdown_BreachFlattensAndDisables 测试回撤达到硬上限时平掉所有持仓、持久化停用状态并结束主循环，重置前不能再运行
+func TestHardDrawdown_BreachFlattensAndDisables(t *testing.T) {
+	exchange := &MockTrader{positions: []map[string]interface{}{
+		{"symbol": "BTCUSDT", "side": "long", "markPrice": 50000.0},
+		{"symbol": "ETHUSDT", "side": "short", "markPrice": 3000.0},
+	}}
+	at := newJournalTestTrader(t.TempDir(), exchange)
+	db := newRecordingDisabler()
+	at.database = db
+	at.userID = "u1"
+	at.initialBalance = 1000
+	at.config.HardDrawdownPct = 20
+
+	// 最高净值 1200，回撤不足 20% 时不触发
+	assert.Empty(t, at.checkHardDrawdown(1200))
+	assert.Empty(t, at.checkHardDrawdown(1000))
+
+	reason := at.checkHardDrawdown(950)
+	require.NotEmpty(t, reason)
+	assert.Contains(t, reason, "20.83%")
+
+	at.isRunning = true
+	at.stopMonitorCh = make(chan struct{})
+	at.stopOnce = &sync.Once{}
+	logs := at.disableForHardDrawdown(reason)
+
+	assert.ElementsMatch(t, []string{"BTCUSDT_long", "ETHUSDT_short"}, exchange.closed)
+	assert.Contains(t, logs[len(logs)-1], "交易员已停用")
+	assert.Equal(t, reason, db.reasons["u1/journal_trader"])
+	assert.Equal(t, reason, at.DisabledReason())
+	assert.False(t, at.isRunning)
+	select {
+	case <-at.stopMonitorCh:
+	default:
+		t.Fatal("主循环应收到停止信号")
+	}
+
+	// 停用后 Run 直接返回，不启动主循环
+	require.NoError(t, at.Run())
+	assert.False(t, at.isRunning)
+
+	// 重置后以当前净值作为新的最高净值，不会立即再次触发
+	at.ResetHardDrawdown()
+	assert.Empty(t, at.DisabledReason())
+	assert.Empty(t, at.checkHardDrawdown(950))
+	assert.NotEmpty(t, at.checkHardDrawdown(700))
+}
+
+// TestHardDrawdown_DisabledByDefault 测试未配置硬回撤上限时不触发
+func TestHardDrawdown_DisabledByDefault(t *testing.T) {
+	at := newJournalTestTrader(t.TempDir(), &MockTrader{})
+	at.initialBalance = 1000
+	assert.Empty(t, at.checkHardDrawdown(100))
+}
+
+// TestHardDrawdown_PeakSurvivesRestart 测试最高净值和重置后的重新计算状态持久化：进程重启后按保存的最高净值计算回撤，
+// 重置后重启不会以初始资金为最高净值立即再次触发
+func TestHardDrawdown_PeakSurvivesRestart(t *testing.T) {
+	db := newRecordingDisabler()
+	newTrader := func(peak float64, rebase bool) *AutoTrader {
+		at := newJournalTestTrader(t.TempDir(), &MockTrader{})
+		at.database = db
+		at.userID = "u1"
+		at.initialBalance = 1000
+		at.config.HardDrawdownPct = 20
+		// 与 NewAutoTrader 一致：从数据库加载的最高净值和重新计算状态
+		at.hardDrawdown.peak = peak
+		at.hardDrawdown.rebase = rebase
+		return at
+	}
+
+	at := newTrader(0, false)
+	assert.Empty(t, at.checkHardDrawdown(1100))
+	assert.Empty(t, at.checkHardDrawdown(1500))
+	assert.Equal(t, 1500.0, db.peaks["u1/journal_trader"], "最高净值变化时保存")
+	assert.Empty(t, at.checkHardDrawdown(1300))
+	assert.Equal(t, 1500.0, db.peaks["u1/journal_trader"])
+
+	// 重启后沿用保存的最高净值（而不是以当前净值或初始资金重新开始）
+	restarted := newTrader(db.peaks["u1/journal_trader"], false)
+	assert.NotEmpty(t, restarted.checkHardDrawdown(1150), "自 1500 回撤 23%")
+
+	// 重置后（数据库中最高净值清零并标记重新计算）重启，以重启后第一次的净值作为最高净值
+	restarted = newTrader(0, true)
+	assert.Empty(t, restarted.checkHardDrawdown(700), "不以初始资金 1000 为最高净值")
+	assert.Equal(t, 700.0, db.peaks["u1/journal_trader"])
+	assert.NotEmpty(t, restarted.checkHardDrawdown(550))
+}
+
+// TestHardDrawdown_ConcurrentStop 测试硬回撤熔断结束主循环后 Stop 再次关闭停止信号不会 panic
+// （两者在不同goroutine中，Stop 可能在熔断修改 isRunning 之前已通过检查）
+func TestHardDrawdown_ConcurrentStop(t *testing.T) {
+	at := newJournalTestTrader(t.TempDir(), &MockTrader{})
+	at.isRunning = true
+	at.stopMonitorCh = make(chan struct{})
+	at.stopOnce = &sync.Once{}
+
+	at.disableForHardDrawdown("硬回撤熔断")
+	at.isRunning = true // Stop 已读到运行中
+	assert.NotPanics(t, at.Stop)
+	assert.False(t, at.isRunning)
+	select {
+	case <-at.stopMonitorCh:
+	default:
+		t.Fatal("主循环应收到停止信号")
+	}
+}
@@ -0,0 +1,85 @@
+package trader
+
+import (
+	"fmt"
+	"strings"
+
+	"aspen/decision"
+	"aspen/logger"
+)
+
+// 对冲策略：同一币种已有反向持仓时如何处理开仓（应用层规则，与交易所账户的持仓模式无关）
+const (
+	HedgePolicyNoHedge   = "no_hedge"   // 拒绝开仓，需要先给出平仓决策（默认）
+	HedgePolicyAllowFlip = "allow_flip" // 先平掉反向持仓再开仓（反手）
+)
+
+// NormalizeHedgePolicy 校验对冲策略（空值使用 no_hedge）
+func NormalizeHedgePolicy(policy string) (string, error) {
+	policy = strings.ToLower(strings.TrimSpace(policy))
+	switch policy {
+	case "":
+		return HedgePolicyNoHedge, nil
+	case HedgePolicyNoHedge, HedgePolicyAllowFlip:
+		return policy, nil
+	}
+	return "", fmt.Errorf("未知的对冲策略: %s（可选 no_hedge / allow_flip）", policy)
+}
+
+// enforceHedgePolicy 开仓前检查同币种的反向持仓：no_hedge 拒绝开仓，allow_flip 先平掉反向持仓。
+// 单向持仓模式的账户上反向开仓会直接减仓甚至反向，双向持仓模式的账户上会同时持有多空，
+// 因此无论账户是哪种模式都在执行器中统一处理
+func (at *AutoTrader) enforceHedgePolicy(d *decision.Decision, actionRecord *logger.DecisionAction) error {
+	side := strings.TrimPrefix(d.Action, "open_")
+	opposite := "short"
+	if side == "short" {
+		opposite = "long"
+	}
+
+	positions, err := at.trader.GetPositions()
+	if err != nil {
+		return fmt.Errorf("获取持仓失败，无法检查 %s 的反向持仓: %w", d.Symbol, err)
+	}
+	hasOpposite := false
+	for _, pos := range positions {
+		if pos["symbol"] == d.Symbol && pos["side"] == opposite {
+			hasOpposite = true
+			break
+		}
+	}
+	if !hasOpposite {
+		return nil
+	}
+
+	if at.config.HedgePolicy != HedgePolicyAllowFlip {
+		return reject(RejectHedgePolicy, fmt.Errorf("❌ %s 已有%s，对冲策略 %s 不允许同时持有反向仓位。如需换向，请先给出 close_%s 决策",
+			d.Symbol, sideName(opposite), HedgePolicyNoHedge, opposite))
+	}
+
+	// 反手：先平掉反向持仓（全部），再继续开仓
+	ref := at.decisionRef(d.Symbol, 0)
+	var order map[string]interface{}
+	if opposite == "long" {
+		order, err = at.closeLong(d.Symbol, 0, "", ref)
+	} else {
+		order, err = at.closeShort(d.Symbol, 0, "", ref)
+	}
+	if err != nil {
+		return fmt.Errorf("反手前平掉 %s %s失败: %w", d.Symbol, sideName(opposite), err)
+	}
+	at.closePositionMeta(d.Symbol, opposite)
+	at.intents.clearPosition(at.id, d.Symbol, opposite)
+
+	note := fmt.Sprintf("对冲策略 %s: 开%s前已平掉%s（订单ID: %v）", HedgePolicyAllowFlip, sideName(side), sideName(opposite), order["orderId"])
+	actionRecord.Adjustments = append(actionRecord.Adjustments, note)
+	logger.Infof("  🔁 %s %s", d.Symbol, note)
+	return nil
+}
+
+// sideName 持仓方向的中文名称
+func sideName(side string) string {
+	if side == "long" {
+		return "多仓"
+	}
+	return "空仓"
+}
@@ -0,0 +1,86 @@
+package trader
+
+import (
+	"testing"
+
+	"aspen/decision"
+	"aspen/logger"
+	"aspen/market"
+
+	"github.com/agiledragon/gomonkey/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestEnforceHedgePolicy 测试对冲策略在执行器中统一处理反向持仓：no_hedge 拒绝开仓，allow_flip 先平反向持仓
+func TestEnforceHedgePolicy(t *testing.T) {
+	patches := gomonkey.ApplyFunc(market.Get, func(symbol string) (*market.Data, error) {
+		return &market.Data{Symbol: symbol, CurrentPrice: 100.0}, nil
+	})
+	defer patches.Reset()
+
+	tests := []struct {
+		name       string
+		policy     string
+		action     string
+		positions  []map[string]interface{}
+		wantErr    bool
+		wantClosed []string
+	}{
+		{"无反向持仓正常开仓", HedgePolicyNoHedge, "open_long", nil, false, nil},
+		{"no_hedge 有空仓时拒绝开多", HedgePolicyNoHedge, "open_long",
+			[]map[string]interface{}{{"symbol": "SOLUSDT", "side": "short", "positionAmt": -2.0}}, true, nil},
+		{"no_hedge 有多仓时拒绝开空", "", "open_short",
+			[]map[string]interface{}{{"symbol": "SOLUSDT", "side": "long", "positionAmt": 2.0}}, true, nil},
+		{"其他币种的反向持仓不影响", HedgePolicyNoHedge, "open_long",
+			[]map[string]interface{}{{"symbol": "BTCUSDT", "side": "short", "positionAmt": -0.1}}, false, nil},
+		{"allow_flip 先平空仓再开多", HedgePolicyAllowFlip, "open_long",
+			[]map[string]interface{}{{"symbol": "SOLUSDT", "side": "short", "positionAmt": -2.0}}, false, []string{"SOLUSDT_short"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exchange := newClientIDMockTrader()
+			exchange.positions = tt.positions
+			at := newJournalTestTrader(t.TempDir(), exchange)
+			at.config.HedgePolicy = tt.policy
+
+			d := &decision.Decision{Symbol: "SOLUSDT", Action: tt.action, Leverage: 5, PositionSizeUSD: 200}
+			record := &logger.DecisionAction{Action: d.Action, Symbol: d.Symbol, ClientOrderID: "hedge-test"}
+			var err error
+			if tt.action == "open_long" {
+				err = at.executeOpenLongWithRecord(d, record)
+			} else {
+				err = at.executeOpenShortWithRecord(d, record)
+			}
+
+			if tt.wantErr {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), HedgePolicyNoHedge)
+				assert.Empty(t, exchange.orders, "拒绝时不应下单")
+				assert.Empty(t, exchange.closed)
+				return
+			}
+			require.NoError(t, err)
+			assert.Len(t, exchange.orders, 1)
+			assert.Equal(t, tt.wantClosed, exchange.closed)
+			if tt.wantClosed != nil {
+				require.Len(t, record.Adjustments, 1)
+				assert.Contains(t, record.Adjustments[0], HedgePolicyAllowFlip)
+			}
+		})
+	}
+}
+
+// TestNormalizeHedgePolicy 测试对冲策略校验
+func TestNormalizeHedgePolicy(t *testing.T) {
+	policy, err := NormalizeHedgePolicy("")
+	assert.NoError(t, err)
+	assert.Equal(t, HedgePolicyNoHedge, policy)
+
+	policy, err = NormalizeHedgePolicy(" Allow_Flip ")
+	assert.NoError(t, err)
+	assert.Equal(t, HedgePolicyAllowFlip, policy)
+
+	_, err = NormalizeHedgePolicy("hedge")
+	assert.Error(t, err)
+}
@@ -227,6 +227,15 @@ func (t *HyperliquidTrader) GetBalance() (map[string]interface{}, error) {
 	result["availableBalance"] = availableBalance        // 可用余额（仅 Perpetuals，不含 Spot）
 	result["totalUnrealizedProfit"] = totalUnrealizedPnl // 未实现盈亏（仅来自 Perpetuals）
 	result["spotBalance"] = spotUSDCBalance              // Spot 现货余额（单独返回）
+	// Hyperliquid 只以 USDC 结算，按 1:1 折算
+	result["assetBalances"] = []AssetBalance{{
+		Asset:            MarginAssetUSDC,
+		WalletBalance:    totalWalletBalance,
+		AvailableBalance: availableBalance,
+		UnrealizedProfit: totalUnrealizedPnl,
+		USDPrice:         1,
+		USDValue:         totalWalletBalance + totalUnrealizedPnl,
+	}}
 
 	logger.Infof("✓ Hyperliquid 完整账户:")
 	logger.Infof("  • Spot 现货余额: %.2f USDC （需手动转账到 Perpetuals 才能开仓）", spotUSDCBalance)
@@ -302,6 +311,7 @@ func (t *HyperliquidTrader) GetPositions() ([]map[string]interface{}, error) {
 		posMap["unRealizedProfit"] = unrealizedPnl
 		posMap["leverage"] = float64(position.Leverage.Value)
 		posMap["liquidationPrice"] = liquidationPx
+		posMap["initialMargin"], _ = strconv.ParseFloat(position.MarginUsed, 64)
 
 		result = append(result, posMap)
 	}
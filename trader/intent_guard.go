@@ -0,0 +1,131 @@
+package trader
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"aspen/decision"
+	"aspen/logger"
+)
+
+// DefaultIntentDedupWindow 默认重复开仓意图检查窗口
+const DefaultIntentDedupWindow = 15 * time.Minute
+
+// 意图键分桶宽度（按对数分桶）：只有仓位大小和入场价格都几乎相同的开仓才视为同一意图，
+// 有意的加仓应使用 add_to_position，不受该检查影响
+const (
+	intentSizeBucketPct  = 10.0 // 仓位大小分桶宽度（%）
+	intentPriceBucketPct = 0.5  // 入场价格分桶宽度（%）
+)
+
+// intentKeyPrefix 某个交易员某币种某方向的意图键前缀
+func intentKeyPrefix(traderID, symbol, side string) string {
+	return fmt.Sprintf("%s|%s|%s|", traderID, symbol, side)
+}
+
+// intentKey 根据交易员、币种、方向、仓位大小和入场价格分桶生成确定的开仓意图键
+func intentKey(traderID, symbol, side string, sizeUSD, price float64) string {
+	return fmt.Sprintf("%ss%d|p%d", intentKeyPrefix(traderID, symbol, side),
+		logBucket(sizeUSD, intentSizeBucketPct), logBucket(price, intentPriceBucketPct))
+}
+
+// logBucket 按相对宽度对数分桶
+func logBucket(v, widthPct float64) int64 {
+	if v <= 0 {
+		return 0
+	}
+	return int64(math.Floor(math.Log(v) / math.Log1p(widthPct/100)))
+}
+
+// intentBook 已成交的开仓意图及成交时间（零值可用）
+type intentBook struct {
+	mu       sync.Mutex
+	executed map[string]time.Time
+}
+
+// record 记录一次已成交的开仓意图
+func (b *intentBook) record(key string, at time.Time) {
+	if key == "" {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.executed == nil {
+		b.executed = make(map[string]time.Time)
+	}
+	b.executed[key] = at
+}
+
+// lastExecuted 意图最近一次成交的时间
+func (b *intentBook) lastExecuted(key string) (time.Time, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	at, ok := b.executed[key]
+	return at, ok
+}
+
+// clearPosition 持仓平仓后清除该币种该方向的意图（之后再开同样的仓位不算重复）
+func (b *intentBook) clearPosition(traderID, symbol, side string) {
+	prefix := intentKeyPrefix(traderID, symbol, side)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for key := range b.executed {
+		if strings.HasPrefix(key, prefix) {
+			delete(b.executed, key)
+		}
+	}
+}
+
+// restoreFromRecords 从决策日志恢复已成交的开仓意图（周期中断重启后重复开仓检查仍然有效）
+func (b *intentBook) restoreFromRecords(traderID string, records []*logger.DecisionRecord) {
+	for _, record := range records {
+		for _, action := range record.Decisions {
+			if !action.Success {
+				continue
+			}
+			switch action.Action {
+			case "open_long", "open_short":
+				b.record(action.IntentKey, action.Timestamp)
+			case "close_long", "close_short":
+				b.clearPosition(traderID, action.Symbol, strings.TrimPrefix(action.Action, "close_"))
+			}
+		}
+	}
+}
+
+// openIntentKey 开仓决策的意图键（仓位大小取AI给出的原始值，价格优先取AI决策时的行情快照，没有快照时取当前价格）
+func (at *AutoTrader) openIntentKey(d *decision.Decision) string {
+	price := at.decisionRef(d.Symbol, 0).Price
+	if price <= 0 {
+		if data, err := at.getMarketData(d.Symbol); err == nil {
+			price = data.CurrentPrice
+		}
+	}
+	if price <= 0 || d.PositionSizeUSD <= 0 {
+		return ""
+	}
+	return intentKey(at.id, d.Symbol, strings.TrimPrefix(d.Action, "open_"), d.PositionSizeUSD, price)
+}
+
+// checkDuplicateIntent 开仓前检查窗口内是否已成交过相同意图的开仓（期间已平仓的除外），
+// 需在风控调整仓位之前调用；拒绝时返回带 duplicate_intent 原因代码的错误
+func (at *AutoTrader) checkDuplicateIntent(d *decision.Decision, actionRecord *logger.DecisionAction, now time.Time) error {
+	window := at.config.IntentDedupWindow
+	if window <= 0 {
+		return nil
+	}
+	if actionRecord.IntentKey == "" {
+		// 未经周期计划直接执行的开仓在此确定意图键
+		actionRecord.IntentKey = at.openIntentKey(d)
+	}
+
+	executedAt, ok := at.intents.lastExecuted(actionRecord.IntentKey)
+	if !ok || now.Sub(executedAt) > window {
+		return nil
+	}
+	return reject(RejectDuplicateIntent, fmt.Errorf("❌ 重复开仓：%s %s 相同仓位和价格的开仓已于 %s 成交且未平仓（%.0f 分钟内），拒绝重复执行。如需加仓，请使用 add_to_position",
+		d.Symbol, d.Action, executedAt.Format("15:04:05"), window.Minutes()))
+}
@@ -0,0 +1,122 @@
+package trader
+
+import (
+	"testing"
+	"time"
+
+	"aspen/decision"
+	"aspen/logger"
+	"aspen/market"
+
+	"github.com/agiledragon/gomonkey/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestIntentKeyBuckets 测试意图键分桶：相近的仓位和价格得到同一个键，明显不同的加仓规模或价格得到不同的键
+func TestIntentKeyBuckets(t *testing.T) {
+	base := intentKey("t1", "BTCUSDT", "long", 1000, 50000)
+	assert.Equal(t, base, intentKey("t1", "BTCUSDT", "long", 1001, 50001))
+	assert.NotEqual(t, base, intentKey("t1", "BTCUSDT", "short", 1000, 50000))
+	assert.NotEqual(t, base, intentKey("t2", "BTCUSDT", "long", 1000, 50000))
+	assert.NotEqual(t, base, intentKey("t1", "BTCUSDT", "long", 2000, 50000), "仓位翻倍不应视为同一意图")
+	assert.NotEqual(t, base, intentKey("t1", "BTCUSDT", "long", 1000, 52000), "入场价格相差4%不应视为同一意图")
+
+	var book intentBook
+	now := time.Now()
+	book.record(base, now)
+	book.record(intentKey("t1", "BTCUSDT", "short", 1000, 50000), now)
+	book.clearPosition("t1", "BTCUSDT", "long")
+	_, ok := book.lastExecuted(base)
+	assert.False(t, ok, "平多后应清除多仓意图")
+	_, ok = book.lastExecuted(intentKey("t1", "BTCUSDT", "short", 1000, 50000))
+	assert.True(t, ok, "平多不影响空仓意图")
+}
+
+// TestIntentDedup_RetryAfterPartialFailure 测试周期部分执行后中断，重启后的下一周期AI重复给出已成交的开仓时被拒绝，
+// 加仓和平仓后重新开仓不受影响
+func TestIntentDedup_RetryAfterPartialFailure(t *testing.T) {
+	prices := map[string]float64{"BTCUSDT": 50000.0, "ETHUSDT": 3000.0}
+	patches := gomonkey.ApplyFunc(market.Get, func(symbol string) (*market.Data, error) {
+		return &market.Data{Symbol: symbol, CurrentPrice: prices[symbol]}, nil
+	})
+	defer patches.Reset()
+
+	logDir := t.TempDir()
+	exchange := newClientIDMockTrader()
+	newTrader := func() *AutoTrader {
+		at := newJournalTestTrader(logDir, exchange)
+		at.config.IntentDedupWindow = DefaultIntentDedupWindow
+		return at
+	}
+
+	openBTC := decision.Decision{Symbol: "BTCUSDT", Action: "open_long", Leverage: 5, PositionSizeUSD: 1000, StopLoss: 48000, TakeProfit: 56000, Confidence: 80}
+	openETH := decision.Decision{Symbol: "ETHUSDT", Action: "open_short", Leverage: 5, PositionSizeUSD: 600, StopLoss: 3100, TakeProfit: 2700, Confidence: 80}
+
+	// 周期1：BTC 开多成交后进程被终止，ETH 开空未执行
+	at := newTrader()
+	at.faultHook = func(point string, index int) error {
+		if point == faultAfterOrderExecuted && index == 0 {
+			return errSimulatedKill
+		}
+		return nil
+	}
+	cycleID := newCycleID(time.Now())
+	journal, err := at.decisionLogger.BeginCycle(cycleID, &logger.DecisionRecord{})
+	require.NoError(t, err)
+	err = at.executeCycleDecisions(journal, cycleID, &logger.DecisionRecord{}, []decision.Decision{openBTC, openETH}, nil)
+	require.ErrorIs(t, err, errSimulatedKill)
+	require.Len(t, exchange.orders, 1)
+
+	// 重启对账后，交易所持仓查询仍未返回 BTC 持仓（模拟持仓同步滞后）
+	restarted := newTrader()
+	require.Equal(t, 1, restarted.RecoverInterruptedCycles())
+
+	// 周期2：AI看到几乎相同的数据，重复给出 BTC 开多（仓位和价格略有不同）并重新给出 ETH 开空
+	prices["BTCUSDT"] = 50050.0
+	retryBTC := openBTC
+	retryBTC.PositionSizeUSD = 1010
+	cycleID = newCycleID(time.Now().Add(2 * time.Minute))
+	journal, err = restarted.decisionLogger.BeginCycle(cycleID, &logger.DecisionRecord{})
+	require.NoError(t, err)
+	record := &logger.DecisionRecord{}
+	require.NoError(t, restarted.executeCycleDecisions(journal, cycleID, record, []decision.Decision{retryBTC, openETH}, nil))
+	require.NoError(t, journal.Complete())
+
+	require.Len(t, record.Decisions, 2)
+	btc, eth := record.Decisions[0], record.Decisions[1]
+	assert.False(t, btc.Success)
+	assert.Equal(t, RejectDuplicateIntent, btc.RejectReason)
+	assert.Contains(t, btc.Error, "add_to_position")
+	assert.True(t, eth.Success, "未成交过的开仓不受影响: %s", eth.Error)
+	assert.Empty(t, eth.RejectReason)
+	assert.Len(t, exchange.orders, 2, "重复的 BTC 开仓不应提交到交易所")
+
+	// 有意加仓使用 add_to_position，不受重复意图检查限制
+	exchange.positions = []map[string]interface{}{
+		{"symbol": "BTCUSDT", "side": "long", "positionAmt": 0.02, "entryPrice": 50000.0, "markPrice": 50050.0, "leverage": 5.0},
+	}
+	add := decision.Decision{Symbol: "BTCUSDT", Action: "add_to_position", PositionSizeUSD: 1000}
+	addRecord := &logger.DecisionAction{Action: add.Action, Symbol: add.Symbol}
+	require.NoError(t, restarted.executeDecisionWithRecord(&add, addRecord))
+	assert.Empty(t, addRecord.RejectReason)
+
+	// 平仓后同样的开仓不再视为重复
+	closeBTC := decision.Decision{Symbol: "BTCUSDT", Action: "close_long"}
+	require.NoError(t, restarted.executeDecisionWithRecord(&closeBTC, &logger.DecisionAction{Action: closeBTC.Action, Symbol: closeBTC.Symbol}))
+	exchange.positions = []map[string]interface{}{}
+	reopen := retryBTC
+	reopenRecord := &logger.DecisionAction{Action: reopen.Action, Symbol: reopen.Symbol}
+	require.NoError(t, restarted.executeDecisionWithRecord(&reopen, reopenRecord))
+	assert.NotEmpty(t, reopenRecord.IntentKey)
+
+	// 窗口外的相同开仓不再拦截；窗口为 0 时不检查
+	restarted.intents.record(reopenRecord.IntentKey, time.Now().Add(-DefaultIntentDedupWindow-time.Minute))
+	assert.NoError(t, restarted.checkDuplicateIntent(&reopen, &logger.DecisionAction{IntentKey: reopenRecord.IntentKey}, time.Now()))
+	restarted.intents.record(reopenRecord.IntentKey, time.Now())
+	err = restarted.checkDuplicateIntent(&reopen, &logger.DecisionAction{IntentKey: reopenRecord.IntentKey}, time.Now())
+	require.Error(t, err)
+	assert.Equal(t, RejectDuplicateIntent, decision.RejectionCode(err), "拒绝原因代码随错误返回")
+	restarted.config.IntentDedupWindow = 0
+	assert.NoError(t, restarted.checkDuplicateIntent(&reopen, &logger.DecisionAction{IntentKey: reopenRecord.IntentKey}, time.Now()))
+}
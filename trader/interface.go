@@ -1,5 +1,7 @@
 package trader
 
+import "time"
+
 // Trader 交易器统一接口
 // 支持多个交易平台（币安、Hyperliquid等）
 type Trader interface {
@@ -51,3 +53,43 @@ type Trader interface {
 	// FormatQuantity 格式化数量到正确的精度
 	FormatQuantity(symbol string, quantity float64) (string, error)
 }
+
+// ClientOrderIDTrader 支持指定客户端订单ID的交易器（可选实现）
+// 决策周期内的开平仓使用按周期和决策序号确定的客户端订单ID，
+// 进程在周期中途重启后可据此向交易所查询中断周期的订单是否已提交
+type ClientOrderIDTrader interface {
+	OpenLongWithClientID(symbol string, quantity float64, leverage int, clientOrderID string) (map[string]interface{}, error)
+	OpenShortWithClientID(symbol string, quantity float64, leverage int, clientOrderID string) (map[string]interface{}, error)
+	CloseLongWithClientID(symbol string, quantity float64, clientOrderID string) (map[string]interface{}, error)
+	CloseShortWithClientID(symbol string, quantity float64, clientOrderID string) (map[string]interface{}, error)
+
+	// QueryOrderByClientID 按客户端订单ID查询订单（found=false 表示交易所没有该订单）
+	QueryOrderByClientID(symbol, clientOrderID string) (order map[string]interface{}, found bool, err error)
+}
+
+// QuoteClientOrderIDTrader 支持指定客户端订单ID按金额开仓的交易器（可选实现，目前为模拟仓）
+// 同一个客户端订单ID重复提交时返回第一次的订单，不会重复成交，决策周期内按金额开仓的重试因此是幂等的
+type QuoteClientOrderIDTrader interface {
+	OpenLongQuoteWithClientID(symbol string, quoteQuantity float64, leverage int, clientOrderID string) (map[string]interface{}, error)
+	OpenShortQuoteWithClientID(symbol string, quoteQuantity float64, leverage int, clientOrderID string) (map[string]interface{}, error)
+}
+
+// QuoteQuantityTrader 支持按金额开仓的交易器（可选实现，目前为模拟仓）
+// 按花费的保证金资产金额（如 100 USDT）下单，成交数量由成交价决定：决策到成交之间的价格波动只影响数量，不影响花费
+type QuoteQuantityTrader interface {
+	OpenLongQuote(symbol string, quoteQuantity float64, leverage int) (map[string]interface{}, error)
+	OpenShortQuote(symbol string, quoteQuantity float64, leverage int) (map[string]interface{}, error)
+}
+
+// FundingFeeTrader 能查询持仓资金费的交易器（可选实现，目前为币安合约）
+type FundingFeeTrader interface {
+	// FundingPaid 查询币种自 since 起已支付的资金费（收到资金费时为负数）
+	FundingPaid(symbol string, since time.Time) (float64, error)
+}
+
+// PositionModeTrader 区分账户持仓模式的交易器（可选实现，目前为币安合约）
+// 持仓模式只在启动时检测，从不自动切换；对冲规则由执行器按交易员的 HedgePolicy 统一处理
+type PositionModeTrader interface {
+	// PositionMode 账户持仓模式（hedge/one_way，尚未检测成功时为空）
+	PositionMode() string
+}
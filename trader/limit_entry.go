@@ -0,0 +1,68 @@
+package trader
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"aspen/decision"
+	"aspen/logger"
+)
+
+// placeLimitOpen 决策给出限价时挂限价开仓单（仅模拟仓支持），价格到达限价后成交，成交时按决策挂上止损止盈；
+// 超过挂单最长保留时间未成交由 cancelStaleOrders 撤单。返回 false 表示未给出限价或限价已优于当前价格
+// （挂单会立即成交），由调用方按市价开仓
+func (at *AutoTrader) placeLimitOpen(d *decision.Decision, side string, currentPrice float64, actionRecord *logger.DecisionAction) (bool, error) {
+	if d.LimitPrice <= 0 {
+		return false, nil
+	}
+	pt, ok := at.trader.(*PaperTrader)
+	if !ok {
+		return true, reject(RejectLimitOrderUnsupported, fmt.Errorf("❌ %s 交易器不支持限价挂单，请按市价开仓（不填 limit_price）", at.exchange))
+	}
+
+	order := PaperLimitOrder{
+		Symbol:     d.Symbol,
+		Side:       side,
+		Quantity:   d.PositionSizeUSD / d.LimitPrice,
+		Price:      d.LimitPrice,
+		Leverage:   d.Leverage,
+		StopLoss:   d.StopLoss,
+		TakeProfit: d.TakeProfit,
+		Note:       d.Note,
+	}
+	if order.crossed(currentPrice) {
+		logger.Infof("  ℹ️ %s 限价 %.4f 已优于当前价格 %.4f，按市价开仓", d.Symbol, d.LimitPrice, currentPrice)
+		return false, nil
+	}
+	for _, pending := range pt.LimitOrders(d.Symbol) {
+		if pending.Side == side {
+			return true, reject(RejectPositionExists, fmt.Errorf("❌ %s 已有%s限价挂单（限价 %.4f），拒绝重复挂单",
+				d.Symbol, strings.ToLower(side), pending.Price))
+		}
+	}
+
+	placed, err := pt.PlaceLimitOrder(order)
+	if err != nil {
+		return true, err
+	}
+	actionRecord.Quantity = placed.Quantity
+	actionRecord.Price = placed.Price
+	at.intents.record(actionRecord.IntentKey, time.Now())
+	logger.Infof("  ✓ 已挂限价单 %s，限价: %.4f，数量: %.4f，冻结: %.2f %s", placed.ID, placed.Price, placed.Quantity, placed.Reserved, pt.MarginAsset())
+	return true, nil
+}
+
+// onPaperLimitFill 模拟仓限价单成交后按市价开仓的同一流程记录成交（参考价为限价，挂单等待时间不计入成交耗时）、
+// 推送开仓事件并记录持仓元数据（模拟仓释放锁后调用）
+func (at *AutoTrader) onPaperLimitFill(order PaperLimitOrder, fill map[string]interface{}) {
+	side := strings.ToLower(order.Side)
+	action := "open_" + side
+	at.recordFill(action, order.Symbol, priceRef{Price: order.Price, Source: fillSourceDecision}, fill)
+	at.emitOrderWebhook(action, order.Symbol, order.Leverage, fill)
+
+	// 单向持仓模式下全部数量用于抵消反向持仓时没有新持仓
+	if netted, _ := fill["netted"].(float64); netted < order.Quantity {
+		at.setPositionMeta(order.Symbol, side, order.StopLoss, order.TakeProfit, order.Note)
+	}
+}
@@ -0,0 +1,92 @@
+package trader
+
+import (
+	"testing"
+	"time"
+
+	"aspen/decision"
+	"aspen/logger"
+	"aspen/market"
+
+	"github.com/agiledragon/gomonkey/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestPlaceLimitOpen_EndToEnd 测试决策给出限价时模拟仓挂限价单，价格到达后成交并记录持仓元数据和止损止盈，
+// 限价已优于当前价格时按市价开仓，超时未成交的挂单由交易周期撤销并释放冻结的资金
+func TestPlaceLimitOpen_EndToEnd(t *testing.T) {
+	patches := gomonkey.ApplyFunc(market.Get, func(symbol string) (*market.Data, error) {
+		return &market.Data{Symbol: symbol, CurrentPrice: 100.0}, nil
+	})
+	defer patches.Reset()
+
+	price := 100.0
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	pt := newLimitOrderPaperTrader(t, &price, &now)
+	at := newJournalTestTrader(t.TempDir(), pt)
+	pt.SetLimitOrderHandler(at.onPaperLimitFill)
+	at.config.StaleOrderMaxAge = time.Hour
+
+	long := decision.Decision{Symbol: "SOLUSDT", Action: "open_long", Leverage: 5, PositionSizeUSD: 950,
+		LimitPrice: 95, StopLoss: 90, TakeProfit: 120, Note: "回踩支撑挂单"}
+	d := long
+	record := &logger.DecisionAction{Action: d.Action, Symbol: d.Symbol}
+	require.NoError(t, at.executeOpenLongWithRecord(&d, record))
+	orders := pt.LimitOrders("SOLUSDT")
+	require.Len(t, orders, 1)
+	assert.InDelta(t, 10.0, orders[0].Quantity, 1e-9, "按限价换算数量")
+	assert.InDelta(t, 95.0, record.Price, 1e-9)
+	assert.Empty(t, pt.positions, "挂单未成交前没有持仓")
+
+	d = long
+	err := at.executeOpenLongWithRecord(&d, &logger.DecisionAction{})
+	require.Error(t, err)
+	assert.Equal(t, RejectPositionExists, decision.RejectionCode(err), "同方向已有挂单时拒绝重复挂单")
+
+	price = 95
+	_, err = pt.GetPositions()
+	require.NoError(t, err)
+	pos := pt.positions["SOLUSDT_LONG"]
+	require.NotNil(t, pos)
+	assert.InDelta(t, 95.0, pos.EntryPrice, 1e-9)
+	assert.Contains(t, pos.StopOrders, PaperStopOrder{Type: PaperStopLoss, TriggerPrice: 90, Quantity: 10})
+	meta := at.positionMeta[positionMetaKey("SOLUSDT", "long")]
+	require.NotNil(t, meta, "成交回调记录持仓元数据")
+	assert.Equal(t, 90.0, meta.StopLoss)
+	assert.Equal(t, "回踩支撑挂单", meta.Note)
+
+	// 限价已优于当前价格（挂单会立即成交）时按市价开仓
+	d = decision.Decision{Symbol: "ETHUSDT", Action: "open_long", Leverage: 5, PositionSizeUSD: 500, LimitPrice: 100, StopLoss: 80, TakeProfit: 150}
+	require.NoError(t, at.executeOpenLongWithRecord(&d, &logger.DecisionAction{}))
+	assert.Contains(t, pt.positions, "ETHUSDT_LONG")
+	assert.Empty(t, pt.LimitOrders("ETHUSDT"))
+
+	// 超时未成交的挂单由交易周期撤销
+	d = decision.Decision{Symbol: "BTCUSDT", Action: "open_short", Leverage: 5, PositionSizeUSD: 1100, LimitPrice: 110, StopLoss: 120, TakeProfit: 80}
+	require.NoError(t, at.executeOpenShortWithRecord(&d, &logger.DecisionAction{}))
+	balanceAfterPlace := pt.balance
+	stale := pt.LimitOrders("BTCUSDT")
+	require.Len(t, stale, 1)
+	now = now.Add(2 * time.Hour)
+	logs := at.cancelStaleOrders()
+	require.Len(t, logs, 1)
+	assert.Empty(t, pt.LimitOrders(""))
+	assert.InDelta(t, balanceAfterPlace+stale[0].Reserved, pt.balance, 1e-9)
+}
+
+// TestPlaceLimitOpen_UnsupportedExchange 测试实盘交易器给出限价时拒绝开仓，不会按市价下单
+func TestPlaceLimitOpen_UnsupportedExchange(t *testing.T) {
+	patches := gomonkey.ApplyFunc(market.Get, func(symbol string) (*market.Data, error) {
+		return &market.Data{Symbol: symbol, CurrentPrice: 100.0}, nil
+	})
+	defer patches.Reset()
+
+	exchange := newClientIDMockTrader()
+	at := newJournalTestTrader(t.TempDir(), exchange)
+	d := &decision.Decision{Symbol: "SOLUSDT", Action: "open_long", Leverage: 5, PositionSizeUSD: 200, LimitPrice: 95, StopLoss: 90, TakeProfit: 120}
+	err := at.executeOpenLongWithRecord(d, &logger.DecisionAction{ClientOrderID: "limit-test"})
+	require.Error(t, err)
+	assert.Equal(t, RejectLimitOrderUnsupported, decision.RejectionCode(err))
+	assert.Empty(t, exchange.orders)
+}
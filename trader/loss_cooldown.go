@@ -0,0 +1,118 @@
+package trader
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"aspen/decision"
+	"aspen/logger"
+)
+
+// LossCooldownConfig 亏损后降仓冷却（默认关闭）：
+// 单笔平仓亏损达到 LossPct 后，之后的开仓/加仓仓位乘以 SizeFactor，
+// 持续 Minutes 分钟或 Trades 笔开仓（都配置时先到者结束）
+type LossCooldownConfig struct {
+	LossPct    float64 // 触发冷却的单笔亏损收益率（正数，如 5 表示亏损 ≥5%），0 表示不启用
+	SizeFactor float64 // 冷却期间的仓位系数（0-1）
+	Minutes    int     // 冷却时长（分钟），0 表示不按时间结束
+	Trades     int     // 冷却持续的开仓笔数，0 表示不按笔数结束
+}
+
+// DefaultLossCooldownConfig 默认亏损后降仓冷却参数（未启用）
+func DefaultLossCooldownConfig() LossCooldownConfig {
+	return LossCooldownConfig{
+		SizeFactor: 0.5,
+		Minutes:    60,
+	}
+}
+
+// Enabled 是否启用（需配置触发亏损、有效的仓位系数和至少一种结束条件）
+func (c LossCooldownConfig) Enabled() bool {
+	return c.LossPct > 0 && c.SizeFactor > 0 && c.SizeFactor < 1 && (c.Minutes > 0 || c.Trades > 0)
+}
+
+// lossCooldownState 当前的亏损冷却状态（零值表示未处于冷却）
+type lossCooldownState struct {
+	mu         sync.Mutex
+	active     bool
+	until      time.Time // 按时间结束的时刻（零值表示不按时间结束）
+	tradesLeft int       // 剩余按笔数计的开仓（0 表示不按笔数结束）
+	trigger    string    // 触发冷却的平仓说明
+}
+
+// start 触发（或重新开始）冷却
+func (s *lossCooldownState) start(cfg LossCooldownConfig, trigger string, now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.active = true
+	s.until = time.Time{}
+	if cfg.Minutes > 0 {
+		s.until = now.Add(time.Duration(cfg.Minutes) * time.Minute)
+	}
+	s.tradesLeft = cfg.Trades
+	s.trigger = trigger
+}
+
+// current 当前是否处于冷却（时间已到则结束冷却），返回触发说明
+func (s *lossCooldownState) current(now time.Time) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.active && !s.until.IsZero() && !now.Before(s.until) {
+		s.active = false
+	}
+	return s.trigger, s.active
+}
+
+// consumeTrade 冷却期间成交一笔开仓，按笔数计的冷却用完后结束
+func (s *lossCooldownState) consumeTrade() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.active || s.tradesLeft <= 0 {
+		return
+	}
+	s.tradesLeft--
+	if s.tradesLeft == 0 {
+		s.active = false
+	}
+}
+
+// observeLossCooldown 成功执行交易动作后更新亏损冷却：平仓亏损达到阈值时开始冷却，冷却期间的开仓/加仓计入笔数
+func (at *AutoTrader) observeLossCooldown(actionRecord *logger.DecisionAction, now time.Time) {
+	cfg := at.config.LossCooldown
+	if !cfg.Enabled() {
+		return
+	}
+
+	switch {
+	case strings.HasPrefix(actionRecord.Action, "close_") || actionRecord.Action == "partial_close":
+		if actionRecord.PnLPct > -cfg.LossPct {
+			return
+		}
+		trigger := fmt.Sprintf("%s %s 亏损 %.2f%%", actionRecord.Symbol, actionRecord.Action, actionRecord.PnLPct)
+		at.lossCooldown.start(cfg, trigger, now)
+		logger.Warnf("🧊 [%s] 亏损后降仓冷却：%s ≥ %.2f%%，之后开仓仓位 ×%.2f", at.name, trigger, cfg.LossPct, cfg.SizeFactor)
+	case actionRecord.Action == "open_long" || actionRecord.Action == "open_short" || actionRecord.Action == "add_to_position":
+		at.lossCooldown.consumeTrade()
+	}
+}
+
+// applyLossCooldown 冷却期间按系数缩小开仓/加仓仓位，调整写入 actionRecord.Adjustments
+func (at *AutoTrader) applyLossCooldown(d *decision.Decision, actionRecord *logger.DecisionAction, now time.Time) {
+	cfg := at.config.LossCooldown
+	if !cfg.Enabled() || d.PositionSizeUSD <= 0 {
+		return
+	}
+	trigger, active := at.lossCooldown.current(now)
+	if !active {
+		return
+	}
+
+	size := d.PositionSizeUSD * cfg.SizeFactor
+	adjustment := fmt.Sprintf("亏损后降仓冷却（%s）: 仓位 %.2f → %.2f %s（×%.2f）",
+		trigger, d.PositionSizeUSD, size, at.getStablecoinUnit(), cfg.SizeFactor)
+	logger.Warnf("  ⚠️  %s %s", d.Symbol, adjustment)
+	actionRecord.Adjustments = append(actionRecord.Adjustments, adjustment)
+	d.PositionSizeUSD = size
+}
@@ -0,0 +1,72 @@
+package trader
+
+import (
+	"testing"
+	"time"
+
+	"aspen/decision"
+	"aspen/logger"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// lossCooldownSize 冷却规则调整后的开仓仓位
+func lossCooldownSize(at *AutoTrader, size float64, now time.Time) float64 {
+	d := &decision.Decision{Symbol: "BTCUSDT", Action: "open_long", PositionSizeUSD: size}
+	at.applyLossCooldown(d, &logger.DecisionAction{}, now)
+	return d.PositionSizeUSD
+}
+
+// TestLossCooldown_ShrinksSizeUntilCooldownEnds 测试平仓亏损达到阈值后缩小开仓仓位，冷却时间结束后恢复
+func TestLossCooldown_ShrinksSizeUntilCooldownEnds(t *testing.T) {
+	at := newJournalTestTrader(t.TempDir(), &MockTrader{})
+	at.config.LossCooldown = LossCooldownConfig{LossPct: 5, SizeFactor: 0.5, Minutes: 60}
+	t0 := time.Date(2026, 1, 15, 12, 0, 0, 0, time.UTC)
+
+	// 亏损未达到阈值不触发
+	at.observeLossCooldown(&logger.DecisionAction{Action: "close_long", Symbol: "ETHUSDT", PnLPct: -4.9}, t0)
+	assert.Equal(t, 1000.0, lossCooldownSize(at, 1000, t0))
+
+	at.observeLossCooldown(&logger.DecisionAction{Action: "close_short", Symbol: "ETHUSDT", PnLPct: -6.2}, t0)
+	d := &decision.Decision{Symbol: "BTCUSDT", Action: "open_long", PositionSizeUSD: 1000}
+	record := &logger.DecisionAction{}
+	at.applyLossCooldown(d, record, t0.Add(10*time.Minute))
+	assert.Equal(t, 500.0, d.PositionSizeUSD)
+	if assert.Len(t, record.Adjustments, 1) {
+		assert.Contains(t, record.Adjustments[0], "ETHUSDT close_short 亏损 -6.20%")
+	}
+
+	// 冷却期间的开仓不影响按时间结束的冷却
+	at.observeLossCooldown(&logger.DecisionAction{Action: "open_long", Symbol: "BTCUSDT"}, t0.Add(10*time.Minute))
+	assert.Equal(t, 500.0, lossCooldownSize(at, 1000, t0.Add(59*time.Minute)))
+	assert.Equal(t, 1000.0, lossCooldownSize(at, 1000, t0.Add(60*time.Minute)))
+}
+
+// TestLossCooldown_EndsAfterTrades 测试按笔数计的冷却在指定笔数开仓后恢复，再次亏损重新开始冷却
+func TestLossCooldown_EndsAfterTrades(t *testing.T) {
+	at := newJournalTestTrader(t.TempDir(), &MockTrader{})
+	at.config.LossCooldown = LossCooldownConfig{LossPct: 3, SizeFactor: 0.25, Trades: 2}
+	now := time.Now()
+
+	at.observeLossCooldown(&logger.DecisionAction{Action: "partial_close", Symbol: "SOLUSDT", PnLPct: -3}, now)
+	for i := 0; i < 2; i++ {
+		assert.Equal(t, 250.0, lossCooldownSize(at, 1000, now.Add(24*time.Hour)), "第 %d 笔开仓应缩小仓位", i+1)
+		at.observeLossCooldown(&logger.DecisionAction{Action: "open_short", Symbol: "BTCUSDT"}, now)
+	}
+	assert.Equal(t, 1000.0, lossCooldownSize(at, 1000, now))
+
+	at.observeLossCooldown(&logger.DecisionAction{Action: "close_long", Symbol: "BTCUSDT", PnLPct: -10}, now)
+	assert.Equal(t, 250.0, lossCooldownSize(at, 1000, now))
+}
+
+// TestLossCooldown_Disabled 测试未启用时亏损平仓不影响仓位
+func TestLossCooldown_Disabled(t *testing.T) {
+	at := newJournalTestTrader(t.TempDir(), &MockTrader{})
+	at.config.LossCooldown = DefaultLossCooldownConfig()
+	now := time.Now()
+
+	at.observeLossCooldown(&logger.DecisionAction{Action: "close_long", Symbol: "BTCUSDT", PnLPct: -50}, now)
+	assert.Equal(t, 1000.0, lossCooldownSize(at, 1000, now))
+	assert.False(t, LossCooldownConfig{LossPct: 5, SizeFactor: 1, Minutes: 60}.Enabled(), "仓位系数为 1 时不启用")
+	assert.False(t, LossCooldownConfig{LossPct: 5, SizeFactor: 0.5}.Enabled(), "没有结束条件时不启用")
+}
@@ -0,0 +1,27 @@
+package trader
+
+import (
+	"fmt"
+
+	"aspen/logger"
+)
+
+// SetManageOnly 切换只管理持仓模式（下一笔决策立即生效，不需要重启交易员）
+func (at *AutoTrader) SetManageOnly(enabled bool) {
+	if at.manageOnly.Swap(enabled) != enabled {
+		logger.Infof("🔒 [%s] 只管理持仓模式: %v", at.name, enabled)
+	}
+}
+
+// ManageOnly 是否处于只管理持仓模式
+func (at *AutoTrader) ManageOnly() bool {
+	return at.manageOnly.Load()
+}
+
+// checkManageOnly 只管理持仓模式下拒绝开仓和加仓（平仓、部分平仓和止盈止损调整不受影响）
+func (at *AutoTrader) checkManageOnly() error {
+	if at.ManageOnly() {
+		return reject(RejectManageOnly, fmt.Errorf("❌ 只管理持仓模式下禁止新开仓和加仓"))
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package trader
+
+import (
+	"testing"
+
+	"aspen/decision"
+	"aspen/logger"
+	"aspen/market"
+
+	"github.com/agiledragon/gomonkey/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestManageOnly_RejectsOpensAllowsCloses 测试只管理持仓模式下开仓和加仓被拒绝且不下单，平仓照常执行
+func TestManageOnly_RejectsOpensAllowsCloses(t *testing.T) {
+	patches := gomonkey.ApplyFunc(market.Get, func(symbol string) (*market.Data, error) {
+		return &market.Data{Symbol: symbol, CurrentPrice: 50000.0}, nil
+	})
+	defer patches.Reset()
+
+	exchange := newClientIDMockTrader()
+	at := newJournalTestTrader(t.TempDir(), exchange)
+	at.SetManageOnly(true)
+
+	execute := func(action string) (*logger.DecisionAction, error) {
+		d := &decision.Decision{Symbol: "BTCUSDT", Action: action, Leverage: 5, PositionSizeUSD: 200}
+		record := &logger.DecisionAction{Action: action, Symbol: d.Symbol, ClientOrderID: "manage-only-" + action}
+		return record, at.executeDecisionWithRecord(d, record)
+	}
+
+	for _, action := range []string{"open_long", "open_short", "add_to_position"} {
+		record, err := execute(action)
+		require.Error(t, err, action)
+		assert.Equal(t, RejectManageOnly, record.RejectReason, action)
+		assert.Contains(t, record.Rejection, "只管理持仓模式", action)
+	}
+	assert.Empty(t, exchange.orders, "只管理持仓模式下不应下开仓单")
+
+	for _, action := range []string{"close_long", "close_short"} {
+		record, err := execute(action)
+		require.NoError(t, err, action)
+		assert.Empty(t, record.RejectReason, action)
+	}
+	assert.Len(t, exchange.orders, 2)
+
+	// 关闭后恢复开仓
+	at.SetManageOnly(false)
+	_, err := execute("open_long")
+	require.NoError(t, err)
+	assert.Len(t, exchange.orders, 3)
+}
@@ -0,0 +1,117 @@
+package trader
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"aspen/logger"
+)
+
+// 保证金资产
+const (
+	MarginAssetUSDT = "USDT"
+	MarginAssetUSDC = "USDC"
+)
+
+// assetPriceCacheTTL 保证金资产USD价格缓存时长
+const assetPriceCacheTTL = 5 * time.Minute
+
+// NormalizeMarginAsset 标准化保证金资产（为空时默认 USDT，以保持历史数据口径不变）
+func NormalizeMarginAsset(asset string) (string, error) {
+	asset = strings.ToUpper(strings.TrimSpace(asset))
+	switch asset {
+	case "":
+		return MarginAssetUSDT, nil
+	case MarginAssetUSDT, MarginAssetUSDC:
+		return asset, nil
+	default:
+		return "", fmt.Errorf("不支持的保证金资产: %s（仅支持 USDT/USDC）", asset)
+	}
+}
+
+// AssetBalance 单个保证金资产的余额（原生数量 + USD折算）
+type AssetBalance struct {
+	Asset            string  `json:"asset"`
+	WalletBalance    float64 `json:"wallet_balance"`    // 钱包余额（原生数量）
+	AvailableBalance float64 `json:"available_balance"` // 可用余额（原生数量）
+	UnrealizedProfit float64 `json:"unrealized_profit"` // 未实现盈亏（原生数量）
+	USDPrice         float64 `json:"usd_price"`         // 折算价格（1单位资产 = ? USD，USDT 视为 1）
+	USDValue         float64 `json:"usd_value"`         // 净值USD折算 =（钱包余额 + 未实现盈亏）× 折算价格
+}
+
+// assetPriceCache 保证金资产USD价格缓存（零值可用）
+type assetPriceCache struct {
+	mu     sync.Mutex
+	prices map[string]cachedAssetPrice
+}
+
+type cachedAssetPrice struct {
+	price     float64
+	fetchedAt time.Time
+}
+
+// USDPrice 获取资产的USD折算价格
+// USDT 作为系统计价单位固定为 1；BUSD 为已下线的遗留资产，按 1:1 兑换处理；其余资产通过 <ASSET>USDT 交易对价格折算
+func (c *assetPriceCache) USDPrice(asset string, fetch func(symbol string) (float64, error)) (float64, error) {
+	switch asset {
+	case MarginAssetUSDT, "BUSD":
+		return 1, nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if cached, ok := c.prices[asset]; ok && time.Since(cached.fetchedAt) < assetPriceCacheTTL {
+		return cached.price, nil
+	}
+
+	price, err := fetch(asset + "USDT")
+	if err != nil {
+		return 0, fmt.Errorf("获取 %s 折算价格失败: %w", asset, err)
+	}
+	if price <= 0 {
+		return 0, fmt.Errorf("%s 折算价格异常: %.6f", asset, price)
+	}
+	if c.prices == nil {
+		c.prices = make(map[string]cachedAssetPrice)
+	}
+	c.prices[asset] = cachedAssetPrice{price: price, fetchedAt: time.Now()}
+	return price, nil
+}
+
+// consolidateAssetBalances 按USD折算价格汇总多资产余额
+// 无法获取折算价格的资产不计入汇总（记录警告），避免按错误单位相加
+func consolidateAssetBalances(assets []AssetBalance, priceFn func(asset string) (float64, error)) (consolidated []AssetBalance, walletUSD, unrealizedUSD float64) {
+	for _, a := range assets {
+		if a.WalletBalance == 0 && a.UnrealizedProfit == 0 {
+			continue
+		}
+		price, err := priceFn(a.Asset)
+		if err != nil {
+			logger.Warnf("⚠️  %v，%s 余额不计入账户净值", err, a.Asset)
+			continue
+		}
+		a.USDPrice = price
+		a.USDValue = (a.WalletBalance + a.UnrealizedProfit) * price
+		walletUSD += a.WalletBalance * price
+		unrealizedUSD += a.UnrealizedProfit * price
+		consolidated = append(consolidated, a)
+	}
+	return consolidated, walletUSD, unrealizedUSD
+}
+
+// marginAssetEquity 将账户净值（USD折算）换算为保证金资产原生数量
+// 余额信息中没有该资产的折算价格时按 1:1 处理
+func marginAssetEquity(balance map[string]interface{}, asset string, equityUSD float64) (native, usdPrice float64) {
+	usdPrice = 1
+	if assets, ok := balance["assetBalances"].([]AssetBalance); ok {
+		for _, a := range assets {
+			if a.Asset == asset && a.USDPrice > 0 {
+				usdPrice = a.USDPrice
+				break
+			}
+		}
+	}
+	return equityUSD / usdPrice, usdPrice
+}
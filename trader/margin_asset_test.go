@@ -0,0 +1,104 @@
+package trader
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"aspen/logger"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestNormalizeMarginAsset 测试保证金资产标准化
+func TestNormalizeMarginAsset(t *testing.T) {
+	for input, want := range map[string]string{"": "USDT", " usdc ": "USDC", "USDT": "USDT"} {
+		got, err := NormalizeMarginAsset(input)
+		assert.NoError(t, err, input)
+		assert.Equal(t, want, got, input)
+	}
+	_, err := NormalizeMarginAsset("BNB")
+	assert.Error(t, err)
+}
+
+// TestConsolidateAssetBalances_SkipsUnpricedAssets 测试无法折算的资产不计入汇总
+func TestConsolidateAssetBalances_SkipsUnpricedAssets(t *testing.T) {
+	assets := []AssetBalance{
+		{Asset: "USDT", WalletBalance: 1000, UnrealizedProfit: -50},
+		{Asset: "USDC", WalletBalance: 500},
+		{Asset: "XYZ", WalletBalance: 10},
+	}
+	consolidated, wallet, unrealized := consolidateAssetBalances(assets, func(asset string) (float64, error) {
+		switch asset {
+		case "USDT":
+			return 1, nil
+		case "USDC":
+			return 1.001, nil
+		}
+		return 0, errors.New("no price")
+	})
+
+	assert.Len(t, consolidated, 2)
+	assert.InDelta(t, 1000+500*1.001, wallet, 1e-9)
+	assert.InDelta(t, -50, unrealized, 1e-9)
+}
+
+// TestPaperTrader_MarginAsset 测试模拟仓保证金资产参数化
+func TestPaperTrader_MarginAsset(t *testing.T) {
+	pt, err := NewPaperTrader(1000)
+	assert.NoError(t, err)
+	assert.Equal(t, MarginAssetUSDT, pt.MarginAsset(), "默认 USDT")
+
+	pt.SetMarginAsset("usdc")
+	assert.Equal(t, MarginAssetUSDC, pt.MarginAsset())
+
+	balance, err := pt.GetBalance()
+	assert.NoError(t, err)
+	assets := balance["assetBalances"].([]AssetBalance)
+	if assert.Len(t, assets, 1) {
+		assert.Equal(t, MarginAssetUSDC, assets[0].Asset)
+		assert.InDelta(t, 1000, assets[0].USDValue, 1e-9)
+	}
+}
+
+// TestPaperTrader_ConfiguredCurrency 测试模拟仓按配置的账户币种创建：余额、日志和交易员状态使用同一币种
+func TestPaperTrader_ConfiguredCurrency(t *testing.T) {
+	var buf bytes.Buffer
+	prevOut := logger.Log.Out
+	logger.Log.SetOutput(&buf)
+	defer logger.Log.SetOutput(prevOut)
+
+	pt, err := NewPaperTraderWithDB(2500, " usdc ", nil, "")
+	require.NoError(t, err)
+	assert.Equal(t, MarginAssetUSDC, pt.MarginAsset())
+	assert.Contains(t, buf.String(), "初始余额: 2500.00 USDC")
+
+	balance, err := pt.GetBalance()
+	require.NoError(t, err)
+	assets := balance["assetBalances"].([]AssetBalance)
+	if assert.Len(t, assets, 1) {
+		assert.Equal(t, MarginAssetUSDC, assets[0].Asset)
+		assert.InDelta(t, 2500, assets[0].WalletBalance, 1e-9)
+	}
+
+	// 交易员未显式设置保证金资产时沿用模拟仓的币种（不再固定显示 USDC）
+	at := newJournalTestTrader(t.TempDir(), pt)
+	at.exchange = "paper"
+	assert.Equal(t, MarginAssetUSDC, at.getStablecoinUnit())
+	assert.Equal(t, MarginAssetUSDC, at.GetStatus()["margin_asset"])
+
+	usdt, err := NewPaperTraderWithDB(1000, "", nil, "")
+	require.NoError(t, err)
+	at = newJournalTestTrader(t.TempDir(), usdt)
+	at.exchange = "paper"
+	assert.Equal(t, MarginAssetUSDT, at.getStablecoinUnit())
+	info, err := at.GetAccountInfo()
+	require.NoError(t, err)
+	assert.Equal(t, MarginAssetUSDT, info["margin_asset"])
+
+	// 无效币种回退为 USDT
+	invalid, err := NewPaperTraderWithDB(1000, "BNB", nil, "")
+	require.NoError(t, err)
+	assert.Equal(t, MarginAssetUSDT, invalid.MarginAsset())
+}
@@ -0,0 +1,8 @@
+package trader
+
+import "aspen/market"
+
+// getMarketData 从交易员配置的行情数据源获取市场数据（未配置时使用全局数据源），指标按交易员配置的周期计算
+func (at *AutoTrader) getMarketData(symbol string) (*market.Data, error) {
+	return market.GetWithIndicators(at.config.DataSource, symbol, at.config.Indicators)
+}
@@ -0,0 +1,108 @@
+package trader
+
+import (
+	"testing"
+
+	"aspen/decision"
+	"aspen/logger"
+	"aspen/market"
+	"aspen/pool"
+
+	"github.com/agiledragon/gomonkey/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestGetMarketData_PerTraderDataSource 测试两个配置了不同数据源的交易员各自按对应交易所的价格开仓
+func TestGetMarketData_PerTraderDataSource(t *testing.T) {
+	prices := map[market.DataSource]float64{market.DataSourceBinance: 100, market.DataSourceBybit: 50}
+	var requested []market.DataSource
+	patches := gomonkey.ApplyFunc(market.GetFrom, func(source market.DataSource, symbol string) (*market.Data, error) {
+		requested = append(requested, source)
+		return &market.Data{Symbol: symbol, CurrentPrice: prices[source]}, nil
+	})
+	defer patches.Reset()
+
+	for _, tt := range []struct {
+		source       market.DataSource
+		wantQuantity float64
+	}{
+		{market.DataSourceBinance, 2},
+		{market.DataSourceBybit, 4},
+	} {
+		t.Run(string(tt.source), func(t *testing.T) {
+			requested = nil
+			at := newJournalTestTrader(t.TempDir(), newClientIDMockTrader())
+			at.config.DataSource = tt.source
+
+			d := &decision.Decision{Symbol: "SOLUSDT", Action: "open_long", Leverage: 5, PositionSizeUSD: 200}
+			record := &logger.DecisionAction{Action: d.Action, Symbol: d.Symbol}
+			require.NoError(t, at.executeOpenLongWithRecord(d, record))
+
+			assert.Equal(t, prices[tt.source], record.Price)
+			assert.InDelta(t, tt.wantQuantity, record.Quantity, 1e-9)
+			require.NotEmpty(t, requested)
+			for _, source := range requested {
+				assert.Equal(t, tt.source, source)
+			}
+		})
+	}
+}
+
+// TestRequiredSymbols 测试交易员需要订阅的币种：交易币种、持仓币种和 BTC，其他数据源的交易员不需要订阅
+func TestRequiredSymbols(t *testing.T) {
+	at := newJournalTestTrader(t.TempDir(), newClientIDMockTrader())
+	at.tradingCoins = []string{"sol", "ETHUSDT"}
+	at.positionMeta = map[string]*PositionMeta{"DOGEUSDT_long": {}}
+
+	symbols, usesPool := at.RequiredSymbols()
+	assert.Equal(t, []string{"BTCUSDT", "DOGEUSDT", "ETHUSDT", "SOLUSDT"}, symbols)
+	assert.False(t, usesPool)
+
+	at.tradingCoins = nil
+	_, usesPool = at.RequiredSymbols()
+	assert.True(t, usesPool, "无交易币种和默认币种时使用币种池")
+
+	at.config.DataSource = market.DataSourceBybit
+	if market.GetCurrentDataSource() != market.DataSourceBybit {
+		symbols, _ = at.RequiredSymbols()
+		assert.Empty(t, symbols)
+	}
+}
+
+// TestGetCandidateCoins_CoinPoolCap 测试币种池数量上限按交易员配置生效：未配置时使用完整币种池，配置后按交易员的上限和排序依据截取
+func TestGetCandidateCoins_CoinPoolCap(t *testing.T) {
+	merged := &pool.MergedCoinPool{
+		AllSymbols:    []string{"BTCUSDT", "ETHUSDT", "SOLUSDT"},
+		SymbolSources: map[string][]string{"BTCUSDT": {"ai500"}, "ETHUSDT": {"oi_top"}, "SOLUSDT": {"oi_top"}},
+	}
+	patches := gomonkey.ApplyFunc(pool.GetMergedCoinPool, func(int) (*pool.MergedCoinPool, error) {
+		return merged, nil
+	})
+	defer patches.Reset()
+	var capArgs []interface{}
+	patches.ApplyFunc(pool.CapMergedCoinPool, func(m *pool.MergedCoinPool, maxSymbols int, rankBy string) *pool.MergedCoinPool {
+		capArgs = []interface{}{maxSymbols, rankBy}
+		if maxSymbols <= 0 {
+			return m
+		}
+		capped := *m
+		capped.AllSymbols = m.AllSymbols[len(m.AllSymbols)-maxSymbols:]
+		return &capped
+	})
+
+	at := newJournalTestTrader(t.TempDir(), newClientIDMockTrader())
+	coins, err := at.getCandidateCoins()
+	require.NoError(t, err)
+	assert.Len(t, coins, 3, "未配置上限时不截取")
+	assert.Equal(t, []interface{}{0, ""}, capArgs)
+
+	at.config.CoinPoolMaxSymbols = 2
+	at.config.CoinPoolRankBy = pool.RankByOI
+	coins, err = at.getCandidateCoins()
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{2, pool.RankByOI}, capArgs)
+	require.Len(t, coins, 2)
+	assert.Equal(t, "ETHUSDT", coins[0].Symbol)
+	assert.Equal(t, []string{"oi_top"}, coins[0].Sources)
+}
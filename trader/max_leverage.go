@@ -0,0 +1,51 @@
+package trader
+
+import (
+	"context"
+	"fmt"
+
+	"aspen/decision"
+	"aspen/logger"
+	"aspen/market"
+
+	"github.com/adshao/go-binance/v2/futures"
+)
+
+// applySymbolMaxLeverage 按交易所币种杠杆上限（数据源元数据或手动配置）收紧开仓/加仓杠杆，调整写入 actionRecord.Adjustments
+func (at *AutoTrader) applySymbolMaxLeverage(d *decision.Decision, actionRecord *logger.DecisionAction) {
+	maxLev, ok := market.SymbolMaxLeverage(d.Symbol)
+	if !ok || d.Leverage <= maxLev {
+		return
+	}
+	adjustment := fmt.Sprintf("交易所杠杆上限: %dx → %dx", d.Leverage, maxLev)
+	logger.Warnf("  ⚠️  %s %s", d.Symbol, adjustment)
+	actionRecord.Adjustments = append(actionRecord.Adjustments, adjustment)
+	d.Leverage = maxLev
+	if actionRecord.Leverage > maxLev {
+		actionRecord.Leverage = maxLev
+	}
+}
+
+// loadLeverageBrackets 查询 Binance 杠杆分层（需要签名），按各币种最高档的初始杠杆记录最大杠杆
+func (t *FuturesTrader) loadLeverageBrackets() error {
+	brackets, err := t.client.NewGetLeverageBracketService().Do(context.Background())
+	if err != nil {
+		return fmt.Errorf("查询杠杆分层失败: %w", err)
+	}
+	leverages := leverageBracketMaxima(brackets)
+	market.RegisterExchangeMaxLeverages(leverages)
+	return nil
+}
+
+// leverageBracketMaxima 各币种杠杆分层中最高的初始杠杆（名义价值最小的一档）
+func leverageBracketMaxima(brackets []*futures.LeverageBracket) map[string]int {
+	leverages := make(map[string]int, len(brackets))
+	for _, b := range brackets {
+		for _, bracket := range b.Brackets {
+			if bracket.InitialLeverage > leverages[b.Symbol] {
+				leverages[b.Symbol] = bracket.InitialLeverage
+			}
+		}
+	}
+	return leverages
+}
@@ -0,0 +1,37 @@
+package trader
+
+import (
+	"testing"
+
+	"aspen/decision"
+	"aspen/logger"
+	"aspen/market"
+
+	"github.com/adshao/go-binance/v2/futures"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestApplySymbolMaxLeverage 测试按 Binance 杠杆分层的最高档收紧AI给出的杠杆
+func TestApplySymbolMaxLeverage(t *testing.T) {
+	leverages := leverageBracketMaxima([]*futures.LeverageBracket{
+		{Symbol: "LEVTESTUSDT", Brackets: []futures.Bracket{
+			{Bracket: 1, InitialLeverage: 20, NotionalCap: 5000},
+			{Bracket: 2, InitialLeverage: 10, NotionalFloor: 5000, NotionalCap: 25000},
+		}},
+	})
+	assert.Equal(t, map[string]int{"LEVTESTUSDT": 20}, leverages)
+	market.RegisterExchangeMaxLeverages(leverages)
+
+	at := newJournalTestTrader(t.TempDir(), &MockTrader{})
+	d := &decision.Decision{Symbol: "LEVTESTUSDT", Action: "open_long", Leverage: 50}
+	record := &logger.DecisionAction{Leverage: 50}
+	at.applySymbolMaxLeverage(d, record)
+	assert.Equal(t, 20, d.Leverage)
+	assert.Equal(t, 20, record.Leverage)
+	assert.Equal(t, []string{"交易所杠杆上限: 50x → 20x"}, record.Adjustments)
+
+	// 未知币种或未超限时不调整
+	d = &decision.Decision{Symbol: "UNKNOWNUSDT", Action: "open_long", Leverage: 50}
+	at.applySymbolMaxLeverage(d, &logger.DecisionAction{})
+	assert.Equal(t, 50, d.Leverage)
+}
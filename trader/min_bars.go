@@ -0,0 +1,85 @@
+package trader
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"aspen/decision"
+	"aspen/logger"
+)
+
+// minBarsCandleInterval 两次交易间隔按该周期的K线计数（与指标使用的K线周期一致）
+const minBarsCandleInterval = confirmationCandleInterval
+
+// candleIndex 时间所在K线的序号（按 minBarsCandleInterval 划分）
+func candleIndex(t time.Time) int64 {
+	return t.Unix() / int64(minBarsCandleInterval/time.Second)
+}
+
+// lastActionBook 各币种最近一次开平仓所在K线的序号（零值可用）
+type lastActionBook struct {
+	mu      sync.Mutex
+	candles map[string]int64
+}
+
+// record 记录币种在某根K线上执行了开平仓（只前进不后退）
+func (b *lastActionBook) record(symbol string, candle int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.candles == nil {
+		b.candles = make(map[string]int64)
+	}
+	if prev, ok := b.candles[symbol]; ok && prev >= candle {
+		return
+	}
+	b.candles[symbol] = candle
+}
+
+// get 币种最近一次开平仓所在K线的序号
+func (b *lastActionBook) get(symbol string) (int64, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	candle, ok := b.candles[symbol]
+	return candle, ok
+}
+
+// restoreFromRecords 从决策日志恢复各币种最近一次开平仓的K线（重启后最少间隔K线检查仍然有效）
+func (b *lastActionBook) restoreFromRecords(records []*logger.DecisionRecord) {
+	for _, record := range records {
+		for _, action := range record.Decisions {
+			if !action.Success || !isTrackedAction(action.Action) || action.Timestamp.IsZero() {
+				continue
+			}
+			b.record(action.Symbol, candleIndex(action.Timestamp))
+		}
+	}
+}
+
+// observeLastAction 成功执行开平仓后记录所在K线
+func (at *AutoTrader) observeLastAction(actionRecord *logger.DecisionAction, now time.Time) {
+	if !isTrackedAction(actionRecord.Action) {
+		return
+	}
+	at.lastActions.record(actionRecord.Symbol, candleIndex(now))
+}
+
+// checkMinBarsSinceTrade 开平仓前检查距同一币种上次开平仓是否已过去足够的K线，不足时跳过本次动作，结论写入 actionRecord.GuardVerdict
+// （只约束AI决策，交易所上的止损/止盈单不受影响）
+func (at *AutoTrader) checkMinBarsSinceTrade(d *decision.Decision, actionRecord *logger.DecisionAction, now time.Time) error {
+	minBars := at.config.MinBarsBetweenTrades
+	if minBars <= 0 {
+		return nil
+	}
+	last, ok := at.lastActions.get(d.Symbol)
+	if !ok {
+		return nil
+	}
+	bars := candleIndex(now) - last
+	if bars >= int64(minBars) {
+		return nil
+	}
+	actionRecord.GuardVerdict = fmt.Sprintf("跳过：%s 距上次开平仓 %d/%d 根K线", d.Symbol, bars, minBars)
+	return reject(RejectMinBars, fmt.Errorf("❌ 最少间隔K线：%s 距上次开平仓仅 %d 根K线（要求 %d 根），跳过 %s",
+		d.Symbol, bars, minBars, d.Action))
+}
@@ -0,0 +1,73 @@
+package trader
+
+import (
+	"testing"
+	"time"
+
+	"aspen/decision"
+	"aspen/logger"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestMinBarsSinceTrade_SkipsWithinWindow 测试同一币种上次开平仓后K线数不足时跳过动作，过去足够的K线后放行
+func TestMinBarsSinceTrade_SkipsWithinWindow(t *testing.T) {
+	at := newJournalTestTrader(t.TempDir(), &MockTrader{})
+	at.config.MinBarsBetweenTrades = 3
+
+	check := func(symbol, action string, now time.Time) (*logger.DecisionAction, error) {
+		record := &logger.DecisionAction{Symbol: symbol, Action: action}
+		return record, at.checkMinBarsSinceTrade(&decision.Decision{Symbol: symbol, Action: action}, record, now)
+	}
+
+	t0 := time.Date(2026, 1, 15, 12, 0, 30, 0, time.UTC)
+	_, err := check("BTCUSDT", "open_long", t0)
+	require.NoError(t, err, "没有开平仓记录时不限制")
+	at.observeLastAction(&logger.DecisionAction{Symbol: "BTCUSDT", Action: "open_long"}, t0)
+
+	record, err := check("BTCUSDT", "close_long", t0.Add(time.Minute))
+	require.Error(t, err, "同一根K线内")
+	assert.Equal(t, RejectMinBars, decision.RejectionCode(err))
+	assert.Contains(t, record.GuardVerdict, "0/3")
+
+	_, err = check("BTCUSDT", "close_long", t0.Add(6*time.Minute))
+	require.Error(t, err, "只过去了 2 根K线")
+	_, err = check("ETHUSDT", "open_short", t0.Add(time.Minute))
+	assert.NoError(t, err, "其他币种不受影响")
+
+	_, err = check("BTCUSDT", "close_long", t0.Add(9*time.Minute))
+	assert.NoError(t, err, "已过去 3 根K线")
+
+	// 非开平仓动作不计入
+	at.observeLastAction(&logger.DecisionAction{Symbol: "BTCUSDT", Action: "update_stop_loss"}, t0.Add(9*time.Minute))
+	_, err = check("BTCUSDT", "close_long", t0.Add(9*time.Minute))
+	assert.NoError(t, err)
+
+	// 关闭检查时不限制
+	at.observeLastAction(&logger.DecisionAction{Symbol: "BTCUSDT", Action: "close_long"}, t0.Add(9*time.Minute))
+	at.config.MinBarsBetweenTrades = 0
+	_, err = check("BTCUSDT", "open_short", t0.Add(9*time.Minute))
+	assert.NoError(t, err)
+}
+
+// TestMinBarsSinceTrade_OpenPathRejected 测试开仓执行路径在间隔K线不足时拒绝下单，并可从决策日志恢复上次开平仓的K线
+func TestMinBarsSinceTrade_OpenPathRejected(t *testing.T) {
+	mock := &MockTrader{}
+	at := newJournalTestTrader(t.TempDir(), mock)
+	at.config.MinBarsBetweenTrades = 5
+
+	at.lastActions.restoreFromRecords([]*logger.DecisionRecord{{Decisions: []logger.DecisionAction{
+		{Symbol: "BTCUSDT", Action: "close_short", Success: true, Timestamp: time.Now()},
+		{Symbol: "ETHUSDT", Action: "open_long", Success: false, Timestamp: time.Now()},
+	}}})
+
+	record := &logger.DecisionAction{Symbol: "BTCUSDT", Action: "open_long"}
+	err := at.executeOpenLongWithRecord(&decision.Decision{Symbol: "BTCUSDT", Action: "open_long", PositionSizeUSD: 100, Leverage: 5}, record)
+	require.Error(t, err)
+	assert.Equal(t, RejectMinBars, decision.RejectionCode(err))
+	assert.NotEmpty(t, record.GuardVerdict)
+
+	_, ok := at.lastActions.get("ETHUSDT")
+	assert.False(t, ok, "失败的动作不恢复")
+}
@@ -0,0 +1,22 @@
+package trader
+
+// OpenGate 跨交易员的开仓协调（如同一用户所有交易员的最大同时持仓数）
+type OpenGate interface {
+	// AcquireOpen 新开仓前申请名额，超限时返回错误；获准时返回的 release 必须在下单结束后调用
+	AcquireOpen(at *AutoTrader, symbol, side string) (release func(), err error)
+}
+
+// acquireOpenSlot 新开仓前向开仓协调器申请名额，未配置协调器时直接放行
+func (at *AutoTrader) acquireOpenSlot(symbol, side string) (func(), error) {
+	if at.config.OpenGate == nil {
+		return func() {}, nil
+	}
+	release, err := at.config.OpenGate.AcquireOpen(at, symbol, side)
+	if err != nil {
+		return nil, err
+	}
+	if release == nil {
+		release = func() {}
+	}
+	return release, nil
+}
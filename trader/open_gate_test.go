@@ -0,0 +1,67 @@
+package trader
+
+import (
+	"fmt"
+	"testing"
+
+	"aspen/decision"
+	"aspen/logger"
+	"aspen/market"
+
+	"github.com/agiledragon/gomonkey/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingGate 多个交易员共用的开仓名额，下单结束后计入已开仓数
+type countingGate struct {
+	limit    int
+	opened   int
+	acquired []string
+}
+
+func (g *countingGate) AcquireOpen(at *AutoTrader, symbol, side string) (func(), error) {
+	if g.opened >= g.limit {
+		return nil, fmt.Errorf("已有 %d 个持仓，达到上限 %d", g.opened, g.limit)
+	}
+	g.acquired = append(g.acquired, at.id+":"+symbol+"_"+side)
+	return func() { g.opened++ }, nil
+}
+
+// TestOpenGate_SharedAcrossTraders 测试两个交易员共用开仓协调器，合计开仓超过上限时超限的开仓被拒绝且不下单
+func TestOpenGate_SharedAcrossTraders(t *testing.T) {
+	patches := gomonkey.ApplyFunc(market.Get, func(symbol string) (*market.Data, error) {
+		return &market.Data{Symbol: symbol, CurrentPrice: 100.0}, nil
+	})
+	defer patches.Reset()
+
+	gate := &countingGate{limit: 2}
+	newTrader := func(id string) (*AutoTrader, *clientIDMockTrader) {
+		exchange := newClientIDMockTrader()
+		at := newJournalTestTrader(t.TempDir(), exchange)
+		at.id = id
+		at.config.OpenGate = gate
+		return at, exchange
+	}
+	a, exchangeA := newTrader("a")
+	b, exchangeB := newTrader("b")
+
+	open := func(at *AutoTrader, symbol, action string) error {
+		d := &decision.Decision{Symbol: symbol, Action: action, Leverage: 5, PositionSizeUSD: 200}
+		record := &logger.DecisionAction{Action: action, Symbol: symbol, ClientOrderID: at.id + "-" + symbol}
+		if action == "open_long" {
+			return at.executeOpenLongWithRecord(d, record)
+		}
+		return at.executeOpenShortWithRecord(d, record)
+	}
+
+	require.NoError(t, open(a, "BTCUSDT", "open_long"))
+	require.NoError(t, open(b, "ETHUSDT", "open_short"))
+
+	err := open(b, "SOLUSDT", "open_long")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "达到上限 2")
+	assert.Len(t, exchangeA.orders, 1)
+	assert.Len(t, exchangeB.orders, 1, "超限时不应下单")
+	assert.Equal(t, []string{"a:BTCUSDT_long", "b:ETHUSDT_short"}, gate.acquired)
+}
@@ -0,0 +1,104 @@
+package trader
+
+import (
+	"fmt"
+	"strings"
+
+	"aspen/market"
+)
+
+// orderPreviewFeeRate 预览手续费使用的费率（与模拟仓开仓一致，按 Taker 0.04% 计）
+const orderPreviewFeeRate = 0.0004
+
+// OrderPreview 假设订单的保证金和强平价格预览
+type OrderPreview struct {
+	Source           string  `json:"source"` // 计算所用的账户（见 SimulationSourcePaper / SimulationSourceLive）
+	Symbol           string  `json:"symbol"`
+	Side             string  `json:"side"` // long / short
+	Price            float64 `json:"price"`
+	Quantity         float64 `json:"quantity"`
+	NotionalUSD      float64 `json:"notional_usd"`
+	Leverage         int     `json:"leverage"`
+	MarginMode       string  `json:"margin_mode"`       // cross / isolated
+	RequiredMargin   float64 `json:"required_margin"`   // 名义价值 / 杠杆
+	Fee              float64 `json:"fee"`               // 开仓手续费
+	TotalRequired    float64 `json:"total_required"`    // 保证金 + 手续费
+	AvailableBalance float64 `json:"available_balance"` // 下单前的可用余额
+	Fits             bool    `json:"fits"`              // 可用余额是否足够
+	LiquidationPrice float64 `json:"liquidation_price"` // 成交后该方向持仓的强平价格（0 表示不会被强平）
+}
+
+// PreviewOrder 计算假设订单需要的保证金、手续费和成交后的强平价格，不下单也不修改交易员状态。
+// sizeUSD 与 quantity 二选一（quantity 优先）；余额不足时按补足差额后成交计算强平价格
+func (at *AutoTrader) PreviewOrder(symbol, side string, sizeUSD, quantity float64, leverage int) (*OrderPreview, error) {
+	symbol = market.Normalize(symbol)
+	side = strings.ToLower(strings.TrimSpace(side))
+	if side != "long" && side != "short" {
+		return nil, fmt.Errorf("side 必须是 long 或 short: %s", side)
+	}
+	if leverage <= 0 {
+		return nil, fmt.Errorf("杠杆必须大于0: %d", leverage)
+	}
+	if quantity <= 0 && sizeUSD <= 0 {
+		return nil, fmt.Errorf("需要 size_usd 或 quantity")
+	}
+
+	account, source, err := at.simulationAccount()
+	if err != nil {
+		return nil, err
+	}
+	price, err := account.GetMarketPrice(symbol)
+	if err != nil {
+		return nil, err
+	}
+	if price <= 0 {
+		return nil, fmt.Errorf("%s 价格无效: %.8f", symbol, price)
+	}
+	if quantity <= 0 {
+		quantity = sizeUSD / price
+	}
+	balance, err := account.GetBalance()
+	if err != nil {
+		return nil, fmt.Errorf("获取账户余额失败: %w", err)
+	}
+
+	preview := &OrderPreview{
+		Source:      source,
+		Symbol:      symbol,
+		Side:        side,
+		Price:       price,
+		Quantity:    quantity,
+		NotionalUSD: quantity * price,
+		Leverage:    leverage,
+	}
+	preview.RequiredMargin = preview.NotionalUSD / float64(leverage)
+	preview.Fee = preview.NotionalUSD * orderPreviewFeeRate
+	preview.TotalRequired = preview.RequiredMargin + preview.Fee
+	preview.AvailableBalance, _ = balance["availableBalance"].(float64)
+	preview.Fits = preview.AvailableBalance >= preview.TotalRequired
+
+	// 在账户副本上成交以计算强平价格（全仓强平价格取决于账户余额和其他持仓）
+	_ = account.SetMarginMode(symbol, at.config.IsCrossMargin)
+	if !preview.Fits {
+		account.mu.Lock()
+		account.balance += preview.TotalRequired - preview.AvailableBalance + 1e-9 // 避免浮点误差导致副本仍然余额不足
+		account.mu.Unlock()
+	}
+	if side == "short" {
+		_, err = account.OpenShort(symbol, quantity, leverage)
+	} else {
+		_, err = account.OpenLong(symbol, quantity, leverage)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("计算强平价格失败: %w", err)
+	}
+
+	account.updateUnrealizedPnL()
+	account.mu.RLock()
+	defer account.mu.RUnlock()
+	preview.MarginMode = paperMarginMode(account.isolated[symbol])
+	if pos, ok := account.positions[account.getPositionKey(symbol, strings.ToUpper(side))]; ok {
+		preview.LiquidationPrice = account.liquidationPriceLocked(pos)
+	}
+	return preview, nil
+}
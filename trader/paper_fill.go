@@ -0,0 +1,148 @@
+package trader
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"aspen/logger"
+	"aspen/market"
+)
+
+// 模拟仓成交价模式
+const (
+	PaperFillInstant        = "instant"          // 按下单时的实时价格成交（默认）
+	PaperFillNextCandleOpen = "next_candle_open" // 回测按下单后下一根K线的开盘价成交，实盘模拟仓按短暂成交延迟后的实时价格成交
+)
+
+// defaultPaperFillLatency 实盘模拟仓 next_candle_open 模式的默认成交延迟
+const defaultPaperFillLatency = time.Second
+
+// maxPaperFillLatency 实盘模拟仓成交延迟上限（下单期间持有开仓锁，不能长时间等待）
+const maxPaperFillLatency = 5 * time.Second
+
+// nextCandleRetries 下一根K线开盘后仍未取到时的重试次数
+const nextCandleRetries = 3
+
+// nextCandleRetryDelay 重试间隔（K线开盘后行情接口可能稍有延迟）
+const nextCandleRetryDelay = 2 * time.Second
+
+// PaperFillConfig 模拟仓成交价模型
+// next_candle_open 模式下，回测（模拟时钟）开仓和平仓都按下单时刻之后第一根K线的开盘价成交；
+// 实盘模拟仓不等待K线开盘（最长可达一个K线周期，期间一直持有开仓锁），而是等待 Latency 后按实时价格成交
+type PaperFillConfig struct {
+	Mode     string        // instant / next_candle_open
+	Interval string        // 下一根K线的周期（默认3m，与决策使用的日内K线一致）
+	Latency  time.Duration // 实盘模拟仓的成交延迟（默认1秒，上限5秒）
+}
+
+// DefaultPaperFillConfig 默认成交价模型（实时价格成交）
+func DefaultPaperFillConfig() PaperFillConfig {
+	return PaperFillConfig{Mode: PaperFillInstant, Interval: "3m"}
+}
+
+// NormalizePaperFillMode 校验成交价模式（空值为 instant）
+func NormalizePaperFillMode(mode string) (string, error) {
+	switch mode = strings.ToLower(strings.TrimSpace(mode)); mode {
+	case "":
+		return PaperFillInstant, nil
+	case PaperFillInstant, PaperFillNextCandleOpen:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("无效的模拟仓成交价模式: %s（可选 %s、%s）", mode, PaperFillInstant, PaperFillNextCandleOpen)
+	}
+}
+
+// candleFunc 获取最近 limit 根K线
+type candleFunc func(symbol, interval string, limit int) ([]market.Kline, error)
+
+// SetFillModel 设置模拟仓成交价模型（无效的模式或周期使用默认值）
+func (t *PaperTrader) SetFillModel(cfg PaperFillConfig) {
+	defaults := DefaultPaperFillConfig()
+	mode, err := NormalizePaperFillMode(cfg.Mode)
+	if err != nil {
+		logger.Warnf("⚠️ [Paper Trading] %v，使用 %s", err, defaults.Mode)
+		mode = defaults.Mode
+	}
+	cfg.Mode = mode
+	if cfg.Interval == "" {
+		cfg.Interval = defaults.Interval
+	}
+	if d, err := time.ParseDuration(cfg.Interval); err != nil || d <= 0 {
+		logger.Warnf("⚠️ [Paper Trading] 成交价K线周期 %q 无效，使用 %s", cfg.Interval, defaults.Interval)
+		cfg.Interval = defaults.Interval
+	}
+	if cfg.Latency <= 0 {
+		cfg.Latency = defaultPaperFillLatency
+	} else if cfg.Latency > maxPaperFillLatency {
+		logger.Warnf("⚠️ [Paper Trading] 成交延迟 %v 超过上限，使用 %v", cfg.Latency, maxPaperFillLatency)
+		cfg.Latency = maxPaperFillLatency
+	}
+
+	t.mu.Lock()
+	t.fill = cfg
+	t.mu.Unlock()
+
+	if cfg.Mode == PaperFillNextCandleOpen {
+		logger.Infof("📝 [Paper Trading] 成交价模式: 下一根%s K线开盘价（实盘按 %v 成交延迟后的实时价格）", cfg.Interval, cfg.Latency)
+	}
+}
+
+// fillBasePrice 订单成交的基准价格（价格冲击在此基础上计算）。
+// next_candle_open 模式需要等待成交延迟，调用方应在加锁前调用
+func (t *PaperTrader) fillBasePrice(symbol string) (float64, error) {
+	t.mu.RLock()
+	cfg, simulated := t.fill, t.clock != nil
+	t.mu.RUnlock()
+	if cfg.Mode != PaperFillNextCandleOpen {
+		return t.getMarketPrice(symbol)
+	}
+	if !simulated {
+		t.sleep(cfg.Latency)
+		return t.getMarketPrice(symbol)
+	}
+	return t.nextCandleOpen(symbol, cfg.Interval)
+}
+
+// nextCandleOpen 模拟时钟下单时刻之后第一根K线的开盘价，多次重试仍取不到时按实时价格成交
+func (t *PaperTrader) nextCandleOpen(symbol, interval string) (float64, error) {
+	step, _ := time.ParseDuration(interval)
+	next := t.now().Truncate(step).Add(step).UnixMilli()
+
+	fetch := t.candleFn
+	if fetch == nil {
+		fetch = market.NewAPIClientFor(t.dataSource).GetKlines
+	}
+	for attempt := 0; ; attempt++ {
+		klines, err := fetch(symbol, interval, 3)
+		if err == nil {
+			for _, k := range klines {
+				if k.OpenTime >= next && k.Open > 0 {
+					return k.Open, nil
+				}
+			}
+		}
+		if attempt >= nextCandleRetries {
+			logger.Warnf("⚠️ [Paper Trading] 未取到 %s 下一根%s K线 (err: %v)，按实时价格成交", symbol, interval, err)
+			return t.getMarketPrice(symbol)
+		}
+		t.sleep(nextCandleRetryDelay)
+	}
+}
+
+// now 当前时间（回测时使用模拟时钟）
+func (t *PaperTrader) now() time.Time {
+	if t.clock != nil {
+		return t.clock()
+	}
+	return time.Now()
+}
+
+// sleep 等待成交延迟或重试（测试中可替换）
+func (t *PaperTrader) sleep(d time.Duration) {
+	if t.sleepFn != nil {
+		t.sleepFn(d)
+		return
+	}
+	time.Sleep(d)
+}
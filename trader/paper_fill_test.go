@@ -0,0 +1,114 @@
+package trader
+
+import (
+	"testing"
+	"time"
+
+	"aspen/market"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newNextCandlePaperTrader 实时价格固定为 100、按模拟时钟和历史K线以下一根3m K线开盘价成交的模拟仓（回测用法）
+func newNextCandlePaperTrader(t *testing.T, clock *time.Time, candles []market.Kline) *PaperTrader {
+	t.Helper()
+	pt, err := NewPaperTrader(10000)
+	require.NoError(t, err)
+	pt.priceFn = func(symbol string) (float64, error) { return 100, nil }
+	pt.SetFillModel(PaperFillConfig{Mode: PaperFillNextCandleOpen})
+	pt.candleFn = func(symbol, interval string, limit int) ([]market.Kline, error) {
+		return candles, nil
+	}
+	pt.clock = func() time.Time { return *clock }
+	return pt
+}
+
+// TestPaperFill_NextCandleOpen 测试启用下一根K线开盘价模式时，开仓和平仓都按下单后下一根K线的开盘价成交而不是实时价格
+func TestPaperFill_NextCandleOpen(t *testing.T) {
+	base := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	clock := base.Add(90 * time.Second) // 12:01:30，下一根3m K线 12:03 开盘
+	pt := newNextCandlePaperTrader(t, &clock, []market.Kline{
+		{OpenTime: base.UnixMilli(), Open: 98},                       // 下单时所在的K线
+		{OpenTime: base.Add(3 * time.Minute).UnixMilli(), Open: 105}, // 开仓成交的K线
+		{OpenTime: base.Add(6 * time.Minute).UnixMilli(), Open: 110}, // 平仓成交的K线
+	})
+
+	order, err := pt.OpenLong("BTCUSDT", 1, 5)
+	require.NoError(t, err)
+	assert.Equal(t, 105.0, order["price"])
+	assert.Equal(t, 105.0, pt.positions["BTCUSDT_LONG"].EntryPrice)
+
+	clock = base.Add(4*time.Minute + 10*time.Second) // 12:04:10，下一根K线 12:06 开盘
+	order, err = pt.CloseLong("BTCUSDT", 0)
+	require.NoError(t, err)
+	assert.Equal(t, 110.0, order["price"])
+	assert.InDelta(t, 5.0, order["pnl"], 1e-9)
+
+	// 恰好在K线开盘时刻下单，按下一根K线成交
+	clock = base.Add(3 * time.Minute)
+	order, err = pt.OpenShort("BTCUSDT", 1, 5)
+	require.NoError(t, err)
+	assert.Equal(t, 110.0, order["price"])
+
+	// 默认模式按实时价格成交
+	pt.SetFillModel(PaperFillConfig{})
+	order, err = pt.CloseShort("BTCUSDT", 0)
+	require.NoError(t, err)
+	assert.Equal(t, 100.0, order["price"])
+}
+
+// TestPaperFill_LiveUsesBoundedLatency 测试实盘模拟仓（无模拟时钟）在 next_candle_open 模式下不等待K线开盘，
+// 只等待有上限的成交延迟后按实时价格成交
+func TestPaperFill_LiveUsesBoundedLatency(t *testing.T) {
+	pt, err := NewPaperTrader(10000)
+	require.NoError(t, err)
+	pt.priceFn = func(symbol string) (float64, error) { return 100, nil }
+	pt.candleFn = func(symbol, interval string, limit int) ([]market.Kline, error) {
+		t.Fatal("实盘模拟仓不应请求下一根K线")
+		return nil, nil
+	}
+	var waits []time.Duration
+	pt.sleepFn = func(d time.Duration) { waits = append(waits, d) }
+
+	pt.SetFillModel(PaperFillConfig{Mode: PaperFillNextCandleOpen, Interval: "3m", Latency: 500 * time.Millisecond})
+	order, err := pt.OpenLong("ETHUSDT", 1, 5)
+	require.NoError(t, err)
+	assert.Equal(t, 100.0, order["price"])
+	assert.Equal(t, []time.Duration{500 * time.Millisecond}, waits)
+
+	// 未设置延迟使用默认值，超过上限时按上限等待
+	waits = nil
+	pt.SetFillModel(PaperFillConfig{Mode: PaperFillNextCandleOpen})
+	_, err = pt.CloseLong("ETHUSDT", 0)
+	require.NoError(t, err)
+	pt.SetFillModel(PaperFillConfig{Mode: PaperFillNextCandleOpen, Latency: 3 * time.Minute})
+	_, err = pt.OpenShort("ETHUSDT", 1, 5)
+	require.NoError(t, err)
+	assert.Equal(t, []time.Duration{defaultPaperFillLatency, maxPaperFillLatency}, waits)
+}
+
+// TestPaperFill_NextCandleMissingFallsBack 测试回测取不到下一根K线时重试后按实时价格成交
+func TestPaperFill_NextCandleMissingFallsBack(t *testing.T) {
+	clock := time.Date(2026, 3, 1, 12, 1, 30, 0, time.UTC)
+	pt := newNextCandlePaperTrader(t, &clock, []market.Kline{{OpenTime: clock.Add(-time.Hour).UnixMilli(), Open: 90}})
+	var waits []time.Duration
+	pt.sleepFn = func(d time.Duration) { waits = append(waits, d) }
+
+	order, err := pt.OpenLong("ETHUSDT", 1, 5)
+	require.NoError(t, err)
+	assert.Equal(t, 100.0, order["price"])
+	assert.Len(t, waits, nextCandleRetries)
+}
+
+// TestNormalizePaperFillMode 测试成交价模式校验
+func TestNormalizePaperFillMode(t *testing.T) {
+	mode, err := NormalizePaperFillMode("")
+	require.NoError(t, err)
+	assert.Equal(t, PaperFillInstant, mode)
+	mode, err = NormalizePaperFillMode(" Next_Candle_Open ")
+	require.NoError(t, err)
+	assert.Equal(t, PaperFillNextCandleOpen, mode)
+	_, err = NormalizePaperFillMode("vwap")
+	assert.Error(t, err)
+}
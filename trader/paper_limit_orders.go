@@ -0,0 +1,330 @@
+package trader
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"aspen/config"
+	"aspen/logger"
+)
+
+// PaperLimitOrder 模拟仓限价开仓挂单：下单时按限价冻结保证金和手续费，价格到达限价时按限价成交，
+// 撤单（手动撤单、CancelAllOrders 或超时清理）时释放冻结的资金
+type PaperLimitOrder struct {
+	ID         string    `json:"id"`
+	Symbol     string    `json:"symbol"`
+	Side       string    `json:"side"`     // LONG / SHORT
+	Quantity   float64   `json:"quantity"` // 开仓数量
+	Price      float64   `json:"price"`    // 限价
+	Leverage   int       `json:"leverage"`
+	StopLoss   float64   `json:"stop_loss,omitempty"`   // 成交后挂到持仓上的止损价（0 表示不设置）
+	TakeProfit float64   `json:"take_profit,omitempty"` // 成交后挂到持仓上的止盈价（0 表示不设置）
+	Note       string    `json:"note,omitempty"`        // 开仓说明（成交后记入持仓元数据）
+	Reserved   float64   `json:"reserved"`              // 冻结的保证金和手续费（挂单时已从空闲余额中扣除）
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// crossed 价格是否到达限价：多单 价格≤限价，空单 价格≥限价
+func (o PaperLimitOrder) crossed(price float64) bool {
+	if o.Side == "LONG" {
+		return price <= o.Price
+	}
+	return price >= o.Price
+}
+
+// limitOrderFunc 限价单成交回调：fill 与市价开仓返回的订单结构相同（orderId/side/quantity/price/margin/fee，
+// 单向持仓模式抵消反向持仓时另有 netted/pnl）
+type limitOrderFunc func(order PaperLimitOrder, fill map[string]interface{})
+
+// paperLimitFill 已成交、尚未通知回调的限价单
+type paperLimitFill struct {
+	order PaperLimitOrder
+	fill  map[string]interface{}
+}
+
+// SetLimitOrderHandler 设置限价单成交回调（释放锁后调用，回调中可以调用模拟仓方法）
+func (t *PaperTrader) SetLimitOrderHandler(fn limitOrderFunc) {
+	t.mu.Lock()
+	t.onLimitFill = fn
+	t.mu.Unlock()
+}
+
+// PlaceLimitOrder 挂限价开仓单：调用方填写 Symbol、Side（LONG/SHORT）、Quantity、Price、Leverage，
+// 可选 StopLoss/TakeProfit/Note。按限价冻结保证金和手续费，返回填好ID、冻结资金和挂单时间的挂单。
+// 限价已优于当前价格（挂单会立即成交）时拒绝，应按市价开仓
+func (t *PaperTrader) PlaceLimitOrder(order PaperLimitOrder) (PaperLimitOrder, error) {
+	order.Side = strings.ToUpper(order.Side)
+	if order.Side != "LONG" && order.Side != "SHORT" {
+		return PaperLimitOrder{}, fmt.Errorf("无效的持仓方向: %s", order.Side)
+	}
+	if order.Quantity <= 0 || order.Price <= 0 {
+		return PaperLimitOrder{}, fmt.Errorf("数量和限价必须大于0")
+	}
+	if order.Leverage <= 0 {
+		return PaperLimitOrder{}, fmt.Errorf("杠杆必须大于0")
+	}
+	currentPrice, err := t.getMarketPrice(order.Symbol)
+	if err != nil {
+		return PaperLimitOrder{}, fmt.Errorf("获取 %s 价格失败: %w", order.Symbol, err)
+	}
+	if order.crossed(currentPrice) {
+		return PaperLimitOrder{}, fmt.Errorf("%s %s 限价 %.4f 已优于当前价格 %.4f，挂单会立即成交，请按市价开仓",
+			order.Symbol, order.Side, order.Price, currentPrice)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	notional := order.Quantity * order.Price
+	margin := notional / float64(order.Leverage)
+	fee := notional * t.feeRate()
+	order.Reserved = margin + fee
+
+	// 与市价开仓相同，可用余额包含全仓持仓的浮动盈亏
+	t.updateUnrealizedPnLLocked()
+	if available := t.computeBalanceLocked().Available; available < order.Reserved {
+		return PaperLimitOrder{}, fmt.Errorf("余额不足，需要冻结 %.2f %s（保证金 %.2f + 手续费 %.2f），当前可用 %.2f %s",
+			order.Reserved, t.asset, margin, fee, available, t.asset)
+	}
+
+	order.CreatedAt = t.now()
+	t.limitOrderSeq++
+	order.ID = fmt.Sprintf("paper_limit_%d_%d", order.CreatedAt.UnixNano(), t.limitOrderSeq)
+	t.limitOrders = append(t.limitOrders, order)
+	t.balance -= order.Reserved
+
+	logger.Infof("📝 [Paper Trading] 挂限价单: %s %s, 数量: %.6f, 限价: %.4f, 杠杆: %dx, 冻结: %.2f %s",
+		order.Symbol, order.Side, order.Quantity, order.Price, order.Leverage, order.Reserved, t.asset)
+	t.SaveState()
+	return order, nil
+}
+
+// CancelLimitOrder 撤销限价挂单并释放冻结的资金
+func (t *PaperTrader) CancelLimitOrder(orderID string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	cancelled := t.cancelLimitOrdersLocked(func(order PaperLimitOrder) bool { return order.ID == orderID })
+	if len(cancelled) == 0 {
+		return fmt.Errorf("限价单不存在: %s", orderID)
+	}
+	t.SaveState()
+	return nil
+}
+
+// CancelStaleLimitOrders 撤销挂单时长达到 maxAge 仍未成交的限价单并释放冻结的资金（maxAge ≤ 0 时不处理），
+// 返回被撤销的挂单
+func (t *PaperTrader) CancelStaleLimitOrders(maxAge time.Duration) []PaperLimitOrder {
+	if maxAge <= 0 {
+		return nil
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.now()
+	cancelled := t.cancelLimitOrdersLocked(func(order PaperLimitOrder) bool {
+		return now.Sub(order.CreatedAt) >= maxAge
+	})
+	if len(cancelled) > 0 {
+		t.SaveState()
+	}
+	return cancelled
+}
+
+// cancelSymbolLimitOrders 撤销该币种的所有限价挂单并释放冻结的资金
+func (t *PaperTrader) cancelSymbolLimitOrders(symbol string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if cancelled := t.cancelLimitOrdersLocked(func(order PaperLimitOrder) bool { return order.Symbol == symbol }); len(cancelled) > 0 {
+		t.SaveState()
+	}
+}
+
+// cancelLimitOrdersLocked 撤销满足条件的挂单并把冻结的资金返还空闲余额（调用方已加锁），返回被撤销的挂单
+func (t *PaperTrader) cancelLimitOrdersLocked(match func(PaperLimitOrder) bool) []PaperLimitOrder {
+	var cancelled []PaperLimitOrder
+	kept := t.limitOrders[:0]
+	for _, order := range t.limitOrders {
+		if !match(order) {
+			kept = append(kept, order)
+			continue
+		}
+		t.balance += order.Reserved
+		cancelled = append(cancelled, order)
+		logger.Infof("📝 [Paper Trading] 撤销限价单: %s %s, 数量: %.6f, 限价: %.4f, 释放: %.2f %s",
+			order.Symbol, order.Side, order.Quantity, order.Price, order.Reserved, t.asset)
+	}
+	t.limitOrders = kept
+	if len(kept) == 0 {
+		t.limitOrders = nil
+	}
+	return cancelled
+}
+
+// LimitOrders 返回该币种未成交的限价挂单（symbol 为空时返回全部）
+func (t *PaperTrader) LimitOrders(symbol string) []PaperLimitOrder {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	var result []PaperLimitOrder
+	for _, order := range t.limitOrders {
+		if symbol == "" || order.Symbol == symbol {
+			result = append(result, order)
+		}
+	}
+	return result
+}
+
+// fillLimitOrdersLocked 按最新价格成交到达限价的挂单，成交结果排队等释放锁后通知回调（调用方已加锁）。
+// 返回是否有挂单成交
+func (t *PaperTrader) fillLimitOrdersLocked() bool {
+	if len(t.limitOrders) == 0 {
+		return false
+	}
+
+	filled := false
+	kept := t.limitOrders[:0]
+	for _, order := range t.limitOrders {
+		price, err := t.getMarketPrice(order.Symbol)
+		if err != nil || price <= 0 || !order.crossed(price) {
+			kept = append(kept, order)
+			continue
+		}
+		fill, ok := t.fillLimitOrderLocked(order)
+		if !ok {
+			kept = append(kept, order)
+			continue
+		}
+		t.limitFills = append(t.limitFills, paperLimitFill{order: order, fill: fill})
+		filled = true
+	}
+	t.limitOrders = kept
+	if len(kept) == 0 {
+		t.limitOrders = nil
+	}
+	return filled
+}
+
+// fillLimitOrderLocked 按限价成交挂单（挂单一直在簿上，跳空穿过限价也按限价成交）：释放冻结的资金，
+// 单向持仓模式先抵消反向持仓，剩余数量按限价扣除保证金和手续费开仓或加仓（加仓不修改持仓杠杆），
+// 并挂上挂单带的止损止盈。冻结后调高了手续费率、冻结的资金不够且可用余额也不足以补足时不成交（调用方已加锁）
+func (t *PaperTrader) fillLimitOrderLocked(order PaperLimitOrder) (map[string]interface{}, bool) {
+	notional := order.Quantity * order.Price
+	if extra := notional/float64(order.Leverage) + notional*t.feeRate() - order.Reserved; extra > 0 {
+		if available := t.computeBalanceLocked().Available; available < extra {
+			logger.Warnf("⚠️ [Paper Trading] 限价单 %s 冻结的资金不足（差额 %.2f %s，可用 %.2f %s），继续挂单",
+				order.ID, extra, t.asset, available, t.asset)
+			return nil, false
+		}
+	}
+
+	opposite := "SHORT"
+	if order.Side == "SHORT" {
+		opposite = "LONG"
+	}
+	quantity := order.Quantity
+	var nettedPnL float64
+	netted := t.nettableQuantityLocked(order.Symbol, opposite, quantity)
+	if netted > 0 {
+		nettedPnL, _ = t.netOppositeLocked(order.Symbol, opposite, netted, order.Price)
+		quantity -= netted
+	}
+
+	t.balance += order.Reserved
+	var margin, fee float64
+	if quantity > 0 {
+		notional = quantity * order.Price
+		margin = notional / float64(order.Leverage)
+		fee = notional * t.feeRate()
+		t.balance -= margin + fee
+
+		key := t.getPositionKey(order.Symbol, order.Side)
+		pos, exists := t.positions[key]
+		if exists && pos.Quantity > 0 {
+			// 加仓：计算新的平均开仓价，杠杆沿用持仓（保证金按挂单杠杆占用）
+			totalQuantity := pos.Quantity + quantity
+			pos.EntryPrice = (pos.Quantity*pos.EntryPrice + quantity*order.Price) / totalQuantity
+			pos.Quantity = totalQuantity
+			pos.Margin += margin
+		} else {
+			pos = &Position{
+				Symbol:     order.Symbol,
+				Side:       order.Side,
+				Quantity:   quantity,
+				EntryPrice: order.Price,
+				Leverage:   order.Leverage,
+				Margin:     margin,
+				Isolated:   t.isolated[order.Symbol],
+			}
+			t.positions[key] = pos
+		}
+		if order.StopLoss > 0 {
+			replaceStopOrder(pos, PaperStopOrder{Type: PaperStopLoss, TriggerPrice: order.StopLoss, Quantity: quantity})
+		}
+		if order.TakeProfit > 0 {
+			replaceStopOrder(pos, PaperStopOrder{Type: PaperTakeProfit, TriggerPrice: order.TakeProfit, Quantity: quantity})
+		}
+	}
+
+	logger.Infof("📝 [Paper Trading] 限价单成交: %s %s, 数量: %.6f, 成交价: %.4f, 保证金: %.2f %s, 手续费: %.2f %s",
+		order.Symbol, order.Side, order.Quantity, order.Price, margin, t.asset, fee, t.asset)
+
+	side := "BUY"
+	if order.Side == "SHORT" {
+		side = "SELL"
+	}
+	fill := map[string]interface{}{
+		"orderId":  order.ID,
+		"symbol":   order.Symbol,
+		"side":     side,
+		"quantity": order.Quantity,
+		"price":    order.Price,
+		"leverage": order.Leverage,
+		"margin":   margin,
+		"fee":      fee,
+		"status":   "FILLED",
+	}
+	if netted > 0 {
+		fill["netted"] = netted
+		fill["pnl"] = nettedPnL
+	}
+	return fill, true
+}
+
+// notifyLimitFills 释放锁后把已成交的限价单通知回调（没有设置回调时丢弃）
+func (t *PaperTrader) notifyLimitFills() {
+	t.mu.Lock()
+	fills, handler := t.limitFills, t.onLimitFill
+	t.limitFills = nil
+	t.mu.Unlock()
+
+	if handler == nil {
+		return
+	}
+	for _, f := range fills {
+		handler(f.order, f.fill)
+	}
+}
+
+// loadPaperLimitOrders 从数据库恢复未成交的限价挂单（冻结的资金已在保存的余额中扣除）
+func loadPaperLimitOrders(db *config.Database, traderID string) []PaperLimitOrder {
+	raw, err := db.LoadPaperLimitOrders(traderID)
+	if err != nil {
+		logger.Warnf("⚠️ [Paper Trading] 加载限价挂单失败: %v", err)
+		return nil
+	}
+	if raw == "" || raw == "[]" {
+		return nil
+	}
+
+	var orders []PaperLimitOrder
+	if err := json.Unmarshal([]byte(raw), &orders); err != nil {
+		logger.Warnf("⚠️ [Paper Trading] 反序列化限价挂单失败: %v", err)
+		return nil
+	}
+	return orders
+}
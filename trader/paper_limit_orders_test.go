@@ -0,0 +1,217 @@
+package trader
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newLimitOrderPaperTrader 价格由 price、时钟由 now 控制的模拟仓
+func newLimitOrderPaperTrader(t *testing.T, price *float64, now *time.Time) *PaperTrader {
+	t.Helper()
+	pt := newStopOrderPaperTrader(t, price)
+	pt.clock = func() time.Time { return *now }
+	return pt
+}
+
+// TestPaperLimitOrders_StaleOrderCancelled 测试挂单超过最长保留时间后被撤销、冻结的资金返还，未超时的挂单保留
+func TestPaperLimitOrders_StaleOrderCancelled(t *testing.T) {
+	price := 100.0
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	pt := newLimitOrderPaperTrader(t, &price, &now)
+
+	stale, err := pt.PlaceLimitOrder(PaperLimitOrder{Symbol: "BTCUSDT", Side: "LONG", Quantity: 10, Price: 90, Leverage: 5})
+	require.NoError(t, err)
+	reserved := 900.0/5 + 900*DefaultPaperFeeRate
+	assert.InDelta(t, reserved, stale.Reserved, 1e-9)
+
+	balance, err := pt.GetBalance()
+	require.NoError(t, err)
+	assert.InDelta(t, 10000-reserved, balance["availableBalance"], 1e-9, "挂单冻结保证金和手续费")
+	assert.InDelta(t, 10000.0, balance["totalWalletBalance"], 1e-9, "冻结的资金仍计入钱包余额")
+
+	now = now.Add(30 * time.Minute)
+	fresh, err := pt.PlaceLimitOrder(PaperLimitOrder{Symbol: "ETHUSDT", Side: "SHORT", Quantity: 1, Price: 110, Leverage: 5})
+	require.NoError(t, err)
+
+	now = now.Add(29 * time.Minute)
+	assert.Empty(t, pt.CancelStaleLimitOrders(time.Hour), "未超过最长保留时间")
+	assert.Empty(t, pt.CancelStaleLimitOrders(0), "0 表示不处理")
+
+	now = now.Add(time.Minute)
+	cancelled := pt.CancelStaleLimitOrders(time.Hour)
+	require.Len(t, cancelled, 1)
+	assert.Equal(t, stale.ID, cancelled[0].ID)
+	assert.Equal(t, []PaperLimitOrder{fresh}, pt.LimitOrders(""))
+
+	balance, err = pt.GetBalance()
+	require.NoError(t, err)
+	assert.InDelta(t, 10000-fresh.Reserved, balance["availableBalance"], 1e-9, "撤单后释放冻结的资金")
+
+	require.NoError(t, pt.CancelLimitOrder(fresh.ID))
+	assert.Error(t, pt.CancelLimitOrder(fresh.ID), "挂单已撤销")
+	assert.InDelta(t, 10000.0, pt.balance, 1e-9, "全部撤单后余额恢复")
+}
+
+// TestPaperLimitOrders_FillAtLimit 测试价格到达限价时按限价开仓，冻结的资金转为保证金和手续费
+func TestPaperLimitOrders_FillAtLimit(t *testing.T) {
+	price := 100.0
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	pt := newLimitOrderPaperTrader(t, &price, &now)
+
+	_, err := pt.PlaceLimitOrder(PaperLimitOrder{Symbol: "BTCUSDT", Side: "LONG", Quantity: 10, Price: 95, Leverage: 5})
+	require.NoError(t, err)
+	_, err = pt.PlaceLimitOrder(PaperLimitOrder{Symbol: "BTCUSDT", Side: "LONG", Quantity: 1000, Price: 95, Leverage: 5})
+	assert.Error(t, err, "余额不足时不能挂单")
+
+	price = 96
+	_, err = pt.GetPositions()
+	require.NoError(t, err)
+	assert.NotContains(t, pt.positions, "BTCUSDT_LONG", "未到达限价不成交")
+
+	price = 95
+	_, err = pt.GetPositions()
+	require.NoError(t, err)
+	pos := pt.positions["BTCUSDT_LONG"]
+	require.NotNil(t, pos)
+	assert.InDelta(t, 95.0, pos.EntryPrice, 1e-9)
+	assert.InDelta(t, 190.0, pos.Margin, 1e-9)
+	assert.Empty(t, pt.LimitOrders("BTCUSDT"))
+	assert.InDelta(t, 10000-190-950*DefaultPaperFeeRate, pt.balance, 1e-9)
+
+	now = now.Add(24 * time.Hour)
+	assert.Empty(t, pt.CancelStaleLimitOrders(time.Hour), "已成交的挂单不再撤销")
+}
+
+// TestPaperLimitOrders_ShortGapFillsAtLimit 测试空单跳空穿过限价时按限价成交，冻结的资金正好覆盖保证金和手续费，
+// 加仓不修改持仓杠杆，止损止盈随成交挂到持仓上，订单ID使用模拟时钟且不重复
+func TestPaperLimitOrders_ShortGapFillsAtLimit(t *testing.T) {
+	price := 100.0
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	pt := newLimitOrderPaperTrader(t, &price, &now)
+
+	_, err := pt.OpenShort("BTCUSDT", 1, 10)
+	require.NoError(t, err)
+	balanceBefore := pt.balance
+	order, err := pt.PlaceLimitOrder(PaperLimitOrder{Symbol: "BTCUSDT", Side: "SHORT", Quantity: 10, Price: 110, Leverage: 5, StopLoss: 130, TakeProfit: 80})
+	require.NoError(t, err)
+	other, err := pt.PlaceLimitOrder(PaperLimitOrder{Symbol: "ETHUSDT", Side: "LONG", Quantity: 1, Price: 90, Leverage: 5})
+	require.NoError(t, err)
+	assert.NotEqual(t, order.ID, other.ID)
+	assert.Contains(t, order.ID, fmt.Sprint(now.UnixNano()))
+	_, err = pt.PlaceLimitOrder(PaperLimitOrder{Symbol: "BTCUSDT", Side: "SHORT", Quantity: 1, Price: 95, Leverage: 5})
+	assert.Error(t, err, "限价已优于当前价格时拒绝挂单")
+
+	price = 125 // 跳空穿过限价（未到止损价）
+	_, err = pt.GetPositions()
+	require.NoError(t, err)
+	pos := pt.positions["BTCUSDT_SHORT"]
+	require.NotNil(t, pos)
+	assert.InDelta(t, 11.0, pos.Quantity, 1e-9)
+	assert.InDelta(t, (100.0+10*110)/11, pos.EntryPrice, 1e-9, "按限价成交")
+	assert.Equal(t, 10, pos.Leverage, "加仓不修改持仓杠杆")
+	assert.InDelta(t, 10+1100.0/5, pos.Margin, 1e-9)
+	assert.InDelta(t, balanceBefore-other.Reserved-1100.0/5-1100*DefaultPaperFeeRate, pt.balance, 1e-9,
+		"只扣除冻结的资金，不会因跳空多扣")
+	assert.Contains(t, pos.StopOrders, PaperStopOrder{Type: PaperTakeProfit, TriggerPrice: 80, Quantity: 10})
+}
+
+// TestPaperLimitOrders_HandlerAndOneWayNetting 测试单向持仓模式下成交先抵消反向持仓，成交回调在释放锁后收到订单结果
+func TestPaperLimitOrders_HandlerAndOneWayNetting(t *testing.T) {
+	price := 100.0
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	pt := newLimitOrderPaperTrader(t, &price, &now)
+	pt.SetPositionMode(PaperPositionOneWay)
+
+	var fills []map[string]interface{}
+	pt.SetLimitOrderHandler(func(order PaperLimitOrder, fill map[string]interface{}) {
+		// 释放锁后调用，可以调用模拟仓方法
+		_ = pt.LimitOrders("")
+		fills = append(fills, fill)
+	})
+
+	_, err := pt.OpenShort("BTCUSDT", 4, 5)
+	require.NoError(t, err)
+	order, err := pt.PlaceLimitOrder(PaperLimitOrder{Symbol: "BTCUSDT", Side: "LONG", Quantity: 10, Price: 95, Leverage: 5})
+	require.NoError(t, err)
+
+	price = 95
+	_, err = pt.GetBalance()
+	require.NoError(t, err)
+	assert.NotContains(t, pt.positions, "BTCUSDT_SHORT", "先抵消空仓")
+	pos := pt.positions["BTCUSDT_LONG"]
+	require.NotNil(t, pos)
+	assert.InDelta(t, 6.0, pos.Quantity, 1e-9)
+	assert.InDelta(t, 20.0, pt.RealizedPnL(), 1e-9)
+
+	require.Len(t, fills, 1)
+	assert.Equal(t, order.ID, fills[0]["orderId"])
+	assert.Equal(t, "BUY", fills[0]["side"])
+	assert.InDelta(t, 4.0, fills[0]["netted"], 1e-9)
+	assert.InDelta(t, 95.0, fills[0]["price"], 1e-9)
+}
+
+// TestPaperLimitOrders_CancelAllOrders 测试取消该币种所有挂单时一起撤销限价单并释放冻结的资金
+func TestPaperLimitOrders_CancelAllOrders(t *testing.T) {
+	price := 100.0
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	pt := newLimitOrderPaperTrader(t, &price, &now)
+
+	_, err := pt.PlaceLimitOrder(PaperLimitOrder{Symbol: "BTCUSDT", Side: "LONG", Quantity: 1, Price: 90, Leverage: 5})
+	require.NoError(t, err)
+	eth, err := pt.PlaceLimitOrder(PaperLimitOrder{Symbol: "ETHUSDT", Side: "LONG", Quantity: 1, Price: 90, Leverage: 5})
+	require.NoError(t, err)
+
+	require.NoError(t, pt.CancelAllOrders("BTCUSDT"))
+	assert.Equal(t, []PaperLimitOrder{eth}, pt.LimitOrders(""))
+	assert.InDelta(t, 10000-eth.Reserved, pt.balance, 1e-9)
+}
+
+// TestPaperLimitOrders_PersistAcrossRestart 测试挂单与冻结后的余额一起保存，重启后超时撤单仍能释放资金
+func TestPaperLimitOrders_PersistAcrossRestart(t *testing.T) {
+	database, _ := createTempDB(t)
+	defer database.Close()
+
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	pt, err := NewPaperTraderWithDB(5000, MarginAssetUSDT, database, "limit-orders")
+	require.NoError(t, err)
+	pt.priceFn = func(symbol string) (float64, error) { return 100, nil }
+	pt.clock = func() time.Time { return now }
+	order, err := pt.PlaceLimitOrder(PaperLimitOrder{Symbol: "SOLUSDT", Side: "SHORT", Quantity: 5, Price: 120, Leverage: 2})
+	require.NoError(t, err)
+
+	restored, err := NewPaperTraderWithDB(5000, MarginAssetUSDT, database, "limit-orders")
+	require.NoError(t, err)
+	restored.clock = func() time.Time { return now.Add(2 * time.Hour) }
+	assert.Equal(t, order.ID, restored.LimitOrders("SOLUSDT")[0].ID)
+	assert.InDelta(t, 5000-order.Reserved, restored.balance, 1e-9)
+
+	require.Len(t, restored.CancelStaleLimitOrders(time.Hour), 1)
+	reloaded, err := NewPaperTraderWithDB(5000, MarginAssetUSDT, database, "limit-orders")
+	require.NoError(t, err)
+	assert.Empty(t, reloaded.LimitOrders(""))
+	assert.InDelta(t, 5000.0, reloaded.balance, 1e-9)
+}
+
+// TestCancelStaleOrders_Job 测试交易周期的超时挂单清理按配置的最长保留时间撤单并记录执行日志
+func TestCancelStaleOrders_Job(t *testing.T) {
+	price := 100.0
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	pt := newLimitOrderPaperTrader(t, &price, &now)
+	_, err := pt.PlaceLimitOrder(PaperLimitOrder{Symbol: "BTCUSDT", Side: "LONG", Quantity: 1, Price: 90, Leverage: 5})
+	require.NoError(t, err)
+
+	at := &AutoTrader{trader: pt}
+	now = now.Add(2 * time.Hour)
+	assert.Empty(t, at.cancelStaleOrders(), "未配置最长保留时间时不处理")
+
+	at.config.StaleOrderMaxAge = time.Hour
+	logs := at.cancelStaleOrders()
+	require.Len(t, logs, 1)
+	assert.Contains(t, logs[0], "BTCUSDT")
+	assert.Empty(t, pt.LimitOrders(""))
+	assert.InDelta(t, 10000.0, pt.balance, 1e-9)
+}
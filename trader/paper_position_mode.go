@@ -0,0 +1,86 @@
+package trader
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"aspen/logger"
+)
+
+// 模拟仓持仓模式
+const (
+	PaperPositionHedge  = "hedge"   // 双向持仓：同一币种可同时持有多仓和空仓（默认）
+	PaperPositionOneWay = "one_way" // 单向持仓：同一币种只有一个方向，反向开仓先抵消现有持仓，剩余数量再开新仓
+)
+
+// NormalizePaperPositionMode 校验持仓模式（空值为 hedge）
+func NormalizePaperPositionMode(mode string) (string, error) {
+	switch mode = strings.ToLower(strings.TrimSpace(mode)); mode {
+	case "":
+		return PaperPositionHedge, nil
+	case PaperPositionHedge, PaperPositionOneWay:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("无效的模拟仓持仓模式: %s（可选 %s、%s）", mode, PaperPositionHedge, PaperPositionOneWay)
+	}
+}
+
+// SetPositionMode 设置模拟仓持仓模式（无效的模式使用 hedge）
+func (t *PaperTrader) SetPositionMode(mode string) {
+	normalized, err := NormalizePaperPositionMode(mode)
+	if err != nil {
+		logger.Warnf("⚠️ [Paper Trading] %v，使用 %s", err, PaperPositionHedge)
+		normalized = PaperPositionHedge
+	}
+
+	t.mu.Lock()
+	t.positionMode = normalized
+	t.mu.Unlock()
+
+	if normalized == PaperPositionOneWay {
+		logger.Infof("📝 [Paper Trading] 持仓模式: 单向持仓（反向开仓先抵消现有持仓）")
+	}
+}
+
+// PositionMode 当前持仓模式
+func (t *PaperTrader) PositionMode() string {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	if t.positionMode == "" {
+		return PaperPositionHedge
+	}
+	return t.positionMode
+}
+
+// nettableQuantityLocked 单向持仓模式下本次开仓可以抵消的反向持仓数量（双向持仓模式为 0）
+func (t *PaperTrader) nettableQuantityLocked(symbol, oppositeSide string, quantity float64) float64 {
+	if t.positionMode != PaperPositionOneWay {
+		return 0
+	}
+	pos, exists := t.positions[t.getPositionKey(symbol, oppositeSide)]
+	if !exists || pos.Quantity <= 0 {
+		return 0
+	}
+	return math.Min(quantity, pos.Quantity)
+}
+
+// netOppositeLocked 按成交价平掉反向持仓的 quantity 数量（结算盈亏、释放保证金），返回已实现盈亏和撤销函数
+// （剩余数量开仓失败时撤销，整单不成交）。调用方需持有写锁
+func (t *PaperTrader) netOppositeLocked(symbol, oppositeSide string, quantity, price float64) (float64, func()) {
+	key := t.getPositionKey(symbol, oppositeSide)
+	pos := t.positions[key]
+	saved, balance, realizedPnL := *pos, t.balance, t.realizedPnL
+
+	pnl := t.settleCloseLocked(key, pos, quantity, price)
+	logger.Infof("📝 [Paper Trading] 单向持仓抵消%s: %s, 数量: %.6f, 开仓价: %.2f, 成交价: %.2f, 盈亏: %.2f %s",
+		oppositeSide, symbol, quantity, saved.EntryPrice, price, pnl, t.asset)
+
+	undo := func() {
+		restored := saved
+		t.positions[key] = &restored
+		t.balance, t.realizedPnL = balance, realizedPnL
+		logger.Infof("📝 [Paper Trading] 开仓失败，撤销 %s %s 的抵消", symbol, oppositeSide)
+	}
+	return pnl, undo
+}
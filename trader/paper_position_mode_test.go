@@ -0,0 +1,151 @@
+package trader
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newPositionModePaperTrader 价格由 *price 决定的模拟仓
+func newPositionModePaperTrader(t *testing.T, mode string, price *float64) *PaperTrader {
+	t.Helper()
+	pt, err := NewPaperTrader(10000)
+	require.NoError(t, err)
+	pt.priceFn = func(symbol string) (float64, error) { return *price, nil }
+	pt.SetPositionMode(mode)
+	return pt
+}
+
+// TestPaperPositionMode_OneWayNetsOpposite 测试单向持仓模式下持有多仓时开空先抵消多仓，超出部分开空仓
+func TestPaperPositionMode_OneWayNetsOpposite(t *testing.T) {
+	price := 100.0
+	pt := newPositionModePaperTrader(t, PaperPositionOneWay, &price)
+	assert.Equal(t, PaperPositionOneWay, pt.PositionMode())
+
+	_, err := pt.OpenLong("BTCUSDT", 2, 5)
+	require.NoError(t, err)
+
+	// 部分抵消：多仓减少，不产生空仓
+	price = 110
+	order, err := pt.OpenShort("BTCUSDT", 0.5, 5)
+	require.NoError(t, err)
+	assert.Equal(t, 0.5, order["netted"])
+	assert.InDelta(t, 5.0, order["pnl"], 1e-9)
+	require.Contains(t, pt.positions, "BTCUSDT_LONG")
+	assert.InDelta(t, 1.5, pt.positions["BTCUSDT_LONG"].Quantity, 1e-9)
+	assert.NotContains(t, pt.positions, "BTCUSDT_SHORT")
+	assert.InDelta(t, 5.0, pt.realizedPnL, 1e-9)
+
+	// 超出多仓数量：多仓平完，剩余数量开空
+	order, err = pt.OpenShort("BTCUSDT", 2, 5)
+	require.NoError(t, err)
+	assert.Equal(t, 2.0, order["quantity"])
+	assert.Equal(t, 1.5, order["netted"])
+	assert.NotContains(t, pt.positions, "BTCUSDT_LONG")
+	require.Contains(t, pt.positions, "BTCUSDT_SHORT")
+	assert.InDelta(t, 0.5, pt.positions["BTCUSDT_SHORT"].Quantity, 1e-9)
+	assert.InDelta(t, 20.0, pt.realizedPnL, 1e-9)
+
+	positions, err := pt.GetPositions()
+	require.NoError(t, err)
+	assert.Len(t, positions, 1)
+}
+
+// TestPaperPositionMode_HedgeKeepsBothSides 测试双向持仓模式（默认）下多仓和空仓同时存在
+func TestPaperPositionMode_HedgeKeepsBothSides(t *testing.T) {
+	price := 100.0
+	pt := newPositionModePaperTrader(t, "", &price)
+	assert.Equal(t, PaperPositionHedge, pt.PositionMode())
+
+	_, err := pt.OpenLong("BTCUSDT", 2, 5)
+	require.NoError(t, err)
+	order, err := pt.OpenShort("BTCUSDT", 1, 5)
+	require.NoError(t, err)
+	assert.NotContains(t, order, "netted")
+
+	assert.InDelta(t, 2.0, pt.positions["BTCUSDT_LONG"].Quantity, 1e-9)
+	assert.InDelta(t, 1.0, pt.positions["BTCUSDT_SHORT"].Quantity, 1e-9)
+	assert.Zero(t, pt.realizedPnL)
+}
+
+func TestNormalizePaperPositionMode(t *testing.T) {
+	mode, err := NormalizePaperPositionMode(" One_Way ")
+	require.NoError(t, err)
+	assert.Equal(t, PaperPositionOneWay, mode)
+
+	mode, err = NormalizePaperPositionMode("")
+	require.NoError(t, err)
+	assert.Equal(t, PaperPositionHedge, mode)
+
+	_, err = NormalizePaperPositionMode("netting")
+	assert.Error(t, err)
+}
+
+// TestPaperPositionMode_OneWayEqualCloseFlattens 测试单向持仓模式下开空数量等于多仓时平成空仓并结算盈亏
+func TestPaperPositionMode_OneWayEqualCloseFlattens(t *testing.T) {
+	price := 100.0
+	pt := newPositionModePaperTrader(t, PaperPositionOneWay, &price)
+
+	_, err := pt.OpenLong("ETHUSDT", 3, 5)
+	require.NoError(t, err)
+	balanceAfterOpen := pt.balance
+
+	price = 90
+	order, err := pt.OpenShort("ETHUSDT", 3, 5)
+	require.NoError(t, err)
+	assert.Equal(t, 3.0, order["netted"])
+	assert.InDelta(t, -30.0, order["pnl"], 1e-9)
+	assert.Empty(t, pt.positions)
+	assert.InDelta(t, -30.0, pt.realizedPnL, 1e-9)
+	// 返还保证金 60 并扣除亏损 30
+	assert.InDelta(t, balanceAfterOpen+60-30, pt.balance, 1e-9)
+}
+
+// TestPaperPositionMode_OneWayReverseEntryPrice 测试单向持仓模式下开空数量大于多仓时剩余空仓按本次成交价开仓，
+// 抵消释放的保证金可用于剩余数量开仓
+func TestPaperPositionMode_OneWayReverseEntryPrice(t *testing.T) {
+	price := 100.0
+	pt, err := NewPaperTrader(1000)
+	require.NoError(t, err)
+	pt.priceFn = func(symbol string) (float64, error) { return price, nil }
+	pt.SetPositionMode(PaperPositionOneWay)
+
+	_, err = pt.OpenLong("BTCUSDT", 40, 5) // 占用保证金 800
+	require.NoError(t, err)
+
+	price = 105
+	order, err := pt.OpenShort("BTCUSDT", 50, 5)
+	require.NoError(t, err, "抵消多仓释放的保证金应可用于剩余数量开仓")
+	assert.Equal(t, 40.0, order["netted"])
+	assert.NotContains(t, pt.positions, "BTCUSDT_LONG")
+	short := pt.positions["BTCUSDT_SHORT"]
+	require.NotNil(t, short)
+	assert.InDelta(t, 10.0, short.Quantity, 1e-9)
+	assert.Equal(t, 105.0, short.EntryPrice)
+	assert.InDelta(t, 210.0, short.Margin, 1e-9)
+	assert.InDelta(t, 200.0, pt.realizedPnL, 1e-9)
+}
+
+// TestPaperPositionMode_OneWayInsufficientResidualUndoesNetting 测试剩余数量保证金不足时整单不成交，原持仓不变
+func TestPaperPositionMode_OneWayInsufficientResidualUndoesNetting(t *testing.T) {
+	price := 100.0
+	pt, err := NewPaperTrader(1000)
+	require.NoError(t, err)
+	pt.priceFn = func(symbol string) (float64, error) { return price, nil }
+	pt.SetPositionMode(PaperPositionOneWay)
+
+	_, err = pt.OpenLong("BTCUSDT", 40, 5)
+	require.NoError(t, err)
+	balance := pt.balance
+	long := *pt.positions["BTCUSDT_LONG"]
+
+	_, err = pt.OpenShort("BTCUSDT", 200, 5)
+	require.Error(t, err)
+	require.Contains(t, pt.positions, "BTCUSDT_LONG")
+	assert.Equal(t, long.Quantity, pt.positions["BTCUSDT_LONG"].Quantity)
+	assert.Equal(t, long.Margin, pt.positions["BTCUSDT_LONG"].Margin)
+	assert.NotContains(t, pt.positions, "BTCUSDT_SHORT")
+	assert.Equal(t, balance, pt.balance)
+	assert.Zero(t, pt.realizedPnL)
+}
@@ -0,0 +1,105 @@
+package trader
+
+import (
+	"fmt"
+	"math"
+
+	"aspen/logger"
+	"aspen/market"
+)
+
+// PriceImpactConfig 模拟仓大单价格冲击模型（默认关闭）
+// 成交价按 订单名义价值 / 近期成交额 线性恶化：冲击比例 = Coefficient × 名义价值 / 近期成交额，最多 MaxImpactPct%
+type PriceImpactConfig struct {
+	Enabled      bool
+	Coefficient  float64 // 冲击系数（订单等于近期全部成交额时的价格冲击比例）
+	MaxImpactPct float64 // 单笔订单最大价格冲击百分比
+	VolumeWindow int     // 计算近期成交额使用的3分钟K线数量
+}
+
+// DefaultPriceImpactConfig 默认价格冲击参数（未启用）
+func DefaultPriceImpactConfig() PriceImpactConfig {
+	return PriceImpactConfig{
+		Coefficient:  0.1,
+		MaxImpactPct: 2,
+		VolumeWindow: 20, // 约1小时
+	}
+}
+
+// quoteVolumeFunc 获取近期成交额（USDT）
+type quoteVolumeFunc func(symbol string, window int) (float64, error)
+
+// recentQuoteVolume 近期成交额：最近 window 根3分钟K线的成交额（USDT）之和
+func (t *PaperTrader) recentQuoteVolume(symbol string, window int) (float64, error) {
+	var klines []market.Kline
+	var err error
+	if market.WSMonitorCli != nil && t.usesGlobalDataSource() {
+		klines, err = market.WSMonitorCli.GetCurrentKlines(symbol, "3m")
+	} else {
+		klines, err = market.NewAPIClientFor(t.dataSource).GetKlines(symbol, "3m", window)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("获取 %s 成交额失败: %w", symbol, err)
+	}
+
+	if len(klines) > window {
+		klines = klines[len(klines)-window:]
+	}
+	total := 0.0
+	for _, k := range klines {
+		total += k.QuoteVolume
+	}
+	return total, nil
+}
+
+// SetPriceImpact 设置大单价格冲击模型
+func (t *PaperTrader) SetPriceImpact(cfg PriceImpactConfig) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	defaults := DefaultPriceImpactConfig()
+	if cfg.Coefficient <= 0 {
+		cfg.Coefficient = defaults.Coefficient
+	}
+	if cfg.MaxImpactPct <= 0 {
+		cfg.MaxImpactPct = defaults.MaxImpactPct
+	}
+	if cfg.VolumeWindow <= 0 {
+		cfg.VolumeWindow = defaults.VolumeWindow
+	}
+	t.priceImpact = cfg
+
+	if cfg.Enabled {
+		logger.Infof("📝 [Paper Trading] 已启用大单价格冲击模型 (系数: %.3f, 上限: %.2f%%, 成交额窗口: %d根3m K线)",
+			cfg.Coefficient, cfg.MaxImpactPct, cfg.VolumeWindow)
+	}
+}
+
+// applyPriceImpact 按价格冲击模型计算成交价：买入（开多/平空）价格上移，卖出（开空/平多）价格下移
+// 未启用或无法获取成交额时按原价成交
+func (t *PaperTrader) applyPriceImpact(symbol string, quantity, price float64, isBuy bool) float64 {
+	cfg := t.priceImpact
+	if !cfg.Enabled || quantity <= 0 || price <= 0 {
+		return price
+	}
+
+	volumeFn := t.volumeFn
+	if volumeFn == nil {
+		volumeFn = t.recentQuoteVolume
+	}
+	volume, err := volumeFn(symbol, cfg.VolumeWindow)
+	if err != nil {
+		logger.Warnf("⚠️ [Paper Trading] 无法获取 %s 近期成交额，忽略价格冲击: %v", symbol, err)
+		return price
+	}
+	if volume <= 0 {
+		logger.Warnf("⚠️ [Paper Trading] %s 近期成交额为0，忽略价格冲击", symbol)
+		return price
+	}
+
+	impact := math.Min(cfg.Coefficient*quantity*price/volume, cfg.MaxImpactPct/100)
+	if isBuy {
+		return price * (1 + impact)
+	}
+	return price * (1 - impact)
+}
@@ -0,0 +1,189 @@
+package trader
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// 模拟仓真实度档位：一次性设置手续费、滑点、成交延迟和价格冲击
+const (
+	PaperRealismOptimistic  = "optimistic"  // 乐观：挂单手续费，无滑点，实时价格成交
+	PaperRealismRealistic   = "realistic"   // 贴近实盘：吃单手续费，少量滑点，短暂成交延迟（回测为下一根K线开盘价），启用价格冲击
+	PaperRealismPessimistic = "pessimistic" // 悲观：较高手续费和滑点，较长成交延迟（回测为下一根K线开盘价），加倍价格冲击
+)
+
+// DefaultPaperFeeRate 模拟仓默认开仓手续费率（0.04%，与交易所吃单费率一致）
+const DefaultPaperFeeRate = 0.0004
+
+// maxPaperSlippagePct 滑点覆盖值上限（%）
+const maxPaperSlippagePct = 5
+
+// PaperCostConfig 模拟仓交易成本
+type PaperCostConfig struct {
+	FeeRate     float64 // 开仓手续费率（按名义价值，0.0004 = 0.04%，0 使用默认费率）
+	SlippagePct float64 // 固定滑点（%）：买入成交价上移、卖出下移，在价格冲击之后叠加
+}
+
+// PaperRealism 模拟仓真实度配置（档位展开后的完整参数）
+type PaperRealism struct {
+	Costs       PaperCostConfig
+	Fill        PaperFillConfig
+	PriceImpact PriceImpactConfig
+}
+
+// PaperRealismProfile 档位对应的参数：
+//   - optimistic:  手续费 0.02%，无滑点，实时价格成交，不计价格冲击
+//   - realistic:   手续费 0.05%，滑点 0.05%，实盘成交延迟 0.5 秒（回测按下一根3分钟K线开盘价），默认价格冲击
+//   - pessimistic: 手续费 0.075%，滑点 0.15%，实盘成交延迟 2 秒（回测按下一根3分钟K线开盘价），价格冲击系数 0.2（上限 5%）
+func PaperRealismProfile(name string) (PaperRealism, error) {
+	impact := DefaultPriceImpactConfig()
+
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case PaperRealismOptimistic:
+		return PaperRealism{
+			Costs:       PaperCostConfig{FeeRate: 0.0002},
+			Fill:        DefaultPaperFillConfig(),
+			PriceImpact: impact,
+		}, nil
+	case PaperRealismRealistic:
+		impact.Enabled = true
+		return PaperRealism{
+			Costs:       PaperCostConfig{FeeRate: 0.0005, SlippagePct: 0.05},
+			Fill:        PaperFillConfig{Mode: PaperFillNextCandleOpen, Interval: "3m", Latency: 500 * time.Millisecond},
+			PriceImpact: impact,
+		}, nil
+	case PaperRealismPessimistic:
+		impact.Enabled = true
+		impact.Coefficient = 0.2
+		impact.MaxImpactPct = 5
+		return PaperRealism{
+			Costs:       PaperCostConfig{FeeRate: 0.00075, SlippagePct: 0.15},
+			Fill:        PaperFillConfig{Mode: PaperFillNextCandleOpen, Interval: "3m", Latency: 2 * time.Second},
+			PriceImpact: impact,
+		}, nil
+	default:
+		return PaperRealism{}, fmt.Errorf("无效的模拟仓真实度档位: %s（可选 %s、%s、%s）",
+			name, PaperRealismOptimistic, PaperRealismRealistic, PaperRealismPessimistic)
+	}
+}
+
+// NormalizePaperRealism 校验真实度档位（空值表示不使用档位，按系统配置的成交模型和默认手续费）
+func NormalizePaperRealism(name string) (string, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" {
+		return "", nil
+	}
+	if _, err := PaperRealismProfile(name); err != nil {
+		return "", err
+	}
+	return name, nil
+}
+
+// PaperRealismOverrides 单项覆盖（未设置的项使用档位或系统配置的值）
+type PaperRealismOverrides struct {
+	FeePct      *float64 `json:"fee_pct,omitempty"`      // 开仓手续费（%），必须大于0
+	SlippagePct *float64 `json:"slippage_pct,omitempty"` // 固定滑点（%），0 表示无滑点
+	FillMode    *string  `json:"fill_mode,omitempty"`    // 成交价模式（instant/next_candle_open）
+}
+
+// Validate 校验覆盖值
+func (o PaperRealismOverrides) Validate() error {
+	if o.FeePct != nil && (*o.FeePct <= 0 || *o.FeePct > 1) {
+		return fmt.Errorf("模拟仓手续费必须在 0-1%% 之间: %.4f", *o.FeePct)
+	}
+	if o.SlippagePct != nil && (*o.SlippagePct < 0 || *o.SlippagePct > maxPaperSlippagePct) {
+		return fmt.Errorf("模拟仓滑点必须在 0-%d%% 之间: %.4f", maxPaperSlippagePct, *o.SlippagePct)
+	}
+	if o.FillMode != nil {
+		if _, err := NormalizePaperFillMode(*o.FillMode); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ParsePaperRealismOverrides 解析交易员的单项覆盖（JSON格式，空字符串表示不覆盖）
+func ParsePaperRealismOverrides(raw string) (PaperRealismOverrides, error) {
+	var overrides PaperRealismOverrides
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return overrides, nil
+	}
+	if err := json.Unmarshal([]byte(raw), &overrides); err != nil {
+		return PaperRealismOverrides{}, fmt.Errorf("模拟仓真实度覆盖配置格式错误: %w", err)
+	}
+	if err := overrides.Validate(); err != nil {
+		return PaperRealismOverrides{}, err
+	}
+	return overrides, nil
+}
+
+// Encode 序列化为数据库存储格式（没有覆盖项时返回空字符串）
+func (o PaperRealismOverrides) Encode() string {
+	if o.FeePct == nil && o.SlippagePct == nil && o.FillMode == nil {
+		return ""
+	}
+	raw, _ := json.Marshal(o)
+	return string(raw)
+}
+
+// ResolvePaperRealism 计算交易员的模拟仓真实度：设置了档位时使用档位参数，否则使用 base（系统配置），
+// 再叠加单项覆盖（覆盖优先）
+func ResolvePaperRealism(profile string, overrides PaperRealismOverrides, base PaperRealism) (PaperRealism, error) {
+	realism := base
+	if profile = strings.TrimSpace(profile); profile != "" {
+		var err error
+		if realism, err = PaperRealismProfile(profile); err != nil {
+			return base, err
+		}
+	}
+	if err := overrides.Validate(); err != nil {
+		return realism, err
+	}
+
+	if overrides.FeePct != nil {
+		realism.Costs.FeeRate = *overrides.FeePct / 100
+	}
+	if overrides.SlippagePct != nil {
+		realism.Costs.SlippagePct = *overrides.SlippagePct
+	}
+	if overrides.FillMode != nil {
+		realism.Fill.Mode, _ = NormalizePaperFillMode(*overrides.FillMode)
+	}
+	return realism, nil
+}
+
+// SetCosts 设置模拟仓手续费率和固定滑点（手续费率不大于0时使用默认费率，滑点为负时视为0）
+func (t *PaperTrader) SetCosts(cfg PaperCostConfig) {
+	if cfg.FeeRate <= 0 {
+		cfg.FeeRate = DefaultPaperFeeRate
+	}
+	if cfg.SlippagePct < 0 {
+		cfg.SlippagePct = 0
+	}
+
+	t.mu.Lock()
+	t.costs = cfg
+	t.mu.Unlock()
+}
+
+// feeRate 开仓手续费率（调用方需持有锁）
+func (t *PaperTrader) feeRate() float64 {
+	if t.costs.FeeRate <= 0 {
+		return DefaultPaperFeeRate
+	}
+	return t.costs.FeeRate
+}
+
+// applySlippage 按固定滑点调整成交价：买入（开多/平空）上移，卖出（开空/平多）下移（调用方需持有锁）
+func (t *PaperTrader) applySlippage(price float64, isBuy bool) float64 {
+	if t.costs.SlippagePct <= 0 {
+		return price
+	}
+	if isBuy {
+		return price * (1 + t.costs.SlippagePct/100)
+	}
+	return price * (1 - t.costs.SlippagePct/100)
+}
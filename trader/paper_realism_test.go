@@ -0,0 +1,99 @@
+package trader
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestResolvePaperRealism_RealisticProfile 测试选择 realistic 档位时使用文档中的手续费、滑点、成交模式和价格冲击
+func TestResolvePaperRealism_RealisticProfile(t *testing.T) {
+	base := PaperRealism{Fill: DefaultPaperFillConfig(), PriceImpact: DefaultPriceImpactConfig()}
+
+	realism, err := ResolvePaperRealism("Realistic", PaperRealismOverrides{}, base)
+	require.NoError(t, err)
+	assert.Equal(t, 0.0005, realism.Costs.FeeRate, "手续费 0.05%")
+	assert.Equal(t, 0.05, realism.Costs.SlippagePct, "滑点 0.05%")
+	assert.Equal(t, PaperFillNextCandleOpen, realism.Fill.Mode)
+	assert.Equal(t, "3m", realism.Fill.Interval)
+	assert.Equal(t, 500*time.Millisecond, realism.Fill.Latency, "实盘模拟仓只等待短暂的成交延迟")
+	assert.True(t, realism.PriceImpact.Enabled)
+
+	// 未设置档位时使用系统配置
+	realism, err = ResolvePaperRealism("", PaperRealismOverrides{}, base)
+	require.NoError(t, err)
+	assert.Equal(t, base, realism)
+
+	_, err = ResolvePaperRealism("reckless", PaperRealismOverrides{}, base)
+	assert.Error(t, err)
+}
+
+// TestResolvePaperRealism_OverrideWins 测试单项覆盖优先于档位，未覆盖的项保持档位的值
+func TestResolvePaperRealism_OverrideWins(t *testing.T) {
+	fee, slippage, mode := 0.02, 0.0, "instant"
+	realism, err := ResolvePaperRealism(PaperRealismPessimistic, PaperRealismOverrides{FeePct: &fee, SlippagePct: &slippage, FillMode: &mode}, PaperRealism{})
+	require.NoError(t, err)
+	assert.Equal(t, 0.0002, realism.Costs.FeeRate)
+	assert.Zero(t, realism.Costs.SlippagePct, "显式设置 0 滑点也优先于档位")
+	assert.Equal(t, PaperFillInstant, realism.Fill.Mode)
+	assert.True(t, realism.PriceImpact.Enabled, "未覆盖的价格冲击使用档位的值")
+	assert.Equal(t, 5.0, realism.PriceImpact.MaxImpactPct)
+
+	// 只覆盖手续费时滑点使用档位的值
+	realism, err = ResolvePaperRealism(PaperRealismRealistic, PaperRealismOverrides{FeePct: &fee}, PaperRealism{})
+	require.NoError(t, err)
+	assert.Equal(t, 0.0002, realism.Costs.FeeRate)
+	assert.Equal(t, 0.05, realism.Costs.SlippagePct)
+
+	negative := -0.1
+	_, err = ResolvePaperRealism(PaperRealismRealistic, PaperRealismOverrides{SlippagePct: &negative}, PaperRealism{})
+	assert.Error(t, err)
+}
+
+// TestParsePaperRealismOverrides 测试单项覆盖的解析和序列化
+func TestParsePaperRealismOverrides(t *testing.T) {
+	overrides, err := ParsePaperRealismOverrides(`{"fee_pct":0.03,"slippage_pct":0}`)
+	require.NoError(t, err)
+	require.NotNil(t, overrides.FeePct)
+	require.NotNil(t, overrides.SlippagePct)
+	assert.Nil(t, overrides.FillMode)
+	assert.JSONEq(t, `{"fee_pct":0.03,"slippage_pct":0}`, overrides.Encode())
+
+	overrides, err = ParsePaperRealismOverrides("")
+	require.NoError(t, err)
+	assert.Empty(t, overrides.Encode())
+
+	for _, raw := range []string{`{"fee_pct":0}`, `{"slippage_pct":10}`, `{"fill_mode":"vwap"}`, `not json`} {
+		_, err := ParsePaperRealismOverrides(raw)
+		assert.Error(t, err, raw)
+	}
+}
+
+// TestPaperTrader_SetCosts 测试模拟仓按设置的手续费率扣费，并按固定滑点调整成交价
+func TestPaperTrader_SetCosts(t *testing.T) {
+	pt, err := NewPaperTrader(10000)
+	require.NoError(t, err)
+	pt.priceFn = func(symbol string) (float64, error) { return 100, nil }
+
+	realism, err := PaperRealismProfile(PaperRealismRealistic)
+	require.NoError(t, err)
+	pt.SetCosts(realism.Costs)
+
+	order, err := pt.OpenLong("BTCUSDT", 10, 5)
+	require.NoError(t, err)
+	assert.InDelta(t, 100.05, order["price"], 1e-9, "买入成交价上移 0.05%")
+	assert.InDelta(t, 1000.5*0.0005, order["fee"], 1e-9)
+
+	order, err = pt.CloseLong("BTCUSDT", 0)
+	require.NoError(t, err)
+	assert.InDelta(t, 99.95, order["price"], 1e-9, "卖出成交价下移 0.05%")
+
+	// 零值使用默认费率、无滑点
+	pt.SetCosts(PaperCostConfig{})
+	order, err = pt.OpenShort("BTCUSDT", 10, 5)
+	require.NoError(t, err)
+	assert.Equal(t, 100.0, order["price"])
+	assert.InDelta(t, 1000*DefaultPaperFeeRate, order["fee"], 1e-9)
+}
@@ -0,0 +1,20 @@
+package trader
+
+import "time"
+
+// SetReplayMode 将模拟仓切换为回放模式（回测使用）：按 priceFn 给出的回放K线收盘价成交和计算盈亏，不请求实时行情；
+// clock 为回放的模拟时钟。回放模式下按该价格即时成交（不使用下一根K线开盘价模型）
+func (t *PaperTrader) SetReplayMode(priceFn func(symbol string) (float64, error), clock func() time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.priceFn = priceFn
+	t.clock = clock
+	t.fill = DefaultPaperFillConfig()
+}
+
+// RealizedPnL 已实现盈亏（含强平亏损，不含手续费）
+func (t *PaperTrader) RealizedPnL() float64 {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.realizedPnL
+}
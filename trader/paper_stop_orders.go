@@ -0,0 +1,207 @@
+package trader
+
+import (
+	"fmt"
+	"strings"
+
+	"aspen/logger"
+)
+
+// 模拟仓条件单类型
+const (
+	PaperStopLoss   = "stop_loss"
+	PaperTakeProfit = "take_profit"
+)
+
+// PaperStopOrder 模拟仓止损/止盈单：价格穿过触发价时按触发价平仓（叠加设置的固定滑点）
+type PaperStopOrder struct {
+	Type         string  `json:"type"`          // stop_loss / take_profit
+	TriggerPrice float64 `json:"trigger_price"` // 触发价
+	Quantity     float64 `json:"quantity"`      // 触发时的平仓数量（0 或超过持仓数量时全部平仓）
+}
+
+// triggered 按最新价格判断是否触发：多仓止损 价格≤触发价、止盈 价格≥触发价；空仓相反
+func (o PaperStopOrder) triggered(side string, price float64) bool {
+	below := price <= o.TriggerPrice
+	if (side == "LONG") == (o.Type == PaperStopLoss) {
+		return below
+	}
+	return price >= o.TriggerPrice
+}
+
+// pnlAt 按给定价格计算持仓的未实现盈亏：多仓 (价格 - 开仓价) × 数量，空仓 (开仓价 - 价格) × 数量
+func (p *Position) pnlAt(price float64) float64 {
+	if p.Side == "LONG" {
+		return (price - p.EntryPrice) * p.Quantity
+	}
+	return (p.EntryPrice - price) * p.Quantity
+}
+
+// SetStopLoss 设置止损单（替换该持仓已有的止损单）
+func (t *PaperTrader) SetStopLoss(symbol string, positionSide string, quantity, stopPrice float64) error {
+	return t.setStopOrder(symbol, positionSide, PaperStopOrder{Type: PaperStopLoss, TriggerPrice: stopPrice, Quantity: quantity})
+}
+
+// SetTakeProfit 设置止盈单（替换该持仓已有的止盈单）
+func (t *PaperTrader) SetTakeProfit(symbol string, positionSide string, quantity, takeProfitPrice float64) error {
+	return t.setStopOrder(symbol, positionSide, PaperStopOrder{Type: PaperTakeProfit, TriggerPrice: takeProfitPrice, Quantity: quantity})
+}
+
+// setStopOrder 在持仓上挂条件单（同类型的旧单被替换）
+func (t *PaperTrader) setStopOrder(symbol, positionSide string, order PaperStopOrder) error {
+	if order.TriggerPrice <= 0 {
+		return fmt.Errorf("%s 触发价必须大于0: %.8f", order.Type, order.TriggerPrice)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	side := strings.ToUpper(positionSide)
+	pos, exists := t.positions[t.getPositionKey(symbol, side)]
+	if !exists || pos.Quantity <= 0 {
+		return fmt.Errorf("没有 %s %s 持仓，无法设置%s", symbol, side, paperStopOrderName(order.Type))
+	}
+
+	replaceStopOrder(pos, order)
+
+	logger.Infof("📝 [Paper Trading] 设置%s: %s %s, 触发价: %.4f, 数量: %.6f",
+		paperStopOrderName(order.Type), symbol, side, order.TriggerPrice, order.Quantity)
+	t.SaveState()
+	return nil
+}
+
+// replaceStopOrder 在持仓上挂条件单，替换同类型的旧单（调用方已加锁）
+func replaceStopOrder(pos *Position, order PaperStopOrder) {
+	orders := pos.StopOrders[:0]
+	for _, existing := range pos.StopOrders {
+		if existing.Type != order.Type {
+			orders = append(orders, existing)
+		}
+	}
+	pos.StopOrders = append(orders, order)
+}
+
+// CancelStopLossOrders 取消该币种（多空两个方向）的止损单
+func (t *PaperTrader) CancelStopLossOrders(symbol string) error {
+	t.cancelStopOrders(symbol, PaperStopLoss)
+	return nil
+}
+
+// CancelTakeProfitOrders 取消该币种（多空两个方向）的止盈单
+func (t *PaperTrader) CancelTakeProfitOrders(symbol string) error {
+	t.cancelStopOrders(symbol, PaperTakeProfit)
+	return nil
+}
+
+// CancelAllOrders 取消该币种的所有挂单（止盈/止损单和限价挂单，限价单冻结的资金同时释放）
+func (t *PaperTrader) CancelAllOrders(symbol string) error {
+	t.cancelStopOrders(symbol, "")
+	t.cancelSymbolLimitOrders(symbol)
+	return nil
+}
+
+// CancelStopOrders 取消该币种的止盈/止损单
+func (t *PaperTrader) CancelStopOrders(symbol string) error {
+	t.cancelStopOrders(symbol, "")
+	return nil
+}
+
+// cancelStopOrders 删除该币种指定类型的条件单（orderType 为空时删除全部）
+func (t *PaperTrader) cancelStopOrders(symbol, orderType string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	removed := 0
+	for _, pos := range t.positions {
+		if pos.Symbol != symbol {
+			continue
+		}
+		kept := pos.StopOrders[:0]
+		for _, order := range pos.StopOrders {
+			if orderType != "" && order.Type != orderType {
+				kept = append(kept, order)
+				continue
+			}
+			removed++
+		}
+		pos.StopOrders = kept
+		if len(kept) == 0 {
+			pos.StopOrders = nil
+		}
+	}
+	if removed > 0 {
+		t.SaveState()
+	}
+}
+
+// StopOrders 返回该币种各方向持仓上的条件单（positionSide -> 条件单）
+func (t *PaperTrader) StopOrders(symbol string) map[string][]PaperStopOrder {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	result := make(map[string][]PaperStopOrder)
+	for _, pos := range t.positions {
+		if pos.Symbol == symbol && len(pos.StopOrders) > 0 {
+			result[pos.Side] = append([]PaperStopOrder(nil), pos.StopOrders...)
+		}
+	}
+	return result
+}
+
+// stopOrderFunc 条件单触发回调：pos 为本次平掉的部分（数量为平仓数量），price 为成交价
+type stopOrderFunc func(order PaperStopOrder, pos Position, price, pnl float64)
+
+// SetStopOrderHandler 设置条件单触发回调（在持有锁时调用，回调不能阻塞或调用模拟仓方法）
+func (t *PaperTrader) SetStopOrderHandler(fn stopOrderFunc) {
+	t.mu.Lock()
+	t.onStopOrder = fn
+	t.mu.Unlock()
+}
+
+// triggerStopOrdersLocked 按各持仓最近一次取得的价格触发条件单：按触发价平仓、计入已实现盈亏并删除该单，
+// 持仓全部平完时其余条件单一起删除（调用方已加锁）。返回是否有条件单触发
+func (t *PaperTrader) triggerStopOrdersLocked() bool {
+	triggered := false
+	for key, pos := range t.positions {
+		if pos.markPrice <= 0 || len(pos.StopOrders) == 0 {
+			continue
+		}
+		for i := 0; i < len(pos.StopOrders); i++ {
+			order := pos.StopOrders[i]
+			if !order.triggered(pos.Side, pos.markPrice) {
+				continue
+			}
+			pos.StopOrders = append(pos.StopOrders[:i:i], pos.StopOrders[i+1:]...)
+			i--
+
+			closeQuantity := order.Quantity
+			if closeQuantity <= 0 || closeQuantity > pos.Quantity {
+				closeQuantity = pos.Quantity
+			}
+			price := t.applySlippage(order.TriggerPrice, pos.Side == "SHORT") // 平空为买入
+			closed := *pos
+			closed.Quantity = closeQuantity
+			pnl := t.settleCloseLocked(key, pos, closeQuantity, price)
+			pos.UnrealizedPnL = pos.pnlAt(pos.markPrice)
+			triggered = true
+
+			logger.Infof("📝 [Paper Trading] %s触发: %s %s, 数量: %.6f, 开仓价: %.2f, 触发价: %.2f, 成交价: %.2f, 盈亏: %.2f %s",
+				paperStopOrderName(order.Type), pos.Symbol, pos.Side, closeQuantity, pos.EntryPrice, order.TriggerPrice, price, pnl, t.asset)
+			if t.onStopOrder != nil {
+				t.onStopOrder(order, closed, price, pnl)
+			}
+			if pos.Quantity <= 0 {
+				break
+			}
+		}
+	}
+	return triggered
+}
+
+// paperStopOrderName 条件单类型的中文名称
+func paperStopOrderName(orderType string) string {
+	if orderType == PaperTakeProfit {
+		return "止盈单"
+	}
+	return "止损单"
+}
@@ -0,0 +1,145 @@
+package trader
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newStopOrderPaperTrader 价格由 price 控制的模拟仓
+func newStopOrderPaperTrader(t *testing.T, price *float64) *PaperTrader {
+	t.Helper()
+	pt, err := NewPaperTrader(10000)
+	require.NoError(t, err)
+	pt.priceFn = func(symbol string) (float64, error) { return *price, nil }
+	return pt
+}
+
+// TestPaperStopOrders_StopLossTriggers 测试价格跌破止损价时按止损价平多仓、计入已实现盈亏，同一持仓的止盈单一起删除
+func TestPaperStopOrders_StopLossTriggers(t *testing.T) {
+	price := 100.0
+	pt := newStopOrderPaperTrader(t, &price)
+	var fired []PaperStopOrder
+	pt.SetStopOrderHandler(func(order PaperStopOrder, pos Position, fillPrice, pnl float64) {
+		fired = append(fired, order)
+	})
+
+	_, err := pt.OpenLong("BTCUSDT", 10, 5)
+	require.NoError(t, err)
+	require.NoError(t, pt.SetStopLoss("BTCUSDT", "LONG", 10, 95))
+	require.NoError(t, pt.SetTakeProfit("BTCUSDT", "LONG", 10, 120))
+
+	price = 96
+	_, err = pt.GetBalance()
+	require.NoError(t, err)
+	assert.Contains(t, pt.positions, "BTCUSDT_LONG", "未跌破止损价不触发")
+
+	price = 90 // 跳空跌破止损价，按止损价成交
+	balance, err := pt.GetBalance()
+	require.NoError(t, err)
+	assert.NotContains(t, pt.positions, "BTCUSDT_LONG")
+	assert.InDelta(t, -50.0, pt.RealizedPnL(), 1e-9)
+	assert.InDelta(t, 10000-50-1000*DefaultPaperFeeRate, balance["totalWalletBalance"], 1e-9)
+	require.Len(t, fired, 1)
+	assert.Equal(t, PaperStopLoss, fired[0].Type)
+	assert.Empty(t, pt.StopOrders("BTCUSDT"))
+
+	// 新开的持仓不继承已平仓位的条件单
+	_, err = pt.OpenLong("BTCUSDT", 1, 5)
+	require.NoError(t, err)
+	price = 200
+	_, err = pt.GetPositions()
+	require.NoError(t, err)
+	assert.Contains(t, pt.positions, "BTCUSDT_LONG")
+}
+
+// TestPaperStopOrders_PartialTakeProfit 测试空仓止盈单按设置数量部分平仓，剩余持仓保留止损单
+func TestPaperStopOrders_PartialTakeProfit(t *testing.T) {
+	price := 100.0
+	pt := newStopOrderPaperTrader(t, &price)
+
+	_, err := pt.OpenShort("ETHUSDT", 10, 5)
+	require.NoError(t, err)
+	require.NoError(t, pt.SetStopLoss("ETHUSDT", "SHORT", 10, 110))
+	require.NoError(t, pt.SetTakeProfit("ETHUSDT", "SHORT", 4, 90))
+
+	price = 89
+	_, err = pt.GetPositions()
+	require.NoError(t, err)
+	pos := pt.positions["ETHUSDT_SHORT"]
+	require.NotNil(t, pos)
+	assert.InDelta(t, 6.0, pos.Quantity, 1e-9)
+	assert.InDelta(t, 40.0, pt.RealizedPnL(), 1e-9, "按止盈价 90 平掉 4 个")
+	assert.InDelta(t, 66.0, pos.UnrealizedPnL, 1e-9, "剩余持仓按新数量计算浮盈")
+	assert.Equal(t, map[string][]PaperStopOrder{"SHORT": {{Type: PaperStopLoss, TriggerPrice: 110, Quantity: 10}}}, pt.StopOrders("ETHUSDT"))
+
+	price = 111
+	_, err = pt.GetPositions()
+	require.NoError(t, err)
+	assert.NotContains(t, pt.positions, "ETHUSDT_SHORT", "止损单数量超过剩余持仓时全部平仓")
+	assert.InDelta(t, 40.0-60.0, pt.RealizedPnL(), 1e-9)
+}
+
+// TestPaperStopOrders_SetAndCancel 测试重复设置替换同类型条件单，取消操作删除对应的条件单，没有持仓或触发价无效时报错
+func TestPaperStopOrders_SetAndCancel(t *testing.T) {
+	price := 100.0
+	pt := newStopOrderPaperTrader(t, &price)
+	assert.Error(t, pt.SetStopLoss("BTCUSDT", "LONG", 1, 95), "没有持仓")
+
+	_, err := pt.OpenLong("BTCUSDT", 1, 5)
+	require.NoError(t, err)
+	assert.Error(t, pt.SetStopLoss("BTCUSDT", "LONG", 1, 0))
+	require.NoError(t, pt.SetStopLoss("BTCUSDT", "LONG", 1, 95))
+	require.NoError(t, pt.SetStopLoss("BTCUSDT", "LONG", 1, 97))
+	require.NoError(t, pt.SetTakeProfit("BTCUSDT", "LONG", 1, 110))
+	assert.Equal(t, []PaperStopOrder{
+		{Type: PaperStopLoss, TriggerPrice: 97, Quantity: 1},
+		{Type: PaperTakeProfit, TriggerPrice: 110, Quantity: 1},
+	}, pt.StopOrders("BTCUSDT")["LONG"])
+
+	require.NoError(t, pt.CancelStopLossOrders("BTCUSDT"))
+	assert.Equal(t, []PaperStopOrder{{Type: PaperTakeProfit, TriggerPrice: 110, Quantity: 1}}, pt.StopOrders("BTCUSDT")["LONG"])
+	require.NoError(t, pt.SetStopLoss("BTCUSDT", "LONG", 1, 97))
+	require.NoError(t, pt.CancelTakeProfitOrders("BTCUSDT"))
+	assert.Equal(t, []PaperStopOrder{{Type: PaperStopLoss, TriggerPrice: 97, Quantity: 1}}, pt.StopOrders("BTCUSDT")["LONG"])
+	require.NoError(t, pt.CancelAllOrders("BTCUSDT"))
+	assert.Empty(t, pt.StopOrders("BTCUSDT"))
+
+	price = 50
+	_, err = pt.GetPositions()
+	require.NoError(t, err)
+	assert.Contains(t, pt.positions, "BTCUSDT_LONG", "已取消的止损单不再触发")
+}
+
+// TestPaperStopOrders_PersistAcrossRestart 测试条件单随持仓保存，重启后恢复并继续触发
+func TestPaperStopOrders_PersistAcrossRestart(t *testing.T) {
+	database, _ := createTempDB(t)
+	defer database.Close()
+
+	price := 100.0
+	pt, err := NewPaperTraderWithDB(5000, MarginAssetUSDT, database, "stop-orders")
+	require.NoError(t, err)
+	pt.priceFn = func(symbol string) (float64, error) { return price, nil }
+	_, err = pt.OpenLong("SOLUSDT", 5, 5)
+	require.NoError(t, err)
+	require.NoError(t, pt.SetStopLoss("SOLUSDT", "LONG", 5, 90))
+	require.NoError(t, pt.SetTakeProfit("SOLUSDT", "LONG", 5, 130))
+
+	restored, err := NewPaperTraderWithDB(5000, MarginAssetUSDT, database, "stop-orders")
+	require.NoError(t, err)
+	restored.priceFn = func(symbol string) (float64, error) { return price, nil }
+	assert.Equal(t, pt.StopOrders("SOLUSDT"), restored.StopOrders("SOLUSDT"))
+
+	price = 131
+	_, err = restored.GetPositions()
+	require.NoError(t, err)
+	assert.Empty(t, restored.positions)
+	assert.InDelta(t, 150.0, restored.RealizedPnL(), 1e-9)
+
+	// 触发后的状态也已保存
+	reloaded, err := NewPaperTraderWithDB(5000, MarginAssetUSDT, database, "stop-orders")
+	require.NoError(t, err)
+	assert.Empty(t, reloaded.positions)
+	assert.InDelta(t, 150.0, reloaded.realizedPnL, 1e-9)
+}
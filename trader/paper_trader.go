@@ -3,6 +3,7 @@ package trader
 import (
 	"encoding/json"
 	"fmt"
+	"math"
 	"strconv"
 	"strings"
 	"sync"
@@ -21,50 +22,88 @@ type Position struct {
 	EntryPrice    float64 `json:"entry_price"`
 	Leverage      int     `json:"leverage"`
 	UnrealizedPnL float64 `json:"unrealized_pnl"`
+	Margin        float64 `json:"margin"`             // 开仓时占用的初始保证金（加仓累加，部分平仓按数量比例释放，不随杠杆调整和价格变化）
+	Isolated      bool    `json:"isolated,omitempty"` // 逐仓持仓（默认全仓）
+
+	StopOrders []PaperStopOrder `json:"stop_orders,omitempty"` // 模拟仓挂的止损/止盈单（随持仓持久化，持仓平完时一起删除）
+
+	markPrice float64 // 最近一次更新未实现盈亏时的价格（不持久化）
 }
 
-// PaperTrader 模拟仓交易器
+// PaperTrader 模拟仓交易器（不计资金费：行情数据源的资金费率只用于提示词，现货数据源也没有资金费率）
 type PaperTrader struct {
-	traderID       string               // 交易器唯一标识（用于持久化）
-	initialBalance float64              // 初始USDC余额
-	balance        float64              // 当前可用USDC余额（已扣除保证金）
-	realizedPnL    float64              // 已实现盈亏
-	positions      map[string]*Position // symbol_side -> Position
-	db             *config.Database     // 数据库引用（用于持久化）
+	traderID       string                                 // 交易器唯一标识（用于持久化）
+	asset          string                                 // 保证金资产（USDT/USDC，仅影响显示单位，模拟仓按 1:1 折算USD）
+	initialBalance float64                                // 初始余额
+	balance        float64                                // 当前可用余额（已扣除保证金）
+	realizedPnL    float64                                // 已实现盈亏
+	positions      map[string]*Position                   // symbol_side -> Position
+	db             *config.Database                       // 数据库引用（用于持久化）
+	priceImpact    PriceImpactConfig                      // 大单价格冲击模型（默认关闭）
+	costs          PaperCostConfig                        // 手续费率和固定滑点（零值使用默认费率、无滑点）
+	volumeFn       quoteVolumeFunc                        // 近期成交额来源（测试可替换）
+	priceFn        func(symbol string) (float64, error)   // 行情价格来源（测试可替换）
+	dataSource     market.DataSource                      // 行情数据源（空值使用全局数据源）
+	isolated       map[string]bool                        // symbol -> 逐仓（SetMarginMode 记录，之后的开仓生效；默认全仓）
+	fill           PaperFillConfig                        // 成交价模型（默认按实时价格成交）
+	candleFn       candleFunc                             // 下一根K线开盘价成交使用的K线来源（测试和回测可替换）
+	clock          func() time.Time                       // 模拟时钟（回测使用，nil 表示实时）
+	sleepFn        func(time.Duration)                    // 等待成交延迟（测试可替换）
+	positionMode   string                                 // 持仓模式（hedge/one_way，空值为 hedge）
+	onLiquidation  func(pos Position, price, pnl float64) // 强平回调（在持有锁时调用，不能阻塞或回调模拟仓）
+	onStopOrder    stopOrderFunc                          // 止损/止盈单触发回调（在持有锁时调用，不能阻塞或回调模拟仓）
+	clientOrders   map[string]map[string]interface{}      // clientOrderID -> 已成交的按金额开仓订单（重试时直接返回，不重复成交）
+	clientOrderMu  sync.Mutex                             // 串行化带客户端订单ID的按金额开仓，避免同一ID并发重试重复成交
+	limitOrders    []PaperLimitOrder                      // 未成交的限价挂单（冻结的资金已从 balance 中扣除）
+	limitOrderSeq  int                                    // 限价单序号（与挂单时间一起生成订单ID）
+	limitFills     []paperLimitFill                       // 已成交、尚未通知回调的限价单
+	onLimitFill    limitOrderFunc                         // 限价单成交回调（释放锁后调用）
 	mu             sync.RWMutex
 }
 
-// NewPaperTrader 创建模拟仓交易器
-// initialUSDC: 初始USDC金额
-func NewPaperTrader(initialUSDC float64) (*PaperTrader, error) {
-	if initialUSDC <= 0 {
-		return nil, fmt.Errorf("初始USDC金额必须大于0")
+// NewPaperTrader 创建模拟仓交易器（保证金资产默认 USDT，可通过 SetMarginAsset 修改）
+// initialAmount: 初始金额
+func NewPaperTrader(initialAmount float64) (*PaperTrader, error) {
+	if initialAmount <= 0 {
+		return nil, fmt.Errorf("初始金额必须大于0")
 	}
 
 	trader := &PaperTrader{
-		initialBalance: initialUSDC,
-		balance:        initialUSDC,
+		asset:          MarginAssetUSDT,
+		initialBalance: initialAmount,
+		balance:        initialAmount,
 		realizedPnL:    0.0,
 		positions:      make(map[string]*Position),
+		isolated:       make(map[string]bool),
+		fill:           DefaultPaperFillConfig(),
 	}
 
-	logger.Infof("📝 [Paper Trading] 模拟仓已创建，初始余额: %.2f USDC", initialUSDC)
+	logger.Infof("📝 [Paper Trading] 模拟仓已创建，初始余额: %.2f %s", initialAmount, trader.asset)
 	return trader, nil
 }
 
 // NewPaperTraderWithDB 创建模拟仓交易器（带数据库持久化支持）
-// 如果数据库中存在已保存的状态，则恢复；否则从初始余额开始
-func NewPaperTraderWithDB(initialUSDC float64, db *config.Database, traderID string) (*PaperTrader, error) {
-	if initialUSDC <= 0 {
-		return nil, fmt.Errorf("初始USDC金额必须大于0")
+// 如果数据库中存在已保存的状态，则恢复；否则从初始余额开始。
+// asset 为账户币种（保证金资产 USDT/USDC，金额和日志均以该币种计，无效值使用 USDT）
+func NewPaperTraderWithDB(initialAmount float64, asset string, db *config.Database, traderID string) (*PaperTrader, error) {
+	if initialAmount <= 0 {
+		return nil, fmt.Errorf("初始金额必须大于0")
+	}
+	normalized, err := NormalizeMarginAsset(asset)
+	if err != nil {
+		logger.Warnf("⚠️ [Paper Trading] %v，使用 %s", err, MarginAssetUSDT)
+		normalized = MarginAssetUSDT
 	}
 
 	pt := &PaperTrader{
 		traderID:       traderID,
-		initialBalance: initialUSDC,
-		balance:        initialUSDC,
+		asset:          normalized,
+		initialBalance: initialAmount,
+		balance:        initialAmount,
 		realizedPnL:    0.0,
 		positions:      make(map[string]*Position),
+		isolated:       make(map[string]bool),
+		fill:           DefaultPaperFillConfig(),
 		db:             db,
 	}
 
@@ -77,6 +116,7 @@ func NewPaperTraderWithDB(initialUSDC float64, db *config.Database, traderID str
 			pt.initialBalance = savedInitBal
 			pt.balance = savedBalance
 			pt.realizedPnL = savedPnL
+			pt.limitOrders = loadPaperLimitOrders(db, traderID)
 
 			// 反序列化持仓
 			if savedPositions != "" && savedPositions != "{}" {
@@ -84,22 +124,65 @@ func NewPaperTraderWithDB(initialUSDC float64, db *config.Database, traderID str
 				if err := json.Unmarshal([]byte(savedPositions), &positions); err != nil {
 					logger.Warnf("⚠️ [Paper Trading] 反序列化持仓失败: %v，从空仓开始", err)
 				} else {
+					for _, pos := range positions {
+						// 旧版本保存的持仓没有记录初始保证金，按开仓价值补齐（开仓时已按该值从余额中扣除）
+						if pos.Margin <= 0 && pos.Leverage > 0 {
+							pos.Margin = pos.EntryPrice * pos.Quantity / float64(pos.Leverage)
+						}
+					}
 					pt.positions = positions
-					logger.Infof("✅ [Paper Trading] 已从数据库恢复状态: 余额=%.2f, 已实现盈亏=%.2f, 持仓数=%d",
-						savedBalance, savedPnL, len(positions))
+					logger.Infof("✅ [Paper Trading] 已从数据库恢复状态: 余额=%.2f %s, 已实现盈亏=%.2f %s, 持仓数=%d",
+						savedBalance, pt.asset, savedPnL, pt.asset, len(positions))
 					return pt, nil
 				}
 			}
-			logger.Infof("✅ [Paper Trading] 已从数据库恢复状态: 余额=%.2f, 已实现盈亏=%.2f, 无持仓",
-				savedBalance, savedPnL)
+			logger.Infof("✅ [Paper Trading] 已从数据库恢复状态: 余额=%.2f %s, 已实现盈亏=%.2f %s, 无持仓",
+				savedBalance, pt.asset, savedPnL, pt.asset)
 			return pt, nil
 		}
 	}
 
-	logger.Infof("📝 [Paper Trading] 模拟仓已创建，初始余额: %.2f USDC", initialUSDC)
+	logger.Infof("📝 [Paper Trading] 模拟仓已创建，初始余额: %.2f %s", initialAmount, pt.asset)
 	return pt, nil
 }
 
+// Clone 复制模拟仓的账户状态（余额、持仓、保证金模式和行情来源）。副本不关联数据库，
+// 在副本上交易不影响原账户（用于模拟决策）
+func (t *PaperTrader) Clone() *PaperTrader {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	positions := make(map[string]*Position, len(t.positions))
+	for key, pos := range t.positions {
+		copied := *pos
+		copied.StopOrders = append([]PaperStopOrder(nil), pos.StopOrders...)
+		positions[key] = &copied
+	}
+	isolated := make(map[string]bool, len(t.isolated))
+	for symbol, on := range t.isolated {
+		isolated[symbol] = on
+	}
+	return &PaperTrader{
+		asset:          t.asset,
+		initialBalance: t.initialBalance,
+		balance:        t.balance,
+		realizedPnL:    t.realizedPnL,
+		positions:      positions,
+		priceImpact:    t.priceImpact,
+		costs:          t.costs,
+		volumeFn:       t.volumeFn,
+		priceFn:        t.priceFn,
+		dataSource:     t.dataSource,
+		isolated:       isolated,
+		fill:           t.fill,
+		candleFn:       t.candleFn,
+		clock:          t.clock,
+		sleepFn:        t.sleepFn,
+		positionMode:   t.positionMode,
+		limitOrders:    append([]PaperLimitOrder(nil), t.limitOrders...),
+	}
+}
+
 // SaveState 将当前状态保存到数据库
 func (t *PaperTrader) SaveState() {
 	if t.db == nil || t.traderID == "" {
@@ -113,44 +196,75 @@ func (t *PaperTrader) SaveState() {
 		return
 	}
 
-	if err := t.db.SavePaperTraderState(t.traderID, t.initialBalance, t.balance, t.realizedPnL, string(positionsJSON)); err != nil {
+	// 序列化限价挂单（与余额一起保存，冻结的资金不会丢失）
+	limitOrdersJSON, err := json.Marshal(t.limitOrders)
+	if err != nil {
+		logger.Warnf("⚠️ [Paper Trading] 序列化限价挂单失败: %v", err)
+		return
+	}
+
+	if err := t.db.SavePaperTraderStateWithLimitOrders(t.traderID, t.initialBalance, t.balance, t.realizedPnL,
+		string(positionsJSON), string(limitOrdersJSON)); err != nil {
 		logger.Warnf("⚠️ [Paper Trading] 保存状态到数据库失败: %v", err)
 	}
 }
 
+// hasPosition 是否持有该币种该方向的仓位
+func (t *PaperTrader) hasPosition(symbol, side string) bool {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	pos, exists := t.positions[t.getPositionKey(symbol, side)]
+	return exists && pos.Quantity > 0
+}
+
 // getPositionKey 生成持仓键
 func (t *PaperTrader) getPositionKey(symbol, side string) string {
 	return fmt.Sprintf("%s_%s", symbol, side)
 }
 
-// updateUnrealizedPnL 更新未实现盈亏
+// updateUnrealizedPnL 更新未实现盈亏（释放锁后通知已成交的限价单）
 func (t *PaperTrader) updateUnrealizedPnL() {
 	t.mu.Lock()
-	defer t.mu.Unlock()
+	t.updateUnrealizedPnLLocked()
+	t.mu.Unlock()
+	t.notifyLimitFills()
+}
 
-	for key, pos := range t.positions {
+// updateUnrealizedPnLLocked 按当前价格成交到达限价的挂单，并更新各持仓的未实现盈亏（调用方已加锁）
+func (t *PaperTrader) updateUnrealizedPnLLocked() {
+	filled := t.fillLimitOrdersLocked()
+	for _, pos := range t.positions {
 		currentPrice, err := t.getMarketPrice(pos.Symbol)
 		if err != nil {
 			logger.Warnf("⚠️ [Paper Trading] 获取 %s 价格失败: %v", pos.Symbol, err)
 			continue
 		}
+		pos.markPrice = currentPrice
+		pos.UnrealizedPnL = pos.pnlAt(currentPrice)
+	}
 
-		if pos.Side == "LONG" {
-			// 多仓盈亏 = (当前价格 - 开仓价格) * 数量
-			pos.UnrealizedPnL = (currentPrice - pos.EntryPrice) * pos.Quantity
-		} else {
-			// 空仓盈亏 = (开仓价格 - 当前价格) * 数量
-			pos.UnrealizedPnL = (pos.EntryPrice - currentPrice) * pos.Quantity
-		}
-
-		t.positions[key] = pos
+	// 先按最新价格触发止损/止盈单，再检查强平
+	triggered := t.triggerStopOrdersLocked()
+	if liquidated := t.liquidateLocked(); filled || triggered || len(liquidated) > 0 {
+		t.SaveState()
 	}
 }
 
 // getMarketPrice 获取市场价格
 func (t *PaperTrader) getMarketPrice(symbol string) (float64, error) {
-	// 使用 market 包获取实时价格
-	apiClient := market.NewAPIClient()
+	if t.priceFn != nil {
+		return t.priceFn(symbol)
+	}
+
+	// 优先使用WebSocket行情缓存，避免频繁轮询时每次都请求REST（缓存只包含全局数据源的行情）
+	if t.usesGlobalDataSource() {
+		if price, ok := market.GetCachedPrice(symbol); ok {
+			return price, nil
+		}
+	}
+
+	// 缓存未命中时使用 market 包获取实时价格
+	apiClient := market.NewAPIClientFor(t.dataSource)
 	price, err := apiClient.GetCurrentPrice(symbol)
 	if err != nil {
 		return 0, fmt.Errorf("获取市场价格失败: %w", err)
@@ -158,44 +272,231 @@ func (t *PaperTrader) getMarketPrice(symbol string) (float64, error) {
 	return price, nil
 }
 
-// GetBalance 获取账户余额
-func (t *PaperTrader) GetBalance() (map[string]interface{}, error) {
-	// 更新未实现盈亏
-	t.updateUnrealizedPnL()
+// SetDataSource 设置模拟仓的行情数据源（空值使用全局数据源）
+func (t *PaperTrader) SetDataSource(source market.DataSource) {
+	t.dataSource = source
+}
+
+// usesGlobalDataSource 模拟仓是否使用全局数据源（可以复用WebSocket行情缓存）
+func (t *PaperTrader) usesGlobalDataSource() bool {
+	return t.dataSource == "" || t.dataSource == market.GetCurrentDataSource()
+}
+
+// SetMarginAsset 设置模拟仓的保证金资产（USDT/USDC）
+func (t *PaperTrader) SetMarginAsset(asset string) {
+	normalized, err := NormalizeMarginAsset(asset)
+	if err != nil {
+		logger.Warnf("⚠️ [Paper Trading] %v，使用 %s", err, MarginAssetUSDT)
+		normalized = MarginAssetUSDT
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.asset = normalized
+}
 
+// MarginAsset 返回模拟仓的保证金资产
+func (t *PaperTrader) MarginAsset() string {
 	t.mu.RLock()
 	defer t.mu.RUnlock()
+	return t.asset
+}
+
+// paperMaintenanceMarginRate 模拟仓维持保证金率（按当前价格的名义价值计，参考币安 BTCUSDT 第一档 0.4%）
+const paperMaintenanceMarginRate = 0.004
+
+// maintenanceMargin 持仓的维持保证金（按最近价格的名义价值计，尚未取得价格时按开仓价估算）
+func (p *Position) maintenanceMargin() float64 {
+	markPrice := p.markPrice
+	if markPrice <= 0 {
+		markPrice = p.EntryPrice
+	}
+	return p.Quantity * markPrice * paperMaintenanceMarginRate
+}
+
+// liquidateLocked 按最近价格检查强平（调用方已加锁，且已更新未实现盈亏），返回被强平的持仓键
+//
+//	逐仓：IM_i + uPnL_i ≤ MM_i 时单独强平该持仓，亏损以该持仓的保证金为限，不影响其他持仓和账户余额
+//	全仓：free + Σ_全仓 (IM_i + uPnL_i) ≤ Σ_全仓 MM_i 时强平全部全仓持仓，亏损由整个账户余额承担
+//	     （穿仓部分不追缴，余额最低为 0）
+//
+// 尚未取得价格的持仓不参与强平判断
+func (t *PaperTrader) liquidateLocked() []string {
+	var liquidated []string
+	crossEquity, crossMaintenance := t.balance, 0.0
+	crossPriced := true
+	for key, pos := range t.positions {
+		if pos.markPrice <= 0 {
+			if !pos.Isolated {
+				crossPriced = false
+			}
+			continue
+		}
+		if !pos.Isolated {
+			crossEquity += pos.Margin + pos.UnrealizedPnL
+			crossMaintenance += pos.maintenanceMargin()
+			continue
+		}
+		if pos.Margin+pos.UnrealizedPnL > pos.maintenanceMargin() {
+			continue
+		}
+		// 逐仓强平：保证金全部亏损（开仓时已从空闲余额扣除）
+		t.realizedPnL -= pos.Margin
+		delete(t.positions, key)
+		liquidated = append(liquidated, key)
+		t.notifyLiquidationLocked(pos, -pos.Margin)
+		logger.Warnf("💥 [Paper Trading] 逐仓强平: %s %s, 数量: %.6f, 开仓价: %.2f, 强平价: %.2f, 亏损保证金: %.2f %s",
+			pos.Symbol, pos.Side, pos.Quantity, pos.EntryPrice, pos.markPrice, pos.Margin, t.asset)
+	}
+
+	if !crossPriced || crossMaintenance == 0 || crossEquity > crossMaintenance {
+		return liquidated
+	}
+	for key, pos := range t.positions {
+		if pos.Isolated {
+			continue
+		}
+		t.balance += pos.Margin + pos.UnrealizedPnL
+		t.realizedPnL += pos.UnrealizedPnL
+		delete(t.positions, key)
+		liquidated = append(liquidated, key)
+		t.notifyLiquidationLocked(pos, pos.UnrealizedPnL)
+		logger.Warnf("💥 [Paper Trading] 全仓强平: %s %s, 数量: %.6f, 开仓价: %.2f, 强平价: %.2f, 盈亏: %.2f %s",
+			pos.Symbol, pos.Side, pos.Quantity, pos.EntryPrice, pos.markPrice, pos.UnrealizedPnL, t.asset)
+	}
+	if t.balance < 0 {
+		logger.Warnf("💥 [Paper Trading] 全仓穿仓 %.2f %s，余额归零", -t.balance, t.asset)
+		t.balance = 0
+	}
+	return liquidated
+}
 
-	// 计算总未实现盈亏和总保证金占用
-	totalUnrealizedPnL := 0.0
-	totalMarginUsed := 0.0
+// SetLiquidationHandler 设置强平回调（在持有锁时调用，回调不能阻塞或调用模拟仓方法）
+func (t *PaperTrader) SetLiquidationHandler(fn func(pos Position, price, pnl float64)) {
+	t.mu.Lock()
+	t.onLiquidation = fn
+	t.mu.Unlock()
+}
+
+// notifyLiquidationLocked 通知强平回调
+func (t *PaperTrader) notifyLiquidationLocked(pos *Position, pnl float64) {
+	if t.onLiquidation != nil {
+		t.onLiquidation(*pos, pos.markPrice, pnl)
+	}
+}
+
+// liquidationPriceLocked 持仓的强平价格（调用方已加锁，且已更新未实现盈亏）：
+// 逐仓按持仓自身保证金计算；全仓按空闲余额和其他全仓持仓的净值（扣除维持保证金）共同承担亏损计算，假设其他持仓价格不变
+func (t *PaperTrader) liquidationPriceLocked(pos *Position) float64 {
+	if pos.Quantity <= 0 {
+		return 0
+	}
+	buffer := pos.Margin
+	if !pos.Isolated {
+		buffer += t.balance
+		for _, other := range t.positions {
+			if other != pos && !other.Isolated {
+				buffer += other.Margin + other.UnrealizedPnL - other.maintenanceMargin()
+			}
+		}
+	}
+	// 多仓：buffer + (P - E)·Q = Q·P·mmr；空仓：buffer + (E - P)·Q = Q·P·mmr
+	if pos.Side == "LONG" {
+		return math.Max(0, (pos.EntryPrice*pos.Quantity-buffer)/(pos.Quantity*(1-paperMaintenanceMarginRate)))
+	}
+	return (pos.EntryPrice*pos.Quantity + buffer) / (pos.Quantity * (1 + paperMaintenanceMarginRate))
+}
+
+// paperBalance 模拟仓账户余额明细
+type paperBalance struct {
+	Free              float64 // 空闲余额（t.balance）
+	Wallet            float64 // 钱包余额
+	ReservedMargin    float64 // 全部持仓占用的初始保证金
+	OrderReserved     float64 // 限价挂单冻结的保证金和手续费
+	MaintenanceMargin float64 // 全仓持仓的维持保证金
+	UnrealizedPnL     float64 // 全部持仓的未实现盈亏
+	Equity            float64 // 总权益
+	Available         float64 // 可用余额（可能为负）
+}
+
+// computeBalanceLocked 计算账户余额（调用方已加锁，且已更新未实现盈亏）
+//
+// 记号：
+//
+//	free   = t.balance：开仓时扣除初始保证金和手续费，平仓时返还释放的保证金并计入盈亏，
+//	         挂限价单时冻结保证金和手续费（成交或撤单时释放）
+//	R_j    = 限价挂单 j 冻结的资金 PaperLimitOrder.Reserved
+//	IM_i   = 持仓 i 开仓时占用的初始保证金 Position.Margin（不随当前价格和杠杆调整重算）
+//	uPnL_i = 持仓 i 按当前价格计算的未实现盈亏
+//	MM_i   = 数量_i × 当前价格_i × paperMaintenanceMarginRate
+//
+// 公式：
+//
+//	钱包余额  wallet = free + Σ IM_i + Σ R_j
+//	总权益    equity = wallet + Σ uPnL_i
+//	全仓：各持仓共享账户余额，浮盈浮亏直接计入可用余额，并预留维持保证金缓冲
+//	          cross = Σ_全仓 (uPnL_i − MM_i)
+//	逐仓：每个持仓的亏损由自己的保证金承担，只有超出保证金的亏损（IM_i + uPnL_i < 0）影响账户
+//	          isolated = Σ_逐仓 min(0, IM_i + uPnL_i)
+//	      （取得价格时已按 liquidateLocked 强平，该项只在价格未更新的持仓上出现）
+//	可用余额  available = free + cross + isolated
+//
+// 全仓浮亏超过空闲余额时 available 为负，不截断为 0（由 marginDeficit 报告缺口，风控据此拒绝开仓）
+func (t *PaperTrader) computeBalanceLocked() paperBalance {
+	b := paperBalance{Free: t.balance}
+	cross, isolated := 0.0, 0.0
 	for _, pos := range t.positions {
-		totalUnrealizedPnL += pos.UnrealizedPnL
-		// 计算该持仓的保证金占用
-		currentPrice, err := t.getMarketPrice(pos.Symbol)
-		if err == nil {
-			notional := pos.Quantity * currentPrice
-			marginUsed := notional / float64(pos.Leverage)
-			totalMarginUsed += marginUsed
+		b.ReservedMargin += pos.Margin
+		b.UnrealizedPnL += pos.UnrealizedPnL
+		if pos.Isolated {
+			isolated += math.Min(0, pos.Margin+pos.UnrealizedPnL)
+			continue
 		}
+		maintenance := pos.maintenanceMargin()
+		b.MaintenanceMargin += maintenance
+		cross += pos.UnrealizedPnL - maintenance
+	}
+	for _, order := range t.limitOrders {
+		b.OrderReserved += order.Reserved
 	}
+	b.Wallet = b.Free + b.ReservedMargin + b.OrderReserved
+	b.Equity = b.Wallet + b.UnrealizedPnL
+	b.Available = b.Free + cross + isolated
+	return b
+}
+
+// GetBalance 获取账户余额（计算公式见 computeBalanceLocked）
+func (t *PaperTrader) GetBalance() (map[string]interface{}, error) {
+	// 更新未实现盈亏
+	t.updateUnrealizedPnL()
 
-	// 总权益 = 初始余额 + 已实现盈亏 + 未实现盈亏
-	totalBalance := t.initialBalance + t.realizedPnL + totalUnrealizedPnL
+	t.mu.RLock()
+	defer t.mu.RUnlock()
 
-	// 可用余额 = 总权益 - 保证金占用
-	// 注意：t.balance 是开仓后剩余的余额（已扣除保证金），但不包含未实现盈亏
-	// 所以可用余额应该是：总权益 - 保证金占用
-	availableBalance := totalBalance - totalMarginUsed
-	if availableBalance < 0 {
-		availableBalance = 0 // 防止负数
+	b := t.computeBalanceLocked()
+	if b.Available < 0 {
+		logger.Warnf("⚠️ [Paper Trading] 可用余额为负: %.2f %s（空闲余额 %.2f，未实现盈亏 %.2f）",
+			b.Available, t.asset, b.Free, b.UnrealizedPnL)
 	}
 
 	result := map[string]interface{}{
-		"totalWalletBalance":    totalBalance,
-		"availableBalance":      availableBalance,
-		"totalUnrealizedProfit": totalUnrealizedPnL,
+		"totalWalletBalance":    b.Wallet,
+		"availableBalance":      b.Available,
+		"totalUnrealizedProfit": b.UnrealizedPnL,
+		"totalInitialMargin":    b.ReservedMargin,
+		"totalMaintMargin":      b.MaintenanceMargin,
+		"totalOpenOrderMargin":  b.OrderReserved,
+		"marginDeficit":         math.Max(0, -b.Available),
 		"initialBalance":        t.initialBalance,
+		// 模拟仓单一保证金资产，按 1:1 折算USD
+		"assetBalances": []AssetBalance{{
+			Asset:            t.asset,
+			WalletBalance:    b.Wallet,
+			AvailableBalance: b.Available,
+			UnrealizedProfit: b.UnrealizedPnL,
+			USDPrice:         1,
+			USDValue:         b.Equity,
+		}},
 	}
 
 	return result, nil
@@ -215,13 +516,8 @@ func (t *PaperTrader) GetPositions() ([]map[string]interface{}, error) {
 			currentPrice, _ := t.getMarketPrice(pos.Symbol)
 			// 标准化 side 字段：将 "LONG"/"SHORT" 转换为小写 "long"/"short"
 			side := strings.ToLower(pos.Side)
-			// 计算清算价格（简化计算：entryPrice * (1 - 1/leverage) for long, entryPrice * (1 + 1/leverage) for short）
-			liquidationPrice := 0.0
-			if side == "long" {
-				liquidationPrice = pos.EntryPrice * (1.0 - 1.0/float64(pos.Leverage))
-			} else if side == "short" {
-				liquidationPrice = pos.EntryPrice * (1.0 + 1.0/float64(pos.Leverage))
-			}
+			// 强平价格：逐仓按持仓保证金，全仓按账户余额计算（见 liquidationPriceLocked）
+			liquidationPrice := t.liquidationPriceLocked(pos)
 			positions = append(positions, map[string]interface{}{
 				"symbol":           pos.Symbol,
 				"side":             side, // 使用 "side" 而不是 "positionSide"，与其他交易所保持一致
@@ -232,6 +528,8 @@ func (t *PaperTrader) GetPositions() ([]map[string]interface{}, error) {
 				"unRealizedProfit": pos.UnrealizedPnL,
 				"liquidationPrice": liquidationPrice,
 				"leverage":         pos.Leverage,
+				"initialMargin":    pos.Margin,
+				"marginMode":       paperMarginMode(pos.Isolated),
 			})
 		}
 	}
@@ -241,30 +539,84 @@ func (t *PaperTrader) GetPositions() ([]map[string]interface{}, error) {
 
 // OpenLong 开多仓
 func (t *PaperTrader) OpenLong(symbol string, quantity float64, leverage int) (map[string]interface{}, error) {
-	t.mu.Lock()
-	defer t.mu.Unlock()
-
 	if quantity <= 0 {
 		return nil, fmt.Errorf("数量必须大于0")
 	}
+	return t.openLong(symbol, quantity, 0, leverage)
+}
+
+// OpenLongQuote 按金额开多仓：花费固定为 quoteQuantity，成交数量 = 金额 / 成交价
+func (t *PaperTrader) OpenLongQuote(symbol string, quoteQuantity float64, leverage int) (map[string]interface{}, error) {
+	if quoteQuantity <= 0 {
+		return nil, fmt.Errorf("金额必须大于0")
+	}
+	return t.openLong(symbol, 0, quoteQuantity, leverage)
+}
 
-	// 获取当前价格
-	currentPrice, err := t.getMarketPrice(symbol)
+// openLong 开多仓（quoteQuantity > 0 时按金额下单，数量在确定成交价后计算）
+func (t *PaperTrader) openLong(symbol string, quantity, quoteQuantity float64, leverage int) (map[string]interface{}, error) {
+
+	// 获取成交价格（下一根K线开盘价模式需要等待成交延迟，在加锁前获取；启用价格冲击模型时，大单买入成交价上移）
+	currentPrice, err := t.fillBasePrice(symbol)
 	if err != nil {
 		return nil, err
 	}
 
-	// 计算所需保证金（简化：使用全仓模式）
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if quoteQuantity > 0 {
+		if currentPrice <= 0 {
+			return nil, fmt.Errorf("%s 价格无效: %.8f，无法按金额下单", symbol, currentPrice)
+		}
+		quantity = quoteQuantity / currentPrice // 按基准价估算数量，用于计算价格冲击
+	}
+	currentPrice = t.applySlippage(t.applyPriceImpact(symbol, quantity, currentPrice, true), true)
+	if quoteQuantity > 0 {
+		quantity = quoteQuantity / currentPrice // 花费固定，成交价只影响数量
+	}
+
+	// 单向持仓模式下先抵消空仓（结算盈亏、释放保证金），剩余数量再开仓
+	orderQuantity := quantity
+	netted := t.nettableQuantityLocked(symbol, "SHORT", quantity)
+	var nettedPnL float64
+	undoNet := func() {}
+	if netted > 0 {
+		nettedPnL, undoNet = t.netOppositeLocked(symbol, "SHORT", netted, currentPrice)
+		quantity -= netted
+	}
+	if quantity <= 0 {
+		t.SaveState()
+		result := map[string]interface{}{
+			"orderId":  fmt.Sprintf("paper_%d", time.Now().UnixNano()),
+			"symbol":   symbol,
+			"side":     "BUY",
+			"quantity": orderQuantity,
+			"price":    currentPrice,
+			"netted":   netted,
+			"pnl":      nettedPnL,
+			"status":   "FILLED",
+		}
+		if quoteQuantity > 0 {
+			result["quoteQuantity"] = quoteQuantity
+		}
+		return result, nil
+	}
+
+	// 计算所需保证金
 	notional := quantity * currentPrice
 	requiredMargin := notional / float64(leverage)
 
-	// 计算手续费（Taker费率 0.04%）
-	tradingFee := notional * 0.0004
+	// 计算手续费（默认Taker费率 0.04%，可按真实度档位调整）
+	tradingFee := notional * t.feeRate()
 	totalRequired := requiredMargin + tradingFee
 
-	if t.balance < totalRequired {
-		return nil, fmt.Errorf("余额不足，需要 %.2f USDC（保证金 %.2f + 手续费 %.2f），当前可用 %.2f USDC",
-			totalRequired, requiredMargin, tradingFee, t.balance)
+	// 可用余额包含全仓持仓的浮动盈亏，全仓浮亏较大时即使空闲余额充足也不能开新仓
+	t.updateUnrealizedPnLLocked()
+	available := t.computeBalanceLocked().Available
+	if available < totalRequired {
+		undoNet() // 剩余数量无法开仓时整单不成交
+		return nil, fmt.Errorf("余额不足，需要 %.2f %s（保证金 %.2f + 手续费 %.2f），当前可用 %.2f %s",
+			totalRequired, t.asset, requiredMargin, tradingFee, available, t.asset)
 	}
 
 	key := t.getPositionKey(symbol, "LONG")
@@ -278,6 +630,7 @@ func (t *PaperTrader) OpenLong(symbol string, quantity float64, leverage int) (m
 		pos.Quantity = totalQuantity
 		pos.EntryPrice = newEntryPrice
 		pos.Leverage = leverage
+		pos.Margin += requiredMargin
 	} else {
 		// 新开仓
 		pos = &Position{
@@ -286,6 +639,8 @@ func (t *PaperTrader) OpenLong(symbol string, quantity float64, leverage int) (m
 			Quantity:   quantity,
 			EntryPrice: currentPrice,
 			Leverage:   leverage,
+			Margin:     requiredMargin,
+			Isolated:   t.isolated[symbol],
 		}
 	}
 
@@ -293,49 +648,113 @@ func (t *PaperTrader) OpenLong(symbol string, quantity float64, leverage int) (m
 	// 扣除保证金和手续费
 	t.balance -= totalRequired
 
-	logger.Infof("📝 [Paper Trading] 开多仓: %s, 数量: %.6f, 价格: %.2f, 杠杆: %dx, 保证金: %.2f USDC, 手续费: %.2f USDC",
-		symbol, quantity, currentPrice, leverage, requiredMargin, tradingFee)
+	logger.Infof("📝 [Paper Trading] 开多仓: %s, 数量: %.6f, 价格: %.2f, 杠杆: %dx, 保证金: %.2f %s, 手续费: %.2f %s",
+		symbol, quantity, currentPrice, leverage, requiredMargin, t.asset, tradingFee, t.asset)
 
 	// 持久化状态
 	t.SaveState()
 
-	return map[string]interface{}{
+	result := map[string]interface{}{
 		"orderId":  fmt.Sprintf("paper_%d", time.Now().UnixNano()),
 		"symbol":   symbol,
 		"side":     "BUY",
-		"quantity": quantity,
+		"quantity": orderQuantity,
 		"price":    currentPrice,
 		"leverage": leverage,
+		"margin":   requiredMargin,
+		"fee":      tradingFee,
 		"status":   "FILLED",
-	}, nil
+	}
+	if netted > 0 {
+		result["netted"] = netted
+		result["pnl"] = nettedPnL
+	}
+	if quoteQuantity > 0 {
+		result["quoteQuantity"] = quoteQuantity
+	}
+	return result, nil
 }
 
 // OpenShort 开空仓
 func (t *PaperTrader) OpenShort(symbol string, quantity float64, leverage int) (map[string]interface{}, error) {
-	t.mu.Lock()
-	defer t.mu.Unlock()
-
 	if quantity <= 0 {
 		return nil, fmt.Errorf("数量必须大于0")
 	}
+	return t.openShort(symbol, quantity, 0, leverage)
+}
+
+// OpenShortQuote 按金额开空仓：花费固定为 quoteQuantity，成交数量 = 金额 / 成交价
+func (t *PaperTrader) OpenShortQuote(symbol string, quoteQuantity float64, leverage int) (map[string]interface{}, error) {
+	if quoteQuantity <= 0 {
+		return nil, fmt.Errorf("金额必须大于0")
+	}
+	return t.openShort(symbol, 0, quoteQuantity, leverage)
+}
+
+// openShort 开空仓（quoteQuantity > 0 时按金额下单，数量在确定成交价后计算）
+func (t *PaperTrader) openShort(symbol string, quantity, quoteQuantity float64, leverage int) (map[string]interface{}, error) {
 
-	// 获取当前价格
-	currentPrice, err := t.getMarketPrice(symbol)
+	// 获取成交价格（下一根K线开盘价模式需要等待成交延迟，在加锁前获取；启用价格冲击模型时，大单卖出成交价下移）
+	currentPrice, err := t.fillBasePrice(symbol)
 	if err != nil {
 		return nil, err
 	}
 
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if quoteQuantity > 0 {
+		if currentPrice <= 0 {
+			return nil, fmt.Errorf("%s 价格无效: %.8f，无法按金额下单", symbol, currentPrice)
+		}
+		quantity = quoteQuantity / currentPrice // 按基准价估算数量，用于计算价格冲击
+	}
+	currentPrice = t.applySlippage(t.applyPriceImpact(symbol, quantity, currentPrice, false), false)
+	if quoteQuantity > 0 {
+		quantity = quoteQuantity / currentPrice // 花费固定，成交价只影响数量
+	}
+
+	// 单向持仓模式下先抵消多仓（结算盈亏、释放保证金），剩余数量再开仓
+	orderQuantity := quantity
+	netted := t.nettableQuantityLocked(symbol, "LONG", quantity)
+	var nettedPnL float64
+	undoNet := func() {}
+	if netted > 0 {
+		nettedPnL, undoNet = t.netOppositeLocked(symbol, "LONG", netted, currentPrice)
+		quantity -= netted
+	}
+	if quantity <= 0 {
+		t.SaveState()
+		result := map[string]interface{}{
+			"orderId":  fmt.Sprintf("paper_%d", time.Now().UnixNano()),
+			"symbol":   symbol,
+			"side":     "SELL",
+			"quantity": orderQuantity,
+			"price":    currentPrice,
+			"netted":   netted,
+			"pnl":      nettedPnL,
+			"status":   "FILLED",
+		}
+		if quoteQuantity > 0 {
+			result["quoteQuantity"] = quoteQuantity
+		}
+		return result, nil
+	}
+
 	// 计算所需保证金
 	notional := quantity * currentPrice
 	requiredMargin := notional / float64(leverage)
 
-	// 计算手续费（Taker费率 0.04%）
-	tradingFee := notional * 0.0004
+	// 计算手续费（默认Taker费率 0.04%，可按真实度档位调整）
+	tradingFee := notional * t.feeRate()
 	totalRequired := requiredMargin + tradingFee
 
-	if t.balance < totalRequired {
-		return nil, fmt.Errorf("余额不足，需要 %.2f USDC（保证金 %.2f + 手续费 %.2f），当前可用 %.2f USDC",
-			totalRequired, requiredMargin, tradingFee, t.balance)
+	// 可用余额包含全仓持仓的浮动盈亏，全仓浮亏较大时即使空闲余额充足也不能开新仓
+	t.updateUnrealizedPnLLocked()
+	available := t.computeBalanceLocked().Available
+	if available < totalRequired {
+		undoNet() // 剩余数量无法开仓时整单不成交
+		return nil, fmt.Errorf("余额不足，需要 %.2f %s（保证金 %.2f + 手续费 %.2f），当前可用 %.2f %s",
+			totalRequired, t.asset, requiredMargin, tradingFee, available, t.asset)
 	}
 
 	key := t.getPositionKey(symbol, "SHORT")
@@ -349,6 +768,7 @@ func (t *PaperTrader) OpenShort(symbol string, quantity float64, leverage int) (
 		pos.Quantity = totalQuantity
 		pos.EntryPrice = newEntryPrice
 		pos.Leverage = leverage
+		pos.Margin += requiredMargin
 	} else {
 		// 新开仓
 		pos = &Position{
@@ -357,6 +777,8 @@ func (t *PaperTrader) OpenShort(symbol string, quantity float64, leverage int) (
 			Quantity:   quantity,
 			EntryPrice: currentPrice,
 			Leverage:   leverage,
+			Margin:     requiredMargin,
+			Isolated:   t.isolated[symbol],
 		}
 	}
 
@@ -364,25 +786,45 @@ func (t *PaperTrader) OpenShort(symbol string, quantity float64, leverage int) (
 	// 扣除保证金和手续费
 	t.balance -= totalRequired
 
-	logger.Infof("📝 [Paper Trading] 开空仓: %s, 数量: %.6f, 价格: %.2f, 杠杆: %dx, 保证金: %.2f USDC, 手续费: %.2f USDC",
-		symbol, quantity, currentPrice, leverage, requiredMargin, tradingFee)
+	logger.Infof("📝 [Paper Trading] 开空仓: %s, 数量: %.6f, 价格: %.2f, 杠杆: %dx, 保证金: %.2f %s, 手续费: %.2f %s",
+		symbol, quantity, currentPrice, leverage, requiredMargin, t.asset, tradingFee, t.asset)
 
 	// 持久化状态
 	t.SaveState()
 
-	return map[string]interface{}{
+	result := map[string]interface{}{
 		"orderId":  fmt.Sprintf("paper_%d", time.Now().UnixNano()),
 		"symbol":   symbol,
 		"side":     "SELL",
-		"quantity": quantity,
+		"quantity": orderQuantity,
 		"price":    currentPrice,
 		"leverage": leverage,
+		"margin":   requiredMargin,
+		"fee":      tradingFee,
 		"status":   "FILLED",
-	}, nil
+	}
+	if netted > 0 {
+		result["netted"] = netted
+		result["pnl"] = nettedPnL
+	}
+	if quoteQuantity > 0 {
+		result["quoteQuantity"] = quoteQuantity
+	}
+	return result, nil
 }
 
 // CloseLong 平多仓
 func (t *PaperTrader) CloseLong(symbol string, quantity float64) (map[string]interface{}, error) {
+	if !t.hasPosition(symbol, "LONG") {
+		return nil, fmt.Errorf("没有多仓持仓")
+	}
+
+	// 获取成交价格（下一根K线开盘价模式需要等待成交延迟，在加锁前获取）
+	currentPrice, err := t.fillBasePrice(symbol)
+	if err != nil {
+		return nil, err
+	}
+
 	t.mu.Lock()
 	defer t.mu.Unlock()
 
@@ -393,41 +835,19 @@ func (t *PaperTrader) CloseLong(symbol string, quantity float64) (map[string]int
 		return nil, fmt.Errorf("没有多仓持仓")
 	}
 
-	// 获取当前价格
-	currentPrice, err := t.getMarketPrice(symbol)
-	if err != nil {
-		return nil, err
-	}
-
 	// 确定平仓数量
 	closeQuantity := quantity
 	if quantity <= 0 || quantity > pos.Quantity {
 		closeQuantity = pos.Quantity
 	}
+	currentPrice = t.applySlippage(t.applyPriceImpact(symbol, closeQuantity, currentPrice, false), false) // 平多为卖出
 
-	// 保存开仓价和杠杆（用于日志）
+	// 保存开仓价（用于日志）
 	entryPrice := pos.EntryPrice
-	leverage := pos.Leverage
-
-	// 计算盈亏
-	pnl := (currentPrice - entryPrice) * closeQuantity
-	marginUsed := (entryPrice * closeQuantity) / float64(leverage)
+	pnl := t.settleCloseLocked(key, pos, closeQuantity, currentPrice)
 
-	// 更新余额（返还保证金 + 盈亏）
-	t.balance += marginUsed + pnl
-	// 更新已实现盈亏
-	t.realizedPnL += pnl
-
-	// 更新持仓
-	pos.Quantity -= closeQuantity
-	if pos.Quantity <= 0 {
-		delete(t.positions, key)
-	} else {
-		t.positions[key] = pos
-	}
-
-	logger.Infof("📝 [Paper Trading] 平多仓: %s, 数量: %.6f, 开仓价: %.2f, 平仓价: %.2f, 盈亏: %.2f USDC",
-		symbol, closeQuantity, entryPrice, currentPrice, pnl)
+	logger.Infof("📝 [Paper Trading] 平多仓: %s, 数量: %.6f, 开仓价: %.2f, 平仓价: %.2f, 盈亏: %.2f %s",
+		symbol, closeQuantity, entryPrice, currentPrice, pnl, t.asset)
 
 	// 持久化状态
 	t.SaveState()
@@ -445,6 +865,16 @@ func (t *PaperTrader) CloseLong(symbol string, quantity float64) (map[string]int
 
 // CloseShort 平空仓
 func (t *PaperTrader) CloseShort(symbol string, quantity float64) (map[string]interface{}, error) {
+	if !t.hasPosition(symbol, "SHORT") {
+		return nil, fmt.Errorf("没有空仓持仓")
+	}
+
+	// 获取成交价格（下一根K线开盘价模式需要等待成交延迟，在加锁前获取）
+	currentPrice, err := t.fillBasePrice(symbol)
+	if err != nil {
+		return nil, err
+	}
+
 	t.mu.Lock()
 	defer t.mu.Unlock()
 
@@ -455,25 +885,48 @@ func (t *PaperTrader) CloseShort(symbol string, quantity float64) (map[string]in
 		return nil, fmt.Errorf("没有空仓持仓")
 	}
 
-	// 获取当前价格
-	currentPrice, err := t.getMarketPrice(symbol)
-	if err != nil {
-		return nil, err
-	}
-
 	// 确定平仓数量
 	closeQuantity := quantity
 	if quantity <= 0 || quantity > pos.Quantity {
 		closeQuantity = pos.Quantity
 	}
+	currentPrice = t.applySlippage(t.applyPriceImpact(symbol, closeQuantity, currentPrice, true), true) // 平空为买入
 
-	// 保存开仓价和杠杆（用于日志）
+	// 保存开仓价（用于日志）
 	entryPrice := pos.EntryPrice
-	leverage := pos.Leverage
+	pnl := t.settleCloseLocked(key, pos, closeQuantity, currentPrice)
+
+	logger.Infof("📝 [Paper Trading] 平空仓: %s, 数量: %.6f, 开仓价: %.2f, 平仓价: %.2f, 盈亏: %.2f %s",
+		symbol, closeQuantity, entryPrice, currentPrice, pnl, t.asset)
+
+	// 持久化状态
+	t.SaveState()
 
+	return map[string]interface{}{
+		"orderId":  fmt.Sprintf("paper_%d", time.Now().UnixNano()),
+		"symbol":   symbol,
+		"side":     "BUY",
+		"quantity": closeQuantity,
+		"price":    currentPrice,
+		"pnl":      pnl,
+		"status":   "FILLED",
+	}, nil
+}
+
+// settleCloseLocked 按成交价平掉持仓的 closeQuantity 数量：结算盈亏、按比例释放保证金，全部平完时删除持仓。
+// 返回已实现盈亏（调用方需持有写锁）
+func (t *PaperTrader) settleCloseLocked(key string, pos *Position, closeQuantity, price float64) float64 {
 	// 计算盈亏
-	pnl := (entryPrice - currentPrice) * closeQuantity
-	marginUsed := (entryPrice * closeQuantity) / float64(leverage)
+	pnl := (price - pos.EntryPrice) * closeQuantity
+	if pos.Side == "SHORT" {
+		pnl = -pnl
+	}
+	// 按平仓数量比例释放开仓时占用的保证金（开仓后调整杠杆不影响已占用的保证金）
+	marginUsed := pos.Margin * closeQuantity / pos.Quantity
+	// 逐仓亏损以释放的保证金为限
+	if pos.Isolated && pnl < -marginUsed {
+		pnl = -marginUsed
+	}
 
 	// 更新余额（返还保证金 + 盈亏）
 	t.balance += marginUsed + pnl
@@ -481,28 +934,14 @@ func (t *PaperTrader) CloseShort(symbol string, quantity float64) (map[string]in
 	t.realizedPnL += pnl
 
 	// 更新持仓
+	pos.Margin -= marginUsed
 	pos.Quantity -= closeQuantity
 	if pos.Quantity <= 0 {
 		delete(t.positions, key)
 	} else {
 		t.positions[key] = pos
 	}
-
-	logger.Infof("📝 [Paper Trading] 平空仓: %s, 数量: %.6f, 开仓价: %.2f, 平仓价: %.2f, 盈亏: %.2f USDC",
-		symbol, closeQuantity, entryPrice, currentPrice, pnl)
-
-	// 持久化状态
-	t.SaveState()
-
-	return map[string]interface{}{
-		"orderId":  fmt.Sprintf("paper_%d", time.Now().UnixNano()),
-		"symbol":   symbol,
-		"side":     "BUY",
-		"quantity": closeQuantity,
-		"price":    currentPrice,
-		"pnl":      pnl,
-		"status":   "FILLED",
-	}, nil
+	return pnl
 }
 
 // SetLeverage 设置杠杆（模拟仓中仅记录，不影响实际交易）
@@ -522,8 +961,18 @@ func (t *PaperTrader) SetLeverage(symbol string, leverage int) error {
 	return nil
 }
 
-// SetMarginMode 设置仓位模式（模拟仓中仅记录）
+// SetMarginMode 设置仓位模式（之后的开仓生效；与交易所一致，有持仓时不能切换）
 func (t *PaperTrader) SetMarginMode(symbol string, isCrossMargin bool) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, pos := range t.positions {
+		if pos.Symbol == symbol && pos.Isolated == isCrossMargin {
+			return fmt.Errorf("%s 存在持仓，无法切换仓位模式", symbol)
+		}
+	}
+	t.isolated[symbol] = !isCrossMargin
+
 	mode := "逐仓"
 	if isCrossMargin {
 		mode = "全仓"
@@ -532,43 +981,19 @@ func (t *PaperTrader) SetMarginMode(symbol string, isCrossMargin bool) error {
 	return nil
 }
 
+// paperMarginMode 仓位模式名称
+func paperMarginMode(isolated bool) string {
+	if isolated {
+		return "isolated"
+	}
+	return "cross"
+}
+
 // GetMarketPrice 获取市场价格
 func (t *PaperTrader) GetMarketPrice(symbol string) (float64, error) {
 	return t.getMarketPrice(symbol)
 }
 
-// SetStopLoss 设置止损单（模拟仓中暂不支持）
-func (t *PaperTrader) SetStopLoss(symbol string, positionSide string, quantity, stopPrice float64) error {
-	logger.Infof("📝 [Paper Trading] 止损单功能暂不支持（模拟仓）")
-	return nil
-}
-
-// SetTakeProfit 设置止盈单（模拟仓中暂不支持）
-func (t *PaperTrader) SetTakeProfit(symbol string, positionSide string, quantity, takeProfitPrice float64) error {
-	logger.Infof("📝 [Paper Trading] 止盈单功能暂不支持（模拟仓）")
-	return nil
-}
-
-// CancelStopLossOrders 取消止损单
-func (t *PaperTrader) CancelStopLossOrders(symbol string) error {
-	return nil
-}
-
-// CancelTakeProfitOrders 取消止盈单
-func (t *PaperTrader) CancelTakeProfitOrders(symbol string) error {
-	return nil
-}
-
-// CancelAllOrders 取消所有挂单
-func (t *PaperTrader) CancelAllOrders(symbol string) error {
-	return nil
-}
-
-// CancelStopOrders 取消止盈/止损单
-func (t *PaperTrader) CancelStopOrders(symbol string) error {
-	return nil
-}
-
 // FormatQuantity 格式化数量
 func (t *PaperTrader) FormatQuantity(symbol string, quantity float64) (string, error) {
 	// 简化处理，保留6位小数
@@ -2,11 +2,15 @@ package trader
 
 import (
 	"aspen/config"
+	"aspen/decision"
+	"aspen/logger"
+	"aspen/market"
 	"encoding/json"
 	"os"
 	"path/filepath"
 	"testing"
 
+	"github.com/agiledragon/gomonkey/v2"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -81,13 +85,13 @@ func TestSetMarginMode_NoPanic(t *testing.T) {
 }
 
 // ============================================================
-// Stop-loss / take-profit stubs — should not error
+// Stop-loss / take-profit without a position — set errors, cancel is a no-op
 // ============================================================
 
-func TestStopLossAndTakeProfitStubs(t *testing.T) {
+func TestStopLossAndTakeProfit_NoPosition(t *testing.T) {
 	pt, _ := NewPaperTrader(1000)
-	assert.NoError(t, pt.SetStopLoss("BTCUSDT", "LONG", 1, 90000))
-	assert.NoError(t, pt.SetTakeProfit("BTCUSDT", "LONG", 1, 110000))
+	assert.Error(t, pt.SetStopLoss("BTCUSDT", "LONG", 1, 90000))
+	assert.Error(t, pt.SetTakeProfit("BTCUSDT", "LONG", 1, 110000))
 	assert.NoError(t, pt.CancelStopLossOrders("BTCUSDT"))
 	assert.NoError(t, pt.CancelTakeProfitOrders("BTCUSDT"))
 	assert.NoError(t, pt.CancelAllOrders("BTCUSDT"))
@@ -167,7 +171,7 @@ func TestSaveState_LoadState_RoundTrip(t *testing.T) {
 	traderID := "test-trader-1"
 
 	// Create a paper trader with DB
-	pt, err := NewPaperTraderWithDB(5000, database, traderID)
+	pt, err := NewPaperTraderWithDB(5000, MarginAssetUSDT, database, traderID)
 	require.NoError(t, err)
 	assert.Equal(t, 5000.0, pt.balance)
 
@@ -186,7 +190,7 @@ func TestSaveState_LoadState_RoundTrip(t *testing.T) {
 	pt.SaveState()
 
 	// Create a new trader with same DB — should restore state
-	pt2, err := NewPaperTraderWithDB(5000, database, traderID)
+	pt2, err := NewPaperTraderWithDB(5000, MarginAssetUSDT, database, traderID)
 	require.NoError(t, err)
 	assert.InDelta(t, 4500.0, pt2.balance, 0.01)
 	assert.InDelta(t, -200.0, pt2.realizedPnL, 0.01)
@@ -204,7 +208,7 @@ func TestNewPaperTraderWithDB_FreshStart(t *testing.T) {
 	defer database.Close()
 
 	// No saved state — should use initial balance
-	pt, err := NewPaperTraderWithDB(8000, database, "brand-new-trader")
+	pt, err := NewPaperTraderWithDB(8000, MarginAssetUSDT, database, "brand-new-trader")
 	require.NoError(t, err)
 	assert.Equal(t, 8000.0, pt.balance)
 	assert.Equal(t, 8000.0, pt.initialBalance)
@@ -214,7 +218,7 @@ func TestNewPaperTraderWithDB_FreshStart(t *testing.T) {
 
 func TestNewPaperTraderWithDB_NilDB(t *testing.T) {
 	// Should work without DB (no persistence)
-	pt, err := NewPaperTraderWithDB(3000, nil, "no-db-trader")
+	pt, err := NewPaperTraderWithDB(3000, MarginAssetUSDT, nil, "no-db-trader")
 	require.NoError(t, err)
 	assert.Equal(t, 3000.0, pt.balance)
 }
@@ -224,7 +228,7 @@ func TestNewPaperTraderWithDB_EmptyTraderID(t *testing.T) {
 	defer database.Close()
 
 	// Empty traderID — should still work, just no persistence
-	pt, err := NewPaperTraderWithDB(2000, database, "")
+	pt, err := NewPaperTraderWithDB(2000, MarginAssetUSDT, database, "")
 	require.NoError(t, err)
 	assert.Equal(t, 2000.0, pt.balance)
 }
@@ -233,7 +237,7 @@ func TestNewPaperTraderWithDB_RejectsZeroBalance(t *testing.T) {
 	database, _ := createTempDB(t)
 	defer database.Close()
 
-	pt, err := NewPaperTraderWithDB(0, database, "zero-bal")
+	pt, err := NewPaperTraderWithDB(0, MarginAssetUSDT, database, "zero-bal")
 	assert.Error(t, err)
 	assert.Nil(t, pt)
 }
@@ -249,7 +253,7 @@ func TestSaveState_MultiplePositions(t *testing.T) {
 	defer database.Close()
 
 	traderID := "multi-pos-trader"
-	pt, err := NewPaperTraderWithDB(10000, database, traderID)
+	pt, err := NewPaperTraderWithDB(10000, MarginAssetUSDT, database, traderID)
 	require.NoError(t, err)
 
 	pt.positions["BTCUSDT_LONG"] = &Position{
@@ -264,7 +268,7 @@ func TestSaveState_MultiplePositions(t *testing.T) {
 	pt.SaveState()
 
 	// Reload
-	pt2, err := NewPaperTraderWithDB(10000, database, traderID)
+	pt2, err := NewPaperTraderWithDB(10000, MarginAssetUSDT, database, traderID)
 	require.NoError(t, err)
 	assert.InDelta(t, 8000, pt2.balance, 0.01)
 	assert.InDelta(t, 150, pt2.realizedPnL, 0.01)
@@ -312,13 +316,13 @@ func TestDBPersistence_EmptyPositions(t *testing.T) {
 	defer database.Close()
 
 	traderID := "empty-pos-trader"
-	pt, _ := NewPaperTraderWithDB(5000, database, traderID)
+	pt, _ := NewPaperTraderWithDB(5000, MarginAssetUSDT, database, traderID)
 	pt.balance = 4000
 	pt.realizedPnL = 100
 	// No positions
 	pt.SaveState()
 
-	pt2, err := NewPaperTraderWithDB(5000, database, traderID)
+	pt2, err := NewPaperTraderWithDB(5000, MarginAssetUSDT, database, traderID)
 	require.NoError(t, err)
 	assert.InDelta(t, 4000, pt2.balance, 0.01)
 	assert.Len(t, pt2.positions, 0)
@@ -331,7 +335,7 @@ func TestDBPersistence_OverwriteState(t *testing.T) {
 	traderID := "overwrite-trader"
 
 	// First save
-	pt, _ := NewPaperTraderWithDB(5000, database, traderID)
+	pt, _ := NewPaperTraderWithDB(5000, MarginAssetUSDT, database, traderID)
 	pt.balance = 4000
 	pt.SaveState()
 
@@ -339,7 +343,7 @@ func TestDBPersistence_OverwriteState(t *testing.T) {
 	pt.balance = 3000
 	pt.SaveState()
 
-	pt2, err := NewPaperTraderWithDB(5000, database, traderID)
+	pt2, err := NewPaperTraderWithDB(5000, MarginAssetUSDT, database, traderID)
 	require.NoError(t, err)
 	assert.InDelta(t, 3000, pt2.balance, 0.01, "should have latest saved state")
 }
@@ -352,3 +356,276 @@ func TestDBPersistence_FileExists(t *testing.T) {
 	_, err := os.Stat(dbPath)
 	assert.NoError(t, err, "database file should exist")
 }
+
+// ============================================================
+// Price impact model
+// ============================================================
+
+func newPriceImpactTrader(t *testing.T, volume float64) *PaperTrader {
+	t.Helper()
+	pt, err := NewPaperTrader(10000)
+	require.NoError(t, err)
+	pt.SetPriceImpact(PriceImpactConfig{Enabled: true, Coefficient: 0.1, MaxImpactPct: 2})
+	pt.volumeFn = func(symbol string, window int) (float64, error) {
+		return volume, nil
+	}
+	return pt
+}
+
+func TestApplyPriceImpact_LargeOrderGetsWorseFill(t *testing.T) {
+	// 近期成交额 1,000,000 USDT
+	pt := newPriceImpactTrader(t, 1_000_000)
+
+	small := pt.applyPriceImpact("BTCUSDT", 0.01, 50000, true) // 500 U
+	large := pt.applyPriceImpact("BTCUSDT", 2, 50000, true)    // 100,000 U = 10% 成交额
+	assert.Greater(t, large, small)
+	assert.InDelta(t, 50000*1.01, large, 0.01)    // 0.1 × 10% = 1%
+	assert.InDelta(t, 50000*1.00005, small, 0.01) // 0.1 × 0.05% = 0.005%
+
+	// 卖出方向成交价下移
+	smallSell := pt.applyPriceImpact("BTCUSDT", 0.01, 50000, false)
+	largeSell := pt.applyPriceImpact("BTCUSDT", 2, 50000, false)
+	assert.Less(t, largeSell, smallSell)
+	assert.Less(t, smallSell, 50000.0)
+}
+
+func TestApplyPriceImpact_CappedAtMax(t *testing.T) {
+	pt := newPriceImpactTrader(t, 1000)
+	price := pt.applyPriceImpact("BTCUSDT", 10, 50000, true)
+	assert.InDelta(t, 50000*1.02, price, 0.01)
+}
+
+func TestApplyPriceImpact_DisabledByDefault(t *testing.T) {
+	pt, err := NewPaperTrader(10000)
+	require.NoError(t, err)
+	pt.volumeFn = func(symbol string, window int) (float64, error) {
+		return 1000, nil
+	}
+	assert.Equal(t, 50000.0, pt.applyPriceImpact("BTCUSDT", 10, 50000, true))
+}
+
+func TestApplyPriceImpact_NoVolumeKeepsPrice(t *testing.T) {
+	pt := newPriceImpactTrader(t, 0)
+	assert.Equal(t, 50000.0, pt.applyPriceImpact("BTCUSDT", 10, 50000, true))
+}
+
+// ============================================================
+// Balance math — reserved margin, cross/isolated drawdown
+// ============================================================
+
+// newPricedPaperTrader 创建价格可控的模拟仓（prices 可在测试中修改）
+func newPricedPaperTrader(t *testing.T, initial float64, prices map[string]float64) *PaperTrader {
+	t.Helper()
+	pt, err := NewPaperTrader(initial)
+	require.NoError(t, err)
+	pt.priceFn = func(symbol string) (float64, error) {
+		return prices[symbol], nil
+	}
+	return pt
+}
+
+// assertCents 断言金额精确到分
+func assertCents(t *testing.T, expected float64, actual interface{}, name string) {
+	t.Helper()
+	assert.InDelta(t, expected, actual.(float64), 0.005, name)
+}
+
+func TestGetBalance_DrawdownMatchesHandComputed(t *testing.T) {
+	prices := map[string]float64{"BTCUSDT": 50000, "ETHUSDT": 3000}
+	pt := newPricedPaperTrader(t, 10000, prices)
+
+	// 全仓多 BTC 0.2 @ 50000，10x：名义 10000，IM 1000，手续费 4 → free 8996
+	_, err := pt.OpenLong("BTCUSDT", 0.2, 10)
+	require.NoError(t, err)
+	// 开仓后调整杠杆不改变已占用的保证金
+	require.NoError(t, pt.SetLeverage("BTCUSDT", 20))
+
+	// 逐仓空 ETH 2 @ 3000，5x：名义 6000，IM 1200，手续费 2.4 → free 7793.6
+	require.NoError(t, pt.SetMarginMode("ETHUSDT", false))
+	_, err = pt.OpenShort("ETHUSDT", 2, 5)
+	require.NoError(t, err)
+	assert.Error(t, pt.SetMarginMode("ETHUSDT", true), "有持仓时不能切换仓位模式")
+
+	// 行情反向：BTC 45000，ETH 3500
+	// BTC uPnL = (45000-50000)×0.2 = -1000，MM = 0.2×45000×0.004 = 36
+	// ETH uPnL = (3000-3500)×2 = -1000，逐仓桶 1200-1000 = 200 ≥ 0 不影响账户
+	// available = 7793.6 + (-1000 - 36) + 0 = 6757.60
+	// wallet = 7793.6 + 1000 + 1200 = 9993.60，equity = 9993.6 - 2000 = 7993.60
+	prices["BTCUSDT"], prices["ETHUSDT"] = 45000, 3500
+	balance, err := pt.GetBalance()
+	require.NoError(t, err)
+	assertCents(t, 6757.60, balance["availableBalance"], "available")
+	assertCents(t, 9993.60, balance["totalWalletBalance"], "wallet")
+	assertCents(t, -2000.00, balance["totalUnrealizedProfit"], "uPnL")
+	assertCents(t, 2200.00, balance["totalInitialMargin"], "initial margin")
+	assertCents(t, 36.00, balance["totalMaintMargin"], "maint margin")
+	assertCents(t, 0, balance["marginDeficit"], "deficit")
+	assertCents(t, 7993.60, balance["assetBalances"].([]AssetBalance)[0].USDValue, "equity")
+
+	// 继续下跌：BTC 10000，ETH 4200
+	// BTC uPnL = -40000×0.2 = -8000，MM = 0.2×10000×0.004 = 8；全仓净值 7793.6 + 1000 - 8000 = 793.6 > 8 不强平
+	// ETH uPnL = -1200×2 = -2400，逐仓 1200-2400 < MM，逐仓强平，亏损以保证金 1200 为限
+	// available = 7793.6 - 8000 - 8 = -214.40（不截断）
+	prices["BTCUSDT"], prices["ETHUSDT"] = 10000, 4200
+	balance, err = pt.GetBalance()
+	require.NoError(t, err)
+	assertCents(t, -214.40, balance["availableBalance"], "available")
+	assertCents(t, 214.40, balance["marginDeficit"], "deficit")
+	assertCents(t, 8793.60, balance["totalWalletBalance"], "wallet")
+	assert.NotContains(t, pt.positions, "ETHUSDT_SHORT", "逐仓持仓已强平")
+
+	// 空闲余额仍有 7793.6，但可用余额为负时拒绝开新仓
+	_, err = pt.OpenLong("SOLUSDT", 1, 5)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "余额不足")
+
+	// 回到 45000 部分平仓 0.1 BTC：释放 IM 1000×0.1/0.2 = 500（而非按 20x 重算的 250），盈亏 -500
+	// free = 7793.6 + 500 - 500 = 7793.6，剩余 IM 500
+	prices["BTCUSDT"], prices["ETHUSDT"] = 45000, 3000
+	_, err = pt.CloseLong("BTCUSDT", 0.1)
+	require.NoError(t, err)
+	assertCents(t, 7793.60, pt.balance, "free")
+	assertCents(t, 500.00, pt.positions["BTCUSDT_LONG"].Margin, "remaining margin")
+
+	// 全部平仓后钱包余额 = 初始余额 - 手续费 + 已实现盈亏 = 10000 - 6.4 - 500 - 500 - 1200 = 7793.60
+	_, err = pt.CloseLong("BTCUSDT", 0)
+	require.NoError(t, err)
+	balance, err = pt.GetBalance()
+	require.NoError(t, err)
+	assertCents(t, 7793.60, balance["totalWalletBalance"], "wallet")
+	assertCents(t, 7793.60, balance["availableBalance"], "available")
+	assertCents(t, -2200.00, pt.realizedPnL, "realized")
+}
+
+// TestLiquidation_IsolatedVsCross 测试逐仓持仓按自身保证金单独强平，全仓持仓由整个账户余额承担亏损
+func TestLiquidation_IsolatedVsCross(t *testing.T) {
+	// 同样的持仓（多 BTC 0.1 @ 50000，10x，IM 500，手续费 2 → free 9498）分别用逐仓和全仓
+	// 逐仓强平价 = (50000×0.1 - 500) / (0.1×0.996) ≈ 45180.72
+	// 全仓强平价 = (50000×0.1 - 500 - 9498) / (0.1×0.996) < 0，由全部余额承担，不会强平
+	for _, tt := range []struct {
+		name           string
+		isolated       bool
+		wantLiqPrice   float64
+		wantLiquidated bool
+		wantRealized   float64
+		wantWallet     float64
+	}{
+		{"逐仓按保证金强平", true, 45180.72, true, -500, 9498},
+		{"全仓共享余额不强平", false, 0, false, 0, 9998},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			prices := map[string]float64{"BTCUSDT": 50000}
+			pt := newPricedPaperTrader(t, 10000, prices)
+			require.NoError(t, pt.SetMarginMode("BTCUSDT", !tt.isolated))
+			_, err := pt.OpenLong("BTCUSDT", 0.1, 10)
+			require.NoError(t, err)
+
+			positions, err := pt.GetPositions()
+			require.NoError(t, err)
+			require.Len(t, positions, 1)
+			assertCents(t, tt.wantLiqPrice, positions[0]["liquidationPrice"], "liquidation price")
+
+			// 跌破逐仓强平价：逐仓亏损 600 > 保证金 500
+			prices["BTCUSDT"] = 44000
+			positions, err = pt.GetPositions()
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantLiquidated, len(positions) == 0)
+			assertCents(t, tt.wantRealized, pt.realizedPnL, "realized")
+
+			balance, err := pt.GetBalance()
+			require.NoError(t, err)
+			assertCents(t, tt.wantWallet, balance["totalWalletBalance"], "wallet")
+		})
+	}
+}
+
+// TestLiquidation_IsolatedIndependentOfCross 测试逐仓强平不影响全仓持仓，全仓亏损耗尽账户余额时强平全部全仓持仓
+func TestLiquidation_IsolatedIndependentOfCross(t *testing.T) {
+	prices := map[string]float64{"BTCUSDT": 50000, "ETHUSDT": 3000}
+	pt := newPricedPaperTrader(t, 2000, prices)
+
+	// 全仓多 BTC 0.1 @ 50000，10x：IM 500，手续费 2 → free 1498
+	_, err := pt.OpenLong("BTCUSDT", 0.1, 10)
+	require.NoError(t, err)
+	// 逐仓多 ETH 1 @ 3000，10x：IM 300，手续费 1.2 → free 1196.8
+	require.NoError(t, pt.SetMarginMode("ETHUSDT", false))
+	_, err = pt.OpenLong("ETHUSDT", 1, 10)
+	require.NoError(t, err)
+
+	// ETH 2600：逐仓 300 - 400 < MM，单独强平；BTC 不受影响
+	prices["ETHUSDT"] = 2600
+	positions, err := pt.GetPositions()
+	require.NoError(t, err)
+	require.Len(t, positions, 1)
+	assert.Equal(t, "BTCUSDT", positions[0]["symbol"])
+	assertCents(t, 1196.80, pt.balance, "free")
+	assertCents(t, -300.00, pt.realizedPnL, "realized")
+
+	// BTC 34000：全仓净值 1196.8 + 500 + (34000-50000)×0.1 = 96.8 > MM 13.6，不强平
+	prices["BTCUSDT"] = 34000
+	positions, err = pt.GetPositions()
+	require.NoError(t, err)
+	require.Len(t, positions, 1, "全仓净值仍高于维持保证金")
+	liqPrice := positions[0]["liquidationPrice"].(float64)
+	assert.Less(t, liqPrice, 34000.0)
+
+	// 跌破强平价：全仓持仓强平，亏损由账户余额承担
+	prices["BTCUSDT"] = liqPrice - 10
+	positions, err = pt.GetPositions()
+	require.NoError(t, err)
+	assert.Empty(t, positions)
+	pnl := (liqPrice - 10 - 50000) * 0.1
+	assertCents(t, 1196.80+500+pnl, pt.balance, "free")
+	assertCents(t, -300+pnl, pt.realizedPnL, "realized")
+}
+
+// TestCloseIsolated_LossCappedAtMargin 测试逐仓持仓平仓时亏损以保证金为限（价格跳空越过强平价）
+func TestCloseIsolated_LossCappedAtMargin(t *testing.T) {
+	prices := map[string]float64{"SOLUSDT": 100}
+	pt := newPricedPaperTrader(t, 1000, prices)
+	require.NoError(t, pt.SetMarginMode("SOLUSDT", false))
+	_, err := pt.OpenShort("SOLUSDT", 10, 5) // IM 200，手续费 0.4 → free 799.6
+	require.NoError(t, err)
+
+	prices["SOLUSDT"] = 150 // 亏损 500 > 保证金 200
+	order, err := pt.CloseShort("SOLUSDT", 0)
+	require.NoError(t, err)
+	assertCents(t, -200.00, order["pnl"], "pnl")
+	assertCents(t, 799.60, pt.balance, "free")
+}
+
+func TestNewPaperTraderWithDB_BackfillsLegacyMargin(t *testing.T) {
+	db, _ := createTempDB(t)
+	defer db.Close()
+	positions := `{"BTCUSDT_LONG":{"symbol":"BTCUSDT","side":"LONG","quantity":0.2,"entry_price":50000,"leverage":10}}`
+	require.NoError(t, db.SavePaperTraderState("legacy", 10000, 8996, 0, positions))
+
+	pt, err := NewPaperTraderWithDB(10000, MarginAssetUSDT, db, "legacy")
+	require.NoError(t, err)
+	assert.Equal(t, 1000.0, pt.positions["BTCUSDT_LONG"].Margin)
+	assert.False(t, pt.positions["BTCUSDT_LONG"].Isolated)
+}
+
+// TestOpenRejectedWhenAvailableNegative 测试全仓浮亏导致可用余额为负时，风控拒绝开仓而不是按负余额缩小仓位
+func TestOpenRejectedWhenAvailableNegative(t *testing.T) {
+	patches := gomonkey.ApplyFunc(market.Get, func(symbol string) (*market.Data, error) {
+		return &market.Data{Symbol: symbol, CurrentPrice: 100}, nil
+	})
+	defer patches.Reset()
+
+	prices := map[string]float64{"BTCUSDT": 50000}
+	pt := newPricedPaperTrader(t, 1000, prices)
+	_, err := pt.OpenLong("BTCUSDT", 0.1, 10) // IM 500，手续费 2 → free 498
+	require.NoError(t, err)
+	prices["BTCUSDT"] = 44000 // uPnL -600，MM 17.6 → available = 498 - 617.6 = -119.60
+
+	at := newJournalTestTrader(t.TempDir(), pt)
+	at.config.IsCrossMargin = true
+	d := &decision.Decision{Symbol: "SOLUSDT", Action: "open_long", Leverage: 5, PositionSizeUSD: 100}
+	err = at.executeOpenLongWithRecord(d, &logger.DecisionAction{})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "-119.60")
+	assert.Contains(t, err.Error(), "暂停开仓")
+	assert.Equal(t, 100.0, d.PositionSizeUSD, "不按负余额反推缩小仓位")
+	assert.Len(t, pt.positions, 1)
+}
@@ -0,0 +1,204 @@
+package trader
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"aspen/decision"
+	"aspen/logger"
+)
+
+// closedPositionIDLimit 保留的最近已平仓持仓ID数量（用于区分"已平仓"和"不存在"）
+const closedPositionIDLimit = 200
+
+// positionIDBook 持仓ID登记（零值可用，由 positionMetaMutex 保护）
+type positionIDBook struct {
+	restored map[string]string // symbol_side -> 重启前开仓的持仓ID（从决策日志恢复，首次同步持仓时使用）
+	closed   []string          // 最近已平仓的持仓ID（从旧到新）
+}
+
+// markClosed 记录已平仓的持仓ID
+func (b *positionIDBook) markClosed(id string) {
+	if id == "" || b.isClosed(id) {
+		return
+	}
+	b.closed = append(b.closed, id)
+	if len(b.closed) > closedPositionIDLimit {
+		b.closed = b.closed[len(b.closed)-closedPositionIDLimit:]
+	}
+}
+
+// isClosed 持仓ID是否已平仓
+func (b *positionIDBook) isClosed(id string) bool {
+	for _, closed := range b.closed {
+		if closed == id {
+			return true
+		}
+	}
+	return false
+}
+
+// restoreFromRecords 从决策日志恢复持仓ID：仍未平仓的ID在重启后继续沿用，已平仓的ID记入已平仓列表
+func (b *positionIDBook) restoreFromRecords(records []*logger.DecisionRecord) {
+	if b.restored == nil {
+		b.restored = make(map[string]string)
+	}
+	for _, record := range records {
+		for _, action := range record.Decisions {
+			if !action.Success {
+				continue
+			}
+			switch action.Action {
+			case "open_long", "open_short":
+				if action.PositionID != "" {
+					b.restored[positionMetaKey(action.Symbol, strings.TrimPrefix(action.Action, "open_"))] = action.PositionID
+				}
+			case "close_long", "close_short":
+				key := positionMetaKey(action.Symbol, strings.TrimPrefix(action.Action, "close_"))
+				id := action.PositionID
+				if id == "" {
+					id = b.restored[key]
+				}
+				if b.restored[key] == id {
+					delete(b.restored, key)
+				}
+				b.markClosed(id)
+			}
+		}
+	}
+}
+
+// newPositionID 生成持仓ID（如 BTC-L-260115133000），与已有ID冲突时追加序号
+func newPositionID(symbol, side string, openedAt time.Time, exists func(id string) bool) string {
+	base := strings.TrimSuffix(symbol, "USDT")
+	sideCode := "L"
+	if strings.EqualFold(side, "short") {
+		sideCode = "S"
+	}
+	id := fmt.Sprintf("%s-%s-%s", base, sideCode, openedAt.UTC().Format("060102150405"))
+	candidate := id
+	for n := 2; exists(candidate); n++ {
+		candidate = fmt.Sprintf("%s-%d", id, n)
+	}
+	return candidate
+}
+
+// splitPositionMetaKey 拆分持仓元数据键为币种和方向
+func splitPositionMetaKey(key string) (symbol, side string) {
+	i := strings.LastIndex(key, "_")
+	if i < 0 {
+		return key, ""
+	}
+	return key[:i], key[i+1:]
+}
+
+// allocatePositionIDLocked 为持仓分配ID（调用方已持有 positionMetaMutex）
+// 优先沿用从决策日志恢复的ID，保证重启前后同一持仓的ID不变
+func (at *AutoTrader) allocatePositionIDLocked(symbol, side string, openedAt time.Time) string {
+	key := positionMetaKey(symbol, side)
+	if id, ok := at.positionIDs.restored[key]; ok {
+		delete(at.positionIDs.restored, key)
+		return id
+	}
+	return newPositionID(symbol, side, openedAt, func(id string) bool {
+		if at.positionIDs.isClosed(id) {
+			return true
+		}
+		for _, meta := range at.positionMeta {
+			if meta.PositionID == id {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// positionIDFor 获取当前持仓的ID，未知时返回空
+func (at *AutoTrader) positionIDFor(symbol, side string) string {
+	at.positionMetaMutex.RLock()
+	defer at.positionMetaMutex.RUnlock()
+	if meta, ok := at.positionMeta[positionMetaKey(symbol, side)]; ok {
+		return meta.PositionID
+	}
+	return ""
+}
+
+// closePositionMeta 全部平仓后立即移除持仓元数据，并记录已平仓的持仓ID，返回该ID
+func (at *AutoTrader) closePositionMeta(symbol, side string) string {
+	at.positionMetaMutex.Lock()
+	defer at.positionMetaMutex.Unlock()
+	key := positionMetaKey(symbol, side)
+	meta, ok := at.positionMeta[key]
+	if !ok {
+		return ""
+	}
+	delete(at.positionMeta, key)
+	at.positionIDs.markClosed(meta.PositionID)
+	at.positionViewCache = nil
+	return meta.PositionID
+}
+
+// resolvePositionRef 校验决策中指定的持仓ID：必须是本交易员当前持有的持仓，且与决策的币种和方向一致
+// 未指定币种时按持仓ID补全；未指定持仓ID时保持按 symbol+方向 处理
+func (at *AutoTrader) resolvePositionRef(d *decision.Decision, actionRecord *logger.DecisionAction) error {
+	if d.PositionID == "" {
+		return nil
+	}
+	actionRecord.PositionID = d.PositionID
+
+	at.positionMetaMutex.RLock()
+	defer at.positionMetaMutex.RUnlock()
+
+	for key, meta := range at.positionMeta {
+		if meta.PositionID != d.PositionID {
+			continue
+		}
+		symbol, side := splitPositionMetaKey(key)
+		if d.Symbol == "" {
+			d.Symbol = symbol
+			actionRecord.Symbol = symbol
+		} else if d.Symbol != symbol {
+			return fmt.Errorf("持仓 %s 属于 %s，与决策币种 %s 不一致", d.PositionID, symbol, d.Symbol)
+		}
+		if wantSide := strings.TrimPrefix(d.Action, "close_"); wantSide != d.Action && wantSide != side {
+			return fmt.Errorf("持仓 %s 为%s仓，不能执行 %s", d.PositionID, side, d.Action)
+		}
+		return nil
+	}
+
+	if at.positionIDs.isClosed(d.PositionID) {
+		return fmt.Errorf("持仓 %s 已平仓", d.PositionID)
+	}
+	return fmt.Errorf("持仓ID %s 不存在或不属于该交易员", d.PositionID)
+}
+
+// referencedSide 决策通过持仓ID指定的持仓方向，未指定或未找到时返回空
+func (at *AutoTrader) referencedSide(d *decision.Decision) string {
+	if d.PositionID == "" {
+		return ""
+	}
+	at.positionMetaMutex.RLock()
+	defer at.positionMetaMutex.RUnlock()
+	for key, meta := range at.positionMeta {
+		if meta.PositionID == d.PositionID {
+			_, side := splitPositionMetaKey(key)
+			return side
+		}
+	}
+	return ""
+}
+
+// findTargetPosition 查找决策要操作的持仓（指定持仓ID时按其方向匹配，用于区分同币种的多空持仓）
+func (at *AutoTrader) findTargetPosition(positions []map[string]interface{}, d *decision.Decision) map[string]interface{} {
+	side := at.referencedSide(d)
+	for _, pos := range positions {
+		symbol, _ := pos["symbol"].(string)
+		posAmt, _ := pos["positionAmt"].(float64)
+		posSide, _ := pos["side"].(string)
+		if symbol == d.Symbol && posAmt != 0 && (side == "" || strings.EqualFold(posSide, side)) {
+			return pos
+		}
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package trader
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"aspen/logger"
+)
+
+// ReconcilePositions 按交易所实际持仓覆盖本地持仓状态（持仓元数据、持仓ID、首次出现时间、最高收益和开仓意图），
+// 返回发现的差异说明。本地记录有而交易所没有的持仓视为已平仓，交易所有而本地没有的持仓补齐记录。
+// 模拟仓的持仓就是本地状态，不需要对账
+func (at *AutoTrader) ReconcilePositions() ([]string, error) {
+	if _, ok := at.trader.(*PaperTrader); ok {
+		return nil, nil
+	}
+
+	positions, err := at.trader.GetPositions()
+	if err != nil {
+		return nil, fmt.Errorf("获取交易所持仓失败: %w", err)
+	}
+	exchange := make(map[string]bool, len(positions))
+	for _, pos := range positions {
+		symbol, _ := pos["symbol"].(string)
+		side, _ := pos["side"].(string)
+		amount, _ := pos["positionAmt"].(float64)
+		if symbol == "" || side == "" || math.Abs(amount) == 0 {
+			continue
+		}
+		exchange[positionMetaKey(symbol, side)] = true
+	}
+
+	at.positionMetaMutex.Lock()
+	local := make(map[string]bool)
+	for key := range at.positionMeta {
+		local[key] = true
+	}
+	for key := range at.positionIDs.restored {
+		local[key] = true
+	}
+	for key := range at.positionFirstSeenTime {
+		local[key] = true
+	}
+
+	var discrepancies []string
+	for key := range local {
+		if exchange[key] {
+			continue
+		}
+		discrepancies = append(discrepancies, fmt.Sprintf("%s 本地记录有持仓，交易所无持仓（视为已平仓）", key))
+		if id, ok := at.positionIDs.restored[key]; ok {
+			at.positionIDs.markClosed(id)
+			delete(at.positionIDs.restored, key)
+		}
+		delete(at.positionFirstSeenTime, key)
+		symbol, side := splitPositionMetaKey(key)
+		at.intents.clearPosition(at.id, symbol, side)
+	}
+
+	now := time.Now().UnixMilli()
+	current := make(map[string]int64, len(exchange))
+	for key := range exchange {
+		if !local[key] {
+			discrepancies = append(discrepancies, fmt.Sprintf("%s 交易所有持仓，本地无记录（已补齐）", key))
+		}
+		if _, ok := at.positionFirstSeenTime[key]; !ok {
+			at.positionFirstSeenTime[key] = now
+		}
+		current[key] = at.positionFirstSeenTime[key]
+	}
+	at.positionViewCache = nil
+	at.positionMetaMutex.Unlock()
+
+	// 持仓元数据：补齐交易所持仓（沿用恢复的持仓ID），清理已不存在的持仓
+	at.syncPositionMeta(current)
+	at.clearStalePeakPnL(exchange)
+
+	sort.Strings(discrepancies)
+	for _, d := range discrepancies {
+		logger.Warnf("⚠️ [%s] 持仓对账: %s", at.name, d)
+	}
+	if len(discrepancies) == 0 {
+		logger.Infof("✓ [%s] 持仓对账完成：本地状态与交易所一致（%d 个持仓）", at.name, len(exchange))
+	} else {
+		logger.Warnf("⚠️ [%s] 持仓对账完成：%d 处差异，已按交易所持仓更新本地状态", at.name, len(discrepancies))
+	}
+	return discrepancies, nil
+}
+
+// clearStalePeakPnL 清理交易所已无持仓的最高收益缓存（键为 symbol_side 或 symbol）
+func (at *AutoTrader) clearStalePeakPnL(exchange map[string]bool) {
+	symbols := make(map[string]bool, len(exchange))
+	for key := range exchange {
+		symbol, _ := splitPositionMetaKey(key)
+		symbols[symbol] = true
+	}
+
+	at.peakPnLCacheMutex.Lock()
+	defer at.peakPnLCacheMutex.Unlock()
+	for key := range at.peakPnLCache {
+		if exchange[key] || symbols[key] {
+			continue
+		}
+		delete(at.peakPnLCache, key)
+	}
+}
@@ -0,0 +1,81 @@
+package trader
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestReconcilePositions_OverwritesLocalState 测试重启后本地持仓状态按交易所实际持仓覆盖：
+// 交易所已没有的持仓被清理，交易所新出现的持仓补齐记录，两边一致的持仓沿用恢复的持仓ID
+func TestReconcilePositions_OverwritesLocalState(t *testing.T) {
+	exchange := &MockTrader{positions: []map[string]interface{}{
+		{"symbol": "ETHUSDT", "side": "short", "positionAmt": -2.0, "entryPrice": 3000.0},
+		{"symbol": "SOLUSDT", "side": "long", "positionAmt": 10.0, "entryPrice": 150.0},
+		{"symbol": "XRPUSDT", "side": "long", "positionAmt": 0.0, "entryPrice": 0.5},
+	}}
+	at := newJournalTestTrader(t.TempDir(), exchange)
+
+	// 重启前的本地状态：BTC 多仓在停机期间已被平掉，ETH 空仓从决策日志恢复了持仓ID
+	openedAt := time.Now().Add(-time.Hour)
+	at.positionMeta = map[string]*PositionMeta{
+		"BTCUSDT_long": {PositionID: "BTC-L-1", OpenedAt: openedAt},
+	}
+	at.positionIDs.restored = map[string]string{"ETHUSDT_short": "ETH-S-1"}
+	at.positionFirstSeenTime["BTCUSDT_long"] = openedAt.UnixMilli()
+	at.peakPnLCache["BTCUSDT_long"] = 12
+	at.peakPnLCache["ETHUSDT_short"] = 5
+	btcIntent := intentKey(at.id, "BTCUSDT", "long", 500, 50000)
+	at.intents.record(btcIntent, openedAt)
+
+	discrepancies, err := at.ReconcilePositions()
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"BTCUSDT_long 本地记录有持仓，交易所无持仓（视为已平仓）",
+		"SOLUSDT_long 交易所有持仓，本地无记录（已补齐）",
+	}, discrepancies)
+
+	require.Len(t, at.positionMeta, 2)
+	assert.Equal(t, "ETH-S-1", at.positionMeta["ETHUSDT_short"].PositionID)
+	assert.NotEmpty(t, at.positionMeta["SOLUSDT_long"].PositionID)
+	assert.NotContains(t, at.positionMeta, "BTCUSDT_long")
+	assert.True(t, at.positionIDs.isClosed("BTC-L-1"))
+	assert.Empty(t, at.positionIDs.restored)
+
+	assert.Len(t, at.positionFirstSeenTime, 2)
+	assert.Contains(t, at.positionFirstSeenTime, "ETHUSDT_short")
+	assert.Contains(t, at.positionFirstSeenTime, "SOLUSDT_long")
+	assert.Equal(t, map[string]float64{"ETHUSDT_short": 5}, at.peakPnLCache)
+	_, ok := at.intents.lastExecuted(btcIntent)
+	assert.False(t, ok, "交易所已平仓的持仓不应再阻止相同开仓")
+
+	// 再次对账没有差异
+	discrepancies, err = at.ReconcilePositions()
+	require.NoError(t, err)
+	assert.Empty(t, discrepancies)
+}
+
+// TestReconcilePositions_PaperNoop 测试模拟仓交易员不对账，本地状态保持不变
+func TestReconcilePositions_PaperNoop(t *testing.T) {
+	paper, err := NewPaperTrader(1000)
+	require.NoError(t, err)
+	at := newJournalTestTrader(t.TempDir(), paper)
+	at.positionFirstSeenTime["BTCUSDT_long"] = time.Now().UnixMilli()
+
+	discrepancies, err := at.ReconcilePositions()
+	require.NoError(t, err)
+	assert.Empty(t, discrepancies)
+	assert.Contains(t, at.positionFirstSeenTime, "BTCUSDT_long")
+}
+
+// TestReconcilePositions_ExchangeError 测试获取交易所持仓失败时返回错误且不修改本地状态
+func TestReconcilePositions_ExchangeError(t *testing.T) {
+	at := newJournalTestTrader(t.TempDir(), &MockTrader{shouldFailPositions: true})
+	at.positionFirstSeenTime["BTCUSDT_long"] = time.Now().UnixMilli()
+
+	_, err := at.ReconcilePositions()
+	assert.Error(t, err)
+	assert.Contains(t, at.positionFirstSeenTime, "BTCUSDT_long")
+}
@@ -0,0 +1,40 @@
+package trader
+
+import (
+	"fmt"
+
+	"aspen/decision"
+	"aspen/logger"
+)
+
+// resolvePositionSize 决策按净值百分比给出仓位时，以执行时的账户净值换算为金额（而不是AI看到的快照净值），
+// 并记录请求的百分比、换算所用净值和解析仓位字段时的警告
+func (at *AutoTrader) resolvePositionSize(d *decision.Decision, actionRecord *logger.DecisionAction) error {
+	if d.SizingWarning != "" {
+		logger.Warnf("  ⚠️  %s %s", d.Symbol, d.SizingWarning)
+		actionRecord.Adjustments = append(actionRecord.Adjustments, d.SizingWarning)
+	}
+	if d.PositionSizePct == 0 {
+		return nil
+	}
+	actionRecord.RequestedSizePct = d.PositionSizePct
+
+	balance, err := at.trader.GetBalance()
+	if err != nil {
+		return fmt.Errorf("获取账户余额失败: %w", err)
+	}
+	equity := accountEquity(balance)
+	actionRecord.SizingEquity = equity
+
+	size, adjustment, err := at.config.PositionSizing.SizeUSD(d.Symbol, d.PositionSizePct, equity)
+	if err != nil {
+		return fmt.Errorf("❌ %w", err)
+	}
+	if adjustment != "" {
+		logger.Warnf("  ⚠️  %s %s", d.Symbol, adjustment)
+		actionRecord.Adjustments = append(actionRecord.Adjustments, adjustment)
+	}
+	logger.Infof("  📏 %s 仓位 %.2f%% × 净值 %.2f → %.2f %s", d.Symbol, d.PositionSizePct, equity, size, at.getStablecoinUnit())
+	d.PositionSizeUSD = size
+	return nil
+}
@@ -0,0 +1,85 @@
+package trader
+
+import (
+	"testing"
+
+	"aspen/decision"
+	"aspen/logger"
+	"aspen/market"
+
+	"github.com/agiledragon/gomonkey/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestResolvePositionSize_UsesExecutionEquity 测试百分比仓位按执行时净值（而不是AI看到的快照净值）换算，
+// 并记录请求的百分比和实际下单金额
+func TestResolvePositionSize_UsesExecutionEquity(t *testing.T) {
+	patches := gomonkey.ApplyFunc(market.Get, func(symbol string) (*market.Data, error) {
+		return &market.Data{Symbol: symbol, CurrentPrice: 100.0}, nil
+	})
+	defer patches.Reset()
+
+	sizing, err := decision.NormalizePositionSizing(decision.SizingModePercent, 1, 30)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name        string
+		equity      float64 // 执行时净值
+		pct         float64
+		wantSizeUSD float64
+		wantAdjust  bool
+		wantErr     bool
+	}{
+		{"净值上涨后按新净值换算", 2000, 10, 200, false, false},
+		{"净值下跌后按新净值换算", 800, 10, 80, false, false},
+		{"低于最小开仓金额时提高到最小金额", 150, 5, 12, true, false},
+		{"最小开仓金额超过上限时拒绝", 35, 10, 0, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exchange := newClientIDMockTrader()
+			exchange.balance = map[string]interface{}{
+				"totalWalletBalance":    tt.equity,
+				"availableBalance":      tt.equity,
+				"totalUnrealizedProfit": 0.0,
+			}
+			at := newJournalTestTrader(t.TempDir(), exchange)
+			at.config.PositionSizing = sizing
+
+			// 解析时按快照净值 1200 换算的金额，执行时应被重新计算
+			d := &decision.Decision{Symbol: "SOLUSDT", Action: "open_long", Leverage: 5,
+				PositionSizePct: tt.pct, PositionSizeUSD: 1200 * tt.pct / 100, StopLoss: 90, TakeProfit: 130}
+			record := &logger.DecisionAction{Action: d.Action, Symbol: d.Symbol}
+			err := at.executeOpenLongWithRecord(d, record)
+			if tt.wantErr {
+				require.Error(t, err)
+				assert.Empty(t, exchange.orders)
+				return
+			}
+			require.NoError(t, err)
+
+			assert.InDelta(t, tt.wantSizeUSD, d.PositionSizeUSD, 1e-9)
+			assert.Equal(t, tt.pct, record.RequestedSizePct)
+			assert.Equal(t, tt.equity, record.SizingEquity)
+			assert.InDelta(t, tt.wantSizeUSD, record.RealizedSizeUSD, 1e-9)
+			assert.Equal(t, tt.wantAdjust, len(record.Adjustments) > 0, "adjustments: %v", record.Adjustments)
+		})
+	}
+}
+
+// TestResolvePositionSize_MixedFieldsWarning 测试同时给出百分比和金额时按百分比执行并记录警告
+func TestResolvePositionSize_MixedFieldsWarning(t *testing.T) {
+	exchange := newClientIDMockTrader()
+	at := newJournalTestTrader(t.TempDir(), exchange)
+	at.config.PositionSizing, _ = decision.NormalizePositionSizing(decision.SizingModePercent, 0, 0)
+
+	d := &decision.Decision{Symbol: "SOLUSDT", Action: "open_long", PositionSizePct: 5, PositionSizeUSD: 9999,
+		SizingWarning: "同时给出 position_size_pct=5.00 和 position_size_usd=9999.00，按百分比执行"}
+	record := &logger.DecisionAction{}
+	require.NoError(t, at.resolvePositionSize(d, record))
+
+	// MockTrader 默认净值 10000 + 100
+	assert.InDelta(t, 505, d.PositionSizeUSD, 1e-9)
+	assert.Equal(t, []string{d.SizingWarning}, record.Adjustments)
+}
@@ -0,0 +1,328 @@
+package trader
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"aspen/logger"
+	"aspen/market"
+)
+
+// positionViewCacheTTL 持仓详情缓存时间（前端每几秒轮询一次，避免每次都请求交易所）
+const positionViewCacheTTL = 3 * time.Second
+
+// PositionMeta 交易员为每个持仓记录的元数据（交易所接口不提供的信息）
+type PositionMeta struct {
+	PositionID string    // 持仓ID（开仓或首次发现时分配，平仓/调整决策可用其指定持仓）
+	StopLoss   float64   // 当前止损价
+	TakeProfit float64   // 当前止盈价
+	OpenCycle  int       // 开仓所在的决策周期编号
+	OpenedAt   time.Time // 开仓（或首次发现）时间
+	Note       string    // 开仓时AI给出的简短说明
+}
+
+// PositionView 持仓详情（GET /api/traders/:id/positions 返回的结构）
+type PositionView struct {
+	PositionID       string   `json:"position_id"` // 持仓ID
+	Symbol           string   `json:"symbol"`
+	Side             string   `json:"side"` // "long" or "short"
+	Quantity         float64  `json:"quantity"`
+	EntryPrice       float64  `json:"entry_price"`
+	MarkPrice        float64  `json:"mark_price"`
+	LiquidationPrice float64  `json:"liquidation_price"`
+	Leverage         int      `json:"leverage"`
+	MarginUsed       float64  `json:"margin_used"` // 持仓占用的初始保证金（交易所未提供时按标记价格/杠杆估算）
+	UnrealizedPnL    float64  `json:"unrealized_pnl"`
+	UnrealizedPnLPct float64  `json:"unrealized_pnl_pct"`
+	StopLoss         float64  `json:"stop_loss"`             // 0 表示未设置或未知
+	TakeProfit       float64  `json:"take_profit"`           // 0 表示未设置或未知
+	OpenedAt         int64    `json:"opened_at"`             // 开仓时间（毫秒），0 表示未知
+	AgeSeconds       int64    `json:"age_seconds"`           // 持仓时长（秒）
+	FundingPaid      *float64 `json:"funding_paid"`          // 开仓以来已支付的资金费（收到时为负数），交易所未提供时为 null
+	OpenCycle        int      `json:"open_cycle"`            // 开仓的决策周期编号，0 表示未知
+	PriceSource      string   `json:"price_source"`          // 标记价格来源: "cache" | "source" | "exchange"
+	Note             string   `json:"note"`                  // 开仓说明（如"RSI底背离，开多"）
+	AllocationPct    float64  `json:"allocation_pct"`        // 该币种（多空合计）占用保证金占净值百分比
+	AllocationBudget float64  `json:"allocation_budget_pct"` // 该币种资金分配上限百分比，0 表示不限制
+}
+
+// positionMetaKey 生成持仓元数据键（与 positionFirstSeenTime 保持一致）
+func positionMetaKey(symbol, side string) string {
+	return symbol + "_" + strings.ToLower(side)
+}
+
+// setPositionMeta 开仓后记录持仓元数据，返回分配的持仓ID
+func (at *AutoTrader) setPositionMeta(symbol, side string, stopLoss, takeProfit float64, note string) string {
+	at.positionMetaMutex.Lock()
+	defer at.positionMetaMutex.Unlock()
+	if at.positionMeta == nil {
+		at.positionMeta = make(map[string]*PositionMeta)
+	}
+	now := time.Now()
+	key := positionMetaKey(symbol, side)
+	delete(at.positionMeta, key)
+	meta := &PositionMeta{
+		PositionID: at.allocatePositionIDLocked(symbol, side, now),
+		StopLoss:   stopLoss,
+		TakeProfit: takeProfit,
+		OpenCycle:  at.callCount,
+		OpenedAt:   now,
+		Note:       note,
+	}
+	at.positionMeta[key] = meta
+	at.positionViewCache = nil
+	return meta.PositionID
+}
+
+// updatePositionMetaStops 调整止损/止盈后更新元数据（传 0 表示不修改）
+func (at *AutoTrader) updatePositionMetaStops(symbol, side string, stopLoss, takeProfit float64) {
+	at.positionMetaMutex.Lock()
+	defer at.positionMetaMutex.Unlock()
+	if at.positionMeta == nil {
+		at.positionMeta = make(map[string]*PositionMeta)
+	}
+	key := positionMetaKey(symbol, side)
+	meta, ok := at.positionMeta[key]
+	if !ok {
+		now := time.Now()
+		meta = &PositionMeta{PositionID: at.allocatePositionIDLocked(symbol, side, now), OpenedAt: now}
+		at.positionMeta[key] = meta
+	}
+	if stopLoss > 0 {
+		meta.StopLoss = stopLoss
+	}
+	if takeProfit > 0 {
+		meta.TakeProfit = takeProfit
+	}
+	at.positionViewCache = nil
+}
+
+// syncPositionMeta 根据当前持仓同步元数据：补齐未知持仓（如重启前开的仓）并分配持仓ID，清理已平仓的记录
+func (at *AutoTrader) syncPositionMeta(current map[string]int64) {
+	at.positionMetaMutex.Lock()
+	defer at.positionMetaMutex.Unlock()
+	if at.positionMeta == nil {
+		at.positionMeta = make(map[string]*PositionMeta)
+	}
+	for key, firstSeenMs := range current {
+		if _, ok := at.positionMeta[key]; !ok {
+			symbol, side := splitPositionMetaKey(key)
+			openedAt := time.UnixMilli(firstSeenMs)
+			at.positionMeta[key] = &PositionMeta{PositionID: at.allocatePositionIDLocked(symbol, side, openedAt), OpenedAt: openedAt}
+		}
+	}
+	for key, meta := range at.positionMeta {
+		if _, ok := current[key]; !ok {
+			at.positionIDs.markClosed(meta.PositionID)
+			delete(at.positionMeta, key)
+		}
+	}
+}
+
+// usesGlobalDataSource 交易员是否使用全局数据源（可以复用WebSocket行情缓存）
+func (at *AutoTrader) usesGlobalDataSource() bool {
+	return at.config.DataSource == "" || at.config.DataSource == market.GetCurrentDataSource()
+}
+
+// viewMarkPrice 持仓详情的标记价格：使用全局数据源时取行情缓存（来源 "cache"），
+// 交易员单独配置了数据源时通过该数据源的 REST 接口获取（来源 "source"）；取不到时返回 false，沿用交易所返回的标记价格
+func (at *AutoTrader) viewMarkPrice(symbol string) (float64, string, bool) {
+	if at.usesGlobalDataSource() {
+		if cached, ok := market.GetCachedPrice(symbol); ok {
+			return cached, "cache", true
+		}
+		return 0, "", false
+	}
+	price, err := market.NewAPIClientFor(at.config.DataSource).GetCurrentPrice(symbol)
+	if err != nil || price <= 0 {
+		logger.Warnf("⚠️  无法从 %s 获取 %s 价格，持仓详情使用交易所标记价格: %v", at.config.DataSource, symbol, err)
+		return 0, "", false
+	}
+	return price, "source", true
+}
+
+// positionFundingPaid 查询各持仓开仓以来已支付的资金费（键为 positionMetaKey），交易器不支持查询时返回空
+// 资金费流水不区分多空，对冲模式下同一币种同时持有多空仓时无法拆分，不返回该币种
+func (at *AutoTrader) positionFundingPaid(positions []map[string]interface{}) map[string]float64 {
+	funding := make(map[string]float64)
+	ft, ok := at.trader.(FundingFeeTrader)
+	if !ok {
+		return funding
+	}
+
+	sides := make(map[string][]string)
+	for _, pos := range positions {
+		symbol, _ := pos["symbol"].(string)
+		side, _ := pos["side"].(string)
+		if symbol != "" && side != "" {
+			sides[symbol] = append(sides[symbol], side)
+		}
+	}
+	for symbol, symbolSides := range sides {
+		if len(symbolSides) != 1 {
+			continue
+		}
+		key := positionMetaKey(symbol, symbolSides[0])
+		at.positionMetaMutex.RLock()
+		var openedAt time.Time
+		if meta, ok := at.positionMeta[key]; ok {
+			openedAt = meta.OpenedAt
+		}
+		at.positionMetaMutex.RUnlock()
+		if openedAt.IsZero() {
+			continue
+		}
+
+		paid, err := ft.FundingPaid(symbol, openedAt)
+		if err != nil {
+			logger.Warnf("⚠️  获取 %s 资金费失败: %v", symbol, err)
+			continue
+		}
+		funding[key] = paid
+	}
+	return funding
+}
+
+// GetPositionViews 获取持仓详情（包含止损止盈、持仓时长、开仓周期等）
+// 标记价格优先使用交易员数据源的行情，结果短暂缓存以支持高频轮询
+func (at *AutoTrader) GetPositionViews() ([]PositionView, error) {
+	at.positionMetaMutex.RLock()
+	if at.positionViewCache != nil && time.Since(at.positionViewCacheAt) < positionViewCacheTTL {
+		cached := make([]PositionView, len(at.positionViewCache))
+		copy(cached, at.positionViewCache)
+		at.positionMetaMutex.RUnlock()
+		return cached, nil
+	}
+	at.positionMetaMutex.RUnlock()
+
+	positions, err := at.trader.GetPositions()
+	if err != nil {
+		return nil, fmt.Errorf("获取持仓失败: %w", err)
+	}
+	// 在加锁前取余额和行情：模拟仓限价单成交回调会写入持仓元数据，REST 请求也不应阻塞元数据更新
+	balance, balanceErr := at.trader.GetBalance()
+	type markQuote struct {
+		price  float64
+		source string
+	}
+	marks := make(map[string]markQuote)
+	for _, pos := range positions {
+		symbol, _ := pos["symbol"].(string)
+		if _, done := marks[symbol]; done || symbol == "" {
+			continue
+		}
+		if price, source, ok := at.viewMarkPrice(symbol); ok {
+			marks[symbol] = markQuote{price: price, source: source}
+		} else {
+			marks[symbol] = markQuote{}
+		}
+	}
+	funding := at.positionFundingPaid(positions)
+
+	now := time.Now()
+	views := make([]PositionView, 0, len(positions))
+
+	at.positionMetaMutex.Lock()
+	defer at.positionMetaMutex.Unlock()
+
+	for _, pos := range positions {
+		symbol, _ := pos["symbol"].(string)
+		side, _ := pos["side"].(string)
+		if symbol == "" || side == "" {
+			continue
+		}
+		quantity, _ := pos["positionAmt"].(float64)
+		if quantity < 0 {
+			quantity = -quantity
+		}
+		if quantity == 0 {
+			continue
+		}
+		entryPrice, _ := pos["entryPrice"].(float64)
+		markPrice, _ := pos["markPrice"].(float64)
+		unrealizedPnl, _ := pos["unRealizedProfit"].(float64)
+		liquidationPrice, _ := pos["liquidationPrice"].(float64)
+
+		leverage := 10
+		switch lev := pos["leverage"].(type) {
+		case float64:
+			leverage = int(lev)
+		case int:
+			leverage = lev
+		}
+		if leverage <= 0 {
+			leverage = 1
+		}
+
+		// 标记价格优先取交易员数据源的行情，并据此重算未实现盈亏
+		priceSource := "exchange"
+		if mark := marks[symbol]; mark.price > 0 {
+			markPrice = mark.price
+			priceSource = mark.source
+			if strings.ToLower(side) == "long" {
+				unrealizedPnl = (markPrice - entryPrice) * quantity
+			} else {
+				unrealizedPnl = (entryPrice - markPrice) * quantity
+			}
+		}
+
+		// 保证金使用交易器返回的实际初始保证金，未提供时按标记价格估算
+		marginUsed, _ := pos["initialMargin"].(float64)
+		if marginUsed <= 0 {
+			marginUsed = (quantity * markPrice) / float64(leverage)
+		}
+		view := PositionView{
+			Symbol:           symbol,
+			Side:             strings.ToLower(side),
+			Quantity:         quantity,
+			EntryPrice:       entryPrice,
+			MarkPrice:        markPrice,
+			LiquidationPrice: liquidationPrice,
+			Leverage:         leverage,
+			MarginUsed:       marginUsed,
+			UnrealizedPnL:    unrealizedPnl,
+			UnrealizedPnLPct: calculatePnLPercentage(unrealizedPnl, marginUsed),
+			PriceSource:      priceSource,
+		}
+
+		if paid, ok := funding[positionMetaKey(symbol, side)]; ok {
+			view.FundingPaid = &paid
+		}
+
+		if meta, ok := at.positionMeta[positionMetaKey(symbol, side)]; ok {
+			view.PositionID = meta.PositionID
+			view.StopLoss = meta.StopLoss
+			view.TakeProfit = meta.TakeProfit
+			view.OpenCycle = meta.OpenCycle
+			view.Note = meta.Note
+			if !meta.OpenedAt.IsZero() {
+				view.OpenedAt = meta.OpenedAt.UnixMilli()
+				view.AgeSeconds = int64(now.Sub(meta.OpenedAt).Seconds())
+			}
+		}
+
+		views = append(views, view)
+	}
+
+	// 单币种资金分配（与执行开仓时的分配上限检查口径一致）
+	if balanceErr == nil {
+		if equity := accountEquity(balance); equity > 0 {
+			margins := make(map[string]float64)
+			for _, view := range views {
+				margins[view.Symbol] += view.MarginUsed
+			}
+			for i := range views {
+				views[i].AllocationPct = margins[views[i].Symbol] / equity * 100
+				views[i].AllocationBudget = at.config.AllocationBudget.BudgetPctFor(views[i].Symbol)
+			}
+		}
+	}
+
+	at.positionViewCache = views
+	at.positionViewCacheAt = now
+
+	result := make([]PositionView, len(views))
+	copy(result, views)
+	return result, nil
+}
@@ -0,0 +1,79 @@
+package trader
+
+import (
+	"fmt"
+
+	"aspen/logger"
+)
+
+// quoteQuantityTrader 启用按金额下单且交易器支持时返回按金额下单的交易器
+func (at *AutoTrader) quoteQuantityTrader() (QuoteQuantityTrader, bool) {
+	if !at.config.QuoteQuantityOrders {
+		return nil, false
+	}
+	t, ok := at.trader.(QuoteQuantityTrader)
+	return t, ok
+}
+
+// quoteClientOrderIDTrader 启用按金额下单且交易器支持客户端订单ID时返回对应的交易器
+func (at *AutoTrader) quoteClientOrderIDTrader() (QuoteClientOrderIDTrader, bool) {
+	if !at.config.QuoteQuantityOrders {
+		return nil, false
+	}
+	t, ok := at.trader.(QuoteClientOrderIDTrader)
+	return t, ok
+}
+
+// OpenLongQuoteWithClientID 按金额开多仓（同一个客户端订单ID已成交时直接返回原订单）
+func (t *PaperTrader) OpenLongQuoteWithClientID(symbol string, quoteQuantity float64, leverage int, clientOrderID string) (map[string]interface{}, error) {
+	return t.quoteWithClientID(clientOrderID, func() (map[string]interface{}, error) {
+		return t.OpenLongQuote(symbol, quoteQuantity, leverage)
+	})
+}
+
+// OpenShortQuoteWithClientID 按金额开空仓（同一个客户端订单ID已成交时直接返回原订单）
+func (t *PaperTrader) OpenShortQuoteWithClientID(symbol string, quoteQuantity float64, leverage int, clientOrderID string) (map[string]interface{}, error) {
+	return t.quoteWithClientID(clientOrderID, func() (map[string]interface{}, error) {
+		return t.OpenShortQuote(symbol, quoteQuantity, leverage)
+	})
+}
+
+// quoteWithClientID 按客户端订单ID去重执行按金额开仓：成功的订单按ID记录，同一ID再次提交时返回记录的订单
+func (t *PaperTrader) quoteWithClientID(clientOrderID string, place func() (map[string]interface{}, error)) (map[string]interface{}, error) {
+	if clientOrderID == "" {
+		return nil, fmt.Errorf("客户端订单ID不能为空")
+	}
+
+	t.clientOrderMu.Lock()
+	defer t.clientOrderMu.Unlock()
+
+	if order, ok := t.clientOrders[clientOrderID]; ok {
+		logger.Infof("📝 [Paper Trading] 客户端订单ID %s 已成交，返回原订单", clientOrderID)
+		return order, nil
+	}
+	order, err := place()
+	if err != nil {
+		return nil, err
+	}
+	order["clientOrderId"] = clientOrderID
+	if t.clientOrders == nil {
+		t.clientOrders = make(map[string]map[string]interface{})
+	}
+	t.clientOrders[clientOrderID] = order
+	return order, nil
+}
+
+// applyQuoteFill 按金额下单成交后，用实际成交数量和花费更新动作记录，返回成交数量（后续设置止损止盈使用）；
+// 按数量下单的订单原样返回 quantity
+func applyQuoteFill(order map[string]interface{}, quantity float64, actionRecord *logger.DecisionAction) float64 {
+	quote, ok := order["quoteQuantity"].(float64)
+	if !ok || quote <= 0 {
+		return quantity
+	}
+	if filled, ok := order["quantity"].(float64); ok && filled > 0 {
+		quantity = filled
+	}
+	actionRecord.Quantity = quantity
+	actionRecord.RealizedSizeUSD = quote
+	return quantity
+}
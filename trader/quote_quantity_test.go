@@ -0,0 +1,97 @@
+package trader
+
+import (
+	"testing"
+
+	"aspen/decision"
+	"aspen/logger"
+	"aspen/market"
+
+	"github.com/agiledragon/gomonkey/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestPaperTrader_QuoteQuantitySpendsExactAmount 测试按金额开仓无论成交价多少都恰好花费指定金额，成交价只影响数量
+func TestPaperTrader_QuoteQuantitySpendsExactAmount(t *testing.T) {
+	for _, price := range []float64{80, 100, 137.5} {
+		prices := map[string]float64{"BTCUSDT": price, "ETHUSDT": price * 2}
+		pt := newPricedPaperTrader(t, 10000, prices)
+
+		order, err := pt.OpenLongQuote("BTCUSDT", 500, 5)
+		require.NoError(t, err)
+		pos := pt.positions["BTCUSDT_LONG"]
+		assert.InDelta(t, 500, pos.Quantity*pos.EntryPrice, 1e-9, "价格 %.1f", price)
+		assert.InDelta(t, 500/price, order["quantity"], 1e-12)
+		assert.Equal(t, 500.0, order["quoteQuantity"])
+		assert.InDelta(t, 100, pos.Margin, 1e-9)
+
+		order, err = pt.OpenShortQuote("ETHUSDT", 250, 5)
+		require.NoError(t, err)
+		pos = pt.positions["ETHUSDT_SHORT"]
+		assert.InDelta(t, 250, pos.Quantity*pos.EntryPrice, 1e-9)
+	}
+
+	pt := newPricedPaperTrader(t, 10000, map[string]float64{"BTCUSDT": 100})
+	_, err := pt.OpenLongQuote("BTCUSDT", 0, 5)
+	assert.Error(t, err)
+	_, err = pt.OpenLongQuote("SOLUSDT", 100, 5)
+	assert.Error(t, err, "没有价格时不能按金额换算数量")
+}
+
+// TestQuoteQuantityOrders_OpenSpendsPositionSize 测试启用按金额下单时，决策到成交之间价格变化只影响成交数量，
+// 花费仍为决策的仓位金额，止损止盈和动作记录使用实际成交数量；未启用时按决策价格换算的数量下单
+func TestQuoteQuantityOrders_OpenSpendsPositionSize(t *testing.T) {
+	patches := gomonkey.ApplyFunc(market.Get, func(symbol string) (*market.Data, error) {
+		return &market.Data{Symbol: symbol, CurrentPrice: 100}, nil // 决策时价格
+	})
+	defer patches.Reset()
+
+	prices := map[string]float64{"BTCUSDT": 125, "ETHUSDT": 125} // 成交时价格已上涨
+	pt := newPricedPaperTrader(t, 10000, prices)
+	at := newJournalTestTrader(t.TempDir(), pt)
+	at.config.QuoteQuantityOrders = true
+
+	record := &logger.DecisionAction{}
+	d := &decision.Decision{Symbol: "BTCUSDT", Action: "open_long", Leverage: 5, PositionSizeUSD: 1000}
+	require.NoError(t, at.executeOpenLongWithRecord(d, record))
+
+	pos := pt.positions["BTCUSDT_LONG"]
+	assert.InDelta(t, 1000, pos.Quantity*pos.EntryPrice, 1e-9)
+	assert.InDelta(t, 8, pos.Quantity, 1e-9)
+	assert.InDelta(t, 8, record.Quantity, 1e-9)
+	assert.Equal(t, 1000.0, record.RealizedSizeUSD)
+
+	// 未启用时按决策价格换算数量，花费随成交价变化
+	at.config.QuoteQuantityOrders = false
+	d = &decision.Decision{Symbol: "ETHUSDT", Action: "open_short", Leverage: 5, PositionSizeUSD: 1000}
+	require.NoError(t, at.executeOpenShortWithRecord(d, &logger.DecisionAction{}))
+	pos = pt.positions["ETHUSDT_SHORT"]
+	assert.InDelta(t, 10, pos.Quantity, 1e-9)
+	assert.InDelta(t, 1250, pos.Quantity*pos.EntryPrice, 1e-9)
+}
+
+// TestQuoteQuantityOrders_ClientOrderIDIdempotent 测试按金额开仓也使用客户端订单ID：同一ID重试只成交一次，不同ID各自成交
+func TestQuoteQuantityOrders_ClientOrderIDIdempotent(t *testing.T) {
+	pt := newPricedPaperTrader(t, 10000, map[string]float64{"BTCUSDT": 100, "ETHUSDT": 50})
+	at := newJournalTestTrader(t.TempDir(), pt)
+	at.config.QuoteQuantityOrders = true
+
+	first, err := at.openLong("BTCUSDT", 0, 500, 5, "cid-1", priceRef{Price: 100})
+	require.NoError(t, err)
+	assert.Equal(t, "cid-1", first["clientOrderId"])
+	retry, err := at.openLong("BTCUSDT", 0, 500, 5, "cid-1", priceRef{Price: 100})
+	require.NoError(t, err)
+	assert.Equal(t, first, retry, "重试返回第一次的订单")
+	assert.InDelta(t, 5, pt.positions["BTCUSDT_LONG"].Quantity, 1e-9, "同一ID只成交一次")
+
+	_, err = at.openLong("BTCUSDT", 0, 500, 5, "cid-2", priceRef{Price: 100})
+	require.NoError(t, err)
+	assert.InDelta(t, 10, pt.positions["BTCUSDT_LONG"].Quantity, 1e-9, "不同ID各自成交")
+
+	_, err = at.openShort("ETHUSDT", 0, 250, 5, "cid-3", priceRef{Price: 50})
+	require.NoError(t, err)
+	_, err = at.openShort("ETHUSDT", 0, 250, 5, "cid-3", priceRef{Price: 50})
+	require.NoError(t, err)
+	assert.InDelta(t, 5, pt.positions["ETHUSDT_SHORT"].Quantity, 1e-9)
+}
@@ -0,0 +1,77 @@
+package trader
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"aspen/decision"
+	"aspen/logger"
+)
+
+// 执行器拒绝开仓的原因代码（决策验证阶段的原因代码见 decision 包）
+const (
+	RejectBlackout               = "blackout"                 // 交易暂停窗口内
+	RejectStablecoinDepeg        = "stablecoin_depeg"         // 保证金稳定币脱锚
+	RejectDataOutage             = "data_outage"              // 行情数据超过阈值未更新
+	RejectMaxSpread              = "max_spread"               // 买卖价差超过上限
+	RejectChurnGuard             = "churn_guard"              // 刚对同一币种同方向开平过仓
+	RejectEntryConfirmation      = "entry_confirmation"       // 开仓方向的信号尚未持续足够的K线
+	RejectMinBars                = "min_bars"                 // 距同一币种上次开平仓的K线数不足
+	RejectSymbolAllocation       = "symbol_allocation"        // 单币种资金分配已达上限
+	RejectHedgePolicy            = "hedge_policy"             // 对冲策略不允许反向持仓
+	RejectPositionExists         = "position_exists"          // 已有同币种同方向持仓
+	RejectMaxConcurrentPositions = "max_concurrent_positions" // 用户所有交易员合计持仓数达到上限
+	RejectInsufficientMargin     = "insufficient_margin"      // 可用保证金不足
+	RejectManageOnly             = "manage_only"              // 只管理持仓模式下不开新仓
+	RejectLimitOrderUnsupported  = "limit_order_unsupported"  // 交易器不支持限价挂单
+	RejectDuplicateIntent        = "duplicate_intent"         // 窗口内已成交过相同意图的开仓
+)
+
+// reject 为拒绝开仓的错误附加原因代码
+func reject(code string, err error) error {
+	return decision.Reject(code, err)
+}
+
+// recordRejection 决策因检查未通过被拒绝时，在动作记录中写入原因代码和面向用户的说明
+// （基础设施错误如获取行情失败不是拒绝，不记录原因）
+func recordRejection(d *decision.Decision, actionRecord *logger.DecisionAction, err error) {
+	code := actionRecord.RejectReason
+	if code == "" {
+		code = decision.RejectionCode(err)
+	}
+	if code == "" {
+		return
+	}
+	actionRecord.RejectReason = code
+	actionRecord.Rejection = explainRejection(d.Action, d.Symbol, code, err)
+}
+
+// explainRejection 拒绝说明，如 "AI 想要 open_long BTCUSDT，但被 max_daily_loss 拦截：动态风控已禁止开仓（当日亏损 4.50% / 上限 5.00%）"
+func explainRejection(action, symbol, code string, err error) string {
+	reason := strings.TrimSpace(strings.TrimPrefix(err.Error(), "❌"))
+	return fmt.Sprintf("AI 想要 %s %s，但被 %s 拦截：%s", action, symbol, code, reason)
+}
+
+// recordValidationRejection AI输出的决策未通过验证（整批决策都不执行）时，把被拒绝的决策及原因写入决策记录
+func (at *AutoTrader) recordValidationRejection(record *logger.DecisionRecord, err error) {
+	var invalid *decision.ValidationError
+	if !errors.As(err, &invalid) {
+		return
+	}
+	d := invalid.Decision
+	code := invalid.Code()
+	record.Decisions = append(record.Decisions, logger.DecisionAction{
+		Action:       d.Action,
+		Symbol:       d.Symbol,
+		Leverage:     d.Leverage,
+		Timestamp:    time.Now(),
+		Success:      false,
+		Error:        invalid.Err.Error(),
+		Note:         d.Note,
+		RejectReason: code,
+		Rejection:    explainRejection(d.Action, d.Symbol, code, invalid.Err),
+	})
+	logger.Warnf("⛔ [%s] %s", at.name, record.Decisions[len(record.Decisions)-1].Rejection)
+}
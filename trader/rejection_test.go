@@ -0,0 +1,98 @@
+package trader
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"aspen/decision"
+	"aspen/logger"
+	"aspen/market"
+
+	"github.com/agiledragon/gomonkey/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestExecuteDecision_RecordsRejectionReason 测试开仓被检查拒绝时，动作记录中写入具体的拒绝原因代码和说明
+func TestExecuteDecision_RecordsRejectionReason(t *testing.T) {
+	patches := gomonkey.ApplyFunc(market.Get, func(symbol string) (*market.Data, error) {
+		return &market.Data{Symbol: symbol, CurrentPrice: 100.0}, nil
+	})
+	defer patches.Reset()
+
+	tests := []struct {
+		name      string
+		setup     func(at *AutoTrader, exchange *clientIDMockTrader)
+		wantCode  string
+		wantInMsg string
+	}{
+		{"当日亏损接近上限", func(at *AutoTrader, _ *clientIDMockTrader) {
+			at.riskLimits = decision.ComputeRiskLimits(decision.DefaultRiskScalingConfig(5, 20), 1000, -4.5, 0, 5, 5)
+		}, decision.RejectMaxDailyLoss, "当日亏损 4.50% / 上限 5.00%"},
+		{"回撤接近上限", func(at *AutoTrader, _ *clientIDMockTrader) {
+			at.riskLimits = decision.ComputeRiskLimits(decision.DefaultRiskScalingConfig(5, 20), 1000, 0, 18, 5, 5)
+		}, decision.RejectMaxDrawdown, "回撤 18.00% / 上限 20.00%"},
+		{"账户净值低于下限", func(at *AutoTrader, _ *clientIDMockTrader) {
+			cfg := decision.DefaultRiskScalingConfig(5, 20)
+			cfg.MinEquity = 1200
+			at.riskLimits = decision.ComputeRiskLimits(cfg, 1000, 0, 0, 5, 5)
+		}, decision.RejectMinEquity, "净值 1000.00 低于下限 1200.00"},
+		{"已有同方向持仓", func(_ *AutoTrader, exchange *clientIDMockTrader) {
+			exchange.positions = []map[string]interface{}{{"symbol": "SOLUSDT", "side": "long", "positionAmt": 2.0}}
+		}, RejectPositionExists, "已有多仓"},
+		{"对冲策略不允许反向持仓", func(_ *AutoTrader, exchange *clientIDMockTrader) {
+			exchange.positions = []map[string]interface{}{{"symbol": "SOLUSDT", "side": "short", "positionAmt": -2.0}}
+		}, RejectHedgePolicy, "不允许同时持有反向仓位"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exchange := newClientIDMockTrader()
+			at := newJournalTestTrader(t.TempDir(), exchange)
+			tt.setup(at, exchange)
+
+			d := &decision.Decision{Symbol: "SOLUSDT", Action: "open_long", Leverage: 5, PositionSizeUSD: 200}
+			record := &logger.DecisionAction{Action: d.Action, Symbol: d.Symbol}
+			err := at.executeDecisionWithRecord(d, record)
+
+			require.Error(t, err)
+			assert.Empty(t, exchange.orders, "拒绝时不应下单")
+			assert.Equal(t, tt.wantCode, record.RejectReason)
+			assert.Contains(t, record.Rejection, "AI 想要 open_long SOLUSDT，但被 "+tt.wantCode+" 拦截")
+			assert.Contains(t, record.Rejection, tt.wantInMsg)
+		})
+	}
+
+	// 不是检查拒绝（没有可加仓的持仓）时不记录拒绝原因
+	at := newJournalTestTrader(t.TempDir(), newClientIDMockTrader())
+	record := &logger.DecisionAction{}
+	err := at.executeDecisionWithRecord(&decision.Decision{Symbol: "SOLUSDT", Action: "add_to_position", PositionSizeUSD: 100}, record)
+	require.Error(t, err)
+	assert.Empty(t, record.RejectReason)
+	assert.Empty(t, record.Rejection)
+}
+
+// TestRecordValidationRejection 测试AI输出未通过验证时，被拒绝的决策及原因写入决策记录
+func TestRecordValidationRejection(t *testing.T) {
+	at := newJournalTestTrader(t.TempDir(), newClientIDMockTrader())
+	d := decision.Decision{Symbol: "BTCUSDT", Action: "open_short", Leverage: 3, PositionSizeUSD: 5}
+	err := fmt.Errorf("决策验证失败: %w", &decision.ValidationError{
+		Index:    1,
+		Decision: d,
+		Err:      decision.Reject(decision.RejectMinPositionSize, errors.New("BTCUSDT 开仓金额过小(5.00 USDT)")),
+	})
+
+	record := &logger.DecisionRecord{}
+	at.recordValidationRejection(record, err)
+	require.Len(t, record.Decisions, 1)
+	action := record.Decisions[0]
+	assert.False(t, action.Success)
+	assert.Equal(t, "open_short", action.Action)
+	assert.Equal(t, decision.RejectMinPositionSize, action.RejectReason)
+	assert.Equal(t, "AI 想要 open_short BTCUSDT，但被 min_position_size 拦截：BTCUSDT 开仓金额过小(5.00 USDT)", action.Rejection)
+	assert.Len(t, record.Rejected(), 1)
+
+	// 其他错误（如AI调用失败）不记录动作
+	at.recordValidationRejection(record, errors.New("AI调用失败"))
+	assert.Len(t, record.Decisions, 1)
+}
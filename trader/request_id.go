@@ -0,0 +1,39 @@
+package trader
+
+import (
+	"net/http"
+	"sync/atomic"
+
+	"aspen/httpclient"
+)
+
+// requestIDSetter 可在外发请求中附带交易周期请求ID的交易所客户端
+type requestIDSetter interface {
+	SetRequestID(id string)
+}
+
+// requestIDHolder 交易所客户端当前周期的请求ID（嵌入交易所客户端，交易所SDK的请求不携带周期 context）
+type requestIDHolder struct {
+	requestID atomic.Value
+}
+
+// SetRequestID 设置之后外发请求附带的请求ID
+func (h *requestIDHolder) SetRequestID(id string) {
+	h.requestID.Store(id)
+}
+
+// currentRequestID 当前的请求ID（未设置时返回空字符串）
+func (h *requestIDHolder) currentRequestID() string {
+	id, _ := h.requestID.Load().(string)
+	return id
+}
+
+// withRequestIDHeader 返回在外发请求中附带当前请求ID的HTTP客户端副本（超时等设置不变）
+func (h *requestIDHolder) withRequestIDHeader(client *http.Client) *http.Client {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	wrapped := *client
+	wrapped.Transport = &httpclient.RequestIDTransport{Base: client.Transport, Current: h.currentRequestID}
+	return &wrapped
+}
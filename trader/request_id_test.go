@@ -0,0 +1,106 @@
+package trader
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"aspen/httpclient"
+	"aspen/logger"
+	"aspen/market"
+	"aspen/mcp"
+	"aspen/pool"
+
+	"github.com/agiledragon/gomonkey/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestRunCycle_PropagatesRequestID 测试周期请求ID出现在外发AI请求头、本周期的日志行、决策记录和交易所客户端中
+func TestRunCycle_PropagatesRequestID(t *testing.T) {
+	patches := gomonkey.ApplyFunc(market.GetWithIndicators, func(source market.DataSource, symbol string, params market.IndicatorParams) (*market.Data, error) {
+		return &market.Data{Symbol: symbol, CurrentPrice: 50000, CurrentRSI7: math.NaN(), NoOpenInterest: true}, nil
+	})
+	patches.ApplyFunc(pool.GetOITopPositions, func() ([]pool.OIPosition, error) {
+		return nil, errors.New("oi top disabled in test")
+	})
+	defer patches.Reset()
+
+	headers := make(chan string, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers <- r.Header.Get(httpclient.DefaultRequestIDHeader)
+		content := "<decision>\n```json\n[{\"symbol\": \"BTCUSDT\", \"action\": \"wait\", \"reasoning\": \"no setup\"}]\n```\n</decision>"
+		resp, _ := json.Marshal(map[string]interface{}{
+			"choices": []map[string]interface{}{{"message": map[string]string{"content": content}}},
+		})
+		w.Write(resp)
+	}))
+	defer srv.Close()
+
+	var logs bytes.Buffer
+	output := logger.Log.Out
+	logger.Log.SetOutput(&logs)
+	defer logger.Log.SetOutput(output)
+
+	exchange := &requestIDMockTrader{}
+	at := newJournalTestTrader(t.TempDir(), exchange)
+	at.tradingCoins = []string{"BTCUSDT"}
+	at.mcpClient = mcp.New()
+	at.mcpClient.SetCustomAPI(srv.URL, "sk-test", "test-model")
+
+	require.NoError(t, at.runCycle(CycleTrigger{Type: TriggerScheduled}))
+
+	requestID := <-headers
+	require.Len(t, requestID, 16)
+	assert.Equal(t, requestID, exchange.currentRequestID())
+
+	cycleLines := 0
+	for _, line := range strings.Split(logs.String(), "\n") {
+		if strings.Contains(line, "request_id") && strings.Contains(line, requestID) {
+			cycleLines++
+		}
+	}
+	assert.Contains(t, logs.String(), "AI决策周期")
+	assert.GreaterOrEqual(t, cycleLines, 3, "周期日志行应带请求ID:\n%s", logs.String())
+
+	records, err := at.decisionLogger.GetLatestRecords(1)
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	assert.Equal(t, requestID, records[0].RequestID)
+}
+
+// requestIDMockTrader 记录周期请求ID的模拟交易所
+type requestIDMockTrader struct {
+	MockTrader
+	requestIDHolder
+}
+
+// TestRequestIDTransport_SetsHeader 测试交易所客户端的外发请求附带当前请求ID，请求头名称可配置
+func TestRequestIDTransport_SetsHeader(t *testing.T) {
+	received := make(chan http.Header, 2)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- r.Header.Clone()
+	}))
+	defer srv.Close()
+
+	var holder requestIDHolder
+	client := holder.withRequestIDHeader(&http.Client{})
+
+	resp, err := client.Get(srv.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Empty(t, (<-received).Get(httpclient.DefaultRequestIDHeader), "未设置请求ID时不附带请求头")
+
+	httpclient.SetRequestIDHeader("x-correlation-id")
+	defer httpclient.SetRequestIDHeader("")
+	holder.SetRequestID("cycle-42")
+	resp, err = client.Get(srv.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, "cycle-42", (<-received).Get("X-Correlation-Id"))
+}
@@ -0,0 +1,49 @@
+package trader
+
+import (
+	"sort"
+	"strings"
+
+	"aspen/market"
+	"aspen/pool"
+)
+
+// RequiredSymbols 交易员需要实时行情（WebSocket 订阅）的币种：交易币种（未配置时为默认币种）、已知持仓的币种和 BTCUSDT（决策始终参考BTC）。
+// 交易币种和默认币种都为空时候选币种来自币种池，返回 usesPool=true 由调用方补充币种池；
+// 单独配置了其他数据源的交易员通过 REST 获取行情，不需要订阅
+func (at *AutoTrader) RequiredSymbols() (symbols []string, usesPool bool) {
+	if source := at.config.DataSource; source != "" && source != market.GetCurrentDataSource() {
+		return nil, false
+	}
+
+	set := map[string]bool{"BTCUSDT": true}
+	coins := at.tradingCoins
+	if len(coins) == 0 {
+		coins = at.defaultCoins
+		usesPool = len(coins) == 0
+	}
+	for _, coin := range coins {
+		set[normalizeSymbol(coin)] = true
+	}
+
+	at.positionMetaMutex.RLock()
+	for key := range at.positionMeta {
+		if i := strings.LastIndex(key, "_"); i > 0 {
+			set[key[:i]] = true
+		}
+	}
+	at.positionMetaMutex.RUnlock()
+
+	symbols = make([]string, 0, len(set))
+	for symbol := range set {
+		symbols = append(symbols, symbol)
+	}
+	sort.Strings(symbols)
+	return symbols, usesPool
+}
+
+// CapCoinPool 按交易员配置的币种数量上限截取币种池（未配置上限时原样返回），
+// 决策候选币种和行情订阅使用同一个截取结果
+func (at *AutoTrader) CapCoinPool(merged *pool.MergedCoinPool) *pool.MergedCoinPool {
+	return pool.CapMergedCoinPool(merged, at.config.CoinPoolMaxSymbols, at.config.CoinPoolRankBy)
+}
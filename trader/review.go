@@ -0,0 +1,70 @@
+package trader
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"aspen/decision"
+	"aspen/logger"
+	"aspen/mcp"
+)
+
+// ReviewConfig 两级模型配置：扫描模型（AIModel）每个周期给出决策，满足触发条件的决策（开仓、大仓位、大额亏损平仓）
+// 由更强的复核模型确认、否决或调整后再执行
+type ReviewConfig struct {
+	Provider        string // 复核模型提供商（deepseek/qwen/openrouter/custom），空表示不启用
+	APIKey          string
+	CustomAPIURL    string
+	CustomModelName string
+	Triggers        decision.ReviewTriggers
+	Fallback        string // 复核模型不可用时的处理方式（hold/execute）
+}
+
+// Enabled 是否启用复核（配置了复核模型和至少一个触发条件）
+func (c ReviewConfig) Enabled() bool {
+	return c.Provider != "" && c.Triggers.Enabled()
+}
+
+// newReviewClient 创建复核模型的AI客户端
+func newReviewClient(cfg ReviewConfig) (*mcp.Client, error) {
+	client, err := mcp.NewForProvider(cfg.Provider, cfg.APIKey, cfg.CustomAPIURL, cfg.CustomModelName)
+	if err != nil {
+		return nil, fmt.Errorf("复核模型: %w", err)
+	}
+	return client, nil
+}
+
+// reviewDecisions 两级模型复核扫描模型的决策（未启用时不处理），两个模型的输出都保存在决策记录中
+func (at *AutoTrader) reviewDecisions(ctx *decision.Context, full *decision.FullDecision, record *logger.DecisionRecord) {
+	if at.reviewClient == nil {
+		return
+	}
+	review := decision.ReviewDecisions(ctx, full, at.reviewClient, decision.ReviewConfig{
+		Triggers: at.config.Review.Triggers,
+		Fallback: at.config.Review.Fallback,
+	})
+	if review == nil {
+		return
+	}
+
+	verdictJSON, _ := json.MarshalIndent(review.Items, "", "  ")
+	record.Review = &logger.ReviewSnapshot{
+		ScanModel:   at.mcpClient.Model,
+		ReviewModel: review.Model,
+		VerdictJSON: string(verdictJSON),
+		RawResponse: review.RawResponse,
+		DurationMs:  review.DurationMs,
+		Error:       review.Error,
+		Fallback:    review.Fallback,
+	}
+	if review.Error != "" {
+		logger.Warnf("⚠️  [%s] 复核模型不可用，%d 个待复核决策按 %s 处理: %s", at.name, len(review.Items), review.Fallback, review.Error)
+		record.ExecutionLog = append(record.ExecutionLog,
+			fmt.Sprintf("复核模型 %s 不可用（%s），%d 个待复核决策按 %s 处理", review.Model, review.Error, len(review.Items), review.Fallback))
+	}
+	for _, item := range review.Items {
+		logger.Infof("🔍 复核 %s %s: %s %s", item.Proposed.Symbol, item.Proposed.Action, item.Verdict, item.Reason)
+		record.ExecutionLog = append(record.ExecutionLog,
+			fmt.Sprintf("复核 %s %s: %s %s", item.Proposed.Symbol, item.Proposed.Action, item.Verdict, item.Reason))
+	}
+}
@@ -0,0 +1,41 @@
+package trader
+
+import (
+	"time"
+
+	"aspen/logger"
+)
+
+// dayStartLayout 当日起始净值对应的 UTC 日期格式
+const dayStartLayout = "2006-01-02"
+
+// riskEquitySaver 持久化动态风控的净值峰值和当日起始净值（*config.Database 实现），进程重启后按同一基准计算
+type riskEquitySaver interface {
+	SaveRiskEquity(userID, id string, peak, dayStart float64, dayStartDate string) error
+}
+
+// updateRiskEquity 按本周期净值计算当日盈亏（%）和自峰值的回撤（%）：UTC 日期切换后以当天第一次取得的净值作为当日起点，
+// 同一天内重启沿用已保存的起点；净值峰值或当日起点变化时保存到数据库
+func (at *AutoTrader) updateRiskEquity(totalEquity float64, now time.Time) (dailyPnLPct, drawdownPct float64) {
+	previousPeak, previousDayStart := at.peakEquity, at.dayStartEquity
+
+	today := now.UTC().Format(dayStartLayout)
+	if at.dayStartDate != today || at.dayStartEquity <= 0 {
+		at.dayStartEquity = totalEquity
+		at.dayStartDate = today
+	}
+	at.dailyPnL = totalEquity - at.dayStartEquity
+	if at.dayStartEquity > 0 {
+		dailyPnLPct = (at.dailyPnL / at.dayStartEquity) * 100
+	}
+	drawdownPct = at.updateDrawdown(totalEquity)
+
+	if at.peakEquity != previousPeak || at.dayStartEquity != previousDayStart {
+		if db, ok := at.database.(riskEquitySaver); ok {
+			if err := db.SaveRiskEquity(at.userID, at.id, at.peakEquity, at.dayStartEquity, at.dayStartDate); err != nil {
+				logger.Warnf("⚠️ [%s] 保存净值峰值和当日起始净值失败: %v", at.name, err)
+			}
+		}
+	}
+	return dailyPnLPct, drawdownPct
+}
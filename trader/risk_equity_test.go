@@ -0,0 +1,55 @@
+package trader
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// recordingRiskEquitySaver 记录保存的净值峰值和当日起始净值
+type recordingRiskEquitySaver struct {
+	saves        int
+	peak         float64
+	dayStart     float64
+	dayStartDate string
+}
+
+func (d *recordingRiskEquitySaver) SaveRiskEquity(userID, id string, peak, dayStart float64, dayStartDate string) error {
+	d.saves++
+	d.peak, d.dayStart, d.dayStartDate = peak, dayStart, dayStartDate
+	return nil
+}
+
+// TestUpdateRiskEquity_UTCDayBoundaryAndRestart 测试当日起始净值在 UTC 日期切换时重置，同一天内重启沿用已保存的起点和峰值，
+// 峰值或起点变化时保存到数据库
+func TestUpdateRiskEquity_UTCDayBoundaryAndRestart(t *testing.T) {
+	db := &recordingRiskEquitySaver{}
+	at := &AutoTrader{database: db}
+	day1 := time.Date(2026, 3, 1, 23, 0, 0, 0, time.UTC)
+
+	daily, dd := at.updateRiskEquity(1000, day1)
+	assert.Zero(t, daily)
+	assert.Zero(t, dd)
+	assert.Equal(t, 1, db.saves)
+	assert.Equal(t, "2026-03-01", db.dayStartDate)
+
+	daily, dd = at.updateRiskEquity(950, day1.Add(30*time.Minute))
+	assert.InDelta(t, -5, daily, 1e-9)
+	assert.InDelta(t, 5, dd, 1e-9)
+	assert.Equal(t, 1, db.saves, "峰值和起点不变时不保存")
+
+	// 模拟同一天内进程重启：从数据库加载的起点和峰值继续生效，不以重启后第一次的净值为起点
+	restarted := &AutoTrader{database: db, peakEquity: db.peak, dayStartEquity: db.dayStart, dayStartDate: db.dayStartDate}
+	daily, dd = restarted.updateRiskEquity(900, day1.Add(45*time.Minute))
+	assert.InDelta(t, -10, daily, 1e-9)
+	assert.InDelta(t, 10, dd, 1e-9)
+
+	// UTC 日期切换后以当天第一次的净值为起点，峰值保留
+	daily, dd = restarted.updateRiskEquity(880, day1.Add(2*time.Hour))
+	assert.Zero(t, daily)
+	assert.InDelta(t, 12, dd, 1e-9)
+	assert.Equal(t, "2026-03-02", db.dayStartDate)
+	assert.Equal(t, 880.0, db.dayStart)
+	assert.Equal(t, 1000.0, db.peak)
+}
@@ -0,0 +1,253 @@
+package trader
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"aspen/decision"
+)
+
+// 模拟账户来源
+const (
+	SimulationSourcePaper = "paper_clone"   // 模拟仓交易员：复制模拟仓当前状态
+	SimulationSourceLive  = "live_snapshot" // 实盘交易员：按当前余额和持仓建立模拟仓，使用交易所行情价格
+)
+
+// SimulatedAction 单个决策在模拟账户上的执行结果
+type SimulatedAction struct {
+	Symbol      string  `json:"symbol"`
+	Action      string  `json:"action"`
+	Status      string  `json:"status"` // executed / skipped（不改变持仓的动作）/ rejected
+	Error       string  `json:"error,omitempty"`
+	Side        string  `json:"side,omitempty"` // long / short
+	Price       float64 `json:"price,omitempty"`
+	Quantity    float64 `json:"quantity,omitempty"`
+	NotionalUSD float64 `json:"notional_usd,omitempty"`
+	Leverage    int     `json:"leverage,omitempty"`
+	Margin      float64 `json:"margin,omitempty"`       // 开仓占用的保证金
+	Fee         float64 `json:"fee,omitempty"`          // 开仓手续费
+	RealizedPnL float64 `json:"realized_pnl,omitempty"` // 平仓盈亏
+}
+
+// DecisionSimulation 决策模拟结果：执行后的模拟账户余额和持仓
+type DecisionSimulation struct {
+	Source    string                   `json:"source"`
+	Actions   []SimulatedAction        `json:"actions"`
+	TotalFees float64                  `json:"total_fees"`
+	Balance   map[string]interface{}   `json:"balance"`
+	Positions []map[string]interface{} `json:"positions"`
+}
+
+// SimulateDecisions 在交易员当前账户状态的一次性副本上执行决策，返回模拟仓的执行结果。
+// 决策按与AI决策相同的规则验证，按执行顺序（先平仓后开仓）执行；不下真实订单，不修改交易员状态
+func (at *AutoTrader) SimulateDecisions(decisions []decision.Decision) (*DecisionSimulation, error) {
+	account, source, err := at.simulationAccount()
+	if err != nil {
+		return nil, err
+	}
+	result := &DecisionSimulation{Source: source, Actions: []SimulatedAction{}}
+	for _, d := range sortDecisionsByPriority(decisions) {
+		action := at.simulateDecision(account, d)
+		result.TotalFees += action.Fee
+		result.Actions = append(result.Actions, action)
+	}
+
+	if result.Balance, err = account.GetBalance(); err != nil {
+		return nil, fmt.Errorf("获取模拟账户余额失败: %w", err)
+	}
+	if result.Positions, err = account.GetPositions(); err != nil {
+		return nil, fmt.Errorf("获取模拟账户持仓失败: %w", err)
+	}
+	if result.Positions == nil {
+		result.Positions = []map[string]interface{}{}
+	}
+	return result, nil
+}
+
+// ReplayDecisions 在给定的模拟仓上按与 SimulateDecisions 相同的规则（决策验证、先平仓后开仓）执行决策，
+// 仓位大小、杠杆上限和仓位模式取自 cfg；用于回测在回放模式的模拟仓上执行每个周期的决策
+func ReplayDecisions(account *PaperTrader, decisions []decision.Decision, cfg AutoTraderConfig) []SimulatedAction {
+	at := &AutoTrader{config: cfg}
+	actions := make([]SimulatedAction, 0, len(decisions))
+	for _, d := range sortDecisionsByPriority(decisions) {
+		actions = append(actions, at.simulateDecision(account, d))
+	}
+	return actions
+}
+
+// simulationAccount 模拟用的一次性账户：模拟仓交易员复制其状态，实盘交易员按当前余额和持仓建立模拟仓
+func (at *AutoTrader) simulationAccount() (*PaperTrader, string, error) {
+	if paper, ok := at.trader.(*PaperTrader); ok {
+		return paper.Clone(), SimulationSourcePaper, nil
+	}
+
+	balance, err := at.trader.GetBalance()
+	if err != nil {
+		return nil, "", fmt.Errorf("获取账户余额失败: %w", err)
+	}
+	positions, err := at.trader.GetPositions()
+	if err != nil {
+		return nil, "", fmt.Errorf("获取持仓失败: %w", err)
+	}
+	wallet, _ := balance["totalWalletBalance"].(float64)
+	account, err := NewPaperTrader(wallet)
+	if err != nil {
+		return nil, "", fmt.Errorf("账户余额异常 (%.2f)，无法模拟: %w", wallet, err)
+	}
+	account.asset = at.getStablecoinUnit()
+	account.priceFn = at.trader.GetMarketPrice
+
+	for _, pos := range positions {
+		symbol, _ := pos["symbol"].(string)
+		side, _ := pos["side"].(string)
+		quantity, _ := pos["positionAmt"].(float64)
+		entryPrice, _ := pos["entryPrice"].(float64)
+		quantity = math.Abs(quantity)
+		if symbol == "" || quantity == 0 || entryPrice <= 0 {
+			continue
+		}
+		side = strings.ToUpper(side)
+		leverage := positionLeverage(pos, 1)
+		margin := entryPrice * quantity / float64(leverage)
+		account.positions[account.getPositionKey(symbol, side)] = &Position{
+			Symbol:     symbol,
+			Side:       side,
+			Quantity:   quantity,
+			EntryPrice: entryPrice,
+			Leverage:   leverage,
+			Margin:     margin,
+		}
+		account.balance -= margin
+	}
+	return account, SimulationSourceLive, nil
+}
+
+// simulateDecision 在模拟账户上执行单个决策
+func (at *AutoTrader) simulateDecision(account *PaperTrader, d decision.Decision) SimulatedAction {
+	result := SimulatedAction{Symbol: d.Symbol, Action: d.Action}
+	reject := func(err error) SimulatedAction {
+		result.Status = "rejected"
+		result.Error = err.Error()
+		return result
+	}
+
+	balance, err := account.GetBalance()
+	if err != nil {
+		return reject(err)
+	}
+	equity := accountEquity(balance)
+	if d.PositionSizePct > 0 && (d.Action == "open_long" || d.Action == "open_short" || d.Action == "add_to_position") {
+		size, _, err := at.config.PositionSizing.SizeUSD(d.Symbol, d.PositionSizePct, equity)
+		if err != nil {
+			return reject(err)
+		}
+		d.PositionSizeUSD = size
+	}
+	if err := decision.ValidateDecision(&d, equity, at.config.BTCETHLeverage, at.config.AltcoinLeverage); err != nil {
+		return reject(err)
+	}
+
+	positions, err := account.GetPositions()
+	if err != nil {
+		return reject(err)
+	}
+
+	var order map[string]interface{}
+	switch d.Action {
+	case "open_long", "open_short":
+		result.Side = strings.TrimPrefix(d.Action, "open_")
+		for _, pos := range positions {
+			if pos["symbol"] == d.Symbol && pos["side"] == result.Side {
+				return reject(fmt.Errorf("%s 已有%s，拒绝开仓以防止仓位叠加超限", d.Symbol, sideName(result.Side)))
+			}
+		}
+		// 与实际开仓一样先按交易员配置设置仓位模式（已有持仓时保持原模式）
+		_ = account.SetMarginMode(d.Symbol, at.config.IsCrossMargin)
+		order, err = simulateOpen(account, d.Symbol, result.Side, d.PositionSizeUSD, d.Leverage)
+
+	case "add_to_position":
+		target := at.findTargetPosition(positions, &d)
+		if target == nil {
+			return reject(fmt.Errorf("%s 没有持仓，无法加仓", d.Symbol))
+		}
+		result.Side, _ = target["side"].(string)
+		if d.Leverage <= 0 {
+			d.Leverage = positionLeverage(target, 1)
+		}
+		order, err = simulateOpen(account, d.Symbol, result.Side, d.PositionSizeUSD, d.Leverage)
+
+	case "close_long":
+		result.Side = "long"
+		order, err = account.CloseLong(d.Symbol, 0)
+	case "close_short":
+		result.Side = "short"
+		order, err = account.CloseShort(d.Symbol, 0)
+
+	case "partial_close":
+		target := at.findTargetPosition(positions, &d)
+		if target == nil {
+			return reject(fmt.Errorf("持仓不存在: %s", d.Symbol))
+		}
+		result.Side, _ = target["side"].(string)
+		amount, _ := target["positionAmt"].(float64)
+		quantity := math.Abs(amount) * d.ClosePercentage / 100
+		if result.Side == "long" {
+			order, err = account.CloseLong(d.Symbol, quantity)
+		} else {
+			order, err = account.CloseShort(d.Symbol, quantity)
+		}
+
+	default:
+		// hold/wait 以及调整止损止盈不改变持仓和余额
+		result.Status = "skipped"
+		return result
+	}
+	if err != nil {
+		return reject(err)
+	}
+
+	result.Status = "executed"
+	result.Price, _ = order["price"].(float64)
+	result.Quantity, _ = order["quantity"].(float64)
+	result.NotionalUSD = result.Price * result.Quantity
+	result.Leverage, _ = order["leverage"].(int)
+	result.Margin, _ = order["margin"].(float64)
+	result.Fee, _ = order["fee"].(float64)
+	result.RealizedPnL, _ = order["pnl"].(float64)
+	return result
+}
+
+// simulateOpen 在模拟账户上按金额开仓或加仓
+func simulateOpen(account *PaperTrader, symbol, side string, sizeUSD float64, leverage int) (map[string]interface{}, error) {
+	if sizeUSD <= 0 {
+		return nil, fmt.Errorf("仓位大小必须大于0: %.2f", sizeUSD)
+	}
+	if leverage <= 0 {
+		return nil, fmt.Errorf("杠杆必须大于0: %d", leverage)
+	}
+	price, err := account.GetMarketPrice(symbol)
+	if err != nil {
+		return nil, err
+	}
+	quantity := sizeUSD / price
+	if side == "short" {
+		return account.OpenShort(symbol, quantity, leverage)
+	}
+	return account.OpenLong(symbol, quantity, leverage)
+}
+
+// positionLeverage 持仓的杠杆（交易所返回 float64，模拟仓返回 int），未知时返回 fallback
+func positionLeverage(pos map[string]interface{}, fallback int) int {
+	switch lev := pos["leverage"].(type) {
+	case float64:
+		if lev > 0 {
+			return int(lev)
+		}
+	case int:
+		if lev > 0 {
+			return lev
+		}
+	}
+	return fallback
+}
@@ -0,0 +1,295 @@
+package trader
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"aspen/decision"
+	"aspen/logger"
+)
+
+// priceRef 下单参考价格：做出决策时看到的价格、取得时间和决策来源
+type priceRef struct {
+	Price  float64
+	At     time.Time
+	Source string // fillSourceDecision / fillSourceStop
+}
+
+// 成交来源
+const (
+	fillSourceDecision = "decision" // AI决策执行（定时周期和手动触发）
+	fillSourceStop     = "stop"     // 回撤止盈、交易暂停窗口清仓、粉尘清理等止损管理
+)
+
+// SlippageSample 一次成交的滑点记录
+type SlippageSample struct {
+	Symbol         string    `json:"symbol"`
+	Action         string    `json:"action"` // open_long / open_short / close_long / close_short
+	Source         string    `json:"source"`
+	ReferencePrice float64   `json:"reference_price"` // 决策参考价格（周期快照价格）
+	FillPrice      float64   `json:"fill_price"`      // 实际成交均价
+	SlippageBps    float64   `json:"slippage_bps"`    // 有符号滑点（正数表示成交价差于参考价格）
+	LatencyMs      int64     `json:"latency_ms"`      // 参考价格取得到成交的耗时
+	Timestamp      time.Time `json:"timestamp"`
+}
+
+// slippageBps 计算有符号滑点（bps）：买入成交价高于参考价、卖出成交价低于参考价为正（不利）
+func slippageBps(action string, reference, fill float64) float64 {
+	if reference <= 0 || fill <= 0 {
+		return 0
+	}
+	bps := (fill - reference) / reference * 10000
+	switch action {
+	case "open_short", "close_long": // 卖出
+		return -bps
+	}
+	return bps
+}
+
+// orderFillPrice 从订单结果中读取成交均价（交易所返回 avgPrice，模拟盘返回 price），无法获取时返回 0
+func orderFillPrice(order map[string]interface{}) float64 {
+	switch v := order["avgPrice"].(type) {
+	case float64:
+		if v > 0 {
+			return v
+		}
+	case string:
+		if f, err := strconv.ParseFloat(v, 64); err == nil && f > 0 {
+			return f
+		}
+	}
+	if v, ok := order["price"].(float64); ok && v > 0 {
+		return v
+	}
+	return 0
+}
+
+// SlippageStats 一类动作的滑点统计
+type SlippageStats struct {
+	Action       string  `json:"action,omitempty"`
+	Count        int     `json:"count"`
+	AvgBps       float64 `json:"avg_bps"`
+	P95Bps       float64 `json:"p95_bps"`
+	AvgLatencyMs float64 `json:"avg_latency_ms"`
+}
+
+// SlippageSummary 交易员的滑点汇总
+type SlippageSummary struct {
+	SlippageStats
+	ByAction []SlippageStats `json:"by_action"`
+	Warning  string          `json:"warning,omitempty"` // 平均滑点超过阈值时的提示
+}
+
+// slippageMinSamplesForWarning 样本数达到该值后才提示滑点过高
+const slippageMinSamplesForWarning = 5
+
+// summarizeSlippage 汇总滑点样本（按动作类型分组，固定顺序）
+func summarizeSlippage(samples []SlippageSample, warnBps float64) SlippageSummary {
+	summary := SlippageSummary{SlippageStats: slippageStats("", samples), ByAction: []SlippageStats{}}
+	for _, action := range []string{"open_long", "open_short", "close_long", "close_short"} {
+		var group []SlippageSample
+		for _, s := range samples {
+			if s.Action == action {
+				group = append(group, s)
+			}
+		}
+		if len(group) > 0 {
+			summary.ByAction = append(summary.ByAction, slippageStats(action, group))
+		}
+	}
+	if warnBps > 0 && summary.Count >= slippageMinSamplesForWarning && summary.AvgBps > warnBps {
+		summary.Warning = fmt.Sprintf("平均滑点 %.1f bps 超过 %.1f bps（决策到成交平均 %.1f 秒），建议使用响应更快的模型或减少交易币种",
+			summary.AvgBps, warnBps, summary.AvgLatencyMs/1000)
+	}
+	return summary
+}
+
+// slippageStats 计算一组样本的平均值和 P95（最近秩法）
+func slippageStats(action string, samples []SlippageSample) SlippageStats {
+	stats := SlippageStats{Action: action, Count: len(samples)}
+	if len(samples) == 0 {
+		return stats
+	}
+	values := make([]float64, len(samples))
+	var sumBps, sumLatency float64
+	for i, s := range samples {
+		values[i] = s.SlippageBps
+		sumBps += s.SlippageBps
+		sumLatency += float64(s.LatencyMs)
+	}
+	sort.Float64s(values)
+	stats.AvgBps = sumBps / float64(len(samples))
+	stats.AvgLatencyMs = sumLatency / float64(len(samples))
+	stats.P95Bps = values[int(math.Ceil(0.95*float64(len(values))))-1]
+	return stats
+}
+
+// slippageSampleLimit 保留的滑点样本数
+const slippageSampleLimit = 500
+
+// slippageTracker 滑点样本（追加写入决策日志目录下的 slippage/fills.jsonl，重启后恢复；path 为空时仅保存在内存）
+type slippageTracker struct {
+	mu      sync.Mutex
+	path    string
+	loaded  bool
+	samples []SlippageSample // 从旧到新
+}
+
+// loadLocked 首次使用时从文件加载最近的样本（调用方已加锁）
+func (t *slippageTracker) loadLocked() {
+	if t.loaded {
+		return
+	}
+	t.loaded = true
+	if t.path == "" {
+		return
+	}
+	f, err := os.Open(t.path)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	total := 0
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var s SlippageSample
+		if err := json.Unmarshal(scanner.Bytes(), &s); err != nil {
+			continue
+		}
+		t.samples = append(t.samples, s)
+		total++
+	}
+	if len(t.samples) > slippageSampleLimit {
+		t.samples = t.samples[len(t.samples)-slippageSampleLimit:]
+	}
+	// 文件中的旧样本过多时重写，避免文件无限增长
+	if total > 2*slippageSampleLimit {
+		t.rewriteLocked()
+	}
+}
+
+// rewriteLocked 用内存中的样本重写文件（调用方已加锁）
+func (t *slippageTracker) rewriteLocked() {
+	var data []byte
+	for _, s := range t.samples {
+		line, _ := json.Marshal(s)
+		data = append(append(data, line...), '\n')
+	}
+	if err := os.WriteFile(t.path, data, 0600); err != nil {
+		logger.Warnf("⚠️  重写滑点记录失败: %v", err)
+	}
+}
+
+// add 记录一个样本
+func (t *slippageTracker) add(s SlippageSample) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.loadLocked()
+	t.samples = append(t.samples, s)
+	if len(t.samples) > slippageSampleLimit {
+		t.samples = t.samples[len(t.samples)-slippageSampleLimit:]
+	}
+	if t.path == "" {
+		return
+	}
+
+	if err := os.MkdirAll(filepath.Dir(t.path), 0700); err != nil {
+		logger.Warnf("⚠️  创建滑点记录目录失败: %v", err)
+		return
+	}
+	line, _ := json.Marshal(s)
+	f, err := os.OpenFile(t.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		logger.Warnf("⚠️  写入滑点记录失败: %v", err)
+		return
+	}
+	defer f.Close()
+	f.Write(append(line, '\n'))
+}
+
+// all 获取全部样本（从旧到新）
+func (t *slippageTracker) all() []SlippageSample {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.loadLocked()
+	return append([]SlippageSample(nil), t.samples...)
+}
+
+// slippageTrackerFor 获取交易员的滑点记录（首次使用时创建）
+func (at *AutoTrader) slippageTrackerFor() *slippageTracker {
+	at.slippageOnce.Do(func() {
+		at.slippage = &slippageTracker{}
+		if at.decisionLogger != nil {
+			at.slippage.path = filepath.Join(at.decisionLogger.Dir(), "slippage", "fills.jsonl")
+		}
+	})
+	return at.slippage
+}
+
+// setDecisionPrices 记录本周期AI决策使用的价格快照（执行决策时作为滑点参考价格），nil 表示清除
+func (at *AutoTrader) setDecisionPrices(ctx *decision.Context, snapshotAt time.Time) {
+	at.decisionPricesMutex.Lock()
+	defer at.decisionPricesMutex.Unlock()
+	at.decisionPrices = nil
+	if ctx == nil {
+		return
+	}
+	at.decisionPrices = make(map[string]priceRef, len(ctx.MarketDataMap))
+	for symbol, data := range ctx.MarketDataMap {
+		if data != nil && data.CurrentPrice > 0 {
+			at.decisionPrices[symbol] = priceRef{Price: data.CurrentPrice, At: snapshotAt, Source: fillSourceDecision}
+		}
+	}
+}
+
+// decisionRef 执行决策时的参考价格：优先使用本周期快照中的价格，没有快照时使用执行前获取的当前价格
+func (at *AutoTrader) decisionRef(symbol string, currentPrice float64) priceRef {
+	at.decisionPricesMutex.Lock()
+	ref, ok := at.decisionPrices[symbol]
+	at.decisionPricesMutex.Unlock()
+	if ok {
+		return ref
+	}
+	return priceRef{Price: currentPrice, At: time.Now(), Source: fillSourceDecision}
+}
+
+// recordFill 记录一次成交的滑点（所有下单路径统一经过 openLong/openShort/closeLong/closeShort）
+func (at *AutoTrader) recordFill(action, symbol string, ref priceRef, order map[string]interface{}) {
+	fill := orderFillPrice(order)
+	if fill <= 0 || ref.Price <= 0 {
+		return // 交易所未返回成交均价（如 Hyperliquid），无法统计
+	}
+	now := time.Now()
+	sample := SlippageSample{
+		Symbol:         symbol,
+		Action:         action,
+		Source:         ref.Source,
+		ReferencePrice: ref.Price,
+		FillPrice:      fill,
+		SlippageBps:    slippageBps(action, ref.Price, fill),
+		Timestamp:      now,
+	}
+	if !ref.At.IsZero() {
+		sample.LatencyMs = now.Sub(ref.At).Milliseconds()
+	}
+	at.slippageTrackerFor().add(sample)
+	if at.metricsRecorder != nil {
+		at.metricsRecorder.RecordSlippage(action, sample.SlippageBps, float64(sample.LatencyMs)/1000)
+	}
+	logger.Infof("  📐 %s %s 成交滑点: 参考价 %.6g → 成交价 %.6g (%+.1f bps, 耗时 %d ms)",
+		symbol, action, ref.Price, fill, sample.SlippageBps, sample.LatencyMs)
+}
+
+// GetSlippageSummary 获取滑点汇总（平均值和 P95，按动作类型分组）
+func (at *AutoTrader) GetSlippageSummary() SlippageSummary {
+	return summarizeSlippage(at.slippageTrackerFor().all(), at.config.SlippageWarningBps)
+}
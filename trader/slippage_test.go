@@ -0,0 +1,141 @@
+package trader
+
+import (
+	"testing"
+	"time"
+
+	"aspen/decision"
+	"aspen/logger"
+	"aspen/market"
+
+	"github.com/agiledragon/gomonkey/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fillMockTrader 按指定价格成交的模拟交易所（返回 avgPrice）
+type fillMockTrader struct {
+	*MockTrader
+	fills map[string]float64 // symbol -> 成交均价
+}
+
+func (m *fillMockTrader) fill(order map[string]interface{}, err error) (map[string]interface{}, error) {
+	if err != nil {
+		return nil, err
+	}
+	order["avgPrice"] = m.fills[order["symbol"].(string)]
+	return order, nil
+}
+
+func (m *fillMockTrader) OpenLong(symbol string, quantity float64, leverage int) (map[string]interface{}, error) {
+	return m.fill(m.MockTrader.OpenLong(symbol, quantity, leverage))
+}
+
+func (m *fillMockTrader) OpenShort(symbol string, quantity float64, leverage int) (map[string]interface{}, error) {
+	return m.fill(m.MockTrader.OpenShort(symbol, quantity, leverage))
+}
+
+func (m *fillMockTrader) CloseLong(symbol string, quantity float64) (map[string]interface{}, error) {
+	return m.fill(m.MockTrader.CloseLong(symbol, quantity))
+}
+
+func (m *fillMockTrader) CloseShort(symbol string, quantity float64) (map[string]interface{}, error) {
+	return m.fill(m.MockTrader.CloseShort(symbol, quantity))
+}
+
+func TestSlippageBps(t *testing.T) {
+	// 买入（开多、平空）成交价高于参考价为不利
+	assert.InDelta(t, 10, slippageBps("open_long", 100, 100.1), 1e-9)
+	assert.InDelta(t, 10, slippageBps("close_short", 100, 100.1), 1e-9)
+	// 卖出（开空、平多）成交价低于参考价为不利
+	assert.InDelta(t, 10, slippageBps("open_short", 100, 99.9), 1e-9)
+	assert.InDelta(t, -10, slippageBps("close_long", 100, 100.1), 1e-9)
+	assert.Zero(t, slippageBps("open_long", 0, 100))
+
+	assert.Equal(t, 101.5, orderFillPrice(map[string]interface{}{"avgPrice": 101.5, "price": 99.0}))
+	assert.Equal(t, 101.5, orderFillPrice(map[string]interface{}{"avgPrice": "101.5"}))
+	assert.Equal(t, 99.0, orderFillPrice(map[string]interface{}{"avgPrice": 0.0, "price": 99.0}), "模拟盘返回 price")
+	assert.Zero(t, orderFillPrice(map[string]interface{}{"price": "99.0"}))
+}
+
+func TestSummarizeSlippage(t *testing.T) {
+	var samples []SlippageSample
+	for i := 1; i <= 20; i++ {
+		samples = append(samples, SlippageSample{Action: "open_long", SlippageBps: float64(i), LatencyMs: 1000})
+	}
+	samples = append(samples, SlippageSample{Action: "close_short", SlippageBps: -4, LatencyMs: 3000})
+
+	summary := summarizeSlippage(samples, 0)
+	assert.Equal(t, 21, summary.Count)
+	assert.InDelta(t, 206.0/21, summary.AvgBps, 1e-9)
+	assert.Empty(t, summary.Warning)
+	require.Len(t, summary.ByAction, 2)
+	assert.Equal(t, SlippageStats{Action: "open_long", Count: 20, AvgBps: 10.5, P95Bps: 19, AvgLatencyMs: 1000}, summary.ByAction[0])
+	assert.Equal(t, SlippageStats{Action: "close_short", Count: 1, AvgBps: -4, P95Bps: -4, AvgLatencyMs: 3000}, summary.ByAction[1])
+
+	// 平均滑点超过阈值时提示
+	assert.NotEmpty(t, summarizeSlippage(samples, 5).Warning)
+	assert.Empty(t, summarizeSlippage(samples, 15).Warning)
+	assert.Empty(t, summarizeSlippage(samples[:3], 1).Warning, "样本不足时不提示")
+}
+
+// TestSlippage_RecordedForEveryExecutionPath 测试AI决策和止损管理的成交都按参考价格记录滑点，并在重启后恢复
+func TestSlippage_RecordedForEveryExecutionPath(t *testing.T) {
+	// 执行时行情已从快照的 50000 涨到 50100
+	patches := gomonkey.ApplyFunc(market.Get, func(symbol string) (*market.Data, error) {
+		return &market.Data{Symbol: symbol, CurrentPrice: 50100}, nil
+	})
+	defer patches.Reset()
+
+	logDir := t.TempDir()
+	exchange := &fillMockTrader{
+		MockTrader: &MockTrader{positions: []map[string]interface{}{}},
+		fills:      map[string]float64{"BTCUSDT": 50100, "ETHUSDT": 3003},
+	}
+	at := newJournalTestTrader(logDir, exchange)
+	at.config.SlippageWarningBps = 15
+
+	// AI决策：参考价格为AI看到的快照价格
+	snapshotAt := time.Now().Add(-2 * time.Second)
+	at.setDecisionPrices(&decision.Context{MarketDataMap: map[string]*market.Data{
+		"BTCUSDT": {Symbol: "BTCUSDT", CurrentPrice: 50000},
+	}}, snapshotAt)
+	d := &decision.Decision{Symbol: "BTCUSDT", Action: "open_long", Leverage: 5, PositionSizeUSD: 1000}
+	require.NoError(t, at.executeDecisionWithRecord(d, &logger.DecisionAction{}))
+	at.setDecisionPrices(nil, time.Time{})
+
+	// 止损管理：参考价格为触发时的标记价格
+	require.NoError(t, at.emergencyClosePosition("ETHUSDT", "short", 3000))
+
+	summary := at.GetSlippageSummary()
+	require.Equal(t, 2, summary.Count)
+	require.Len(t, summary.ByAction, 2)
+	assert.Equal(t, "open_long", summary.ByAction[0].Action)
+	assert.InDelta(t, 20, summary.ByAction[0].AvgBps, 1e-9)
+	assert.GreaterOrEqual(t, summary.ByAction[0].AvgLatencyMs, 2000.0)
+	assert.Equal(t, "close_short", summary.ByAction[1].Action)
+	assert.InDelta(t, 10, summary.ByAction[1].AvgBps, 1e-9)
+
+	samples := at.slippageTrackerFor().all()
+	assert.Equal(t, fillSourceDecision, samples[0].Source)
+	assert.Equal(t, 50000.0, samples[0].ReferencePrice)
+	assert.Equal(t, fillSourceStop, samples[1].Source)
+
+	// 样本不足时不提示，达到样本数后平均滑点 15+ bps 触发提示
+	assert.Empty(t, at.GetStatus()["slippage_warning"])
+	for i := 0; i < 3; i++ {
+		require.NoError(t, at.emergencyClosePosition("ETHUSDT", "short", 3000))
+	}
+	assert.Empty(t, at.GetSlippageSummary().Warning, "平均滑点 12 bps 未超过 15 bps")
+	at.config.SlippageWarningBps = 10
+	assert.NotEmpty(t, at.GetStatus()["slippage_warning"])
+
+	// 重启后从决策日志目录恢复
+	restarted := newJournalTestTrader(logDir, exchange)
+	assert.Equal(t, 5, restarted.GetSlippageSummary().Count)
+
+	// 交易所未返回成交均价时不记录
+	noFill := newJournalTestTrader(t.TempDir(), &MockTrader{})
+	require.NoError(t, noFill.emergencyClosePosition("ETHUSDT", "long", 3000))
+	assert.Zero(t, noFill.GetSlippageSummary().Count)
+}
@@ -0,0 +1,38 @@
+package trader
+
+import (
+	"fmt"
+
+	"aspen/logger"
+	"aspen/market"
+)
+
+// bookTickerFunc 获取最优买卖价
+type bookTickerFunc func(symbol string) (*market.BookTicker, error)
+
+// checkSpread 开仓前检查买卖价差，超过 MaxSpreadBps 时拒绝开仓（价差过大开仓即亏损）
+// 无法获取盘口时只记录警告并放行
+func (at *AutoTrader) checkSpread(symbol string) error {
+	maxBps := at.config.MaxSpreadBps
+	if maxBps <= 0 {
+		return nil
+	}
+
+	fetch := at.bookTickerFn
+	if fetch == nil {
+		fetch = market.NewAPIClientFor(at.config.DataSource).GetBookTicker
+	}
+	book, err := fetch(symbol)
+	if err != nil {
+		logger.Warnf("⚠️  无法获取 %s 盘口，跳过价差检查: %v", symbol, err)
+		return nil
+	}
+
+	spread := book.SpreadBps()
+	if spread > maxBps {
+		return reject(RejectMaxSpread, fmt.Errorf("❌ %s 买卖价差 %.1f bps（买一 %.6f / 卖一 %.6f）超过上限 %.1f bps，拒绝开仓",
+			symbol, spread, book.BidPrice, book.AskPrice, maxBps))
+	}
+	logger.Infof("  ✓ %s 买卖价差 %.1f bps（上限 %.1f bps）", symbol, spread, maxBps)
+	return nil
+}
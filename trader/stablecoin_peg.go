@@ -0,0 +1,47 @@
+package trader
+
+import (
+	"fmt"
+	"math"
+
+	"aspen/logger"
+)
+
+// StablecoinPegConfig 稳定币脱锚保护（默认关闭）
+// 通过交易所读取稳定币交易对价格（如 USDCUSDT），偏离 1 超过 MaxDeviationPct% 时禁止新开仓，
+// 避免脱锚期间以失真的名义价值和盈亏继续建仓；平仓和止盈止损不受影响
+type StablecoinPegConfig struct {
+	Enabled         bool
+	Symbol          string  // 用于检测锚定的稳定币交易对
+	MaxDeviationPct float64 // 允许偏离 1 的最大百分比
+}
+
+// DefaultStablecoinPegConfig 默认稳定币脱锚保护参数（未启用）
+func DefaultStablecoinPegConfig() StablecoinPegConfig {
+	return StablecoinPegConfig{
+		Symbol:          "USDCUSDT",
+		MaxDeviationPct: 0.5,
+	}
+}
+
+// checkStablecoinPeg 开仓前检查稳定币是否脱锚
+// 无法获取价格时只记录警告并放行（检测是可选的保护，不应因行情接口故障阻断交易）
+func (at *AutoTrader) checkStablecoinPeg() error {
+	cfg := at.config.StablecoinPeg
+	if !cfg.Enabled || cfg.Symbol == "" || cfg.MaxDeviationPct <= 0 {
+		return nil
+	}
+
+	price, err := at.trader.GetMarketPrice(cfg.Symbol)
+	if err != nil || price <= 0 {
+		logger.Warnf("⚠️  无法获取 %s 价格，跳过稳定币脱锚检查 (price: %.4f, err: %v)", cfg.Symbol, price, err)
+		return nil
+	}
+
+	deviationPct := math.Abs(price-1) * 100
+	if deviationPct > cfg.MaxDeviationPct {
+		return reject(RejectStablecoinDepeg, fmt.Errorf("❌ 稳定币脱锚保护：%s 价格 %.4f 偏离 %.2f%%（上限 %.2f%%），禁止新开仓",
+			cfg.Symbol, price, deviationPct, cfg.MaxDeviationPct))
+	}
+	return nil
+}
@@ -0,0 +1,28 @@
+package trader
+
+import (
+	"fmt"
+
+	"aspen/logger"
+)
+
+// cancelStaleOrders 撤销挂单时间超过 StaleOrderMaxAge 仍未成交的限价挂单并释放冻结的资金，返回执行日志
+// （目前只有模拟仓支持限价挂单，实盘交易器只下市价单和止盈止损单）
+func (at *AutoTrader) cancelStaleOrders() []string {
+	maxAge := at.config.StaleOrderMaxAge
+	if maxAge <= 0 {
+		return nil
+	}
+	pt, ok := at.trader.(*PaperTrader)
+	if !ok {
+		return nil
+	}
+
+	var logs []string
+	for _, order := range pt.CancelStaleLimitOrders(maxAge) {
+		logger.Infof("🧹 %s %s 限价单挂单超过 %v 未成交，已撤单并释放 %.2f %s", order.Symbol, order.Side, maxAge, order.Reserved, pt.MarginAsset())
+		logs = append(logs, fmt.Sprintf("🧹 限价单 %s %s（数量 %.6f，限价 %.4f）挂单超过 %v 未成交，已撤单并释放 %.2f %s",
+			order.Symbol, order.Side, order.Quantity, order.Price, maxAge, order.Reserved, pt.MarginAsset()))
+	}
+	return logs
+}
@@ -0,0 +1,291 @@
+package trader
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"aspen/decision"
+	"aspen/logger"
+)
+
+// symbolHistoryLimit 每个币种保留的最近交易动作数
+const symbolHistoryLimit = 5
+
+// symbolHistoryLookback 启动时从决策日志恢复币种动作记录所读取的周期数
+const symbolHistoryLookback = 200
+
+// ChurnGuardConfig 防反复开平仓检查（默认关闭）
+// 在 Cycles 个周期内刚对同一币种同方向开仓或平仓后，再次开同方向仓位会被拒绝，
+// 除非决策信心度不低于 MinConfidence
+type ChurnGuardConfig struct {
+	Enabled       bool
+	Cycles        int // 检查的周期窗口
+	MinConfidence int // 窗口内允许再次开仓所需的最低信心度（0-100）
+}
+
+// DefaultChurnGuardConfig 默认防反复开平仓参数（未启用）
+func DefaultChurnGuardConfig() ChurnGuardConfig {
+	return ChurnGuardConfig{
+		Cycles:        3,
+		MinConfidence: 85,
+	}
+}
+
+// SymbolAction 单个币种的一次交易动作
+type SymbolAction struct {
+	Symbol    string    `json:"symbol"`
+	Action    string    `json:"action"`
+	Cycle     int       `json:"cycle"` // 所在周期编号（从决策日志恢复的记录为非正数，按周期顺序递减）
+	Timestamp time.Time `json:"timestamp"`
+	Price     float64   `json:"price"`
+	PnLPct    float64   `json:"pnl_pct,omitempty"` // 平仓时的收益率
+}
+
+// side 动作对应的持仓方向（partial_close 无方向时返回空）
+func (a SymbolAction) side() string {
+	switch {
+	case strings.HasSuffix(a.Action, "_long"):
+		return "long"
+	case strings.HasSuffix(a.Action, "_short"):
+		return "short"
+	}
+	return ""
+}
+
+// symbolHistory 各币种最近的交易动作（零值可用）
+type symbolHistory struct {
+	mu      sync.Mutex
+	actions map[string][]SymbolAction // key: symbol，从旧到新
+}
+
+// record 记录一次交易动作，每个币种只保留最近 symbolHistoryLimit 条
+func (h *symbolHistory) record(a SymbolAction) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.actions == nil {
+		h.actions = make(map[string][]SymbolAction)
+	}
+	list := append(h.actions[a.Symbol], a)
+	if len(list) > symbolHistoryLimit {
+		list = list[len(list)-symbolHistoryLimit:]
+	}
+	h.actions[a.Symbol] = list
+}
+
+// recent 获取币种最近的交易动作（从旧到新）
+func (h *symbolHistory) recent(symbol string) []SymbolAction {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]SymbolAction(nil), h.actions[symbol]...)
+}
+
+// all 获取所有币种的交易动作
+func (h *symbolHistory) all() []SymbolAction {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	var out []SymbolAction
+	for _, list := range h.actions {
+		out = append(out, list...)
+	}
+	return out
+}
+
+// isTrackedAction 是否为需要记录的交易动作
+func isTrackedAction(action string) bool {
+	switch action {
+	case "open_long", "open_short", "add_to_position", "close_long", "close_short", "partial_close":
+		return true
+	}
+	return false
+}
+
+// restoreFromRecords 从决策日志恢复各币种动作记录（重启后防反复开平仓检查仍然有效）
+// 日志中的周期编号在每次启动时重新计数，因此按记录顺序换算为相对周期：最后一条记录为周期 0
+func (h *symbolHistory) restoreFromRecords(records []*logger.DecisionRecord) {
+	for i, record := range records {
+		cycle := i - len(records) + 1
+		for _, action := range record.Decisions {
+			if !action.Success || !isTrackedAction(action.Action) {
+				continue
+			}
+			h.record(SymbolAction{
+				Symbol:    action.Symbol,
+				Action:    action.Action,
+				Cycle:     cycle,
+				Timestamp: action.Timestamp,
+				Price:     action.Price,
+				PnLPct:    action.PnLPct,
+			})
+		}
+	}
+}
+
+// recordSymbolAction 成功执行交易动作后记录到币种动作历史；平仓时根据本周期持仓记录收益率
+func (at *AutoTrader) recordSymbolAction(actionRecord *logger.DecisionAction, positions []decision.PositionInfo) {
+	if !isTrackedAction(actionRecord.Action) {
+		return
+	}
+
+	if strings.HasPrefix(actionRecord.Action, "close_") || actionRecord.Action == "partial_close" {
+		side := strings.TrimPrefix(actionRecord.Action, "close_")
+		for _, pos := range positions {
+			if pos.Symbol == actionRecord.Symbol && (actionRecord.Action == "partial_close" || pos.Side == side) {
+				actionRecord.PnLPct = pos.UnrealizedPnLPct
+				break
+			}
+		}
+	}
+
+	at.symbolHistory.record(SymbolAction{
+		Symbol:    actionRecord.Symbol,
+		Action:    actionRecord.Action,
+		Cycle:     at.callCount,
+		Timestamp: actionRecord.Timestamp,
+		Price:     actionRecord.Price,
+		PnLPct:    actionRecord.PnLPct,
+	})
+}
+
+// recentSymbolActions 构建提示词使用的近期币种动作
+func (at *AutoTrader) recentSymbolActions() []decision.RecentSymbolAction {
+	actions := at.symbolHistory.all()
+	out := make([]decision.RecentSymbolAction, 0, len(actions))
+	for _, a := range actions {
+		out = append(out, decision.RecentSymbolAction{
+			Symbol:    a.Symbol,
+			Action:    a.Action,
+			CyclesAgo: at.callCount - a.Cycle,
+			PnLPct:    a.PnLPct,
+		})
+	}
+	return out
+}
+
+// checkChurnGuard 开仓前检查是否刚对同一币种同方向开仓或平仓，结论写入 actionRecord.GuardVerdict
+func (at *AutoTrader) checkChurnGuard(d *decision.Decision, actionRecord *logger.DecisionAction) error {
+	cfg := at.config.ChurnGuard
+	if !cfg.Enabled || cfg.Cycles <= 0 {
+		return nil
+	}
+
+	side := strings.TrimPrefix(d.Action, "open_")
+	history := at.symbolHistory.recent(d.Symbol)
+	for i := len(history) - 1; i >= 0; i-- {
+		prev := history[i]
+		cyclesAgo := at.callCount - prev.Cycle
+		if cyclesAgo > cfg.Cycles {
+			break
+		}
+		if prev.side() != side {
+			continue
+		}
+
+		desc := fmt.Sprintf("%s %d 个周期前刚%s", d.Symbol, cyclesAgo, recentActionDesc(prev))
+		if cfg.MinConfidence > 0 && d.Confidence >= cfg.MinConfidence {
+			actionRecord.GuardVerdict = fmt.Sprintf("放行：%s，信心度 %d ≥ %d", desc, d.Confidence, cfg.MinConfidence)
+			logger.Infof("  ℹ️  防反复开平仓：%s", actionRecord.GuardVerdict)
+			return nil
+		}
+		actionRecord.GuardVerdict = fmt.Sprintf("拒绝：%s（%d 个周期内），信心度 %d < %d", desc, cfg.Cycles, d.Confidence, cfg.MinConfidence)
+		return reject(RejectChurnGuard, fmt.Errorf("❌ 防反复开平仓：%s", actionRecord.GuardVerdict))
+	}
+	return nil
+}
+
+// recentActionDesc 动作描述（平仓附带收益率）
+func recentActionDesc(a SymbolAction) string {
+	labels := map[string]string{
+		"open_long":       "开多",
+		"open_short":      "开空",
+		"add_to_position": "加仓",
+		"close_long":      "平多",
+		"close_short":     "平空",
+	}
+	desc := labels[a.Action]
+	if strings.HasPrefix(a.Action, "close_") {
+		desc += fmt.Sprintf("（%+.2f%%）", a.PnLPct)
+	}
+	return desc
+}
+
+// SymbolHistoryEntry 币种时间线条目（决策与完整交易合并）
+type SymbolHistoryEntry struct {
+	Type      string    `json:"type"` // "decision" 或 "trade"
+	Timestamp time.Time `json:"timestamp"`
+	Cycle     int       `json:"cycle,omitempty"`
+
+	// 决策字段
+	Action       string  `json:"action,omitempty"`
+	Success      bool    `json:"success"`
+	Error        string  `json:"error,omitempty"`
+	Price        float64 `json:"price,omitempty"`
+	Note         string  `json:"note,omitempty"`
+	GuardVerdict string  `json:"guard_verdict,omitempty"`
+
+	// 交易字段（开仓到平仓）
+	Side       string    `json:"side,omitempty"`
+	OpenTime   time.Time `json:"open_time,omitempty"`
+	OpenPrice  float64   `json:"open_price,omitempty"`
+	ClosePrice float64   `json:"close_price,omitempty"`
+	PnL        float64   `json:"pnl,omitempty"`
+	PnLPct     float64   `json:"pnl_pct,omitempty"`
+	Duration   string    `json:"duration,omitempty"`
+}
+
+// GetSymbolHistory 获取单个币种的时间线（最近 lookback 个周期的决策和完整交易，从旧到新）
+func (at *AutoTrader) GetSymbolHistory(symbol string, lookback int) ([]SymbolHistoryEntry, error) {
+	records, err := at.decisionLogger.GetLatestRecords(lookback)
+	if err != nil {
+		return nil, fmt.Errorf("获取决策日志失败: %w", err)
+	}
+
+	entries := []SymbolHistoryEntry{}
+	for _, record := range records {
+		for _, action := range record.Decisions {
+			if action.Symbol != symbol {
+				continue
+			}
+			ts := action.Timestamp
+			if ts.IsZero() {
+				ts = record.Timestamp
+			}
+			entries = append(entries, SymbolHistoryEntry{
+				Type:         "decision",
+				Timestamp:    ts,
+				Cycle:        record.CycleNumber,
+				Action:       action.Action,
+				Success:      action.Success,
+				Error:        action.Error,
+				Price:        action.Price,
+				Note:         action.Note,
+				GuardVerdict: action.GuardVerdict,
+				PnLPct:       action.PnLPct,
+			})
+		}
+	}
+
+	trades, err := at.decisionLogger.GetSymbolTrades(lookback, symbol)
+	if err != nil {
+		return nil, fmt.Errorf("分析交易记录失败: %w", err)
+	}
+	for _, trade := range trades {
+		entries = append(entries, SymbolHistoryEntry{
+			Type:       "trade",
+			Timestamp:  trade.CloseTime,
+			Side:       trade.Side,
+			OpenTime:   trade.OpenTime,
+			OpenPrice:  trade.OpenPrice,
+			ClosePrice: trade.ClosePrice,
+			PnL:        trade.PnL,
+			PnLPct:     trade.PnLPct,
+			Duration:   trade.Duration,
+			Note:       trade.CloseNote,
+		})
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].Timestamp.Before(entries[j].Timestamp) })
+	return entries, nil
+}
@@ -0,0 +1,206 @@
+package trader
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"aspen/logger"
+)
+
+// 交易事件类型
+const (
+	WebhookEventOpen        = "open"        // 开仓成交
+	WebhookEventClose       = "close"       // 平仓成交（含部分平仓、紧急平仓）
+	WebhookEventLiquidation = "liquidation" // 强平（模拟仓）
+	WebhookEventRisk        = "risk"        // 风控事件（动态风控禁止开仓、回撤平仓）
+)
+
+// Webhook 请求头
+const (
+	WebhookSignatureHeader = "X-Aspen-Signature" // sha256=<HMAC-SHA256(secret, body) 十六进制>
+	WebhookEventHeader     = "X-Aspen-Event"     // 事件类型
+)
+
+// webhookMaxAttempts 单个事件最多发送次数（含首次）
+const webhookMaxAttempts = 4
+
+// webhookBaseBackoff 首次重试的等待时间，之后每次翻倍
+const webhookBaseBackoff = time.Second
+
+// webhookTimeout 单次请求超时
+const webhookTimeout = 10 * time.Second
+
+// WebhookConfig 用户配置的交易事件 Webhook（URL 为空表示不推送，Secret 为空时不签名）
+type WebhookConfig struct {
+	URL    string
+	Secret string
+}
+
+// Enabled 是否配置了 Webhook
+func (c WebhookConfig) Enabled() bool {
+	return strings.TrimSpace(c.URL) != ""
+}
+
+// WebhookEvent 推送的交易事件
+type WebhookEvent struct {
+	Event      string    `json:"event"` // open / close / liquidation / risk
+	TraderID   string    `json:"trader_id"`
+	TraderName string    `json:"trader_name"`
+	UserID     string    `json:"user_id"`
+	Symbol     string    `json:"symbol,omitempty"`
+	Side       string    `json:"side,omitempty"`   // long / short
+	Action     string    `json:"action,omitempty"` // open_long / close_short 等
+	Quantity   float64   `json:"quantity,omitempty"`
+	Price      float64   `json:"price,omitempty"`
+	Leverage   int       `json:"leverage,omitempty"`
+	PnL        float64   `json:"pnl,omitempty"` // 平仓/强平的已实现盈亏（交易所返回时）
+	OrderID    string    `json:"order_id,omitempty"`
+	Reason     string    `json:"reason,omitempty"` // 风控事件和回撤平仓的原因
+	Timestamp  time.Time `json:"timestamp"`
+}
+
+// SignWebhookPayload 计算请求体的 HMAC-SHA256 签名（十六进制）
+func SignWebhookPayload(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// webhookSender 发送交易事件，失败时按指数退避重试（次数有限）
+type webhookSender struct {
+	cfg     WebhookConfig
+	client  *http.Client
+	sleepFn func(time.Duration) // 重试等待（测试可替换）
+}
+
+// newWebhookSender 创建发送器（未配置 URL 时返回 nil）
+func newWebhookSender(cfg WebhookConfig) *webhookSender {
+	if !cfg.Enabled() {
+		return nil
+	}
+	cfg.URL = strings.TrimSpace(cfg.URL)
+	return &webhookSender{cfg: cfg, client: &http.Client{Timeout: webhookTimeout}, sleepFn: time.Sleep}
+}
+
+// deliver 发送事件，返回最后一次失败的原因。4xx（429 除外）视为对方拒收，不重试
+func (s *webhookSender) deliver(event WebhookEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("序列化事件失败: %w", err)
+	}
+
+	backoff := webhookBaseBackoff
+	for attempt := 1; ; attempt++ {
+		retry, err := s.post(event.Event, body)
+		if err == nil {
+			return nil
+		}
+		if !retry || attempt >= webhookMaxAttempts {
+			return fmt.Errorf("发送 %d 次后失败: %w", attempt, err)
+		}
+		s.sleepFn(backoff)
+		backoff *= 2
+	}
+}
+
+// post 发送一次请求，返回失败时是否值得重试
+func (s *webhookSender) post(eventType string, body []byte) (bool, error) {
+	req, err := http.NewRequest(http.MethodPost, s.cfg.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(WebhookEventHeader, eventType)
+	if s.cfg.Secret != "" {
+		req.Header.Set(WebhookSignatureHeader, "sha256="+SignWebhookPayload(s.cfg.Secret, body))
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	retry := resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests
+	return retry, fmt.Errorf("HTTP %d", resp.StatusCode)
+}
+
+// SetWebhook 更新交易事件 Webhook 配置（URL 为空时停止推送）
+func (at *AutoTrader) SetWebhook(cfg WebhookConfig) {
+	at.webhookMu.Lock()
+	at.webhook = newWebhookSender(cfg)
+	at.webhookMu.Unlock()
+}
+
+// emitWebhook 异步推送交易事件（未配置 Webhook 时为空操作，不阻塞交易流程）
+func (at *AutoTrader) emitWebhook(event WebhookEvent) {
+	at.webhookMu.RLock()
+	sender := at.webhook
+	at.webhookMu.RUnlock()
+	if sender == nil {
+		return
+	}
+
+	event.TraderID, event.TraderName, event.UserID = at.id, at.name, at.userID
+	if event.Timestamp.IsZero() {
+		event.Timestamp = time.Now().UTC()
+	}
+	go func() {
+		if err := sender.deliver(event); err != nil {
+			logger.Warnf("⚠️ [%s] Webhook 推送 %s 事件失败: %v", at.name, event.Event, err)
+		}
+	}()
+}
+
+// onPaperLiquidation 模拟仓强平时推送事件（在模拟仓持有锁时调用，emitWebhook 异步发送不会阻塞）
+func (at *AutoTrader) onPaperLiquidation(pos Position, price, pnl float64) {
+	at.emitWebhook(WebhookEvent{
+		Event:    WebhookEventLiquidation,
+		Symbol:   pos.Symbol,
+		Side:     strings.ToLower(pos.Side),
+		Quantity: pos.Quantity,
+		Price:    price,
+		Leverage: pos.Leverage,
+		PnL:      pnl,
+	})
+}
+
+// onPaperStopOrder 模拟仓止损/止盈单触发时推送平仓事件（在模拟仓持有锁时调用，emitWebhook 异步发送不会阻塞）
+func (at *AutoTrader) onPaperStopOrder(order PaperStopOrder, pos Position, price, pnl float64) {
+	side := strings.ToLower(pos.Side)
+	at.emitWebhook(WebhookEvent{
+		Event:    WebhookEventClose,
+		Action:   "close_" + side,
+		Symbol:   pos.Symbol,
+		Side:     side,
+		Quantity: pos.Quantity,
+		Price:    price,
+		Leverage: pos.Leverage,
+		PnL:      pnl,
+	})
+}
+
+// emitOrderWebhook 按成交结果推送开仓/平仓事件
+func (at *AutoTrader) emitOrderWebhook(action, symbol string, leverage int, order map[string]interface{}) {
+	event := WebhookEvent{Event: WebhookEventOpen, Action: action, Symbol: symbol, Leverage: leverage}
+	if strings.HasPrefix(action, "close_") {
+		event.Event = WebhookEventClose
+	}
+	event.Side = strings.TrimPrefix(strings.TrimPrefix(action, "open_"), "close_")
+	event.Quantity, _ = order["quantity"].(float64)
+	event.Price, _ = order["price"].(float64)
+	event.PnL, _ = order["pnl"].(float64)
+	if id, ok := order["orderId"]; ok && id != nil {
+		event.OrderID = fmt.Sprint(id)
+	}
+	at.emitWebhook(event)
+}
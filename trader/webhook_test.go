@@ -0,0 +1,118 @@
+package trader
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// webhookRequest 测试服务器收到的请求
+type webhookRequest struct {
+	body      []byte
+	signature string
+	event     string
+}
+
+// TestWebhook_CloseEvent 测试平仓成交后推送 close 事件，请求体和 HMAC 签名正确
+func TestWebhook_CloseEvent(t *testing.T) {
+	received := make(chan webhookRequest, 4)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received <- webhookRequest{body: body, signature: r.Header.Get(WebhookSignatureHeader), event: r.Header.Get(WebhookEventHeader)}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	pt, err := NewPaperTrader(10000)
+	require.NoError(t, err)
+	price := 100.0
+	pt.priceFn = func(symbol string) (float64, error) { return price, nil }
+	_, err = pt.OpenLong("BTCUSDT", 2, 5)
+	require.NoError(t, err)
+
+	at := newJournalTestTrader(t.TempDir(), pt)
+	at.userID = "u1"
+	at.SetWebhook(WebhookConfig{URL: server.URL, Secret: "top-secret"})
+
+	price = 110
+	_, err = at.closeLong("BTCUSDT", 0, "", priceRef{})
+	require.NoError(t, err)
+
+	var req webhookRequest
+	select {
+	case req = <-received:
+	case <-time.After(5 * time.Second):
+		t.Fatal("未收到 Webhook 请求")
+	}
+
+	assert.Equal(t, WebhookEventClose, req.event)
+	assert.Equal(t, "sha256="+SignWebhookPayload("top-secret", req.body), req.signature)
+
+	var event WebhookEvent
+	require.NoError(t, json.Unmarshal(req.body, &event))
+	assert.Equal(t, WebhookEventClose, event.Event)
+	assert.Equal(t, "journal_trader", event.TraderID)
+	assert.Equal(t, "Journal Trader", event.TraderName)
+	assert.Equal(t, "u1", event.UserID)
+	assert.Equal(t, "BTCUSDT", event.Symbol)
+	assert.Equal(t, "long", event.Side)
+	assert.Equal(t, "close_long", event.Action)
+	assert.Equal(t, 2.0, event.Quantity)
+	assert.Equal(t, 110.0, event.Price)
+	assert.InDelta(t, 20.0, event.PnL, 1e-9)
+	assert.NotEmpty(t, event.OrderID)
+	assert.False(t, event.Timestamp.IsZero())
+}
+
+// TestWebhook_RetryWithBackoff 测试服务端 5xx 时按指数退避重试，4xx 不重试，重试次数有限
+func TestWebhook_RetryWithBackoff(t *testing.T) {
+	var calls atomic.Int32
+	status := []int{http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusOK}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := calls.Add(1)
+		w.WriteHeader(status[min(int(n), len(status))-1])
+	}))
+	defer server.Close()
+
+	sender := newWebhookSender(WebhookConfig{URL: server.URL})
+	var waits []time.Duration
+	sender.sleepFn = func(d time.Duration) { waits = append(waits, d) }
+
+	require.NoError(t, sender.deliver(WebhookEvent{Event: WebhookEventOpen}))
+	assert.Equal(t, int32(3), calls.Load())
+	assert.Equal(t, []time.Duration{webhookBaseBackoff, 2 * webhookBaseBackoff}, waits)
+
+	// 一直失败：最多发送 webhookMaxAttempts 次
+	calls.Store(0)
+	waits = nil
+	server.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+	assert.Error(t, sender.deliver(WebhookEvent{Event: WebhookEventRisk}))
+	assert.Equal(t, int32(webhookMaxAttempts), calls.Load())
+	assert.Len(t, waits, webhookMaxAttempts-1)
+
+	// 4xx 视为拒收，不重试
+	calls.Store(0)
+	server.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusBadRequest)
+	})
+	assert.Error(t, sender.deliver(WebhookEvent{Event: WebhookEventClose}))
+	assert.Equal(t, int32(1), calls.Load())
+}
+
+// TestWebhook_Disabled 测试未配置 URL 时不推送
+func TestWebhook_Disabled(t *testing.T) {
+	assert.Nil(t, newWebhookSender(WebhookConfig{URL: "  ", Secret: "x"}))
+	at := newJournalTestTrader(t.TempDir(), &MockTrader{})
+	at.emitWebhook(WebhookEvent{Event: WebhookEventOpen}) // 不应 panic
+}